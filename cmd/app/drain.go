@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// connectionDrainer tracks active websocket connections so the server can give
+// them time to finish during shutdown. While draining, the readiness endpoint
+// fails and new websocket connections are refused, so clients reconnect to
+// a replica that is not shutting down.
+type connectionDrainer struct {
+	active   int64
+	draining int32
+}
+
+// isWebsocketRequest returns true if the request is for a websocket upgrade.
+func isWebsocketRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// Wrap returns a handler that tracks websocket connections to the given handler.
+func (d *connectionDrainer) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.isDraining() && (r.URL.Path == "/api/readyz" || isWebsocketRequest(r)) {
+			http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		if !isWebsocketRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		atomic.AddInt64(&d.active, 1)
+		defer atomic.AddInt64(&d.active, -1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isDraining returns true if the server is shutting down.
+func (d *connectionDrainer) isDraining() bool {
+	return atomic.LoadInt32(&d.draining) == 1
+}
+
+// Drain stops accepting new websocket connections and blocks until all active
+// ones have closed, or the timeout is reached.
+func (d *connectionDrainer) Drain(timeout time.Duration) {
+	atomic.StoreInt32(&d.draining, 1)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		active := atomic.LoadInt64(&d.active)
+		if active == 0 {
+			applogger.Info("All websocket connections have been drained")
+			return
+		}
+		select {
+		case <-deadline.C:
+			applogger.Info(fmt.Sprintf("Drain timeout reached with %d active websocket connections, disconnecting them", active))
+			return
+		case <-ticker.C:
+		}
+	}
+}
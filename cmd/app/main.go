@@ -2,8 +2,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 
@@ -19,9 +24,9 @@ var applogger = logf.Log.WithName("app")
 
 func main() {
 	var vdiCluster string
-	var enableCORS bool
+	var drainTimeout time.Duration
 	pflag.CommandLine.StringVar(&vdiCluster, "vdi-cluster", "", "The VDICluster this application is serving")
-	pflag.CommandLine.BoolVar(&enableCORS, "enable-cors", false, "Add CORS headers to requests")
+	pflag.CommandLine.DurationVar(&drainTimeout, "drain-timeout", v1.DefaultDrainTimeout, "How long to wait for websocket connections to close when shutting down")
 	common.ParseFlagsAndSetupLogging()
 
 	common.PrintVersion(applogger)
@@ -34,16 +39,32 @@ func main() {
 	}
 
 	// build the server
-	srvr, err := newServer(cfg, vdiCluster, enableCORS)
+	drainer := &connectionDrainer{}
+	srvr, err := newServer(cfg, vdiCluster, drainer)
 	if err != nil {
 		applogger.Error(err, "Failed to build the server router")
 		os.Exit(1)
 	}
 
 	// serve
-	applogger.Info(fmt.Sprintf("Starting VDI cluster frontend on :%d", v1.WebPort))
-	if err := srvr.ListenAndServeTLS(tlsutil.ServerKeypair()); err != nil {
-		applogger.Error(err, "Failed to start https server")
-		os.Exit(1)
+	go func() {
+		applogger.Info(fmt.Sprintf("Starting VDI cluster frontend on :%d", v1.WebPort))
+		if err := srvr.ListenAndServeTLS(tlsutil.ServerKeypair()); err != nil && err != http.ErrServerClosed {
+			applogger.Error(err, "Failed to start https server")
+			os.Exit(1)
+		}
+	}()
+
+	// wait for a shutdown signal and drain connections
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	<-sigs
+	applogger.Info(fmt.Sprintf("Received shutdown signal, draining websocket connections for up to %s", drainTimeout))
+	drainer.Drain(drainTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srvr.Shutdown(ctx); err != nil {
+		applogger.Error(err, "Failed to gracefully shutdown https server")
 	}
 }
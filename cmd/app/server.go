@@ -1,16 +1,19 @@
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/api"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/tlsutil"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -46,7 +49,7 @@ func formatLog(writer io.Writer, params handlers.LogFormatterParams) {
 	}
 }
 
-func newServer(cfg *rest.Config, vdiCluster string, enableCORS bool) (*http.Server, error) {
+func newServer(cfg *rest.Config, vdiCluster string, drainer *connectionDrainer) (*http.Server, error) {
 	// build the api router with our kubeconfig
 	apiRouter, err := api.NewFromConfig(cfg, vdiCluster)
 	if err != nil {
@@ -62,19 +65,63 @@ func newServer(cfg *rest.Config, vdiCluster string, enableCORS bool) (*http.Serv
 
 	wrappedRouter := handlers.ProxyHeaders(
 		handlers.CompressHandler(
-			handlers.CustomLoggingHandler(os.Stdout, r, formatLog),
+			handlers.CustomLoggingHandler(os.Stdout, drainer.Wrap(r), formatLog),
 		),
 	)
 
-	if enableCORS {
-		wrappedRouter = handlers.CORS()(wrappedRouter)
+	// gRPC calls are served on the same port over HTTP/2
+	grpcHandler := apiRouter.GRPCHandler()
+
+	// CORS can be toggled on the VDICluster at runtime
+	corsRouter := handlers.CORS()(wrappedRouter)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcHandler.ServeHTTP(w, r)
+			return
+		}
+		if apiRouter.CORSEnabled() {
+			corsRouter.ServeHTTP(w, r)
+			return
+		}
+		wrappedRouter.ServeHTTP(w, r)
+	})
+
+	tlsConfig, err := newTLSConfig(apiRouter)
+	if err != nil {
+		return nil, err
 	}
 
 	return &http.Server{
-		Handler: wrappedRouter,
-		Addr:    fmt.Sprintf(":%d", v1.WebPort),
+		Handler:   handler,
+		Addr:      fmt.Sprintf(":%d", v1.WebPort),
+		TLSConfig: tlsConfig,
 		// TODO: make these configurable (currently high for large dir transfers)
 		WriteTimeout: 300 * time.Second,
 		ReadTimeout:  300 * time.Second,
 	}, nil
 }
+
+// newTLSConfig returns the TLS configuration for the server. Client certificates
+// are only requested while the VDICluster authenticates users with them, so that
+// browsers do not prompt for a certificate otherwise. They are verified by the
+// auth provider and not during the handshake.
+func newTLSConfig(apiRouter api.DesktopAPI) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(tlsutil.ServerKeypair())
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		// gRPC calls require HTTP/2
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		if !apiRouter.RequestClientCerts() {
+			return nil, nil
+		}
+		clientConfig := config.Clone()
+		clientConfig.ClientAuth = tls.RequestClientCert
+		return clientConfig, nil
+	}
+	return config, nil
+}
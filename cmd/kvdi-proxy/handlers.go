@@ -1,8 +1,9 @@
 package main
 
 import (
-	"context"
+	"bytes"
 	"fmt"
+	"image/png"
 	"io"
 	"io/ioutil"
 	"net"
@@ -12,12 +13,15 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/audio"
 	"github.com/tinyzimmer/kvdi/pkg/audio/pa"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/rfb"
+	"github.com/tinyzimmer/kvdi/pkg/util/usbip"
 
 	"golang.org/x/net/websocket"
 )
@@ -63,7 +67,73 @@ func setupPulseAudio(manager *pa.DeviceManager) error {
 	return nil
 }
 
+// startPulseAudio sets up the pulse-audio devices for a display connection and
+// returns a function for cleaning them up. If the devices cannot be set up, audio
+// is disabled and the returned function does nothing.
+func startPulseAudio() func() {
+	log.Info("Setting up pulse-audio devices")
+
+	paDevices, err := pa.NewDeviceManager(&pa.DeviceManagerOpts{
+		PulseServer: getPulseServer(),
+	})
+	if err != nil {
+		log.Error(err, "Failed to create new PA device manager, audio will be disabled")
+		return func() {}
+	}
+
+	destroy := func() {
+		if derr := paDevices.Destroy(); derr != nil {
+			log.Error(derr, "Failed to cleanup device manager")
+		}
+	}
+	if err := setupPulseAudio(paDevices); err != nil {
+		destroy()
+		log.Error(err, "Failure while setting up pulse audio, audio will be disabled")
+		return func() {}
+	}
+	return destroy
+}
+
+// getProxyOpts builds the options for filtering a display connection from the
+// parameters set by the API and the clipboard policy of the template. If a
+// watermark was requested, the time of the connection is appended to its text.
+// Text input is always accepted, so that the web client can pass through text
+// composed with an input method. The display stream is throttled to the limits
+// of the template.
+func getProxyOpts(wsconn *websocket.Conn, viewOnly bool) *rfb.ProxyOpts {
+	query := wsconn.Request().URL.Query()
+	policy := v1alpha1.ClipboardPolicy(clipboardPolicy)
+	opts := &rfb.ProxyOpts{
+		ViewOnly:            viewOnly,
+		TextInput:           true,
+		DisableClipboardIn:  query.Get(v1.DisableClipboardInQueryParam) == "true" || !policy.AllowsIn(),
+		DisableClipboardOut: query.Get(v1.DisableClipboardOutQueryParam) == "true" || !policy.AllowsOut(),
+		Throttle:            policyThrottle,
+	}
+	if text := query.Get(v1.WatermarkQueryParam); text != "" {
+		opts.Watermark = rfb.NewWatermark(fmt.Sprintf("%s %s", text, time.Now().UTC().Format("2006-01-02 15:04 UTC")))
+	}
+	return opts
+}
+
 func websockifyHandler(wsconn *websocket.Conn) {
+	if rdpEnabled() {
+		rdpHandler(wsconn, "display", false, false)
+		return
+	}
+
+	opts := getProxyOpts(wsconn, false)
+	// the user's own connections follow the settings they chose
+	opts.Throttle = displayThrottle
+
+	setKeyboardLayout(wsconn)
+
+	// resumable connections share a session with the server across reconnects
+	if token := getResumeToken(wsconn, opts); token != "" {
+		resumableDisplayHandler(wsconn, token, opts)
+		return
+	}
+
 	log.Info(fmt.Sprintf("Received display proxy request, connecting to %s", vncAddr))
 	vncConn, err := net.Dial(vncConnectProto, vncConnectAddr)
 
@@ -72,64 +142,104 @@ func websockifyHandler(wsconn *websocket.Conn) {
 		wsconn.Close()
 		return
 	}
+	defer vncConn.Close()
 
 	log.Info("Connection to vnc server established")
 
-	log.Info("Setting up pulse-audio devices")
+	defer startPulseAudio()()
 
-	paDevices, err := pa.NewDeviceManager(&pa.DeviceManagerOpts{
-		PulseServer: getPulseServer(),
-	})
-	if err != nil {
-		log.Error(err, "Failed to create new PA device manager, audio will be disabled")
-	}
+	log.Info("Starting display proxy")
 
-	if paDevices != nil {
-		if err := setupPulseAudio(paDevices); err != nil {
-			if derr := paDevices.Destroy(); derr != nil {
-				log.Error(derr, "Failed to cleanup device manager")
-			}
-			log.Error(err, "Failure while setting up pulse audio, audio will be disabled")
-		} else {
-			defer func() {
-				if derr := paDevices.Destroy(); derr != nil {
-					log.Error(derr, "Failed to cleanup device manager")
-				}
-			}()
+	wsconn.PayloadType = websocket.BinaryFrame
+
+	// wrap the connection so we can log metrics
+	watcher := apiutil.NewWebsocketWatcher(wsconn)
+
+	stChan := logWatcherMetrics("display", watcher)
+	defer func() { stChan <- struct{}{} }()
+
+	// recorded sessions are refused if the recording cannot be started
+	if id := wsconn.Request().URL.Query().Get(v1.RecordingQueryParam); id != "" {
+		rec, finish, err := startRecording(id)
+		if err != nil {
+			log.Error(err, "Failed to start session recording")
+			return
 		}
+		defer finish()
+		opts.Recorder = rec
 	}
 
-	log.Info("Starting display proxy")
+	// block until either side of the connection is finished
+	if err := rfb.Proxy(watcher, vncConn, opts); err != nil {
+		log.Error(err, "Error while proxying display stream")
+	}
+}
+
+func wsViewOnlyHandler(wsconn *websocket.Conn) {
+	if rdpEnabled() {
+		rdpHandler(wsconn, "view", true, true)
+		return
+	}
+
+	log.Info(fmt.Sprintf("Received view-only display proxy request, connecting to %s", vncAddr))
+	vncConn, err := net.Dial(vncConnectProto, vncConnectAddr)
+
+	if err != nil {
+		log.Error(err, "Failed to connect to display server")
+		wsconn.Close()
+		return
+	}
+	defer vncConn.Close()
+
+	log.Info("Starting view-only display proxy")
 
 	wsconn.PayloadType = websocket.BinaryFrame
 
-	// wrap the connection so we can log metrics
 	watcher := apiutil.NewWebsocketWatcher(wsconn)
 
-	stChan := logWatcherMetrics("display", watcher)
+	stChan := logWatcherMetrics("view", watcher)
 	defer func() { stChan <- struct{}{} }()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	// block until either side of the connection is finished
+	if err := rfb.Proxy(watcher, vncConn, getProxyOpts(wsconn, true)); err != nil {
+		log.Error(err, "Error while proxying view-only display stream")
+	}
 
-	// Copy client connection to the server
-	go func() {
-		if _, err := io.Copy(vncConn, watcher); err != nil {
-			log.Error(err, "Error while copying stream from websocket connection to display socket")
-		}
-		cancel()
-	}()
+	log.Info("View-only display proxy ended")
+}
 
-	// Copy server connection to the client
-	go func() {
-		if _, err := io.Copy(watcher, vncConn); err != nil {
-			log.Error(err, "Error while copying stream from display socket to websocket connection")
-		}
-		cancel()
-	}()
+func wsShadowHandler(wsconn *websocket.Conn) {
+	if rdpEnabled() {
+		rdpHandler(wsconn, "shadow", true, wsconn.Request().URL.Query().Get(v1.ShadowInteractiveQueryParam) != "true")
+		return
+	}
+
+	log.Info(fmt.Sprintf("Received shadow display proxy request, connecting to %s", vncAddr))
+	vncConn, err := net.Dial(vncConnectProto, vncConnectAddr)
 
-	// block until the context is finished
-	for range ctx.Done() {
+	if err != nil {
+		log.Error(err, "Failed to connect to display server")
+		wsconn.Close()
+		return
 	}
+	defer vncConn.Close()
+
+	interactive := wsconn.Request().URL.Query().Get(v1.ShadowInteractiveQueryParam) == "true"
+	log.Info("Starting shadow display proxy", "Interactive", interactive)
+
+	wsconn.PayloadType = websocket.BinaryFrame
+
+	watcher := apiutil.NewWebsocketWatcher(wsconn)
+
+	stChan := logWatcherMetrics("shadow", watcher)
+	defer func() { stChan <- struct{}{} }()
+
+	// block until either side of the connection is finished
+	if err := rfb.Proxy(watcher, vncConn, getProxyOpts(wsconn, !interactive)); err != nil {
+		log.Error(err, "Error while proxying shadow display stream")
+	}
+
+	log.Info("Shadow display proxy ended")
 }
 
 func wsAudioHandler(wsconn *websocket.Conn) {
@@ -166,9 +276,15 @@ func wsAudioHandler(wsconn *websocket.Conn) {
 		audioBuffer.Close()
 	}()
 
-	// Copy any received recording data to the buffer
+	// Copy any received microphone data to the buffer. When the template does
+	// not allow microphone input it is still read off the connection, but discarded.
+	var mic io.Writer = audioBuffer
+	if !microphoneEnabled {
+		log.Info("Microphone input is disabled for this desktop session, discarding client audio")
+		mic = ioutil.Discard
+	}
 	go func() {
-		if _, err := io.Copy(audioBuffer, watcher); err != nil {
+		if _, err := io.Copy(mic, watcher); err != nil {
 			if !errors.IsBrokenPipeError(err) {
 				log.Error(err, "Error while copying from websocket connection to audio buffer")
 			}
@@ -188,7 +304,96 @@ func wsAudioHandler(wsconn *websocket.Conn) {
 	log.Info("Audio stream proxy ended")
 }
 
+func wsUSBHandler(wsconn *websocket.Conn) {
+	defer wsconn.Close()
+
+	if len(usbAllowlist) == 0 {
+		log.Info("USB redirection is disabled for this desktop session")
+		return
+	}
+
+	busID := wsconn.Request().URL.Query().Get(v1.USBBusIDQueryParam)
+	log.Info(fmt.Sprintf("Received USB redirection request for device %s", busID))
+
+	wsconn.PayloadType = websocket.BinaryFrame
+
+	watcher := apiutil.NewWebsocketWatcher(wsconn)
+	stChan := logWatcherMetrics("usb", watcher)
+	defer func() { stChan <- struct{}{} }()
+
+	// The client is expected to speak USB/IP as the exporting side
+	dev, err := usbip.Import(watcher, busID, usbAllowlist)
+	if err != nil {
+		log.Error(err, "Failed to import USB device from client")
+		return
+	}
+
+	port, hostConn, err := usbip.Attach(dev)
+	if err != nil {
+		log.Error(err, "Failed to attach USB device to vhci-hcd")
+		return
+	}
+	defer hostConn.Close()
+	log.Info(fmt.Sprintf("Attached USB device %s to port %d", dev.String(), port))
+
+	if err := usbip.Proxy(watcher, hostConn, usbAllowlist); err != nil {
+		if !errors.IsBrokenPipeError(err) {
+			log.Error(err, "Error while proxying USB device")
+		}
+	}
+
+	if err := usbip.Detach(port); err != nil {
+		log.Error(err, "Failed to detach USB device from vhci-hcd")
+	}
+
+	log.Info("USB redirection ended")
+}
+
+func screenshotHandler(w http.ResponseWriter, r *http.Request) {
+	if rdpEnabled() {
+		apiutil.ReturnAPIError(errors.New("Screenshots are not supported for desktops served over RDP"), w)
+		return
+	}
+
+	log.Info(fmt.Sprintf("Received screenshot request, connecting to %s", vncAddr))
+	vncConn, err := net.Dial(vncConnectProto, vncConnectAddr)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer vncConn.Close()
+
+	if err := vncConn.SetDeadline(time.Now().Add(time.Second * 30)); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	img, err := rfb.Capture(vncConn)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, &buf); err != nil {
+		log.Error(err, "Failed to copy screenshot to response buffer")
+	}
+}
+
 func statFileHandler(w http.ResponseWriter, r *http.Request) {
+	// browsing is only needed for downloads and would expose file names otherwise
+	if !v1alpha1.FileTransferPolicy(fileTransferPolicy).AllowsDownload() {
+		apiutil.ReturnAPIForbidden(nil, "File downloads are disabled for this desktop session", w)
+		return
+	}
 	path, err := getLocalPathFromRequest(r)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
@@ -286,6 +491,11 @@ func downloadDir(w http.ResponseWriter, path string) {
 func downloadFileHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 
+	if !v1alpha1.FileTransferPolicy(fileTransferPolicy).AllowsDownload() {
+		apiutil.ReturnAPIForbidden(nil, "File downloads are disabled for this desktop session", w)
+		return
+	}
+
 	path, err := getLocalPathFromRequest(r)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
@@ -308,6 +518,10 @@ func downloadFileHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func uploadFileHandler(w http.ResponseWriter, r *http.Request) {
+	if !v1alpha1.FileTransferPolicy(fileTransferPolicy).AllowsUpload() {
+		apiutil.ReturnAPIForbidden(nil, "File uploads are disabled for this desktop session", w)
+		return
+	}
 	uploadDir := filepath.Join(v1.DesktopHomeMntPath, "Uploads")
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
 		apiutil.ReturnAPIError(err, w)
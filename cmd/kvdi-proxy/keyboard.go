@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"golang.org/x/net/websocket"
+)
+
+// setKeyboardLayout writes the keyboard layout passed by the API for a display
+// connection to the desktop, which applies it to the display. When no layout was
+// passed, the layout of the display is left unchanged.
+func setKeyboardLayout(wsconn *websocket.Conn) {
+	query := wsconn.Request().URL.Query()
+	layout := &v1.KeyboardLayoutConfig{
+		Layout:  query.Get(v1.KeyboardLayoutQueryParam),
+		Variant: query.Get(v1.KeyboardVariantQueryParam),
+	}
+	if layout.Layout == "" {
+		return
+	}
+	if err := layout.Validate(); err != nil {
+		log.Error(err, "Ignoring invalid keyboard layout")
+		return
+	}
+	data := strings.TrimSpace(fmt.Sprintf("%s %s", layout.Layout, layout.Variant)) + "\n"
+	if err := ioutil.WriteFile(v1.KeyboardLayoutPath, []byte(data), 0644); err != nil {
+		log.Error(err, "Failed to set keyboard layout")
+		return
+	}
+	log.Info(fmt.Sprintf("Set keyboard layout to %s", strings.TrimSpace(data)))
+}
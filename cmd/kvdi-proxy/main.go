@@ -11,6 +11,8 @@ import (
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/tlsutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/tracing"
+	"github.com/tinyzimmer/kvdi/pkg/util/usbip"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -30,15 +32,53 @@ var vncAddr string
 var userID int
 var vncConnectProto, vncConnectAddr string
 
+// smart card configurations
+var smartCardAddr string
+
+// clipboard and file transfer policies from the DesktopTemplate
+var clipboardPolicy, fileTransferPolicy string
+
+// whether the DesktopTemplate accepts microphone input
+var microphoneEnabled bool
+
+// how long a dropped display connection can be resumed for
+var reconnectTimeout time.Duration
+
+// the classes of USB devices the DesktopTemplate allows redirecting
+var usbClasses []string
+var usbAllowlist usbip.Allowlist
+
 // main application entry point
 func main() {
 
 	// parse flags and setup logging
 	pflag.CommandLine.StringVar(&vncAddr, "vnc-addr", "unix:///var/run/kvdi/display.sock", "The tcp or unix-socket address of the vnc server")
+	pflag.CommandLine.StringVar(&rdpAddr, "rdp-addr", "", "The address of an RDP server to serve the display from through guacd, the vnc server is used if empty")
+	pflag.CommandLine.StringVar(&rdpSecurity, "rdp-security", "any", "The security mode to negotiate with the RDP server, one of any, nla, tls, or rdp")
+	pflag.CommandLine.StringVar(&rdpDomain, "rdp-domain", "", "The domain to log into the RDP server with")
+	pflag.CommandLine.BoolVar(&rdpIgnoreCert, "rdp-ignore-cert", false, "Accept the certificate presented by the RDP server without verifying it")
+	pflag.CommandLine.StringVar(&smartCardAddr, "smartcard-addr", "", "The unix-socket address of the pcscd bridge, smart card redirection is disabled if empty")
+	pflag.CommandLine.StringVar(&printDir, "print-dir", "", "The directory the desktop's virtual printer writes PDFs to, printing is disabled if empty")
 	pflag.CommandLine.IntVar(&userID, "user-id", 9000, "The ID of the main user in the desktop container")
+	pflag.CommandLine.StringVar(&clipboardPolicy, "clipboard", "bidirectional", "The directions clipboard contents are synced, one of none, one-way-in, one-way-out, or bidirectional")
+	pflag.CommandLine.StringVar(&fileTransferPolicy, "file-transfer", "bidirectional", "The directions files can be transferred, one of none, upload, download, or bidirectional")
+	pflag.CommandLine.BoolVar(&microphoneEnabled, "microphone", false, "Write audio received from clients to the virtual microphone, otherwise it is discarded")
+	pflag.CommandLine.DurationVar(&reconnectTimeout, "reconnect-timeout", 0, "How long a dropped display connection is held open for the client to resume, resuming is disabled if zero")
+	pflag.CommandLine.StringSliceVar(&usbClasses, "usb-classes", nil, "The classes of USB devices clients can redirect, USB redirection is disabled if empty")
+	pflag.CommandLine.IntVar(&maxQuality, "max-quality", -1, "The highest JPEG quality level, from 0 to 9, the display is encoded with, unlimited if negative")
+	pflag.CommandLine.IntVar(&maxFrameRate, "max-frame-rate", 0, "The most frames per second sent to each display client, unlimited if zero")
+	pflag.CommandLine.Int64Var(&maxBandwidth, "max-bandwidth", 0, "The most bytes per second sent to each display client, unlimited if zero")
 	common.ParseFlagsAndSetupLogging()
 	common.PrintVersion(log)
 
+	var err error
+	if usbAllowlist, err = usbip.NewAllowlist(usbClasses); err != nil {
+		log.Error(err, "Invalid USB device classes")
+		os.Exit(1)
+	}
+
+	setupDisplayThrottles()
+
 	// Set the location of our vnc socket appropriatly
 	if strings.HasPrefix(vncAddr, "tcp://") {
 		vncConnectProto = "tcp"
@@ -70,6 +110,9 @@ func main() {
 func newServer() (*http.Server, error) {
 	r := mux.NewRouter()
 
+	// Continue the traces of requests from the API when tracing is enabled
+	r.Use(newTracingMiddleware(tracing.FromEnv("kvdi-proxy")))
+
 	// The websockify route is in charge of proxying noVNC conncetions to the local
 	// VNC socket. This route is pretty bulletproof.
 	r.Path("/api/desktops/ws/{namespace}/{name}/display").Handler(&websocket.Server{
@@ -77,6 +120,27 @@ func newServer() (*http.Server, error) {
 		Handler:   websockifyHandler,
 	})
 
+	// The view route proxies noVNC connections the same as above, except that
+	// any input events sent by the client are dropped before reaching the server.
+	r.Path("/api/desktops/ws/{namespace}/{name}/view").Handler(&websocket.Server{
+		Handshake: wsHandshake,
+		Handler:   wsViewOnlyHandler,
+	})
+
+	// The shadow route lets another user attach to the display. The API only
+	// passes the interactive parameter when the user is allowed to send input.
+	r.Path("/api/desktops/ws/{namespace}/{name}/shadow").Handler(&websocket.Server{
+		Handshake: wsHandshake,
+		Handler:   wsShadowHandler,
+	})
+
+	// The settings route lets the user change the quality, frame rate, and
+	// bandwidth of their display stream while connected.
+	r.Path("/api/desktops/ws/{namespace}/{name}/settings").Handler(&websocket.Server{
+		Handshake: wsHandshake,
+		Handler:   wsSettingsHandler,
+	})
+
 	// This route creates a recorder on the local pulseaudio sink and ships
 	// the data back to the client over a websocket.
 	r.Path("/api/desktops/ws/{namespace}/{name}/audio").Handler(&websocket.Server{
@@ -84,6 +148,30 @@ func newServer() (*http.Server, error) {
 		Handler:   wsAudioHandler,
 	})
 
+	// This route forwards smart card APDUs from the client to the pcscd bridge
+	// running in the desktop container, when enabled in the DesktopTemplate.
+	r.Path("/api/desktops/ws/{namespace}/{name}/smartcard").Handler(&websocket.Server{
+		Handshake: wsHandshake,
+		Handler:   wsSmartCardHandler,
+	})
+
+	// This route delivers PDFs written by the virtual printer in the desktop to
+	// the client, when enabled in the DesktopTemplate.
+	r.Path("/api/desktops/ws/{namespace}/{name}/printer").Handler(&websocket.Server{
+		Handshake: wsHandshake,
+		Handler:   wsPrinterHandler,
+	})
+
+	// This route imports a USB device exported by the client and attaches it to
+	// the node, when enabled in the DesktopTemplate.
+	r.Path("/api/desktops/ws/{namespace}/{name}/usb").Handler(&websocket.Server{
+		Handshake: wsHandshake,
+		Handler:   wsUSBHandler,
+	})
+
+	// This route captures the current contents of the display as a PNG.
+	r.Path("/api/sessions/{namespace}/{name}/screenshot").Methods("POST").HandlerFunc(screenshotHandler)
+
 	// This route is for doing a stat of files in the user's home directory when
 	// enabled in the DesktopTemplate.
 	r.PathPrefix("/api/desktops/fs/{namespace}/{name}/stat/").HandlerFunc(statFileHandler)
@@ -96,6 +184,12 @@ func newServer() (*http.Server, error) {
 	// DesktopTemplate.
 	r.Path("/api/desktops/fs/{namespace}/{name}/put").HandlerFunc(uploadFileHandler)
 
+	// These routes are used by the API to retrieve recordings of display sessions
+	// when enabled in the DesktopTemplate. Recordings are removed once uploaded.
+	r.Path("/api/recordings").Methods("GET").HandlerFunc(listRecordingsHandler)
+	r.Path("/api/recordings/{recording}").Methods("GET").HandlerFunc(getRecordingHandler)
+	r.Path("/api/recordings/{recording}").Methods("DELETE").HandlerFunc(deleteRecordingHandler)
+
 	wrapped := handlers.CustomLoggingHandler(os.Stdout, r, formatLog)
 
 	tlsConfig, err := tlsutil.NewServerTLSConfig()
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"golang.org/x/net/websocket"
+)
+
+// the directory the virtual printer in the desktop writes PDFs to
+var printDir string
+
+// how often the spool directory is checked for new print jobs
+var printPollInterval = time.Second
+
+// how long a PDF must go unmodified before it is considered finished
+var printSettleTime = 2 * time.Second
+
+// listPrintJobs returns the finished PDFs in the spool directory, oldest first.
+func listPrintJobs() ([]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(printDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	jobs := make([]os.FileInfo, 0)
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(strings.ToLower(file.Name()), ".pdf") {
+			continue
+		}
+		if time.Since(file.ModTime()) < printSettleTime {
+			continue
+		}
+		jobs = append(jobs, file)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ModTime().Before(jobs[j].ModTime()) })
+	return jobs, nil
+}
+
+// sendPrintJob sends a finished PDF to the client and removes it from the spool
+// directory. Jobs that are too large to deliver are discarded.
+func sendPrintJob(wsconn *websocket.Conn, file os.FileInfo) error {
+	path := filepath.Join(printDir, file.Name())
+	if file.Size() > v1.MaxPrintJobSize {
+		log.Info(fmt.Sprintf("Discarding print job %s larger than %d bytes", file.Name(), v1.MaxPrintJobSize))
+		return os.Remove(path)
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := websocket.JSON.Send(wsconn, &v1.PrintJob{Name: file.Name(), Size: int64(len(body))}); err != nil {
+		return err
+	}
+	if err := websocket.Message.Send(wsconn, body); err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Delivered print job %s to client", file.Name()))
+	return os.Remove(path)
+}
+
+// wsPrinterHandler delivers print jobs from the virtual printer in the desktop to
+// the client until the connection is closed. Jobs printed while no client is
+// connected are delivered once one connects.
+func wsPrinterHandler(wsconn *websocket.Conn) {
+	if printDir == "" {
+		log.Info("Printing is disabled for this desktop session")
+		wsconn.Close()
+		return
+	}
+	defer wsconn.Close()
+
+	log.Info("Received printer proxy request, watching for print jobs")
+
+	// Nothing is expected from the client, so reads are only used to notice when
+	// the connection closes.
+	closed := make(chan struct{})
+	go func() {
+		var msg []byte
+		for {
+			if err := websocket.Message.Receive(wsconn, &msg); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(printPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			log.Info("Printer proxy ended")
+			return
+		case <-ticker.C:
+			jobs, err := listPrintJobs()
+			if err != nil {
+				log.Error(err, "Failed to list print jobs")
+				continue
+			}
+			for _, job := range jobs {
+				if err := sendPrintJob(wsconn, job); err != nil {
+					log.Error(err, "Failed to deliver print job", "Job", job.Name())
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/guac"
+
+	"golang.org/x/net/websocket"
+)
+
+// The display size used when the client does not provide one
+const (
+	defaultRDPWidth  = 1024
+	defaultRDPHeight = 768
+	defaultRDPDPI    = 96
+)
+
+// rdp configurations
+var rdpAddr, rdpSecurity, rdpDomain string
+var rdpIgnoreCert bool
+
+// rdpConnectionID is the ID of the guacd connection opened by the desktop user.
+// View-only and shadow connections join this connection, since opening another
+// would start a new session on the RDP server.
+var rdpConnectionID string
+var rdpConnectionMux sync.Mutex
+
+// rdpEnabled returns true if the display is served over RDP.
+func rdpEnabled() bool { return rdpAddr != "" }
+
+// readRDPCredential reads the given key from the mounted credentials secret. An
+// empty string is returned if the key does not exist.
+func readRDPCredential(key string) string {
+	data, err := ioutil.ReadFile(filepath.Join(v1.RDPCredentialsMountPath, key))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// queryInt returns the integer value of the given query parameter, or the default
+// if it is missing or invalid.
+func queryInt(wsconn *websocket.Conn, param string, def int) int {
+	val, err := strconv.Atoi(wsconn.Request().URL.Query().Get(param))
+	if err != nil || val <= 0 {
+		return def
+	}
+	return val
+}
+
+// getRDPConfig builds the guacd configuration for a display connection. When join
+// is set the connection joins the desktop user's, otherwise a new RDP session is
+// opened with the injected credentials.
+func getRDPConfig(wsconn *websocket.Conn, join, viewOnly bool) (*guac.Config, error) {
+	query := wsconn.Request().URL.Query()
+	cfg := &guac.Config{
+		Width:          queryInt(wsconn, "width", defaultRDPWidth),
+		Height:         queryInt(wsconn, "height", defaultRDPHeight),
+		DPI:            queryInt(wsconn, "dpi", defaultRDPDPI),
+		AudioMimetypes: []string{"audio/L16", "audio/L8"},
+		ImageMimetypes: []string{"image/png", "image/jpeg", "image/webp"},
+	}
+
+	if join {
+		rdpConnectionMux.Lock()
+		defer rdpConnectionMux.Unlock()
+		if rdpConnectionID == "" {
+			return nil, fmt.Errorf("There is no RDP session to join")
+		}
+		cfg.Protocol = rdpConnectionID
+		cfg.Parameters = map[string]string{"read-only": strconv.FormatBool(viewOnly)}
+		return cfg, nil
+	}
+
+	host, port, err := net.SplitHostPort(rdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	username := readRDPCredential("username")
+	if username == "" {
+		username = query.Get(v1.UsernameQueryParam)
+	}
+	policy := v1alpha1.ClipboardPolicy(clipboardPolicy)
+	cfg.Protocol = "rdp"
+	cfg.Parameters = map[string]string{
+		"hostname":      host,
+		"port":          port,
+		"security":      rdpSecurity,
+		"ignore-cert":   strconv.FormatBool(rdpIgnoreCert),
+		"domain":        rdpDomain,
+		"username":      username,
+		"password":      readRDPCredential("password"),
+		"resize-method": "display-update",
+		"disable-paste": strconv.FormatBool(query.Get(v1.DisableClipboardInQueryParam) == "true" || !policy.AllowsIn()),
+		"disable-copy":  strconv.FormatBool(query.Get(v1.DisableClipboardOutQueryParam) == "true" || !policy.AllowsOut()),
+		"read-only":     strconv.FormatBool(viewOnly),
+	}
+	return cfg, nil
+}
+
+// rdpHandler serves a display connection through guacd. The desktop user's
+// connection opens the RDP session, and view-only and shadow connections join it.
+func rdpHandler(wsconn *websocket.Conn, proxyType string, join, viewOnly bool) {
+	// watermarks and recordings are only implemented for VNC streams, so connections
+	// requiring them are refused rather than served without them
+	query := wsconn.Request().URL.Query()
+	if query.Get(v1.WatermarkQueryParam) != "" || query.Get(v1.RecordingQueryParam) != "" {
+		log.Info("Watermarks and session recordings are not supported over RDP, refusing connection")
+		wsconn.Close()
+		return
+	}
+
+	cfg, err := getRDPConfig(wsconn, join, viewOnly)
+	if err != nil {
+		log.Error(err, "Failed to build RDP connection configuration")
+		wsconn.Close()
+		return
+	}
+
+	log.Info(fmt.Sprintf("Received %s proxy request, connecting to %s through guacd", proxyType, rdpAddr))
+	guacdConn, err := net.Dial("tcp", v1.DefaultGuacdAddr)
+	if err != nil {
+		log.Error(err, "Failed to connect to guacd")
+		wsconn.Close()
+		return
+	}
+	defer guacdConn.Close()
+
+	rdr := bufio.NewReader(guacdConn)
+	if err := guacdConn.SetDeadline(time.Now().Add(time.Second * 30)); err != nil {
+		log.Error(err, "Failed to set handshake deadline")
+		wsconn.Close()
+		return
+	}
+	id, err := guac.Handshake(guacdConn, rdr, cfg)
+	if err != nil {
+		log.Error(err, "Failed to complete guacd handshake")
+		wsconn.Close()
+		return
+	}
+	if err := guacdConn.SetDeadline(time.Time{}); err != nil {
+		log.Error(err, "Failed to clear handshake deadline")
+		wsconn.Close()
+		return
+	}
+
+	if !join {
+		rdpConnectionMux.Lock()
+		rdpConnectionID = id
+		rdpConnectionMux.Unlock()
+		defer func() {
+			rdpConnectionMux.Lock()
+			defer rdpConnectionMux.Unlock()
+			if rdpConnectionID == id {
+				rdpConnectionID = ""
+			}
+		}()
+	}
+
+	log.Info(fmt.Sprintf("Starting %s proxy", proxyType), "Connection", id)
+
+	// the guacamole client expects each message to contain whole instructions
+	wsconn.PayloadType = websocket.TextFrame
+
+	watcher := apiutil.NewWebsocketWatcher(wsconn)
+
+	stChan := logWatcherMetrics(proxyType, watcher)
+	defer func() { stChan <- struct{}{} }()
+
+	// block until either side of the connection is finished
+	if err := guac.Proxy(watcher, guacdConn, rdr, id); err != nil {
+		log.Error(err, fmt.Sprintf("Error while proxying %s stream", proxyType))
+	}
+
+	log.Info(fmt.Sprintf("%s proxy ended", strings.Title(proxyType)))
+}
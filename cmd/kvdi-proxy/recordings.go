@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rfb"
+
+	"github.com/gorilla/mux"
+)
+
+const recordingExt = ".fbs"
+
+// activeRecordings tracks the recordings currently being written so they are not
+// handed to the API before they are finished.
+var activeRecordings = make(map[string]struct{})
+var recordingsMux sync.Mutex
+
+// getRecordingPath returns the local path for the recording with the given ID.
+func getRecordingPath(id string) (string, error) {
+	if _, _, _, err := v1.ParseRecordingID(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(v1.DesktopRecordingsMntPath, id+recordingExt), nil
+}
+
+// startRecording creates a new recording with the given ID. The returned function
+// must be called to finish the recording.
+func startRecording(id string) (*rfb.Recorder, func(), error) {
+	path, err := getRecordingPath(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(v1.DesktopRecordingsMntPath, 0700); err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	buf := bufio.NewWriter(f)
+	rec, err := rfb.NewRecorder(buf)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	recordingsMux.Lock()
+	activeRecordings[id] = struct{}{}
+	recordingsMux.Unlock()
+
+	log.Info(fmt.Sprintf("Recording display session to %s", path))
+	return rec, func() {
+		if err := buf.Flush(); err != nil {
+			log.Error(err, "Failed to flush session recording")
+		}
+		if err := f.Close(); err != nil {
+			log.Error(err, "Failed to close session recording")
+		}
+		recordingsMux.Lock()
+		delete(activeRecordings, id)
+		recordingsMux.Unlock()
+	}, nil
+}
+
+// listRecordingsHandler returns the IDs of the finished recordings waiting to be
+// uploaded.
+func listRecordingsHandler(w http.ResponseWriter, r *http.Request) {
+	ids := make([]string, 0)
+	files, err := ioutil.ReadDir(v1.DesktopRecordingsMntPath)
+	if err != nil && !os.IsNotExist(err) {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	recordingsMux.Lock()
+	defer recordingsMux.Unlock()
+	for _, file := range files {
+		id := strings.TrimSuffix(file.Name(), recordingExt)
+		if _, ok := activeRecordings[id]; ok || !strings.HasSuffix(file.Name(), recordingExt) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	apiutil.WriteJSON(ids, w)
+}
+
+// getRecordingHandler returns the contents of a finished recording.
+func getRecordingHandler(w http.ResponseWriter, r *http.Request) {
+	path, err := getRecordingPath(mux.Vars(r)["recording"])
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer f.Close()
+	finfo, err := f.Stat()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(finfo.Size(), 10))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, f); err != nil {
+		log.Error(err, "Failed to copy recording to response buffer")
+	}
+}
+
+// deleteRecordingHandler removes a recording once it has been uploaded.
+func deleteRecordingHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["recording"]
+	path, err := getRecordingPath(id)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	recordingsMux.Lock()
+	defer recordingsMux.Unlock()
+	if _, ok := activeRecordings[id]; ok {
+		apiutil.ReturnAPIError(fmt.Errorf("Recording %s is still in progress", id), w)
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
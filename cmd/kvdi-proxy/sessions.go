@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/rfb"
+
+	"golang.org/x/net/websocket"
+)
+
+// displaySession is a resumable display session along with the options it was
+// started with.
+type displaySession struct {
+	*rfb.Session
+	opts rfb.ProxyOpts
+}
+
+// displaySessions tracks the resumable display sessions by their resume token.
+var displaySessions = make(map[string]*displaySession)
+var displaySessionsMux sync.Mutex
+
+// getResumeToken returns the key for the resumable display session requested by
+// the connection. An empty string is returned if resuming is disabled, the client
+// did not request it, or the connection is watermarked or recorded. Tokens are
+// scoped to the user set by the API so they cannot be used to take over another
+// user's session.
+func getResumeToken(wsconn *websocket.Conn, opts *rfb.ProxyOpts) string {
+	if reconnectTimeout <= 0 || opts.Watermark != nil {
+		return ""
+	}
+	query := wsconn.Request().URL.Query()
+	token := query.Get(v1.ResumeTokenQueryParam)
+	if token == "" || query.Get(v1.RecordingQueryParam) != "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", query.Get(v1.UsernameQueryParam), token)
+}
+
+// getDisplaySession returns the running display session for the given token. A
+// new session is started if there is none, or if the options for the connection
+// differ from the ones the session was started with.
+func getDisplaySession(token string, opts *rfb.ProxyOpts) (*rfb.Session, error) {
+	displaySessionsMux.Lock()
+	defer displaySessionsMux.Unlock()
+
+	if existing, ok := displaySessions[token]; ok {
+		select {
+		case <-existing.Done():
+		default:
+			if existing.opts == *opts {
+				log.Info("Resuming existing display session")
+				return existing.Session, nil
+			}
+			existing.Close()
+		}
+	}
+
+	log.Info(fmt.Sprintf("Starting resumable display session, connecting to %s", vncAddr))
+	vncConn, err := net.Dial(vncConnectProto, vncConnectAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	stopPulseAudio := startPulseAudio()
+
+	sess, err := rfb.NewSession(vncConn, opts, reconnectTimeout)
+	if err != nil {
+		vncConn.Close()
+		stopPulseAudio()
+		return nil, err
+	}
+
+	entry := &displaySession{Session: sess, opts: *opts}
+	displaySessions[token] = entry
+	go func() {
+		<-sess.Done()
+		log.Info("Resumable display session ended")
+		stopPulseAudio()
+		displaySessionsMux.Lock()
+		defer displaySessionsMux.Unlock()
+		if displaySessions[token] == entry {
+			delete(displaySessions, token)
+		}
+	}()
+	return sess, nil
+}
+
+func resumableDisplayHandler(wsconn *websocket.Conn, token string, opts *rfb.ProxyOpts) {
+	sess, err := getDisplaySession(token, opts)
+	if err != nil {
+		log.Error(err, "Failed to start display session")
+		wsconn.Close()
+		return
+	}
+
+	log.Info("Attaching to resumable display session")
+
+	wsconn.PayloadType = websocket.BinaryFrame
+
+	// wrap the connection so we can log metrics
+	watcher := apiutil.NewWebsocketWatcher(wsconn)
+
+	stChan := logWatcherMetrics("display", watcher)
+	defer func() { stChan <- struct{}{} }()
+
+	// block until the client disconnects or is replaced by a newer connection,
+	// the session stays open for the reconnect timeout after
+	if err := sess.Serve(watcher); err != nil {
+		log.Error(err, "Error while proxying display stream")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io"
+	"sync"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/rfb"
+
+	"golang.org/x/net/websocket"
+)
+
+// limits on the display stream from the DesktopTemplate
+var maxQuality, maxFrameRate int
+var maxBandwidth int64
+
+// the settings requested by the user over the settings websocket
+var displaySettings = &v1.DisplaySettings{}
+var displaySettingsMux sync.Mutex
+
+// displayThrottle applies the display settings to the user's display connections,
+// and policyThrottle applies only the limits from the DesktopTemplate to viewers.
+var displayThrottle, policyThrottle *rfb.Throttle
+
+// setupDisplayThrottles creates the throttles for display connections from the
+// limits in the DesktopTemplate. Viewers are not throttled when there are none.
+func setupDisplayThrottles() {
+	limits := getDisplayLimits()
+	displayThrottle = rfb.NewThrottle(throttleLimits(limits))
+	if limits.Quality != nil || limits.FrameRate > 0 || limits.Bandwidth > 0 {
+		policyThrottle = rfb.NewThrottle(throttleLimits(limits))
+	}
+}
+
+// getDisplayLimits returns the limits on the display stream set by the
+// DesktopTemplate.
+func getDisplayLimits() *v1.DisplaySettings {
+	limits := &v1.DisplaySettings{FrameRate: int32(maxFrameRate), Bandwidth: maxBandwidth}
+	if maxQuality >= 0 {
+		quality := int32(maxQuality)
+		limits.Quality = &quality
+	}
+	return limits
+}
+
+// throttleLimits converts display settings to the limits of a throttle.
+func throttleLimits(settings *v1.DisplaySettings) rfb.ThrottleLimits {
+	limits := rfb.ThrottleLimits{
+		Quality:   -1,
+		FrameRate: int(settings.FrameRate),
+		Bandwidth: settings.Bandwidth,
+	}
+	if settings.Quality != nil {
+		limits.Quality = int(*settings.Quality)
+	}
+	return limits
+}
+
+// getDisplaySettings returns the settings applied to the user's display
+// connections.
+func getDisplaySettings() *v1.DisplaySettings {
+	displaySettingsMux.Lock()
+	defer displaySettingsMux.Unlock()
+	return displaySettings.WithinLimits(getDisplayLimits())
+}
+
+// setDisplaySettings applies the given settings to the user's display
+// connections, including those already running, and returns the settings
+// applied after the limits of the DesktopTemplate.
+func setDisplaySettings(settings *v1.DisplaySettings) *v1.DisplaySettings {
+	displaySettingsMux.Lock()
+	defer displaySettingsMux.Unlock()
+	displaySettings = settings
+	applied := settings.WithinLimits(getDisplayLimits())
+	displayThrottle.SetLimits(throttleLimits(applied))
+	return applied
+}
+
+// wsSettingsHandler lets the user change the display settings of the desktop
+// while connected. The current settings are sent when the client connects, and
+// again after each change it sends. Settings are kept for the life of the desktop,
+// so they also apply to later display connections.
+func wsSettingsHandler(wsconn *websocket.Conn) {
+	defer wsconn.Close()
+
+	status := &v1.DisplaySettingsStatus{Settings: getDisplaySettings(), Limits: getDisplayLimits()}
+	if rdpEnabled() {
+		status.Error = "Display settings are not supported for desktops served over RDP"
+		if err := websocket.JSON.Send(wsconn, status); err != nil {
+			log.Error(err, "Failed to send display settings to client")
+		}
+		return
+	}
+
+	for {
+		if err := websocket.JSON.Send(wsconn, status); err != nil {
+			log.Error(err, "Failed to send display settings to client")
+			return
+		}
+		settings := &v1.DisplaySettings{}
+		if err := websocket.JSON.Receive(wsconn, settings); err != nil {
+			if err != io.EOF {
+				log.Error(err, "Failed to read display settings from client")
+			}
+			return
+		}
+		status = &v1.DisplaySettingsStatus{Limits: getDisplayLimits()}
+		if err := settings.Validate(); err != nil {
+			status.Settings = getDisplaySettings()
+			status.Error = err.Error()
+			continue
+		}
+		status.Settings = setDisplaySettings(settings)
+		log.Info("Updated display settings", "Quality", status.Settings.Quality, "FrameRate", status.Settings.FrameRate, "Bandwidth", status.Settings.Bandwidth)
+	}
+}
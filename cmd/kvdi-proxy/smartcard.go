@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"golang.org/x/net/websocket"
+)
+
+// wsSmartCardHandler relays a smart card held by the client to the pcscd bridge
+// in the desktop. The bridge forwards to vpcd, the pcscd driver for virtual
+// readers, which sends length-prefixed commands (and APDUs) to the card and
+// expects the responses in the same framing, so the stream is copied as-is.
+func wsSmartCardHandler(wsconn *websocket.Conn) {
+	if smartCardAddr == "" {
+		log.Info("Smart card redirection is disabled for this desktop session")
+		wsconn.Close()
+		return
+	}
+
+	log.Info(fmt.Sprintf("Received smart card proxy request, connecting to %s", smartCardAddr))
+	cardConn, err := net.Dial("unix", strings.TrimPrefix(smartCardAddr, "unix://"))
+	if err != nil {
+		log.Error(err, "Failed to connect to pcscd bridge")
+		wsconn.Close()
+		return
+	}
+	defer cardConn.Close()
+
+	wsconn.PayloadType = websocket.BinaryFrame
+
+	watcher := apiutil.NewWebsocketWatcher(wsconn)
+	stChan := logWatcherMetrics("smartcard", watcher)
+	defer func() { stChan <- struct{}{} }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Copy card responses from the client to the bridge
+	go func() {
+		if _, err := io.Copy(cardConn, watcher); err != nil {
+			if !errors.IsBrokenPipeError(err) {
+				log.Error(err, "Error while copying stream from websocket connection to pcscd bridge")
+			}
+		}
+		cancel()
+	}()
+
+	// Copy reader commands from the bridge to the client
+	go func() {
+		if _, err := io.Copy(watcher, cardConn); err != nil {
+			if !errors.IsBrokenPipeError(err) {
+				log.Error(err, "Error while copying stream from pcscd bridge to websocket connection")
+			}
+		}
+		cancel()
+	}()
+
+	// block until the context is finished
+	for range ctx.Done() {
+	}
+
+	log.Info("Smart card proxy ended")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func newSmartCardClient(t *testing.T) *websocket.Conn {
+	t.Helper()
+	srvr := httptest.NewServer(websocket.Handler(wsSmartCardHandler))
+	t.Cleanup(srvr.Close)
+	url := "ws" + strings.TrimPrefix(srvr.URL, "http")
+	conn, err := websocket.Dial(url, "", srvr.URL)
+	if err != nil {
+		t.Fatal("Failed to dial smart card channel:", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.PayloadType = websocket.BinaryFrame
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestSmartCardHandler(t *testing.T) {
+	defer func() { smartCardAddr = "" }()
+
+	t.Run("Disabled", func(t *testing.T) {
+		smartCardAddr = ""
+		conn := newSmartCardClient(t)
+		if _, err := conn.Read(make([]byte, 1)); err == nil {
+			t.Error("Expected the channel to be closed when smart cards are disabled")
+		}
+	})
+
+	t.Run("BridgeNotRunning", func(t *testing.T) {
+		smartCardAddr = "unix://" + filepath.Join(t.TempDir(), "missing.sock")
+		conn := newSmartCardClient(t)
+		if _, err := conn.Read(make([]byte, 1)); err == nil {
+			t.Error("Expected the channel to be closed when the bridge is not listening")
+		}
+	})
+
+	t.Run("Relay", func(t *testing.T) {
+		sock := filepath.Join(t.TempDir(), "smartcard.sock")
+		l, err := net.Listen("unix", sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		smartCardAddr = "unix://" + sock
+
+		// vpcd asks the card for its ATR and expects it back in the same framing
+		getATR := []byte{0x00, 0x01, 0x04}
+		atr := []byte{0x00, 0x04, 0x3b, 0x80, 0x80, 0x01}
+
+		bridgeErr := make(chan error, 1)
+		bridgeRecvd := make(chan []byte, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				bridgeErr <- err
+				return
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			if _, err := conn.Write(getATR); err != nil {
+				bridgeErr <- err
+				return
+			}
+			buf := make([]byte, len(atr))
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				bridgeErr <- err
+				return
+			}
+			bridgeRecvd <- buf
+		}()
+
+		conn := newSmartCardClient(t)
+
+		buf := make([]byte, len(getATR))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Fatal("Failed to read command from the bridge:", err)
+		}
+		if !bytes.Equal(buf, getATR) {
+			t.Errorf("Expected client to receive %v, got %v", getATR, buf)
+		}
+
+		if _, err := conn.Write(atr); err != nil {
+			t.Fatal("Failed to write response to the bridge:", err)
+		}
+		select {
+		case err := <-bridgeErr:
+			t.Fatal("Bridge failed:", err)
+		case got := <-bridgeRecvd:
+			if !bytes.Equal(got, atr) {
+				t.Errorf("Expected bridge to receive %v, got %v", atr, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/tracing"
+
+	"github.com/gorilla/mux"
+)
+
+// tracingResponseWriter tracks the status code of a response, including when the
+// connection is taken over for a websocket.
+type tracingResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (t *tracingResponseWriter) WriteHeader(s int) {
+	t.ResponseWriter.WriteHeader(s)
+	t.status = s
+}
+
+func (t *tracingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := t.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && t.status == http.StatusOK {
+		t.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// newTracingMiddleware returns a middleware recording a span for every request
+// carrying a traceparent header from the API. Nothing is recorded if the given
+// tracer is nil.
+func newTracingMiddleware(tracer *tracing.Tracer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tracer == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			route := apiutil.GetGorillaPath(r)
+			ctx, span := tracer.Start(
+				tracing.Extract(r.Context(), r.Header),
+				fmt.Sprintf("%s %s", r.Method, route),
+				tracing.WithKind(tracing.SpanKindServer),
+			)
+			defer span.End()
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.route", route)
+			span.SetAttribute("http.target", r.URL.Path)
+
+			tw := &tracingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+
+			span.SetAttribute("http.status_code", tw.status)
+			if tw.status >= http.StatusInternalServerError {
+				span.SetError(fmt.Errorf("%d %s", tw.status, http.StatusText(tw.status)))
+			}
+		})
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"k8s.io/client-go/rest"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis"
+	"github.com/tinyzimmer/kvdi/pkg/billing"
 	"github.com/tinyzimmer/kvdi/pkg/controller"
+	"github.com/tinyzimmer/kvdi/pkg/gc"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
@@ -84,6 +86,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup the billing collector
+	if err := mgr.Add(billing.NewCollector(mgr.GetClient())); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
+	// Setup the orphaned resource sweeper
+	if err := mgr.Add(gc.NewSweeper(mgr.GetClient(), mgr.GetAPIReader())); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
 	// Add the Metrics Service
 	addMetrics(ctx, cfg)
 
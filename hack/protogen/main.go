@@ -0,0 +1,21 @@
+// protogen writes the protobuf definitions for the gRPC API to the given file.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/tinyzimmer/kvdi/pkg/api"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println("Usage: protogen <output>")
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(os.Args[1], []byte(api.GRPCProto()), 0644); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
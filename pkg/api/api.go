@@ -11,6 +11,7 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/apis"
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/audit"
 	"github.com/tinyzimmer/kvdi/pkg/auth"
 	"github.com/tinyzimmer/kvdi/pkg/auth/common"
 	"github.com/tinyzimmer/kvdi/pkg/auth/mfa"
@@ -18,6 +19,7 @@ import (
 	util "github.com/tinyzimmer/kvdi/pkg/util/common"
 
 	"github.com/gorilla/mux"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
@@ -36,6 +38,15 @@ var apiLogger = logf.Log.WithName("api")
 // DesktopAPI serves HTTP requests for the /api resource
 type DesktopAPI interface {
 	ServeHTTP(http.ResponseWriter, *http.Request)
+	// CORSEnabled returns true if CORS headers should currently be added to
+	// responses.
+	CORSEnabled() bool
+	// RequestClientCerts returns true if client certificates should currently be
+	// requested during the TLS handshake.
+	RequestClientCerts() bool
+	// GRPCHandler returns a handler serving the gRPC API. Calls are routed through
+	// the same handlers and middleware as HTTP requests.
+	GRPCHandler() http.Handler
 }
 
 // desktopAPI implements the DesktopAPI interface
@@ -54,6 +65,22 @@ type desktopAPI struct {
 	secrets *secrets.SecretEngine
 	// the mfa backend for setting and retrieving OTP secrets
 	mfa *mfa.Manager
+	// the push mfa provider, nil when users authorize with OTPs
+	mfaProvider common.MFAProvider
+	// the monitor for desktop disk usage
+	disk *diskMonitor
+	// the list of revoked access tokens
+	revoked *revocationList
+	// the broker for the event stream
+	events *eventBroker
+	// the audit logger, writes to the backend configured on the cluster
+	audit *audit.Logger
+	// the rate limits and lockouts for login attempts
+	logins *loginLimiter
+	// the sender for email notifications, nil when email is not configured
+	email emailSender
+	// the keys for signing and verifying access tokens
+	jwtKeys jwtKeyCache
 }
 
 func (d *desktopAPI) handleClusterUpdate(req reconcile.Request) error {
@@ -62,43 +89,80 @@ func (d *desktopAPI) handleClusterUpdate(req reconcile.Request) error {
 		return nil
 	}
 
+	// fetch the remote state into a new object so that fields removed from
+	// the spec do not linger from the previous configuration.
+	cluster := &v1alpha1.VDICluster{}
+	if err := d.client.Get(context.TODO(), req.NamespacedName, cluster); err != nil {
+		return err
+	}
+
 	if d.vdiCluster == nil {
 		// we are setting up the api the first time
-		d.vdiCluster = &v1alpha1.VDICluster{}
 		apiLogger.Info("Setting up kVDI runtime")
+		d.secrets = secrets.GetSecretEngine(cluster)
+		d.mfa = mfa.NewManager(d.secrets)
+		d.revoked = newRevocationList(d.secrets)
+		d.auth = auth.GetAuthProvider(cluster, d.secrets)
 	} else {
 		apiLogger.Info("Syncing kVDI runtime configuration with VDICluster spec")
+		// Swapping the auth or secrets backends requires a restart of the app. The
+		// manager rolls the deployment when this happens, until then we keep serving
+		// with the current providers and configuration.
+		if cluster.GetAuthBackend() != d.vdiCluster.GetAuthBackend() || cluster.GetSecretsBackend() != d.vdiCluster.GetSecretsBackend() {
+			apiLogger.Info("Auth or secrets backend has changed, waiting for the app to be restarted")
+			return nil
+		}
 	}
 
-	var err error
-	// overwrite the api vdicluster object with the remote state
-	if err = d.client.Get(context.TODO(), req.NamespacedName, d.vdiCluster); err != nil {
+	// call Setup on the secrets backend, should be idempotent
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
 		return err
 	}
 
-	if d.secrets == nil {
-		// we have not set up secrets yet
-		d.secrets = secrets.GetSecretEngine(d.vdiCluster)
-		// this means mfa also still need to be setup
-		d.mfa = mfa.NewManager(d.secrets)
+	// call Setup on the auth provider, should be idempotent
+	if err := d.auth.Setup(d.client, cluster); err != nil {
+		return err
 	}
-	// call Setup on the secrets backend, should be idempotent
-	if err = d.secrets.Setup(d.client, d.vdiCluster); err != nil {
+
+	// swap the push mfa provider if it changed and set it up
+	if err := d.syncMFAProvider(cluster); err != nil {
 		return err
 	}
 
-	if d.auth == nil {
-		// auth has not been setup yet
-		d.auth = auth.GetAuthProvider(d.vdiCluster, d.secrets)
+	// swap the audit backend if its configuration changed
+	if err := d.syncAuditLog(cluster); err != nil {
+		return err
 	}
-	// call Setup on the auth provider, should be idempotent
-	if err = d.auth.Setup(d.client, d.vdiCluster); err != nil {
+
+	// rebuild the email sender with the current configuration
+	if err := d.syncEmail(cluster); err != nil {
 		return err
 	}
 
+	// all other configurations are read from the cluster object at request time
+	d.vdiCluster = cluster
+
 	return nil
 }
 
+// CORSEnabled implements the DesktopAPI interface and returns true if the
+// VDICluster has CORS enabled.
+func (d *desktopAPI) CORSEnabled() bool {
+	if d.vdiCluster == nil {
+		return false
+	}
+	return d.vdiCluster.EnableCORS()
+}
+
+// RequestClientCerts implements the DesktopAPI interface and returns true if the
+// VDICluster authenticates users with client certificates presented to the app.
+func (d *desktopAPI) RequestClientCerts() bool {
+	if d.vdiCluster == nil {
+		return false
+	}
+	return d.vdiCluster.RequestClientCerts()
+}
+
 func buildScheme() (*runtime.Scheme, error) {
 	scheme := runtime.NewScheme()
 	if err := apis.AddToScheme(scheme); err != nil {
@@ -107,6 +171,9 @@ func buildScheme() (*runtime.Scheme, error) {
 	if err := corev1.AddToScheme(scheme); err != nil {
 		return nil, err
 	}
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
 	return scheme, nil
 }
 
@@ -118,7 +185,7 @@ func getClientFromConfigAndScheme(cfg *rest.Config, scheme *runtime.Scheme) (cli
 // and vdi cluster name.
 func NewFromConfig(cfg *rest.Config, vdiCluster string) (DesktopAPI, error) {
 	// create an api object
-	api := &desktopAPI{clusterName: vdiCluster}
+	api := &desktopAPI{clusterName: vdiCluster, events: newEventBroker(), logins: newLoginLimiter()}
 
 	// build our scheme
 	scheme, err := buildScheme()
@@ -157,6 +224,11 @@ func NewFromConfig(cfg *rest.Config, vdiCluster string) (DesktopAPI, error) {
 		return nil, err
 	}
 
+	// publish desktop and role changes to the event stream
+	if err = api.watchEvents(mgr.GetCache()); err != nil {
+		return nil, err
+	}
+
 	// start the mgr
 	go func() {
 		// we run this manager for life so no need to actually use this
@@ -168,6 +240,10 @@ func NewFromConfig(cfg *rest.Config, vdiCluster string) (DesktopAPI, error) {
 		}
 	}()
 
+	// start monitoring desktop disk usage
+	api.disk = newDiskMonitor(api.client, vdiCluster)
+	go api.disk.Run()
+
 	// return the api and build the router
 	return api, api.buildRouter()
 }
@@ -177,7 +253,7 @@ func NewTestAPI() (srvr *http.Server, addr, adminPass string, err error) {
 	adminPass = "testing"
 
 	// create an api object
-	api := &desktopAPI{clusterName: "test-cluster"}
+	api := &desktopAPI{clusterName: "test-cluster", events: newEventBroker(), logins: newLoginLimiter()}
 
 	// build our scheme
 	var scheme *runtime.Scheme
@@ -229,6 +305,7 @@ func NewTestAPI() (srvr *http.Server, addr, adminPass string, err error) {
 	// set up auth and secrets
 	api.secrets = secrets.GetSecretEngine(api.vdiCluster)
 	api.mfa = mfa.NewManager(api.secrets)
+	api.revoked = newRevocationList(api.secrets)
 	api.auth = auth.GetAuthProvider(api.vdiCluster, api.secrets)
 	if err = api.secrets.Setup(api.client, api.vdiCluster); err != nil {
 		return
@@ -242,6 +319,11 @@ func NewTestAPI() (srvr *http.Server, addr, adminPass string, err error) {
 		return
 	}
 
+	// set a dummy template bundle key
+	if err = api.secrets.WriteSecret(v1.TemplateBundleSecretKey, []byte("supersecret")); err != nil {
+		return
+	}
+
 	// reconcile initial credentials for auth
 	// will be admin:testing
 	if err = api.auth.Reconcile(apiLogger, api.client, api.vdiCluster, adminPass); err != nil {
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// accessLogger handles access log events.
+var accessLogger = logf.Log.WithName("api_access")
+
+// accessLogCount is the number of successful requests seen, used for sampling.
+var accessLogCount uint64
+
+// accessLogContextKey is the key where the access log entry is stored in the
+// request context.
+type accessLogContextKey struct{}
+
+// accessLogEntry contains information about a request that is only known to
+// handlers further down the chain. A pointer is stored in the request context
+// so they can fill it in.
+type accessLogEntry struct {
+	user string
+}
+
+// setAccessLogUser sets the user on the access log entry for the given request,
+// if there is one.
+func setAccessLogUser(r *http.Request, user string) {
+	if entry, ok := r.Context().Value(accessLogContextKey{}).(*accessLogEntry); ok {
+		entry.user = user
+	}
+}
+
+// accessLogResponseWriter extends the regular http.ResponseWriter and tracks the
+// status code and bytes written. When a Hijack is requested for a websocket
+// connection, the bytes transferred over the connection are tracked instead.
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	size    int
+	watcher *apiutil.WebsocketWatcher
+}
+
+func (a *accessLogResponseWriter) WriteHeader(s int) {
+	a.ResponseWriter.WriteHeader(s)
+	a.status = s
+}
+
+func (a *accessLogResponseWriter) Write(b []byte) (int, error) {
+	size, err := a.ResponseWriter.Write(b)
+	a.size += size
+	return size, err
+}
+
+func (a *accessLogResponseWriter) Flush() { apiutil.Flush(a.ResponseWriter) }
+
+func (a *accessLogResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	a.watcher = apiutil.NewWebsocketWatcher(nil)
+	conn, rw, err := a.watcher.Hijack(a.ResponseWriter)
+	if err == nil && a.status == http.StatusOK {
+		a.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// BytesSent returns the total number of bytes sent to the client.
+func (a *accessLogResponseWriter) BytesSent() int {
+	if a.watcher != nil {
+		return a.size + a.watcher.BytesSentCount()
+	}
+	return a.size
+}
+
+// accessLogBody wraps a request body and tracks the bytes read from it.
+type accessLogBody struct {
+	io.ReadCloser
+	size int
+}
+
+func (a *accessLogBody) Read(b []byte) (int, error) {
+	size, err := a.ReadCloser.Read(b)
+	a.size += size
+	return size, err
+}
+
+// accessLogMiddleware implements mux.MiddlewareFunc and logs a line for every
+// request when the access log is enabled.
+func (d *desktopAPI) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.vdiCluster == nil || !d.vdiCluster.AccessLogEnabled() || d.accessLogExcluded(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		entry := &accessLogEntry{}
+		aw := &accessLogResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		body := &accessLogBody{ReadCloser: r.Body}
+		r.Body = body
+
+		// run the request flow
+		next.ServeHTTP(aw, r.WithContext(context.WithValue(r.Context(), accessLogContextKey{}, entry)))
+
+		if !d.sampleAccessLog(aw.status) {
+			return
+		}
+
+		bytesRcvd := body.size
+		if aw.watcher != nil {
+			bytesRcvd += aw.watcher.BytesRecvdCount()
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		accessLogger.Info(
+			fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, aw.status),
+			"User.Name", entry.user,
+			"Request.Method", r.Method,
+			"Request.Path", r.URL.Path,
+			"Request.Route", apiutil.GetGorillaPath(r),
+			"Request.RemoteHost", host,
+			"Response.Status", aw.status,
+			"Response.Latency", time.Since(start).String(),
+			"BytesSent", aw.BytesSent(),
+			"BytesReceived", bytesRcvd,
+		)
+	})
+}
+
+// accessLogExcluded returns true if the given request should not be written to
+// the access log.
+func (d *desktopAPI) accessLogExcluded(r *http.Request) bool {
+	for _, prefix := range d.vdiCluster.GetAccessLogExcludePaths() {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleAccessLog returns true if a request with the given status should be
+// written to the access log. Failed requests are always logged.
+func (d *desktopAPI) sampleAccessLog(status int) bool {
+	if status >= http.StatusBadRequest {
+		return true
+	}
+	rate := uint64(d.vdiCluster.GetAccessLogSampleRate())
+	return atomic.AddUint64(&accessLogCount, 1)%rate == 0
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getAnnouncementForRequest retrieves the VDIAnnouncement in the request path. If it
+// does not exist, a not found error is written to the response and nil is returned.
+func (d *desktopAPI) getAnnouncementForRequest(w http.ResponseWriter, r *http.Request) *v1alpha1.VDIAnnouncement {
+	name := apiutil.GetAnnouncementFromRequest(r)
+	announcement := &v1alpha1.VDIAnnouncement{}
+	nn := types.NamespacedName{Name: name, Namespace: metav1.NamespaceAll}
+	if err := d.client.Get(context.TODO(), nn, announcement); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("The announcement '%s' doesn't exist", name), w)
+			return nil
+		}
+		apiutil.ReturnAPIError(err, w)
+		return nil
+	}
+	if !d.isClusterAnnouncement(announcement) {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The announcement '%s' doesn't exist", name), w)
+		return nil
+	}
+	return announcement
+}
+
+func (d *desktopAPI) isClusterAnnouncement(announcement *v1alpha1.VDIAnnouncement) bool {
+	labels := announcement.GetLabels()
+	return labels != nil && labels[v1.RoleClusterRefLabel] == d.clusterName
+}
+
+// watchAnnouncements adds handlers to the informer for announcements that publish
+// events when they start and stop being shown. Announcements that were already
+// shown when the cache first synced are not published again.
+func (d *desktopAPI) watchAnnouncements(c cache.Cache, started time.Time) error {
+	informer, err := c.GetInformer(context.TODO(), &v1alpha1.VDIAnnouncement{})
+	if err != nil {
+		return err
+	}
+	scheduler := newAnnouncementScheduler(d.events)
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			announcement, ok := obj.(*v1alpha1.VDIAnnouncement)
+			if !ok || !d.isClusterAnnouncement(announcement) {
+				return
+			}
+			scheduler.Schedule(announcement, !announcement.GetCreationTimestamp().Time.Before(started))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok := oldObj.(*v1alpha1.VDIAnnouncement)
+			if !ok {
+				return
+			}
+			announcement, ok := newObj.(*v1alpha1.VDIAnnouncement)
+			if !ok || !d.isClusterAnnouncement(announcement) || old.GetResourceVersion() == announcement.GetResourceVersion() {
+				return
+			}
+			scheduler.Schedule(announcement, true)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			announcement, ok := obj.(*v1alpha1.VDIAnnouncement)
+			if !ok || !d.isClusterAnnouncement(announcement) {
+				return
+			}
+			scheduler.Remove(announcement)
+		},
+	})
+	return nil
+}
+
+// announcementScheduler publishes events when announcements start and stop being
+// shown, including at their start and end times.
+type announcementScheduler struct {
+	mux     sync.Mutex
+	events  *eventBroker
+	entries map[string]*scheduledAnnouncement
+}
+
+// scheduledAnnouncement tracks the state of a single announcement.
+type scheduledAnnouncement struct {
+	// incremented on each change, so timers for previous versions do nothing
+	generation int
+	// whether the announcement was last published as shown
+	shown bool
+	// the timers for the start and end times
+	timers []*time.Timer
+}
+
+func newAnnouncementScheduler(events *eventBroker) *announcementScheduler {
+	return &announcementScheduler{events: events, entries: make(map[string]*scheduledAnnouncement)}
+}
+
+// Schedule publishes the given announcement if it is being shown, or its removal
+// if it no longer is, and sets timers for its start and end times. When notify is
+// false, an announcement already being shown is not published again.
+func (s *announcementScheduler) Schedule(announcement *v1alpha1.VDIAnnouncement, notify bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	entry := s.resetEntry(announcement.GetName())
+	generation := entry.generation
+	ann := announcement.ToAnnouncement()
+	now := time.Now()
+
+	switch {
+	case ann.IsActive(now):
+		if notify {
+			s.events.Publish(&v1.Event{Type: v1.EventAnnouncementPublished, Announcement: ann})
+		}
+		entry.shown = true
+	case entry.shown:
+		s.events.Publish(&v1.Event{Type: v1.EventAnnouncementRemoved, Announcement: ann})
+		entry.shown = false
+	}
+
+	if ann.StartTime != nil && ann.StartTime.After(now) {
+		entry.timers = append(entry.timers, time.AfterFunc(ann.StartTime.Sub(now), func() {
+			s.fire(ann, generation, v1.EventAnnouncementPublished, true)
+		}))
+	}
+	if ann.EndTime != nil && ann.EndTime.After(now) {
+		entry.timers = append(entry.timers, time.AfterFunc(ann.EndTime.Sub(now), func() {
+			s.fire(ann, generation, v1.EventAnnouncementRemoved, false)
+		}))
+	}
+}
+
+// Remove stops the timers for the given announcement and publishes its removal if
+// it was being shown.
+func (s *announcementScheduler) Remove(announcement *v1alpha1.VDIAnnouncement) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	entry := s.resetEntry(announcement.GetName())
+	if entry.shown {
+		s.events.Publish(&v1.Event{Type: v1.EventAnnouncementRemoved, Announcement: announcement.ToAnnouncement()})
+	}
+	delete(s.entries, announcement.GetName())
+}
+
+// resetEntry stops the timers for the announcement with the given name and returns
+// its entry for a new version. It must be called with the lock held.
+func (s *announcementScheduler) resetEntry(name string) *scheduledAnnouncement {
+	entry, ok := s.entries[name]
+	if !ok {
+		entry = &scheduledAnnouncement{}
+		s.entries[name] = entry
+	}
+	for _, timer := range entry.timers {
+		timer.Stop()
+	}
+	entry.timers = nil
+	entry.generation++
+	return entry
+}
+
+// fire publishes an event for an announcement reaching its start or end time,
+// unless it has changed since the timer was set.
+func (s *announcementScheduler) fire(ann *v1.Announcement, generation int, eventType v1.EventType, shown bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	entry, ok := s.entries[ann.Name]
+	if !ok || entry.generation != generation || entry.shown == shown {
+		return
+	}
+	entry.shown = shown
+	s.events.Publish(&v1.Event{Type: eventType, Announcement: ann})
+}
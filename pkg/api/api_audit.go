@@ -1,18 +1,25 @@
 package api
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"reflect"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
-
-	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"github.com/tinyzimmer/kvdi/pkg/audit"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
 )
 
-// auditLogger handles the audit events. This could be overridden with audit
-// "providers" that just implement the logr interface.
-var auditLogger = logf.Log.WithName("api_audit")
+// auditExcludedPaths are route prefixes that are never written to the audit log.
+var auditExcludedPaths = []string{"/api/healthz", "/api/readyz", "/api/metrics"}
 
 // AuditResult contains information about an audit event from the API router.
 type AuditResult struct {
@@ -50,20 +57,179 @@ func buildAuditMsg(result *AuditResult) string {
 	if result.FromOwner {
 		msg = msg + " (OWNER)"
 	}
+	if result.UserSession.Impersonator != "" {
+		msg = msg + fmt.Sprintf(" (IMPERSONATED BY %s)", result.UserSession.Impersonator)
+	}
 	return msg
 }
 
-// auditLog logs the event with parseable metadata.
+// auditContextKey is the key where the audit entry is stored in the request
+// context.
+type auditContextKey struct{}
+
+// auditEntry contains information about a request that is only known to
+// handlers further down the chain.
+type auditEntry struct {
+	user         string
+	impersonator string
+	message      string
+	result       *AuditResult
+}
+
+// setAuditUser sets the user on the audit entry for the given request, if there
+// is one.
+func setAuditUser(r *http.Request, user string) {
+	if entry, ok := r.Context().Value(auditContextKey{}).(*auditEntry); ok {
+		entry.user = user
+	}
+}
+
+// setAuditImpersonator sets the user impersonating the request user on the audit
+// entry for the given request, if there is one.
+func setAuditImpersonator(r *http.Request, impersonator string) {
+	if entry, ok := r.Context().Value(auditContextKey{}).(*auditEntry); ok {
+		entry.impersonator = impersonator
+	}
+}
+
+// setAuditMessage sets a message on the audit entry for the given request, if
+// there is one. It is used when the route has no grants to evaluate.
+func setAuditMessage(r *http.Request, msg string) {
+	if entry, ok := r.Context().Value(auditContextKey{}).(*auditEntry); ok {
+		entry.message = msg
+	}
+}
+
+// auditLog records the result of evaluating a user's grants on the audit entry
+// for the request. The event is written by the audit middleware once the
+// response status is known.
 func (d *desktopAPI) auditLog(result *AuditResult) {
-	if !d.vdiCluster.AuditLogEnabled() {
-		return
-	}
-	msg := buildAuditMsg(result)
-	auditLogger.Info(
-		msg,
-		"Allowed", result.Allowed,
-		"User.Name", result.UserSession.User.Name,
-		"Request.Path", result.Request.URL.Path,
-		"API.Actions", result.Actions,
-	)
+	if entry, ok := result.Request.Context().Value(auditContextKey{}).(*auditEntry); ok {
+		entry.result = result
+	}
+}
+
+// auditResponseWriter tracks the status code of a response and calls onHijack
+// when the connection is taken over for a websocket.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status   int
+	onHijack func(status int)
+}
+
+func (a *auditResponseWriter) WriteHeader(s int) {
+	a.ResponseWriter.WriteHeader(s)
+	a.status = s
+}
+
+func (a *auditResponseWriter) Flush() { apiutil.Flush(a.ResponseWriter) }
+
+func (a *auditResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := a.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		a.onHijack(http.StatusSwitchingProtocols)
+	}
+	return conn, rw, err
+}
+
+// auditMiddleware implements mux.MiddlewareFunc and writes an audit event for
+// every request when the audit log is enabled. Websocket connections are
+// written when the connection is upgraded, instead of when they close.
+func (d *desktopAPI) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.audit == nil || d.audit.Backend() == nil || auditExcluded(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entry := &auditEntry{}
+		r = r.WithContext(context.WithValue(r.Context(), auditContextKey{}, entry))
+
+		var once sync.Once
+		write := func(status int) {
+			once.Do(func() { d.audit.Log(buildAuditEvent(r, entry, status)) })
+		}
+		aw := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK, onHijack: write}
+
+		next.ServeHTTP(aw, r)
+		write(aw.status)
+	})
+}
+
+// auditExcluded returns true if the given request should not be written to the
+// audit log.
+func auditExcluded(r *http.Request) bool {
+	for _, prefix := range auditExcludedPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildAuditEvent builds an audit event from the given request and entry.
+func buildAuditEvent(r *http.Request, entry *auditEntry, status int) *audit.Event {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	event := &audit.Event{
+		Timestamp:    time.Now().UTC(),
+		User:         entry.user,
+		Impersonator: entry.impersonator,
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		Status:       status,
+		SourceIP:     host,
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		Message:      entry.message,
+	}
+	if entry.result == nil {
+		return event
+	}
+	event.Decision = audit.DecisionDenied
+	if entry.result.Allowed {
+		event.Decision = audit.DecisionAllowed
+	}
+	event.FromOwner = entry.result.FromOwner
+	event.Message = buildAuditMsg(entry.result)
+	// the last action evaluated is the one that decided the request
+	if len(entry.result.Actions) > 0 {
+		action := entry.result.Actions[len(entry.result.Actions)-1]
+		event.Verb = string(action.Verb)
+		event.Resource = string(action.ResourceType)
+		event.ResourceName = action.ResourceName
+		event.Namespace = action.ResourceNamespace
+	}
+	return event
+}
+
+// syncAuditLog rebuilds the audit backend if its configuration differs between
+// the current cluster and the given one. The logger is created on first use.
+func (d *desktopAPI) syncAuditLog(cluster *v1alpha1.VDICluster) error {
+	if d.audit == nil {
+		podName, _ := k8sutil.GetThisPodName()
+		d.audit = audit.NewLogger(podName, nil)
+	} else if d.vdiCluster != nil &&
+		d.vdiCluster.AuditLogEnabled() == cluster.AuditLogEnabled() &&
+		reflect.DeepEqual(getAuditLogConfig(d.vdiCluster), getAuditLogConfig(cluster)) {
+		return nil
+	}
+	backend, err := audit.GetBackend(cluster, d.secrets)
+	if err != nil {
+		return err
+	}
+	d.audit.SetBackend(backend)
+	return nil
+}
+
+func getAuditLogConfig(cluster *v1alpha1.VDICluster) *v1alpha1.AuditLogConfig {
+	if cluster.Spec.App != nil {
+		return cluster.Spec.App.Audit
+	}
+	return nil
 }
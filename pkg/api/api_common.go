@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
@@ -16,6 +17,7 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 	"github.com/tinyzimmer/kvdi/pkg/util/tlsutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/tracing"
 
 	corev1 "k8s.io/api/core/v1"
 )
@@ -39,15 +41,28 @@ func (d *desktopAPI) GetWhoAmI(w http.ResponseWriter, r *http.Request) {
 
 // returnNewJWT will return a new JSON web token to the requestor.
 func (d *desktopAPI) returnNewJWT(w http.ResponseWriter, result *v1.AuthResult, authorized bool, state string) {
-	// fetch the JWT signing secret
-	secret, err := d.secrets.ReadSecret(v1.JWTSecretKey, true)
+	// fetch the JWT signing keys
+	keys, err := d.getJWTKeys(true)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
 
-	// create a new token
-	claims, newToken, err := apiutil.GenerateJWT(secret, result, authorized, d.vdiCluster.GetTokenDuration())
+	// add any roles temporarily granted to the user
+	if err := d.applyRoleGrants(result.User); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// create a new token, using the shortest lifetime configured across the
+	// user's roles, and never outliving any of them
+	duration := result.User.GetTokenDuration(d.vdiCluster.GetTokenDuration())
+	if expiresAt := result.User.GetRolesExpireAt(); expiresAt > 0 {
+		if untilExpiry := time.Until(time.Unix(expiresAt, 0)); untilExpiry < duration {
+			duration = untilExpiry
+		}
+	}
+	claims, newToken, err := apiutil.GenerateJWT(keys, result, authorized, duration)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
@@ -55,7 +70,7 @@ func (d *desktopAPI) returnNewJWT(w http.ResponseWriter, result *v1.AuthResult,
 
 	if authorized && !result.RefreshNotSupported {
 		// Generate a refresh token
-		refreshToken, err := d.generateRefreshToken(result.User)
+		refreshToken, err := d.generateRefreshToken(result)
 		if err != nil {
 			apiutil.ReturnAPIError(err, w)
 			return
@@ -71,51 +86,126 @@ func (d *desktopAPI) returnNewJWT(w http.ResponseWriter, result *v1.AuthResult,
 	}
 
 	// return the token to the user
-	apiutil.WriteJSON(&v1.SessionResponse{
+	res := &v1.SessionResponse{
 		Token:      newToken,
 		ExpiresAt:  claims.ExpiresAt,
 		Renewable:  !result.RefreshNotSupported,
 		User:       result.User,
 		Authorized: authorized,
 		State:      state,
-	}, w)
+	}
+	if !authorized {
+		res.MFAMethod = d.getMFAMethod()
+		res.MFAEnrollmentRequired, err = d.mfaEnrollmentRequired(result.User)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+	apiutil.WriteJSON(res, w)
 }
 
-func (d *desktopAPI) generateRefreshToken(user *v1.VDIUser) (string, error) {
+// generateRefreshToken generates a new refresh token for the user in the given
+// result. Any refresh token issued by the auth provider is stored with it.
+func (d *desktopAPI) generateRefreshToken(result *v1.AuthResult) (string, error) {
 	refreshToken := uuid.New().String()
 	if err := d.secrets.Lock(10); err != nil {
 		return "", err
 	}
 	defer d.secrets.Release()
-	tokens, err := d.secrets.ReadSecretMap(v1.RefreshTokensSecretKey, false)
+	tokens, err := d.readSecretMapIfExists(v1.RefreshTokensSecretKey)
 	if err != nil {
-		if !errors.IsSecretNotFoundError(err) {
-			return "", err
-		}
-		tokens = make(map[string][]byte)
+		return "", err
+	}
+	tokens[refreshToken] = []byte(result.User.Name)
+	if err := d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens); err != nil {
+		return "", err
 	}
-	tokens[refreshToken] = []byte(user.Name)
-	return refreshToken, d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens)
+	if result.ProviderRefreshToken == "" {
+		return refreshToken, nil
+	}
+	providerTokens, err := d.readSecretMapIfExists(v1.ProviderRefreshTokensSecretKey)
+	if err != nil {
+		return "", err
+	}
+	providerTokens[refreshToken] = []byte(result.ProviderRefreshToken)
+	return refreshToken, d.secrets.WriteSecretMap(v1.ProviderRefreshTokensSecretKey, providerTokens)
 }
 
-func (d *desktopAPI) lookupRefreshToken(refreshToken string) (string, error) {
+// lookupRefreshToken returns the user and any provider refresh token for the
+// given refresh token. The refresh token is removed from the secrets backend.
+func (d *desktopAPI) lookupRefreshToken(refreshToken string) (username, providerToken string, err error) {
 	if err := d.secrets.Lock(10); err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer d.secrets.Release()
 	tokens, err := d.secrets.ReadSecretMap(v1.RefreshTokensSecretKey, false)
 	if err != nil {
 		if errors.IsSecretNotFoundError(err) {
-			return "", errors.New("The refresh token does not exist in the secret storage")
+			return "", "", errors.New("The refresh token does not exist in the secret storage")
 		}
-		return "", err
+		return "", "", err
 	}
 	user, ok := tokens[refreshToken]
 	if !ok {
-		return "", errors.New("The refresh token does not exist in the secret storage")
+		return "", "", errors.New("The refresh token does not exist in the secret storage")
 	}
 	delete(tokens, refreshToken)
-	return string(user), d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens)
+	if err := d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens); err != nil {
+		return "", "", err
+	}
+	providerTokens, err := d.readSecretMapIfExists(v1.ProviderRefreshTokensSecretKey)
+	if err != nil {
+		return "", "", err
+	}
+	if token, ok := providerTokens[refreshToken]; ok {
+		delete(providerTokens, refreshToken)
+		if err := d.secrets.WriteSecretMap(v1.ProviderRefreshTokensSecretKey, providerTokens); err != nil {
+			return "", "", err
+		}
+		providerToken = string(token)
+	}
+	return string(user), providerToken, nil
+}
+
+// revokeUserRefreshTokens removes all the refresh tokens issued to the given user,
+// along with any provider refresh tokens stored with them.
+func (d *desktopAPI) revokeUserRefreshTokens(username string) error {
+	if err := d.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer d.secrets.Release()
+	tokens, err := d.readSecretMapIfExists(v1.RefreshTokensSecretKey)
+	if err != nil {
+		return err
+	}
+	providerTokens, err := d.readSecretMapIfExists(v1.ProviderRefreshTokensSecretKey)
+	if err != nil {
+		return err
+	}
+	for token, user := range tokens {
+		if string(user) == username {
+			delete(tokens, token)
+			delete(providerTokens, token)
+		}
+	}
+	if err := d.secrets.WriteSecretMap(v1.RefreshTokensSecretKey, tokens); err != nil {
+		return err
+	}
+	return d.secrets.WriteSecretMap(v1.ProviderRefreshTokensSecretKey, providerTokens)
+}
+
+// readSecretMapIfExists reads the given secret map, returning an empty map if
+// it does not exist yet. The secrets lock should be held by the caller.
+func (d *desktopAPI) readSecretMapIfExists(key string) (map[string][]byte, error) {
+	data, err := d.secrets.ReadSecretMap(key, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return nil, err
+		}
+		return make(map[string][]byte), nil
+	}
+	return data, nil
 }
 
 func (d *desktopAPI) getDesktopWebsocketURL(r *http.Request) (*url.URL, error) {
@@ -162,6 +252,7 @@ func (d *desktopAPI) serveHTTPProxy(w http.ResponseWriter, r *http.Request) {
 	for hdr, val := range r.Header {
 		req.Header.Add(hdr, strings.Join(val, ";"))
 	}
+	tracing.Inject(r.Context(), req.Header)
 
 	// Build an HTTP client
 	clientTLSConfig, err := tlsutil.NewClientTLSConfig()
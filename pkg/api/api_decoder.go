@@ -19,30 +19,107 @@ var Decoders = map[string]map[string]interface{}{
 	"/api/sessions": {
 		"POST": v1.CreateSessionRequest{},
 	},
+	"/api/sessions/{namespace}/{name}/invites": {
+		"POST": v1.CreateSessionInviteRequest{},
+	},
+	"/api/sessions/{namespace}/{name}/viewtokens": {
+		"POST": v1.CreateViewTokenRequest{},
+	},
 	"/api/users": {
 		"POST": v1.CreateUserRequest{},
 	},
 	"/api/users/{user}": {
 		"PUT": v1.UpdateUserRequest{},
 	},
+	"/api/impersonate/{user}": {
+		"POST": v1.ImpersonateRequest{},
+	},
+	"/api/users/{user}/password": {
+		"PUT": v1.ChangePasswordRequest{},
+	},
 	"/api/users/{user}/mfa": {
 		"PUT": v1.UpdateMFARequest{},
 	},
 	"/api/users/{user}/mfa/verify": {
 		"PUT": v1.AuthorizeRequest{},
 	},
+	"/api/users/{user}/userdata": {
+		"PUT": v1.UpdateUserDataRequest{},
+	},
+	"/api/users/{user}/secrets/{secret}": {
+		"PUT": v1.UpdateUserSecretRequest{},
+	},
+	"/api/users/{user}/dotfiles": {
+		"PUT": v1.DotfilesConfig{},
+	},
+	"/api/users/{user}/email": {
+		"PUT": v1.UserEmailConfig{},
+	},
+	"/api/users/{user}/keyboard": {
+		"PUT": v1.KeyboardLayoutConfig{},
+	},
+	"/api/reset-password": {
+		"POST": v1.PasswordResetRequest{},
+	},
+	"/api/reset-password/confirm": {
+		"POST": v1.ConfirmPasswordResetRequest{},
+	},
 	"/api/roles": {
 		"POST": v1.CreateRoleRequest{},
 	},
+	"/api/apikeys": {
+		"POST": v1.CreateAPIKeyRequest{},
+	},
 	"/api/templates": {
 		"POST": v1alpha1.DesktopTemplate{},
 	},
+	"/api/templates/export": {
+		"POST": v1.ExportTemplatesRequest{},
+	},
+	"/api/templates/import": {
+		"POST": v1alpha1.ImportTemplateBundleRequest{},
+	},
+	"/api/templates/{template}/rollback": {
+		"POST": v1.RollbackTemplateRequest{},
+	},
+	"/api/templates/{template}/estimate": {
+		"POST": v1.EstimateSessionRequest{},
+	},
+	"/api/roles/evaluate": {
+		"POST": v1.EvaluatePermissionRequest{},
+	},
 	"/api/roles/{role}": {
 		"PUT": v1.UpdateRoleRequest{},
 	},
+	"/api/roles/{role}/grants": {
+		"POST": v1.CreateRoleGrantRequest{},
+	},
+	"/api/groups": {
+		"POST": v1.CreateGroupRequest{},
+	},
+	"/api/groups/{group}": {
+		"PUT": v1.UpdateGroupRequest{},
+	},
+	"/api/announcements": {
+		"POST": v1.CreateAnnouncementRequest{},
+	},
+	"/api/announcements/{announcement}": {
+		"PUT": v1.UpdateAnnouncementRequest{},
+	},
 	"/api/login": {
 		"POST": v1.LoginRequest{},
 	},
+	"/api/admin/nodes/{node}/drain": {
+		"POST": v1.DrainNodeRequest{},
+	},
+}
+
+// Readers is a map of request paths/methods to functions that read the request
+// object, for requests with bodies that are not always JSON.
+var Readers = map[string]map[string]func(r *http.Request) (interface{}, error){
+	"/api/users/import": {
+		"POST": readImportUsersRequest,
+	},
 }
 
 // DecodeRequest will inspect the request object for the type of object
@@ -60,6 +137,16 @@ func DecodeRequest(next http.Handler) http.Handler {
 				apiutil.SetRequestObject(r, req)
 			}
 		}
+		if reader, ok := Readers[path]; ok {
+			if readFunc, ok := reader[r.Method]; ok {
+				req, err := readFunc(r)
+				if err != nil {
+					apiutil.ReturnAPIError(err, w)
+					return
+				}
+				apiutil.SetRequestObject(r, req)
+			}
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// recordDesktopActivity sets the last activity time on the given desktop to now.
+// It is called when display and audio connections are opened and closed so the
+// manager can track how long a desktop has been idle. Errors are only logged since
+// they should not interrupt the connection.
+func (d *desktopAPI) recordDesktopActivity(nn types.NamespacedName) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		desktop := &v1alpha1.Desktop{}
+		if err := d.client.Get(context.TODO(), nn, desktop); err != nil {
+			return err
+		}
+		now := metav1.Now()
+		desktop.Status.LastActivity = &now
+		return d.client.Status().Update(context.TODO(), desktop)
+	})
+	if err != nil {
+		apiLogger.Error(err, "Failed to record activity on desktop", "Desktop.Name", nn.Name, "Desktop.Namespace", nn.Namespace)
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// diskMonitorInterval is how often desktop disk usage is collected.
+const diskMonitorInterval = 30 * time.Second
+
+// diskWarningThreshold is the fraction of a desktop's ephemeral storage limit
+// at which users are warned that their desktop may be evicted.
+const diskWarningThreshold = 0.8
+
+// diskUsage represents the ephemeral storage usage of a desktop.
+type diskUsage struct {
+	UsedBytes  int64
+	LimitBytes int64
+	// whether the node the desktop is running on is under disk pressure
+	NodePressure bool
+}
+
+// Pressure returns true if the desktop is at risk of being evicted for disk usage.
+func (d *diskUsage) Pressure() bool {
+	if d.NodePressure {
+		return true
+	}
+	return d.LimitBytes > 0 && float64(d.UsedBytes) >= diskWarningThreshold*float64(d.LimitBytes)
+}
+
+// diskMonitor periodically collects the ephemeral storage usage of desktop pods
+// from the kubelets they are running on.
+type diskMonitor struct {
+	client      client.Client
+	clusterName string
+
+	mux   sync.RWMutex
+	usage map[types.NamespacedName]*diskUsage
+}
+
+func newDiskMonitor(c client.Client, clusterName string) *diskMonitor {
+	return &diskMonitor{
+		client:      c,
+		clusterName: clusterName,
+		usage:       make(map[types.NamespacedName]*diskUsage),
+	}
+}
+
+// Get returns the latest disk usage for the given desktop, or nil if it is not known.
+func (m *diskMonitor) Get(nn types.NamespacedName) *diskUsage {
+	if m == nil {
+		return nil
+	}
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.usage[nn]
+}
+
+// Run collects disk usage on an interval for the life of the process.
+func (m *diskMonitor) Run() {
+	if k8sutil.DefaultClient == nil {
+		apiLogger.Info("No in-cluster client available, desktop disk monitoring is disabled")
+		return
+	}
+	ticker := time.NewTicker(diskMonitorInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := m.collect(); err != nil {
+			apiLogger.Error(err, "Failed to collect desktop disk usage")
+		}
+	}
+}
+
+// collect refreshes the disk usage for all desktops in the cluster.
+func (m *diskMonitor) collect() error {
+	pods := &corev1.PodList{}
+	if err := m.client.List(context.TODO(), pods, client.MatchingLabels{
+		v1.VDIClusterLabel: m.clusterName,
+		v1.ComponentLabel:  "desktop",
+	}); err != nil {
+		return err
+	}
+
+	// group the pods by the node they are running on
+	podsByNode := make(map[string][]corev1.Pod)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+	}
+
+	usage := make(map[types.NamespacedName]*diskUsage)
+	for nodeName, nodePods := range podsByNode {
+		node := &corev1.Node{}
+		if err := m.client.Get(context.TODO(), types.NamespacedName{Name: nodeName, Namespace: metav1.NamespaceAll}, node); err != nil {
+			apiLogger.Error(err, "Failed to retrieve node", "Node.Name", nodeName)
+			continue
+		}
+		used, err := k8sutil.GetPodEphemeralStorageUsage(nodeName)
+		if err != nil {
+			apiLogger.Error(err, "Failed to retrieve stats summary", "Node.Name", nodeName)
+			continue
+		}
+		pressure := nodeHasDiskPressure(node)
+		for _, pod := range nodePods {
+			nn := types.NamespacedName{Name: pod.GetName(), Namespace: pod.GetNamespace()}
+			usage[nn] = &diskUsage{
+				UsedBytes:    used[nn],
+				LimitBytes:   podEphemeralStorageLimit(&pod),
+				NodePressure: pressure,
+			}
+		}
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for nn := range m.usage {
+		if _, ok := usage[nn]; !ok {
+			desktopEphemeralStorageUsedBytes.DeleteLabelValues(nn.String())
+			desktopEphemeralStorageLimitBytes.DeleteLabelValues(nn.String())
+		}
+	}
+	for nn, u := range usage {
+		desktopEphemeralStorageUsedBytes.WithLabelValues(nn.String()).Set(float64(u.UsedBytes))
+		desktopEphemeralStorageLimitBytes.WithLabelValues(nn.String()).Set(float64(u.LimitBytes))
+		if u.Pressure() {
+			apiLogger.Info(fmt.Sprintf("Desktop %s is at risk of eviction for disk usage", nn.String()), "UsedBytes", u.UsedBytes, "LimitBytes", u.LimitBytes)
+		}
+	}
+	m.usage = usage
+	return nil
+}
+
+// nodeHasDiskPressure returns true if the node is reporting the DiskPressure condition.
+func nodeHasDiskPressure(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeDiskPressure && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// podEphemeralStorageLimit returns the sum of the ephemeral storage limits of the
+// containers in the pod.
+func podEphemeralStorageLimit(pod *corev1.Pod) int64 {
+	var limit int64
+	for _, container := range pod.Spec.Containers {
+		if q, ok := container.Resources.Limits[corev1.ResourceEphemeralStorage]; ok {
+			limit += q.Value()
+		}
+	}
+	return limit
+}
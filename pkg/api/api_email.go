@@ -0,0 +1,76 @@
+package api
+
+import (
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/email"
+)
+
+// emailSender is the interface for sending notifications. It is satisfied by an
+// *email.Sender and swapped out in tests.
+type emailSender interface {
+	Send(to []string, msg email.Message, data *email.Data) error
+	AdminAddresses() []string
+	AppURL() string
+}
+
+// syncEmail rebuilds the email sender from the cluster configuration. The sender
+// is nil when email is not configured.
+func (d *desktopAPI) syncEmail(cluster *v1alpha1.VDICluster) error {
+	if !cluster.EmailEnabled() {
+		d.email = nil
+		return nil
+	}
+	sender, err := email.New(cluster, d.secrets)
+	if err != nil {
+		return err
+	}
+	d.email = sender
+	return nil
+}
+
+// sendEmail sends the given message in the background. Failures are logged, since
+// they should never fail the request that triggered them.
+func (d *desktopAPI) sendEmail(to []string, msg email.Message, data *email.Data) {
+	sender := d.email
+	if sender == nil || len(to) == 0 {
+		return
+	}
+	go func() {
+		if err := sender.Send(to, msg, data); err != nil {
+			apiLogger.Error(err, "Failed to send email", "Message", msg)
+		}
+	}()
+}
+
+// sendUserEmail sends the given message to the address configured for a user. When
+// they have not set one, the address from the auth provider is used if it has one.
+func (d *desktopAPI) sendUserEmail(username string, msg email.Message, data *email.Data) {
+	if d.email == nil {
+		return
+	}
+	addr, err := d.getUserEmail(username)
+	if err != nil {
+		apiLogger.Error(err, "Failed to look up email address", "User", username)
+		return
+	}
+	address := addr.Address
+	if address == "" && d.auth != nil {
+		if user, err := d.auth.GetUser(username); err == nil {
+			address = user.Email
+		}
+	}
+	if address == "" {
+		return
+	}
+	data.User = username
+	d.sendEmail([]string{address}, msg, data)
+}
+
+// alertAdmins sends an alert to the administrator addresses configured on the
+// cluster.
+func (d *desktopAPI) alertAdmins(reason, message string) {
+	if d.email == nil {
+		return
+	}
+	d.sendEmail(d.email.AdminAddresses(), email.MessageAdminAlert, &email.Data{Reason: reason, Message: message})
+}
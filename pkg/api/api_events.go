@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// eventSubscriberBuffer is the number of events queued for a subscriber before
+// new events are dropped for it.
+const eventSubscriberBuffer = 64
+
+// eventBroker fans out events to all the clients following the event stream.
+type eventBroker struct {
+	mux         sync.RWMutex
+	subscribers map[chan *v1.Event]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan *v1.Event]struct{})}
+}
+
+// Subscribe returns a channel that receives all events published after the call.
+func (b *eventBroker) Subscribe() chan *v1.Event {
+	ch := make(chan *v1.Event, eventSubscriberBuffer)
+	if b == nil {
+		return ch
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe stops sending events to the given channel.
+func (b *eventBroker) Unsubscribe(ch chan *v1.Event) {
+	if b == nil {
+		return
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	delete(b.subscribers, ch)
+}
+
+// Publish sends an event to all subscribers. Subscribers that are not keeping
+// up miss the event rather than blocking the publisher.
+func (b *eventBroker) Publish(event *v1.Event) {
+	if b == nil {
+		return
+	}
+	if event.Time == 0 {
+		event.Time = time.Now().Unix()
+	}
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			apiLogger.Info("Dropping event for slow event stream subscriber", "Type", event.Type)
+		}
+	}
+}
+
+// publishLoginEvent publishes a login event for the given user.
+func (d *desktopAPI) publishLoginEvent(user *v1.VDIUser) {
+	d.events.Publish(&v1.Event{Type: v1.EventUserLogin, User: user.GetName()})
+}
+
+// watchEvents adds handlers to the informers for desktops, roles, and announcements
+// that publish their lifecycle events. It should be called before the cache is started.
+func (d *desktopAPI) watchEvents(c cache.Cache) error {
+	// objects that already exist are sent as adds when the cache first syncs
+	started := time.Now().Truncate(time.Second)
+
+	desktopInformer, err := c.GetInformer(context.TODO(), &v1alpha1.Desktop{})
+	if err != nil {
+		return err
+	}
+	desktopInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			desktop, ok := obj.(*v1alpha1.Desktop)
+			if !ok || !d.isClusterDesktop(desktop) || desktop.GetCreationTimestamp().Time.Before(started) {
+				return
+			}
+			d.publishSessionEvent(v1.EventSessionCreated, desktop)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok := oldObj.(*v1alpha1.Desktop)
+			if !ok {
+				return
+			}
+			desktop, ok := newObj.(*v1alpha1.Desktop)
+			if !ok || !d.isClusterDesktop(desktop) {
+				return
+			}
+			if desktop.Status.Phase != "" && old.Status.Phase != desktop.Status.Phase {
+				d.publishSessionEvent(v1.EventSessionPhase, desktop)
+			}
+			if !desktopIsReady(old) && desktopIsReady(desktop) {
+				d.publishSessionEvent(v1.EventSessionReady, desktop)
+			}
+			if old.GetAnnotations()[v1.DrainAnnotation] != desktop.GetAnnotations()[v1.DrainAnnotation] {
+				if notice := desktop.GetDrainNotice(); notice != nil {
+					d.publishDrainEvent(desktop, notice)
+				}
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			desktop, ok := obj.(*v1alpha1.Desktop)
+			if !ok || !d.isClusterDesktop(desktop) {
+				return
+			}
+			d.publishSessionEvent(v1.EventSessionDeleted, desktop)
+		},
+	})
+
+	roleInformer, err := c.GetInformer(context.TODO(), &v1alpha1.VDIRole{})
+	if err != nil {
+		return err
+	}
+	roleInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			role, ok := obj.(*v1alpha1.VDIRole)
+			if !ok || !d.isClusterRole(role) || role.GetCreationTimestamp().Time.Before(started) {
+				return
+			}
+			d.events.Publish(&v1.Event{Type: v1.EventRoleCreated, Role: role.GetName()})
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok := oldObj.(*v1alpha1.VDIRole)
+			if !ok {
+				return
+			}
+			role, ok := newObj.(*v1alpha1.VDIRole)
+			if !ok || !d.isClusterRole(role) {
+				return
+			}
+			// the generation does not change with the metadata, but group bindings
+			// are stored in the annotations
+			if old.GetGeneration() == role.GetGeneration() && equality.Semantic.DeepEqual(old.GetAnnotations(), role.GetAnnotations()) {
+				return
+			}
+			d.events.Publish(&v1.Event{Type: v1.EventRoleUpdated, Role: role.GetName()})
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			role, ok := obj.(*v1alpha1.VDIRole)
+			if !ok || !d.isClusterRole(role) {
+				return
+			}
+			d.events.Publish(&v1.Event{Type: v1.EventRoleDeleted, Role: role.GetName()})
+		},
+	})
+
+	return d.watchAnnouncements(c, started)
+}
+
+func (d *desktopAPI) isClusterDesktop(desktop *v1alpha1.Desktop) bool {
+	return desktop.Spec.VDICluster == d.clusterName
+}
+
+func (d *desktopAPI) isClusterRole(role *v1alpha1.VDIRole) bool {
+	labels := role.GetLabels()
+	return labels != nil && labels[v1.RoleClusterRefLabel] == d.clusterName
+}
+
+func desktopIsReady(desktop *v1alpha1.Desktop) bool {
+	return desktop.Status.Running && desktop.Status.PodPhase == corev1.PodRunning
+}
+
+func (d *desktopAPI) publishSessionEvent(eventType v1.EventType, desktop *v1alpha1.Desktop) {
+	d.events.Publish(&v1.Event{
+		Type: eventType,
+		Session: &v1.EventSession{
+			Namespace: desktop.GetNamespace(),
+			Name:      desktop.GetName(),
+			Template:  desktop.Spec.Template,
+			User:      desktop.Spec.User,
+			Phase:     string(desktop.Status.Phase),
+		},
+	})
+}
+
+// publishDrainEvent publishes an event notifying the user of the given desktop
+// that it will be migrated or terminated.
+func (d *desktopAPI) publishDrainEvent(desktop *v1alpha1.Desktop, notice *v1.DrainNotice) {
+	d.events.Publish(&v1.Event{
+		Type: v1.EventSessionDraining,
+		Session: &v1.EventSession{
+			Namespace: desktop.GetNamespace(),
+			Name:      desktop.GetName(),
+			Template:  desktop.Spec.Template,
+			User:      desktop.Spec.User,
+			Phase:     string(desktop.Status.Phase),
+		},
+		Drain: notice,
+	})
+}
+
+// eventAllowed returns true if the given user is allowed to receive the event.
+// The rules mirror those for reading the same resources through the API.
+func eventAllowed(user *v1.VDIUser, event *v1.Event) bool {
+	switch event.Type {
+	case v1.EventSessionCreated, v1.EventSessionPhase, v1.EventSessionReady, v1.EventSessionDeleted, v1.EventSessionDraining:
+		if event.Session == nil {
+			return false
+		}
+		if event.Session.User != "" && event.Session.User == user.GetName() {
+			return true
+		}
+		return user.Evaluate(&v1.APIAction{
+			Verb:              v1.VerbRead,
+			ResourceType:      v1.ResourceTemplates,
+			ResourceName:      event.Session.Template,
+			ResourceNamespace: event.Session.Namespace,
+		}) && user.Evaluate(&v1.APIAction{
+			Verb:         v1.VerbRead,
+			ResourceType: v1.ResourceUsers,
+			ResourceName: event.Session.User,
+		})
+	case v1.EventUserLogin:
+		if event.User == user.GetName() {
+			return true
+		}
+		return user.Evaluate(&v1.APIAction{
+			Verb:         v1.VerbRead,
+			ResourceType: v1.ResourceUsers,
+			ResourceName: event.User,
+		})
+	case v1.EventRoleCreated, v1.EventRoleUpdated, v1.EventRoleDeleted:
+		return user.Evaluate(&v1.APIAction{
+			Verb:         v1.VerbRead,
+			ResourceType: v1.ResourceRoles,
+			ResourceName: event.Role,
+		})
+	case v1.EventAnnouncementPublished, v1.EventAnnouncementRemoved:
+		return event.Announcement != nil && event.Announcement.TargetsUser(user)
+	}
+	return false
+}
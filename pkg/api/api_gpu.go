@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// setGPUStatus populates the GPU status of any of the given templates that attach
+// GPUs to their desktops. Base templates are resolved against the templates given
+// when the GPU configuration is inherited. Nodes are only listed if at least one
+// template has GPUs.
+func (d *desktopAPI) setGPUStatus(templates []v1alpha1.DesktopTemplate) error {
+	rawTemplates := make(map[string]*v1alpha1.DesktopTemplate, len(templates))
+	for i := range templates {
+		rawTemplates[templates[i].GetName()] = &templates[i]
+	}
+	lookup := func(name string) (*v1alpha1.DesktopTemplate, error) {
+		if tmpl, ok := rawTemplates[name]; ok {
+			return tmpl, nil
+		}
+		return nil, fmt.Errorf("DesktopTemplate %s not found", name)
+	}
+
+	var nodes *corev1.NodeList
+	for i := range templates {
+		effective, err := templates[i].Resolve(lookup)
+		if err != nil {
+			// the base may not be in the list given, fall back to a full lookup
+			if effective, err = templates[i].GetEffectiveTemplate(d.client); err != nil {
+				return err
+			}
+		}
+		if !effective.HasGPU() {
+			continue
+		}
+		if nodes == nil {
+			nodes = &corev1.NodeList{}
+			if err := d.client.List(context.TODO(), nodes); err != nil {
+				return err
+			}
+		}
+		templates[i].Status.GPU = getGPUStatus(effective, nodes.Items)
+	}
+	return nil
+}
+
+// getGPUStatus returns the GPU status for the given template against the given nodes.
+func getGPUStatus(tmpl *v1alpha1.DesktopTemplate, nodes []corev1.Node) *v1alpha1.GPUStatus {
+	status := &v1alpha1.GPUStatus{}
+	for i := range nodes {
+		if !tmpl.GPUSchedulableOn(&nodes[i]) {
+			continue
+		}
+		allocatable := nodes[i].Status.Allocatable[tmpl.GetGPUResourceName()]
+		status.Nodes++
+		status.Allocatable += allocatable.Value()
+	}
+	status.Schedulable = status.Nodes > 0
+	return status
+}
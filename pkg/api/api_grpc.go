@@ -0,0 +1,190 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/api/rpc"
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+)
+
+// The package and name of the gRPC service.
+const (
+	grpcPackage     = "kvdi.v1"
+	grpcServiceName = "KVDI"
+)
+
+// grpcMethods are the REST endpoints exposed over gRPC. Calls are served by the
+// REST router, so they pass through the same authentication, authorization, and
+// audit middleware as HTTP requests.
+var grpcMethods = []*rpc.Method{
+	{
+		Name:        "Login",
+		Description: "Retrieve a session token for a user",
+		HTTPMethod:  http.MethodPost,
+		Path:        "/api/login",
+		Request:     v1.LoginRequest{},
+		Response:    v1.SessionResponse{},
+	},
+	{
+		Name:        "Authorize",
+		Description: "Verify a user's MFA token",
+		HTTPMethod:  http.MethodPost,
+		Path:        "/api/authorize",
+		Request:     v1.AuthorizeRequest{},
+		Response:    v1.SessionResponse{},
+	},
+	{
+		Name:        "ListUsers",
+		Description: "Retrieve a list of all users",
+		HTTPMethod:  http.MethodGet,
+		Path:        "/api/users",
+		Response:    []*v1.VDIUser{},
+		ListField:   "users",
+	},
+	{
+		Name:        "GetUser",
+		Description: "Retrieve information for a single user",
+		HTTPMethod:  http.MethodGet,
+		Path:        "/api/users/{user}",
+		Response:    v1.VDIUser{},
+	},
+	{
+		Name:        "CreateUser",
+		Description: "Create a new user",
+		HTTPMethod:  http.MethodPost,
+		Path:        "/api/users",
+		Request:     v1.CreateUserRequest{},
+	},
+	{
+		Name:        "UpdateUser",
+		Description: "Update a user",
+		HTTPMethod:  http.MethodPut,
+		Path:        "/api/users/{user}",
+		Request:     v1.UpdateUserRequest{},
+	},
+	{
+		Name:        "DeleteUser",
+		Description: "Delete a user",
+		HTTPMethod:  http.MethodDelete,
+		Path:        "/api/users/{user}",
+	},
+	{
+		Name:        "ListRoles",
+		Description: "Retrieve a list of all VDIRoles",
+		HTTPMethod:  http.MethodGet,
+		Path:        "/api/roles",
+		Response:    []*v1alpha1.VDIRole{},
+		ListField:   "roles",
+	},
+	{
+		Name:        "GetRole",
+		Description: "Retrieve information for a single VDIRole",
+		HTTPMethod:  http.MethodGet,
+		Path:        "/api/roles/{role}",
+		Response:    v1alpha1.VDIRole{},
+	},
+	{
+		Name:        "CreateRole",
+		Description: "Create a new VDIRole",
+		HTTPMethod:  http.MethodPost,
+		Path:        "/api/roles",
+		Request:     v1.CreateRoleRequest{},
+	},
+	{
+		Name:        "UpdateRole",
+		Description: "Update a VDIRole",
+		HTTPMethod:  http.MethodPut,
+		Path:        "/api/roles/{role}",
+		Request:     v1.UpdateRoleRequest{},
+	},
+	{
+		Name:        "DeleteRole",
+		Description: "Delete a VDIRole",
+		HTTPMethod:  http.MethodDelete,
+		Path:        "/api/roles/{role}",
+	},
+	{
+		Name:        "ListTemplates",
+		Description: "Retrieve a list of all available DesktopTemplates",
+		HTTPMethod:  http.MethodGet,
+		Path:        "/api/templates",
+		Response:    []*v1alpha1.DesktopTemplate{},
+		ListField:   "templates",
+	},
+	{
+		Name:        "GetTemplate",
+		Description: "Retrieve information for a single DesktopTemplate",
+		HTTPMethod:  http.MethodGet,
+		Path:        "/api/templates/{template}",
+		Response:    v1alpha1.DesktopTemplate{},
+	},
+	{
+		Name:        "CreateTemplate",
+		Description: "Create a new DesktopTemplate",
+		HTTPMethod:  http.MethodPost,
+		Path:        "/api/templates",
+		Request:     v1alpha1.DesktopTemplate{},
+	},
+	{
+		Name:        "UpdateTemplate",
+		Description: "Update a DesktopTemplate, only fields set in the request are applied",
+		HTTPMethod:  http.MethodPut,
+		Path:        "/api/templates/{template}",
+		Request:     v1alpha1.DesktopTemplate{},
+	},
+	{
+		Name:        "DeleteTemplate",
+		Description: "Delete a DesktopTemplate",
+		HTTPMethod:  http.MethodDelete,
+		Path:        "/api/templates/{template}",
+	},
+	{
+		Name:        "ListSessions",
+		Description: "Retrieve status information for all desktop sessions",
+		HTTPMethod:  http.MethodGet,
+		Path:        "/api/sessions",
+		Response:    v1.DesktopSessionsResponse{},
+	},
+	{
+		Name:        "GetSession",
+		Description: "Get the status of a desktop session",
+		HTTPMethod:  http.MethodGet,
+		Path:        "/api/sessions/{namespace}/{name}",
+		Response:    v1alpha1.DesktopSessionStatusResponse{},
+	},
+	{
+		Name:        "CreateSession",
+		Description: "Start a new desktop session",
+		HTTPMethod:  http.MethodPost,
+		Path:        "/api/sessions",
+		Request:     v1.CreateSessionRequest{},
+		Response:    v1.CreateSessionResponse{},
+	},
+	{
+		Name:        "DeleteSession",
+		Description: "Stop a desktop session",
+		HTTPMethod:  http.MethodDelete,
+		Path:        "/api/sessions/{namespace}/{name}",
+	},
+}
+
+// grpcService is the gRPC service built from the method table.
+var grpcService *rpc.Service
+
+func init() {
+	var err error
+	grpcService, err = rpc.NewService(grpcPackage, grpcServiceName, grpcMethods, grpcFieldNumbers)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GRPCProto returns the protobuf definitions for the gRPC API.
+func GRPCProto() string { return grpcService.Proto() }
+
+// GRPCHandler implements the DesktopAPI interface and returns a handler serving
+// the gRPC API.
+func (d *desktopAPI) GRPCHandler() http.Handler {
+	return grpcService.NewServer(d)
+}
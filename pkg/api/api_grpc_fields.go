@@ -0,0 +1,360 @@
+package api
+
+import "github.com/tinyzimmer/kvdi/pkg/api/rpc"
+
+// grpcFieldNumbers are the field numbers of the gRPC messages. They are part of the
+// wire format, so once published a number must not change or be given to another
+// field. Fields added to the API types need an entry here with the next unused
+// number in their message.
+var grpcFieldNumbers = rpc.FieldNumbers{
+	"AuthorizeRequest": {
+		"otp":         1,
+		"transaction": 2,
+		"state":       3,
+	},
+	"AvailabilityConfig": {
+		"time_zone":          1,
+		"windows":            2,
+		"terminate_sessions": 3,
+	},
+	"AvailabilityWindow": {
+		"schedule": 1,
+		"duration": 2,
+	},
+	"ConnectionStatus": {
+		"connected":   1,
+		"client_addr": 2,
+		"proxy_pod":   3,
+	},
+	"CreateRoleRequest": {
+		"name":                      1,
+		"annotations":               2,
+		"rules":                     3,
+		"inherits":                  4,
+		"token_duration":            5,
+		"watermark":                 6,
+		"max_sessions":              7,
+		"max_sessions_per_template": 8,
+		"placement":                 9,
+		"require_mfa":               10,
+	},
+	"CreateSessionRequest": {
+		"template":          1,
+		"namespace":         2,
+		"params":            3,
+		"snapshot":          4,
+		"template_revision": 5,
+		"env":               6,
+		"secret_env":        7,
+	},
+	"CreateSessionResponse": {
+		"name":      1,
+		"namespace": 2,
+	},
+	"CreateTemplateRequest": {
+		"kind":        1,
+		"api_version": 2,
+		"metadata":    3,
+		"spec":        4,
+		"status":      5,
+	},
+	"CreateUserRequest": {
+		"username":         1,
+		"password":         2,
+		"roles":            3,
+		"role_expirations": 4,
+	},
+	"DeleteRoleRequest": {
+		"role": 1,
+	},
+	"DeleteSessionRequest": {
+		"namespace": 1,
+		"name":      2,
+	},
+	"DeleteTemplateRequest": {
+		"template": 1,
+	},
+	"DeleteUserRequest": {
+		"user": 1,
+	},
+	"DesktopConfig": {
+		"service_account":     1,
+		"capabilities":        2,
+		"allow_root":          3,
+		"socket_addr":         4,
+		"socket_type":         5,
+		"rdp":                 6,
+		"allow_file_transfer": 7,
+		"file_transfer":       8,
+		"clipboard":           9,
+		"allow_microphone":    10,
+		"allow_smart_card":    11,
+		"allow_printing":      12,
+		"usb":                 13,
+		"watermark":           14,
+		"record_sessions":     15,
+		"reconnect_timeout":   16,
+		"proxy_image":         17,
+		"init":                18,
+		"display":             19,
+	},
+	"DesktopNetworkConfig": {
+		"ingress": 1,
+		"egress":  2,
+	},
+	"DesktopPodConfig": {
+		"annotations":     1,
+		"labels":          2,
+		"init_containers": 3,
+		"sidecars":        4,
+		"volumes":         5,
+		"volume_mounts":   6,
+	},
+	"DesktopSession": {
+		"name":      1,
+		"namespace": 2,
+		"user":      3,
+		"status":    4,
+	},
+	"DesktopSessionStatus": {
+		"display": 1,
+		"audio":   2,
+	},
+	"DesktopSessionStatusResponse": {
+		"running":             1,
+		"pod_phase":           2,
+		"pod_error":           3,
+		"preempted":           4,
+		"drained":             5,
+		"drain":               6,
+		"disk_used_bytes":     7,
+		"disk_limit_bytes":    8,
+		"disk_pressure":       9,
+		"terminates_at":       10,
+		"termination_reason":  11,
+		"termination_pending": 12,
+		"clipboard":           13,
+		"file_transfer":       14,
+		"microphone":          15,
+		"phase":               16,
+	},
+	"DesktopSessionsResponse": {
+		"sessions": 1,
+		"metadata": 2,
+	},
+	"DesktopTemplate": {
+		"kind":        1,
+		"api_version": 2,
+		"metadata":    3,
+		"spec":        4,
+		"status":      5,
+	},
+	"DesktopTemplateParameter": {
+		"name":             1,
+		"description":      2,
+		"type":             3,
+		"default":          4,
+		"options":          5,
+		"minimum":          6,
+		"maximum":          7,
+		"env":              8,
+		"resource":         9,
+		"claim_mount_path": 10,
+	},
+	"DesktopTemplateSpec": {
+		"base_template":          1,
+		"image":                  2,
+		"image_pull_policy":      3,
+		"image_pull_secrets":     4,
+		"resources":              5,
+		"gpu":                    6,
+		"env":                    7,
+		"session_env":            8,
+		"config":                 9,
+		"tags":                   10,
+		"description":            11,
+		"icon":                   12,
+		"namespaces":             13,
+		"max_sessions":           14,
+		"availability":           15,
+		"idle_timeout":           16,
+		"max_lifetime":           17,
+		"user_data":              18,
+		"parameters":             19,
+		"pod":                    20,
+		"network":                21,
+		"security_profiles":      22,
+		"version":                23,
+		"session_update_policy":  24,
+		"revision_history_limit": 25,
+	},
+	"DesktopTemplateStatus": {
+		"gpu": 1,
+	},
+	"DisplayConfig": {
+		"max_quality":    1,
+		"max_frame_rate": 2,
+		"max_bandwidth":  3,
+	},
+	"DrainNotice": {
+		"node":     1,
+		"action":   2,
+		"deadline": 3,
+		"message":  4,
+	},
+	"GPUConfig": {
+		"vendor":        1,
+		"count":         2,
+		"resource_name": 3,
+		"node_selector": 4,
+		"tolerations":   5,
+	},
+	"GPUStatus": {
+		"schedulable": 1,
+		"nodes":       2,
+		"allocatable": 3,
+	},
+	"GetRoleRequest": {
+		"role": 1,
+	},
+	"GetSessionRequest": {
+		"namespace": 1,
+		"name":      2,
+	},
+	"GetTemplateRequest": {
+		"template": 1,
+	},
+	"GetUserRequest": {
+		"user": 1,
+	},
+	"ListMeta": {
+		"total":    1,
+		"continue": 2,
+	},
+	"ListRolesResponse": {
+		"roles": 1,
+	},
+	"ListTemplatesResponse": {
+		"templates": 1,
+	},
+	"ListUsersResponse": {
+		"users": 1,
+	},
+	"LoginRequest": {
+		"username": 1,
+		"password": 2,
+		"state":    3,
+	},
+	"NetworkRulesConfig": {
+		"allowed_cid_rs": 1,
+		"allow_dns":      2,
+	},
+	"OKResponse": {
+		"ok": 1,
+	},
+	"RDPConfig": {
+		"address":            1,
+		"security":           2,
+		"ignore_cert":        3,
+		"domain":             4,
+		"credentials_secret": 5,
+		"guacd_image":        6,
+	},
+	"Rule": {
+		"verbs":             1,
+		"resources":         2,
+		"resource_patterns": 3,
+		"namespaces":        4,
+	},
+	"SecurityProfilesConfig": {
+		"seccomp":   1,
+		"app_armor": 2,
+		"se_linux":  3,
+	},
+	"SessionEnvConfig": {
+		"allowed":      1,
+		"user_secrets": 2,
+	},
+	"SessionPlacement": {
+		"namespaces":    1,
+		"node_selector": 2,
+		"max_cpu":       3,
+		"max_memory":    4,
+	},
+	"SessionResponse": {
+		"token":                   1,
+		"expires_at":              2,
+		"renewable":               3,
+		"user":                    4,
+		"authorized":              5,
+		"state":                   6,
+		"mfa_method":              7,
+		"mfa_enrollment_required": 8,
+	},
+	"USBConfig": {
+		"allowed_classes": 1,
+	},
+	"UpdateRoleRequest": {
+		"role":                      1,
+		"annotations":               2,
+		"rules":                     3,
+		"inherits":                  4,
+		"token_duration":            5,
+		"watermark":                 6,
+		"max_sessions":              7,
+		"max_sessions_per_template": 8,
+		"placement":                 9,
+		"require_mfa":               10,
+	},
+	"UpdateTemplateRequest": {
+		"template":    1,
+		"kind":        2,
+		"api_version": 3,
+		"metadata":    4,
+		"spec":        5,
+		"status":      6,
+	},
+	"UpdateUserRequest": {
+		"user":             1,
+		"password":         2,
+		"roles":            3,
+		"role_expirations": 4,
+	},
+	"UserMFAStatus": {
+		"enabled":  1,
+		"verified": 2,
+	},
+	"VDIRole": {
+		"kind":                      1,
+		"api_version":               2,
+		"metadata":                  3,
+		"rules":                     4,
+		"inherits":                  5,
+		"token_duration":            6,
+		"watermark":                 7,
+		"max_sessions":              8,
+		"max_sessions_per_template": 9,
+		"placement":                 10,
+		"require_mfa":               11,
+	},
+	"VDIUser": {
+		"name":         1,
+		"display_name": 2,
+		"email":        3,
+		"roles":        4,
+		"groups":       5,
+		"mfa":          6,
+		"restrictions": 7,
+	},
+	"VDIUserRole": {
+		"name":                      1,
+		"rules":                     2,
+		"token_duration":            3,
+		"watermark":                 4,
+		"max_sessions":              5,
+		"max_sessions_per_template": 6,
+		"placement":                 7,
+		"expires_at":                8,
+		"require_mfa":               9,
+	},
+}
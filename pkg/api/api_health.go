@@ -1,32 +1,176 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"k8s.io/apimachinery/pkg/types"
 )
 
-func (d *desktopAPI) Healthz(w http.ResponseWriter, r *http.Request) {}
+// healthCheckTimeout is how long a component has to respond to a health check
+// before it is reported as failed.
+const healthCheckTimeout = 10 * time.Second
+
+// healthCheck is a check against a single component the app depends on.
+type healthCheck struct {
+	name    string
+	backend string
+	check   func() error
+}
 
+// swagger:route GET /api/healthz Miscellaneous getHealthz
+// Reports the status of the components the app depends on. The response code is
+// always 200 while the app is serving requests, so that a failing dependency does
+// not cause liveness probes to restart it.
+// responses:
+//   200: healthResponse
+func (d *desktopAPI) Healthz(w http.ResponseWriter, r *http.Request) {
+	apiutil.WriteJSON(d.checkHealth(), w)
+}
+
+// swagger:route GET /api/readyz Miscellaneous getReadyz
+// Reports the status of the components the app depends on. A 503 is returned if
+// any of them are failing, so load balancers stop routing requests to the app.
+// responses:
+//   200: healthResponse
+//   503: healthResponse
 func (d *desktopAPI) Readyz(w http.ResponseWriter, r *http.Request) {
-	if errs := d.checkReadiness(); len(errs) != 0 {
-		apiutil.ReturnAPIErrors(errs, w)
+	report := d.checkHealth()
+	if !report.Healthy() {
+		out, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		apiutil.WriteOrLogError(out, w, http.StatusServiceUnavailable)
 		return
 	}
-	apiutil.WriteOK(w)
+	apiutil.WriteJSON(report, w)
+}
+
+// healthCheckResult is the outcome of a single health check.
+type healthCheckResult struct {
+	err     error
+	latency time.Duration
 }
 
-func (d *desktopAPI) checkReadiness() []error {
-	errs := make([]error, 0)
-	if d.auth == nil {
-		errs = append(errs, errors.New("Authentication has not been setup yet"))
+// checkHealth runs the health checks for every component concurrently and returns
+// the results. Components that do not respond within the timeout are reported as
+// failed.
+func (d *desktopAPI) checkHealth() *v1.HealthReport {
+	checks := d.healthChecks()
+	report := &v1.HealthReport{
+		Status:     v1.HealthStatusOK,
+		Components: make([]*v1.ComponentHealth, len(checks)),
 	}
-	if d.secrets == nil {
-		errs = append(errs, errors.New("Secrets storage has not been setup yet"))
+	results := make([]chan healthCheckResult, len(checks))
+	for idx, check := range checks {
+		results[idx] = make(chan healthCheckResult, 1)
+		go func(check func() error, results chan<- healthCheckResult) {
+			started := time.Now()
+			err := check()
+			results <- healthCheckResult{err: err, latency: time.Since(started)}
+		}(check.check, results[idx])
 	}
-	if d.mfa == nil {
-		errs = append(errs, errors.New("MFA storage has not been setup yet "))
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	for idx, check := range checks {
+		var result healthCheckResult
+		select {
+		case result = <-results[idx]:
+		case <-ctx.Done():
+			// prefer a result that arrived at the same time as the deadline
+			select {
+			case result = <-results[idx]:
+			default:
+				result = healthCheckResult{
+					err:     fmt.Errorf("Timed out after %s", healthCheckTimeout),
+					latency: healthCheckTimeout,
+				}
+			}
+		}
+		component := &v1.ComponentHealth{
+			Name:          check.name,
+			Backend:       check.backend,
+			Status:        v1.HealthStatusOK,
+			LatencyMillis: result.latency.Milliseconds(),
+		}
+		if result.err != nil {
+			component.Status = v1.HealthStatusFailed
+			component.Error = result.err.Error()
+			report.Status = v1.HealthStatusFailed
+		}
+		report.Components[idx] = component
 	}
-	return errs
+	return report
+}
+
+// healthChecks returns the checks for the Kubernetes API, the secrets backend, the
+// auth provider, and MFA storage. Auth providers that depend on a remote service,
+// e.g. an LDAP server or OIDC issuer, are checked by implementing the HealthChecker
+// interface.
+func (d *desktopAPI) healthChecks() []*healthCheck {
+	var secretsBackend, authBackend string
+	if d.vdiCluster != nil {
+		secretsBackend, authBackend = d.vdiCluster.GetSecretsBackend(), d.vdiCluster.GetAuthBackend()
+	}
+	return []*healthCheck{
+		{
+			name: v1.HealthComponentKubernetes,
+			check: func() error {
+				if d.client == nil {
+					return errors.New("Kubernetes client has not been setup yet")
+				}
+				return d.client.Get(context.TODO(), types.NamespacedName{Name: d.clusterName}, &v1alpha1.VDICluster{})
+			},
+		},
+		{
+			name:    v1.HealthComponentSecrets,
+			backend: secretsBackend,
+			check: func() error {
+				if d.secrets == nil {
+					return errors.New("Secrets storage has not been setup yet")
+				}
+				return d.secrets.CheckHealth()
+			},
+		},
+		{
+			name:    v1.HealthComponentAuth,
+			backend: authBackend,
+			check: func() error {
+				if d.auth == nil {
+					return errors.New("Authentication has not been setup yet")
+				}
+				if checker, ok := d.auth.(common.HealthChecker); ok {
+					return checker.CheckHealth()
+				}
+				return nil
+			},
+		},
+		{
+			name: v1.HealthComponentMFA,
+			check: func() error {
+				if d.mfa == nil {
+					return errors.New("MFA storage has not been setup yet")
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Health report response
+// swagger:response healthResponse
+type swaggerHealthResponse struct {
+	// in:body
+	Body v1.HealthReport
 }
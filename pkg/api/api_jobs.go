@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"github.com/google/uuid"
+)
+
+// jobHeartbeatInterval is how often a running job records that it is still in
+// progress, even when it has no new progress to report.
+const jobHeartbeatInterval = 30 * time.Second
+
+// jobStaleAfter is how long a running job can go without a heartbeat before it is
+// considered interrupted.
+const jobStaleAfter = 3 * jobHeartbeatInterval
+
+// jobRetention is how long jobs are kept after they were last updated.
+const jobRetention = 24 * time.Hour
+
+// errJobInterrupted is reported for running jobs that stopped sending heartbeats.
+const errJobInterrupted = "The job was interrupted, the app instance running it may have restarted"
+
+// jobProgressFunc is used by jobs to report the percentage of the operation that has
+// completed and what it is currently doing.
+type jobProgressFunc func(progress int, message string)
+
+// jobFunc performs the operation for a job. The returned result is set on the job
+// even if an error is also returned.
+type jobFunc func(progress jobProgressFunc) (interface{}, error)
+
+// isAsyncRequest returns true if the client asked for the request to be run as a job.
+func isAsyncRequest(r *http.Request) bool {
+	return r.URL.Query().Get(v1.AsyncQueryParam) == "true"
+}
+
+// startJob records a new job for the user making the given request and runs fn in
+// the background. The job is returned in its initial state.
+func (d *desktopAPI) startJob(r *http.Request, jobType v1.JobType, fn jobFunc) (*v1.Job, error) {
+	now := time.Now().Unix()
+	job := &v1.Job{
+		ID:        uuid.New().String(),
+		Type:      jobType,
+		User:      apiutil.GetRequestUserSession(r).User.GetName(),
+		State:     v1.JobRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := d.saveJob(job); err != nil {
+		return nil, err
+	}
+	running := *job
+	go d.runJob(&running, fn)
+	return job, nil
+}
+
+// runJob runs fn and records its progress and result on the given job.
+func (d *desktopAPI) runJob(job *v1.Job, fn jobFunc) {
+	var mux sync.Mutex
+	save := func() {
+		job.UpdatedAt = time.Now().Unix()
+		if err := d.saveJob(job); err != nil {
+			apiLogger.Error(err, "Failed to save job", "Job.ID", job.ID, "Job.Type", job.Type)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mux.Lock()
+				save()
+				mux.Unlock()
+			}
+		}
+	}()
+
+	result, err := fn(func(progress int, message string) {
+		mux.Lock()
+		defer mux.Unlock()
+		job.Progress = progress
+		job.Message = message
+		save()
+	})
+	close(done)
+
+	mux.Lock()
+	defer mux.Unlock()
+	if result != nil {
+		out, merr := json.Marshal(result)
+		if merr != nil {
+			apiLogger.Error(merr, "Failed to marshal job result", "Job.ID", job.ID, "Job.Type", job.Type)
+		} else {
+			job.Result = out
+		}
+	}
+	if err != nil {
+		apiLogger.Error(err, "Job failed", "Job.ID", job.ID, "Job.Type", job.Type, "User", job.User)
+		job.State = v1.JobFailed
+		job.Error = err.Error()
+	} else {
+		job.State = v1.JobSucceeded
+		job.Progress = 100
+	}
+	job.Message = ""
+	job.CompletedAt = time.Now().Unix()
+	save()
+}
+
+// saveJob writes the given job to the secrets backend, removing any jobs past the
+// retention.
+func (d *desktopAPI) saveJob(job *v1.Job) error {
+	out, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := d.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer d.secrets.Release()
+	jobs, err := d.readSecretMapIfExists(v1.JobsSecretKey)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-jobRetention).Unix()
+	for id, data := range jobs {
+		existing := &v1.Job{}
+		if err := json.Unmarshal(data, existing); err != nil || existing.UpdatedAt < cutoff {
+			delete(jobs, id)
+		}
+	}
+	jobs[job.ID] = out
+	return d.secrets.WriteSecretMap(v1.JobsSecretKey, jobs)
+}
+
+// readJobs returns all the jobs in the secrets backend. Running jobs that stopped
+// sending heartbeats are returned as failed.
+func (d *desktopAPI) readJobs() ([]*v1.Job, error) {
+	data, err := d.readSecretMapIfExists(v1.JobsSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	stale := time.Now().Add(-jobStaleAfter).Unix()
+	jobs := make([]*v1.Job, 0, len(data))
+	for _, raw := range data {
+		job := &v1.Job{}
+		if err := json.Unmarshal(raw, job); err != nil {
+			return nil, err
+		}
+		if !job.Done() && job.UpdatedAt < stale {
+			job.State = v1.JobFailed
+			job.Error = errJobInterrupted
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// getUserJob returns the job with the given ID if it belongs to the given user, or
+// nil if it doesn't exist.
+func (d *desktopAPI) getUserJob(user, id string) (*v1.Job, error) {
+	jobs, err := d.readJobs()
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if job.ID == id && job.User == user {
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+// listUserJobs returns the jobs started by the given user, newest first.
+func (d *desktopAPI) listUserJobs(user string) ([]*v1.Job, error) {
+	jobs, err := d.readJobs()
+	if err != nil {
+		return nil, err
+	}
+	userJobs := make([]*v1.Job, 0)
+	for _, job := range jobs {
+		if job.User == user {
+			userJobs = append(userJobs, job)
+		}
+	}
+	sort.SliceStable(userJobs, func(i, j int) bool {
+		return userJobs[i].CreatedAt > userJobs[j].CreatedAt
+	})
+	return userJobs, nil
+}
+
+// runAsJob starts fn as a job for the given request and writes the job to the
+// response.
+func (d *desktopAPI) runAsJob(w http.ResponseWriter, r *http.Request, jobType v1.JobType, fn jobFunc) {
+	job, err := d.startJob(r, jobType, fn)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(job, w)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// jwtKeyCache holds the key set built from the JWT secret and signing keys, so
+// the keys are only parsed again when they change in the secrets backend.
+type jwtKeyCache struct {
+	mux     sync.Mutex
+	secret  []byte
+	keys    []byte
+	useKeys bool
+	set     *apiutil.JWTKeySet
+}
+
+// getJWTKeys returns the keys for signing and verifying access tokens. When cache
+// is false, the keys are read from the secrets backend instead of the cache.
+func (d *desktopAPI) getJWTKeys(cache bool) (*apiutil.JWTKeySet, error) {
+	secret, err := d.secrets.ReadSecret(v1.JWTSecretKey, cache)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := d.secrets.ReadSecret(v1.JWTSigningKeysSecretKey, cache)
+	if err != nil && !errors.IsSecretNotFoundError(err) {
+		return nil, err
+	}
+	useKeys := d.vdiCluster.IsUsingTokenSigningKeys()
+
+	c := &d.jwtKeys
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.set != nil && c.useKeys == useKeys && bytes.Equal(c.secret, secret) && bytes.Equal(c.keys, keys) {
+		return c.set, nil
+	}
+	signingKeys := make([]*v1.JWTSigningKey, 0)
+	if len(keys) > 0 {
+		if err := json.Unmarshal(keys, &signingKeys); err != nil {
+			return nil, err
+		}
+	}
+	set, err := apiutil.NewJWTKeySet(secret, signingKeys, useKeys)
+	if err != nil {
+		return nil, err
+	}
+	c.secret, c.keys, c.useKeys, c.set = secret, keys, useKeys, set
+	return set, nil
+}
+
+// decodeAndVerifyJWT verifies the given access token with the given keys and
+// returns its claims. If the token was signed with a key that is not in the set,
+// the keys are read again in case they were rotated.
+func (d *desktopAPI) decodeAndVerifyJWT(keys *apiutil.JWTKeySet, authToken string) (*v1.JWTClaims, error) {
+	claims, err := apiutil.DecodeAndVerifyJWT(keys, authToken)
+	if err != apiutil.ErrUnknownTokenKey {
+		return claims, err
+	}
+	if keys, err = d.getJWTKeys(false); err != nil {
+		return nil, err
+	}
+	return apiutil.DecodeAndVerifyJWT(keys, authToken)
+}
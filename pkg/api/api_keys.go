@@ -0,0 +1,177 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/google/uuid"
+)
+
+// storedAPIKey is the representation of an API key kept in the secrets backend.
+// Only a hash of the key's secret is stored.
+type storedAPIKey struct {
+	*v1.APIKey
+	Hash string `json:"hash"`
+}
+
+// hashAPIKeySecret returns the hex encoded sha256 of an API key secret.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseAPIKeyToken splits an API key token into its ID and secret.
+func parseAPIKeyToken(token string) (id, secret string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(token, v1.APIKeyTokenPrefix), ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.New("Malformed API key")
+	}
+	return parts[0], parts[1], nil
+}
+
+// readAPIKeys returns all API keys in the secrets backend.
+func (d *desktopAPI) readAPIKeys(cache bool) (map[string]*storedAPIKey, error) {
+	keys := make(map[string]*storedAPIKey)
+	data, err := d.secrets.ReadSecretMap(v1.APIKeysSecretKey, cache)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return keys, nil
+		}
+		return nil, err
+	}
+	for id, raw := range data {
+		key := &storedAPIKey{}
+		if err := json.Unmarshal(raw, key); err != nil {
+			return nil, err
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// writeAPIKeys writes the given API keys to the secrets backend. The caller
+// should hold the secrets lock.
+func (d *desktopAPI) writeAPIKeys(keys map[string]*storedAPIKey) error {
+	data := make(map[string][]byte)
+	for id, key := range keys {
+		raw, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		data[id] = raw
+	}
+	return d.secrets.WriteSecretMap(v1.APIKeysSecretKey, data)
+}
+
+// getUserAPIKeys returns the API keys belonging to the given user, sorted by
+// creation time.
+func (d *desktopAPI) getUserAPIKeys(username string) ([]*v1.APIKey, error) {
+	keys, err := d.readAPIKeys(false)
+	if err != nil {
+		return nil, err
+	}
+	userKeys := make([]*v1.APIKey, 0)
+	for _, key := range keys {
+		if key.User == username {
+			userKeys = append(userKeys, key.APIKey)
+		}
+	}
+	sort.Slice(userKeys, func(i, j int) bool { return userKeys[i].CreatedAt < userKeys[j].CreatedAt })
+	return userKeys, nil
+}
+
+// createAPIKey generates a new API key for the given user and returns it along
+// with its token.
+func (d *desktopAPI) createAPIKey(username string, req *v1.CreateAPIKeyRequest) (*v1.APIKey, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	key := &v1.APIKey{
+		ID:        strings.Replace(uuid.New().String(), "-", "", -1),
+		Name:      req.Name,
+		User:      username,
+		Rules:     req.Rules,
+		CreatedAt: time.Now().Unix(),
+	}
+	if expiresIn := req.GetExpiresIn(); expiresIn > 0 {
+		key.ExpiresAt = time.Now().Add(expiresIn).Unix()
+	}
+
+	if err := d.secrets.Lock(10); err != nil {
+		return nil, "", err
+	}
+	defer d.secrets.Release()
+	keys, err := d.readAPIKeys(false)
+	if err != nil {
+		return nil, "", err
+	}
+	keys[key.ID] = &storedAPIKey{APIKey: key, Hash: hashAPIKeySecret(secret)}
+	if err := d.writeAPIKeys(keys); err != nil {
+		return nil, "", err
+	}
+	return key, fmt.Sprintf("%s%s.%s", v1.APIKeyTokenPrefix, key.ID, secret), nil
+}
+
+// deleteAPIKey removes the API key with the given ID.
+func (d *desktopAPI) deleteAPIKey(id string) error {
+	if err := d.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer d.secrets.Release()
+	keys, err := d.readAPIKeys(false)
+	if err != nil {
+		return err
+	}
+	delete(keys, id)
+	return d.writeAPIKeys(keys)
+}
+
+// getSessionFromAPIKey verifies the given API key token and returns claims for
+// the user it belongs to, restricted to the rules on the key. The user's roles
+// and grants are looked up on every request, so keys lose access along with their
+// owner. Keys are rejected while the owner's roles require MFA they have not set
+// up, the same as logging in.
+func (d *desktopAPI) getSessionFromAPIKey(token string) (*v1.JWTClaims, error) {
+	id, secret, err := parseAPIKeyToken(token)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := d.readAPIKeys(true)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKeySecret(secret))) != 1 {
+		return nil, errors.New("Invalid API key")
+	}
+	if key.Expired() {
+		return nil, errors.New("API key has expired")
+	}
+	user, err := d.auth.GetUser(key.User)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.applyRoleGrants(user); err != nil {
+		return nil, err
+	}
+	if required, err := d.mfaEnrollmentRequired(user); err != nil {
+		return nil, err
+	} else if required {
+		return nil, errors.New("MFA is required for the roles of the API key's user, log in to set it up")
+	}
+	user.Restrictions = key.Rules
+	return &v1.JWTClaims{User: user, Authorized: true, APIKey: key.ID}, nil
+}
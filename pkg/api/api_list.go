@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// writeList writes the given items to the response. When a page of results was
+// requested they are wrapped in a list response with the given metadata.
+func writeList(opts *v1.ListOptions, meta *v1.ListMeta, items interface{}, w http.ResponseWriter) {
+	if opts.Paginated() {
+		apiutil.WriteJSON(&v1.ListResponse{Metadata: meta, Items: items}, w)
+		return
+	}
+	apiutil.WriteJSON(items, w)
+}
+
+// Options for paginating, filtering, and sorting lists
+// swagger:parameters getUsers getRoles getTemplates getDesktopSessions
+type swaggerListOptions struct {
+	// The maximum number of items to return. When set, a page of results is returned
+	// in a list response.
+	// in:query
+	Limit int `json:"limit"`
+	// The token returned with the previous page of results.
+	// in:query
+	Continue string `json:"continue"`
+	// The field to sort by, defaults to `name`.
+	// in:query
+	SortBy string `json:"sortBy"`
+	// The order to sort in, either `asc` or `desc`.
+	// in:query
+	Order string `json:"order"`
+	// Filters in the format of `<field>=<value>`. Only items where the field contains
+	// the value, ignoring case, are returned.
+	// in:query
+	Filter []string `json:"filter"`
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/lock"
+)
+
+// lockCheckInterval is how often a proxied connection checks that it still holds
+// the lock on its desktop.
+var lockCheckInterval = 5 * time.Second
+
+// getDisplayTakeoverKey returns the key allowing a display connection to take over
+// the lock on a desktop from an earlier connection of the same client. The client
+// identifies its display session with the resume token, which may reach any replica
+// of the app when it reconnects. An empty string is returned when the client did
+// not provide one.
+func getDisplayTakeoverKey(r *http.Request) string {
+	token := r.URL.Query().Get(v1.ResumeTokenQueryParam)
+	if token == "" || !resumeTokenRegex.MatchString(token) {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiutil.GetRequestUserSession(r).User.GetName() + "/" + token))
+	return hex.EncodeToString(sum[:])
+}
+
+// watchLock returns a ResponseWriter that closes the connection hijacked from it
+// once the given lock is taken over, along with a function to stop watching. This
+// ends a proxied connection that was replaced by another one, possibly on another
+// replica of the app, which would otherwise stay open until it timed out.
+func watchLock(w http.ResponseWriter, l *lock.Lock) (http.ResponseWriter, func()) {
+	watcher := &lockWatchingResponseWriter{ResponseWriter: w}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				held, err := l.IsHeld()
+				if err != nil {
+					apiLogger.Error(err, "Failed to check lock on desktop", "Lock", l.GetName())
+					continue
+				}
+				if !held {
+					apiLogger.Info("Lock on desktop was taken over, closing connection", "Lock", l.GetName())
+					watcher.close()
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return watcher, func() { once.Do(func() { close(stop) }) }
+}
+
+// lockWatchingResponseWriter keeps track of the connection hijacked from a
+// ResponseWriter, and of the proxied connection to the desktop, so they can be
+// closed by a lock watcher.
+type lockWatchingResponseWriter struct {
+	http.ResponseWriter
+	conn    net.Conn
+	backend net.Conn
+	mux     sync.Mutex
+}
+
+// dialBackend dials the desktop for the proxy and keeps track of the connection.
+func (l *lockWatchingResponseWriter) dialBackend(network, addr string) (net.Conn, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.backend = conn
+	return conn, nil
+}
+
+// Hijack implements the http.Hijacker interface.
+func (l *lockWatchingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := l.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.conn = conn
+	return conn, rw, nil
+}
+
+// close ends the proxied connection. When the desktop was dialed, only that side
+// is closed, so the proxy sends the client a close frame. Clients treat this as
+// a clean disconnect, instead of trying to reconnect and waiting for the lock.
+func (l *lockWatchingResponseWriter) close() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.backend != nil {
+		l.backend.Close()
+		return
+	}
+	if l.conn != nil {
+		l.conn.Close()
+	}
+}
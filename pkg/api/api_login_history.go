@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// recordLoginAttempt adds a login attempt for the given username to the history
+// in the secrets backend. Errors are logged and do not fail the login.
+func (d *desktopAPI) recordLoginAttempt(r *http.Request, username string, success bool, mfa v1.LoginMFAResult) {
+	cfg := d.vdiCluster.GetLoginHistory()
+	if cfg.Disabled {
+		return
+	}
+	attempt := &v1.LoginAttempt{
+		Time:       time.Now().Unix(),
+		User:       username,
+		Provider:   d.vdiCluster.GetAuthBackend(),
+		ClientAddr: getClientAddr(r),
+		UserAgent:  r.UserAgent(),
+		Success:    success,
+		MFA:        mfa,
+	}
+	if err := d.appendLoginAttempt(attempt, cfg.GetMaxAttempts(), cfg.GetRetention()); err != nil {
+		apiLogger.Error(err, "Failed to record login attempt", "User", username)
+	}
+}
+
+// appendLoginAttempt adds the given attempt to the history, removing attempts that
+// are older than the retention or past the maximum number kept.
+func (d *desktopAPI) appendLoginAttempt(attempt *v1.LoginAttempt, maxAttempts int, retention time.Duration) error {
+	if err := d.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer d.secrets.Release()
+	attempts, err := d.readLoginHistory()
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-retention).Unix()
+	kept := make([]*v1.LoginAttempt, 0, len(attempts)+1)
+	for _, existing := range attempts {
+		if existing.Time >= cutoff {
+			kept = append(kept, existing)
+		}
+	}
+	kept = append(kept, attempt)
+	if len(kept) > maxAttempts {
+		kept = kept[len(kept)-maxAttempts:]
+	}
+	out, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+	return d.secrets.WriteSecret(v1.LoginHistorySecretKey, out)
+}
+
+// readLoginHistory returns the recorded login attempts, oldest first.
+func (d *desktopAPI) readLoginHistory() ([]*v1.LoginAttempt, error) {
+	attempts := make([]*v1.LoginAttempt, 0)
+	data, err := d.secrets.ReadSecret(v1.LoginHistorySecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return attempts, nil
+		}
+		return nil, err
+	}
+	return attempts, json.Unmarshal(data, &attempts)
+}
+
+// loginHistoryQuery filters the login history.
+type loginHistoryQuery struct {
+	user       string
+	start, end time.Time
+	// when not nil, only attempts with the given outcome are returned
+	success *bool
+}
+
+// parseLoginHistoryQuery reads the time range and outcome to filter login attempts
+// by from the query of the given request. Times are in RFC3339 format.
+func parseLoginHistoryQuery(r *http.Request) (*loginHistoryQuery, error) {
+	query := r.URL.Query()
+	q := &loginHistoryQuery{}
+	for param, out := range map[string]*time.Time{"start": &q.start, "end": &q.end} {
+		raw := query.Get(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s time '%s', expected RFC3339", param, raw)
+		}
+		*out = t
+	}
+	if !q.start.IsZero() && !q.end.IsZero() && q.end.Before(q.start) {
+		return nil, errors.New("The end time must not be before the start time")
+	}
+	switch raw := query.Get("success"); raw {
+	case "":
+	case "true", "false":
+		success := raw == "true"
+		q.success = &success
+	default:
+		return nil, fmt.Errorf("Invalid success filter '%s', expected true or false", raw)
+	}
+	return q, nil
+}
+
+// matches returns true if the given attempt matches the query.
+func (q *loginHistoryQuery) matches(attempt *v1.LoginAttempt) bool {
+	if q.user != "" && attempt.User != q.user {
+		return false
+	}
+	if !q.start.IsZero() && attempt.Time < q.start.Unix() {
+		return false
+	}
+	if !q.end.IsZero() && attempt.Time > q.end.Unix() {
+		return false
+	}
+	return q.success == nil || attempt.Success == *q.success
+}
+
+// queryLoginHistory returns the login attempts matching the given query, newest
+// first.
+func (d *desktopAPI) queryLoginHistory(q *loginHistoryQuery) (*v1.LoginHistory, error) {
+	attempts, err := d.readLoginHistory()
+	if err != nil {
+		return nil, err
+	}
+	// attempts are stored in the order they were made
+	matched := make([]*v1.LoginAttempt, 0)
+	for i := len(attempts) - 1; i >= 0; i-- {
+		if q.matches(attempts[i]) {
+			matched = append(matched, attempts[i])
+		}
+	}
+	return &v1.LoginHistory{Attempts: matched}, nil
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// Reasons a login attempt can be rejected by the loginLimiter.
+const (
+	loginLimitedIP      = "ip"
+	loginLimitedUser    = "user"
+	loginLimitedLockout = "lockout"
+)
+
+// attemptWindow counts attempts over a fixed window of time.
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+// add counts an attempt in the window and returns how long until another attempt
+// is allowed if the maximum has been reached.
+func (a *attemptWindow) add(now time.Time, window time.Duration, max int) time.Duration {
+	if now.Sub(a.start) >= window {
+		a.start, a.count = now, 0
+	}
+	if a.count >= max {
+		return a.start.Add(window).Sub(now)
+	}
+	a.count++
+	return 0
+}
+
+// userAttempts tracks the login attempts and failures for a single username.
+type userAttempts struct {
+	attemptWindow
+	// the number of consecutive failed attempts
+	failures int
+	// the number of times the user has been locked out since their last success
+	lockouts int
+	// the time of the last failed attempt
+	lastFailure time.Time
+	// the time the current lockout ends
+	lockedUntil time.Time
+}
+
+// loginLimiter tracks login attempts by client address and username, and locks
+// out usernames after repeated failures. Lockouts back off exponentially until
+// the user logs in successfully.
+type loginLimiter struct {
+	mux       sync.Mutex
+	ips       map[string]*attemptWindow
+	users     map[string]*userAttempts
+	lastPrune time.Time
+}
+
+// newLoginLimiter returns a new loginLimiter.
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{
+		ips:   make(map[string]*attemptWindow),
+		users: make(map[string]*userAttempts),
+	}
+}
+
+// getUser returns the attempts for the given username, creating them if needed.
+// Failures are forgotten once the user has gone longer than the maximum lockout
+// duration without one.
+func (l *loginLimiter) getUser(cfg *v1alpha1.LoginRateLimitConfig, username string, now time.Time) *userAttempts {
+	key := strings.ToLower(username)
+	user, ok := l.users[key]
+	if !ok {
+		user = &userAttempts{}
+		l.users[key] = user
+	}
+	if now.Sub(user.lastFailure) > cfg.GetMaxLockoutDuration() && now.After(user.lockedUntil) {
+		user.failures, user.lockouts = 0, 0
+	}
+	return user
+}
+
+// allow counts a login attempt from the given address for the given username. If
+// the attempt is not allowed, the time until the next one will be and the reason
+// are returned.
+func (l *loginLimiter) allow(cfg *v1alpha1.LoginRateLimitConfig, addr, username string, now time.Time) (time.Duration, string) {
+	if l == nil || cfg.Disabled {
+		return 0, ""
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.prune(cfg, now)
+
+	var user *userAttempts
+	if username != "" {
+		user = l.getUser(cfg, username, now)
+		if now.Before(user.lockedUntil) {
+			return user.lockedUntil.Sub(now), loginLimitedLockout
+		}
+	}
+
+	ip, ok := l.ips[addr]
+	if !ok {
+		ip = &attemptWindow{}
+		l.ips[addr] = ip
+	}
+	if retryAfter := ip.add(now, cfg.GetWindow(), cfg.GetMaxAttemptsPerIP()); retryAfter > 0 {
+		return retryAfter, loginLimitedIP
+	}
+
+	if user != nil {
+		if retryAfter := user.add(now, cfg.GetWindow(), cfg.GetMaxAttemptsPerUser()); retryAfter > 0 {
+			return retryAfter, loginLimitedUser
+		}
+	}
+	return 0, ""
+}
+
+// fail records a failed attempt for the given username. If it locks out the user,
+// the duration of the lockout is returned. Each lockout before a successful login
+// doubles the duration, up to the configured maximum.
+func (l *loginLimiter) fail(cfg *v1alpha1.LoginRateLimitConfig, username string, now time.Time) time.Duration {
+	if l == nil || cfg.Disabled || username == "" {
+		return 0
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	user := l.getUser(cfg, username, now)
+	user.lastFailure = now
+	user.failures++
+	if user.failures < cfg.GetLockoutThreshold() {
+		return 0
+	}
+
+	duration := cfg.GetLockoutDuration()
+	for i := 0; i < user.lockouts && duration < cfg.GetMaxLockoutDuration(); i++ {
+		duration *= 2
+	}
+	if duration > cfg.GetMaxLockoutDuration() {
+		duration = cfg.GetMaxLockoutDuration()
+	}
+	user.failures = 0
+	user.lockouts++
+	user.lockedUntil = now.Add(duration)
+	return duration
+}
+
+// succeed clears the failures and lockouts for the given username.
+func (l *loginLimiter) succeed(username string) {
+	if l == nil {
+		return
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if user, ok := l.users[strings.ToLower(username)]; ok {
+		user.failures, user.lockouts = 0, 0
+	}
+}
+
+// prune removes expired windows and users with no recent failures. It is called
+// with the lock held and runs at most once per window.
+func (l *loginLimiter) prune(cfg *v1alpha1.LoginRateLimitConfig, now time.Time) {
+	window := cfg.GetWindow()
+	if now.Sub(l.lastPrune) < window {
+		return
+	}
+	l.lastPrune = now
+	for addr, ip := range l.ips {
+		if now.Sub(ip.start) >= window {
+			delete(l.ips, addr)
+		}
+	}
+	for username, user := range l.users {
+		if now.Sub(user.start) >= window && now.After(user.lockedUntil) && now.Sub(user.lastFailure) > cfg.GetMaxLockoutDuration() {
+			delete(l.users, username)
+		}
+	}
+}
+
+// getClientAddr returns the address a request came from without the port.
+func getClientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// checkLoginAllowed counts a login attempt for the given username and writes a
+// TooManyRequests response if it is not allowed. False is returned if the request
+// should not continue.
+func (d *desktopAPI) checkLoginAllowed(w http.ResponseWriter, r *http.Request, username string) bool {
+	retryAfter, reason := d.logins.allow(d.vdiCluster.GetLoginRateLimit(), getClientAddr(r), username, time.Now())
+	if retryAfter == 0 {
+		return true
+	}
+	// round up so clients never retry too early
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	loginRateLimitedTotal.WithLabelValues(reason).Inc()
+	setAuditMessage(r, fmt.Sprintf("Login attempt for %q from %s rejected by %s rate limit", username, getClientAddr(r), reason))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	apiutil.ReturnAPITooManyRequests(fmt.Errorf("Too many login attempts, try again in %d seconds", seconds), w)
+	return false
+}
+
+// recordLoginFailure records a failed login attempt for the given username, and
+// logs and audits any lockout it causes.
+func (d *desktopAPI) recordLoginFailure(r *http.Request, username string) {
+	lockout := d.logins.fail(d.vdiCluster.GetLoginRateLimit(), username, time.Now())
+	if lockout == 0 {
+		return
+	}
+	loginLockoutsTotal.Inc()
+	msg := fmt.Sprintf("User %q locked out for %s after repeated failed login attempts", username, lockout)
+	apiLogger.Info(msg, "ClientAddr", getClientAddr(r))
+	setAuditMessage(r, msg)
+	d.alertAdmins("User locked out", fmt.Sprintf("%s. The last attempt came from %s.", msg, getClientAddr(r)))
+}
@@ -71,6 +71,34 @@ var (
 		Name:      "active_audio_streams",
 		Help:      "The current number of active audio streams.",
 	})
+
+	// desktopEphemeralStorageUsedBytes tracks the ephemeral storage used by desktops
+	desktopEphemeralStorageUsedBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "desktop_ephemeral_storage_used_bytes",
+		Help:      "The ephemeral storage used by desktop sessions.",
+	}, []string{"desktop"})
+
+	// desktopEphemeralStorageLimitBytes tracks the ephemeral storage limits of desktops
+	desktopEphemeralStorageLimitBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "desktop_ephemeral_storage_limit_bytes",
+		Help:      "The ephemeral storage limit of desktop sessions.",
+	}, []string{"desktop"})
+
+	// loginRateLimitedTotal tracks login attempts rejected by rate limits and lockouts
+	loginRateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "login_rate_limited_total",
+		Help:      "Total number of login attempts rejected by rate limits, by the limit that was reached.",
+	}, []string{"reason"})
+
+	// loginLockoutsTotal tracks users locked out after repeated failed logins
+	loginLockoutsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "login_lockouts_total",
+		Help:      "Total number of times a user was locked out after repeated failed login attempts.",
+	})
 )
 
 // apiResponseWriter extends the regular http.ResponseWriter and stores the
@@ -111,6 +139,8 @@ func (a *apiResponseWriter) getBytesRcvdCounter() (counter *prometheus.CounterVe
 	return
 }
 
+func (a *apiResponseWriter) Flush() { apiutil.Flush(a.ResponseWriter) }
+
 func (a *apiResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	conn, rw, err := apiutil.NewWebsocketWatcher(nil).
 		WithLabels(map[string]string{"desktop": a.desktopName, "client": a.clientAddr}).
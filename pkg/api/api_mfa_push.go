@@ -0,0 +1,204 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// syncMFAProvider swaps the push MFA provider if the cluster changed it, and sets
+// up the current one.
+func (d *desktopAPI) syncMFAProvider(cluster *v1alpha1.VDICluster) error {
+	if d.vdiCluster == nil || d.vdiCluster.GetMFAProvider() != cluster.GetMFAProvider() {
+		d.mfaProvider = auth.GetMFAProvider(cluster, d.secrets)
+	}
+	if d.mfaProvider == nil {
+		return nil
+	}
+	return d.mfaProvider.Setup(d.client, cluster)
+}
+
+// getMFAMethod returns how users that are not authorized yet must authorize.
+func (d *desktopAPI) getMFAMethod() string {
+	if d.mfaProvider != nil {
+		return v1.MFAMethodPush
+	}
+	return v1.MFAMethodTOTP
+}
+
+// pushTransactionTTL is how long a push transaction can be used to authorize the
+// session it was sent for.
+const pushTransactionTTL = 5 * time.Minute
+
+// pushTransaction is the signed payload handed to the user after sending a push.
+// It is bound to the unauthorized session that sent the push and expires after
+// pushTransactionTTL.
+type pushTransaction struct {
+	// The ID of the push with the provider, empty when the provider allowed the
+	// user without a push
+	ID string `json:"id"`
+	// The ID of the session the push was sent for
+	Session string `json:"session"`
+	// When the transaction expires
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// spendPushTransaction marks the transaction with the given signature as spent in
+// the secrets backend, so it can only be used once across all replicas. False is
+// returned if it was already spent.
+func (d *desktopAPI) spendPushTransaction(sig string, expiresAt int64) (bool, error) {
+	if err := d.secrets.Lock(10); err != nil {
+		return false, err
+	}
+	defer d.secrets.Release()
+	existing, err := d.secrets.ReadSecretMap(v1.SpentPushTransactionsSecretKey, false)
+	if err != nil && !errors.IsSecretNotFoundError(err) {
+		return false, err
+	}
+	// expired transactions no longer need to be tracked
+	now := time.Now().Unix()
+	spent := make(map[string][]byte, len(existing)+1)
+	for s, raw := range existing {
+		if exp, err := strconv.ParseInt(string(raw), 10, 64); err == nil && exp >= now {
+			spent[s] = raw
+		}
+	}
+	if _, ok := spent[sig]; ok {
+		return false, nil
+	}
+	spent[sig] = []byte(strconv.FormatInt(expiresAt, 10))
+	return true, d.secrets.WriteSecretMap(v1.SpentPushTransactionsSecretKey, spent)
+}
+
+// signPushTransaction returns a transaction to hand to the user for the push with
+// the given ID. The transaction is signed with the JWT secret so it can only be
+// used to authorize the session it was sent for.
+func (d *desktopAPI) signPushTransaction(userSession *v1.JWTClaims, txID string) (string, error) {
+	payload, err := json.Marshal(&pushTransaction{
+		ID:        txID,
+		Session:   userSession.Id,
+		ExpiresAt: time.Now().Add(pushTransactionTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := d.pushTransactionSignature(userSession.User.Name, encoded)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%s", encoded, sig), nil
+}
+
+// verifyPushTransaction verifies the given transaction was issued to the given
+// session and has not expired. The transaction and its signature are returned.
+func (d *desktopAPI) verifyPushTransaction(userSession *v1.JWTClaims, transaction string) (*pushTransaction, string, error) {
+	parts := strings.Split(transaction, ".")
+	if len(parts) != 2 {
+		return nil, "", errors.New("Malformed MFA transaction")
+	}
+	sig, err := d.pushTransactionSignature(userSession.User.Name, parts[0])
+	if err != nil {
+		return nil, "", err
+	}
+	if !hmac.Equal([]byte(sig), []byte(parts[1])) {
+		return nil, "", errors.New("MFA transaction was not issued to this user")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, "", errors.New("Malformed MFA transaction")
+	}
+	tx := &pushTransaction{}
+	if err := json.Unmarshal(payload, tx); err != nil {
+		return nil, "", errors.New("Malformed MFA transaction")
+	}
+	if tx.Session != userSession.Id {
+		return nil, "", errors.New("MFA transaction was not issued to this session")
+	}
+	if tx.ExpiresAt < time.Now().Unix() {
+		return nil, "", errors.New("MFA transaction has expired")
+	}
+	return tx, sig, nil
+}
+
+func (d *desktopAPI) pushTransactionSignature(username, payload string) (string, error) {
+	secret, err := d.secrets.ReadSecret(v1.JWTSecretKey, true)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(fmt.Sprintf("%s:%s", username, payload)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// authorizePush polls the push transaction in the request and returns an authorized
+// token once the user approves it. Polls do not count towards the login rate limits.
+func (d *desktopAPI) authorizePush(w http.ResponseWriter, r *http.Request, userSession *v1.JWTClaims, req *v1.AuthorizeRequest) {
+	username := userSession.User.Name
+	if req.GetTransaction() == "" {
+		apiutil.ReturnAPIError(errors.New("This cluster uses push MFA, send a push and provide its transaction"), w)
+		return
+	}
+	tx, sig, err := d.verifyPushTransaction(userSession, req.GetTransaction())
+	if err != nil {
+		apiutil.ReturnAPIForbidden(err, "Invalid MFA transaction", w)
+		return
+	}
+
+	// an empty ID means the provider allowed the user without a push
+	status := v1.MFAPushApproved
+	if tx.ID != "" {
+		if status, err = d.mfaProvider.Status(tx.ID); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	// transactions that have been answered can only be used once
+	if status != v1.MFAPushPending {
+		ok, err := d.spendPushTransaction(sig, tx.ExpiresAt)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		if !ok {
+			apiutil.ReturnAPIForbidden(nil, "MFA transaction has already been used", w)
+			return
+		}
+	}
+
+	switch status {
+	case v1.MFAPushPending:
+		out, err := json.MarshalIndent(&v1.MFAPushResponse{Transaction: req.GetTransaction(), Status: status}, "", "    ")
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		apiutil.WriteOrLogError(out, w, http.StatusAccepted)
+	case v1.MFAPushApproved:
+		apiLogger.Info(fmt.Sprintf("User %s authorized with an MFA push", username))
+		d.logins.succeed(username)
+		d.recordLoginAttempt(r, username, true, v1.LoginMFAPassed)
+		d.publishLoginEvent(userSession.User)
+		d.returnNewJWT(w, &v1.AuthResult{
+			User:                userSession.User,
+			RefreshNotSupported: !userSession.Renewable,
+		}, true, req.GetState())
+	default:
+		d.recordLoginFailure(r, username)
+		d.recordLoginAttempt(r, username, false, v1.LoginMFAFailed)
+		apiutil.ReturnAPIForbidden(nil, "MFA push was denied", w)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// passwordReset is a pending password reset. The tokens sent to users are signed and
+// carry their own expiry, and are also recorded in the secrets backend by ID so they
+// can only be used once, and requesting a new one invalidates the last.
+type passwordReset struct {
+	User      string    `json:"user"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// readPasswordResets returns the pending password resets with expired ones removed.
+// The secrets lock should be held by the caller.
+func (d *desktopAPI) readPasswordResets() (map[string][]byte, error) {
+	resets, err := d.secrets.ReadSecretMap(v1.PasswordResetsSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return make(map[string][]byte), nil
+		}
+		return nil, err
+	}
+	for id, data := range resets {
+		reset := &passwordReset{}
+		if err := json.Unmarshal(data, reset); err != nil || time.Now().After(reset.ExpiresAt) {
+			delete(resets, id)
+		}
+	}
+	return resets, nil
+}
+
+// createPasswordReset returns a signed token authorizing a password reset for the
+// given user, and when it expires. Any previous reset for the user is invalidated.
+func (d *desktopAPI) createPasswordReset(username string) (string, time.Time, error) {
+	secret, err := d.secrets.ReadSecret(v1.JWTSecretKey, true)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claims, token, err := apiutil.GeneratePasswordResetJWT(secret, username, d.vdiCluster.GetPasswordResetTTL())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	reset := &passwordReset{User: username, ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC()}
+	data, err := json.Marshal(reset)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if err := d.secrets.Lock(10); err != nil {
+		return "", time.Time{}, err
+	}
+	defer d.secrets.Release()
+	resets, err := d.readPasswordResets()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	for id, existing := range resets {
+		old := &passwordReset{}
+		if err := json.Unmarshal(existing, old); err == nil && old.User == username {
+			delete(resets, id)
+		}
+	}
+	resets[claims.Id] = data
+	return token, reset.ExpiresAt, d.secrets.WriteSecretMap(v1.PasswordResetsSecretKey, resets)
+}
+
+// claimPasswordReset verifies the given token is valid and has not been used, and
+// spends it so concurrent requests cannot use it too. The user it was issued to is
+// returned along with the reset, so it can be restored if the new password is
+// rejected.
+func (d *desktopAPI) claimPasswordReset(token string) (username, id string, data []byte, err error) {
+	secret, err := d.secrets.ReadSecret(v1.JWTSecretKey, true)
+	if err != nil {
+		return "", "", nil, err
+	}
+	claims, err := apiutil.DecodePasswordResetJWT(secret, token)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if err := d.secrets.Lock(10); err != nil {
+		return "", "", nil, err
+	}
+	defer d.secrets.Release()
+	resets, err := d.readPasswordResets()
+	if err != nil {
+		return "", "", nil, err
+	}
+	reset := &passwordReset{}
+	data, ok := resets[claims.Id]
+	if !ok || json.Unmarshal(data, reset) != nil || reset.User != claims.Subject {
+		return "", "", nil, errors.New("The password reset token is invalid or has expired")
+	}
+	delete(resets, claims.Id)
+	if err := d.secrets.WriteSecretMap(v1.PasswordResetsSecretKey, resets); err != nil {
+		return "", "", nil, err
+	}
+	return reset.User, claims.Id, data, nil
+}
+
+// restorePasswordReset puts back a password reset spent by claimPasswordReset. It
+// is not restored if a new reset was requested for the user in the meantime.
+func (d *desktopAPI) restorePasswordReset(username, id string, data []byte) error {
+	if err := d.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer d.secrets.Release()
+	resets, err := d.readPasswordResets()
+	if err != nil {
+		return err
+	}
+	for _, existing := range resets {
+		reset := &passwordReset{}
+		if err := json.Unmarshal(existing, reset); err == nil && reset.User == username {
+			return nil
+		}
+	}
+	resets[id] = data
+	return d.secrets.WriteSecretMap(v1.PasswordResetsSecretKey, resets)
+}
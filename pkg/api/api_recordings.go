@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/s3util"
+	"github.com/tinyzimmer/kvdi/pkg/util/tlsutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/tracing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// recordingExt is the extension used for recordings in the bucket.
+const recordingExt = ".fbs"
+
+// recordingUploadTimeout is the maximum amount of time to spend uploading the
+// pending recordings from a desktop.
+const recordingUploadTimeout = 30 * time.Minute
+
+// getRecordingsClient returns an S3 client for the configured recordings bucket.
+func (d *desktopAPI) getRecordingsClient(cfg *v1alpha1.RecordingsConfig) (*s3util.Client, error) {
+	creds, err := s3util.GetCredentials(d.client, cfg.CredentialsSecret, d.vdiCluster.GetCoreNamespace())
+	if err != nil {
+		return nil, err
+	}
+	return s3util.NewClient(cfg.Endpoint, cfg.Region, cfg.Bucket, creds), nil
+}
+
+// recordingKey returns the key in the bucket for the given recording.
+func recordingKey(cfg *v1alpha1.RecordingsConfig, namespace, template, id string) string {
+	return path.Join(cfg.Prefix, namespace, template, id+recordingExt)
+}
+
+// parseRecordingKey parses a recording from the given object in the bucket. False
+// is returned if the object is not a recording.
+func parseRecordingKey(cfg *v1alpha1.RecordingsConfig, obj *s3util.Object) (*v1.Recording, bool) {
+	key := strings.TrimPrefix(strings.TrimPrefix(obj.Key, cfg.Prefix), "/")
+	if !strings.HasSuffix(key, recordingExt) {
+		return nil, false
+	}
+	parts := strings.Split(strings.TrimSuffix(key, recordingExt), "/")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	desktop, user, startedAt, err := v1.ParseRecordingID(parts[2])
+	if err != nil {
+		return nil, false
+	}
+	return &v1.Recording{
+		ID:        parts[2],
+		Namespace: parts[0],
+		Template:  parts[1],
+		Desktop:   desktop,
+		User:      user,
+		StartedAt: startedAt,
+		Size:      obj.Size,
+	}, true
+}
+
+// setRecordingOptions sets the query parameter telling the kvdi-proxy to record
+// the display connection when the template of the requested desktop has recording
+// enabled and storage is configured. True is returned if the connection will be
+// recorded.
+func (d *desktopAPI) setRecordingOptions(r *http.Request) (bool, error) {
+	if d.vdiCluster.GetRecordingsConfig() == nil {
+		return false, nil
+	}
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	desktop := &v1alpha1.Desktop{}
+	if err := d.client.Get(context.TODO(), nn, desktop); err != nil {
+		return false, err
+	}
+	tmpl, err := desktop.GetTemplate(d.client)
+	if err != nil {
+		return false, err
+	}
+	if !tmpl.RecordingEnabled() {
+		return false, nil
+	}
+	user := apiutil.GetRequestUserSession(r).User
+	query := r.URL.Query()
+	query.Set(v1.RecordingQueryParam, v1.NewRecordingID(nn.Name, user.GetName(), time.Now()))
+	r.URL.RawQuery = query.Encode()
+	return true, nil
+}
+
+// uploadRecordings copies any finished recordings from the kvdi-proxy of the given
+// desktop to the recordings bucket. Recordings are only removed from the desktop
+// once they are uploaded, so any that fail are retried the next time a display
+// connection ends.
+func (d *desktopAPI) uploadRecordings(nn types.NamespacedName) {
+	cfg := d.vdiCluster.GetRecordingsConfig()
+	if cfg == nil {
+		return
+	}
+	if _, err := d.doUploadRecordings(cfg, nn, nil); err != nil {
+		apiLogger.Error(err, "Failed to upload session recordings", "Desktop.Namespace", nn.Namespace, "Desktop.Name", nn.Name)
+	}
+}
+
+// doUploadRecordings uploads the finished recordings from the given desktop and
+// returns the IDs of the ones that were uploaded. When progress is not nil, it is
+// called after each recording is uploaded.
+func (d *desktopAPI) doUploadRecordings(cfg *v1alpha1.RecordingsConfig, nn types.NamespacedName, progress jobProgressFunc) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordingUploadTimeout)
+	defer cancel()
+
+	desktop := &v1alpha1.Desktop{}
+	if err := d.client.Get(ctx, nn, desktop); err != nil {
+		return nil, err
+	}
+	svc := &corev1.Service{}
+	if err := d.client.Get(ctx, nn, svc); err != nil {
+		return nil, err
+	}
+	s3, err := d.getRecordingsClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	clientTLSConfig, err := tlsutil.NewClientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: clientTLSConfig,
+		},
+	}
+	proxyURL := fmt.Sprintf("https://%s:%d/api/recordings", svc.Spec.ClusterIP, v1.WebPort)
+
+	res, err := doProxyRequest(ctx, httpClient, http.MethodGet, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0)
+	err = json.NewDecoder(res.Body).Decode(&ids)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, _, _, err := v1.ParseRecordingID(id); err != nil {
+			return uploaded, err
+		}
+		res, err := doProxyRequest(ctx, httpClient, http.MethodGet, fmt.Sprintf("%s/%s", proxyURL, id))
+		if err != nil {
+			return uploaded, err
+		}
+		key := recordingKey(cfg, nn.Namespace, desktop.Spec.Template, id)
+		err = s3.PutObjectStream(ctx, key, "application/octet-stream", res.Body, res.ContentLength)
+		res.Body.Close()
+		if err != nil {
+			return uploaded, err
+		}
+		apiLogger.Info("Uploaded session recording", "Desktop.Namespace", nn.Namespace, "Desktop.Name", nn.Name, "Recording.Key", key)
+		res, err = doProxyRequest(ctx, httpClient, http.MethodDelete, fmt.Sprintf("%s/%s", proxyURL, id))
+		if err != nil {
+			return uploaded, err
+		}
+		res.Body.Close()
+		uploaded = append(uploaded, id)
+		if progress != nil {
+			progress(len(uploaded)*100/len(ids), fmt.Sprintf("Uploaded recording %s", id))
+		}
+	}
+	return uploaded, nil
+}
+
+// doProxyRequest performs a request against a kvdi-proxy and returns an error if
+// it is not successful. The caller is responsible for closing the body.
+func doProxyRequest(ctx context.Context, httpClient *http.Client, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	tracing.Inject(ctx, req.Header)
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("%s %s returned status %d", method, url, res.StatusCode)
+	}
+	return res, nil
+}
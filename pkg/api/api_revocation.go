@@ -0,0 +1,171 @@
+package api
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// revocationSyncInterval is how often the revocation list is reloaded from the
+// secrets backend, so that revocations made by other app replicas are honored.
+const revocationSyncInterval = 15 * time.Second
+
+// revocationList tracks access tokens that were revoked before their natural
+// expiry. Single tokens are revoked by their ID, and all the tokens for a user
+// are revoked by recording the time they were logged out everywhere.
+type revocationList struct {
+	secrets *secrets.SecretEngine
+
+	mux sync.RWMutex
+	// token IDs mapped to when they expire
+	tokens map[string]int64
+	// users mapped to when their tokens were revoked, in milliseconds
+	users    map[string]int64
+	syncedAt time.Time
+}
+
+func newRevocationList(s *secrets.SecretEngine) *revocationList {
+	return &revocationList{
+		secrets: s,
+		tokens:  make(map[string]int64),
+		users:   make(map[string]int64),
+	}
+}
+
+// IsRevoked returns true if the token with the given claims has been revoked.
+func (l *revocationList) IsRevoked(claims *v1.JWTClaims) (bool, error) {
+	if err := l.syncIfStale(); err != nil {
+		return false, err
+	}
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	if _, ok := l.tokens[claims.Id]; ok && claims.Id != "" {
+		return true, nil
+	}
+	if revokedAt, ok := l.users[claims.User.GetName()]; ok && claims.GetIssuedAtMillis() < revokedAt {
+		return true, nil
+	}
+	return false, nil
+}
+
+// RevokeToken revokes the token with the given claims. Tokens without an ID were
+// issued before revocation was supported and cannot be revoked individually.
+func (l *revocationList) RevokeToken(claims *v1.JWTClaims) error {
+	if claims.Id == "" {
+		return nil
+	}
+	if err := l.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer l.secrets.Release()
+	tokens, err := readRevocationMap(l.secrets, v1.RevokedTokensSecretKey)
+	if err != nil {
+		return err
+	}
+	// expired tokens no longer need to be tracked
+	now := time.Now().Unix()
+	for id, expiresAt := range tokens {
+		if expiresAt < now {
+			delete(tokens, id)
+		}
+	}
+	tokens[claims.Id] = claims.ExpiresAt
+	if err := writeRevocationMap(l.secrets, v1.RevokedTokensSecretKey, tokens); err != nil {
+		return err
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.tokens = tokens
+	return nil
+}
+
+// RevokeUser revokes all the tokens issued to the given user up until now. The
+// maxAge is the longest lifetime of any token, after which older revocations for
+// other users no longer need to be tracked.
+func (l *revocationList) RevokeUser(username string, maxAge time.Duration) error {
+	if err := l.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer l.secrets.Release()
+	users, err := readRevocationMap(l.secrets, v1.RevokedUsersSecretKey)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for user, revokedAt := range users {
+		if revokedAt < unixMillis(now.Add(-maxAge)) {
+			delete(users, user)
+		}
+	}
+	// tokens issued in the same millisecond as the revocation are revoked as well
+	users[username] = unixMillis(now) + 1
+	if err := writeRevocationMap(l.secrets, v1.RevokedUsersSecretKey, users); err != nil {
+		return err
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.users = users
+	return nil
+}
+
+// syncIfStale reloads the revocation list from the secrets backend if it has
+// not been synced recently.
+func (l *revocationList) syncIfStale() error {
+	l.mux.RLock()
+	stale := time.Since(l.syncedAt) > revocationSyncInterval
+	l.mux.RUnlock()
+	if !stale {
+		return nil
+	}
+	tokens, err := readRevocationMap(l.secrets, v1.RevokedTokensSecretKey)
+	if err != nil {
+		return err
+	}
+	users, err := readRevocationMap(l.secrets, v1.RevokedUsersSecretKey)
+	if err != nil {
+		return err
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.tokens, l.users, l.syncedAt = tokens, users, time.Now()
+	return nil
+}
+
+// unixMillis returns the given time in milliseconds since the epoch.
+func unixMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// readRevocationMap reads a map of unix timestamps from the secrets backend. An
+// empty map is returned if it does not exist yet.
+func readRevocationMap(s *secrets.SecretEngine, key string) (map[string]int64, error) {
+	out := make(map[string]int64)
+	data, err := s.ReadSecretMap(key, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return out, nil
+		}
+		return nil, err
+	}
+	for k, v := range data {
+		ts, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = ts
+	}
+	return out, nil
+}
+
+// writeRevocationMap writes a map of unix timestamps to the secrets backend.
+func writeRevocationMap(s *secrets.SecretEngine, key string, data map[string]int64) error {
+	out := make(map[string][]byte, len(data))
+	for k, v := range data {
+		out[k] = []byte(strconv.FormatInt(v, 10))
+	}
+	return s.WriteSecretMap(key, out)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getRoleForRequest retrieves the VDIRole in the request path. If it does not exist,
+// a not found error is written to the response and nil is returned.
+func (d *desktopAPI) getRoleForRequest(w http.ResponseWriter, r *http.Request) *v1alpha1.VDIRole {
+	name := apiutil.GetRoleFromRequest(r)
+	role := &v1alpha1.VDIRole{}
+	nn := types.NamespacedName{Name: name, Namespace: metav1.NamespaceAll}
+	if err := d.client.Get(context.TODO(), nn, role); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("The role '%s' doesn't exist", name), w)
+			return nil
+		}
+		apiutil.ReturnAPIError(err, w)
+		return nil
+	}
+	return role
+}
+
+// applyRoleGrants updates the roles of the given user with the ones temporarily
+// granted to them. Roles that have since expired are removed first, since the user
+// may come from the claims of a previous token.
+func (d *desktopAPI) applyRoleGrants(user *v1.VDIUser) error {
+	now := time.Now().Unix()
+	roles := make([]*v1.VDIUserRole, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		if role.ExpiresAt == 0 || role.ExpiresAt > now {
+			roles = append(roles, role)
+		}
+	}
+	user.Roles = roles
+
+	vdiRoles, err := d.vdiCluster.GetResolvedRoles(d.client)
+	if err != nil {
+		return err
+	}
+	for _, vdiRole := range vdiRoles {
+		grant, err := vdiRole.GetActiveGrantForUser(user.GetName())
+		if err != nil {
+			return err
+		}
+		if grant == nil {
+			continue
+		}
+		if existing := getUserRole(user, vdiRole.GetName()); existing != nil {
+			// keep whichever binding lasts the longest
+			if existing.ExpiresAt != 0 && existing.ExpiresAt < grant.ExpiresAt {
+				existing.ExpiresAt = grant.ExpiresAt
+			}
+			continue
+		}
+		role := vdiRole.ToUserRole()
+		role.ExpiresAt = grant.ExpiresAt
+		user.Roles = append(user.Roles, role)
+	}
+	return nil
+}
+
+// getUserRole returns the role of the given user with the given name, or nil if they
+// do not have it.
+func getUserRole(user *v1.VDIUser, name string) *v1.VDIUserRole {
+	for _, role := range user.Roles {
+		if role.GetName() == name {
+			return role
+		}
+	}
+	return nil
+}
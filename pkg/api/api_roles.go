@@ -0,0 +1,58 @@
+package api
+
+import (
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+)
+
+// rolesWith returns the roles for this cluster with the given roles added, replacing
+// any existing roles of the same name.
+func (d *desktopAPI) rolesWith(newRoles ...*v1alpha1.VDIRole) ([]v1alpha1.VDIRole, error) {
+	roles, err := d.vdiCluster.GetRoles(d.client)
+	if err != nil {
+		return nil, err
+	}
+	for _, newRole := range newRoles {
+		var replaced bool
+		for idx := range roles {
+			if roles[idx].GetName() == newRole.GetName() {
+				roles[idx] = *newRole
+				replaced = true
+			}
+		}
+		if !replaced {
+			roles = append(roles, *newRole)
+		}
+	}
+	return roles, nil
+}
+
+// validateRoleInheritance checks that the given role only inherits from roles that
+// exist and does not end up inheriting from itself.
+func (d *desktopAPI) validateRoleInheritance(role *v1alpha1.VDIRole) error {
+	roles, err := d.rolesWith(role)
+	if err != nil {
+		return err
+	}
+	return v1alpha1.ValidateRoleInheritance(roles, role.GetName())
+}
+
+// getResolvedRules returns the effective rules each of the given roles would grant, including
+// the ones they inherit, if they were saved.
+func (d *desktopAPI) getResolvedRules(newRoles ...*v1alpha1.VDIRole) ([]v1.Rule, error) {
+	roles, err := d.rolesWith(newRoles...)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{}, len(newRoles))
+	for _, role := range newRoles {
+		names[role.GetName()] = struct{}{}
+	}
+	rules := make([]v1.Rule, 0)
+	for _, role := range v1alpha1.ResolveRoles(roles) {
+		if _, ok := names[role.GetName()]; ok {
+			rules = append(rules, role.GetEffectiveRules()...)
+		}
+	}
+	return rules, nil
+}
@@ -12,7 +12,16 @@ import (
 func (d *desktopAPI) buildRouter() error {
 	r := mux.NewRouter()
 
-	// Run the metrics middleware first
+	// Trace requests first so the spans cover everything else
+	r.Use(d.tracingMiddleware)
+
+	// Then the access log middleware so it sees the final status
+	r.Use(d.accessLogMiddleware)
+
+	// Then the audit log, also so it sees the final status
+	r.Use(d.auditMiddleware)
+
+	// Then the metrics middleware
 	r.Use(prometheusMiddleware)
 
 	// Setup the decoder
@@ -38,49 +47,147 @@ func (d *desktopAPI) buildRouter() error {
 
 	r.PathPrefix("/api/refresh_token").HandlerFunc(d.GetRefreshToken).Methods("GET") // Refresh a user's access token
 
+	// Password resets are not protected since the user cannot log in. The link that
+	// is emailed to the user authorizes the reset.
+	r.HandleFunc("/api/reset-password", d.PostResetPassword).Methods("POST")                // Email a password reset link to a user
+	r.HandleFunc("/api/reset-password/confirm", d.PostResetPasswordConfirm).Methods("POST") // Set a new password with the token from a reset link
+
+	// The public keys for verifying access tokens are not protected so that other
+	// services can verify tokens issued by kVDI.
+	r.HandleFunc("/api/.well-known/jwks.json", d.GetJWKS).Methods("GET")
+
+	// Back-channel logouts are not protected since they are sent by the auth provider.
+	// The logout token in the request is verified instead.
+	r.HandleFunc("/api/logout/backchannel", d.PostBackChannelLogout).Methods("POST")
+
 	// Main HTTP routes
 
 	protected := r.PathPrefix("/api").Subrouter()
 
 	// SUBROUTER ASSUMES /api PREFIX ON ALL ROUTES
 
-	protected.HandleFunc("/authorize", d.PostAuthorize).Methods("POST") // Verify a user's MFA token
+	protected.HandleFunc("/authorize", d.PostAuthorize).Methods("POST")          // Verify a user's MFA token
+	protected.HandleFunc("/authorize/push", d.PostAuthorizePush).Methods("POST") // Send an MFA push to the user
 
 	// Misc routes
-	protected.HandleFunc("/logout", d.PostLogout).Methods("POST")       // Cleans up user's desktops
-	protected.HandleFunc("/whoami", d.GetWhoAmI).Methods("GET")         // Convenience route for decoding JWTs
-	protected.HandleFunc("/config", d.GetConfig).Methods("GET")         // Retrieve server configuration
-	protected.HandleFunc("/namespaces", d.GetNamespaces).Methods("GET") // Retrieve a list of available namespaces for the requesting user
+	protected.HandleFunc("/logout", d.PostLogout).Methods("POST")              // Cleans up user's desktops
+	protected.HandleFunc("/whoami", d.GetWhoAmI).Methods("GET")                // Convenience route for decoding JWTs
+	protected.HandleFunc("/config", d.GetConfig).Methods("GET")                // Retrieve server configuration
+	protected.HandleFunc("/status", d.GetStatus).Methods("GET")                // Retrieve the conditions of the VDICluster and the health of its components
+	protected.HandleFunc("/config/reload", d.PostConfigReload).Methods("POST") // Re-sync server configuration with the VDICluster
+	protected.HandleFunc("/namespaces", d.GetNamespaces).Methods("GET")        // Retrieve a list of available namespaces for the requesting user
+	protected.HandleFunc("/gc", d.GetGCReport).Methods("GET")                  // Retrieve the results of the last orphaned resource scan
+	protected.HandleFunc("/reports/usage", d.GetUsageReport).Methods("GET")    // Retrieve desktop usage aggregated over a range of days
+	protected.HandleFunc("/reports/logins", d.GetLoginReport).Methods("GET")   // Retrieve the login attempts for all users
+
+	// Job operations
+	protected.HandleFunc("/jobs", d.GetJobs).Methods("GET")      // Retrieve the requesting user's long-running operations
+	protected.HandleFunc("/jobs/{job}", d.GetJob).Methods("GET") // Retrieve the progress and result of a long-running operation
 
 	// User operations
-	protected.HandleFunc("/users", d.GetUsers).Methods("GET")                           // Retrieve a list of all users
-	protected.HandleFunc("/users", d.PostUsers).Methods("POST")                         // Create a new user
-	protected.HandleFunc("/users/{user}", d.GetUser).Methods("GET")                     // Retrieve information for a single user
-	protected.HandleFunc("/users/{user}", d.PutUser).Methods("PUT")                     // Update a user
-	protected.HandleFunc("/users/{user}/mfa", d.GetUserMFA).Methods("GET")              // Retrieve MFA status for a user
-	protected.HandleFunc("/users/{user}/mfa", d.PutUserMFA).Methods("PUT")              // Update MFA status for a user
-	protected.HandleFunc("/users/{user}/mfa/verify", d.PutUserMFAVerify).Methods("PUT") // Verify that a user has succesfully configured MFA
-	protected.HandleFunc("/users/{user}", d.DeleteUser).Methods("DELETE")               // Delete a user
+	protected.HandleFunc("/users", d.GetUsers).Methods("GET")                                    // Retrieve a list of all users
+	protected.HandleFunc("/users", d.PostUsers).Methods("POST")                                  // Create a new user
+	protected.HandleFunc("/users/import", d.PostImportUsers).Methods("POST")                     // Create users in bulk from CSV or JSON
+	protected.HandleFunc("/users/export", d.GetExportUsers).Methods("GET")                       // Export all users and their roles as CSV or JSON
+	protected.HandleFunc("/users/{user}", d.GetUser).Methods("GET")                              // Retrieve information for a single user
+	protected.HandleFunc("/users/{user}", d.PutUser).Methods("PUT")                              // Update a user
+	protected.HandleFunc("/users/{user}/password", d.PutUserPassword).Methods("PUT")             // Change the password for the requesting user
+	protected.HandleFunc("/users/{user}/mfa", d.GetUserMFA).Methods("GET")                       // Retrieve MFA status for a user
+	protected.HandleFunc("/users/{user}/mfa", d.PutUserMFA).Methods("PUT")                       // Update MFA status for a user
+	protected.HandleFunc("/users/{user}/mfa/verify", d.PutUserMFAVerify).Methods("PUT")          // Verify that a user has succesfully configured MFA
+	protected.HandleFunc("/users/{user}/userdata", d.GetUserData).Methods("GET")                 // Retrieve the first-boot script for a user's desktops
+	protected.HandleFunc("/users/{user}/userdata", d.PutUserData).Methods("PUT")                 // Set the first-boot script for a user's desktops
+	protected.HandleFunc("/users/{user}/dotfiles", d.GetUserDotfiles).Methods("GET")             // Retrieve the dotfiles repository for a user's desktops
+	protected.HandleFunc("/users/{user}/dotfiles", d.PutUserDotfiles).Methods("PUT")             // Set the dotfiles repository for a user's desktops
+	protected.HandleFunc("/users/{user}/secrets", d.GetUserSecrets).Methods("GET")               // Retrieve the names of the secrets configured for a user
+	protected.HandleFunc("/users/{user}/secrets/{secret}", d.PutUserSecret).Methods("PUT")       // Set a secret for a user
+	protected.HandleFunc("/users/{user}/secrets/{secret}", d.DeleteUserSecret).Methods("DELETE") // Delete a secret for a user
+	protected.HandleFunc("/users/{user}/email", d.GetUserEmail).Methods("GET")                   // Retrieve the address notifications are sent to for a user
+	protected.HandleFunc("/users/{user}/email", d.PutUserEmail).Methods("PUT")                   // Set the address notifications are sent to for a user
+	protected.HandleFunc("/users/{user}/keyboard", d.GetUserKeyboard).Methods("GET")             // Retrieve the keyboard layout for a user's desktop sessions
+	protected.HandleFunc("/users/{user}/keyboard", d.PutUserKeyboard).Methods("PUT")             // Set the keyboard layout for a user's desktop sessions
+	protected.HandleFunc("/users/{user}/logins", d.GetUserLogins).Methods("GET")                 // Retrieve the login attempts for a user
+	protected.HandleFunc("/users/{user}", d.DeleteUser).Methods("DELETE")                        // Delete a user
+
+	// Impersonation operations
+	protected.HandleFunc("/impersonate/{user}", d.PostImpersonate).Methods("POST") // Retrieve a short-lived token for acting as another user
+
+	// Userdata snapshot operations
+	protected.HandleFunc("/users/{user}/snapshots", d.GetUserSnapshots).Methods("GET")                         // Retrieve the snapshots of a user's userdata volumes
+	protected.HandleFunc("/users/{user}/snapshots/{namespace}/{name}", d.DeleteUserSnapshot).Methods("DELETE") // Delete a snapshot of a user's userdata volume
+
+	// API key operations
+	protected.HandleFunc("/apikeys", d.GetAPIKeys).Methods("GET")               // Retrieve the requesting user's API keys
+	protected.HandleFunc("/apikeys", d.PostAPIKeys).Methods("POST")             // Create a new API key for the requesting user
+	protected.HandleFunc("/apikeys/{apikey}", d.GetAPIKey).Methods("GET")       // Retrieve information for a single API key
+	protected.HandleFunc("/apikeys/{apikey}", d.DeleteAPIKey).Methods("DELETE") // Revoke an API key
 
 	// Role operations
-	protected.HandleFunc("/roles", d.GetRoles).Methods("GET")             // Retrieve a list of all VDIRoles
-	protected.HandleFunc("/roles", d.CreateRole).Methods("POST")          // Create a new VDIRole
-	protected.HandleFunc("/roles/{role}", d.GetRole).Methods("GET")       // Retrieve information for a single VDIRole
-	protected.HandleFunc("/roles/{role}", d.UpdateRole).Methods("PUT")    // Update a VDIRole
-	protected.HandleFunc("/roles/{role}", d.DeleteRole).Methods("DELETE") // Delete a VDIRole
+	protected.HandleFunc("/roles", d.GetRoles).Methods("GET")                                // Retrieve a list of all VDIRoles
+	protected.HandleFunc("/roles", d.CreateRole).Methods("POST")                             // Create a new VDIRole
+	protected.HandleFunc("/roles/evaluate", d.PostRolesEvaluate).Methods("POST")             // Evaluate whether a user or role is allowed an action
+	protected.HandleFunc("/roles/{role}", d.GetRole).Methods("GET")                          // Retrieve information for a single VDIRole
+	protected.HandleFunc("/roles/{role}", d.UpdateRole).Methods("PUT")                       // Update a VDIRole
+	protected.HandleFunc("/roles/{role}", d.DeleteRole).Methods("DELETE")                    // Delete a VDIRole
+	protected.HandleFunc("/roles/{role}/grants", d.GetRoleGrants).Methods("GET")             // Retrieve the users a VDIRole is temporarily granted to
+	protected.HandleFunc("/roles/{role}/grants", d.PostRoleGrant).Methods("POST")            // Temporarily grant a VDIRole to a user
+	protected.HandleFunc("/roles/{role}/grants/{user}", d.DeleteRoleGrant).Methods("DELETE") // Revoke a temporary grant of a VDIRole
+
+	// Group operations
+	protected.HandleFunc("/groups", d.GetGroups).Methods("GET")              // Retrieve a list of all VDIGroups
+	protected.HandleFunc("/groups", d.CreateGroup).Methods("POST")           // Create a new VDIGroup
+	protected.HandleFunc("/groups/{group}", d.GetGroup).Methods("GET")       // Retrieve information for a single VDIGroup
+	protected.HandleFunc("/groups/{group}", d.UpdateGroup).Methods("PUT")    // Update a VDIGroup
+	protected.HandleFunc("/groups/{group}", d.DeleteGroup).Methods("DELETE") // Delete a VDIGroup
+
+	// Announcement operations
+	protected.HandleFunc("/announcements", d.GetAnnouncements).Methods("GET")                     // Retrieve the announcements shown to the user
+	protected.HandleFunc("/announcements", d.CreateAnnouncement).Methods("POST")                  // Create a new VDIAnnouncement
+	protected.HandleFunc("/announcements/{announcement}", d.GetAnnouncement).Methods("GET")       // Retrieve a single VDIAnnouncement
+	protected.HandleFunc("/announcements/{announcement}", d.UpdateAnnouncement).Methods("PUT")    // Update a VDIAnnouncement
+	protected.HandleFunc("/announcements/{announcement}", d.DeleteAnnouncement).Methods("DELETE") // Delete a VDIAnnouncement
 
 	// Template operations
 	protected.HandleFunc("/templates", d.GetDesktopTemplates).Methods("GET")                 // Retrieve a list of all available DesktopTemplates
 	protected.HandleFunc("/templates", d.PostDesktopTemplates).Methods("POST")               // Create a new DesktopTemplate
+	protected.HandleFunc("/templates/export", d.PostExportTemplates).Methods("POST")         // Export DesktopTemplates as a signed bundle
+	protected.HandleFunc("/templates/import", d.PostImportTemplates).Methods("POST")         // Import a signed bundle of DesktopTemplates
 	protected.HandleFunc("/templates/{template}", d.GetDesktopTemplate).Methods("GET")       // Retrieve information for a single DesktopTemplate
 	protected.HandleFunc("/templates/{template}", d.PutDesktopTemplate).Methods("PUT")       // Update a DesktopTemplate
 	protected.HandleFunc("/templates/{template}", d.DeleteDesktopTemplate).Methods("DELETE") // Delete a DesktopTemplate
+	// // Template revisions
+	protected.HandleFunc("/templates/{template}/revisions", d.GetDesktopTemplateRevisions).Methods("GET") // Retrieve the recorded revisions of a DesktopTemplate
+	protected.HandleFunc("/templates/{template}/rollback", d.PostDesktopTemplateRollback).Methods("POST") // Roll back a DesktopTemplate to a previous revision
+	protected.HandleFunc("/templates/{template}/estimate", d.PostDesktopTemplateEstimate).Methods("POST") // Estimate the resources and cost of a session from a DesktopTemplate
+
+	// Session recording operations
+	protected.HandleFunc("/recordings", d.GetRecordings).Methods("GET")                                   // Retrieve a list of session recordings
+	protected.HandleFunc("/recordings/{namespace}/{template}/{recording}", d.GetRecording).Methods("GET") // Download a session recording
+
+	// Event stream
+	protected.Path("/events").Handler(&websocket.Server{ // Follow session, login, and role events
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler:   d.GetEventsWebsocket,
+	})
 
 	// Desktop session operations
-	protected.HandleFunc("/sessions", d.GetDesktopSessions).Methods("GET")                         // Retrieve status information for all desktop sessions
-	protected.HandleFunc("/sessions", d.StartDesktopSession).Methods("POST")                       // Start a new desktop session
-	protected.HandleFunc("/sessions/{namespace}/{name}", d.GetDesktopSessionStatus).Methods("GET") // Get the status of a desktop session
-	protected.HandleFunc("/sessions/{namespace}/{name}", d.DeleteDesktopSession).Methods("DELETE") // Stop a desktop session
+	protected.HandleFunc("/sessions", d.GetDesktopSessions).Methods("GET")                                         // Retrieve status information for all desktop sessions
+	protected.HandleFunc("/sessions", d.StartDesktopSession).Methods("POST")                                       // Start a new desktop session
+	protected.HandleFunc("/sessions/{user}", d.DeleteUserSessions).Methods("DELETE")                               // Log out a user everywhere
+	protected.HandleFunc("/sessions/{namespace}/{name}", d.GetDesktopSessionStatus).Methods("GET")                 // Get the status of a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}", d.DeleteDesktopSession).Methods("DELETE")                 // Stop a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/logs", d.GetSessionLogs).Methods("GET")                     // Stream the logs of the containers in a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/screenshot", d.PostSessionScreenshot).Methods("POST")       // Capture the display of a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/invites", d.GetSessionInvites).Methods("GET")               // Retrieve the active invites for a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/invites", d.PostSessionInvite).Methods("POST")              // Invite another user to attach to a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/invites/{invite}", d.DeleteSessionInvite).Methods("DELETE") // Revoke an invite to a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/snapshots", d.PostSessionSnapshot).Methods("POST")          // Snapshot the userdata volume of a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/handoff", d.PostSessionHandoff).Methods("POST")             // Create a one-time code for picking up a desktop display on another device
+	protected.HandleFunc("/sessions/{namespace}/{name}/recordings", d.PostSessionRecordings).Methods("POST")       // Upload the finished recordings of a desktop session
+	// // Read-only view tokens
+	protected.HandleFunc("/sessions/{namespace}/{name}/viewtokens", d.GetSessionViewTokens).Methods("GET")                  // Retrieve the active view tokens for a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/viewtokens", d.PostSessionViewToken).Methods("POST")                 // Create a read-only view token for a desktop session
+	protected.HandleFunc("/sessions/{namespace}/{name}/viewtokens/{viewtoken}", d.DeleteSessionViewToken).Methods("DELETE") // Revoke a view token for a desktop session
 
 	// Methods for interacting with the kvdi-proxy
 	// // Plain HTTP routes
@@ -94,13 +201,25 @@ func (d *desktopAPI) buildRouter() error {
 		Handshake: func(*websocket.Config, *http.Request) error { return nil },
 		Handler:   d.GetDesktopLogsWebsocket,
 	})
-	protected.HandleFunc("/desktops/ws/{namespace}/{name}/display", d.GetWebsockify)    // Connect to the VNC socket on a desktop over websockets
-	protected.HandleFunc("/desktops/ws/{namespace}/{name}/audio", d.GetWebsockifyAudio) // Connect to the audio stream of a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/display", d.GetWebsockify)                // Connect to the VNC socket on a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/view", d.GetWebsockifyView)               // Connect to the VNC socket on a desktop over websockets with input disabled
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/shadow", d.GetWebsockifyShadow)           // Attach to another user's desktop display over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/invites/{invite}", d.GetWebsockifyInvite) // Attach to a desktop display the user was invited to over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/viewtoken", d.GetWebsockifyViewToken)     // Watch a desktop display with a view token over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/settings", d.GetWebsockifySettings)       // Change the quality, frame rate, and bandwidth of the display of a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/audio", d.GetWebsockifyAudio)             // Connect to the audio stream of a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/smartcard", d.GetWebsockifySmartCard)     // Redirect a smart card into a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/usb", d.GetWebsockifyUSB)                 // Redirect a USB device into a desktop over websockets
+	protected.HandleFunc("/desktops/ws/{namespace}/{name}/printer", d.GetWebsockifyPrinter)         // Receive documents printed in a desktop over websockets
 	// // Filesystem access
 	protected.PathPrefix("/desktops/fs/{namespace}/{name}/stat/").HandlerFunc(d.GetStatDesktopFile).Methods("GET")    // Retrieve file info or a directory listing from a desktop
 	protected.PathPrefix("/desktops/fs/{namespace}/{name}/get/").HandlerFunc(d.GetDownloadDesktopFile).Methods("GET") // Retrieve the contents of a file from a desktop
 	protected.HandleFunc("/desktops/fs/{namespace}/{name}/put", d.PutDesktopFile).Methods("PUT")                      // Uploads a file to a desktop
 
+	// Cluster maintenance operations
+	protected.HandleFunc("/admin/nodes/{node}/drain", d.GetNodeDrain).Methods("GET")   // Retrieve the progress of draining desktops from a node
+	protected.HandleFunc("/admin/nodes/{node}/drain", d.PostNodeDrain).Methods("POST") // Migrate or terminate the desktops running on a node
+
 	// Validate the user session on all requests
 	protected.Use(d.ValidateUserSession)
 	// check the grants for the request user
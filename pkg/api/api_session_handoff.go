@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// sessionHandoffTTL is how long a handoff code may be redeemed for after it is
+// created.
+var sessionHandoffTTL = 2 * time.Minute
+
+// handoffCodeChars are the characters used in handoff codes. Characters that are
+// easily confused with each other are left out, since the code is usually typed
+// in on the other device.
+const handoffCodeChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newHandoffCode returns a random handoff code in the form of XXXX-XXXX.
+func newHandoffCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var code strings.Builder
+	for i, b := range buf {
+		if i == len(buf)/2 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(handoffCodeChars[int(b)%len(handoffCodeChars)])
+	}
+	return code.String(), nil
+}
+
+// hashHandoffCode returns the hex encoded sha256 of a handoff code. Codes are
+// normalized first so they are accepted regardless of case or separators.
+func hashHandoffCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// getSessionHandoff returns the pending handoff on the given desktop, or nil if
+// there is none or it has expired.
+func getSessionHandoff(desktop *v1alpha1.Desktop) (*v1.SessionHandoff, error) {
+	raw, ok := desktop.GetAnnotations()[v1.SessionHandoffAnnotation]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	handoff := &v1.SessionHandoff{}
+	if err := json.Unmarshal([]byte(raw), handoff); err != nil {
+		return nil, err
+	}
+	if handoff.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	return handoff, nil
+}
+
+// setSessionHandoff writes the given handoff to the desktop, replacing any that
+// is pending. A nil handoff removes it.
+func (d *desktopAPI) setSessionHandoff(desktop *v1alpha1.Desktop, handoff *v1.SessionHandoff) error {
+	annotations := desktop.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if handoff == nil {
+		delete(annotations, v1.SessionHandoffAnnotation)
+	} else {
+		out, err := json.Marshal(handoff)
+		if err != nil {
+			return err
+		}
+		annotations[v1.SessionHandoffAnnotation] = string(out)
+	}
+	desktop.SetAnnotations(annotations)
+	return d.client.Update(context.TODO(), desktop)
+}
+
+// redeemSessionHandoff checks the handoff code in the request against the pending
+// handoff on the desktop in the request path. If it matches and was created by the
+// requesting user, the handoff is removed so the code cannot be used again, and
+// true is returned.
+func (d *desktopAPI) redeemSessionHandoff(r *http.Request) (bool, error) {
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		return false, err
+	}
+	handoff, err := getSessionHandoff(desktop)
+	if err != nil || handoff == nil {
+		return false, err
+	}
+	if handoff.User != apiutil.GetRequestUserSession(r).User.GetName() {
+		return false, nil
+	}
+	hash := hashHandoffCode(r.URL.Query().Get(v1.HandoffQueryParam))
+	if subtle.ConstantTimeCompare([]byte(handoff.CodeHash), []byte(hash)) != 1 {
+		return false, nil
+	}
+	if err := d.setSessionHandoff(desktop, nil); err != nil {
+		// the handoff was redeemed or replaced by another request in the meantime
+		if kerrors.IsConflict(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
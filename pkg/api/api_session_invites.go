@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// getSessionInvites returns the invites on the given desktop that have not expired.
+func getSessionInvites(desktop *v1alpha1.Desktop) ([]*v1.SessionInvite, error) {
+	invites := make([]*v1.SessionInvite, 0)
+	raw, ok := desktop.GetAnnotations()[v1.SessionInvitesAnnotation]
+	if !ok || raw == "" {
+		return invites, nil
+	}
+	all := make([]*v1.SessionInvite, 0)
+	if err := json.Unmarshal([]byte(raw), &all); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	for _, invite := range all {
+		if invite.ExpiresAt > now {
+			invites = append(invites, invite)
+		}
+	}
+	return invites, nil
+}
+
+// setSessionInvites writes the given invites to the desktop.
+func (d *desktopAPI) setSessionInvites(desktop *v1alpha1.Desktop, invites []*v1.SessionInvite) error {
+	annotations := desktop.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if len(invites) == 0 {
+		delete(annotations, v1.SessionInvitesAnnotation)
+	} else {
+		out, err := json.Marshal(invites)
+		if err != nil {
+			return err
+		}
+		annotations[v1.SessionInvitesAnnotation] = string(out)
+	}
+	desktop.SetAnnotations(annotations)
+	return d.client.Update(context.TODO(), desktop)
+}
+
+// getSessionInviteForRequest returns the invite in the request path if it has not
+// expired and was issued to the requesting user. Nil is returned otherwise.
+func (d *desktopAPI) getSessionInviteForRequest(r *http.Request) (*v1.SessionInvite, error) {
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	invites, err := getSessionInvites(desktop)
+	if err != nil {
+		return nil, err
+	}
+	id := apiutil.GetInviteFromRequest(r)
+	user := apiutil.GetRequestUserSession(r).User
+	for _, invite := range invites {
+		if invite.ID == id && invite.User == user.GetName() {
+			return invite, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// claimPooledDesktop claims a running desktop from a SessionPool for the given request
+// and user. Nil is returned if none are available. Pooled desktops were booted as the
+// anonymous user, so they are not handed to users that configured their own first-boot
+// script or dotfiles repository.
+func (d *desktopAPI) claimPooledDesktop(req *v1.CreateSessionRequest, user *v1.VDIUser, dotfiles *v1.DotfilesConfig) (*v1alpha1.Desktop, error) {
+	username := user.GetName()
+	if d.vdiCluster.GetUserdataVolumeSpec() != nil || dotfiles.Repository != "" {
+		return nil, nil
+	}
+	hasUserData, err := d.userHasUserData(username)
+	if err != nil || hasUserData {
+		return nil, err
+	}
+
+	desktops := &v1alpha1.DesktopList{}
+	if err := d.client.List(context.TODO(), desktops, client.InNamespace(req.GetNamespace()), d.vdiCluster.GetClusterDesktopsSelector()); err != nil {
+		return nil, err
+	}
+
+	for _, desktop := range desktops.Items {
+		if !desktop.IsWarm() || !desktop.Status.Running || desktop.GetDeletionTimestamp() != nil || desktop.Spec.Template != req.GetTemplate() {
+			continue
+		}
+		nn := types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}
+
+		// reset the idle timer before the desktop is handed over
+		d.recordDesktopActivity(nn)
+
+		claimed := &v1alpha1.Desktop{}
+		if err := d.client.Get(context.TODO(), nn, claimed); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return nil, err
+		}
+		if !claimed.IsWarm() {
+			continue
+		}
+		claimed.Spec.User = username
+		claimed.SetLabels(d.vdiCluster.GetUserDesktopLabels(username))
+		annotations := claimed.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[v1.ClaimedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		annotations[v1.UserRolesAnnotation] = strings.Join(user.GetRoleNames(), v1.AuthGroupSeparator)
+		claimed.SetAnnotations(annotations)
+		claimed.SetOwnerReferences(nil)
+		if err := d.client.Update(context.TODO(), claimed); err != nil {
+			// another request claimed the desktop first
+			if kerrors.IsConflict(err) || kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		apiLogger.Info("Claimed desktop from session pool", "Desktop.Name", claimed.GetName(), "SessionPool.Name", claimed.Spec.SessionPool, "User.Name", username)
+		return claimed, nil
+	}
+
+	return nil, nil
+}
+
+// userHasUserData returns true if the given user has configured their own
+// first-boot script.
+func (d *desktopAPI) userHasUserData(username string) (bool, error) {
+	users, err := d.secrets.ReadSecretMap(v1.UserDataSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(users[username]) > 0, nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/lock"
+)
+
+// invalidLockNameChars matches characters that can't be used in the name of a lock.
+var invalidLockNameChars = regexp.MustCompile(`[^a-z0-9.-]`)
+
+// hasSessionQuotas returns true if any session quotas apply to the given user.
+func hasSessionQuotas(user *v1.VDIUser) bool {
+	return user.GetMaxSessions() > 0 || user.GetMaxSessionsPerTemplate() > 0 || len(user.GetSessionResourceLimits()) > 0
+}
+
+// newSessionQuotaLock returns a lock to hold while checking the session quotas of
+// the given user, so concurrent requests can't exceed them.
+func (d *desktopAPI) newSessionQuotaLock(username string) *lock.Lock {
+	name := invalidLockNameChars.ReplaceAllString(strings.ToLower(username), "-")
+	return lock.New(d.client, fmt.Sprintf("session-quota-%s", name), time.Second*10)
+}
+
+// checkSessionQuotas returns a QuotaExceededError if starting a new session from
+// the given template would put the user over either of their session quotas.
+// Desktops that are being deleted do not count against the quotas.
+func (d *desktopAPI) checkSessionQuotas(user *v1.VDIUser, template string) error {
+	desktops := &v1alpha1.DesktopList{}
+	if err := d.client.List(context.TODO(), desktops, d.vdiCluster.GetUserDesktopsSelector(user.GetName())); err != nil {
+		return err
+	}
+	var running, runningTemplate int
+	for _, desktop := range desktops.Items {
+		if desktop.GetDeletionTimestamp() != nil {
+			continue
+		}
+		running++
+		if desktop.Spec.Template == template {
+			runningTemplate++
+		}
+	}
+	if max := int(user.GetMaxSessions()); max > 0 && running >= max {
+		return errors.NewQuotaExceededError(running, max, "sessions")
+	}
+	if max := int(user.GetMaxSessionsPerTemplate()); max > 0 && runningTemplate >= max {
+		return errors.NewQuotaExceededError(runningTemplate, max, fmt.Sprintf("sessions for template %s", template))
+	}
+	return nil
+}
+
+// checkSessionResources returns a QuotaExceededError if starting a new session from
+// the given template and parameters in the given namespace would put the user over
+// the CPU or memory quotas of their roles.
+func (d *desktopAPI) checkSessionResources(user *v1.VDIUser, tmpl *v1alpha1.DesktopTemplate, params map[string]string, namespace string) error {
+	if len(user.GetSessionResourceLimits()) == 0 {
+		return nil
+	}
+	// parameters can change the resources the session is launched with
+	tmpl, err := tmpl.ApplyParameters(params)
+	if err != nil {
+		return err
+	}
+	usage, err := d.vdiCluster.GetUserSessionResourceUsage(d.client, user.GetName(), nil)
+	if err != nil {
+		return err
+	}
+	for name, quantity := range d.vdiCluster.GetSessionResourceUsage(tmpl, namespace) {
+		sum := usage[name]
+		sum.Add(quantity)
+		usage[name] = sum
+	}
+	if name := user.GetExceededSessionResource(usage); name != "" {
+		used, limit := usage[name], user.GetSessionResourceLimits()[name]
+		return errors.NewResourceQuotaExceededError(used.String(), limit.String(), string(name))
+	}
+	return nil
+}
+
+// getSessionNodeSelector returns the node labels required by the placement policies
+// of the user's roles. An error is returned if they conflict with each other, or
+// with the node selector of the given template.
+func getSessionNodeSelector(user *v1.VDIUser, tmpl *v1alpha1.DesktopTemplate) (map[string]string, error) {
+	selector, err := user.GetSessionNodeSelector()
+	if err != nil {
+		return nil, err
+	}
+	for key, val := range tmpl.GetDesktopNodeSelector() {
+		if required, ok := selector[key]; ok && required != val {
+			return nil, fmt.Errorf("Template %s requires node label %s=%s, but your roles require %s", tmpl.GetName(), key, val, required)
+		}
+	}
+	return selector, nil
+}
+
+// returnSessionQuotaError writes an error from checking session quotas to the
+// response. Exceeded quotas are returned with a TooManyRequests status.
+func returnSessionQuotaError(err error, w http.ResponseWriter) {
+	if errors.IsQuotaExceededError(err) {
+		apiutil.ReturnAPITooManyRequests(err, w)
+		return
+	}
+	apiutil.ReturnAPIError(err, w)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errSnapshotsNotConfigured is returned when snapshots are requested but no
+// VolumeSnapshotClass is configured.
+var errSnapshotsNotConfigured = errors.New("Session snapshots are not configured for this cluster")
+
+// snapshotReadyTimeout is the maximum amount of time a snapshot job waits for the
+// snapshot to become ready to use.
+const snapshotReadyTimeout = 30 * time.Minute
+
+// snapshotPollInterval is how often a snapshot job checks if the snapshot is ready.
+const snapshotPollInterval = 5 * time.Second
+
+// newDesktopSnapshot converts the given VolumeSnapshot to its API representation.
+func newDesktopSnapshot(snapshot *unstructured.Unstructured) *v1.DesktopSnapshot {
+	return &v1.DesktopSnapshot{
+		Name:        snapshot.GetName(),
+		Namespace:   snapshot.GetNamespace(),
+		User:        snapshot.GetLabels()[v1.UserLabel],
+		Template:    snapshot.GetAnnotations()[v1.SnapshotTemplateAnnotation],
+		Desktop:     snapshot.GetLabels()[v1.DesktopNameLabel],
+		CreatedAt:   snapshot.GetCreationTimestamp().Unix(),
+		ReadyToUse:  k8sutil.VolumeSnapshotReady(snapshot),
+		RestoreSize: k8sutil.VolumeSnapshotRestoreSize(snapshot),
+	}
+}
+
+// getUserSnapshot returns the VolumeSnapshot with the given name and namespace if
+// it was taken of the given user's userdata volume in this cluster. Nil is returned
+// if it does not exist or belongs to someone else.
+func (d *desktopAPI) getUserSnapshot(username, namespace, name string) (*unstructured.Unstructured, error) {
+	snapshot := k8sutil.NewVolumeSnapshotObject()
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, snapshot); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	for key, val := range d.vdiCluster.GetUserDesktopLabels(username) {
+		if snapshot.GetLabels()[key] != val {
+			return nil, nil
+		}
+	}
+	return snapshot, nil
+}
+
+// checkSnapshotRestore checks that a new session for the given user can be restored
+// from the given snapshot. The snapshot must be ready to use, and the user can't
+// have another session in its namespace using their userdata volume.
+func (d *desktopAPI) checkSnapshotRestore(username string, snapshot *unstructured.Unstructured) error {
+	if !k8sutil.VolumeSnapshotReady(snapshot) {
+		return fmt.Errorf("The snapshot %s is not ready to use yet", snapshot.GetName())
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := d.client.Get(context.TODO(), types.NamespacedName{Name: d.vdiCluster.GetUserdataVolumeName(username), Namespace: snapshot.GetNamespace()}, pvc)
+	if err == nil {
+		return fmt.Errorf("The userdata volume for %s is in use by another session in %s", username, snapshot.GetNamespace())
+	}
+	return client.IgnoreNotFound(err)
+}
+
+// waitForSnapshotReady polls the given VolumeSnapshot until it is ready to use and
+// returns it. An error is returned if the storage provider reports one, or the
+// snapshot is not ready before the timeout.
+func (d *desktopAPI) waitForSnapshotReady(nn types.NamespacedName) (*unstructured.Unstructured, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotReadyTimeout)
+	defer cancel()
+	ticker := time.NewTicker(snapshotPollInterval)
+	defer ticker.Stop()
+	for {
+		snapshot := k8sutil.NewVolumeSnapshotObject()
+		if err := d.client.Get(ctx, nn, snapshot); err != nil {
+			return nil, err
+		}
+		if k8sutil.VolumeSnapshotReady(snapshot) {
+			return snapshot, nil
+		}
+		if msg := k8sutil.VolumeSnapshotError(snapshot); msg != "" {
+			return snapshot, fmt.Errorf("Failed to take snapshot %s: %s", nn.String(), msg)
+		}
+		select {
+		case <-ctx.Done():
+			return snapshot, fmt.Errorf("Timed out waiting for snapshot %s to be ready to use", nn.String())
+		case <-ticker.C:
+		}
+	}
+}
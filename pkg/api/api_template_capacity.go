@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// templateQueueTTL is how long a user keeps their place in the queue for a template
+// without asking for a session again.
+const templateQueueTTL = 2 * time.Minute
+
+// templateQueueEntry is a user waiting for a session from a template that has
+// reached its capacity.
+type templateQueueEntry struct {
+	User     string    `json:"user"`
+	JoinedAt time.Time `json:"joinedAt"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// checkTemplateCapacity returns a QuotaExceededError if the given template has no
+// room for another session for the user. Users that are turned away are queued in
+// the order they first asked, and as sessions end the users at the front of the
+// queue are let in first. Users keep their place by retrying within the
+// templateQueueTTL. The capacity lock for the template should be held by the caller.
+func (d *desktopAPI) checkTemplateCapacity(username string, tmpl *v1alpha1.DesktopTemplate) error {
+	running, err := d.countTemplateSessions(tmpl.GetName())
+	if err != nil {
+		return err
+	}
+	max := int(tmpl.GetMaxSessions())
+
+	if err := d.secrets.Lock(10); err != nil {
+		return err
+	}
+	defer d.secrets.Release()
+	queues, err := d.readTemplateQueues()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	queue := make([]*templateQueueEntry, 0)
+	if data, ok := queues[tmpl.GetName()]; ok {
+		var entries []*templateQueueEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if now.Sub(entry.LastSeen) < templateQueueTTL {
+				queue = append(queue, entry)
+			}
+		}
+	}
+	sort.SliceStable(queue, func(i, j int) bool { return queue[i].JoinedAt.Before(queue[j].JoinedAt) })
+
+	position := len(queue)
+	for idx, entry := range queue {
+		if entry.User == username {
+			position = idx
+			break
+		}
+	}
+
+	if position < max-running {
+		// there is room for the user, take them out of the queue
+		if position < len(queue) {
+			queue = append(queue[:position], queue[position+1:]...)
+		}
+		return d.writeTemplateQueue(queues, tmpl.GetName(), queue)
+	}
+
+	if position == len(queue) {
+		queue = append(queue, &templateQueueEntry{User: username, JoinedAt: now})
+	}
+	queue[position].LastSeen = now
+	if err := d.writeTemplateQueue(queues, tmpl.GetName(), queue); err != nil {
+		return err
+	}
+	return errors.NewCapacityReachedError(tmpl.GetName(), running, max, position)
+}
+
+// readTemplateQueues returns the queues for all templates. The secrets lock should
+// be held by the caller.
+func (d *desktopAPI) readTemplateQueues() (map[string][]byte, error) {
+	queues, err := d.secrets.ReadSecretMap(v1.TemplateQueuesSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return make(map[string][]byte), nil
+		}
+		return nil, err
+	}
+	return queues, nil
+}
+
+// writeTemplateQueue writes the queue for the given template into the queues read by
+// readTemplateQueues and saves them to the secrets backend. The secrets lock should
+// be held by the caller.
+func (d *desktopAPI) writeTemplateQueue(queues map[string][]byte, template string, queue []*templateQueueEntry) error {
+	if len(queue) == 0 {
+		if _, ok := queues[template]; !ok {
+			return nil
+		}
+		delete(queues, template)
+	} else {
+		data, err := json.Marshal(queue)
+		if err != nil {
+			return err
+		}
+		queues[template] = data
+	}
+	return d.secrets.WriteSecretMap(v1.TemplateQueuesSecretKey, queues)
+}
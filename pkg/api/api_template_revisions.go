@@ -0,0 +1,14 @@
+package api
+
+import (
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+// recordTemplateRevision records the current revision of a template after it was
+// written. Failures are only logged, since desktops also record the revision of the
+// template they boot from.
+func (d *desktopAPI) recordTemplateRevision(tmpl *v1alpha1.DesktopTemplate) {
+	if err := tmpl.RecordRevision(d.client, d.vdiCluster.GetCoreNamespace()); err != nil {
+		apiLogger.Error(err, "Failed to record template revision", "Template", tmpl.GetName(), "Revision", tmpl.GetRevision())
+	}
+}
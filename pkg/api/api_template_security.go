@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// checkTemplateSecurityProfiles returns an error if the security profiles of the given
+// template are malformed, or if it uses an AppArmor profile and none of the nodes in
+// the cluster have AppArmor enabled. Whether localhost profiles are present on the
+// nodes cannot be determined from the API, and is reported on the status of desktops
+// that fail to start because of them.
+func (d *desktopAPI) checkTemplateSecurityProfiles(tmpl *v1alpha1.DesktopTemplate) error {
+	if err := tmpl.ValidateSecurityProfiles(); err != nil {
+		return err
+	}
+	if !tmpl.RequiresAppArmor() {
+		return nil
+	}
+	nodes := &corev1.NodeList{}
+	if err := d.client.List(context.TODO(), nodes); err != nil {
+		return err
+	}
+	for i := range nodes.Items {
+		if v1alpha1.AppArmorEnabledOn(&nodes.Items[i]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("DesktopTemplate %s uses AppArmor profile %s, but no nodes have AppArmor enabled", tmpl.GetName(), tmpl.GetAppArmorProfile())
+}
@@ -1,12 +1,56 @@
 package api
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/api/client"
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/audit"
+	"github.com/tinyzimmer/kvdi/pkg/auth"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/auth/mfa"
+	"github.com/tinyzimmer/kvdi/pkg/email"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/lock"
+	"github.com/tinyzimmer/kvdi/pkg/util/tracing"
+
+	"github.com/gorilla/mux"
+	"github.com/xlzd/gotp"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // mustNewTestAPI creates and starts a new HTTP server connected to the
@@ -165,3 +209,4960 @@ func TestUsers(t *testing.T) {
 	}
 
 }
+
+// TestGroups tests group related operations.
+func TestGroups(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+		Username: "test-user",
+		Password: "test-password",
+		Roles:    []string{"test-cluster-launch-templates"},
+	}); err != nil {
+		t.Fatal("Unable to create test user:", err)
+	}
+
+	// Check that we can't create a group without a name
+	if err := cl.CreateVDIGroup(&v1.CreateGroupRequest{Users: []string{"test-user"}}); err == nil {
+		t.Error("Expected to not be able to create group with no name, got nil error")
+	}
+
+	// Check that we can create a group
+	if err := cl.CreateVDIGroup(&v1.CreateGroupRequest{
+		Name:  "admins",
+		Users: []string{"test-user"},
+		Roles: []string{"test-cluster-admin"},
+	}); err != nil {
+		t.Fatal("Unable to create test group:", err)
+	}
+	groups, err := cl.GetVDIGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].GetName() != "admins" {
+		t.Error("Expected one admins group, got", groups)
+	}
+
+	// members should get the roles of the group in addition to their own
+	user, err := cl.GetVDIUser("test-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Roles) != 2 || user.Roles[0].GetName() != "test-cluster-launch-templates" || user.Roles[1].GetName() != "test-cluster-admin" {
+		t.Error("Expected user to have their own role and the group's, got:", user.Roles)
+	}
+	if !reflect.DeepEqual(user.Groups, []string{"admins"}) {
+		t.Error("Expected user to be a member of the admins group, got:", user.Groups)
+	}
+
+	// removing the user from the group removes the roles
+	if err := cl.UpdateVDIGroup("admins", &v1.UpdateGroupRequest{Roles: []string{"test-cluster-admin"}}); err != nil {
+		t.Fatal(err)
+	}
+	user, err = cl.GetVDIUser("test-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Roles) != 1 || len(user.Groups) != 0 {
+		t.Error("Expected user to only have their own role, got:", user.Roles, user.Groups)
+	}
+
+	// deleting a user removes them from their groups
+	if err := cl.UpdateVDIGroup("admins", &v1.UpdateGroupRequest{
+		Users: []string{"test-user", "admin"},
+		Roles: []string{"test-cluster-admin"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.DeleteVDIUser("test-user"); err != nil {
+		t.Fatal(err)
+	}
+	group, err := cl.GetVDIGroup("admins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(group.GetUsers(), []string{"admin"}) {
+		t.Error("Expected deleted user to be removed from the group, got:", group.GetUsers())
+	}
+
+	// Delete the group
+	if err := cl.DeleteVDIGroup("admins"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.GetVDIGroup("admins"); err == nil {
+		t.Error("Expected error for retrieving deleted group, got nil")
+	} else if !strings.Contains(err.Error(), "No group") {
+		t.Error("Expected group not found error, got:", err)
+	}
+}
+
+// TestChangePassword tests users changing their own password.
+func TestChangePassword(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	for _, name := range []string{"test-user", "other-user"} {
+		if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+			Username: name,
+			Password: "test-password",
+			Roles:    []string{"test-cluster-launch-templates"},
+		}); err != nil {
+			t.Fatal("Unable to create test user:", err)
+		}
+	}
+
+	userCl, err := client.New(&client.Opts{URL: opts.URL, Username: "test-user", Password: "test-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userCl.Close()
+
+	// users can't change their password without the current one
+	if err := userCl.UpdateVDIUser("test-user", &v1.UpdateUserRequest{Password: "new-password"}); err == nil {
+		t.Error("Expected error changing password without the current one, got nil")
+	}
+	if err := userCl.ChangeVDIUserPassword("test-user", &v1.ChangePasswordRequest{
+		OldPassword: "wrong-password",
+		NewPassword: "new-password",
+	}); err == nil {
+		t.Error("Expected error changing password with incorrect current password, got nil")
+	} else if !strings.Contains(err.Error(), "incorrect") {
+		t.Error("Expected incorrect password error, got:", err)
+	}
+
+	// or the password of another user
+	if err := userCl.ChangeVDIUserPassword("other-user", &v1.ChangePasswordRequest{
+		OldPassword: "test-password",
+		NewPassword: "new-password",
+	}); err == nil {
+		t.Error("Expected error changing another user's password, got nil")
+	}
+	// this applies to admins as well
+	if err := cl.ChangeVDIUserPassword("other-user", &v1.ChangePasswordRequest{
+		OldPassword: "test-password",
+		NewPassword: "new-password",
+	}); err == nil {
+		t.Error("Expected error changing another user's password as admin, got nil")
+	}
+
+	if err := userCl.ChangeVDIUserPassword("test-user", &v1.ChangePasswordRequest{
+		OldPassword: "test-password",
+		NewPassword: "new-password",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the new password should work for logging in
+	newCl, err := client.New(&client.Opts{URL: opts.URL, Username: "test-user", Password: "new-password"})
+	if err != nil {
+		t.Fatal("Expected to be able to log in with new password, got:", err)
+	}
+	newCl.Close()
+	if _, err := client.New(&client.Opts{URL: opts.URL, Username: "test-user", Password: "test-password"}); err == nil {
+		t.Error("Expected old password to no longer work, got nil error")
+	}
+}
+
+// TestTokenRevocation tests logging out and forcing users to log out everywhere.
+func TestTokenRevocation(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+		Username: "test-user",
+		Password: "test-password",
+		Roles:    []string{"test-cluster-launch-templates"},
+	}); err != nil {
+		t.Fatal("Unable to create test user:", err)
+	}
+	userOpts := &client.Opts{URL: opts.URL, Username: "test-user", Password: "test-password"}
+
+	// tokens can't be used after logging out
+	userCl, err := client.New(userOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := userCl.WhoAmI(); err != nil {
+		t.Fatal(err)
+	}
+	userCl.Close()
+	if _, err := userCl.WhoAmI(); err == nil {
+		t.Error("Expected error using token after logout, got nil")
+	} else if !strings.Contains(err.Error(), "revoked") {
+		t.Error("Expected token revoked error, got:", err)
+	}
+
+	// users can only be logged out by those who can update them
+	userCl, err = client.New(userOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userCl.Close()
+	if err := userCl.LogoutVDIUser("admin"); err == nil {
+		t.Error("Expected error logging out another user without permission, got nil")
+	}
+
+	// all of the user's sessions are revoked
+	otherCl, err := client.New(userOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.LogoutVDIUser("test-user"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*client.Client{userCl, otherCl} {
+		if _, err := c.WhoAmI(); err == nil {
+			t.Error("Expected error using token after forced logout, got nil")
+		}
+	}
+	if _, err := cl.WhoAmI(); err != nil {
+		t.Error("Expected other users to remain logged in, got:", err)
+	}
+
+	// tokens issued after the revocation are valid, even within the same second
+	time.Sleep(time.Millisecond)
+	newCl, err := client.New(userOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer newCl.Close()
+	if _, err := newCl.WhoAmI(); err != nil {
+		t.Error("Expected new session to be valid, got:", err)
+	}
+}
+
+// TestJobs tests running long operations as jobs and retrieving their results.
+func TestJobs(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	job, err := cl.ImportVDIUsersAsync([]*v1.UserRecord{
+		{Username: "contractor-1", Roles: []string{"test-cluster-launch-templates"}, Password: "test-password"},
+		{Username: "contractor-2", Roles: []string{"test-cluster-launch-templates"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID == "" || job.Type != v1.JobTypeUserImport || job.User != "admin" {
+		t.Error("Unexpected job:", job)
+	}
+	if job, err = cl.WaitForJob(job.ID, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if job.State != v1.JobSucceeded || job.Progress != 100 || job.CompletedAt == 0 {
+		t.Fatal("Expected job to succeed, got:", job)
+	}
+	resp := &v1.ImportUsersResponse{}
+	if err := json.Unmarshal(job.Result, resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Imported != 2 || resp.GeneratedPasswords["contractor-2"] == "" {
+		t.Error("Expected result of the import, got:", resp)
+	}
+	succeeded := job.ID
+
+	// failed imports keep the result with the errors for each row
+	if job, err = cl.ImportVDIUsersAsync([]*v1.UserRecord{
+		{Username: "contractor-3", Roles: []string{"missing-role"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if job, err = cl.WaitForJob(job.ID, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if job.State != v1.JobFailed || job.Error == "" {
+		t.Fatal("Expected job to fail, got:", job)
+	}
+	resp = &v1.ImportUsersResponse{}
+	if err := json.Unmarshal(job.Result, resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) != 1 {
+		t.Error("Expected error for the invalid row, got:", resp.Errors)
+	}
+
+	jobs, err := cl.GetJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatal("Expected two jobs, got:", len(jobs))
+	}
+
+	// users can only see their own jobs
+	userCl, err := client.New(&client.Opts{URL: opts.URL, Username: "contractor-1", Password: "test-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userCl.Close()
+	if _, err := userCl.GetJob(succeeded); err == nil {
+		t.Error("Expected error retrieving another user's job, got nil")
+	}
+	if jobs, err := userCl.GetJobs(); err != nil {
+		t.Fatal(err)
+	} else if len(jobs) != 0 {
+		t.Error("Expected no jobs for the user, got:", jobs)
+	}
+
+	// jobs that stop sending heartbeats are reported as interrupted, and old jobs
+	// are removed
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	os.Setenv("POD_NAMESPACE", "default")
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, j := range []*v1.Job{
+		{ID: "stale", User: "admin", State: v1.JobRunning, CreatedAt: now.Add(-time.Hour).Unix(), UpdatedAt: now.Add(-jobStaleAfter - time.Second).Unix()},
+		{ID: "expired", User: "admin", State: v1.JobSucceeded, CreatedAt: now.Add(-2 * jobRetention).Unix(), UpdatedAt: now.Add(-jobRetention - time.Second).Unix()},
+		{ID: "running", User: "admin", State: v1.JobRunning, CreatedAt: now.Unix(), UpdatedAt: now.Unix()},
+	} {
+		if err := d.saveJob(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jobs, err = d.listUserJobs("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "running" || jobs[1].ID != "stale" {
+		t.Fatal("Expected the running and stale jobs, got:", jobs)
+	}
+	if jobs[0].State != v1.JobRunning {
+		t.Error("Expected job with recent heartbeat to be running, got:", jobs[0].State)
+	}
+	if jobs[1].State != v1.JobFailed || jobs[1].Error != errJobInterrupted {
+		t.Error("Expected stale job to be interrupted, got:", jobs[1])
+	}
+}
+
+func TestUserImport(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	countUsers := func() int {
+		users, err := cl.GetVDIUsers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(users)
+	}
+
+	// rows that fail validation prevent all users from being created
+	if _, err := cl.ImportVDIUsers([]*v1.UserRecord{
+		{Username: "contractor-1", Roles: []string{"test-cluster-admin"}},
+		{Username: "contractor-2", Roles: []string{"missing-role"}},
+		{Username: "admin", Roles: []string{"test-cluster-admin"}},
+	}); err == nil {
+		t.Error("Expected error for invalid rows")
+	} else if !strings.Contains(err.Error(), "2 of 3 rows failed") {
+		t.Error("Expected summary of failed rows, got:", err)
+	}
+	if count := countUsers(); count != 1 {
+		t.Error("Expected no users to be imported, got:", count)
+	}
+
+	// users created before a failure are removed again
+	if _, err := cl.ImportVDIUsers([]*v1.UserRecord{
+		{Username: "contractor-1", Roles: []string{"test-cluster-admin"}, Password: "test-password"},
+		{Username: "contractor-2", Roles: []string{"test-cluster-admin"}, Password: "short"},
+	}); err == nil {
+		t.Error("Expected error for password that does not meet the policy")
+	}
+	if count := countUsers(); count != 1 {
+		t.Error("Expected created users to be removed after failed import, got:", count)
+	}
+
+	resp, err := cl.ImportVDIUsers([]*v1.UserRecord{
+		{Username: "contractor-1", Roles: []string{"test-cluster-admin"}, Password: "test-password"},
+		{Username: "contractor-2", Roles: []string{"test-cluster-admin"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Imported != 2 {
+		t.Error("Expected two users to be imported, got:", resp.Imported)
+	}
+	if _, ok := resp.GeneratedPasswords["contractor-1"]; ok {
+		t.Error("Expected no password to be generated for user that was given one")
+	}
+	generated, ok := resp.GeneratedPasswords["contractor-2"]
+	if !ok {
+		t.Fatal("Expected a password to be generated, got:", resp.GeneratedPasswords)
+	}
+	userCl, err := client.New(&client.Opts{URL: opts.URL, Username: "contractor-2", Password: generated})
+	if err != nil {
+		t.Fatal("Expected to be able to log in with generated password, got:", err)
+	}
+	userCl.Close()
+
+	records, err := cl.ExportVDIUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatal("Expected three users in export, got:", len(records))
+	}
+	for _, record := range records {
+		if record.Password != "" {
+			t.Error("Expected passwords to not be exported")
+		}
+		if len(record.Roles) != 1 {
+			t.Error("Expected user to have one role, got:", record.Roles)
+		}
+	}
+
+	// csv exports can be read back in
+	var buf strings.Builder
+	if err := writeUserCSV(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := readUserCSV(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 3 || parsed[0].Username != records[0].Username || len(parsed[0].Roles) != 1 {
+		t.Error("Expected exported users to be read back, got:", parsed)
+	}
+	parsed, err = readUserCSV(strings.NewReader("roles,username\nrole-a; role-b,contractor-3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed[0].Username != "contractor-3" || len(parsed[0].Roles) != 2 || parsed[0].Roles[1] != "role-b" {
+		t.Error("Expected columns to be read by name, got:", parsed[0])
+	}
+	if _, err := readUserCSV(strings.NewReader("username\ncontractor-3\n")); err == nil {
+		t.Error("Expected error for CSV missing the roles column")
+	}
+
+	// users that can only create users cannot import users with more privileges
+	if err := cl.CreateVDIRole(&v1.CreateRoleRequest{
+		Name: "user-creator",
+		Rules: []v1.Rule{{
+			Verbs:     []v1.Verb{v1.VerbCreate},
+			Resources: []v1.Resource{v1.ResourceUsers},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+		Username: "test-user",
+		Password: "test-password",
+		Roles:    []string{"user-creator"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	userCl, err = client.New(&client.Opts{URL: opts.URL, Username: "test-user", Password: "test-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userCl.Close()
+	admins := []*v1.UserRecord{{Username: "contractor-3", Roles: []string{"test-cluster-admin"}}}
+	if _, err := userCl.ImportVDIUsers(admins); err == nil || !strings.Contains(err.Error(), elevateDenyReason) {
+		t.Error("Expected import of an admin to be denied, got:", err)
+	}
+	if _, err := userCl.ImportVDIUsersAsync(admins); err == nil || !strings.Contains(err.Error(), elevateDenyReason) {
+		t.Error("Expected async import of an admin to be denied, got:", err)
+	}
+	if _, err := userCl.ImportVDIUsers([]*v1.UserRecord{
+		{Username: "contractor-3", Roles: []string{"user-creator"}},
+	}); err != nil {
+		t.Error("Expected import with the user's own role to succeed, got:", err)
+	}
+}
+
+func TestUserData(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	// check that the admin user has no script configured
+	resp, err := cl.GetVDIUserData("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.UserData != "" {
+		t.Error("Expected no user data, got:", resp.UserData)
+	}
+
+	// setting a script for a user that doesn't exist should fail
+	if err := cl.UpdateVDIUserData("missing-user", &v1.UpdateUserDataRequest{UserData: "echo hello"}); err == nil {
+		t.Error("Expected error setting user data for missing user, got nil")
+	} else if !strings.Contains(err.Error(), "not found") {
+		t.Error("Expected user not found error, got:", err)
+	}
+
+	// set a script for the admin user
+	if err := cl.UpdateVDIUserData("admin", &v1.UpdateUserDataRequest{UserData: "echo hello"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = cl.GetVDIUserData("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.UserData != "echo hello" {
+		t.Error("Expected user data to be set, got:", resp.UserData)
+	}
+
+	// remove the script
+	if err := cl.UpdateVDIUserData("admin", &v1.UpdateUserDataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = cl.GetVDIUserData("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.UserData != "" {
+		t.Error("Expected user data to be removed, got:", resp.UserData)
+	}
+}
+
+// TestDotfiles tests managing dotfiles repositories for users.
+func TestDotfiles(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	// check that the admin user has no repository configured
+	dotfiles, err := cl.GetVDIUserDotfiles("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dotfiles.Repository != "" {
+		t.Error("Expected no dotfiles repository, got:", dotfiles.Repository)
+	}
+
+	// invalid configurations should be rejected
+	if err := cl.UpdateVDIUserDotfiles("admin", &v1.DotfilesConfig{
+		Repository: "https://github.com/example/dotfiles",
+		Depth:      -1,
+	}); err == nil {
+		t.Error("Expected error setting negative depth, got nil")
+	}
+
+	// set a repository for the admin user
+	if err := cl.UpdateVDIUserDotfiles("admin", &v1.DotfilesConfig{
+		Repository: "https://github.com/example/dotfiles",
+		Branch:     "main",
+		Depth:      1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	dotfiles, err = cl.GetVDIUserDotfiles("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dotfiles.Repository != "https://github.com/example/dotfiles" || dotfiles.Branch != "main" || dotfiles.Depth != 1 {
+		t.Error("Expected dotfiles to be set, got:", dotfiles)
+	}
+
+	// remove the repository
+	if err := cl.UpdateVDIUserDotfiles("admin", &v1.DotfilesConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	dotfiles, err = cl.GetVDIUserDotfiles("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dotfiles.Repository != "" {
+		t.Error("Expected dotfiles to be removed, got:", dotfiles)
+	}
+}
+
+// TestUserSecrets tests managing the secrets of users.
+func TestUserSecrets(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	// invalid names and empty values should be rejected
+	if err := cl.SetVDIUserSecret("admin", "bad$name", &v1.UpdateUserSecretRequest{Value: "value"}); err == nil {
+		t.Error("Expected error setting a secret with an invalid name, got nil")
+	}
+	if err := cl.SetVDIUserSecret("admin", "token", &v1.UpdateUserSecretRequest{}); err == nil {
+		t.Error("Expected error setting a secret with no value, got nil")
+	}
+
+	// setting a secret for a user that doesn't exist should fail
+	if err := cl.SetVDIUserSecret("missing-user", "token", &v1.UpdateUserSecretRequest{Value: "value"}); err == nil {
+		t.Error("Expected error setting a secret for missing user, got nil")
+	}
+
+	for _, name := range []string{"token", "aws.key"} {
+		if err := cl.SetVDIUserSecret("admin", name, &v1.UpdateUserSecretRequest{Value: "value"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := cl.GetVDIUserSecrets("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp.Names, []string{"aws.key", "token"}) {
+		t.Error("Expected both secrets to be listed, got:", resp.Names)
+	}
+
+	if err := cl.DeleteVDIUserSecret("admin", "token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.DeleteVDIUserSecret("admin", "token"); err == nil {
+		t.Error("Expected error deleting a missing secret, got nil")
+	}
+	if resp, err = cl.GetVDIUserSecrets("admin"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(resp.Names, []string{"aws.key"}) {
+		t.Error("Expected one secret to remain, got:", resp.Names)
+	}
+}
+
+// TestLoginHistory tests recording and querying login attempts.
+func TestLoginHistory(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	start := time.Now().Add(-time.Second)
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+		Username: "test-user",
+		Password: "test-password",
+		Roles:    []string{"test-cluster-launch-templates"},
+	}); err != nil {
+		t.Fatal("Unable to create test user:", err)
+	}
+	if _, err := client.New(&client.Opts{URL: opts.URL, Username: "test-user", Password: "wrong-password"}); err == nil {
+		t.Fatal("Expected error logging in with the wrong password, got nil")
+	}
+	if _, err := client.New(&client.Opts{URL: opts.URL, Username: "nobody", Password: "test-password"}); err == nil {
+		t.Fatal("Expected error logging in as a missing user, got nil")
+	}
+	userCl, err := client.New(&client.Opts{URL: opts.URL, Username: "test-user", Password: "test-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userCl.Close()
+
+	// users can see their own attempts, newest first
+	history, err := userCl.GetVDIUserLogins("test-user", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Attempts) != 2 {
+		t.Fatal("Expected two attempts for the user, got:", history.Attempts)
+	}
+	if latest := history.Attempts[0]; !latest.Success || latest.MFA != v1.LoginMFANotRequired || latest.Provider != v1alpha1.AuthBackendLocal || latest.ClientAddr == "" || latest.UserAgent == "" {
+		t.Error("Unexpected successful attempt:", latest)
+	}
+	if failed := history.Attempts[1]; failed.Success || failed.MFA != "" {
+		t.Error("Unexpected failed attempt:", failed)
+	}
+
+	// but not the attempts of other users
+	if _, err := userCl.GetVDIUserLogins("admin", time.Time{}, time.Time{}); err == nil {
+		t.Error("Expected error reading another user's attempts, got nil")
+	}
+	if _, err := userCl.GetLoginReport("", time.Time{}, time.Time{}); err == nil {
+		t.Error("Expected error reading all attempts as a user, got nil")
+	}
+
+	// admins can query attempts for all users, including ones that do not exist
+	if history, err = cl.GetLoginReport("", start, time.Now()); err != nil {
+		t.Fatal(err)
+	} else if len(history.Attempts) != 4 {
+		t.Error("Expected four attempts, got:", history.Attempts)
+	}
+	if history, err = cl.GetLoginReport("nobody", time.Time{}, time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if len(history.Attempts) != 1 || history.Attempts[0].Success {
+		t.Error("Expected one failed attempt for the missing user, got:", history.Attempts)
+	}
+	if history, err = cl.GetLoginReport("", time.Time{}, start); err != nil {
+		t.Fatal(err)
+	} else if len(history.Attempts) != 0 {
+		t.Error("Expected no attempts before the start of the test, got:", history.Attempts)
+	}
+	if _, err := cl.GetLoginReport("", time.Now(), start); err == nil {
+		t.Error("Expected error querying with the end before the start, got nil")
+	}
+}
+
+// TestUserEmail tests managing the email addresses of users.
+func TestUserEmail(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	if err := cl.UpdateVDIUserEmail("admin", &v1.UserEmailConfig{Address: "Admin <admin@example.com>"}); err == nil {
+		t.Error("Expected error setting an invalid address, got nil")
+	}
+	if err := cl.UpdateVDIUserEmail("admin", &v1.UserEmailConfig{Address: "admin@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	addr, err := cl.GetVDIUserEmail("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.Address != "admin@example.com" {
+		t.Error("Expected address to be set, got:", addr.Address)
+	}
+	if err := cl.UpdateVDIUserEmail("admin", &v1.UserEmailConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if addr, err = cl.GetVDIUserEmail("admin"); err != nil {
+		t.Fatal(err)
+	} else if addr.Address != "" {
+		t.Error("Expected address to be removed, got:", addr.Address)
+	}
+
+	// password resets require email to be configured
+	if err := cl.RequestPasswordReset("admin"); err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Error("Expected password resets to be disabled, got:", err)
+	}
+}
+
+// TestUserKeyboard tests managing the keyboard layouts of users and passing them
+// to the kvdi-proxy.
+func TestUserKeyboard(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	for _, invalid := range []*v1.KeyboardLayoutConfig{
+		{Layout: "de; rm -rf /"},
+		{Layout: "de", Variant: "no dead keys"},
+		{Variant: "nodeadkeys"},
+	} {
+		if err := cl.UpdateVDIUserKeyboard("admin", invalid); err == nil {
+			t.Error("Expected error setting an invalid layout, got nil:", invalid)
+		}
+	}
+	if err := cl.UpdateVDIUserKeyboard("admin", &v1.KeyboardLayoutConfig{Layout: "de", Variant: "nodeadkeys"}); err != nil {
+		t.Fatal(err)
+	}
+	layout, err := cl.GetVDIUserKeyboard("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout.Layout != "de" || layout.Variant != "nodeadkeys" {
+		t.Error("Expected layout to be set, got:", layout)
+	}
+	if err := cl.UpdateVDIUserKeyboard("admin", &v1.KeyboardLayoutConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if layout, err = cl.GetVDIUserKeyboard("admin"); err != nil {
+		t.Fatal(err)
+	} else if layout.Layout != "" {
+		t.Error("Expected layout to be removed, got:", layout)
+	}
+
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	proxyQuery := func(user string, detected *v1.KeyboardLayoutConfig) url.Values {
+		req := httptest.NewRequest(http.MethodGet, "/api/desktops/ws/default/test/display", nil)
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: &v1.VDIUser{Name: user}})
+		d.setKeyboardLayoutOptions(req, detected)
+		return req.URL.Query()
+	}
+
+	// the layout detected by the client is used when the user has none
+	if query := proxyQuery("test-user", &v1.KeyboardLayoutConfig{Layout: "fr"}); query.Get(v1.KeyboardLayoutQueryParam) != "fr" || query.Get(v1.KeyboardVariantQueryParam) != "" {
+		t.Error("Expected the detected layout to be passed to the proxy, got:", query)
+	}
+	if query := proxyQuery("test-user", &v1.KeyboardLayoutConfig{Layout: "fr && reboot"}); query.Get(v1.KeyboardLayoutQueryParam) != "" {
+		t.Error("Expected an invalid detected layout to be ignored, got:", query)
+	}
+
+	// and the user's own layout takes precedence
+	if err := d.secrets.WriteSecretMap(v1.UserKeyboardLayoutsSecretKey, map[string][]byte{
+		"test-user": []byte(`{"layout":"us","variant":"dvorak"}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if query := proxyQuery("test-user", &v1.KeyboardLayoutConfig{Layout: "fr"}); query.Get(v1.KeyboardLayoutQueryParam) != "us" || query.Get(v1.KeyboardVariantQueryParam) != "dvorak" {
+		t.Error("Expected the user's layout to be passed to the proxy, got:", query)
+	}
+}
+
+// sentEmail is a message sent through the fakeEmailSender.
+type sentEmail struct {
+	to   []string
+	msg  email.Message
+	data *email.Data
+}
+
+// fakeEmailSender records the messages sent through it.
+type fakeEmailSender struct {
+	sent chan *sentEmail
+}
+
+func (f *fakeEmailSender) Send(to []string, msg email.Message, data *email.Data) error {
+	f.sent <- &sentEmail{to: to, msg: msg, data: data}
+	return nil
+}
+
+func (f *fakeEmailSender) AdminAddresses() []string { return []string{"ops@example.com"} }
+
+func (f *fakeEmailSender) AppURL() string { return "https://kvdi.example.com" }
+
+// TestEmailNotifications tests the messages emailed to users and administrators.
+func TestEmailNotifications(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.Email = &v1alpha1.EmailConfig{Server: "smtp.example.com:25", From: "kvdi@example.com", AppURL: "https://kvdi.example.com"}
+	sender := &fakeEmailSender{sent: make(chan *sentEmail, 10)}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster), email: sender, logins: newLoginLimiter()}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	d.mfa = mfa.NewManager(d.secrets)
+	d.auth = auth.GetAuthProvider(cluster, d.secrets)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.auth.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.auth.Reconcile(apiLogger, d.client, cluster, "testing"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.secrets.WriteSecret(v1.JWTSecretKey, []byte("supersecret")); err != nil {
+		t.Fatal(err)
+	}
+
+	nextEmail := func() *sentEmail {
+		t.Helper()
+		select {
+		case sent := <-sender.sent:
+			return sent
+		case <-time.After(time.Second):
+			t.Fatal("Expected an email to be sent")
+		}
+		return nil
+	}
+	expectNoEmail := func() {
+		t.Helper()
+		select {
+		case sent := <-sender.sent:
+			t.Error("Expected no email to be sent, got:", sent.msg)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	requestReset := func(username string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/reset-password", nil)
+		apiutil.SetRequestObject(req, &v1.PasswordResetRequest{Username: username})
+		rr := httptest.NewRecorder()
+		d.PostResetPassword(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatal("Expected reset request to succeed, got:", rr.Code, rr.Body.String())
+		}
+	}
+	confirmReset := func(token, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/reset-password/confirm", nil)
+		apiutil.SetRequestObject(req, &v1.ConfirmPasswordResetRequest{Token: token, Password: password})
+		rr := httptest.NewRecorder()
+		d.PostResetPasswordConfirm(rr, req)
+		return rr
+	}
+
+	// nothing is sent to users without an address, or that don't exist
+	requestReset("admin")
+	requestReset("nobody")
+	expectNoEmail()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/users/admin/email", nil)
+	req = mux.SetURLVars(req, map[string]string{"user": "admin"})
+	apiutil.SetRequestObject(req, &v1.UserEmailConfig{Address: "admin@example.com"})
+	rr := httptest.NewRecorder()
+	d.PutUserEmail(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected address to be set, got:", rr.Code, rr.Body.String())
+	}
+
+	getResetToken := func() string {
+		t.Helper()
+		requestReset("admin")
+		sent := nextEmail()
+		if sent.msg != email.MessagePasswordReset || sent.to[0] != "admin@example.com" || !strings.HasPrefix(sent.data.Link, "https://kvdi.example.com/#/reset-password?token=") {
+			t.Fatal("Expected a password reset link, got:", sent.msg, sent.to, sent.data.Link)
+		}
+		return strings.TrimPrefix(sent.data.Link, "https://kvdi.example.com/#/reset-password?token=")
+	}
+
+	// requesting a new link invalidates the last one
+	oldToken := getResetToken()
+	token := getResetToken()
+	if rr := confirmReset(oldToken, "new-password"); rr.Code != http.StatusForbidden {
+		t.Error("Expected replaced token to be rejected, got:", rr.Code)
+	}
+	if rr := confirmReset("bad-token", "new-password"); rr.Code != http.StatusForbidden {
+		t.Error("Expected invalid token to be rejected, got:", rr.Code)
+	}
+
+	// passwords that fail the policy don't spend the token
+	if rr := confirmReset(token, "short"); rr.Code != http.StatusBadRequest {
+		t.Error("Expected password policy to be enforced, got:", rr.Code)
+	}
+
+	// password reset links can be used once, even by concurrent requests
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { codes <- confirmReset(token, "new-password").Code }()
+	}
+	if first, second := <-codes, <-codes; first+second != http.StatusOK+http.StatusForbidden {
+		t.Fatal("Expected exactly one concurrent reset to succeed, got:", first, second)
+	}
+	if _, err := d.auth.Authenticate(&v1.LoginRequest{Username: "admin", Password: "new-password"}); err != nil {
+		t.Error("Expected new password to work, got:", err)
+	}
+	if rr := confirmReset(token, "other-password"); rr.Code != http.StatusForbidden {
+		t.Error("Expected token to only be usable once, got:", rr.Code)
+	}
+
+	// MFA enrollment links are sent to the user
+	req = httptest.NewRequest(http.MethodPut, "/api/users/admin/mfa", nil)
+	req = mux.SetURLVars(req, map[string]string{"user": "admin"})
+	apiutil.SetRequestObject(req, &v1.UpdateMFARequest{Enabled: true})
+	rr = httptest.NewRecorder()
+	d.PutUserMFA(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected MFA to be enabled, got:", rr.Code, rr.Body.String())
+	}
+	if sent := nextEmail(); sent.msg != email.MessageMFAEnrollment || !strings.HasPrefix(sent.data.Link, "otpauth://") {
+		t.Error("Expected an MFA enrollment link, got:", sent.msg, sent.data.Link)
+	}
+
+	// administrators are alerted to lockouts
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{LoginRateLimit: &v1alpha1.LoginRateLimitConfig{LockoutThreshold: 1}}
+	d.recordLoginFailure(httptest.NewRequest(http.MethodPost, "/api/login", nil), "admin")
+	if sent := nextEmail(); sent.msg != email.MessageAdminAlert || sent.to[0] != "ops@example.com" || sent.data.Reason != "User locked out" {
+		t.Error("Expected a lockout alert, got:", sent.msg, sent.to, sent.data.Reason)
+	}
+}
+
+// TestTemplateBundles tests exporting and importing template bundles.
+func TestTemplateBundles(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	if err := cl.CreateDesktopTemplate(&v1alpha1.DesktopTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-template"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.CreateVDIRole(&v1.CreateRoleRequest{
+		Name: "test-role",
+		Rules: []v1.Rule{{
+			Verbs:            []v1.Verb{v1.VerbLaunch},
+			Resources:        []v1.Resource{v1.ResourceTemplates},
+			ResourcePatterns: []string{"^test-template$"},
+			Namespaces:       []string{"default"},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check that exporting a missing template fails
+	if _, err := cl.ExportDesktopTemplates(&v1.ExportTemplatesRequest{
+		Templates: []string{"missing-template"},
+	}); err == nil {
+		t.Error("Expected error exporting missing template, got nil")
+	}
+
+	// Export the template and the role granting access to it
+	bundle, err := cl.ExportDesktopTemplates(&v1.ExportTemplatesRequest{
+		Templates:    []string{"test-template"},
+		IncludeRoles: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Templates) != 1 || bundle.Templates[0].GetName() != "test-template" {
+		t.Error("Expected bundle to contain test-template, got:", bundle.Templates)
+	}
+	if len(bundle.Roles) != 1 || bundle.Roles[0].GetName() != "test-role" {
+		t.Error("Expected bundle to contain only test-role, got:", bundle.Roles)
+	}
+	if bundle.Signature == "" {
+		t.Error("Expected bundle to be signed")
+	}
+
+	// Importing over existing objects should fail without overwrite
+	if err := cl.ImportDesktopTemplates(&v1alpha1.ImportTemplateBundleRequest{
+		Bundle: bundle,
+	}); err == nil {
+		t.Error("Expected error importing existing templates, got nil")
+	} else if !strings.Contains(err.Error(), "already exists") {
+		t.Error("Expected already exists error, got:", err)
+	}
+
+	// A tampered bundle should be rejected
+	tampered := *bundle
+	tampered.Source = "another-cluster"
+	if err := cl.ImportDesktopTemplates(&v1alpha1.ImportTemplateBundleRequest{
+		Bundle:    &tampered,
+		Overwrite: true,
+	}); err == nil {
+		t.Error("Expected error importing tampered bundle, got nil")
+	} else if !strings.Contains(err.Error(), "signature is invalid") {
+		t.Error("Expected invalid signature error, got:", err)
+	}
+
+	// Import the bundle with new names
+	if err := cl.ImportDesktopTemplates(&v1alpha1.ImportTemplateBundleRequest{
+		Bundle:        bundle,
+		TemplateNames: map[string]string{"test-template": "imported-template"},
+		RoleNames:     map[string]string{"test-role": "imported-role"},
+		Namespaces:    map[string]string{"default": "imported"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.GetDesktopTemplate("imported-template"); err != nil {
+		t.Error("Expected imported template to exist, got:", err)
+	}
+	role, err := cl.GetVDIRole("imported-role")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(role.Rules) != 1 {
+		t.Fatal("Expected imported role to have one rule, got:", role.Rules)
+	}
+	rule := role.Rules[0]
+	if !rule.MatchesResourceName("imported-template") || rule.MatchesResourceName("test-template") {
+		t.Error("Expected imported role patterns to be remapped, got:", rule.ResourcePatterns)
+	}
+	if !rule.HasNamespace("imported") || rule.HasNamespace("default") {
+		t.Error("Expected imported role namespaces to be remapped, got:", rule.Namespaces)
+	}
+
+	// templates inheriting from others
+	if err := cl.CreateDesktopTemplate(&v1alpha1.DesktopTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "base-template"},
+		Spec:       v1alpha1.DesktopTemplateSpec{Image: "base-image"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.CreateDesktopTemplate(&v1alpha1.DesktopTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "child-template",
+			Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: "{}", "team": "qa"},
+		},
+		Spec: v1alpha1.DesktopTemplateSpec{BaseTemplate: "base-template", Namespaces: []string{"default"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = cl.ExportDesktopTemplates(&v1.ExportTemplatesRequest{Templates: []string{"child-template"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if annotations := bundle.Templates[0].GetAnnotations(); len(annotations) != 1 || annotations["team"] != "qa" {
+		t.Error("Expected cluster-specific annotations to be left out, got:", annotations)
+	}
+	if err := cl.ImportDesktopTemplates(&v1alpha1.ImportTemplateBundleRequest{
+		Bundle:        bundle,
+		TemplateNames: map[string]string{"child-template": "child-copy", "base-template": "missing-template"},
+	}); err == nil || !strings.Contains(err.Error(), "missing-template") {
+		t.Error("Expected error importing a template with a missing base, got:", err)
+	}
+	if _, err := cl.GetDesktopTemplate("child-copy"); err == nil {
+		t.Error("Expected nothing to be imported when a base template is missing")
+	}
+
+	// flattened templates carry the configuration of their bases
+	bundle, err = cl.ExportDesktopTemplates(&v1.ExportTemplatesRequest{Templates: []string{"child-template"}, Flatten: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec := bundle.Templates[0].Spec; spec.BaseTemplate != "" || spec.Image != "base-image" {
+		t.Error("Expected base template to be merged into the export, got:", spec.BaseTemplate, spec.Image)
+	}
+	if err := cl.ImportDesktopTemplates(&v1alpha1.ImportTemplateBundleRequest{
+		Bundle:        bundle,
+		TemplateNames: map[string]string{"child-template": "child-copy"},
+		Namespaces:    map[string]string{"default": "imported"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := cl.GetDesktopTemplate("child-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(imported.Spec.Namespaces, []string{"imported"}) {
+		t.Error("Expected imported template namespaces to be remapped, got:", imported.Spec.Namespaces)
+	}
+}
+
+// TestConfigReload tests applying VDICluster changes at runtime.
+func TestConfigReload(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	if _, err := cl.ReloadServerConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.App = &v1alpha1.AppConfig{CORSEnabled: true}
+	d := &desktopAPI{clusterName: "test-cluster", client: fake.NewFakeClientWithScheme(scheme, cluster)}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-cluster"}}
+
+	if err := d.handleClusterUpdate(req); err != nil {
+		t.Fatal(err)
+	}
+	if !d.CORSEnabled() {
+		t.Error("Expected CORS to be enabled")
+	}
+
+	// removed fields should not linger from the previous configuration
+	cluster.Spec.App = nil
+	if err := d.client.Update(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.handleClusterUpdate(req); err != nil {
+		t.Fatal(err)
+	}
+	if d.CORSEnabled() {
+		t.Error("Expected CORS to be disabled")
+	}
+
+	// backend swaps should leave the current configuration in place
+	cluster.Spec.App = &v1alpha1.AppConfig{CORSEnabled: true}
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{
+		LDAPAuth: &v1alpha1.LDAPConfig{URL: "ldap://ldap.example.com:389"},
+	}
+	if err := d.client.Update(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.handleClusterUpdate(req); err != nil {
+		t.Fatal(err)
+	}
+	if d.CORSEnabled() || d.vdiCluster.GetAuthBackend() != v1alpha1.AuthBackendLocal {
+		t.Error("Expected configuration to be unchanged until restart")
+	}
+}
+
+// TestAccessLog tests the access log middleware.
+func TestAccessLog(t *testing.T) {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Spec.App = &v1alpha1.AppConfig{
+		AccessLog: &v1alpha1.AccessLogConfig{Enabled: true, SampleRate: 2},
+	}
+	d := &desktopAPI{vdiCluster: cluster}
+
+	var user string
+	handler := d.accessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setAccessLogUser(r, "admin")
+		user = r.Context().Value(accessLogContextKey{}).(*accessLogEntry).user
+		w.Write([]byte("hello"))
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/whoami", nil))
+	if user != "admin" {
+		t.Error("Expected user to be set on the access log entry, got:", user)
+	}
+	if rr.Body.String() != "hello" {
+		t.Error("Expected response to pass through, got:", rr.Body.String())
+	}
+
+	// probes should be excluded by default
+	if !d.accessLogExcluded(httptest.NewRequest(http.MethodGet, "/api/readyz", nil)) {
+		t.Error("Expected readiness probes to be excluded")
+	}
+
+	// errors are always logged, only half of successful requests should be
+	for i := 0; i < 4; i++ {
+		if !d.sampleAccessLog(http.StatusForbidden) {
+			t.Error("Expected failed request to be logged")
+		}
+	}
+	var sampled int
+	for i := 0; i < 4; i++ {
+		if d.sampleAccessLog(http.StatusOK) {
+			sampled++
+		}
+	}
+	if sampled != 2 {
+		t.Error("Expected 2 out of 4 requests to be logged, got:", sampled)
+	}
+}
+
+// TestTracing tests the tracing middleware.
+func TestTracing(t *testing.T) {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Spec.App = &v1alpha1.AppConfig{
+		Tracing: &v1alpha1.TracingConfig{Endpoint: "http://127.0.0.1:4318"},
+	}
+	d := &desktopAPI{vdiCluster: cluster}
+
+	var sc tracing.SpanContext
+	var proxied http.Header
+	handler := mux.NewRouter()
+	handler.Use(d.tracingMiddleware)
+	handler.PathPrefix("/api/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc = tracing.SpanContextFromContext(r.Context())
+		proxied = http.Header{}
+		tracing.Inject(r.Context(), proxied)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// the parent is not sampled so nothing is exported
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	req := httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+	req.Header.Set(tracing.TraceParentHeader, parent)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Error("Expected response to pass through, got:", rr.Code)
+	}
+	if !sc.IsValid() || sc.TraceParent()[:35] != parent[:35] {
+		t.Error("Expected request to continue the caller's trace, got:", sc.TraceParent())
+	}
+	if proxied.Get(tracing.TraceParentHeader) != sc.TraceParent() {
+		t.Error("Expected the request span to be propagated, got:", proxied.Get(tracing.TraceParentHeader))
+	}
+
+	// probes should not be traced
+	sc = tracing.SpanContext{}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/readyz", nil))
+	if sc.IsValid() {
+		t.Error("Expected readiness probes to not be traced")
+	}
+
+	// nothing is traced when tracing is disabled
+	cluster.Spec.App.Tracing = nil
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if sc.IsValid() {
+		t.Error("Expected requests to not be traced when tracing is disabled")
+	}
+}
+
+type testAuditBackend struct{ events []*audit.Event }
+
+func (b *testAuditBackend) Name() string { return "test" }
+
+func (b *testAuditBackend) Write(event *audit.Event) error {
+	b.events = append(b.events, event)
+	return nil
+}
+
+func (b *testAuditBackend) Close() error { return nil }
+
+// TestAuditLog tests the audit log middleware.
+func TestAuditLog(t *testing.T) {
+	d := &desktopAPI{vdiCluster: &v1alpha1.VDICluster{}, audit: audit.NewLogger("test-server", nil)}
+
+	// enabling the audit log should set the configured backend
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Spec.App = &v1alpha1.AppConfig{
+		Audit: &v1alpha1.AuditLogConfig{Backend: v1alpha1.AuditLogStdout},
+	}
+	if err := d.syncAuditLog(cluster); err != nil {
+		t.Fatal(err)
+	}
+	if backend := d.audit.Backend(); backend == nil || backend.Name() != "stdout" {
+		t.Fatal("Expected stdout backend to be configured, got:", backend)
+	}
+
+	backend := &testAuditBackend{}
+	d.audit.SetBackend(backend)
+
+	handler := d.auditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setAuditUser(r, "admin")
+		d.auditLog(&AuditResult{
+			Allowed: false,
+			Actions: []*v1.APIAction{
+				{Verb: v1.VerbUpdate, ResourceType: v1.ResourceRoles, ResourceName: "test-role"},
+			},
+			UserSession: &v1.JWTClaims{User: &v1.VDIUser{Name: "admin"}},
+			Request:     r,
+		})
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	req := httptest.NewRequest(http.MethodPut, "/api/roles/test-role", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// probes are never audited
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/healthz", nil))
+
+	if len(backend.events) != 1 {
+		t.Fatal("Expected one audit event, got:", len(backend.events))
+	}
+	event := backend.events[0]
+	if event.User != "admin" || event.Verb != string(v1.VerbUpdate) || event.Resource != string(v1.ResourceRoles) ||
+		event.ResourceName != "test-role" || event.Decision != audit.DecisionDenied ||
+		event.Status != http.StatusForbidden || event.SourceIP != "10.0.0.1" || event.Hash == "" {
+		t.Errorf("Unexpected audit event: %+v", event)
+	}
+}
+
+// TestNodeDrain tests draining desktops from a node.
+func TestNodeDrain(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	// draining a node that doesn't exist should fail
+	if _, err := cl.DrainNode("missing-node", &v1.DrainNodeRequest{}); err == nil {
+		t.Error("Expected error draining missing node, got nil")
+	} else if !strings.Contains(err.Error(), "not found") {
+		t.Error("Expected node not found error, got:", err)
+	}
+
+	// invalid requests should fail
+	if _, err := cl.DrainNode("missing-node", &v1.DrainNodeRequest{Action: "reboot"}); err == nil {
+		t.Error("Expected error for invalid drain action, got nil")
+	}
+
+	// a node with no desktops is already drained
+	status, err := cl.GetNodeDrainStatus("test-node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Complete || len(status.Desktops) != 0 {
+		t.Error("Expected empty completed drain, got:", status)
+	}
+}
+
+// TestNodeDrainCordon tests that draining a node can also cordon it.
+func TestNodeDrainCordon(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	node := &corev1.Node{}
+	node.Name = "maintenance-node"
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, node)}
+
+	drainNode := func(cordon bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/nodes/maintenance-node/drain", nil)
+		req = mux.SetURLVars(req, map[string]string{"node": "maintenance-node"})
+		apiutil.SetRequestObject(req, &v1.DrainNodeRequest{Cordon: cordon})
+		rr := httptest.NewRecorder()
+		d.PostNodeDrain(rr, req)
+		return rr
+	}
+	isCordoned := func() bool {
+		found := &corev1.Node{}
+		if err := d.client.Get(context.TODO(), types.NamespacedName{Name: "maintenance-node"}, found); err != nil {
+			t.Fatal(err)
+		}
+		return found.Spec.Unschedulable
+	}
+
+	if rr := drainNode(false); rr.Code != http.StatusOK {
+		t.Fatal("Expected drain to succeed, got:", rr.Code, rr.Body.String())
+	}
+	if isCordoned() {
+		t.Error("Expected node to not be cordoned without the cordon option")
+	}
+	if rr := drainNode(true); rr.Code != http.StatusOK {
+		t.Fatal("Expected drain to succeed, got:", rr.Code, rr.Body.String())
+	}
+	if !isCordoned() {
+		t.Error("Expected node to be cordoned")
+	}
+}
+
+// TestListPagination tests paginating, filtering, and sorting list endpoints.
+func TestListPagination(t *testing.T) {
+	cl, close := mustNewClientWithClose(t)
+	defer close()
+
+	for _, name := range []string{"test-user-a", "test-user-b", "test-user-c"} {
+		if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+			Username: name,
+			Password: "test-password",
+			Roles:    []string{"test-cluster-launch-templates"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the full list should still be returned without a limit
+	users, err := cl.GetVDIUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 4 {
+		t.Fatal("Expected four users, got:", len(users))
+	}
+
+	// page through the users
+	users, meta, err := cl.ListVDIUsers(&v1.ListOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || users[0].GetName() != "admin" || meta.Total != 4 || meta.Continue == "" {
+		t.Fatal("Expected first page of three users, got:", users, meta)
+	}
+	users, meta, err = cl.ListVDIUsers(&v1.ListOptions{Limit: 3, Continue: meta.Continue})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].GetName() != "test-user-c" || meta.Continue != "" {
+		t.Error("Expected last page with one user, got:", users, meta)
+	}
+
+	// filter and sort the users
+	users, meta, err = cl.ListVDIUsers(&v1.ListOptions{
+		Limit:      10,
+		Descending: true,
+		Filters:    map[string]string{"roles": "LAUNCH-templates"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || users[0].GetName() != "test-user-c" || meta.Total != 3 {
+		t.Error("Expected three users in descending order, got:", users, meta)
+	}
+
+	// unknown fields should be rejected
+	if _, _, err := cl.ListVDIUsers(&v1.ListOptions{Limit: 10, SortBy: "password"}); err == nil {
+		t.Error("Expected error sorting on unknown field, got nil")
+	}
+	if _, _, err := cl.ListVDIRoles(&v1.ListOptions{Limit: 10, Filters: map[string]string{"image": "ubuntu"}}); err == nil {
+		t.Error("Expected error filtering roles on unknown field, got nil")
+	}
+
+	roles, meta, err := cl.ListVDIRoles(&v1.ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 1 || meta.Total != 2 {
+		t.Error("Expected one of two roles, got:", roles, meta)
+	}
+
+	sessions, err := cl.ListDesktopSessions(&v1.ListOptions{Limit: 10, Filters: map[string]string{"user": "admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions.Metadata == nil || sessions.Metadata.Total != 0 {
+		t.Error("Expected empty page of sessions, got:", sessions.Metadata)
+	}
+}
+
+// TestAPIKeys tests creating, using, and revoking API keys.
+func TestAPIKeys(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// keys must be restricted to at least one rule
+	if _, err := cl.CreateAPIKey(&v1.CreateAPIKeyRequest{Name: "ci"}); err == nil {
+		t.Error("Expected error creating key without rules")
+	}
+
+	created, err := cl.CreateAPIKey(&v1.CreateAPIKeyRequest{
+		Name: "ci",
+		Rules: []v1.Rule{
+			{Verbs: []v1.Verb{v1.VerbRead}, Resources: []v1.Resource{v1.ResourceTemplates}},
+		},
+		ExpiresIn: "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Token, v1.APIKeyTokenPrefix) || created.APIKey.ExpiresAt == 0 {
+		t.Error("Unexpected API key response:", created)
+	}
+
+	keys, err := cl.GetAPIKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].ID != created.APIKey.ID || keys[0].User != "admin" {
+		t.Error("Expected the created key to be listed, got:", keys)
+	}
+
+	keyClient, err := client.New(&client.Opts{URL: opts.URL, APIKey: created.Token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keyClient.Close()
+
+	// the key should act as the admin user within its rules
+	if _, err := keyClient.GetDesktopTemplates(); err != nil {
+		t.Error("Expected key to be able to read templates, got:", err)
+	}
+	if _, err := keyClient.GetVDIRoles(); err == nil {
+		t.Error("Expected key to be restricted from reading roles")
+	}
+	if _, err := keyClient.CreateAPIKey(&v1.CreateAPIKeyRequest{
+		Name:  "nested",
+		Rules: created.APIKey.Rules,
+	}); err == nil {
+		t.Error("Expected key to be unable to create other keys")
+	}
+
+	// a key with a bad secret should be rejected
+	badClient, err := client.New(&client.Opts{URL: opts.URL, APIKey: created.Token + "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := badClient.GetDesktopTemplates(); err == nil {
+		t.Error("Expected key with invalid secret to be rejected")
+	}
+
+	// keys stop working once the user's roles, including granted ones, require MFA
+	// they have not set up
+	if err := cl.CreateVDIRole(&v1.CreateRoleRequest{
+		Name:       "mfa-role",
+		Rules:      []v1.Rule{{Verbs: []v1.Verb{v1.VerbRead}, Resources: []v1.Resource{v1.ResourceTemplates}}},
+		RequireMFA: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.CreateVDIRoleGrant("mfa-role", &v1.CreateRoleGrantRequest{User: "admin", Duration: "1h"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyClient.GetDesktopTemplates(); err == nil {
+		t.Error("Expected key to be rejected while MFA is required")
+	}
+	if err := cl.DeleteVDIRoleGrant("mfa-role", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyClient.GetDesktopTemplates(); err != nil {
+		t.Error("Expected key to work again once MFA is no longer required, got:", err)
+	}
+
+	// revoking the key should remove access
+	if err := cl.DeleteAPIKey(created.APIKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyClient.GetDesktopTemplates(); err == nil {
+		t.Error("Expected revoked key to be rejected")
+	}
+	if _, err := cl.GetAPIKey(created.APIKey.ID); err == nil {
+		t.Error("Expected revoked key to not be found")
+	}
+}
+
+// TestImpersonation tests acting as another user with an impersonation token.
+func TestImpersonation(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+		Username: "test-user",
+		Password: "test-password",
+		Roles:    []string{"test-cluster-launch-templates"},
+	}); err != nil {
+		t.Fatal("Unable to create test user:", err)
+	}
+
+	if _, err := cl.Impersonate("admin", &v1.ImpersonateRequest{}); err == nil {
+		t.Error("Expected error impersonating yourself, got nil")
+	}
+	if _, err := cl.Impersonate("missing-user", &v1.ImpersonateRequest{}); err == nil {
+		t.Error("Expected error impersonating a user that does not exist, got nil")
+	}
+	if _, err := cl.Impersonate("test-user", &v1.ImpersonateRequest{Duration: "2h"}); err == nil {
+		t.Error("Expected error requesting a token longer than the max duration, got nil")
+	}
+
+	res, err := cl.Impersonate("test-user", &v1.ImpersonateRequest{Duration: "5m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.User.GetName() != "test-user" || res.Renewable || !res.Authorized ||
+		res.ExpiresAt > time.Now().Add(5*time.Minute).Unix() {
+		t.Error("Unexpected impersonation response:", res)
+	}
+
+	do := func(method, path, body string) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(method, opts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(TokenHeader, res.Token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, out
+	}
+
+	// the token acts as the user with their roles
+	resp, body := do(http.MethodGet, "/api/whoami", "")
+	user := &v1.VDIUser{}
+	if err := json.Unmarshal(body, user); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || user.GetName() != "test-user" ||
+		len(user.Roles) != 1 || user.Roles[0].GetName() != "test-cluster-launch-templates" {
+		t.Error("Expected to be acting as the test user, got:", resp.StatusCode, string(body))
+	}
+	if resp, _ := do(http.MethodGet, "/api/users", ""); resp.StatusCode != http.StatusForbidden {
+		t.Error("Expected the admin's permissions to not carry over, got:", resp.StatusCode)
+	}
+
+	// credentials cannot be created while impersonating
+	if resp, _ := do(http.MethodPost, "/api/apikeys", `{"name": "ci", "rules": [{"verbs": ["read"], "resources": ["templates"]}]}`); resp.StatusCode != http.StatusForbidden {
+		t.Error("Expected API keys to be denied while impersonating, got:", resp.StatusCode)
+	}
+
+	// the impersonator is recorded in audit events
+	req := httptest.NewRequest(http.MethodGet, "/api/templates", nil)
+	event := buildAuditEvent(req, &auditEntry{
+		user:         "test-user",
+		impersonator: "admin",
+		result: &AuditResult{
+			Allowed:     true,
+			UserSession: &v1.JWTClaims{User: user, Impersonator: "admin"},
+			Request:     req,
+		},
+	}, http.StatusOK)
+	if event.User != "test-user" || event.Impersonator != "admin" || !strings.HasSuffix(event.Message, "(IMPERSONATED BY admin)") {
+		t.Errorf("Unexpected audit event: %+v", event)
+	}
+
+	// users without the impersonate verb cannot impersonate others
+	userCl, err := client.New(&client.Opts{URL: opts.URL, Username: "test-user", Password: "test-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userCl.Close()
+	if _, err := userCl.Impersonate("admin", &v1.ImpersonateRequest{}); err == nil {
+		t.Error("Expected error impersonating without permission, got nil")
+	}
+}
+
+// TestRecordings tests listing and downloading session recordings.
+func TestRecordings(t *testing.T) {
+	objects := map[string]string{
+		"recordings/default/ubuntu/1600000000.ubuntu-abcde.admin.fbs":   "FBS 001.000\n",
+		"recordings/default/private/1600000001.private-fghij.admin.fbs": "FBS 001.000\n",
+		"recordings/other/ubuntu/1600000002.ubuntu-klmno.admin.fbs":     "FBS 001.000\n",
+		"recordings/default/ubuntu/README.txt":                          "",
+	}
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access-key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("list-type") == "2" {
+			var contents strings.Builder
+			for key, body := range objects {
+				if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+					contents.WriteString(fmt.Sprintf("<Contents><Key>%s</Key><Size>%d</Size></Contents>", key, len(body)))
+				}
+			}
+			fmt.Fprintf(w, "<ListBucketResult>%s<IsTruncated>false</IsTruncated></ListBucketResult>", contents.String())
+			return
+		}
+		body, ok := objects[strings.TrimPrefix(r.URL.Path, "/recordings-bucket/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer s3.Close()
+
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-creds", Namespace: v1.DefaultNamespace},
+		Data: map[string][]byte{
+			"AWS_ACCESS_KEY_ID":     []byte("access-key"),
+			"AWS_SECRET_ACCESS_KEY": []byte("secret-key"),
+		},
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Spec.Desktops = &v1alpha1.DesktopsConfig{
+		Recordings: &v1alpha1.RecordingsConfig{
+			Bucket:            "recordings-bucket",
+			Endpoint:          s3.URL,
+			Prefix:            "recordings",
+			CredentialsSecret: "s3-creds",
+		},
+	}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, secret)}
+	sess := &v1.JWTClaims{User: &v1.VDIUser{
+		Name: "auditor",
+		Roles: []*v1.VDIUserRole{{
+			Name: "auditor",
+			Rules: []v1.Rule{{
+				Verbs:            []v1.Verb{v1.VerbRead},
+				Resources:        []v1.Resource{v1.ResourceRecordings},
+				ResourcePatterns: []string{"ubuntu"},
+				Namespaces:       []string{"default"},
+			}},
+		}},
+	}}
+
+	// only recordings of templates and namespaces the user can read are listed
+	req := httptest.NewRequest(http.MethodGet, "/api/recordings", nil)
+	apiutil.SetRequestUserSession(req, sess)
+	rr := httptest.NewRecorder()
+	d.GetRecordings(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 listing recordings, got:", rr.Code, rr.Body.String())
+	}
+	recordings := make([]*v1.Recording, 0)
+	if err := json.Unmarshal(rr.Body.Bytes(), &recordings); err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 1 {
+		t.Fatal("Expected one recording, got:", len(recordings))
+	}
+	rec := recordings[0]
+	if rec.Namespace != "default" || rec.Template != "ubuntu" || rec.Desktop != "ubuntu-abcde" ||
+		rec.User != "admin" || rec.StartedAt != 1600000000 || rec.Size != 12 {
+		t.Errorf("Unexpected recording: %+v", rec)
+	}
+
+	// download the recording
+	req = httptest.NewRequest(http.MethodGet, "/api/recordings/default/ubuntu/"+rec.ID, nil)
+	req = mux.SetURLVars(req, map[string]string{"namespace": "default", "template": "ubuntu", "recording": rec.ID})
+	rr = httptest.NewRecorder()
+	d.GetRecording(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "FBS 001.000\n" {
+		t.Error("Expected recording contents, got:", rr.Code, rr.Body.String())
+	}
+
+	// missing recordings should return not found
+	req = mux.SetURLVars(req, map[string]string{"namespace": "default", "template": "ubuntu", "recording": "1600000009.ubuntu-abcde.admin"})
+	rr = httptest.NewRecorder()
+	d.GetRecording(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Error("Expected 404 for missing recording, got:", rr.Code)
+	}
+
+	// malformed IDs should be rejected
+	req = mux.SetURLVars(req, map[string]string{"namespace": "default", "template": "ubuntu", "recording": "../../etc"})
+	rr = httptest.NewRecorder()
+	d.GetRecording(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Error("Expected 400 for malformed recording ID, got:", rr.Code)
+	}
+}
+
+// TestUsageReport tests aggregating recorded usage into reports.
+func TestUsageReport(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	newUsage := func(date string, records ...*v1.UsageRecord) *corev1.ConfigMap {
+		day, err := time.Parse(v1.UsageDateFormat, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := day.Add(time.Hour)
+		sessions := make([]*v1.UsageSession, len(records))
+		for idx, rec := range records {
+			sessions[idx] = &v1.UsageSession{
+				Name: fmt.Sprintf("%s-%d", rec.Template, idx), Namespace: rec.Namespace, User: rec.User,
+				Roles: rec.Roles, Template: rec.Template, Start: day, End: &end, DurationHours: 1,
+			}
+		}
+		out, err := json.Marshal(&v1.DailyUsage{Date: date, Records: records, Sessions: sessions})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nn := cluster.GetUsageName(day)
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+			Data:       map[string]string{v1.UsageKey: string(out)},
+		}
+	}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme,
+		newUsage("2020-01-01",
+			&v1.UsageRecord{User: "alice", Roles: []string{"finance"}, Namespace: "default", Template: "ubuntu", DesktopHours: 1, CPUHours: 2},
+			&v1.UsageRecord{User: "bob", Roles: []string{"engineering"}, Namespace: "default", Template: "ubuntu", DesktopHours: 3, CPUHours: 6},
+		),
+		newUsage("2020-01-02",
+			&v1.UsageRecord{User: "alice", Roles: []string{"finance"}, Namespace: "default", Template: "arch", DesktopHours: 2, CPUHours: 4},
+		),
+		newUsage("2020-02-01",
+			&v1.UsageRecord{User: "alice", Roles: []string{"finance"}, Namespace: "default", Template: "arch", DesktopHours: 8, CPUHours: 8},
+		),
+	)}
+
+	getReport := func(query, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/reports/usage?"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		d.GetUsageReport(rr, req)
+		return rr
+	}
+
+	rr := getReport("groupBy=role&start=2020-01-01&end=2020-01-31", "")
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 for usage report, got:", rr.Code, rr.Body.String())
+	}
+	report := &v1.UsageReport{}
+	if err := json.Unmarshal(rr.Body.Bytes(), report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Sessions) != 3 {
+		t.Error("Expected 3 sessions in report, got:", len(report.Sessions))
+	}
+	if len(report.Groups) != 2 {
+		t.Fatal("Expected 2 groups in report, got:", len(report.Groups))
+	}
+	if group := report.Groups[0]; group.Key != "engineering" || group.DesktopHours != 3 || group.Sessions != 1 {
+		t.Errorf("Unexpected engineering usage: %+v", group)
+	}
+	if group := report.Groups[1]; group.Key != "finance" || group.DesktopHours != 3 || group.CPUHours != 6 || group.Sessions != 2 {
+		t.Errorf("Unexpected finance usage: %+v", group)
+	}
+
+	rr = getReport("groupBy=template&start=2020-01-01&end=2020-01-31", "text/csv")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != csvContentType {
+		t.Fatal("Expected CSV usage report, got:", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 || lines[1] != "2020-01-01,2020-01-31,arch,1,2.0000,4.0000,0.0000" {
+		t.Error("Unexpected CSV usage report:", rr.Body.String())
+	}
+
+	for _, query := range []string{
+		"groupBy=department",
+		"start=01-01-2020",
+		"start=2020-02-01&end=2020-01-01",
+		"start=2019-01-01&end=2020-12-31",
+	} {
+		if rr := getReport(query, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for query %s, got: %d", query, rr.Code)
+		}
+	}
+}
+
+// TestLoginRateLimit tests rate limiting and locking out repeated login attempts.
+func TestLoginRateLimit(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+
+	// repeated failures lock out the user, even with the correct password
+	badOpts := &client.Opts{URL: opts.URL, Username: opts.Username, Password: "wrong"}
+	for i := 0; i < 5; i++ {
+		if _, err := client.New(badOpts); err == nil || strings.Contains(err.Error(), "Too many") {
+			t.Fatal("Expected invalid credentials, got:", err)
+		}
+	}
+	if _, err := client.New(opts); err == nil || !strings.Contains(err.Error(), "Too many login attempts") {
+		t.Fatal("Expected user to be locked out, got:", err)
+	}
+
+	cfg := &v1alpha1.LoginRateLimitConfig{MaxAttemptsPerIP: 3, LockoutThreshold: 2, LockoutDuration: "1m", MaxLockoutDuration: "3m"}
+	limiter := newLoginLimiter()
+	now := time.Now()
+
+	// attempts from the same address are limited across usernames
+	for _, user := range []string{"a", "b", "c"} {
+		if retry, _ := limiter.allow(cfg, "10.0.0.1", user, now); retry != 0 {
+			t.Fatal("Expected attempt to be allowed for", user)
+		}
+	}
+	if retry, reason := limiter.allow(cfg, "10.0.0.1", "d", now); retry != time.Minute || reason != loginLimitedIP {
+		t.Error("Expected attempt to be limited by ip, got:", retry, reason)
+	}
+	if retry, _ := limiter.allow(cfg, "10.0.0.1", "d", now.Add(time.Minute)); retry != 0 {
+		t.Error("Expected attempts to be allowed after the window, got:", retry)
+	}
+
+	// lockouts back off exponentially up to the maximum, and usernames are case insensitive
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		if lockout := limiter.fail(cfg, "Spray", now); lockout != 0 {
+			t.Fatal("Expected first failure to not lock out user")
+		}
+		if lockout := limiter.fail(cfg, "spray", now); lockout != expected {
+			t.Errorf("Expected lockout of %s, got %s", expected, lockout)
+		}
+		if retry, reason := limiter.allow(cfg, "10.0.0.2", "SPRAY", now); retry != expected || reason != loginLimitedLockout {
+			t.Error("Expected attempt to be rejected by lockout, got:", retry, reason)
+		}
+		now = now.Add(expected)
+	}
+
+	// a successful login resets the backoff
+	limiter.succeed("spray")
+	limiter.fail(cfg, "spray", now)
+	if lockout := limiter.fail(cfg, "spray", now); lockout != time.Minute {
+		t.Error("Expected backoff to be reset after a successful login, got:", lockout)
+	}
+
+	// disabled limits allow everything
+	cfg.Disabled = true
+	if retry, _ := limiter.allow(cfg, "10.0.0.2", "spray", now); retry != 0 {
+		t.Error("Expected disabled limits to allow attempts, got:", retry)
+	}
+}
+
+// fakeMFAProvider is a push MFA provider that returns the statuses set on it.
+type fakeMFAProvider struct {
+	common.MFAProvider
+	statuses map[string]v1.MFAPushStatus
+}
+
+func (f *fakeMFAProvider) Push(user *v1.VDIUser, clientAddr string) (string, error) {
+	return "tx-" + user.Name, nil
+}
+
+func (f *fakeMFAProvider) Status(txID string) (v1.MFAPushStatus, error) {
+	return f.statuses[txID], nil
+}
+
+// TestPushMFA tests authorizing sessions with an MFA push.
+func TestPushMFA(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	provider := &fakeMFAProvider{statuses: map[string]v1.MFAPushStatus{"tx-test-user": v1.MFAPushPending}}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster), mfaProvider: provider}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.secrets.WriteSecret(v1.JWTSecretKey, []byte("supersecret")); err != nil {
+		t.Fatal(err)
+	}
+
+	session := func(user, id string) *v1.JWTClaims {
+		claims := &v1.JWTClaims{User: &v1.VDIUser{Name: user}}
+		claims.Id = id
+		return claims
+	}
+	sendPush := func(user, id string) *v1.MFAPushResponse {
+		req := httptest.NewRequest(http.MethodPost, "/api/authorize/push", nil)
+		apiutil.SetRequestUserSession(req, session(user, id))
+		rr := httptest.NewRecorder()
+		d.PostAuthorizePush(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatal("Expected push to be sent, got:", rr.Code, rr.Body.String())
+		}
+		res := &v1.MFAPushResponse{}
+		if err := json.Unmarshal(rr.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	authorize := func(user, id, transaction string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/authorize", nil)
+		apiutil.SetRequestUserSession(req, session(user, id))
+		apiutil.SetRequestObject(req, &v1.AuthorizeRequest{Transaction: transaction})
+		rr := httptest.NewRecorder()
+		d.PostAuthorize(rr, req)
+		return rr
+	}
+
+	// unauthorized tokens tell the client to use a push
+	rr := httptest.NewRecorder()
+	d.checkMFAAndReturnJWT(rr, httptest.NewRequest(http.MethodPost, "/api/login", nil), &v1.AuthResult{User: &v1.VDIUser{Name: "test-user"}}, "")
+	res := &v1.SessionResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Authorized || res.MFAMethod != v1.MFAMethodPush {
+		t.Fatal("Expected push MFA to be required, got:", res.Authorized, res.MFAMethod)
+	}
+
+	push := sendPush("test-user", "session-1")
+	if push.Status != v1.MFAPushPending || push.Transaction == "" {
+		t.Fatal("Expected pending push transaction, got:", push)
+	}
+
+	// OTPs are not accepted and transactions are bound to the user they were sent to
+	if rr := authorize("test-user", "session-1", ""); rr.Code != http.StatusBadRequest {
+		t.Error("Expected request without a transaction to fail, got:", rr.Code)
+	}
+	if rr := authorize("other-user", "session-1", push.Transaction); rr.Code != http.StatusForbidden {
+		t.Error("Expected transaction for another user to be rejected, got:", rr.Code)
+	}
+	if rr := authorize("test-user", "session-2", push.Transaction); rr.Code != http.StatusForbidden {
+		t.Error("Expected transaction for another session to be rejected, got:", rr.Code)
+	}
+
+	if rr := authorize("test-user", "session-1", push.Transaction); rr.Code != http.StatusAccepted {
+		t.Error("Expected pending push to return accepted, got:", rr.Code, rr.Body.String())
+	}
+	provider.statuses["tx-test-user"] = v1.MFAPushApproved
+	if rr := authorize("test-user", "session-2", push.Transaction); rr.Code != http.StatusForbidden {
+		t.Error("Expected approved transaction for another session to be rejected, got:", rr.Code)
+	}
+	rr = authorize("test-user", "session-1", push.Transaction)
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected approved push to authorize the session, got:", rr.Code, rr.Body.String())
+	}
+	res = &v1.SessionResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Authorized || res.Token == "" {
+		t.Error("Expected an authorized token, got:", res)
+	}
+
+	// transactions cannot be replayed once used
+	if rr := authorize("test-user", "session-1", push.Transaction); rr.Code != http.StatusForbidden {
+		t.Error("Expected used transaction to be rejected, got:", rr.Code)
+	}
+
+	// including against other replicas
+	replica := &desktopAPI{vdiCluster: cluster, client: d.client, mfaProvider: provider}
+	replica.secrets = secrets.GetSecretEngine(cluster)
+	if err := replica.secrets.Setup(replica.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/authorize", nil)
+	apiutil.SetRequestUserSession(req, session("test-user", "session-1"))
+	apiutil.SetRequestObject(req, &v1.AuthorizeRequest{Transaction: push.Transaction})
+	rr = httptest.NewRecorder()
+	replica.PostAuthorize(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Error("Expected used transaction to be rejected by another replica, got:", rr.Code)
+	}
+
+	provider.statuses["tx-test-user"] = v1.MFAPushDenied
+	if rr := authorize("test-user", "session-3", sendPush("test-user", "session-3").Transaction); rr.Code != http.StatusForbidden {
+		t.Error("Expected denied push to be rejected, got:", rr.Code)
+	}
+
+	// users the provider allows without a push are bound to their session too
+	allowed, err := d.signPushTransaction(session("test-user", "session-4"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr := authorize("test-user", "session-5", allowed); rr.Code != http.StatusForbidden {
+		t.Error("Expected allowed transaction for another session to be rejected, got:", rr.Code)
+	}
+	if rr := authorize("test-user", "session-4", allowed); rr.Code != http.StatusOK {
+		t.Error("Expected allowed transaction to authorize its session, got:", rr.Code, rr.Body.String())
+	}
+	if rr := authorize("test-user", "session-4", allowed); rr.Code != http.StatusForbidden {
+		t.Error("Expected allowed transaction to only be used once, got:", rr.Code)
+	}
+}
+
+// TestRoleRequireMFA tests that users with a role requiring MFA must enroll and
+// authorize with an OTP before their session is authorized.
+func TestRoleRequireMFA(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if err := cl.CreateVDIRole(&v1.CreateRoleRequest{
+		Name:       "mfa-role",
+		RequireMFA: true,
+		Rules:      []v1.Rule{{Verbs: []v1.Verb{v1.VerbRead}, Resources: []v1.Resource{v1.ResourceTemplates}, ResourcePatterns: []string{".*"}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+		Username: "mfa-required",
+		Password: "mfa-password",
+		Roles:    []string{"mfa-role"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client cannot log in until MFA is set up
+	userOpts := &client.Opts{URL: opts.URL, Username: "mfa-required", Password: "mfa-password"}
+	if _, err := client.New(userOpts); err == nil || !strings.Contains(err.Error(), "not been set up") {
+		t.Fatal("Expected error logging in without MFA set up, got:", err)
+	}
+
+	var token string
+	do := func(method, path, body string, out interface{}) int {
+		t.Helper()
+		req, err := http.NewRequest(method, opts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(TokenHeader, token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	session := &v1.SessionResponse{}
+	if code := do(http.MethodPost, "/api/login", `{"username": "mfa-required", "password": "mfa-password"}`, session); code != http.StatusOK {
+		t.Fatal("Expected login to succeed, got:", code)
+	}
+	if session.Authorized || !session.MFAEnrollmentRequired {
+		t.Fatal("Expected unauthorized session requiring enrollment, got:", session)
+	}
+	token = session.Token
+
+	// the unauthorized token can only be used to enroll the user
+	if code := do(http.MethodGet, "/api/templates", "", nil); code != http.StatusForbidden {
+		t.Error("Expected unauthorized token to be denied, got:", code)
+	}
+	if code := do(http.MethodGet, "/api/users/admin/mfa", "", nil); code != http.StatusForbidden {
+		t.Error("Expected enrollment of another user to be denied, got:", code)
+	}
+	if code := do(http.MethodPost, "/api/authorize", `{"otp": "123456"}`, nil); code != http.StatusForbidden {
+		t.Error("Expected authorizing without MFA set up to be denied, got:", code)
+	}
+	mfa := &v1.MFAResponse{}
+	if code := do(http.MethodPut, "/api/users/mfa-required/mfa", `{"enabled": true}`, mfa); code != http.StatusOK {
+		t.Fatal("Expected to enroll in MFA, got:", code)
+	}
+	uri, err := url.Parse(mfa.ProvisioningURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	totp := gotp.NewDefaultTOTP(uri.Query().Get("secret"))
+	if code := do(http.MethodPut, "/api/users/mfa-required/mfa/verify", fmt.Sprintf(`{"otp": %q}`, totp.Now()), nil); code != http.StatusOK {
+		t.Fatal("Expected to verify MFA, got:", code)
+	}
+
+	// once verified, MFA cannot be changed without authorizing
+	if code := do(http.MethodPut, "/api/users/mfa-required/mfa", `{"enabled": false}`, nil); code != http.StatusForbidden {
+		t.Error("Expected changing verified MFA to be denied, got:", code)
+	}
+	session = &v1.SessionResponse{}
+	if code := do(http.MethodPost, "/api/authorize", fmt.Sprintf(`{"otp": %q}`, totp.Now()), session); code != http.StatusOK {
+		t.Fatal("Expected to authorize with an OTP, got:", code)
+	}
+	if !session.Authorized {
+		t.Error("Expected an authorized session, got:", session)
+	}
+
+	// the client can log in with an OTP now
+	userOpts.OTPFunc = func() (string, error) { return totp.Now(), nil }
+	userCl, err := client.New(userOpts)
+	if err != nil {
+		t.Fatal("Expected to log in with an OTP, got:", err)
+	}
+	userCl.Close()
+}
+
+// TestRoleInheritance tests resolving rules inherited from other roles.
+func TestRoleInheritance(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	newRole := func(name string, verb v1.Verb, inherits ...string) *v1alpha1.VDIRole {
+		role := &v1alpha1.VDIRole{Inherits: inherits}
+		role.Name = name
+		role.Labels = map[string]string{v1.RoleClusterRefLabel: cluster.GetName()}
+		if verb != "" {
+			role.Rules = []v1.Rule{{Verbs: []v1.Verb{verb}, Resources: []v1.Resource{v1.ResourceTemplates}}}
+		}
+		return role
+	}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme,
+		newRole("base", v1.VerbRead),
+		newRole("mid", v1.VerbUse, "base"),
+		newRole("child", "", "mid", "deleted"),
+		newRole("cycle-a", v1.VerbCreate, "cycle-b"),
+		newRole("cycle-b", v1.VerbUpdate, "cycle-a"),
+	)}
+
+	roles, err := cluster.GetResolvedRoles(d.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verbs := make(map[string][]v1.Verb)
+	for _, role := range roles {
+		for _, rule := range role.GetRules() {
+			verbs[role.GetName()] = append(verbs[role.GetName()], rule.Verbs...)
+		}
+	}
+	// rules are inherited through every level, and missing roles are ignored
+	if !reflect.DeepEqual(verbs["child"], []v1.Verb{v1.VerbUse, v1.VerbRead}) {
+		t.Error("Expected child to inherit rules from mid and base, got:", verbs["child"])
+	}
+	if !reflect.DeepEqual(verbs["base"], []v1.Verb{v1.VerbRead}) {
+		t.Error("Expected base to keep only its own rules, got:", verbs["base"])
+	}
+	// cycles only combine the rules of the roles involved
+	if !reflect.DeepEqual(verbs["cycle-a"], []v1.Verb{v1.VerbCreate, v1.VerbUpdate}) {
+		t.Error("Expected cycle-a to have the rules of both roles, got:", verbs["cycle-a"])
+	}
+
+	// new roles must inherit from roles that exist, and not from themselves
+	if err := d.validateRoleInheritance(newRole("new", "", "mid")); err != nil {
+		t.Error("Expected valid inheritance, got:", err)
+	}
+	if err := d.validateRoleInheritance(newRole("new", "", "nope")); err == nil {
+		t.Error("Expected error inheriting from a role that does not exist")
+	}
+	if err := d.validateRoleInheritance(newRole("base", "", "child")); err == nil {
+		t.Error("Expected error for role inheriting from itself through another role")
+	}
+
+	// escalation checks see the rules a role would inherit
+	rules, err := d.getResolvedRules(newRole("new", "", "mid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Error("Expected new role to resolve to 2 rules, got:", rules)
+	}
+	user := &v1.VDIUser{Roles: []*v1.VDIUserRole{{Name: "reader", Rules: newRole("reader", v1.VerbRead).Rules}}}
+	for _, rule := range rules {
+		if rule.Verbs[0] == v1.VerbUse && user.IncludesRule(rule, NewResourceGetter(d)) {
+			t.Error("Expected inherited use rule to not be included in a read-only user's rules")
+		}
+	}
+}
+
+// TestRoleEvaluation tests evaluating permissions for users and roles.
+func TestRoleEvaluation(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	launch := &v1.EvaluatePermissionRequest{
+		Role:      "test-cluster-launch-templates",
+		Verb:      v1.VerbLaunch,
+		Resource:  v1.ResourceTemplates,
+		Name:      "ubuntu",
+		Namespace: "default",
+	}
+	res, err := cl.EvaluateVDIPermission(launch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Allowed || res.Role != launch.Role || res.Rule == nil || !reflect.DeepEqual(res.Rule.Namespaces, []string{"default"}) {
+		t.Errorf("Expected launch to be allowed by the launch-templates rule, got: %+v", res)
+	}
+
+	launch.Namespace = "other"
+	res, err = cl.EvaluateVDIPermission(launch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.Rule != nil || res.Reason == "" {
+		t.Errorf("Expected launch in another namespace to be denied with a reason, got: %+v", res)
+	}
+
+	res, err = cl.EvaluateVDIPermission(&v1.EvaluatePermissionRequest{User: "admin", Verb: v1.VerbDelete, Resource: v1.ResourceUsers, Name: "someone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Allowed || res.Role != "test-cluster-admin" || !reflect.DeepEqual(res.EvaluatedRoles, []string{"test-cluster-admin"}) {
+		t.Errorf("Expected admin to be allowed by the admin role, got: %+v", res)
+	}
+
+	// roles that don't name any data loss prevention verbs grant them with use
+	for name, verbs := range map[string][]v1.Verb{
+		"legacy-role": {v1.VerbUse, v1.VerbLaunch},
+		"dlp-role":    {v1.VerbUse, v1.VerbLaunch, v1.VerbClipboardIn},
+	} {
+		if err := cl.CreateVDIRole(&v1.CreateRoleRequest{
+			Name:  name,
+			Rules: []v1.Rule{{Verbs: verbs, Resources: []v1.Resource{v1.ResourceTemplates}, ResourcePatterns: []string{".*"}}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		role    string
+		verb    v1.Verb
+		allowed bool
+	}{
+		{"legacy-role", v1.VerbFileDownload, true},
+		{"legacy-role", v1.VerbClipboardOut, true},
+		{"dlp-role", v1.VerbClipboardIn, true},
+		{"dlp-role", v1.VerbFileDownload, false},
+		{"dlp-role", v1.VerbClipboardOut, false},
+	} {
+		res, err := cl.EvaluateVDIPermission(&v1.EvaluatePermissionRequest{Role: tc.role, Verb: tc.verb, Resource: v1.ResourceTemplates, Name: "ubuntu"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != tc.allowed {
+			t.Errorf("Expected %s allowed to be %v for %s, got: %+v", tc.verb, tc.allowed, tc.role, res)
+		}
+	}
+
+	// escalation checks compare the verbs roles are granted with use
+	if err := cl.CreateVDIRole(&v1.CreateRoleRequest{
+		Name: "role-creator",
+		Rules: []v1.Rule{
+			{Verbs: []v1.Verb{v1.VerbCreate}, Resources: []v1.Resource{v1.ResourceRoles}, ResourcePatterns: []string{".*"}},
+			{Verbs: []v1.Verb{v1.VerbUse, v1.VerbClipboardIn}, Resources: []v1.Resource{v1.ResourceTemplates}, ResourcePatterns: []string{".*"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+		Username: "role-creator",
+		Password: "test-password",
+		Roles:    []string{"role-creator"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	userCl, err := client.New(&client.Opts{URL: opts.URL, Username: "role-creator", Password: "test-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userCl.Close()
+	if err := userCl.CreateVDIRole(&v1.CreateRoleRequest{
+		Name:  "use-only",
+		Rules: []v1.Rule{{Verbs: []v1.Verb{v1.VerbUse}, Resources: []v1.Resource{v1.ResourceTemplates}, ResourcePatterns: []string{".*"}}},
+	}); err == nil || !strings.Contains(err.Error(), elevateDenyReason) {
+		t.Error("Expected role granting file transfers and clipboard-out with use to be denied, got:", err)
+	}
+	if err := userCl.CreateVDIRole(&v1.CreateRoleRequest{
+		Name:  "use-clipboard-in",
+		Rules: []v1.Rule{{Verbs: []v1.Verb{v1.VerbUse, v1.VerbClipboardIn}, Resources: []v1.Resource{v1.ResourceTemplates}, ResourcePatterns: []string{".*"}}},
+	}); err != nil {
+		t.Error("Expected role with the user's own template verbs to be allowed, got:", err)
+	}
+
+	for _, req := range []*v1.EvaluatePermissionRequest{
+		{Role: "missing-role", Verb: v1.VerbRead, Resource: v1.ResourceTemplates},
+		{User: "missing-user", Verb: v1.VerbRead, Resource: v1.ResourceTemplates},
+		{User: "admin", Role: launch.Role, Verb: v1.VerbRead, Resource: v1.ResourceTemplates},
+		{User: "admin", Resource: v1.ResourceTemplates},
+	} {
+		if _, err := cl.EvaluateVDIPermission(req); err == nil {
+			t.Errorf("Expected error evaluating %+v", req)
+		}
+	}
+}
+
+// TestSessionQuotas tests enforcing per-role session quotas.
+func TestSessionQuotas(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	newDesktop := func(name, template string) *v1alpha1.Desktop {
+		desktop := &v1alpha1.Desktop{}
+		desktop.Name = name
+		desktop.Namespace = "default"
+		desktop.Labels = cluster.GetUserDesktopLabels("quota-user")
+		desktop.Spec.Template = template
+		return desktop
+	}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme,
+		newDesktop("desktop-1", "ubuntu"),
+		newDesktop("desktop-2", "arch"),
+	)}
+	user := &v1.VDIUser{
+		Name: "quota-user",
+		Roles: []*v1.VDIUserRole{
+			{Name: "role-1", MaxSessions: 5, MaxSessionsPerTemplate: 1},
+			{Name: "role-2", MaxSessions: 3},
+		},
+	}
+
+	if !hasSessionQuotas(user) {
+		t.Error("Expected user to have session quotas")
+	}
+	if max := user.GetMaxSessions(); max != 3 {
+		t.Error("Expected lowest max sessions of 3, got:", max)
+	}
+
+	// one more session from a template not in use is allowed
+	if err := d.checkSessionQuotas(user, "fedora"); err != nil {
+		t.Error("Expected session to be allowed, got:", err)
+	}
+
+	// another session from a template in use is not
+	if err := d.checkSessionQuotas(user, "ubuntu"); !errors.IsQuotaExceededError(err) {
+		t.Error("Expected quota exceeded error, got:", err)
+	}
+
+	// neither is any session once the total is reached
+	user.Roles[1].MaxSessions = 2
+	if err := d.checkSessionQuotas(user, "fedora"); !errors.IsQuotaExceededError(err) {
+		t.Error("Expected quota exceeded error, got:", err)
+	}
+
+	rr := httptest.NewRecorder()
+	returnSessionQuotaError(d.checkSessionQuotas(user, "fedora"), rr)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Error("Expected 429 for exceeded quota, got:", rr.Code)
+	}
+
+	// users without quotas are not checked
+	if hasSessionQuotas(&v1.VDIUser{Roles: []*v1.VDIUserRole{{Name: "unlimited"}}}) {
+		t.Error("Expected user to have no session quotas")
+	}
+}
+
+func TestTemplateCapacity(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	newDesktop := func(name string) *v1alpha1.Desktop {
+		desktop := &v1alpha1.Desktop{}
+		desktop.Name = name
+		desktop.Namespace = "default"
+		desktop.Spec.VDICluster = cluster.GetName()
+		desktop.Spec.Template = "ubuntu"
+		return desktop
+	}
+	running := newDesktop("running")
+	terminating := newDesktop("terminating")
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	failed := newDesktop("failed")
+	failed.Status.PodPhase = corev1.PodFailed
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster, running, terminating, failed)}
+	os.Setenv("POD_NAMESPACE", "default")
+	d.secrets = secrets.GetSecretEngine(cluster)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "ubuntu"
+	tmpl.Spec.MaxSessions = 2
+
+	// terminating and failed desktops do not count towards the capacity
+	if count, err := d.countTemplateSessions("ubuntu"); err != nil {
+		t.Fatal(err)
+	} else if count != 1 {
+		t.Error("Expected only the running desktop to be counted, got:", count)
+	}
+	if err := d.checkTemplateCapacity("user-1", tmpl); err != nil {
+		t.Error("Expected session to be allowed, got:", err)
+	}
+	if err := d.client.Create(context.TODO(), newDesktop("user-1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// users beyond the capacity are queued in the order they asked
+	for pos, user := range []string{"user-2", "user-3"} {
+		err := d.checkTemplateCapacity(user, tmpl)
+		if !errors.IsQuotaExceededError(err) {
+			t.Fatal("Expected quota exceeded error, got:", err)
+		}
+		if !strings.HasSuffix(err.Error(), fmt.Sprintf("%d ahead of you", pos)) {
+			t.Errorf("Expected %d ahead of %s, got: %s", pos, user, err)
+		}
+	}
+	rr := httptest.NewRecorder()
+	returnSessionQuotaError(d.checkTemplateCapacity("user-3", tmpl), rr)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Error("Expected 429 for reached capacity, got:", rr.Code)
+	}
+
+	// once a session ends only the user at the front of the queue is let in
+	if err := d.client.Delete(context.TODO(), running); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.checkTemplateCapacity("user-3", tmpl); !errors.IsQuotaExceededError(err) {
+		t.Error("Expected user-3 to still be queued, got:", err)
+	}
+	if err := d.checkTemplateCapacity("user-2", tmpl); err != nil {
+		t.Error("Expected user-2 to be allowed, got:", err)
+	}
+	if err := d.checkTemplateCapacity("user-3", tmpl); err != nil {
+		t.Error("Expected user-3 to be allowed once first in line, got:", err)
+	}
+}
+
+func TestSessionPlacement(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "jupyter"
+	tmpl.Spec.Resources.Limits = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+	}
+	running := &v1alpha1.Desktop{}
+	running.Name = "jupyter-1"
+	running.Namespace = "data-science"
+	running.Labels = cluster.GetUserDesktopLabels("analyst")
+	running.Spec.Template = "jupyter"
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, tmpl, running)}
+
+	user := &v1.VDIUser{
+		Name: "analyst",
+		Roles: []*v1.VDIUserRole{
+			{Name: "data-science", Placement: &v1.SessionPlacement{
+				Namespaces:   []string{"data-science"},
+				NodeSelector: map[string]string{"pool": "data-science"},
+				MaxCPU:       "4",
+				MaxMemory:    "12Gi",
+			}},
+			{Name: "everyone"},
+		},
+	}
+
+	if !user.AllowsSessionNamespace("data-science") || user.AllowsSessionNamespace("default") {
+		t.Error("Expected sessions to be fenced into the data-science namespace")
+	}
+	selector, err := getSessionNodeSelector(user, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selector["pool"] != "data-science" {
+		t.Error("Expected node selector from role, got:", selector)
+	}
+
+	// the template's node selector cannot conflict with the role's
+	gpuTmpl := tmpl.DeepCopy()
+	gpuTmpl.Spec.GPU = &v1alpha1.GPUConfig{NodeSelector: map[string]string{"pool": "gpu"}}
+	if _, err := getSessionNodeSelector(user, gpuTmpl); err == nil {
+		t.Error("Expected error for conflicting node selectors")
+	}
+
+	// a second session fits in the cpu quota but not the memory quota
+	if !hasSessionQuotas(user) {
+		t.Error("Expected resource quotas to count as session quotas")
+	}
+	if err := d.checkSessionResources(user, tmpl, nil, "data-science"); !errors.IsQuotaExceededError(err) {
+		t.Error("Expected quota exceeded error, got:", err)
+	}
+	user.Roles[0].Placement.MaxMemory = "16Gi"
+	if err := d.checkSessionResources(user, tmpl, nil, "data-science"); err != nil {
+		t.Error("Expected session to be allowed, got:", err)
+	}
+
+	// resources requested through parameters count towards the quotas
+	paramTmpl := tmpl.DeepCopy()
+	paramTmpl.Spec.Parameters = []v1alpha1.DesktopTemplateParameter{{
+		Name:     "memory",
+		Type:     v1alpha1.ParameterQuantity,
+		Resource: corev1.ResourceMemory,
+	}}
+	if err := d.checkSessionResources(user, paramTmpl, map[string]string{"memory": "4Gi"}, "data-science"); err != nil {
+		t.Error("Expected session within the quota to be allowed, got:", err)
+	}
+	if err := d.checkSessionResources(user, paramTmpl, map[string]string{"memory": "10Gi"}, "data-science"); !errors.IsQuotaExceededError(err) {
+		t.Error("Expected quota exceeded error for memory parameter, got:", err)
+	}
+}
+
+// TestRefreshTokens tests storing provider refresh tokens alongside the ones
+// issued by kVDI.
+func TestRefreshTokens(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &v1.VDIUser{Name: "oidc-user"}
+	withProvider, err := d.generateRefreshToken(&v1.AuthResult{User: user, ProviderRefreshToken: "provider-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutProvider, err := d.generateRefreshToken(&v1.AuthResult{User: user})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	username, providerToken, err := d.lookupRefreshToken(withProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "oidc-user" || providerToken != "provider-token" {
+		t.Error("Expected user and provider token, got:", username, providerToken)
+	}
+	username, providerToken, err = d.lookupRefreshToken(withoutProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "oidc-user" || providerToken != "" {
+		t.Error("Expected user without provider token, got:", username, providerToken)
+	}
+
+	// refresh tokens can only be used once
+	if _, _, err := d.lookupRefreshToken(withProvider); err == nil {
+		t.Error("Expected error looking up used refresh token, got nil")
+	}
+}
+
+// fakeLogoutProvider is an auth provider that accepts logout tokens from a
+// static map of tokens to users.
+type fakeLogoutProvider struct {
+	common.AuthProvider
+	tokens map[string]string
+}
+
+func (f *fakeLogoutProvider) GetLogoutURL(*v1.VDIUser) (string, error) { return "", nil }
+
+func (f *fakeLogoutProvider) VerifyLogoutToken(token string) (string, error) {
+	username, ok := f.tokens[token]
+	if !ok {
+		return "", errors.New("invalid logout token")
+	}
+	return username, nil
+}
+
+func TestBackChannelLogout(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	d.revoked = newRevocationList(d.secrets)
+
+	post := func(token string, expectedCode int) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/logout/backchannel", strings.NewReader(url.Values{"logout_token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		d.PostBackChannelLogout(rr, req)
+		if rr.Code != expectedCode {
+			t.Fatal("Expected", expectedCode, "got:", rr.Code, rr.Body.String())
+		}
+		if !strings.Contains(rr.Header().Get("Cache-Control"), "no-store") {
+			t.Error("Expected response to not be cached, got:", rr.Header().Get("Cache-Control"))
+		}
+	}
+
+	// the provider does not support back-channel logout
+	d.auth = struct{ common.AuthProvider }{}
+	post("test-token", http.StatusNotFound)
+
+	d.auth = &fakeLogoutProvider{tokens: map[string]string{"test-token": "oidc-user", "unknown-token": ""}}
+	refreshToken, err := d.generateRefreshToken(&v1.AuthResult{User: &v1.VDIUser{Name: "oidc-user"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &v1.JWTClaims{User: &v1.VDIUser{Name: "oidc-user"}}
+	claims.Id = "test-id"
+	claims.IssuedAt = time.Now().Add(-time.Minute).Unix()
+	claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+
+	post("", http.StatusBadRequest)
+	post("bad-token", http.StatusBadRequest)
+	post("unknown-token", http.StatusOK)
+	if revoked, err := d.revoked.IsRevoked(claims); err != nil || revoked {
+		t.Fatal("Expected sessions to be untouched for an unknown user, got:", revoked, err)
+	}
+
+	post("test-token", http.StatusOK)
+	if revoked, err := d.revoked.IsRevoked(claims); err != nil || !revoked {
+		t.Error("Expected access tokens to be revoked, got:", revoked, err)
+	}
+	if _, _, err := d.lookupRefreshToken(refreshToken); err == nil {
+		t.Error("Expected refresh token to be revoked")
+	}
+}
+
+// TestSessionParameters tests launching desktops with template parameters.
+func TestSessionParameters(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "param-template"
+	tmpl.Spec.Parameters = []v1alpha1.DesktopTemplateParameter{
+		{Name: "resolution", Default: "1920x1080", Env: "DISPLAY_RESOLUTION"},
+		{Name: "cpus", Type: v1alpha1.ParameterInteger, Minimum: "1", Maximum: "4"},
+	}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, tmpl)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	os.Setenv("POD_NAMESPACE", "default")
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	startSession := func(params map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: &v1.VDIUser{
+			Name:  "param-user",
+			Roles: []*v1.VDIUserRole{{Name: "finance"}, {Name: "engineering"}},
+		}})
+		apiutil.SetRequestObject(req, &v1.CreateSessionRequest{Template: "param-template", Params: params})
+		rr := httptest.NewRecorder()
+		d.StartDesktopSession(rr, req)
+		return rr
+	}
+
+	// required parameters must be set, and values must be valid
+	for _, params := range []map[string]string{
+		nil,
+		{"cpus": "8"},
+		{"cpus": "two"},
+		{"cpus": "2", "unknown": "value"},
+	} {
+		if rr := startSession(params); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for params %v, got: %d", params, rr.Code)
+		}
+	}
+
+	rr := startSession(map[string]string{"cpus": "2"})
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 for valid params, got:", rr.Code, rr.Body.String())
+	}
+	resp := &v1.CreateSessionResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	desktop := &v1alpha1.Desktop{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: resp.Name, Namespace: resp.Namespace}, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if desktop.Spec.Parameters["cpus"] != "2" || desktop.Spec.Parameters["resolution"] != "1920x1080" {
+		t.Error("Expected chosen and default parameters on desktop, got:", desktop.Spec.Parameters)
+	}
+	if roles := desktop.GetUserRoles(); !reflect.DeepEqual(roles, []string{"finance", "engineering"}) {
+		t.Error("Expected user roles to be recorded on desktop, got:", roles)
+	}
+}
+
+// TestSessionEnv tests setting environment variables when starting sessions.
+func TestSessionEnv(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "env-template"
+	tmpl.Spec.SessionEnv = &v1alpha1.SessionEnvConfig{Allowed: []string{"GIT_*", "EDITOR", "USER"}}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, tmpl)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	os.Setenv("POD_NAMESPACE", "default")
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.secrets.WriteSecretMap(v1.UserSecretsSecretKey, map[string][]byte{
+		"env-user": []byte(`{"git-token": "secret"}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	verbs := []v1.Verb{v1.VerbLaunch}
+	startSession := func(env, secretEnv map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: &v1.VDIUser{
+			Name: "env-user",
+			Roles: []*v1.VDIUserRole{{
+				Name: "launcher",
+				Rules: []v1.Rule{{
+					Verbs:            verbs,
+					Resources:        []v1.Resource{v1.ResourceTemplates},
+					ResourcePatterns: []string{".*"},
+					Namespaces:       []string{v1.NamespaceAll},
+				}},
+			}},
+		}})
+		apiutil.SetRequestObject(req, &v1.CreateSessionRequest{Template: "env-template", Env: env, SecretEnv: secretEnv})
+		rr := httptest.NewRecorder()
+		d.StartDesktopSession(rr, req)
+		return rr
+	}
+
+	// setting environment variables requires the set-env verb
+	if rr := startSession(map[string]string{"EDITOR": "vim"}, nil); rr.Code != http.StatusForbidden {
+		t.Error("Expected 403 without set-env, got:", rr.Code)
+	}
+	verbs = append(verbs, v1.VerbSetEnv)
+
+	// only variables allowed by the template can be set, and secrets must exist
+	for _, req := range []struct{ env, secretEnv map[string]string }{
+		{env: map[string]string{"HOME": "/tmp"}},
+		{env: map[string]string{"USER": "root"}},
+		{secretEnv: map[string]string{"GIT_TOKEN": "missing"}},
+	} {
+		if rr := startSession(req.env, req.secretEnv); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %v, got: %d", req, rr.Code)
+		}
+	}
+
+	rr := startSession(map[string]string{"EDITOR": "vim"}, map[string]string{"GIT_TOKEN": "git-token"})
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 for allowed env, got:", rr.Code, rr.Body.String())
+	}
+	resp := &v1.CreateSessionResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	desktop := &v1alpha1.Desktop{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: resp.Name, Namespace: resp.Namespace}, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if desktop.Spec.Env["EDITOR"] != "vim" || desktop.Spec.SecretEnv["GIT_TOKEN"] != "git-token" {
+		t.Error("Expected env to be set on desktop, got:", desktop.Spec.Env, desktop.Spec.SecretEnv)
+	}
+}
+
+// TestTemplateCatalog tests that templates are listed per namespace and can't be
+// launched outside the namespaces they allow.
+func TestTemplateCatalog(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "team-a-desktop"
+	tmpl.Spec.Description = "A desktop for team a"
+	tmpl.Spec.Namespaces = []string{"team-a"}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, tmpl)}
+	user := &v1.VDIUser{
+		Name: "catalog-user",
+		Roles: []*v1.VDIUserRole{{
+			Name: "launcher",
+			Rules: []v1.Rule{{
+				Verbs:            []v1.Verb{v1.VerbLaunch},
+				Resources:        []v1.Resource{v1.ResourceTemplates},
+				ResourcePatterns: []string{".*"},
+				Namespaces:       []string{v1.NamespaceAll},
+			}},
+		}},
+	}
+
+	listTemplates := func(namespace string) []v1alpha1.DesktopTemplate {
+		req := httptest.NewRequest(http.MethodGet, "/api/templates?namespace="+namespace, nil)
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: user})
+		rr := httptest.NewRecorder()
+		d.GetDesktopTemplates(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatal("Expected 200 listing templates, got:", rr.Code, rr.Body.String())
+		}
+		tmpls := make([]v1alpha1.DesktopTemplate, 0)
+		if err := json.Unmarshal(rr.Body.Bytes(), &tmpls); err != nil {
+			t.Fatal(err)
+		}
+		return tmpls
+	}
+	if tmpls := listTemplates("team-a"); len(tmpls) != 1 || tmpls[0].Spec.Description != "A desktop for team a" {
+		t.Error("Expected the template to be listed with its description for team-a, got:", tmpls)
+	}
+	if tmpls := listTemplates("team-b"); len(tmpls) != 0 {
+		t.Error("Expected no templates to be listed for team-b, got:", tmpls)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+	apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: user})
+	apiutil.SetRequestObject(req, &v1.CreateSessionRequest{Template: tmpl.Name, Namespace: "team-b"})
+	rr := httptest.NewRecorder()
+	d.StartDesktopSession(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Error("Expected 403 launching the template outside its namespaces, got:", rr.Code)
+	}
+}
+
+// TestTemplateRevisions tests listing and rolling back template revisions, and
+// pinning sessions to them.
+func TestTemplateRevisions(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "versioned-template"
+	tmpl.Generation = 1
+	tmpl.Spec = v1alpha1.DesktopTemplateSpec{Image: "image:1", Version: "1.0"}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, tmpl)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	os.Setenv("POD_NAMESPACE", "default")
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	d.recordTemplateRevision(tmpl)
+
+	// the fake client does not bump the generation on updates
+	tmpl.Generation = 2
+	tmpl.Spec = v1alpha1.DesktopTemplateSpec{Image: "image:2", Version: "2.0"}
+	if err := d.client.Update(context.TODO(), tmpl); err != nil {
+		t.Fatal(err)
+	}
+	d.recordTemplateRevision(tmpl)
+
+	templateRequest := func(method, path string, body interface{}) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		req = mux.SetURLVars(req, map[string]string{"template": tmpl.Name})
+		if body != nil {
+			apiutil.SetRequestObject(req, body)
+		}
+		return req
+	}
+
+	rr := httptest.NewRecorder()
+	d.GetDesktopTemplateRevisions(rr, templateRequest(http.MethodGet, "/api/templates/versioned-template/revisions", nil))
+	revs := make([]*v1alpha1.DesktopTemplateRevision, 0)
+	if err := json.Unmarshal(rr.Body.Bytes(), &revs); err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].Revision != 2 || !revs[0].Current || revs[1].Version != "1.0" || revs[1].Spec.Image != "image:1" {
+		t.Fatal("Expected both revisions newest first, got:", rr.Body.String())
+	}
+
+	// sessions can be pinned to a previous revision
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+	apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: &v1.VDIUser{Name: "pinned-user"}})
+	apiutil.SetRequestObject(req, &v1.CreateSessionRequest{Template: tmpl.Name, TemplateRevision: 1})
+	rr = httptest.NewRecorder()
+	d.StartDesktopSession(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 launching a pinned session, got:", rr.Code, rr.Body.String())
+	}
+	resp := &v1.CreateSessionResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	desktop := &v1alpha1.Desktop{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: resp.Name, Namespace: resp.Namespace}, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if desktop.Spec.TemplateRevision != 1 {
+		t.Error("Expected desktop to be pinned to revision 1, got:", desktop.Spec.TemplateRevision)
+	}
+
+	for _, rev := range []int64{2, 5} {
+		rr = httptest.NewRecorder()
+		d.PostDesktopTemplateRollback(rr, templateRequest(http.MethodPost, "/api/templates/versioned-template/rollback", &v1.RollbackTemplateRequest{Revision: rev}))
+		if rr.Code == http.StatusOK {
+			t.Errorf("Expected rolling back to revision %d to fail", rev)
+		}
+	}
+	rr = httptest.NewRecorder()
+	d.PostDesktopTemplateRollback(rr, templateRequest(http.MethodPost, "/api/templates/versioned-template/rollback", &v1.RollbackTemplateRequest{Revision: 1}))
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 rolling back, got:", rr.Code, rr.Body.String())
+	}
+	found := &v1alpha1.DesktopTemplate{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: tmpl.Name}, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Spec.Image != "image:1" || found.Spec.Version != "1.0" {
+		t.Error("Expected template to be rolled back to revision 1, got:", found.Spec)
+	}
+}
+
+// TestSessionEstimate tests estimating the resources and cost of sessions.
+func TestSessionEstimate(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "gpu-template"
+	tmpl.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")}
+	tmpl.Spec.GPU = &v1alpha1.GPUConfig{Count: 2}
+	tmpl.Spec.Parameters = []v1alpha1.DesktopTemplateParameter{
+		{Name: "cpus", Type: v1alpha1.ParameterQuantity, Default: "1", Resource: corev1.ResourceCPU},
+	}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, tmpl)}
+
+	estimate := func(req *v1.EstimateSessionRequest) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/templates/gpu-template/estimate", nil)
+		r = mux.SetURLVars(r, map[string]string{"template": tmpl.Name})
+		apiutil.SetRequestObject(r, req)
+		rr := httptest.NewRecorder()
+		d.PostDesktopTemplateEstimate(rr, r)
+		return rr
+	}
+
+	// without prices only the footprint is returned
+	rr := estimate(&v1.EstimateSessionRequest{Params: map[string]string{"cpus": "2"}})
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 estimating a session, got:", rr.Code, rr.Body.String())
+	}
+	resp := &v1.SessionEstimate{}
+	if err := json.Unmarshal(rr.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.CPU != 2 || resp.MemoryGiB != 4 || resp.GPUs != 2 || resp.Limits["nvidia.com/gpu"] != "2" {
+		t.Error("Expected footprint with chosen parameters and GPUs, got:", rr.Body.String())
+	}
+	if resp.Currency != "" || resp.CostPerHour != 0 || len(resp.Breakdown) != 0 {
+		t.Error("Expected no cost without configured prices, got:", rr.Body.String())
+	}
+
+	cluster.Spec.Billing = &v1alpha1.BillingConfig{Prices: &v1alpha1.PriceConfig{
+		PerHour: map[corev1.ResourceName]string{
+			corev1.ResourceCPU:    "0.5",
+			corev1.ResourceMemory: "0.25",
+			"nvidia.com/gpu":      "1.5",
+		},
+	}}
+	rr = estimate(&v1.EstimateSessionRequest{Params: map[string]string{"cpus": "2"}})
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 estimating a session, got:", rr.Code, rr.Body.String())
+	}
+	resp = &v1.SessionEstimate{}
+	if err := json.Unmarshal(rr.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Currency != "USD" || resp.CostPerHour != 5 || len(resp.Breakdown) != 3 {
+		t.Fatal("Expected priced estimate, got:", rr.Body.String())
+	}
+	if item := resp.Breakdown[2]; item.Resource != "nvidia.com/gpu" || item.Quantity != 2 || item.CostPerHour != 3 {
+		t.Error("Expected GPU cost in breakdown, got:", item)
+	}
+
+	// invalid parameters and prices are rejected
+	if rr := estimate(&v1.EstimateSessionRequest{Params: map[string]string{"cpus": "two"}}); rr.Code != http.StatusBadRequest {
+		t.Error("Expected 400 for invalid params, got:", rr.Code)
+	}
+	cluster.Spec.Billing.Prices.PerHour[corev1.ResourceCPU] = "cheap"
+	if rr := estimate(&v1.EstimateSessionRequest{}); rr.Code != http.StatusBadRequest {
+		t.Error("Expected 400 for invalid prices, got:", rr.Code)
+	}
+
+	// unknown templates are not found
+	d.client = fake.NewFakeClientWithScheme(scheme)
+	if rr := estimate(&v1.EstimateSessionRequest{}); rr.Code != http.StatusNotFound {
+		t.Error("Expected 404 for missing template, got:", rr.Code)
+	}
+}
+
+// TestTemplateGPUStatus tests the GPU availability reported for templates.
+func TestTemplateGPUStatus(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gpuTmpl := &v1alpha1.DesktopTemplate{}
+	gpuTmpl.Name = "gpu-template"
+	gpuTmpl.Spec.GPU = &v1alpha1.GPUConfig{
+		Count:        2,
+		NodeSelector: map[string]string{"gpu-model": "a100"},
+	}
+	childTmpl := &v1alpha1.DesktopTemplate{}
+	childTmpl.Name = "child-template"
+	childTmpl.Spec.BaseTemplate = "gpu-template"
+	plainTmpl := &v1alpha1.DesktopTemplate{}
+	plainTmpl.Name = "plain-template"
+
+	newNode := func(name string, gpus int64, labels map[string]string, taints ...corev1.Taint) *corev1.Node {
+		node := &corev1.Node{}
+		node.Name = name
+		node.Labels = labels
+		node.Spec.Taints = taints
+		node.Status.Allocatable = corev1.ResourceList{
+			"nvidia.com/gpu": *resource.NewQuantity(gpus, resource.DecimalSI),
+		}
+		node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
+		return node
+	}
+	a100 := map[string]string{"gpu-model": "a100"}
+	nodes := []*corev1.Node{
+		newNode("gpu-1", 4, a100, corev1.Taint{Key: "nvidia.com/gpu", Effect: corev1.TaintEffectNoSchedule}),
+		newNode("gpu-2", 2, a100),
+		// not enough gpus
+		newNode("gpu-3", 1, a100),
+		// wrong model
+		newNode("gpu-4", 4, map[string]string{"gpu-model": "t4"}),
+		// untolerated taint
+		newNode("gpu-5", 4, a100, corev1.Taint{Key: "dedicated", Effect: corev1.TaintEffectNoExecute}),
+	}
+	d := &desktopAPI{client: fake.NewFakeClientWithScheme(scheme, gpuTmpl, childTmpl, plainTmpl, nodes[0], nodes[1], nodes[2], nodes[3], nodes[4])}
+
+	tmpls, err := d.getAllDesktopTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.setGPUStatus(tmpls.Items); err != nil {
+		t.Fatal(err)
+	}
+	for _, tmpl := range tmpls.Items {
+		if tmpl.GetName() == "plain-template" {
+			if tmpl.Status.GPU != nil {
+				t.Error("Expected no GPU status for template without GPUs, got:", tmpl.Status.GPU)
+			}
+			continue
+		}
+		status := tmpl.Status.GPU
+		if status == nil {
+			t.Errorf("Expected GPU status for %s, got nil", tmpl.GetName())
+			continue
+		}
+		if !status.Schedulable || status.Nodes != 2 || status.Allocatable != 6 {
+			t.Errorf("Expected 2 schedulable nodes with 6 GPUs for %s, got: %+v", tmpl.GetName(), *status)
+		}
+	}
+
+	// cordoned nodes are not schedulable
+	for _, name := range []string{"gpu-1", "gpu-2"} {
+		node := &corev1.Node{}
+		if err := d.client.Get(context.TODO(), types.NamespacedName{Name: name}, node); err != nil {
+			t.Fatal(err)
+		}
+		node.Spec.Unschedulable = true
+		if err := d.client.Update(context.TODO(), node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/templates/gpu-template", nil)
+	req = mux.SetURLVars(req, map[string]string{"template": "gpu-template"})
+	rr := httptest.NewRecorder()
+	d.GetDesktopTemplate(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 retrieving template, got:", rr.Code, rr.Body.String())
+	}
+	tmpl := &v1alpha1.DesktopTemplate{}
+	if err := json.Unmarshal(rr.Body.Bytes(), tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Status.GPU == nil || tmpl.Status.GPU.Schedulable {
+		t.Error("Expected template to be unschedulable, got:", tmpl.Status.GPU)
+	}
+}
+
+func TestTemplateSecurityProfiles(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &corev1.Node{}
+	node.Name = "node-1"
+	node.Status.Conditions = []corev1.NodeCondition{{
+		Type:    corev1.NodeReady,
+		Status:  corev1.ConditionTrue,
+		Message: "kubelet is posting ready status",
+	}}
+	d := &desktopAPI{client: fake.NewFakeClientWithScheme(scheme, node)}
+
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "hardened-template"
+	if err := d.checkTemplateSecurityProfiles(tmpl); err != nil {
+		t.Error("Expected no error without security profiles, got:", err)
+	}
+
+	for _, profiles := range []*v1alpha1.SecurityProfilesConfig{
+		{Seccomp: "default"},
+		{Seccomp: "localhost/"},
+		{Seccomp: "localhost/../../etc/passwd"},
+		{AppArmor: "kvdi-desktop"},
+	} {
+		tmpl.Spec.SecurityProfiles = profiles
+		if err := d.checkTemplateSecurityProfiles(tmpl); err == nil {
+			t.Errorf("Expected error for malformed profiles %+v, got nil", *profiles)
+		}
+	}
+
+	// seccomp and selinux do not depend on the nodes
+	tmpl.Spec.SecurityProfiles = &v1alpha1.SecurityProfilesConfig{
+		Seccomp:  "localhost/profiles/kvdi-desktop.json",
+		AppArmor: "unconfined",
+		SELinux:  &corev1.SELinuxOptions{Level: "s0:c123,c456"},
+	}
+	if err := d.checkTemplateSecurityProfiles(tmpl); err != nil {
+		t.Error("Expected no error for valid profiles, got:", err)
+	}
+
+	// apparmor profiles require a node with apparmor enabled
+	tmpl.Spec.SecurityProfiles.AppArmor = "runtime/default"
+	if err := d.checkTemplateSecurityProfiles(tmpl); err == nil || !strings.Contains(err.Error(), "no nodes have AppArmor enabled") {
+		t.Error("Expected error for AppArmor without enabled nodes, got:", err)
+	}
+	node.Status.Conditions[0].Message = "kubelet is posting ready status. AppArmor enabled"
+	if err := d.client.Update(context.TODO(), node); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.checkTemplateSecurityProfiles(tmpl); err != nil {
+		t.Error("Expected no error with AppArmor enabled on a node, got:", err)
+	}
+}
+
+func TestTemplateTransferPolicy(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "regulated"
+	tmpl.Spec.Config = &v1alpha1.DesktopConfig{
+		AllowFileTransfer: true,
+		FileTransfer:      v1alpha1.FileTransferUpload,
+		Clipboard:         v1alpha1.ClipboardOneWayIn,
+	}
+	desktop := &v1alpha1.Desktop{}
+	desktop.Name = "regulated-abcde"
+	desktop.Namespace = "default"
+	desktop.Spec.Template = "regulated"
+	d := &desktopAPI{client: fake.NewFakeClientWithScheme(scheme, tmpl, desktop)}
+
+	user := &v1.VDIUser{
+		Name: "test-user",
+		Roles: []*v1.VDIUserRole{
+			{
+				Name: "test-role",
+				Rules: []v1.Rule{
+					{Verbs: []v1.Verb{v1.VerbAll}, Resources: []v1.Resource{v1.ResourceAll}, Namespaces: []string{v1.NamespaceAll}, ResourcePatterns: []string{".*"}},
+				},
+			},
+		},
+	}
+	request := func(method, path string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		req = mux.SetURLVars(req, map[string]string{"namespace": "default", "name": "regulated-abcde"})
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: user})
+		return req
+	}
+
+	// copying out of the desktop is disabled even though the user has the verb
+	req := request(http.MethodGet, "/api/desktops/ws/default/regulated-abcde/display?disableClipboardIn=true&username=admin")
+	if err := d.setDisplayOptions(req); err != nil {
+		t.Fatal(err)
+	}
+	query := req.URL.Query()
+	if query.Get(v1.DisableClipboardOutQueryParam) != "true" {
+		t.Error("Expected clipboard out to be disabled by the template")
+	}
+	if query.Get(v1.DisableClipboardInQueryParam) != "" {
+		t.Error("Expected client supplied clipboard parameter to be removed")
+	}
+	if query.Get(v1.UsernameQueryParam) != "test-user" {
+		t.Error("Expected username to be set to the requesting user, got:", query.Get(v1.UsernameQueryParam))
+	}
+
+	// only well-formed resume tokens are passed to the proxy
+	for token, valid := range map[string]bool{
+		"Zm9vYmFyYmF6cXV4MTIz": true,
+		"short":                false,
+		"has/slash-0123456789": false,
+	} {
+		req = request(http.MethodGet, "/api/desktops/ws/default/regulated-abcde/display?resume="+url.QueryEscape(token))
+		if err := d.setDisplayOptions(req); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.URL.Query().Get(v1.ResumeTokenQueryParam); (got == token) != valid {
+			t.Errorf("Expected resume token %q valid=%v, got: %q", token, valid, got)
+		}
+	}
+
+	// downloads are refused before reaching the desktop
+	rr := httptest.NewRecorder()
+	d.GetDownloadDesktopFile(rr, request(http.MethodGet, "/api/desktops/fs/default/regulated-abcde/get/secret.txt"))
+	if rr.Code != http.StatusForbidden {
+		t.Error("Expected 403 for download from an upload-only template, got:", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	d.GetStatDesktopFile(rr, request(http.MethodGet, "/api/desktops/fs/default/regulated-abcde/stat/."))
+	if rr.Code != http.StatusForbidden {
+		t.Error("Expected 403 for browsing an upload-only template, got:", rr.Code)
+	}
+
+	// the proxy is told to enforce the policies as well
+	args := strings.Join(tmpl.GetDesktopProxyContainer().Args, " ")
+	if !strings.Contains(args, "--clipboard one-way-in") || !strings.Contains(args, "--file-transfer upload") {
+		t.Error("Expected policies in kvdi-proxy args, got:", args)
+	}
+	if !tmpl.FileTransferEnabled() {
+		t.Error("Expected file transfer to be enabled for an upload-only template")
+	}
+}
+
+func TestShadowing(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme)}
+
+	shadowUser := &v1.VDIUser{
+		Name: "helpdesk",
+		Roles: []*v1.VDIUserRole{
+			{
+				Name: "helpdesk",
+				Rules: []v1.Rule{
+					{Verbs: []v1.Verb{v1.VerbShadow}, Resources: []v1.Resource{v1.ResourceTemplates}, ResourcePatterns: []string{".*"}},
+				},
+			},
+		},
+	}
+
+	shadow := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/desktops/ws/default/ubuntu-abcde/shadow"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"namespace": "default", "name": "ubuntu-abcde"})
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: shadowUser})
+		rr := httptest.NewRecorder()
+		d.GetWebsockifyShadow(rr, req)
+		return rr
+	}
+
+	// interactive shadowing requires the user to be able to use the desktop
+	if rr := shadow("?interactive=true"); rr.Code != http.StatusForbidden {
+		t.Error("Expected 403 for interactive shadow without use permissions, got:", rr.Code)
+	}
+	// view-only shadowing should get as far as looking up the desktop
+	if rr := shadow(""); rr.Code != http.StatusNotFound {
+		t.Error("Expected 404 for shadowing a missing desktop, got:", rr.Code)
+	}
+
+	// the owner override used by the display routes should not apply
+	if RouterGrantRequirements["/api/desktops/ws/{namespace}/{name}/shadow"]["GET"].OverrideFunc != nil {
+		t.Error("Expected no override function for the shadow route")
+	}
+}
+
+func TestSessionInvites(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+
+	now := time.Now().Unix()
+	invites := []*v1.SessionInvite{
+		{ID: "view", User: "guest", Mode: v1.SessionInviteView, CreatedBy: "owner", ExpiresAt: now + 3600},
+		{ID: "expired", User: "guest", Mode: v1.SessionInviteControl, CreatedBy: "owner", ExpiresAt: now - 1},
+	}
+	raw, err := json.Marshal(invites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desktop := &v1alpha1.Desktop{}
+	desktop.Name = "ubuntu-abcde"
+	desktop.Namespace = "default"
+	desktop.Annotations = map[string]string{v1.SessionInvitesAnnotation: string(raw)}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, desktop)}
+
+	// expired invites are dropped
+	found, err := getSessionInvites(desktop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != "view" {
+		t.Fatal("Expected only the unexpired invite, got:", found)
+	}
+
+	request := func(method, id, query, user string) *http.Request {
+		req := httptest.NewRequest(method, "/api/desktops/ws/default/ubuntu-abcde/invites/"+id+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"namespace": "default", "name": "ubuntu-abcde", "invite": id})
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: &v1.VDIUser{Name: user}})
+		return req
+	}
+
+	// only the invited user can use an unexpired invite
+	for _, tc := range []struct {
+		id, user string
+		allowed  bool
+	}{
+		{"view", "guest", true},
+		{"view", "someone-else", false},
+		{"expired", "guest", false},
+		{"missing", "guest", false},
+	} {
+		allowed, _, err := denyUninvitedUser(d, &v1.VDIUser{Name: tc.user}, request(http.MethodGet, tc.id, "", tc.user))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != tc.allowed {
+			t.Errorf("Expected allowed to be %v for invite %s and user %s", tc.allowed, tc.id, tc.user)
+		}
+	}
+
+	// view invites do not allow sending input
+	rr := httptest.NewRecorder()
+	d.GetWebsockifyInvite(rr, request(http.MethodGet, "view", "?interactive=true", "guest"))
+	if rr.Code != http.StatusForbidden {
+		t.Error("Expected 403 for interactive attach with a view invite, got:", rr.Code)
+	}
+
+	// revoking an invite removes it from the desktop
+	rr = httptest.NewRecorder()
+	d.DeleteSessionInvite(rr, request(http.MethodDelete, "view", "", "owner"))
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 revoking invite, got:", rr.Code, rr.Body.String())
+	}
+	updated := &v1alpha1.Desktop{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: desktop.Name, Namespace: desktop.Namespace}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := updated.Annotations[v1.SessionInvitesAnnotation]; ok {
+		t.Error("Expected invites annotation to be removed, got:", updated.Annotations)
+	}
+	rr = httptest.NewRecorder()
+	d.DeleteSessionInvite(rr, request(http.MethodDelete, "view", "", "owner"))
+	if rr.Code != http.StatusNotFound {
+		t.Error("Expected 404 revoking a missing invite, got:", rr.Code)
+	}
+}
+
+func TestSessionViewTokens(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	desktop := &v1alpha1.Desktop{}
+	desktop.Name = "ubuntu-abcde"
+	desktop.Namespace = "default"
+	other := &v1alpha1.Desktop{}
+	other.Name = "ubuntu-fghij"
+	other.Namespace = "default"
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, desktop, other)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	os.Setenv("POD_NAMESPACE", "default")
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.secrets.WriteSecret(v1.JWTSecretKey, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, name, path string, session *v1.JWTClaims, vars map[string]string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		if vars == nil {
+			vars = make(map[string]string)
+		}
+		vars["namespace"], vars["name"] = "default", name
+		req = mux.SetURLVars(req, vars)
+		apiutil.SetRequestUserSession(req, session)
+		return req
+	}
+	owner := &v1.JWTClaims{User: &v1.VDIUser{Name: "owner"}}
+
+	req := request(http.MethodPost, desktop.Name, "/api/sessions/default/ubuntu-abcde/viewtokens", owner, nil)
+	apiutil.SetRequestObject(req, &v1.CreateViewTokenRequest{Label: "dashboard"})
+	rr := httptest.NewRecorder()
+	d.PostSessionViewToken(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 creating view token, got:", rr.Code, rr.Body.String())
+	}
+	created := &v1.CreateViewTokenResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Label != "dashboard" || created.CreatedBy != "owner" {
+		t.Error("Unexpected view token metadata:", created.ViewToken)
+	}
+
+	// the token carries no roles and references the view token
+	keys, err := d.getJWTKeys(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := apiutil.DecodeAndVerifyJWT(keys, created.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ViewToken != created.ID || len(session.User.Roles) != 0 {
+		t.Error("Unexpected view token claims:", session)
+	}
+
+	// the token is only valid for the desktop it was created for
+	for _, tc := range []struct {
+		name    string
+		allowed bool
+	}{
+		{desktop.Name, true},
+		{other.Name, false},
+	} {
+		allowed, _, err := denyWithoutViewToken(d, session.User, request(http.MethodGet, tc.name, "/api/desktops/ws/default/"+tc.name+"/viewtoken", session, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != tc.allowed {
+			t.Errorf("Expected allowed to be %v for desktop %s", tc.allowed, tc.name)
+		}
+	}
+
+	// listing does not return the token itself
+	rr = httptest.NewRecorder()
+	d.GetSessionViewTokens(rr, request(http.MethodGet, desktop.Name, "/api/sessions/default/ubuntu-abcde/viewtokens", owner, nil))
+	if strings.Contains(rr.Body.String(), created.Token) {
+		t.Error("Expected token to be omitted from listing")
+	}
+	tokens := &v1.ViewTokensResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), tokens); err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens.ViewTokens) != 1 || tokens.ViewTokens[0].ID != created.ID {
+		t.Fatal("Expected the created view token, got:", tokens.ViewTokens)
+	}
+
+	// revoked tokens are no longer accepted
+	rr = httptest.NewRecorder()
+	d.DeleteSessionViewToken(rr, request(http.MethodDelete, desktop.Name, "/api/sessions/default/ubuntu-abcde/viewtokens/"+created.ID, owner, map[string]string{"viewtoken": created.ID}))
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 revoking view token, got:", rr.Code, rr.Body.String())
+	}
+	if allowed, _, err := denyWithoutViewToken(d, session.User, request(http.MethodGet, desktop.Name, "/api/desktops/ws/default/ubuntu-abcde/viewtoken", session, nil)); err != nil {
+		t.Fatal(err)
+	} else if allowed {
+		t.Error("Expected revoked view token to be denied")
+	}
+
+	// regular sessions are not view tokens
+	if allowed, _, _ := denyWithoutViewToken(d, owner.User, request(http.MethodGet, desktop.Name, "/api/desktops/ws/default/ubuntu-abcde/viewtoken", owner, nil)); allowed {
+		t.Error("Expected session without a view token to be denied")
+	}
+}
+
+func TestSessionHandoff(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desktop := &v1alpha1.Desktop{}
+	desktop.Name = "ubuntu-abcde"
+	desktop.Namespace = "default"
+	desktop.Spec.User = "owner"
+	d := &desktopAPI{vdiCluster: &v1alpha1.VDICluster{}, client: fake.NewFakeClientWithScheme(scheme, desktop)}
+
+	request := func(method, path, user string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		req = mux.SetURLVars(req, map[string]string{"namespace": "default", "name": "ubuntu-abcde"})
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: &v1.VDIUser{Name: user}})
+		return req
+	}
+	redeem := func(code, user string) bool {
+		t.Helper()
+		query := url.Values{v1.HandoffQueryParam: []string{code}}
+		redeemed, err := d.redeemSessionHandoff(request(http.MethodGet, "/api/desktops/ws/default/ubuntu-abcde/display?"+query.Encode(), user))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return redeemed
+	}
+
+	// only the owner can hand off their desktop
+	rr := httptest.NewRecorder()
+	d.PostSessionHandoff(rr, request(http.MethodPost, "/api/sessions/default/ubuntu-abcde/handoff", "someone-else"))
+	if rr.Code != http.StatusForbidden {
+		t.Error("Expected 403 handing off another user's desktop, got:", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	d.PostSessionHandoff(rr, request(http.MethodPost, "/api/sessions/default/ubuntu-abcde/handoff", "owner"))
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 creating handoff, got:", rr.Code, rr.Body.String())
+	}
+	created := &v1.SessionHandoffResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), created); err != nil {
+		t.Fatal(err)
+	}
+	if len(created.Code) != 9 || created.ExpiresAt <= time.Now().Unix() {
+		t.Fatal("Unexpected handoff response:", created)
+	}
+
+	// only a hash of the code is stored
+	updated := &v1alpha1.Desktop{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: desktop.Name, Namespace: desktop.Namespace}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if raw := updated.Annotations[v1.SessionHandoffAnnotation]; raw == "" || strings.Contains(raw, created.Code) {
+		t.Error("Expected only a hash of the code to be stored, got:", raw)
+	}
+
+	// the code can only be redeemed once, by the owner
+	if redeem(created.Code, "someone-else") {
+		t.Error("Expected another user to be unable to redeem the code")
+	}
+	if redeem("ABCD-EFGH", "owner") {
+		t.Error("Expected an incorrect code to be rejected")
+	}
+	if !redeem(strings.ToLower(strings.Replace(created.Code, "-", "", 1)), "owner") {
+		t.Error("Expected the code to be redeemed regardless of case or separators")
+	}
+	if redeem(created.Code, "owner") {
+		t.Error("Expected the code to be rejected after it was redeemed")
+	}
+
+	// expired codes are rejected
+	defer func(ttl time.Duration) { sessionHandoffTTL = ttl }(sessionHandoffTTL)
+	sessionHandoffTTL = -time.Second
+	rr = httptest.NewRecorder()
+	d.PostSessionHandoff(rr, request(http.MethodPost, "/api/sessions/default/ubuntu-abcde/handoff", "owner"))
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 creating handoff, got:", rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), created); err != nil {
+		t.Fatal(err)
+	}
+	if redeem(created.Code, "owner") {
+		t.Error("Expected an expired code to be rejected")
+	}
+
+	// an invalid code is refused before taking the display
+	rr = httptest.NewRecorder()
+	d.GetWebsockify(rr, request(http.MethodGet, "/api/desktops/ws/default/ubuntu-abcde/display?handoff=ABCD-EFGH", "owner"))
+	if rr.Code != http.StatusForbidden {
+		t.Error("Expected 403 picking up the display with an invalid code, got:", rr.Code)
+	}
+}
+
+// TestSessionLogs tests streaming the container logs of a desktop session.
+// hijackableRecorder is a ResponseRecorder whose connection can be hijacked.
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (h *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.conn, bufio.NewReadWriter(bufio.NewReader(h.conn), bufio.NewWriter(h.conn)), nil
+}
+
+func TestDisplayLockTakeover(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("POD_NAME", "test-server")
+	os.Setenv("POD_NAMESPACE", "default")
+	pod := &corev1.Pod{}
+	pod.Name = "test-server"
+	pod.Namespace = "default"
+	c := fake.NewFakeClientWithScheme(scheme, pod)
+
+	interval := lockCheckInterval
+	lockCheckInterval = 100 * time.Millisecond
+	defer func() { lockCheckInterval = interval }()
+
+	newRequest := func(resume string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/desktops/ws/default/test-desktop/display?resume="+resume, nil)
+		apiutil.SetRequestUserSession(r, &v1.JWTClaims{User: &v1.VDIUser{Name: "test-user"}})
+		return r
+	}
+	key := getDisplayTakeoverKey(newRequest("test-resume-token-1234"))
+	if key == "" || key == getDisplayTakeoverKey(newRequest("test-resume-token-5678")) {
+		t.Fatal("Expected distinct takeover keys for different resume tokens, got:", key)
+	}
+	if key := getDisplayTakeoverKey(newRequest("bad")); key != "" {
+		t.Error("Expected no takeover key for an invalid resume token, got:", key)
+	}
+
+	l := lock.New(c, "display-test", -1).WithTakeoverKey(key)
+	if err := l.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	local, remote := net.Pipe()
+	defer remote.Close()
+	w, stop := watchLock(&hijackableRecorder{ResponseRecorder: httptest.NewRecorder(), conn: local}, l)
+	defer stop()
+	if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the connection stays open while the lock is held
+	remote.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	if _, err := remote.Read(make([]byte, 1)); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatal("Expected the connection to stay open, got:", err)
+	}
+
+	// a reconnect with the same resume token takes over the lock and closes the
+	// old connection
+	if err := lock.New(c, "display-test", -1).WithTakeoverKey(key).Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	remote.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := remote.Read(make([]byte, 1)); err != io.EOF {
+		t.Error("Expected the old connection to be closed, got:", err)
+	}
+
+	// once the desktop is dialed, only that side is closed so the proxy can close
+	// the client connection cleanly
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	l = lock.New(c, "display-test", -1).WithTakeoverKey(key)
+	if err := l.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	local, remote = net.Pipe()
+	defer remote.Close()
+	w, stop = watchLock(&hijackableRecorder{ResponseRecorder: httptest.NewRecorder(), conn: local}, l)
+	defer stop()
+	if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.(*lockWatchingResponseWriter).dialBackend("tcp", listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	desktopConn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer desktopConn.Close()
+	if err := lock.New(c, "display-test", -1).WithForcedTakeover().Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	desktopConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := desktopConn.Read(make([]byte, 1)); err != io.EOF {
+		t.Error("Expected the connection to the desktop to be closed, got:", err)
+	}
+	remote.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, err := remote.Read(make([]byte, 1)); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Error("Expected the client connection to be left for the proxy to close, got:", err)
+	}
+}
+
+func TestSessionLogs(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{}
+	pod.Name = "ubuntu-abcde"
+	pod.Namespace = "default"
+	pod.Spec.InitContainers = []corev1.Container{{Name: "userdata"}}
+	pod.Spec.Containers = []corev1.Container{{Name: "desktop"}, {Name: "kvdi-proxy"}}
+	d := &desktopAPI{vdiCluster: &v1alpha1.VDICluster{}, client: fake.NewFakeClientWithScheme(scheme, pod)}
+
+	defer func(orig func(context.Context, *corev1.Pod, *corev1.PodLogOptions) (io.ReadCloser, error)) {
+		streamPodLogs = orig
+	}(streamPodLogs)
+	var requested []*corev1.PodLogOptions
+	streamPodLogs = func(ctx context.Context, pod *corev1.Pod, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+		requested = append(requested, opts)
+		switch opts.Container {
+		case "userdata":
+			return ioutil.NopCloser(strings.NewReader("running first-boot script\ndone")), nil
+		case "desktop":
+			return ioutil.NopCloser(strings.NewReader("starting display server\n")), nil
+		}
+		return nil, errors.New("container is waiting to start")
+	}
+
+	request := func(name, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/sessions/default/"+name+"/logs?"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"namespace": "default", "name": name})
+		rr := httptest.NewRecorder()
+		d.GetSessionLogs(rr, req)
+		return rr
+	}
+
+	// every container is included in pod order, with errors written inline
+	rr := request(pod.Name, "tailLines=100")
+	expected := "[userdata] running first-boot script\n[userdata] done\n[desktop] starting display server\n" +
+		"[kvdi-proxy] Error retrieving logs: container is waiting to start\n"
+	if rr.Code != http.StatusOK || rr.Body.String() != expected {
+		t.Errorf("Unexpected logs response: %d %q", rr.Code, rr.Body.String())
+	}
+	if len(requested) != 3 || requested[0].TailLines == nil || *requested[0].TailLines != 100 || requested[0].Follow {
+		t.Error("Unexpected pod log options:", requested)
+	}
+
+	// single containers are not prefixed
+	requested = nil
+	rr = request(pod.Name, "container=desktop&follow=true")
+	if rr.Code != http.StatusOK || rr.Body.String() != "starting display server\n" {
+		t.Errorf("Unexpected logs response: %d %q", rr.Code, rr.Body.String())
+	}
+	if len(requested) != 1 || !requested[0].Follow {
+		t.Error("Unexpected pod log options:", requested)
+	}
+
+	// errors are returned when no logs could be retrieved
+	if rr := request(pod.Name, "container=kvdi-proxy"); rr.Code != http.StatusBadRequest {
+		t.Error("Expected 400 when no logs could be retrieved, got:", rr.Code)
+	}
+	if rr := request(pod.Name, "container=missing"); rr.Code != http.StatusBadRequest {
+		t.Error("Expected 400 for a container that does not exist, got:", rr.Code)
+	}
+	if rr := request(pod.Name, "follow=true&previous=true"); rr.Code != http.StatusBadRequest {
+		t.Error("Expected 400 following previous logs, got:", rr.Code)
+	}
+	if rr := request("missing", ""); rr.Code != http.StatusNotFound {
+		t.Error("Expected 404 for a session that does not exist, got:", rr.Code)
+	}
+}
+
+func TestSessionSnapshots(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fake client needs to know about the snapshot list kind
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: k8sutil.VolumeSnapshotAPIGroup, Version: "v1beta1", Kind: "VolumeSnapshotList"}, &unstructured.UnstructuredList{})
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "ubuntu"
+	desktop := &v1alpha1.Desktop{}
+	desktop.Name = "ubuntu-abcde"
+	desktop.Namespace = "default"
+	desktop.Labels = cluster.GetUserDesktopLabels("owner")
+	desktop.Spec = v1alpha1.DesktopSpec{VDICluster: cluster.Name, Template: "ubuntu", User: "owner"}
+	pvc := &corev1.PersistentVolumeClaim{}
+	pvc.Name = cluster.GetUserdataVolumeName("owner")
+	pvc.Namespace = "default"
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, tmpl, desktop, pvc)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	os.Setenv("POD_NAMESPACE", "default")
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotSession := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sessions/default/ubuntu-abcde/snapshots", nil)
+		req = mux.SetURLVars(req, map[string]string{"namespace": "default", "name": "ubuntu-abcde"})
+		rr := httptest.NewRecorder()
+		d.PostSessionSnapshot(rr, req)
+		return rr
+	}
+	startSession := func(user, snapshot string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: &v1.VDIUser{Name: user}})
+		apiutil.SetRequestObject(req, &v1.CreateSessionRequest{Template: "ubuntu", Snapshot: snapshot})
+		rr := httptest.NewRecorder()
+		d.StartDesktopSession(rr, req)
+		return rr
+	}
+
+	// snapshots require a snapshot class and userdata volumes
+	if rr := snapshotSession(); rr.Code != http.StatusBadRequest {
+		t.Error("Expected 400 when snapshots are not configured, got:", rr.Code)
+	}
+	cluster.Spec.UserDataSpec = &corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+	}
+	cluster.Spec.Desktops = &v1alpha1.DesktopsConfig{SnapshotClass: "csi-snapclass"}
+
+	rr := snapshotSession()
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 taking snapshot, got:", rr.Code, rr.Body.String())
+	}
+	created := &v1.DesktopSnapshot{}
+	if err := json.Unmarshal(rr.Body.Bytes(), created); err != nil {
+		t.Fatal(err)
+	}
+	if created.User != "owner" || created.Template != "ubuntu" || created.Desktop != "ubuntu-abcde" {
+		t.Error("Expected snapshot to reference the desktop it was taken from, got:", created)
+	}
+
+	// snapshots are listed for their owner only
+	listSnapshots := func(user string) []*v1.DesktopSnapshot {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/"+user+"/snapshots", nil)
+		req = mux.SetURLVars(req, map[string]string{"user": user})
+		rr := httptest.NewRecorder()
+		d.GetUserSnapshots(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatal("Expected 200 listing snapshots, got:", rr.Code, rr.Body.String())
+		}
+		snapshots := make([]*v1.DesktopSnapshot, 0)
+		if err := json.Unmarshal(rr.Body.Bytes(), &snapshots); err != nil {
+			t.Fatal(err)
+		}
+		return snapshots
+	}
+	if snapshots := listSnapshots("owner"); len(snapshots) != 1 || snapshots[0].Name != created.Name {
+		t.Error("Expected the owner's snapshot to be listed, got:", snapshots)
+	}
+	if snapshots := listSnapshots("someone-else"); len(snapshots) != 0 {
+		t.Error("Expected no snapshots for another user, got:", snapshots)
+	}
+
+	// sessions can't be restored from other users' snapshots, snapshots that aren't
+	// ready, or while the user's volume is in use
+	if rr := startSession("someone-else", created.Name); rr.Code != http.StatusNotFound {
+		t.Error("Expected 404 restoring another user's snapshot, got:", rr.Code)
+	}
+	if rr := startSession("owner", created.Name); rr.Code != http.StatusBadRequest {
+		t.Error("Expected 400 restoring a snapshot that isn't ready, got:", rr.Code)
+	}
+	snapshot, err := d.getUserSnapshot("owner", "default", created.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot.Object["status"] = map[string]interface{}{"readyToUse": true}
+	if err := d.client.Update(context.TODO(), snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if rr := startSession("owner", created.Name); rr.Code != http.StatusBadRequest {
+		t.Error("Expected 400 restoring while the userdata volume is in use, got:", rr.Code)
+	}
+	if err := d.client.Delete(context.TODO(), pvc); err != nil {
+		t.Fatal(err)
+	}
+	rr = startSession("owner", created.Name)
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 restoring from snapshot, got:", rr.Code, rr.Body.String())
+	}
+	resp := &v1.CreateSessionResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	restored := &v1alpha1.Desktop{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: resp.Name, Namespace: resp.Namespace}, restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Spec.Snapshot != created.Name {
+		t.Error("Expected desktop to be restored from the snapshot, got:", restored.Spec.Snapshot)
+	}
+
+	// snapshots can only be deleted by their owner
+	deleteSnapshot := func(user string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/api/users/"+user+"/snapshots/default/"+created.Name, nil)
+		req = mux.SetURLVars(req, map[string]string{"user": user, "namespace": "default", "name": created.Name})
+		rr := httptest.NewRecorder()
+		d.DeleteUserSnapshot(rr, req)
+		return rr.Code
+	}
+	if code := deleteSnapshot("someone-else"); code != http.StatusNotFound {
+		t.Error("Expected 404 deleting another user's snapshot, got:", code)
+	}
+	if code := deleteSnapshot("owner"); code != http.StatusOK {
+		t.Error("Expected 200 deleting snapshot, got:", code)
+	}
+	if snapshots := listSnapshots("owner"); len(snapshots) != 0 {
+		t.Error("Expected snapshot to be deleted, got:", snapshots)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	d := &desktopAPI{events: newEventBroker()}
+
+	user := &v1.VDIUser{
+		Name: "test-user",
+		Roles: []*v1.VDIUserRole{
+			{
+				Name: "test-role",
+				Rules: []v1.Rule{
+					{Verbs: []v1.Verb{v1.VerbRead}, Resources: []v1.Resource{v1.ResourceTemplates}, Namespaces: []string{"team-a"}, ResourcePatterns: []string{".*"}},
+					{Verbs: []v1.Verb{v1.VerbRead}, Resources: []v1.Resource{v1.ResourceUsers}, ResourcePatterns: []string{".*"}},
+				},
+			},
+		},
+	}
+
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiutil.SetRequestUserSession(r, &v1.JWTClaims{User: user})
+		(&websocket.Server{
+			Handshake: func(*websocket.Config, *http.Request) error { return nil },
+			Handler:   d.GetEventsWebsocket,
+		}).ServeHTTP(w, r)
+	}))
+	defer srvr.Close()
+
+	conn, err := websocket.Dial(strings.Replace(srvr.URL, "http", "ws", 1)+"/api/events?types=session.created,role.created", "", srvr.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// wait for the handler to subscribe
+	for i := 0; i < 50; i++ {
+		d.events.mux.RLock()
+		subscribed := len(d.events.subscribers) == 1
+		d.events.mux.RUnlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	newSession := func(namespace, user string) *v1.EventSession {
+		return &v1.EventSession{Namespace: namespace, Name: "desktop", Template: "ubuntu", User: user}
+	}
+	for _, event := range []*v1.Event{
+		// not readable in this namespace
+		{Type: v1.EventSessionCreated, Session: newSession("team-b", "other-user")},
+		// filtered by type
+		{Type: v1.EventUserLogin, User: "other-user"},
+		// roles are not readable
+		{Type: v1.EventRoleCreated, Role: "admin"},
+		// owned by the user
+		{Type: v1.EventSessionCreated, Session: newSession("team-b", "test-user")},
+		// readable in this namespace
+		{Type: v1.EventSessionCreated, Session: newSession("team-a", "other-user")},
+	} {
+		d.events.Publish(event)
+	}
+
+	for _, expected := range []string{"team-b", "team-a"} {
+		if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		event := &v1.Event{}
+		if err := websocket.JSON.Receive(conn, event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != v1.EventSessionCreated || event.Session == nil || event.Session.Namespace != expected {
+			t.Errorf("Expected session created event in %s, got: %+v", expected, event)
+		}
+		if event.Time == 0 {
+			t.Error("Expected the event time to be set")
+		}
+	}
+
+	// role events are sent to users that can read roles
+	roleReader := &v1.VDIUser{
+		Name: "role-reader",
+		Roles: []*v1.VDIUserRole{
+			{
+				Name:  "role-reader",
+				Rules: []v1.Rule{{Verbs: []v1.Verb{v1.VerbRead}, Resources: []v1.Resource{v1.ResourceRoles}, ResourcePatterns: []string{".*"}}},
+			},
+		},
+	}
+	if !eventAllowed(roleReader, &v1.Event{Type: v1.EventRoleCreated, Role: "admin"}) {
+		t.Error("Expected role event to be allowed")
+	}
+	// drain notices are sent to the owner of the session
+	draining := &v1.Event{Type: v1.EventSessionDraining, Session: newSession("team-b", "test-user"), Drain: &v1.DrainNotice{Node: "test-node"}}
+	if !eventAllowed(user, draining) {
+		t.Error("Expected session owner to receive drain event")
+	}
+	if eventAllowed(roleReader, draining) {
+		t.Error("Expected drain event to be denied to other users")
+	}
+	// progress is sent to the owner of the session
+	if !eventAllowed(user, &v1.Event{Type: v1.EventSessionPhase, Session: newSession("team-b", "test-user")}) {
+		t.Error("Expected session owner to receive phase event")
+	}
+	if eventAllowed(roleReader, &v1.Event{Type: "unknown"}) {
+		t.Error("Expected unknown event to be denied")
+	}
+}
+
+// TestClient tests the authentication flows, session functions, and retries of
+// the API client.
+func TestClient(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// tokens can be refreshed on demand
+	if err := cl.RefreshToken(); err != nil {
+		t.Error("Expected to be able to refresh token, got:", err)
+	}
+
+	// Enable MFA for a new user
+	if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+		Username: "mfa-user",
+		Password: "mfa-password",
+		Roles:    []string{"test-cluster-admin"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mfaResp, err := cl.UpdateVDIUserMFA("mfa-user", &v1.UpdateMFARequest{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := url.Parse(mfaResp.ProvisioningURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	totp := gotp.NewDefaultTOTP(uri.Query().Get("secret"))
+	if _, err := cl.VerifyVDIUserMFA("mfa-user", totp.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user can't log in without an OTP
+	mfaOpts := &client.Opts{URL: opts.URL, Username: "mfa-user", Password: "mfa-password"}
+	if _, err := client.New(mfaOpts); err == nil {
+		t.Error("Expected error logging in without an OTPFunc, got nil")
+	}
+	mfaOpts.OTPFunc = func() (string, error) { return "000000", nil }
+	if _, err := client.New(mfaOpts); err == nil {
+		t.Error("Expected error logging in with a bad OTP, got nil")
+	}
+	mfaOpts.OTPFunc = func() (string, error) { return totp.Now(), nil }
+	mfaCl, err := client.New(mfaOpts)
+	if err != nil {
+		t.Fatal("Expected to log in with an OTP, got:", err)
+	}
+	defer mfaCl.Close()
+	if user, err := mfaCl.WhoAmI(); err != nil {
+		t.Fatal(err)
+	} else if user.Name != "mfa-user" {
+		t.Error("Expected to be authenticated as mfa-user, got:", user.Name)
+	}
+
+	// Sessions
+	if _, err := cl.CreateDesktopSession(&v1.CreateSessionRequest{Template: "missing-template"}); err == nil {
+		t.Error("Expected error creating session for missing template, got nil")
+	}
+	if err := cl.CreateDesktopTemplate(&v1alpha1.DesktopTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "client-template"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sess, err := cl.CreateDesktopSession(&v1.CreateSessionRequest{Template: "client-template"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Name == "" || sess.Namespace != "default" {
+		t.Error("Expected session in the default namespace, got:", sess)
+	}
+	if status, err := cl.GetDesktopSessionStatus(sess.Namespace, sess.Name); err != nil {
+		t.Error("Expected to get session status, got:", err)
+	} else if status.Running {
+		t.Error("Expected session to not be running yet")
+	}
+	if _, err := cl.AttachDisplay(sess.Namespace, "missing-session"); err == nil {
+		t.Error("Expected error attaching to missing session, got nil")
+	}
+	if err := cl.DeleteDesktopSession(sess.Namespace, sess.Name); err != nil {
+		t.Error("Expected to delete session, got:", err)
+	}
+	if _, err := cl.GetDesktopSessionStatus(sess.Namespace, sess.Name); err == nil {
+		t.Error("Expected error getting deleted session, got nil")
+	}
+
+	// Retries
+	var attempts int
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": "unavailable"}`))
+			return
+		}
+		w.Write([]byte(`["default"]`))
+	}))
+	defer flaky.Close()
+	flakyCl, err := client.New(&client.Opts{URL: flaky.URL, APIKey: "kvdi_test", RetryInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nss, err := flakyCl.GetNamespaces(); err != nil {
+		t.Error("Expected request to succeed after retries, got:", err)
+	} else if len(nss) != 1 || attempts != 3 {
+		t.Error("Expected success on the third attempt, got:", nss, attempts)
+	}
+
+	// requests that are not idempotent are not retried once sent
+	attempts = 0
+	if err := flakyCl.CreateVDIRole(&v1.CreateRoleRequest{Name: "test-role"}); err == nil {
+		t.Error("Expected error from unavailable server, got nil")
+	}
+	if attempts != 1 {
+		t.Error("Expected POST to not be retried, got attempts:", attempts)
+	}
+
+	// retries can be disabled
+	attempts = 0
+	noRetryCl, err := client.New(&client.Opts{URL: flaky.URL, APIKey: "kvdi_test", MaxRetries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := noRetryCl.GetNamespaces(); err == nil || attempts != 1 {
+		t.Error("Expected a single failed attempt with retries disabled, got:", err, attempts)
+	}
+}
+
+// TestGRPCProto tests that the checked in protobuf definitions are up to date.
+func TestGRPCProto(t *testing.T) {
+	proto, err := ioutil.ReadFile("../../doc/kvdi.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(proto) != GRPCProto() {
+		t.Error("doc/kvdi.proto is out of date, run `make proto`")
+	}
+}
+
+// TestGRPC tests that gRPC calls are served by the REST routes.
+func TestGRPC(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpcService.NewServer(srvr.Handler)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	methods := grpcService.Descriptor().Services().Get(0).Methods()
+	invoke := func(ctx context.Context, name string, in map[string]interface{}) (map[string]interface{}, error) {
+		t.Helper()
+		desc := methods.ByName(protoreflect.Name(name))
+		req, res := dynamicpb.NewMessage(desc.Input()), dynamicpb.NewMessage(desc.Output())
+		if in != nil {
+			body, err := json.Marshal(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := protojson.Unmarshal(body, req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := conn.Invoke(ctx, "/kvdi.v1.KVDI/"+name, req, res); err != nil {
+			return nil, err
+		}
+		body, err := protojson.Marshal(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make(map[string]interface{})
+		if err := json.Unmarshal(body, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out, nil
+	}
+
+	// Calls without a token are rejected by the session middleware
+	if _, err := invoke(context.Background(), "ListUsers", nil); status.Code(err) != codes.PermissionDenied {
+		t.Error("Expected permission denied without a token, got:", err)
+	}
+
+	// Bad credentials are rejected
+	if _, err := invoke(context.Background(), "Login", map[string]interface{}{
+		"username": opts.Username,
+		"password": "wrong",
+	}); status.Code(err) != codes.PermissionDenied {
+		t.Error("Expected permission denied for bad credentials, got:", err)
+	}
+
+	session, err := invoke(context.Background(), "Login", map[string]interface{}{
+		"username": opts.Username,
+		"password": opts.Password,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session["authorized"] != true {
+		t.Error("Expected an authorized session, got:", session)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-session-token", session["token"].(string))
+
+	users, err := invoke(ctx, "ListUsers", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, ok := users["users"].([]interface{}); !ok || len(list) != 1 {
+		t.Error("Expected one user, got:", users)
+	}
+
+	// Request bodies are validated by the decoder
+	if _, err := invoke(ctx, "CreateUser", map[string]interface{}{
+		"username": "grpc-user",
+		"roles":    []string{"test-cluster-admin"},
+	}); status.Code(err) != codes.InvalidArgument {
+		t.Error("Expected invalid argument for a user without a password, got:", err)
+	}
+	if _, err := invoke(ctx, "CreateUser", map[string]interface{}{
+		"username": "grpc-user",
+		"password": "grpc-password",
+		"roles":    []string{"test-cluster-admin"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := invoke(ctx, "GetUser", map[string]interface{}{"user": "grpc-user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user["name"] != "grpc-user" {
+		t.Error("Expected grpc-user, got:", user)
+	}
+
+	if _, err := invoke(ctx, "GetRole", map[string]interface{}{"role": "missing"}); status.Code(err) != codes.NotFound {
+		t.Error("Expected not found for a missing role, got:", err)
+	}
+	roles, err := invoke(ctx, "ListRoles", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, ok := roles["roles"].([]interface{}); !ok || len(list) != 2 {
+		t.Error("Expected two roles, got:", roles)
+	}
+
+	if _, err := invoke(ctx, "DeleteUser", map[string]interface{}{"user": "grpc-user"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invoke(ctx, "GetUser", map[string]interface{}{"user": "grpc-user"}); status.Code(err) != codes.NotFound {
+		t.Error("Expected not found for a deleted user, got:", err)
+	}
+}
+
+// TestRoleGrants tests temporarily granting roles to users.
+func TestRoleGrants(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	role := &v1alpha1.VDIRole{Rules: []v1.Rule{{Verbs: []v1.Verb{v1.VerbRead}, Resources: []v1.Resource{v1.ResourceTemplates}}}}
+	role.Name = "oncall"
+	role.Labels = map[string]string{v1.RoleClusterRefLabel: cluster.GetName()}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster, role, cluster.GetAdminRole())}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	d.auth = auth.GetAuthProvider(cluster, d.secrets)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.auth.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.auth.Reconcile(apiLogger, d.client, cluster, "testing"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.secrets.WriteSecret(v1.JWTSecretKey, []byte("supersecret")); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, user string, body interface{}) *http.Request {
+		req := httptest.NewRequest(method, "/api/roles/oncall/grants", nil)
+		req = mux.SetURLVars(req, map[string]string{"role": "oncall", "user": user})
+		apiutil.SetRequestUserSession(req, &v1.JWTClaims{User: &v1.VDIUser{Name: "admin"}})
+		if body != nil {
+			apiutil.SetRequestObject(req, body)
+		}
+		return req
+	}
+
+	// roles can only be granted to users that exist
+	rr := httptest.NewRecorder()
+	d.PostRoleGrant(rr, request(http.MethodPost, "", &v1.CreateRoleGrantRequest{User: "nobody", Duration: "5m"}))
+	if rr.Code != http.StatusNotFound {
+		t.Error("Expected 404 granting a role to a missing user, got:", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	d.PostRoleGrant(rr, request(http.MethodPost, "", &v1.CreateRoleGrantRequest{User: "admin", Duration: "5m"}))
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 granting role, got:", rr.Code, rr.Body.String())
+	}
+	grant := &v1.RoleGrant{}
+	if err := json.Unmarshal(rr.Body.Bytes(), grant); err != nil {
+		t.Fatal(err)
+	}
+	if grant.User != "admin" || grant.CreatedBy != "admin" {
+		t.Error("Expected grant to admin by admin, got:", grant)
+	}
+
+	// granted roles are added to the claims, and expired ones left over from previous
+	// claims are dropped
+	user := &v1.VDIUser{Name: "admin", Roles: []*v1.VDIUserRole{{Name: "stale", ExpiresAt: time.Now().Unix() - 1}}}
+	if err := d.applyRoleGrants(user); err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Roles) != 1 || user.Roles[0].GetName() != "oncall" || user.Roles[0].ExpiresAt != grant.ExpiresAt {
+		t.Fatal("Expected only the granted role, got:", user.Roles)
+	}
+	other := &v1.VDIUser{Name: "someone-else"}
+	if err := d.applyRoleGrants(other); err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Roles) != 0 {
+		t.Error("Expected no roles for a user without grants, got:", other.Roles)
+	}
+
+	// tokens do not outlive the grant
+	rr = httptest.NewRecorder()
+	d.returnNewJWT(rr, &v1.AuthResult{User: &v1.VDIUser{Name: "admin"}, RefreshNotSupported: true}, true, "")
+	session := &v1.SessionResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), session); err != nil {
+		t.Fatal(err)
+	}
+	if session.ExpiresAt > grant.ExpiresAt {
+		t.Error("Expected token to expire with the grant, got:", session.ExpiresAt, grant.ExpiresAt)
+	}
+
+	// local users can also be assigned roles until a given time
+	adminRole := cluster.GetAdminRole().GetName()
+	if err := d.auth.UpdateUser("admin", &v1.UpdateUserRequest{
+		Roles:           []string{adminRole, "oncall"},
+		RoleExpirations: map[string]int64{"oncall": time.Now().Unix() - 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	localUser, err := d.auth.GetUser("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := localUser.GetRoleNames(); !reflect.DeepEqual(names, []string{adminRole}) {
+		t.Error("Expected expired local role to be left out, got:", names)
+	}
+
+	// revoking a grant removes it from the role
+	rr = httptest.NewRecorder()
+	d.GetRoleGrants(rr, request(http.MethodGet, "", nil))
+	grants := &v1.RoleGrantsResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), grants); err != nil {
+		t.Fatal(err)
+	}
+	if len(grants.Grants) != 1 {
+		t.Error("Expected one active grant, got:", grants.Grants)
+	}
+	rr = httptest.NewRecorder()
+	d.DeleteRoleGrant(rr, request(http.MethodDelete, "admin", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatal("Expected 200 revoking grant, got:", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	d.DeleteRoleGrant(rr, request(http.MethodDelete, "admin", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Error("Expected 404 revoking a missing grant, got:", rr.Code)
+	}
+}
+
+// unhealthyAuthProvider is an auth provider whose remote service is unreachable.
+type unhealthyAuthProvider struct{ common.AuthProvider }
+
+func (u *unhealthyAuthProvider) CheckHealth() error { return errors.New("ldap server unreachable") }
+
+// TestHealth tests the health and readiness endpoints.
+func TestHealth(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+
+	getReport := func(handler http.HandlerFunc, expectedCode int) *v1.HealthReport {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != expectedCode {
+			t.Fatal("Expected", expectedCode, "got:", rr.Code, rr.Body.String())
+		}
+		report := &v1.HealthReport{}
+		if err := json.Unmarshal(rr.Body.Bytes(), report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	componentStatus := func(report *v1.HealthReport) map[string]v1.HealthStatus {
+		statuses := make(map[string]v1.HealthStatus)
+		for _, component := range report.Components {
+			statuses[component.Name] = component.Status
+		}
+		return statuses
+	}
+
+	// nothing has been setup yet
+	d := &desktopAPI{clusterName: cluster.Name, vdiCluster: cluster}
+	report := getReport(d.Readyz, http.StatusServiceUnavailable)
+	if report.Status != v1.HealthStatusFailed || len(report.Components) != 4 {
+		t.Fatal("Expected all components to be reported as failed, got:", report)
+	}
+	for _, component := range report.Components {
+		if component.Status != v1.HealthStatusFailed || component.Error == "" {
+			t.Error("Expected component to have failed, got:", component)
+		}
+	}
+	// liveness does not fail on dependencies
+	if report := getReport(d.Healthz, http.StatusOK); report.Healthy() {
+		t.Error("Expected health report to show failed components, got:", report)
+	}
+
+	// the cluster is not found in the kubernetes api
+	d.client = fake.NewFakeClientWithScheme(scheme)
+	d.secrets = secrets.GetSecretEngine(cluster)
+	d.mfa = mfa.NewManager(d.secrets)
+	d.auth = auth.GetAuthProvider(cluster, d.secrets)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	report = getReport(d.Readyz, http.StatusServiceUnavailable)
+	if statuses := componentStatus(report); statuses[v1.HealthComponentKubernetes] != v1.HealthStatusFailed || statuses[v1.HealthComponentSecrets] != v1.HealthStatusOK {
+		t.Error("Expected only the kubernetes api to fail, got:", statuses)
+	}
+
+	// everything is healthy, the jwt secret not being written yet is fine
+	d.client = fake.NewFakeClientWithScheme(scheme, cluster)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	report = getReport(d.Readyz, http.StatusOK)
+	if !report.Healthy() {
+		t.Fatal("Expected all components to be healthy, got:", componentStatus(report))
+	}
+	for _, component := range report.Components {
+		switch component.Name {
+		case v1.HealthComponentSecrets:
+			if component.Backend != v1alpha1.SecretsBackendK8s {
+				t.Error("Expected k8s secrets backend, got:", component.Backend)
+			}
+		case v1.HealthComponentAuth:
+			if component.Backend != v1alpha1.AuthBackendLocal {
+				t.Error("Expected local auth backend, got:", component.Backend)
+			}
+		}
+	}
+
+	// auth providers backed by a remote service are checked
+	d.auth = &unhealthyAuthProvider{d.auth}
+	report = getReport(d.Readyz, http.StatusServiceUnavailable)
+	for _, component := range report.Components {
+		if component.Name == v1.HealthComponentAuth {
+			if component.Status != v1.HealthStatusFailed || component.Error != "ldap server unreachable" {
+				t.Error("Expected auth provider to have failed, got:", component)
+			}
+		} else if component.Status != v1.HealthStatusOK {
+			t.Error("Expected component to be healthy, got:", component)
+		}
+	}
+}
+
+// TestGetStatus tests reporting the conditions of the VDICluster along with the
+// health of the app.
+func TestGetStatus(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	for _, condType := range v1alpha1.VDIClusterConditionTypes {
+		cluster.SetCondition(condType, corev1.ConditionTrue, "Reconciled", "")
+	}
+	cluster.SetCondition(v1alpha1.VDIClusterAppReady, corev1.ConditionFalse, "DeploymentNotReady", "")
+	cluster.Status.Ready = cluster.ConditionsReady()
+
+	d := &desktopAPI{clusterName: cluster.Name, vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	d.mfa = mfa.NewManager(d.secrets)
+	d.auth = auth.GetAuthProvider(cluster, d.secrets)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	getStatus := func() *v1alpha1.ClusterStatusResponse {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		d.GetStatus(rr, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatal("Expected 200, got:", rr.Code, rr.Body.String())
+		}
+		status := &v1alpha1.ClusterStatusResponse{}
+		if err := json.Unmarshal(rr.Body.Bytes(), status); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	// the app deployment is not ready yet
+	status := getStatus()
+	if status.Ready || status.Name != cluster.Name || len(status.Conditions) != len(v1alpha1.VDIClusterConditionTypes) {
+		t.Fatal("Expected the cluster to not be ready, got:", status)
+	}
+	for _, cond := range status.Conditions {
+		if expected := cond.Type != v1alpha1.VDIClusterAppReady; (cond.Status == corev1.ConditionTrue) != expected {
+			t.Error("Unexpected condition status, got:", cond)
+		}
+	}
+	if status.Health == nil || !status.Health.Healthy() {
+		t.Error("Expected the app to be healthy, got:", status.Health)
+	}
+
+	// every condition is true
+	cluster.SetCondition(v1alpha1.VDIClusterAppReady, corev1.ConditionTrue, "DeploymentReady", "")
+	cluster.Status.Ready = cluster.ConditionsReady()
+	if err := d.client.Status().Update(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	if status := getStatus(); !status.Ready {
+		t.Error("Expected the cluster to be ready, got:", status)
+	}
+
+	// the cluster is not ready when the app is unhealthy
+	d.auth = &unhealthyAuthProvider{d.auth}
+	if status := getStatus(); status.Ready || status.Health.Healthy() {
+		t.Error("Expected the cluster to not be ready, got:", status)
+	}
+}
+
+// TestJWTSigningKeys tests signing access tokens with the keys generated by the
+// manager and publishing their public keys.
+func TestJWTSigningKeys(t *testing.T) {
+	scheme, err := buildScheme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{TokenSigning: &v1alpha1.TokenSigningConfig{Algorithm: v1alpha1.TokenSigningRS256}}
+	d := &desktopAPI{vdiCluster: cluster, client: fake.NewFakeClientWithScheme(scheme, cluster)}
+	d.secrets = secrets.GetSecretEngine(cluster)
+	if err := d.secrets.Setup(d.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.secrets.WriteSecret(v1.JWTSecretKey, []byte("supersecret")); err != nil {
+		t.Fatal(err)
+	}
+	authResult := &v1.AuthResult{User: &v1.VDIUser{Name: "admin"}}
+
+	getJWKS := func() *v1.JSONWebKeySet {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		d.GetJWKS(rr, httptest.NewRequest(http.MethodGet, "/api/.well-known/jwks.json", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatal("Expected 200 fetching jwks, got:", rr.Code, rr.Body.String())
+		}
+		jwks := &v1.JSONWebKeySet{}
+		if err := json.Unmarshal(rr.Body.Bytes(), jwks); err != nil {
+			t.Fatal(err)
+		}
+		return jwks
+	}
+	generate := func(keys *apiutil.JWTKeySet) string {
+		t.Helper()
+		_, token, err := apiutil.GenerateJWT(keys, authResult, true, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	writeKeys := func(keys ...*v1.JWTSigningKey) {
+		t.Helper()
+		out, err := json.Marshal(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.secrets.WriteSecret(v1.JWTSigningKeysSecretKey, out); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// until the manager generates keys, tokens are signed with the secret
+	keys, err := d.getJWTKeys(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyToken := generate(keys)
+	if jwks := getJWKS(); len(jwks.Keys) != 0 {
+		t.Error("Expected no public keys, got:", jwks.Keys)
+	}
+
+	current, err := apiutil.NewJWTSigningKey(v1alpha1.TokenSigningRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKeys(current)
+	if keys, err = d.getJWTKeys(true); err != nil {
+		t.Fatal(err)
+	}
+	token := generate(keys)
+	for _, tok := range []string{legacyToken, token} {
+		if _, err := d.decodeAndVerifyJWT(keys, tok); err != nil {
+			t.Error("Expected token to verify, got:", err)
+		}
+	}
+	if jwks := getJWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != current.ID || jwks.Keys[0].Algorithm != "RS256" {
+		t.Error("Expected the signing key to be published, got:", jwks.Keys)
+	}
+
+	// tokens signed with a key that was rotated in after the keys were read are
+	// verified after reading the keys again
+	next, err := apiutil.NewJWTSigningKey(v1alpha1.TokenSigningRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextKeys, err := apiutil.NewJWTKeySet([]byte("supersecret"), []*v1.JWTSigningKey{next}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextToken := generate(nextKeys)
+	if _, err := d.decodeAndVerifyJWT(keys, nextToken); err != apiutil.ErrUnknownTokenKey {
+		t.Error("Expected unknown key error, got:", err)
+	}
+	current.RetiredAt = time.Now().Unix()
+	writeKeys(current, next)
+	if _, err := d.decodeAndVerifyJWT(keys, nextToken); err != nil {
+		t.Error("Expected token signed with the rotated key to verify, got:", err)
+	}
+	if _, err := d.decodeAndVerifyJWT(keys, token); err != nil {
+		t.Error("Expected token signed with the retired key to verify, got:", err)
+	}
+	if jwks := getJWKS(); len(jwks.Keys) != 2 {
+		t.Error("Expected both keys to be published, got:", jwks.Keys)
+	}
+
+	// signing with keys is turned off
+	d.vdiCluster = &v1alpha1.VDICluster{}
+	if keys, err = d.getJWTKeys(true); err != nil {
+		t.Fatal(err)
+	}
+	legacyKeys, err := apiutil.NewJWTKeySet([]byte("supersecret"), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apiutil.DecodeAndVerifyJWT(legacyKeys, generate(keys)); err != nil {
+		t.Error("Expected token to be signed with the secret, got:", err)
+	}
+}
+
+// TestAnnouncements tests managing announcements and the announcements shown to users.
+func TestAnnouncements(t *testing.T) {
+	srvr, opts := mustNewTestAPI(t)
+	defer srvr.Close()
+	cl, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if err := cl.CreateVDIUser(&v1.CreateUserRequest{
+		Username: "test-user",
+		Password: "test-password",
+		Roles:    []string{"test-cluster-launch-templates"},
+	}); err != nil {
+		t.Fatal("Unable to create test user:", err)
+	}
+
+	now := time.Now()
+	later := now.Add(time.Hour)
+	for _, req := range []*v1.CreateAnnouncementRequest{
+		{Message: "No name"},
+		{Name: "no-message"},
+		{Name: "bad-severity", Message: "Test", Severity: "urgent"},
+		{Name: "bad-schedule", Message: "Test", StartTime: &later, EndTime: &now},
+	} {
+		if err := cl.CreateAnnouncement(req); err == nil {
+			t.Errorf("Expected error creating invalid announcement %q", req.Name)
+		}
+	}
+
+	for _, req := range []*v1.CreateAnnouncementRequest{
+		{Name: "outage", Message: "The cluster is degraded", Severity: v1.AnnouncementCritical},
+		{Name: "maintenance", Message: "Maintenance tonight", StartTime: &later},
+		{Name: "admins-only", Message: "Upgrade at noon", Roles: []string{"test-cluster-admin"}},
+	} {
+		if err := cl.CreateAnnouncement(req); err != nil {
+			t.Fatal("Unable to create announcement:", err)
+		}
+	}
+
+	userCl, err := client.New(&client.Opts{URL: opts.URL, Username: "test-user", Password: "test-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userCl.Close()
+
+	// users only see active announcements targeted at them
+	announcements, err := userCl.GetAnnouncements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(announcements) != 1 || announcements[0].Name != "outage" || announcements[0].Severity != v1.AnnouncementCritical {
+		t.Error("Expected only the outage announcement for the user, got:", announcements)
+	}
+	if announcements, err = cl.GetAnnouncements(); err != nil {
+		t.Fatal(err)
+	} else if len(announcements) != 2 {
+		t.Error("Expected the outage and admin announcements for the admin, got:", announcements)
+	}
+
+	// all announcements can only be listed with a grant to read them
+	if _, err := userCl.GetAllAnnouncements(); err == nil {
+		t.Error("Expected user to not be able to list all announcements")
+	}
+	if announcements, err = cl.GetAllAnnouncements(); err != nil {
+		t.Fatal(err)
+	} else if len(announcements) != 3 {
+		t.Error("Expected all announcements for the admin, got:", announcements)
+	}
+	if err := userCl.DeleteAnnouncement("outage"); err == nil {
+		t.Error("Expected user to not be able to delete announcements")
+	}
+
+	// updates replace the details of the announcement
+	if err := cl.UpdateAnnouncement("maintenance", &v1.UpdateAnnouncementRequest{Message: "Maintenance now", EndTime: &later}); err != nil {
+		t.Fatal(err)
+	}
+	announcement, err := cl.GetAnnouncement("maintenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if announcement.Message != "Maintenance now" || announcement.Severity != v1.AnnouncementInfo || announcement.StartTime != nil || announcement.EndTime == nil {
+		t.Errorf("Unexpected announcement after update: %+v", announcement)
+	}
+	if announcements, err = userCl.GetAnnouncements(); err != nil {
+		t.Fatal(err)
+	} else if len(announcements) != 2 {
+		t.Error("Expected the maintenance announcement to be shown after the update, got:", announcements)
+	}
+
+	if err := cl.DeleteAnnouncement("outage"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.GetAnnouncement("outage"); err == nil {
+		t.Error("Expected error getting deleted announcement")
+	}
+	if err := cl.UpdateAnnouncement("outage", &v1.UpdateAnnouncementRequest{Message: "Test"}); err == nil {
+		t.Error("Expected error updating deleted announcement")
+	}
+}
+
+// TestAnnouncementScheduler tests the events published as announcements start and
+// stop being shown.
+func TestAnnouncementScheduler(t *testing.T) {
+	events := newEventBroker()
+	ch := events.Subscribe()
+	defer events.Unsubscribe(ch)
+	scheduler := newAnnouncementScheduler(events)
+
+	expectEvent := func(eventType v1.EventType, message string) {
+		t.Helper()
+		select {
+		case event := <-ch:
+			if event.Type != eventType || event.Announcement == nil || event.Announcement.Message != message {
+				t.Errorf("Expected %s event for %q, got: %+v", eventType, message, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %s event for %q", eventType, message)
+		}
+	}
+	expectNoEvent := func() {
+		t.Helper()
+		select {
+		case event := <-ch:
+			t.Errorf("Expected no event, got: %+v", event)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	newAnnouncement := func(message string, start, end time.Duration) *v1alpha1.VDIAnnouncement {
+		announcement := &v1alpha1.VDIAnnouncement{Message: message}
+		announcement.Name = "test-announcement"
+		announcement.StartTime = &metav1.Time{Time: time.Now().Add(start)}
+		announcement.EndTime = &metav1.Time{Time: time.Now().Add(end)}
+		return announcement
+	}
+
+	// announcements already shown at startup are not published again
+	scheduler.Schedule(newAnnouncement("startup", -time.Minute, time.Hour), false)
+	expectNoEvent()
+
+	// changes while shown are published
+	scheduler.Schedule(newAnnouncement("changed", -time.Minute, time.Hour), true)
+	expectEvent(v1.EventAnnouncementPublished, "changed")
+
+	// announcements are published at their start time and removed at their end,
+	// and timers from before a change are stopped
+	scheduler.Schedule(newAnnouncement("scheduled", 200*time.Millisecond, 400*time.Millisecond), true)
+	expectEvent(v1.EventAnnouncementRemoved, "scheduled")
+	expectEvent(v1.EventAnnouncementPublished, "scheduled")
+	expectEvent(v1.EventAnnouncementRemoved, "scheduled")
+
+	// deleting a shown announcement removes it
+	announcement := newAnnouncement("deleted", -time.Minute, time.Hour)
+	scheduler.Schedule(announcement, true)
+	expectEvent(v1.EventAnnouncementPublished, "deleted")
+	scheduler.Remove(announcement)
+	expectEvent(v1.EventAnnouncementRemoved, "deleted")
+	expectNoEvent()
+
+	// announcement events are only sent to the users they target
+	user := &v1.VDIUser{Name: "test-user", Roles: []*v1.VDIUserRole{{Name: "developers"}}}
+	event := &v1.Event{Type: v1.EventAnnouncementPublished, Announcement: &v1.Announcement{Name: "test"}}
+	if !eventAllowed(user, event) {
+		t.Error("Expected announcement without roles to be sent to all users")
+	}
+	event.Announcement.Roles = []string{"operators"}
+	if eventAllowed(user, event) {
+		t.Error("Expected announcement for other roles to not be sent to the user")
+	}
+	event.Announcement.Roles = append(event.Announcement.Roles, "developers")
+	if !eventAllowed(user, event) {
+		t.Error("Expected announcement for the user's role to be sent to them")
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/tracing"
+)
+
+// tracingServiceName is the service spans from the app are reported under.
+const tracingServiceName = "kvdi-app"
+
+// tracingExcludedPaths are route prefixes that are not traced.
+var tracingExcludedPaths = []string{"/api/healthz", "/api/readyz", "/api/metrics"}
+
+// tracer returns the tracer for the current tracing configuration, or nil if
+// tracing is disabled.
+func (d *desktopAPI) tracer() *tracing.Tracer {
+	if d.vdiCluster == nil {
+		return nil
+	}
+	return tracing.ForConfig(tracingServiceName, d.vdiCluster.GetTracing())
+}
+
+// tracingResponseWriter tracks the status code of a response, including when the
+// connection is taken over for a websocket.
+type tracingResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (t *tracingResponseWriter) WriteHeader(s int) {
+	t.ResponseWriter.WriteHeader(s)
+	t.status = s
+}
+
+func (t *tracingResponseWriter) Flush() { apiutil.Flush(t.ResponseWriter) }
+
+func (t *tracingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := t.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && t.status == http.StatusOK {
+		t.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// tracingMiddleware implements mux.MiddlewareFunc and records a span for every
+// request when tracing is enabled. Requests carrying a traceparent header continue
+// the caller's trace. Spans for websocket connections last until they are closed.
+func (d *desktopAPI) tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracer := d.tracer()
+		if tracer == nil || tracingExcluded(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := apiutil.GetGorillaPath(r)
+		ctx, span := tracer.Start(
+			tracing.Extract(r.Context(), r.Header),
+			fmt.Sprintf("%s %s", r.Method, route),
+			tracing.WithKind(tracing.SpanKindServer),
+		)
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", r.URL.Path)
+		span.SetAttribute("net.peer.ip", getClientAddr(r))
+
+		tw := &tracingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", tw.status)
+		if tw.status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("%d %s", tw.status, http.StatusText(tw.status)))
+		}
+	})
+}
+
+// tracingExcluded returns true if the given request should not be traced.
+func tracingExcluded(r *http.Request) bool {
+	for _, prefix := range tracingExcludedPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setTracingUser sets the user on the span for the given request, if there is one.
+func setTracingUser(r *http.Request, user string) {
+	tracing.SpanFromContext(r.Context()).SetAttribute("kvdi.user", user)
+}
+
+// authenticate passes the given login request to the auth provider, recording a
+// span for the call.
+func (d *desktopAPI) authenticate(r *http.Request, req *v1.LoginRequest) (*v1.AuthResult, error) {
+	_, span := d.tracer().Start(r.Context(), "auth.Authenticate")
+	defer span.End()
+	span.SetAttribute("kvdi.auth.backend", d.vdiCluster.GetAuthBackend())
+	result, err := d.auth.Authenticate(req)
+	span.SetError(err)
+	return result, err
+}
+
+// refresh retrieves the current information for the given user from the auth
+// provider, recording a span for the call.
+func (d *desktopAPI) refresh(r *http.Request, username, providerToken string) (*v1.AuthResult, error) {
+	_, span := d.tracer().Start(r.Context(), "auth.Refresh")
+	defer span.End()
+	span.SetAttribute("kvdi.auth.backend", d.vdiCluster.GetAuthBackend())
+	span.SetAttribute("kvdi.user", username)
+	result, err := d.auth.Refresh(username, providerToken)
+	span.SetError(err)
+	return result, err
+}
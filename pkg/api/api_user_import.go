@@ -0,0 +1,219 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// csvContentType is the content type used for CSV user imports and exports.
+const csvContentType = "text/csv"
+
+// userCSVHeader is the header row of CSV user exports. Imports may order the
+// columns any way they like, and the password column is optional. Roles are
+// separated by semicolons.
+var userCSVHeader = []string{"username", "roles", "password"}
+
+// isCSV returns true if the given Content-Type or Accept header value includes CSV.
+func isCSV(header string) bool {
+	for _, value := range strings.Split(header, ",") {
+		if mediaType, _, err := mime.ParseMediaType(value); err == nil && mediaType == csvContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// readImportUsersRequest reads the users in an import request into an
+// ImportUsersRequest, so that their roles can be checked before the request is
+// handled.
+func readImportUsersRequest(r *http.Request) (interface{}, error) {
+	records, err := readUserRecords(r)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.ImportUsersRequest{Users: records}, nil
+}
+
+// readUserRecords reads the users in an import request. CSV is read when the
+// Content-Type is text/csv, otherwise a JSON list of records is expected.
+func readUserRecords(r *http.Request) ([]*v1.UserRecord, error) {
+	defer r.Body.Close()
+	if isCSV(r.Header.Get("Content-Type")) {
+		return readUserCSV(r.Body)
+	}
+	records := make([]*v1.UserRecord, 0)
+	if err := json.NewDecoder(r.Body).Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// readUserCSV reads user records from CSV with a header row.
+func readUserCSV(in io.Reader) ([]*v1.UserRecord, error) {
+	reader := csv.NewReader(in)
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("The CSV is missing a header row")
+	}
+	columns := make(map[string]int)
+	for idx, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = idx
+	}
+	for _, required := range []string{"username", "roles"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("The CSV is missing the '%s' column", required)
+		}
+	}
+	records := make([]*v1.UserRecord, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := &v1.UserRecord{Roles: make([]string, 0)}
+		record.Username = strings.TrimSpace(row[columns["username"]])
+		for _, role := range strings.Split(row[columns["roles"]], ";") {
+			if role = strings.TrimSpace(role); role != "" {
+				record.Roles = append(record.Roles, role)
+			}
+		}
+		if idx, ok := columns["password"]; ok {
+			record.Password = row[idx]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// writeUserCSV writes the given user records as CSV with a header row.
+func writeUserCSV(out io.Writer, records []*v1.UserRecord) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(userCSVHeader); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := writer.Write([]string{record.Username, strings.Join(record.Roles, ";"), record.Password}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// generateInitialPassword returns a random password for an imported user that
+// was not given one. The suffix makes sure it contains every class of character
+// a password policy can require.
+func generateInitialPassword() (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf) + "-Aa1", nil
+}
+
+// importUsers creates the given users. Every record is validated before any users
+// are created, and if creating one fails, the users created before it are removed
+// again. The returned error is only set for failures unrelated to the records. When
+// progress is not nil, it is called after each user is created.
+func (d *desktopAPI) importUsers(records []*v1.UserRecord, progress jobProgressFunc) (*v1.ImportUsersResponse, error) {
+	resp := &v1.ImportUsersResponse{
+		GeneratedPasswords: make(map[string]string),
+		Errors:             make([]*v1.UserImportError, 0),
+	}
+	if len(records) == 0 {
+		resp.Error = "No users were provided"
+		return resp, nil
+	}
+
+	users, err := d.auth.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]struct{})
+	for _, user := range users {
+		existing[user.GetName()] = struct{}{}
+	}
+	roles, err := d.vdiCluster.GetRoles(d.client)
+	if err != nil {
+		return nil, err
+	}
+	roleNames := make(map[string]struct{})
+	for _, role := range roles {
+		roleNames[role.GetName()] = struct{}{}
+	}
+
+	reqs := make([]*v1.CreateUserRequest, len(records))
+	seen := make(map[string]struct{})
+	for idx, record := range records {
+		rowErr := func(err error) {
+			resp.Errors = append(resp.Errors, &v1.UserImportError{Row: idx + 1, Username: record.Username, Error: err.Error()})
+		}
+		req := &v1.CreateUserRequest{Username: record.Username, Password: record.Password, Roles: record.Roles}
+		if req.Password == "" {
+			if req.Password, err = generateInitialPassword(); err != nil {
+				return nil, err
+			}
+			resp.GeneratedPasswords[req.Username] = req.Password
+		}
+		if err := req.Validate(); err != nil {
+			rowErr(err)
+			continue
+		}
+		if _, ok := seen[req.Username]; ok {
+			rowErr(fmt.Errorf("User %s appears more than once", req.Username))
+			continue
+		}
+		seen[req.Username] = struct{}{}
+		if _, ok := existing[req.Username]; ok {
+			rowErr(fmt.Errorf("User %s already exists", req.Username))
+			continue
+		}
+		for _, role := range req.Roles {
+			if _, ok := roleNames[role]; !ok {
+				rowErr(fmt.Errorf("Role %s does not exist", role))
+				break
+			}
+		}
+		reqs[idx] = req
+	}
+	if len(resp.Errors) > 0 {
+		return failedImport(resp, len(records)), nil
+	}
+
+	for idx, req := range reqs {
+		if err := d.auth.CreateUser(req); err != nil {
+			resp.Errors = append(resp.Errors, &v1.UserImportError{Row: idx + 1, Username: req.Username, Error: err.Error()})
+			for _, created := range reqs[:idx] {
+				if derr := d.auth.DeleteUser(created.Username); derr != nil {
+					apiLogger.Error(derr, fmt.Sprintf("Failed to remove user %s after failed import", created.Username))
+				}
+			}
+			return failedImport(resp, len(records)), nil
+		}
+		if progress != nil {
+			progress((idx+1)*100/len(reqs), fmt.Sprintf("Created user %s", req.Username))
+		}
+	}
+
+	resp.Imported = len(reqs)
+	return resp, nil
+}
+
+// failedImport clears the results of an import that did not complete and sets
+// a summary of the errors.
+func failedImport(resp *v1.ImportUsersResponse, total int) *v1.ImportUsersResponse {
+	resp.Imported = 0
+	resp.GeneratedPasswords = nil
+	resp.Error = fmt.Sprintf("%d of %d rows failed, no users were imported", len(resp.Errors), total)
+	return resp
+}
@@ -23,8 +23,11 @@ type ExtraCheckFunc func(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (a
 type ResourceValueFunc func(r *http.Request) (name string)
 
 // MethodPermissions represents a set of checks to run for an API method.
+// DLPActions are data loss prevention checks that are evaluated before the
+// OverrideFunc, so they apply to resource owners as well.
 type MethodPermissions struct {
 	OverrideFunc          OverrideFunc
+	DLPActions            []v1.APIAction
 	Actions               []v1.APIAction
 	ResourceNameFunc      ResourceValueFunc
 	ResourceNamespaceFunc ResourceValueFunc
@@ -41,7 +44,12 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 	},
 	"/api/authorize": {
 		"POST": {
-			OverrideFunc: allowAll,
+			ExtraCheckFunc: denyAPIKeySession,
+		},
+	},
+	"/api/authorize/push": {
+		"POST": {
+			ExtraCheckFunc: denyAPIKeySession,
 		},
 	},
 	"/api/logout": {
@@ -49,14 +57,45 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowAll,
 		},
 	},
+	"/api/apikeys": {
+		"GET": {
+			ExtraCheckFunc: denyAPIKeySession,
+		},
+		"POST": {
+			ExtraCheckFunc: denyImpersonatedSession,
+		},
+	},
+	"/api/apikeys/{apikey}": {
+		"GET": {
+			ExtraCheckFunc: denyAPIKeySession,
+		},
+		"DELETE": {
+			ExtraCheckFunc: denyAPIKeySession,
+		},
+	},
 	"/api/config": {
 		"GET": {
 			OverrideFunc: allowAll,
 		},
 	},
+	"/api/status": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceAll,
+				},
+			},
+		},
+	},
 	"/api/config/reload": {
 		"POST": {
-			OverrideFunc: allowAll,
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceAll,
+				},
+			},
 		},
 	},
 	"/api/namespaces": {
@@ -64,222 +103,915 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc: allowAll,
 		},
 	},
-	"/api/users": {
-		"GET": {
+	"/api/events": {
+		"GET": {
+			// events are filtered against the user's rules as they are sent
+			OverrideFunc: allowAll,
+		},
+	},
+	"/api/admin/nodes/{node}/drain": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceAll,
+				},
+			},
+		},
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceAll,
+				},
+			},
+		},
+	},
+	"/api/gc": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceAll,
+				},
+			},
+		},
+	},
+	"/api/reports/usage": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceReports,
+				},
+			},
+		},
+	},
+	"/api/reports/logins": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+		},
+	},
+	"/api/jobs": {
+		"GET": {
+			// only the requesting user's jobs are returned
+			OverrideFunc: allowAll,
+		},
+	},
+	"/api/jobs/{job}": {
+		"GET": {
+			OverrideFunc: allowAll,
+		},
+	},
+	"/api/users": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+		},
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbCreate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ExtraCheckFunc: denyUserElevatePerms,
+		},
+	},
+	"/api/users/import": {
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbCreate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ExtraCheckFunc: denyUserElevatePerms,
+		},
+	},
+	"/api/users/export": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+		},
+	},
+	"/api/users/{user}": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUserExceptPassword,
+			ExtraCheckFunc:   denyUserElevatePerms,
+		},
+		"DELETE": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbDelete,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+		},
+	},
+	"/api/impersonate/{user}": {
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbImpersonate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			ExtraCheckFunc:   denyImpersonatedSession,
+		},
+	},
+	"/api/users/{user}/password": {
+		"PUT": {
+			ExtraCheckFunc: denyOtherUserPassword,
+		},
+	},
+	"/api/users/{user}/mfa": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/mfa/verify": {
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/userdata": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/logins": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/secrets": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/secrets/{secret}": {
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+		"DELETE": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/dotfiles": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/email": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/keyboard": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/snapshots": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/users/{user}/snapshots/{namespace}/{name}": {
+		"DELETE": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceUsers,
+				},
+			},
+			ResourceNameFunc: apiutil.GetUserFromRequest,
+			OverrideFunc:     allowSameUser,
+		},
+	},
+	"/api/roles": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceRoles,
+				},
+			},
+		},
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbCreate,
+					ResourceType: v1.ResourceRoles,
+				},
+			},
+			ExtraCheckFunc: denyUserElevatePerms,
+		},
+	},
+	"/api/roles/evaluate": {
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceRoles,
+				},
+			},
+			ExtraCheckFunc: denyPermissionEvaluationAccess,
+		},
+	},
+	"/api/roles/{role}": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceRoles,
+				},
+			},
+			ResourceNameFunc: apiutil.GetRoleFromRequest,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceRoles,
+				},
+			},
+			ResourceNameFunc: apiutil.GetRoleFromRequest,
+			ExtraCheckFunc:   denyUserElevatePerms,
+		},
+		"DELETE": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbDelete,
+					ResourceType: v1.ResourceRoles,
+				},
+			},
+			ResourceNameFunc: apiutil.GetRoleFromRequest,
+		},
+	},
+	"/api/roles/{role}/grants": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceRoles,
+				},
+			},
+			ResourceNameFunc: apiutil.GetRoleFromRequest,
+		},
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceRoles,
+				},
+			},
+			ResourceNameFunc: apiutil.GetRoleFromRequest,
+			ExtraCheckFunc:   denyUserElevatePerms,
+		},
+	},
+	"/api/roles/{role}/grants/{user}": {
+		"DELETE": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceRoles,
+				},
+			},
+			ResourceNameFunc: apiutil.GetRoleFromRequest,
+		},
+	},
+	"/api/groups": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceGroups,
+				},
+			},
+		},
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbCreate,
+					ResourceType: v1.ResourceGroups,
+				},
+			},
+			ExtraCheckFunc: denyUserElevatePerms,
+		},
+	},
+	"/api/groups/{group}": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceGroups,
+				},
+			},
+			ResourceNameFunc: apiutil.GetGroupFromRequest,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceGroups,
+				},
+			},
+			ResourceNameFunc: apiutil.GetGroupFromRequest,
+			ExtraCheckFunc:   denyUserElevatePerms,
+		},
+		"DELETE": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbDelete,
+					ResourceType: v1.ResourceGroups,
+				},
+			},
+			ResourceNameFunc: apiutil.GetGroupFromRequest,
+		},
+	},
+	"/api/announcements": {
+		"GET": {
+			// users can read the announcements shown to them
+			OverrideFunc: allowAnnouncementsForUser,
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceAnnouncements,
+				},
+			},
+		},
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbCreate,
+					ResourceType: v1.ResourceAnnouncements,
+				},
+			},
+		},
+	},
+	"/api/announcements/{announcement}": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceAnnouncements,
+				},
+			},
+			ResourceNameFunc: apiutil.GetAnnouncementFromRequest,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceAnnouncements,
+				},
+			},
+			ResourceNameFunc: apiutil.GetAnnouncementFromRequest,
+		},
+		"DELETE": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbDelete,
+					ResourceType: v1.ResourceAnnouncements,
+				},
+			},
+			ResourceNameFunc: apiutil.GetAnnouncementFromRequest,
+		},
+	},
+	"/api/templates": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+		},
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbCreate,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+		},
+	},
+	"/api/templates/export": {
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+			ExtraCheckFunc: denyTemplateBundleAccess,
+		},
+	},
+	"/api/templates/import": {
+		"POST": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbCreate,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+			ExtraCheckFunc: denyTemplateBundleAccess,
+		},
+	},
+	"/api/templates/{template}": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+			ResourceNameFunc: apiutil.GetTemplateFromRequest,
+		},
+		"PUT": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+			ResourceNameFunc: apiutil.GetTemplateFromRequest,
+		},
+		"DELETE": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbDelete,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+			ResourceNameFunc: apiutil.GetTemplateFromRequest,
+		},
+	},
+	"/api/templates/{template}/revisions": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+			ResourceNameFunc: apiutil.GetTemplateFromRequest,
+		},
+	},
+	"/api/templates/{template}/rollback": {
+		"POST": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbRead,
-					ResourceType: v1.ResourceUsers,
+					Verb:         v1.VerbUpdate,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
+			ResourceNameFunc: apiutil.GetTemplateFromRequest,
 		},
+	},
+	"/api/templates/{template}/estimate": {
 		"POST": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbCreate,
-					ResourceType: v1.ResourceUsers,
+					Verb:         v1.VerbLaunch,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ExtraCheckFunc: denyUserElevatePerms,
+			ResourceNameFunc: apiutil.GetTemplateFromRequest,
+			ResourceNamespaceFunc: func(r *http.Request) string {
+				req := apiutil.GetRequestObject(r).(*v1.EstimateSessionRequest)
+				return req.GetNamespace()
+			},
 		},
 	},
-	"/api/users/{user}": {
+	"/api/sessions": {
 		"GET": {
 			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceTemplates,
+				},
 				{
 					Verb:         v1.VerbRead,
 					ResourceType: v1.ResourceUsers,
 				},
 			},
-			ResourceNameFunc: apiutil.GetUserFromRequest,
-			OverrideFunc:     allowSameUser,
 		},
-		"PUT": {
+		"POST": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbUpdate,
-					ResourceType: v1.ResourceUsers,
+					Verb:         v1.VerbLaunch,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ResourceNameFunc: apiutil.GetUserFromRequest,
-			OverrideFunc:     allowSameUser,
-			ExtraCheckFunc:   denyUserElevatePerms,
+			ResourceNameFunc: func(r *http.Request) string {
+				req := apiutil.GetRequestObject(r).(*v1.CreateSessionRequest)
+				return req.GetTemplate()
+			},
+			ResourceNamespaceFunc: func(r *http.Request) string {
+				req := apiutil.GetRequestObject(r).(*v1.CreateSessionRequest)
+				return req.GetNamespace()
+			},
 		},
+	},
+	"/api/sessions/{user}": {
 		"DELETE": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbDelete,
+					Verb:         v1.VerbUpdate,
 					ResourceType: v1.ResourceUsers,
 				},
 			},
 			ResourceNameFunc: apiutil.GetUserFromRequest,
 		},
 	},
-	"/api/users/{user}/mfa": {
+	"/api/sessions/{namespace}/{name}": {
 		"GET": {
 			Actions: []v1.APIAction{
 				{
 					Verb:         v1.VerbRead,
-					ResourceType: v1.ResourceUsers,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ResourceNameFunc: apiutil.GetUserFromRequest,
-			OverrideFunc:     allowSameUser,
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
-		"PUT": {
+		"DELETE": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbUpdate,
-					ResourceType: v1.ResourceUsers,
+					Verb:         v1.VerbDelete,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ResourceNameFunc: apiutil.GetUserFromRequest,
-			OverrideFunc:     allowSameUser,
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/users/{user}/mfa/verify": {
-		"PUT": {
+	"/api/sessions/{namespace}/{name}/logs": {
+		"GET": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbUpdate,
-					ResourceType: v1.ResourceUsers,
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ResourceNameFunc: apiutil.GetUserFromRequest,
-			OverrideFunc:     allowSameUser,
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/roles": {
+	"/api/sessions/{namespace}/{name}/invites": {
 		"GET": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbRead,
-					ResourceType: v1.ResourceRoles,
+					Verb:         v1.VerbShadow,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
 		"POST": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbCreate,
-					ResourceType: v1.ResourceRoles,
+					Verb:         v1.VerbShadow,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ExtraCheckFunc: denyUserElevatePerms,
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/roles/{role}": {
+	"/api/sessions/{namespace}/{name}/invites/{invite}": {
+		"DELETE": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbShadow,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
+		},
+	},
+	"/api/sessions/{namespace}/{name}/viewtokens": {
 		"GET": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbRead,
-					ResourceType: v1.ResourceRoles,
+					Verb:         v1.VerbShadow,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ResourceNameFunc: apiutil.GetRoleFromRequest,
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
-		"PUT": {
+		"POST": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbUpdate,
-					ResourceType: v1.ResourceRoles,
+					Verb:         v1.VerbShadow,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ResourceNameFunc: apiutil.GetRoleFromRequest,
-			ExtraCheckFunc:   denyUserElevatePerms,
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
+	},
+	"/api/sessions/{namespace}/{name}/viewtokens/{viewtoken}": {
 		"DELETE": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbDelete,
-					ResourceType: v1.ResourceRoles,
+					Verb:         v1.VerbShadow,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ResourceNameFunc: apiutil.GetRoleFromRequest,
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/templates": {
-		"GET": {
+	"/api/sessions/{namespace}/{name}/snapshots": {
+		"POST": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbRead,
+					Verb:         v1.VerbUse,
 					ResourceType: v1.ResourceTemplates,
 				},
 			},
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
+	},
+	"/api/sessions/{namespace}/{name}/recordings": {
 		"POST": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbCreate,
+					Verb:         v1.VerbUse,
 					ResourceType: v1.ResourceTemplates,
 				},
 			},
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/templates/{template}": {
+	"/api/recordings": {
 		"GET": {
 			Actions: []v1.APIAction{
 				{
 					Verb:         v1.VerbRead,
-					ResourceType: v1.ResourceTemplates,
+					ResourceType: v1.ResourceRecordings,
 				},
 			},
-			ResourceNameFunc: apiutil.GetTemplateFromRequest,
 		},
-		"PUT": {
+	},
+	"/api/recordings/{namespace}/{template}/{recording}": {
+		"GET": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbUpdate,
-					ResourceType: v1.ResourceTemplates,
+					Verb:         v1.VerbRead,
+					ResourceType: v1.ResourceRecordings,
 				},
 			},
-			ResourceNameFunc: apiutil.GetTemplateFromRequest,
+			ResourceNameFunc:      apiutil.GetTemplateFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 		},
-		"DELETE": {
+	},
+	"/api/sessions/{namespace}/{name}/handoff": {
+		"POST": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbDelete,
+					Verb:         v1.VerbUse,
 					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ResourceNameFunc: apiutil.GetTemplateFromRequest,
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/sessions": {
-		"GET": {
+	"/api/sessions/{namespace}/{name}/screenshot": {
+		"POST": {
 			Actions: []v1.APIAction{
 				{
 					Verb:         v1.VerbRead,
-					ResourceType: v1.ResourceTemplates,
+					ResourceType: v1.ResourceAll,
 				},
+			},
+		},
+	},
+	"/api/desktops/{namespace}/{name}/logs/{container}": {
+		"GET": {
+			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbRead,
-					ResourceType: v1.ResourceUsers,
+					Verb:         v1.VerbUse,
+					ResourceType: v1.ResourceTemplates,
 				},
 			},
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
-		"POST": {
+	},
+	"/api/desktops/ws/{namespace}/{name}/logs/{container}": {
+		"GET": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbLaunch,
+					Verb:         v1.VerbUse,
 					ResourceType: v1.ResourceTemplates,
 				},
 			},
-			ResourceNameFunc: func(r *http.Request) string {
-				req := apiutil.GetRequestObject(r).(*v1.CreateSessionRequest)
-				return req.GetTemplate()
-			},
-			ResourceNamespaceFunc: func(r *http.Request) string {
-				req := apiutil.GetRequestObject(r).(*v1.CreateSessionRequest)
-				return req.GetNamespace()
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
+		},
+	},
+	"/api/desktops/ws/{namespace}/{name}/display": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUse,
+					ResourceType: v1.ResourceTemplates,
+				},
 			},
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/sessions/{namespace}/{name}": {
+	"/api/desktops/ws/{namespace}/{name}/view": {
 		"GET": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbRead,
+					Verb:         v1.VerbView,
 					ResourceType: v1.ResourceTemplates,
 				},
 			},
@@ -287,10 +1019,36 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 			OverrideFunc:          allowSessionOwner,
 		},
-		"DELETE": {
+	},
+	"/api/desktops/ws/{namespace}/{name}/shadow": {
+		"GET": {
 			Actions: []v1.APIAction{
 				{
-					Verb:         v1.VerbDelete,
+					Verb:         v1.VerbShadow,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
+			ResourceNameFunc:      apiutil.GetNameFromRequest,
+			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
+		},
+	},
+	"/api/desktops/ws/{namespace}/{name}/invites/{invite}": {
+		"GET": {
+			// access is granted by the invite instead of the user's rules
+			ExtraCheckFunc: denyUninvitedUser,
+		},
+	},
+	"/api/desktops/ws/{namespace}/{name}/viewtoken": {
+		"GET": {
+			// access is granted by the view token instead of the user's rules
+			ExtraCheckFunc: denyWithoutViewToken,
+		},
+	},
+	"/api/desktops/ws/{namespace}/{name}/settings": {
+		"GET": {
+			Actions: []v1.APIAction{
+				{
+					Verb:         v1.VerbUse,
 					ResourceType: v1.ResourceTemplates,
 				},
 			},
@@ -299,7 +1057,7 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/desktops/{namespace}/{name}/logs/{container}": {
+	"/api/desktops/ws/{namespace}/{name}/audio": {
 		"GET": {
 			Actions: []v1.APIAction{
 				{
@@ -312,7 +1070,7 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/desktops/ws/{namespace}/{name}/logs/{container}": {
+	"/api/desktops/ws/{namespace}/{name}/smartcard": {
 		"GET": {
 			Actions: []v1.APIAction{
 				{
@@ -325,7 +1083,7 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/desktops/ws/{namespace}/{name}/display": {
+	"/api/desktops/ws/{namespace}/{name}/printer": {
 		"GET": {
 			Actions: []v1.APIAction{
 				{
@@ -338,7 +1096,7 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 			OverrideFunc:          allowSessionOwner,
 		},
 	},
-	"/api/desktops/ws/{namespace}/{name}/audio": {
+	"/api/desktops/ws/{namespace}/{name}/usb": {
 		"GET": {
 			Actions: []v1.APIAction{
 				{
@@ -385,6 +1143,12 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 					ResourceType: v1.ResourceTemplates,
 				},
 			},
+			DLPActions: []v1.APIAction{
+				{
+					Verb:         v1.VerbFileDownload,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
 			ResourceNameFunc:      apiutil.GetNameFromRequest,
 			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 			OverrideFunc:          allowSessionOwner,
@@ -398,6 +1162,12 @@ var RouterGrantRequirements = map[string]map[string]MethodPermissions{
 					ResourceType: v1.ResourceTemplates,
 				},
 			},
+			DLPActions: []v1.APIAction{
+				{
+					Verb:         v1.VerbFileUpload,
+					ResourceType: v1.ResourceTemplates,
+				},
+			},
 			ResourceNameFunc:      apiutil.GetNameFromRequest,
 			ResourceNamespaceFunc: apiutil.GetNamespaceFromRequest,
 			OverrideFunc:          allowSessionOwner,
@@ -409,9 +1179,8 @@ func (d *desktopAPI) ValidateUserGrants(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		result := &AuditResult{Request: r}
 
-		// todo - can't use defer because websocket connections block and we won't
-		// see the log until the session is over
-		// // defer d.auditLog(result)
+		// The result is recorded on the request and written by the audit middleware
+		// once the response status is known.
 
 		// rertrieve the user and the path to match required grants
 		userSession := apiutil.GetRequestUserSession(r)
@@ -431,8 +1200,22 @@ func (d *desktopAPI) ValidateUserGrants(next http.Handler) http.Handler {
 			return
 		}
 
-		// Check if the route supports validating resource ownership
-		if methodGrant.OverrideFunc != nil {
+		// Data loss prevention checks apply to everyone, including resource owners
+		for _, action := range methodGrant.DLPActions {
+			apiAction := buildActionFromTemplate(methodGrant, action, r)
+			result.Actions = append(result.Actions, apiAction)
+			if !userSession.User.Evaluate(apiAction) {
+				msg := fmt.Sprintf("%s does not have the ability to %s", userSession.User.Name, apiAction.String())
+				apiutil.ReturnAPIForbidden(nil, msg, w)
+				result.Allowed = false
+				d.auditLog(result)
+				return
+			}
+		}
+
+		// Check if the route supports validating resource ownership. Owners are still
+		// subject to any restrictions on the session, e.g. from an API key.
+		if methodGrant.OverrideFunc != nil && restrictionsAllowActions(methodGrant, userSession.User, r) {
 			if allowed, owner, err := methodGrant.OverrideFunc(d, userSession.User, r); err != nil {
 				apiutil.ReturnAPIForbidden(err, "An error ocurred validating permission to the requested resource", w)
 				result.Allowed = false
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
@@ -19,6 +20,16 @@ func allowSameUser(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed
 	return allowed, true, err
 }
 
+// allowSameUserExceptPassword is the same as allowSameUser, except users cannot change
+// their own password without a grant to update users. They must use the password
+// route instead, which verifies their current password.
+func allowSameUserExceptPassword(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed, owner bool, err error) {
+	if reqObj, ok := apiutil.GetRequestObject(r).(*v1.UpdateUserRequest); ok && reqObj.Password != "" {
+		return false, false, nil
+	}
+	return allowSameUser(d, reqUser, r)
+}
+
 func allowSessionOwner(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed, owner bool, err error) {
 	nn := apiutil.GetNamespacedNameFromRequest(r)
 	found := &v1alpha1.Desktop{}
@@ -40,6 +51,136 @@ func allowSessionOwner(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (all
 	return true, true, nil
 }
 
+// denyUninvitedUser denies requests to attach to a desktop display without a valid
+// invite for the requesting user.
+func denyUninvitedUser(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed bool, reason string, err error) {
+	invite, err := d.getSessionInviteForRequest(r)
+	if err != nil {
+		return false, "", err
+	}
+	if invite == nil {
+		return false, "The invite does not exist or has expired", nil
+	}
+	return true, "", nil
+}
+
+// denyWithoutViewToken denies requests to watch a desktop display that were not
+// made with a valid view token for the desktop.
+func denyWithoutViewToken(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed bool, reason string, err error) {
+	viewToken, err := d.getViewTokenForRequest(r)
+	if err != nil {
+		return false, "", err
+	}
+	if viewToken == nil {
+		return false, "The view token does not exist or has expired", nil
+	}
+	return true, "", nil
+}
+
+// denyAPIKeySession denies requests authenticated with an API key. This is used
+// for routes that manage sessions and credentials.
+func denyAPIKeySession(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed bool, reason string, err error) {
+	if apiutil.GetRequestUserSession(r).APIKey != "" {
+		return false, "This route cannot be used with an API key", nil
+	}
+	return true, "", nil
+}
+
+// denyOtherUserPassword denies requests to change the password of any user other
+// than the one making the request. API keys are denied as well.
+func denyOtherUserPassword(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed bool, reason string, err error) {
+	if reqUser.Name != apiutil.GetUserFromRequest(r) {
+		return false, "Users can only change their own password", nil
+	}
+	return denyImpersonatedSession(d, reqUser, r)
+}
+
+// denyImpersonatedSession denies requests made with a token impersonating another
+// user, as well as API keys. This is used for routes that create credentials, so
+// access gained by impersonating a user cannot outlive the impersonation token.
+func denyImpersonatedSession(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed bool, reason string, err error) {
+	if apiutil.GetRequestUserSession(r).Impersonator != "" {
+		return false, "This route cannot be used while impersonating a user", nil
+	}
+	return denyAPIKeySession(d, reqUser, r)
+}
+
+// restrictionsAllowActions returns true if the restrictions on the user allow
+// all of the actions required for the route.
+func restrictionsAllowActions(perms MethodPermissions, reqUser *v1.VDIUser, r *http.Request) bool {
+	for _, action := range perms.Actions {
+		if !reqUser.RestrictionsAllow(buildActionFromTemplate(perms, action, r)) {
+			return false
+		}
+	}
+	return true
+}
+
 func allowAll(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed, owner bool, err error) {
 	return true, false, nil
 }
+
+// allowAnnouncementsForUser allows all users to read the announcements shown to
+// them. Listing all announcements falls through to the grants for the route.
+func allowAnnouncementsForUser(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed, owner bool, err error) {
+	return r.URL.Query().Get("all") != "true", false, nil
+}
+
+// denyTemplateBundleAccess checks that the user has access to every template and
+// role contained in a template bundle request.
+func denyTemplateBundleAccess(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed bool, reason string, err error) {
+	switch req := apiutil.GetRequestObject(r).(type) {
+	case *v1.ExportTemplatesRequest:
+		actions := make([]*v1.APIAction, 0)
+		for _, name := range req.GetTemplates() {
+			actions = append(actions, &v1.APIAction{Verb: v1.VerbRead, ResourceType: v1.ResourceTemplates, ResourceName: name})
+		}
+		if req.IncludeRoles {
+			actions = append(actions, &v1.APIAction{Verb: v1.VerbRead, ResourceType: v1.ResourceRoles})
+		}
+		return evaluateActions(reqUser, actions)
+
+	case *v1alpha1.ImportTemplateBundleRequest:
+		actions := make([]*v1.APIAction, 0)
+		for _, tmpl := range req.GetTemplates() {
+			actions = append(actions, &v1.APIAction{Verb: v1.VerbCreate, ResourceType: v1.ResourceTemplates, ResourceName: tmpl.GetName()})
+			if req.Overwrite {
+				actions = append(actions, &v1.APIAction{Verb: v1.VerbUpdate, ResourceType: v1.ResourceTemplates, ResourceName: tmpl.GetName()})
+			}
+		}
+		for _, role := range req.GetRoles() {
+			actions = append(actions, &v1.APIAction{Verb: v1.VerbCreate, ResourceType: v1.ResourceRoles, ResourceName: role.GetName()})
+			if req.Overwrite {
+				actions = append(actions, &v1.APIAction{Verb: v1.VerbUpdate, ResourceType: v1.ResourceRoles, ResourceName: role.GetName()})
+			}
+		}
+		if allowed, reason, err := evaluateActions(reqUser, actions); !allowed || err != nil {
+			return allowed, reason, err
+		}
+		// make sure the imported roles don't grant more than the user has
+		return denyUserElevatePerms(d, reqUser, r)
+	}
+
+	return false, "Could not determine the resources in the request", nil
+}
+
+// denyPermissionEvaluationAccess makes sure the requesting user can read the role
+// or user they are evaluating permissions for.
+func denyPermissionEvaluationAccess(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed bool, reason string, err error) {
+	req := apiutil.GetRequestObject(r).(*v1.EvaluatePermissionRequest)
+	if req.Role != "" {
+		return evaluateActions(reqUser, []*v1.APIAction{{Verb: v1.VerbRead, ResourceType: v1.ResourceRoles, ResourceName: req.Role}})
+	}
+	return evaluateActions(reqUser, []*v1.APIAction{{Verb: v1.VerbRead, ResourceType: v1.ResourceUsers, ResourceName: req.User}})
+}
+
+// evaluateActions returns false with a reason for the first action the user is
+// not allowed to perform.
+func evaluateActions(reqUser *v1.VDIUser, actions []*v1.APIAction) (allowed bool, reason string, err error) {
+	for _, action := range actions {
+		if !reqUser.Evaluate(action) {
+			return false, fmt.Sprintf("%s does not have the ability to %s", reqUser.Name, action.String()), nil
+		}
+	}
+	return true, "", nil
+}
@@ -13,14 +13,16 @@ var elevateDenyReason = "The requested operation grants more privileges than the
 func denyUserElevatePerms(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (allowed bool, reason string, err error) {
 
 	// This is an ugly hack at the moment. This will be triggered if called from
-	// allowSameUser while configuring MFA options. No need to check.
-	if apiutil.GetGorillaPath(r) == "/api/users/{user}/mfa" {
+	// allowSameUser while configuring MFA options, first-boot scripts, dotfiles, or
+	// keyboard layouts, or while reading login history. No need to check.
+	switch apiutil.GetGorillaPath(r) {
+	case "/api/users/{user}/mfa", "/api/users/{user}/mfa/verify", "/api/users/{user}/userdata", "/api/users/{user}/dotfiles", "/api/users/{user}/keyboard", "/api/users/{user}/logins":
 		return true, "", nil
 	}
 
 	// Check that a POST /users will not grant permissions the user does not have.
 	if reqObj, ok := apiutil.GetRequestObject(r).(*v1.CreateUserRequest); ok {
-		vdiRoles, err := d.vdiCluster.GetRoles(d.client)
+		vdiRoles, err := d.vdiCluster.GetResolvedRoles(d.client)
 		if err != nil {
 			return false, "", err
 		}
@@ -29,7 +31,7 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (
 			if roleObj == nil {
 				continue
 			}
-			for _, rule := range roleObj.GetRules() {
+			for _, rule := range roleObj.GetEffectiveRules() {
 				if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
 					return false, elevateDenyReason, nil
 				}
@@ -40,7 +42,7 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (
 
 	// Check that a PUT /users/{user} will not grant permissions the user does not have.
 	if reqObj, ok := apiutil.GetRequestObject(r).(*v1.UpdateUserRequest); ok {
-		vdiRoles, err := d.vdiCluster.GetRoles(d.client)
+		vdiRoles, err := d.vdiCluster.GetResolvedRoles(d.client)
 		if err != nil {
 			return false, "", err
 		}
@@ -49,7 +51,7 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (
 			if roleObj == nil {
 				continue
 			}
-			for _, rule := range roleObj.GetRules() {
+			for _, rule := range roleObj.GetEffectiveRules() {
 				if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
 					return false, elevateDenyReason, nil
 				}
@@ -58,9 +60,77 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (
 		return true, "", nil
 	}
 
-	// Check that a POST /roles will not grant permissions the user does not have.
+	// Check that a POST /users/import will not grant any of the imported users
+	// permissions the user does not have. This applies to imports run as jobs as well.
+	if reqObj, ok := apiutil.GetRequestObject(r).(*v1.ImportUsersRequest); ok {
+		vdiRoles, err := d.vdiCluster.GetResolvedRoles(d.client)
+		if err != nil {
+			return false, "", err
+		}
+		for _, record := range reqObj.Users {
+			for _, role := range record.Roles {
+				roleObj := getRoleByName(vdiRoles, role)
+				if roleObj == nil {
+					continue
+				}
+				for _, rule := range roleObj.GetEffectiveRules() {
+					if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
+						return false, elevateDenyReason, nil
+					}
+				}
+			}
+		}
+		return true, "", nil
+	}
+
+	// Check that a POST /groups will not grant its members permissions the user does not have.
+	if reqObj, ok := apiutil.GetRequestObject(r).(*v1.CreateGroupRequest); ok {
+		vdiRoles, err := d.vdiCluster.GetResolvedRoles(d.client)
+		if err != nil {
+			return false, "", err
+		}
+		for _, role := range reqObj.Roles {
+			roleObj := getRoleByName(vdiRoles, role)
+			if roleObj == nil {
+				continue
+			}
+			for _, rule := range roleObj.GetEffectiveRules() {
+				if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
+					return false, elevateDenyReason, nil
+				}
+			}
+		}
+		return true, "", nil
+	}
+
+	// Check that a PUT /groups/{group} will not grant its members permissions the user does not have.
+	if reqObj, ok := apiutil.GetRequestObject(r).(*v1.UpdateGroupRequest); ok {
+		vdiRoles, err := d.vdiCluster.GetResolvedRoles(d.client)
+		if err != nil {
+			return false, "", err
+		}
+		for _, role := range reqObj.Roles {
+			roleObj := getRoleByName(vdiRoles, role)
+			if roleObj == nil {
+				continue
+			}
+			for _, rule := range roleObj.GetEffectiveRules() {
+				if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
+					return false, elevateDenyReason, nil
+				}
+			}
+		}
+		return true, "", nil
+	}
+
+	// Check that a POST /roles will not grant permissions the user does not have,
+	// including through the roles it inherits from.
 	if reqObj, ok := apiutil.GetRequestObject(r).(*v1.CreateRoleRequest); ok {
-		for _, rule := range reqObj.GetRules() {
+		rules, err := d.getResolvedRules(d.newRoleFromRequest(reqObj))
+		if err != nil {
+			return false, "", err
+		}
+		for _, rule := range rules {
 			if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
 				return false, elevateDenyReason, nil
 			}
@@ -68,9 +138,48 @@ func denyUserElevatePerms(d *desktopAPI, reqUser *v1.VDIUser, r *http.Request) (
 		return true, "", nil
 	}
 
-	// Check that a PUT /roles/{role} will not grant permissions the user does not have.
+	// Check that a PUT /roles/{role} will not grant permissions the user does not have,
+	// including through the roles it inherits from.
 	if reqObj, ok := apiutil.GetRequestObject(r).(*v1.UpdateRoleRequest); ok {
-		for _, rule := range reqObj.GetRules() {
+		role := &v1alpha1.VDIRole{Rules: reqObj.GetRules(), Inherits: reqObj.Inherits}
+		role.SetName(apiutil.GetRoleFromRequest(r))
+		rules, err := d.getResolvedRules(role)
+		if err != nil {
+			return false, "", err
+		}
+		for _, rule := range rules {
+			if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
+				return false, elevateDenyReason, nil
+			}
+		}
+		return true, "", nil
+	}
+
+	// Check that a POST /roles/{role}/grants will not grant permissions the user does not have.
+	if _, ok := apiutil.GetRequestObject(r).(*v1.CreateRoleGrantRequest); ok {
+		vdiRoles, err := d.vdiCluster.GetResolvedRoles(d.client)
+		if err != nil {
+			return false, "", err
+		}
+		roleObj := getRoleByName(vdiRoles, apiutil.GetRoleFromRequest(r))
+		if roleObj == nil {
+			return true, "", nil
+		}
+		for _, rule := range roleObj.GetEffectiveRules() {
+			if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
+				return false, elevateDenyReason, nil
+			}
+		}
+		return true, "", nil
+	}
+
+	// Check that a POST /templates/import will not create roles granting permissions the user does not have.
+	if reqObj, ok := apiutil.GetRequestObject(r).(*v1alpha1.ImportTemplateBundleRequest); ok {
+		rules, err := d.getResolvedRules(reqObj.GetRoles()...)
+		if err != nil {
+			return false, "", err
+		}
+		for _, rule := range rules {
 			if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
 				return false, elevateDenyReason, nil
 			}
@@ -2,13 +2,14 @@ package api
 
 import (
 	"net/http"
+	"strings"
 
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 )
 
-// ValidateUserSession retrieves the JWT token from the X-Session-Token and
-// verifies that it is valid.
+// ValidateUserSession retrieves the JWT token or API key from the X-Session-Token
+// and verifies that it is valid.
 func (d *desktopAPI) ValidateUserSession(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// get the auth token
@@ -28,30 +29,89 @@ func (d *desktopAPI) ValidateUserSession(next http.Handler) http.Handler {
 			return
 		}
 
-		// retrieve the jwt secret
-		jwtSecret, err := d.secrets.ReadSecret(v1.JWTSecretKey, true)
-		if err != nil {
-			apiutil.ReturnAPIError(err, w)
-			return
+		var session *v1.JWTClaims
+		if strings.HasPrefix(authToken, v1.APIKeyTokenPrefix) {
+			// verify the api key and retrieve the user it belongs to
+			var err error
+			session, err = d.getSessionFromAPIKey(authToken)
+			if err != nil {
+				apiutil.ReturnAPIForbidden(nil, err.Error(), w)
+				return
+			}
+		} else {
+			// retrieve the jwt signing keys
+			keys, err := d.getJWTKeys(true)
+			if err != nil {
+				apiutil.ReturnAPIError(err, w)
+				return
+			}
+
+			// verify the token and retrieve the claims
+			session, err = d.decodeAndVerifyJWT(keys, authToken)
+			if err != nil {
+				apiutil.ReturnAPIForbidden(nil, err.Error(), w)
+				return
+			}
+
+			// make sure the token was not revoked before it expired
+			revoked, err := d.revoked.IsRevoked(session)
+			if err != nil {
+				apiutil.ReturnAPIError(err, w)
+				return
+			}
+			if revoked {
+				apiutil.ReturnAPIForbidden(nil, "Token provided in the request has been revoked", w)
+				return
+			}
 		}
 
-		// verify the token and retrieve the claims
-		session, err := apiutil.DecodeAndVerifyJWT(jwtSecret, authToken)
-		if err != nil {
-			apiutil.ReturnAPIForbidden(nil, err.Error(), w)
+		// only let requests to authorize a token with mfa, or to enroll in mfa
+		// when the user's roles require it, go through
+		if !session.Authorized && !isAuthorizeRequest(r) && !d.isMFAEnrollmentRequest(r, session) {
+			apiutil.ReturnAPIForbidden(nil, "User session is not authorized", w)
 			return
 		}
 
-		// let requests to authorize a token with mfa to go through
-		if !session.Authorized && apiutil.GetGorillaPath(r) != "/api/authorize" && r.Method != http.MethodPost {
-			apiutil.ReturnAPIForbidden(nil, "User session is not authorized", w)
+		// view tokens can only be used to watch the display they were created for
+		if session.ViewToken != "" && !isViewTokenRequest(r) {
+			apiutil.ReturnAPIForbidden(nil, "View tokens can only be used to watch a desktop display", w)
 			return
 		}
 
 		// Set the request user object with a pointer to the decoded user session
 		apiutil.SetRequestUserSession(r, session)
+		setAccessLogUser(r, session.User.GetName())
+		setAuditUser(r, session.User.GetName())
+		setAuditImpersonator(r, session.Impersonator)
+		setTracingUser(r, session.User.GetName())
 
 		// serve the next handler
 		next.ServeHTTP(w, r)
 	})
 }
+
+// isMFAEnrollmentRequest returns true if the request is for a user whose roles
+// require MFA to set up their own OTP secret before authorizing their token.
+func (d *desktopAPI) isMFAEnrollmentRequest(r *http.Request, session *v1.JWTClaims) bool {
+	path := apiutil.GetGorillaPath(r)
+	switch {
+	case path == "/api/users/{user}/mfa" && (r.Method == http.MethodGet || r.Method == http.MethodPut):
+	case path == "/api/users/{user}/mfa/verify" && r.Method == http.MethodPut:
+	default:
+		return false
+	}
+	if apiutil.GetUserFromRequest(r) != session.User.GetName() {
+		return false
+	}
+	required, err := d.mfaEnrollmentRequired(session.User)
+	return err == nil && required
+}
+
+// isAuthorizeRequest returns true if the request is to authorize a token with MFA.
+func isAuthorizeRequest(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	path := apiutil.GetGorillaPath(r)
+	return path == "/api/authorize" || path == "/api/authorize/push"
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// viewTokenRoute is the only route view tokens may be used with.
+const viewTokenRoute = "/api/desktops/ws/{namespace}/{name}/viewtoken"
+
+// getViewTokens returns the view tokens on the given desktop that have not expired.
+func getViewTokens(desktop *v1alpha1.Desktop) ([]*v1.ViewToken, error) {
+	tokens := make([]*v1.ViewToken, 0)
+	raw, ok := desktop.GetAnnotations()[v1.ViewTokensAnnotation]
+	if !ok || raw == "" {
+		return tokens, nil
+	}
+	all := make([]*v1.ViewToken, 0)
+	if err := json.Unmarshal([]byte(raw), &all); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	for _, token := range all {
+		if token.ExpiresAt > now {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// setViewTokens writes the given view tokens to the desktop.
+func (d *desktopAPI) setViewTokens(desktop *v1alpha1.Desktop, tokens []*v1.ViewToken) error {
+	annotations := desktop.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if len(tokens) == 0 {
+		delete(annotations, v1.ViewTokensAnnotation)
+	} else {
+		out, err := json.Marshal(tokens)
+		if err != nil {
+			return err
+		}
+		annotations[v1.ViewTokensAnnotation] = string(out)
+	}
+	desktop.SetAnnotations(annotations)
+	return d.client.Update(context.TODO(), desktop)
+}
+
+// getViewTokenForRequest returns the view token the request was authenticated with
+// if it was created for the desktop in the request path and has not expired or been
+// revoked. Nil is returned otherwise.
+func (d *desktopAPI) getViewTokenForRequest(r *http.Request) (*v1.ViewToken, error) {
+	id := apiutil.GetRequestUserSession(r).ViewToken
+	if id == "" {
+		return nil, nil
+	}
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := getViewTokens(desktop)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if token.ID == id {
+			return token, nil
+		}
+	}
+	return nil, nil
+}
+
+// isViewTokenRequest returns true if the request is to watch a display with a
+// view token.
+func isViewTokenRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && apiutil.GetGorillaPath(r) == viewTokenRoute
+}
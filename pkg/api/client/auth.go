@@ -1,9 +1,7 @@
 package client
 
 import (
-	"bytes"
 	"encoding/json"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
@@ -13,36 +11,26 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 )
 
+// mfaPushPollInterval is how often the client checks if the user approved an MFA
+// push.
+const mfaPushPollInterval = 2 * time.Second
+
 // authenticate retrieves an access token for the API and starts a goroutine
-// to refresh the token as needed.
+// to refresh the token as needed. When an API key is configured, it is used as
+// the access token directly.
 func (c *Client) authenticate() error {
+	if c.opts.APIKey != "" {
+		c.setAccessToken(c.opts.APIKey)
+		return nil
+	}
 	loginRequest := &v1.LoginRequest{
 		Username: c.opts.Username,
 		Password: c.opts.Password,
 		State:    uuid.New().String(),
 	}
-	payload, err := json.Marshal(loginRequest)
-	if err != nil {
-		return err
-	}
-	res, err := c.httpClient.Post(c.getEndpoint("login"), "application/json", bytes.NewReader(payload))
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return err
-	}
-
-	if res.StatusCode != http.StatusOK {
-		return c.returnAPIError(body)
-	}
 
 	sessionResponse := &v1.SessionResponse{}
-
-	if err := json.Unmarshal(body, sessionResponse); err != nil {
+	if err := c.do(http.MethodPost, "login", loginRequest, sessionResponse); err != nil {
 		return err
 	}
 
@@ -52,6 +40,19 @@ func (c *Client) authenticate() error {
 
 	c.setAccessToken(sessionResponse.Token)
 
+	if sessionResponse.MFAEnrollmentRequired {
+		return errors.New("The user's roles require MFA, but it has not been set up for them yet")
+	}
+
+	if !sessionResponse.Authorized {
+		var err error
+		sessionResponse, err = c.authorize(sessionResponse.MFAMethod, loginRequest.State)
+		if err != nil {
+			return err
+		}
+		c.setAccessToken(sessionResponse.Token)
+	}
+
 	if sessionResponse.Renewable {
 		c.stopCh = make(chan struct{})
 		go c.runTokenRefreshLoop(sessionResponse)
@@ -60,6 +61,60 @@ func (c *Client) authenticate() error {
 	return nil
 }
 
+// authorize completes the MFA challenge for the current access token and returns
+// the authorized session.
+func (c *Client) authorize(method, state string) (*v1.SessionResponse, error) {
+	if method == v1.MFAMethodPush {
+		return c.authorizePush(state)
+	}
+	if c.opts.OTPFunc == nil {
+		return nil, errors.New("The user requires MFA, but no OTPFunc was provided to the client")
+	}
+	otp, err := c.opts.OTPFunc()
+	if err != nil {
+		return nil, err
+	}
+	sessionResponse := &v1.SessionResponse{}
+	if err := c.do(http.MethodPost, "authorize", &v1.AuthorizeRequest{OTP: otp, State: state}, sessionResponse); err != nil {
+		return nil, err
+	}
+	if sessionResponse.State != state {
+		return nil, errors.New("State was malformed during authorization flow, your request might have been intercepted")
+	}
+	return sessionResponse, nil
+}
+
+// authorizePush sends an MFA push to the user and waits for them to approve it.
+func (c *Client) authorizePush(state string) (*v1.SessionResponse, error) {
+	push := &v1.MFAPushResponse{}
+	if err := c.do(http.MethodPost, "authorize/push", nil, push); err != nil {
+		return nil, err
+	}
+	req := &v1.AuthorizeRequest{Transaction: push.Transaction, State: state}
+	for {
+		status, body, err := c.doRaw(http.MethodPost, "authorize", req)
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case http.StatusOK:
+			sessionResponse := &v1.SessionResponse{}
+			if err := json.Unmarshal(body, sessionResponse); err != nil {
+				return nil, err
+			}
+			if sessionResponse.State != state {
+				return nil, errors.New("State was malformed during authorization flow, your request might have been intercepted")
+			}
+			return sessionResponse, nil
+		case http.StatusAccepted:
+			// the push is still pending, denied pushes are returned as errors
+			time.Sleep(mfaPushPollInterval)
+		default:
+			return nil, c.returnAPIError(body)
+		}
+	}
+}
+
 // runTokenRefreshLoop is used as a goroutine to request a new access token when the
 // current one is about to expire.
 func (c *Client) runTokenRefreshLoop(session *v1.SessionResponse) {
@@ -85,23 +140,20 @@ func (c *Client) runTokenRefreshLoop(session *v1.SessionResponse) {
 	}
 }
 
-// refreshToken performs a refresh_token request and returns the response or any error.
-func (c *Client) refreshToken() (*v1.SessionResponse, error) {
-	res, err := c.httpClient.Get(c.getEndpoint("refresh_token"))
+// RefreshToken requests a new access token for the client. Tokens are refreshed
+// automatically before they expire, this can be used to pick up changes to the
+// user's roles immediately.
+func (c *Client) RefreshToken() error {
+	session, err := c.refreshToken()
 	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if res.StatusCode != http.StatusOK {
-		return nil, c.returnAPIError(body)
+		return err
 	}
+	c.setAccessToken(session.Token)
+	return nil
+}
 
+// refreshToken performs a refresh_token request and returns the response or any error.
+func (c *Client) refreshToken() (*v1.SessionResponse, error) {
 	sessionResponse := &v1.SessionResponse{}
-	return sessionResponse, json.Unmarshal(body, sessionResponse)
+	return sessionResponse, c.do(http.MethodGet, "refresh_token", nil, sessionResponse)
 }
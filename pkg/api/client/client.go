@@ -8,6 +8,13 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"strings"
+	"time"
+)
+
+// Defaults for retrying requests that fail with a temporary error.
+const (
+	defaultMaxRetries    = 3
+	defaultRetryInterval = time.Second
 )
 
 // Client provides a REST wrapper to the kVDI API.
@@ -16,6 +23,8 @@ type Client struct {
 	opts *Opts
 	// the http client used for sending requests
 	httpClient *http.Client
+	// the tls configuration used for websocket connections, nil for plain http
+	tlsConfig *tls.Config
 	// the current access token for performing requests
 	accessToken string
 	// a stop channel for the refresh_token loop
@@ -30,8 +39,7 @@ type Opts struct {
 	Username string
 	// The password to use to authenticate.
 	Password string
-	// TODO: Allow for API keys tied to roles for auth providers that don't allow us
-	// to independently verify credentials (e.g. OpenID).
+	// An API key to authenticate with instead of a username and password.
 	APIKey string
 	// The PEM encoded CA certificate to use when validating the kVDI server certificate.
 	// When using the generated certificate, this can be found in the kvdi-app
@@ -39,6 +47,36 @@ type Opts struct {
 	TLSCACert []byte
 	// Set to true to skip TLS verification.
 	TLSInsecureSkipVerify bool
+	// Called to retrieve a one-time password when the user has MFA enabled. It is
+	// not required when the cluster uses push MFA, in which case the client waits
+	// for the user to approve the push.
+	OTPFunc func() (string, error)
+	// The number of times to retry requests that fail with a temporary error.
+	// Requests that are not idempotent are only retried when they could not be
+	// sent. Defaults to 3, set to a negative value to disable retries.
+	MaxRetries int
+	// How long to wait before the first retry. The interval is doubled for each
+	// subsequent attempt. Defaults to 1 second.
+	RetryInterval time.Duration
+}
+
+// getMaxRetries returns the number of times to retry failed requests.
+func (o *Opts) getMaxRetries() int {
+	if o.MaxRetries < 0 {
+		return 0
+	}
+	if o.MaxRetries == 0 {
+		return defaultMaxRetries
+	}
+	return o.MaxRetries
+}
+
+// getRetryInterval returns how long to wait before the first retry.
+func (o *Opts) getRetryInterval() time.Duration {
+	if o.RetryInterval <= 0 {
+		return defaultRetryInterval
+	}
+	return o.RetryInterval
 }
 
 // New creates a new kVDI client.
@@ -67,6 +105,7 @@ func New(opts *Opts) (*Client, error) {
 		cl.httpClient.Transport = &http.Transport{
 			TLSClientConfig: tlsConfig,
 		}
+		cl.tlsConfig = tlsConfig
 	}
 
 	return cl, cl.authenticate()
@@ -77,6 +116,10 @@ func (c *Client) Close() {
 	if c.stopCh != nil {
 		c.stopCh <- struct{}{}
 	}
+	// API keys are not tied to a session
+	if c.opts.APIKey != "" {
+		return
+	}
 	if err := c.do(http.MethodPost, "logout", nil, nil); err != nil {
 		log.Println("Error posting to /api/logout. Refresh token could not be revoked:", err)
 	}
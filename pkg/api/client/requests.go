@@ -2,7 +2,10 @@ package client
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
@@ -16,6 +19,20 @@ func (c *Client) GetServerConfig() (*v1alpha1.VDIClusterSpec, error) {
 	return spec, c.do(http.MethodGet, "config", nil, spec)
 }
 
+// ReloadServerConfig forces the server to re-sync its configuration with the
+// VDICluster and returns the resulting configuration.
+func (c *Client) ReloadServerConfig() (*v1alpha1.VDIClusterSpec, error) {
+	spec := &v1alpha1.VDIClusterSpec{}
+	return spec, c.do(http.MethodPost, "config/reload", nil, spec)
+}
+
+// GetClusterStatus returns the conditions of the VDICluster and the health of
+// the components the server depends on.
+func (c *Client) GetClusterStatus() (*v1alpha1.ClusterStatusResponse, error) {
+	status := &v1alpha1.ClusterStatusResponse{}
+	return status, c.do(http.MethodGet, "status", nil, status)
+}
+
 // GetNamespaces retrieves a list of namespaces the current user has access to.
 func (c *Client) GetNamespaces() ([]string, error) {
 	nss := make([]string, 0)
@@ -37,7 +54,122 @@ func (c *Client) GetDesktopSessions() (*v1.DesktopSessionsResponse, error) {
 	return resp, c.do(http.MethodGet, "sessions", nil, resp)
 }
 
-// TODO: Should Create,Use,Delete desktop sessions be implemented?
+// ListDesktopSessions retrieves a filtered and sorted page of desktop sessions.
+func (c *Client) ListDesktopSessions(opts *v1.ListOptions) (*v1.DesktopSessionsResponse, error) {
+	resp := &v1.DesktopSessionsResponse{}
+	return resp, c.do(http.MethodGet, getListEndpoint("sessions", opts), nil, resp)
+}
+
+// CreateDesktopSession starts a new desktop session and returns its name and
+// namespace.
+func (c *Client) CreateDesktopSession(req *v1.CreateSessionRequest) (*v1.CreateSessionResponse, error) {
+	resp := &v1.CreateSessionResponse{}
+	return resp, c.do(http.MethodPost, "sessions", req, resp)
+}
+
+// GetDesktopSessionStatus retrieves the status of the given desktop session.
+func (c *Client) GetDesktopSessionStatus(namespace, name string) (*v1alpha1.DesktopSessionStatusResponse, error) {
+	resp := &v1alpha1.DesktopSessionStatusResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("sessions/%s/%s", namespace, name), nil, resp)
+}
+
+// DeleteDesktopSession stops the given desktop session.
+func (c *Client) DeleteDesktopSession(namespace, name string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("sessions/%s/%s", namespace, name), nil, nil)
+}
+
+// StreamSessionLogs retrieves the logs of the containers in the given desktop
+// session. When following, the reader returns lines as they are written until it
+// is closed. The caller is responsible for closing the returned reader.
+func (c *Client) StreamSessionLogs(namespace, name string, opts *v1.SessionLogOptions) (io.ReadCloser, error) {
+	endpoint := fmt.Sprintf("sessions/%s/%s/logs", namespace, name)
+	if opts != nil {
+		endpoint = fmt.Sprintf("%s?%s", endpoint, opts.Encode().Encode())
+	}
+	return c.doStream(http.MethodGet, endpoint)
+}
+
+// GetSessionInvites retrieves the active invites for the given desktop session.
+func (c *Client) GetSessionInvites(namespace, name string) (*v1.SessionInvitesResponse, error) {
+	resp := &v1.SessionInvitesResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("sessions/%s/%s/invites", namespace, name), nil, resp)
+}
+
+// CreateSessionInvite invites another user to attach to the given desktop session.
+func (c *Client) CreateSessionInvite(namespace, name string, req *v1.CreateSessionInviteRequest) (*v1.SessionInvite, error) {
+	resp := &v1.SessionInvite{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/invites", namespace, name), req, resp)
+}
+
+// DeleteSessionInvite revokes the invite with the given ID.
+func (c *Client) DeleteSessionInvite(namespace, name, id string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("sessions/%s/%s/invites/%s", namespace, name, id), nil, nil)
+}
+
+// CreateSessionHandoff creates a one-time code for picking up the display of the
+// given desktop session on another device with PickUpDisplay.
+func (c *Client) CreateSessionHandoff(namespace, name string) (*v1.SessionHandoffResponse, error) {
+	resp := &v1.SessionHandoffResponse{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/handoff", namespace, name), nil, resp)
+}
+
+// GetSessionViewTokens retrieves the active view tokens for the given desktop session.
+func (c *Client) GetSessionViewTokens(namespace, name string) (*v1.ViewTokensResponse, error) {
+	resp := &v1.ViewTokensResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("sessions/%s/%s/viewtokens", namespace, name), nil, resp)
+}
+
+// CreateSessionViewToken creates a read-only view token for the given desktop session.
+func (c *Client) CreateSessionViewToken(namespace, name string, req *v1.CreateViewTokenRequest) (*v1.CreateViewTokenResponse, error) {
+	resp := &v1.CreateViewTokenResponse{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/viewtokens", namespace, name), req, resp)
+}
+
+// DeleteSessionViewToken revokes the view token with the given ID.
+func (c *Client) DeleteSessionViewToken(namespace, name, id string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("sessions/%s/%s/viewtokens/%s", namespace, name, id), nil, nil)
+}
+
+// Impersonate retrieves a short-lived token for acting as the given user. The
+// token is not used by the client, and cannot be renewed.
+func (c *Client) Impersonate(username string, req *v1.ImpersonateRequest) (*v1.SessionResponse, error) {
+	resp := &v1.SessionResponse{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("impersonate/%s", username), req, resp)
+}
+
+// CreateSessionSnapshot takes a snapshot of the userdata volume of the given desktop
+// session. New sessions can be restored from it once it is ready to use.
+func (c *Client) CreateSessionSnapshot(namespace, name string) (*v1.DesktopSnapshot, error) {
+	resp := &v1.DesktopSnapshot{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/snapshots", namespace, name), nil, resp)
+}
+
+// CreateSessionSnapshotAsync takes a snapshot of the userdata volume of the given
+// desktop session in a job. The job completes with the snapshot once it is ready to use.
+func (c *Client) CreateSessionSnapshotAsync(namespace, name string) (*v1.Job, error) {
+	resp := &v1.Job{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/snapshots?%s=true", namespace, name, v1.AsyncQueryParam), nil, resp)
+}
+
+// UploadSessionRecordings uploads the finished recordings of the given desktop
+// session in a job. The job completes with the IDs of the uploaded recordings.
+func (c *Client) UploadSessionRecordings(namespace, name string) (*v1.Job, error) {
+	resp := &v1.Job{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("sessions/%s/%s/recordings", namespace, name), nil, resp)
+}
+
+// DrainNode notifies the users of desktops running on the given node and migrates
+// or terminates their desktops once the grace period passes.
+func (c *Client) DrainNode(node string, req *v1.DrainNodeRequest) (*v1.NodeDrainStatus, error) {
+	resp := &v1.NodeDrainStatus{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("admin/nodes/%s/drain", node), req, resp)
+}
+
+// GetNodeDrainStatus retrieves the progress of draining desktops from the given node.
+func (c *Client) GetNodeDrainStatus(node string) (*v1.NodeDrainStatus, error) {
+	resp := &v1.NodeDrainStatus{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("admin/nodes/%s/drain", node), nil, resp)
+}
 
 // VDIRole functions
 
@@ -48,6 +180,17 @@ func (c *Client) GetVDIRoles() ([]*v1alpha1.VDIRole, error) {
 	return resp, c.do(http.MethodGet, "roles", nil, &resp)
 }
 
+// ListVDIRoles retrieves a filtered and sorted page of VDIRoles. A limit or
+// continue token must be set in the options.
+func (c *Client) ListVDIRoles(opts *v1.ListOptions) ([]*v1alpha1.VDIRole, *v1.ListMeta, error) {
+	roles := make([]*v1alpha1.VDIRole, 0)
+	resp := &v1.ListResponse{Items: &roles}
+	if err := c.do(http.MethodGet, getListEndpoint("roles", opts), nil, resp); err != nil {
+		return nil, nil, err
+	}
+	return roles, resp.Metadata, nil
+}
+
 // CreateVDIRole creates  a new VDIRole for this cluster.
 func (c *Client) CreateVDIRole(req *v1.CreateRoleRequest) error {
 	return c.do(http.MethodPost, "roles", req, nil)
@@ -70,6 +213,109 @@ func (c *Client) DeleteVDIRole(name string) error {
 	return c.do(http.MethodDelete, fmt.Sprintf("roles/%s", name), nil, nil)
 }
 
+// GetVDIRoleGrants retrieves the users the given VDIRole is temporarily granted to.
+func (c *Client) GetVDIRoleGrants(name string) (*v1.RoleGrantsResponse, error) {
+	resp := &v1.RoleGrantsResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("roles/%s/grants", name), nil, resp)
+}
+
+// CreateVDIRoleGrant temporarily grants the given VDIRole to a user.
+func (c *Client) CreateVDIRoleGrant(name string, req *v1.CreateRoleGrantRequest) (*v1.RoleGrant, error) {
+	resp := &v1.RoleGrant{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("roles/%s/grants", name), req, resp)
+}
+
+// DeleteVDIRoleGrant revokes the temporary grant of the given VDIRole to a user.
+func (c *Client) DeleteVDIRoleGrant(name, user string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("roles/%s/grants/%s", name, user), nil, nil)
+}
+
+// EvaluateVDIPermission evaluates whether a user or VDIRole would be allowed to
+// perform an action, and which rule allows it.
+func (c *Client) EvaluateVDIPermission(req *v1.EvaluatePermissionRequest) (*v1.PermissionEvaluation, error) {
+	resp := &v1.PermissionEvaluation{}
+	return resp, c.do(http.MethodPost, "roles/evaluate", req, resp)
+}
+
+// VDIGroup functions
+
+// GetVDIGroups retrieves the available VDIGroups for kVDI. This is the same as doing
+// `kubectl get vdigroups -l "kvdi.io/cluster-ref=kvdi" -o json`.
+func (c *Client) GetVDIGroups() ([]*v1alpha1.VDIGroup, error) {
+	resp := make([]*v1alpha1.VDIGroup, 0)
+	return resp, c.do(http.MethodGet, "groups", nil, &resp)
+}
+
+// ListVDIGroups retrieves a filtered and sorted page of VDIGroups. A limit or
+// continue token must be set in the options.
+func (c *Client) ListVDIGroups(opts *v1.ListOptions) ([]*v1alpha1.VDIGroup, *v1.ListMeta, error) {
+	groups := make([]*v1alpha1.VDIGroup, 0)
+	resp := &v1.ListResponse{Items: &groups}
+	if err := c.do(http.MethodGet, getListEndpoint("groups", opts), nil, resp); err != nil {
+		return nil, nil, err
+	}
+	return groups, resp.Metadata, nil
+}
+
+// CreateVDIGroup creates a new VDIGroup for this cluster.
+func (c *Client) CreateVDIGroup(req *v1.CreateGroupRequest) error {
+	return c.do(http.MethodPost, "groups", req, nil)
+}
+
+// GetVDIGroup retrieves a single VDIGroup in kVDI by its name.
+func (c *Client) GetVDIGroup(name string) (*v1alpha1.VDIGroup, error) {
+	group := &v1alpha1.VDIGroup{}
+	return group, c.do(http.MethodGet, fmt.Sprintf("groups/%s", name), nil, group)
+}
+
+// UpdateVDIGroup will update a VDIGroup. The existing members and roles are replaced
+// by those in the request, even if nil or unset.
+func (c *Client) UpdateVDIGroup(name string, req *v1.UpdateGroupRequest) error {
+	return c.do(http.MethodPut, fmt.Sprintf("groups/%s", name), req, nil)
+}
+
+// DeleteVDIGroup will delete the given VDIGroup.
+func (c *Client) DeleteVDIGroup(name string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("groups/%s", name), nil, nil)
+}
+
+// Announcement functions
+
+// GetAnnouncements retrieves the announcements currently shown to the user.
+func (c *Client) GetAnnouncements() ([]*v1.Announcement, error) {
+	resp := make([]*v1.Announcement, 0)
+	return resp, c.do(http.MethodGet, "announcements", nil, &resp)
+}
+
+// GetAllAnnouncements retrieves all the announcements, including those not currently
+// shown to the user.
+func (c *Client) GetAllAnnouncements() ([]*v1.Announcement, error) {
+	resp := make([]*v1.Announcement, 0)
+	return resp, c.do(http.MethodGet, "announcements?all=true", nil, &resp)
+}
+
+// CreateAnnouncement creates a new announcement for this cluster.
+func (c *Client) CreateAnnouncement(req *v1.CreateAnnouncementRequest) error {
+	return c.do(http.MethodPost, "announcements", req, nil)
+}
+
+// GetAnnouncement retrieves a single announcement by its name.
+func (c *Client) GetAnnouncement(name string) (*v1.Announcement, error) {
+	announcement := &v1.Announcement{}
+	return announcement, c.do(http.MethodGet, fmt.Sprintf("announcements/%s", name), nil, announcement)
+}
+
+// UpdateAnnouncement will update an announcement. Its existing details are replaced
+// by those in the request, even if nil or unset.
+func (c *Client) UpdateAnnouncement(name string, req *v1.UpdateAnnouncementRequest) error {
+	return c.do(http.MethodPut, fmt.Sprintf("announcements/%s", name), req, nil)
+}
+
+// DeleteAnnouncement will delete the given announcement.
+func (c *Client) DeleteAnnouncement(name string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("announcements/%s", name), nil, nil)
+}
+
 // DesktopTemplate functions
 
 // GetDesktopTemplates returns a list of available DesktopTemplates. This is the same as doing
@@ -79,6 +325,24 @@ func (c *Client) GetDesktopTemplates() ([]*v1alpha1.DesktopTemplate, error) {
 	return resp, c.do(http.MethodGet, "templates", nil, &resp)
 }
 
+// GetNamespaceDesktopTemplates returns the DesktopTemplates the current user can launch
+// in the given namespace.
+func (c *Client) GetNamespaceDesktopTemplates(namespace string) ([]*v1alpha1.DesktopTemplate, error) {
+	resp := make([]*v1alpha1.DesktopTemplate, 0)
+	return resp, c.do(http.MethodGet, fmt.Sprintf("templates?namespace=%s", url.QueryEscape(namespace)), nil, &resp)
+}
+
+// ListDesktopTemplates retrieves a filtered and sorted page of DesktopTemplates. A
+// limit or continue token must be set in the options.
+func (c *Client) ListDesktopTemplates(opts *v1.ListOptions) ([]*v1alpha1.DesktopTemplate, *v1.ListMeta, error) {
+	tmpls := make([]*v1alpha1.DesktopTemplate, 0)
+	resp := &v1.ListResponse{Items: &tmpls}
+	if err := c.do(http.MethodGet, getListEndpoint("templates", opts), nil, resp); err != nil {
+		return nil, nil, err
+	}
+	return tmpls, resp.Metadata, nil
+}
+
 // CreateDesktopTemplate creates a new DesktopTemplate for this cluster.
 func (c *Client) CreateDesktopTemplate(req *v1alpha1.DesktopTemplate) error {
 	return c.do(http.MethodPost, "templates", req, nil)
@@ -102,6 +366,44 @@ func (c *Client) DeleteDesktopTemplate(name string) error {
 	return c.do(http.MethodDelete, fmt.Sprintf("templates/%s", name), nil, nil)
 }
 
+// GetDesktopTemplateRevisions retrieves the recorded revisions of the given
+// DesktopTemplate, newest first.
+func (c *Client) GetDesktopTemplateRevisions(name string) ([]*v1alpha1.DesktopTemplateRevision, error) {
+	resp := make([]*v1alpha1.DesktopTemplateRevision, 0)
+	return resp, c.do(http.MethodGet, fmt.Sprintf("templates/%s/revisions", name), nil, &resp)
+}
+
+// RollbackDesktopTemplate rolls the given DesktopTemplate back to a previous revision.
+func (c *Client) RollbackDesktopTemplate(name string, revision int64) error {
+	return c.do(http.MethodPost, fmt.Sprintf("templates/%s/rollback", name), &v1.RollbackTemplateRequest{Revision: revision}, nil)
+}
+
+// EstimateDesktopSession returns the estimated resources and hourly cost of launching
+// a session from the given DesktopTemplate.
+func (c *Client) EstimateDesktopSession(name string, req *v1.EstimateSessionRequest) (*v1.SessionEstimate, error) {
+	estimate := &v1.SessionEstimate{}
+	return estimate, c.do(http.MethodPost, fmt.Sprintf("templates/%s/estimate", name), req, estimate)
+}
+
+// ExportDesktopTemplates returns a signed bundle of the requested DesktopTemplates
+// that can be imported into another cluster.
+func (c *Client) ExportDesktopTemplates(req *v1.ExportTemplatesRequest) (*v1alpha1.DesktopTemplateBundle, error) {
+	bundle := &v1alpha1.DesktopTemplateBundle{}
+	return bundle, c.do(http.MethodPost, "templates/export", req, bundle)
+}
+
+// ImportDesktopTemplates imports a signed bundle of DesktopTemplates, and any roles
+// contained in it, into this cluster.
+func (c *Client) ImportDesktopTemplates(req *v1alpha1.ImportTemplateBundleRequest) error {
+	return c.do(http.MethodPost, "templates/import", req, nil)
+}
+
+// ImportDesktopTemplatesAsync verifies the given bundle and imports it in a job.
+func (c *Client) ImportDesktopTemplatesAsync(req *v1alpha1.ImportTemplateBundleRequest) (*v1.Job, error) {
+	resp := &v1.Job{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("templates/import?%s=true", v1.AsyncQueryParam), req, resp)
+}
+
 // VDIUser functions
 
 // GetVDIUsers returns a list of available VDIUsers, if possible. VDIUsers are not
@@ -112,11 +414,42 @@ func (c *Client) GetVDIUsers() ([]*v1.VDIUser, error) {
 	return resp, c.do(http.MethodGet, "users", nil, &resp)
 }
 
+// ListVDIUsers retrieves a filtered and sorted page of VDIUsers. A limit or
+// continue token must be set in the options.
+func (c *Client) ListVDIUsers(opts *v1.ListOptions) ([]*v1.VDIUser, *v1.ListMeta, error) {
+	users := make([]*v1.VDIUser, 0)
+	resp := &v1.ListResponse{Items: &users}
+	if err := c.do(http.MethodGet, getListEndpoint("users", opts), nil, resp); err != nil {
+		return nil, nil, err
+	}
+	return users, resp.Metadata, nil
+}
+
 // CreateVDIUser creates a new VDIUser for this cluster, if possible.
 func (c *Client) CreateVDIUser(req *v1.CreateUserRequest) error {
 	return c.do(http.MethodPost, "users", req, nil)
 }
 
+// ImportVDIUsers creates the given users in bulk. If any of them fail, no users
+// are created and the returned error summarizes how many rows failed.
+func (c *Client) ImportVDIUsers(users []*v1.UserRecord) (*v1.ImportUsersResponse, error) {
+	resp := &v1.ImportUsersResponse{}
+	return resp, c.do(http.MethodPost, "users/import", users, resp)
+}
+
+// ImportVDIUsersAsync creates the given users in bulk in a job. The job completes
+// with the same response as ImportVDIUsers.
+func (c *Client) ImportVDIUsersAsync(users []*v1.UserRecord) (*v1.Job, error) {
+	resp := &v1.Job{}
+	return resp, c.do(http.MethodPost, fmt.Sprintf("users/import?%s=true", v1.AsyncQueryParam), users, resp)
+}
+
+// ExportVDIUsers retrieves all users and the names of their roles.
+func (c *Client) ExportVDIUsers() ([]*v1.UserRecord, error) {
+	resp := make([]*v1.UserRecord, 0)
+	return resp, c.do(http.MethodGet, "users/export", nil, &resp)
+}
+
 // GetVDIUser returns a single VDIUser by name, if possible. VDIUsers are not
 // like DesktopTemplates and VDIRoles in that they are not CRDs, and are just used
 // as an internal abstraction on the concept of a user.
@@ -132,9 +465,259 @@ func (c *Client) UpdateVDIUser(name string, req *v1.UpdateUserRequest) error {
 	return c.do(http.MethodPut, fmt.Sprintf("users/%s", name), req, nil)
 }
 
+// GetVDIUserMFA retrieves the MFA status for the given VDIUser.
+func (c *Client) GetVDIUserMFA(name string) (*v1.MFAResponse, error) {
+	resp := &v1.MFAResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("users/%s/mfa", name), nil, resp)
+}
+
+// UpdateVDIUserMFA enables or disables MFA for the given VDIUser. When MFA is
+// enabled, the response contains the provisioning URI and backup codes for the
+// user.
+func (c *Client) UpdateVDIUserMFA(name string, req *v1.UpdateMFARequest) (*v1.MFAResponse, error) {
+	resp := &v1.MFAResponse{}
+	return resp, c.do(http.MethodPut, fmt.Sprintf("users/%s/mfa", name), req, resp)
+}
+
+// VerifyVDIUserMFA verifies that the given VDIUser has configured MFA by
+// providing a one-time password.
+func (c *Client) VerifyVDIUserMFA(name, otp string) (*v1.MFAResponse, error) {
+	resp := &v1.MFAResponse{}
+	return resp, c.do(http.MethodPut, fmt.Sprintf("users/%s/mfa/verify", name), &v1.AuthorizeRequest{OTP: otp}, resp)
+}
+
+// ChangeVDIUserPassword will change the password for the given VDIUser. The client
+// must be authenticated as the same user, and the user's current password must be
+// provided.
+func (c *Client) ChangeVDIUserPassword(name string, req *v1.ChangePasswordRequest) error {
+	return c.do(http.MethodPut, fmt.Sprintf("users/%s/password", name), req, nil)
+}
+
+// LogoutVDIUser will revoke all the sessions for the given VDIUser, requiring them to
+// log in again.
+func (c *Client) LogoutVDIUser(name string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("sessions/%s", name), nil, nil)
+}
+
 // DeleteVDIUser will delete the given VDIUser.
 func (c *Client) DeleteVDIUser(name string) error {
 	return c.do(http.MethodDelete, fmt.Sprintf("users/%s", name), nil, nil)
 }
 
+// GetVDIUserData returns the first-boot script configured for the given VDIUser.
+func (c *Client) GetVDIUserData(name string) (*v1.UserDataResponse, error) {
+	resp := &v1.UserDataResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("users/%s/userdata", name), nil, resp)
+}
+
+// UpdateVDIUserData sets the first-boot script for the given VDIUser. An empty script
+// removes it.
+func (c *Client) UpdateVDIUserData(name string, req *v1.UpdateUserDataRequest) error {
+	return c.do(http.MethodPut, fmt.Sprintf("users/%s/userdata", name), req, nil)
+}
+
+// GetVDIUserSecrets returns the names of the secrets configured for the given VDIUser.
+func (c *Client) GetVDIUserSecrets(name string) (*v1.UserSecretsResponse, error) {
+	resp := &v1.UserSecretsResponse{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("users/%s/secrets", name), nil, resp)
+}
+
+// SetVDIUserSecret sets the value of a secret for the given VDIUser.
+func (c *Client) SetVDIUserSecret(name, secret string, req *v1.UpdateUserSecretRequest) error {
+	return c.do(http.MethodPut, fmt.Sprintf("users/%s/secrets/%s", name, secret), req, nil)
+}
+
+// DeleteVDIUserSecret deletes a secret for the given VDIUser.
+func (c *Client) DeleteVDIUserSecret(name, secret string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("users/%s/secrets/%s", name, secret), nil, nil)
+}
+
+// GetVDIUserLogins returns the login attempts recorded for the given VDIUser between
+// start and end, newest first. Zero times leave the range open on that side.
+func (c *Client) GetVDIUserLogins(name string, start, end time.Time) (*v1.LoginHistory, error) {
+	resp := &v1.LoginHistory{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("users/%s/logins?%s", name, loginHistoryQuery(url.Values{}, start, end)), nil, resp)
+}
+
+// GetLoginReport returns the login attempts recorded for all users between start and
+// end, newest first. If a username is provided, only attempts for it are returned.
+func (c *Client) GetLoginReport(username string, start, end time.Time) (*v1.LoginHistory, error) {
+	query := url.Values{}
+	if username != "" {
+		query.Set("user", username)
+	}
+	resp := &v1.LoginHistory{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("reports/logins?%s", loginHistoryQuery(query, start, end)), nil, resp)
+}
+
+// loginHistoryQuery adds the given time range to the query and encodes it.
+func loginHistoryQuery(query url.Values, start, end time.Time) string {
+	if !start.IsZero() {
+		query.Set("start", start.Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		query.Set("end", end.Format(time.RFC3339))
+	}
+	return query.Encode()
+}
+
+// GetVDIUserDotfiles returns the dotfiles repository configured for the given VDIUser.
+func (c *Client) GetVDIUserDotfiles(name string) (*v1.DotfilesConfig, error) {
+	resp := &v1.DotfilesConfig{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("users/%s/dotfiles", name), nil, resp)
+}
+
+// UpdateVDIUserDotfiles sets the dotfiles repository for the given VDIUser. An empty
+// repository removes it.
+func (c *Client) UpdateVDIUserDotfiles(name string, req *v1.DotfilesConfig) error {
+	return c.do(http.MethodPut, fmt.Sprintf("users/%s/dotfiles", name), req, nil)
+}
+
+// GetVDIUserEmail returns the address notifications are sent to for the given VDIUser.
+func (c *Client) GetVDIUserEmail(name string) (*v1.UserEmailConfig, error) {
+	resp := &v1.UserEmailConfig{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("users/%s/email", name), nil, resp)
+}
+
+// UpdateVDIUserEmail sets the address notifications are sent to for the given VDIUser.
+// An empty address removes it.
+func (c *Client) UpdateVDIUserEmail(name string, req *v1.UserEmailConfig) error {
+	return c.do(http.MethodPut, fmt.Sprintf("users/%s/email", name), req, nil)
+}
+
+// GetVDIUserKeyboard returns the keyboard layout set on the displays of the given
+// VDIUser's desktop sessions.
+func (c *Client) GetVDIUserKeyboard(name string) (*v1.KeyboardLayoutConfig, error) {
+	resp := &v1.KeyboardLayoutConfig{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("users/%s/keyboard", name), nil, resp)
+}
+
+// UpdateVDIUserKeyboard sets the keyboard layout for the displays of the given
+// VDIUser's desktop sessions. An empty layout removes it.
+func (c *Client) UpdateVDIUserKeyboard(name string, req *v1.KeyboardLayoutConfig) error {
+	return c.do(http.MethodPut, fmt.Sprintf("users/%s/keyboard", name), req, nil)
+}
+
+// RequestPasswordReset requests a password reset link be emailed to the given user.
+// This succeeds whether or not the user exists.
+func (c *Client) RequestPasswordReset(username string) error {
+	return c.do(http.MethodPost, "reset-password", &v1.PasswordResetRequest{Username: username}, nil)
+}
+
+// ConfirmPasswordReset sets a new password with the token from a password reset link.
+func (c *Client) ConfirmPasswordReset(req *v1.ConfirmPasswordResetRequest) error {
+	return c.do(http.MethodPost, "reset-password/confirm", req, nil)
+}
+
+// GetVDIUserSnapshots returns the snapshots of the given VDIUser's userdata volumes.
+func (c *Client) GetVDIUserSnapshots(name string) ([]*v1.DesktopSnapshot, error) {
+	resp := make([]*v1.DesktopSnapshot, 0)
+	return resp, c.do(http.MethodGet, fmt.Sprintf("users/%s/snapshots", name), nil, &resp)
+}
+
+// DeleteVDIUserSnapshot deletes a snapshot of the given VDIUser's userdata volume.
+func (c *Client) DeleteVDIUserSnapshot(name, namespace, snapshot string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("users/%s/snapshots/%s/%s", name, namespace, snapshot), nil, nil)
+}
+
+// APIKey functions
+
+// GetAPIKeys retrieves the API keys belonging to the current user.
+func (c *Client) GetAPIKeys() ([]*v1.APIKey, error) {
+	resp := make([]*v1.APIKey, 0)
+	return resp, c.do(http.MethodGet, "apikeys", nil, &resp)
+}
+
+// CreateAPIKey creates a new API key for the current user. The returned token is
+// not retrievable again.
+func (c *Client) CreateAPIKey(req *v1.CreateAPIKeyRequest) (*v1.CreateAPIKeyResponse, error) {
+	resp := &v1.CreateAPIKeyResponse{}
+	return resp, c.do(http.MethodPost, "apikeys", req, resp)
+}
+
+// GetAPIKey retrieves the API key with the given ID.
+func (c *Client) GetAPIKey(id string) (*v1.APIKey, error) {
+	resp := &v1.APIKey{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("apikeys/%s", id), nil, resp)
+}
+
+// DeleteAPIKey revokes the API key with the given ID.
+func (c *Client) DeleteAPIKey(id string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("apikeys/%s", id), nil, nil)
+}
+
+// Recording functions
+
+// GetRecordings retrieves the session recordings the current user has access to.
+func (c *Client) GetRecordings() ([]*v1.Recording, error) {
+	resp := make([]*v1.Recording, 0)
+	return resp, c.do(http.MethodGet, "recordings", nil, &resp)
+}
+
+// ListRecordings retrieves a filtered and sorted page of session recordings. A limit
+// or continue token must be set in the options.
+func (c *Client) ListRecordings(opts *v1.ListOptions) ([]*v1.Recording, *v1.ListMeta, error) {
+	recordings := make([]*v1.Recording, 0)
+	resp := &v1.ListResponse{Items: &recordings}
+	if err := c.do(http.MethodGet, getListEndpoint("recordings", opts), nil, resp); err != nil {
+		return nil, nil, err
+	}
+	return recordings, resp.Metadata, nil
+}
+
+// DownloadRecording retrieves the contents of the given session recording. The
+// caller is responsible for closing the returned reader.
+func (c *Client) DownloadRecording(rec *v1.Recording) (io.ReadCloser, error) {
+	return c.doStream(http.MethodGet, fmt.Sprintf("recordings/%s/%s/%s", rec.Namespace, rec.Template, rec.ID))
+}
+
+// Report functions
+
+// GetUsageReport retrieves the desktop usage for the cluster grouped by the given
+// field. Start and end are days in YYYY-MM-DD format, and the server defaults are
+// used for any that are empty.
+func (c *Client) GetUsageReport(groupBy v1.UsageGroupBy, start, end string) (*v1.UsageReport, error) {
+	query := url.Values{}
+	if groupBy != "" {
+		query.Set("groupBy", string(groupBy))
+	}
+	if start != "" {
+		query.Set("start", start)
+	}
+	if end != "" {
+		query.Set("end", end)
+	}
+	resp := &v1.UsageReport{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("reports/usage?%s", query.Encode()), nil, resp)
+}
+
+// Job functions
+
+// GetJobs retrieves the jobs started by the current user, newest first.
+func (c *Client) GetJobs() ([]*v1.Job, error) {
+	resp := make([]*v1.Job, 0)
+	return resp, c.do(http.MethodGet, "jobs", nil, &resp)
+}
+
+// GetJob retrieves the progress and result of the given job.
+func (c *Client) GetJob(id string) (*v1.Job, error) {
+	resp := &v1.Job{}
+	return resp, c.do(http.MethodGet, fmt.Sprintf("jobs/%s", id), nil, resp)
+}
+
+// WaitForJob polls the given job at the given interval until it completes, and
+// returns it. Whether the job succeeded should be checked on the returned job.
+func (c *Client) WaitForJob(id string, interval time.Duration) (*v1.Job, error) {
+	for {
+		job, err := c.GetJob(id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Done() {
+			return job, nil
+		}
+		time.Sleep(interval)
+	}
+}
+
 // TODO: Should MFA management functions be implemented?
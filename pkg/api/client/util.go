@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 )
 
@@ -22,6 +27,15 @@ func (c *Client) getEndpoint(ep string) string {
 	return fmt.Sprintf("%s/api/%s", strings.TrimSuffix(c.opts.URL, "/"), ep)
 }
 
+// getListEndpoint returns the given list endpoint with the query parameters for the
+// given options.
+func getListEndpoint(ep string, opts *v1.ListOptions) string {
+	if opts == nil {
+		return ep
+	}
+	return fmt.Sprintf("%s?%s", ep, opts.Encode().Encode())
+}
+
 // returnAPIError converts the given response body into an API error and returns it.
 // If the body cannot be decoded, an error containing its contents is returned.
 func (c *Client) returnAPIError(body []byte) error {
@@ -32,37 +46,93 @@ func (c *Client) returnAPIError(body []byte) error {
 	return err
 }
 
-// do is a helper function for a generic request flow with the API.
-func (c *Client) do(method, endpoint string, req, resp interface{}) error {
+// send performs a request against the API with the given body, retrying it when
+// it fails with a temporary error. The caller is responsible for closing the body
+// of the returned response.
+func (c *Client) send(method, endpoint string, body []byte) (*http.Response, error) {
+	interval := c.opts.getRetryInterval()
+	for attempt := 0; ; attempt++ {
+		r, err := http.NewRequest(method, c.getEndpoint(endpoint), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Add("X-Session-Token", c.getAccessToken())
+		r.Header.Add("Content-Type", "application/json")
+
+		res, err := c.httpClient.Do(r)
+		if attempt >= c.opts.getMaxRetries() || !shouldRetry(method, res, err) {
+			return res, err
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+// shouldRetry returns true if a request with the given method and result can be
+// safely retried.
+func shouldRetry(method string, res *http.Response, err error) bool {
+	if err != nil {
+		// requests that could not be sent never reached the server
+		if urlErr, ok := err.(*url.Error); ok {
+			if opErr, ok := urlErr.Err.(*net.OpError); ok && opErr.Op == "dial" {
+				return true
+			}
+		}
+		return isIdempotent(method)
+	}
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+// isIdempotent returns true if requests with the given method can be repeated
+// without side effects.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// doRaw performs a request with the given object encoded as the body and returns
+// the status code and body of the response.
+func (c *Client) doRaw(method, endpoint string, req interface{}) (int, []byte, error) {
 	var reqBody []byte
 	var err error
 
 	if req != nil {
 		reqBody, err = json.Marshal(req)
 		if err != nil {
-			return err
+			return 0, nil, err
 		}
 	}
 
-	r, err := http.NewRequest(method, c.getEndpoint(endpoint), bytes.NewReader(reqBody))
+	rawRes, err := c.send(method, endpoint, reqBody)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
+	defer rawRes.Body.Close()
 
-	r.Header.Add("X-Session-Token", c.getAccessToken())
-	r.Header.Add("Content-Type", "application/json")
-
-	rawRes, err := c.httpClient.Do(r)
+	body, err := ioutil.ReadAll(rawRes.Body)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
-	defer rawRes.Body.Close()
+	return rawRes.StatusCode, body, nil
+}
 
-	body, err := ioutil.ReadAll(rawRes.Body)
+// do is a helper function for a generic request flow with the API.
+func (c *Client) do(method, endpoint string, req, resp interface{}) error {
+	status, body, err := c.doRaw(method, endpoint, req)
 	if err != nil {
 		return err
 	}
-	if rawRes.StatusCode != http.StatusOK {
+	if status != http.StatusOK {
 		return c.returnAPIError(body)
 	}
 
@@ -72,3 +142,21 @@ func (c *Client) do(method, endpoint string, req, resp interface{}) error {
 
 	return nil
 }
+
+// doStream is a helper function for requests that return a raw body instead of JSON.
+// The caller is responsible for closing the returned reader.
+func (c *Client) doStream(method, endpoint string) (io.ReadCloser, error) {
+	rawRes, err := c.send(method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rawRes.StatusCode != http.StatusOK {
+		defer rawRes.Body.Close()
+		body, err := ioutil.ReadAll(rawRes.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.returnAPIError(body)
+	}
+	return rawRes.Body, nil
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/gorilla/websocket"
+)
+
+// AttachDisplay connects to the display of the given desktop session. The
+// returned connection carries the raw display protocol of the desktop, e.g. RFB
+// for VNC desktops, in binary messages. The caller is responsible for closing it.
+func (c *Client) AttachDisplay(namespace, name string) (*websocket.Conn, error) {
+	return c.dialWebsocket(fmt.Sprintf("desktops/ws/%s/%s/display", namespace, name))
+}
+
+// PickUpDisplay connects to the display of the given desktop session with a code
+// created by CreateSessionHandoff on another device. The display is taken from the
+// connection that held it, which is closed. The caller is responsible for closing
+// the returned connection.
+func (c *Client) PickUpDisplay(namespace, name, code string) (*websocket.Conn, error) {
+	query := url.Values{}
+	query.Set(v1.HandoffQueryParam, code)
+	return c.dialWebsocket(fmt.Sprintf("desktops/ws/%s/%s/display?%s", namespace, name, query.Encode()))
+}
+
+// AttachAudio connects to the audio stream of the given desktop session. The
+// caller is responsible for closing the returned connection.
+func (c *Client) AttachAudio(namespace, name string) (*websocket.Conn, error) {
+	return c.dialWebsocket(fmt.Sprintf("desktops/ws/%s/%s/audio", namespace, name))
+}
+
+// SetDisplaySettings changes the quality, frame rate, and bandwidth of the display
+// of the given desktop session. The settings apply to connections that are already
+// open, and are kept for later ones. The returned status contains the settings
+// applied after the limits of the template.
+func (c *Client) SetDisplaySettings(namespace, name string, settings *v1.DisplaySettings) (*v1.DisplaySettingsStatus, error) {
+	conn, err := c.dialWebsocket(fmt.Sprintf("desktops/ws/%s/%s/settings", namespace, name))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	status := &v1.DisplaySettingsStatus{}
+	// the current settings are sent first
+	if err := conn.ReadJSON(status); err != nil {
+		return nil, err
+	}
+	if status.Error != "" {
+		return nil, errors.New(status.Error)
+	}
+	if err := conn.WriteJSON(settings); err != nil {
+		return nil, err
+	}
+	if err := conn.ReadJSON(status); err != nil {
+		return nil, err
+	}
+	if status.Error != "" {
+		return nil, errors.New(status.Error)
+	}
+	return status, nil
+}
+
+// dialWebsocket opens a websocket connection to the given API endpoint. When the
+// server refuses the upgrade, the error in the response is returned.
+func (c *Client) dialWebsocket(endpoint string) (*websocket.Conn, error) {
+	wsURL := c.getEndpoint(endpoint)
+	if strings.HasPrefix(wsURL, "https") {
+		wsURL = "wss" + strings.TrimPrefix(wsURL, "https")
+	} else {
+		wsURL = "ws" + strings.TrimPrefix(wsURL, "http")
+	}
+	dialer := &websocket.Dialer{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: c.tlsConfig,
+		Jar:             c.httpClient.Jar,
+		// matches the default dialer
+		HandshakeTimeout: 45 * time.Second,
+	}
+	header := http.Header{}
+	header.Set("X-Session-Token", c.getAccessToken())
+	conn, res, err := dialer.Dial(wsURL, header)
+	if err != nil {
+		if err == websocket.ErrBadHandshake && res != nil {
+			defer res.Body.Close()
+			if body, rerr := ioutil.ReadAll(res.Body); rerr == nil {
+				return nil, c.returnAPIError(body)
+			}
+		}
+		return nil, err
+	}
+	return conn, nil
+}
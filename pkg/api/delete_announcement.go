@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation DELETE /api/announcements/{announcement} Announcements deleteAnnouncementRequest
+// ---
+// summary: Delete the specified announcement.
+// description: Users currently shown the announcement are notified over the event stream.
+// parameters:
+// - name: announcement
+//   in: path
+//   description: The announcement to delete
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteAnnouncement(w http.ResponseWriter, r *http.Request) {
+	announcement := d.getAnnouncementForRequest(w, r)
+	if announcement == nil {
+		return
+	}
+	if err := d.client.Delete(context.TODO(), announcement); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
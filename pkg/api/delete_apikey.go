@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation DELETE /api/apikeys/{apikey} APIKeys deleteAPIKeyRequest
+// ---
+// summary: Revoke the specified API key.
+// parameters:
+// - name: apikey
+//   in: path
+//   description: The ID of the API key to revoke
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, err := d.getRequestAPIKey(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if key == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The API key '%s' doesn't exist", apiutil.GetAPIKeyFromRequest(r)), w)
+		return
+	}
+	if err := d.deleteAPIKey(key.ID); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation DELETE /api/groups/{group} Groups deleteGroupRequest
+// ---
+// summary: Delete the specified group.
+// parameters:
+// - name: group
+//   in: path
+//   description: The group to delete
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	group := apiutil.GetGroupFromRequest(r)
+	nn := types.NamespacedName{Name: group, Namespace: metav1.NamespaceAll}
+	vdiGroup := &v1alpha1.VDIGroup{}
+	if err := d.client.Get(context.TODO(), nn, vdiGroup); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("The group '%s' doesn't exist", group), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.client.Delete(context.TODO(), vdiGroup); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation DELETE /api/roles/{role}/grants/{user} Roles deleteRoleGrant
+// ---
+// summary: Revoke a temporary grant of a role to a user.
+// description: Sessions already issued with the role keep it until they expire.
+// parameters:
+// - name: role
+//   in: path
+//   description: The name of the role
+//   type: string
+//   required: true
+// - name: user
+//   in: path
+//   description: The user the role was granted to
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteRoleGrant(w http.ResponseWriter, r *http.Request) {
+	role := d.getRoleForRequest(w, r)
+	if role == nil {
+		return
+	}
+	grants, err := role.GetActiveGrants()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	username := apiutil.GetUserFromRequest(r)
+	remaining := make([]*v1.RoleGrant, 0)
+	for _, grant := range grants {
+		if grant.User != username {
+			remaining = append(remaining, grant)
+		}
+	}
+	if len(remaining) == len(grants) {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The role '%s' is not granted to %s", role.GetName(), username), w)
+		return
+	}
+	if err := role.SetGrants(remaining); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.client.Update(context.TODO(), role); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
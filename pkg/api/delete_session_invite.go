@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation DELETE /api/sessions/{namespace}/{name}/invites/{invite} Sessions deleteSessionInvite
+// ---
+// summary: Revoke an invite to a desktop session.
+// description: Connections already made with the invite are not closed.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: invite
+//   in: path
+//   description: The ID of the invite
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteSessionInvite(w http.ResponseWriter, r *http.Request) {
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", nn.String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	invites, err := getSessionInvites(desktop)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	id := apiutil.GetInviteFromRequest(r)
+	remaining := make([]*v1.SessionInvite, 0)
+	for _, invite := range invites {
+		if invite.ID != id {
+			remaining = append(remaining, invite)
+		}
+	}
+	if len(remaining) == len(invites) {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The invite '%s' doesn't exist", id), w)
+		return
+	}
+	if err := d.setSessionInvites(desktop, remaining); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
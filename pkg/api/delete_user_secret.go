@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"github.com/gorilla/mux"
+)
+
+// swagger:operation DELETE /api/users/{user}/secrets/{secret} Users deleteUserSecretRequest
+// ---
+// summary: Delete one of the user's secrets.
+// description: Sessions already using the secret are not affected.
+// parameters:
+//   - name: user
+//     in: path
+//     description: The user the secret belongs to
+//     type: string
+//     required: true
+//   - name: secret
+//     in: path
+//     description: The name of the secret
+//     type: string
+//     required: true
+//
+// responses:
+//
+//	"200":
+//	  "$ref": "#/responses/boolResponse"
+//	"400":
+//	  "$ref": "#/responses/error"
+//	"403":
+//	  "$ref": "#/responses/error"
+//	"404":
+//	  "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteUserSecret(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+	name := mux.Vars(r)["secret"]
+
+	if err := d.secrets.Lock(10); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer d.secrets.Release()
+
+	users, secrets, err := d.readUserSecrets(username)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	if _, ok := secrets[name]; !ok {
+		apiutil.ReturnAPINotFound(fmt.Errorf("No secret %s found for user %s", name, username), w)
+		return
+	}
+	delete(secrets, name)
+
+	if err := d.writeUserSecrets(users, username, secrets); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteOK(w)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation DELETE /api/sessions/{user} Auth deleteUserSessionsRequest
+// ---
+// summary: Log out the specified user everywhere.
+// description: |
+//   All access tokens issued to the user up until now are revoked, along with any
+//   refresh tokens. The user will need to log in again. API keys and desktops owned
+//   by the user are left untouched.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to log out
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteUserSessions(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+	if err := d.revokeUserSessions(username); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	reqUser := apiutil.GetRequestUserSession(r).User
+	apiLogger.Info(fmt.Sprintf("Revoked all sessions for %s", username), "RevokedBy", reqUser.GetName())
+	apiutil.WriteOK(w)
+}
+
+// revokeUserSessions revokes all the access and refresh tokens issued to the given
+// user up until now.
+func (d *desktopAPI) revokeUserSessions(username string) error {
+	maxAge, err := d.getMaxTokenDuration()
+	if err != nil {
+		return err
+	}
+	if err := d.revoked.RevokeUser(username, maxAge); err != nil {
+		return err
+	}
+	return d.revokeUserRefreshTokens(username)
+}
+
+// getMaxTokenDuration returns the longest lifetime an access token can be issued
+// with.
+func (d *desktopAPI) getMaxTokenDuration() (time.Duration, error) {
+	duration := d.vdiCluster.GetTokenDuration()
+	roles, err := d.vdiCluster.GetRoles(d.client)
+	if err != nil {
+		return 0, err
+	}
+	for _, role := range roles {
+		if roleDuration := role.ToUserRole().GetTokenDuration(); roleDuration > duration {
+			duration = roleDuration
+		}
+	}
+	return duration, nil
+}
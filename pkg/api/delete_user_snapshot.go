@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation DELETE /api/users/{user}/snapshots/{namespace}/{name} Users deleteUserSnapshotRequest
+// ---
+// summary: Delete a snapshot of the given user's userdata volume.
+// description: Sessions already restored from the snapshot are not affected.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user the snapshot belongs to
+//   type: string
+//   required: true
+// - name: namespace
+//   in: path
+//   description: The namespace of the snapshot
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the snapshot
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) DeleteUserSnapshot(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	snapshot, err := d.getUserSnapshot(username, nn.Namespace, nn.Name)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if snapshot == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("No snapshot %s found for user %s", nn.String(), username), w)
+		return
+	}
+	if err := d.client.Delete(context.TODO(), snapshot); client.IgnoreNotFound(err) != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
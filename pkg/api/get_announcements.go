@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation GET /api/announcements Announcements getAnnouncements
+// ---
+// summary: Retrieve the announcements currently shown to the requesting user.
+// description: |
+//   Announcements are shown between their start and end times to users holding any
+//   of their roles, or to all users when they have none. Changes are also sent over
+//   the event stream at `/api/events`.
+// parameters:
+// - name: all
+//   in: query
+//   description: Set to true to retrieve all announcements, including those not shown to the user. This requires a grant to read announcements.
+//   type: boolean
+//   required: false
+// responses:
+//   "200":
+//     "$ref": "#/responses/announcementsResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetAnnouncements(w http.ResponseWriter, r *http.Request) {
+	announcements, err := d.vdiCluster.GetAnnouncements(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	all := r.URL.Query().Get("all") == "true"
+	user := apiutil.GetRequestUserSession(r).User
+	now := time.Now()
+	out := make([]*v1.Announcement, 0)
+	for _, announcement := range announcements {
+		ann := announcement.ToAnnouncement()
+		if all || (ann.IsActive(now) && ann.TargetsUser(user)) {
+			out = append(out, ann)
+		}
+	}
+	apiutil.WriteJSON(out, w)
+}
+
+// swagger:operation GET /api/announcements/{announcement} Announcements getAnnouncement
+// ---
+// summary: Retrieve the specified announcement.
+// parameters:
+// - name: announcement
+//   in: path
+//   description: The announcement to retrieve
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/announcementResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetAnnouncement(w http.ResponseWriter, r *http.Request) {
+	announcement := d.getAnnouncementForRequest(w, r)
+	if announcement == nil {
+		return
+	}
+	apiutil.WriteJSON(announcement.ToAnnouncement(), w)
+}
+
+// A list of announcements
+// swagger:response announcementsResponse
+type swaggerAnnouncementsResponse struct {
+	// in:body
+	Body []v1.Announcement
+}
+
+// A single announcement
+// swagger:response announcementResponse
+type swaggerAnnouncementResponse struct {
+	// in:body
+	Body v1.Announcement
+}
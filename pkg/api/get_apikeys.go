@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/apikeys APIKeys getAPIKeys
+// Retrieves the API keys belonging to the requesting user.
+// responses:
+//   200: apiKeysResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userSession := apiutil.GetRequestUserSession(r)
+	keys, err := d.getUserAPIKeys(userSession.User.GetName())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(keys, w)
+}
+
+// swagger:operation GET /api/apikeys/{apikey} APIKeys getAPIKey
+// ---
+// summary: Retrieve the specified API key.
+// parameters:
+// - name: apikey
+//   in: path
+//   description: The ID of the API key
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/apiKeyResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, err := d.getRequestAPIKey(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if key == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The API key '%s' doesn't exist", apiutil.GetAPIKeyFromRequest(r)), w)
+		return
+	}
+	apiutil.WriteJSON(key, w)
+}
+
+// getRequestAPIKey returns the API key in the request path if it belongs to the
+// requesting user, or nil if it doesn't exist.
+func (d *desktopAPI) getRequestAPIKey(r *http.Request) (*v1.APIKey, error) {
+	userSession := apiutil.GetRequestUserSession(r)
+	keys, err := d.getUserAPIKeys(userSession.User.GetName())
+	if err != nil {
+		return nil, err
+	}
+	id := apiutil.GetAPIKeyFromRequest(r)
+	for _, key := range keys {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+// API keys response
+// swagger:response apiKeysResponse
+type swaggerAPIKeysResponse struct {
+	// in:body
+	Body []v1.APIKey
+}
+
+// API key response
+// swagger:response apiKeyResponse
+type swaggerAPIKeyResponse struct {
+	// in:body
+	Body v1.APIKey
+}
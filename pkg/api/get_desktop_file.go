@@ -1,9 +1,14 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // swagger:operation GET /api/desktops/fs/{namespace}/{name}/stat/{fpath} Desktops statDesktopFile
@@ -35,6 +40,9 @@ import (
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) GetStatDesktopFile(w http.ResponseWriter, r *http.Request) {
+	if !d.checkFileTransferPolicy(w, r, v1alpha1.FileTransferPolicy.AllowsDownload, "File downloads are disabled for this desktop session") {
+		return
+	}
 	d.serveHTTPProxy(w, r)
 }
 
@@ -77,5 +85,33 @@ type swaggerStatDesktopFileResponse struct {
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) GetDownloadDesktopFile(w http.ResponseWriter, r *http.Request) {
+	if !d.checkFileTransferPolicy(w, r, v1alpha1.FileTransferPolicy.AllowsDownload, "File downloads are disabled for this desktop session") {
+		return
+	}
 	d.serveHTTPProxy(w, r)
 }
+
+// checkFileTransferPolicy returns true if the file transfer policy of the template
+// for the requested desktop passes the given check. Otherwise an error is written
+// to the response.
+func (d *desktopAPI) checkFileTransferPolicy(w http.ResponseWriter, r *http.Request, allowed func(v1alpha1.FileTransferPolicy) bool, msg string) bool {
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", apiutil.GetNamespacedNameFromRequest(r).String()), w)
+			return false
+		}
+		apiutil.ReturnAPIError(err, w)
+		return false
+	}
+	tmpl, err := desktop.GetTemplate(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return false
+	}
+	if !allowed(tmpl.GetFileTransferPolicy()) {
+		apiutil.ReturnAPIForbidden(nil, msg, w)
+		return false
+	}
+	return true
+}
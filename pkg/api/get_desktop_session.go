@@ -2,7 +2,6 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -13,13 +12,23 @@ import (
 
 	"golang.org/x/net/websocket"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // swagger:operation GET /api/sessions/{namespace}/{name} Sessions getSession
 // ---
 // summary: Retrieve the status of the requested desktop session.
-// description: Details include the PodPhase and CRD status.
+// description: |
+//   Details include the PodPhase and CRD status, along with how far the desktop has
+//   progressed towards serving display connections (`Scheduling`, `PullingImage`,
+//   `BootingDisplay`, or `Ready`) and why the pod could not be
+//   created or started if it failed to (e.g. it was rejected by an admission controller
+//   or its security profiles are not loaded on the node). If the desktop will be destroyed for
+//   reaching its maximum lifetime or the end of its template's availability window,
+//   the time and reason are included, and `terminationPending` is set once it is less
+//   than 15 minutes away. The clipboard and file transfer policies of the desktop's
+//   template are included so clients can hide actions that are not available.
 // parameters:
 // - name: namespace
 //   in: path
@@ -50,7 +59,7 @@ func (d *desktopAPI) GetDesktopSessionStatus(w http.ResponseWriter, r *http.Requ
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
-	apiutil.WriteJSON(toReturnStatus(desktop), w)
+	apiutil.WriteJSON(d.toReturnStatus(desktop), w)
 }
 
 // Session status response
@@ -63,7 +72,10 @@ type swaggerGetSessionResponse struct {
 // swagger:operation GET /api/desktops/ws/{namespace}/{name}/status Desktops getSessionStatusWs
 // ---
 // summary: Retrieve status updates of the requested desktop session over a websocket.
-// description: Details include the PodPhase and CRD status.
+// description: |
+//   Details include the PodPhase, the progress of the desktop towards serving display
+//   connections, CRD status, and ephemeral storage usage. The
+//   connection is closed once the desktop is running unless `follow` is set.
 // parameters:
 // - name: namespace
 //   in: path
@@ -75,6 +87,11 @@ type swaggerGetSessionResponse struct {
 //   description: The name of the desktop session
 //   type: string
 //   required: true
+// - name: follow
+//   in: query
+//   description: Keep sending status updates after the desktop is running
+//   type: boolean
+//   required: false
 // responses:
 //   "UPGRADE": {}
 //   "400":
@@ -86,8 +103,13 @@ type swaggerGetSessionResponse struct {
 func (d *desktopAPI) GetDesktopSessionStatusWebsocket(conn *websocket.Conn) {
 	defer conn.Close()
 
+	follow := conn.Request().URL.Query().Get("follow") == "true"
+
 	ticker := time.NewTicker(time.Duration(2) * time.Second)
-	for range ticker.C {
+	defer func() { ticker.Stop() }()
+	following := false
+	for {
+		<-ticker.C
 
 		desktop, err := d.getDesktopForRequest(conn.Request())
 		if err != nil {
@@ -101,15 +123,24 @@ func (d *desktopAPI) GetDesktopSessionStatusWebsocket(conn *websocket.Conn) {
 				return
 			}
 		}
-		st := toReturnStatus(desktop)
+		st := d.toReturnStatus(desktop)
 		if _, err := conn.Write(st.JSON()); err != nil {
 			apiLogger.Error(err, "Failed to write status to websocket connection")
 			return
 		}
 
 		if st.Running && st.PodPhase == corev1.PodRunning {
-			// we are done here, the client shouldn't need anything else
-			return
+			if !follow {
+				// we are done here, the client shouldn't need anything else
+				return
+			}
+			if !following {
+				// the client wants to keep receiving updates, slow down to the
+				// rate disk usage is collected
+				ticker.Stop()
+				ticker = time.NewTicker(diskMonitorInterval)
+				following = true
+			}
 		}
 
 	}
@@ -121,19 +152,42 @@ func (d *desktopAPI) getDesktopForRequest(r *http.Request) (*v1alpha1.Desktop, e
 	return found, d.client.Get(context.TODO(), nn, found)
 }
 
-type desktopStatus struct {
-	Running  bool            `json:"running"`
-	PodPhase corev1.PodPhase `json:"podPhase"`
-}
+// terminationWarningPeriod is how long before a desktop is destroyed for reaching
+// its maximum lifetime or the close of its availability window that its user is
+// warned.
+const terminationWarningPeriod = 15 * time.Minute
 
-func toReturnStatus(desktop *v1alpha1.Desktop) *desktopStatus {
-	return &desktopStatus{
-		Running:  desktop.Status.Running,
-		PodPhase: desktop.Status.PodPhase,
+func (d *desktopAPI) toReturnStatus(desktop *v1alpha1.Desktop) *v1alpha1.DesktopSessionStatusResponse {
+	st := &v1alpha1.DesktopSessionStatusResponse{
+		Running:   desktop.Status.Running,
+		PodPhase:  desktop.Status.PodPhase,
+		Phase:     desktop.Status.Phase,
+		PodError:  desktop.Status.PodError,
+		Preempted: desktop.Status.Preempted,
+		Drained:   desktop.Status.DrainedFrom != "",
 	}
-}
-
-func (d *desktopStatus) JSON() []byte {
-	out, _ := json.Marshal(d)
-	return out
+	// let the user know their desktop is about to be migrated or terminated
+	if notice := desktop.GetDrainNotice(); notice != nil && desktop.Status.DrainedFrom != notice.Node {
+		st.Drain = notice
+	}
+	// let the user know their desktop is about to reach its lifetime or the end
+	// of its availability window
+	if terminatesAt := desktop.Status.TerminatesAt; terminatesAt != nil {
+		st.TerminatesAt = &terminatesAt.Time
+		st.TerminationReason = desktop.Status.TerminationReason
+		st.TerminationPending = time.Until(terminatesAt.Time) <= terminationWarningPeriod
+	}
+	// let the client know which clipboard, file transfer, and microphone actions
+	// are available
+	if tmpl, err := desktop.GetTemplate(d.client); err == nil {
+		st.Clipboard = tmpl.GetClipboardPolicy()
+		st.FileTransfer = tmpl.GetFileTransferPolicy()
+		st.Microphone = tmpl.MicrophoneEnabled()
+	}
+	if usage := d.disk.Get(types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}); usage != nil {
+		st.DiskUsedBytes = usage.UsedBytes
+		st.DiskLimitBytes = usage.LimitBytes
+		st.DiskPressure = usage.Pressure()
+	}
+	return st
 }
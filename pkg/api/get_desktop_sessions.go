@@ -16,11 +16,20 @@ import (
 
 // swagger:route GET /api/sessions Sessions getDesktopSessions
 // Retrieves a list of currently active desktop sessions and their status.
+//
+// Sessions can be filtered on their `name`, `namespace`, `user`, or `template`. When a
+// `limit` or `continue` token is provided, the response includes metadata about the page.
 // responses:
 //   200: desktopSessionsResponse
 //   400: error
 //   403: error
 func (d *desktopAPI) GetDesktopSessions(w http.ResponseWriter, r *http.Request) {
+	opts, err := v1.ParseListOptions(r.URL.Query())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
 	desktops := &v1alpha1.DesktopList{}
 	displayLocks := &corev1.ConfigMapList{}
 	audioLocks := &corev1.ConfigMapList{}
@@ -31,6 +40,18 @@ func (d *desktopAPI) GetDesktopSessions(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// filter and sort the desktops
+	indices, meta, err := opts.Apply(len(desktops.Items), v1.ListFields{
+		"name":      func(i int) string { return desktops.Items[i].GetName() },
+		"namespace": func(i int) string { return desktops.Items[i].GetNamespace() },
+		"user":      func(i int) string { return desktops.Items[i].GetUser() },
+		"template":  func(i int) string { return desktops.Items[i].Spec.Template },
+	})
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
 	// retrieve all active display locks
 	if err := d.client.List(
 		context.TODO(),
@@ -57,9 +78,13 @@ func (d *desktopAPI) GetDesktopSessions(w http.ResponseWriter, r *http.Request)
 	res := &v1.DesktopSessionsResponse{
 		Sessions: make([]*v1.DesktopSession, 0),
 	}
+	if opts.Paginated() {
+		res.Metadata = meta
+	}
 
 	// iterate desktops and parse properties and connection status
-	for _, desktop := range desktops.Items {
+	for _, i := range indices {
+		desktop := desktops.Items[i]
 		sess := &v1.DesktopSession{
 			Name:      desktop.GetName(),
 			Namespace: desktop.GetNamespace(),
@@ -74,15 +99,14 @@ func (d *desktopAPI) GetDesktopSessions(w http.ResponseWriter, r *http.Request)
 }
 
 // getSessionStatus iterates the current locks and builds a session object for the given desktop.
-// TODO: Getters for the names of locks, sprintf calls also present in get_websockify.go. This function
-// could also be optimized to work on pointers to slices and pop found locks off for future iterations.
+// TODO: This function could be optimized to work on pointers to slices and pop found locks off for future iterations.
 func getSessionStatus(cluster *v1alpha1.VDICluster, desktop v1alpha1.Desktop, displayLocks, audioLocks []corev1.ConfigMap) *v1.DesktopSessionStatus {
 	status := &v1.DesktopSessionStatus{
 		Display: &v1.ConnectionStatus{Connected: false},
 		Audio:   &v1.ConnectionStatus{Connected: false},
 	}
-	displayLockName := fmt.Sprintf("display-%s-%s", desktop.GetNamespace(), desktop.GetName())
-	audioLockName := fmt.Sprintf("audio-%s-%s", desktop.GetNamespace(), desktop.GetName())
+	displayLockName := desktop.GetDisplayLockName()
+	audioLockName := desktop.GetAudioLockName()
 
 	// iterate display locks and populate the status if one matches this desktop
 	for _, lock := range displayLocks {
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"golang.org/x/net/websocket"
+)
+
+// swagger:operation GET /api/events Events getEventsWs
+// ---
+// summary: Follow session, login, role, and announcement events over a websocket.
+// description: |
+//   Each message is a JSON object with the `type` of the event, the unix `time`
+//   it happened, and the `session`, `user`, `role`, or `announcement` it is for.
+//   Only events for resources the requesting user is allowed to read are sent, and
+//   announcement events are only sent to the users they are shown to. Login events
+//   are only sent by the app replica that handled the login.
+// parameters:
+// - name: types
+//   in: query
+//   description: A comma-separated list of event types to receive, defaults to all
+//   type: string
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetEventsWebsocket(conn *websocket.Conn) {
+	defer conn.Close()
+
+	userSession := apiutil.GetRequestUserSession(conn.Request())
+
+	var types map[v1.EventType]struct{}
+	if typesParam := conn.Request().URL.Query().Get("types"); typesParam != "" {
+		types = make(map[v1.EventType]struct{})
+		for _, t := range strings.Split(typesParam, ",") {
+			types[v1.EventType(strings.TrimSpace(t))] = struct{}{}
+		}
+	}
+
+	events := d.events.Subscribe()
+	defer d.events.Unsubscribe(events)
+
+	// the client is not expected to send anything, reads only return once the
+	// connection is closed
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		buf := make([]byte, 512)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event := <-events:
+			if types != nil {
+				if _, ok := types[event.Type]; !ok {
+					continue
+				}
+			}
+			if !eventAllowed(userSession.User, event) {
+				continue
+			}
+			out, err := json.Marshal(event)
+			if err != nil {
+				apiLogger.Error(err, "Failed to marshal event")
+				continue
+			}
+			if _, err := conn.Write(out); err != nil {
+				apiLogger.Error(err, "Failed to write event to websocket connection")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:route GET /api/gc Miscellaneous getGCReport
+// Retrieves the results of the last scan for orphaned resources.
+// responses:
+//   200: gcReportResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) GetGCReport(w http.ResponseWriter, r *http.Request) {
+	cm := &corev1.ConfigMap{}
+	if err := d.client.Get(context.TODO(), d.vdiCluster.GetGCReportName(), cm); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(errors.New("No garbage collection has run for this cluster"), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	raw, ok := cm.Data[v1.GCReportKey]
+	if !ok {
+		apiutil.ReturnAPINotFound(errors.New("No garbage collection has run for this cluster"), w)
+		return
+	}
+	report := &v1.GCReport{}
+	if err := json.Unmarshal([]byte(raw), report); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(report, w)
+}
+
+// Garbage collection report response
+// swagger:response gcReportResponse
+type swaggerGCReportResponse struct {
+	// in:body
+	Body v1.GCReport
+}
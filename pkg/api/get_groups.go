@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/groups Groups getGroups
+// Retrieves a list of the user groups in kVDI.
+//
+// Groups can be filtered on their `name`. When a `limit` or `continue` token is
+// provided, a page of groups is returned in a list response.
+// responses:
+//   200: groupsResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetGroups(w http.ResponseWriter, r *http.Request) {
+	opts, err := v1.ParseListOptions(r.URL.Query())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	groups, err := d.vdiCluster.GetGroups(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	indices, meta, err := opts.Apply(len(groups), v1.ListFields{
+		"name": func(i int) string { return groups[i].GetName() },
+	})
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	page := make([]v1alpha1.VDIGroup, len(indices))
+	for idx, i := range indices {
+		page[idx] = groups[i]
+	}
+	writeList(opts, meta, page, w)
+}
+
+// swagger:operation GET /api/groups/{group} Groups getGroup
+// ---
+// summary: Retrieve the specified group.
+// description: Details include the members of the group and the roles bound to it.
+// parameters:
+// - name: group
+//   in: path
+//   description: The group to retrieve details about
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/groupResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetGroup(w http.ResponseWriter, r *http.Request) {
+	groups, err := d.vdiCluster.GetGroups(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	groupName := apiutil.GetGroupFromRequest(r)
+	for _, group := range groups {
+		if group.GetName() == groupName {
+			apiutil.WriteJSON(group, w)
+			return
+		}
+	}
+	apiutil.ReturnAPINotFound(fmt.Errorf("No group with the name '%s' found", groupName), w)
+}
+
+// A list of groups
+// swagger:response groupsResponse
+type swaggerGroupsResponse struct {
+	// in:body
+	Body []v1alpha1.VDIGroup
+}
+
+// A single group
+// swagger:response groupResponse
+type swaggerGroupResponse struct {
+	// in:body
+	Body v1alpha1.VDIGroup
+}
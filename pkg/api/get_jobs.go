@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/jobs Jobs getJobs
+// Retrieves the jobs started by the requesting user, newest first. Jobs are kept for
+// a day after they were last updated.
+// responses:
+//   200: jobsResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetJobs(w http.ResponseWriter, r *http.Request) {
+	userSession := apiutil.GetRequestUserSession(r)
+	jobs, err := d.listUserJobs(userSession.User.GetName())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(jobs, w)
+}
+
+// swagger:operation GET /api/jobs/{job} Jobs getJob
+// ---
+// summary: Retrieve the progress and result of the specified job.
+// description: |
+//   Jobs are started by making a long-running request with `async=true`. Once the
+//   job has completed, the result is the same as the response to the request when
+//   it is made synchronously. Jobs that stop reporting progress, for example because
+//   the app instance running them restarted, are reported as failed.
+// parameters:
+// - name: job
+//   in: path
+//   description: The ID of the job
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/jobResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetJob(w http.ResponseWriter, r *http.Request) {
+	userSession := apiutil.GetRequestUserSession(r)
+	id := apiutil.GetJobFromRequest(r)
+	job, err := d.getUserJob(userSession.User.GetName(), id)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if job == nil {
+		apiutil.ReturnAPINotFound(fmt.Errorf("The job '%s' doesn't exist", id), w)
+		return
+	}
+	apiutil.WriteJSON(job, w)
+}
+
+// A long-running operation
+// swagger:response jobResponse
+type swaggerJobResponse struct {
+	// in:body
+	Body v1.Job
+}
+
+// A list of long-running operations
+// swagger:response jobsResponse
+type swaggerJobsResponse struct {
+	// in:body
+	Body []v1.Job
+}
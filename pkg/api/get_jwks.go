@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:route GET /api/.well-known/jwks.json Miscellaneous getJWKS
+// Retrieves the public keys for verifying access tokens issued by kVDI. The set is
+// empty unless tokens are signed with RS256 or ES256 keys.
+// responses:
+//   200: jwksResponse
+//   500: error
+func (d *desktopAPI) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	keys, err := d.getJWTKeys(true)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(keys.PublicKeys(), w)
+}
+
+// JSON web key set response
+// swagger:response jwksResponse
+type swaggerJWKSResponse struct {
+	// in:body
+	Body v1.JSONWebKeySet
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation GET /api/reports/logins Users getLoginReportRequest
+// ---
+// summary: Retrieves the recorded login attempts for all users, newest first.
+// description: |
+//   Attempts are kept up to the limits configured in `auth.loginHistory` on the VDICluster.
+//   Failed attempts are recorded under the username that was given, so they include attempts
+//   for users that do not exist.
+// parameters:
+// - name: user
+//   in: query
+//   description: Only return attempts for this username.
+//   type: string
+//   required: false
+// - name: start
+//   in: query
+//   description: Only return attempts made at or after this time, in RFC3339 format.
+//   type: string
+//   required: false
+// - name: end
+//   in: query
+//   description: Only return attempts made at or before this time, in RFC3339 format.
+//   type: string
+//   required: false
+// - name: success
+//   in: query
+//   description: Only return successful (true) or failed (false) attempts.
+//   type: string
+//   required: false
+// responses:
+//   "200":
+//     "$ref": "#/responses/loginHistoryResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetLoginReport(w http.ResponseWriter, r *http.Request) {
+	q, err := parseLoginHistoryQuery(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	q.user = r.URL.Query().Get("user")
+	history, err := d.queryLoginHistory(q)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(history, w)
+}
+
+// Login history response
+// swagger:response loginHistoryResponse
+type swaggerLoginHistoryResponse struct {
+	// in:body
+	Body v1.LoginHistory
+}
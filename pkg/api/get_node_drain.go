@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation GET /api/admin/nodes/{node}/drain Miscellaneous getNodeDrain
+// ---
+// summary: Retrieves the progress of draining desktops from a node.
+// parameters:
+// - name: node
+//   in: path
+//   description: The node being drained
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/nodeDrainResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetNodeDrain(w http.ResponseWriter, r *http.Request) {
+	status, err := d.getNodeDrainStatus(apiutil.GetNodeFromRequest(r))
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(status, w)
+}
+
+// getNodeDrainStatus returns the progress of the desktops that received a drain
+// notice for the given node.
+func (d *desktopAPI) getNodeDrainStatus(node string) (*v1.NodeDrainStatus, error) {
+	desktops := &v1alpha1.DesktopList{}
+	if err := d.client.List(context.TODO(), desktops, client.InNamespace(metav1.NamespaceAll), d.vdiCluster.GetClusterDesktopsSelector()); err != nil {
+		return nil, err
+	}
+	status := &v1.NodeDrainStatus{
+		Node:     node,
+		Desktops: make([]*v1.DesktopDrainStatus, 0),
+		Complete: true,
+	}
+	for _, desktop := range desktops.Items {
+		notice := desktop.GetDrainNotice()
+		if notice == nil || notice.Node != node {
+			continue
+		}
+		st := &v1.DesktopDrainStatus{
+			Name:      desktop.GetName(),
+			Namespace: desktop.GetNamespace(),
+			User:      desktop.GetUser(),
+			Action:    notice.Action,
+			Deadline:  notice.Deadline,
+		}
+		switch {
+		case desktop.GetDeletionTimestamp() != nil:
+			st.State = v1.DrainStateTerminating
+		case desktop.Status.DrainedFrom == node && desktop.Status.Running:
+			st.State = v1.DrainStateMigrated
+		case desktop.Status.DrainedFrom == node:
+			st.State = v1.DrainStateMigrating
+		default:
+			st.State = v1.DrainStatePending
+		}
+		if st.State != v1.DrainStateMigrated {
+			status.Complete = false
+		}
+		status.Desktops = append(status.Desktops, st)
+	}
+	return status, nil
+}
+
+// Node drain response
+// swagger:response nodeDrainResponse
+type swaggerNodeDrainResponse struct {
+	// in:body
+	Body v1.NodeDrainStatus
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/s3util"
+)
+
+// errRecordingsNotConfigured is returned when recordings are requested but no
+// storage is configured.
+var errRecordingsNotConfigured = errors.New("Session recording storage is not configured")
+
+// swagger:route GET /api/recordings Recordings getRecordings
+// Retrieves the session recordings the requesting user has access to.
+//
+// Recordings can be filtered on their `name`, `namespace`, `template`, `desktop`, or `user`.
+// The `name` of a recording is its ID, which begins with the time it was started.
+// responses:
+//   200: recordingsResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetRecordings(w http.ResponseWriter, r *http.Request) {
+	opts, err := v1.ParseListOptions(r.URL.Query())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	cfg := d.vdiCluster.GetRecordingsConfig()
+	if cfg == nil {
+		apiutil.ReturnAPIError(errRecordingsNotConfigured, w)
+		return
+	}
+	s3, err := d.getRecordingsClient(cfg)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	objects, err := s3.ListObjects(context.TODO(), cfg.Prefix)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	user := apiutil.GetRequestUserSession(r).User
+	recordings := make([]*v1.Recording, 0)
+	for _, obj := range objects {
+		recording, ok := parseRecordingKey(cfg, obj)
+		if !ok {
+			continue
+		}
+		if !user.Evaluate(&v1.APIAction{
+			Verb:              v1.VerbRead,
+			ResourceType:      v1.ResourceRecordings,
+			ResourceName:      recording.Template,
+			ResourceNamespace: recording.Namespace,
+		}) {
+			continue
+		}
+		recordings = append(recordings, recording)
+	}
+
+	indices, meta, err := opts.Apply(len(recordings), v1.ListFields{
+		"name":      func(i int) string { return recordings[i].ID },
+		"namespace": func(i int) string { return recordings[i].Namespace },
+		"template":  func(i int) string { return recordings[i].Template },
+		"desktop":   func(i int) string { return recordings[i].Desktop },
+		"user":      func(i int) string { return recordings[i].User },
+	})
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	page := make([]*v1.Recording, len(indices))
+	for idx, i := range indices {
+		page[idx] = recordings[i]
+	}
+	writeList(opts, meta, page, w)
+}
+
+// swagger:operation GET /api/recordings/{namespace}/{template}/{recording} Recordings getRecording
+// ---
+// summary: Download a session recording.
+// description: |
+//   Recordings are in the FBS 001.000 format and contain the data sent to the
+//   client over the course of the display connection. They can be replayed with
+//   any player supporting the format.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace the recorded desktop ran in
+//   type: string
+//   required: true
+// - name: template
+//   in: path
+//   description: The template the recorded desktop was booted from
+//   type: string
+//   required: true
+// - name: recording
+//   in: path
+//   description: The ID of the recording
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     content:
+//       "application/octet-stream":
+//         type: string
+//         format: binary
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetRecording(w http.ResponseWriter, r *http.Request) {
+	cfg := d.vdiCluster.GetRecordingsConfig()
+	if cfg == nil {
+		apiutil.ReturnAPIError(errRecordingsNotConfigured, w)
+		return
+	}
+	id := apiutil.GetRecordingFromRequest(r)
+	if _, _, _, err := v1.ParseRecordingID(id); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	s3, err := d.getRecordingsClient(cfg)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	key := recordingKey(cfg, apiutil.GetNamespaceFromRequest(r), apiutil.GetTemplateFromRequest(r), id)
+	body, size, err := s3.GetObject(r.Context(), key)
+	if err != nil {
+		if s3util.IsNotFound(err) {
+			apiutil.ReturnAPINotFound(fmt.Errorf("Recording %s not found", id), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+recordingExt))
+	if size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		apiLogger.Error(err, "Failed to copy recording to response")
+	}
+}
+
+// Recordings response
+// swagger:response recordingsResponse
+type swaggerRecordingsResponse struct {
+	// in:body
+	Body []v1.Recording
+}
@@ -3,25 +3,19 @@ package api
 import (
 	"net/http"
 
-	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
-	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 )
 
 // swagger:route GET /api/refresh_token Auth refreshTokenRequest
 // Retrieves a new JWT access token. It uses the HttpOnly cookie included in the request.
+// When using OIDC, the refresh token issued by the provider is used to look up the
+// user's current groups.
 // responses:
 //   200: sessionResponse
 //   400: error
 //   403: error
 //   500: error
 func (d *desktopAPI) GetRefreshToken(w http.ResponseWriter, r *http.Request) {
-
-	if d.vdiCluster.IsUsingOIDCAuth() {
-		apiutil.ReturnAPIError(errors.New("Token has expired and cannot be refreshed due to OIDC auth"), w)
-		return
-	}
-
 	refreshToken, err := r.Cookie(RefreshTokenCookie)
 	if err != nil {
 		apiutil.ReturnAPIForbidden(err, "Could not retrieve a refresh token from the request", w)
@@ -32,20 +26,33 @@ func (d *desktopAPI) GetRefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	username, err := d.lookupRefreshToken(refreshToken.Value)
+	username, providerToken, err := d.lookupRefreshToken(refreshToken.Value)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
 
-	// retrieve the user from the auth provider
-	user, err := d.auth.GetUser(username)
+	// retrieve the user's current information from the auth provider
+	result, err := d.refresh(r, username, providerToken)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
 
+	// the user's roles may have started requiring MFA since they logged in
+	if err := d.applyRoleGrants(result.User); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if required, err := d.mfaEnrollmentRequired(result.User); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	} else if required {
+		apiutil.ReturnAPIForbidden(nil, "MFA is required for your roles, log in again to set it up", w)
+		return
+	}
+
 	// return a new access and refresh token for the user
 	// TODO: Use state during a refresh?
-	d.returnNewJWT(w, &v1.AuthResult{User: user}, true, "")
+	d.returnNewJWT(w, result, true, "")
 }
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation GET /api/roles/{role}/grants Roles getRoleGrants
+// ---
+// summary: Retrieve the users the role is temporarily granted to.
+// parameters:
+// - name: role
+//   in: path
+//   description: The name of the role
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getRoleGrantsResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetRoleGrants(w http.ResponseWriter, r *http.Request) {
+	role := d.getRoleForRequest(w, r)
+	if role == nil {
+		return
+	}
+	grants, err := role.GetActiveGrants()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(&v1.RoleGrantsResponse{Grants: grants}, w)
+}
+
+// Role grants response
+// swagger:response getRoleGrantsResponse
+type swaggerGetRoleGrantsResponse struct {
+	// in:body
+	Body v1.RoleGrantsResponse
+}
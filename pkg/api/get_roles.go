@@ -5,22 +5,42 @@ import (
 	"net/http"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 )
 
 // swagger:route GET /api/roles Roles getRoles
 // Retrieves a list of the authorization roles in kVDI.
+//
+// Roles can be filtered on their `name`. When a `limit` or `continue` token is
+// provided, a page of roles is returned in a list response.
 // responses:
 //   200: rolesResponse
 //   400: error
 //   403: error
 func (d *desktopAPI) GetRoles(w http.ResponseWriter, r *http.Request) {
+	opts, err := v1.ParseListOptions(r.URL.Query())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	roles, err := d.vdiCluster.GetRoles(d.client)
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
-	apiutil.WriteJSON(roles, w)
+	indices, meta, err := opts.Apply(len(roles), v1.ListFields{
+		"name": func(i int) string { return roles[i].GetName() },
+	})
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	page := make([]v1alpha1.VDIRole, len(indices))
+	for idx, i := range indices {
+		page[idx] = roles[i]
+	}
+	writeList(opts, meta, page, w)
 }
 
 // swagger:operation GET /api/roles/{role} Roles getRole
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/invites Sessions getSessionInvites
+// ---
+// summary: Retrieve the active invites for a desktop session.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getSessionInvitesResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetSessionInvites(w http.ResponseWriter, r *http.Request) {
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", apiutil.GetNamespacedNameFromRequest(r).String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	invites, err := getSessionInvites(desktop)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(&v1.SessionInvitesResponse{Invites: invites}, w)
+}
+
+// Session invites response
+// swagger:response getSessionInvitesResponse
+type swaggerGetSessionInvitesResponse struct {
+	// in:body
+	Body v1.SessionInvitesResponse
+}
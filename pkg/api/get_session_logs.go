@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// streamPodLogs opens a stream of the logs for a container in a pod. It is a
+// variable so it can be replaced in tests.
+var streamPodLogs = k8sutil.StreamPodLogs
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/logs Sessions getSessionLogs
+// ---
+// summary: Stream the logs of the containers in a desktop session.
+// description: |
+//   Logs are returned as plain text for the init containers (e.g. the first-boot
+//   script) followed by the containers (e.g. the display server and `kvdi-proxy`)
+//   of the desktop pod. When more than one container is included, each line is
+//   prefixed with the name of the container it came from. When following, lines
+//   from every container are interleaved as they are written, until the client
+//   disconnects or the server's write timeout is reached.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: container
+//   in: query
+//   description: A container to retrieve logs for. May be provided multiple times. Defaults to all of them.
+//   type: string
+// - name: follow
+//   in: query
+//   description: Keep streaming logs as they are written
+//   type: boolean
+// - name: tailLines
+//   in: query
+//   description: The number of lines to retrieve from the end of the logs of each container
+//   type: integer
+// - name: previous
+//   in: query
+//   description: Retrieve the logs of the previous instance of containers that restarted
+//   type: boolean
+// responses:
+//   "200":
+//     "$ref": "#/responses/getLogsResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetSessionLogs(w http.ResponseWriter, r *http.Request) {
+	opts, err := v1.ParseSessionLogOptions(r.URL.Query())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	pod, err := d.getDesktopPodForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", nn.String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	containers, err := getSessionLogContainers(pod, opts.Containers)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// open every stream before writing the response, so errors can still be returned
+	// with a status code when none of them could be opened
+	streams := make([]io.ReadCloser, len(containers))
+	errs := make([]error, len(containers))
+	var opened int
+	for idx, container := range containers {
+		streams[idx], errs[idx] = streamPodLogs(r.Context(), pod, opts.PodLogOptions(container))
+		if errs[idx] == nil {
+			opened++
+		}
+	}
+	if opened == 0 {
+		apiutil.ReturnAPIError(errs[0], w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	out := &sessionLogWriter{w: w, prefix: len(containers) > 1}
+
+	if !opts.Follow {
+		for idx, container := range containers {
+			out.copy(container, streams[idx], errs[idx])
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for idx, container := range containers {
+		wg.Add(1)
+		go func(container string, stream io.ReadCloser, err error) {
+			defer wg.Done()
+			out.copy(container, stream, err)
+		}(container, streams[idx], errs[idx])
+	}
+	wg.Wait()
+}
+
+// getSessionLogContainers returns the requested containers in the order they run in
+// the pod, or all of them if none were requested.
+func getSessionLogContainers(pod *corev1.Pod, requested []string) ([]string, error) {
+	all := make([]string, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	for _, container := range pod.Spec.InitContainers {
+		all = append(all, container.Name)
+	}
+	for _, container := range pod.Spec.Containers {
+		all = append(all, container.Name)
+	}
+	if len(requested) == 0 {
+		return all, nil
+	}
+	containers := make([]string, 0, len(requested))
+	for _, container := range all {
+		if common.StringSliceContains(requested, container) {
+			containers = append(containers, container)
+		}
+	}
+	for _, name := range requested {
+		if !common.StringSliceContains(all, name) {
+			return nil, fmt.Errorf("Desktop session %s/%s has no container named %s", pod.Namespace, pod.Name, name)
+		}
+	}
+	return containers, nil
+}
+
+// sessionLogWriter writes lines of container logs to a response, optionally
+// prefixed with the container they came from. It is safe to write lines from
+// multiple containers concurrently.
+type sessionLogWriter struct {
+	w      http.ResponseWriter
+	prefix bool
+	mux    sync.Mutex
+}
+
+// copy writes the lines from the given stream until it ends, and then closes it. If
+// the stream could not be opened, the error is written in its place.
+func (s *sessionLogWriter) copy(container string, stream io.ReadCloser, err error) {
+	if err != nil {
+		s.writeLine(container, []byte(fmt.Sprintf("Error retrieving logs: %s\n", err.Error())))
+		return
+	}
+	defer stream.Close()
+	rdr := bufio.NewReader(stream)
+	for {
+		line, err := rdr.ReadBytes('\n')
+		if len(line) > 0 {
+			if line[len(line)-1] != '\n' {
+				line = append(line, '\n')
+			}
+			if werr := s.writeLine(container, line); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF && err != context.Canceled {
+				apiLogger.Error(err, "Error reading logs for desktop session", "Container", container)
+			}
+			return
+		}
+	}
+}
+
+func (s *sessionLogWriter) writeLine(container string, line []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.prefix {
+		line = append([]byte(fmt.Sprintf("[%s] ", container)), line...)
+	}
+	if _, err := s.w.Write(line); err != nil {
+		return err
+	}
+	apiutil.Flush(s.w)
+	return nil
+}
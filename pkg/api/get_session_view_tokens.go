@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation GET /api/sessions/{namespace}/{name}/viewtokens Sessions getSessionViewTokens
+// ---
+// summary: Retrieve the active view tokens for a desktop session.
+// description: The tokens themselves are not returned, only their metadata.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getSessionViewTokensResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetSessionViewTokens(w http.ResponseWriter, r *http.Request) {
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", apiutil.GetNamespacedNameFromRequest(r).String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	tokens, err := getViewTokens(desktop)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(&v1.ViewTokensResponse{ViewTokens: tokens}, w)
+}
+
+// Session view tokens response
+// swagger:response getSessionViewTokensResponse
+type swaggerGetSessionViewTokensResponse struct {
+	// in:body
+	Body v1.ViewTokensResponse
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// swagger:route GET /api/status Miscellaneous getStatus
+// Reports the conditions of the VDICluster, as last observed by the manager, along
+// with the health of the components the app depends on. The cluster is only ready
+// when all of them are.
+// responses:
+//   200: statusResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) GetStatus(w http.ResponseWriter, r *http.Request) {
+	cluster := &v1alpha1.VDICluster{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: d.clusterName}, cluster); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	conditions := cluster.Status.Conditions
+	if conditions == nil {
+		conditions = []v1alpha1.VDIClusterCondition{}
+	}
+	health := d.checkHealth()
+	apiutil.WriteJSON(&v1alpha1.ClusterStatusResponse{
+		Name:       cluster.GetName(),
+		Ready:      cluster.Status.Ready && health.Healthy(),
+		Conditions: conditions,
+		Health:     health,
+	}, w)
+}
+
+// Cluster status response
+// swagger:response statusResponse
+type swaggerStatusResponse struct {
+	// in:body
+	Body v1alpha1.ClusterStatusResponse
+}
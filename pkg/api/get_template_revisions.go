@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation GET /api/templates/{template}/revisions Templates getTemplateRevisions
+// ---
+// summary: Retrieve the recorded revisions of a DesktopTemplate.
+// description: |
+//   Revisions are returned newest first. Revisions are recorded when templates are
+//   written through the API, and when desktops are booted from them.
+// parameters:
+// - name: template
+//   in: path
+//   description: The DesktopTemplate to retrieve revisions for
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/templateRevisionsResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetDesktopTemplateRevisions(w http.ResponseWriter, r *http.Request) {
+	nn := types.NamespacedName{Name: apiutil.GetTemplateFromRequest(r), Namespace: metav1.NamespaceAll}
+	tmpl := &v1alpha1.DesktopTemplate{}
+	if err := d.client.Get(context.TODO(), nn, tmpl); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	revs, err := tmpl.GetRevisions(d.client, d.vdiCluster.GetCoreNamespace())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(revs, w)
+}
+
+// Template revisions response
+// swagger:response templateRevisionsResponse
+type swaggerTemplateRevisionsResponse struct {
+	// in:body
+	Body []v1alpha1.DesktopTemplateRevision
+}
@@ -2,9 +2,13 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/user"
@@ -14,20 +18,73 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// swagger:route GET /api/templates Templates getTemplates
-// Retrieves available templates to boot desktops from.
+// swagger:operation GET /api/templates Templates getTemplates
+// ---
+// summary: Retrieves the templates the requesting user can boot desktops from.
+// description: |
+//   Templates are only returned if the user is allowed to launch them in at least one
+//   namespace, or in the given `namespace` when it is provided. Templates that attach
+//   GPUs to their desktops include the availability of GPU nodes in their status.
+//
+//   Templates can be filtered on their `name`, `image`, `description`, or `tags`. When a
+//   `limit` or `continue` token is provided, a page of templates is returned in a list
+//   response.
+// parameters:
+// - name: namespace
+//   in: query
+//   description: Only return templates the user can launch in this namespace.
+//   type: string
+//   required: false
 // responses:
-//   200: templatesResponse
-//   400: error
-//   403: error
+//   "200":
+//     "$ref": "#/responses/templatesResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
 func (d *desktopAPI) GetDesktopTemplates(w http.ResponseWriter, r *http.Request) {
+	opts, err := v1.ParseListOptions(r.URL.Query())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	sess := apiutil.GetRequestUserSession(r)
 	tmpls, err := d.getAllDesktopTemplates()
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
-	apiutil.WriteJSON(user.FilterTemplates(sess.User, tmpls.Items), w)
+	if err := d.setGPUStatus(tmpls.Items); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	filtered := user.FilterTemplates(sess.User, tmpls.Items, r.URL.Query().Get("namespace"))
+	indices, meta, err := opts.Apply(len(filtered), v1.ListFields{
+		"name":        func(i int) string { return filtered[i].GetName() },
+		"image":       func(i int) string { return filtered[i].Spec.Image },
+		"description": func(i int) string { return filtered[i].Spec.Description },
+		"tags":        func(i int) string { return formatTemplateTags(filtered[i].Spec.Tags) },
+	})
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	page := make([]v1alpha1.DesktopTemplate, len(indices))
+	for idx, i := range indices {
+		page[idx] = filtered[i]
+	}
+	writeList(opts, meta, page, w)
+}
+
+// formatTemplateTags returns the given tags as a sorted, comma-separated list of
+// `key=value` pairs for filtering and sorting.
+func formatTemplateTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, val := range tags {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, val))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
 }
 
 // getAllDesktopTemplates lists the DesktopTemplates registered in the api servers.
@@ -66,7 +123,12 @@ func (d *desktopAPI) GetDesktopTemplate(w http.ResponseWriter, r *http.Request)
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
-	apiutil.WriteJSON(tmpl, w)
+	tmpls := []v1alpha1.DesktopTemplate{*tmpl}
+	if err := d.setGPUStatus(tmpls); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(tmpls[0], w)
 }
 
 // Templates response
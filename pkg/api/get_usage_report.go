@@ -0,0 +1,114 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/billing"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// defaultUsageReportDays is the number of days covered by a usage report when no
+// start is given.
+const defaultUsageReportDays = 30
+
+// maxUsageReportDays is the largest number of days a single usage report may cover.
+const maxUsageReportDays = 366
+
+// swagger:operation GET /api/reports/usage Reports getUsageReport
+// ---
+// summary: Retrieve the desktop usage for the cluster over a range of days.
+// description: |
+//   Usage is aggregated by the given field. When grouping by role, usage is counted
+//   towards each role the user held when launching the desktop. The groups are returned
+//   as CSV when `text/csv` is requested in the Accept header, otherwise the full report
+//   is returned as JSON.
+// produces:
+// - application/json
+// - text/csv
+// parameters:
+// - name: groupBy
+//   in: query
+//   description: The field to group usage by, one of user, role, template, or namespace. Defaults to user.
+//   type: string
+//   required: false
+// - name: start
+//   in: query
+//   description: The first day to include in YYYY-MM-DD format. Defaults to 30 days before the end.
+//   type: string
+//   required: false
+// - name: end
+//   in: query
+//   description: The last day to include in YYYY-MM-DD format. Defaults to today.
+//   type: string
+//   required: false
+// responses:
+//   "200":
+//     "$ref": "#/responses/usageReportResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUsageReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	groupBy := v1.UsageGroupBy(query.Get("groupBy"))
+	if groupBy == "" {
+		groupBy = v1.UsageGroupByUser
+	}
+	if !groupBy.IsValid() {
+		apiutil.ReturnAPIError(fmt.Errorf("Invalid groupBy '%s'", groupBy), w)
+		return
+	}
+
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	if raw := query.Get("end"); raw != "" {
+		var err error
+		if end, err = time.Parse(v1.UsageDateFormat, raw); err != nil {
+			apiutil.ReturnAPIError(fmt.Errorf("Invalid end date '%s', expected YYYY-MM-DD", raw), w)
+			return
+		}
+	}
+	start := end.AddDate(0, 0, -(defaultUsageReportDays - 1))
+	if raw := query.Get("start"); raw != "" {
+		var err error
+		if start, err = time.Parse(v1.UsageDateFormat, raw); err != nil {
+			apiutil.ReturnAPIError(fmt.Errorf("Invalid start date '%s', expected YYYY-MM-DD", raw), w)
+			return
+		}
+	}
+	if start.After(end) {
+		apiutil.ReturnAPIError(fmt.Errorf("The start date must not be after the end date"), w)
+		return
+	}
+	if end.Sub(start) >= maxUsageReportDays*24*time.Hour {
+		apiutil.ReturnAPIError(fmt.Errorf("Usage reports may not cover more than %d days", maxUsageReportDays), w)
+		return
+	}
+
+	days, err := billing.ReadUsage(d.client, d.vdiCluster, start, end)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	report := billing.NewUsageReport(start.Format(v1.UsageDateFormat), end.Format(v1.UsageDateFormat), groupBy, days)
+
+	if !isCSV(r.Header.Get("Accept")) {
+		apiutil.WriteJSON(report, w)
+		return
+	}
+	w.Header().Set("Content-Type", csvContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=usage-%s-%s-%s.csv", groupBy, report.Start, report.End))
+	if err := billing.WriteUsageReportCSV(w, report); err != nil {
+		apiLogger.Error(err, "Failed to write usage report to response")
+	}
+}
+
+// The usage report
+// swagger:response usageReportResponse
+type swaggerUsageReportResponse struct {
+	// in:body
+	Body v1.UsageReport
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/users/{user}/dotfiles Users getUserDotfilesRequest
+// ---
+// summary: Retrieves the dotfiles repository configured for the given user.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getDotfilesResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserDotfiles(w http.ResponseWriter, r *http.Request) {
+	dotfiles, err := d.getUserDotfiles(apiutil.GetUserFromRequest(r))
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(dotfiles, w)
+}
+
+// getUserDotfiles returns the dotfiles configuration for the given user. An empty
+// configuration is returned if the user has none.
+func (d *desktopAPI) getUserDotfiles(username string) (*v1.DotfilesConfig, error) {
+	dotfiles := &v1.DotfilesConfig{}
+	users, err := d.secrets.ReadSecretMap(v1.DotfilesSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return dotfiles, nil
+		}
+		return nil, err
+	}
+	if data, ok := users[username]; ok {
+		if err := json.Unmarshal(data, dotfiles); err != nil {
+			return nil, err
+		}
+	}
+	return dotfiles, nil
+}
+
+// Dotfiles response
+// swagger:response getDotfilesResponse
+type swaggerGetDotfilesResponse struct {
+	// in:body
+	Body v1.DotfilesConfig
+}
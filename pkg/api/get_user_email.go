@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/users/{user}/email Users getUserEmailRequest
+// ---
+// summary: Retrieves the email address notifications are sent to for the given user.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getUserEmailResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserEmail(w http.ResponseWriter, r *http.Request) {
+	addr, err := d.getUserEmail(apiutil.GetUserFromRequest(r))
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(addr, w)
+}
+
+// getUserEmail returns the email configuration for the given user. An empty
+// configuration is returned if the user has none.
+func (d *desktopAPI) getUserEmail(username string) (*v1.UserEmailConfig, error) {
+	users, err := d.secrets.ReadSecretMap(v1.UserEmailsSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return &v1.UserEmailConfig{}, nil
+		}
+		return nil, err
+	}
+	return &v1.UserEmailConfig{Address: string(users[username])}, nil
+}
+
+// User email response
+// swagger:response getUserEmailResponse
+type swaggerGetUserEmailResponse struct {
+	// in:body
+	Body v1.UserEmailConfig
+}
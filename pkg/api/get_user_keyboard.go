@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/users/{user}/keyboard Users getUserKeyboardRequest
+// ---
+// summary: Retrieves the keyboard layout set on the displays of the given user's desktop sessions.
+// description: An empty layout means the layout detected by the client is used.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getUserKeyboardResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserKeyboard(w http.ResponseWriter, r *http.Request) {
+	layout, err := d.getUserKeyboardLayout(apiutil.GetUserFromRequest(r))
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(layout, w)
+}
+
+// getUserKeyboardLayout returns the keyboard layout for the given user. An empty
+// configuration is returned if the user has none.
+func (d *desktopAPI) getUserKeyboardLayout(username string) (*v1.KeyboardLayoutConfig, error) {
+	layout := &v1.KeyboardLayoutConfig{}
+	users, err := d.secrets.ReadSecretMap(v1.UserKeyboardLayoutsSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return layout, nil
+		}
+		return nil, err
+	}
+	data, ok := users[username]
+	if !ok {
+		return layout, nil
+	}
+	return layout, json.Unmarshal(data, layout)
+}
+
+// User keyboard layout response
+// swagger:response getUserKeyboardResponse
+type swaggerGetUserKeyboardResponse struct {
+	// in:body
+	Body v1.KeyboardLayoutConfig
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation GET /api/users/{user}/logins Users getUserLoginsRequest
+// ---
+// summary: Retrieves the recorded login attempts for the given user, newest first.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// - name: start
+//   in: query
+//   description: Only return attempts made at or after this time, in RFC3339 format.
+//   type: string
+//   required: false
+// - name: end
+//   in: query
+//   description: Only return attempts made at or before this time, in RFC3339 format.
+//   type: string
+//   required: false
+// - name: success
+//   in: query
+//   description: Only return successful (true) or failed (false) attempts.
+//   type: string
+//   required: false
+// responses:
+//   "200":
+//     "$ref": "#/responses/loginHistoryResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserLogins(w http.ResponseWriter, r *http.Request) {
+	q, err := parseLoginHistoryQuery(r)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	q.user = apiutil.GetUserFromRequest(r)
+	history, err := d.queryLoginHistory(q)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(history, w)
+}
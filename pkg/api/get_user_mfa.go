@@ -43,10 +43,17 @@ func (d *desktopAPI) GetUserMFA(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	remaining, err := d.mfa.GetBackupCodesRemaining(username)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
 	apiutil.WriteJSON(&v1.MFAResponse{
-		Enabled:         true,
-		Verified:        verified,
-		ProvisioningURI: gotp.NewDefaultTOTP(secret).ProvisioningUri(username, "kVDI"),
+		Enabled:              true,
+		Verified:             verified,
+		ProvisioningURI:      gotp.NewDefaultTOTP(secret).ProvisioningUri(username, "kVDI"),
+		BackupCodesRemaining: remaining,
 	}, w)
 }
 
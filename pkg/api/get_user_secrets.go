@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/users/{user}/secrets Users getUserSecretsRequest
+// ---
+// summary: Retrieves the names of the secrets configured for the given user.
+// description: The values of secrets are never returned.
+// parameters:
+//   - name: user
+//     in: path
+//     description: The user to query
+//     type: string
+//     required: true
+//
+// responses:
+//
+//	"200":
+//	  "$ref": "#/responses/getUserSecretsResponse"
+//	"400":
+//	  "$ref": "#/responses/error"
+//	"403":
+//	  "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserSecrets(w http.ResponseWriter, r *http.Request) {
+	_, secrets, err := d.readUserSecrets(apiutil.GetUserFromRequest(r))
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	apiutil.WriteJSON(&v1.UserSecretsResponse{Names: names}, w)
+}
+
+// readUserSecrets returns the secrets of all users, along with the decoded secrets
+// of the given one.
+func (d *desktopAPI) readUserSecrets(username string) (map[string][]byte, map[string]string, error) {
+	users, err := d.secrets.ReadSecretMap(v1.UserSecretsSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return nil, nil, err
+		}
+		users = make(map[string][]byte)
+	}
+	secrets := make(map[string]string)
+	if data, ok := users[username]; ok {
+		if err := json.Unmarshal(data, &secrets); err != nil {
+			return nil, nil, err
+		}
+	}
+	return users, secrets, nil
+}
+
+// writeUserSecrets updates the secrets of the given user in the map of all users
+// and writes it back to the secrets backend. The caller should hold the secrets lock.
+func (d *desktopAPI) writeUserSecrets(users map[string][]byte, username string, secrets map[string]string) error {
+	if len(secrets) == 0 {
+		delete(users, username)
+	} else {
+		data, err := json.Marshal(secrets)
+		if err != nil {
+			return err
+		}
+		users[username] = data
+	}
+	return d.secrets.WriteSecretMap(v1.UserSecretsSecretKey, users)
+}
+
+// User secrets response
+// swagger:response getUserSecretsResponse
+type swaggerGetUserSecretsResponse struct {
+	// in:body
+	Body v1.UserSecretsResponse
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+)
+
+// swagger:operation GET /api/users/{user}/snapshots Users getUserSnapshotsRequest
+// ---
+// summary: Retrieves the snapshots of the given user's userdata volumes.
+// description: Snapshots are returned newest first.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/snapshotsResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserSnapshots(w http.ResponseWriter, r *http.Request) {
+	list := k8sutil.NewVolumeSnapshotList()
+	if err := d.client.List(context.TODO(), list, d.vdiCluster.GetUserDesktopsSelector(apiutil.GetUserFromRequest(r))); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	snapshots := make([]*v1.DesktopSnapshot, len(list.Items))
+	for i := range list.Items {
+		snapshots[i] = newDesktopSnapshot(&list.Items[i])
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].CreatedAt > snapshots[j].CreatedAt })
+	apiutil.WriteJSON(snapshots, w)
+}
+
+// A list of snapshots of a user's userdata volumes
+// swagger:response snapshotsResponse
+type swaggerSnapshotsResponse struct {
+	// in:body
+	Body []v1.DesktopSnapshot
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation GET /api/users/{user}/userdata Users getUserDataRequest
+// ---
+// summary: Retrieves the first-boot script configured for the given user.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to query
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/getUserDataResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetUserData(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+
+	users, err := d.secrets.ReadSecretMap(v1.UserDataSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		users = make(map[string][]byte)
+	}
+
+	apiutil.WriteJSON(&v1.UserDataResponse{
+		UserData: string(users[username]),
+	}, w)
+}
+
+// User data response
+// swagger:response getUserDataResponse
+type swaggerGetUserDataResponse struct {
+	// in:body
+	Body v1.UserDataResponse
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"strings"
 
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
@@ -10,16 +11,44 @@ import (
 
 // swagger:route GET /api/users Users getUsers
 // Retrieves all the users currently known to kVDI.
+//
+// Users can be filtered on their `name` or `roles`. When a `limit` or `continue` token
+// is provided, a page of users is returned in a list response.
 // responses:
 //   200: usersResponse
 //   400: error
 //   403: error
 func (d *desktopAPI) GetUsers(w http.ResponseWriter, r *http.Request) {
+	opts, err := v1.ParseListOptions(r.URL.Query())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	users, err := d.auth.GetUsers()
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	indices, meta, err := opts.Apply(len(users), v1.ListFields{
+		"name": func(i int) string { return users[i].GetName() },
+		"roles": func(i int) string {
+			names := make([]string, len(users[i].Roles))
+			for idx, role := range users[i].Roles {
+				names[idx] = role.GetName()
+			}
+			return strings.Join(names, ",")
+		},
+	})
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	page := make([]*v1.VDIUser, len(indices))
+	for idx, i := range indices {
+		page[idx] = users[i]
+	}
+	users = page
+
 	mfaUsers, err := d.mfa.GetMFAUsers()
 	if err != nil {
 		apiutil.ReturnAPIError(err, w)
@@ -37,7 +66,7 @@ func (d *desktopAPI) GetUsers(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-	apiutil.WriteJSON(users, w)
+	writeList(opts, meta, users, w)
 }
 
 // swagger:operation GET /api/users/{user} Users getUser
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation GET /api/users/export Users exportUsers
+// ---
+// summary: Export all users and their role assignments.
+// description: |
+//   Users are returned as CSV when `text/csv` is requested in the Accept header,
+//   otherwise as a JSON list. The output can be imported into another cluster with
+//   `/api/users/import`. Passwords are not exported, so imported users are given
+//   new ones.
+// produces:
+// - application/json
+// - text/csv
+// responses:
+//   "200":
+//     "$ref": "#/responses/exportUsersResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetExportUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := d.auth.GetUsers()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	records := make([]*v1.UserRecord, len(users))
+	for idx, user := range users {
+		records[idx] = &v1.UserRecord{Username: user.GetName(), Roles: make([]string, len(user.Roles))}
+		for ridx, role := range user.Roles {
+			records[idx].Roles[ridx] = role.GetName()
+		}
+	}
+	if !isCSV(r.Header.Get("Accept")) {
+		apiutil.WriteJSON(records, w)
+		return
+	}
+	w.Header().Set("Content-Type", csvContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=users.csv")
+	if err := writeUserCSV(w, records); err != nil {
+		apiLogger.Error(err, "Failed to write users to response")
+	}
+}
+
+// The exported users
+// swagger:response exportUsersResponse
+type swaggerExportUsersResponse struct {
+	// in:body
+	Body []v1.UserRecord
+}
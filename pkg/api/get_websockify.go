@@ -1,20 +1,28 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strings"
 
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/lock"
 	"github.com/tinyzimmer/kvdi/pkg/util/tlsutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/tracing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/gorilla/websocket"
 	"github.com/koding/websocketproxy"
 )
 
+// resumeTokenRegex matches the tokens clients may use to resume a display session.
+var resumeTokenRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -41,6 +49,35 @@ var upgrader = websocket.Upgrader{
 //   description: The X-Session-Token of the requesting client
 //   type: string
 //   required: true
+// - name: resume
+//   in: query
+//   description: |
+//     A token generated by the client to identify its display session. If the template
+//     allows reconnecting, a dropped connection that reconnects with the same token
+//     resumes the existing session. Reconnecting with the same token also replaces
+//     a connection that was not closed yet, on any replica of the app.
+//   type: string
+//   required: false
+// - name: handoff
+//   in: query
+//   description: |
+//     A code created with `POST /api/sessions/{namespace}/{name}/handoff` on another
+//     device of the user. The connection takes over the display from whichever
+//     connection holds it, which is then closed. Each code can only be used once.
+//   type: string
+//   required: false
+// - name: keyboardLayout
+//   in: query
+//   description: |
+//     The XKB keyboard layout detected by the client, set on the display when the user has
+//     not configured a layout of their own.
+//   type: string
+//   required: false
+// - name: keyboardVariant
+//   in: query
+//   description: The XKB variant of the keyboard layout detected by the client.
+//   type: string
+//   required: false
 // responses:
 //   "UPGRADE": {}
 //   "400":
@@ -50,25 +87,366 @@ var upgrader = websocket.Upgrader{
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) GetWebsockify(w http.ResponseWriter, r *http.Request) {
+	nn := apiutil.GetNamespacedNameFromRequest(r)
 	lockName := fmt.Sprintf(
 		"display-%s",
-		strings.Replace(apiutil.GetNamespacedNameFromRequest(r).String(), "/", "-", -1),
+		strings.Replace(nn.String(), "/", "-", -1),
 	)
 	labels := d.vdiCluster.GetComponentLabels("display-lock")
 	labels[v1.ClientAddrLabel] = strings.Split(r.RemoteAddr, ":")[0] // Populated by ProxyHeaders handler wrapping the router
-	sessionLock := lock.New(d.client, lockName, -1).WithLabels(labels)
+	// A client reconnecting to the same display session takes over the lock, even
+	// when it reaches another replica than the one serving its old connection.
+	sessionLock := lock.New(d.client, lockName, -1).WithLabels(labels).WithTakeoverKey(getDisplayTakeoverKey(r))
+
+	// A user picking up the display on another device takes it from whichever
+	// connection holds it.
+	if r.URL.Query().Get(v1.HandoffQueryParam) != "" {
+		redeemed, err := d.redeemSessionHandoff(r)
+		if err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		if !redeemed {
+			apiutil.ReturnAPIForbidden(nil, "The handoff code is invalid or has expired", w)
+			return
+		}
+		apiLogger.Info(fmt.Sprintf("User %s is picking up desktop %s on another device", apiutil.GetRequestUserSession(r).User.GetName(), nn.String()))
+		sessionLock = sessionLock.WithForcedTakeover()
+	}
 
 	if err := sessionLock.Acquire(); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
 
+	d.recordDesktopActivity(nn)
+	w, stopWatching := watchLock(w, sessionLock)
 	defer func() {
+		stopWatching()
 		if err := sessionLock.Release(); err != nil {
 			apiLogger.Error(err, "Failed to release lock on desktop display")
 		}
+		// recorded after the release so the manager sees the desktop as disconnected
+		d.recordDesktopActivity(nn)
 	}()
 
+	// only the owner of the display may change its keyboard layout
+	query := r.URL.Query()
+	detectedLayout := &v1.KeyboardLayoutConfig{
+		Layout:  query.Get(v1.KeyboardLayoutQueryParam),
+		Variant: query.Get(v1.KeyboardVariantQueryParam),
+	}
+
+	if err := d.setDisplayOptions(r); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	d.setKeyboardLayoutOptions(r, detectedLayout)
+
+	recorded, err := d.setRecordingOptions(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if recorded {
+		// the recording is finished once the proxy returns
+		defer func() { go d.uploadRecordings(nn) }()
+	}
+
+	d.ServeWebsocketProxy(w, r)
+}
+
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/view Desktops doViewWebsocket
+// ---
+// summary: Start a view-only mTLS noVNC connection with the provided Desktop.
+// description: |
+//   Assumes the requesting client is a noVNC RFB object. The display is streamed to
+//   the client, but any keyboard, mouse, or clipboard events it sends are dropped.
+//   Multiple viewers may be connected to a desktop at once.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client
+//   type: string
+//   required: true
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifyView(w http.ResponseWriter, r *http.Request) {
+	if err := d.setDisplayOptions(r); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	d.ServeWebsocketProxy(w, r)
+}
+
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/shadow Desktops doShadowWebsocket
+// ---
+// summary: Attach to the display of another user's desktop session.
+// description: |
+//   Assumes the requesting client is a noVNC RFB object. The connection is view-only
+//   unless interactive mode is requested, in which case the user must also be allowed
+//   to use the template the desktop was booted from. Shadowing does not take the display
+//   lock, so the owner of the desktop remains connected.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client
+//   type: string
+//   required: true
+// - name: interactive
+//   in: query
+//   description: Set to true to forward keyboard and mouse input to the desktop
+//   type: boolean
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifyShadow(w http.ResponseWriter, r *http.Request) {
+	user := apiutil.GetRequestUserSession(r).User
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+
+	interactive := r.URL.Query().Get(v1.ShadowInteractiveQueryParam) == "true"
+	if interactive {
+		if !user.Evaluate(&v1.APIAction{
+			Verb:              v1.VerbUse,
+			ResourceType:      v1.ResourceTemplates,
+			ResourceName:      nn.Name,
+			ResourceNamespace: nn.Namespace,
+		}) {
+			apiutil.ReturnAPIForbidden(nil, "User is not allowed to send input to this desktop", w)
+			return
+		}
+	}
+
+	apiLogger.Info(fmt.Sprintf("User %s is shadowing desktop %s", user.GetName(), nn.String()), "Interactive", interactive)
+
+	d.serveShadowProxy(w, r, interactive)
+}
+
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/invites/{invite} Desktops doInviteWebsocket
+// ---
+// summary: Attach to the display of a desktop session the user was invited to.
+// description: |
+//   Assumes the requesting client is a noVNC RFB object. The connection is view-only
+//   unless interactive mode is requested and the invite allows control of the display.
+//   Like shadowing, the owner of the desktop remains connected.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: invite
+//   in: path
+//   description: The ID of the invite
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client
+//   type: string
+//   required: true
+// - name: interactive
+//   in: query
+//   description: Set to true to forward keyboard and mouse input to the desktop
+//   type: boolean
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifyInvite(w http.ResponseWriter, r *http.Request) {
+	user := apiutil.GetRequestUserSession(r).User
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+
+	invite, err := d.getSessionInviteForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if invite == nil {
+		apiutil.ReturnAPIForbidden(nil, "The invite does not exist or has expired", w)
+		return
+	}
+
+	interactive := r.URL.Query().Get(v1.ShadowInteractiveQueryParam) == "true"
+	if interactive && invite.Mode != v1.SessionInviteControl {
+		apiutil.ReturnAPIForbidden(nil, "The invite does not allow sending input to this desktop", w)
+		return
+	}
+
+	apiLogger.Info(fmt.Sprintf("User %s is attaching to desktop %s with an invite from %s", user.GetName(), nn.String(), invite.CreatedBy), "Invite", invite.ID, "Interactive", interactive)
+
+	d.serveShadowProxy(w, r, interactive)
+}
+
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/viewtoken Desktops doViewTokenWebsocket
+// ---
+// summary: Watch the display of a desktop session with a read-only view token.
+// description: |
+//   Assumes the requesting client is a noVNC RFB object. Input is never forwarded and
+//   the owner of the desktop remains connected.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: A view token created for the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifyViewToken(w http.ResponseWriter, r *http.Request) {
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+
+	viewToken, err := d.getViewTokenForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if viewToken == nil {
+		apiutil.ReturnAPIForbidden(nil, "The view token does not exist or has expired", w)
+		return
+	}
+
+	apiLogger.Info(fmt.Sprintf("Watching desktop %s with a view token from %s", nn.String(), viewToken.CreatedBy), "ViewToken", viewToken.ID, "Label", viewToken.Label)
+
+	d.serveShadowProxy(w, r, false)
+}
+
+// serveShadowProxy proxies a display connection that does not take the display
+// lock. Input is only forwarded when interactive is true.
+func (d *desktopAPI) serveShadowProxy(w http.ResponseWriter, r *http.Request, interactive bool) {
+	if err := d.setDisplayOptions(r); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	query := r.URL.Query()
+	query.Del(v1.ShadowInteractiveQueryParam)
+	if interactive {
+		query.Set(v1.ShadowInteractiveQueryParam, "true")
+	}
+	r.URL.RawQuery = query.Encode()
+
+	d.ServeWebsocketProxy(w, r)
+}
+
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/settings Desktops doDisplaySettings
+// ---
+// summary: Change the quality, frame rate, and bandwidth of the display of a desktop session.
+// description: |
+//   A DisplaySettingsStatus is sent as a text frame when the client connects. Each
+//   DisplaySettings the client sends is applied to its display connections right away,
+//   lowered to the limits of the DesktopTemplate, and answered with a new status. The
+//   settings are kept for the life of the desktop, so they also apply when reconnecting.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client. Can also be provided in the header.
+//   type: string
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifySettings(w http.ResponseWriter, r *http.Request) {
 	d.ServeWebsocketProxy(w, r)
 }
 
@@ -100,9 +478,10 @@ func (d *desktopAPI) GetWebsockify(w http.ResponseWriter, r *http.Request) {
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) GetWebsockifyAudio(w http.ResponseWriter, r *http.Request) {
+	nn := apiutil.GetNamespacedNameFromRequest(r)
 	lockName := fmt.Sprintf(
 		"audio-%s",
-		strings.Replace(apiutil.GetNamespacedNameFromRequest(r).String(), "/", "-", -1),
+		strings.Replace(nn.String(), "/", "-", -1),
 	)
 	labels := d.vdiCluster.GetComponentLabels("audio-lock")
 	labels[v1.ClientAddrLabel] = strings.Split(r.RemoteAddr, ":")[0] // Populated by ProxyHeaders handler wrapping the router
@@ -113,15 +492,257 @@ func (d *desktopAPI) GetWebsockifyAudio(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	d.recordDesktopActivity(nn)
 	defer func() {
 		if err := sessionLock.Release(); err != nil {
 			apiLogger.Error(err, "Failed to release lock on desktop audio")
 		}
+		// recorded after the release so the manager sees the desktop as disconnected
+		d.recordDesktopActivity(nn)
+	}()
+
+	d.ServeWebsocketProxy(w, r)
+}
+
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/smartcard Desktops doSmartCard
+// ---
+// summary: Redirect a client smart card into the given desktop session.
+// description: |
+//   Frames sent over the websocket are forwarded to the pcscd bridge inside the desktop.
+//   The DesktopTemplate for the session must have smart card redirection enabled.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client. Can also be provided in the header.
+//   type: string
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifySmartCard(w http.ResponseWriter, r *http.Request) {
+	lockName := fmt.Sprintf(
+		"smartcard-%s",
+		strings.Replace(apiutil.GetNamespacedNameFromRequest(r).String(), "/", "-", -1),
+	)
+	labels := d.vdiCluster.GetComponentLabels("smartcard-lock")
+	labels[v1.ClientAddrLabel] = strings.Split(r.RemoteAddr, ":")[0] // Populated by ProxyHeaders handler wrapping the router
+	sessionLock := lock.New(d.client, lockName, -1).WithLabels(labels)
+
+	if err := sessionLock.Acquire(); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	defer func() {
+		if err := sessionLock.Release(); err != nil {
+			apiLogger.Error(err, "Failed to release lock on desktop smart card")
+		}
+	}()
+
+	d.ServeWebsocketProxy(w, r)
+}
+
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/printer Desktops doPrinter
+// ---
+// summary: Receive documents printed in the given desktop session.
+// description: |
+//   Each print job is sent as a text frame containing a PrintJob, followed by a binary
+//   frame with the contents of the rendered PDF. Jobs printed while no client is connected
+//   are delivered once one connects. The DesktopTemplate for the session must have
+//   printing enabled.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client. Can also be provided in the header.
+//   type: string
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifyPrinter(w http.ResponseWriter, r *http.Request) {
+	// Only one client receives print jobs at a time, so each job is only printed once.
+	lockName := fmt.Sprintf(
+		"printer-%s",
+		strings.Replace(apiutil.GetNamespacedNameFromRequest(r).String(), "/", "-", -1),
+	)
+	labels := d.vdiCluster.GetComponentLabels("printer-lock")
+	labels[v1.ClientAddrLabel] = strings.Split(r.RemoteAddr, ":")[0] // Populated by ProxyHeaders handler wrapping the router
+	sessionLock := lock.New(d.client, lockName, -1).WithLabels(labels)
+
+	if err := sessionLock.Acquire(); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	defer func() {
+		if err := sessionLock.Release(); err != nil {
+			apiLogger.Error(err, "Failed to release lock on desktop printer")
+		}
 	}()
 
 	d.ServeWebsocketProxy(w, r)
 }
 
+// swagger:operation GET /api/desktops/ws/{namespace}/{name}/usb Desktops doUSB
+// ---
+// summary: Redirect a client USB device into the given desktop session.
+// description: |
+//   The client is expected to speak USB/IP over the websocket as the exporting side. The
+//   device with the given bus ID is imported and attached to the node running the desktop.
+//   The DesktopTemplate for the session must allow the class of the device, and of each
+//   of its interfaces.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: busid
+//   in: query
+//   description: The USB/IP bus ID of the device to redirect
+//   type: string
+//   required: true
+// - name: token
+//   in: query
+//   description: The X-Session-Token of the requesting client. Can also be provided in the header.
+//   type: string
+//   required: false
+// responses:
+//   "UPGRADE": {}
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) GetWebsockifyUSB(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get(v1.USBBusIDQueryParam) == "" {
+		apiutil.ReturnAPIError(errors.New("A busid is required"), w)
+		return
+	}
+	d.ServeWebsocketProxy(w, r)
+}
+
+// setDisplayOptions sets the query parameters used by the kvdi-proxy to filter
+// a display connection. Clipboard updates are disabled in either direction if
+// the requesting user lacks the corresponding verb or the template of the
+// requested desktop does not allow it. If the user's roles or the template
+// require the display to be watermarked, the text for the kvdi-proxy to render
+// is added as well. Any of these parameters supplied by the client are removed,
+// along with any recording ID, and the username is always set to the requesting
+// user for logging into desktops served over RDP.
+func (d *desktopAPI) setDisplayOptions(r *http.Request) error {
+	query := r.URL.Query()
+	query.Del(v1.WatermarkQueryParam)
+	query.Del(v1.DisableClipboardInQueryParam)
+	query.Del(v1.DisableClipboardOutQueryParam)
+	query.Del(v1.RecordingQueryParam)
+	query.Del(v1.KeyboardLayoutQueryParam)
+	query.Del(v1.KeyboardVariantQueryParam)
+	query.Del(v1.HandoffQueryParam)
+	if token := query.Get(v1.ResumeTokenQueryParam); token != "" && !resumeTokenRegex.MatchString(token) {
+		query.Del(v1.ResumeTokenQueryParam)
+	}
+
+	user := apiutil.GetRequestUserSession(r).User
+	query.Set(v1.UsernameQueryParam, user.GetName())
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+
+	desktop := &v1alpha1.Desktop{}
+	if err := d.client.Get(context.TODO(), nn, desktop); err != nil {
+		return err
+	}
+	tmpl, err := desktop.GetTemplate(d.client)
+	if err != nil {
+		return err
+	}
+
+	policy := tmpl.GetClipboardPolicy()
+	for verb, opt := range map[v1.Verb]struct {
+		param   string
+		allowed bool
+	}{
+		v1.VerbClipboardIn:  {v1.DisableClipboardInQueryParam, policy.AllowsIn()},
+		v1.VerbClipboardOut: {v1.DisableClipboardOutQueryParam, policy.AllowsOut()},
+	} {
+		if !opt.allowed || !user.Evaluate(&v1.APIAction{
+			Verb:              verb,
+			ResourceType:      v1.ResourceTemplates,
+			ResourceName:      nn.Name,
+			ResourceNamespace: nn.Namespace,
+		}) {
+			query.Set(opt.param, "true")
+		}
+	}
+
+	if user.WatermarkRequired() || tmpl.WatermarkEnabled() {
+		clientAddr := strings.Split(r.RemoteAddr, ":")[0] // Populated by ProxyHeaders handler wrapping the router
+		query.Set(v1.WatermarkQueryParam, fmt.Sprintf("%s %s", user.GetName(), clientAddr))
+	}
+
+	r.URL.RawQuery = query.Encode()
+	return nil
+}
+
+// setKeyboardLayoutOptions passes the keyboard layout to set on the display to the
+// kvdi-proxy. The layout configured for the user takes precedence over the one
+// detected by the client, and invalid layouts from the client are ignored.
+func (d *desktopAPI) setKeyboardLayoutOptions(r *http.Request, detected *v1.KeyboardLayoutConfig) {
+	user := apiutil.GetRequestUserSession(r).User
+	layout, err := d.getUserKeyboardLayout(user.GetName())
+	if err != nil {
+		apiLogger.Error(err, "Failed to retrieve keyboard layout for user, using the layout detected by the client", "User", user.GetName())
+		layout = &v1.KeyboardLayoutConfig{}
+	}
+	if layout.Layout == "" && detected.Validate() == nil {
+		layout = detected
+	}
+	if layout.Layout == "" {
+		return
+	}
+	query := r.URL.Query()
+	query.Set(v1.KeyboardLayoutQueryParam, layout.Layout)
+	if layout.Variant != "" {
+		query.Set(v1.KeyboardVariantQueryParam, layout.Variant)
+	}
+	r.URL.RawQuery = query.Encode()
+}
+
 func (d *desktopAPI) ServeWebsocketProxy(w http.ResponseWriter, r *http.Request) {
 	endpointURL, err := d.getDesktopWebsocketURL(r)
 	if err != nil {
@@ -145,6 +766,14 @@ func (d *desktopAPI) ServeWebsocketProxy(w http.ResponseWriter, r *http.Request)
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 	}
+	// Connections watching a lock are closed from the desktop side when it is taken
+	// over, so the proxy closes the client connection cleanly.
+	if watcher, ok := w.(*lockWatchingResponseWriter); ok {
+		proxy.Dialer.NetDial = watcher.dialBackend
+	}
 	proxy.Upgrader = &upgrader
+	proxy.Director = func(incoming *http.Request, out http.Header) {
+		tracing.Inject(incoming.Context(), out)
+	}
 	proxy.ServeHTTP(w, r)
 }
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Request containing a new announcement
+// swagger:parameters postAnnouncementRequest
+type swaggerCreateAnnouncementRequest struct {
+	// in:body
+	Body v1.CreateAnnouncementRequest
+}
+
+// swagger:route POST /api/announcements Announcements postAnnouncementRequest
+// Create a new announcement to show to users.
+// responses:
+//   200: boolResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.CreateAnnouncementRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	announcement := &v1alpha1.VDIAnnouncement{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: req.GetName(),
+			Labels: map[string]string{
+				v1.RoleClusterRefLabel: d.vdiCluster.GetName(),
+			},
+		},
+		Message:  req.Message,
+		Severity: req.Severity,
+		Roles:    req.Roles,
+	}
+	announcement.SetSchedule(req.StartTime, req.EndTime)
+	if err := d.client.Create(context.TODO(), announcement); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
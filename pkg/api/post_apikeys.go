@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// Request containing a new API key
+// swagger:parameters postAPIKeyRequest
+type swaggerCreateAPIKeyRequest struct {
+	// in:body
+	Body v1.CreateAPIKeyRequest
+}
+
+// swagger:route POST /api/apikeys APIKeys postAPIKeyRequest
+// Create a new API key for the requesting user. The token is only returned in this response.
+// responses:
+//   200: postAPIKeyResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) PostAPIKeys(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.CreateAPIKeyRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	if d.vdiCluster.IsUsingOIDCAuth() {
+		apiutil.ReturnAPIError(errors.New("API keys are not supported when using OIDC authentication"), w)
+		return
+	}
+	userSession := apiutil.GetRequestUserSession(r)
+	// make sure the key does not grant permissions the user does not have
+	for _, rule := range req.Rules {
+		if !userSession.User.IncludesRule(rule, NewResourceGetter(d)) {
+			apiutil.ReturnAPIForbidden(nil, elevateDenyReason, w)
+			return
+		}
+	}
+	key, token, err := d.createAPIKey(userSession.User.GetName(), req)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(&v1.CreateAPIKeyResponse{APIKey: key, Token: token}, w)
+}
+
+// Created API key response
+// swagger:response postAPIKeyResponse
+type swaggerCreateAPIKeyResponse struct {
+	// in:body
+	Body v1.CreateAPIKeyResponse
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
@@ -11,11 +12,13 @@ import (
 )
 
 // swagger:route POST /api/authorize Auth authorizeRequest
-// Authorizes a JWT token with a one time password.
+// Authorizes a JWT token with a one time password or an unused MFA backup code. When the cluster uses push MFA, the transaction of a sent push is polled instead, and a 202 with the push status is returned until it is approved. Transactions can only be used once, by the session that sent the push.
 // responses:
 //   200: sessionResponse
+//   202: mfaPushResponse
 //   400: error
 //   403: error
+//   429: error
 func (d *desktopAPI) PostAuthorize(w http.ResponseWriter, r *http.Request) {
 	userSession := apiutil.GetRequestUserSession(r)
 
@@ -26,14 +29,26 @@ func (d *desktopAPI) PostAuthorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Push MFA is required for every user and does not use OTP secrets
+	if d.mfaProvider != nil {
+		d.authorizePush(w, r, userSession, req)
+		return
+	}
+
 	secret, verified, err := d.mfa.GetUserMFAStatus(userSession.User.Name)
 	if err != nil {
 		if !errors.IsUserNotFoundError(err) {
 			apiutil.ReturnAPIError(err, w)
 			return
 		}
+		// The user's roles require MFA, but they have not enrolled yet
+		if userSession.User.MFARequired() {
+			apiutil.ReturnAPIForbidden(nil, "MFA is required for your roles, it must be set up before authorizing", w)
+			return
+		}
 		// The user does not require MFA - this shouldn't happen but go ahead
 		// and send back an authorized token
+		d.publishLoginEvent(userSession.User)
 		d.returnNewJWT(w, &v1.AuthResult{
 			User:                userSession.User,
 			RefreshNotSupported: !userSession.Renewable,
@@ -41,6 +56,11 @@ func (d *desktopAPI) PostAuthorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject the attempt if the client or user has made too many
+	if !d.checkLoginAllowed(w, r, userSession.User.Name) {
+		return
+	}
+
 	if !verified {
 		// The user has not verified their MFA secret yet.
 		// The login attempt should not have required MFA.
@@ -51,10 +71,24 @@ func (d *desktopAPI) PostAuthorize(w http.ResponseWriter, r *http.Request) {
 	totp := gotp.NewDefaultTOTP(secret)
 
 	if totp.Now() != req.GetOTP() {
-		apiutil.ReturnAPIForbidden(nil, "Invalid MFA Code", w)
-		return
+		// fall back to the user's backup codes, a matching code is invalidated
+		used, err := d.mfa.UseBackupCode(userSession.User.Name, req.GetOTP())
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		if !used {
+			d.recordLoginFailure(r, userSession.User.Name)
+			d.recordLoginAttempt(r, userSession.User.Name, false, v1.LoginMFAFailed)
+			apiutil.ReturnAPIForbidden(nil, "Invalid MFA Code", w)
+			return
+		}
+		apiLogger.Info(fmt.Sprintf("User %s authorized with an MFA backup code", userSession.User.Name))
 	}
 
+	d.logins.succeed(userSession.User.Name)
+	d.recordLoginAttempt(r, userSession.User.Name, true, v1.LoginMFAPassed)
+	d.publishLoginEvent(userSession.User)
 	d.returnNewJWT(w, &v1.AuthResult{
 		User:                userSession.User,
 		RefreshNotSupported: !userSession.Renewable,
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:route POST /api/authorize/push Auth authorizePushRequest
+// Sends a push notification for the user to approve their login. The returned transaction is polled with /api/authorize until the push is approved or denied.
+// responses:
+//   200: mfaPushResponse
+//   400: error
+//   403: error
+//   429: error
+func (d *desktopAPI) PostAuthorizePush(w http.ResponseWriter, r *http.Request) {
+	userSession := apiutil.GetRequestUserSession(r)
+
+	if d.mfaProvider == nil {
+		apiutil.ReturnAPIError(errors.New("Push MFA is not configured for this cluster"), w)
+		return
+	}
+
+	// Sending pushes counts as a login attempt
+	if !d.checkLoginAllowed(w, r, userSession.User.Name) {
+		return
+	}
+
+	txID, err := d.mfaProvider.Push(userSession.User, getClientAddr(r))
+	if err != nil {
+		apiLogger.Error(err, "Failed to send MFA push", "User", userSession.User.Name)
+		apiutil.ReturnAPIForbidden(err, "Unable to send MFA push", w)
+		return
+	}
+	transaction, err := d.signPushTransaction(userSession, txID)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// an empty ID means the provider allowed the user without a push
+	status := v1.MFAPushPending
+	if txID == "" {
+		status = v1.MFAPushApproved
+	}
+	apiutil.WriteJSON(&v1.MFAPushResponse{Transaction: transaction, Status: status}, w)
+}
+
+// The transaction for a sent MFA push
+// swagger:response mfaPushResponse
+type swaggerMFAPushResponse struct {
+	// in:body
+	Body v1.MFAPushResponse
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// swagger:route POST /api/config/reload Miscellaneous postConfigReload
+// Re-syncs the runtime configuration of this app instance with the VDICluster.
+// Changes are normally picked up automatically, this can be used to force a sync.
+// responses:
+//   200: configResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) PostConfigReload(w http.ResponseWriter, r *http.Request) {
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: d.clusterName}}
+	if err := d.handleClusterUpdate(req); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(d.vdiCluster.Spec, w)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Request containing a new group
+// swagger:parameters postGroupRequest
+type swaggerCreateGroupRequest struct {
+	// in:body
+	Body v1.CreateGroupRequest
+}
+
+// swagger:route POST /api/groups Groups postGroupRequest
+// Create a new group in kVDI. Groups are only supported with local authentication.
+// responses:
+//   200: boolResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	if err := d.checkGroupsSupported(); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	req := apiutil.GetRequestObject(r).(*v1.CreateGroupRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	group := &v1alpha1.VDIGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: req.GetName(),
+			Labels: map[string]string{
+				v1.RoleClusterRefLabel: d.vdiCluster.GetName(),
+			},
+		},
+		Users: req.Users,
+		Roles: req.Roles,
+	}
+	if err := d.client.Create(context.TODO(), group); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
+
+// checkGroupsSupported returns an error if the cluster is not using an auth
+// backend that supports groups.
+func (d *desktopAPI) checkGroupsSupported() error {
+	if !d.vdiCluster.IsUsingLocalAuth() {
+		return errors.New("Groups are only supported with local authentication, use the groups of your identity provider instead")
+	}
+	return nil
+}
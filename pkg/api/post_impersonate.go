@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Request to impersonate a user
+// swagger:parameters postImpersonateRequest
+type swaggerImpersonateRequest struct {
+	// in:body
+	Body v1.ImpersonateRequest
+}
+
+// swagger:operation POST /api/impersonate/{user} Users postImpersonateRequest
+// ---
+// summary: Retrieve a short-lived token for acting as another user.
+// description: |
+//   The token carries the roles the user would receive when logging in, including
+//   temporary role grants, so that problems they report can be reproduced exactly.
+//   Every request made with the token is recorded in the audit log with the name of
+//   the impersonating user. The token cannot be renewed, cannot be used to create
+//   API keys or impersonate other users, and is refused if the user has privileges
+//   the requesting user does not.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to impersonate
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/sessionResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostImpersonate(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.ImpersonateRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	reqUser := apiutil.GetRequestUserSession(r).User
+	username := apiutil.GetUserFromRequest(r)
+	if username == reqUser.GetName() {
+		apiutil.ReturnAPIError(errors.New("Users cannot impersonate themselves"), w)
+		return
+	}
+
+	user, err := d.auth.GetUser(username)
+	if err != nil {
+		if errors.IsUserNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// add any roles temporarily granted to the user
+	if err := d.applyRoleGrants(user); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// make sure impersonating the user does not grant privileges the requesting
+	// user does not have
+	for _, role := range user.Roles {
+		for _, rule := range role.EffectiveRules() {
+			if !reqUser.IncludesRule(rule, NewResourceGetter(d)) {
+				apiutil.ReturnAPIForbidden(nil, elevateDenyReason, w)
+				return
+			}
+		}
+	}
+
+	keys, err := d.getJWTKeys(true)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// never outlive the session the user would receive when logging in
+	duration := req.GetDuration()
+	if userDuration := user.GetTokenDuration(d.vdiCluster.GetTokenDuration()); userDuration < duration {
+		duration = userDuration
+	}
+	if expiresAt := user.GetRolesExpireAt(); expiresAt > 0 {
+		if untilExpiry := time.Until(time.Unix(expiresAt, 0)); untilExpiry < duration {
+			duration = untilExpiry
+		}
+	}
+	claims, token, err := apiutil.GenerateImpersonationJWT(keys, user, reqUser.GetName(), duration)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiLogger.Info(fmt.Sprintf("User %s is impersonating %s", reqUser.GetName(), username), "ID", claims.Id, "ExpiresAt", claims.ExpiresAt)
+	apiutil.WriteJSON(&v1.SessionResponse{
+		Token:      token,
+		ExpiresAt:  claims.ExpiresAt,
+		Renewable:  false,
+		User:       user,
+		Authorized: true,
+	}, w)
+}
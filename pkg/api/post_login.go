@@ -17,6 +17,7 @@ const userAnonymous = "anonymous"
 //   200: sessionResponse
 //   400: error
 //   403: error
+//   429: error
 //   500: error
 func (d *desktopAPI) PostLogin(w http.ResponseWriter, r *http.Request) {
 
@@ -31,7 +32,7 @@ func (d *desktopAPI) PostLogin(w http.ResponseWriter, r *http.Request) {
 		// pass the request object to the auth backend, it should know how to handle a
 		// GET separately. The backend needs to generate claims that it can then
 		// provide on a subsequent POST with the initial state token.
-		_, err := d.auth.Authenticate(req)
+		_, err := d.authenticate(r, req)
 		if err != nil {
 			apiLogger.Error(err, "Failure handling auth callback")
 			apiutil.ReturnAPIError(err, w)
@@ -55,8 +56,23 @@ func (d *desktopAPI) PostLogin(w http.ResponseWriter, r *http.Request) {
 	// is needed in the authentication flow.
 	req.SetRequest(r)
 
+	// Record who is attempting to log in
+	setAuditUser(r, req.GetUsername())
+
+	// Usernames are read from the certificate when using client certificates, so
+	// only the client address is limited.
+	limitUsername := req.GetUsername()
+	if d.vdiCluster.IsUsingCertAuth() {
+		limitUsername = ""
+	}
+
+	// Reject the attempt if the client or username has made too many
+	if !d.checkLoginAllowed(w, r, limitUsername) {
+		return
+	}
+
 	// Pass the request to the provider
-	result, err := d.auth.Authenticate(req)
+	result, err := d.authenticate(r, req)
 	if err != nil {
 		apiLogger.Error(err, "Authentication failed, checking if anonymous is allowed")
 		// Allow anonymous if set in the configuration
@@ -67,9 +83,13 @@ func (d *desktopAPI) PostLogin(w http.ResponseWriter, r *http.Request) {
 					Roles: []*v1.VDIUserRole{d.vdiCluster.GetLaunchTemplatesRole().ToUserRole()},
 				},
 			}
+			d.recordLoginAttempt(r, userAnonymous, true, v1.LoginMFANotRequired)
+			d.publishLoginEvent(result.User)
 			d.returnNewJWT(w, result, true, req.GetState())
 			return
 		}
+		d.recordLoginFailure(r, limitUsername)
+		d.recordLoginAttempt(r, req.GetUsername(), false, "")
 		// If it's not an actual credential error, it will still be logged server side,
 		// but always tell the user 'Invalid credentials'.
 		apiutil.ReturnAPIForbidden(err, "Invalid credentials", w)
@@ -86,26 +106,74 @@ func (d *desktopAPI) PostLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	d.checkMFAAndReturnJWT(w, result, req.GetState())
+	d.checkMFAAndReturnJWT(w, r, result, req.GetState())
 }
 
-func (d *desktopAPI) checkMFAAndReturnJWT(w http.ResponseWriter, result *v1.AuthResult, state string) {
-	// check if MFA is configured for the user and that they have verified their secret
+func (d *desktopAPI) checkMFAAndReturnJWT(w http.ResponseWriter, r *http.Request, result *v1.AuthResult, state string) {
+	// check if MFA is configured for the user and that they have verified their secret,
+	// push MFA is required for every user
+	if d.mfaProvider != nil {
+		d.recordLoginAttempt(r, result.User.Name, true, v1.LoginMFARequired)
+		d.returnUnauthorizedJWT(w, result, state)
+		return
+	}
+	// add any roles temporarily granted to the user, they may require MFA
+	if err := d.applyRoleGrants(result.User); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	if _, verified, err := d.mfa.GetUserMFAStatus(result.User.Name); err != nil || !verified {
 		// Return any error that isn't a not found error
 		if err != nil && !errors.IsUserNotFoundError(err) {
 			apiutil.ReturnAPIError(err, w)
 			return
 		}
+		// One of the user's roles requires MFA, they must enroll before they
+		// are authorized
+		if result.User.MFARequired() {
+			d.recordLoginAttempt(r, result.User.Name, true, v1.LoginMFARequired)
+			d.returnUnauthorizedJWT(w, result, state)
+			return
+		}
 		// The user does not require MFA
+		d.logins.succeed(result.User.Name)
+		d.recordLoginAttempt(r, result.User.Name, true, v1.LoginMFANotRequired)
+		d.publishLoginEvent(result.User)
 		d.returnNewJWT(w, result, true, state)
 		return
 	}
 
-	// the user requires MFA
+	d.recordLoginAttempt(r, result.User.Name, true, v1.LoginMFARequired)
+	d.returnUnauthorizedJWT(w, result, state)
+}
+
+// returnUnauthorizedJWT returns a token the user must authorize with MFA. Refresh
+// tokens from the provider are not carried through to the authorized token, so the
+// session cannot be renewed.
+func (d *desktopAPI) returnUnauthorizedJWT(w http.ResponseWriter, result *v1.AuthResult, state string) {
+	if result.ProviderRefreshToken != "" {
+		result.RefreshNotSupported = true
+	}
 	d.returnNewJWT(w, result, false, state)
 }
 
+// mfaEnrollmentRequired returns true if the roles of the given user require MFA,
+// but they have not set up and verified an OTP secret yet. Push MFA is required
+// for every user and needs no enrollment.
+func (d *desktopAPI) mfaEnrollmentRequired(user *v1.VDIUser) (bool, error) {
+	if d.mfaProvider != nil || !user.MFARequired() {
+		return false, nil
+	}
+	_, verified, err := d.mfa.GetUserMFAStatus(user.Name)
+	if err != nil {
+		if errors.IsUserNotFoundError(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return !verified, nil
+}
+
 // Login request
 // swagger:parameters loginRequest
 type swaggerLoginRequest struct {
@@ -5,21 +5,47 @@ import (
 	"net/http"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// Logout response
+// swagger:response logoutResponse
+type swaggerLogoutResponse struct {
+	// in:body
+	Body v1.LogoutResponse
+}
+
 // swagger:route POST /api/logout Auth logout
-// Ends the current user session.
+// Ends the current user session. When the authentication provider keeps a session
+// of its own for the user (e.g. OIDC), the URL for ending it is returned in the
+// X-Redirect header and the response body.
 // responses:
-//   200: boolResponse
+//   200: logoutResponse
 //   400: error
 //   403: error
 func (d *desktopAPI) PostLogout(w http.ResponseWriter, r *http.Request) {
 	userSession := apiutil.GetRequestUserSession(r)
-	if err := d.CleanupUserDesktops(userSession.User.GetName()); err != nil {
+	// API keys are not tied to a session, so there is nothing to end
+	if userSession.APIKey != "" {
+		apiutil.WriteOK(w)
+		return
+	}
+	// Ending an impersonated session should not touch the desktops or provider
+	// session of the impersonated user.
+	impersonated := userSession.Impersonator != ""
+	if !impersonated {
+		if err := d.CleanupUserDesktops(userSession.User.GetName()); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+	// Revoke the access token so it can't be used until it expires
+	if err := d.revoked.RevokeToken(userSession); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
@@ -27,7 +53,7 @@ func (d *desktopAPI) PostLogout(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		// Revoke the token and remove the cookie
 		// Lookup will fetch and clear the token from the db.
-		if _, err := d.lookupRefreshToken(refreshToken.Value); err != nil {
+		if _, _, err := d.lookupRefreshToken(refreshToken.Value); err != nil {
 			apiLogger.Error(err, "Error while revoking refresh token, garbage may be left in the db")
 		}
 		// Set the cookie to an empty value
@@ -38,7 +64,18 @@ func (d *desktopAPI) PostLogout(w http.ResponseWriter, r *http.Request) {
 			Secure:   true,
 		})
 	}
-	apiutil.WriteOK(w)
+	res := &v1.LogoutResponse{OK: true}
+	if handler, ok := d.auth.(common.LogoutHandler); ok && !impersonated {
+		// The kVDI session is already over at this point, so failing to build the
+		// provider URL only leaves the user signed in there.
+		if res.LogoutURL, err = handler.GetLogoutURL(userSession.User); err != nil {
+			apiLogger.Error(err, "Error building logout URL for the auth provider")
+		}
+		if res.LogoutURL != "" {
+			w.Header().Set("X-Redirect", res.LogoutURL)
+		}
+	}
+	apiutil.WriteJSON(res, w)
 }
 
 func (d *desktopAPI) CleanupUserDesktops(username string) error {
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation POST /api/logout/backchannel Auth postBackChannelLogout
+// ---
+// summary: Receive a back-channel logout from the authentication provider.
+// description: |
+//   Implements OpenID Connect Back-Channel Logout. The provider posts a signed
+//   logout token when a user's session with it ends, and every access and refresh
+//   token issued to the user up until now is revoked. Tokens that only identify
+//   a session (`sid`) and not a subject (`sub`) are not supported. The request must
+//   be form encoded.
+// consumes:
+// - application/x-www-form-urlencoded
+// parameters:
+// - name: logout_token
+//   in: formData
+//   description: The logout token issued by the provider
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostBackChannelLogout(w http.ResponseWriter, r *http.Request) {
+	// Responses must not be cached by the provider
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	handler, ok := d.auth.(common.LogoutHandler)
+	if !ok {
+		apiutil.ReturnAPINotFound(errors.New("The auth provider does not support back-channel logout"), w)
+		return
+	}
+	token := r.PostFormValue("logout_token")
+	if token == "" {
+		apiutil.ReturnAPIError(errors.New("No logout_token provided in the request"), w)
+		return
+	}
+	username, err := handler.VerifyLogoutToken(token)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	// The user has not logged in since the provider was configured, so there is
+	// nothing to revoke.
+	if username == "" {
+		apiutil.WriteOK(w)
+		return
+	}
+	setAuditUser(r, username)
+	if err := d.revokeUserSessions(username); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiLogger.Info(fmt.Sprintf("Revoked all sessions for %s after a back-channel logout", username))
+	apiutil.WriteOK(w)
+}
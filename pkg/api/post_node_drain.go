@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation POST /api/admin/nodes/{node}/drain Miscellaneous postNodeDrainRequest
+// ---
+// summary: Moves desktop sessions off of a node.
+// description: The users of desktops running on the node are notified, and once the grace period passes their desktops are migrated to another node or terminated. When `cordon` is set, the node is marked unschedulable first. The progress of the drain is returned.
+// parameters:
+// - name: node
+//   in: path
+//   description: The node to drain
+//   type: string
+//   required: true
+// - in: body
+//   name: postNodeDrainRequest
+//   description: How to drain the node.
+//   schema:
+//     "$ref": "#/definitions/DrainNodeRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/nodeDrainResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostNodeDrain(w http.ResponseWriter, r *http.Request) {
+	nodeName := apiutil.GetNodeFromRequest(r)
+	req := apiutil.GetRequestObject(r).(*v1.DrainNodeRequest)
+
+	node := &corev1.Node{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: nodeName, Namespace: metav1.NamespaceAll}, node); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("Node %s not found", nodeName), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// Keep new desktops from being scheduled onto the node
+	if req.Cordon && !node.Spec.Unschedulable {
+		node.Spec.Unschedulable = true
+		if err := d.client.Update(context.TODO(), node); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		apiLogger.Info(fmt.Sprintf("Cordoned node %s for drain", nodeName))
+	}
+
+	deadline := time.Now().Add(req.GetGracePeriod()).UTC().Truncate(time.Second)
+	notice, err := json.Marshal(&v1.DrainNotice{
+		Node:     nodeName,
+		Action:   req.GetAction(),
+		Deadline: deadline,
+		Message:  req.Message,
+	})
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	desktops, err := d.getDesktopsOnNode(nodeName)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// The users are notified through the status of their desktops and the event
+	// stream, and the manager takes care of migrating or terminating them at the
+	// deadline.
+	for _, desktop := range desktops {
+		annotations := desktop.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[v1.DrainAnnotation] = string(notice)
+		desktop.SetAnnotations(annotations)
+		if err := d.client.Update(context.TODO(), desktop); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	d.alertAdmins("Node draining", fmt.Sprintf(
+		"Node %s is being drained. %d desktops will be moved with action %q at %s.",
+		nodeName, len(desktops), req.GetAction(), deadline.Format(time.RFC3339),
+	))
+
+	status, err := d.getNodeDrainStatus(nodeName)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(status, w)
+}
+
+// getDesktopsOnNode returns the desktops for this cluster whose pods are running
+// on the given node.
+func (d *desktopAPI) getDesktopsOnNode(node string) ([]*v1alpha1.Desktop, error) {
+	desktops := &v1alpha1.DesktopList{}
+	if err := d.client.List(context.TODO(), desktops, client.InNamespace(metav1.NamespaceAll), d.vdiCluster.GetClusterDesktopsSelector()); err != nil {
+		return nil, err
+	}
+	onNode := make([]*v1alpha1.Desktop, 0)
+	for i, desktop := range desktops.Items {
+		pod := &corev1.Pod{}
+		if err := d.client.Get(context.TODO(), types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}, pod); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return nil, err
+		}
+		if pod.Spec.NodeName == node {
+			onNode = append(onNode, &desktops.Items[i])
+		}
+	}
+	return onNode, nil
+}
+
+// swagger:parameters postNodeDrainRequest
+type swaggerDrainNodeRequest struct {
+	// in:body
+	Body v1.DrainNodeRequest
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/email"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation POST /api/reset-password Auth postResetPasswordRequest
+// ---
+// summary: Emails a time-limited password reset link to a user.
+// description: Only available with local authentication when email is configured on the cluster. The response is the same whether or not the user exists or has an email address, so it cannot be used to discover users.
+// parameters:
+// - in: body
+//   name: postResetPasswordRequest
+//   description: The user to reset the password for.
+//   schema:
+//     "$ref": "#/definitions/PasswordResetRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "429":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostResetPassword(w http.ResponseWriter, r *http.Request) {
+	if !d.vdiCluster.PasswordResetEnabled() {
+		apiutil.ReturnAPIError(errors.New("Password resets are not enabled on this cluster"), w)
+		return
+	}
+
+	req := apiutil.GetRequestObject(r).(*v1.PasswordResetRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	setAuditUser(r, req.Username)
+
+	// Reset requests count towards the login rate limits, so they can't be used
+	// to flood a user's inbox.
+	if !d.checkLoginAllowed(w, r, req.Username) {
+		return
+	}
+
+	if err := d.sendPasswordReset(req.Username); err != nil {
+		apiLogger.Error(err, "Failed to create password reset", "User", req.Username)
+	}
+	apiutil.WriteOK(w)
+}
+
+// sendPasswordReset emails a password reset link to the given user if they exist
+// and have an email address.
+func (d *desktopAPI) sendPasswordReset(username string) error {
+	if _, err := d.auth.GetUser(username); err != nil {
+		if errors.IsUserNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	addr, err := d.getUserEmail(username)
+	if err != nil {
+		return err
+	}
+	if addr.Address == "" {
+		return nil
+	}
+	token, expiresAt, err := d.createPasswordReset(username)
+	if err != nil {
+		return err
+	}
+	apiLogger.Info(fmt.Sprintf("Sending password reset link to user %s", username))
+	d.sendEmail([]string{addr.Address}, email.MessagePasswordReset, &email.Data{
+		User: username,
+		Link: fmt.Sprintf("%s/#/reset-password?token=%s", d.email.AppURL(), url.QueryEscape(token)),
+		Time: expiresAt,
+	})
+	return nil
+}
+
+// Request containing the user to reset the password for
+// swagger:parameters postResetPasswordRequest
+type swaggerResetPasswordRequest struct {
+	// in:body
+	Body v1.PasswordResetRequest
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation POST /api/reset-password/confirm Auth postResetPasswordConfirmRequest
+// ---
+// summary: Sets a new password for a user with the token from a password reset link.
+// description: The token is signed and expires after `auth.localAuth.passwordResetTTL`. It can only be used once, and is not spent if the new password does not satisfy the cluster's password policy.
+// parameters:
+// - in: body
+//   name: postResetPasswordConfirmRequest
+//   description: The reset token and new password.
+//   schema:
+//     "$ref": "#/definitions/ConfirmPasswordResetRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostResetPasswordConfirm(w http.ResponseWriter, r *http.Request) {
+	if !d.vdiCluster.PasswordResetEnabled() {
+		apiutil.ReturnAPIError(errors.New("Password resets are not enabled on this cluster"), w)
+		return
+	}
+
+	req := apiutil.GetRequestObject(r).(*v1.ConfirmPasswordResetRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	username, id, reset, err := d.claimPasswordReset(req.Token)
+	if err != nil {
+		apiutil.ReturnAPIForbidden(err, "The password reset link is invalid or has expired", w)
+		return
+	}
+	setAuditUser(r, username)
+
+	// The token is given back if the new password does not pass the password policy,
+	// so the user can try again with a different one.
+	if err := d.auth.UpdateUser(username, &v1.UpdateUserRequest{Password: req.Password}); err != nil {
+		if rerr := d.restorePasswordReset(username, id, reset); rerr != nil {
+			apiLogger.Error(rerr, "Failed to restore password reset", "User", username)
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// A successful reset clears any lockouts the user ran into
+	d.logins.succeed(username)
+	apiLogger.Info(fmt.Sprintf("User %s reset their password", username))
+	apiutil.WriteOK(w)
+}
+
+// Request containing a password reset token and new password
+// swagger:parameters postResetPasswordConfirmRequest
+type swaggerConfirmPasswordResetRequest struct {
+	// in:body
+	Body v1.ConfirmPasswordResetRequest
+}
@@ -33,6 +33,10 @@ func (d *desktopAPI) CreateRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	role := d.newRoleFromRequest(req)
+	if err := d.validateRoleInheritance(role); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	if err := d.client.Create(context.TODO(), role); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
@@ -49,6 +53,14 @@ func (d *desktopAPI) newRoleFromRequest(req *v1.CreateRoleRequest) *v1alpha1.VDI
 				v1.RoleClusterRefLabel: d.vdiCluster.GetName(),
 			},
 		},
-		Rules: req.GetRules(),
+		Rules:         req.GetRules(),
+		Inherits:      req.Inherits,
+		TokenDuration: req.GetTokenDuration(),
+		Watermark:     req.Watermark,
+		RequireMFA:    req.RequireMFA,
+
+		MaxSessions:            req.MaxSessions,
+		MaxSessionsPerTemplate: req.MaxSessionsPerTemplate,
+		Placement:              req.Placement,
 	}
 }
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Request containing a new role grant
+// swagger:parameters postRoleGrantRequest
+type swaggerCreateRoleGrantRequest struct {
+	// in:body
+	Body v1.CreateRoleGrantRequest
+}
+
+// swagger:operation POST /api/roles/{role}/grants Roles postRoleGrantRequest
+// ---
+// summary: Temporarily grant a role to a user.
+// description: |
+//   The role is added to the user's session when they next authenticate or renew
+//   their session, and their tokens do not outlive the grant. Any existing grant
+//   for the user is replaced.
+// parameters:
+// - name: role
+//   in: path
+//   description: The name of the role
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/postRoleGrantResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostRoleGrant(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.CreateRoleGrantRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	user := apiutil.GetRequestUserSession(r).User
+
+	role := d.getRoleForRequest(w, r)
+	if role == nil {
+		return
+	}
+
+	if _, err := d.auth.GetUser(req.User); err != nil {
+		if errors.IsUserNotFoundError(err) {
+			apiutil.ReturnAPINotFound(fmt.Errorf("The user %s does not exist", req.User), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	grants, err := role.GetActiveGrants()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	grant := &v1.RoleGrant{
+		User:      req.User,
+		CreatedBy: user.GetName(),
+		ExpiresAt: req.GetExpiresAt(),
+	}
+	newGrants := []*v1.RoleGrant{grant}
+	for _, existing := range grants {
+		if existing.User != req.User {
+			newGrants = append(newGrants, existing)
+		}
+	}
+	if err := role.SetGrants(newGrants); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := d.client.Update(context.TODO(), role); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiLogger.Info(fmt.Sprintf("User %s granted role %s to %s", user.GetName(), role.GetName(), grant.User), "ExpiresAt", grant.ExpiresAt)
+	apiutil.WriteJSON(grant, w)
+}
+
+// Created role grant response
+// swagger:response postRoleGrantResponse
+type swaggerCreateRoleGrantResponse struct {
+	// in:body
+	Body v1.RoleGrant
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Request containing the user or role and the action to evaluate
+// swagger:parameters postRolesEvaluateRequest
+type swaggerEvaluatePermissionRequest struct {
+	// in:body
+	Body v1.EvaluatePermissionRequest
+}
+
+// swagger:route POST /api/roles/evaluate Roles postRolesEvaluateRequest
+// Evaluate whether a user or role would be allowed to perform an action, and which
+// rule allows it. Roles are evaluated with the rules of the roles they inherit from,
+// and users with the roles the auth provider reports for them along with any roles
+// temporarily granted to them.
+// responses:
+//   200: permissionEvaluationResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) PostRolesEvaluate(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.EvaluatePermissionRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	var roles []*v1.VDIUserRole
+	if req.Role != "" {
+		resolved, err := d.vdiCluster.GetResolvedRoles(d.client)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		for _, role := range resolved {
+			if role.GetName() == req.Role {
+				roles = []*v1.VDIUserRole{role.ToUserRole()}
+				break
+			}
+		}
+		if roles == nil {
+			apiutil.ReturnAPINotFound(errors.NewRoleNotFoundError(req.Role), w)
+			return
+		}
+	} else {
+		user, err := d.auth.GetUser(req.User)
+		if err != nil {
+			if errors.IsUserNotFoundError(err) {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		// add any roles temporarily granted to the user
+		if err := d.applyRoleGrants(user); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		roles = user.Roles
+	}
+
+	apiutil.WriteJSON(evaluatePermission(roles, req.GetAction()), w)
+}
+
+// evaluatePermission checks the given roles in order for a rule allowing the action.
+func evaluatePermission(roles []*v1.VDIUserRole, action *v1.APIAction) *v1.PermissionEvaluation {
+	result := &v1.PermissionEvaluation{
+		Action:         action,
+		EvaluatedRoles: make([]string, len(roles)),
+	}
+	for idx, role := range roles {
+		result.EvaluatedRoles[idx] = role.GetName()
+	}
+	for _, role := range roles {
+		if rule := role.MatchingRule(action); rule != nil {
+			result.Allowed = true
+			result.Role = role.GetName()
+			result.Rule = rule
+			return result
+		}
+	}
+	if len(roles) == 0 {
+		result.Reason = fmt.Sprintf("No roles are applied, so nothing allows %s", action.String())
+	} else {
+		result.Reason = fmt.Sprintf("No rule in roles %s allows %s", strings.Join(result.EvaluatedRoles, ", "), action.String())
+	}
+	return result
+}
+
+// The result of evaluating an action
+// swagger:response permissionEvaluationResponse
+type swaggerPermissionEvaluationResponse struct {
+	// in:body
+	Body v1.PermissionEvaluation
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/handoff Sessions postSessionHandoffRequest
+// ---
+// summary: Create a one-time code for picking up the display of a desktop session on another device.
+// description: |
+//   The owner of the desktop redeems the code by passing it in the `handoff` query
+//   parameter when connecting to `/api/desktops/ws/{namespace}/{name}/display` from
+//   the other device. The connection takes over the display, and the connection that
+//   held it before is closed. Codes expire after two minutes, and creating a new code
+//   replaces any that was not redeemed yet. The code is only returned once.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/postSessionHandoffResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostSessionHandoff(w http.ResponseWriter, r *http.Request) {
+	user := apiutil.GetRequestUserSession(r).User
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", nn.String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	if desktop.Spec.User != user.GetName() {
+		apiutil.ReturnAPIForbidden(nil, "Only the owner of a desktop session can hand it off", w)
+		return
+	}
+
+	code, err := newHandoffCode()
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	handoff := &v1.SessionHandoff{
+		CodeHash:  hashHandoffCode(code),
+		User:      user.GetName(),
+		ExpiresAt: time.Now().Add(sessionHandoffTTL).Unix(),
+	}
+	if err := d.setSessionHandoff(desktop, handoff); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiLogger.Info(fmt.Sprintf("User %s created a handoff code for desktop %s", user.GetName(), nn.String()))
+	apiutil.WriteJSON(&v1.SessionHandoffResponse{Code: code, ExpiresAt: handoff.ExpiresAt}, w)
+}
+
+// Created session handoff response
+// swagger:response postSessionHandoffResponse
+type swaggerSessionHandoffResponse struct {
+	// in:body
+	Body v1.SessionHandoffResponse
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/google/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Request containing a new session invite
+// swagger:parameters postSessionInviteRequest
+type swaggerCreateSessionInviteRequest struct {
+	// in:body
+	Body v1.CreateSessionInviteRequest
+}
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/invites Sessions postSessionInviteRequest
+// ---
+// summary: Invite another user to attach to the display of a desktop session.
+// description: |
+//   The invited user attaches with the returned invite ID. Invites for `control`
+//   require the requesting user to be allowed to use the desktop themselves.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/postSessionInviteResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostSessionInvite(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.CreateSessionInviteRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	user := apiutil.GetRequestUserSession(r).User
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", nn.String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	if req.User == desktop.Spec.User {
+		apiutil.ReturnAPIError(errors.New("The owner of a desktop session cannot be invited to it"), w)
+		return
+	}
+
+	// users that are only allowed to watch the display can't let others control it
+	if req.GetMode() == v1.SessionInviteControl && desktop.Spec.User != user.GetName() {
+		if !user.Evaluate(&v1.APIAction{
+			Verb:              v1.VerbUse,
+			ResourceType:      v1.ResourceTemplates,
+			ResourceName:      nn.Name,
+			ResourceNamespace: nn.Namespace,
+		}) {
+			apiutil.ReturnAPIForbidden(nil, "User is not allowed to send input to this desktop", w)
+			return
+		}
+	}
+
+	if _, err := d.auth.GetUser(req.User); err != nil {
+		if errors.IsUserNotFoundError(err) {
+			apiutil.ReturnAPINotFound(fmt.Errorf("The user %s does not exist", req.User), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	invites, err := getSessionInvites(desktop)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	invite := &v1.SessionInvite{
+		ID:        uuid.New().String(),
+		User:      req.User,
+		Mode:      req.GetMode(),
+		CreatedBy: user.GetName(),
+		ExpiresAt: time.Now().Add(req.GetDuration()).Unix(),
+	}
+	if err := d.setSessionInvites(desktop, append(invites, invite)); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiLogger.Info(fmt.Sprintf("User %s invited %s to desktop %s", user.GetName(), invite.User, nn.String()), "Mode", invite.Mode, "ID", invite.ID)
+	apiutil.WriteJSON(invite, w)
+}
+
+// Created session invite response
+// swagger:response postSessionInviteResponse
+type swaggerCreateSessionInviteResponse struct {
+	// in:body
+	Body v1.SessionInvite
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/recordings Sessions postSessionRecordingsRequest
+// ---
+// summary: Upload the finished recordings of a desktop session to the recordings bucket.
+// description: |
+//   Recordings are normally uploaded when a display connection ends. This can be used
+//   to export them without waiting for the next connection to end. The upload is done
+//   in a job, which is returned, and completes with the IDs of the uploaded recordings.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/jobResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostSessionRecordings(w http.ResponseWriter, r *http.Request) {
+	cfg := d.vdiCluster.GetRecordingsConfig()
+	if cfg == nil {
+		apiutil.ReturnAPIError(errRecordingsNotConfigured, w)
+		return
+	}
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	if _, err := d.getDesktopForRequest(r); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", nn.String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	d.runAsJob(w, r, v1.JobTypeRecordingExport, func(progress jobProgressFunc) (interface{}, error) {
+		return d.doUploadRecordings(cfg, nn, progress)
+	})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/screenshot Sessions postSessionScreenshot
+// ---
+// summary: Capture the current contents of a desktop session's display.
+// description: |
+//   Returns a PNG of the current framebuffer. The request is always written to the
+//   audit log, regardless of whether audit logging is enabled for the cluster.
+// produces:
+// - image/png
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     description: A PNG image of the desktop display
+//     schema:
+//       type: file
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostSessionScreenshot(w http.ResponseWriter, r *http.Request) {
+	userSession := apiutil.GetRequestUserSession(r)
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+	apiLogger.Info(
+		"Capturing screenshot of desktop session",
+		"User.Name", userSession.User.GetName(),
+		"Desktop.Namespace", nn.Namespace,
+		"Desktop.Name", nn.Name,
+	)
+	d.serveHTTPProxy(w, r)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/snapshots Sessions postSessionSnapshotRequest
+// ---
+// summary: Take a snapshot of the userdata volume of a desktop session.
+// description: |
+//   The snapshot is owned by the user of the desktop, and can be used to restore their
+//   home directory in new sessions in the same namespace. It may take some time for the
+//   storage provider to make the snapshot ready to use. Snapshots are only available
+//   when the cluster has a `snapshotClass` and `userdataSpec` configured.
+//
+//   When `async=true` is set in the query, a job is returned instead, which completes
+//   with the snapshot once it is ready to use.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// - name: async
+//   in: query
+//   description: Return a job that completes once the snapshot is ready to use.
+//   type: boolean
+//   required: false
+// responses:
+//   "200":
+//     "$ref": "#/responses/snapshotResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostSessionSnapshot(w http.ResponseWriter, r *http.Request) {
+	class := d.vdiCluster.GetSessionSnapshotClass()
+	if class == "" {
+		apiutil.ReturnAPIError(errSnapshotsNotConfigured, w)
+		return
+	}
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", nn.String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{
+		Name:      d.vdiCluster.GetUserdataVolumeName(desktop.GetUser()),
+		Namespace: desktop.GetNamespace(),
+	}, pvc); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPIError(fmt.Errorf("Desktop session %s does not have a userdata volume", nn.String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	snapshot := k8sutil.NewVolumeSnapshot(pvc.GetName(), pvc.GetNamespace(), class, d.vdiCluster.GetDesktopLabels(desktop))
+	snapshot.SetAnnotations(map[string]string{v1.SnapshotTemplateAnnotation: desktop.Spec.Template})
+	if err := d.client.Create(context.TODO(), snapshot); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiLogger.Info(fmt.Sprintf("Created snapshot %s of the userdata volume for desktop %s", snapshot.GetName(), nn.String()), "User", desktop.GetUser())
+	if isAsyncRequest(r) {
+		snapshotNN := types.NamespacedName{Name: snapshot.GetName(), Namespace: snapshot.GetNamespace()}
+		d.runAsJob(w, r, v1.JobTypeSessionSnapshot, func(progress jobProgressFunc) (interface{}, error) {
+			progress(0, fmt.Sprintf("Waiting for snapshot %s to be ready to use", snapshotNN.String()))
+			ready, err := d.waitForSnapshotReady(snapshotNN)
+			if ready == nil {
+				return nil, err
+			}
+			return newDesktopSnapshot(ready), err
+		})
+		return
+	}
+	apiutil.WriteJSON(newDesktopSnapshot(snapshot), w)
+}
+
+// A snapshot of a user's userdata volume
+// swagger:response snapshotResponse
+type swaggerSnapshotResponse struct {
+	// in:body
+	Body v1.DesktopSnapshot
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/google/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Request containing a new view token
+// swagger:parameters postSessionViewTokenRequest
+type swaggerCreateViewTokenRequest struct {
+	// in:body
+	Body v1.CreateViewTokenRequest
+}
+
+// swagger:operation POST /api/sessions/{namespace}/{name}/viewtokens Sessions postSessionViewTokenRequest
+// ---
+// summary: Create a read-only view token for the display of a desktop session.
+// description: |
+//   The returned token can only be used to watch the display of this desktop session
+//   at `/api/desktops/ws/{namespace}/{name}/viewtoken`. It cannot send input and is
+//   rejected by every other route. The token is only returned once.
+// parameters:
+// - name: namespace
+//   in: path
+//   description: The namespace of the desktop session
+//   type: string
+//   required: true
+// - name: name
+//   in: path
+//   description: The name of the desktop session
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/postSessionViewTokenResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostSessionViewToken(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.CreateViewTokenRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	user := apiutil.GetRequestUserSession(r).User
+	nn := apiutil.GetNamespacedNameFromRequest(r)
+
+	desktop, err := d.getDesktopForRequest(r)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No desktop session %s found", nn.String()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	tokens, err := getViewTokens(desktop)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	viewToken := &v1.ViewToken{
+		ID:        uuid.New().String(),
+		Label:     req.Label,
+		CreatedBy: user.GetName(),
+		ExpiresAt: time.Now().Add(req.GetDuration()).Unix(),
+	}
+
+	keys, err := d.getJWTKeys(true)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	token, err := apiutil.GenerateViewTokenJWT(keys, viewToken)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	if err := d.setViewTokens(desktop, append(tokens, viewToken)); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiLogger.Info(fmt.Sprintf("User %s created a view token for desktop %s", user.GetName(), nn.String()), "ID", viewToken.ID, "Label", viewToken.Label)
+	apiutil.WriteJSON(&v1.CreateViewTokenResponse{ViewToken: viewToken, Token: token}, w)
+}
+
+// Created view token response
+// swagger:response postSessionViewTokenResponse
+type swaggerCreateViewTokenResponse struct {
+	// in:body
+	Body v1.CreateViewTokenResponse
+}
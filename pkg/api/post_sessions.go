@@ -6,14 +6,19 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/lock"
+	"github.com/tinyzimmer/kvdi/pkg/util/tracing"
 
 	"github.com/google/uuid"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // Request for a new desktop session
@@ -23,18 +28,11 @@ type swaggerCreateSessionRequest struct {
 	Body v1.CreateSessionRequest
 }
 
-// CreateSessionResponse returns the name of the Desktop and what namespace
-// it is running in.
-type CreateSessionResponse struct {
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
-}
-
 // New session response
 // swagger:response postSessionResponse
 type swaggerCreateSessionResponse struct {
 	// in:body
-	Body CreateSessionResponse
+	Body v1.CreateSessionResponse
 }
 
 // swagger:route POST /api/sessions Sessions postSessionRequest
@@ -43,6 +41,8 @@ type swaggerCreateSessionResponse struct {
 //   200: postSessionResponse
 //   400: error
 //   403: error
+//   404: error
+//   429: error
 func (d *desktopAPI) StartDesktopSession(w http.ResponseWriter, r *http.Request) {
 	sess := apiutil.GetRequestUserSession(r)
 	req := apiutil.GetRequestObject(r).(*v1.CreateSessionRequest)
@@ -51,30 +51,268 @@ func (d *desktopAPI) StartDesktopSession(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	desktop := d.newDesktopForRequest(req, sess.User.GetName())
+	tmpl := &v1alpha1.DesktopTemplate{}
+	if err := d.client.Get(context.TODO(), types.NamespacedName{Name: req.GetTemplate(), Namespace: metav1.NamespaceAll}, tmpl); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	// Launch pinned sessions from the spec of the requested revision
+	if req.TemplateRevision != 0 {
+		spec, err := tmpl.GetRevisionSpec(d.client, d.vdiCluster.GetCoreNamespace(), req.TemplateRevision)
+		if err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				apiutil.ReturnAPINotFound(fmt.Errorf("No revision %d found for template %s", req.TemplateRevision, tmpl.GetName()), w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		tmpl.Spec = *spec
+	}
+	tmpl, err := tmpl.GetEffectiveTemplate(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	if !tmpl.AllowsNamespace(req.GetNamespace()) {
+		apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("Template %s cannot be launched in namespace %s", tmpl.GetName(), req.GetNamespace()), w)
+		return
+	}
+
+	// Sessions must also be placed where the policies of the user's roles allow
+	if !sess.User.AllowsSessionNamespace(req.GetNamespace()) {
+		apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("Your roles do not allow launching sessions in namespace %s", req.GetNamespace()), w)
+		return
+	}
+	nodeSelector, err := getSessionNodeSelector(sess.User, tmpl)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	available, _, err := tmpl.GetAvailability(time.Now())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if !available {
+		apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("Template %s is not available at this time", tmpl.GetName()), w)
+		return
+	}
+
+	params, err := tmpl.ResolveParameters(req.Params)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// Make sure the user may set the requested environment variables
+	if req.HasEnv() {
+		if !sess.User.Evaluate(&v1.APIAction{
+			Verb:              v1.VerbSetEnv,
+			ResourceType:      v1.ResourceTemplates,
+			ResourceName:      tmpl.GetName(),
+			ResourceNamespace: req.GetNamespace(),
+		}) {
+			apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("Your roles do not allow setting environment variables for template %s", tmpl.GetName()), w)
+			return
+		}
+		if err := d.checkSessionEnv(sess.User.GetName(), tmpl, req); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	// Make sure the user can restore their home directory from the requested snapshot
+	if req.Snapshot != "" {
+		if d.vdiCluster.GetSessionSnapshotClass() == "" {
+			apiutil.ReturnAPIError(errSnapshotsNotConfigured, w)
+			return
+		}
+		snapshot, err := d.getUserSnapshot(sess.User.GetName(), req.GetNamespace(), req.Snapshot)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		if snapshot == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No snapshot %s found in namespace %s", req.Snapshot, req.GetNamespace()), w)
+			return
+		}
+		if err := d.checkSnapshotRestore(sess.User.GetName(), snapshot); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	desktop := d.newDesktopForRequest(req, sess.User, params)
+	desktop.Spec.NodeSelector = nodeSelector
+
+	// Flag the desktop for cloning the user's dotfiles if they have a repository configured
+	dotfiles, err := d.getUserDotfiles(sess.User.GetName())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if dotfiles.Repository != "" {
+		desktop.Annotations[v1.DotfilesAnnotation] = "true"
+	}
+
+	// Link the desktop's startup to the trace for this request
+	if sc := tracing.SpanContextFromContext(r.Context()); sc.IsValid() {
+		desktop.Annotations[v1.TraceParentAnnotation] = sc.TraceParent()
+	}
+
+	// If the user has session quotas, hold a lock while checking them so concurrent
+	// requests can't exceed them.
+	if hasSessionQuotas(sess.User) {
+		quotaLock := d.newSessionQuotaLock(sess.User.GetName())
+		if err := quotaLock.Acquire(); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		defer func() {
+			if err := quotaLock.Release(); err != nil {
+				apiLogger.Error(err, "Failed to release session quota lock")
+			}
+		}()
+		if err := d.checkSessionQuotas(sess.User, tmpl.GetName()); err != nil {
+			returnSessionQuotaError(err, w)
+			return
+		}
+		if err := d.checkSessionResources(sess.User, tmpl, params, req.GetNamespace()); err != nil {
+			returnSessionQuotaError(err, w)
+			return
+		}
+	}
+
+	// Hand the user an already running desktop if there is a session pool for
+	// the template. Pooled desktops are booted with the default parameters, the
+	// user's current volume, and the current revision of the template. Pooled
+	// desktops are not booted onto the nodes required by the user's roles, nor with
+	// any environment set from the user or their secrets.
+	var claimed *v1alpha1.Desktop
+	if tmpl.IsDefaultParameters(params) && req.Snapshot == "" && req.TemplateRevision == 0 && len(nodeSelector) == 0 && !req.HasEnv() && !tmpl.HasUserSecretEnv() {
+		claimed, err = d.claimPooledDesktop(req, sess.User, dotfiles)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+	if claimed != nil {
+		apiutil.WriteJSON(&v1.CreateSessionResponse{
+			Name:      claimed.GetName(),
+			Namespace: claimed.GetNamespace(),
+		}, w)
+		return
+	}
+
+	// If the template has a session limit, hold a lock while checking capacity
+	// so concurrent requests can't exceed it.
+	if max := tmpl.GetMaxSessions(); max > 0 {
+		capacityLock := lock.New(d.client, fmt.Sprintf("template-capacity-%s", tmpl.GetName()), time.Second*10)
+		if err := capacityLock.Acquire(); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		defer func() {
+			if err := capacityLock.Release(); err != nil {
+				apiLogger.Error(err, "Failed to release template capacity lock")
+			}
+		}()
+		if err := d.checkTemplateCapacity(sess.User.GetName(), tmpl); err != nil {
+			returnSessionQuotaError(err, w)
+			return
+		}
+	}
 
 	if err := d.client.Create(context.TODO(), desktop); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
 
-	apiutil.WriteJSON(&CreateSessionResponse{
+	apiutil.WriteJSON(&v1.CreateSessionResponse{
 		Name:      desktop.GetName(),
 		Namespace: desktop.GetNamespace(),
 	}, w)
 }
 
-func (d *desktopAPI) newDesktopForRequest(req *v1.CreateSessionRequest, username string) *v1alpha1.Desktop {
+func (d *desktopAPI) newDesktopForRequest(req *v1.CreateSessionRequest, user *v1.VDIUser, params map[string]string) *v1alpha1.Desktop {
+	if len(params) == 0 {
+		params = nil
+	}
+	var env, secretEnv map[string]string
+	if len(req.Env) > 0 {
+		env = req.Env
+	}
+	if len(req.SecretEnv) > 0 {
+		secretEnv = req.SecretEnv
+	}
 	return &v1alpha1.Desktop{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("%s-%s", req.GetTemplate(), strings.Split(uuid.New().String(), "-")[0]),
 			Namespace: req.GetNamespace(),
-			Labels:    d.vdiCluster.GetUserDesktopLabels(username),
+			Labels:    d.vdiCluster.GetUserDesktopLabels(user.GetName()),
+			Annotations: map[string]string{
+				v1.UserRolesAnnotation: strings.Join(user.GetRoleNames(), v1.AuthGroupSeparator),
+			},
 		},
 		Spec: v1alpha1.DesktopSpec{
-			VDICluster: d.vdiCluster.GetName(),
-			Template:   req.GetTemplate(),
-			User:       username,
+			VDICluster:       d.vdiCluster.GetName(),
+			Template:         req.GetTemplate(),
+			User:             user.GetName(),
+			Parameters:       params,
+			Snapshot:         req.Snapshot,
+			TemplateRevision: req.TemplateRevision,
+			Env:              env,
+			SecretEnv:        secretEnv,
 		},
 	}
 }
+
+// checkSessionEnv makes sure the template allows every environment variable in
+// the request, and that the user has each of the secrets it references.
+func (d *desktopAPI) checkSessionEnv(username string, tmpl *v1alpha1.DesktopTemplate, req *v1.CreateSessionRequest) error {
+	for name := range req.Env {
+		if !tmpl.AllowsSessionEnv(name) {
+			return fmt.Errorf("Template %s does not allow setting %s", tmpl.GetName(), name)
+		}
+	}
+	if len(req.SecretEnv) == 0 {
+		return nil
+	}
+	_, secrets, err := d.readUserSecrets(username)
+	if err != nil {
+		return err
+	}
+	for name, secret := range req.SecretEnv {
+		if !tmpl.AllowsSessionEnv(name) {
+			return fmt.Errorf("Template %s does not allow setting %s", tmpl.GetName(), name)
+		}
+		if _, ok := secrets[secret]; !ok {
+			return fmt.Errorf("No secret %s found for user %s", secret, username)
+		}
+	}
+	return nil
+}
+
+// countTemplateSessions returns the number of active desktops in this cluster booted
+// from the given template. Desktops that are being deleted, or whose pods have failed
+// or exited, do not count towards the capacity of the template.
+func (d *desktopAPI) countTemplateSessions(template string) (int, error) {
+	desktops := &v1alpha1.DesktopList{}
+	if err := d.client.List(context.TODO(), desktops); err != nil {
+		return 0, err
+	}
+	var count int
+	for _, desktop := range desktops.Items {
+		if desktop.Spec.VDICluster == d.vdiCluster.GetName() && desktop.Spec.Template == template && desktop.IsActive() {
+			count++
+		}
+	}
+	return count, nil
+}
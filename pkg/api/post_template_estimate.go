@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// bytesPerGiB is used for converting memory quantities to GiB.
+const bytesPerGiB = 1024 * 1024 * 1024
+
+// Request containing the options a session would be launched with
+// swagger:parameters postTemplateEstimateRequest
+type swaggerEstimateSessionRequest struct {
+	// in:body
+	Body v1.EstimateSessionRequest
+}
+
+// swagger:operation POST /api/templates/{template}/estimate Templates postTemplateEstimateRequest
+// ---
+// summary: Estimate the resources and hourly cost of launching a session from a DesktopTemplate.
+// description: |
+//   The template is resolved the same way it would be when launching a session with the
+//   given options, including parameters and any namespace resource defaults. Costs are
+//   only included when prices are configured in the billing configuration of the
+//   VDICluster.
+// parameters:
+// - name: template
+//   in: path
+//   description: The DesktopTemplate to estimate a session for
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/sessionEstimateResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostDesktopTemplateEstimate(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.EstimateSessionRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	tmpl := &v1alpha1.DesktopTemplate{}
+	nn := types.NamespacedName{Name: apiutil.GetTemplateFromRequest(r), Namespace: metav1.NamespaceAll}
+	if err := d.client.Get(context.TODO(), nn, tmpl); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if req.TemplateRevision != 0 {
+		spec, err := tmpl.GetRevisionSpec(d.client, d.vdiCluster.GetCoreNamespace(), req.TemplateRevision)
+		if err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				apiutil.ReturnAPINotFound(fmt.Errorf("No revision %d found for template %s", req.TemplateRevision, tmpl.GetName()), w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		tmpl.Spec = *spec
+	}
+	tmpl, err := tmpl.GetEffectiveTemplate(d.client)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	if !tmpl.AllowsNamespace(req.GetNamespace()) {
+		apiutil.ReturnAPIForbidden(nil, fmt.Sprintf("Template %s cannot be launched in namespace %s", tmpl.GetName(), req.GetNamespace()), w)
+		return
+	}
+
+	params, err := tmpl.ResolveParameters(req.Params)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	tmpl, err = tmpl.ApplyParameters(params)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	estimate, err := estimateSession(d.vdiCluster, tmpl, req.GetNamespace())
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteJSON(estimate, w)
+}
+
+// estimateSession computes the resource footprint of a desktop booted from the given
+// template in the given namespace, and its hourly cost if the cluster has prices
+// configured. Resources are counted by their request, falling back to their limit,
+// the same as the scheduler does.
+func estimateSession(cluster *v1alpha1.VDICluster, tmpl *v1alpha1.DesktopTemplate, namespace string) (*v1.SessionEstimate, error) {
+	resources := cluster.GetDesktopResources(tmpl, namespace)
+	estimate := &v1.SessionEstimate{
+		Template:  tmpl.GetName(),
+		Namespace: namespace,
+		Requests:  resourceListToStrings(resources.Requests),
+		Limits:    resourceListToStrings(resources.Limits),
+	}
+
+	quantities := make(map[corev1.ResourceName]float64)
+	for name, q := range resources.Limits {
+		quantities[name] = resourceQuantity(name, q)
+	}
+	for name, q := range resources.Requests {
+		quantities[name] = resourceQuantity(name, q)
+	}
+	estimate.CPU = quantities[corev1.ResourceCPU]
+	estimate.MemoryGiB = quantities[corev1.ResourceMemory]
+	if tmpl.HasGPU() {
+		estimate.GPUs = quantities[tmpl.GetGPUResourceName()]
+	}
+
+	prices, err := cluster.GetResourcePrices()
+	if err != nil {
+		return nil, err
+	}
+	if prices == nil {
+		return estimate, nil
+	}
+	estimate.Currency = cluster.GetPriceCurrency()
+	estimate.Breakdown = make([]*v1.SessionCostItem, 0)
+	for name, quantity := range quantities {
+		price, ok := prices[name]
+		if !ok {
+			continue
+		}
+		item := &v1.SessionCostItem{
+			Resource:     string(name),
+			Quantity:     quantity,
+			PricePerHour: price,
+			CostPerHour:  price * quantity,
+		}
+		estimate.Breakdown = append(estimate.Breakdown, item)
+		estimate.CostPerHour += item.CostPerHour
+	}
+	sort.Slice(estimate.Breakdown, func(i, j int) bool {
+		return estimate.Breakdown[i].Resource < estimate.Breakdown[j].Resource
+	})
+	return estimate, nil
+}
+
+// resourceQuantity returns the given quantity in cores for CPU, GiB for memory and
+// ephemeral storage, and units for anything else.
+func resourceQuantity(name corev1.ResourceName, q resource.Quantity) float64 {
+	switch name {
+	case corev1.ResourceMemory, corev1.ResourceEphemeralStorage:
+		return float64(q.Value()) / bytesPerGiB
+	default:
+		return float64(q.MilliValue()) / 1000
+	}
+}
+
+// resourceListToStrings converts a resource list to a map of resource names to
+// their quantities.
+func resourceListToStrings(list corev1.ResourceList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(list))
+	for name, q := range list {
+		out[string(name)] = q.String()
+	}
+	return out
+}
+
+// The estimated resources and cost of a session
+// swagger:response sessionEstimateResponse
+type swaggerSessionEstimateResponse struct {
+	// in:body
+	Body v1.SessionEstimate
+}
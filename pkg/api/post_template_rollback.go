@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Request containing the revision to roll back to
+// swagger:parameters postTemplateRollbackRequest
+type swaggerRollbackTemplateRequest struct {
+	// in:body
+	Body v1.RollbackTemplateRequest
+}
+
+// swagger:operation POST /api/templates/{template}/rollback Templates postTemplateRollbackRequest
+// ---
+// summary: Roll back a DesktopTemplate to a previous revision.
+// description: |
+//   The spec of the given revision is applied to the template, which records it as a
+//   new revision. Running desktops are handled according to the update policy of the
+//   template.
+// parameters:
+// - name: template
+//   in: path
+//   description: The DesktopTemplate to roll back
+//   type: string
+//   required: true
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostDesktopTemplateRollback(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.RollbackTemplateRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	nn := types.NamespacedName{Name: apiutil.GetTemplateFromRequest(r), Namespace: metav1.NamespaceAll}
+	tmpl := &v1alpha1.DesktopTemplate{}
+	if err := d.client.Get(context.TODO(), nn, tmpl); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if req.Revision == tmpl.GetRevision() {
+		apiutil.ReturnAPIError(fmt.Errorf("Revision %d is already the current revision of %s", req.Revision, tmpl.GetName()), w)
+		return
+	}
+	spec, err := tmpl.GetRevisionSpec(d.client, d.vdiCluster.GetCoreNamespace(), req.Revision)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("No revision %d found for template %s", req.Revision, tmpl.GetName()), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	tmpl.Spec = *spec
+	if err := d.client.Update(context.TODO(), tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	d.recordTemplateRevision(tmpl)
+
+	apiLogger.Info(fmt.Sprintf("Rolled back template %s to revision %d", tmpl.GetName(), req.Revision), "Revision", tmpl.GetRevision())
+	apiutil.WriteOK(w)
+}
@@ -21,10 +21,15 @@ func (d *desktopAPI) PostDesktopTemplates(w http.ResponseWriter, r *http.Request
 		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
 		return
 	}
+	if err := d.checkTemplateSecurityProfiles(tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	if err := d.client.Create(context.TODO(), tmpl); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	d.recordTemplateRevision(tmpl)
 	apiutil.WriteOK(w)
 }
 
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/version"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:route POST /api/templates/export Templates exportTemplatesRequest
+// Export DesktopTemplates, and optionally the roles granting access to them, as a signed bundle.
+// responses:
+//   200: templateBundleResponse
+//   400: error
+//   403: error
+//   404: error
+func (d *desktopAPI) PostExportTemplates(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1.ExportTemplatesRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	bundle := &v1alpha1.DesktopTemplateBundle{
+		Version:   version.Version,
+		Source:    d.vdiCluster.GetName(),
+		Created:   time.Now().UTC(),
+		Templates: make([]v1alpha1.DesktopTemplate, 0),
+	}
+
+	for _, name := range req.GetTemplates() {
+		nn := types.NamespacedName{Name: name, Namespace: metav1.NamespaceAll}
+		tmpl := &v1alpha1.DesktopTemplate{}
+		if err := d.client.Get(context.TODO(), nn, tmpl); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		if req.Flatten {
+			effective, err := tmpl.GetEffectiveTemplate(d.client)
+			if err != nil {
+				apiutil.ReturnAPIError(err, w)
+				return
+			}
+			tmpl = effective
+			tmpl.Spec.BaseTemplate = ""
+		}
+		bundle.Templates = append(bundle.Templates, v1alpha1.DesktopTemplate{
+			ObjectMeta: exportObjectMeta(tmpl.ObjectMeta),
+			Spec:       tmpl.Spec,
+		})
+	}
+
+	if req.IncludeRoles {
+		roles, err := d.vdiCluster.GetRoles(d.client)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		for _, role := range roles {
+			if !d.roleGrantsTemplates(&role, req.GetTemplates()) {
+				continue
+			}
+			bundle.Roles = append(bundle.Roles, v1alpha1.VDIRole{
+				ObjectMeta:    exportObjectMeta(role.ObjectMeta),
+				Rules:         role.GetRules(),
+				TokenDuration: role.TokenDuration,
+				Watermark:     role.Watermark,
+				RequireMFA:    role.RequireMFA,
+
+				MaxSessions:            role.MaxSessions,
+				MaxSessionsPerTemplate: role.MaxSessionsPerTemplate,
+				Placement:              role.Placement,
+			})
+		}
+	}
+
+	key, err := d.secrets.ReadSecret(v1.TemplateBundleSecretKey, true)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := bundle.Sign(key); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteJSON(bundle, w)
+}
+
+// roleGrantsTemplates returns true if the given role has a rule explicitly granting
+// access to any of the given templates. The built-in roles are never included.
+func (d *desktopAPI) roleGrantsTemplates(role *v1alpha1.VDIRole, templates []string) bool {
+	if role.GetName() == d.vdiCluster.GetAdminRole().GetName() ||
+		role.GetName() == d.vdiCluster.GetLaunchTemplatesRole().GetName() {
+		return false
+	}
+	for _, rule := range role.GetRules() {
+		if !hasExplicitResource(rule, v1.ResourceTemplates) {
+			continue
+		}
+		for _, tmpl := range templates {
+			if rule.MatchesResourceName(tmpl) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasExplicitResource returns true if the rule names the given resource type directly,
+// as opposed to matching it with a wildcard.
+func hasExplicitResource(rule v1.Rule, resource v1.Resource) bool {
+	for _, item := range rule.Resources {
+		if item == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// exportExcludedAnnotations are annotations that only make sense in the cluster
+// they were set in, and are left out of exported objects.
+var exportExcludedAnnotations = []string{
+	corev1.LastAppliedConfigAnnotation,
+	v1.RoleGrantsAnnotation,
+}
+
+// exportObjectMeta returns a copy of the given metadata with all cluster-specific
+// fields removed.
+func exportObjectMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	out := metav1.ObjectMeta{Name: meta.GetName()}
+AnnotationLoop:
+	for k, v := range meta.GetAnnotations() {
+		for _, excluded := range exportExcludedAnnotations {
+			if k == excluded {
+				continue AnnotationLoop
+			}
+		}
+		if out.Annotations == nil {
+			out.Annotations = make(map[string]string)
+		}
+		out.Annotations[k] = v
+	}
+	for k, v := range meta.GetLabels() {
+		if k == v1.RoleClusterRefLabel {
+			continue
+		}
+		if out.Labels == nil {
+			out.Labels = make(map[string]string)
+		}
+		out.Labels[k] = v
+	}
+	return out
+}
+
+// Request containing templates to export
+// swagger:parameters exportTemplatesRequest
+type swaggerExportTemplatesRequest struct {
+	// in:body
+	Body v1.ExportTemplatesRequest
+}
+
+// A signed template bundle
+// swagger:response templateBundleResponse
+type swaggerTemplateBundleResponse struct {
+	// in:body
+	Body v1alpha1.DesktopTemplateBundle
+}
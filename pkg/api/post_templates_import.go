@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:route POST /api/templates/import Templates importTemplatesRequest
+// Import a signed bundle of DesktopTemplates and VDIRoles exported from another cluster.
+// The bundle is verified before the request returns. When `async=true` is set in the
+// query, the objects are then created in a job, and the job is returned instead.
+// responses:
+//   200: boolResponse
+//   400: error
+//   403: error
+func (d *desktopAPI) PostImportTemplates(w http.ResponseWriter, r *http.Request) {
+	req := apiutil.GetRequestObject(r).(*v1alpha1.ImportTemplateBundleRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	key, err := d.secrets.ReadSecret(v1.TemplateBundleSecretKey, true)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if err := req.Bundle.Verify(key); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	templates := req.GetTemplates()
+	roles := d.getImportedRoles(req)
+
+	if err := d.checkImportedBaseTemplates(templates); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	for _, tmpl := range templates {
+		if err := d.checkTemplateSecurityProfiles(tmpl); err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	// Check for conflicts before creating anything so a failed import does not
+	// leave the cluster half-populated.
+	if !req.Overwrite {
+		for _, tmpl := range templates {
+			if exists, err := d.objectExists(tmpl.GetName(), &v1alpha1.DesktopTemplate{}); err != nil {
+				apiutil.ReturnAPIError(err, w)
+				return
+			} else if exists {
+				apiutil.ReturnAPIError(fmt.Errorf("DesktopTemplate %s already exists", tmpl.GetName()), w)
+				return
+			}
+		}
+		for _, role := range roles {
+			if exists, err := d.objectExists(role.GetName(), &v1alpha1.VDIRole{}); err != nil {
+				apiutil.ReturnAPIError(err, w)
+				return
+			} else if exists {
+				apiutil.ReturnAPIError(fmt.Errorf("VDIRole %s already exists", role.GetName()), w)
+				return
+			}
+		}
+	}
+
+	if isAsyncRequest(r) {
+		d.runAsJob(w, r, v1.JobTypeTemplateImport, func(progress jobProgressFunc) (interface{}, error) {
+			if err := d.createImportedObjects(templates, roles, progress); err != nil {
+				return nil, err
+			}
+			return map[string]bool{"ok": true}, nil
+		})
+		return
+	}
+
+	if err := d.createImportedObjects(templates, roles, nil); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteOK(w)
+}
+
+// createImportedObjects creates or replaces the given templates and roles. When
+// progress is not nil, it is called after each object is created.
+func (d *desktopAPI) createImportedObjects(templates []*v1alpha1.DesktopTemplate, roles []*v1alpha1.VDIRole, progress jobProgressFunc) error {
+	total := len(templates) + len(roles)
+	created := 0
+	report := func(kind, name string) {
+		created++
+		if progress != nil {
+			progress(created*100/total, fmt.Sprintf("Imported %s %s", kind, name))
+		}
+	}
+	for _, tmpl := range templates {
+		found := &v1alpha1.DesktopTemplate{}
+		if err := d.createOrReplace(tmpl, found); err != nil {
+			return err
+		}
+		d.recordTemplateRevision(tmpl)
+		report("DesktopTemplate", tmpl.GetName())
+	}
+	for _, role := range roles {
+		found := &v1alpha1.VDIRole{}
+		if err := d.createOrReplace(role, found); err != nil {
+			return err
+		}
+		report("VDIRole", role.GetName())
+	}
+	return nil
+}
+
+// getImportedRoles returns the roles in the import request labeled for this cluster.
+func (d *desktopAPI) getImportedRoles(req *v1alpha1.ImportTemplateBundleRequest) []*v1alpha1.VDIRole {
+	roles := req.GetRoles()
+	for _, role := range roles {
+		if role.Labels == nil {
+			role.Labels = make(map[string]string)
+		}
+		role.Labels[v1.RoleClusterRefLabel] = d.vdiCluster.GetName()
+	}
+	return roles
+}
+
+// checkImportedBaseTemplates returns an error if any of the given templates
+// reference a base template that is neither being imported nor already in the
+// cluster.
+func (d *desktopAPI) checkImportedBaseTemplates(templates []*v1alpha1.DesktopTemplate) error {
+	imported := make(map[string]struct{}, len(templates))
+	for _, tmpl := range templates {
+		imported[tmpl.GetName()] = struct{}{}
+	}
+	for _, tmpl := range templates {
+		base := tmpl.Spec.BaseTemplate
+		if base == "" {
+			continue
+		}
+		if _, ok := imported[base]; ok {
+			continue
+		}
+		exists, err := d.objectExists(base, &v1alpha1.DesktopTemplate{})
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("DesktopTemplate %s inherits from %s, which is not in the bundle or the cluster. Export the bundle with flatten set to include it", tmpl.GetName(), base)
+		}
+	}
+	return nil
+}
+
+// objectExists returns true if a cluster-scoped object with the given name exists.
+func (d *desktopAPI) objectExists(name string, obj object) (bool, error) {
+	nn := types.NamespacedName{Name: name, Namespace: metav1.NamespaceAll}
+	if err := d.client.Get(context.TODO(), nn, obj); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// createOrReplace creates the given object, or replaces it entirely if it already
+// exists. found is used to retrieve the existing object.
+func (d *desktopAPI) createOrReplace(obj, found object) error {
+	exists, err := d.objectExists(obj.GetName(), found)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return d.client.Create(context.TODO(), obj)
+	}
+	obj.SetResourceVersion(found.GetResourceVersion())
+	return d.client.Update(context.TODO(), obj)
+}
+
+// object is used for handling the different kinds of imported resources.
+type object interface {
+	runtime.Object
+	metav1.Object
+}
+
+// Request containing a bundle to import
+// swagger:parameters importTemplatesRequest
+type swaggerImportTemplatesRequest struct {
+	// in:body
+	Body v1alpha1.ImportTemplateBundleRequest
+}
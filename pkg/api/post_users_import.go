@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation POST /api/users/import Users importUsers
+// ---
+// summary: Create users in bulk from CSV or JSON.
+// description: |
+//   The body is read as CSV when the Content-Type is `text/csv`, otherwise it is
+//   expected to be a JSON list of users. CSV requires a header row with `username`
+//   and `roles` columns, and an optional `password` column. Multiple roles are
+//   separated by semicolons.
+//
+//   Every row is validated before any users are created, and if any row fails, no
+//   users are imported. The response then has a 400 status and includes an error
+//   for each failed row. Passwords are generated for users without one and returned
+//   in the response. Only the local authentication provider supports creating users.
+//
+//   Users cannot import users with roles that grant more than their own permissions.
+//
+//   When `async=true` is set in the query, the users are created in a job, and the
+//   job is returned instead. The response above is then the result of the job.
+// consumes:
+// - application/json
+// - text/csv
+// parameters:
+// - in: body
+//   name: users
+//   description: The users to create
+//   schema:
+//     type: array
+//     items:
+//       "$ref": "#/definitions/UserRecord"
+// - name: async
+//   in: query
+//   description: Create the users in a job and return the job.
+//   type: boolean
+//   required: false
+// responses:
+//   "200":
+//     "$ref": "#/responses/importUsersResponse"
+//   "400":
+//     "$ref": "#/responses/importUsersResponse"
+//   "403":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PostImportUsers(w http.ResponseWriter, r *http.Request) {
+	req, ok := apiutil.GetRequestObject(r).(*v1.ImportUsersRequest)
+	if !ok || req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	records := req.Users
+	user := apiutil.GetRequestUserSession(r).User
+	if isAsyncRequest(r) {
+		d.runAsJob(w, r, v1.JobTypeUserImport, func(progress jobProgressFunc) (interface{}, error) {
+			resp, err := d.importUsers(records, progress)
+			if err != nil {
+				return nil, err
+			}
+			if resp.Error != "" {
+				return resp, errors.New(resp.Error)
+			}
+			apiLogger.Info(fmt.Sprintf("User %s imported %d users", user.GetName(), resp.Imported))
+			return resp, nil
+		})
+		return
+	}
+	resp, err := d.importUsers(records, nil)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	if resp.Error != "" {
+		out, err := json.MarshalIndent(resp, "", "    ")
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		apiutil.WriteOrLogError(out, w, http.StatusBadRequest)
+		return
+	}
+	apiLogger.Info(fmt.Sprintf("User %s imported %d users", user.GetName(), resp.Imported))
+	apiutil.WriteJSON(resp, w)
+}
+
+// The result of a bulk user import
+// swagger:response importUsersResponse
+type swaggerImportUsersResponse struct {
+	// in:body
+	Body v1.ImportUsersResponse
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+)
+
+// swagger:operation PUT /api/announcements/{announcement} Announcements putAnnouncementRequest
+// ---
+// summary: Update the specified announcement.
+// description: All details of the announcement will be replaced with those provided in the payload, even if undefined.
+// parameters:
+// - name: announcement
+//   in: path
+//   description: The announcement to update
+//   type: string
+//   required: true
+// - in: body
+//   name: announcementDetails
+//   description: The announcement details to update.
+//   schema:
+//     "$ref": "#/definitions/UpdateAnnouncementRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) UpdateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	announcement := d.getAnnouncementForRequest(w, r)
+	if announcement == nil {
+		return
+	}
+	params := apiutil.GetRequestObject(r).(*v1.UpdateAnnouncementRequest)
+	if params == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	announcement.Message = params.Message
+	announcement.Severity = params.Severity
+	announcement.Roles = params.Roles
+	announcement.SetSchedule(params.StartTime, params.EndTime)
+	if err := d.client.Update(context.TODO(), announcement); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
+
+// Request containing updates to an announcement
+// swagger:parameters putAnnouncementRequest
+type swaggerUpdateAnnouncementRequest struct {
+	// in:body
+	Body v1.UpdateAnnouncementRequest
+}
@@ -1,6 +1,10 @@
 package api
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
 
 // swagger:operation PUT /api/desktops/fs/{namespace}/{name}/put Desktops putDesktopFile
 // ---
@@ -32,5 +36,8 @@ import "net/http"
 //   "404":
 //     "$ref": "#/responses/error"
 func (d *desktopAPI) PutDesktopFile(w http.ResponseWriter, r *http.Request) {
+	if !d.checkFileTransferPolicy(w, r, v1alpha1.FileTransferPolicy.AllowsUpload, "File uploads are disabled for this desktop session") {
+		return
+	}
 	d.serveHTTPProxy(w, r)
 }
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// swagger:operation PUT /api/groups/{group} Groups putGroupRequest
+// ---
+// summary: Update the specified group.
+// description: The members and roles of the group will be replaced with those provided in the payload, even if undefined.
+// parameters:
+// - name: group
+//   in: path
+//   description: The group to update
+//   type: string
+//   required: true
+// - in: body
+//   name: groupDetails
+//   description: The group details to update.
+//   schema:
+//     "$ref": "#/definitions/UpdateGroupRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	if err := d.checkGroupsSupported(); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	group := apiutil.GetGroupFromRequest(r)
+	nn := types.NamespacedName{Name: group, Namespace: metav1.NamespaceAll}
+	vdiGroup := &v1alpha1.VDIGroup{}
+	if err := d.client.Get(context.TODO(), nn, vdiGroup); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			apiutil.ReturnAPINotFound(fmt.Errorf("The group '%s' doesn't exist", group), w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	params := apiutil.GetRequestObject(r).(*v1.UpdateGroupRequest)
+	if params == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+	vdiGroup.Users = params.Users
+	vdiGroup.Roles = params.Roles
+	if err := d.client.Update(context.TODO(), vdiGroup); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	apiutil.WriteOK(w)
+}
+
+// Request containing updates to a group
+// swagger:parameters putGroupRequest
+type swaggerUpdateGroupRequest struct {
+	// in:body
+	Body v1.UpdateGroupRequest
+}
@@ -59,6 +59,17 @@ func (d *desktopAPI) UpdateRole(w http.ResponseWriter, r *http.Request) {
 	}
 	vdiRole.Annotations = params.GetAnnotations()
 	vdiRole.Rules = params.GetRules()
+	vdiRole.Inherits = params.Inherits
+	vdiRole.TokenDuration = params.GetTokenDuration()
+	vdiRole.Watermark = params.Watermark
+	vdiRole.RequireMFA = params.RequireMFA
+	vdiRole.MaxSessions = params.MaxSessions
+	vdiRole.MaxSessionsPerTemplate = params.MaxSessionsPerTemplate
+	vdiRole.Placement = params.Placement
+	if err := d.validateRoleInheritance(vdiRole); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 	if err := d.client.Update(context.TODO(), vdiRole); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
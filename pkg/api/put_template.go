@@ -54,11 +54,16 @@ func (d *desktopAPI) PutDesktopTemplate(w http.ResponseWriter, r *http.Request)
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	if err := d.checkTemplateSecurityProfiles(tmpl); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
 
 	if err := d.client.Update(context.TODO(), tmpl); err != nil {
 		apiutil.ReturnAPIError(err, w)
 		return
 	}
+	d.recordTemplateRevision(tmpl)
 
 	apiutil.WriteOK(w)
 }
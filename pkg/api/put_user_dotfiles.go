@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation PUT /api/users/{user}/dotfiles Users putUserDotfilesRequest
+// ---
+// summary: Sets the dotfiles repository to clone into the user's desktop sessions.
+// description: The repository is cloned into `~/.dotfiles` and top-level dotfiles are linked into the home directory. Sending an empty repository removes the configuration.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to update
+//   type: string
+//   required: true
+// - in: body
+//   name: putUserDotfilesRequest
+//   description: The dotfiles repository for the user.
+//   schema:
+//     "$ref": "#/definitions/DotfilesConfig"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PutUserDotfiles(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+
+	// We can't verify the user exists when using OIDC, same as with MFA.
+	if !d.vdiCluster.IsUsingOIDCAuth() {
+		if _, err := d.auth.GetUser(username); err != nil {
+			if errors.IsUserNotFoundError(err) {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	req := apiutil.GetRequestObject(r).(*v1.DotfilesConfig)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	if err := d.secrets.Lock(10); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer d.secrets.Release()
+
+	users, err := d.secrets.ReadSecretMap(v1.DotfilesSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		users = make(map[string][]byte)
+	}
+
+	if req.Repository == "" {
+		delete(users, username)
+	} else {
+		data, err := json.Marshal(req)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		users[username] = data
+	}
+
+	if err := d.secrets.WriteSecretMap(v1.DotfilesSecretKey, users); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteOK(w)
+}
+
+// Request containing a dotfiles repository for a user
+// swagger:parameters putUserDotfilesRequest
+type swaggerUpdateDotfilesRequest struct {
+	// in:body
+	Body v1.DotfilesConfig
+}
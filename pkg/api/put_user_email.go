@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation PUT /api/users/{user}/email Users putUserEmailRequest
+// ---
+// summary: Sets the email address notifications are sent to for the given user.
+// description: Users receive MFA enrollment links, password reset links, and warnings before their desktops are destroyed at this address. Sending an empty address removes it.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to update
+//   type: string
+//   required: true
+// - in: body
+//   name: putUserEmailRequest
+//   description: The email address for the user.
+//   schema:
+//     "$ref": "#/definitions/UserEmailConfig"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PutUserEmail(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+
+	// We can't verify the user exists when using OIDC, same as with MFA.
+	if !d.vdiCluster.IsUsingOIDCAuth() {
+		if _, err := d.auth.GetUser(username); err != nil {
+			if errors.IsUserNotFoundError(err) {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	req := apiutil.GetRequestObject(r).(*v1.UserEmailConfig)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	if err := d.secrets.Lock(10); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer d.secrets.Release()
+
+	users, err := d.secrets.ReadSecretMap(v1.UserEmailsSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		users = make(map[string][]byte)
+	}
+
+	if req.Address == "" {
+		delete(users, username)
+	} else {
+		users[username] = []byte(req.Address)
+	}
+
+	if err := d.secrets.WriteSecretMap(v1.UserEmailsSecretKey, users); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteOK(w)
+}
+
+// Request containing an email address for a user
+// swagger:parameters putUserEmailRequest
+type swaggerUpdateUserEmailRequest struct {
+	// in:body
+	Body v1.UserEmailConfig
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation PUT /api/users/{user}/keyboard Users putUserKeyboardRequest
+// ---
+// summary: Sets the keyboard layout for the displays of the given user's desktop sessions.
+// description: |
+//   The layout is applied to the display each time the user connects, and takes precedence
+//   over the layout detected by the client. Sending an empty layout removes it.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to update
+//   type: string
+//   required: true
+// - in: body
+//   name: putUserKeyboardRequest
+//   description: The keyboard layout for the user.
+//   schema:
+//     "$ref": "#/definitions/KeyboardLayoutConfig"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PutUserKeyboard(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+
+	// We can't verify the user exists when using OIDC, same as with MFA.
+	if !d.vdiCluster.IsUsingOIDCAuth() {
+		if _, err := d.auth.GetUser(username); err != nil {
+			if errors.IsUserNotFoundError(err) {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	req := apiutil.GetRequestObject(r).(*v1.KeyboardLayoutConfig)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	if err := d.secrets.Lock(10); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer d.secrets.Release()
+
+	users, err := d.secrets.ReadSecretMap(v1.UserKeyboardLayoutsSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		users = make(map[string][]byte)
+	}
+
+	if req.Layout == "" {
+		delete(users, username)
+	} else {
+		out, err := json.Marshal(req)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		users[username] = out
+	}
+
+	if err := d.secrets.WriteSecretMap(v1.UserKeyboardLayoutsSecretKey, users); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteOK(w)
+}
+
+// Request containing a keyboard layout for a user
+// swagger:parameters putUserKeyboardRequest
+type swaggerUpdateUserKeyboardRequest struct {
+	// in:body
+	Body v1.KeyboardLayoutConfig
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/email"
 
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
@@ -14,6 +15,9 @@ import (
 // swagger:operation PUT /api/users/{user}/mfa Users putUserMFARequest
 // ---
 // summary: Updates MFA configuration for the specified user.
+// description: |
+//   When enabling MFA, a new OTP secret and set of backup codes are generated for the user.
+//   The backup codes are only returned in this response.
 // parameters:
 // - name: user
 //   in: path
@@ -59,6 +63,11 @@ func (d *desktopAPI) PutUserMFA(w http.ResponseWriter, r *http.Request) {
 
 	// We are enabling MFA
 	if req.Enabled {
+		// Users authorize with pushes instead of shared OTP secrets
+		if d.mfaProvider != nil {
+			apiutil.ReturnAPIError(errors.New("This cluster uses push MFA, OTP secrets cannot be enrolled"), w)
+			return
+		}
 		// https://github.com/xlzd/gotp/blob/master/utils.go#L79
 		//Only uses uppercase characters and digits
 		newSecret := gotp.RandomSecret(32)
@@ -66,10 +75,21 @@ func (d *desktopAPI) PutUserMFA(w http.ResponseWriter, r *http.Request) {
 			apiutil.ReturnAPIError(err, w)
 			return
 		}
+		backupCodes, err := d.mfa.GenerateBackupCodes(username)
+		if err != nil {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		provisioningURI := gotp.NewDefaultTOTP(newSecret).ProvisioningUri(username, "kVDI")
+		// Send the enrollment link to the user as well, for when an administrator
+		// is enabling MFA on their behalf.
+		d.sendUserEmail(username, email.MessageMFAEnrollment, &email.Data{Link: provisioningURI})
 		apiutil.WriteJSON(&v1.MFAResponse{
-			Enabled:         true,
-			Verified:        false,
-			ProvisioningURI: gotp.NewDefaultTOTP(newSecret).ProvisioningUri(username, "kVDI"),
+			Enabled:              true,
+			Verified:             false,
+			ProvisioningURI:      provisioningURI,
+			BackupCodes:          backupCodes,
+			BackupCodesRemaining: len(backupCodes),
 		}, w)
 		return
 	}
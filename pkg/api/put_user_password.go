@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation PUT /api/users/{user}/password Users putUserPasswordRequest
+// ---
+// summary: Change the password for the requesting user.
+// description: Users can only change their own password, and must provide their current one.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to change the password for
+//   type: string
+//   required: true
+// - in: body
+//   name: body
+//   description: The current and new password.
+//   schema:
+//     "$ref": "#/definitions/ChangePasswordRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PutUserPassword(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+	req := apiutil.GetRequestObject(r).(*v1.ChangePasswordRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	if err := d.auth.ChangePassword(username, req); err != nil {
+		if errors.IsUserNotFoundError(err) {
+			apiutil.ReturnAPINotFound(err, w)
+			return
+		}
+		if errors.IsInvalidCredentialsError(err) {
+			apiutil.ReturnAPIForbidden(err, "The current password is incorrect", w)
+			return
+		}
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiLogger.Info(fmt.Sprintf("User %s changed their password", username))
+	apiutil.WriteOK(w)
+}
+
+// Request containing a user's current and new password
+// swagger:parameters putUserPasswordRequest
+type swaggerChangePasswordRequest struct {
+	// in:body
+	Body v1.ChangePasswordRequest
+}
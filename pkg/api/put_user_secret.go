@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/gorilla/mux"
+)
+
+// swagger:operation PUT /api/users/{user}/secrets/{secret} Users putUserSecretRequest
+// ---
+// summary: Sets the value of one of the user's secrets.
+// description: Secrets can be injected into the environment of the user's desktop sessions. Changes apply to sessions started afterwards.
+// parameters:
+//   - name: user
+//     in: path
+//     description: The user to update
+//     type: string
+//     required: true
+//   - name: secret
+//     in: path
+//     description: The name of the secret
+//     type: string
+//     required: true
+//   - in: body
+//     name: putUserSecretRequest
+//     description: The value to set for the secret.
+//     schema:
+//     "$ref": "#/definitions/UpdateUserSecretRequest"
+//
+// responses:
+//
+//	"200":
+//	  "$ref": "#/responses/boolResponse"
+//	"400":
+//	  "$ref": "#/responses/error"
+//	"403":
+//	  "$ref": "#/responses/error"
+//	"404":
+//	  "$ref": "#/responses/error"
+func (d *desktopAPI) PutUserSecret(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+	name := mux.Vars(r)["secret"]
+
+	if err := v1.ValidateUserSecretName(name); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	// We can't verify the user exists when using OIDC, same as with MFA.
+	if !d.vdiCluster.IsUsingOIDCAuth() {
+		if _, err := d.auth.GetUser(username); err != nil {
+			if errors.IsUserNotFoundError(err) {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	req := apiutil.GetRequestObject(r).(*v1.UpdateUserSecretRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	if err := d.secrets.Lock(10); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer d.secrets.Release()
+
+	users, secrets, err := d.readUserSecrets(username)
+	if err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	secrets[name] = req.Value
+
+	if err := d.writeUserSecrets(users, username, secrets); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteOK(w)
+}
+
+// Request containing the value of a user secret
+// swagger:parameters putUserSecretRequest
+type swaggerUpdateUserSecretRequest struct {
+	// in:body
+	Body v1.UpdateUserSecretRequest
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// swagger:operation PUT /api/users/{user}/userdata Users putUserDataRequest
+// ---
+// summary: Sets the first-boot script to run in the user's desktop sessions.
+// description: The script is used in place of any configured on the template. Sending an empty script removes it.
+// parameters:
+// - name: user
+//   in: path
+//   description: The user to update
+//   type: string
+//   required: true
+// - in: body
+//   name: putUserDataRequest
+//   description: The script to set for the user.
+//   schema:
+//     "$ref": "#/definitions/UpdateUserDataRequest"
+// responses:
+//   "200":
+//     "$ref": "#/responses/boolResponse"
+//   "400":
+//     "$ref": "#/responses/error"
+//   "403":
+//     "$ref": "#/responses/error"
+//   "404":
+//     "$ref": "#/responses/error"
+func (d *desktopAPI) PutUserData(w http.ResponseWriter, r *http.Request) {
+	username := apiutil.GetUserFromRequest(r)
+
+	// We can't verify the user exists when using OIDC, same as with MFA.
+	if !d.vdiCluster.IsUsingOIDCAuth() {
+		if _, err := d.auth.GetUser(username); err != nil {
+			if errors.IsUserNotFoundError(err) {
+				apiutil.ReturnAPINotFound(err, w)
+				return
+			}
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+	}
+
+	req := apiutil.GetRequestObject(r).(*v1.UpdateUserDataRequest)
+	if req == nil {
+		apiutil.ReturnAPIError(errors.New("Malformed request"), w)
+		return
+	}
+
+	if err := d.secrets.Lock(10); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+	defer d.secrets.Release()
+
+	users, err := d.secrets.ReadSecretMap(v1.UserDataSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			apiutil.ReturnAPIError(err, w)
+			return
+		}
+		users = make(map[string][]byte)
+	}
+
+	if req.UserData == "" {
+		delete(users, username)
+	} else {
+		users[username] = []byte(req.UserData)
+	}
+
+	if err := d.secrets.WriteSecretMap(v1.UserDataSecretKey, users); err != nil {
+		apiutil.ReturnAPIError(err, w)
+		return
+	}
+
+	apiutil.WriteOK(w)
+}
+
+// Request containing a first-boot script for a user
+// swagger:parameters putUserDataRequest
+type swaggerUpdateUserDataRequest struct {
+	// in:body
+	Body v1.UpdateUserDataRequest
+}
@@ -31,7 +31,7 @@ func (r *ResourceGetter) GetUsers() ([]v1.VDIUser, error) {
 
 // GetRoles returns a list of all the VDIRolse for this cluster.
 func (r *ResourceGetter) GetRoles() ([]v1.VDIUserRole, error) {
-	roles, err := r.api.vdiCluster.GetRoles(r.api.client)
+	roles, err := r.api.vdiCluster.GetResolvedRoles(r.api.client)
 	if err != nil {
 		apiLogger.Error(err, "Failed to list VDI roles")
 		return nil, err
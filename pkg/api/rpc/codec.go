@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// toJSON converts a request message to the JSON body expected by the REST API,
+// returning the values of the path parameters separately.
+func (m *method) toJSON(msg *dynamicpb.Message) (params map[string]string, body []byte, err error) {
+	raw, err := protojson.MarshalOptions{}.Marshal(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, nil, err
+	}
+	params = make(map[string]string)
+	for _, param := range m.params {
+		if val, ok := fields[param]; ok {
+			params[param] = val.(string)
+		}
+		delete(fields, param)
+	}
+	if m.Request == nil {
+		return params, nil, nil
+	}
+	unquoteInts(msg.Descriptor(), fields)
+	body, err = json.Marshal(fields)
+	return params, body, err
+}
+
+// unquoteInts converts the 64-bit integers in the given protojson output back to
+// JSON numbers. The protobuf JSON mapping encodes them as strings, which
+// encoding/json will not decode into an integer.
+func unquoteInts(desc protoreflect.MessageDescriptor, fields map[string]interface{}) {
+	for i := 0; i < desc.Fields().Len(); i++ {
+		fd := desc.Fields().Get(i)
+		val, ok := fields[fd.JSONName()]
+		if !ok {
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			entries, _ := val.(map[string]interface{})
+			for key, entry := range entries {
+				entries[key] = unquoteValue(fd.MapValue(), entry)
+			}
+		case fd.IsList():
+			items, _ := val.([]interface{})
+			for idx, item := range items {
+				items[idx] = unquoteValue(fd, item)
+			}
+		default:
+			fields[fd.JSONName()] = unquoteValue(fd, val)
+		}
+	}
+}
+
+func unquoteValue(fd protoreflect.FieldDescriptor, val interface{}) interface{} {
+	switch fd.Kind() {
+	case protoreflect.Int64Kind, protoreflect.Uint64Kind:
+		if str, ok := val.(string); ok {
+			return json.Number(str)
+		}
+	case protoreflect.MessageKind:
+		if nested, ok := val.(map[string]interface{}); ok && !isWellKnown(fd.Message()) {
+			unquoteInts(fd.Message(), nested)
+		}
+	}
+	return val
+}
+
+func isWellKnown(desc protoreflect.MessageDescriptor) bool {
+	return strings.HasPrefix(string(desc.FullName()), "google.protobuf.")
+}
+
+// fromJSON converts a response body from the REST API to a response message.
+func (m *method) fromJSON(body []byte) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(m.response)
+	if m.ListField != "" {
+		wrapped, err := json.Marshal(map[string]json.RawMessage{m.ListField: body})
+		if err != nil {
+			return nil, err
+		}
+		body = wrapped
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
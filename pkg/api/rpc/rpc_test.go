@@ -0,0 +1,307 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type testEmbedded struct {
+	Kind string `json:"kind"`
+}
+
+type testChild struct {
+	Count int64 `json:"count"`
+}
+
+type testRequest struct {
+	testEmbedded `json:",inline"`
+	DisplayName  string               `json:"displayName"`
+	Size         int64                `json:"size"`
+	Children     []*testChild         `json:"children"`
+	ChildMap     map[string]testChild `json:"childMap"`
+	Labels       map[string]string    `json:"labels"`
+	Created      time.Time            `json:"created"`
+	Raw          interface{}          `json:"raw"`
+	Ignored      string               `json:"-"`
+	unexported   string
+}
+
+type testResponse struct {
+	Name     string            `json:"name"`
+	Received map[string]string `json:"received"`
+}
+
+var testMethods = []*Method{
+	{
+		Name:       "CreateThing",
+		HTTPMethod: http.MethodPost,
+		Path:       "/api/things/{namespace}/{name}",
+		Request:    testRequest{},
+		Response:   testResponse{},
+	},
+	{
+		Name:       "ListThings",
+		HTTPMethod: http.MethodGet,
+		Path:       "/api/things",
+		Response:   []*testResponse{},
+		ListField:  "things",
+	},
+	{
+		Name:       "DeleteThing",
+		HTTPMethod: http.MethodDelete,
+		Path:       "/api/things/{namespace}/{name}",
+	},
+}
+
+// testFieldNumbers returns a new field table for the test methods.
+func testFieldNumbers() FieldNumbers {
+	return FieldNumbers{
+		"CreateThingRequest": {
+			"namespace":    1,
+			"name":         2,
+			"kind":         3,
+			"display_name": 4,
+			"size":         5,
+			"children":     6,
+			"child_map":    7,
+			"labels":       8,
+			"created":      9,
+			"raw":          10,
+		},
+		"TestChild":          {"count": 1},
+		"TestResponse":       {"name": 1, "received": 2},
+		"ListThingsResponse": {"things": 1},
+		"DeleteThingRequest": {"namespace": 1, "name": 2},
+		"OKResponse":         {"ok": 1},
+	}
+}
+
+func mustNewService(t *testing.T) *Service {
+	t.Helper()
+	svc, err := NewService("kvdi.test", "Things", testMethods, testFieldNumbers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestProto(t *testing.T) {
+	proto := mustNewService(t).Proto()
+	for _, expected := range []string{
+		"package kvdi.test;",
+		"rpc CreateThing(CreateThingRequest) returns (TestResponse);",
+		"rpc ListThings(ListThingsRequest) returns (ListThingsResponse);",
+		"rpc DeleteThing(DeleteThingRequest) returns (OKResponse);",
+		"string namespace = 1;",
+		"string name = 2;",
+		"string kind = 3;",
+		`string display_name = 4 [json_name = "displayName"];`,
+		"int64 size = 5;",
+		"repeated TestChild children = 6;",
+		`map<string, TestChild> child_map = 7 [json_name = "childMap"];`,
+		"map<string, string> labels = 8;",
+		"string created = 9;",
+		"google.protobuf.Value raw = 10;",
+		"repeated TestResponse things = 1;",
+	} {
+		if !strings.Contains(proto, expected) {
+			t.Errorf("Expected proto to contain %q, got:\n%s", expected, proto)
+		}
+	}
+	for _, unexpected := range []string{"ignored", "unexported"} {
+		if strings.Contains(proto, unexpected) {
+			t.Errorf("Expected proto to not contain %q, got:\n%s", unexpected, proto)
+		}
+	}
+}
+
+func TestDuplicateFields(t *testing.T) {
+	if _, err := NewService("kvdi.test", "Things", []*Method{
+		{
+			Name:       "CreateThing",
+			HTTPMethod: http.MethodPost,
+			Path:       "/api/things/{displayName}",
+			Request:    testRequest{},
+		},
+	}, testFieldNumbers()); err == nil {
+		t.Error("Expected error for path parameter conflicting with request field")
+	}
+}
+
+func TestFieldNumbers(t *testing.T) {
+	// numbers come from the table, not the order fields are declared in
+	numbers := testFieldNumbers()
+	numbers["CreateThingRequest"]["display_name"] = 12
+	numbers["CreateThingRequest"]["kind"] = 4
+	svc, err := NewService("kvdi.test", "Things", testMethods, numbers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proto := svc.Proto()
+	for _, expected := range []string{
+		"string kind = 4;",
+		`string display_name = 12 [json_name = "displayName"];`,
+	} {
+		if !strings.Contains(proto, expected) {
+			t.Errorf("Expected proto to contain %q, got:\n%s", expected, proto)
+		}
+	}
+	if idx := strings.Index(proto, "display_name = 12"); idx < strings.Index(proto, "raw = 10") {
+		t.Error("Expected fields to be rendered in number order")
+	}
+
+	// every field needs a number
+	numbers = testFieldNumbers()
+	delete(numbers["TestChild"], "count")
+	if _, err := NewService("kvdi.test", "Things", testMethods, numbers); err == nil {
+		t.Error("Expected error for field without a number")
+	}
+
+	// and numbers can't be shared
+	numbers = testFieldNumbers()
+	numbers["TestResponse"]["received"] = 1
+	if _, err := NewService("kvdi.test", "Things", testMethods, numbers); err == nil {
+		t.Error("Expected error for fields sharing a number")
+	}
+}
+
+// testHandler echoes the request back in the response.
+func testHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Session-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "Forbidden: No token provided in request"}`))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Write([]byte(`[{"name": "one"}, {"name": "two", "unknown": true}]`))
+	case http.MethodDelete:
+		if r.URL.Path != "/api/things/default/missing" {
+			w.Write([]byte(`{"ok": true}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "thing not found"}`))
+	case http.MethodPost:
+		body, _ := ioutil.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":     r.URL.Path,
+			"received": map[string]string{"body": string(body)},
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	svc := mustNewService(t)
+	lis := bufconn.Listen(1024 * 1024)
+	server := svc.NewServer(http.HandlerFunc(testHandler))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	methods := svc.Descriptor().Services().Get(0).Methods()
+	invoke := func(ctx context.Context, name string, set func(*dynamicpb.Message)) (*dynamicpb.Message, error) {
+		t.Helper()
+		desc := methods.ByName(protoreflect.Name(name))
+		in, out := dynamicpb.NewMessage(desc.Input()), dynamicpb.NewMessage(desc.Output())
+		if set != nil {
+			set(in)
+		}
+		return out, conn.Invoke(ctx, "/kvdi.test.Things/"+name, in, out)
+	}
+	authed := metadata.AppendToOutgoingContext(context.Background(), TokenMetadataKey, "token")
+
+	// calls without credentials are rejected by the handler
+	if _, err := invoke(context.Background(), "ListThings", nil); status.Code(err) != codes.PermissionDenied {
+		t.Error("Expected permission denied without a token, got:", err)
+	} else if status.Convert(err).Message() != "Forbidden: No token provided in request" {
+		t.Error("Expected error message from response, got:", status.Convert(err).Message())
+	}
+
+	// bearer tokens are accepted in the authorization metadata
+	bearer := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+	out, err := invoke(bearer, "ListThings", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	things := out.Get(out.Descriptor().Fields().ByName("things")).List()
+	if things.Len() != 2 {
+		t.Fatal("Expected two things, got:", things.Len())
+	}
+	if name := things.Get(1).Message().Get(things.Get(1).Message().Descriptor().Fields().ByName("name")).String(); name != "two" {
+		t.Error("Expected second thing to be named two, got:", name)
+	}
+
+	// path parameters are required
+	if _, err := invoke(authed, "DeleteThing", nil); status.Code(err) != codes.InvalidArgument {
+		t.Error("Expected invalid argument without path parameters, got:", err)
+	}
+	setPath := func(name string) func(*dynamicpb.Message) {
+		return func(msg *dynamicpb.Message) {
+			fields := msg.Descriptor().Fields()
+			msg.Set(fields.ByName("namespace"), protoreflect.ValueOfString("default"))
+			msg.Set(fields.ByName("name"), protoreflect.ValueOfString(name))
+		}
+	}
+	if _, err := invoke(authed, "DeleteThing", setPath("missing")); status.Code(err) != codes.NotFound {
+		t.Error("Expected not found, got:", err)
+	}
+	out, err = invoke(authed, "DeleteThing", setPath("thing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Get(out.Descriptor().Fields().ByName("ok")).Bool() {
+		t.Error("Expected ok response")
+	}
+
+	// request messages are converted to the JSON the handler expects
+	out, err = invoke(authed, "CreateThing", func(msg *dynamicpb.Message) {
+		setPath("thing")(msg)
+		fields := msg.Descriptor().Fields()
+		msg.Set(fields.ByName("display_name"), protoreflect.ValueOfString("My Thing"))
+		msg.Set(fields.ByName("size"), protoreflect.ValueOfInt64(1024))
+		child := msg.Mutable(fields.ByName("children")).List().NewElement()
+		child.Message().Set(child.Message().Descriptor().Fields().ByName("count"), protoreflect.ValueOfInt64(2))
+		msg.Mutable(fields.ByName("children")).List().Append(child)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := out.Descriptor().Fields()
+	if name := out.Get(fields.ByName("name")).String(); name != "/api/things/default/thing" {
+		t.Error("Expected request path to be built from parameters, got:", name)
+	}
+	body := out.Get(fields.ByName("received")).Map().Get(protoreflect.ValueOfString("body").MapKey()).String()
+	var received testRequest
+	if err := json.Unmarshal([]byte(body), &received); err != nil {
+		t.Fatal(err)
+	}
+	if received.DisplayName != "My Thing" || received.Size != 1024 || len(received.Children) != 1 || received.Children[0].Count != 2 {
+		t.Error("Request body was not converted correctly, got:", body)
+	}
+	if strings.Contains(body, "namespace") {
+		t.Error("Expected path parameters to be removed from the body, got:", body)
+	}
+}
@@ -0,0 +1,289 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// structPackagePrefix is the prefix of the packages whose structs are translated
+// into messages. Structs from other packages, like the Kubernetes API types, are
+// carried as a google.protobuf.Value holding their JSON representation.
+const structPackagePrefix = "github.com/tinyzimmer/kvdi/"
+
+// valueType is the name of the well-known type used for opaque fields.
+const valueType = ".google.protobuf.Value"
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	metaTimeType      = reflect.TypeOf(metav1.Time{})
+	byteSliceType     = reflect.TypeOf([]byte{})
+)
+
+// scalar is the protobuf type of a field that is not a message.
+type scalar string
+
+// Protobuf scalar types used for fields.
+const (
+	scalarString scalar = "string"
+	scalarBool   scalar = "bool"
+	scalarInt32  scalar = "int32"
+	scalarInt64  scalar = "int64"
+	scalarUint32 scalar = "uint32"
+	scalarUint64 scalar = "uint64"
+	scalarFloat  scalar = "float"
+	scalarDouble scalar = "double"
+	scalarBytes  scalar = "bytes"
+)
+
+// field is a field in a message.
+type field struct {
+	// the proto name of the field
+	name string
+	// the name of the field in the JSON representation
+	jsonName string
+	// the field number
+	number int32
+	// set for scalar fields and the values of scalar maps
+	scalar scalar
+	// set for message fields and the values of message maps, either the name of a
+	// message in the schema or a fully-qualified well-known type
+	message string
+	// true for repeated fields
+	repeated bool
+	// true for map<string, ...> fields
+	isMap bool
+}
+
+// typeName returns the type of the field as it appears in a .proto file.
+func (f *field) typeName() string {
+	name := string(f.scalar)
+	if f.message != "" {
+		name = strings.TrimPrefix(f.message, ".")
+	}
+	if f.isMap {
+		return fmt.Sprintf("map<string, %s>", name)
+	}
+	if f.repeated {
+		return "repeated " + name
+	}
+	return name
+}
+
+// message is a message in the schema.
+type message struct {
+	name   string
+	fields []*field
+}
+
+// schema is a set of messages built by reflecting over Go types. The JSON
+// representation of each message matches the encoding/json representation of the
+// type it was built from.
+type schema struct {
+	messages []*message
+	byName   map[string]*message
+	types    map[reflect.Type]string
+}
+
+func newSchema() *schema {
+	return &schema{byName: make(map[string]*message), types: make(map[reflect.Type]string)}
+}
+
+// add adds a message to the schema, returning an error if another message has the
+// same name.
+func (s *schema) add(msg *message) error {
+	if _, ok := s.byName[msg.name]; ok {
+		return fmt.Errorf("Duplicate message name %s", msg.name)
+	}
+	s.messages = append(s.messages, msg)
+	s.byName[msg.name] = msg
+	return nil
+}
+
+// messageName returns the name of the message for the given struct type.
+func messageName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+// messageFor returns the name of the message for the given struct type, adding it
+// and any messages it refers to the schema if needed.
+func (s *schema) messageFor(t reflect.Type) (string, error) {
+	if name, ok := s.types[t]; ok {
+		return name, nil
+	}
+	name := messageName(t)
+	msg := &message{name: name}
+	// register the type before its fields so recursive types resolve
+	s.types[t] = name
+	if err := s.add(msg); err != nil {
+		return "", err
+	}
+	fields, err := s.structFields(t)
+	if err != nil {
+		return "", err
+	}
+	msg.fields = fields
+	return name, nil
+}
+
+// structFields returns the fields for the given struct type in the order they are
+// declared. Embedded structs are inlined the way encoding/json does.
+func (s *schema) structFields(t reflect.Type) ([]*field, error) {
+	fields := make([]*field, 0)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		jsonName := strings.Split(tag, ",")[0]
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && jsonName == "" && ft.Kind() == reflect.Struct {
+			inlined, err := s.structFields(ft)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, inlined...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if jsonName == "" {
+			jsonName = sf.Name
+		}
+		f, err := s.fieldFor(sf.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", t.Name(), sf.Name, err.Error())
+		}
+		f.name, f.jsonName = protoName(jsonName), jsonName
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// number assigns the field numbers for every message in the schema from the given
+// table. An error is returned if a field is missing from the table, or if two
+// fields in a message share a number.
+func (s *schema) number(numbers FieldNumbers) error {
+	for _, msg := range s.messages {
+		seen := make(map[int32]string)
+		for _, f := range msg.fields {
+			num, ok := numbers[msg.name][f.name]
+			if !ok || num < 1 {
+				return fmt.Errorf("No field number for %s.%s", msg.name, f.name)
+			}
+			if other, ok := seen[num]; ok {
+				return fmt.Errorf("Fields %s and %s in %s have the same number %d", other, f.name, msg.name, num)
+			}
+			seen[num] = f.name
+			f.number = num
+		}
+		sort.Slice(msg.fields, func(i, j int) bool { return msg.fields[i].number < msg.fields[j].number })
+	}
+	return nil
+}
+
+// fieldFor returns a field with the type for the given Go type.
+func (s *schema) fieldFor(t reflect.Type) (*field, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType || t == metaTimeType:
+		return &field{scalar: scalarString}, nil
+	case t == byteSliceType:
+		return &field{scalar: scalarBytes}, nil
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		elem, err := s.fieldFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if elem.repeated || elem.isMap {
+			return &field{message: valueType}, nil
+		}
+		elem.repeated = true
+		return elem, nil
+	case t.Kind() == reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return &field{message: valueType}, nil
+		}
+		elem, err := s.fieldFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if elem.repeated || elem.isMap || elem.scalar == scalarBytes {
+			return &field{message: valueType}, nil
+		}
+		elem.isMap = true
+		return elem, nil
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return &field{message: valueType}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &field{scalar: scalarString}, nil
+	case reflect.Bool:
+		return &field{scalar: scalarBool}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &field{scalar: scalarInt32}, nil
+	case reflect.Int, reflect.Int64:
+		return &field{scalar: scalarInt64}, nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &field{scalar: scalarUint32}, nil
+	case reflect.Uint, reflect.Uint64:
+		return &field{scalar: scalarUint64}, nil
+	case reflect.Float32:
+		return &field{scalar: scalarFloat}, nil
+	case reflect.Float64:
+		return &field{scalar: scalarDouble}, nil
+	case reflect.Struct:
+		if !strings.HasPrefix(t.PkgPath(), structPackagePrefix) {
+			return &field{message: valueType}, nil
+		}
+		name, err := s.messageFor(t)
+		if err != nil {
+			return nil, err
+		}
+		return &field{message: name}, nil
+	case reflect.Interface:
+		return &field{message: valueType}, nil
+	}
+	return nil, fmt.Errorf("Unsupported type %s", t)
+}
+
+// protoName converts a JSON field name to the snake case used for proto fields.
+func protoName(jsonName string) string {
+	var b strings.Builder
+	runes := []rune(jsonName)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// start a new word at the beginning of a capitalized word or acronym
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteRune('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,207 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// TokenMetadataKey is the metadata key clients can use to pass their session
+// token. A bearer token in the authorization key is also accepted.
+const TokenMetadataKey = "x-session-token"
+
+// credentials are the client details extracted from an incoming call.
+type credentials struct {
+	token      string
+	remoteAddr string
+}
+
+type credentialsKey struct{}
+
+// credentialsFromContext returns the credentials stored in the context by the
+// server interceptor.
+func credentialsFromContext(ctx context.Context) *credentials {
+	if creds, ok := ctx.Value(credentialsKey{}).(*credentials); ok {
+		return creds
+	}
+	return &credentials{}
+}
+
+// CredentialsInterceptor is a unary server interceptor that extracts the session
+// token and peer address from an incoming call. They are forwarded to the REST
+// handler, so calls pass through the same session and grant validation as HTTP
+// requests.
+func CredentialsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	creds := &credentials{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(TokenMetadataKey); len(vals) > 0 {
+			creds.token = vals[0]
+		} else if vals := md.Get("authorization"); len(vals) > 0 {
+			creds.token = strings.TrimPrefix(vals[0], "Bearer ")
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		creds.remoteAddr = p.Addr.String()
+	}
+	return handler(context.WithValue(ctx, credentialsKey{}, creds), req)
+}
+
+// NewServer returns a gRPC server for the service. Each call is translated to a
+// request against the given REST handler, and the response is translated back
+// into the response message.
+func (s *Service) NewServer(handler http.Handler, opts ...grpc.ServerOption) *grpc.Server {
+	desc := &grpc.ServiceDesc{
+		ServiceName: s.FullName(),
+		HandlerType: (*interface{})(nil),
+		Metadata:    s.file.Path(),
+	}
+	for _, m := range s.methods {
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: m.Name,
+			Handler:    s.methodHandler(m),
+		})
+	}
+	opts = append([]grpc.ServerOption{grpc.UnaryInterceptor(CredentialsInterceptor)}, opts...)
+	server := grpc.NewServer(opts...)
+	server.RegisterService(desc, handler)
+	return server
+}
+
+func (s *Service) methodHandler(m *method) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := dynamicpb.NewMessage(m.request)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		call := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return m.serve(ctx, srv.(http.Handler), req.(*dynamicpb.Message))
+		}
+		if interceptor == nil {
+			return call(ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + s.FullName() + "/" + m.Name}
+		return interceptor(ctx, in, info, call)
+	}
+}
+
+// serve runs the given request message through the REST handler.
+func (m *method) serve(ctx context.Context, handler http.Handler, in *dynamicpb.Message) (interface{}, error) {
+	params, body, err := m.toJSON(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	path := m.Path
+	for _, match := range pathParamRegex.FindAllStringSubmatch(m.Path, -1) {
+		val := params[match[1]]
+		if val == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "%s is required", match[1])
+		}
+		path = strings.Replace(path, match[0], url.PathEscape(val), 1)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, m.HTTPMethod, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	req.RequestURI = path
+	creds := credentialsFromContext(ctx)
+	if creds.token != "" {
+		req.Header.Set("X-Session-Token", creds.token)
+	}
+	if creds.remoteAddr != "" {
+		req.RemoteAddr = creds.remoteAddr
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rw := newResponseWriter()
+	handler.ServeHTTP(rw, req)
+
+	if rw.code >= http.StatusBadRequest {
+		return nil, status.Error(statusCode(rw.code), errorMessage(rw.body.Bytes()))
+	}
+	out, err := m.fromJSON(rw.body.Bytes())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to decode response: %s", err.Error())
+	}
+	return out, nil
+}
+
+// responseWriter is an http.ResponseWriter that buffers the response from the
+// REST handler, so it can be translated into the response message.
+type responseWriter struct {
+	header      http.Header
+	code        int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newResponseWriter() *responseWriter {
+	return &responseWriter{header: make(http.Header), code: http.StatusOK}
+}
+
+// Header implements http.ResponseWriter.
+func (w *responseWriter) Header() http.Header { return w.header }
+
+// Write implements http.ResponseWriter.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter. Only the first status code written
+// is kept, like with a real connection.
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.code, w.wroteHeader = code, true
+}
+
+// statusCode maps an HTTP status code to a gRPC status code.
+func statusCode(httpCode int) codes.Code {
+	switch httpCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	if httpCode >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// errorMessage returns the message from an API error response.
+func errorMessage(body []byte) string {
+	apiErr := struct {
+		Error string `json:"error"`
+	}{}
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error != "" {
+		return apiErr.Error
+	}
+	return strings.TrimSpace(string(body))
+}
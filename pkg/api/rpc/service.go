@@ -0,0 +1,342 @@
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/structpb" // registers google/protobuf/struct.proto
+)
+
+// pathParamRegex matches the variables in a gorilla path template.
+var pathParamRegex = regexp.MustCompile(`{([^}:]+)(:[^}]+)?}`)
+
+// OKResponse is the response for methods that return no data in the REST API.
+type OKResponse struct {
+	// Always true when the request succeeds
+	OK bool `json:"ok"`
+}
+
+// Method describes a gRPC method that is served by a REST endpoint.
+type Method struct {
+	// The name of the method
+	Name string
+	// A description of the method, rendered as a comment in the .proto file
+	Description string
+	// The HTTP method of the REST endpoint
+	HTTPMethod string
+	// The path of the REST endpoint, as a gorilla path template. The variables
+	// in the path become the leading fields of the request message.
+	Path string
+	// The type of the request body, nil if the endpoint takes no body. The fields
+	// of the type are inlined into the request message.
+	Request interface{}
+	// The type of the response body, nil if the endpoint returns an OKResponse.
+	Response interface{}
+	// When the endpoint returns a JSON array, the name of the repeated field
+	// holding the array in the response message.
+	ListField string
+}
+
+// FieldNumbers is a table of the field numbers for each message in a Service,
+// keyed by message name and then by the proto name of the field. Fields are
+// numbered from the table rather than the order they are declared in Go, so the
+// wire format stays the same as types change. Every field must be listed. New
+// fields should be given the next unused number in their message, and entries for
+// removed fields can be left in place so their numbers aren't reused.
+type FieldNumbers map[string]map[string]int32
+
+// method is a Method with its resolved descriptors.
+type method struct {
+	*Method
+	params   []string
+	request  protoreflect.MessageDescriptor
+	response protoreflect.MessageDescriptor
+}
+
+// Service is a gRPC service built from a list of Methods.
+type Service struct {
+	pkg, name string
+	methods   []*method
+	schema    *schema
+	file      protoreflect.FileDescriptor
+}
+
+// NewService builds the schema for the given methods, with fields numbered from
+// the given table, and returns a new Service.
+func NewService(pkg, name string, methods []*Method, numbers FieldNumbers) (*Service, error) {
+	s := &Service{pkg: pkg, name: name, schema: newSchema()}
+	for _, m := range methods {
+		if err := s.addMethod(m); err != nil {
+			return nil, fmt.Errorf("%s: %s", m.Name, err.Error())
+		}
+	}
+	if err := s.schema.number(numbers); err != nil {
+		return nil, err
+	}
+	fdp := s.fileDescriptorProto()
+	file, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, err
+	}
+	s.file = file
+	for _, m := range s.methods {
+		m.request = file.Messages().ByName(protoreflect.Name(m.Name + "Request"))
+		m.response = file.Messages().ByName(protoreflect.Name(s.responseName(m)))
+	}
+	return s, nil
+}
+
+// FullName returns the fully-qualified name of the service.
+func (s *Service) FullName() string { return s.pkg + "." + s.name }
+
+// Descriptor returns the descriptor for the file containing the service and its
+// messages. Clients can use it to build dynamic messages for calls.
+func (s *Service) Descriptor() protoreflect.FileDescriptor { return s.file }
+
+// responseName returns the name of the response message for a method.
+func (s *Service) responseName(m *method) string {
+	if m.ListField != "" {
+		return m.Name + "Response"
+	}
+	if m.Response == nil {
+		return messageName(reflect.TypeOf(OKResponse{}))
+	}
+	return s.schema.types[structType(m.Response)]
+}
+
+// structType returns the underlying type of the given value, dereferencing
+// pointers.
+func structType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func (s *Service) addMethod(m *Method) error {
+	meth := &method{Method: m}
+
+	// The request message is the path parameters followed by the body
+	req := &message{name: m.Name + "Request"}
+	for _, match := range pathParamRegex.FindAllStringSubmatch(m.Path, -1) {
+		meth.params = append(meth.params, match[1])
+		req.fields = append(req.fields, &field{name: protoName(match[1]), jsonName: match[1], scalar: scalarString})
+	}
+	if m.Request != nil {
+		t := structType(m.Request)
+		if t.Kind() != reflect.Struct {
+			return fmt.Errorf("Request type %s is not a struct", t)
+		}
+		fields, err := s.schema.structFields(t)
+		if err != nil {
+			return err
+		}
+		req.fields = append(req.fields, fields...)
+	}
+	seen := make(map[string]struct{})
+	for _, f := range req.fields {
+		if _, ok := seen[f.name]; ok {
+			return fmt.Errorf("Duplicate field %s in request", f.name)
+		}
+		seen[f.name] = struct{}{}
+	}
+	if err := s.schema.add(req); err != nil {
+		return err
+	}
+
+	switch {
+	case m.ListField != "":
+		t := reflect.TypeOf(m.Response)
+		if t == nil || t.Kind() != reflect.Slice {
+			return fmt.Errorf("Response type for a list must be a slice")
+		}
+		f, err := s.schema.fieldFor(t)
+		if err != nil {
+			return err
+		}
+		f.name, f.jsonName = protoName(m.ListField), m.ListField
+		if err := s.schema.add(&message{name: m.Name + "Response", fields: []*field{f}}); err != nil {
+			return err
+		}
+	case m.Response == nil:
+		if _, err := s.schema.messageFor(reflect.TypeOf(OKResponse{})); err != nil {
+			return err
+		}
+	default:
+		t := structType(m.Response)
+		if t.Kind() != reflect.Struct {
+			return fmt.Errorf("Response type %s is not a struct", t)
+		}
+		if _, err := s.schema.messageFor(t); err != nil {
+			return err
+		}
+	}
+
+	s.methods = append(s.methods, meth)
+	return nil
+}
+
+// typeName returns the fully-qualified name of a message referenced by a field.
+func (s *Service) typeName(name string) string {
+	if strings.HasPrefix(name, ".") {
+		return name
+	}
+	return "." + s.pkg + "." + name
+}
+
+// fileDescriptorProto builds the descriptor for the service and its messages.
+func (s *Service) fileDescriptorProto() *descriptorpb.FileDescriptorProto {
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String(strings.Replace(s.pkg, ".", "/", -1) + "/" + strings.ToLower(s.name) + ".proto"),
+		Package:    proto.String(s.pkg),
+		Dependency: []string{"google/protobuf/struct.proto"},
+		Syntax:     proto.String("proto3"),
+	}
+	for _, msg := range s.schema.messages {
+		fdp.MessageType = append(fdp.MessageType, s.messageDescriptorProto(msg))
+	}
+	svc := &descriptorpb.ServiceDescriptorProto{Name: proto.String(s.name)}
+	for _, m := range s.methods {
+		svc.Method = append(svc.Method, &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(m.Name),
+			InputType:  proto.String(s.typeName(m.Name + "Request")),
+			OutputType: proto.String(s.typeName(s.responseName(m))),
+		})
+	}
+	fdp.Service = []*descriptorpb.ServiceDescriptorProto{svc}
+	return fdp
+}
+
+func (s *Service) messageDescriptorProto(msg *message) *descriptorpb.DescriptorProto {
+	dp := &descriptorpb.DescriptorProto{Name: proto.String(msg.name)}
+	for _, f := range msg.fields {
+		fdp := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(f.name),
+			JsonName: proto.String(f.jsonName),
+			Number:   proto.Int32(f.number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if f.repeated || f.isMap {
+			fdp.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		}
+		if f.isMap {
+			entry := &descriptorpb.DescriptorProto{
+				Name:    proto.String(mapEntryName(f.name)),
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("key"),
+						JsonName: proto.String("key"),
+						Number:   proto.Int32(1),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					},
+					s.setFieldType(&descriptorpb.FieldDescriptorProto{
+						Name:     proto.String("value"),
+						JsonName: proto.String("value"),
+						Number:   proto.Int32(2),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					}, f),
+				},
+			}
+			dp.NestedType = append(dp.NestedType, entry)
+			fdp.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+			fdp.TypeName = proto.String(s.typeName(msg.name + "." + mapEntryName(f.name)))
+		} else {
+			s.setFieldType(fdp, f)
+		}
+		dp.Field = append(dp.Field, fdp)
+	}
+	return dp
+}
+
+// setFieldType sets the type of the given descriptor to the type of f.
+func (s *Service) setFieldType(fdp *descriptorpb.FieldDescriptorProto, f *field) *descriptorpb.FieldDescriptorProto {
+	if f.message != "" {
+		fdp.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		fdp.TypeName = proto.String(s.typeName(f.message))
+		return fdp
+	}
+	fdp.Type = scalarTypes[f.scalar].Enum()
+	return fdp
+}
+
+var scalarTypes = map[scalar]descriptorpb.FieldDescriptorProto_Type{
+	scalarString: descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	scalarBool:   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	scalarInt32:  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	scalarInt64:  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	scalarUint32: descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	scalarUint64: descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	scalarFloat:  descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	scalarDouble: descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	scalarBytes:  descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+}
+
+// mapEntryName returns the name of the entry message for a map field, following
+// the naming used by protoc.
+func mapEntryName(fieldName string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range fieldName {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + "Entry"
+}
+
+// Proto returns the service and its messages rendered as a .proto file.
+func (s *Service) Proto() string {
+	var b strings.Builder
+	b.WriteString("// Code generated by hack/protogen. DO NOT EDIT.\n\n")
+	b.WriteString("syntax = \"proto3\";\n\n")
+	fmt.Fprintf(&b, "package %s;\n\n", s.pkg)
+	b.WriteString("import \"google/protobuf/struct.proto\";\n\n")
+
+	fmt.Fprintf(&b, "service %s {\n", s.name)
+	for i, m := range s.methods {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if m.Description != "" {
+			fmt.Fprintf(&b, "  // %s\n", m.Description)
+		}
+		fmt.Fprintf(&b, "  // Served by %s %s\n", m.HTTPMethod, m.Path)
+		fmt.Fprintf(&b, "  rpc %s(%sRequest) returns (%s);\n", m.Name, m.Name, s.responseName(m))
+	}
+	b.WriteString("}\n")
+
+	// render messages sorted by name for a stable diff when types change
+	messages := make([]*message, len(s.schema.messages))
+	copy(messages, s.schema.messages)
+	sort.Slice(messages, func(i, j int) bool { return messages[i].name < messages[j].name })
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "\nmessage %s {\n", msg.name)
+		for _, f := range msg.fields {
+			fmt.Fprintf(&b, "  %s %s = %d", f.typeName(), f.name, f.number)
+			if f.jsonName != f.name {
+				fmt.Fprintf(&b, " [json_name = %q]", f.jsonName)
+			}
+			b.WriteString(";\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,144 @@
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GetAuditSinks returns the sinks audit events are forwarded to.
+func (c *VDICluster) GetAuditSinks() []AuditSinkConfig {
+	if c.Spec.App != nil && c.Spec.App.Audit != nil {
+		return c.Spec.App.Audit.Sinks
+	}
+	return nil
+}
+
+// Validate returns an error if the sink does not have exactly one destination
+// configured, or if the destination is missing its address.
+func (s *AuditSinkConfig) Validate() error {
+	if s.Name == "" {
+		return errors.New("Audit sinks require a name")
+	}
+	var count int
+	for _, set := range []bool{s.Syslog != nil, s.Splunk != nil, s.Webhook != nil} {
+		if set {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("Audit sink %s must set exactly one of syslog, splunk, or webhook", s.Name)
+	}
+	switch {
+	case s.Syslog != nil && s.Syslog.Address == "":
+		return fmt.Errorf("No address configured for audit sink %s", s.Name)
+	case s.Splunk != nil && s.Splunk.URL == "":
+		return fmt.Errorf("No url configured for audit sink %s", s.Name)
+	case s.Webhook != nil && s.Webhook.URL == "":
+		return fmt.Errorf("No url configured for audit sink %s", s.Name)
+	}
+	return nil
+}
+
+// GetBatchSize returns the maximum number of events to send at once.
+func (s *AuditSinkConfig) GetBatchSize() int {
+	if s.BatchSize > 0 {
+		return s.BatchSize
+	}
+	return 100
+}
+
+// GetFlushInterval returns how long to wait before sending a batch that is not full.
+func (s *AuditSinkConfig) GetFlushInterval() time.Duration {
+	if s.FlushInterval != "" {
+		if dur, err := time.ParseDuration(s.FlushInterval); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return time.Second
+}
+
+// GetMaxRetries returns how many times to retry sending a batch before dropping it.
+func (s *AuditSinkConfig) GetMaxRetries() int {
+	if s.MaxRetries != nil && *s.MaxRetries >= 0 {
+		return int(*s.MaxRetries)
+	}
+	return 5
+}
+
+// GetQueueSize returns the number of events that can be waiting to be sent.
+func (s *AuditSinkConfig) GetQueueSize() int {
+	if s.QueueSize > 0 {
+		return s.QueueSize
+	}
+	return 10000
+}
+
+// GetProtocol returns the protocol to send events to the syslog server with.
+func (s *AuditSyslogConfig) GetProtocol() AuditSyslogProtocol {
+	if s.Protocol != "" {
+		return s.Protocol
+	}
+	return AuditSyslogTCP
+}
+
+// GetFacility returns the syslog facility to send events with.
+func (s *AuditSyslogConfig) GetFacility() int {
+	if s.Facility != nil && *s.Facility >= 0 && *s.Facility <= 23 {
+		return int(*s.Facility)
+	}
+	return 13
+}
+
+// GetTag returns the app name to send events with.
+func (s *AuditSyslogConfig) GetTag() string {
+	if s.Tag != "" {
+		return s.Tag
+	}
+	return "kvdi"
+}
+
+// GetTokenKey returns the key in the secrets backend where the HEC token is stored.
+func (s *AuditSplunkConfig) GetTokenKey() string {
+	if s.TokenKey != "" {
+		return s.TokenKey
+	}
+	return "splunk-hec-token"
+}
+
+// GetSourceType returns the sourcetype to set on events.
+func (s *AuditSplunkConfig) GetSourceType() string {
+	if s.SourceType != "" {
+		return s.SourceType
+	}
+	return "kvdi:audit"
+}
+
+// GetEventURL returns the URL of the event endpoint of the collector.
+func (s *AuditSplunkConfig) GetEventURL() string {
+	if strings.Contains(s.URL, "/services/collector") {
+		return s.URL
+	}
+	return strings.TrimSuffix(s.URL, "/") + "/services/collector/event"
+}
+
+// GetTimeout returns the timeout for requests to the collector.
+func (s *AuditSplunkConfig) GetTimeout() time.Duration {
+	if s.Timeout != "" {
+		if dur, err := time.ParseDuration(s.Timeout); err == nil {
+			return dur
+		}
+	}
+	return 5 * time.Second
+}
+
+// GetTimeout returns the timeout for requests to the webhook.
+func (w *AuditWebhookConfig) GetTimeout() time.Duration {
+	if w.Timeout != "" {
+		if dur, err := time.ParseDuration(w.Timeout); err == nil {
+			return dur
+		}
+	}
+	return 5 * time.Second
+}
@@ -0,0 +1,69 @@
+package v1alpha1
+
+import (
+	"encoding/base64"
+)
+
+// IsUsingCertAuth returns true if the cluster is using the client certificate
+// authentication driver.
+func (c *VDICluster) IsUsingCertAuth() bool {
+	if c.Spec.Auth != nil {
+		if c.Spec.Auth.CertAuth != nil && !c.Spec.Auth.CertAuth.IsUndefined() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCertClientCA returns the CA bundle to use when verifying client certificates.
+// The value is base64 decoded and returned to the caller.
+func (c *VDICluster) GetCertClientCA() ([]byte, error) {
+	if c.Spec.Auth != nil && c.Spec.Auth.CertAuth != nil {
+		return base64.StdEncoding.DecodeString(c.Spec.Auth.CertAuth.ClientCACert)
+	}
+	return nil, nil
+}
+
+// GetCertTrustedHeader returns the header to read client certificates from, or an
+// empty string if they are read from the TLS connection.
+func (c *VDICluster) GetCertTrustedHeader() string {
+	if c.Spec.Auth != nil && c.Spec.Auth.CertAuth != nil {
+		return c.Spec.Auth.CertAuth.TrustedHeader
+	}
+	return ""
+}
+
+// RequestClientCerts returns true if the app should request client certificates
+// during the TLS handshake.
+func (c *VDICluster) RequestClientCerts() bool {
+	return c.IsUsingCertAuth() && c.GetCertTrustedHeader() == ""
+}
+
+// GetCertUsernameAttribute returns the attribute of client certificates to use for
+// usernames.
+func (c *VDICluster) GetCertUsernameAttribute() CertUsernameAttribute {
+	if c.Spec.Auth != nil && c.Spec.Auth.CertAuth != nil {
+		if c.Spec.Auth.CertAuth.UsernameAttribute != "" {
+			return c.Spec.Auth.CertAuth.UsernameAttribute
+		}
+	}
+	return CertUsernameCN
+}
+
+// GetCertAdminGroups returns the certificate attributes that will map to administrator
+// access.
+func (c *VDICluster) GetCertAdminGroups() []string {
+	if c.Spec.Auth != nil && c.Spec.Auth.CertAuth != nil {
+		return c.Spec.Auth.CertAuth.AdminGroups
+	}
+	return []string{}
+}
+
+// AllowNonGroupedCertReadOnly returns true if users whose certificates are not bound
+// to any roles should be allowed read-only access to kVDI.
+func (c *VDICluster) AllowNonGroupedCertReadOnly() bool {
+	if c.Spec.Auth != nil && c.Spec.Auth.CertAuth != nil {
+		return c.Spec.Auth.CertAuth.AllowNonGroupedReadOnly
+	}
+	return false
+}
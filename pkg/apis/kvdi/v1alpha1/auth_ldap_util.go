@@ -3,6 +3,7 @@ package v1alpha1
 import (
 	"encoding/base64"
 	"strings"
+	"time"
 )
 
 // IsUsingLDAPAuth returns true if the cluster is using the ldap authentication
@@ -24,18 +25,75 @@ func (c *VDICluster) GetLDAPURL() string {
 	return ""
 }
 
-// IsUsingLDAPOverTLS returns true if the configured LDAP server is using TLS.
+// GetLDAPURLs returns the URLs of all the configured LDAP servers, starting with
+// the primary one.
+func (c *VDICluster) GetLDAPURLs() []string {
+	urls := make([]string, 0)
+	if c.Spec.Auth == nil || c.Spec.Auth.LDAPAuth == nil {
+		return urls
+	}
+	seen := make(map[string]struct{})
+	for _, u := range append([]string{c.Spec.Auth.LDAPAuth.URL}, c.Spec.Auth.LDAPAuth.URLs...) {
+		if _, ok := seen[u]; ok || u == "" {
+			continue
+		}
+		seen[u] = struct{}{}
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// IsUsingLDAPOverTLS returns true if any of the configured LDAP servers are using TLS.
 func (c *VDICluster) IsUsingLDAPOverTLS() bool {
-	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil {
-		if c.Spec.Auth.LDAPAuth.URL != "" {
-			if strings.HasPrefix(c.Spec.Auth.LDAPAuth.URL, "ldaps") {
-				return true
-			}
+	for _, u := range c.GetLDAPURLs() {
+		if strings.HasPrefix(u, "ldaps") {
+			return true
 		}
 	}
 	return false
 }
 
+// LDAPRoundRobinEnabled returns true if new connections should be spread across
+// all of the configured LDAP servers.
+func (c *VDICluster) LDAPRoundRobinEnabled() bool {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil {
+		return c.Spec.Auth.LDAPAuth.RoundRobin
+	}
+	return false
+}
+
+// GetLDAPHealthCheckInterval returns how long an unreachable LDAP server is skipped
+// before it is tried again.
+func (c *VDICluster) GetLDAPHealthCheckInterval() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.HealthCheckInterval != "" {
+		if dur, err := time.ParseDuration(c.Spec.Auth.LDAPAuth.HealthCheckInterval); err == nil {
+			return dur
+		}
+	}
+	return 30 * time.Second
+}
+
+// GetLDAPConnectTimeout returns how long to wait when connecting to an LDAP server.
+func (c *VDICluster) GetLDAPConnectTimeout() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.ConnectTimeout != "" {
+		if dur, err := time.ParseDuration(c.Spec.Auth.LDAPAuth.ConnectTimeout); err == nil {
+			return dur
+		}
+	}
+	return 5 * time.Second
+}
+
+// GetLDAPSearchTimeout returns how long to wait for the response to a request to
+// an LDAP server.
+func (c *VDICluster) GetLDAPSearchTimeout() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.SearchTimeout != "" {
+		if dur, err := time.ParseDuration(c.Spec.Auth.LDAPAuth.SearchTimeout); err == nil {
+			return dur
+		}
+	}
+	return 30 * time.Second
+}
+
 // GetLDAPUserDNKey returns the key in the secret where the bind DN can be retrieved.
 func (c *VDICluster) GetLDAPUserDNKey() string {
 	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil {
@@ -91,3 +149,171 @@ func (c *VDICluster) GetLDAPAdminGroups() []string {
 	}
 	return []string{}
 }
+
+// IsUsingActiveDirectory returns true if the configured LDAP server is Active Directory.
+func (c *VDICluster) IsUsingActiveDirectory() bool {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil {
+		return c.Spec.Auth.LDAPAuth.ActiveDirectory
+	}
+	return false
+}
+
+// GetLDAPUserFilter returns the filter users must match in addition to their
+// username when they are looked up.
+func (c *VDICluster) GetLDAPUserFilter() string {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.UserFilter != "" {
+		return c.Spec.Auth.LDAPAuth.UserFilter
+	}
+	if c.IsUsingActiveDirectory() {
+		return "(&(objectCategory=person)(objectClass=user))"
+	}
+	return ""
+}
+
+// getLDAPAttributes returns the attribute overrides for the LDAP provider.
+func (c *VDICluster) getLDAPAttributes() *LDAPAttributeConfig {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.Attributes != nil {
+		return c.Spec.Auth.LDAPAuth.Attributes
+	}
+	return &LDAPAttributeConfig{}
+}
+
+// GetLDAPUsernameAttribute returns the attribute users log in with.
+func (c *VDICluster) GetLDAPUsernameAttribute() string {
+	if attr := c.getLDAPAttributes().Username; attr != "" {
+		return attr
+	}
+	if c.IsUsingActiveDirectory() {
+		return "sAMAccountName"
+	}
+	return "uid"
+}
+
+// GetLDAPMemberOfAttribute returns the attribute listing the groups an entry is
+// a member of.
+func (c *VDICluster) GetLDAPMemberOfAttribute() string {
+	if attr := c.getLDAPAttributes().MemberOf; attr != "" {
+		return attr
+	}
+	return "memberOf"
+}
+
+// GetLDAPAccountStatusAttribute returns the attribute holding the status of an
+// account. An empty string is returned when the Active Directory account flags
+// should be checked instead.
+func (c *VDICluster) GetLDAPAccountStatusAttribute() string {
+	if attr := c.getLDAPAttributes().AccountStatus; attr != "" {
+		return attr
+	}
+	if c.IsUsingActiveDirectory() {
+		return ""
+	}
+	return "accountStatus"
+}
+
+// GetLDAPAccountStatusActiveValues returns the values of the account status
+// attribute that allow an account to log in.
+func (c *VDICluster) GetLDAPAccountStatusActiveValues() []string {
+	if values := c.getLDAPAttributes().AccountStatusActiveValues; len(values) > 0 {
+		return values
+	}
+	return []string{"active"}
+}
+
+// GetLDAPMailAttribute returns the attribute holding the email address of a user.
+func (c *VDICluster) GetLDAPMailAttribute() string {
+	if attr := c.getLDAPAttributes().Mail; attr != "" {
+		return attr
+	}
+	return "mail"
+}
+
+// GetLDAPDisplayNameAttribute returns the attribute holding the display name of
+// a user.
+func (c *VDICluster) GetLDAPDisplayNameAttribute() string {
+	if attr := c.getLDAPAttributes().DisplayName; attr != "" {
+		return attr
+	}
+	return "displayName"
+}
+
+// LDAPNestedGroupsEnabled returns true if nested group membership should be
+// resolved by recursively searching the LDAP server.
+func (c *VDICluster) LDAPNestedGroupsEnabled() bool {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil {
+		return c.Spec.Auth.LDAPAuth.NestedGroups
+	}
+	return false
+}
+
+// GetLDAPMaxGroupDepth returns the maximum depth to follow nested groups.
+func (c *VDICluster) GetLDAPMaxGroupDepth() int {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil {
+		if c.Spec.Auth.LDAPAuth.MaxGroupDepth > 0 {
+			return int(c.Spec.Auth.LDAPAuth.MaxGroupDepth)
+		}
+	}
+	return 10
+}
+
+// GetLDAPPoolSize returns the number of idle connections to keep open to the
+// LDAP server.
+func (c *VDICluster) GetLDAPPoolSize() int {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.Pool != nil {
+		if c.Spec.Auth.LDAPAuth.Pool.Size > 0 {
+			return int(c.Spec.Auth.LDAPAuth.Pool.Size)
+		}
+	}
+	return 10
+}
+
+// GetLDAPPoolIdleTimeout returns how long connections to the LDAP server may
+// sit idle before they are closed.
+func (c *VDICluster) GetLDAPPoolIdleTimeout() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.Pool != nil {
+		if c.Spec.Auth.LDAPAuth.Pool.IdleTimeout != "" {
+			if dur, err := time.ParseDuration(c.Spec.Auth.LDAPAuth.Pool.IdleTimeout); err == nil {
+				return dur
+			}
+		}
+	}
+	return 5 * time.Minute
+}
+
+// LDAPReferralsEnabled returns true if referrals returned by the LDAP server should
+// be followed.
+func (c *VDICluster) LDAPReferralsEnabled() bool {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.Referrals != nil {
+		return c.Spec.Auth.LDAPAuth.Referrals.Follow
+	}
+	return false
+}
+
+// GetLDAPReferralMaxHops returns the maximum number of hops to follow away from
+// the configured LDAP server.
+func (c *VDICluster) GetLDAPReferralMaxHops() int {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.Referrals != nil {
+		if c.Spec.Auth.LDAPAuth.Referrals.MaxHops > 0 {
+			return int(c.Spec.Auth.LDAPAuth.Referrals.MaxHops)
+		}
+	}
+	return 3
+}
+
+// GetLDAPReferralAllowedHosts returns the hosts that referrals may be followed to.
+// An empty list means all hosts are allowed.
+func (c *VDICluster) GetLDAPReferralAllowedHosts() []string {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.Referrals != nil {
+		return c.Spec.Auth.LDAPAuth.Referrals.AllowedHosts
+	}
+	return []string{}
+}
+
+// GetLDAPReferralTrustedHosts returns the referred hosts that are trusted with
+// credentials.
+func (c *VDICluster) GetLDAPReferralTrustedHosts() []string {
+	if c.Spec.Auth != nil && c.Spec.Auth.LDAPAuth != nil && c.Spec.Auth.LDAPAuth.Referrals != nil {
+		return c.Spec.Auth.LDAPAuth.Referrals.TrustedHosts
+	}
+	return []string{}
+}
@@ -0,0 +1,61 @@
+package v1alpha1
+
+import "time"
+
+// GetMFAProvider returns the provider users authorize with after logging in.
+func (c *VDICluster) GetMFAProvider() MFAProvider {
+	if c.Spec.Auth != nil && c.Spec.Auth.MFA != nil && c.Spec.Auth.MFA.Provider != "" {
+		return c.Spec.Auth.MFA.Provider
+	}
+	return MFAProviderTOTP
+}
+
+// IsUsingPushMFA returns true if users authorize by approving a push notification
+// instead of with a one-time password.
+func (c *VDICluster) IsUsingPushMFA() bool {
+	return c.GetMFAProvider() != MFAProviderTOTP
+}
+
+// GetDuoMFAConfig returns the configurations for the Duo MFA provider.
+func (c *VDICluster) GetDuoMFAConfig() *DuoMFAConfig {
+	if c.Spec.Auth != nil && c.Spec.Auth.MFA != nil && c.Spec.Auth.MFA.Duo != nil {
+		return c.Spec.Auth.MFA.Duo
+	}
+	return &DuoMFAConfig{}
+}
+
+// GetSecretKeyKey returns the key in the secrets backend where the Duo secret key
+// is stored.
+func (d *DuoMFAConfig) GetSecretKeyKey() string {
+	if d.SecretKeyKey != "" {
+		return d.SecretKeyKey
+	}
+	return "duo-secretkey"
+}
+
+// GetMFAWebhookConfig returns the configurations for the webhook MFA provider.
+func (c *VDICluster) GetMFAWebhookConfig() *MFAWebhookConfig {
+	if c.Spec.Auth != nil && c.Spec.Auth.MFA != nil && c.Spec.Auth.MFA.Webhook != nil {
+		return c.Spec.Auth.MFA.Webhook
+	}
+	return &MFAWebhookConfig{}
+}
+
+// GetTokenKey returns the key in the secrets backend where the bearer token for
+// the webhook is stored.
+func (m *MFAWebhookConfig) GetTokenKey() string {
+	if m.TokenKey != "" {
+		return m.TokenKey
+	}
+	return "mfa-webhook-token"
+}
+
+// GetTimeout returns the timeout for requests to the webhook.
+func (m *MFAWebhookConfig) GetTimeout() time.Duration {
+	if m.Timeout != "" {
+		if dur, err := time.ParseDuration(m.Timeout); err == nil {
+			return dur
+		}
+	}
+	return 10 * time.Second
+}
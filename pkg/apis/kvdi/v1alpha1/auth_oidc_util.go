@@ -2,6 +2,8 @@ package v1alpha1
 
 import (
 	"encoding/base64"
+	"net/url"
+	"strings"
 
 	oidc "github.com/coreos/go-oidc"
 )
@@ -66,6 +68,20 @@ func (c *VDICluster) GetOIDCGroupScope() string {
 	return "groups"
 }
 
+// GetOIDCGroupClaims returns all the claims to use for matching a user's groups to
+// VDI roles.
+func (c *VDICluster) GetOIDCGroupClaims() []string {
+	claims := []string{c.GetOIDCGroupScope()}
+	if c.Spec.Auth != nil && c.Spec.Auth.OIDCAuth != nil {
+		for _, claim := range c.Spec.Auth.OIDCAuth.RoleClaims {
+			if claim != "" && claim != claims[0] {
+				claims = append(claims, claim)
+			}
+		}
+	}
+	return claims
+}
+
 // GetOIDCAdminGroups returns the values in the groups claim that will map to administrator access.
 func (c *VDICluster) GetOIDCAdminGroups() []string {
 	if c.Spec.Auth != nil && c.Spec.Auth.OIDCAuth != nil {
@@ -110,3 +126,86 @@ func (c *VDICluster) AllowNonGroupedReadOnly() bool {
 	}
 	return false
 }
+
+// GetOIDCPKCEEnabled returns true if PKCE should be used during the authorization code flow.
+func (c *VDICluster) GetOIDCPKCEEnabled() bool {
+	if c.Spec.Auth != nil && c.Spec.Auth.OIDCAuth != nil {
+		return !c.Spec.Auth.OIDCAuth.DisablePKCE
+	}
+	return true
+}
+
+// GetOIDCTokenEndpointAuthMethod returns the method to use when authenticating to the
+// token endpoint. An empty value signals the method should be auto-detected.
+func (c *VDICluster) GetOIDCTokenEndpointAuthMethod() OIDCTokenEndpointAuthMethod {
+	if c.Spec.Auth != nil && c.Spec.Auth.OIDCAuth != nil {
+		return c.Spec.Auth.OIDCAuth.TokenEndpointAuthMethod
+	}
+	return ""
+}
+
+// GetOIDCPrivateKeyKey returns the key in the secret where the private key for signing
+// client assertions can be retrieved.
+func (c *VDICluster) GetOIDCPrivateKeyKey() string {
+	if c.Spec.Auth != nil && c.Spec.Auth.OIDCAuth != nil {
+		if c.Spec.Auth.OIDCAuth.PrivateKeyKey != "" {
+			return c.Spec.Auth.OIDCAuth.PrivateKeyKey
+		}
+	}
+	return "oidc-privatekey"
+}
+
+// GetOIDCPrivateKeyID returns the key ID to place in the headers of client assertions.
+func (c *VDICluster) GetOIDCPrivateKeyID() string {
+	if c.Spec.Auth != nil && c.Spec.Auth.OIDCAuth != nil {
+		return c.Spec.Auth.OIDCAuth.PrivateKeyID
+	}
+	return ""
+}
+
+// GetOIDCPostLogoutRedirectURL returns the URL the OIDC provider should redirect to after
+// a user logs out. It defaults to the root of the redirect URL.
+func (c *VDICluster) GetOIDCPostLogoutRedirectURL() string {
+	if c.Spec.Auth != nil && c.Spec.Auth.OIDCAuth != nil {
+		if c.Spec.Auth.OIDCAuth.PostLogoutRedirectURL != "" {
+			return c.Spec.Auth.OIDCAuth.PostLogoutRedirectURL
+		}
+	}
+	redirectURL, err := url.Parse(c.GetOIDCRedirectURL())
+	if err != nil || redirectURL.Host == "" {
+		return ""
+	}
+	return (&url.URL{Scheme: redirectURL.Scheme, Host: redirectURL.Host, Path: "/"}).String()
+}
+
+// GetOIDCProviderLogoutEnabled returns true if users should also be logged out of the
+// OIDC provider when they log out of kVDI.
+func (c *VDICluster) GetOIDCProviderLogoutEnabled() bool {
+	if c.Spec.Auth != nil && c.Spec.Auth.OIDCAuth != nil {
+		return !c.Spec.Auth.OIDCAuth.DisableProviderLogout
+	}
+	return true
+}
+
+// IsUsingAzureADAuth returns true if the cluster is using the oidc authentication
+// backend with Azure AD.
+func (c *VDICluster) IsUsingAzureADAuth() bool {
+	return c.IsUsingOIDCAuth() && c.Spec.Auth.OIDCAuth.AzureAD != nil
+}
+
+// GetAzureADGraphURL returns the base URL of Microsoft Graph.
+func (c *VDICluster) GetAzureADGraphURL() string {
+	if c.IsUsingAzureADAuth() && c.Spec.Auth.OIDCAuth.AzureAD.GraphURL != "" {
+		return strings.TrimSuffix(c.Spec.Auth.OIDCAuth.AzureAD.GraphURL, "/")
+	}
+	return "https://graph.microsoft.com"
+}
+
+// GetAzureADSecurityGroupsOnly returns true if only the security groups of users
+// should be resolved from Microsoft Graph.
+func (c *VDICluster) GetAzureADSecurityGroupsOnly() bool {
+	if c.IsUsingAzureADAuth() {
+		return c.Spec.Auth.OIDCAuth.AzureAD.SecurityGroupsOnly
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package v1alpha1
+
+import "time"
+
+// IsUsingWebhookAuth returns true if the cluster is using the webhook authentication
+// driver.
+func (c *VDICluster) IsUsingWebhookAuth() bool {
+	if c.Spec.Auth != nil {
+		if c.Spec.Auth.WebhookAuth != nil && !c.Spec.Auth.WebhookAuth.IsUndefined() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWebhookAuthConfig returns the configurations for the webhook auth provider.
+func (c *VDICluster) GetWebhookAuthConfig() *WebhookAuthConfig {
+	if c.Spec.Auth != nil && c.Spec.Auth.WebhookAuth != nil {
+		return c.Spec.Auth.WebhookAuth
+	}
+	return &WebhookAuthConfig{}
+}
+
+// GetSigningKeyKey returns the key in the secrets backend where the key for signing
+// requests to the webhook is stored.
+func (w *WebhookAuthConfig) GetSigningKeyKey() string {
+	if w.SigningKeyKey != "" {
+		return w.SigningKeyKey
+	}
+	return "auth-webhook-signing-key"
+}
+
+// GetTimeout returns the timeout for requests to the webhook.
+func (w *WebhookAuthConfig) GetTimeout() time.Duration {
+	if w.Timeout != "" {
+		if dur, err := time.ParseDuration(w.Timeout); err == nil {
+			return dur
+		}
+	}
+	return 10 * time.Second
+}
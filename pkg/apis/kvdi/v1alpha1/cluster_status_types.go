@@ -0,0 +1,20 @@
+package v1alpha1
+
+import (
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+)
+
+// ClusterStatusResponse is the status of a VDICluster as returned by the API.
+// +k8s:deepcopy-gen=false
+type ClusterStatusResponse struct {
+	// The name of the VDICluster
+	Name string `json:"name"`
+	// True when every condition is true and every component checked by the app
+	// is healthy
+	Ready bool `json:"ready"`
+	// The conditions last reported on the VDICluster by the manager
+	Conditions []VDIClusterCondition `json:"conditions"`
+	// The health of the components the app depends on, as checked by the replica
+	// serving the request
+	Health *v1.HealthReport `json:"health"`
+}
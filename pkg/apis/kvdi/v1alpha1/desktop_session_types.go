@@ -0,0 +1,47 @@
+package v1alpha1
+
+import (
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DesktopSessionStatusResponse is the status of a desktop session as returned
+// by the API.
+// +k8s:deepcopy-gen=false
+type DesktopSessionStatusResponse struct {
+	// Whether the desktop is running.
+	Running bool `json:"running"`
+	// The phase of the desktop's pod.
+	PodPhase corev1.PodPhase `json:"podPhase"`
+	// Why the desktop's pod could not be created or started, if it failed to.
+	PodError string `json:"podError,omitempty"`
+	// Whether the desktop was preempted by a higher priority desktop.
+	Preempted bool `json:"preempted"`
+	// Whether the desktop was migrated off of a drained node.
+	Drained bool `json:"drained"`
+	// Set when the node the desktop is running on is being drained.
+	Drain *v1.DrainNotice `json:"drain,omitempty"`
+	// The bytes used on the desktop's ephemeral storage.
+	DiskUsedBytes int64 `json:"diskUsedBytes,omitempty"`
+	// The limit of the desktop's ephemeral storage.
+	DiskLimitBytes int64 `json:"diskLimitBytes,omitempty"`
+	// Whether the desktop is close to its ephemeral storage limit.
+	DiskPressure bool `json:"diskPressure"`
+	// When the desktop will be destroyed for reaching its maximum lifetime or the
+	// end of its template's availability window.
+	TerminatesAt *time.Time `json:"terminatesAt,omitempty"`
+	// Why the desktop will be destroyed.
+	TerminationReason TerminationReason `json:"terminationReason,omitempty"`
+	// Whether the termination is less than 15 minutes away.
+	TerminationPending bool `json:"terminationPending"`
+	// The clipboard policy of the desktop's template.
+	Clipboard ClipboardPolicy `json:"clipboard,omitempty"`
+	// The file transfer policy of the desktop's template.
+	FileTransfer FileTransferPolicy `json:"fileTransfer,omitempty"`
+	// Whether the desktop's template accepts microphone input on the audio stream.
+	Microphone bool `json:"microphone"`
+	// How far the desktop has progressed towards serving display connections.
+	Phase DesktopPhase `json:"phase,omitempty"`
+}
@@ -14,6 +14,31 @@ type DesktopSpec struct {
 	Template string `json:"template"`
 	// The username to use inside the instance, defaults to `anonymous`.
 	User string `json:"user,omitempty"`
+	// The SessionPool this instance was booted for. Pooled instances are booted
+	// before they are claimed by a user, and always use the `anonymous` user inside
+	// the instance.
+	SessionPool string `json:"sessionPool,omitempty"`
+	// The values of the template parameters chosen for this instance. Parameters
+	// that are not set use their default value.
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// The name of a VolumeSnapshot in the instance's namespace to restore the user's
+	// userdata volume from. The volume the user had before is retained, but is no
+	// longer used for their desktops.
+	Snapshot string `json:"snapshot,omitempty"`
+	// A revision of the template to pin this instance to. The instance keeps using
+	// this revision when the template is updated or rolled back. Defaults to the
+	// current revision.
+	TemplateRevision int64 `json:"templateRevision,omitempty"`
+	// Node labels the instance must be scheduled with, in addition to the ones from
+	// its template. Set from the placement policies of the user's roles at launch.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Environment variables the user set in the instance at launch. They must be
+	// allowed by the `sessionEnv` of the template.
+	Env map[string]string `json:"env,omitempty"`
+	// Environment variables to set in the instance from the user's secrets, mapped
+	// to the name of the secret. They are added to the `sessionEnv.userSecrets` of
+	// the template.
+	SecretEnv map[string]string `json:"secretEnv,omitempty"`
 }
 
 // DesktopStatus defines the observed state of Desktop
@@ -21,8 +46,59 @@ type DesktopStatus struct {
 	// Whether the instance is running and resolvable within the cluster.
 	Running  bool            `json:"running,omitempty"`
 	PodPhase corev1.PodPhase `json:"podPhase,omitempty"`
+	// How far the instance has progressed towards serving display connections.
+	Phase DesktopPhase `json:"phase,omitempty"`
+	// Why the instance's pod could not be created or started, such as an admission
+	// controller rejecting it or a security profile that is not loaded on its node.
+	PodError string `json:"podError,omitempty"`
+	// Whether the node the instance was running on received a preemption notice.
+	// Preempted instances are relaunched onto stable capacity.
+	Preempted bool `json:"preempted,omitempty"`
+	// The node the instance was drained from. Drained instances are relaunched
+	// onto other nodes.
+	DrainedFrom string `json:"drainedFrom,omitempty"`
+	// The last time a display or audio connection to the instance was opened or
+	// closed. Used to destroy idle instances.
+	LastActivity *metav1.Time `json:"lastActivity,omitempty"`
+	// The time the instance will be destroyed for reaching its maximum lifetime or
+	// the close of its template's availability window.
+	TerminatesAt *metav1.Time `json:"terminatesAt,omitempty"`
+	// The reason the instance will be destroyed at `terminatesAt`.
+	TerminationReason TerminationReason `json:"terminationReason,omitempty"`
+	// The revision of the template the instance is running.
+	TemplateRevision int64 `json:"templateRevision,omitempty"`
 }
 
+// DesktopPhase represents how far a desktop instance has progressed towards
+// serving display connections.
+type DesktopPhase string
+
+const (
+	// DesktopPhaseScheduling means the instance's pod is waiting to be created or
+	// scheduled onto a node.
+	DesktopPhaseScheduling DesktopPhase = "Scheduling"
+	// DesktopPhasePullingImage means the instance's pod is scheduled and its images
+	// are being pulled or its containers created.
+	DesktopPhasePullingImage DesktopPhase = "PullingImage"
+	// DesktopPhaseBootingDisplay means the instance's containers are running but its
+	// display is not yet accepting connections.
+	DesktopPhaseBootingDisplay DesktopPhase = "BootingDisplay"
+	// DesktopPhaseReady means the instance's display is accepting connections.
+	DesktopPhaseReady DesktopPhase = "Ready"
+)
+
+// TerminationReason represents why a desktop instance will be destroyed.
+type TerminationReason string
+
+const (
+	// TerminationReasonMaxLifetime means the instance will reach the maximum lifetime
+	// configured for its template.
+	TerminationReasonMaxLifetime TerminationReason = "MaxLifetime"
+	// TerminationReasonAvailability means the availability window of the instance's
+	// template will close.
+	TerminationReasonAvailability TerminationReason = "AvailabilityWindow"
+)
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // Desktop is the Schema for the desktops API
@@ -2,19 +2,68 @@ package v1alpha1
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// GetTemplate retrieves the DesktopTemplate for this Desktop instance.
+// GetTemplate retrieves the DesktopTemplate for this Desktop instance. Any base
+// templates are resolved and merged into the returned template, and the parameters
+// chosen for this instance are applied to it.
 func (d *Desktop) GetTemplate(c client.Client) (*DesktopTemplate, error) {
+	tmpl, _, err := d.GetTemplateAndRevision(c)
+	return tmpl, err
+}
+
+// GetTemplateAndRevision is like GetTemplate, but also returns the revision of the
+// template the instance uses. Instances pinned to a revision, or that were booted
+// from a template with the `keep` update policy, use the recorded spec of their
+// revision instead of the current one.
+func (d *Desktop) GetTemplateAndRevision(c client.Client) (*DesktopTemplate, int64, error) {
 	nn := types.NamespacedName{Name: d.Spec.Template, Namespace: metav1.NamespaceAll}
 	found := &DesktopTemplate{}
-	return found, c.Get(context.TODO(), nn, found)
+	if err := c.Get(context.TODO(), nn, found); err != nil {
+		return nil, 0, err
+	}
+	revision := found.GetRevision()
+	if pinned := d.getTemplateRevision(found); pinned != 0 && pinned != revision {
+		cluster, err := d.GetVDICluster(c)
+		if err != nil {
+			return nil, 0, err
+		}
+		spec, err := found.GetRevisionSpec(c, cluster.GetCoreNamespace(), pinned)
+		if err != nil {
+			return nil, 0, err
+		}
+		found.Spec = *spec
+		revision = pinned
+	}
+	tmpl, err := found.GetEffectiveTemplate(c)
+	if err != nil {
+		return nil, 0, err
+	}
+	tmpl, err = tmpl.ApplyParameters(d.Spec.Parameters)
+	return tmpl, revision, err
+}
+
+// getTemplateRevision returns the revision of the given template this instance
+// should use, or zero for the current one.
+func (d *Desktop) getTemplateRevision(tmpl *DesktopTemplate) int64 {
+	if d.Spec.TemplateRevision != 0 {
+		return d.Spec.TemplateRevision
+	}
+	if tmpl.GetSessionUpdatePolicy() == SessionUpdateKeep {
+		return d.Status.TemplateRevision
+	}
+	return 0
 }
 
 // GetVDICluster retrieves the VDICluster for this Desktop instance
@@ -32,6 +81,110 @@ func (d *Desktop) GetUser() string {
 	return d.Spec.User
 }
 
+// IsWarm returns true if this instance was booted for a SessionPool and has not
+// been claimed by a user yet.
+func (d *Desktop) IsWarm() bool {
+	return d.Spec.SessionPool != "" && d.Spec.User == ""
+}
+
+// IsActive returns true if this instance is not being deleted and its pod has
+// not failed or exited.
+func (d *Desktop) IsActive() bool {
+	if d.GetDeletionTimestamp() != nil {
+		return false
+	}
+	return d.Status.PodPhase != corev1.PodFailed && d.Status.PodPhase != corev1.PodSucceeded
+}
+
+// GetUserDataSecretName returns the name of the secret holding the first-boot
+// script for this instance.
+func (d *Desktop) GetUserDataSecretName() string {
+	return fmt.Sprintf("%s-userdata", d.GetName())
+}
+
+// DotfilesEnabled returns true if the user's dotfiles should be cloned into the
+// instance's home directory.
+func (d *Desktop) DotfilesEnabled() bool {
+	_, ok := d.GetAnnotations()[v1.DotfilesAnnotation]
+	return ok
+}
+
+// GetDotfilesSecretName returns the name of the secret holding the dotfiles
+// repository configuration for this instance.
+func (d *Desktop) GetDotfilesSecretName() string {
+	return fmt.Sprintf("%s-dotfiles", d.GetName())
+}
+
+// GetSessionEnvSecretName returns the name of the secret holding the values of
+// environment variables set from the user's secrets for this instance.
+func (d *Desktop) GetSessionEnvSecretName() string {
+	return fmt.Sprintf("%s-env", d.GetName())
+}
+
+// GetDisplayLockName returns the name of the lock held while a display connection
+// to this instance is open.
+func (d *Desktop) GetDisplayLockName() string {
+	return fmt.Sprintf("display-%s-%s", d.GetNamespace(), d.GetName())
+}
+
+// GetAudioLockName returns the name of the lock held while an audio connection
+// to this instance is open.
+func (d *Desktop) GetAudioLockName() string {
+	return fmt.Sprintf("audio-%s-%s", d.GetNamespace(), d.GetName())
+}
+
+// GetDrainNotice returns the drain notice for the node this instance is running on,
+// or nil if the node is not being drained.
+func (d *Desktop) GetDrainNotice() *v1.DrainNotice {
+	raw, ok := d.GetAnnotations()[v1.DrainAnnotation]
+	if !ok {
+		return nil
+	}
+	notice := &v1.DrainNotice{}
+	if err := json.Unmarshal([]byte(raw), notice); err != nil {
+		return nil
+	}
+	return notice
+}
+
+// GetSessionStart returns the time the session in this instance started. This is
+// when it was claimed for desktops from a SessionPool, and when it was created
+// otherwise.
+func (d *Desktop) GetSessionStart() time.Time {
+	if raw, ok := d.GetAnnotations()[v1.ClaimedAtAnnotation]; ok {
+		if claimed, err := time.Parse(time.RFC3339, raw); err == nil {
+			return claimed
+		}
+	}
+	return d.GetCreationTimestamp().Time
+}
+
+// GetUserRoles returns the names of the roles held by the user of this instance
+// when it was launched.
+func (d *Desktop) GetUserRoles() []string {
+	raw, ok := d.GetAnnotations()[v1.UserRolesAnnotation]
+	if !ok || raw == "" {
+		return nil
+	}
+	return strings.Split(raw, v1.AuthGroupSeparator)
+}
+
+// GetNodeSelector returns the node selector for the instance's pod, combining the
+// one from its template with the one set from the placement policies of its user.
+func (d *Desktop) GetNodeSelector(tmpl *DesktopTemplate) map[string]string {
+	if len(d.Spec.NodeSelector) == 0 {
+		return tmpl.GetDesktopNodeSelector()
+	}
+	selector := make(map[string]string)
+	for key, val := range tmpl.GetDesktopNodeSelector() {
+		selector[key] = val
+	}
+	for key, val := range d.Spec.NodeSelector {
+		selector[key] = val
+	}
+	return selector
+}
+
 // OwnerReferences returns an owner reference slice with this Desktop
 // instance as the owner.
 func (d *Desktop) OwnerReferences() []metav1.OwnerReference {
@@ -46,3 +199,10 @@ func (d *Desktop) OwnerReferences() []metav1.OwnerReference {
 		},
 	}
 }
+
+// JSON returns the JSON representation of the status, or an empty slice if it
+// cannot be marshaled.
+func (d *DesktopSessionStatusResponse) JSON() []byte {
+	out, _ := json.Marshal(d)
+	return out
+}
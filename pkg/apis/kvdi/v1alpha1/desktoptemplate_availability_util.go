@@ -0,0 +1,81 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/cron"
+)
+
+// maxWindowDuration is the longest an availability window can stay open.
+const maxWindowDuration = 7 * 24 * time.Hour
+
+// HasAvailabilityWindows returns true if launching desktops from this template is
+// restricted to availability windows.
+func (t *DesktopTemplate) HasAvailabilityWindows() bool {
+	return t.Spec.Availability != nil && len(t.Spec.Availability.Windows) > 0
+}
+
+// TerminateSessionsOutsideWindows returns true if running desktops booted from this
+// template should be destroyed when their availability window closes.
+func (t *DesktopTemplate) TerminateSessionsOutsideWindows() bool {
+	return t.HasAvailabilityWindows() && t.Spec.Availability.TerminateSessions
+}
+
+// GetAvailabilityLocation returns the location to evaluate availability windows in.
+func (t *DesktopTemplate) GetAvailabilityLocation() (*time.Location, error) {
+	if t.Spec.Availability != nil && t.Spec.Availability.TimeZone != "" {
+		return time.LoadLocation(t.Spec.Availability.TimeZone)
+	}
+	return time.UTC, nil
+}
+
+// GetAvailability returns whether desktops can be launched from this template at the
+// given time, and if so, when the currently open window closes. When multiple windows
+// are open the latest closing time is returned. Templates without availability windows
+// are always available and return a zero closing time.
+func (t *DesktopTemplate) GetAvailability(now time.Time) (available bool, closes time.Time, err error) {
+	if !t.HasAvailabilityWindows() {
+		return true, time.Time{}, nil
+	}
+	loc, err := t.GetAvailabilityLocation()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	now = now.In(loc)
+	for _, window := range t.Spec.Availability.Windows {
+		schedule, duration, err := window.parse()
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		// Walk back a minute at a time over the length of the window looking for
+		// a time it opened.
+		for opened := now.Truncate(time.Minute); opened.After(now.Add(-duration)); opened = opened.Add(-time.Minute) {
+			if !schedule.Matches(opened) {
+				continue
+			}
+			if end := opened.Add(duration); end.After(closes) {
+				closes = end
+			}
+			available = true
+			break
+		}
+	}
+	return available, closes, nil
+}
+
+// parse returns the schedule and duration for this window.
+func (w AvailabilityWindow) parse() (*cron.Schedule, time.Duration, error) {
+	schedule, err := cron.Parse(w.Schedule)
+	if err != nil {
+		return nil, 0, err
+	}
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil {
+		return nil, 0, err
+	}
+	if duration <= 0 || duration > maxWindowDuration {
+		return nil, 0, fmt.Errorf("Window duration must be greater than zero and no longer than %s, got %s", maxWindowDuration, w.Duration)
+	}
+	return schedule, duration, nil
+}
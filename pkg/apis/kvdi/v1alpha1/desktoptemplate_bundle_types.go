@@ -0,0 +1,43 @@
+package v1alpha1
+
+import (
+	"time"
+)
+
+// DesktopTemplateBundle is a signed collection of DesktopTemplates, and optionally
+// the VDIRoles that grant access to them, for sharing templates between clusters.
+// +k8s:deepcopy-gen=false
+type DesktopTemplateBundle struct {
+	// The version of kVDI that exported the bundle.
+	Version string `json:"version"`
+	// The name of the VDICluster the bundle was exported from.
+	Source string `json:"source"`
+	// When the bundle was exported.
+	Created time.Time `json:"created"`
+	// The DesktopTemplates in the bundle.
+	Templates []DesktopTemplate `json:"templates"`
+	// The VDIRoles in the bundle.
+	Roles []VDIRole `json:"roles,omitempty"`
+	// A base64 encoded HMAC-SHA256 of the bundle contents. Clusters sharing bundles
+	// need the same `templateBundleKey` in their secrets backends.
+	Signature string `json:"signature,omitempty"`
+}
+
+// ImportTemplateBundleRequest requests that the contents of a bundle be imported
+// into the cluster.
+// +k8s:deepcopy-gen=false
+type ImportTemplateBundleRequest struct {
+	// The bundle to import.
+	Bundle *DesktopTemplateBundle `json:"bundle"`
+	// A mapping of template names in the bundle to the names they should be
+	// imported as. References to the templates in base templates and role
+	// patterns are updated as well.
+	TemplateNames map[string]string `json:"templateNames,omitempty"`
+	// A mapping of role names in the bundle to the names they should be imported as.
+	RoleNames map[string]string `json:"roleNames,omitempty"`
+	// A mapping of namespaces referenced by templates and roles in the bundle to
+	// the namespaces they should be replaced with.
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+	// Set to true to overwrite templates and roles that already exist.
+	Overwrite bool `json:"overwrite,omitempty"`
+}
@@ -0,0 +1,137 @@
+package v1alpha1
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Sign computes the signature for the bundle with the given key and sets it on the
+// bundle.
+func (b *DesktopTemplateBundle) Sign(key []byte) error {
+	sig, err := b.computeSignature(key)
+	if err != nil {
+		return err
+	}
+	b.Signature = sig
+	return nil
+}
+
+// Verify returns an error if the signature on the bundle was not produced by the
+// given key.
+func (b *DesktopTemplateBundle) Verify(key []byte) error {
+	if b.Signature == "" {
+		return errors.New("The bundle is not signed")
+	}
+	expected, err := b.computeSignature(key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(b.Signature)) {
+		return errors.New("The bundle signature is invalid")
+	}
+	return nil
+}
+
+// computeSignature returns the signature of the bundle contents, excluding any
+// existing signature.
+func (b *DesktopTemplateBundle) computeSignature(key []byte) (string, error) {
+	unsigned := *b
+	unsigned.Signature = ""
+	body, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	if _, err := mac.Write(body); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Validate the ImportTemplateBundleRequest
+func (r *ImportTemplateBundleRequest) Validate() error {
+	if r.Bundle == nil {
+		return errors.New("A bundle is required")
+	}
+	if len(r.Bundle.Templates) == 0 && len(r.Bundle.Roles) == 0 {
+		return errors.New("The bundle is empty")
+	}
+	return nil
+}
+
+// GetTemplates returns the templates in the bundle with the name and namespace
+// remappings in the request applied. The returned templates are stripped of server-set metadata
+// so they can be created in the cluster.
+func (r *ImportTemplateBundleRequest) GetTemplates() []*DesktopTemplate {
+	out := make([]*DesktopTemplate, len(r.Bundle.Templates))
+	for i, tmpl := range r.Bundle.Templates {
+		spec := tmpl.Spec.DeepCopy()
+		if spec.BaseTemplate != "" {
+			spec.BaseTemplate = remapName(r.TemplateNames, spec.BaseTemplate)
+		}
+		for j, ns := range spec.Namespaces {
+			spec.Namespaces[j] = remapName(r.Namespaces, ns)
+		}
+		out[i] = &DesktopTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        remapName(r.TemplateNames, tmpl.GetName()),
+				Labels:      tmpl.GetLabels(),
+				Annotations: tmpl.GetAnnotations(),
+			},
+			Spec: *spec,
+		}
+	}
+	return out
+}
+
+// GetRoles returns the roles in the bundle with the name and namespace remappings
+// in the request applied. The returned roles are stripped of server-set metadata
+// so they can be created in the cluster.
+func (r *ImportTemplateBundleRequest) GetRoles() []*VDIRole {
+	out := make([]*VDIRole, len(r.Bundle.Roles))
+	for i, role := range r.Bundle.Roles {
+		imported := role.DeepCopy()
+		imported.ObjectMeta = metav1.ObjectMeta{
+			Name:        remapName(r.RoleNames, role.GetName()),
+			Labels:      role.GetLabels(),
+			Annotations: role.GetAnnotations(),
+		}
+		for j, rule := range imported.Rules {
+			for k, ns := range rule.Namespaces {
+				imported.Rules[j].Namespaces[k] = remapName(r.Namespaces, ns)
+			}
+			for k, pattern := range rule.ResourcePatterns {
+				imported.Rules[j].ResourcePatterns[k] = remapPattern(r.TemplateNames, pattern)
+			}
+		}
+		out[i] = imported
+	}
+	return out
+}
+
+// remapName returns the new name for the given one, or the name itself if it
+// is not being remapped.
+func remapName(names map[string]string, name string) string {
+	if newName, ok := names[name]; ok && newName != "" {
+		return newName
+	}
+	return name
+}
+
+// remapPattern replaces any references to remapped names in a resource pattern.
+func remapPattern(names map[string]string, pattern string) string {
+	for oldName, newName := range names {
+		if newName == "" {
+			continue
+		}
+		pattern = strings.Replace(pattern, regexp.QuoteMeta(oldName), regexp.QuoteMeta(newName), -1)
+	}
+	return pattern
+}
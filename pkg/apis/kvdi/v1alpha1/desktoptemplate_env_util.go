@@ -0,0 +1,99 @@
+package v1alpha1
+
+import (
+	"sort"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reservedEnvVars are the environment variables set by kVDI that users cannot
+// override.
+var reservedEnvVars = []string{
+	v1.UserEnvVar,
+	v1.VNCSockEnvVar,
+	v1.EnableRootEnvVar,
+	v1.SmartCardSockEnvVar,
+	v1.PrintSpoolEnvVar,
+}
+
+// AllowsSessionEnv returns true if users may set the given environment variable
+// when launching desktops from this template.
+func (t *DesktopTemplate) AllowsSessionEnv(name string) bool {
+	if t.Spec.SessionEnv == nil {
+		return false
+	}
+	for _, reserved := range reservedEnvVars {
+		if name == reserved {
+			return false
+		}
+	}
+	for _, pattern := range t.Spec.SessionEnv.Allowed {
+		if pattern == name {
+			return true
+		}
+		if strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasUserSecretEnv returns true if desktops booted from this template set environment
+// variables from the secrets of their user.
+func (t *DesktopTemplate) HasUserSecretEnv() bool {
+	return t.Spec.SessionEnv != nil && len(t.Spec.SessionEnv.UserSecrets) > 0
+}
+
+// GetSessionSecretEnv returns the environment variables to set from the user's secrets
+// in the given desktop, mapped to the name of the secret. Variables requested for
+// the desktop take precedence over the ones in the template.
+func (t *DesktopTemplate) GetSessionSecretEnv(desktop *Desktop) map[string]string {
+	env := make(map[string]string)
+	if t.Spec.SessionEnv != nil {
+		for name, secret := range t.Spec.SessionEnv.UserSecrets {
+			env[name] = secret
+		}
+	}
+	for name, secret := range desktop.Spec.SecretEnv {
+		env[name] = secret
+	}
+	// values set directly take precedence over the template
+	for name := range desktop.Spec.Env {
+		delete(env, name)
+	}
+	return env
+}
+
+// getSessionEnvVars returns the environment variables set for the given desktop at
+// launch, sorted by name so the pod spec does not change between reconciles. Variables
+// from the user's secrets are referenced from the secret for the desktop, and are
+// optional since the user may not have them.
+func (t *DesktopTemplate) getSessionEnvVars(desktop *Desktop) []corev1.EnvVar {
+	secretEnv := t.GetSessionSecretEnv(desktop)
+	if len(desktop.Spec.Env) == 0 && len(secretEnv) == 0 {
+		return nil
+	}
+	envVars := make([]corev1.EnvVar, 0, len(desktop.Spec.Env)+len(secretEnv))
+	for name, value := range desktop.Spec.Env {
+		envVars = append(envVars, corev1.EnvVar{Name: name, Value: value})
+	}
+	for name := range secretEnv {
+		envVars = append(envVars, corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: desktop.GetSessionEnvSecretName(),
+					},
+					Key:      name,
+					Optional: &v1.TrueVal,
+				},
+			},
+		})
+	}
+	sort.Slice(envVars, func(i, j int) bool { return envVars[i].Name < envVars[j].Name })
+	return envVars
+}
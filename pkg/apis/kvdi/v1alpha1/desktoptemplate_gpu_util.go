@@ -0,0 +1,127 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// gpuResourceNames are the resources advertised by the device plugins for each
+// GPU vendor.
+var gpuResourceNames = map[GPUVendor]corev1.ResourceName{
+	GPUVendorNVIDIA: "nvidia.com/gpu",
+	GPUVendorAMD:    "amd.com/gpu",
+	GPUVendorIntel:  "gpu.intel.com/i915",
+}
+
+// HasGPU returns true if desktops booted from this template have GPUs attached.
+func (t *DesktopTemplate) HasGPU() bool {
+	return t.Spec.GPU != nil
+}
+
+// GetGPUResourceName returns the device plugin resource for the GPUs attached to
+// desktops booted from this template.
+func (t *DesktopTemplate) GetGPUResourceName() corev1.ResourceName {
+	if !t.HasGPU() {
+		return ""
+	}
+	if t.Spec.GPU.ResourceName != "" {
+		return t.Spec.GPU.ResourceName
+	}
+	if name, ok := gpuResourceNames[t.Spec.GPU.Vendor]; ok {
+		return name
+	}
+	return gpuResourceNames[GPUVendorNVIDIA]
+}
+
+// GetGPUCount returns the number of GPUs attached to each desktop booted from this
+// template.
+func (t *DesktopTemplate) GetGPUCount() int64 {
+	if !t.HasGPU() {
+		return 0
+	}
+	if t.Spec.GPU.Count > 0 {
+		return t.Spec.GPU.Count
+	}
+	return 1
+}
+
+// GetDesktopNodeSelector returns the node selector for desktops booted from this
+// template.
+func (t *DesktopTemplate) GetDesktopNodeSelector() map[string]string {
+	if !t.HasGPU() {
+		return nil
+	}
+	return t.Spec.GPU.NodeSelector
+}
+
+// GetDesktopTolerations returns the tolerations for desktops booted from this template.
+func (t *DesktopTemplate) GetDesktopTolerations() []corev1.Toleration {
+	if !t.HasGPU() {
+		return nil
+	}
+	if t.Spec.GPU.Tolerations != nil {
+		return t.Spec.GPU.Tolerations
+	}
+	return []corev1.Toleration{
+		{
+			Key:      string(t.GetGPUResourceName()),
+			Operator: corev1.TolerationOpExists,
+		},
+	}
+}
+
+// GPUSchedulableOn returns true if desktops booted from this template can be
+// scheduled on the given node based on its GPUs, labels, and taints. GPUs already
+// in use on the node are not taken into account.
+func (t *DesktopTemplate) GPUSchedulableOn(node *corev1.Node) bool {
+	if node.Spec.Unschedulable || !nodeIsReady(node) {
+		return false
+	}
+	allocatable, ok := node.Status.Allocatable[t.GetGPUResourceName()]
+	if !ok || allocatable.Value() < t.GetGPUCount() {
+		return false
+	}
+	for key, val := range t.GetDesktopNodeSelector() {
+		if node.GetLabels()[key] != val {
+			return false
+		}
+	}
+	tolerations := t.GetDesktopTolerations()
+TaintLoop:
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		for _, toleration := range tolerations {
+			if toleration.ToleratesTaint(taint) {
+				continue TaintLoop
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// withGPUResources returns the given resource requirements with the GPUs for this
+// template added to the limits. Device plugin resources cannot be overcommitted,
+// so the requests default to the same value.
+func (t *DesktopTemplate) withGPUResources(resources corev1.ResourceRequirements) corev1.ResourceRequirements {
+	if !t.HasGPU() {
+		return resources
+	}
+	if resources.Limits == nil {
+		resources.Limits = make(corev1.ResourceList)
+	}
+	resources.Limits[t.GetGPUResourceName()] = *resource.NewQuantity(t.GetGPUCount(), resource.DecimalSI)
+	return resources
+}
+
+func nodeIsReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
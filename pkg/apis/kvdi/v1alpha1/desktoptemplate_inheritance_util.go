@@ -0,0 +1,173 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxTemplateInheritanceDepth is the maximum number of base templates that will
+// be resolved for a single template.
+const maxTemplateInheritanceDepth = 10
+
+// TemplateLookupFunc is used to retrieve DesktopTemplates by name when resolving
+// base templates.
+type TemplateLookupFunc func(name string) (*DesktopTemplate, error)
+
+// GetEffectiveTemplate returns a copy of this template with the configurations
+// of any base templates merged in, using the given client to look them up.
+func (t *DesktopTemplate) GetEffectiveTemplate(c client.Client) (*DesktopTemplate, error) {
+	return t.Resolve(func(name string) (*DesktopTemplate, error) {
+		nn := types.NamespacedName{Name: name, Namespace: metav1.NamespaceAll}
+		found := &DesktopTemplate{}
+		return found, c.Get(context.TODO(), nn, found)
+	})
+}
+
+// Resolve returns a copy of this template with the configurations of any base
+// templates merged in, using the given function to look them up. An error is
+// returned if a base template does not exist or the inheritance chain contains
+// a cycle.
+func (t *DesktopTemplate) Resolve(lookup TemplateLookupFunc) (*DesktopTemplate, error) {
+	chain := []*DesktopTemplate{t}
+	seen := map[string]struct{}{t.GetName(): {}}
+	current := t
+	for current.Spec.BaseTemplate != "" {
+		if len(chain) > maxTemplateInheritanceDepth {
+			return nil, fmt.Errorf("Template %s exceeds the maximum inheritance depth of %d", t.GetName(), maxTemplateInheritanceDepth)
+		}
+		if _, ok := seen[current.Spec.BaseTemplate]; ok {
+			return nil, fmt.Errorf("Template %s has a cycle in its base templates at %s", t.GetName(), current.Spec.BaseTemplate)
+		}
+		base, err := lookup(current.Spec.BaseTemplate)
+		if err != nil {
+			return nil, err
+		}
+		seen[base.GetName()] = struct{}{}
+		chain = append(chain, base)
+		current = base
+	}
+
+	// Start with the root-most template and overlay each child on top of it
+	effective := chain[len(chain)-1].DeepCopy()
+	for i := len(chain) - 2; i >= 0; i-- {
+		effective.Spec = overlayTemplateSpec(effective.Spec, chain[i].Spec)
+	}
+	// The effective template keeps the identity of the requested one
+	effective.ObjectMeta = *t.ObjectMeta.DeepCopy()
+	effective.TypeMeta = t.TypeMeta
+	effective.Spec.BaseTemplate = t.Spec.BaseTemplate
+	return effective, nil
+}
+
+// overlayTemplateSpec returns the result of overlaying the child spec on top of the
+// base spec.
+func overlayTemplateSpec(base, child DesktopTemplateSpec) DesktopTemplateSpec {
+	out := *base.DeepCopy()
+	child = *child.DeepCopy()
+	if child.Image != "" {
+		out.Image = child.Image
+	}
+	if child.ImagePullPolicy != "" {
+		out.ImagePullPolicy = child.ImagePullPolicy
+	}
+	if child.ImagePullSecrets != nil {
+		out.ImagePullSecrets = child.ImagePullSecrets
+	}
+	out.Resources.Requests = overlayResourceList(out.Resources.Requests, child.Resources.Requests)
+	out.Resources.Limits = overlayResourceList(out.Resources.Limits, child.Resources.Limits)
+	out.Env = overlayEnvVars(out.Env, child.Env)
+	if child.SessionEnv != nil {
+		out.SessionEnv = child.SessionEnv
+	}
+	if child.Config != nil {
+		out.Config = child.Config
+	}
+	if child.MaxSessions != 0 {
+		out.MaxSessions = child.MaxSessions
+	}
+	if child.Availability != nil {
+		out.Availability = child.Availability
+	}
+	if child.IdleTimeout != "" {
+		out.IdleTimeout = child.IdleTimeout
+	}
+	if child.GPU != nil {
+		out.GPU = child.GPU
+	}
+	if child.MaxLifetime != "" {
+		out.MaxLifetime = child.MaxLifetime
+	}
+	if child.UserData != "" {
+		out.UserData = child.UserData
+	}
+	if child.Parameters != nil {
+		out.Parameters = child.Parameters
+	}
+	if child.Pod != nil {
+		out.Pod = child.Pod
+	}
+	if child.Network != nil {
+		out.Network = child.Network
+	}
+	if child.SecurityProfiles != nil {
+		out.SecurityProfiles = child.SecurityProfiles
+	}
+	if child.Description != "" {
+		out.Description = child.Description
+	}
+	if child.Icon != "" {
+		out.Icon = child.Icon
+	}
+	if child.Namespaces != nil {
+		out.Namespaces = child.Namespaces
+	}
+	if child.Tags != nil {
+		if out.Tags == nil {
+			out.Tags = make(map[string]string)
+		}
+		for k, v := range child.Tags {
+			out.Tags[k] = v
+		}
+	}
+	return out
+}
+
+func overlayResourceList(base, child corev1.ResourceList) corev1.ResourceList {
+	if child == nil {
+		return base
+	}
+	if base == nil {
+		return child
+	}
+	for name, quantity := range child {
+		base[name] = quantity
+	}
+	return base
+}
+
+func overlayEnvVars(base, child []corev1.EnvVar) []corev1.EnvVar {
+	if child == nil {
+		return base
+	}
+	out := make([]corev1.EnvVar, 0, len(base)+len(child))
+	for _, env := range base {
+		if !envVarsContain(child, env.Name) {
+			out = append(out, env)
+		}
+	}
+	return append(out, child...)
+}
+
+func envVarsContain(envs []corev1.EnvVar, name string) bool {
+	for _, env := range envs {
+		if env.Name == name {
+			return true
+		}
+	}
+	return false
+}
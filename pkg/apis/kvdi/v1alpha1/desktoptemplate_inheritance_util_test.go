@@ -0,0 +1,53 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	templates := map[string]*DesktopTemplate{
+		"base": {Spec: DesktopTemplateSpec{
+			Image:      "base-image",
+			SessionEnv: &SessionEnvConfig{Allowed: []string{"BAR"}},
+		}},
+		"child": {Spec: DesktopTemplateSpec{
+			BaseTemplate: "base",
+			SessionEnv:   &SessionEnvConfig{Allowed: []string{"FOO"}},
+		}},
+		"grandchild": {Spec: DesktopTemplateSpec{BaseTemplate: "child"}},
+	}
+	for name, tmpl := range templates {
+		tmpl.Name = name
+	}
+	lookup := func(name string) (*DesktopTemplate, error) {
+		if tmpl, ok := templates[name]; ok {
+			return tmpl, nil
+		}
+		return nil, fmt.Errorf("%s not found", name)
+	}
+
+	child, err := templates["child"].Resolve(lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.GetName() != "child" || child.Spec.Image != "base-image" {
+		t.Error("Expected child to keep its name and inherit the base image, got:", child.GetName(), child.Spec.Image)
+	}
+	if !child.AllowsSessionEnv("FOO") || child.AllowsSessionEnv("BAR") {
+		t.Error("Expected child session env to replace the base's, got:", child.Spec.SessionEnv)
+	}
+
+	grandchild, err := templates["grandchild"].Resolve(lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !grandchild.AllowsSessionEnv("FOO") {
+		t.Error("Expected session env to be inherited, got:", grandchild.Spec.SessionEnv)
+	}
+
+	templates["base"].Spec.BaseTemplate = "grandchild"
+	if _, err := templates["child"].Resolve(lookup); err == nil {
+		t.Error("Expected error for a cycle in the base templates")
+	}
+}
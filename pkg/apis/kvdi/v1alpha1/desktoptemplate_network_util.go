@@ -0,0 +1,97 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// RestrictsNetwork returns true if the network access of desktops booted from this
+// template is restricted.
+func (t *DesktopTemplate) RestrictsNetwork() bool {
+	return t.Spec.Network != nil && (t.Spec.Network.Ingress != nil || t.Spec.Network.Egress != nil)
+}
+
+// GetNetworkPolicySpec returns the spec for the NetworkPolicy restricting the network
+// access of the given desktop. An error is returned if any of the configured CIDRs are
+// invalid.
+func (t *DesktopTemplate) GetNetworkPolicySpec(cluster *VDICluster, desktop *Desktop) (networkingv1.NetworkPolicySpec, error) {
+	spec := networkingv1.NetworkPolicySpec{
+		PodSelector: metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				v1.VDIClusterLabel:  cluster.GetName(),
+				v1.DesktopNameLabel: desktop.GetName(),
+			},
+		},
+	}
+	if !t.RestrictsNetwork() {
+		return spec, nil
+	}
+
+	if rules := t.Spec.Network.Ingress; rules != nil {
+		spec.PolicyTypes = append(spec.PolicyTypes, networkingv1.PolicyTypeIngress)
+		// the app proxies display and audio connections to the desktop, and may be
+		// running in any namespace
+		webPort := intstr.FromInt(v1.WebPort)
+		spec.Ingress = append(spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			Ports: []networkingv1.NetworkPolicyPort{{Protocol: protocolPtr(corev1.ProtocolTCP), Port: &webPort}},
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{},
+					PodSelector:       &metav1.LabelSelector{MatchLabels: cluster.GetComponentLabels("app")},
+				},
+			},
+		})
+		peers, err := rules.ipBlockPeers()
+		if err != nil {
+			return spec, err
+		}
+		if len(peers) > 0 {
+			spec.Ingress = append(spec.Ingress, networkingv1.NetworkPolicyIngressRule{From: peers})
+		}
+	}
+
+	if rules := t.Spec.Network.Egress; rules != nil {
+		spec.PolicyTypes = append(spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		// an empty list of egress rules denies all egress
+		spec.Egress = make([]networkingv1.NetworkPolicyEgressRule, 0)
+		if rules.AllowDNS {
+			dnsPort := intstr.FromInt(53)
+			spec.Egress = append(spec.Egress, networkingv1.NetworkPolicyEgressRule{
+				Ports: []networkingv1.NetworkPolicyPort{
+					{Protocol: protocolPtr(corev1.ProtocolUDP), Port: &dnsPort},
+					{Protocol: protocolPtr(corev1.ProtocolTCP), Port: &dnsPort},
+				},
+			})
+		}
+		peers, err := rules.ipBlockPeers()
+		if err != nil {
+			return spec, err
+		}
+		if len(peers) > 0 {
+			spec.Egress = append(spec.Egress, networkingv1.NetworkPolicyEgressRule{To: peers})
+		}
+	}
+
+	return spec, nil
+}
+
+// ipBlockPeers returns a NetworkPolicyPeer for each of the allowed CIDRs.
+func (n *NetworkRulesConfig) ipBlockPeers() ([]networkingv1.NetworkPolicyPeer, error) {
+	peers := make([]networkingv1.NetworkPolicyPeer, 0)
+	for _, cidr := range n.AllowedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("Invalid CIDR in network configuration: %s", cidr)
+		}
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	return peers, nil
+}
+
+func protocolPtr(p corev1.Protocol) *corev1.Protocol { return &p }
@@ -0,0 +1,204 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// GetParameter returns the parameter with the given name, or nil if the template
+// does not define it.
+func (t *DesktopTemplate) GetParameter(name string) *DesktopTemplateParameter {
+	for i, param := range t.Spec.Parameters {
+		if param.Name == name {
+			return &t.Spec.Parameters[i]
+		}
+	}
+	return nil
+}
+
+// ResolveParameters validates the given values against the parameters defined in
+// this template and returns them with defaults filled in. An error is returned for
+// unknown parameters, invalid values, and required parameters that are missing.
+func (t *DesktopTemplate) ResolveParameters(values map[string]string) (map[string]string, error) {
+	for name := range values {
+		if t.GetParameter(name) == nil {
+			return nil, fmt.Errorf("Template %s does not have a parameter named %s", t.GetName(), name)
+		}
+	}
+	resolved := make(map[string]string)
+	for _, param := range t.Spec.Parameters {
+		value, ok := values[param.Name]
+		if !ok {
+			if param.Default == "" {
+				return nil, fmt.Errorf("A value is required for parameter %s", param.Name)
+			}
+			value = param.Default
+		}
+		if err := param.Validate(value); err != nil {
+			return nil, err
+		}
+		resolved[param.Name] = value
+	}
+	return resolved, nil
+}
+
+// IsDefaultParameters returns true if the given resolved values match the defaults
+// for every parameter in this template.
+func (t *DesktopTemplate) IsDefaultParameters(values map[string]string) bool {
+	for _, param := range t.Spec.Parameters {
+		if value, ok := values[param.Name]; ok && value != param.Default {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyParameters returns a copy of this template with the environment variables and
+// resources from the given parameter values applied. Parameters without a value use
+// their default, and are skipped if they do not have one. Claims are mounted separately
+// when building the desktop's volumes.
+func (t *DesktopTemplate) ApplyParameters(values map[string]string) (*DesktopTemplate, error) {
+	if len(t.Spec.Parameters) == 0 {
+		return t, nil
+	}
+	out := t.DeepCopy()
+	for _, param := range t.Spec.Parameters {
+		value, ok := param.getValue(values)
+		if !ok {
+			continue
+		}
+		if err := param.Validate(value); err != nil {
+			return nil, err
+		}
+		if param.Env != "" {
+			out.Spec.Env = overlayEnvVars(out.Spec.Env, []corev1.EnvVar{{Name: param.Env, Value: value}})
+		}
+		if param.Resource != "" {
+			quantity := resource.MustParse(value)
+			if out.Spec.Resources.Requests == nil {
+				out.Spec.Resources.Requests = make(corev1.ResourceList)
+			}
+			if out.Spec.Resources.Limits == nil {
+				out.Spec.Resources.Limits = make(corev1.ResourceList)
+			}
+			out.Spec.Resources.Requests[param.Resource] = quantity
+			out.Spec.Resources.Limits[param.Resource] = quantity.DeepCopy()
+		}
+	}
+	return out, nil
+}
+
+// parameterClaim is a PersistentVolumeClaim chosen with a template parameter.
+type parameterClaim struct {
+	volume, claimName, mountPath string
+}
+
+// getParameterClaims returns the claims to mount for the given parameter values.
+func (t *DesktopTemplate) getParameterClaims(values map[string]string) []parameterClaim {
+	claims := make([]parameterClaim, 0)
+	for i, param := range t.Spec.Parameters {
+		if param.ClaimMountPath == "" {
+			continue
+		}
+		if value, ok := param.getValue(values); ok {
+			claims = append(claims, parameterClaim{
+				volume:    fmt.Sprintf("param-claim-%d", i),
+				claimName: value,
+				mountPath: param.ClaimMountPath,
+			})
+		}
+	}
+	return claims
+}
+
+// getValue returns the value for this parameter from the given values, falling back
+// to the default.
+func (p *DesktopTemplateParameter) getValue(values map[string]string) (string, bool) {
+	if value, ok := values[p.Name]; ok {
+		return value, true
+	}
+	return p.Default, p.Default != ""
+}
+
+// GetType returns the type of value this parameter accepts.
+func (p *DesktopTemplateParameter) GetType() ParameterType {
+	if p.Type != "" {
+		return p.Type
+	}
+	return ParameterString
+}
+
+// Validate returns an error if the given value is not allowed for this parameter,
+// or if the parameter itself is misconfigured.
+func (p *DesktopTemplateParameter) Validate(value string) error {
+	if p.Resource != "" && p.GetType() != ParameterQuantity {
+		return fmt.Errorf("Parameter %s sets a resource and must be a quantity", p.Name)
+	}
+	if p.ClaimMountPath != "" && (p.GetType() != ParameterString || len(p.Options) == 0) {
+		return fmt.Errorf("Parameter %s mounts a claim and must be a string with options", p.Name)
+	}
+	if len(p.Options) > 0 {
+		var found bool
+		for _, opt := range p.Options {
+			if opt == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%q is not a valid option for parameter %s", value, p.Name)
+		}
+	}
+	switch p.GetType() {
+	case ParameterString:
+		return nil
+	case ParameterBoolean:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("Parameter %s must be a boolean", p.Name)
+		}
+		return nil
+	case ParameterInteger:
+		return p.validateRange(value, func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) })
+	case ParameterQuantity:
+		return p.validateRange(value, func(s string) (int64, error) {
+			quantity, err := resource.ParseQuantity(s)
+			if err != nil {
+				return 0, err
+			}
+			return quantity.MilliValue(), nil
+		})
+	default:
+		return fmt.Errorf("Parameter %s has an unknown type %s", p.Name, p.Type)
+	}
+}
+
+// validateRange parses the value along with the minimum and maximum with the given
+// function and checks that the value is within them.
+func (p *DesktopTemplateParameter) validateRange(value string, parse func(string) (int64, error)) error {
+	val, err := parse(value)
+	if err != nil {
+		return fmt.Errorf("Parameter %s must be a valid %s", p.Name, p.GetType())
+	}
+	if p.Minimum != "" {
+		min, err := parse(p.Minimum)
+		if err != nil {
+			return fmt.Errorf("Parameter %s has an invalid minimum %s", p.Name, p.Minimum)
+		}
+		if val < min {
+			return fmt.Errorf("Parameter %s must be at least %s", p.Name, p.Minimum)
+		}
+	}
+	if p.Maximum != "" {
+		max, err := parse(p.Maximum)
+		if err != nil {
+			return fmt.Errorf("Parameter %s has an invalid maximum %s", p.Name, p.Maximum)
+		}
+		if val > max {
+			return fmt.Errorf("Parameter %s must be at most %s", p.Name, p.Maximum)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GetPodConfig returns the customizations to merge into the pods of desktops booted
+// from this template. An empty config is returned if none are defined.
+func (t *DesktopTemplate) GetPodConfig() *DesktopPodConfig {
+	if t.Spec.Pod != nil {
+		return t.Spec.Pod
+	}
+	return &DesktopPodConfig{}
+}
+
+// MergeLabels returns the given labels with the extra pod labels added. Labels
+// already present are not overridden, since kVDI selects desktops with them.
+func (p *DesktopPodConfig) MergeLabels(labels map[string]string) map[string]string {
+	return mergeMissing(labels, p.Labels)
+}
+
+// MergeAnnotations returns the given annotations with the extra pod annotations
+// added. Annotations already present are not overridden.
+func (p *DesktopPodConfig) MergeAnnotations(annotations map[string]string) map[string]string {
+	return mergeMissing(annotations, p.Annotations)
+}
+
+// AppendInitContainers returns the given init containers followed by the extra
+// init containers whose names are not already taken.
+func (p *DesktopPodConfig) AppendInitContainers(containers []corev1.Container) []corev1.Container {
+	return appendContainers(containers, p.InitContainers)
+}
+
+// AppendSidecars returns the given containers followed by the sidecar containers
+// whose names are not already taken.
+func (p *DesktopPodConfig) AppendSidecars(containers []corev1.Container) []corev1.Container {
+	return appendContainers(containers, p.Sidecars)
+}
+
+// AppendVolumes returns the given volumes followed by the extra volumes whose names
+// are not already taken.
+func (p *DesktopPodConfig) AppendVolumes(volumes []corev1.Volume) []corev1.Volume {
+	names := make(map[string]struct{}, len(volumes))
+	for _, vol := range volumes {
+		names[vol.Name] = struct{}{}
+	}
+	for _, vol := range p.Volumes {
+		if _, ok := names[vol.Name]; ok {
+			continue
+		}
+		names[vol.Name] = struct{}{}
+		volumes = append(volumes, vol)
+	}
+	return volumes
+}
+
+// AppendVolumeMounts returns the given volume mounts followed by the extra mounts
+// whose paths are not already taken.
+func (p *DesktopPodConfig) AppendVolumeMounts(mounts []corev1.VolumeMount) []corev1.VolumeMount {
+	paths := make(map[string]struct{}, len(mounts))
+	for _, mount := range mounts {
+		paths[mount.MountPath] = struct{}{}
+	}
+	for _, mount := range p.VolumeMounts {
+		if _, ok := paths[mount.MountPath]; ok {
+			continue
+		}
+		paths[mount.MountPath] = struct{}{}
+		mounts = append(mounts, mount)
+	}
+	return mounts
+}
+
+// mergeMissing returns a copy of base with the keys from extra that it does not
+// already have.
+func mergeMissing(base, extra map[string]string) map[string]string {
+	out := make(map[string]string, len(base)+len(extra))
+	for key, val := range extra {
+		out[key] = val
+	}
+	for key, val := range base {
+		out[key] = val
+	}
+	return out
+}
+
+// appendContainers returns the given containers followed by the extra containers
+// whose names are not already taken.
+func appendContainers(containers, extra []corev1.Container) []corev1.Container {
+	names := make(map[string]struct{}, len(containers))
+	for _, container := range containers {
+		names[container.Name] = struct{}{}
+	}
+	for _, container := range extra {
+		if _, ok := names[container.Name]; ok {
+			continue
+		}
+		names[container.Name] = struct{}{}
+		containers = append(containers, container)
+	}
+	return containers
+}
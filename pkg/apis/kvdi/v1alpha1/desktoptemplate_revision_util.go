@@ -0,0 +1,202 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// versionAnnotation is the annotation on recorded template revisions holding the
+// version label of the template.
+const versionAnnotation = "kvdi.io/template-version"
+
+// GetRevision returns the current revision of the template. This is the generation
+// of the object, which is incremented for every change to the spec.
+func (t *DesktopTemplate) GetRevision() int64 {
+	return t.GetGeneration()
+}
+
+// GetSessionUpdatePolicy returns what happens to running desktops when the template
+// is updated.
+func (t *DesktopTemplate) GetSessionUpdatePolicy() SessionUpdatePolicy {
+	if t.Spec.SessionUpdatePolicy != "" {
+		return t.Spec.SessionUpdatePolicy
+	}
+	return SessionUpdateKeep
+}
+
+// GetRevisionHistoryLimit returns the number of old revisions of the template to
+// retain.
+func (t *DesktopTemplate) GetRevisionHistoryLimit() int {
+	if t.Spec.RevisionHistoryLimit != nil {
+		return int(*t.Spec.RevisionHistoryLimit)
+	}
+	return 10
+}
+
+// GetRevisionName returns the name of the ControllerRevision holding the given
+// revision of the template.
+func (t *DesktopTemplate) GetRevisionName(revision int64) string {
+	return fmt.Sprintf("%s-%d", t.GetName(), revision)
+}
+
+// RecordRevision records the current revision of the template in the given namespace
+// if it has not been already, and prunes old revisions beyond the history limit.
+func (t *DesktopTemplate) RecordRevision(c client.Client, namespace string) error {
+	// the object has not been persisted yet
+	if t.GetRevision() == 0 {
+		return nil
+	}
+	nn := types.NamespacedName{Name: t.GetRevisionName(t.GetRevision()), Namespace: namespace}
+	if err := c.Get(context.TODO(), nn, &appsv1.ControllerRevision{}); err == nil {
+		return nil
+	} else if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	data, err := json.Marshal(t.Spec)
+	if err != nil {
+		return err
+	}
+	rev := &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        nn.Name,
+			Namespace:   nn.Namespace,
+			Labels:      map[string]string{v1.DesktopTemplateLabel: t.GetName()},
+			Annotations: map[string]string{versionAnnotation: t.Spec.Version},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: SchemeGroupVersion.String(),
+					Kind:       "DesktopTemplate",
+					Name:       t.GetName(),
+					UID:        t.GetUID(),
+				},
+			},
+		},
+		Data:     runtime.RawExtension{Raw: data},
+		Revision: t.GetRevision(),
+	}
+	if err := c.Create(context.TODO(), rev); err != nil && !kerrors.IsAlreadyExists(err) {
+		return err
+	}
+	return t.pruneRevisions(c, namespace)
+}
+
+// pruneRevisions deletes the oldest revisions of the template beyond the history
+// limit. The current revision and revisions desktops use are never deleted.
+func (t *DesktopTemplate) pruneRevisions(c client.Client, namespace string) error {
+	revs, err := t.listRevisions(c, namespace)
+	if err != nil {
+		return err
+	}
+	// the current revision does not count towards the limit
+	if len(revs) <= t.GetRevisionHistoryLimit()+1 {
+		return nil
+	}
+	inUse, err := t.getRevisionsInUse(c)
+	if err != nil {
+		return err
+	}
+	excess := len(revs) - t.GetRevisionHistoryLimit() - 1
+	for _, rev := range revs {
+		if excess == 0 {
+			break
+		}
+		if rev.Revision == t.GetRevision() || inUse[rev.Revision] {
+			continue
+		}
+		if err := c.Delete(context.TODO(), &rev); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		excess--
+	}
+	return nil
+}
+
+// getRevisionsInUse returns the revisions of the template that desktops are running
+// or pinned to.
+func (t *DesktopTemplate) getRevisionsInUse(c client.Client) (map[int64]bool, error) {
+	desktops := &DesktopList{}
+	if err := c.List(context.TODO(), desktops); err != nil {
+		return nil, err
+	}
+	inUse := make(map[int64]bool)
+	for _, desktop := range desktops.Items {
+		if desktop.Spec.Template != t.GetName() {
+			continue
+		}
+		inUse[desktop.Spec.TemplateRevision] = true
+		inUse[desktop.Status.TemplateRevision] = true
+	}
+	return inUse, nil
+}
+
+// listRevisions returns the recorded revisions of the template in the given namespace,
+// oldest first.
+func (t *DesktopTemplate) listRevisions(c client.Client, namespace string) ([]appsv1.ControllerRevision, error) {
+	revList := &appsv1.ControllerRevisionList{}
+	if err := c.List(context.TODO(), revList, client.InNamespace(namespace), client.MatchingLabels{v1.DesktopTemplateLabel: t.GetName()}); err != nil {
+		return nil, err
+	}
+	revs := revList.Items
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Revision < revs[j].Revision })
+	return revs, nil
+}
+
+// GetRevisions returns the recorded revisions of the template in the given namespace,
+// newest first.
+func (t *DesktopTemplate) GetRevisions(c client.Client, namespace string) ([]*DesktopTemplateRevision, error) {
+	revs, err := t.listRevisions(c, namespace)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*DesktopTemplateRevision, 0, len(revs))
+	for i := len(revs) - 1; i >= 0; i-- {
+		rev, err := toTemplateRevision(&revs[i])
+		if err != nil {
+			return nil, err
+		}
+		rev.Current = rev.Revision == t.GetRevision()
+		out = append(out, rev)
+	}
+	return out, nil
+}
+
+// GetRevisionSpec returns the spec of the template at the given revision. A not
+// found error is returned if the revision was never recorded or was pruned.
+func (t *DesktopTemplate) GetRevisionSpec(c client.Client, namespace string, revision int64) (*DesktopTemplateSpec, error) {
+	if revision == t.GetRevision() {
+		return t.Spec.DeepCopy(), nil
+	}
+	rev := &appsv1.ControllerRevision{}
+	nn := types.NamespacedName{Name: t.GetRevisionName(revision), Namespace: namespace}
+	if err := c.Get(context.TODO(), nn, rev); err != nil {
+		return nil, err
+	}
+	out, err := toTemplateRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	return &out.Spec, nil
+}
+
+func toTemplateRevision(rev *appsv1.ControllerRevision) (*DesktopTemplateRevision, error) {
+	out := &DesktopTemplateRevision{
+		Revision:  rev.Revision,
+		Version:   rev.GetAnnotations()[versionAnnotation],
+		CreatedAt: rev.GetCreationTimestamp(),
+	}
+	if err := json.Unmarshal(rev.Data.Raw, &out.Spec); err != nil {
+		return nil, fmt.Errorf("Failed to decode template revision %s: %s", rev.GetName(), err.Error())
+	}
+	return out, nil
+}
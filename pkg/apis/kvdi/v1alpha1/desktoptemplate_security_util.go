@@ -0,0 +1,113 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// seccompContainerAnnotationPrefix is the prefix of the annotation for the seccomp
+	// profile of a container. The container name is appended.
+	seccompContainerAnnotationPrefix = "container.seccomp.security.alpha.kubernetes.io/"
+	// appArmorContainerAnnotationPrefix is the prefix of the annotation for the
+	// AppArmor profile of a container. The container name is appended.
+	appArmorContainerAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+	// appArmorEnabledMessage is reported in the ready condition of nodes with
+	// AppArmor enabled.
+	appArmorEnabledMessage = "AppArmor enabled"
+
+	// ProfileRuntimeDefault is the profile provided by the container runtime.
+	ProfileRuntimeDefault = "runtime/default"
+	// ProfileUnconfined means no profile is applied.
+	ProfileUnconfined = "unconfined"
+	// ProfileLocalhostPrefix is the prefix of profiles provided on the nodes.
+	ProfileLocalhostPrefix = "localhost/"
+)
+
+// HasSecurityProfiles returns true if the desktop container is confined by any
+// security profiles.
+func (t *DesktopTemplate) HasSecurityProfiles() bool {
+	p := t.Spec.SecurityProfiles
+	return p != nil && (p.Seccomp != "" || p.AppArmor != "" || p.SELinux != nil)
+}
+
+// GetSeccompProfile returns the seccomp profile for the desktop container, if any.
+func (t *DesktopTemplate) GetSeccompProfile() string {
+	if t.Spec.SecurityProfiles == nil {
+		return ""
+	}
+	return t.Spec.SecurityProfiles.Seccomp
+}
+
+// GetAppArmorProfile returns the AppArmor profile for the desktop container, if any.
+func (t *DesktopTemplate) GetAppArmorProfile() string {
+	if t.Spec.SecurityProfiles == nil {
+		return ""
+	}
+	return t.Spec.SecurityProfiles.AppArmor
+}
+
+// GetSELinuxOptions returns the SELinux context for the desktop container, if any.
+func (t *DesktopTemplate) GetSELinuxOptions() *corev1.SELinuxOptions {
+	if t.Spec.SecurityProfiles == nil {
+		return nil
+	}
+	return t.Spec.SecurityProfiles.SELinux
+}
+
+// ValidateSecurityProfiles returns an error if any of the security profiles for this
+// template are malformed.
+func (t *DesktopTemplate) ValidateSecurityProfiles() error {
+	if err := validateProfile("seccomp", t.GetSeccompProfile()); err != nil {
+		return err
+	}
+	return validateProfile("AppArmor", t.GetAppArmorProfile())
+}
+
+func validateProfile(kind, profile string) error {
+	switch {
+	case profile == "", profile == ProfileRuntimeDefault, profile == ProfileUnconfined:
+		return nil
+	case strings.HasPrefix(profile, ProfileLocalhostPrefix):
+		name := strings.TrimPrefix(profile, ProfileLocalhostPrefix)
+		if name == "" || strings.Contains(name, "..") {
+			return fmt.Errorf("Invalid %s profile %q, localhost profiles must name a profile on the nodes", kind, profile)
+		}
+		return nil
+	default:
+		return fmt.Errorf("Invalid %s profile %q, must be one of %s, %s, or %s<profile>", kind, profile, ProfileRuntimeDefault, ProfileUnconfined, ProfileLocalhostPrefix)
+	}
+}
+
+// RequiresAppArmor returns true if desktops booted from this template can only
+// run on nodes with AppArmor enabled.
+func (t *DesktopTemplate) RequiresAppArmor() bool {
+	profile := t.GetAppArmorProfile()
+	return profile != "" && profile != ProfileUnconfined
+}
+
+// AppArmorEnabledOn returns true if the kubelet on the given node reports that
+// AppArmor is enabled.
+func AppArmorEnabledOn(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return strings.Contains(cond.Message, appArmorEnabledMessage)
+		}
+	}
+	return false
+}
+
+// GetSecurityProfileAnnotations returns the pod annotations applying the seccomp and
+// AppArmor profiles for this template to the given container.
+func (t *DesktopTemplate) GetSecurityProfileAnnotations(container string) map[string]string {
+	annotations := make(map[string]string)
+	if profile := t.GetSeccompProfile(); profile != "" {
+		annotations[seccompContainerAnnotationPrefix+container] = profile
+	}
+	if profile := t.GetAppArmorProfile(); profile != "" {
+		annotations[appArmorContainerAnnotationPrefix+container] = profile
+	}
+	return annotations
+}
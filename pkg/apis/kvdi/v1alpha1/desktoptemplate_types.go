@@ -31,7 +31,7 @@ const (
 
 // SocketType represents the type of service listening on the display socket
 // in the container image.
-// +kubebuilder:validation:Enum=xvnc;xpra
+// +kubebuilder:validation:Enum=xvnc;xpra;rdp
 type SocketType string
 
 const (
@@ -39,23 +39,360 @@ const (
 	SocketXVNC SocketType = "xvnc"
 	// SocketXPRA signals that Xpra is used for the display server.
 	SocketXPRA SocketType = "xpra"
+	// SocketRDP signals that the display is served by an RDP server and translated
+	// for the web client by a guacd sidecar.
+	SocketRDP SocketType = "rdp"
+)
+
+// RDPSecurity represents the security mode negotiated with an RDP server.
+// +kubebuilder:validation:Enum=any;nla;tls;rdp
+type RDPSecurity string
+
+const (
+	// RDPSecurityAny uses the most secure mode supported by the server.
+	RDPSecurityAny RDPSecurity = "any"
+	// RDPSecurityNLA requires Network Level Authentication.
+	RDPSecurityNLA RDPSecurity = "nla"
+	// RDPSecurityTLS requires TLS encryption.
+	RDPSecurityTLS RDPSecurity = "tls"
+	// RDPSecurityRDP uses standard RDP encryption.
+	RDPSecurityRDP RDPSecurity = "rdp"
+)
+
+// ClipboardPolicy represents the directions clipboard contents are synced between
+// clients and desktops.
+// +kubebuilder:validation:Enum=none;one-way-in;one-way-out;bidirectional
+type ClipboardPolicy string
+
+const (
+	// ClipboardNone disables clipboard syncing in both directions.
+	ClipboardNone ClipboardPolicy = "none"
+	// ClipboardOneWayIn only allows pasting from clients into desktops.
+	ClipboardOneWayIn ClipboardPolicy = "one-way-in"
+	// ClipboardOneWayOut only allows copying from desktops to clients.
+	ClipboardOneWayOut ClipboardPolicy = "one-way-out"
+	// ClipboardBidirectional allows clipboard syncing in both directions.
+	ClipboardBidirectional ClipboardPolicy = "bidirectional"
+)
+
+// USBDeviceClass represents a class of USB devices that can be redirected into
+// desktops.
+// +kubebuilder:validation:Enum=audio;communications;hid;image;printer;mass-storage;smart-card;video;vendor-specific
+type USBDeviceClass string
+
+const (
+	// USBClassAudio is for audio devices such as headsets.
+	USBClassAudio USBDeviceClass = "audio"
+	// USBClassCommunications is for modems and serial adapters.
+	USBClassCommunications USBDeviceClass = "communications"
+	// USBClassHID is for keyboards, mice, and other human interface devices.
+	USBClassHID USBDeviceClass = "hid"
+	// USBClassImage is for scanners and cameras using PTP.
+	USBClassImage USBDeviceClass = "image"
+	// USBClassPrinter is for printers.
+	USBClassPrinter USBDeviceClass = "printer"
+	// USBClassMassStorage is for flash drives and external disks.
+	USBClassMassStorage USBDeviceClass = "mass-storage"
+	// USBClassSmartCard is for smart card readers.
+	USBClassSmartCard USBDeviceClass = "smart-card"
+	// USBClassVideo is for webcams.
+	USBClassVideo USBDeviceClass = "video"
+	// USBClassVendorSpecific is for devices with vendor-specific protocols, such
+	// as most license dongles.
+	USBClassVendorSpecific USBDeviceClass = "vendor-specific"
+)
+
+// FileTransferPolicy represents the directions files can be transferred between
+// clients and desktops.
+// +kubebuilder:validation:Enum=none;upload;download;bidirectional
+type FileTransferPolicy string
+
+const (
+	// FileTransferNone disables file transfer in both directions.
+	FileTransferNone FileTransferPolicy = "none"
+	// FileTransferUpload only allows uploading files to desktops.
+	FileTransferUpload FileTransferPolicy = "upload"
+	// FileTransferDownload only allows downloading files from desktops.
+	FileTransferDownload FileTransferPolicy = "download"
+	// FileTransferBidirectional allows file transfer in both directions.
+	FileTransferBidirectional FileTransferPolicy = "bidirectional"
+)
+
+// SessionUpdatePolicy represents what happens to running desktops when their
+// template is updated.
+// +kubebuilder:validation:Enum=keep;recreate
+type SessionUpdatePolicy string
+
+const (
+	// SessionUpdateKeep leaves running desktops on the revision they were booted
+	// from. Only new desktops use the updated template.
+	SessionUpdateKeep SessionUpdatePolicy = "keep"
+	// SessionUpdateRecreate recreates the pods of running desktops with the updated
+	// template. Desktops pinned to a revision are left alone.
+	SessionUpdateRecreate SessionUpdatePolicy = "recreate"
 )
 
 // DesktopTemplateSpec defines the desired state of DesktopTemplate
 type DesktopTemplateSpec struct {
+	// The name of another DesktopTemplate to inherit configurations from. Fields set
+	// on this template are overlayed on top of the base template. Maps (tags, resource
+	// requests/limits) and environment variables are merged, with values in this
+	// template taking precedence. All other fields, including `config`, replace the
+	// value in the base template when set.
+	BaseTemplate string `json:"baseTemplate,omitempty"`
 	// The docker repository and tag to use for desktops booted from this template.
-	Image string `json:"image"`
+	// Required unless inherited from a `baseTemplate`.
+	Image string `json:"image,omitempty"`
 	// The pull policy to use when pulling the container image.
 	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
 	// Any pull secrets required for pulling the container image.
 	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
-	// Resource requirements to apply to desktops booted from this template.
+	// Resource requirements to apply to desktops booted from this template. Setting
+	// an `ephemeral-storage` limit bounds the disk space a desktop can use before it
+	// is evicted, and users are warned in the UI as they approach it.
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// GPUs to attach to desktops booted from this template. The device plugin resource
+	// is added to the resource limits, and desktops are scheduled onto nodes that
+	// provide it.
+	GPU *GPUConfig `json:"gpu,omitempty"`
+	// Extra environment variables to set in desktops booted from this template.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// Environment variables users can set in desktops booted from this template,
+	// including ones set from their own secrets.
+	SessionEnv *SessionEnvConfig `json:"sessionEnv,omitempty"`
 	// Configuration options for the instances. This is highly dependant on using
 	// the Dockerfiles (or close derivitives) provided in this repository.
 	Config *DesktopConfig `json:"config,omitempty"`
 	// Arbitrary tags for displaying in the app UI.
 	Tags map[string]string `json:"tags,omitempty"`
+	// A description of the template to display in the app UI.
+	Description string `json:"description,omitempty"`
+	// The URL of an icon to display for the template in the app UI. Data URIs are
+	// supported.
+	Icon string `json:"icon,omitempty"`
+	// The namespaces desktops can be launched from this template in. The template
+	// is only listed for users allowed to launch it in one of them. Defaults to any
+	// namespace.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// The maximum number of desktops that can be running from this template at
+	// any given time across the cluster. Desktops that are being deleted or have
+	// failed do not count. Requests for new sessions beyond this limit are denied
+	// and the user is queued, keeping their place as long as they retry within two
+	// minutes. Defaults to no limit.
+	MaxSessions int32 `json:"maxSessions,omitempty"`
+	// Restricts the times at which desktops can be launched from this template.
+	// Defaults to always available.
+	Availability *AvailabilityConfig `json:"availability,omitempty"`
+	// How long desktops booted from this template can go without a display or
+	// audio connection before they are destroyed. Overrides the `idleTimeout`
+	// configured on the VDICluster.
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// How long desktops booted from this template can run before they are destroyed.
+	// Overrides the `maxSessionLength` configured on the VDICluster.
+	MaxLifetime string `json:"maxLifetime,omitempty"`
+	// A script to run as root inside desktops booted from this template the first
+	// time they start. This can be used to install user-specific tooling or mount
+	// remote shares without building new images. Users can supply their own script
+	// to run in its place. The script is mounted into the desktop container at
+	// `/etc/kvdi/userdata/user-data`, and images are expected to run it before the
+	// user session starts. The images in this repository do this with the
+	// `kvdi-userdata` systemd unit.
+	UserData string `json:"userData,omitempty"`
+	// Values users can choose when launching desktops from this template, such as
+	// the screen resolution or memory size.
+	Parameters []DesktopTemplateParameter `json:"parameters,omitempty"`
+	// Customizations merged into the pods of desktops booted from this template,
+	// such as extra sidecar containers and volumes.
+	Pod *DesktopPodConfig `json:"pod,omitempty"`
+	// Restrictions on the network access of desktops booted from this template. A
+	// NetworkPolicy is generated for each desktop and removed along with it. Defaults
+	// to unrestricted access.
+	Network *DesktopNetworkConfig `json:"network,omitempty"`
+	// Security profiles to confine the desktop container with. Privileged containers
+	// are not confined by them, so the desktop container is run unprivileged when any
+	// are set, with only the capabilities added by kVDI and `config.capabilities`.
+	SecurityProfiles *SecurityProfilesConfig `json:"securityProfiles,omitempty"`
+	// A version label for the template. It is recorded with each revision of the
+	// template to make them easier to tell apart, and is otherwise informational.
+	Version string `json:"version,omitempty"`
+	// What happens to running desktops when the template is updated. With `keep`
+	// they stay on the revision they were booted from, and with `recreate` their
+	// pods are recreated with the update. Defaults to `keep`.
+	SessionUpdatePolicy SessionUpdatePolicy `json:"sessionUpdatePolicy,omitempty"`
+	// The number of old revisions of the template to retain for rolling back and
+	// pinning desktops to. Revisions that desktops are running or pinned to are
+	// always retained. Defaults to 10.
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+}
+
+// SessionEnvConfig represents the environment variables users can set in desktops,
+// and the ones set from their secrets. Values are stored in a Secret for each desktop
+// and referenced from the desktop container.
+type SessionEnvConfig struct {
+	// The names of environment variables users may set when launching a desktop. A
+	// trailing `*` matches any suffix, e.g. `AWS_*`. Users must also be allowed the
+	// `set-env` verb on the template. Variables kVDI sets itself cannot be overridden.
+	Allowed []string `json:"allowed,omitempty"`
+	// Environment variables to set in every desktop booted from this template,
+	// mapped to the name of one of the user's secrets. Variables are left unset for
+	// users without the secret. Users can manage their secrets at `/api/users/{user}/secrets`.
+	UserSecrets map[string]string `json:"userSecrets,omitempty"`
+}
+
+// DesktopPodConfig represents customizations merged into the pods generated for
+// desktops. Labels, containers, volumes, and mounts that conflict with ones kVDI
+// generates are ignored.
+type DesktopPodConfig struct {
+	// Extra annotations to apply to desktop pods.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Extra labels to apply to desktop pods.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Extra init containers to run before the desktop starts. They run after the
+	// init containers added by kVDI.
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+	// Extra containers to run alongside the desktop, e.g. a proxy or monitoring agent.
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+	// Extra volumes to add to desktop pods. They can be mounted by the extra
+	// containers, or into the desktop container with `volumeMounts`.
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+	// Extra volume mounts for the desktop container.
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+}
+
+// ParameterType represents the type of value a template parameter accepts.
+// +kubebuilder:validation:Enum=string;integer;boolean;quantity
+type ParameterType string
+
+const (
+	// ParameterString accepts any string value.
+	ParameterString ParameterType = "string"
+	// ParameterInteger accepts whole numbers.
+	ParameterInteger ParameterType = "integer"
+	// ParameterBoolean accepts `true` or `false`.
+	ParameterBoolean ParameterType = "boolean"
+	// ParameterQuantity accepts kubernetes resource quantities, e.g. `4Gi`.
+	ParameterQuantity ParameterType = "quantity"
+)
+
+// DesktopTemplateParameter represents a value users can choose when launching a
+// desktop from a template, and how it is applied to the desktop.
+type DesktopTemplateParameter struct {
+	// The name of the parameter. This is the key used to set it when requesting
+	// a new session.
+	Name string `json:"name"`
+	// A description of the parameter for displaying in the app UI.
+	Description string `json:"description,omitempty"`
+	// The type of value the parameter accepts. Defaults to `string`.
+	Type ParameterType `json:"type,omitempty"`
+	// The value to use when one is not provided. Parameters without a default must
+	// be provided when requesting a new session.
+	Default string `json:"default,omitempty"`
+	// When set, the value must be one of these options.
+	Options []string `json:"options,omitempty"`
+	// For `integer` and `quantity` parameters, the lowest value allowed.
+	Minimum string `json:"minimum,omitempty"`
+	// For `integer` and `quantity` parameters, the highest value allowed.
+	Maximum string `json:"maximum,omitempty"`
+	// An environment variable to set to the value in the desktop container.
+	Env string `json:"env,omitempty"`
+	// For `quantity` parameters, a resource to request and limit to the value in the
+	// desktop container, e.g. `memory`. Limits configured for the namespace in the
+	// VDICluster still apply.
+	Resource corev1.ResourceName `json:"resource,omitempty"`
+	// For `string` parameters, a path to mount the PersistentVolumeClaim named by the
+	// value at in the desktop container. The claim must exist in the namespace of the
+	// desktop. `options` are required to restrict which claims users can choose.
+	ClaimMountPath string `json:"claimMountPath,omitempty"`
+}
+
+// GPUVendor represents a vendor of GPUs with a known device plugin.
+// +kubebuilder:validation:Enum=nvidia;amd;intel
+type GPUVendor string
+
+const (
+	// GPUVendorNVIDIA represents NVIDIA GPUs exposed by the NVIDIA device plugin.
+	GPUVendorNVIDIA GPUVendor = "nvidia"
+	// GPUVendorAMD represents AMD GPUs exposed by the ROCm device plugin.
+	GPUVendorAMD GPUVendor = "amd"
+	// GPUVendorIntel represents Intel GPUs exposed by the Intel GPU device plugin.
+	GPUVendorIntel GPUVendor = "intel"
+)
+
+// DesktopNetworkConfig represents restrictions on the network access of desktops.
+// Enforcing them requires a network plugin that supports NetworkPolicies.
+type DesktopNetworkConfig struct {
+	// Restrictions on traffic to desktops. Connections from the kVDI app to the
+	// desktop proxy are always allowed. When omitted, ingress is not restricted.
+	Ingress *NetworkRulesConfig `json:"ingress,omitempty"`
+	// Restrictions on traffic leaving desktops. When omitted, egress is not restricted.
+	Egress *NetworkRulesConfig `json:"egress,omitempty"`
+}
+
+// NetworkRulesConfig represents the traffic allowed in one direction. Traffic not
+// allowed by any of the fields is denied, so an empty config denies all traffic.
+type NetworkRulesConfig struct {
+	// CIDR blocks, e.g. `10.0.0.0/8`, that traffic is allowed to or from.
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+	// Allows DNS lookups to any destination. Only applies to egress. Setting this
+	// alone restricts desktops to DNS-only egress.
+	AllowDNS bool `json:"allowDNS,omitempty"`
+}
+
+// SecurityProfilesConfig represents the AppArmor, seccomp, and SELinux profiles to
+// apply to the desktop container.
+type SecurityProfilesConfig struct {
+	// The seccomp profile to apply. One of `runtime/default`, `unconfined`, or
+	// `localhost/<path>` for a profile relative to the kubelet's seccomp profile root
+	// on the nodes.
+	Seccomp string `json:"seccomp,omitempty"`
+	// The AppArmor profile to apply. One of `runtime/default`, `unconfined`, or
+	// `localhost/<name>` for a profile loaded on the nodes. Templates with a profile
+	// are rejected if no nodes have AppArmor enabled.
+	AppArmor string `json:"appArmor,omitempty"`
+	// The SELinux context to apply.
+	SELinux *corev1.SELinuxOptions `json:"seLinux,omitempty"`
+}
+
+// GPUConfig represents the GPUs to attach to desktops booted from a template.
+type GPUConfig struct {
+	// The vendor of the GPUs. Used to determine the device plugin resource when
+	// `resourceName` is not set. Defaults to `nvidia`.
+	Vendor GPUVendor `json:"vendor,omitempty"`
+	// The number of GPUs to attach to each desktop. Defaults to 1.
+	Count int64 `json:"count,omitempty"`
+	// The extended resource advertised by the device plugin. Defaults to `nvidia.com/gpu`,
+	// `amd.com/gpu`, or `gpu.intel.com/i915` depending on the vendor.
+	ResourceName corev1.ResourceName `json:"resourceName,omitempty"`
+	// Node labels that are required for scheduling desktops, for example to select a
+	// specific GPU model.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations to add to desktops. When omitted, a toleration for any taint with the
+	// same key as the resource name is added, which is how GPU nodes are commonly tainted.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// AvailabilityConfig represents the windows of time during which desktops can be
+// launched from a template.
+type AvailabilityConfig struct {
+	// The IANA time zone to evaluate the window schedules in, e.g. `America/New_York`.
+	// Defaults to `UTC`.
+	TimeZone string `json:"timeZone,omitempty"`
+	// The windows during which the template is available. Requests for new sessions
+	// outside of all windows are denied.
+	Windows []AvailabilityWindow `json:"windows"`
+	// Set to true to also destroy running desktops booted from this template when
+	// the window they are running in closes.
+	TerminateSessions bool `json:"terminateSessions,omitempty"`
+}
+
+// AvailabilityWindow represents a recurring window of time.
+type AvailabilityWindow struct {
+	// A standard five-field cron expression for when the window opens,
+	// e.g. `0 8 * * MON-FRI`.
+	Schedule string `json:"schedule"`
+	// How long the window remains open after it opens, e.g. `9h`. Cannot be
+	// longer than a week.
+	Duration string `json:"duration"`
 }
 
 // DesktopConfig represents configurations for the template and desktops booted
@@ -75,14 +412,67 @@ type DesktopConfig struct {
 	// websockify requests validated by mTLS to this socket.
 	// Must be in the format of `tcp://{host}:{port}` or `unix://{path}`.
 	SocketAddr string `json:"socketAddr,omitempty"`
-	// The type of service listening on the configured socket. Can either be `xpra` or
-	// `xvnc`. Currently `xpra` is used to serve "app profiles" and `xvnc` to serve full
-	// desktops. Defaults to `xvnc`.
+	// The type of service listening on the configured socket. Can either be `xpra`,
+	// `xvnc`, or `rdp`. Currently `xpra` is used to serve "app profiles" and `xvnc` to
+	// serve full desktops. `rdp` serves the display of an RDP server through a guacd
+	// sidecar and ignores the `socketAddr`. Watermarks and session recordings are not
+	// supported over RDP, and connections that require them are refused. Defaults to `xvnc`.
 	SocketType SocketType `json:"socketType,omitempty"`
+	// Configurations for connecting to the RDP server when the `socketType` is `rdp`.
+	RDP *RDPConfig `json:"rdp,omitempty"`
 	// AllowFileTransfer will mount the user's home directory inside the kvdi-proxy image.
 	// This enables the API endpoint for exploring, downloading, and uploading files to
 	// desktop sessions booted from this template.
 	AllowFileTransfer bool `json:"allowFileTransfer,omitempty"`
+	// FileTransfer restricts the directions files can be transferred when
+	// `allowFileTransfer` is set. `upload` only allows sending files to desktops
+	// and `download` only allows retrieving them. Defaults to `bidirectional`.
+	FileTransfer FileTransferPolicy `json:"fileTransfer,omitempty"`
+	// Clipboard restricts the directions clipboard contents are synced between clients
+	// and desktops booted from this template. `one-way-in` only allows pasting into
+	// desktops and `one-way-out` only allows copying out of them. Defaults to
+	// `bidirectional`. Users are additionally subject to the `clipboard-in` and
+	// `clipboard-out` verbs in their roles.
+	Clipboard ClipboardPolicy `json:"clipboard,omitempty"`
+	// AllowMicrophone will let clients stream audio from their microphone into desktop
+	// sessions booted from this template over the audio websocket. Clients send opus/webm
+	// (or any other format gstreamer can decode) and the kvdi-proxy writes it to a virtual
+	// PulseAudio source inside the desktop. Without it, audio is playback only. Microphone
+	// input is off by default, including for templates that accepted it before the
+	// setting was added.
+	AllowMicrophone bool `json:"allowMicrophone,omitempty"`
+	// AllowSmartCard will enable the API endpoint for redirecting a client's smart card
+	// into desktop sessions booted from this template. The kvdi-proxy will forward APDUs
+	// from the client to a pcscd bridge (e.g. vpcd) listening on a socket inside the image.
+	// The image is expected to start the bridge at the path provided in the
+	// SMARTCARD_SOCK_ADDR environment variable.
+	AllowSmartCard bool `json:"allowSmartCard,omitempty"`
+	// AllowPrinting will add a virtual PDF printer to desktop sessions booted from this
+	// template and enable the API endpoint for delivering print jobs to the client. The
+	// image is expected to write printed documents as PDFs to the directory provided in
+	// the PRINT_SPOOL_DIR environment variable, where the kvdi-proxy picks them up and
+	// sends them to the connected client for printing locally.
+	AllowPrinting bool `json:"allowPrinting,omitempty"`
+	// USB configures redirecting client USB devices into desktop sessions booted
+	// from this template over USB/IP.
+	USB *USBConfig `json:"usb,omitempty"`
+	// Watermark will overlay a translucent watermark containing the username, client
+	// IP address, and connection time onto the display of desktop sessions booted from
+	// this template. Watermarked connections are restricted to raw encoding so expect
+	// higher bandwidth usage.
+	Watermark bool `json:"watermark,omitempty"`
+	// RecordSessions will record the display of desktop sessions booted from this
+	// template and upload the recordings to the storage configured in the VDICluster.
+	// Recordings are stored in the FBS format and can be listed and downloaded from
+	// the API by users with access to the `recordings` resource.
+	RecordSessions bool `json:"recordSessions,omitempty"`
+	// ReconnectTimeout is how long the display of a desktop session booted from this
+	// template is held open after the client's connection drops, in the format of a
+	// Go duration string (e.g. `30s`). A client reconnecting within this time resumes
+	// the existing session, including its clipboard, without a new handshake with the
+	// display server. Disabled when unset, and not used for watermarked or recorded
+	// displays.
+	ReconnectTimeout string `json:"reconnectTimeout,omitempty"`
 	// The image to use for the sidecar that proxies mTLS connections to the local
 	// VNC server inside the Desktop. Defaults to the public kvdi-proxy image
 	// matching the version of the currrently running manager.
@@ -91,13 +481,95 @@ type DesktopConfig struct {
 	// are supported. Defaults to `supervisord` (but depending on how much I like systemd
 	// in this use case, that could change).
 	Init DesktopInit `json:"init,omitempty"`
+	// Display limits the display stream sent to clients of desktop sessions booted
+	// from this template. Users can lower the limits for their own sessions while
+	// connected, e.g. when on a slow network.
+	Display *DisplayConfig `json:"display,omitempty"`
+}
+
+// DisplayConfig represents limits on the display stream of desktops. The limits
+// apply to VNC displays, and are ignored for desktops served over RDP.
+type DisplayConfig struct {
+	// The highest JPEG quality level, from 0 (lowest) to 9 (highest), the display is
+	// encoded with. This only applies to clients using the Tight encoding. Unlimited
+	// when unset.
+	MaxQuality *int32 `json:"maxQuality,omitempty"`
+	// The most frames per second sent to each client. Unlimited when unset.
+	MaxFrameRate int32 `json:"maxFrameRate,omitempty"`
+	// The most bandwidth the display stream of each client may use, as a quantity
+	// of bytes per second (e.g. `1Mi`). Unlimited when unset.
+	MaxBandwidth string `json:"maxBandwidth,omitempty"`
+}
+
+// RDPConfig represents configurations for desktops served over RDP.
+type RDPConfig struct {
+	// The address of the RDP server in the format of `{host}:{port}`. Defaults to
+	// `localhost:3389`, which is an RDP server (e.g. xrdp) running in the desktop
+	// container. This can also be an external Windows host.
+	Address string `json:"address,omitempty"`
+	// The security mode to negotiate with the RDP server. Defaults to `any`.
+	Security RDPSecurity `json:"security,omitempty"`
+	// Set to true to accept the certificate presented by the RDP server without
+	// verifying it.
+	IgnoreCert bool `json:"ignoreCert,omitempty"`
+	// The domain to log into the RDP server with.
+	Domain string `json:"domain,omitempty"`
+	// The name of a secret in the namespace of the desktop containing the credentials
+	// to log into the RDP server with. The secret should contain a `password` key and
+	// optionally a `username` key. When no username is provided, the name of the kVDI
+	// user connecting to the desktop is used. The credentials are only mounted in the
+	// kvdi-proxy and are never sent to clients.
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// The image to use for the guacd sidecar. Defaults to `guacamole/guacd:1.3.0`.
+	// Other images are expected to provide guacd at the same path as the official ones.
+	GuacdImage string `json:"guacdImage,omitempty"`
+}
+
+// USBConfig represents configurations for redirecting client USB devices into
+// desktops. Clients export devices with a USB/IP server and connect it to the
+// usb websocket endpoint of a desktop session. The kvdi-proxy imports the device
+// and attaches it to the vhci-hcd driver on the node, which must have the
+// vhci-hcd kernel module loaded. This requires the kvdi-proxy to run privileged,
+// and the node's USB devices to be mounted into the desktop container.
+type USBConfig struct {
+	// The classes of devices that may be redirected. Devices whose class is
+	// defined per interface are only allowed if every interface is one of these
+	// classes. USB redirection is disabled when empty.
+	AllowedClasses []USBDeviceClass `json:"allowedClasses,omitempty"`
 }
 
 // DesktopTemplateStatus defines the observed state of DesktopTemplate
 type DesktopTemplateStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
-	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+	// The availability of GPUs for desktops booted from this template. This is
+	// populated by the API when templates with GPUs are retrieved.
+	GPU *GPUStatus `json:"gpu,omitempty"`
+}
+
+// DesktopTemplateRevision represents a recorded revision of a DesktopTemplate.
+type DesktopTemplateRevision struct {
+	// The revision number. This is the generation of the template when the revision
+	// was recorded.
+	Revision int64 `json:"revision"`
+	// The version label of the template at this revision.
+	Version string `json:"version,omitempty"`
+	// When the revision was recorded.
+	CreatedAt metav1.Time `json:"createdAt"`
+	// Whether this is the current revision of the template.
+	Current bool `json:"current"`
+	// The spec of the template at this revision.
+	Spec DesktopTemplateSpec `json:"spec"`
+}
+
+// GPUStatus represents the availability of GPUs for a template.
+type GPUStatus struct {
+	// Whether there are nodes that desktops booted from the template can be
+	// scheduled on.
+	Schedulable bool `json:"schedulable"`
+	// The number of nodes desktops booted from the template can be scheduled on.
+	Nodes int `json:"nodes"`
+	// The total number of GPUs allocatable on those nodes. This includes GPUs that
+	// are already in use.
+	Allocatable int64 `json:"allocatable"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -2,14 +2,18 @@ package v1alpha1
 
 import (
 	"fmt"
+	"net"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/version"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // GetInitSystem returns the init system used by the docker image in this template.
@@ -32,12 +36,201 @@ func (t *DesktopTemplate) RootEnabled() bool {
 // FileTransferEnabled returns true if desktops booted from the template should
 // allow file transfer.
 func (t *DesktopTemplate) FileTransferEnabled() bool {
+	return t.GetFileTransferPolicy() != FileTransferNone
+}
+
+// GetFileTransferPolicy returns the directions files can be transferred to and
+// from desktops booted from the template.
+func (t *DesktopTemplate) GetFileTransferPolicy() FileTransferPolicy {
+	if t.Spec.Config == nil || !t.Spec.Config.AllowFileTransfer {
+		return FileTransferNone
+	}
+	if t.Spec.Config.FileTransfer != "" {
+		return t.Spec.Config.FileTransfer
+	}
+	return FileTransferBidirectional
+}
+
+// AllowsUpload returns true if the policy allows sending files to desktops. An
+// empty policy allows both directions.
+func (f FileTransferPolicy) AllowsUpload() bool {
+	return f == "" || f == FileTransferBidirectional || f == FileTransferUpload
+}
+
+// AllowsDownload returns true if the policy allows retrieving files from desktops.
+// An empty policy allows both directions.
+func (f FileTransferPolicy) AllowsDownload() bool {
+	return f == "" || f == FileTransferBidirectional || f == FileTransferDownload
+}
+
+// GetClipboardPolicy returns the directions clipboard contents are synced for
+// desktops booted from the template.
+func (t *DesktopTemplate) GetClipboardPolicy() ClipboardPolicy {
+	if t.Spec.Config != nil && t.Spec.Config.Clipboard != "" {
+		return t.Spec.Config.Clipboard
+	}
+	return ClipboardBidirectional
+}
+
+// AllowsIn returns true if the policy allows syncing clipboard contents from clients
+// to desktops. An empty policy allows both directions.
+func (c ClipboardPolicy) AllowsIn() bool {
+	return c == "" || c == ClipboardBidirectional || c == ClipboardOneWayIn
+}
+
+// AllowsOut returns true if the policy allows syncing clipboard contents from
+// desktops to clients. An empty policy allows both directions.
+func (c ClipboardPolicy) AllowsOut() bool {
+	return c == "" || c == ClipboardBidirectional || c == ClipboardOneWayOut
+}
+
+// MicrophoneEnabled returns true if desktops booted from the template should
+// accept microphone input from clients.
+func (t *DesktopTemplate) MicrophoneEnabled() bool {
 	if t.Spec.Config != nil {
-		return t.Spec.Config.AllowFileTransfer
+		return t.Spec.Config.AllowMicrophone
+	}
+	return false
+}
+
+// SmartCardEnabled returns true if desktops booted from the template should
+// allow smart card redirection.
+func (t *DesktopTemplate) SmartCardEnabled() bool {
+	if t.Spec.Config != nil {
+		return t.Spec.Config.AllowSmartCard
+	}
+	return false
+}
+
+// PrintingEnabled returns true if desktops booted from the template should
+// deliver print jobs to clients.
+func (t *DesktopTemplate) PrintingEnabled() bool {
+	if t.Spec.Config != nil {
+		return t.Spec.Config.AllowPrinting
+	}
+	return false
+}
+
+// USBRedirectionEnabled returns true if desktops booted from the template should
+// allow redirecting client USB devices.
+func (t *DesktopTemplate) USBRedirectionEnabled() bool {
+	return len(t.GetUSBAllowedClasses()) > 0
+}
+
+// GetUSBAllowedClasses returns the classes of USB devices that may be redirected
+// into desktops booted from the template.
+func (t *DesktopTemplate) GetUSBAllowedClasses() []USBDeviceClass {
+	if t.Spec.Config != nil && t.Spec.Config.USB != nil {
+		return t.Spec.Config.USB.AllowedClasses
+	}
+	return nil
+}
+
+// WatermarkEnabled returns true if the display of desktops booted from the template
+// should be watermarked.
+func (t *DesktopTemplate) WatermarkEnabled() bool {
+	if t.Spec.Config != nil {
+		return t.Spec.Config.Watermark
+	}
+	return false
+}
+
+// GetDisplayLimits returns the limits on the display stream of desktops booted
+// from the template. Invalid bandwidth quantities are ignored.
+func (t *DesktopTemplate) GetDisplayLimits() *v1.DisplaySettings {
+	limits := &v1.DisplaySettings{}
+	if t.Spec.Config == nil || t.Spec.Config.Display == nil {
+		return limits
+	}
+	display := t.Spec.Config.Display
+	if display.MaxQuality != nil && *display.MaxQuality >= 0 {
+		quality := *display.MaxQuality
+		if quality > v1.MaxDisplayQuality {
+			quality = v1.MaxDisplayQuality
+		}
+		limits.Quality = &quality
+	}
+	if display.MaxFrameRate > 0 {
+		limits.FrameRate = display.MaxFrameRate
+	}
+	if display.MaxBandwidth != "" {
+		if qty, err := resource.ParseQuantity(display.MaxBandwidth); err == nil && qty.Value() > 0 {
+			limits.Bandwidth = qty.Value()
+		}
+	}
+	return limits
+}
+
+// RecordingEnabled returns true if the display of desktops booted from the template
+// should be recorded.
+func (t *DesktopTemplate) RecordingEnabled() bool {
+	if t.Spec.Config != nil {
+		return t.Spec.Config.RecordSessions
+	}
+	return false
+}
+
+// GetReconnectTimeout returns how long the display of desktops booted from the
+// template is held open for a dropped client to reconnect. Zero means reconnecting
+// is disabled.
+func (t *DesktopTemplate) GetReconnectTimeout() time.Duration {
+	if t.Spec.Config != nil && t.Spec.Config.ReconnectTimeout != "" {
+		if dur, err := time.ParseDuration(t.Spec.Config.ReconnectTimeout); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return 0
+}
+
+// GetMaxSessions returns the maximum number of concurrent sessions allowed for
+// this template. Zero means there is no limit.
+func (t *DesktopTemplate) GetMaxSessions() int32 {
+	return t.Spec.MaxSessions
+}
+
+// AllowsNamespace returns true if desktops can be launched from the template in
+// the given namespace.
+func (t *DesktopTemplate) AllowsNamespace(namespace string) bool {
+	if len(t.Spec.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range t.Spec.Namespaces {
+		if ns == namespace {
+			return true
+		}
 	}
 	return false
 }
 
+// GetIdleTimeout returns the duration a desktop booted from this template can go
+// without a connection before it is destroyed. If not set on the template, the
+// value configured on the given VDICluster is returned.
+func (t *DesktopTemplate) GetIdleTimeout(cluster *VDICluster) time.Duration {
+	if t.Spec.IdleTimeout != "" {
+		if dur, err := time.ParseDuration(t.Spec.IdleTimeout); err == nil {
+			return dur
+		}
+	}
+	return cluster.GetIdleTimeout()
+}
+
+// GetMaxLifetime returns how long a desktop booted from this template can run before
+// it is destroyed. If not set on the template, the max session length configured on
+// the given VDICluster is returned.
+func (t *DesktopTemplate) GetMaxLifetime(cluster *VDICluster) time.Duration {
+	if t.Spec.MaxLifetime != "" {
+		if dur, err := time.ParseDuration(t.Spec.MaxLifetime); err == nil {
+			return dur
+		}
+	}
+	return cluster.GetMaxSessionLength()
+}
+
+// GetUserData returns the first-boot script for desktops booted from this template.
+func (t *DesktopTemplate) GetUserData() string {
+	return t.Spec.UserData
+}
+
 // GetKVDIVNCProxyImage returns the kvdi-proxy image for the desktop instance.
 func (t *DesktopTemplate) GetKVDIVNCProxyImage() string {
 	if t.Spec.Config != nil && t.Spec.Config.ProxyImage != "" {
@@ -65,9 +258,10 @@ func (t *DesktopTemplate) GetDesktopPullSecrets() []corev1.LocalObjectReference
 	return t.Spec.ImagePullSecrets
 }
 
-// GetDesktopResources returns the resource requirements for this instance.
+// GetDesktopResources returns the resource requirements for this instance,
+// including any GPUs.
 func (t *DesktopTemplate) GetDesktopResources() corev1.ResourceRequirements {
-	return t.Spec.Resources
+	return t.withGPUResources(*t.Spec.Resources.DeepCopy())
 }
 
 // GetDesktopServiceAccount returns the service account for this instance.
@@ -95,6 +289,75 @@ func (t *DesktopTemplate) GetDisplaySocketType() SocketType {
 	return SocketXVNC
 }
 
+// GetDesktopReadinessProbe returns the probe used to tell when the display in a
+// desktop is accepting connections. Unix sockets are checked for from inside the
+// desktop image, and TCP sockets are connected to. Desktops served over RDP are
+// not probed, since the RDP server is not necessarily running in the desktop pod.
+func (t *DesktopTemplate) GetDesktopReadinessProbe() *corev1.Probe {
+	if t.RDPEnabled() {
+		return nil
+	}
+	probe := &corev1.Probe{
+		PeriodSeconds:    2,
+		FailureThreshold: 3,
+	}
+	addr := t.GetDisplaySocketAddr()
+	if strings.HasPrefix(addr, "tcp://") {
+		_, port, err := net.SplitHostPort(strings.TrimPrefix(addr, "tcp://"))
+		if err != nil {
+			return nil
+		}
+		probe.Handler = corev1.Handler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.Parse(port)},
+		}
+		return probe
+	}
+	probe.Handler = corev1.Handler{
+		Exec: &corev1.ExecAction{
+			Command: []string{"test", "-S", strings.TrimPrefix(addr, "unix://")},
+		},
+	}
+	return probe
+}
+
+// RDPEnabled returns true if desktops booted from this template are served over RDP.
+func (t *DesktopTemplate) RDPEnabled() bool {
+	return t.GetDisplaySocketType() == SocketRDP
+}
+
+// GetRDPConfig returns the RDP configuration for this template, or an empty one
+// if none is set.
+func (t *DesktopTemplate) GetRDPConfig() *RDPConfig {
+	if t.Spec.Config != nil && t.Spec.Config.RDP != nil {
+		return t.Spec.Config.RDP
+	}
+	return &RDPConfig{}
+}
+
+// GetAddress returns the address of the RDP server.
+func (r *RDPConfig) GetAddress() string {
+	if r.Address != "" {
+		return r.Address
+	}
+	return v1.DefaultRDPAddr
+}
+
+// GetSecurity returns the security mode to negotiate with the RDP server.
+func (r *RDPConfig) GetSecurity() RDPSecurity {
+	if r.Security != "" {
+		return r.Security
+	}
+	return RDPSecurityAny
+}
+
+// GetGuacdImage returns the image to use for the guacd sidecar.
+func (r *RDPConfig) GetGuacdImage() string {
+	if r.GuacdImage != "" {
+		return r.GuacdImage
+	}
+	return v1.DefaultGuacdImage
+}
+
 // GetDesktopEnvVars returns the environment variables for a desktop pod.
 func (t *DesktopTemplate) GetDesktopEnvVars(desktop *Desktop) []corev1.EnvVar {
 	envVars := []corev1.EnvVar{
@@ -113,7 +376,20 @@ func (t *DesktopTemplate) GetDesktopEnvVars(desktop *Desktop) []corev1.EnvVar {
 			Value: "true",
 		})
 	}
-	return envVars
+	if t.SmartCardEnabled() {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  v1.SmartCardSockEnvVar,
+			Value: strings.TrimPrefix(v1.DefaultSmartCardSocketAddr, "unix://"),
+		})
+	}
+	if t.PrintingEnabled() {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  v1.PrintSpoolEnvVar,
+			Value: v1.DesktopPrintSpoolPath,
+		})
+	}
+	envVars = append(envVars, t.Spec.Env...)
+	return overlayEnvVars(envVars, t.getSessionEnvVars(desktop))
 }
 
 // GetDesktopPodSecurityContext returns the security context for pods booted
@@ -137,23 +413,36 @@ func (t *DesktopTemplate) GetDesktopContainerSecurityContext() *corev1.SecurityC
 	if t.Spec.Config != nil {
 		capabilities = append(capabilities, t.Spec.Config.Capabilities...)
 	}
+	// privileged containers are not confined by security profiles
+	privileged := &v1.TrueVal
+	if t.HasSecurityProfiles() {
+		privileged = &v1.FalseVal
+	}
 	return &corev1.SecurityContext{
-		Privileged: &v1.TrueVal,
+		Privileged: privileged,
 		Capabilities: &corev1.Capabilities{
 			Add: capabilities,
 		},
+		SELinuxOptions: t.GetSELinuxOptions(),
 	}
 }
 
 var (
-	tmpVolume     = "tmp"
-	runVolume     = "run"
-	shmVolume     = "shm"
-	tlsVolume     = "tls"
-	homeVolume    = "home"
-	cgroupsVolume = "cgroups"
-	runLockVolume = "run-lock"
-	vncSockVolume = "vnc-sock"
+	tmpVolume      = "tmp"
+	runVolume      = "run"
+	shmVolume      = "shm"
+	tlsVolume      = "tls"
+	homeVolume     = "home"
+	cgroupsVolume  = "cgroups"
+	runLockVolume  = "run-lock"
+	vncSockVolume  = "vnc-sock"
+	userDataVolume = "userdata"
+	recordVolume   = "recordings"
+	rdpVolume      = "rdp-credentials"
+	usbVolume      = "usb"
+	printVolume    = "print-spool"
+
+	userDataMode int32 = 0700
 )
 
 // GetDesktopVolumes returns the volumes to mount to desktop pods.
@@ -200,6 +489,65 @@ func (t *DesktopTemplate) GetDesktopVolumes(cluster *VDICluster, desktop *Deskto
 				},
 			},
 		},
+		{
+			// The secret only exists when the template or user supplies a
+			// first-boot script.
+			Name: userDataVolume,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  desktop.GetUserDataSecretName(),
+					DefaultMode: &userDataMode,
+					Optional:    &v1.TrueVal,
+				},
+			},
+		},
+	}
+
+	// Recordings are only mounted in the kvdi-proxy so they cannot be tampered
+	// with from inside the desktop.
+	if t.RecordingEnabled() {
+		volumes = append(volumes, corev1.Volume{
+			Name: recordVolume,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+	}
+
+	// Print jobs are handed from the desktop to the kvdi-proxy through a shared
+	// spool directory.
+	if t.PrintingEnabled() {
+		volumes = append(volumes, corev1.Volume{
+			Name: printVolume,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+	}
+
+	// RDP credentials are also only mounted in the kvdi-proxy.
+	if t.RDPEnabled() && t.GetRDPConfig().CredentialsSecret != "" {
+		volumes = append(volumes, corev1.Volume{
+			Name: rdpVolume,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: t.GetRDPConfig().CredentialsSecret,
+				},
+			},
+		})
+	}
+
+	// Redirected USB devices are attached to the node, so its device nodes are
+	// mounted into the desktop where they can be picked up as they appear.
+	if t.USBRedirectionEnabled() {
+		volumes = append(volumes, corev1.Volume{
+			Name: usbVolume,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: v1.HostUSBPath,
+				},
+			},
+		})
 	}
 
 	// A PVC claim for the user if specified, otherwise use an EmptyDir.
@@ -221,6 +569,18 @@ func (t *DesktopTemplate) GetDesktopVolumes(cluster *VDICluster, desktop *Deskto
 		})
 	}
 
+	// Claims chosen with template parameters
+	for _, claim := range t.getParameterClaims(desktop.Spec.Parameters) {
+		volumes = append(volumes, corev1.Volume{
+			Name: claim.volume,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: claim.claimName,
+				},
+			},
+		})
+	}
+
 	// If systemd we need to add a few more temp filesystems and bind mount
 	// /sys/fs/cgroup.
 	if t.GetInitSystem() == InitSystemd {
@@ -267,6 +627,29 @@ func (t *DesktopTemplate) GetDesktopVolumeMounts(cluster *VDICluster, desktop *D
 			Name:      homeVolume,
 			MountPath: fmt.Sprintf(v1.DesktopHomeFmt, desktop.GetUser()),
 		},
+		{
+			Name:      userDataVolume,
+			MountPath: v1.UserDataMountPath,
+			ReadOnly:  true,
+		},
+	}
+	for _, claim := range t.getParameterClaims(desktop.Spec.Parameters) {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      claim.volume,
+			MountPath: claim.mountPath,
+		})
+	}
+	if t.USBRedirectionEnabled() {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      usbVolume,
+			MountPath: v1.DesktopUSBPath,
+		})
+	}
+	if t.PrintingEnabled() {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      printVolume,
+			MountPath: v1.DesktopPrintSpoolPath,
+		})
 	}
 	if t.GetInitSystem() == InitSystemd {
 		mounts = append(mounts, []corev1.VolumeMount{
@@ -279,6 +662,66 @@ func (t *DesktopTemplate) GetDesktopVolumeMounts(cluster *VDICluster, desktop *D
 	return mounts
 }
 
+// dotfilesScript clones the repository provided in the environment into the home
+// directory and links any top-level dotfiles into place. Failures are logged but
+// do not keep the desktop from starting.
+const dotfilesScript = `
+[ -z "${DOTFILES_REPO}" ] && exit 0
+dest="${HOME_DIR}/.dotfiles"
+if [ -d "${dest}/.git" ] ; then
+  git -C "${dest}" pull --ff-only || echo "Failed to update dotfiles"
+else
+  git clone ${DOTFILES_BRANCH:+--branch "${DOTFILES_BRANCH}"} ${DOTFILES_DEPTH:+--depth "${DOTFILES_DEPTH}"} "${DOTFILES_REPO}" "${dest}" || { echo "Failed to clone dotfiles" ; exit 0 ; }
+fi
+for f in "${dest}"/.[!.]* ; do
+  name="$(basename "${f}")"
+  case "${name}" in
+    .git|.github|.gitignore|.gitmodules) continue ;;
+  esac
+  [ -e "${f}" ] && ln -sfn ".dotfiles/${name}" "${HOME_DIR}/${name}"
+done
+exit 0
+`
+
+// GetDesktopInitContainers returns the init containers for desktop pods. When
+// dotfiles are enabled for the desktop, the user's repository is cloned into
+// their home directory.
+func (t *DesktopTemplate) GetDesktopInitContainers(cluster *VDICluster, desktop *Desktop) []corev1.Container {
+	if !desktop.DotfilesEnabled() {
+		return nil
+	}
+	homeDir := fmt.Sprintf(v1.DesktopHomeFmt, desktop.GetUser())
+	return []corev1.Container{
+		{
+			Name:            "dotfiles",
+			Image:           cluster.GetDotfilesImage(),
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         []string{"/bin/sh", "-c", dotfilesScript},
+			Env: []corev1.EnvVar{
+				{
+					Name:  "HOME_DIR",
+					Value: homeDir,
+				},
+			},
+			EnvFrom: []corev1.EnvFromSource{
+				{
+					SecretRef: &corev1.SecretEnvSource{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: desktop.GetDotfilesSecretName(),
+						},
+					},
+				},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      homeVolume,
+					MountPath: homeDir,
+				},
+			},
+		},
+	}
+}
+
 // GetDesktopProxyContainer returns the configuration for the kvdi-proxy sidecar.
 func (t *DesktopTemplate) GetDesktopProxyContainer() corev1.Container {
 	proxyVolMounts := []corev1.VolumeMount{
@@ -306,18 +749,88 @@ func (t *DesktopTemplate) GetDesktopProxyContainer() corev1.Container {
 			MountPath: v1.DesktopHomeMntPath,
 		})
 	}
+	if t.RecordingEnabled() {
+		proxyVolMounts = append(proxyVolMounts, corev1.VolumeMount{
+			Name:      recordVolume,
+			MountPath: v1.DesktopRecordingsMntPath,
+		})
+	}
+	if t.PrintingEnabled() {
+		proxyVolMounts = append(proxyVolMounts, corev1.VolumeMount{
+			Name:      printVolume,
+			MountPath: v1.DesktopPrintSpoolPath,
+		})
+	}
+	args := []string{"--vnc-addr", t.GetDisplaySocketAddr()}
+	if t.RDPEnabled() {
+		rdp := t.GetRDPConfig()
+		args = append(args, "--rdp-addr", rdp.GetAddress(), "--rdp-security", string(rdp.GetSecurity()))
+		if rdp.IgnoreCert {
+			args = append(args, "--rdp-ignore-cert")
+		}
+		if rdp.Domain != "" {
+			args = append(args, "--rdp-domain", rdp.Domain)
+		}
+		if rdp.CredentialsSecret != "" {
+			proxyVolMounts = append(proxyVolMounts, corev1.VolumeMount{
+				Name:      rdpVolume,
+				MountPath: v1.RDPCredentialsMountPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+	if policy := t.GetClipboardPolicy(); policy != ClipboardBidirectional {
+		args = append(args, "--clipboard", string(policy))
+	}
+	if policy := t.GetFileTransferPolicy(); policy != FileTransferNone && policy != FileTransferBidirectional {
+		args = append(args, "--file-transfer", string(policy))
+	}
+	if t.MicrophoneEnabled() {
+		args = append(args, "--microphone")
+	}
+	if t.SmartCardEnabled() {
+		args = append(args, "--smartcard-addr", v1.DefaultSmartCardSocketAddr)
+	}
+	if t.PrintingEnabled() {
+		args = append(args, "--print-dir", v1.DesktopPrintSpoolPath)
+	}
+	if timeout := t.GetReconnectTimeout(); timeout > 0 && !t.WatermarkEnabled() && !t.RecordingEnabled() {
+		args = append(args, "--reconnect-timeout", timeout.String())
+	}
+	if limits := t.GetDisplayLimits(); !t.RDPEnabled() {
+		if limits.Quality != nil {
+			args = append(args, "--max-quality", strconv.Itoa(int(*limits.Quality)))
+		}
+		if limits.FrameRate > 0 {
+			args = append(args, "--max-frame-rate", strconv.Itoa(int(limits.FrameRate)))
+		}
+		if limits.Bandwidth > 0 {
+			args = append(args, "--max-bandwidth", strconv.FormatInt(limits.Bandwidth, 10))
+		}
+	}
+	var securityContext *corev1.SecurityContext
+	if classes := t.GetUSBAllowedClasses(); len(classes) > 0 {
+		strs := make([]string, len(classes))
+		for i, class := range classes {
+			strs[i] = string(class)
+		}
+		args = append(args, "--usb-classes", strings.Join(strs, ","))
+		// Attaching devices to the vhci-hcd driver requires writing to sysfs
+		securityContext = &corev1.SecurityContext{Privileged: &v1.TrueVal}
+	}
 	return corev1.Container{
 		Name:            "kvdi-proxy",
 		Image:           t.GetKVDIVNCProxyImage(),
 		ImagePullPolicy: corev1.PullIfNotPresent,
-		Args:            []string{"--vnc-addr", t.GetDisplaySocketAddr()},
+		Args:            args,
 		Ports: []corev1.ContainerPort{
 			{
 				Name:          "web",
 				ContainerPort: v1.WebPort,
 			},
 		},
-		VolumeMounts: proxyVolMounts,
+		VolumeMounts:    proxyVolMounts,
+		SecurityContext: securityContext,
 		// TODO: Make these configurable
 		Resources: corev1.ResourceRequirements{
 			Requests: corev1.ResourceList{
@@ -336,6 +849,29 @@ func (t *DesktopTemplate) GetDesktopProxyContainer() corev1.Container {
 	}
 }
 
+// GetDesktopGuacdContainer returns the configuration for the guacd sidecar used to
+// serve desktops over RDP. guacd is only bound to the loopback interface so that it
+// can only be reached through the kvdi-proxy.
+func (t *DesktopTemplate) GetDesktopGuacdContainer() corev1.Container {
+	host, port, _ := net.SplitHostPort(v1.DefaultGuacdAddr)
+	return corev1.Container{
+		Name:            "guacd",
+		Image:           t.GetRDPConfig().GetGuacdImage(),
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"/usr/local/guacamole/sbin/guacd", "-f", "-b", host, "-l", port},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("128Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+		},
+	}
+}
+
 // GetLifecycle returns the lifecycle actions for a desktop container booted from
 // this template.
 func (t *DesktopTemplate) GetLifecycle() *corev1.Lifecycle {
@@ -0,0 +1,57 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SessionPoolSpec defines the desired state of SessionPool
+type SessionPoolSpec struct {
+	// The VDICluster the pooled desktops belong to.
+	VDICluster string `json:"vdiCluster"`
+	// The DesktopTemplate to boot pooled desktops from.
+	Template string `json:"template"`
+	// The number of booted desktops to keep waiting to be claimed. Requests for new
+	// sessions from the template in the namespace of the pool are given one of these
+	// desktops when one is ready, instead of booting a new one. Pooled desktops count
+	// towards the `maxSessions` of the template.
+	//
+	// Pooled desktops are booted before the user is known, so they run as the
+	// `anonymous` user for their whole lifetime. For this reason they are only
+	// handed to users without a custom first-boot script or dotfiles repository,
+	// and pools do nothing on VDIClusters with `userdataSpec` configured.
+	Size int32 `json:"size"`
+}
+
+// SessionPoolStatus defines the observed state of SessionPool
+type SessionPoolStatus struct {
+	// The number of desktops in the pool waiting to be claimed.
+	Warm int32 `json:"warm,omitempty"`
+	// The number of desktops in the pool that are running and ready to be claimed.
+	Ready int32 `json:"ready,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SessionPool is the Schema for the sessionpools API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=sessionpools,scope=Namespaced
+type SessionPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SessionPoolSpec   `json:"spec,omitempty"`
+	Status SessionPoolStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SessionPoolList contains a list of SessionPool
+type SessionPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SessionPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SessionPool{}, &SessionPoolList{})
+}
@@ -0,0 +1,70 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetVDICluster retrieves the VDICluster for this SessionPool.
+func (p *SessionPool) GetVDICluster(c client.Client) (*VDICluster, error) {
+	nn := types.NamespacedName{Name: p.Spec.VDICluster, Namespace: metav1.NamespaceAll}
+	found := &VDICluster{}
+	return found, c.Get(context.TODO(), nn, found)
+}
+
+// GetSize returns the number of desktops to keep in the pool.
+func (p *SessionPool) GetSize() int {
+	if p.Spec.Size < 0 {
+		return 0
+	}
+	return int(p.Spec.Size)
+}
+
+// GetDesktopsSelector returns the label selector to use for looking up the
+// unclaimed desktops in this pool.
+func (p *SessionPool) GetDesktopsSelector() client.MatchingLabels {
+	return client.MatchingLabels{
+		v1.SessionPoolLabel: p.GetName(),
+		v1.VDIClusterLabel:  p.Spec.VDICluster,
+	}
+}
+
+// NewDesktop returns a new unclaimed desktop for this pool.
+func (p *SessionPool) NewDesktop() *Desktop {
+	return &Desktop{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            fmt.Sprintf("%s-%s", p.Spec.Template, strings.Split(uuid.New().String(), "-")[0]),
+			Namespace:       p.GetNamespace(),
+			Labels:          p.GetDesktopsSelector(),
+			OwnerReferences: p.OwnerReferences(),
+		},
+		Spec: DesktopSpec{
+			VDICluster:  p.Spec.VDICluster,
+			Template:    p.Spec.Template,
+			SessionPool: p.GetName(),
+		},
+	}
+}
+
+// OwnerReferences returns an owner reference slice with this SessionPool
+// as the owner.
+func (p *SessionPool) OwnerReferences() []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		{
+			APIVersion:         p.APIVersion,
+			Kind:               p.Kind,
+			Name:               p.GetName(),
+			UID:                p.GetUID(),
+			Controller:         &v1.TrueVal,
+			BlockOwnerDeletion: &v1.FalseVal,
+		},
+	}
+}
@@ -0,0 +1,84 @@
+package v1alpha1
+
+import (
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VDIAnnouncement is the Schema for the vdiannouncements API. Announcements are
+// messages shown to users in the UI between their start and end times, such as
+// notices of maintenance windows or outages.
+// +kubebuilder:resource:path=vdiannouncements,scope=Cluster
+type VDIAnnouncement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// The message shown to users.
+	Message string `json:"message"`
+	// How prominently the message is shown. Defaults to `info`.
+	Severity v1.AnnouncementSeverity `json:"severity,omitempty"`
+	// When the announcement starts being shown. It is shown as soon as it is created
+	// when omitted.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// When the announcement stops being shown. It is shown until deleted when omitted.
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+	// The names of the VDIRoles the announcement is shown to. It is shown to all users
+	// when empty.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// GetSeverity returns how prominently the announcement is shown.
+func (a *VDIAnnouncement) GetSeverity() v1.AnnouncementSeverity {
+	if a.Severity != "" {
+		return a.Severity
+	}
+	return v1.AnnouncementInfo
+}
+
+// ToAnnouncement returns the announcement as it is served by the API.
+func (a *VDIAnnouncement) ToAnnouncement() *v1.Announcement {
+	out := &v1.Announcement{
+		Name:     a.GetName(),
+		Message:  a.Message,
+		Severity: a.GetSeverity(),
+		Roles:    a.Roles,
+	}
+	if a.StartTime != nil {
+		start := a.StartTime.Time
+		out.StartTime = &start
+	}
+	if a.EndTime != nil {
+		end := a.EndTime.Time
+		out.EndTime = &end
+	}
+	return out
+}
+
+// SetSchedule sets the start and end times of the announcement.
+func (a *VDIAnnouncement) SetSchedule(start, end *time.Time) {
+	a.StartTime, a.EndTime = nil, nil
+	if start != nil {
+		a.StartTime = &metav1.Time{Time: *start}
+	}
+	if end != nil {
+		a.EndTime = &metav1.Time{Time: *end}
+	}
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VDIAnnouncementList contains a list of VDIAnnouncement
+type VDIAnnouncementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VDIAnnouncement `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VDIAnnouncement{}, &VDIAnnouncementList{})
+}
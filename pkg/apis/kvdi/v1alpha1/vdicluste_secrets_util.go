@@ -1,12 +1,20 @@
 package v1alpha1
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 const (
 	// SecretsBackendK8s represents using a kubernetes secret for secret storage.
 	SecretsBackendK8s = "k8s"
 	// SecretsBackendVault represents using vault for secret storage.
 	SecretsBackendVault = "vault"
+	// SecretsBackendAWS represents using AWS Secrets Manager for secret storage.
+	SecretsBackendAWS = "awsSecretsManager"
+	// SecretsBackendGCP represents using GCP Secret Manager for secret storage.
+	SecretsBackendGCP = "gcpSecretManager"
 )
 
 // GetSecretsBackend returns the type of secrets backend this VDICluster is using.
@@ -15,10 +23,27 @@ func (c *VDICluster) GetSecretsBackend() string {
 		if c.Spec.Secrets.Vault != nil && !c.Spec.Secrets.Vault.IsUndefined() {
 			return SecretsBackendVault
 		}
+		if c.Spec.Secrets.AWSSecretsManager != nil && !c.Spec.Secrets.AWSSecretsManager.IsUndefined() {
+			return SecretsBackendAWS
+		}
+		if c.Spec.Secrets.GCPSecretManager != nil && !c.Spec.Secrets.GCPSecretManager.IsUndefined() {
+			return SecretsBackendGCP
+		}
 	}
 	return SecretsBackendK8s
 }
 
+// GetSecretsCacheTTL returns how long values read from the secrets backend are
+// cached.
+func (c *VDICluster) GetSecretsCacheTTL() time.Duration {
+	if c.Spec.Secrets != nil && c.Spec.Secrets.CacheTTL != "" {
+		if ttl, err := time.ParseDuration(c.Spec.Secrets.CacheTTL); err == nil {
+			return ttl
+		}
+	}
+	return time.Hour
+}
+
 // GetAuthRole returns the auth role to use when connecting to a vault server.
 func (v *VaultConfig) GetAuthRole() string {
 	if v.AuthRole != "" {
@@ -34,3 +59,50 @@ func (v *VaultConfig) GetSecretsPath() string {
 	}
 	return "kvdi"
 }
+
+// IsKVv2 returns true if the secrets path is in a KV version 2 secrets engine.
+func (v *VaultConfig) IsKVv2() bool {
+	return v.KVVersion == VaultKVv2
+}
+
+// GetKVMountPath returns the path where the KV version 2 secrets engine is mounted.
+func (v *VaultConfig) GetKVMountPath() string {
+	if v.KVMountPath != "" {
+		return strings.Trim(v.KVMountPath, "/")
+	}
+	return strings.Split(strings.TrimPrefix(v.GetSecretsPath(), "/"), "/")[0]
+}
+
+// GetSecretsPrefix returns the prefix to add to the names of secrets in AWS
+// Secrets Manager.
+func (a *AWSSecretsManagerConfig) GetSecretsPrefix() string {
+	if a.SecretsPrefix != "" {
+		return a.SecretsPrefix
+	}
+	return "kvdi/"
+}
+
+// GetEndpoint returns the URL of the Secrets Manager API.
+func (a *AWSSecretsManagerConfig) GetEndpoint() string {
+	if a.Endpoint != "" {
+		return strings.TrimSuffix(a.Endpoint, "/")
+	}
+	return fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", a.Region)
+}
+
+// GetSecretsPrefix returns the prefix to add to the names of secrets in GCP
+// Secret Manager.
+func (g *GCPSecretManagerConfig) GetSecretsPrefix() string {
+	if g.SecretsPrefix != "" {
+		return g.SecretsPrefix
+	}
+	return "kvdi-"
+}
+
+// GetEndpoint returns the URL of the Secret Manager API.
+func (g *GCPSecretManagerConfig) GetEndpoint() string {
+	if g.Endpoint != "" {
+		return strings.TrimSuffix(g.Endpoint, "/")
+	}
+	return "https://secretmanager.googleapis.com"
+}
@@ -0,0 +1,22 @@
+package v1alpha1
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetAnnouncements returns a list of all the VDIAnnouncements that apply to this
+// cluster instance.
+func (v *VDICluster) GetAnnouncements(c client.Client) ([]VDIAnnouncement, error) {
+	announcementList := &VDIAnnouncementList{}
+	return announcementList.Items, c.List(
+		context.TODO(),
+		announcementList,
+		client.InNamespace(metav1.NamespaceAll),
+		client.MatchingLabels{v1.RoleClusterRefLabel: v.GetName()},
+	)
+}
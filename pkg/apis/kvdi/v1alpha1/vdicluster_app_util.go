@@ -2,6 +2,10 @@ package v1alpha1
 
 import (
 	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/version"
@@ -29,6 +33,21 @@ func (c *VDICluster) GetServiceAnnotations() map[string]string {
 	return annotations
 }
 
+// GetServiceAccountAnnotations returns the annotations to apply to the kvdi app
+// service account.
+func (c *VDICluster) GetServiceAccountAnnotations() map[string]string {
+	annotations := make(map[string]string)
+	for k, v := range c.GetAnnotations() {
+		annotations[k] = v
+	}
+	if c.Spec.App != nil {
+		for k, v := range c.Spec.App.ServiceAccountAnnotations {
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
+
 // GetAppReplicas returns the number of app replicas to run in this VDICluster.
 // TODO: auto-scaling?
 func (c *VDICluster) GetAppReplicas() *int32 {
@@ -38,6 +57,17 @@ func (c *VDICluster) GetAppReplicas() *int32 {
 	return &v1.DefaultReplicas
 }
 
+// GetAppDrainTimeout returns how long app instances should wait for active websocket
+// connections to close when shutting down.
+func (c *VDICluster) GetAppDrainTimeout() time.Duration {
+	if c.Spec.App != nil && c.Spec.App.DrainTimeout != "" {
+		if dur, err := time.ParseDuration(c.Spec.App.DrainTimeout); err == nil {
+			return dur
+		}
+	}
+	return v1.DefaultDrainTimeout
+}
+
 // GetAppResources returns the resource requirements for the app deployments.
 func (c *VDICluster) GetAppResources() corev1.ResourceRequirements {
 	if c.Spec.App != nil {
@@ -77,14 +107,125 @@ func (c *VDICluster) EnableCORS() bool {
 	return false
 }
 
-// AuditLogEnabled returns true if auditing events should be logged to stdout.
+// AuditLogEnabled returns true if auditing events should be logged.
 func (c *VDICluster) AuditLogEnabled() bool {
 	if c.Spec.App != nil {
-		return c.Spec.App.AuditLog
+		return c.Spec.App.AuditLog || c.Spec.App.Audit != nil
 	}
 	return false
 }
 
+// GetAuditLogBackend returns the backend to send audit events to.
+func (c *VDICluster) GetAuditLogBackend() AuditLogBackend {
+	if c.Spec.App != nil && c.Spec.App.Audit != nil && c.Spec.App.Audit.Backend != "" {
+		return c.Spec.App.Audit.Backend
+	}
+	return AuditLogStdout
+}
+
+// GetAuditLogFilePath returns the file to append audit events to when using
+// the file backend.
+func (c *VDICluster) GetAuditLogFilePath() string {
+	if c.Spec.App != nil && c.Spec.App.Audit != nil && c.Spec.App.Audit.FilePath != "" {
+		return c.Spec.App.Audit.FilePath
+	}
+	return "/var/log/kvdi/audit.log"
+}
+
+// GetAuditWebhookConfig returns the configuration for the audit webhook backend,
+// or nil if there is none.
+func (c *VDICluster) GetAuditWebhookConfig() *AuditWebhookConfig {
+	if c.Spec.App != nil && c.Spec.App.Audit != nil {
+		return c.Spec.App.Audit.Webhook
+	}
+	return nil
+}
+
+// GetAuditWebhookTimeout returns the timeout for requests to the audit webhook.
+func (c *VDICluster) GetAuditWebhookTimeout() time.Duration {
+	if cfg := c.GetAuditWebhookConfig(); cfg != nil {
+		return cfg.GetTimeout()
+	}
+	return 5 * time.Second
+}
+
+// AccessLogEnabled returns true if requests to the app should be logged to stdout.
+func (c *VDICluster) AccessLogEnabled() bool {
+	if c.Spec.App != nil && c.Spec.App.AccessLog != nil {
+		return c.Spec.App.AccessLog.Enabled
+	}
+	return false
+}
+
+// GetAccessLogSampleRate returns the rate at which successful requests should be
+// written to the access log.
+func (c *VDICluster) GetAccessLogSampleRate() int {
+	if c.Spec.App != nil && c.Spec.App.AccessLog != nil {
+		if c.Spec.App.AccessLog.SampleRate > 1 {
+			return int(c.Spec.App.AccessLog.SampleRate)
+		}
+	}
+	return 1
+}
+
+// GetAccessLogExcludePaths returns the route prefixes that should not be written
+// to the access log.
+func (c *VDICluster) GetAccessLogExcludePaths() []string {
+	if c.Spec.App != nil && c.Spec.App.AccessLog != nil {
+		if len(c.Spec.App.AccessLog.ExcludePaths) > 0 {
+			return c.Spec.App.AccessLog.ExcludePaths
+		}
+	}
+	return []string{"/api/healthz", "/api/readyz", "/api/metrics"}
+}
+
+// GetTracing returns the configuration for exporting traces. Tracing is disabled
+// if none is defined.
+func (c *VDICluster) GetTracing() *TracingConfig {
+	if c.Spec.App != nil && c.Spec.App.Tracing != nil {
+		return c.Spec.App.Tracing
+	}
+	return &TracingConfig{}
+}
+
+// IsEnabled returns true if traces should be exported.
+func (t *TracingConfig) IsEnabled() bool {
+	return t.Endpoint != ""
+}
+
+// GetSampleRate returns one out of how many new traces should be sampled.
+func (t *TracingConfig) GetSampleRate() int32 {
+	if t.SampleRate > 1 {
+		return t.SampleRate
+	}
+	return 1
+}
+
+// GetTracingEnvVars returns the standard OpenTelemetry environment variables for
+// exporting traces from the kvdi-proxy in desktops.
+func (c *VDICluster) GetTracingEnvVars() []corev1.EnvVar {
+	cfg := c.GetTracing()
+	if !cfg.IsEnabled() {
+		return nil
+	}
+	env := []corev1.EnvVar{{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: cfg.Endpoint}}
+	if len(cfg.Headers) > 0 {
+		headers := make([]string, 0, len(cfg.Headers))
+		for key, val := range cfg.Headers {
+			headers = append(headers, fmt.Sprintf("%s=%s", escapeOTLPHeader(key), escapeOTLPHeader(val)))
+		}
+		// keep the order stable so the desktop pods are not recreated
+		sort.Strings(headers)
+		env = append(env, corev1.EnvVar{Name: "OTEL_EXPORTER_OTLP_HEADERS", Value: strings.Join(headers, ",")})
+	}
+	return env
+}
+
+// escapeOTLPHeader percent-encodes a header key or value for OTEL_EXPORTER_OTLP_HEADERS.
+func escapeOTLPHeader(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
 // GetAppSecretsName returns the name of the secret to use for app secrets.
 func (c *VDICluster) GetAppSecretsName() string {
 	if c.Spec.Secrets != nil && c.Spec.Secrets.K8SSecret != nil && c.Spec.Secrets.K8SSecret.SecretName != "" {
@@ -10,6 +10,41 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// AuthBackendLocal represents using the local auth provider.
+	AuthBackendLocal = "local"
+	// AuthBackendLDAP represents using the LDAP auth provider.
+	AuthBackendLDAP = "ldap"
+	// AuthBackendOIDC represents using the OIDC auth provider.
+	AuthBackendOIDC = "oidc"
+	// AuthBackendAzureAD represents using the Azure AD auth provider.
+	AuthBackendAzureAD = "azuread"
+	// AuthBackendCert represents using the client certificate auth provider.
+	AuthBackendCert = "cert"
+	// AuthBackendWebhook represents using the webhook auth provider.
+	AuthBackendWebhook = "webhook"
+)
+
+// GetAuthBackend returns the type of auth backend this VDICluster is using.
+func (c *VDICluster) GetAuthBackend() string {
+	if c.IsUsingLDAPAuth() {
+		return AuthBackendLDAP
+	}
+	if c.IsUsingAzureADAuth() {
+		return AuthBackendAzureAD
+	}
+	if c.IsUsingOIDCAuth() {
+		return AuthBackendOIDC
+	}
+	if c.IsUsingCertAuth() {
+		return AuthBackendCert
+	}
+	if c.IsUsingWebhookAuth() {
+		return AuthBackendWebhook
+	}
+	return AuthBackendLocal
+}
+
 // GetAdminSecret returns the name of the secret for storing the admin password.
 func (c *VDICluster) GetAdminSecret() string {
 	if c.Spec.Auth != nil && c.Spec.Auth.AdminSecret != "" {
@@ -32,11 +67,131 @@ func (c *VDICluster) AnonymousAllowed() bool {
 // if no other options are defined.
 func (c *VDICluster) IsUsingLocalAuth() bool {
 	if c.Spec.Auth != nil {
-		return c.Spec.Auth.LocalAuth != nil && !c.IsUsingLDAPAuth() && !c.IsUsingOIDCAuth()
+		return c.Spec.Auth.LocalAuth != nil && !c.IsUsingLDAPAuth() && !c.IsUsingOIDCAuth() && !c.IsUsingCertAuth() && !c.IsUsingWebhookAuth()
 	}
 	return true
 }
 
+// GetPasswordPolicy returns the policy for local user passwords.
+func (c *VDICluster) GetPasswordPolicy() *PasswordPolicy {
+	if c.Spec.Auth != nil && c.Spec.Auth.LocalAuth != nil && c.Spec.Auth.LocalAuth.PasswordPolicy != nil {
+		return c.Spec.Auth.LocalAuth.PasswordPolicy
+	}
+	return &PasswordPolicy{}
+}
+
+// GetPasswordResetTTL returns how long password reset links are valid for.
+func (c *VDICluster) GetPasswordResetTTL() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.LocalAuth != nil && c.Spec.Auth.LocalAuth.PasswordResetTTL != "" {
+		if dur, err := time.ParseDuration(c.Spec.Auth.LocalAuth.PasswordResetTTL); err == nil && dur > 0 {
+			return dur
+		}
+	}
+	return time.Hour
+}
+
+// GetMinLength returns the minimum length of a password.
+func (p *PasswordPolicy) GetMinLength() int {
+	if p.MinLength > 0 {
+		return p.MinLength
+	}
+	return 8
+}
+
+// GetBreachAPIURL returns the URL of the range API to use for breach checks.
+func (p *PasswordPolicy) GetBreachAPIURL() string {
+	if p.BreachAPIURL != "" {
+		if !strings.HasSuffix(p.BreachAPIURL, "/") {
+			return p.BreachAPIURL + "/"
+		}
+		return p.BreachAPIURL
+	}
+	return "https://api.pwnedpasswords.com/range/"
+}
+
+// GetLoginRateLimit returns the rate limits and lockouts applied to logins.
+func (c *VDICluster) GetLoginRateLimit() *LoginRateLimitConfig {
+	if c.Spec.Auth != nil && c.Spec.Auth.LoginRateLimit != nil {
+		return c.Spec.Auth.LoginRateLimit
+	}
+	return &LoginRateLimitConfig{}
+}
+
+// GetWindow returns the window over which login attempts are counted.
+func (l *LoginRateLimitConfig) GetWindow() time.Duration {
+	if dur, err := time.ParseDuration(l.Window); err == nil && dur > 0 {
+		return dur
+	}
+	return time.Minute
+}
+
+// GetMaxAttemptsPerIP returns the maximum number of login attempts allowed from
+// a single client address in a window.
+func (l *LoginRateLimitConfig) GetMaxAttemptsPerIP() int {
+	if l.MaxAttemptsPerIP > 0 {
+		return l.MaxAttemptsPerIP
+	}
+	return 30
+}
+
+// GetMaxAttemptsPerUser returns the maximum number of login attempts allowed for
+// a single username in a window.
+func (l *LoginRateLimitConfig) GetMaxAttemptsPerUser() int {
+	if l.MaxAttemptsPerUser > 0 {
+		return l.MaxAttemptsPerUser
+	}
+	return 10
+}
+
+// GetLockoutThreshold returns the number of consecutive failed attempts after
+// which a username is locked out.
+func (l *LoginRateLimitConfig) GetLockoutThreshold() int {
+	if l.LockoutThreshold > 0 {
+		return l.LockoutThreshold
+	}
+	return 5
+}
+
+// GetLockoutDuration returns how long a username is locked out the first time.
+func (l *LoginRateLimitConfig) GetLockoutDuration() time.Duration {
+	if dur, err := time.ParseDuration(l.LockoutDuration); err == nil && dur > 0 {
+		return dur
+	}
+	return time.Minute
+}
+
+// GetMaxLockoutDuration returns the longest a username can be locked out for.
+func (l *LoginRateLimitConfig) GetMaxLockoutDuration() time.Duration {
+	if dur, err := time.ParseDuration(l.MaxLockoutDuration); err == nil && dur > 0 {
+		return dur
+	}
+	return time.Hour
+}
+
+// GetLoginHistory returns the configuration for the history of login attempts.
+func (c *VDICluster) GetLoginHistory() *LoginHistoryConfig {
+	if c.Spec.Auth != nil && c.Spec.Auth.LoginHistory != nil {
+		return c.Spec.Auth.LoginHistory
+	}
+	return &LoginHistoryConfig{}
+}
+
+// GetMaxAttempts returns the maximum number of login attempts kept.
+func (l *LoginHistoryConfig) GetMaxAttempts() int {
+	if l.MaxAttempts > 0 {
+		return l.MaxAttempts
+	}
+	return 1000
+}
+
+// GetRetention returns how long login attempts are kept for.
+func (l *LoginHistoryConfig) GetRetention() time.Duration {
+	if dur, err := time.ParseDuration(l.Retention); err == nil && dur > 0 {
+		return dur
+	}
+	return 30 * 24 * time.Hour
+}
+
 // AuthIsUsingSecretEngine returns true if the secrets for the configured auth
 // backend are using the built-in secrets engine and not a separate kubernetes
 // secret.
@@ -84,6 +239,44 @@ func (c *VDICluster) GetTokenDuration() time.Duration {
 	return v1.DefaultSessionLength
 }
 
+// DefaultTokenKeyRetention is how long replaced token signing keys keep verifying
+// tokens when keys are not rotated on an interval.
+const DefaultTokenKeyRetention = 24 * time.Hour
+
+// GetTokenSigningAlgorithm returns the algorithm access tokens are signed with.
+func (c *VDICluster) GetTokenSigningAlgorithm() TokenSigningAlgorithm {
+	if c.Spec.Auth != nil && c.Spec.Auth.TokenSigning != nil && c.Spec.Auth.TokenSigning.Algorithm != "" {
+		return c.Spec.Auth.TokenSigning.Algorithm
+	}
+	return TokenSigningHS256
+}
+
+// GetTokenKeyRotationInterval returns how often a new token signing key should be
+// generated. Zero means keys are not rotated.
+func (c *VDICluster) GetTokenKeyRotationInterval() time.Duration {
+	if c.Spec.Auth != nil && c.Spec.Auth.TokenSigning != nil && c.Spec.Auth.TokenSigning.RotationInterval != "" {
+		if interval, err := time.ParseDuration(c.Spec.Auth.TokenSigning.RotationInterval); err == nil && interval > 0 {
+			return interval
+		}
+	}
+	return 0
+}
+
+// GetTokenKeyRetention returns how long replaced token signing keys keep verifying
+// tokens.
+func (c *VDICluster) GetTokenKeyRetention() time.Duration {
+	if interval := c.GetTokenKeyRotationInterval(); interval > 0 {
+		return interval
+	}
+	return DefaultTokenKeyRetention
+}
+
+// IsUsingTokenSigningKeys returns true if access tokens are signed with the keys
+// generated by the manager instead of the static JWT secret.
+func (c *VDICluster) IsUsingTokenSigningKeys() bool {
+	return c.GetTokenSigningAlgorithm() != TokenSigningHS256 || c.GetTokenKeyRotationInterval() > 0
+}
+
 // GetAdminRole returns an admin role for this VDICluster.
 func (c *VDICluster) GetAdminRole() *VDIRole {
 	var annotations map[string]string
@@ -95,6 +288,10 @@ func (c *VDICluster) GetAdminRole() *VDIRole {
 		annotations = map[string]string{
 			v1.OIDCGroupRoleAnnotation: strings.Join(c.GetOIDCAdminGroups(), v1.AuthGroupSeparator),
 		}
+	} else if c.IsUsingCertAuth() {
+		annotations = map[string]string{
+			v1.CertGroupRoleAnnotation: strings.Join(c.GetCertAdminGroups(), v1.AuthGroupSeparator),
+		}
 	}
 	return &VDIRole{
 		ObjectMeta: metav1.ObjectMeta{
@@ -127,7 +324,11 @@ func (c *VDICluster) GetLaunchTemplatesRole() *VDIRole {
 		},
 		Rules: []v1.Rule{
 			{
-				Verbs:            []v1.Verb{v1.VerbRead, v1.VerbUse, v1.VerbLaunch},
+				Verbs: []v1.Verb{
+					v1.VerbRead, v1.VerbUse, v1.VerbLaunch,
+					v1.VerbFileDownload, v1.VerbFileUpload,
+					v1.VerbClipboardIn, v1.VerbClipboardOut,
+				},
 				Resources:        []v1.Resource{v1.ResourceTemplates},
 				ResourcePatterns: []string{".*"},
 				Namespaces:       []string{c.GetCoreNamespace()},
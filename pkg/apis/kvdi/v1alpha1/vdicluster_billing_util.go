@@ -0,0 +1,109 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// defaultBillingExportInterval is the default interval for exporting usage reports.
+const defaultBillingExportInterval = time.Duration(24) * time.Hour
+
+// defaultPriceCurrency is the default currency of resource prices.
+const defaultPriceCurrency = "USD"
+
+// defaultUsageRetention is the default amount of time daily usage is kept.
+const defaultUsageRetention = time.Duration(90*24) * time.Hour
+
+// BillingExportEnabled returns true if any billing exporters are configured.
+func (c *VDICluster) BillingExportEnabled() bool {
+	return c.GetBillingS3Config() != nil || c.GetBillingHTTPConfig() != nil
+}
+
+// GetBillingExportInterval returns how often usage reports should be exported.
+func (c *VDICluster) GetBillingExportInterval() time.Duration {
+	if c.Spec.Billing != nil && c.Spec.Billing.ExportInterval != "" {
+		dur, err := time.ParseDuration(c.Spec.Billing.ExportInterval)
+		if err != nil {
+			return defaultBillingExportInterval
+		}
+		return dur
+	}
+	return defaultBillingExportInterval
+}
+
+// GetBillingS3Config returns the S3 exporter configuration, or nil if not configured.
+func (c *VDICluster) GetBillingS3Config() *S3ExportConfig {
+	if c.Spec.Billing != nil && c.Spec.Billing.S3 != nil && c.Spec.Billing.S3.Bucket != "" {
+		return c.Spec.Billing.S3
+	}
+	return nil
+}
+
+// GetBillingHTTPConfig returns the HTTP exporter configuration, or nil if not configured.
+func (c *VDICluster) GetBillingHTTPConfig() *HTTPExportConfig {
+	if c.Spec.Billing != nil && c.Spec.Billing.HTTP != nil && c.Spec.Billing.HTTP.URL != "" {
+		return c.Spec.Billing.HTTP
+	}
+	return nil
+}
+
+// GetUsageRetention returns how long daily usage should be kept in the cluster.
+func (c *VDICluster) GetUsageRetention() time.Duration {
+	if c.Spec.Billing != nil && c.Spec.Billing.UsageRetention != "" {
+		dur, err := time.ParseDuration(c.Spec.Billing.UsageRetention)
+		if err != nil {
+			return defaultUsageRetention
+		}
+		return dur
+	}
+	return defaultUsageRetention
+}
+
+// GetPriceCurrency returns the currency resource prices are in.
+func (c *VDICluster) GetPriceCurrency() string {
+	if c.Spec.Billing != nil && c.Spec.Billing.Prices != nil && c.Spec.Billing.Prices.Currency != "" {
+		return c.Spec.Billing.Prices.Currency
+	}
+	return defaultPriceCurrency
+}
+
+// GetResourcePrices returns the hourly price of each resource, or nil if no prices
+// are configured. An error is returned if any of the prices are not valid decimals.
+func (c *VDICluster) GetResourcePrices() (map[corev1.ResourceName]float64, error) {
+	if c.Spec.Billing == nil || c.Spec.Billing.Prices == nil || len(c.Spec.Billing.Prices.PerHour) == 0 {
+		return nil, nil
+	}
+	prices := make(map[corev1.ResourceName]float64, len(c.Spec.Billing.Prices.PerHour))
+	for name, price := range c.Spec.Billing.Prices.PerHour {
+		f, err := strconv.ParseFloat(price, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("Invalid price '%s' for resource %s", price, name)
+		}
+		prices[name] = f
+	}
+	return prices, nil
+}
+
+// GetBillingLedgerName returns the name of the configmap where usage accumulated
+// since the last billing export is stored.
+func (c *VDICluster) GetBillingLedgerName() types.NamespacedName {
+	return types.NamespacedName{
+		Name:      fmt.Sprintf("%s-billing-ledger", c.GetName()),
+		Namespace: c.GetCoreNamespace(),
+	}
+}
+
+// GetUsageName returns the name of the configmap where usage for the day of the
+// given time is stored.
+func (c *VDICluster) GetUsageName(day time.Time) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      fmt.Sprintf("%s-usage-%s", c.GetName(), day.UTC().Format(v1.UsageDateFormat)),
+		Namespace: c.GetCoreNamespace(),
+	}
+}
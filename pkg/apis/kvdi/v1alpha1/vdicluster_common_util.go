@@ -3,6 +3,7 @@ package v1alpha1
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 
@@ -111,6 +112,28 @@ func (c *VDICluster) GetUserdataVolumeSpec() *corev1.PersistentVolumeClaimSpec {
 	return nil
 }
 
+// GetUserdataReclaimPolicy returns what to do with the volumes of users that no
+// longer exist.
+func (c *VDICluster) GetUserdataReclaimPolicy() UserDataReclaimPolicy {
+	if c.Spec.UserDataPolicy != nil && c.Spec.UserDataPolicy.ReclaimPolicy != "" {
+		return c.Spec.UserDataPolicy.ReclaimPolicy
+	}
+	return UserDataReclaimDelete
+}
+
+// GetUserdataRetentionPeriod returns how long user volumes are kept after they
+// were last used. Zero means they are kept indefinitely.
+func (c *VDICluster) GetUserdataRetentionPeriod() time.Duration {
+	if c.Spec.UserDataPolicy != nil && c.Spec.UserDataPolicy.RetentionPeriod != "" {
+		dur, err := time.ParseDuration(c.Spec.UserDataPolicy.RetentionPeriod)
+		if err != nil {
+			return 0
+		}
+		return dur
+	}
+	return 0
+}
+
 // GetUserdataVolumeName returns the name of the userdata volume for the given user.
 func (c *VDICluster) GetUserdataVolumeName(username string) string {
 	return fmt.Sprintf("%s-%s-userdata", c.GetName(), username)
@@ -1,6 +1,30 @@
 package v1alpha1
 
-import "time"
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultDotfilesImage is the image used for cloning dotfiles when none is configured.
+const defaultDotfilesImage = "alpine/git:latest"
+
+// GetDotfilesImage returns the image to use for cloning users' dotfiles repositories.
+func (c *VDICluster) GetDotfilesImage() string {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.DotfilesImage != "" {
+		return c.Spec.Desktops.DotfilesImage
+	}
+	return defaultDotfilesImage
+}
+
+// GetRecordingsConfig returns the storage configuration for session recordings,
+// or nil if not configured.
+func (c *VDICluster) GetRecordingsConfig() *RecordingsConfig {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.Recordings != nil && c.Spec.Desktops.Recordings.Bucket != "" {
+		return c.Spec.Desktops.Recordings
+	}
+	return nil
+}
 
 // GetMaxSessionLength returns the duration to wait to kill a desktop pod.
 // If the duration is not parseable or unconfigured, 0 is returned.
@@ -14,3 +38,164 @@ func (c *VDICluster) GetMaxSessionLength() time.Duration {
 	}
 	return time.Duration(0)
 }
+
+// GetIdleTimeout returns the duration a desktop can go without a connection before
+// it is destroyed. If the duration is not parseable or unconfigured, 0 is returned.
+func (c *VDICluster) GetIdleTimeout() time.Duration {
+	if c.Spec.Desktops != nil && c.Spec.Desktops.IdleTimeout != "" {
+		dur, err := time.ParseDuration(c.Spec.Desktops.IdleTimeout)
+		if err != nil {
+			return time.Duration(0)
+		}
+		return dur
+	}
+	return time.Duration(0)
+}
+
+// defaultPreemptionNoticeTaints are the taints applied to nodes by common cloud
+// tooling when they are about to be reclaimed.
+var defaultPreemptionNoticeTaints = []string{
+	"cloud.google.com/impending-node-termination",
+	"aws-node-termination-handler/spot-itn",
+	"aws-node-termination-handler/rebalance-recommendation",
+}
+
+// PreemptionEnabled returns true if desktops should be relaunched when their node
+// receives a preemption notice.
+func (c *VDICluster) PreemptionEnabled() bool {
+	return c.Spec.Desktops != nil && c.Spec.Desktops.Preemption != nil
+}
+
+// GetPreemptionNoticeTaints returns the taint keys that signal a node is about to
+// be reclaimed.
+func (c *VDICluster) GetPreemptionNoticeTaints() []string {
+	if c.PreemptionEnabled() && len(c.Spec.Desktops.Preemption.NoticeTaints) > 0 {
+		return c.Spec.Desktops.Preemption.NoticeTaints
+	}
+	return defaultPreemptionNoticeTaints
+}
+
+// NodeHasPreemptionNotice returns true if the given node has been tainted with
+// a preemption notice.
+func (c *VDICluster) NodeHasPreemptionNotice(node *corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		for _, key := range c.GetPreemptionNoticeTaints() {
+			if taint.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetStableNodeAffinity returns the node affinity to apply to desktops that have
+// been preempted.
+func (c *VDICluster) GetStableNodeAffinity() *corev1.NodeAffinity {
+	if c.PreemptionEnabled() && c.Spec.Desktops.Preemption.StableNodeAffinity != nil {
+		return c.Spec.Desktops.Preemption.StableNodeAffinity
+	}
+	return &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{
+				{
+					MatchExpressions: []corev1.NodeSelectorRequirement{
+						{
+							Key:      "cloud.google.com/gke-preemptible",
+							Operator: corev1.NodeSelectorOpDoesNotExist,
+						},
+						{
+							Key:      "cloud.google.com/gke-spot",
+							Operator: corev1.NodeSelectorOpDoesNotExist,
+						},
+						{
+							Key:      "eks.amazonaws.com/capacityType",
+							Operator: corev1.NodeSelectorOpNotIn,
+							Values:   []string{"SPOT"},
+						},
+						{
+							Key:      "kubernetes.azure.com/scalesetpriority",
+							Operator: corev1.NodeSelectorOpNotIn,
+							Values:   []string{"spot"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// GetUserdataSnapshotClass returns the VolumeSnapshotClass to use when snapshotting
+// the userdata of preempted desktops. An empty string means snapshots are disabled.
+func (c *VDICluster) GetUserdataSnapshotClass() string {
+	if c.PreemptionEnabled() && c.GetUserdataVolumeSpec() != nil {
+		return c.Spec.Desktops.Preemption.SnapshotClass
+	}
+	return ""
+}
+
+// GetSessionSnapshotClass returns the VolumeSnapshotClass to use for snapshots of
+// userdata volumes requested through the API. An empty string means they are disabled.
+func (c *VDICluster) GetSessionSnapshotClass() string {
+	if c.Spec.Desktops != nil && c.GetUserdataVolumeSpec() != nil {
+		return c.Spec.Desktops.SnapshotClass
+	}
+	return ""
+}
+
+// GetNamespaceResourceConfig returns the default and maximum resources for desktops
+// in the given namespace. Nil is returned if there are none configured.
+func (c *VDICluster) GetNamespaceResourceConfig(namespace string) *NamespaceResourceConfig {
+	if c.Spec.Desktops == nil || c.Spec.Desktops.NamespaceResources == nil {
+		return nil
+	}
+	if conf, ok := c.Spec.Desktops.NamespaceResources[namespace]; ok {
+		return &conf
+	}
+	if conf, ok := c.Spec.Desktops.NamespaceResources["*"]; ok {
+		return &conf
+	}
+	return nil
+}
+
+// GetDesktopResources returns the resource requirements for a desktop booted from
+// the given template in the given namespace. Namespace defaults are applied to any
+// requests or limits the template omits, and limits are capped to the namespace
+// maximums.
+func (c *VDICluster) GetDesktopResources(tmpl *DesktopTemplate, namespace string) corev1.ResourceRequirements {
+	resources := tmpl.GetDesktopResources()
+	conf := c.GetNamespaceResourceConfig(namespace)
+	if conf == nil {
+		return resources
+	}
+
+	resources.Requests = mergeResourceDefaults(resources.Requests, conf.Default.Requests)
+	resources.Limits = mergeResourceDefaults(resources.Limits, conf.Default.Limits)
+
+	for name, max := range conf.Max {
+		if resources.Limits == nil {
+			resources.Limits = make(corev1.ResourceList)
+		}
+		if limit, ok := resources.Limits[name]; !ok || limit.Cmp(max) > 0 {
+			resources.Limits[name] = max.DeepCopy()
+		}
+		// requests cannot be greater than limits
+		if request, ok := resources.Requests[name]; ok && request.Cmp(resources.Limits[name]) > 0 {
+			resources.Requests[name] = resources.Limits[name].DeepCopy()
+		}
+	}
+
+	return resources
+}
+
+// mergeResourceDefaults sets any resources in defaults that are missing from list.
+func mergeResourceDefaults(list, defaults corev1.ResourceList) corev1.ResourceList {
+	for name, quantity := range defaults {
+		if list == nil {
+			list = make(corev1.ResourceList)
+		}
+		if _, ok := list[name]; !ok {
+			list[name] = quantity.DeepCopy()
+		}
+	}
+	return list
+}
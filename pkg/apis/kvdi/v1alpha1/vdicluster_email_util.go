@@ -0,0 +1,47 @@
+package v1alpha1
+
+import "time"
+
+// defaultExpiryWarning is the default time before a desktop is destroyed to warn
+// its user.
+const defaultExpiryWarning = 15 * time.Minute
+
+// EmailEnabled returns true if email notifications are configured.
+func (c *VDICluster) EmailEnabled() bool {
+	return c.Spec.Email != nil && c.Spec.Email.Server != ""
+}
+
+// GetEmailConfig returns the email configuration for this cluster.
+func (c *VDICluster) GetEmailConfig() *EmailConfig {
+	if c.Spec.Email != nil {
+		return c.Spec.Email
+	}
+	return &EmailConfig{}
+}
+
+// GetPasswordKey returns the key in the secrets backend holding the password for
+// the SMTP server.
+func (e *EmailConfig) GetPasswordKey() string {
+	if e.PasswordKey != "" {
+		return e.PasswordKey
+	}
+	return "smtp-password"
+}
+
+// GetExpiryWarning returns how long before a desktop is destroyed to warn its user.
+func (e *EmailConfig) GetExpiryWarning() time.Duration {
+	if e.ExpiryWarning != "" {
+		dur, err := time.ParseDuration(e.ExpiryWarning)
+		if err != nil {
+			return defaultExpiryWarning
+		}
+		return dur
+	}
+	return defaultExpiryWarning
+}
+
+// PasswordResetEnabled returns true if users can reset their passwords over email.
+// This requires the local auth provider and the external URL of the app.
+func (c *VDICluster) PasswordResetEnabled() bool {
+	return c.EmailEnabled() && c.GetEmailConfig().AppURL != "" && c.IsUsingLocalAuth()
+}
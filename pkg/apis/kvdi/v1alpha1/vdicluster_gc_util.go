@@ -0,0 +1,48 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// defaultGCInterval is the default interval for scanning for orphaned resources.
+const defaultGCInterval = time.Hour
+
+// GCEnabled returns true if orphaned resources should be garbage collected.
+func (c *VDICluster) GCEnabled() bool {
+	if c.Spec.GC != nil {
+		return c.Spec.GC.Enabled
+	}
+	return false
+}
+
+// GCDryRun returns true if orphaned resources should only be reported.
+func (c *VDICluster) GCDryRun() bool {
+	if c.Spec.GC != nil {
+		return c.Spec.GC.DryRun
+	}
+	return false
+}
+
+// GetGCInterval returns how often to scan for orphaned resources.
+func (c *VDICluster) GetGCInterval() time.Duration {
+	if c.Spec.GC != nil && c.Spec.GC.Interval != "" {
+		dur, err := time.ParseDuration(c.Spec.GC.Interval)
+		if err != nil {
+			return defaultGCInterval
+		}
+		return dur
+	}
+	return defaultGCInterval
+}
+
+// GetGCReportName returns the name of the configmap where the results of the
+// last garbage collection are stored.
+func (c *VDICluster) GetGCReportName() types.NamespacedName {
+	return types.NamespacedName{
+		Name:      fmt.Sprintf("%s-gc-report", c.GetName()),
+		Namespace: c.GetCoreNamespace(),
+	}
+}
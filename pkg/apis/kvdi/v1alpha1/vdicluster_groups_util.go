@@ -0,0 +1,55 @@
+package v1alpha1
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetGroups returns a list of all the VDIGroups that apply to this cluster instance.
+func (v *VDICluster) GetGroups(c client.Client) ([]VDIGroup, error) {
+	groupList := &VDIGroupList{}
+	return groupList.Items, c.List(
+		context.TODO(),
+		groupList,
+		client.InNamespace(metav1.NamespaceAll),
+		client.MatchingLabels{v1.RoleClusterRefLabel: v.GetName()},
+	)
+}
+
+// GetUserGroupNames returns the names of the given groups the user is a member of.
+func GetUserGroupNames(groups []VDIGroup, username string) []string {
+	names := make([]string, 0)
+	for _, group := range groups {
+		if group.HasUser(username) {
+			names = append(names, group.GetName())
+		}
+	}
+	return names
+}
+
+// GetUserRoleNames returns the given role names with the roles granted to the
+// user through their membership in any of the given groups added.
+func GetUserRoleNames(groups []VDIGroup, username string, roles []string) []string {
+	out := append([]string{}, roles...)
+	seen := make(map[string]struct{}, len(roles))
+	for _, role := range roles {
+		seen[role] = struct{}{}
+	}
+	for _, group := range groups {
+		if !group.HasUser(username) {
+			continue
+		}
+		for _, role := range group.GetRoles() {
+			if _, ok := seen[role]; ok {
+				continue
+			}
+			seen[role] = struct{}{}
+			out = append(out, role)
+		}
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package v1alpha1
+
+import (
+	"context"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetSessionResourceUsage returns the CPU and memory counted against the placement
+// quotas of roles for a desktop booted from the given template in the given
+// namespace. Limits are counted, falling back to requests for resources without one.
+func (c *VDICluster) GetSessionResourceUsage(tmpl *DesktopTemplate, namespace string) corev1.ResourceList {
+	resources := c.GetDesktopResources(tmpl, namespace)
+	usage := corev1.ResourceList{}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if limit, ok := resources.Limits[name]; ok {
+			usage[name] = limit.DeepCopy()
+		} else if request, ok := resources.Requests[name]; ok {
+			usage[name] = request.DeepCopy()
+		}
+	}
+	return usage
+}
+
+// GetUserSessionResourceUsage returns the total CPU and memory counted against the
+// placement quotas of roles for the desktops of the given user. Desktops that are
+// being deleted, or for which skip returns true, are not counted, nor are desktops
+// whose template no longer exists.
+func (c *VDICluster) GetUserSessionResourceUsage(cl client.Client, username string, skip func(*Desktop) bool) (corev1.ResourceList, error) {
+	desktops := &DesktopList{}
+	if err := cl.List(context.TODO(), desktops, c.GetUserDesktopsSelector(username)); err != nil {
+		return nil, err
+	}
+	total := corev1.ResourceList{}
+	for idx := range desktops.Items {
+		desktop := &desktops.Items[idx]
+		if desktop.GetDeletionTimestamp() != nil || (skip != nil && skip(desktop)) {
+			continue
+		}
+		tmpl, err := desktop.GetTemplate(cl)
+		if err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return nil, err
+		}
+		for name, quantity := range c.GetSessionResourceUsage(tmpl, desktop.GetNamespace()) {
+			sum := total[name]
+			sum.Add(quantity)
+			total[name] = sum
+		}
+	}
+	return total, nil
+}
+
+// GetDesktopUser returns the user of the given desktop with the roles they held
+// when it was launched. Roles that no longer exist are left out.
+func (c *VDICluster) GetDesktopUser(cl client.Client, desktop *Desktop) (*v1.VDIUser, error) {
+	user := &v1.VDIUser{Name: desktop.Spec.User, Roles: make([]*v1.VDIUserRole, 0)}
+	names := desktop.GetUserRoles()
+	if len(names) == 0 {
+		return user, nil
+	}
+	roles, err := c.GetRoles(cl)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range roles {
+		for _, name := range names {
+			if roles[idx].GetName() == name {
+				user.Roles = append(user.Roles, roles[idx].ToUserRole())
+				break
+			}
+		}
+	}
+	return user, nil
+}
@@ -2,6 +2,8 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 
@@ -19,3 +21,100 @@ func (v *VDICluster) GetRoles(c client.Client) ([]VDIRole, error) {
 		client.MatchingLabels{v1.RoleClusterRefLabel: v.GetName()},
 	)
 }
+
+// GetResolvedRoles returns a list of all the VDIRoles that apply to this cluster
+// instance with the rules of the roles they inherit from added to their own. These
+// should be used whenever the permissions of a role are evaluated.
+func (v *VDICluster) GetResolvedRoles(c client.Client) ([]VDIRole, error) {
+	roles, err := v.GetRoles(c)
+	if err != nil {
+		return nil, err
+	}
+	return ResolveRoles(roles), nil
+}
+
+// ResolveRoles returns copies of the given roles with the rules of the roles they
+// inherit from added to their own. Inherited roles that do not exist are ignored,
+// and each role is only visited once, so cycles cannot cause roles to grant anything
+// beyond the combined rules of the roles involved.
+func ResolveRoles(roles []VDIRole) []VDIRole {
+	byName := make(map[string]*VDIRole, len(roles))
+	for idx := range roles {
+		byName[roles[idx].GetName()] = &roles[idx]
+	}
+	resolved := make([]VDIRole, len(roles))
+	for idx, role := range roles {
+		out := role.DeepCopy()
+		out.Rules = make([]v1.Rule, 0)
+		visited := make(map[string]struct{})
+		var visit func(r *VDIRole)
+		visit = func(r *VDIRole) {
+			if _, ok := visited[r.GetName()]; ok {
+				return
+			}
+			visited[r.GetName()] = struct{}{}
+			for _, rule := range r.GetRules() {
+				if !containsRule(out.Rules, rule) {
+					out.Rules = append(out.Rules, *rule.DeepCopy())
+				}
+			}
+			for _, name := range r.GetInherits() {
+				if parent, ok := byName[name]; ok {
+					visit(parent)
+				}
+			}
+		}
+		visit(&role)
+		resolved[idx] = *out
+	}
+	return resolved
+}
+
+// ValidateRoleInheritance checks that the given role only inherits directly from
+// roles that exist, and that it does not inherit from itself through any of them.
+// The role is validated as it appears in the given list.
+func ValidateRoleInheritance(roles []VDIRole, name string) error {
+	byName := make(map[string]*VDIRole, len(roles))
+	for idx := range roles {
+		byName[roles[idx].GetName()] = &roles[idx]
+	}
+	visited := make(map[string]struct{})
+	var visit func(r *VDIRole) error
+	visit = func(r *VDIRole) error {
+		for _, parentName := range r.GetInherits() {
+			if parentName == name {
+				return fmt.Errorf("Role %s cannot inherit from itself", name)
+			}
+			if _, ok := visited[parentName]; ok {
+				continue
+			}
+			visited[parentName] = struct{}{}
+			parent, ok := byName[parentName]
+			if !ok {
+				if r.GetName() == name {
+					return fmt.Errorf("Inherited role %s does not exist", parentName)
+				}
+				continue
+			}
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	role, ok := byName[name]
+	if !ok {
+		return fmt.Errorf("Role %s does not exist", name)
+	}
+	return visit(role)
+}
+
+// containsRule returns true if the given rules include one equal to rule.
+func containsRule(rules []v1.Rule, rule v1.Rule) bool {
+	for _, r := range rules {
+		if reflect.DeepEqual(r, rule) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetCondition returns the condition of the given type, or nil if it has not
+// been reported yet.
+func (c *VDICluster) GetCondition(condType VDIClusterConditionType) *VDIClusterCondition {
+	for idx := range c.Status.Conditions {
+		if c.Status.Conditions[idx].Type == condType {
+			return &c.Status.Conditions[idx]
+		}
+	}
+	return nil
+}
+
+// SetCondition sets the status of the condition of the given type. The transition
+// time is only updated when the status changes.
+func (c *VDICluster) SetCondition(condType VDIClusterConditionType, status corev1.ConditionStatus, reason, message string) {
+	cond := c.GetCondition(condType)
+	if cond == nil {
+		c.Status.Conditions = append(c.Status.Conditions, VDIClusterCondition{Type: condType})
+		cond = &c.Status.Conditions[len(c.Status.Conditions)-1]
+	}
+	if cond.Status != status {
+		cond.Status = status
+		cond.LastTransitionTime = metav1.Now()
+	}
+	cond.Reason = reason
+	cond.Message = message
+}
+
+// ConditionsReady returns true if every condition reported on a VDICluster is
+// true.
+func (c *VDICluster) ConditionsReady() bool {
+	for _, condType := range VDIClusterConditionTypes {
+		if cond := c.GetCondition(condType); cond == nil || cond.Status != corev1.ConditionTrue {
+			return false
+		}
+	}
+	return true
+}
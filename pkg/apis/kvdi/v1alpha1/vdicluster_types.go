@@ -17,35 +17,262 @@ type VDIClusterSpec struct {
 	// may want to set it explicitly on your storage-class controller as an extra
 	// safeguard.
 	UserDataSpec *corev1.PersistentVolumeClaimSpec `json:"userdataSpec,omitempty"`
+	// Lifecycle policies for user volumes. Policies are enforced by the garbage
+	// collector, so `gc` must be enabled for them to take effect.
+	UserDataPolicy *UserDataPolicy `json:"userdataPolicy,omitempty"`
 	// App configurations.
 	App *AppConfig `json:"app,omitempty"`
-	// Authentication configurations
+	// Authentication configurations. Changing the auth backend restarts the app
+	// instances, all other changes are applied at runtime.
 	Auth *AuthConfig `json:"auth,omitempty"`
 	// Global desktop configurations
 	Desktops *DesktopsConfig `json:"desktops,omitempty"`
-	// Secrets backend configurations
+	// Secrets backend configurations. Changing the secrets backend restarts the app
+	// instances.
 	Secrets *SecretsConfig `json:"secrets,omitempty"`
 	// Metrics configurations.
 	Metrics *MetricsConfig `json:"metrics,omitempty"`
+	// Billing export configurations.
+	Billing *BillingConfig `json:"billing,omitempty"`
+	// Garbage collection configurations for orphaned desktop resources.
+	GC *GCConfig `json:"gc,omitempty"`
+	// Configurations for sending email notifications to users and administrators.
+	Email *EmailConfig `json:"email,omitempty"`
+}
+
+// UserDataReclaimPolicy represents what happens to the volume of a user that no
+// longer exists.
+// +kubebuilder:validation:Enum=Retain;Delete
+type UserDataReclaimPolicy string
+
+const (
+	// UserDataReclaimRetain keeps the volumes of deleted users.
+	UserDataReclaimRetain UserDataReclaimPolicy = "Retain"
+	// UserDataReclaimDelete deletes the volumes of deleted users.
+	UserDataReclaimDelete UserDataReclaimPolicy = "Delete"
+)
+
+// UserDataPolicy represents lifecycle policies for user volumes.
+type UserDataPolicy struct {
+	// What to do with the volume of a user that no longer exists in the auth
+	// provider. Defaults to `Delete`.
+	ReclaimPolicy UserDataReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// When set, the volume of a user is deleted after going this long without
+	// being mounted by any of their desktops, e.g. `2160h` for 90 days. By default
+	// volumes are kept for as long as the user exists.
+	RetentionPeriod string `json:"retentionPeriod,omitempty"`
+}
+
+// GCConfig represents configurations for garbage collecting resources left
+// behind by desktops and users that no longer exist.
+type GCConfig struct {
+	// Set to true to periodically scan for orphaned resources.
+	Enabled bool `json:"enabled,omitempty"`
+	// How often to scan for orphaned resources. Defaults to `1h`.
+	Interval string `json:"interval,omitempty"`
+	// Set to true to only report orphaned resources without deleting them. The
+	// results of the last scan can be retrieved from the API.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// EmailConfig represents configurations for sending email notifications over
+// SMTP. Users receive MFA enrollment links, password reset links, and warnings
+// before their desktops are destroyed at the addresses they configure.
+type EmailConfig struct {
+	// The address of the SMTP server in `host:port` format. Email is disabled when
+	// this is empty.
+	Server string `json:"server,omitempty"`
+	// The address to send messages from.
+	From string `json:"from,omitempty"`
+	// The username to authenticate to the SMTP server with. No authentication is
+	// done when this is empty.
+	Username string `json:"username,omitempty"`
+	// The key in the secrets backend holding the password for the `username`.
+	// Defaults to `smtp-password`.
+	PasswordKey string `json:"passwordKey,omitempty"`
+	// Set to true to connect to the server over TLS, instead of upgrading the
+	// connection with STARTTLS. This is usually required for port 465.
+	ImplicitTLS bool `json:"implicitTLS,omitempty"`
+	// The external URL of the kVDI app, used for links in messages. Password resets
+	// are disabled when this is empty.
+	AppURL string `json:"appURL,omitempty"`
+	// Addresses to send alerts to, such as when users are locked out or nodes are
+	// drained.
+	AdminAddresses []string `json:"adminAddresses,omitempty"`
+	// How long before a desktop is destroyed to warn its user. Defaults to `15m`.
+	ExpiryWarning string `json:"expiryWarning,omitempty"`
+	// Overrides for the messages that are sent, keyed by `mfa-enrollment`,
+	// `password-reset`, `session-expiry`, and `admin-alert`. Subjects and bodies
+	// are Go templates.
+	Templates map[string]EmailTemplate `json:"templates,omitempty"`
+}
+
+// EmailTemplate represents the templates for the subject and body of a message.
+type EmailTemplate struct {
+	// The template for the subject of the message.
+	Subject string `json:"subject,omitempty"`
+	// The template for the plain-text body of the message.
+	Body string `json:"body,omitempty"`
 }
 
 // DesktopsConfig represents global configurations for desktop
 // sessions.
 type DesktopsConfig struct {
 	// When configured, desktop sessions will be forcefully terminated when
-	// the time limit is reached.
+	// the time limit is reached. Templates may override this value.
 	MaxSessionLength string `json:"maxSessionLength,omitempty"`
+	// When configured, desktop sessions will be terminated after going this long
+	// without a display or audio connection. Templates may override this value.
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// When configured, desktops running on spot or preemptible nodes are relaunched
+	// onto stable capacity when their node receives a preemption notice.
+	Preemption *PreemptionConfig `json:"preemption,omitempty"`
+	// Default and maximum compute resources for desktops, keyed by the namespace
+	// the desktops run in. The `*` key applies to any namespace without its own entry.
+	NamespaceResources map[string]NamespaceResourceConfig `json:"namespaceResources,omitempty"`
+	// The image to use for cloning users' dotfiles repositories into their home
+	// directories. It must provide `sh` and `git`. Defaults to `alpine/git:latest`.
+	DotfilesImage string `json:"dotfilesImage,omitempty"`
+	// Where to store recordings of display sessions for templates with `recordSessions`
+	// enabled. Sessions are not recorded until this is configured.
+	Recordings *RecordingsConfig `json:"recordings,omitempty"`
+	// The name of a VolumeSnapshotClass to use for snapshots of userdata volumes
+	// requested through the API. Users can launch new sessions restored from their
+	// snapshots. Snapshots are only allowed when this is set and `userdataSpec` is
+	// configured.
+	SnapshotClass string `json:"snapshotClass,omitempty"`
 }
 
+// RecordingsConfig represents configurations for storing display session recordings
+// in S3-compatible storage. Recordings are uploaded by the app instances when a
+// display connection ends.
+type RecordingsConfig struct {
+	// The bucket to write recordings to.
+	Bucket string `json:"bucket"`
+	// The region of the bucket. Defaults to `us-east-1`.
+	Region string `json:"region,omitempty"`
+	// A custom endpoint for S3-compatible storage. Defaults to the AWS endpoint for
+	// the region.
+	Endpoint string `json:"endpoint,omitempty"`
+	// A prefix to apply to the keys of written recordings.
+	Prefix string `json:"prefix,omitempty"`
+	// The name of a kubernetes secret in the app namespace containing the
+	// `AWS_ACCESS_KEY_ID` and `AWS_SECRET_ACCESS_KEY` to use for reading and writing
+	// recordings.
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// NamespaceResourceConfig represents default and maximum compute resources for
+// desktops in a namespace.
+type NamespaceResourceConfig struct {
+	// Requests and limits applied to desktops whose template does not set them.
+	Default corev1.ResourceRequirements `json:"default,omitempty"`
+	// The maximum limits allowed for desktops. Limits in templates that exceed these,
+	// or that are not set at all, are lowered to the maximum.
+	Max corev1.ResourceList `json:"max,omitempty"`
+}
+
+// PreemptionConfig represents configurations for handling desktops running on nodes
+// that can be reclaimed by the cloud provider.
+type PreemptionConfig struct {
+	// The taint keys that signal a node is about to be reclaimed. Defaults to the
+	// taints applied by GKE and the AWS Node Termination Handler.
+	NoticeTaints []string `json:"noticeTaints,omitempty"`
+	// The node affinity to apply to preempted desktops when they are relaunched.
+	// Defaults to avoiding nodes labeled as spot or preemptible capacity on GKE,
+	// EKS, and AKS.
+	StableNodeAffinity *corev1.NodeAffinity `json:"stableNodeAffinity,omitempty"`
+	// The name of a VolumeSnapshotClass to use for taking a snapshot of the user's
+	// userdata volume before relaunching a preempted desktop. Snapshots are only taken
+	// when this is set and `userdataSpec` is configured.
+	SnapshotClass string `json:"snapshotClass,omitempty"`
+}
+
+// BillingConfig represents configurations for desktop usage accounting and
+// periodically exporting it to external destinations.
+type BillingConfig struct {
+	// How often to export usage reports. Defaults to `24h`.
+	ExportInterval string `json:"exportInterval,omitempty"`
+	// How long daily usage is kept in the cluster for reporting through the API.
+	// Defaults to `2160h` (90 days).
+	UsageRetention string `json:"usageRetention,omitempty"`
+	// Export usage reports as CSV files to an S3 bucket.
+	S3 *S3ExportConfig `json:"s3,omitempty"`
+	// Export usage reports to an HTTP endpoint.
+	HTTP *HTTPExportConfig `json:"http,omitempty"`
+	// Prices for desktop resources, used to estimate the hourly cost of sessions
+	// before they are launched.
+	Prices *PriceConfig `json:"prices,omitempty"`
+}
+
+// PriceConfig represents the hourly prices of the resources used by desktops.
+type PriceConfig struct {
+	// The currency prices are in. Defaults to `USD`.
+	Currency string `json:"currency,omitempty"`
+	// The price of each resource per hour as a decimal string. CPU is priced per core,
+	// memory and ephemeral storage per GiB, and any other resource (e.g.
+	// `nvidia.com/gpu`) per unit.
+	PerHour map[corev1.ResourceName]string `json:"perHour,omitempty"`
+}
+
+// S3ExportConfig represents configurations for exporting usage reports to S3.
+type S3ExportConfig struct {
+	// The bucket to write reports to.
+	Bucket string `json:"bucket"`
+	// The region of the bucket. Defaults to `us-east-1`.
+	Region string `json:"region,omitempty"`
+	// A custom endpoint for S3-compatible storage. Defaults to the AWS endpoint for
+	// the region.
+	Endpoint string `json:"endpoint,omitempty"`
+	// A prefix to apply to the keys of written reports.
+	Prefix string `json:"prefix,omitempty"`
+	// The name of a kubernetes secret in the same namespace as the manager containing
+	// the `AWS_ACCESS_KEY_ID` and `AWS_SECRET_ACCESS_KEY` to use when writing reports.
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// HTTPExportConfig represents configurations for exporting usage reports to an
+// HTTP endpoint.
+type HTTPExportConfig struct {
+	// The URL to POST reports to.
+	URL string `json:"url"`
+	// The format to send reports in. Defaults to `json`.
+	Format BillingExportFormat `json:"format,omitempty"`
+	// Extra headers to send with the request.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// BillingExportFormat represents the format of an exported usage report.
+// +kubebuilder:validation:Enum=json;csv
+type BillingExportFormat string
+
+const (
+	// BillingExportJSON exports usage reports as JSON.
+	BillingExportJSON BillingExportFormat = "json"
+	// BillingExportCSV exports usage reports as CSV.
+	BillingExportCSV BillingExportFormat = "csv"
+)
+
 // AppConfig represents app configurations for the VDI cluster
 type AppConfig struct {
 	// The image to use for the app instances. Defaults to the public image
 	// matching the version of the currently running manager.
 	Image string `json:"image,omitempty"`
-	// Whether to add CORS headers to API requests
+	// Whether to add CORS headers to API requests. This is applied at runtime.
 	CORSEnabled bool `json:"corsEnabled,omitempty"`
 	// Whether to log auditing events to stdout
 	AuditLog bool `json:"auditLog,omitempty"`
+	// Configurations for the audit log. Setting this enables the audit log with
+	// the configured backend. This is applied at runtime.
+	Audit *AuditLogConfig `json:"audit,omitempty"`
+	// Configurations for logging every request to the API, including proxied
+	// display and file transfer requests. This is applied at runtime.
+	AccessLog *AccessLogConfig `json:"accessLog,omitempty"`
+	// Configurations for exporting traces of API requests, and the desktop reconciles
+	// and proxy requests they lead to, to an OpenTelemetry collector. This is applied
+	// at runtime. Only desktops launched while tracing is enabled export spans from
+	// their proxy.
+	Tracing *TracingConfig `json:"tracing,omitempty"`
 	// The number of app replicas to run
 	Replicas int32 `json:"replicas,omitempty"`
 	// The type of service to create in front of the app instance.
@@ -53,12 +280,162 @@ type AppConfig struct {
 	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
 	// Extra annotations to apply to the app service.
 	ServiceAnnotations map[string]string `json:"serviceAnnotations,omitempty"`
+	// Extra annotations to apply to the app service account, e.g. to bind an IAM role
+	// or workload identity for accessing the secrets backend.
+	ServiceAccountAnnotations map[string]string `json:"serviceAccountAnnotations,omitempty"`
 	// TLS configurations for the app instance
 	TLS *TLSConfig `json:"tls,omitempty"`
 	// Resource requirements to place on the app pods
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// How long app instances wait for active websocket connections to close when
+	// shutting down, before disconnecting them so they can reconnect to a new
+	// replica. Defaults to `2m`.
+	DrainTimeout string `json:"drainTimeout,omitempty"`
 }
 
+// AccessLogConfig contains configurations for the app access log.
+type AccessLogConfig struct {
+	// Set to true to log a line for each request with the user, route, status,
+	// latency, and bytes transferred.
+	Enabled bool `json:"enabled,omitempty"`
+	// Only log one out of every N successful requests. Requests that fail are
+	// always logged. Defaults to 1 (every request).
+	SampleRate int32 `json:"sampleRate,omitempty"`
+	// Route prefixes to exclude from the access log. Defaults to the health,
+	// readiness, and metrics routes.
+	ExcludePaths []string `json:"excludePaths,omitempty"`
+}
+
+// TracingConfig contains configurations for exporting traces to an OpenTelemetry
+// collector.
+type TracingConfig struct {
+	// The base URL of the OTLP/HTTP endpoint of the collector, e.g.
+	// `http://otel-collector.observability:4318`. Spans are sent to `/v1/traces`
+	// using the JSON encoding. Tracing is disabled when empty.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Extra headers to send with each export, e.g. for authenticating with the
+	// collector.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Only sample one out of every N traces started by the app. Requests carrying a
+	// W3C `traceparent` header follow the caller's sampling decision instead.
+	// Defaults to 1 (every trace).
+	SampleRate int32 `json:"sampleRate,omitempty"`
+}
+
+// AuditLogConfig contains configurations for the app audit log.
+type AuditLogConfig struct {
+	// Where to send audit events. Defaults to `stdout`.
+	Backend AuditLogBackend `json:"backend,omitempty"`
+	// The file to append audit events to when using the `file` backend. Defaults
+	// to `/var/log/kvdi/audit.log`.
+	FilePath string `json:"filePath,omitempty"`
+	// Configurations for the `webhook` backend.
+	Webhook *AuditWebhookConfig `json:"webhook,omitempty"`
+	// Additional destinations to forward audit events to, e.g. a SIEM. Events are
+	// still written to the backend, and are sent to each sink in batches in the
+	// background, retrying failed batches.
+	Sinks []AuditSinkConfig `json:"sinks,omitempty"`
+}
+
+// AuditSinkConfig contains configurations for forwarding audit events to an
+// external system. Exactly one of `syslog`, `splunk`, or `webhook` must be set.
+type AuditSinkConfig struct {
+	// A name for the sink, used in log messages.
+	Name string `json:"name"`
+	// Forward events to a syslog server.
+	Syslog *AuditSyslogConfig `json:"syslog,omitempty"`
+	// Forward events to a Splunk HTTP Event Collector.
+	Splunk *AuditSplunkConfig `json:"splunk,omitempty"`
+	// POST events to an HTTP endpoint as JSON arrays.
+	Webhook *AuditWebhookConfig `json:"webhook,omitempty"`
+	// The maximum number of events to send at once. Defaults to 100.
+	BatchSize int `json:"batchSize,omitempty"`
+	// How long to wait for more events before sending a batch that is not full.
+	// Defaults to `1s`.
+	FlushInterval string `json:"flushInterval,omitempty"`
+	// How many times to retry sending a batch before it is dropped. Retries back off
+	// exponentially, starting at one second. Defaults to 5.
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// The number of events that can be waiting to be sent before new ones are
+	// dropped. Defaults to 10000.
+	QueueSize int `json:"queueSize,omitempty"`
+}
+
+// AuditSyslogConfig contains configurations for forwarding audit events to a
+// syslog server. Events are sent in the RFC 5424 format with the JSON encoded
+// event as the message.
+type AuditSyslogConfig struct {
+	// The address of the syslog server, e.g. `syslog.example.com:514`.
+	Address string `json:"address"`
+	// The protocol to send events with. Messages sent over `tcp` and `tls` are
+	// framed with their length as described in RFC 6587. Defaults to `tcp`.
+	Protocol AuditSyslogProtocol `json:"protocol,omitempty"`
+	// The syslog facility to send events with. Defaults to 13 (log audit).
+	Facility *int32 `json:"facility,omitempty"`
+	// The app name to send events with. Defaults to `kvdi`.
+	Tag string `json:"tag,omitempty"`
+	// Skip verifying the certificate of the server when using `tls`.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// AuditSyslogProtocol represents a protocol for sending events to a syslog server.
+// +kubebuilder:validation:Enum=udp;tcp;tls
+type AuditSyslogProtocol string
+
+const (
+	// AuditSyslogUDP sends each event in a single datagram.
+	AuditSyslogUDP AuditSyslogProtocol = "udp"
+	// AuditSyslogTCP sends events over a plain TCP connection.
+	AuditSyslogTCP AuditSyslogProtocol = "tcp"
+	// AuditSyslogTLS sends events over a TLS connection.
+	AuditSyslogTLS AuditSyslogProtocol = "tls"
+)
+
+// AuditSplunkConfig contains configurations for forwarding audit events to a
+// Splunk HTTP Event Collector.
+type AuditSplunkConfig struct {
+	// The base URL of the HTTP Event Collector, e.g. `https://splunk.example.com:8088`.
+	// Events are sent to `/services/collector/event`.
+	URL string `json:"url"`
+	// The key in the secrets backend where the HEC token is stored. Defaults to
+	// `splunk-hec-token`.
+	TokenKey string `json:"tokenKey,omitempty"`
+	// The index to send events to. Defaults to the default index of the token.
+	Index string `json:"index,omitempty"`
+	// The source to set on events. Defaults to the name of the VDICluster.
+	Source string `json:"source,omitempty"`
+	// The sourcetype to set on events. Defaults to `kvdi:audit`.
+	SourceType string `json:"sourceType,omitempty"`
+	// The timeout for each request to the collector. Defaults to `5s`.
+	Timeout string `json:"timeout,omitempty"`
+	// Skip verifying the certificate of the collector.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// AuditWebhookConfig contains configurations for sending audit events to an
+// HTTP endpoint.
+type AuditWebhookConfig struct {
+	// The URL to POST audit events to.
+	URL string `json:"url"`
+	// Extra headers to send with the request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// The timeout for each request to the webhook. Defaults to `5s`.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// AuditLogBackend represents a destination for audit events.
+// +kubebuilder:validation:Enum=stdout;file;webhook
+type AuditLogBackend string
+
+const (
+	// AuditLogStdout writes audit events to stdout as JSON.
+	AuditLogStdout AuditLogBackend = "stdout"
+	// AuditLogFile appends audit events to a file as JSON.
+	AuditLogFile AuditLogBackend = "file"
+	// AuditLogWebhook POSTs audit events to an HTTP endpoint as JSON.
+	AuditLogWebhook AuditLogBackend = "webhook"
+)
+
 // TLSConfig contains TLS configurations for kVDI.
 type TLSConfig struct {
 	// A pre-existing TLS secret to use for the HTTPS listener. If not defined,
@@ -112,18 +489,156 @@ type GrafanaConfig struct {
 type AuthConfig struct {
 	// Allow anonymous users to create desktop instances
 	AllowAnonymous bool `json:"allowAnonymous,omitempty"`
-	// A secret where a generated admin password will be stored
+	// A secret where a generated admin password will be stored. When using the vault
+	// secrets backend, the password is stored at `<secretsPath>/adminPassword` instead.
 	AdminSecret string `json:"adminSecret,omitempty"`
-	// How long issued access tokens should be valid for. When using OIDC auth
-	// you may want to set this to a higher value (e.g. 8-10h) since the refresh token
-	// flow will not be able to lookup a user's grants from the provider. Defaults to `15m`.
+	// How long issued access tokens should be valid for. When using OIDC auth, sessions
+	// can only be renewed if the provider issues refresh tokens (some require the
+	// `offline_access` scope for this). If it does not, you may want to set this to a
+	// higher value (e.g. 8-10h). Defaults to `15m`.
 	TokenDuration string `json:"tokenDuration,omitempty"`
+	// Configurations for the keys access tokens are signed with. When omitted, tokens
+	// are signed with HS256 using a static secret.
+	TokenSigning *TokenSigningConfig `json:"tokenSigning,omitempty"`
 	// Use local auth (secret-backed) authentication
 	LocalAuth *LocalAuthConfig `json:"localAuth,omitempty"`
 	// Use LDAP for authentication.
 	LDAPAuth *LDAPConfig `json:"ldapAuth,omitempty"`
 	// Use OIDC for authentication
 	OIDCAuth *OIDCConfig `json:"oidcAuth,omitempty"`
+	// Use client certificates (e.g. PIV/CAC smart cards) for authentication
+	CertAuth *CertAuthConfig `json:"certAuth,omitempty"`
+	// Use an external HTTP endpoint for authentication
+	WebhookAuth *WebhookAuthConfig `json:"webhookAuth,omitempty"`
+	// Rate limits and lockouts applied to logins and MFA authorizations. When omitted,
+	// the defaults described on each field are used.
+	LoginRateLimit *LoginRateLimitConfig `json:"loginRateLimit,omitempty"`
+	// Configurations for the history of login attempts kept in the secrets backend.
+	// When omitted, the defaults described on each field are used.
+	LoginHistory *LoginHistoryConfig `json:"loginHistory,omitempty"`
+	// Configurations for how users authorize with MFA. When omitted, users that have
+	// enrolled a TOTP secret are prompted for a one-time password.
+	MFA *MFAConfig `json:"mfa,omitempty"`
+}
+
+// TokenSigningConfig configures the keys used for signing access tokens. Keys are
+// generated by the manager and stored in the secrets backend.
+type TokenSigningConfig struct {
+	// The algorithm to sign tokens with. The public keys for `RS256` and `ES256` are
+	// published at `/api/.well-known/jwks.json` so other services can verify tokens.
+	// Defaults to `HS256`.
+	Algorithm TokenSigningAlgorithm `json:"algorithm,omitempty"`
+	// How often a new signing key is generated, in the format of a Go duration string
+	// (e.g. `720h`). Replaced keys keep verifying tokens for another interval, so this
+	// should be longer than the longest token duration on the cluster or its roles.
+	// Keys are not rotated when unset.
+	RotationInterval string `json:"rotationInterval,omitempty"`
+}
+
+// TokenSigningAlgorithm represents an algorithm for signing access tokens.
+// +kubebuilder:validation:Enum=HS256;RS256;ES256
+type TokenSigningAlgorithm string
+
+const (
+	// TokenSigningHS256 signs tokens with HMAC using SHA-256.
+	TokenSigningHS256 TokenSigningAlgorithm = "HS256"
+	// TokenSigningRS256 signs tokens with RSA PKCS#1 v1.5 using SHA-256.
+	TokenSigningRS256 TokenSigningAlgorithm = "RS256"
+	// TokenSigningES256 signs tokens with ECDSA using P-256 and SHA-256.
+	TokenSigningES256 TokenSigningAlgorithm = "ES256"
+)
+
+// MFAConfig configures the provider used for multi-factor authentication.
+type MFAConfig struct {
+	// The provider used to authorize users after they log in. With `totp`, users that
+	// have enrolled a secret provide one-time passwords. With `duo` and `webhook`, every
+	// user must approve a push notification, and enrolling TOTP secrets is disabled.
+	// Defaults to `totp`.
+	Provider MFAProvider `json:"provider,omitempty"`
+	// Configurations for the `duo` provider.
+	Duo *DuoMFAConfig `json:"duo,omitempty"`
+	// Configurations for the `webhook` provider.
+	Webhook *MFAWebhookConfig `json:"webhook,omitempty"`
+}
+
+// MFAProvider represents a provider for multi-factor authentication.
+// +kubebuilder:validation:Enum=totp;duo;webhook
+type MFAProvider string
+
+const (
+	// MFAProviderTOTP authorizes users with one-time passwords from an enrolled secret.
+	MFAProviderTOTP MFAProvider = "totp"
+	// MFAProviderDuo authorizes users with a Duo push.
+	MFAProviderDuo MFAProvider = "duo"
+	// MFAProviderWebhook authorizes users with a push sent by an HTTP endpoint.
+	MFAProviderWebhook MFAProvider = "webhook"
+)
+
+// DuoMFAConfig contains configurations for authorizing users with the Duo Auth API.
+// Usernames in kVDI must match the usernames enrolled in Duo.
+type DuoMFAConfig struct {
+	// The API hostname of the Duo Auth API application, e.g. `api-xxxxxxxx.duosecurity.com`.
+	APIHostname string `json:"apiHostname"`
+	// The integration key of the Duo Auth API application.
+	IntegrationKey string `json:"integrationKey"`
+	// The key in the secrets backend where the secret key of the Duo Auth API application
+	// is stored. Defaults to `duo-secretkey`.
+	SecretKeyKey string `json:"secretKeyKey,omitempty"`
+}
+
+// MFAWebhookConfig contains configurations for authorizing users with a push sent by
+// an HTTP endpoint. When a user needs to authorize, a JSON object with their `user`
+// and `clientAddr` is POSTed to the URL. The endpoint must respond with the `id` of
+// the transaction, which is then polled with a GET to `<url>/<id>`. Polls must respond
+// with a `status` of `pending`, `approved`, or `denied`.
+type MFAWebhookConfig struct {
+	// The URL to send push requests to.
+	URL string `json:"url"`
+	// Extra headers to send with each request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// The key in the secrets backend where a token to send as a bearer token with each
+	// request is stored. No token is sent when the key does not exist. Defaults to
+	// `mfa-webhook-token`.
+	TokenKey string `json:"tokenKey,omitempty"`
+	// The timeout for each request to the webhook. Defaults to `10s`.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// LoginRateLimitConfig configures protections against brute-force and password
+// spraying attempts on the login and MFA authorization routes. Attempts are
+// counted by each app replica separately.
+type LoginRateLimitConfig struct {
+	// Disable rate limiting and lockouts.
+	Disabled bool `json:"disabled,omitempty"`
+	// The window over which attempts are counted. Defaults to `1m`.
+	Window string `json:"window,omitempty"`
+	// The maximum number of attempts allowed from a single client address in a
+	// window. Defaults to 30.
+	MaxAttemptsPerIP int `json:"maxAttemptsPerIP,omitempty"`
+	// The maximum number of attempts allowed for a single username in a window.
+	// Defaults to 10.
+	MaxAttemptsPerUser int `json:"maxAttemptsPerUser,omitempty"`
+	// The number of consecutive failed attempts after which a username is locked
+	// out. Defaults to 5.
+	LockoutThreshold int `json:"lockoutThreshold,omitempty"`
+	// How long a username is locked out the first time. The duration doubles for
+	// each lockout until the user logs in successfully. Defaults to `1m`.
+	LockoutDuration string `json:"lockoutDuration,omitempty"`
+	// The longest a username can be locked out for. Defaults to `1h`.
+	MaxLockoutDuration string `json:"maxLockoutDuration,omitempty"`
+}
+
+// LoginHistoryConfig configures the history of login attempts. Attempts are kept
+// in a single list in the secrets backend, so the number kept should stay small
+// enough to fit in a Kubernetes Secret when using the `k8sSecret` backend.
+type LoginHistoryConfig struct {
+	// Do not record login attempts.
+	Disabled bool `json:"disabled,omitempty"`
+	// The maximum number of attempts kept across all users. The oldest attempts are
+	// removed first. Defaults to 1000.
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// How long attempts are kept for. Defaults to `720h`.
+	Retention string `json:"retention,omitempty"`
 }
 
 // SecretsConfig configurese the backend for secrets management.
@@ -134,16 +649,112 @@ type SecretsConfig struct {
 	// Use vault for storing sensitive values. Requires kubernetes service account
 	// authentication.
 	Vault *VaultConfig `json:"vault,omitempty"`
+	// Use AWS Secrets Manager for storing sensitive values. Credentials are read from
+	// the environment, a web identity token (IAM roles for service accounts), or the
+	// instance metadata service, in that order.
+	AWSSecretsManager *AWSSecretsManagerConfig `json:"awsSecretsManager,omitempty"`
+	// Use GCP Secret Manager for storing sensitive values. Credentials are found using
+	// the application default credentials, which includes workload identity.
+	GCPSecretManager *GCPSecretManagerConfig `json:"gcpSecretManager,omitempty"`
+	// How long secrets read by the app and manager are cached before being read from
+	// the backend again. Defaults to `1h`.
+	CacheTTL string `json:"cacheTTL,omitempty"`
+}
+
+// AWSSecretsManagerConfig represents the configurations for storing secrets in
+// AWS Secrets Manager.
+type AWSSecretsManagerConfig struct {
+	// The region to store secrets in.
+	Region string `json:"region,omitempty"`
+	// A prefix to add to the names of secrets. Defaults to `kvdi/`.
+	SecretsPrefix string `json:"secretsPrefix,omitempty"`
+	// The ID or ARN of the KMS key to encrypt new secrets with. Defaults to the
+	// AWS managed key for Secrets Manager.
+	KMSKeyID string `json:"kmsKeyID,omitempty"`
+	// Override the Secrets Manager endpoint, e.g. for a VPC endpoint.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// IsUndefined returns true if the given AWSSecretsManagerConfig object is not
+// actually configured.
+func (a *AWSSecretsManagerConfig) IsUndefined() bool {
+	return a.Region == ""
+}
+
+// GCPSecretManagerConfig represents the configurations for storing secrets in
+// GCP Secret Manager.
+type GCPSecretManagerConfig struct {
+	// The ID of the project to store secrets in.
+	Project string `json:"project,omitempty"`
+	// A prefix to add to the names of secrets. Defaults to `kvdi-`.
+	SecretsPrefix string `json:"secretsPrefix,omitempty"`
+	// Override the Secret Manager endpoint, e.g. for Private Service Connect.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// IsUndefined returns true if the given GCPSecretManagerConfig object is not
+// actually configured.
+func (g *GCPSecretManagerConfig) IsUndefined() bool {
+	return g.Project == ""
 }
 
 // LocalAuthConfig represents a local, 'passwd'-like authentication driver.
-type LocalAuthConfig struct{}
+type LocalAuthConfig struct {
+	// The policy enforced on passwords when users are created or their passwords
+	// are changed. When omitted, passwords must be at least 8 characters long.
+	PasswordPolicy *PasswordPolicy `json:"passwordPolicy,omitempty"`
+	// How long password reset links emailed to users are valid for. Resets require
+	// `email` to be configured with an `appURL`. Defaults to `1h`.
+	PasswordResetTTL string `json:"passwordResetTTL,omitempty"`
+}
+
+// PasswordPolicy configures the requirements for passwords of local users.
+type PasswordPolicy struct {
+	// The minimum length of a password. Defaults to 8.
+	MinLength int `json:"minLength,omitempty"`
+	// Require passwords to contain an uppercase letter.
+	RequireUppercase bool `json:"requireUppercase,omitempty"`
+	// Require passwords to contain a lowercase letter.
+	RequireLowercase bool `json:"requireLowercase,omitempty"`
+	// Require passwords to contain a number.
+	RequireNumbers bool `json:"requireNumbers,omitempty"`
+	// Require passwords to contain a symbol.
+	RequireSymbols bool `json:"requireSymbols,omitempty"`
+	// Reject passwords that are found in a list of commonly used passwords, or that
+	// contain the username.
+	RejectCommonPasswords bool `json:"rejectCommonPasswords,omitempty"`
+	// Reject passwords that have appeared in known data breaches. Only the first five
+	// characters of the SHA-1 hash of the password are sent to the breach API.
+	CheckBreaches bool `json:"checkBreaches,omitempty"`
+	// The URL of a range API compatible with Have I Been Pwned to use for breach checks.
+	// Defaults to `https://api.pwnedpasswords.com/range/`.
+	BreachAPIURL string `json:"breachAPIURL,omitempty"`
+	// The number of most recent passwords, including the current one, that a user cannot
+	// reuse when changing their password. Defaults to 0, which allows any password to be
+	// reused.
+	HistorySize int `json:"historySize,omitempty"`
+}
 
 // LDAPConfig represents the configurations for using LDAP as the authentication
 // backend.
 type LDAPConfig struct {
 	// The URL to the LDAP server.
 	URL string `json:"url,omitempty"`
+	// URLs of additional LDAP servers replicating the same directory. When a server
+	// cannot be reached, the next one is tried, starting with `url`.
+	URLs []string `json:"urls,omitempty"`
+	// Set to true to spread new connections across all of the servers in turn,
+	// instead of only using the others when `url` is unreachable.
+	RoundRobin bool `json:"roundRobin,omitempty"`
+	// How long a server that could not be reached is skipped before it is tried
+	// again. Unreachable servers are also checked whenever the health of the app is.
+	// Defaults to `30s`.
+	HealthCheckInterval string `json:"healthCheckInterval,omitempty"`
+	// How long to wait when connecting to a server before trying the next one.
+	// Defaults to `5s`.
+	ConnectTimeout string `json:"connectTimeout,omitempty"`
+	// How long to wait for the response to a bind or search. Defaults to `30s`.
+	SearchTimeout string `json:"searchTimeout,omitempty"`
 	// Set to true to skip TLS verification of an `ldaps` connection.
 	TLSInsecureSkipVerify bool `json:"tlsInsecureSkipVerify,omitempty"`
 	// The base64 encoded CA certificate to use when verifying the TLS certificate of
@@ -167,12 +778,89 @@ type LDAPConfig struct {
 	// The base scope to search for users in. Default is to search the entire
 	// directory.
 	UserSearchBase string `json:"userSearchBase,omitempty"`
+	// Configurations for following referrals returned by the LDAP server. This is
+	// required when users are spread across multiple domains in an AD forest.
+	Referrals *LDAPReferralConfig `json:"referrals,omitempty"`
+	// Set to true when the server is Active Directory. Users are looked up by their
+	// `sAMAccountName`, disabled accounts are detected from `userAccountControl`, and
+	// nested group membership is resolved by the server so that roles bound to
+	// parent groups apply to their members.
+	ActiveDirectory bool `json:"activeDirectory,omitempty"`
+	// Set to true to resolve nested group membership on servers other than Active
+	// Directory, by recursively looking up the groups each of a user's groups is a
+	// member of. Only applies to logins, listing the users bound to a role only
+	// considers direct members.
+	NestedGroups bool `json:"nestedGroups,omitempty"`
+	// The maximum depth to follow nested groups when `nestedGroups` is set. Defaults
+	// to 10.
+	MaxGroupDepth int32 `json:"maxGroupDepth,omitempty"`
+	// A filter users must also match when they are looked up, e.g.
+	// `(objectClass=inetOrgPerson)`. Defaults to
+	// `(&(objectCategory=person)(objectClass=user))` when `activeDirectory` is set,
+	// and to no extra filter otherwise.
+	UserFilter string `json:"userFilter,omitempty"`
+	// Overrides for the names of the attributes read from user entries, for
+	// directories that do not use the standard schema.
+	Attributes *LDAPAttributeConfig `json:"attributes,omitempty"`
+	// Configurations for the pool of connections kept open to the LDAP server.
+	Pool *LDAPPoolConfig `json:"pool,omitempty"`
+}
+
+// LDAPAttributeConfig represents the names of the attributes read from user
+// entries in the directory.
+type LDAPAttributeConfig struct {
+	// The attribute users log in with. Defaults to `uid`, or `sAMAccountName` when
+	// `activeDirectory` is set.
+	Username string `json:"username,omitempty"`
+	// The attribute listing the DNs of the groups an entry is a member of. Defaults
+	// to `memberOf`.
+	MemberOf string `json:"memberOf,omitempty"`
+	// The attribute holding the status of an account. Defaults to `accountStatus`.
+	// When `activeDirectory` is set, the flags in `userAccountControl` are checked
+	// instead unless this is set.
+	AccountStatus string `json:"accountStatus,omitempty"`
+	// The values of the account status attribute that allow an account to log in,
+	// compared without regard to case. Defaults to `active`.
+	AccountStatusActiveValues []string `json:"accountStatusActiveValues,omitempty"`
+	// The attribute holding the email address of a user. Notifications are sent to
+	// it when the user has not set an address in kVDI. Defaults to `mail`.
+	Mail string `json:"mail,omitempty"`
+	// The attribute holding the display name of a user. Defaults to `displayName`.
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// LDAPPoolConfig represents configurations for pooling connections to the LDAP
+// server.
+type LDAPPoolConfig struct {
+	// The number of idle connections to keep open to the LDAP server. Requests made
+	// while all of them are in use open additional connections that are closed when
+	// done. Defaults to 10.
+	Size int32 `json:"size,omitempty"`
+	// How long a connection may sit idle in the pool before it is closed. Defaults
+	// to `5m`.
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+}
+
+// LDAPReferralConfig represents configurations for following LDAP referrals.
+type LDAPReferralConfig struct {
+	// Set to true to follow referrals returned from searches. Default is to ignore them.
+	Follow bool `json:"follow,omitempty"`
+	// The maximum number of hops to follow away from the configured server. Referrals
+	// beyond this depth are ignored. Defaults to 3.
+	MaxHops int32 `json:"maxHops,omitempty"`
+	// Hosts that referrals may be followed to. Entries may be an exact hostname or
+	// a wildcard such as `*.example.com`. Default is to follow referrals to any host.
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+	// Hosts that are trusted with the bind credentials and user passwords. Referred
+	// servers not in this list are searched anonymously and users found on them
+	// are not allowed to log in. Entries follow the same rules as `allowedHosts`.
+	TrustedHosts []string `json:"trustedHosts,omitempty"`
 }
 
 // IsUndefined returns true if the given LDAPConfig object is not actually configured.
 // It checks that required values are present.
 func (l *LDAPConfig) IsUndefined() bool {
-	return l.URL == ""
+	return l.URL == "" && len(l.URLs) == 0
 }
 
 // OIDCConfig represents configurations for using an OIDC/OAuth provider for
@@ -200,8 +888,15 @@ type OIDCConfig struct {
 	// `["openid", "email", "profile", "groups"]`.
 	Scopes []string `json:"scopes,omitempty"`
 	// If your OIDC provider does not return a `groups` object, set this to the user
-	// attribute to use for binding authenticated users to VDIRoles. Defaults to `groups`.
+	// attribute to use for binding authenticated users to VDIRoles. Values in the claim
+	// are matched against the `kvdi.io/oidc-groups` annotation on VDIRoles. Nested claims
+	// can be referenced with dot notation, for example `realm_access.roles`. Defaults to `groups`.
 	GroupScope string `json:"groupScope,omitempty"`
+	// Additional claims to match against the `kvdi.io/oidc-groups` annotation on VDIRoles,
+	// in the same format as the `groupScope`. This is useful for providers that return
+	// roles and groups in separate claims. Claims may contain a list of strings or a single
+	// string.
+	RoleClaims []string `json:"roleClaims,omitempty"`
 	// Groups that are allowed administrator access to the cluster. Kubernetes
 	// admins will still have the ability to change rbac configurations via the CRDs.
 	AdminGroups []string `json:"adminGroups,omitempty"`
@@ -214,6 +909,139 @@ type OIDCConfig struct {
 	// valid alternative) and/or you would like to allow any authenticated user
 	// read-only access.
 	AllowNonGroupedReadOnly bool `json:"allowNonGroupedReadOnly,omitempty"`
+	// Set to true to disable PKCE (S256) during the authorization code flow. PKCE is
+	// enabled by default and should only be disabled for providers that reject the
+	// `code_challenge` parameters.
+	DisablePKCE bool `json:"disablePKCE,omitempty"`
+	// The method to use when authenticating to the token endpoint of the OIDC provider.
+	// When omitted, the method is auto-detected between `client_secret_basic` and
+	// `client_secret_post`.
+	TokenEndpointAuthMethod OIDCTokenEndpointAuthMethod `json:"tokenEndpointAuthMethod,omitempty"`
+	// When using `private_key_jwt` as the `tokenEndpointAuthMethod`, the key in the
+	// secret where the PEM encoded private key for signing client assertions is stored.
+	// This is retrieved in the same manner as the `clientIDKey`. RSA and ECDSA keys are
+	// supported. Defaults to `oidc-privatekey`.
+	PrivateKeyKey string `json:"privateKeyKey,omitempty"`
+	// When using `private_key_jwt` as the `tokenEndpointAuthMethod`, an optional key ID
+	// to place in the `kid` header of client assertions.
+	PrivateKeyID string `json:"privateKeyID,omitempty"`
+	// The URL the OIDC provider should send users back to after they log out. It must be
+	// registered with the provider as a post-logout redirect URI. Defaults to the root of
+	// the `redirectURL`.
+	PostLogoutRedirectURL string `json:"postLogoutRedirectURL,omitempty"`
+	// Set to true to only end the kVDI session when users log out, leaving them signed
+	// in to the OIDC provider. By default users are also sent to the `end_session_endpoint`
+	// of the provider when it advertises one.
+	DisableProviderLogout bool `json:"disableProviderLogout,omitempty"`
+	// Configurations for authenticating with Azure AD. When set, the groups of users are
+	// resolved through Microsoft Graph when Azure AD leaves them out of the ID token, which
+	// it does for users in more than 200 groups. The `issuerURL` should be the v2.0 endpoint
+	// of the tenant, for example `https://login.microsoftonline.com/<tenant-id>/v2.0`.
+	AzureAD *AzureADConfig `json:"azureAD,omitempty"`
+}
+
+// AzureADConfig represents configurations for resolving the groups of users
+// authenticated with Azure AD. Groups are matched by their object IDs against the
+// `kvdi.io/oidc-groups` annotation on VDIRoles, and in the `adminGroups`.
+//
+// Microsoft Graph is queried with the client credentials of the application, which
+// requires the `GroupMember.Read.All` application permission.
+type AzureADConfig struct {
+	// The base URL of Microsoft Graph. Change this for national clouds. Defaults to
+	// `https://graph.microsoft.com`.
+	GraphURL string `json:"graphURL,omitempty"`
+	// Set to true to only resolve the security groups of users from Microsoft Graph.
+	// This should match the group claims configured for the application.
+	SecurityGroupsOnly bool `json:"securityGroupsOnly,omitempty"`
+}
+
+// OIDCTokenEndpointAuthMethod represents a method for authenticating to the token
+// endpoint of an OIDC provider.
+// +kubebuilder:validation:Enum=client_secret_basic;client_secret_post;private_key_jwt
+type OIDCTokenEndpointAuthMethod string
+
+const (
+	// OIDCAuthClientSecretBasic sends the client credentials in an HTTP basic auth header.
+	OIDCAuthClientSecretBasic OIDCTokenEndpointAuthMethod = "client_secret_basic"
+	// OIDCAuthClientSecretPost sends the client credentials in the request body.
+	OIDCAuthClientSecretPost OIDCTokenEndpointAuthMethod = "client_secret_post"
+	// OIDCAuthPrivateKeyJWT authenticates with a client assertion signed by a private key.
+	OIDCAuthPrivateKeyJWT OIDCTokenEndpointAuthMethod = "private_key_jwt"
+)
+
+// CertAuthConfig represents configurations for authenticating users with client
+// certificates. Certificates are either requested by the app during the TLS handshake,
+// or read from a header set by an ingress that terminates TLS. In both cases they are
+// verified against the configured CAs. Attributes of the certificate are matched against
+// the `kvdi.io/cert-groups` annotation on VDIRoles.
+type CertAuthConfig struct {
+	// The base64 encoded PEM bundle of the CAs that issue client certificates. This
+	// should include any intermediate CAs, since clients are not expected to send them.
+	ClientCACert string `json:"clientCACert,omitempty"`
+	// When set, the client certificate is read from this header instead of the TLS
+	// connection to the app. The header should contain the URL encoded PEM certificate,
+	// for example the `ssl-client-cert` header set by ingress-nginx with
+	// `nginx.ingress.kubernetes.io/auth-tls-pass-certificate-to-upstream`. The app must
+	// only be reachable through an ingress that sets or strips this header.
+	TrustedHeader string `json:"trustedHeader,omitempty"`
+	// The attribute of the certificate to use for usernames. `upn` reads the user
+	// principal name found in the SAN of most PIV/CAC certificates. Defaults to `cn`.
+	UsernameAttribute CertUsernameAttribute `json:"usernameAttribute,omitempty"`
+	// Organizational units, SAN email addresses, UPNs, or URIs that are allowed
+	// administrator access to the cluster.
+	AdminGroups []string `json:"adminGroups,omitempty"`
+	// Set to true to allow users whose certificates are not bound to any VDIRoles
+	// read-only access.
+	AllowNonGroupedReadOnly bool `json:"allowNonGroupedReadOnly,omitempty"`
+}
+
+// CertUsernameAttribute represents an attribute of a client certificate used for
+// usernames.
+// +kubebuilder:validation:Enum=cn;email;upn
+type CertUsernameAttribute string
+
+const (
+	// CertUsernameCN uses the common name in the subject of the certificate.
+	CertUsernameCN CertUsernameAttribute = "cn"
+	// CertUsernameEmail uses the local part of the first email address in the SAN.
+	CertUsernameEmail CertUsernameAttribute = "email"
+	// CertUsernameUPN uses the local part of the user principal name in the SAN.
+	CertUsernameUPN CertUsernameAttribute = "upn"
+)
+
+// WebhookAuthConfig contains configurations for authenticating users against an HTTP
+// endpoint, for identity systems kVDI does not integrate with. The login request, with
+// the `username` and `password` of the user, is POSTed to the URL as JSON. The request
+// is signed with HMAC-SHA256 using the key in the secrets backend, which is generated by
+// the manager if it does not exist. The `X-Kvdi-Timestamp` header holds the unix time of
+// the request, and the `X-Kvdi-Signature` header holds `sha256=` followed by the hex
+// encoded signature of the timestamp, a `.`, and the body. The endpoint should reject
+// requests with an invalid signature or an old timestamp.
+//
+// The endpoint must respond with a 200 status and a user with a `name` and `roles`,
+// where each role has the `name` of a VDIRole in the cluster (e.g. `{"name": "jdoe",
+// "roles": [{"name": "kvdi-admin"}]}`). The rules of the roles are read from the cluster,
+// and roles that do not exist are ignored. Any other status rejects the login.
+type WebhookAuthConfig struct {
+	// The URL to send login requests to.
+	URL string `json:"url,omitempty"`
+	// Extra headers to send with each request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// The key in the secrets backend where the key for signing requests is stored.
+	// Defaults to `auth-webhook-signing-key`.
+	SigningKeyKey string `json:"signingKeyKey,omitempty"`
+	// The timeout for each request to the webhook. Defaults to `10s`.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// IsUndefined returns true if the given WebhookAuthConfig object is not actually configured.
+func (w *WebhookAuthConfig) IsUndefined() bool {
+	return w.URL == ""
+}
+
+// IsUndefined returns true if the given CertAuthConfig object is not actually configured.
+func (c *CertAuthConfig) IsUndefined() bool {
+	return c.ClientCACert == ""
 }
 
 // IsUndefined returns true if the given OIDCConfig object is not actually configured.
@@ -243,10 +1071,26 @@ type VaultConfig struct {
 	// The base path to store secrets in vault. "Keys" for other configurations in the
 	// context of the vault backend can be put at `<secretsPath>/<secretKey>.data`. This
 	// will change in the future to support keys inside the secret itself, instead of assuming
-	// `data`.
+	// `data`. When using KV v2, this is the path without the `data/` prefix.
 	SecretsPath string `json:"secretsPath,omitempty"`
+	// The version of the KV secrets engine mounted at the secrets path. Defaults to `v1`.
+	KVVersion VaultKVVersion `json:"kvVersion,omitempty"`
+	// When using KV v2, the path where the secrets engine is mounted. The `secretsPath`
+	// must be inside it. Defaults to the first segment of the `secretsPath`.
+	KVMountPath string `json:"kvMountPath,omitempty"`
 }
 
+// VaultKVVersion represents a version of the KV secrets engine in vault.
+// +kubebuilder:validation:Enum=v1;v2
+type VaultKVVersion string
+
+const (
+	// VaultKVv1 represents the KV version 1 secrets engine.
+	VaultKVv1 VaultKVVersion = "v1"
+	// VaultKVv2 represents the versioned KV version 2 secrets engine.
+	VaultKVv2 VaultKVVersion = "v2"
+)
+
 // IsUndefined returns true if the given VaultConfig object is not actually configured.
 // It checks that required values are present.
 func (v *VaultConfig) IsUndefined() bool {
@@ -255,7 +1099,52 @@ func (v *VaultConfig) IsUndefined() bool {
 
 // VDIClusterStatus defines the observed state of VDICluster
 type VDIClusterStatus struct {
+	// True when all of the conditions are true.
 	Ready bool `json:"ready,omitempty"`
+	// The state of each of the components of the cluster, as last observed by the
+	// manager.
+	Conditions []VDIClusterCondition `json:"conditions,omitempty"`
+}
+
+// VDIClusterConditionType represents a component of a VDICluster reported in its
+// status.
+type VDIClusterConditionType string
+
+const (
+	// VDIClusterSecretsBackendReady is true when the secrets backend could be set up
+	// and responded to a health check.
+	VDIClusterSecretsBackendReady VDIClusterConditionType = "SecretsBackendReady"
+	// VDIClusterAuthProviderReady is true when the resources required by the auth
+	// provider were reconciled.
+	VDIClusterAuthProviderReady VDIClusterConditionType = "AuthProviderReady"
+	// VDIClusterPKIReady is true when the CA and the certificates for mTLS between
+	// the app and desktops were reconciled.
+	VDIClusterPKIReady VDIClusterConditionType = "PKIReady"
+	// VDIClusterAppReady is true when all of the replicas of the app are ready.
+	VDIClusterAppReady VDIClusterConditionType = "AppReady"
+)
+
+// VDIClusterConditionTypes are all of the conditions reported on a VDICluster.
+var VDIClusterConditionTypes = []VDIClusterConditionType{
+	VDIClusterSecretsBackendReady,
+	VDIClusterAuthProviderReady,
+	VDIClusterPKIReady,
+	VDIClusterAppReady,
+}
+
+// VDIClusterCondition represents the state of a single component of a VDICluster.
+type VDIClusterCondition struct {
+	// The component the condition is for.
+	Type VDIClusterConditionType `json:"type"`
+	// Whether the component is ready, one of `True`, `False`, or `Unknown`.
+	Status corev1.ConditionStatus `json:"status"`
+	// When the status last changed.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// A machine-readable reason for the status.
+	Reason string `json:"reason,omitempty"`
+	// A human-readable description of the status, e.g. the error that made the
+	// component fail.
+	Message string `json:"message,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -263,6 +1152,12 @@ type VDIClusterStatus struct {
 // VDICluster is the Schema for the vdiclusters API
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=vdiclusters,scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Secrets",type=string,JSONPath=`.status.conditions[?(@.type=="SecretsBackendReady")].status`
+// +kubebuilder:printcolumn:name="Auth",type=string,JSONPath=`.status.conditions[?(@.type=="AuthProviderReady")].status`
+// +kubebuilder:printcolumn:name="PKI",type=string,JSONPath=`.status.conditions[?(@.type=="PKIReady")].status`
+// +kubebuilder:printcolumn:name="App",type=string,JSONPath=`.status.conditions[?(@.type=="AppReady")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type VDICluster struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
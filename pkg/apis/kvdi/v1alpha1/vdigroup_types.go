@@ -0,0 +1,50 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VDIGroup is the Schema for the vdigroups API. Groups bind roles to a set of
+// users when using local authentication.
+// +kubebuilder:resource:path=vdigroups,scope=Cluster
+type VDIGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// The names of the users that are members of the group.
+	Users []string `json:"users,omitempty"`
+	// The names of the VDIRoles granted to members of the group, in addition to the
+	// roles assigned to them directly.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// GetUsers returns the names of the members of this VDIGroup.
+func (g *VDIGroup) GetUsers() []string { return g.Users }
+
+// GetRoles returns the names of the roles bound to this VDIGroup.
+func (g *VDIGroup) GetRoles() []string { return g.Roles }
+
+// HasUser returns true if the given user is a member of this VDIGroup.
+func (g *VDIGroup) HasUser(username string) bool {
+	for _, user := range g.Users {
+		if user == username {
+			return true
+		}
+	}
+	return false
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VDIGroupList contains a list of VDIGroup
+type VDIGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VDIGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VDIGroup{}, &VDIGroupList{})
+}
@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+)
+
+// GetGrants returns all the grants temporarily binding this role to users,
+// including ones that have expired.
+func (v *VDIRole) GetGrants() ([]*v1.RoleGrant, error) {
+	grants := make([]*v1.RoleGrant, 0)
+	raw, ok := v.GetAnnotations()[v1.RoleGrantsAnnotation]
+	if !ok || raw == "" {
+		return grants, nil
+	}
+	return grants, json.Unmarshal([]byte(raw), &grants)
+}
+
+// GetActiveGrants returns the grants for this role that have not expired.
+func (v *VDIRole) GetActiveGrants() ([]*v1.RoleGrant, error) {
+	grants, err := v.GetGrants()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	active := make([]*v1.RoleGrant, 0, len(grants))
+	for _, grant := range grants {
+		if grant.ExpiresAt > now {
+			active = append(active, grant)
+		}
+	}
+	return active, nil
+}
+
+// GetActiveGrantForUser returns the grant binding this role to the given user
+// if it has not expired. Nil is returned otherwise.
+func (v *VDIRole) GetActiveGrantForUser(username string) (*v1.RoleGrant, error) {
+	grants, err := v.GetActiveGrants()
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range grants {
+		if grant.User == username {
+			return grant, nil
+		}
+	}
+	return nil, nil
+}
+
+// SetGrants writes the given grants to the annotations of this role. The role still
+// needs to be updated in the cluster.
+func (v *VDIRole) SetGrants(grants []*v1.RoleGrant) error {
+	annotations := v.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if len(grants) == 0 {
+		delete(annotations, v1.RoleGrantsAnnotation)
+	} else {
+		out, err := json.Marshal(grants)
+		if err != nil {
+			return err
+		}
+		annotations[v1.RoleGrantsAnnotation] = string(out)
+	}
+	v.SetAnnotations(annotations)
+	return nil
+}
+
+// PruneExpiredGrants removes the grants that have expired from this role. It returns
+// true if any were removed, along with the unix timestamp of when the next of the
+// remaining grants expires, or zero if there are none left.
+func (v *VDIRole) PruneExpiredGrants() (pruned bool, nextExpiry int64, err error) {
+	grants, err := v.GetGrants()
+	if err != nil {
+		return false, 0, err
+	}
+	active, err := v.GetActiveGrants()
+	if err != nil {
+		return false, 0, err
+	}
+	for _, grant := range active {
+		if nextExpiry == 0 || grant.ExpiresAt < nextExpiry {
+			nextExpiry = grant.ExpiresAt
+		}
+	}
+	if len(active) == len(grants) {
+		return false, nextExpiry, nil
+	}
+	return true, nextExpiry, v.SetGrants(active)
+}
@@ -16,17 +16,75 @@ type VDIRole struct {
 
 	// A list of rules granting access to resources in the VDICluster.
 	Rules []v1.Rule `json:"rules,omitempty"`
+	// The names of other roles to inherit rules from. The rules of inherited roles,
+	// including the ones they inherit themselves, are added to this role's when the
+	// roles of a user are resolved. Only rules are inherited, other settings like
+	// token durations and session quotas are not.
+	Inherits []string `json:"inherits,omitempty"`
+	// The maximum lifetime of session tokens issued to users with this role,
+	// e.g. `15m` for privileged roles or `24h` for kiosks. When a user holds
+	// multiple roles, the shortest lifetime applies. Defaults to the token
+	// duration configured on the VDICluster.
+	TokenDuration string `json:"tokenDuration,omitempty"`
+	// Watermark the display of any desktop session accessed by users with this role,
+	// regardless of the DesktopTemplate configuration.
+	Watermark bool `json:"watermark,omitempty"`
+	// The maximum number of desktop sessions a user with this role may run at once.
+	// When a user holds multiple roles, the lowest limit applies. Zero means no limit.
+	MaxSessions int32 `json:"maxSessions,omitempty"`
+	// The maximum number of desktop sessions a user with this role may run at once
+	// from any single template. When a user holds multiple roles, the lowest limit
+	// applies. Zero means no limit.
+	MaxSessionsPerTemplate int32 `json:"maxSessionsPerTemplate,omitempty"`
+	// Constrains the namespaces and nodes the desktop sessions of users with this
+	// role run on, and the total CPU and memory they may consume. When a user holds
+	// multiple roles, the constraints of all of them apply.
+	Placement *v1.SessionPlacement `json:"placement,omitempty"`
+	// Require users with this role to complete MFA when they log in, regardless of
+	// whether they enabled it themselves. Users that have not set up MFA yet are
+	// only allowed to enroll until they do.
+	RequireMFA bool `json:"requireMFA,omitempty"`
 }
 
 // GetRules returns the rules for this VDIRole.
 func (v *VDIRole) GetRules() []v1.Rule { return v.Rules }
 
+// GetEffectiveRules returns the rules for this role along with the data loss
+// prevention verbs they imply.
+func (v *VDIRole) GetEffectiveRules() []v1.Rule { return v1.EffectiveRules(v.Rules) }
+
+// GetInherits returns the names of the roles this VDIRole inherits rules from.
+func (v *VDIRole) GetInherits() []string { return v.Inherits }
+
+// GetTokenDuration returns the token lifetime configured for this VDIRole.
+func (v *VDIRole) GetTokenDuration() string { return v.TokenDuration }
+
+// WatermarkEnabled returns true if this VDIRole requires displays to be watermarked.
+func (v *VDIRole) WatermarkEnabled() bool { return v.Watermark }
+
+// MFARequired returns true if this VDIRole requires its users to complete MFA.
+func (v *VDIRole) MFARequired() bool { return v.RequireMFA }
+
+// GetMaxSessions returns the session quota configured for this VDIRole.
+func (v *VDIRole) GetMaxSessions() int32 { return v.MaxSessions }
+
+// GetMaxSessionsPerTemplate returns the per-template session quota configured for
+// this VDIRole.
+func (v *VDIRole) GetMaxSessionsPerTemplate() int32 { return v.MaxSessionsPerTemplate }
+
 // ToUserRole converts this VDIRole to the VDIUserRole format. The VDIUserRole is
 // a condensed representation meant to be stored in JWTs.
 func (v *VDIRole) ToUserRole() *v1.VDIUserRole {
 	return &v1.VDIUserRole{
-		Name:  v.GetName(),
-		Rules: v.GetRules(),
+		Name:          v.GetName(),
+		Rules:         v.GetRules(),
+		TokenDuration: v.GetTokenDuration(),
+		Watermark:     v.WatermarkEnabled(),
+		RequireMFA:    v.MFARequired(),
+
+		MaxSessions:            v.GetMaxSessions(),
+		MaxSessionsPerTemplate: v.GetMaxSessionsPerTemplate(),
+		Placement:              v.Placement,
 	}
 }
 
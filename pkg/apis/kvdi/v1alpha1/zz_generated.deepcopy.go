@@ -7,12 +7,64 @@ package v1alpha1
 import (
 	metav1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	v1 "k8s.io/api/core/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSSecretsManagerConfig) DeepCopyInto(out *AWSSecretsManagerConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSSecretsManagerConfig.
+func (in *AWSSecretsManagerConfig) DeepCopy() *AWSSecretsManagerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSSecretsManagerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessLogConfig) DeepCopyInto(out *AccessLogConfig) {
+	*out = *in
+	if in.ExcludePaths != nil {
+		in, out := &in.ExcludePaths, &out.ExcludePaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessLogConfig.
+func (in *AccessLogConfig) DeepCopy() *AccessLogConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessLogConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppConfig) DeepCopyInto(out *AppConfig) {
 	*out = *in
+	if in.Audit != nil {
+		in, out := &in.Audit, &out.Audit
+		*out = new(AuditLogConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AccessLog != nil {
+		in, out := &in.AccessLog, &out.AccessLog
+		*out = new(AccessLogConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tracing != nil {
+		in, out := &in.Tracing, &out.Tracing
+		*out = new(TracingConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ServiceAnnotations != nil {
 		in, out := &in.ServiceAnnotations, &out.ServiceAnnotations
 		*out = make(map[string]string, len(*in))
@@ -20,6 +72,13 @@ func (in *AppConfig) DeepCopyInto(out *AppConfig) {
 			(*out)[key] = val
 		}
 	}
+	if in.ServiceAccountAnnotations != nil {
+		in, out := &in.ServiceAccountAnnotations, &out.ServiceAccountAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.TLS != nil {
 		in, out := &in.TLS, &out.TLS
 		*out = new(TLSConfig)
@@ -39,13 +98,142 @@ func (in *AppConfig) DeepCopy() *AppConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditLogConfig) DeepCopyInto(out *AuditLogConfig) {
+	*out = *in
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(AuditWebhookConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sinks != nil {
+		in, out := &in.Sinks, &out.Sinks
+		*out = make([]AuditSinkConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditLogConfig.
+func (in *AuditLogConfig) DeepCopy() *AuditLogConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditLogConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditSinkConfig) DeepCopyInto(out *AuditSinkConfig) {
+	*out = *in
+	if in.Syslog != nil {
+		in, out := &in.Syslog, &out.Syslog
+		*out = new(AuditSyslogConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Splunk != nil {
+		in, out := &in.Splunk, &out.Splunk
+		*out = new(AuditSplunkConfig)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(AuditWebhookConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditSinkConfig.
+func (in *AuditSinkConfig) DeepCopy() *AuditSinkConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditSinkConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditSplunkConfig) DeepCopyInto(out *AuditSplunkConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditSplunkConfig.
+func (in *AuditSplunkConfig) DeepCopy() *AuditSplunkConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditSplunkConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditSyslogConfig) DeepCopyInto(out *AuditSyslogConfig) {
+	*out = *in
+	if in.Facility != nil {
+		in, out := &in.Facility, &out.Facility
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditSyslogConfig.
+func (in *AuditSyslogConfig) DeepCopy() *AuditSyslogConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditSyslogConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditWebhookConfig) DeepCopyInto(out *AuditWebhookConfig) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditWebhookConfig.
+func (in *AuditWebhookConfig) DeepCopy() *AuditWebhookConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditWebhookConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthConfig) DeepCopyInto(out *AuthConfig) {
 	*out = *in
+	if in.TokenSigning != nil {
+		in, out := &in.TokenSigning, &out.TokenSigning
+		*out = new(TokenSigningConfig)
+		**out = **in
+	}
 	if in.LocalAuth != nil {
 		in, out := &in.LocalAuth, &out.LocalAuth
 		*out = new(LocalAuthConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.LDAPAuth != nil {
 		in, out := &in.LDAPAuth, &out.LDAPAuth
@@ -57,6 +245,31 @@ func (in *AuthConfig) DeepCopyInto(out *AuthConfig) {
 		*out = new(OIDCConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CertAuth != nil {
+		in, out := &in.CertAuth, &out.CertAuth
+		*out = new(CertAuthConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WebhookAuth != nil {
+		in, out := &in.WebhookAuth, &out.WebhookAuth
+		*out = new(WebhookAuthConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LoginRateLimit != nil {
+		in, out := &in.LoginRateLimit, &out.LoginRateLimit
+		*out = new(LoginRateLimitConfig)
+		**out = **in
+	}
+	if in.LoginHistory != nil {
+		in, out := &in.LoginHistory, &out.LoginHistory
+		*out = new(LoginHistoryConfig)
+		**out = **in
+	}
+	if in.MFA != nil {
+		in, out := &in.MFA, &out.MFA
+		*out = new(MFAConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -71,141 +284,132 @@ func (in *AuthConfig) DeepCopy() *AuthConfig {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Desktop) DeepCopyInto(out *Desktop) {
+func (in *AvailabilityConfig) DeepCopyInto(out *AvailabilityConfig) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]AvailabilityWindow, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Desktop.
-func (in *Desktop) DeepCopy() *Desktop {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AvailabilityConfig.
+func (in *AvailabilityConfig) DeepCopy() *AvailabilityConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(Desktop)
+	out := new(AvailabilityConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Desktop) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DesktopConfig) DeepCopyInto(out *DesktopConfig) {
+func (in *AvailabilityWindow) DeepCopyInto(out *AvailabilityWindow) {
 	*out = *in
-	if in.Capabilities != nil {
-		in, out := &in.Capabilities, &out.Capabilities
-		*out = make([]v1.Capability, len(*in))
-		copy(*out, *in)
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopConfig.
-func (in *DesktopConfig) DeepCopy() *DesktopConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AvailabilityWindow.
+func (in *AvailabilityWindow) DeepCopy() *AvailabilityWindow {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopConfig)
+	out := new(AvailabilityWindow)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DesktopList) DeepCopyInto(out *DesktopList) {
+func (in *AzureADConfig) DeepCopyInto(out *AzureADConfig) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Desktop, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopList.
-func (in *DesktopList) DeepCopy() *DesktopList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureADConfig.
+func (in *AzureADConfig) DeepCopy() *AzureADConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopList)
+	out := new(AzureADConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *DesktopList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DesktopSpec) DeepCopyInto(out *DesktopSpec) {
+func (in *BillingConfig) DeepCopyInto(out *BillingConfig) {
 	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3ExportConfig)
+		**out = **in
+	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(HTTPExportConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Prices != nil {
+		in, out := &in.Prices, &out.Prices
+		*out = new(PriceConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopSpec.
-func (in *DesktopSpec) DeepCopy() *DesktopSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BillingConfig.
+func (in *BillingConfig) DeepCopy() *BillingConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopSpec)
+	out := new(BillingConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DesktopStatus) DeepCopyInto(out *DesktopStatus) {
+func (in *CertAuthConfig) DeepCopyInto(out *CertAuthConfig) {
 	*out = *in
+	if in.AdminGroups != nil {
+		in, out := &in.AdminGroups, &out.AdminGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopStatus.
-func (in *DesktopStatus) DeepCopy() *DesktopStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertAuthConfig.
+func (in *CertAuthConfig) DeepCopy() *CertAuthConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopStatus)
+	out := new(CertAuthConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DesktopTemplate) DeepCopyInto(out *DesktopTemplate) {
+func (in *Desktop) DeepCopyInto(out *Desktop) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopTemplate.
-func (in *DesktopTemplate) DeepCopy() *DesktopTemplate {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Desktop.
+func (in *Desktop) DeepCopy() *Desktop {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopTemplate)
+	out := new(Desktop)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *DesktopTemplate) DeepCopyObject() runtime.Object {
+func (in *Desktop) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -213,13 +417,49 @@ func (in *DesktopTemplate) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DesktopTemplateList) DeepCopyInto(out *DesktopTemplateList) {
+func (in *DesktopConfig) DeepCopyInto(out *DesktopConfig) {
+	*out = *in
+	if in.Capabilities != nil {
+		in, out := &in.Capabilities, &out.Capabilities
+		*out = make([]v1.Capability, len(*in))
+		copy(*out, *in)
+	}
+	if in.RDP != nil {
+		in, out := &in.RDP, &out.RDP
+		*out = new(RDPConfig)
+		**out = **in
+	}
+	if in.USB != nil {
+		in, out := &in.USB, &out.USB
+		*out = new(USBConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Display != nil {
+		in, out := &in.Display, &out.Display
+		*out = new(DisplayConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopConfig.
+func (in *DesktopConfig) DeepCopy() *DesktopConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopList) DeepCopyInto(out *DesktopList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]DesktopTemplate, len(*in))
+		*out = make([]Desktop, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -227,18 +467,18 @@ func (in *DesktopTemplateList) DeepCopyInto(out *DesktopTemplateList) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopTemplateList.
-func (in *DesktopTemplateList) DeepCopy() *DesktopTemplateList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopList.
+func (in *DesktopList) DeepCopy() *DesktopList {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopTemplateList)
+	out := new(DesktopList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *DesktopTemplateList) DeepCopyObject() runtime.Object {
+func (in *DesktopList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -246,14 +486,283 @@ func (in *DesktopTemplateList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DesktopTemplateSpec) DeepCopyInto(out *DesktopTemplateSpec) {
+func (in *DesktopNetworkConfig) DeepCopyInto(out *DesktopNetworkConfig) {
 	*out = *in
-	if in.ImagePullSecrets != nil {
-		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
-		*out = make([]v1.LocalObjectReference, len(*in))
-		copy(*out, *in)
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(NetworkRulesConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Egress != nil {
+		in, out := &in.Egress, &out.Egress
+		*out = new(NetworkRulesConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopNetworkConfig.
+func (in *DesktopNetworkConfig) DeepCopy() *DesktopNetworkConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopNetworkConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopPodConfig) DeepCopyInto(out *DesktopPodConfig) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Sidecars != nil {
+		in, out := &in.Sidecars, &out.Sidecars
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]v1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopPodConfig.
+func (in *DesktopPodConfig) DeepCopy() *DesktopPodConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopPodConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopSpec) DeepCopyInto(out *DesktopSpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SecretEnv != nil {
+		in, out := &in.SecretEnv, &out.SecretEnv
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopSpec.
+func (in *DesktopSpec) DeepCopy() *DesktopSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopStatus) DeepCopyInto(out *DesktopStatus) {
+	*out = *in
+	if in.LastActivity != nil {
+		in, out := &in.LastActivity, &out.LastActivity
+		*out = (*in).DeepCopy()
+	}
+	if in.TerminatesAt != nil {
+		in, out := &in.TerminatesAt, &out.TerminatesAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopStatus.
+func (in *DesktopStatus) DeepCopy() *DesktopStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopTemplate) DeepCopyInto(out *DesktopTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopTemplate.
+func (in *DesktopTemplate) DeepCopy() *DesktopTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DesktopTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopTemplateList) DeepCopyInto(out *DesktopTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DesktopTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopTemplateList.
+func (in *DesktopTemplateList) DeepCopy() *DesktopTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DesktopTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopTemplateParameter) DeepCopyInto(out *DesktopTemplateParameter) {
+	*out = *in
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopTemplateParameter.
+func (in *DesktopTemplateParameter) DeepCopy() *DesktopTemplateParameter {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopTemplateParameter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopTemplateRevision) DeepCopyInto(out *DesktopTemplateRevision) {
+	*out = *in
+	in.CreatedAt.DeepCopyInto(&out.CreatedAt)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopTemplateRevision.
+func (in *DesktopTemplateRevision) DeepCopy() *DesktopTemplateRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopTemplateRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopTemplateSpec) DeepCopyInto(out *DesktopTemplateSpec) {
+	*out = *in
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.GPU != nil {
+		in, out := &in.GPU, &out.GPU
+		*out = new(GPUConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SessionEnv != nil {
+		in, out := &in.SessionEnv, &out.SessionEnv
+		*out = new(SessionEnvConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Config != nil {
 		in, out := &in.Config, &out.Config
 		*out = new(DesktopConfig)
@@ -266,6 +775,43 @@ func (in *DesktopTemplateSpec) DeepCopyInto(out *DesktopTemplateSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Availability != nil {
+		in, out := &in.Availability, &out.Availability
+		*out = new(AvailabilityConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]DesktopTemplateParameter, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Pod != nil {
+		in, out := &in.Pod, &out.Pod
+		*out = new(DesktopPodConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Network != nil {
+		in, out := &in.Network, &out.Network
+		*out = new(DesktopNetworkConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityProfiles != nil {
+		in, out := &in.SecurityProfiles, &out.SecurityProfiles
+		*out = new(SecurityProfilesConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -274,249 +820,1105 @@ func (in *DesktopTemplateSpec) DeepCopy() *DesktopTemplateSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopTemplateSpec)
+	out := new(DesktopTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopTemplateStatus) DeepCopyInto(out *DesktopTemplateStatus) {
+	*out = *in
+	if in.GPU != nil {
+		in, out := &in.GPU, &out.GPU
+		*out = new(GPUStatus)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopTemplateStatus.
+func (in *DesktopTemplateStatus) DeepCopy() *DesktopTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesktopsConfig) DeepCopyInto(out *DesktopsConfig) {
+	*out = *in
+	if in.Preemption != nil {
+		in, out := &in.Preemption, &out.Preemption
+		*out = new(PreemptionConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceResources != nil {
+		in, out := &in.NamespaceResources, &out.NamespaceResources
+		*out = make(map[string]NamespaceResourceConfig, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Recordings != nil {
+		in, out := &in.Recordings, &out.Recordings
+		*out = new(RecordingsConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopsConfig.
+func (in *DesktopsConfig) DeepCopy() *DesktopsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DesktopsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisplayConfig) DeepCopyInto(out *DisplayConfig) {
+	*out = *in
+	if in.MaxQuality != nil {
+		in, out := &in.MaxQuality, &out.MaxQuality
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisplayConfig.
+func (in *DisplayConfig) DeepCopy() *DisplayConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DisplayConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DuoMFAConfig) DeepCopyInto(out *DuoMFAConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DuoMFAConfig.
+func (in *DuoMFAConfig) DeepCopy() *DuoMFAConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DuoMFAConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmailConfig) DeepCopyInto(out *EmailConfig) {
+	*out = *in
+	if in.AdminAddresses != nil {
+		in, out := &in.AdminAddresses, &out.AdminAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make(map[string]EmailTemplate, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmailConfig.
+func (in *EmailConfig) DeepCopy() *EmailConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EmailConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmailTemplate) DeepCopyInto(out *EmailTemplate) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmailTemplate.
+func (in *EmailTemplate) DeepCopy() *EmailTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(EmailTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCConfig) DeepCopyInto(out *GCConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCConfig.
+func (in *GCConfig) DeepCopy() *GCConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GCConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPSecretManagerConfig) DeepCopyInto(out *GCPSecretManagerConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPSecretManagerConfig.
+func (in *GCPSecretManagerConfig) DeepCopy() *GCPSecretManagerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPSecretManagerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUConfig) DeepCopyInto(out *GPUConfig) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUConfig.
+func (in *GPUConfig) DeepCopy() *GPUConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUStatus) DeepCopyInto(out *GPUStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUStatus.
+func (in *GPUStatus) DeepCopy() *GPUStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GrafanaConfig) DeepCopyInto(out *GrafanaConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GrafanaConfig.
+func (in *GrafanaConfig) DeepCopy() *GrafanaConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GrafanaConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPExportConfig) DeepCopyInto(out *HTTPExportConfig) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPExportConfig.
+func (in *HTTPExportConfig) DeepCopy() *HTTPExportConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPExportConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *K8SSecretConfig) DeepCopyInto(out *K8SSecretConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K8SSecretConfig.
+func (in *K8SSecretConfig) DeepCopy() *K8SSecretConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(K8SSecretConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPAttributeConfig) DeepCopyInto(out *LDAPAttributeConfig) {
+	*out = *in
+	if in.AccountStatusActiveValues != nil {
+		in, out := &in.AccountStatusActiveValues, &out.AccountStatusActiveValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPAttributeConfig.
+func (in *LDAPAttributeConfig) DeepCopy() *LDAPAttributeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPAttributeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPConfig) DeepCopyInto(out *LDAPConfig) {
+	*out = *in
+	if in.URLs != nil {
+		in, out := &in.URLs, &out.URLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdminGroups != nil {
+		in, out := &in.AdminGroups, &out.AdminGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Referrals != nil {
+		in, out := &in.Referrals, &out.Referrals
+		*out = new(LDAPReferralConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Attributes != nil {
+		in, out := &in.Attributes, &out.Attributes
+		*out = new(LDAPAttributeConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Pool != nil {
+		in, out := &in.Pool, &out.Pool
+		*out = new(LDAPPoolConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPConfig.
+func (in *LDAPConfig) DeepCopy() *LDAPConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPPoolConfig) DeepCopyInto(out *LDAPPoolConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPPoolConfig.
+func (in *LDAPPoolConfig) DeepCopy() *LDAPPoolConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPPoolConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPReferralConfig) DeepCopyInto(out *LDAPReferralConfig) {
+	*out = *in
+	if in.AllowedHosts != nil {
+		in, out := &in.AllowedHosts, &out.AllowedHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TrustedHosts != nil {
+		in, out := &in.TrustedHosts, &out.TrustedHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPReferralConfig.
+func (in *LDAPReferralConfig) DeepCopy() *LDAPReferralConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPReferralConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalAuthConfig) DeepCopyInto(out *LocalAuthConfig) {
+	*out = *in
+	if in.PasswordPolicy != nil {
+		in, out := &in.PasswordPolicy, &out.PasswordPolicy
+		*out = new(PasswordPolicy)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalAuthConfig.
+func (in *LocalAuthConfig) DeepCopy() *LocalAuthConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalAuthConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoginHistoryConfig) DeepCopyInto(out *LoginHistoryConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoginHistoryConfig.
+func (in *LoginHistoryConfig) DeepCopy() *LoginHistoryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LoginHistoryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoginRateLimitConfig) DeepCopyInto(out *LoginRateLimitConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoginRateLimitConfig.
+func (in *LoginRateLimitConfig) DeepCopy() *LoginRateLimitConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LoginRateLimitConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MFAConfig) DeepCopyInto(out *MFAConfig) {
+	*out = *in
+	if in.Duo != nil {
+		in, out := &in.Duo, &out.Duo
+		*out = new(DuoMFAConfig)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(MFAWebhookConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MFAConfig.
+func (in *MFAConfig) DeepCopy() *MFAConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MFAConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MFAWebhookConfig) DeepCopyInto(out *MFAWebhookConfig) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MFAWebhookConfig.
+func (in *MFAWebhookConfig) DeepCopy() *MFAWebhookConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MFAWebhookConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsConfig) DeepCopyInto(out *MetricsConfig) {
+	*out = *in
+	if in.ServiceMonitor != nil {
+		in, out := &in.ServiceMonitor, &out.ServiceMonitor
+		*out = new(ServiceMonitorConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Prometheus != nil {
+		in, out := &in.Prometheus, &out.Prometheus
+		*out = new(PrometheusConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Grafana != nil {
+		in, out := &in.Grafana, &out.Grafana
+		*out = new(GrafanaConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsConfig.
+func (in *MetricsConfig) DeepCopy() *MetricsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceResourceConfig) DeepCopyInto(out *NamespaceResourceConfig) {
+	*out = *in
+	in.Default.DeepCopyInto(&out.Default)
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceResourceConfig.
+func (in *NamespaceResourceConfig) DeepCopy() *NamespaceResourceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceResourceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkRulesConfig) DeepCopyInto(out *NetworkRulesConfig) {
+	*out = *in
+	if in.AllowedCIDRs != nil {
+		in, out := &in.AllowedCIDRs, &out.AllowedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkRulesConfig.
+func (in *NetworkRulesConfig) DeepCopy() *NetworkRulesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkRulesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCConfig) DeepCopyInto(out *OIDCConfig) {
+	*out = *in
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RoleClaims != nil {
+		in, out := &in.RoleClaims, &out.RoleClaims
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdminGroups != nil {
+		in, out := &in.AdminGroups, &out.AdminGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AzureAD != nil {
+		in, out := &in.AzureAD, &out.AzureAD
+		*out = new(AzureADConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCConfig.
+func (in *OIDCConfig) DeepCopy() *OIDCConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PasswordPolicy) DeepCopyInto(out *PasswordPolicy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordPolicy.
+func (in *PasswordPolicy) DeepCopy() *PasswordPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PasswordPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreemptionConfig) DeepCopyInto(out *PreemptionConfig) {
+	*out = *in
+	if in.NoticeTaints != nil {
+		in, out := &in.NoticeTaints, &out.NoticeTaints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StableNodeAffinity != nil {
+		in, out := &in.StableNodeAffinity, &out.StableNodeAffinity
+		*out = new(v1.NodeAffinity)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreemptionConfig.
+func (in *PreemptionConfig) DeepCopy() *PreemptionConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PreemptionConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PriceConfig) DeepCopyInto(out *PriceConfig) {
+	*out = *in
+	if in.PerHour != nil {
+		in, out := &in.PerHour, &out.PerHour
+		*out = make(map[v1.ResourceName]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriceConfig.
+func (in *PriceConfig) DeepCopy() *PriceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PriceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusConfig) DeepCopyInto(out *PrometheusConfig) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusConfig.
+func (in *PrometheusConfig) DeepCopy() *PrometheusConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RDPConfig) DeepCopyInto(out *RDPConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RDPConfig.
+func (in *RDPConfig) DeepCopy() *RDPConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RDPConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecordingsConfig) DeepCopyInto(out *RecordingsConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordingsConfig.
+func (in *RecordingsConfig) DeepCopy() *RecordingsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RecordingsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3ExportConfig) DeepCopyInto(out *S3ExportConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3ExportConfig.
+func (in *S3ExportConfig) DeepCopy() *S3ExportConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(S3ExportConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretsConfig) DeepCopyInto(out *SecretsConfig) {
+	*out = *in
+	if in.K8SSecret != nil {
+		in, out := &in.K8SSecret, &out.K8SSecret
+		*out = new(K8SSecretConfig)
+		**out = **in
+	}
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(VaultConfig)
+		**out = **in
+	}
+	if in.AWSSecretsManager != nil {
+		in, out := &in.AWSSecretsManager, &out.AWSSecretsManager
+		*out = new(AWSSecretsManagerConfig)
+		**out = **in
+	}
+	if in.GCPSecretManager != nil {
+		in, out := &in.GCPSecretManager, &out.GCPSecretManager
+		*out = new(GCPSecretManagerConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretsConfig.
+func (in *SecretsConfig) DeepCopy() *SecretsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecurityProfilesConfig) DeepCopyInto(out *SecurityProfilesConfig) {
+	*out = *in
+	if in.SELinux != nil {
+		in, out := &in.SELinux, &out.SELinux
+		*out = new(v1.SELinuxOptions)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecurityProfilesConfig.
+func (in *SecurityProfilesConfig) DeepCopy() *SecurityProfilesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SecurityProfilesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceMonitorConfig) DeepCopyInto(out *ServiceMonitorConfig) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceMonitorConfig.
+func (in *ServiceMonitorConfig) DeepCopy() *ServiceMonitorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceMonitorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SessionEnvConfig) DeepCopyInto(out *SessionEnvConfig) {
+	*out = *in
+	if in.Allowed != nil {
+		in, out := &in.Allowed, &out.Allowed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UserSecrets != nil {
+		in, out := &in.UserSecrets, &out.UserSecrets
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionEnvConfig.
+func (in *SessionEnvConfig) DeepCopy() *SessionEnvConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SessionEnvConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SessionPool) DeepCopyInto(out *SessionPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionPool.
+func (in *SessionPool) DeepCopy() *SessionPool {
+	if in == nil {
+		return nil
+	}
+	out := new(SessionPool)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SessionPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DesktopTemplateStatus) DeepCopyInto(out *DesktopTemplateStatus) {
+func (in *SessionPoolList) DeepCopyInto(out *SessionPoolList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SessionPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopTemplateStatus.
-func (in *DesktopTemplateStatus) DeepCopy() *DesktopTemplateStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionPoolList.
+func (in *SessionPoolList) DeepCopy() *SessionPoolList {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopTemplateStatus)
+	out := new(SessionPoolList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SessionPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DesktopsConfig) DeepCopyInto(out *DesktopsConfig) {
+func (in *SessionPoolSpec) DeepCopyInto(out *SessionPoolSpec) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesktopsConfig.
-func (in *DesktopsConfig) DeepCopy() *DesktopsConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionPoolSpec.
+func (in *SessionPoolSpec) DeepCopy() *SessionPoolSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(DesktopsConfig)
+	out := new(SessionPoolSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GrafanaConfig) DeepCopyInto(out *GrafanaConfig) {
+func (in *SessionPoolStatus) DeepCopyInto(out *SessionPoolStatus) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GrafanaConfig.
-func (in *GrafanaConfig) DeepCopy() *GrafanaConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionPoolStatus.
+func (in *SessionPoolStatus) DeepCopy() *SessionPoolStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(GrafanaConfig)
+	out := new(SessionPoolStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *K8SSecretConfig) DeepCopyInto(out *K8SSecretConfig) {
+func (in *TLSConfig) DeepCopyInto(out *TLSConfig) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K8SSecretConfig.
-func (in *K8SSecretConfig) DeepCopy() *K8SSecretConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSConfig.
+func (in *TLSConfig) DeepCopy() *TLSConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(K8SSecretConfig)
+	out := new(TLSConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LDAPConfig) DeepCopyInto(out *LDAPConfig) {
-	*out = *in
-	if in.AdminGroups != nil {
-		in, out := &in.AdminGroups, &out.AdminGroups
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
+func (in *TemplateLookupFunc) DeepCopyInto(out *TemplateLookupFunc) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPConfig.
-func (in *LDAPConfig) DeepCopy() *LDAPConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateLookupFunc.
+func (in *TemplateLookupFunc) DeepCopy() *TemplateLookupFunc {
 	if in == nil {
 		return nil
 	}
-	out := new(LDAPConfig)
+	out := new(TemplateLookupFunc)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LocalAuthConfig) DeepCopyInto(out *LocalAuthConfig) {
+func (in *TokenSigningConfig) DeepCopyInto(out *TokenSigningConfig) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalAuthConfig.
-func (in *LocalAuthConfig) DeepCopy() *LocalAuthConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenSigningConfig.
+func (in *TokenSigningConfig) DeepCopy() *TokenSigningConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(LocalAuthConfig)
+	out := new(TokenSigningConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetricsConfig) DeepCopyInto(out *MetricsConfig) {
+func (in *TracingConfig) DeepCopyInto(out *TracingConfig) {
 	*out = *in
-	if in.ServiceMonitor != nil {
-		in, out := &in.ServiceMonitor, &out.ServiceMonitor
-		*out = new(ServiceMonitorConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Prometheus != nil {
-		in, out := &in.Prometheus, &out.Prometheus
-		*out = new(PrometheusConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Grafana != nil {
-		in, out := &in.Grafana, &out.Grafana
-		*out = new(GrafanaConfig)
-		**out = **in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsConfig.
-func (in *MetricsConfig) DeepCopy() *MetricsConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TracingConfig.
+func (in *TracingConfig) DeepCopy() *TracingConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(MetricsConfig)
+	out := new(TracingConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OIDCConfig) DeepCopyInto(out *OIDCConfig) {
+func (in *USBConfig) DeepCopyInto(out *USBConfig) {
 	*out = *in
-	if in.Scopes != nil {
-		in, out := &in.Scopes, &out.Scopes
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.AdminGroups != nil {
-		in, out := &in.AdminGroups, &out.AdminGroups
-		*out = make([]string, len(*in))
+	if in.AllowedClasses != nil {
+		in, out := &in.AllowedClasses, &out.AllowedClasses
+		*out = make([]USBDeviceClass, len(*in))
 		copy(*out, *in)
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCConfig.
-func (in *OIDCConfig) DeepCopy() *OIDCConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new USBConfig.
+func (in *USBConfig) DeepCopy() *USBConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(OIDCConfig)
+	out := new(USBConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrometheusConfig) DeepCopyInto(out *PrometheusConfig) {
+func (in *UserDataPolicy) DeepCopyInto(out *UserDataPolicy) {
 	*out = *in
-	in.Resources.DeepCopyInto(&out.Resources)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusConfig.
-func (in *PrometheusConfig) DeepCopy() *PrometheusConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserDataPolicy.
+func (in *UserDataPolicy) DeepCopy() *UserDataPolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(PrometheusConfig)
+	out := new(UserDataPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SecretsConfig) DeepCopyInto(out *SecretsConfig) {
+func (in *VDIAnnouncement) DeepCopyInto(out *VDIAnnouncement) {
 	*out = *in
-	if in.K8SSecret != nil {
-		in, out := &in.K8SSecret, &out.K8SSecret
-		*out = new(K8SSecretConfig)
-		**out = **in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
 	}
-	if in.Vault != nil {
-		in, out := &in.Vault, &out.Vault
-		*out = new(VaultConfig)
-		**out = **in
+	if in.EndTime != nil {
+		in, out := &in.EndTime, &out.EndTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretsConfig.
-func (in *SecretsConfig) DeepCopy() *SecretsConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDIAnnouncement.
+func (in *VDIAnnouncement) DeepCopy() *VDIAnnouncement {
 	if in == nil {
 		return nil
 	}
-	out := new(SecretsConfig)
+	out := new(VDIAnnouncement)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VDIAnnouncement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ServiceMonitorConfig) DeepCopyInto(out *ServiceMonitorConfig) {
+func (in *VDIAnnouncementList) DeepCopyInto(out *VDIAnnouncementList) {
 	*out = *in
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VDIAnnouncement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceMonitorConfig.
-func (in *ServiceMonitorConfig) DeepCopy() *ServiceMonitorConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDIAnnouncementList.
+func (in *VDIAnnouncementList) DeepCopy() *VDIAnnouncementList {
 	if in == nil {
 		return nil
 	}
-	out := new(ServiceMonitorConfig)
+	out := new(VDIAnnouncementList)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *TLSConfig) DeepCopyInto(out *TLSConfig) {
-	*out = *in
-	return
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSConfig.
-func (in *TLSConfig) DeepCopy() *TLSConfig {
-	if in == nil {
-		return nil
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VDIAnnouncementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
 	}
-	out := new(TLSConfig)
-	in.DeepCopyInto(out)
-	return out
+	return nil
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -525,7 +1927,7 @@ func (in *VDICluster) DeepCopyInto(out *VDICluster) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -547,6 +1949,23 @@ func (in *VDICluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VDIClusterCondition) DeepCopyInto(out *VDIClusterCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDIClusterCondition.
+func (in *VDIClusterCondition) DeepCopy() *VDIClusterCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(VDIClusterCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VDIClusterList) DeepCopyInto(out *VDIClusterList) {
 	*out = *in
@@ -593,6 +2012,11 @@ func (in *VDIClusterSpec) DeepCopyInto(out *VDIClusterSpec) {
 		*out = new(v1.PersistentVolumeClaimSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.UserDataPolicy != nil {
+		in, out := &in.UserDataPolicy, &out.UserDataPolicy
+		*out = new(UserDataPolicy)
+		**out = **in
+	}
 	if in.App != nil {
 		in, out := &in.App, &out.App
 		*out = new(AppConfig)
@@ -606,7 +2030,7 @@ func (in *VDIClusterSpec) DeepCopyInto(out *VDIClusterSpec) {
 	if in.Desktops != nil {
 		in, out := &in.Desktops, &out.Desktops
 		*out = new(DesktopsConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Secrets != nil {
 		in, out := &in.Secrets, &out.Secrets
@@ -618,6 +2042,21 @@ func (in *VDIClusterSpec) DeepCopyInto(out *VDIClusterSpec) {
 		*out = new(MetricsConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Billing != nil {
+		in, out := &in.Billing, &out.Billing
+		*out = new(BillingConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GC != nil {
+		in, out := &in.GC, &out.GC
+		*out = new(GCConfig)
+		**out = **in
+	}
+	if in.Email != nil {
+		in, out := &in.Email, &out.Email
+		*out = new(EmailConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -634,6 +2073,13 @@ func (in *VDIClusterSpec) DeepCopy() *VDIClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VDIClusterStatus) DeepCopyInto(out *VDIClusterStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]VDIClusterCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -647,6 +2093,75 @@ func (in *VDIClusterStatus) DeepCopy() *VDIClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VDIGroup) DeepCopyInto(out *VDIGroup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDIGroup.
+func (in *VDIGroup) DeepCopy() *VDIGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(VDIGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VDIGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VDIGroupList) DeepCopyInto(out *VDIGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VDIGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VDIGroupList.
+func (in *VDIGroupList) DeepCopy() *VDIGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(VDIGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VDIGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VDIRole) DeepCopyInto(out *VDIRole) {
 	*out = *in
@@ -659,6 +2174,16 @@ func (in *VDIRole) DeepCopyInto(out *VDIRole) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Inherits != nil {
+		in, out := &in.Inherits, &out.Inherits
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(metav1.SessionPlacement)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -728,3 +2253,26 @@ func (in *VaultConfig) DeepCopy() *VaultConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookAuthConfig) DeepCopyInto(out *WebhookAuthConfig) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookAuthConfig.
+func (in *WebhookAuthConfig) DeepCopy() *WebhookAuthConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookAuthConfig)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"errors"
+	"time"
+)
+
+// AnnouncementSeverity represents how prominently an announcement is shown to users.
+// +kubebuilder:validation:Enum=info;warning;critical
+type AnnouncementSeverity string
+
+const (
+	// AnnouncementInfo is for general notices, like upcoming maintenance.
+	AnnouncementInfo AnnouncementSeverity = "info"
+	// AnnouncementWarning is for degraded service, like maintenance in progress.
+	AnnouncementWarning AnnouncementSeverity = "warning"
+	// AnnouncementCritical is for outages.
+	AnnouncementCritical AnnouncementSeverity = "critical"
+)
+
+// Validate returns an error if the severity is not one of the known values. An
+// empty severity is valid and defaults to AnnouncementInfo.
+func (s AnnouncementSeverity) Validate() error {
+	switch s {
+	case "", AnnouncementInfo, AnnouncementWarning, AnnouncementCritical:
+		return nil
+	}
+	return errors.New("The severity must be one of info, warning, or critical")
+}
+
+// Announcement is a message shown to users in the UI, such as a notice of a
+// maintenance window or an outage.
+// +k8s:deepcopy-gen=false
+type Announcement struct {
+	// The name of the announcement
+	Name string `json:"name"`
+	// The message shown to users
+	Message string `json:"message"`
+	// How prominently the message is shown
+	Severity AnnouncementSeverity `json:"severity"`
+	// When the announcement starts being shown, if not since it was created
+	StartTime *time.Time `json:"startTime,omitempty"`
+	// When the announcement stops being shown, if ever
+	EndTime *time.Time `json:"endTime,omitempty"`
+	// The roles the announcement is shown to, all users when empty
+	Roles []string `json:"roles,omitempty"`
+}
+
+// IsActive returns true if the announcement is shown at the given time.
+func (a *Announcement) IsActive(t time.Time) bool {
+	if a.StartTime != nil && t.Before(*a.StartTime) {
+		return false
+	}
+	return a.EndTime == nil || t.Before(*a.EndTime)
+}
+
+// TargetsUser returns true if the announcement is shown to the given user.
+func (a *Announcement) TargetsUser(user *VDIUser) bool {
+	if len(a.Roles) == 0 {
+		return true
+	}
+	for _, role := range user.Roles {
+		for _, name := range a.Roles {
+			if role.GetName() == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -4,8 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/mail"
 	"regexp"
 	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // API Request/Response types
@@ -49,8 +53,12 @@ func (l *LoginRequest) GetRequest() *http.Request {
 
 // AuthorizeRequest is a request with an OTP for receiving an authorized token.
 type AuthorizeRequest struct {
-	// The one-time password
+	// The one-time password. When authorizing a session, an unused backup code
+	// is also accepted.
 	OTP string `json:"otp"`
+	// When the cluster uses push MFA, the transaction returned when sending the push.
+	// The request is polled until the push is approved or denied.
+	Transaction string `json:"transaction,omitempty"`
 	// The state secret for the request flow
 	State string `json:"state"`
 }
@@ -58,6 +66,9 @@ type AuthorizeRequest struct {
 // GetOTP returns the OTP from the request.
 func (a *AuthorizeRequest) GetOTP() string { return a.OTP }
 
+// GetTransaction returns the push transaction from the request.
+func (a *AuthorizeRequest) GetTransaction() string { return a.Transaction }
+
 // GetState returns the state from the request.
 func (a *AuthorizeRequest) GetState() string { return a.State }
 
@@ -75,6 +86,51 @@ type SessionResponse struct {
 	Authorized bool `json:"authorized"`
 	// The state secret generated by the client
 	State string `json:"state"`
+	// When the user is not authorized yet, how they must authorize. Either `totp`
+	// or `push`.
+	MFAMethod string `json:"mfaMethod,omitempty"`
+	// Set when one of the user's roles requires MFA but they have not set it up
+	// yet. The token may only be used to enroll and verify an OTP secret for the
+	// user before authorizing it.
+	MFAEnrollmentRequired bool `json:"mfaEnrollmentRequired,omitempty"`
+}
+
+// LogoutResponse represents a response to ending a user session.
+type LogoutResponse struct {
+	// Always true when the session was ended.
+	OK bool `json:"ok"`
+	// When set, the URL to send the user to for also ending their session with
+	// the authentication provider. It is also returned in the X-Redirect header.
+	LogoutURL string `json:"logoutURL,omitempty"`
+}
+
+// MFAPushStatus represents the status of an MFA push notification.
+type MFAPushStatus string
+
+const (
+	// MFAPushPending means the user has not responded to the push yet.
+	MFAPushPending MFAPushStatus = "pending"
+	// MFAPushApproved means the user approved the push.
+	MFAPushApproved MFAPushStatus = "approved"
+	// MFAPushDenied means the user denied the push or it expired.
+	MFAPushDenied MFAPushStatus = "denied"
+)
+
+// Values for the MFAMethod in a SessionResponse.
+const (
+	// MFAMethodTOTP means the user must provide a one-time password.
+	MFAMethodTOTP = "totp"
+	// MFAMethodPush means the user must approve a push notification.
+	MFAMethodPush = "push"
+)
+
+// MFAPushResponse is the response to sending an MFA push notification, or polling
+// one that is still pending.
+type MFAPushResponse struct {
+	// The transaction to provide in authorize requests to poll for approval.
+	Transaction string `json:"transaction"`
+	// The status of the push.
+	Status MFAPushStatus `json:"status"`
 }
 
 // CreateUserRequest represents a request to create a new user. Not all auth
@@ -87,6 +143,9 @@ type CreateUserRequest struct {
 	Password string `json:"password"`
 	// Roles to assign the new user. These are the names of VDIRoles in the cluster.
 	Roles []string `json:"roles"`
+	// Unix timestamps at which any of the roles are removed from the user. Roles
+	// not included here do not expire. Only supported by the local auth provider.
+	RoleExpirations map[string]int64 `json:"roleExpirations,omitempty"`
 }
 
 // Validate validates a new user request
@@ -100,7 +159,7 @@ func (r *CreateUserRequest) Validate() error {
 	if strings.Contains(r.Username, ":") {
 		return errors.New("Username cannot contain the ':' character")
 	}
-	return nil
+	return validateRoleExpirations(r.Roles, r.RoleExpirations)
 }
 
 // UpdateUserRequest requests updates to an existing user. Not all auth
@@ -111,6 +170,9 @@ type UpdateUserRequest struct {
 	Password string `json:"password"`
 	// When populated will change the roles for the user.
 	Roles []string `json:"roles"`
+	// Unix timestamps at which any of the roles are removed from the user. Roles
+	// not included here do not expire. Only supported by the local auth provider.
+	RoleExpirations map[string]int64 `json:"roleExpirations,omitempty"`
 }
 
 // Validate the UpdateUserRequest
@@ -118,6 +180,74 @@ func (r *UpdateUserRequest) Validate() error {
 	if r.Password == "" && len(r.Roles) == 0 {
 		return errors.New("You must specify either a new password or a list of roles")
 	}
+	return validateRoleExpirations(r.Roles, r.RoleExpirations)
+}
+
+// validateRoleExpirations checks that the given expirations are for roles in
+// the given list and are in the future.
+func validateRoleExpirations(roles []string, expirations map[string]int64) error {
+	assigned := make(map[string]struct{}, len(roles))
+	for _, role := range roles {
+		assigned[role] = struct{}{}
+	}
+	now := time.Now().Unix()
+	for role, expiresAt := range expirations {
+		if _, ok := assigned[role]; !ok {
+			return fmt.Errorf("An expiry was provided for role '%s' which is not being assigned", role)
+		}
+		if expiresAt <= now {
+			return fmt.Errorf("The expiry for role '%s' must be in the future", role)
+		}
+	}
+	return nil
+}
+
+// ChangePasswordRequest requests a user's password be changed by the user
+// themselves. Not all auth providers will be able to implement this route and
+// can instead return an error describing why.
+type ChangePasswordRequest struct {
+	// The user's current password.
+	OldPassword string `json:"oldPassword"`
+	// The new password for the user.
+	NewPassword string `json:"newPassword"`
+}
+
+// Validate the ChangePasswordRequest
+func (r *ChangePasswordRequest) Validate() error {
+	if r.OldPassword == "" || r.NewPassword == "" {
+		return errors.New("You must specify both the current and new password")
+	}
+	return nil
+}
+
+// PasswordResetRequest requests a password reset link be emailed to a user.
+type PasswordResetRequest struct {
+	// The name of the user
+	Username string `json:"username"`
+}
+
+// Validate the PasswordResetRequest
+func (r *PasswordResetRequest) Validate() error {
+	if r.Username == "" {
+		return errors.New("A username is required")
+	}
+	return nil
+}
+
+// ConfirmPasswordResetRequest sets a new password for a user with the token from
+// a password reset link.
+type ConfirmPasswordResetRequest struct {
+	// The token from the password reset link
+	Token string `json:"token"`
+	// The new password for the user
+	Password string `json:"password"`
+}
+
+// Validate the ConfirmPasswordResetRequest
+func (r *ConfirmPasswordResetRequest) Validate() error {
+	if r.Token == "" || r.Password == "" {
+		return errors.New("You must specify both the reset token and a new password")
+	}
 	return nil
 }
 
@@ -136,6 +266,137 @@ type MFAResponse struct {
 	ProvisioningURI string `json:"provisioningURI"`
 	// If enabled is set, whether or not the user has verified their MFA setup
 	Verified bool `json:"verified"`
+	// One-time codes that can be used in place of an OTP if the user loses access
+	// to their device. These are only returned when MFA is first enabled.
+	BackupCodes []string `json:"backupCodes,omitempty"`
+	// The number of backup codes the user has not used yet.
+	BackupCodesRemaining int `json:"backupCodesRemaining"`
+}
+
+// UpdateUserDataRequest sets the first-boot script to run in the user's desktop
+// sessions in place of the one configured on the template.
+type UpdateUserDataRequest struct {
+	// The script to run. An empty value removes the user's script.
+	UserData string `json:"userData"`
+}
+
+// UserDataResponse contains the first-boot script configured for a user.
+type UserDataResponse struct {
+	// The script configured for the user, if any.
+	UserData string `json:"userData"`
+}
+
+// MaxUserSecretSize is the largest value a user secret can hold.
+const MaxUserSecretSize = 32 * 1024
+
+// userSecretNameRegex matches valid names for user secrets.
+var userSecretNameRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+
+// ValidateUserSecretName returns an error if the given name is not valid for a
+// user secret.
+func ValidateUserSecretName(name string) error {
+	if !userSecretNameRegex.MatchString(name) {
+		return fmt.Errorf("Invalid secret name '%s', names must be 1-63 alphanumeric characters, '.', '_', or '-'", name)
+	}
+	return nil
+}
+
+// UpdateUserSecretRequest sets the value of one of a user's secrets. User secrets
+// can be injected into the environment of their desktop sessions, e.g. short-lived
+// cloud credentials.
+type UpdateUserSecretRequest struct {
+	// The value of the secret.
+	Value string `json:"value"`
+}
+
+// Validate the UpdateUserSecretRequest
+func (r *UpdateUserSecretRequest) Validate() error {
+	if r.Value == "" {
+		return errors.New("A value is required")
+	}
+	if len(r.Value) > MaxUserSecretSize {
+		return fmt.Errorf("The value cannot be larger than %d bytes", MaxUserSecretSize)
+	}
+	return nil
+}
+
+// UserSecretsResponse contains the names of the secrets configured for a user.
+// Their values are never returned by the API.
+type UserSecretsResponse struct {
+	// The names of the user's secrets.
+	Names []string `json:"names"`
+}
+
+// DotfilesConfig represents a git repository of dotfiles to clone into the home
+// directory of a user's desktop sessions.
+type DotfilesConfig struct {
+	// The URL of the repository. Credentials for private repositories can be
+	// included in the URL. An empty value removes the user's configuration.
+	Repository string `json:"repository"`
+	// The branch to clone. Defaults to the default branch of the repository.
+	Branch string `json:"branch,omitempty"`
+	// Create a shallow clone with the given number of commits. Defaults to the
+	// full history.
+	Depth int32 `json:"depth,omitempty"`
+}
+
+// Validate the DotfilesConfig
+func (d *DotfilesConfig) Validate() error {
+	if d.Depth < 0 {
+		return errors.New("The clone depth cannot be negative")
+	}
+	if d.Repository == "" && (d.Branch != "" || d.Depth != 0) {
+		return errors.New("A repository is required")
+	}
+	return nil
+}
+
+// UserEmailConfig represents the address notifications are sent to for a user.
+type UserEmailConfig struct {
+	// The email address of the user. An empty value removes the user's address.
+	Address string `json:"address"`
+}
+
+// Validate the UserEmailConfig
+func (u *UserEmailConfig) Validate() error {
+	if u.Address == "" {
+		return nil
+	}
+	addr, err := mail.ParseAddress(u.Address)
+	if err != nil || addr.Address != u.Address {
+		return fmt.Errorf("%q is not a valid email address", u.Address)
+	}
+	return nil
+}
+
+// keyboardLayoutRegex matches the names of XKB layouts and variants.
+var keyboardLayoutRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// KeyboardLayoutConfig represents the keyboard layout set on the display of a
+// user's desktop sessions.
+type KeyboardLayoutConfig struct {
+	// The XKB layout, e.g. us, de, or jp. An empty value removes the user's layout,
+	// and the layout detected by the client is used instead.
+	Layout string `json:"layout"`
+	// The XKB variant of the layout, e.g. dvorak or nodeadkeys.
+	Variant string `json:"variant,omitempty"`
+}
+
+// Validate the KeyboardLayoutConfig
+func (k *KeyboardLayoutConfig) Validate() error {
+	if k.Layout == "" {
+		if k.Variant != "" {
+			return errors.New("A layout is required")
+		}
+		return nil
+	}
+	if !keyboardLayoutRegex.MatchString(k.Layout) {
+		return fmt.Errorf("%q is not a valid keyboard layout", k.Layout)
+	}
+	if k.Variant != "" && !keyboardLayoutRegex.MatchString(k.Variant) {
+		return fmt.Errorf("%q is not a valid keyboard variant", k.Variant)
+	}
+	return nil
 }
 
 // CreateRoleRequest represents a request for a new role.
@@ -146,6 +407,23 @@ type CreateRoleRequest struct {
 	Annotations map[string]string `json:"annotations"`
 	// Rules to apply to the new role.
 	Rules []Rule `json:"rules"`
+	// The names of other roles to inherit rules from.
+	Inherits []string `json:"inherits,omitempty"`
+	// An optional override for the lifetime of session tokens issued to
+	// members of the role.
+	TokenDuration string `json:"tokenDuration,omitempty"`
+	// Whether desktop displays should be watermarked for members of the role.
+	Watermark bool `json:"watermark,omitempty"`
+	// The maximum number of desktop sessions members of the role may run at once.
+	MaxSessions int32 `json:"maxSessions,omitempty"`
+	// The maximum number of desktop sessions members of the role may run at once
+	// from any single template.
+	MaxSessionsPerTemplate int32 `json:"maxSessionsPerTemplate,omitempty"`
+	// Where members of the role may run desktop sessions, and how much CPU and
+	// memory they may consume.
+	Placement *SessionPlacement `json:"placement,omitempty"`
+	// Whether members of the role must complete MFA when they log in.
+	RequireMFA bool `json:"requireMFA,omitempty"`
 }
 
 // GetName returns the name of the new role
@@ -154,17 +432,33 @@ func (r *CreateRoleRequest) GetName() string { return r.Name }
 // GetAnnotations returns the annotations provided in the request
 func (r *CreateRoleRequest) GetAnnotations() map[string]string { return r.Annotations }
 
+// GetTokenDuration returns the token lifetime provided in the request
+func (r *CreateRoleRequest) GetTokenDuration() string { return r.TokenDuration }
+
 // Validate the CreateRoleRequest
 func (r *CreateRoleRequest) Validate() error {
 	if r.Name == "" {
 		return errors.New("A name is required for the new role")
 	}
+	for _, name := range r.Inherits {
+		if name == r.Name {
+			return errors.New("A role cannot inherit from itself")
+		}
+	}
 	for _, rule := range r.Rules {
 		if err := validatePatterns(rule.ResourcePatterns); err != nil {
 			return err
 		}
 	}
-	return nil
+	if err := validateSessionQuotas(r.MaxSessions, r.MaxSessionsPerTemplate); err != nil {
+		return err
+	}
+	if r.Placement != nil {
+		if err := r.Placement.Validate(); err != nil {
+			return err
+		}
+	}
+	return validateTokenDuration(r.TokenDuration)
 }
 
 // GetRules returns the rules for a new role request, or a single-element slice with
@@ -188,11 +482,30 @@ type UpdateRoleRequest struct {
 	Annotations map[string]string `json:"annotations"`
 	// The new rules for the role.
 	Rules []Rule `json:"rules"`
+	// The new names of other roles to inherit rules from.
+	Inherits []string `json:"inherits,omitempty"`
+	// The new token lifetime for the role.
+	TokenDuration string `json:"tokenDuration,omitempty"`
+	// Whether desktop displays should be watermarked for members of the role.
+	Watermark bool `json:"watermark,omitempty"`
+	// The maximum number of desktop sessions members of the role may run at once.
+	MaxSessions int32 `json:"maxSessions,omitempty"`
+	// The maximum number of desktop sessions members of the role may run at once
+	// from any single template.
+	MaxSessionsPerTemplate int32 `json:"maxSessionsPerTemplate,omitempty"`
+	// Where members of the role may run desktop sessions, and how much CPU and
+	// memory they may consume.
+	Placement *SessionPlacement `json:"placement,omitempty"`
+	// Whether members of the role must complete MFA when they log in.
+	RequireMFA bool `json:"requireMFA,omitempty"`
 }
 
 // GetAnnotations returns the annotations provided in the request
 func (r *UpdateRoleRequest) GetAnnotations() map[string]string { return r.Annotations }
 
+// GetTokenDuration returns the token lifetime provided in the request
+func (r *UpdateRoleRequest) GetTokenDuration() string { return r.TokenDuration }
+
 // GetRules returns the rules for an update role request, or a single-element slice with
 // a deny-all rule if none are provided.
 func (r *UpdateRoleRequest) GetRules() []Rule {
@@ -214,6 +527,39 @@ func (r *UpdateRoleRequest) Validate() error {
 			return err
 		}
 	}
+	if err := validateSessionQuotas(r.MaxSessions, r.MaxSessionsPerTemplate); err != nil {
+		return err
+	}
+	if r.Placement != nil {
+		if err := r.Placement.Validate(); err != nil {
+			return err
+		}
+	}
+	return validateTokenDuration(r.TokenDuration)
+}
+
+// validateTokenDuration returns an error if the given token duration is set
+// but is not a valid positive duration.
+func validateTokenDuration(duration string) error {
+	if duration == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return fmt.Errorf("%s is an invalid token duration: %s", duration, err.Error())
+	}
+	if d <= 0 {
+		return fmt.Errorf("Token duration must be greater than zero, got %s", duration)
+	}
+	return nil
+}
+
+// validateSessionQuotas returns an error if either of the given session quotas
+// is negative.
+func validateSessionQuotas(maxSessions, maxSessionsPerTemplate int32) error {
+	if maxSessions < 0 || maxSessionsPerTemplate < 0 {
+		return errors.New("Session quotas cannot be negative")
+	}
 	return nil
 }
 
@@ -228,12 +574,118 @@ func validatePatterns(patterns []string) error {
 	return nil
 }
 
+// CreateGroupRequest represents a request for a new group.
+type CreateGroupRequest struct {
+	// The name of the new group
+	Name string `json:"name"`
+	// The names of the users that are members of the group.
+	Users []string `json:"users"`
+	// The names of the roles to grant members of the group.
+	Roles []string `json:"roles"`
+}
+
+// GetName returns the name of the new group
+func (r *CreateGroupRequest) GetName() string { return r.Name }
+
+// Validate the CreateGroupRequest
+func (r *CreateGroupRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("A name is required for the new group")
+	}
+	return nil
+}
+
+// UpdateGroupRequest requests updates to an existing group. The existing members
+// and roles will be entirely replaced with those supplied in the payload.
+type UpdateGroupRequest struct {
+	// The new members of the group.
+	Users []string `json:"users"`
+	// The new roles to grant members of the group.
+	Roles []string `json:"roles"`
+}
+
+// CreateAnnouncementRequest represents a request for a new announcement.
+type CreateAnnouncementRequest struct {
+	// The name of the new announcement
+	Name string `json:"name"`
+	// The message shown to users
+	Message string `json:"message"`
+	// How prominently the message is shown. Defaults to `info`.
+	Severity AnnouncementSeverity `json:"severity,omitempty"`
+	// When the announcement starts being shown. Defaults to now.
+	StartTime *time.Time `json:"startTime,omitempty"`
+	// When the announcement stops being shown. It is shown until deleted when omitted.
+	EndTime *time.Time `json:"endTime,omitempty"`
+	// The names of the roles the announcement is shown to. Defaults to all users.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// GetName returns the name of the new announcement
+func (r *CreateAnnouncementRequest) GetName() string { return r.Name }
+
+// Validate the CreateAnnouncementRequest
+func (r *CreateAnnouncementRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("A name is required for the new announcement")
+	}
+	return validateAnnouncement(r.Message, r.Severity, r.StartTime, r.EndTime)
+}
+
+// UpdateAnnouncementRequest requests updates to an existing announcement. All of
+// its details will be replaced with those supplied in the payload.
+type UpdateAnnouncementRequest struct {
+	// The message shown to users
+	Message string `json:"message"`
+	// How prominently the message is shown. Defaults to `info`.
+	Severity AnnouncementSeverity `json:"severity,omitempty"`
+	// When the announcement starts being shown. Defaults to now.
+	StartTime *time.Time `json:"startTime,omitempty"`
+	// When the announcement stops being shown. It is shown until deleted when omitted.
+	EndTime *time.Time `json:"endTime,omitempty"`
+	// The names of the roles the announcement is shown to. Defaults to all users.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Validate the UpdateAnnouncementRequest
+func (r *UpdateAnnouncementRequest) Validate() error {
+	return validateAnnouncement(r.Message, r.Severity, r.StartTime, r.EndTime)
+}
+
+func validateAnnouncement(message string, severity AnnouncementSeverity, start, end *time.Time) error {
+	if message == "" {
+		return errors.New("A message is required for the announcement")
+	}
+	if err := severity.Validate(); err != nil {
+		return err
+	}
+	if start != nil && end != nil && !end.After(*start) {
+		return errors.New("The end time must be after the start time")
+	}
+	return nil
+}
+
 // CreateSessionRequest requests a new desktop session with the givin parameters.
 type CreateSessionRequest struct {
 	// The template to create the session from.
 	Template string `json:"template"`
 	// The namespace to launch the template in. Defaults to default.
 	Namespace string `json:"namespace,omitempty"`
+	// Values for the parameters defined in the template. Parameters that are not
+	// set use their default value.
+	Params map[string]string `json:"params,omitempty"`
+	// The name of one of the user's snapshots to restore their home directory from.
+	// The snapshot must be in the namespace of the session.
+	Snapshot string `json:"snapshot,omitempty"`
+	// A revision of the template to pin the session to. Defaults to the current
+	// revision.
+	TemplateRevision int64 `json:"templateRevision,omitempty"`
+	// Environment variables to set in the desktop container. The user must be
+	// allowed the `set-env` verb on the template, and the template must allow
+	// each variable.
+	Env map[string]string `json:"env,omitempty"`
+	// Environment variables to set in the desktop container from the user's secrets,
+	// mapped to the name of the secret. The same restrictions as `env` apply.
+	SecretEnv map[string]string `json:"secretEnv,omitempty"`
 }
 
 // Validate the CreateSessionRequest
@@ -241,9 +693,33 @@ func (r *CreateSessionRequest) Validate() error {
 	if r.Template == "" {
 		return errors.New("A template is required")
 	}
+	if r.TemplateRevision < 0 {
+		return errors.New("The template revision cannot be negative")
+	}
+	for name := range r.Env {
+		if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+			return fmt.Errorf("Invalid environment variable name '%s': %s", name, strings.Join(errs, ", "))
+		}
+		if _, ok := r.SecretEnv[name]; ok {
+			return fmt.Errorf("Environment variable %s cannot be set from both a value and a secret", name)
+		}
+	}
+	for name, secret := range r.SecretEnv {
+		if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+			return fmt.Errorf("Invalid environment variable name '%s': %s", name, strings.Join(errs, ", "))
+		}
+		if err := ValidateUserSecretName(secret); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// HasEnv returns true if the request sets any environment variables.
+func (r *CreateSessionRequest) HasEnv() bool {
+	return len(r.Env) > 0 || len(r.SecretEnv) > 0
+}
+
 // GetTemplate returns the template for this request
 func (r *CreateSessionRequest) GetTemplate() string {
 	return r.Template
@@ -258,11 +734,289 @@ func (r *CreateSessionRequest) GetNamespace() string {
 	return DefaultNamespace
 }
 
+// CreateSessionResponse returns the name of the Desktop and what namespace
+// it is running in.
+type CreateSessionResponse struct {
+	// The name of the Desktop.
+	Name string `json:"name"`
+	// The namespace the Desktop is running in.
+	Namespace string `json:"namespace"`
+}
+
+// RollbackTemplateRequest requests a DesktopTemplate be rolled back to a previous
+// revision.
+type RollbackTemplateRequest struct {
+	// The revision to roll back to.
+	Revision int64 `json:"revision"`
+}
+
+// Validate the RollbackTemplateRequest
+func (r *RollbackTemplateRequest) Validate() error {
+	if r.Revision <= 0 {
+		return errors.New("A revision to roll back to is required")
+	}
+	return nil
+}
+
+// EstimateSessionRequest requests an estimate of the resources and cost of launching
+// a session from a DesktopTemplate.
+type EstimateSessionRequest struct {
+	// The namespace the session would be launched in. Defaults to default.
+	Namespace string `json:"namespace,omitempty"`
+	// Values for the parameters defined in the template. Parameters that are not
+	// set use their default value.
+	Params map[string]string `json:"params,omitempty"`
+	// A revision of the template to estimate the session for. Defaults to the current
+	// revision.
+	TemplateRevision int64 `json:"templateRevision,omitempty"`
+}
+
+// Validate the EstimateSessionRequest
+func (r *EstimateSessionRequest) Validate() error {
+	if r.TemplateRevision < 0 {
+		return errors.New("The template revision cannot be negative")
+	}
+	return nil
+}
+
+// GetNamespace returns the namspace for this request, or the default namespace
+// if not provided.
+func (r *EstimateSessionRequest) GetNamespace() string {
+	if r.Namespace != "" {
+		return r.Namespace
+	}
+	return DefaultNamespace
+}
+
+// CreateSessionInviteRequest requests an invite for another user to attach to
+// the display of a desktop session.
+type CreateSessionInviteRequest struct {
+	// The user to invite.
+	User string `json:"user"`
+	// Whether the user may only watch the display (`view`), or also send input
+	// to it (`control`). Defaults to `view`.
+	Mode SessionInviteMode `json:"mode,omitempty"`
+	// How long the invite is valid for. Defaults to `1h`. Invites are removed
+	// along with the desktop session regardless.
+	Duration string `json:"duration,omitempty"`
+}
+
+// Validate the CreateSessionInviteRequest
+func (r *CreateSessionInviteRequest) Validate() error {
+	if r.User == "" {
+		return errors.New("'user' must be provided in the request")
+	}
+	switch r.Mode {
+	case "", SessionInviteView, SessionInviteControl:
+	default:
+		return fmt.Errorf("Invalid invite mode '%s', must be one of '%s' or '%s'", r.Mode, SessionInviteView, SessionInviteControl)
+	}
+	if r.Duration != "" {
+		dur, err := time.ParseDuration(r.Duration)
+		if err != nil {
+			return fmt.Errorf("Invalid invite duration '%s': %s", r.Duration, err.Error())
+		}
+		if dur <= 0 {
+			return errors.New("The invite duration must be greater than zero")
+		}
+	}
+	return nil
+}
+
+// GetMode returns the mode for the invite, defaulting to view-only.
+func (r *CreateSessionInviteRequest) GetMode() SessionInviteMode {
+	if r.Mode == "" {
+		return SessionInviteView
+	}
+	return r.Mode
+}
+
+// GetDuration returns how long the invite is valid for.
+func (r *CreateSessionInviteRequest) GetDuration() time.Duration {
+	if r.Duration != "" {
+		if dur, err := time.ParseDuration(r.Duration); err == nil {
+			return dur
+		}
+	}
+	return time.Hour
+}
+
+// CreateRoleGrantRequest requests a role be granted to a user for a limited time.
+type CreateRoleGrantRequest struct {
+	// The user to grant the role to.
+	User string `json:"user"`
+	// How long the role is granted for, e.g. `2h`. Either this or `expiresAt`
+	// is required.
+	Duration string `json:"duration,omitempty"`
+	// A unix timestamp of when the grant expires.
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+}
+
+// Validate the CreateRoleGrantRequest
+func (r *CreateRoleGrantRequest) Validate() error {
+	if r.User == "" {
+		return errors.New("'user' must be provided in the request")
+	}
+	if r.Duration == "" && r.ExpiresAt == 0 {
+		return errors.New("One of 'duration' or 'expiresAt' must be provided in the request")
+	}
+	if r.Duration != "" && r.ExpiresAt != 0 {
+		return errors.New("Only one of 'duration' or 'expiresAt' may be provided in the request")
+	}
+	if r.Duration != "" {
+		dur, err := time.ParseDuration(r.Duration)
+		if err != nil {
+			return fmt.Errorf("Invalid grant duration '%s': %s", r.Duration, err.Error())
+		}
+		if dur <= 0 {
+			return errors.New("The grant duration must be greater than zero")
+		}
+		return nil
+	}
+	if r.ExpiresAt <= time.Now().Unix() {
+		return errors.New("The grant expiry must be in the future")
+	}
+	return nil
+}
+
+// GetExpiresAt returns the unix timestamp of when the grant expires.
+func (r *CreateRoleGrantRequest) GetExpiresAt() int64 {
+	if r.Duration != "" {
+		if dur, err := time.ParseDuration(r.Duration); err == nil {
+			return time.Now().Add(dur).Unix()
+		}
+	}
+	return r.ExpiresAt
+}
+
+// CreateViewTokenRequest requests a token granting view-only access to the
+// display of a desktop session.
+type CreateViewTokenRequest struct {
+	// A description of where the token is used.
+	Label string `json:"label,omitempty"`
+	// How long the token is valid for. Defaults to `1h` and may not be longer than
+	// `24h`. Tokens are removed along with the desktop session regardless.
+	Duration string `json:"duration,omitempty"`
+}
+
+// Validate the CreateViewTokenRequest
+func (r *CreateViewTokenRequest) Validate() error {
+	if r.Duration != "" {
+		dur, err := time.ParseDuration(r.Duration)
+		if err != nil {
+			return fmt.Errorf("Invalid view token duration '%s': %s", r.Duration, err.Error())
+		}
+		if dur <= 0 {
+			return errors.New("The view token duration must be greater than zero")
+		}
+		if max, _ := time.ParseDuration(MaxViewTokenDuration); dur > max {
+			return fmt.Errorf("View tokens may not be valid for longer than %s", MaxViewTokenDuration)
+		}
+	}
+	return nil
+}
+
+// GetDuration returns how long the view token is valid for.
+func (r *CreateViewTokenRequest) GetDuration() time.Duration {
+	if r.Duration != "" {
+		if dur, err := time.ParseDuration(r.Duration); err == nil {
+			return dur
+		}
+	}
+	return time.Hour
+}
+
+// MaxImpersonationDuration is the longest an impersonation token may be valid for.
+const MaxImpersonationDuration = "1h"
+
+// EvaluatePermissionRequest requests an evaluation of whether a user or role would
+// be allowed to perform an action.
+type EvaluatePermissionRequest struct {
+	// The user to evaluate the action for. Either a user or a role is required.
+	User string `json:"user,omitempty"`
+	// The role to evaluate the action for.
+	Role string `json:"role,omitempty"`
+	// The verb of the action.
+	Verb Verb `json:"verb"`
+	// The type of resource the action targets.
+	Resource Resource `json:"resource"`
+	// The name of the targeted resource. This is matched against the resource
+	// patterns of rules.
+	Name string `json:"name,omitempty"`
+	// The namespace of the targeted resource. Rules only restrict namespaces for
+	// launching templates.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Validate the EvaluatePermissionRequest
+func (r *EvaluatePermissionRequest) Validate() error {
+	if r.User == "" && r.Role == "" {
+		return errors.New("A user or role to evaluate is required")
+	}
+	if r.User != "" && r.Role != "" {
+		return errors.New("Only one of a user or role can be evaluated")
+	}
+	if r.Verb == "" {
+		return errors.New("A verb is required")
+	}
+	if r.Resource == "" {
+		return errors.New("A resource is required")
+	}
+	return nil
+}
+
+// GetAction returns the action to evaluate for this request.
+func (r *EvaluatePermissionRequest) GetAction() *APIAction {
+	return &APIAction{
+		Verb:              r.Verb,
+		ResourceType:      r.Resource,
+		ResourceName:      r.Name,
+		ResourceNamespace: r.Namespace,
+	}
+}
+
+// ImpersonateRequest requests a token for acting as another user.
+type ImpersonateRequest struct {
+	// How long the token is valid for. Defaults to `15m` and may not be longer than
+	// `1h`. The token also never outlives the session length of the impersonated
+	// user.
+	Duration string `json:"duration,omitempty"`
+}
+
+// Validate the ImpersonateRequest
+func (r *ImpersonateRequest) Validate() error {
+	if r.Duration != "" {
+		dur, err := time.ParseDuration(r.Duration)
+		if err != nil {
+			return fmt.Errorf("Invalid impersonation duration '%s': %s", r.Duration, err.Error())
+		}
+		if dur <= 0 {
+			return errors.New("The impersonation duration must be greater than zero")
+		}
+		if max, _ := time.ParseDuration(MaxImpersonationDuration); dur > max {
+			return fmt.Errorf("Impersonation tokens may not be valid for longer than %s", MaxImpersonationDuration)
+		}
+	}
+	return nil
+}
+
+// GetDuration returns how long the impersonation token is valid for.
+func (r *ImpersonateRequest) GetDuration() time.Duration {
+	if r.Duration != "" {
+		if dur, err := time.ParseDuration(r.Duration); err == nil {
+			return dur
+		}
+	}
+	return DefaultSessionLength
+}
+
 // DesktopSessionsResponse contains a list of desktop sessions and information
 // about their statuses.
 type DesktopSessionsResponse struct {
 	// A list of desktop sessions.
 	Sessions []*DesktopSession `json:"sessions"`
+	// Information about the page of sessions, when one was requested.
+	Metadata *ListMeta `json:"metadata,omitempty"`
 }
 
 // DesktopSession describes the properties and status of a desktop session.
@@ -314,3 +1068,74 @@ type FileStat struct {
 	// When IsDirectory is true, the contents of the directory
 	Contents []*FileStat `json:"contents,omitempty"`
 }
+
+// ExportTemplatesRequest requests a signed bundle of DesktopTemplates for importing
+// into another cluster.
+type ExportTemplatesRequest struct {
+	// The names of the templates to export.
+	Templates []string `json:"templates"`
+	// Whether to include the roles that grant access to the templates.
+	IncludeRoles bool `json:"includeRoles,omitempty"`
+	// Whether to merge the base templates of the exported templates into them, so
+	// the bundle can be imported into clusters that do not have the base templates.
+	Flatten bool `json:"flatten,omitempty"`
+}
+
+// GetTemplates returns the templates to export.
+func (r *ExportTemplatesRequest) GetTemplates() []string { return r.Templates }
+
+// Validate the ExportTemplatesRequest
+func (r *ExportTemplatesRequest) Validate() error {
+	if len(r.Templates) == 0 {
+		return errors.New("At least one template must be specified for export")
+	}
+	return nil
+}
+
+// DrainNodeRequest requests that desktops be moved off of a node.
+type DrainNodeRequest struct {
+	// How long to give users before their desktops are migrated or terminated.
+	// Defaults to 5m.
+	GracePeriod string `json:"gracePeriod,omitempty"`
+	// Whether to migrate desktops to another node or terminate them. Defaults to
+	// `migrate`.
+	Action DrainAction `json:"action,omitempty"`
+	// A message to display to affected users.
+	Message string `json:"message,omitempty"`
+	// Whether to mark the node unschedulable first, so no new desktops are started
+	// on it while it is drained.
+	Cordon bool `json:"cordon,omitempty"`
+}
+
+// GetGracePeriod returns how long to wait before draining desktops.
+func (r *DrainNodeRequest) GetGracePeriod() time.Duration {
+	if r.GracePeriod != "" {
+		if dur, err := time.ParseDuration(r.GracePeriod); err == nil {
+			return dur
+		}
+	}
+	return 5 * time.Minute
+}
+
+// GetAction returns the action to take on desktops.
+func (r *DrainNodeRequest) GetAction() DrainAction {
+	if r.Action != "" {
+		return r.Action
+	}
+	return DrainActionMigrate
+}
+
+// Validate the DrainNodeRequest
+func (r *DrainNodeRequest) Validate() error {
+	if r.GracePeriod != "" {
+		if _, err := time.ParseDuration(r.GracePeriod); err != nil {
+			return fmt.Errorf("Invalid grace period: %s", err.Error())
+		}
+	}
+	switch r.GetAction() {
+	case DrainActionMigrate, DrainActionTerminate:
+	default:
+		return fmt.Errorf("Invalid drain action: %s", r.Action)
+	}
+	return nil
+}
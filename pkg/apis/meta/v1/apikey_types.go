@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"errors"
+	"time"
+)
+
+// APIKeyTokenPrefix is prepended to API key tokens so they can be told apart
+// from session tokens.
+const APIKeyTokenPrefix = "kvdi_"
+
+// APIKey represents a long-lived token for accessing the API without logging in.
+type APIKey struct {
+	// A unique ID for the key
+	ID string `json:"id"`
+	// A name describing what the key is used for
+	Name string `json:"name"`
+	// The user the key acts on behalf of
+	User string `json:"user"`
+	// The rules the key is restricted to. Requests made with the key are allowed
+	// only if both the user and one of these rules allow them.
+	Rules []Rule `json:"rules"`
+	// When the key was created, as a unix timestamp
+	CreatedAt int64 `json:"createdAt"`
+	// When the key expires, as a unix timestamp. Zero if the key does not expire.
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+}
+
+// Expired returns true if the key has passed its expiry.
+func (k *APIKey) Expired() bool {
+	return k.ExpiresAt != 0 && time.Now().Unix() >= k.ExpiresAt
+}
+
+// CreateAPIKeyRequest represents a request to create a new API key for the
+// requesting user.
+type CreateAPIKeyRequest struct {
+	// A name describing what the key is used for.
+	Name string `json:"name"`
+	// The rules to restrict the key to. These may not grant more than the user
+	// already has.
+	Rules []Rule `json:"rules"`
+	// How long until the key expires, e.g. `720h`. If omitted the key does not
+	// expire.
+	ExpiresIn string `json:"expiresIn,omitempty"`
+}
+
+// Validate the create API key request.
+func (r *CreateAPIKeyRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("'name' must be provided in the request")
+	}
+	if len(r.Rules) == 0 {
+		return errors.New("You must restrict the key to at least one rule")
+	}
+	if r.ExpiresIn != "" {
+		dur, err := time.ParseDuration(r.ExpiresIn)
+		if err != nil {
+			return err
+		}
+		if dur <= 0 {
+			return errors.New("'expiresIn' must be a positive duration")
+		}
+	}
+	return nil
+}
+
+// GetExpiresIn returns the lifetime requested for the key, or zero if it should
+// not expire.
+func (r *CreateAPIKeyRequest) GetExpiresIn() time.Duration {
+	dur, _ := time.ParseDuration(r.ExpiresIn)
+	return dur
+}
+
+// CreateAPIKeyResponse contains a newly created API key and its token. The token
+// is only returned once and cannot be retrieved again.
+type CreateAPIKeyResponse struct {
+	// The created key
+	APIKey *APIKey `json:"apiKey"`
+	// The token to pass in the X-Session-Token header
+	Token string `json:"token"`
+}
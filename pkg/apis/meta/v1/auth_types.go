@@ -3,6 +3,7 @@ package v1
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 )
@@ -45,11 +46,13 @@ type AuthResult struct {
 	// The provider can populate this field to signify a redirect is required,
 	// e.g. for OIDC.
 	RedirectURL string
-	// In the case of OIDC, the refresh tokens cannot be used. Because when the user
-	// tries to use them, there is no way to query the provider for the user's information
-	// without initializing a new auth flow. For now, the provider can set this to false to
-	// signal to the server that a refresh is not possible.
+	// The provider can set this to true to signal to the server that a refresh is
+	// not possible. For example, when an OIDC provider does not issue a refresh token.
 	RefreshNotSupported bool
+	// A refresh token issued by the provider, e.g. for OIDC. It is kept in the secrets
+	// backend alongside the kVDI refresh token and handed back to the provider when the
+	// session is renewed.
+	ProviderRefreshToken string
 }
 
 // JWTClaims represents the claims used when issuing JWT tokens.
@@ -60,21 +63,51 @@ type JWTClaims struct {
 	Authorized bool `json:"authorized"`
 	// Whether a refresh token was issued with the claims
 	Renewable bool `json:"renewable"`
+	// The ID of the API key used to authenticate, if any
+	APIKey string `json:"apiKey,omitempty"`
+	// The ID of the view token used to authenticate, if any. These claims can only
+	// be used to watch the display of the desktop the view token was created for.
+	ViewToken string `json:"viewToken,omitempty"`
+	// The name of the user that requested the token when impersonating the user
+	// in the claims. Impersonated sessions cannot be renewed.
+	Impersonator string `json:"impersonator,omitempty"`
+	// When the token was issued in milliseconds since the epoch. This tells apart
+	// tokens issued in the same second as a user's sessions were revoked.
+	IssuedAtMillis int64 `json:"iatMillis,omitempty"`
 	// The standard JWT claims
 	jwt.StandardClaims
 }
 
+// GetIssuedAtMillis returns when the token was issued in milliseconds since the
+// epoch. Tokens issued without the millisecond claim use their issued-at second.
+func (c *JWTClaims) GetIssuedAtMillis() int64 {
+	if c.IssuedAtMillis != 0 {
+		return c.IssuedAtMillis
+	}
+	return c.IssuedAt * 1000
+}
+
 // VDIUser represents a user in kVDI. It is the auth providers responsibility
 // to take an authentication request and generate a JWT with claims defining
 // this object.
 type VDIUser struct {
 	// A unique name for the user
 	Name string `json:"name"`
+	// The name to display for the user, when the auth provider has one
+	DisplayName string `json:"displayName,omitempty"`
+	// The email address of the user, when the auth provider has one
+	Email string `json:"email,omitempty"`
 	// A list of roles applide to the user. The grants associated with each user
 	// are embedded in the JWT signed when authenticating.
 	Roles []*VDIUserRole `json:"roles"`
+	// The names of the groups the user is a member of. Roles granted through
+	// groups are included in Roles.
+	Groups []string `json:"groups,omitempty"`
 	// MFA status for the user
 	MFA *UserMFAStatus `json:"mfa"`
+	// When populated, actions must also be allowed by one of these rules. This
+	// is used to scope the permissions of API keys.
+	Restrictions []Rule `json:"restrictions,omitempty"`
 }
 
 // UserMFAStatus contains information about the MFA configurations
@@ -90,6 +123,9 @@ func (u *VDIUser) GetName() string { return u.Name }
 // Evaluate will iterate the user's roles and return true if any of them have
 // a rule that allows the given action.
 func (u *VDIUser) Evaluate(action *APIAction) bool {
+	if !u.RestrictionsAllow(action) {
+		return false
+	}
 	for _, role := range u.Roles {
 		if ok := role.Evaluate(action); ok {
 			return true
@@ -101,6 +137,9 @@ func (u *VDIUser) Evaluate(action *APIAction) bool {
 // IncludesRule returns true if the rules applied to this user are not elevated
 // by any of the permissions in the provided rule.
 func (u *VDIUser) IncludesRule(ruleToCheck Rule, resourceGetter ResourceGetter) bool {
+	if len(u.Restrictions) > 0 && !u.restrictionsInclude(ruleToCheck, resourceGetter) {
+		return false
+	}
 	for _, role := range u.Roles {
 		if ok := role.IncludesRule(ruleToCheck, resourceGetter); ok {
 			return true
@@ -109,6 +148,118 @@ func (u *VDIUser) IncludesRule(ruleToCheck Rule, resourceGetter ResourceGetter)
 	return false
 }
 
+// RestrictionsAllow returns true if the user has no restrictions, or if one of
+// them allows the given action.
+func (u *VDIUser) RestrictionsAllow(action *APIAction) bool {
+	if len(u.Restrictions) == 0 {
+		return true
+	}
+	for _, rule := range u.Restrictions {
+		if rule.Evaluate(action) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *VDIUser) restrictionsInclude(ruleToCheck Rule, resourceGetter ResourceGetter) bool {
+	for _, rule := range u.Restrictions {
+		if rule.IncludesRule(ruleToCheck, resourceGetter) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTokenDuration returns the lifetime for new session tokens issued to this user.
+// The shortest duration configured across the user's roles is used, and if none
+// of them declare one, the provided default is returned.
+func (u *VDIUser) GetTokenDuration(defaultDuration time.Duration) time.Duration {
+	var duration time.Duration
+	for _, role := range u.Roles {
+		if roleDuration := role.GetTokenDuration(); roleDuration > 0 {
+			if duration == 0 || roleDuration < duration {
+				duration = roleDuration
+			}
+		}
+	}
+	if duration == 0 {
+		return defaultDuration
+	}
+	return duration
+}
+
+// GetRolesExpireAt returns the unix timestamp of when the first of the user's
+// temporary roles expires. Zero is returned if none of the roles expire.
+func (u *VDIUser) GetRolesExpireAt() int64 {
+	var expiresAt int64
+	for _, role := range u.Roles {
+		if role.ExpiresAt > 0 && (expiresAt == 0 || role.ExpiresAt < expiresAt) {
+			expiresAt = role.ExpiresAt
+		}
+	}
+	return expiresAt
+}
+
+// GetRoleNames returns the names of the user's roles.
+func (u *VDIUser) GetRoleNames() []string {
+	names := make([]string, len(u.Roles))
+	for idx, role := range u.Roles {
+		names[idx] = role.GetName()
+	}
+	return names
+}
+
+// WatermarkRequired returns true if any of the user's roles require desktop
+// displays to be watermarked.
+func (u *VDIUser) WatermarkRequired() bool {
+	for _, role := range u.Roles {
+		if role.Watermark {
+			return true
+		}
+	}
+	return false
+}
+
+// MFARequired returns true if any of the user's roles require them to complete
+// MFA when they log in.
+func (u *VDIUser) MFARequired() bool {
+	for _, role := range u.Roles {
+		if role.RequireMFA {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMaxSessions returns the maximum number of desktop sessions this user may run
+// at once. The lowest limit configured across the user's roles is used. Zero means
+// there is no limit.
+func (u *VDIUser) GetMaxSessions() int32 {
+	return lowestLimit(u.Roles, func(role *VDIUserRole) int32 { return role.MaxSessions })
+}
+
+// GetMaxSessionsPerTemplate returns the maximum number of desktop sessions this user
+// may run at once from any single template. The lowest limit configured across the
+// user's roles is used. Zero means there is no limit.
+func (u *VDIUser) GetMaxSessionsPerTemplate() int32 {
+	return lowestLimit(u.Roles, func(role *VDIUserRole) int32 { return role.MaxSessionsPerTemplate })
+}
+
+// lowestLimit returns the lowest non-zero value returned by the given function
+// for the roles, or zero if none of them set one.
+func lowestLimit(roles []*VDIUserRole, getLimit func(*VDIUserRole) int32) int32 {
+	var limit int32
+	for _, role := range roles {
+		if roleLimit := getLimit(role); roleLimit > 0 {
+			if limit == 0 || roleLimit < limit {
+				limit = roleLimit
+			}
+		}
+	}
+	return limit
+}
+
 // FilterNamespaces will take a list of namespaces, and filter them based off
 // the ones this user can provision desktops in.
 func (u *VDIUser) FilterNamespaces(nss []string) []string {
@@ -135,26 +286,68 @@ type VDIUserRole struct {
 	Name string `json:"name"`
 	// The rules for this role.
 	Rules []Rule `json:"rules"`
+	// The maximum lifetime of session tokens issued to members of this role.
+	TokenDuration string `json:"tokenDuration,omitempty"`
+	// Whether desktop displays should be watermarked for members of this role.
+	Watermark bool `json:"watermark,omitempty"`
+	// The maximum number of desktop sessions members of this role may run at once.
+	MaxSessions int32 `json:"maxSessions,omitempty"`
+	// The maximum number of desktop sessions members of this role may run at once
+	// from any single template.
+	MaxSessionsPerTemplate int32 `json:"maxSessionsPerTemplate,omitempty"`
+	// Where members of this role may run desktop sessions, and how much CPU and
+	// memory they may consume.
+	Placement *SessionPlacement `json:"placement,omitempty"`
+	// A unix timestamp of when the role is removed from the user, if it was only
+	// granted for a limited time.
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+	// Whether members of this role must complete MFA when they log in.
+	RequireMFA bool `json:"requireMFA,omitempty"`
 }
 
 // GetName returns the name of the role
 func (r *VDIUserRole) GetName() string { return r.Name }
 
+// GetTokenDuration returns the token lifetime configured for this role. If none
+// is set, or it cannot be parsed, zero is returned.
+func (r *VDIUserRole) GetTokenDuration() time.Duration {
+	if r.TokenDuration == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(r.TokenDuration)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
 // Evaluate iterates all the rules in this role and returns true if any of them
 // allow the provided action.
 func (r *VDIUserRole) Evaluate(action *APIAction) bool {
-	for _, rule := range r.Rules {
-		if ok := rule.Evaluate(action); ok {
-			return true
+	return r.MatchingRule(action) != nil
+}
+
+// MatchingRule returns the first of the effective rules of this role that allows
+// the provided action, or nil if none of them do.
+func (r *VDIUserRole) MatchingRule(action *APIAction) *Rule {
+	rules := r.EffectiveRules()
+	for idx := range rules {
+		if rules[idx].Evaluate(action) {
+			return &rules[idx]
 		}
 	}
-	return false
+	return nil
 }
 
+// EffectiveRules returns the rules of this role along with the data loss prevention
+// verbs they imply. See EffectiveRules for details.
+func (r *VDIUserRole) EffectiveRules() []Rule { return EffectiveRules(r.Rules) }
+
 // IncludesRule returns true if the rules applied to this role are not elevated
-// by any of the permissions in the provided rule.
+// by any of the permissions in the provided rule. The rule to check should already
+// be expanded with EffectiveRules.
 func (r *VDIUserRole) IncludesRule(ruleToCheck Rule, resourceGetter ResourceGetter) bool {
-	for _, rule := range r.Rules {
+	for _, rule := range r.EffectiveRules() {
 		if ok := rule.IncludesRule(ruleToCheck, resourceGetter); ok {
 			return true
 		}
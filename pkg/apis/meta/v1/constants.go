@@ -3,7 +3,7 @@ package v1
 import "time"
 
 const (
-	// RoleClusterRefLabel marks for which cluster a role belongs
+	// RoleClusterRefLabel marks for which cluster a role or group belongs
 	RoleClusterRefLabel = "kvdi.io/cluster-ref"
 	// CreationSpecAnnotation contains the serialized creation spec of a resource
 	// to be compared against desired state.
@@ -16,6 +16,55 @@ const (
 	// to groups provided in claims from an OIDC provider. A semicolon separated list can
 	// bind a role to multiple groups.
 	OIDCGroupRoleAnnotation = "kvdi.io/oidc-groups"
+	// CertGroupRoleAnnotation is the annotation applied to VDIRoles to "bind" them
+	// to attributes of client certificates. Organizational units, SAN email addresses,
+	// UPNs, and URIs are matched. A semicolon separated list can bind a role to
+	// multiple values.
+	CertGroupRoleAnnotation = "kvdi.io/cert-groups"
+	// DotfilesAnnotation is applied to desktops whose user had a dotfiles repository
+	// configured at launch. The repository is cloned into the home volume before the
+	// desktop starts.
+	DotfilesAnnotation = "kvdi.io/dotfiles"
+	// ExpiryWarningAnnotation is applied to desktops once their user has been emailed
+	// a warning that the desktop will be destroyed soon.
+	ExpiryWarningAnnotation = "kvdi.io/expiry-warning-sent"
+	// DrainAnnotation is applied to desktops running on a node being drained. It
+	// contains a serialized DrainNotice.
+	DrainAnnotation = "kvdi.io/drain"
+	// ClaimedAtAnnotation is applied to desktops claimed from a SessionPool and contains
+	// the time they were claimed in RFC3339 format. Their lifetime is counted from this
+	// time instead of when they were created.
+	ClaimedAtAnnotation = "kvdi.io/claimed-at"
+	// UserdataLastUsedAnnotation is applied to userdata volumes and contains the time
+	// they were last released by a desktop in RFC3339 format.
+	UserdataLastUsedAnnotation = "kvdi.io/userdata-last-used"
+	// SessionInvitesAnnotation is applied to desktops and contains a serialized list
+	// of SessionInvites for other users to attach to the display.
+	SessionInvitesAnnotation = "kvdi.io/session-invites"
+	// ViewTokensAnnotation is applied to desktops and contains a serialized list of
+	// ViewTokens granting view-only access to the display.
+	ViewTokensAnnotation = "kvdi.io/view-tokens"
+	// SessionHandoffAnnotation is applied to desktops and contains a serialized
+	// SessionHandoff for moving the display to another device of its user.
+	SessionHandoffAnnotation = "kvdi.io/session-handoff"
+	// AppBackendsAnnotation is applied to the app pod template and contains the auth
+	// and secrets backends in use. Changes to either require the app to be restarted,
+	// all other configurations are applied at runtime.
+	AppBackendsAnnotation = "kvdi.io/app-backends"
+	// UserRolesAnnotation is applied to desktops and contains the names of the roles
+	// held by their user at launch, separated by AuthGroupSeparator. It is used to
+	// attribute usage to roles in reports.
+	UserRolesAnnotation = "kvdi.io/user-roles"
+	// TraceParentAnnotation is applied to desktops created by a traced request and
+	// contains its W3C traceparent, so the desktop's startup is recorded in the
+	// same trace.
+	TraceParentAnnotation = "kvdi.io/traceparent"
+	// SnapshotTemplateAnnotation is applied to snapshots of userdata volumes and
+	// contains the template of the desktop they were taken from.
+	SnapshotTemplateAnnotation = "kvdi.io/snapshot-template"
+	// RoleGrantsAnnotation is applied to VDIRoles and contains a serialized list of
+	// RoleGrants temporarily binding the role to users, regardless of the auth provider.
+	RoleGrantsAnnotation = "kvdi.io/role-grants"
 	// AuthGroupSeparator is the separator used when parsing lists of groups from a string.
 	AuthGroupSeparator = ";"
 	// VDIClusterLabel is the label attached to resources to reference their parents VDI cluster
@@ -27,33 +76,143 @@ const (
 	// DesktopNameLabel is a label referencing the name of the desktop instance. This is to add randomness
 	// for the headless service selector placed in front of each pod.
 	DesktopNameLabel = "desktopName"
+	// SessionPoolLabel is a label referencing the SessionPool an unclaimed desktop
+	// instance belongs to. It is removed when the desktop is claimed.
+	SessionPoolLabel = "sessionPool"
+	// DesktopTemplateLabel is a label referencing the DesktopTemplate a recorded
+	// template revision belongs to.
+	DesktopTemplateLabel = "desktopTemplate"
 	// ClientAddrLabel is the a label referencing the client address on a display/audio lock.
 	ClientAddrLabel = "clientAddr"
+	// WatermarkQueryParam is the query parameter used to pass the text of a display
+	// watermark from the API to the kvdi-proxy.
+	WatermarkQueryParam = "watermark"
+	// DisableClipboardInQueryParam is the query parameter used to signal to the
+	// kvdi-proxy that clipboard updates from the client should be dropped.
+	DisableClipboardInQueryParam = "disableClipboardIn"
+	// DisableClipboardOutQueryParam is the query parameter used to signal to the
+	// kvdi-proxy that clipboard updates from the desktop should be dropped.
+	DisableClipboardOutQueryParam = "disableClipboardOut"
+	// RecordingQueryParam is the query parameter used to pass the ID of a recording
+	// to the kvdi-proxy when a display connection should be recorded.
+	RecordingQueryParam = "recording"
+	// ShadowInteractiveQueryParam is the query parameter used to request, and to
+	// signal to the kvdi-proxy, that a shadowing connection may send input.
+	ShadowInteractiveQueryParam = "interactive"
+	// UsernameQueryParam is the query parameter used to pass the name of the user
+	// connecting to a display to the kvdi-proxy. It is used to log into RDP servers
+	// when the template's credentials do not include a username.
+	UsernameQueryParam = "username"
+	// USBBusIDQueryParam is the query parameter used to pass the bus ID of the USB
+	// device a client wants to redirect into a desktop session.
+	USBBusIDQueryParam = "busid"
+	// ResumeTokenQueryParam is the query parameter used by clients to pass a token
+	// identifying their display session, so that a dropped connection can be resumed.
+	ResumeTokenQueryParam = "resume"
+	// HandoffQueryParam is the query parameter used by clients to pass a handoff code
+	// when picking up the display of a desktop session from another device.
+	HandoffQueryParam = "handoff"
+	// AsyncQueryParam is the query parameter used by clients to ask for a long-running
+	// operation to be run as a job.
+	AsyncQueryParam = "async"
+	// KeyboardLayoutQueryParam is the query parameter used by clients to pass the
+	// keyboard layout they detected, and by the API to pass the layout to set on the
+	// display to the kvdi-proxy.
+	KeyboardLayoutQueryParam = "keyboardLayout"
+	// KeyboardVariantQueryParam is the query parameter used alongside the
+	// KeyboardLayoutQueryParam to pass the variant of the layout.
+	KeyboardVariantQueryParam = "keyboardVariant"
 	// ServerCertificateMountPath is where server certificates get placed inside pods
 	ServerCertificateMountPath = "/etc/kvdi/tls/server"
 	// ClientCertificateMountPath is where client certificates get placed inside pods
 	ClientCertificateMountPath = "/etc/kvdi/tls/client"
 	// SecretAssetsMountPath is a mount path for assets backed by secrets
 	SecretAssetsMountPath = "/etc/kvdi/secrets"
+	// AdminPasswordSecretKey is where the generated admin password is stored when using an external secrets backend.
+	AdminPasswordSecretKey = "adminPassword"
 	// JWTSecretKey is where our JWT secret is stored in the secrets backend.
 	JWTSecretKey = "jwtSecret"
+	// JWTSigningKeysSecretKey is where the keys for signing JWTs are stored in the secrets backend,
+	// when signing keys are enabled on the VDICluster.
+	JWTSigningKeysSecretKey = "jwtSigningKeys"
+	// TemplateBundleSecretKey is where the key for signing template bundles is stored in the secrets backend.
+	TemplateBundleSecretKey = "templateBundleKey"
 	// OTPUsersSecretKey is where a mapping of users to their OTP secrets is held in the secrets backend.
 	OTPUsersSecretKey = "otpUsers"
+	// MFABackupCodesSecretKey is where a mapping of users to hashes of their unused MFA backup codes is held
+	// in the secrets backend.
+	MFABackupCodesSecretKey = "mfaBackupCodes"
 	// RefreshTokensSecretKey is where a mapping of refresh tokens to users is kept in the secrets backend.
 	RefreshTokensSecretKey = "refreshTokens"
+	// ProviderRefreshTokensSecretKey is where a mapping of refresh tokens to the ones issued by the auth provider is kept in the secrets backend.
+	ProviderRefreshTokensSecretKey = "providerRefreshTokens"
+	// RevokedTokensSecretKey is where a mapping of revoked access token IDs to their expiry is kept in the secrets backend.
+	RevokedTokensSecretKey = "revokedTokens"
+	// RevokedUsersSecretKey is where a mapping of users to the time all their access tokens were revoked is kept in the secrets backend.
+	RevokedUsersSecretKey = "revokedUsers"
+	// APIKeysSecretKey is where a mapping of API key IDs to their hashed secrets and rules is kept in the secrets backend.
+	APIKeysSecretKey = "apiKeys"
+	// UserDataSecretKey is where a mapping of users to their first-boot scripts is kept in the secrets backend.
+	UserDataSecretKey = "userData"
+	// DotfilesSecretKey is where a mapping of users to their dotfiles repositories is kept in the secrets backend.
+	DotfilesSecretKey = "dotfiles"
+	// UserKeyboardLayoutsSecretKey is where a mapping of users to their preferred keyboard layouts is kept in the secrets backend.
+	UserKeyboardLayoutsSecretKey = "userKeyboardLayouts"
+	// UserEmailsSecretKey is where a mapping of users to their email addresses is kept in the secrets backend.
+	UserEmailsSecretKey = "userEmails"
+	// PasswordResetsSecretKey is where a mapping of hashed password reset tokens to pending resets is kept in the secrets backend.
+	PasswordResetsSecretKey = "passwordResets"
+	// OIDCSubjectsSecretKey is where a mapping of OIDC subjects to users is kept in the secrets backend.
+	OIDCSubjectsSecretKey = "oidcSubjects"
+	// UserSecretsSecretKey is where a mapping of users to the secrets they can inject into their desktop sessions is kept in the secrets backend.
+	UserSecretsSecretKey = "userSecrets"
+	// LoginHistorySecretKey is where the recent login attempts for all users are kept in the secrets backend.
+	LoginHistorySecretKey = "loginHistory"
+	// JobsSecretKey is where a mapping of job IDs to the state of long-running operations is kept in the secrets backend.
+	JobsSecretKey = "jobs"
+	// OIDCIDTokensSecretKey is where a mapping of users to the last ID token issued to them by the OIDC provider is kept in the secrets backend.
+	OIDCIDTokensSecretKey = "oidcIDTokens"
+	// SpentPushTransactionsSecretKey is where a mapping of the signatures of used MFA push transactions to their expiry is kept in the secrets backend.
+	SpentPushTransactionsSecretKey = "spentPushTransactions"
+	// TemplateQueuesSecretKey is where a mapping of templates to the users waiting for capacity on them is kept in the secrets backend.
+	TemplateQueuesSecretKey = "templateQueues"
+	// RDPCredentialsMountPath is where the credentials for logging into RDP servers
+	// are placed inside the kvdi-proxy
+	RDPCredentialsMountPath = "/etc/kvdi/rdp"
+	// UserDataMountPath is where the first-boot script is placed inside desktop pods
+	UserDataMountPath = "/etc/kvdi/userdata"
+	// UserDataFileName is the name of the first-boot script inside the UserDataMountPath
+	UserDataFileName = "user-data"
+	// UserDataRunAsFileName is the name of the file inside the UserDataMountPath containing
+	// the user to run the first-boot script as
+	UserDataRunAsFileName = "run-as"
 	// WebPort is the port that web services will listen on internally
 	WebPort = 8443
 	// PublicWebPort is the port for the app service
 	PublicWebPort = 443
 	// DesktopRunDir is the dir mounted for internal runtime files
 	DesktopRunDir = "/var/run/kvdi"
+	// KeyboardLayoutPath is where the kvdi-proxy writes the keyboard layout requested by
+	// the connected client, for the desktop to apply to its display.
+	KeyboardLayoutPath = "/var/run/kvdi/keyboard-layout"
 	// DefaultDisplaySocketAddr is the default path used for the display unix socket
 	DefaultDisplaySocketAddr = "unix:///var/run/kvdi/display.sock"
+	// DefaultSmartCardSocketAddr is the path used for the pcscd bridge unix socket
+	DefaultSmartCardSocketAddr = "unix:///run/kvdi-smartcard.sock"
+	// DefaultRDPAddr is the default address of the RDP server for desktops served over RDP
+	DefaultRDPAddr = "localhost:3389"
+	// DefaultGuacdAddr is the address guacd listens on inside desktop pods served over RDP
+	DefaultGuacdAddr = "127.0.0.1:4822"
+	// DefaultGuacdImage is the default image used for the guacd sidecar
+	DefaultGuacdImage = "guacamole/guacd:1.3.0"
 	// DefaultNamespace is the default namespace to provision resources in
 	DefaultNamespace = "default"
 	// DefaultSessionLength is the session length used for setting expiry
 	// times on new user sessions.
 	DefaultSessionLength = time.Duration(15) * time.Minute
+	// DefaultDrainTimeout is how long app instances wait for websocket connections
+	// to close when shutting down.
+	DefaultDrainTimeout = time.Duration(2) * time.Minute
 	// CACertKey is the key where the CA certificate is placed in TLS secrets.
 	CACertKey = "ca.crt"
 	// UserEnvVar is the environment variable used to set the username during a desktop's init
@@ -65,6 +224,12 @@ const (
 	// VNCSockEnvVar is the environment variable used to set the VNC socket during the init
 	// process.
 	VNCSockEnvVar = "VNC_SOCK_ADDR"
+	// SmartCardSockEnvVar is the environment variable used to set the pcscd bridge socket
+	// during the init process.
+	SmartCardSockEnvVar = "SMARTCARD_SOCK_ADDR"
+	// PrintSpoolEnvVar is the environment variable used to set the directory print jobs
+	// are written to during the init process.
+	PrintSpoolEnvVar = "PRINT_SPOOL_DIR"
 )
 
 // NamespaceAll represents all namespaces
@@ -81,9 +246,20 @@ const (
 	// ResourceRoles represents the auth roles in kVDI. This would allow a user
 	// to manipulate policies via the app API.
 	ResourceRoles Resource = "roles"
+	// ResourceGroups represents the user groups in kVDI. Like users, this only
+	// applies when using local auth.
+	ResourceGroups Resource = "groups"
 	// ResourceTeemplates represents desktop templates in kVDI. Mainly the ability
 	// to launch seessions from them and connect to them.
 	ResourceTemplates Resource = "templates"
+	// ResourceRecordings represents recordings of desktop sessions. Patterns are
+	// matched against the template the recorded desktop was booted from.
+	ResourceRecordings Resource = "recordings"
+	// ResourceReports represents usage reports for the cluster.
+	ResourceReports Resource = "reports"
+	// ResourceAnnouncements represents the announcements shown to users. All users
+	// can read the announcements shown to them without a rule allowing it.
+	ResourceAnnouncements Resource = "announcements"
 	// ResourceAll matches all resources
 	ResourceAll Resource = "*"
 )
@@ -105,22 +281,60 @@ const (
 	VerbUse Verb = "use"
 	// Launch operations
 	VerbLaunch Verb = "launch"
+	// View operations, these allow watching a desktop display without sending input
+	VerbView Verb = "view"
+	// Shadow operations, these allow attaching to another user's desktop display.
+	// Input is only allowed if the user can also use the desktop's template.
+	VerbShadow Verb = "shadow"
+	// Downloading files from a desktop session
+	VerbFileDownload Verb = "file-download"
+	// Uploading files to a desktop session
+	VerbFileUpload Verb = "file-upload"
+	// Copying clipboard contents from the client into a desktop session
+	VerbClipboardIn Verb = "clipboard-in"
+	// Copying clipboard contents from a desktop session out to the client
+	VerbClipboardOut Verb = "clipboard-out"
+	// Impersonate operations, these allow acting as another user with a short-lived
+	// token carrying their roles
+	VerbImpersonate Verb = "impersonate"
+	// Setting environment variables in desktop sessions when launching them. The
+	// variables must also be allowed by the template.
+	VerbSetEnv Verb = "set-env"
 	// VerbAll matches all actions
 	VerbAll Verb = "*"
 )
 
+// dlpVerbs are the data loss prevention verbs. Roles that do not name any of them
+// are treated as granting them along with `use`.
+var dlpVerbs = []Verb{VerbFileDownload, VerbFileUpload, VerbClipboardIn, VerbClipboardOut}
+
+// IsDLPVerb returns true if the given verb is one of the data loss prevention verbs.
+func IsDLPVerb(verb Verb) bool {
+	for _, dlp := range dlpVerbs {
+		if verb == dlp {
+			return true
+		}
+	}
+	return false
+}
+
 // Desktop runtime mount paths
 const (
 	HostShmPath    = "/dev/shm"
 	HostCgroupPath = "/sys/fs/cgroup"
+	HostUSBPath    = "/dev/bus/usb"
 
 	DesktopTmpPath     = "/tmp"
 	DesktopRunPath     = "/run"
 	DesktopRunLockPath = "/run/lock"
 	DesktopShmPath     = "/dev/shm"
 	DesktopCgroupPath  = "/sys/fs/cgroup"
+	DesktopUSBPath     = "/dev/bus/usb"
 	DesktopHomeFmt     = "/home/%s"
 	DesktopHomeMntPath = "/mnt/home"
+
+	DesktopRecordingsMntPath = "/var/lib/kvdi/recordings"
+	DesktopPrintSpoolPath    = "/var/spool/kvdi-print"
 )
 
 // Other defaults that we need to the address of
@@ -0,0 +1,67 @@
+package v1
+
+import "errors"
+
+// MaxDisplayQuality is the highest quality level of a display stream.
+const MaxDisplayQuality = 9
+
+// DisplaySettings are the adjustable limits on the display stream of a desktop
+// session. Clients connected to the settings websocket of a desktop session send
+// them as text frames to change the limits while connected to the display.
+// +k8s:deepcopy-gen=false
+type DisplaySettings struct {
+	// The highest JPEG quality level, from 0 (lowest) to 9 (highest), the display is
+	// encoded with. This only applies to clients using the Tight encoding. When unset,
+	// the quality requested by the client is used.
+	Quality *int32 `json:"quality,omitempty"`
+	// The most frames per second sent to the client. Unlimited when zero.
+	FrameRate int32 `json:"frameRate,omitempty"`
+	// The most bytes per second sent to the client. Unlimited when zero.
+	Bandwidth int64 `json:"bandwidth,omitempty"`
+}
+
+// Validate the DisplaySettings
+func (d *DisplaySettings) Validate() error {
+	if d.Quality != nil && (*d.Quality < 0 || *d.Quality > MaxDisplayQuality) {
+		return errors.New("Quality must be between 0 and 9")
+	}
+	if d.FrameRate < 0 {
+		return errors.New("The frame rate cannot be negative")
+	}
+	if d.Bandwidth < 0 {
+		return errors.New("The bandwidth cannot be negative")
+	}
+	return nil
+}
+
+// WithinLimits returns a copy of the settings with each of them lowered to the
+// given limits, where they are lower.
+func (d *DisplaySettings) WithinLimits(limits *DisplaySettings) *DisplaySettings {
+	out := &DisplaySettings{
+		Quality:   d.Quality,
+		FrameRate: d.FrameRate,
+		Bandwidth: d.Bandwidth,
+	}
+	if limits.Quality != nil && (out.Quality == nil || *out.Quality > *limits.Quality) {
+		out.Quality = limits.Quality
+	}
+	if limits.FrameRate > 0 && (out.FrameRate == 0 || out.FrameRate > limits.FrameRate) {
+		out.FrameRate = limits.FrameRate
+	}
+	if limits.Bandwidth > 0 && (out.Bandwidth == 0 || out.Bandwidth > limits.Bandwidth) {
+		out.Bandwidth = limits.Bandwidth
+	}
+	return out
+}
+
+// DisplaySettingsStatus is sent to clients connected to the settings websocket of
+// a desktop session when they connect, and after each change they send.
+// +k8s:deepcopy-gen=false
+type DisplaySettingsStatus struct {
+	// The settings applied to the display stream, after the limits of the template
+	Settings *DisplaySettings `json:"settings"`
+	// The limits set by the template, which settings cannot exceed
+	Limits *DisplaySettings `json:"limits"`
+	// Why the last change sent by the client was refused, if it was
+	Error string `json:"error,omitempty"`
+}
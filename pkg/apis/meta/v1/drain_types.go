@@ -0,0 +1,71 @@
+package v1
+
+import "time"
+
+// DrainAction is the action to take on desktops running on a node being drained.
+type DrainAction string
+
+const (
+	// DrainActionMigrate relaunches desktops on another node.
+	DrainActionMigrate DrainAction = "migrate"
+	// DrainActionTerminate destroys desktops.
+	DrainActionTerminate DrainAction = "terminate"
+)
+
+// DrainState represents the progress of a desktop on a node being drained.
+type DrainState string
+
+const (
+	// DrainStatePending means the desktop is still running on the node and its
+	// user has been notified.
+	DrainStatePending DrainState = "Pending"
+	// DrainStateMigrating means the desktop is being relaunched on another node.
+	DrainStateMigrating DrainState = "Migrating"
+	// DrainStateMigrated means the desktop is running on another node.
+	DrainStateMigrated DrainState = "Migrated"
+	// DrainStateTerminating means the desktop is being destroyed.
+	DrainStateTerminating DrainState = "Terminating"
+)
+
+// DrainNotice is serialized to the DrainAnnotation on desktops running on a node
+// being drained.
+// +k8s:deepcopy-gen=false
+type DrainNotice struct {
+	// The node being drained
+	Node string `json:"node"`
+	// What will happen to the desktop at the deadline
+	Action DrainAction `json:"action"`
+	// When the desktop will be migrated or terminated
+	Deadline time.Time `json:"deadline"`
+	// A message for the user of the desktop
+	Message string `json:"message,omitempty"`
+}
+
+// NodeDrainStatus reports the progress of draining desktops from a node.
+// +k8s:deepcopy-gen=false
+type NodeDrainStatus struct {
+	// The node being drained
+	Node string `json:"node"`
+	// The desktops that were running on the node when the drain started
+	Desktops []*DesktopDrainStatus `json:"desktops"`
+	// Whether all desktops have been migrated or terminated
+	Complete bool `json:"complete"`
+}
+
+// DesktopDrainStatus reports the progress of a single desktop on a node being
+// drained.
+// +k8s:deepcopy-gen=false
+type DesktopDrainStatus struct {
+	// The name of the desktop
+	Name string `json:"name"`
+	// The namespace of the desktop
+	Namespace string `json:"namespace"`
+	// The user of the desktop
+	User string `json:"user"`
+	// What will happen to the desktop at the deadline
+	Action DrainAction `json:"action"`
+	// When the desktop will be migrated or terminated
+	Deadline time.Time `json:"deadline"`
+	// The current state of the desktop
+	State DrainState `json:"state"`
+}
@@ -0,0 +1,43 @@
+package v1
+
+// SessionEstimate contains the estimated resource footprint and hourly cost of
+// launching a session from a DesktopTemplate.
+// +k8s:deepcopy-gen=false
+type SessionEstimate struct {
+	// The template the estimate is for
+	Template string `json:"template"`
+	// The namespace the session would be launched in
+	Namespace string `json:"namespace"`
+	// The resources the desktop would request, with any namespace defaults applied
+	Requests map[string]string `json:"requests,omitempty"`
+	// The resource limits of the desktop, capped to any namespace maximums
+	Limits map[string]string `json:"limits,omitempty"`
+	// The CPU cores requested for the desktop
+	CPU float64 `json:"cpu"`
+	// The memory in GiB requested for the desktop
+	MemoryGiB float64 `json:"memoryGiB"`
+	// The GPUs requested for the desktop
+	GPUs float64 `json:"gpus"`
+	// The currency costs are in, empty if no prices are configured
+	Currency string `json:"currency,omitempty"`
+	// The estimated cost of running the session for an hour, zero if no prices are
+	// configured
+	CostPerHour float64 `json:"costPerHour"`
+	// The hourly cost of each priced resource
+	Breakdown []*SessionCostItem `json:"breakdown,omitempty"`
+}
+
+// SessionCostItem contains the estimated hourly cost of a single resource in a
+// session.
+// +k8s:deepcopy-gen=false
+type SessionCostItem struct {
+	// The name of the resource
+	Resource string `json:"resource"`
+	// The amount of the resource requested, in cores for CPU, GiB for memory, and
+	// units for anything else
+	Quantity float64 `json:"quantity"`
+	// The configured price of a single unit of the resource per hour
+	PricePerHour float64 `json:"pricePerHour"`
+	// The price multiplied by the quantity
+	CostPerHour float64 `json:"costPerHour"`
+}
@@ -0,0 +1,19 @@
+package v1
+
+// PermissionEvaluation contains the result of evaluating whether a user or role is
+// allowed to perform an action.
+// +k8s:deepcopy-gen=false
+type PermissionEvaluation struct {
+	// The action that was evaluated
+	Action *APIAction `json:"action"`
+	// Whether the action is allowed
+	Allowed bool `json:"allowed"`
+	// The role containing the rule that allowed the action
+	Role string `json:"role,omitempty"`
+	// The first rule that allowed the action
+	Rule *Rule `json:"rule,omitempty"`
+	// The roles that were evaluated
+	EvaluatedRoles []string `json:"evaluatedRoles"`
+	// Why the action is denied
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,67 @@
+package v1
+
+// EventType represents the type of an event sent over the event stream.
+type EventType string
+
+const (
+	// EventSessionCreated is sent when a desktop session is created.
+	EventSessionCreated EventType = "session.created"
+	// EventSessionReady is sent when a desktop session is running and ready for connections.
+	EventSessionReady EventType = "session.ready"
+	// EventSessionPhase is sent when a desktop session progresses towards serving
+	// display connections, e.g. from pulling its image to booting its display.
+	EventSessionPhase EventType = "session.phase"
+	// EventSessionDeleted is sent when a desktop session is deleted.
+	EventSessionDeleted EventType = "session.deleted"
+	// EventSessionDraining is sent when the node a desktop session is running on
+	// starts being drained.
+	EventSessionDraining EventType = "session.draining"
+	// EventUserLogin is sent when a user is fully authenticated.
+	EventUserLogin EventType = "user.login"
+	// EventRoleCreated is sent when a role is created.
+	EventRoleCreated EventType = "role.created"
+	// EventRoleUpdated is sent when the rules or bindings on a role change.
+	EventRoleUpdated EventType = "role.updated"
+	// EventRoleDeleted is sent when a role is deleted.
+	EventRoleDeleted EventType = "role.deleted"
+	// EventAnnouncementPublished is sent when an announcement starts being shown,
+	// or changes while it is shown.
+	EventAnnouncementPublished EventType = "announcement.published"
+	// EventAnnouncementRemoved is sent when an announcement stops being shown.
+	EventAnnouncementRemoved EventType = "announcement.removed"
+)
+
+// Event is a message sent over the event stream. Only the field matching the
+// type of the event is populated.
+// +k8s:deepcopy-gen=false
+type Event struct {
+	// The type of the event
+	Type EventType `json:"type"`
+	// A unix timestamp of when the event happened
+	Time int64 `json:"time"`
+	// The desktop session for session events
+	Session *EventSession `json:"session,omitempty"`
+	// The drain notice for session draining events
+	Drain *DrainNotice `json:"drain,omitempty"`
+	// The user for login events
+	User string `json:"user,omitempty"`
+	// The role for role events
+	Role string `json:"role,omitempty"`
+	// The announcement for announcement events
+	Announcement *Announcement `json:"announcement,omitempty"`
+}
+
+// EventSession contains information about the desktop session an event is for.
+// +k8s:deepcopy-gen=false
+type EventSession struct {
+	// The namespace of the desktop
+	Namespace string `json:"namespace"`
+	// The name of the desktop
+	Name string `json:"name"`
+	// The template the desktop was launched from
+	Template string `json:"template"`
+	// The user the desktop belongs to, empty for unclaimed pooled desktops
+	User string `json:"user,omitempty"`
+	// How far the desktop has progressed towards serving display connections
+	Phase string `json:"phase,omitempty"`
+}
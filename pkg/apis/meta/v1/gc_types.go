@@ -0,0 +1,34 @@
+package v1
+
+import "time"
+
+// GCReportKey is the key in the report configmap where the last GCReport is stored.
+const GCReportKey = "report"
+
+// OrphanedResource represents a resource whose owning desktop or user no longer
+// exists.
+type OrphanedResource struct {
+	// The kind of the resource
+	Kind string `json:"kind"`
+	// The name of the resource
+	Name string `json:"name"`
+	// The namespace of the resource, if any
+	Namespace string `json:"namespace,omitempty"`
+	// Why the resource was considered orphaned
+	Reason string `json:"reason"`
+	// Whether the resource was deleted
+	Deleted bool `json:"deleted"`
+}
+
+// GCReport contains the results of a scan for orphaned resources.
+// +k8s:deepcopy-gen=false
+type GCReport struct {
+	// When the scan took place
+	Timestamp time.Time `json:"timestamp"`
+	// Whether orphaned resources were only reported
+	DryRun bool `json:"dryRun"`
+	// The orphaned resources that were found
+	Resources []*OrphanedResource `json:"resources"`
+	// Any errors encountered during the scan or cleanup
+	Errors []string `json:"errors,omitempty"`
+}
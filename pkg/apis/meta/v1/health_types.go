@@ -0,0 +1,50 @@
+package v1
+
+// HealthStatus is the status of the app or one of the components it depends on.
+type HealthStatus string
+
+const (
+	// HealthStatusOK means the component is reachable and able to serve requests.
+	HealthStatusOK HealthStatus = "ok"
+	// HealthStatusFailed means the component is not reachable or returned an error.
+	HealthStatusFailed HealthStatus = "failed"
+)
+
+// Components checked by the health and readiness endpoints.
+const (
+	// HealthComponentKubernetes is the Kubernetes API.
+	HealthComponentKubernetes = "kubernetes"
+	// HealthComponentSecrets is the secrets backend configured for the cluster.
+	HealthComponentSecrets = "secrets"
+	// HealthComponentAuth is the authentication provider configured for the cluster.
+	HealthComponentAuth = "auth"
+	// HealthComponentMFA is the storage for MFA secrets.
+	HealthComponentMFA = "mfa"
+)
+
+// HealthReport is returned by the health and readiness endpoints.
+// +k8s:deepcopy-gen=false
+type HealthReport struct {
+	// The overall status, ok only if every component is ok
+	Status HealthStatus `json:"status"`
+	// The status of each component
+	Components []*ComponentHealth `json:"components"`
+}
+
+// ComponentHealth is the status of a single component the app depends on.
+// +k8s:deepcopy-gen=false
+type ComponentHealth struct {
+	// The name of the component
+	Name string `json:"name"`
+	// The status of the component
+	Status HealthStatus `json:"status"`
+	// The backend or provider in use, e.g. vault or ldap
+	Backend string `json:"backend,omitempty"`
+	// How long the check took in milliseconds
+	LatencyMillis int64 `json:"latencyMillis"`
+	// The error returned by the check when it failed
+	Error string `json:"error,omitempty"`
+}
+
+// Healthy returns true if every component in the report is ok.
+func (h *HealthReport) Healthy() bool { return h.Status == HealthStatusOK }
@@ -0,0 +1,62 @@
+package v1
+
+import "encoding/json"
+
+// JobType represents the operation performed by a job.
+type JobType string
+
+const (
+	// JobTypeTemplateImport is an import of a bundle of DesktopTemplates and VDIRoles.
+	JobTypeTemplateImport JobType = "template-import"
+	// JobTypeUserImport is a bulk import of users.
+	JobTypeUserImport JobType = "user-import"
+	// JobTypeSessionSnapshot is a snapshot of the userdata volume of a desktop session.
+	// It completes once the snapshot is ready to use.
+	JobTypeSessionSnapshot JobType = "session-snapshot"
+	// JobTypeRecordingExport is an upload of the finished recordings in a desktop
+	// session to the recordings bucket.
+	JobTypeRecordingExport JobType = "recording-export"
+)
+
+// JobState represents the state of a job.
+type JobState string
+
+const (
+	// JobRunning means the job is still in progress.
+	JobRunning JobState = "running"
+	// JobSucceeded means the job completed successfully.
+	JobSucceeded JobState = "succeeded"
+	// JobFailed means the job completed with an error, or was interrupted.
+	JobFailed JobState = "failed"
+)
+
+// Job represents a long-running operation started by a user. Jobs are returned by
+// requests made with `async=true` and can be polled at `/api/jobs/{job}`.
+// +k8s:deepcopy-gen=false
+type Job struct {
+	// The ID of the job
+	ID string `json:"id"`
+	// The operation performed by the job
+	Type JobType `json:"type"`
+	// The user who started the job
+	User string `json:"user"`
+	// The state of the job
+	State JobState `json:"state"`
+	// The percentage of the job that has completed
+	Progress int `json:"progress"`
+	// A description of what the job is currently doing
+	Message string `json:"message,omitempty"`
+	// The error the job failed with
+	Error string `json:"error,omitempty"`
+	// The result of the job, the same as the response to a synchronous request
+	Result json.RawMessage `json:"result,omitempty"`
+	// A unix timestamp of when the job was started
+	CreatedAt int64 `json:"createdAt"`
+	// A unix timestamp of when the job last reported progress
+	UpdatedAt int64 `json:"updatedAt"`
+	// A unix timestamp of when the job completed
+	CompletedAt int64 `json:"completedAt,omitempty"`
+}
+
+// Done returns true if the job has completed.
+func (j *Job) Done() bool { return j.State != JobRunning }
@@ -0,0 +1,51 @@
+package v1
+
+// JWTSigningKey is a key for signing JWTs, as stored in the secrets backend. Tokens
+// signed with it carry its ID in the `kid` header.
+// +k8s:deepcopy-gen=false
+type JWTSigningKey struct {
+	// The ID of the key
+	ID string `json:"kid"`
+	// The algorithm the key signs with, one of HS256, RS256, or ES256
+	Algorithm string `json:"alg"`
+	// The HMAC secret, or the PEM encoded private key
+	Key []byte `json:"key"`
+	// A unix timestamp of when the key was created
+	CreatedAt int64 `json:"createdAt"`
+	// A unix timestamp of when the key stopped signing new tokens. Retired keys
+	// keep verifying tokens until they are removed.
+	RetiredAt int64 `json:"retiredAt,omitempty"`
+}
+
+// IsRetired returns true if the key no longer signs new tokens.
+func (k *JWTSigningKey) IsRetired() bool { return k.RetiredAt != 0 }
+
+// JSONWebKey is the public part of a JWT signing key in JWK format.
+// +k8s:deepcopy-gen=false
+type JSONWebKey struct {
+	// The type of the key, RSA or EC
+	KeyType string `json:"kty"`
+	// The ID of the key
+	KeyID string `json:"kid"`
+	// The algorithm the key signs with
+	Algorithm string `json:"alg"`
+	// What the key is used for, always sig
+	Use string `json:"use"`
+	// The modulus of an RSA key
+	N string `json:"n,omitempty"`
+	// The exponent of an RSA key
+	E string `json:"e,omitempty"`
+	// The curve of an EC key
+	Curve string `json:"crv,omitempty"`
+	// The x coordinate of an EC key
+	X string `json:"x,omitempty"`
+	// The y coordinate of an EC key
+	Y string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet contains the public keys for verifying JWTs issued by kVDI.
+// +k8s:deepcopy-gen=false
+type JSONWebKeySet struct {
+	// The public keys
+	Keys []JSONWebKey `json:"keys"`
+}
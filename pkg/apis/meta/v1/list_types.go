@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query parameters for paginating, filtering, and sorting list responses.
+const (
+	// ListLimitParam is the maximum number of items to return.
+	ListLimitParam = "limit"
+	// ListContinueParam is the token returned with the previous page of results.
+	ListContinueParam = "continue"
+	// ListSortByParam is the field to sort items by. Defaults to `name`.
+	ListSortByParam = "sortBy"
+	// ListOrderParam is the order to sort items in, either `asc` or `desc`.
+	ListOrderParam = "order"
+	// ListFilterParam is a filter in the format of `<field>=<value>`. Only items
+	// where the field contains the value, ignoring case, are returned. It can be
+	// provided multiple times.
+	ListFilterParam = "filter"
+)
+
+// ListOptions represents options for paginating, filtering, and sorting a list.
+// +k8s:deepcopy-gen=false
+type ListOptions struct {
+	// The maximum number of items to return. Zero means no limit.
+	Limit int
+	// The token returned with the previous page of results.
+	Continue string
+	// The field to sort by.
+	SortBy string
+	// Set to true to sort in descending order.
+	Descending bool
+	// Filters to apply to the list, keyed by field.
+	Filters map[string]string
+}
+
+// ParseListOptions parses list options from the given query parameters.
+func ParseListOptions(query url.Values) (*ListOptions, error) {
+	opts := &ListOptions{
+		Continue: query.Get(ListContinueParam),
+		SortBy:   query.Get(ListSortByParam),
+		Filters:  make(map[string]string),
+	}
+	if limit := query.Get(ListLimitParam); limit != "" {
+		var err error
+		opts.Limit, err = strconv.Atoi(limit)
+		if err != nil || opts.Limit < 0 {
+			return nil, fmt.Errorf("Invalid limit '%s'", limit)
+		}
+	}
+	switch order := query.Get(ListOrderParam); order {
+	case "", "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		return nil, fmt.Errorf("Invalid order '%s', must be 'asc' or 'desc'", order)
+	}
+	for _, filter := range query[ListFilterParam] {
+		spl := strings.SplitN(filter, "=", 2)
+		if len(spl) != 2 || spl[0] == "" {
+			return nil, fmt.Errorf("Invalid filter '%s', must be in the format of <field>=<value>", filter)
+		}
+		opts.Filters[spl[0]] = spl[1]
+	}
+	return opts, nil
+}
+
+// Encode returns the query parameters for these options.
+func (o *ListOptions) Encode() url.Values {
+	query := url.Values{}
+	if o.Limit > 0 {
+		query.Set(ListLimitParam, strconv.Itoa(o.Limit))
+	}
+	if o.Continue != "" {
+		query.Set(ListContinueParam, o.Continue)
+	}
+	if o.SortBy != "" {
+		query.Set(ListSortByParam, o.SortBy)
+	}
+	if o.Descending {
+		query.Set(ListOrderParam, "desc")
+	}
+	for field, value := range o.Filters {
+		query.Add(ListFilterParam, fmt.Sprintf("%s=%s", field, value))
+	}
+	return query
+}
+
+// Paginated returns true if a page of results was requested, as opposed to the
+// full list.
+func (o *ListOptions) Paginated() bool {
+	return o.Limit > 0 || o.Continue != ""
+}
+
+// ListFields maps the names of the fields a list can be filtered and sorted on
+// to functions returning the value of the field for the item at an index.
+// +k8s:deepcopy-gen=false
+type ListFields map[string]func(i int) string
+
+// Apply filters and sorts a list with the given length and fields. The indices
+// of the items to return are returned in order, along with the metadata for the
+// response.
+func (o *ListOptions) Apply(length int, fields ListFields) ([]int, *ListMeta, error) {
+	for field := range o.Filters {
+		if _, ok := fields[field]; !ok {
+			return nil, nil, fmt.Errorf("Cannot filter on field '%s'", field)
+		}
+	}
+	sortBy := o.SortBy
+	if sortBy == "" {
+		sortBy = "name"
+	}
+	sortFunc, ok := fields[sortBy]
+	if !ok {
+		return nil, nil, fmt.Errorf("Cannot sort on field '%s'", sortBy)
+	}
+
+	indices := make([]int, 0, length)
+ItemLoop:
+	for i := 0; i < length; i++ {
+		for field, value := range o.Filters {
+			if !strings.Contains(strings.ToLower(fields[field](i)), strings.ToLower(value)) {
+				continue ItemLoop
+			}
+		}
+		indices = append(indices, i)
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		if o.Descending {
+			return sortFunc(indices[i]) > sortFunc(indices[j])
+		}
+		return sortFunc(indices[i]) < sortFunc(indices[j])
+	})
+
+	meta := &ListMeta{Total: len(indices)}
+	offset, err := decodeContinueToken(o.Continue)
+	if err != nil {
+		return nil, nil, err
+	}
+	if offset > len(indices) {
+		offset = len(indices)
+	}
+	indices = indices[offset:]
+	if o.Limit > 0 && len(indices) > o.Limit {
+		indices = indices[:o.Limit]
+		meta.Continue = encodeContinueToken(offset + o.Limit)
+	}
+	return indices, meta, nil
+}
+
+func encodeContinueToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeContinueToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, errors.New("Invalid continue token")
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, errors.New("Invalid continue token")
+	}
+	return offset, nil
+}
+
+// ListMeta contains information about a page of results in a list response.
+type ListMeta struct {
+	// The total number of items matching the filters.
+	Total int `json:"total"`
+	// The token to pass in the `continue` parameter to retrieve the next page.
+	// Empty when there are no more results.
+	Continue string `json:"continue,omitempty"`
+}
+
+// ListResponse is returned from list endpoints when a page of results is requested.
+// +k8s:deepcopy-gen=false
+type ListResponse struct {
+	// Information about the page of results.
+	Metadata *ListMeta `json:"metadata"`
+	// The items in the page.
+	Items interface{} `json:"items"`
+}
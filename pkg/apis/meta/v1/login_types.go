@@ -0,0 +1,43 @@
+package v1
+
+// LoginMFAResult represents the outcome of multi-factor authentication for a login
+// attempt.
+type LoginMFAResult string
+
+const (
+	// LoginMFANotRequired means the user logged in without MFA.
+	LoginMFANotRequired LoginMFAResult = "not-required"
+	// LoginMFARequired means the user's credentials were accepted, and they were
+	// asked to authorize the login with MFA.
+	LoginMFARequired LoginMFAResult = "required"
+	// LoginMFAPassed means the user authorized the login with MFA.
+	LoginMFAPassed LoginMFAResult = "passed"
+	// LoginMFAFailed means the user failed to authorize the login with MFA.
+	LoginMFAFailed LoginMFAResult = "failed"
+)
+
+// LoginAttempt is a record of a single login or MFA authorization attempt.
+// +k8s:deepcopy-gen=false
+type LoginAttempt struct {
+	// A unix timestamp of when the attempt was made
+	Time int64 `json:"time"`
+	// The username the attempt was made for
+	User string `json:"user"`
+	// The authentication provider that handled the attempt
+	Provider string `json:"provider"`
+	// The address of the client that made the attempt
+	ClientAddr string `json:"clientAddr"`
+	// The user agent of the client that made the attempt
+	UserAgent string `json:"userAgent,omitempty"`
+	// Whether the attempt succeeded
+	Success bool `json:"success"`
+	// The outcome of MFA for the attempt, empty when the credentials were rejected
+	MFA LoginMFAResult `json:"mfa,omitempty"`
+}
+
+// LoginHistory contains the login attempts matching a query, newest first.
+// +k8s:deepcopy-gen=false
+type LoginHistory struct {
+	// The login attempts
+	Attempts []*LoginAttempt `json:"attempts"`
+}
@@ -0,0 +1,147 @@
+package v1
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// SessionPlacement constrains where the desktop sessions of a role's members run,
+// and how much CPU and memory they may consume across all of their sessions.
+type SessionPlacement struct {
+	// The namespaces members of the role may launch sessions in. When empty, any
+	// namespace allowed by the role's rules may be used.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Node labels the sessions of members of the role are scheduled with, e.g. to
+	// fence a team onto its own node pool.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// The total CPU members of the role may consume across their sessions, e.g. `8`.
+	// The CPU limit of each session is counted, or its request if it has no limit.
+	MaxCPU string `json:"maxCPU,omitempty"`
+	// The total memory members of the role may consume across their sessions, e.g.
+	// `32Gi`. The memory limit of each session is counted, or its request if it has
+	// no limit.
+	MaxMemory string `json:"maxMemory,omitempty"`
+}
+
+// Validate returns an error if either of the resource quotas cannot be parsed.
+func (p *SessionPlacement) Validate() error {
+	for name, quantity := range map[string]string{"CPU": p.MaxCPU, "memory": p.MaxMemory} {
+		if quantity == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(quantity)
+		if err != nil {
+			return fmt.Errorf("%s is an invalid %s quota: %s", quantity, name, err.Error())
+		}
+		if q.Sign() <= 0 {
+			return fmt.Errorf("The %s quota must be greater than zero, got %s", name, quantity)
+		}
+	}
+	return nil
+}
+
+// GetResourceLimits returns the CPU and memory quotas that are set. Quotas that
+// cannot be parsed are ignored.
+func (p *SessionPlacement) GetResourceLimits() corev1.ResourceList {
+	limits := corev1.ResourceList{}
+	for name, quantity := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    p.MaxCPU,
+		corev1.ResourceMemory: p.MaxMemory,
+	} {
+		if quantity == "" {
+			continue
+		}
+		if q, err := resource.ParseQuantity(quantity); err == nil {
+			limits[name] = q
+		}
+	}
+	return limits
+}
+
+// HasSessionPlacement returns true if any of the user's roles constrain the
+// placement or size of their sessions.
+func (u *VDIUser) HasSessionPlacement() bool {
+	for _, role := range u.Roles {
+		if role.Placement != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsSessionNamespace returns true if every role of the user that restricts
+// namespaces allows sessions in the given one.
+func (u *VDIUser) AllowsSessionNamespace(namespace string) bool {
+	for _, role := range u.Roles {
+		if role.Placement == nil || len(role.Placement.Namespaces) == 0 {
+			continue
+		}
+		var allowed bool
+		for _, ns := range role.Placement.Namespaces {
+			if ns == namespace {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// GetSessionNodeSelector returns the node labels required by all of the user's
+// roles. An error is returned if two roles require different values for the same
+// label, since a session could not be scheduled anywhere.
+func (u *VDIUser) GetSessionNodeSelector() (map[string]string, error) {
+	var selector map[string]string
+	for _, role := range u.Roles {
+		if role.Placement == nil {
+			continue
+		}
+		for key, val := range role.Placement.NodeSelector {
+			if selector == nil {
+				selector = make(map[string]string)
+			}
+			if existing, ok := selector[key]; ok && existing != val {
+				return nil, fmt.Errorf("Roles require conflicting values for node label %s: %s and %s", key, existing, val)
+			}
+			selector[key] = val
+		}
+	}
+	return selector, nil
+}
+
+// GetSessionResourceLimits returns the total CPU and memory the user's sessions may
+// consume. The lowest quota configured across the user's roles is used for each.
+func (u *VDIUser) GetSessionResourceLimits() corev1.ResourceList {
+	limits := corev1.ResourceList{}
+	for _, role := range u.Roles {
+		if role.Placement == nil {
+			continue
+		}
+		for name, quantity := range role.Placement.GetResourceLimits() {
+			if existing, ok := limits[name]; !ok || quantity.Cmp(existing) < 0 {
+				limits[name] = quantity
+			}
+		}
+	}
+	return limits
+}
+
+// GetExceededSessionResource returns the name of the first resource in the given
+// usage that is over the user's quotas, or an empty string if none are.
+func (u *VDIUser) GetExceededSessionResource(usage corev1.ResourceList) corev1.ResourceName {
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		limit, ok := u.GetSessionResourceLimits()[name]
+		if !ok {
+			continue
+		}
+		if used, ok := usage[name]; ok && used.Cmp(limit) > 0 {
+			return name
+		}
+	}
+	return ""
+}
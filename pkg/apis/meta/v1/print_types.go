@@ -0,0 +1,15 @@
+package v1
+
+// MaxPrintJobSize is the largest PDF that will be delivered to clients. Larger
+// print jobs are discarded.
+const MaxPrintJobSize = 64 * 1024 * 1024
+
+// PrintJob is sent to clients connected to the printer websocket of a desktop
+// session as a text frame ahead of each print job. The contents of the rendered
+// PDF follow in a single binary frame.
+type PrintJob struct {
+	// The name of the PDF, usually derived from the title of the document.
+	Name string `json:"name"`
+	// The size of the PDF in bytes.
+	Size int64 `json:"size"`
+}
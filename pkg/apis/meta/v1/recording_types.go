@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recordingIDRegex matches the IDs of session recordings. IDs are made up of the
+// unix time the recording started, the name of the desktop, and the user who
+// was connected, separated by dots.
+var recordingIDRegex = regexp.MustCompile(`^([0-9]+)\.([a-z0-9-]+)\.([^/]+)$`)
+
+// Recording represents a recording of a desktop display session.
+type Recording struct {
+	// The ID of the recording
+	ID string `json:"id"`
+	// The namespace the recorded desktop ran in
+	Namespace string `json:"namespace"`
+	// The template the recorded desktop was booted from
+	Template string `json:"template"`
+	// The name of the recorded desktop
+	Desktop string `json:"desktop"`
+	// The user who was connected to the display
+	User string `json:"user"`
+	// When the recording started, as a unix timestamp
+	StartedAt int64 `json:"startedAt"`
+	// The size of the recording in bytes
+	Size int64 `json:"size"`
+}
+
+// NewRecordingID returns the ID for a recording of the given desktop by the given
+// user, starting at the given time.
+func NewRecordingID(desktop, user string, startedAt time.Time) string {
+	return fmt.Sprintf("%d.%s.%s", startedAt.Unix(), desktop, strings.Replace(user, "/", "_", -1))
+}
+
+// ParseRecordingID parses the desktop, user, and start time from the given recording
+// ID. An error is returned if the ID is malformed.
+func ParseRecordingID(id string) (desktop, user string, startedAt int64, err error) {
+	matches := recordingIDRegex.FindStringSubmatch(id)
+	if matches == nil || matches[3] == "." || matches[3] == ".." {
+		return "", "", 0, fmt.Errorf("Invalid recording ID '%s'", id)
+	}
+	startedAt, err = strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("Invalid recording ID '%s'", id)
+	}
+	return matches[2], matches[3], startedAt, nil
+}
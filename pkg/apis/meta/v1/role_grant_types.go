@@ -0,0 +1,20 @@
+package v1
+
+// RoleGrant temporarily binds a VDIRole to a user. The role is added to the user's
+// claims when they authenticate until the grant expires.
+// +k8s:deepcopy-gen=false
+type RoleGrant struct {
+	// The user the role was granted to
+	User string `json:"user"`
+	// The user that created the grant
+	CreatedBy string `json:"createdBy"`
+	// A unix timestamp of when the grant expires
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// RoleGrantsResponse contains the active grants for a role.
+// +k8s:deepcopy-gen=false
+type RoleGrantsResponse struct {
+	// The grants that have not expired
+	Grants []*RoleGrant `json:"grants"`
+}
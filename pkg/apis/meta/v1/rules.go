@@ -9,7 +9,10 @@ import (
 // an rbacv1.PolicyRule, with resources being a regex and the addition of a
 // namespace selector.
 type Rule struct {
-	// The actions this rule applies for. VerbAll matches all actions.
+	// The actions this rule applies for. VerbAll matches all actions. Roles that
+	// do not name any of `file-download`, `file-upload`, `clipboard-in`, or
+	// `clipboard-out` are granted them wherever they are granted `use`. Naming one
+	// of them opts the role into granting each of them explicitly.
 	Verbs []Verb `json:"verbs,omitempty"`
 	// Resources this rule applies to. ResourceAll matches all resources.
 	Resources []Resource `json:"resources,omitempty"`
@@ -22,6 +25,34 @@ type Rule struct {
 	Namespaces []string `json:"namespaces,omitempty"`
 }
 
+// EffectiveRules returns the rules of a single role with the data loss prevention
+// verbs they imply. Roles that do not name any of the verbs are granted them
+// wherever they are granted `use`, so that roles created before the verbs existed
+// keep their abilities. Permission checks and privilege escalation checks should
+// both compare the effective rules.
+func EffectiveRules(rules []Rule) []Rule {
+	for _, rule := range rules {
+		for _, verb := range rule.Verbs {
+			if IsDLPVerb(verb) {
+				return rules
+			}
+		}
+	}
+	effective := make([]Rule, len(rules))
+	for idx, rule := range rules {
+		effective[idx] = rule
+		for _, verb := range rule.Verbs {
+			if verb == VerbUse {
+				verbs := make([]Verb, 0, len(rule.Verbs)+len(dlpVerbs))
+				verbs = append(verbs, rule.Verbs...)
+				effective[idx].Verbs = append(verbs, dlpVerbs...)
+				break
+			}
+		}
+	}
+	return effective
+}
+
 // Evaluate checks if this rule allows the given action. First the verb is matched,
 // then the resource type, and then optionally a name and namespace.
 func (r *Rule) Evaluate(action *APIAction) bool {
@@ -0,0 +1,24 @@
+package v1
+
+// SessionHandoff is a pending handoff of the display of a desktop session to
+// another device of the same user. Only a hash of its code is stored.
+// +k8s:deepcopy-gen=false
+type SessionHandoff struct {
+	// A hash of the one-time code for picking up the display
+	CodeHash string `json:"codeHash"`
+	// The user that created the handoff, and the only one that may redeem it
+	User string `json:"user"`
+	// A unix timestamp of when the handoff expires
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// SessionHandoffResponse contains the one-time code for picking up the display
+// of a desktop session on another device. The code is only returned in this
+// response.
+// +k8s:deepcopy-gen=false
+type SessionHandoffResponse struct {
+	// The code to pass in the `handoff` query parameter when connecting to the display
+	Code string `json:"code"`
+	// A unix timestamp of when the code expires
+	ExpiresAt int64 `json:"expiresAt"`
+}
@@ -0,0 +1,35 @@
+package v1
+
+// SessionInviteMode represents what an invited user may do with a desktop display.
+type SessionInviteMode string
+
+const (
+	// SessionInviteView allows the invited user to watch the display.
+	SessionInviteView SessionInviteMode = "view"
+	// SessionInviteControl allows the invited user to send keyboard and mouse input
+	// to the display.
+	SessionInviteControl SessionInviteMode = "control"
+)
+
+// SessionInvite allows a user to attach to the display of another user's desktop
+// session.
+// +k8s:deepcopy-gen=false
+type SessionInvite struct {
+	// The ID of the invite, used when attaching to the display
+	ID string `json:"id"`
+	// The user that was invited
+	User string `json:"user"`
+	// What the invited user may do with the display
+	Mode SessionInviteMode `json:"mode"`
+	// The user that created the invite
+	CreatedBy string `json:"createdBy"`
+	// A unix timestamp of when the invite expires
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// SessionInvitesResponse contains the active invites for a desktop session.
+// +k8s:deepcopy-gen=false
+type SessionInvitesResponse struct {
+	// The invites that have not expired
+	Invites []*SessionInvite `json:"invites"`
+}
@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Query parameters for retrieving the logs of a desktop session
+const (
+	// SessionLogContainerParam is the query parameter for a container to retrieve
+	// logs for. It may be provided multiple times.
+	SessionLogContainerParam = "container"
+	// SessionLogFollowParam is the query parameter for following the logs.
+	SessionLogFollowParam = "follow"
+	// SessionLogTailLinesParam is the query parameter for the number of lines to
+	// retrieve from the end of the logs.
+	SessionLogTailLinesParam = "tailLines"
+	// SessionLogPreviousParam is the query parameter for retrieving the logs of the
+	// previous instance of a container that restarted.
+	SessionLogPreviousParam = "previous"
+)
+
+// SessionLogOptions represents options for retrieving the logs of a desktop session.
+// +k8s:deepcopy-gen=false
+type SessionLogOptions struct {
+	// The containers to retrieve logs for, including init containers. All of them
+	// are used when empty.
+	Containers []string
+	// Set to true to keep streaming logs as they are written.
+	Follow bool
+	// The number of lines to retrieve from the end of the logs of each container.
+	// Zero means all of them.
+	TailLines int64
+	// Set to true to retrieve the logs of the previous instance of each container.
+	Previous bool
+}
+
+// ParseSessionLogOptions parses session log options from the given query parameters.
+func ParseSessionLogOptions(query url.Values) (*SessionLogOptions, error) {
+	opts := &SessionLogOptions{Containers: query[SessionLogContainerParam]}
+	var err error
+	if follow := query.Get(SessionLogFollowParam); follow != "" {
+		if opts.Follow, err = strconv.ParseBool(follow); err != nil {
+			return nil, fmt.Errorf("Invalid value for follow '%s'", follow)
+		}
+	}
+	if previous := query.Get(SessionLogPreviousParam); previous != "" {
+		if opts.Previous, err = strconv.ParseBool(previous); err != nil {
+			return nil, fmt.Errorf("Invalid value for previous '%s'", previous)
+		}
+	}
+	if opts.Follow && opts.Previous {
+		return nil, fmt.Errorf("The logs of previous containers cannot be followed")
+	}
+	if tail := query.Get(SessionLogTailLinesParam); tail != "" {
+		opts.TailLines, err = strconv.ParseInt(tail, 10, 64)
+		if err != nil || opts.TailLines < 0 {
+			return nil, fmt.Errorf("Invalid tailLines '%s'", tail)
+		}
+	}
+	return opts, nil
+}
+
+// Encode returns the query parameters for these options.
+func (o *SessionLogOptions) Encode() url.Values {
+	query := url.Values{}
+	for _, container := range o.Containers {
+		query.Add(SessionLogContainerParam, container)
+	}
+	if o.Follow {
+		query.Set(SessionLogFollowParam, "true")
+	}
+	if o.TailLines > 0 {
+		query.Set(SessionLogTailLinesParam, strconv.FormatInt(o.TailLines, 10))
+	}
+	if o.Previous {
+		query.Set(SessionLogPreviousParam, "true")
+	}
+	return query
+}
+
+// PodLogOptions returns the options for retrieving the logs of the given container.
+func (o *SessionLogOptions) PodLogOptions(container string) *corev1.PodLogOptions {
+	opts := &corev1.PodLogOptions{
+		Container: container,
+		Follow:    o.Follow,
+		Previous:  o.Previous,
+	}
+	if o.TailLines > 0 {
+		tail := o.TailLines
+		opts.TailLines = &tail
+	}
+	return opts
+}
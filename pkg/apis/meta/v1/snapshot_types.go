@@ -0,0 +1,24 @@
+package v1
+
+// DesktopSnapshot represents a snapshot of a user's userdata volume taken from
+// one of their desktop sessions. New sessions can be restored from it.
+// +k8s:deepcopy-gen=false
+type DesktopSnapshot struct {
+	// The name of the snapshot
+	Name string `json:"name"`
+	// The namespace of the snapshot. Sessions restored from it must run in the
+	// same namespace.
+	Namespace string `json:"namespace"`
+	// The user whose volume was snapshotted
+	User string `json:"user"`
+	// The template of the desktop the snapshot was taken from
+	Template string `json:"template"`
+	// The name of the desktop the snapshot was taken from
+	Desktop string `json:"desktop"`
+	// When the snapshot was taken, as a unix timestamp
+	CreatedAt int64 `json:"createdAt"`
+	// Whether the snapshot can be used to restore new sessions
+	ReadyToUse bool `json:"readyToUse"`
+	// The minimum size of a volume restored from the snapshot, when known
+	RestoreSize string `json:"restoreSize,omitempty"`
+}
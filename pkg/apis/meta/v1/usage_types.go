@@ -0,0 +1,119 @@
+package v1
+
+import "time"
+
+// UsageKey is the key in daily usage configmaps where the DailyUsage is stored.
+const UsageKey = "usage"
+
+// UsageDateFormat is the format of dates used in usage reports.
+const UsageDateFormat = "2006-01-02"
+
+// UsageGroupBy represents a field usage can be grouped by in a report.
+type UsageGroupBy string
+
+const (
+	// UsageGroupByUser groups usage by the user that owned the desktops.
+	UsageGroupByUser UsageGroupBy = "user"
+	// UsageGroupByRole groups usage by the roles of the user that owned the desktops.
+	// Usage is counted towards each role the user held.
+	UsageGroupByRole UsageGroupBy = "role"
+	// UsageGroupByTemplate groups usage by the template the desktops were booted from.
+	UsageGroupByTemplate UsageGroupBy = "template"
+	// UsageGroupByNamespace groups usage by the namespace the desktops ran in.
+	UsageGroupByNamespace UsageGroupBy = "namespace"
+)
+
+// IsValid returns true if this is a supported UsageGroupBy.
+func (u UsageGroupBy) IsValid() bool {
+	switch u {
+	case UsageGroupByUser, UsageGroupByRole, UsageGroupByTemplate, UsageGroupByNamespace:
+		return true
+	}
+	return false
+}
+
+// UsageSession represents a single desktop session.
+// +k8s:deepcopy-gen=false
+type UsageSession struct {
+	// The name of the desktop
+	Name string `json:"name"`
+	// The namespace of the desktop
+	Namespace string `json:"namespace"`
+	// The user that owned the desktop
+	User string `json:"user"`
+	// The roles held by the user when the desktop was launched
+	Roles []string `json:"roles,omitempty"`
+	// The template the desktop was booted from
+	Template string `json:"template"`
+	// When the session started
+	Start time.Time `json:"start"`
+	// When the session ended, empty if it is still running
+	End *time.Time `json:"end,omitempty"`
+	// How long the session has been running in hours
+	DurationHours float64 `json:"durationHours"`
+	// The CPU cores requested for the desktop
+	CPURequest float64 `json:"cpuRequest"`
+	// The memory in GiB requested for the desktop
+	MemoryGiBRequest float64 `json:"memoryGiBRequest"`
+}
+
+// UsageRecord represents the usage of a single user's desktops from a template
+// in a namespace over a day.
+// +k8s:deepcopy-gen=false
+type UsageRecord struct {
+	// The user that owned the desktops
+	User string `json:"user"`
+	// The roles held by the user
+	Roles []string `json:"roles,omitempty"`
+	// The namespace the desktops ran in
+	Namespace string `json:"namespace"`
+	// The template the desktops were booted from
+	Template string `json:"template"`
+	// The total hours desktops were running
+	DesktopHours float64 `json:"desktopHours"`
+	// The requested CPU cores multiplied by running hours
+	CPUHours float64 `json:"cpuHours"`
+	// The requested memory in GiB multiplied by running hours
+	MemoryGiBHours float64 `json:"memoryGiBHours"`
+}
+
+// DailyUsage contains the usage of a VDICluster over a single day.
+// +k8s:deepcopy-gen=false
+type DailyUsage struct {
+	// The day in UsageDateFormat
+	Date string `json:"date"`
+	// The usage records for the day
+	Records []*UsageRecord `json:"records"`
+	// The sessions that ran during the day
+	Sessions []*UsageSession `json:"sessions"`
+}
+
+// UsageReportGroup contains the aggregated usage for a single group in a report.
+// +k8s:deepcopy-gen=false
+type UsageReportGroup struct {
+	// The value of the field the usage was grouped by
+	Key string `json:"key"`
+	// The number of sessions in the group
+	Sessions int `json:"sessions"`
+	// The total hours desktops were running
+	DesktopHours float64 `json:"desktopHours"`
+	// The requested CPU cores multiplied by running hours
+	CPUHours float64 `json:"cpuHours"`
+	// The requested memory in GiB multiplied by running hours
+	MemoryGiBHours float64 `json:"memoryGiBHours"`
+}
+
+// UsageReport contains the usage of a VDICluster over a range of days.
+// +k8s:deepcopy-gen=false
+type UsageReport struct {
+	// The first day of the report in UsageDateFormat
+	Start string `json:"start"`
+	// The last day of the report in UsageDateFormat
+	End string `json:"end"`
+	// The field usage was grouped by
+	GroupBy UsageGroupBy `json:"groupBy"`
+	// The aggregated usage for each group
+	Groups []*UsageReportGroup `json:"groups"`
+	// The sessions that ran during the report
+	Sessions []*UsageSession `json:"sessions"`
+}
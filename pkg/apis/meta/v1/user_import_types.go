@@ -0,0 +1,47 @@
+package v1
+
+// UserRecord represents a user in a bulk import or export.
+// +k8s:deepcopy-gen=false
+type UserRecord struct {
+	// The name of the user
+	Username string `json:"username"`
+	// The names of the VDIRoles assigned to the user
+	Roles []string `json:"roles"`
+	// The initial password for the user. Only used on import, one is generated
+	// when omitted.
+	Password string `json:"password,omitempty"`
+}
+
+// ImportUsersRequest contains the users in a bulk import, read from either the
+// CSV or JSON body of the request.
+// +k8s:deepcopy-gen=false
+type ImportUsersRequest struct {
+	// The users to create
+	Users []*UserRecord `json:"users"`
+}
+
+// ImportUsersResponse reports the result of a bulk user import. Imports are all or
+// nothing, if any row fails no users are created.
+// +k8s:deepcopy-gen=false
+type ImportUsersResponse struct {
+	// The number of users created
+	Imported int `json:"imported"`
+	// Passwords generated for users that were imported without one, keyed by username
+	GeneratedPasswords map[string]string `json:"generatedPasswords,omitempty"`
+	// A summary of why the import failed
+	Error string `json:"error,omitempty"`
+	// The rows that failed
+	Errors []*UserImportError `json:"errors,omitempty"`
+}
+
+// UserImportError describes why a row in a bulk user import failed.
+// +k8s:deepcopy-gen=false
+type UserImportError struct {
+	// The position of the row in the request, starting at 1 and not counting the
+	// CSV header
+	Row int `json:"row"`
+	// The username in the row, if any
+	Username string `json:"username,omitempty"`
+	// A message describing the error
+	Error string `json:"error"`
+}
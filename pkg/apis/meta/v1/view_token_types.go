@@ -0,0 +1,34 @@
+package v1
+
+// MaxViewTokenDuration is the longest a view token may be valid for.
+const MaxViewTokenDuration = "24h"
+
+// ViewToken grants view-only access to the display of a single desktop session
+// without a user session, e.g. for embedding the display in a dashboard.
+// +k8s:deepcopy-gen=false
+type ViewToken struct {
+	// The ID of the view token
+	ID string `json:"id"`
+	// A description of where the token is used
+	Label string `json:"label,omitempty"`
+	// The user that created the view token
+	CreatedBy string `json:"createdBy"`
+	// A unix timestamp of when the view token expires
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// CreateViewTokenResponse contains a new view token and the token to provide
+// when connecting to the display. The token is only returned in this response.
+// +k8s:deepcopy-gen=false
+type CreateViewTokenResponse struct {
+	*ViewToken
+	// The token to pass in the `token` query parameter when connecting to the display
+	Token string `json:"token"`
+}
+
+// ViewTokensResponse contains the active view tokens for a desktop session.
+// +k8s:deepcopy-gen=false
+type ViewTokensResponse struct {
+	// The view tokens that have not expired
+	ViewTokens []*ViewToken `json:"viewTokens"`
+}
@@ -20,6 +20,29 @@ func (in *APIAction) DeepCopy() *APIAction {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *APIKey) DeepCopyInto(out *APIKey) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]Rule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APIKey.
+func (in *APIKey) DeepCopy() *APIKey {
+	if in == nil {
+		return nil
+	}
+	out := new(APIKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthResult) DeepCopyInto(out *AuthResult) {
 	*out = *in
@@ -57,6 +80,38 @@ func (in *AuthorizeRequest) DeepCopy() *AuthorizeRequest {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChangePasswordRequest) DeepCopyInto(out *ChangePasswordRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChangePasswordRequest.
+func (in *ChangePasswordRequest) DeepCopy() *ChangePasswordRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(ChangePasswordRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfirmPasswordResetRequest) DeepCopyInto(out *ConfirmPasswordResetRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfirmPasswordResetRequest.
+func (in *ConfirmPasswordResetRequest) DeepCopy() *ConfirmPasswordResetRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfirmPasswordResetRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConnectionStatus) DeepCopyInto(out *ConnectionStatus) {
 	*out = *in
@@ -73,6 +128,92 @@ func (in *ConnectionStatus) DeepCopy() *ConnectionStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CreateAPIKeyRequest) DeepCopyInto(out *CreateAPIKeyRequest) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]Rule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CreateAPIKeyRequest.
+func (in *CreateAPIKeyRequest) DeepCopy() *CreateAPIKeyRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(CreateAPIKeyRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CreateAPIKeyResponse) DeepCopyInto(out *CreateAPIKeyResponse) {
+	*out = *in
+	if in.APIKey != nil {
+		in, out := &in.APIKey, &out.APIKey
+		*out = new(APIKey)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CreateAPIKeyResponse.
+func (in *CreateAPIKeyResponse) DeepCopy() *CreateAPIKeyResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(CreateAPIKeyResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CreateGroupRequest) DeepCopyInto(out *CreateGroupRequest) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CreateGroupRequest.
+func (in *CreateGroupRequest) DeepCopy() *CreateGroupRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(CreateGroupRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CreateRoleGrantRequest) DeepCopyInto(out *CreateRoleGrantRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CreateRoleGrantRequest.
+func (in *CreateRoleGrantRequest) DeepCopy() *CreateRoleGrantRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(CreateRoleGrantRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CreateRoleRequest) DeepCopyInto(out *CreateRoleRequest) {
 	*out = *in
@@ -90,6 +231,16 @@ func (in *CreateRoleRequest) DeepCopyInto(out *CreateRoleRequest) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Inherits != nil {
+		in, out := &in.Inherits, &out.Inherits
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(SessionPlacement)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -103,9 +254,46 @@ func (in *CreateRoleRequest) DeepCopy() *CreateRoleRequest {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CreateSessionInviteRequest) DeepCopyInto(out *CreateSessionInviteRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CreateSessionInviteRequest.
+func (in *CreateSessionInviteRequest) DeepCopy() *CreateSessionInviteRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(CreateSessionInviteRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CreateSessionRequest) DeepCopyInto(out *CreateSessionRequest) {
 	*out = *in
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SecretEnv != nil {
+		in, out := &in.SecretEnv, &out.SecretEnv
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -119,6 +307,22 @@ func (in *CreateSessionRequest) DeepCopy() *CreateSessionRequest {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CreateSessionResponse) DeepCopyInto(out *CreateSessionResponse) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CreateSessionResponse.
+func (in *CreateSessionResponse) DeepCopy() *CreateSessionResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(CreateSessionResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CreateUserRequest) DeepCopyInto(out *CreateUserRequest) {
 	*out = *in
@@ -127,6 +331,13 @@ func (in *CreateUserRequest) DeepCopyInto(out *CreateUserRequest) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.RoleExpirations != nil {
+		in, out := &in.RoleExpirations, &out.RoleExpirations
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -140,6 +351,22 @@ func (in *CreateUserRequest) DeepCopy() *CreateUserRequest {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CreateViewTokenRequest) DeepCopyInto(out *CreateViewTokenRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CreateViewTokenRequest.
+func (in *CreateViewTokenRequest) DeepCopy() *CreateViewTokenRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(CreateViewTokenRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DesktopSession) DeepCopyInto(out *DesktopSession) {
 	*out = *in
@@ -201,6 +428,11 @@ func (in *DesktopSessionsResponse) DeepCopyInto(out *DesktopSessionsResponse) {
 			}
 		}
 	}
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = new(ListMeta)
+		**out = **in
+	}
 	return
 }
 
@@ -214,6 +446,98 @@ func (in *DesktopSessionsResponse) DeepCopy() *DesktopSessionsResponse {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DotfilesConfig) DeepCopyInto(out *DotfilesConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DotfilesConfig.
+func (in *DotfilesConfig) DeepCopy() *DotfilesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DotfilesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainNodeRequest) DeepCopyInto(out *DrainNodeRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainNodeRequest.
+func (in *DrainNodeRequest) DeepCopy() *DrainNodeRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(DrainNodeRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EstimateSessionRequest) DeepCopyInto(out *EstimateSessionRequest) {
+	*out = *in
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EstimateSessionRequest.
+func (in *EstimateSessionRequest) DeepCopy() *EstimateSessionRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(EstimateSessionRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EvaluatePermissionRequest) DeepCopyInto(out *EvaluatePermissionRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EvaluatePermissionRequest.
+func (in *EvaluatePermissionRequest) DeepCopy() *EvaluatePermissionRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(EvaluatePermissionRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportTemplatesRequest) DeepCopyInto(out *ExportTemplatesRequest) {
+	*out = *in
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportTemplatesRequest.
+func (in *ExportTemplatesRequest) DeepCopy() *ExportTemplatesRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportTemplatesRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FileStat) DeepCopyInto(out *FileStat) {
 	*out = *in
@@ -241,6 +565,22 @@ func (in *FileStat) DeepCopy() *FileStat {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImpersonateRequest) DeepCopyInto(out *ImpersonateRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImpersonateRequest.
+func (in *ImpersonateRequest) DeepCopy() *ImpersonateRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(ImpersonateRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JWTClaims) DeepCopyInto(out *JWTClaims) {
 	*out = *in
@@ -263,9 +603,78 @@ func (in *JWTClaims) DeepCopy() *JWTClaims {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyboardLayoutConfig) DeepCopyInto(out *KeyboardLayoutConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyboardLayoutConfig.
+func (in *KeyboardLayoutConfig) DeepCopy() *KeyboardLayoutConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyboardLayoutConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListMeta) DeepCopyInto(out *ListMeta) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListMeta.
+func (in *ListMeta) DeepCopy() *ListMeta {
+	if in == nil {
+		return nil
+	}
+	out := new(ListMeta)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogoutResponse) DeepCopyInto(out *LogoutResponse) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogoutResponse.
+func (in *LogoutResponse) DeepCopy() *LogoutResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(LogoutResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MFAPushResponse) DeepCopyInto(out *MFAPushResponse) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MFAPushResponse.
+func (in *MFAPushResponse) DeepCopy() *MFAPushResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(MFAPushResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MFAResponse) DeepCopyInto(out *MFAResponse) {
 	*out = *in
+	if in.BackupCodes != nil {
+		in, out := &in.BackupCodes, &out.BackupCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -279,6 +688,86 @@ func (in *MFAResponse) DeepCopy() *MFAResponse {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OrphanedResource) DeepCopyInto(out *OrphanedResource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OrphanedResource.
+func (in *OrphanedResource) DeepCopy() *OrphanedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(OrphanedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PasswordResetRequest) DeepCopyInto(out *PasswordResetRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordResetRequest.
+func (in *PasswordResetRequest) DeepCopy() *PasswordResetRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(PasswordResetRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrintJob) DeepCopyInto(out *PrintJob) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrintJob.
+func (in *PrintJob) DeepCopy() *PrintJob {
+	if in == nil {
+		return nil
+	}
+	out := new(PrintJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Recording) DeepCopyInto(out *Recording) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Recording.
+func (in *Recording) DeepCopy() *Recording {
+	if in == nil {
+		return nil
+	}
+	out := new(Recording)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollbackTemplateRequest) DeepCopyInto(out *RollbackTemplateRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollbackTemplateRequest.
+func (in *RollbackTemplateRequest) DeepCopy() *RollbackTemplateRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(RollbackTemplateRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Rule) DeepCopyInto(out *Rule) {
 	*out = *in
@@ -315,6 +804,34 @@ func (in *Rule) DeepCopy() *Rule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SessionPlacement) DeepCopyInto(out *SessionPlacement) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionPlacement.
+func (in *SessionPlacement) DeepCopy() *SessionPlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(SessionPlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SessionResponse) DeepCopyInto(out *SessionResponse) {
 	*out = *in
@@ -357,6 +874,32 @@ func (in *StatDesktopFileResponse) DeepCopy() *StatDesktopFileResponse {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateGroupRequest) DeepCopyInto(out *UpdateGroupRequest) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateGroupRequest.
+func (in *UpdateGroupRequest) DeepCopy() *UpdateGroupRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateGroupRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpdateMFARequest) DeepCopyInto(out *UpdateMFARequest) {
 	*out = *in
@@ -390,6 +933,16 @@ func (in *UpdateRoleRequest) DeepCopyInto(out *UpdateRoleRequest) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Inherits != nil {
+		in, out := &in.Inherits, &out.Inherits
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(SessionPlacement)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -403,6 +956,22 @@ func (in *UpdateRoleRequest) DeepCopy() *UpdateRoleRequest {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateUserDataRequest) DeepCopyInto(out *UpdateUserDataRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateUserDataRequest.
+func (in *UpdateUserDataRequest) DeepCopy() *UpdateUserDataRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateUserDataRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpdateUserRequest) DeepCopyInto(out *UpdateUserRequest) {
 	*out = *in
@@ -411,6 +980,13 @@ func (in *UpdateUserRequest) DeepCopyInto(out *UpdateUserRequest) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.RoleExpirations != nil {
+		in, out := &in.RoleExpirations, &out.RoleExpirations
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -424,6 +1000,54 @@ func (in *UpdateUserRequest) DeepCopy() *UpdateUserRequest {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateUserSecretRequest) DeepCopyInto(out *UpdateUserSecretRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateUserSecretRequest.
+func (in *UpdateUserSecretRequest) DeepCopy() *UpdateUserSecretRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateUserSecretRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserDataResponse) DeepCopyInto(out *UserDataResponse) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserDataResponse.
+func (in *UserDataResponse) DeepCopy() *UserDataResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(UserDataResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserEmailConfig) DeepCopyInto(out *UserEmailConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserEmailConfig.
+func (in *UserEmailConfig) DeepCopy() *UserEmailConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(UserEmailConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UserMFAStatus) DeepCopyInto(out *UserMFAStatus) {
 	*out = *in
@@ -440,6 +1064,27 @@ func (in *UserMFAStatus) DeepCopy() *UserMFAStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserSecretsResponse) DeepCopyInto(out *UserSecretsResponse) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserSecretsResponse.
+func (in *UserSecretsResponse) DeepCopy() *UserSecretsResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(UserSecretsResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VDIUser) DeepCopyInto(out *VDIUser) {
 	*out = *in
@@ -454,11 +1099,23 @@ func (in *VDIUser) DeepCopyInto(out *VDIUser) {
 			}
 		}
 	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.MFA != nil {
 		in, out := &in.MFA, &out.MFA
 		*out = new(UserMFAStatus)
 		**out = **in
 	}
+	if in.Restrictions != nil {
+		in, out := &in.Restrictions, &out.Restrictions
+		*out = make([]Rule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -482,6 +1139,11 @@ func (in *VDIUserRole) DeepCopyInto(out *VDIUserRole) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(SessionPlacement)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
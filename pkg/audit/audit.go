@@ -0,0 +1,90 @@
+// Package audit contains a logger for recording API events to pluggable backends.
+// Each event carries a hash of the one before it, so that entries removed or
+// modified after the fact can be detected.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var auditLogger = logf.Log.WithName("audit")
+
+// Backend is an interface for writing audit events to a destination.
+type Backend interface {
+	// Name should return a short name for the backend, used in log messages.
+	Name() string
+	// Write should write the given event to the destination.
+	Write(*Event) error
+	// Close should release any resources held by the backend.
+	Close() error
+}
+
+// Decision values for an event.
+const (
+	// DecisionAllowed means the user was granted access to the route.
+	DecisionAllowed = "allowed"
+	// DecisionDenied means the user was denied access to the route.
+	DecisionDenied = "denied"
+)
+
+// Event represents a single request to the API.
+type Event struct {
+	// The position of the event in the chain written by this instance
+	Sequence uint64 `json:"sequence"`
+	// The app instance that handled the request
+	Instance string `json:"instance,omitempty"`
+	// The time the request completed
+	Timestamp time.Time `json:"timestamp"`
+	// The user that made the request, if known
+	User string `json:"user,omitempty"`
+	// The user acting as User, when the request was made with an impersonation
+	// token
+	Impersonator string `json:"impersonator,omitempty"`
+	// The HTTP method of the request
+	Method string `json:"method"`
+	// The path of the request
+	Path string `json:"path"`
+	// The verb of the API action the request was evaluated as
+	Verb string `json:"verb,omitempty"`
+	// The type of resource the request acted on
+	Resource string `json:"resource,omitempty"`
+	// The name of the resource the request acted on
+	ResourceName string `json:"resourceName,omitempty"`
+	// The namespace of the resource the request acted on
+	Namespace string `json:"namespace,omitempty"`
+	// Whether the user was allowed or denied access, empty for routes that are
+	// not protected
+	Decision string `json:"decision,omitempty"`
+	// True if access was granted because the user owns the resource
+	FromOwner bool `json:"fromOwner,omitempty"`
+	// The HTTP status code of the response
+	Status int `json:"status"`
+	// The address the request came from
+	SourceIP string `json:"sourceIP"`
+	// The contents of the X-Forwarded-For header, if present
+	ForwardedFor string `json:"forwardedFor,omitempty"`
+	// A human-readable summary of the event
+	Message string `json:"message,omitempty"`
+	// The hash of the previous event in the chain
+	PrevHash string `json:"prevHash"`
+	// The hash of this event, including PrevHash
+	Hash string `json:"hash"`
+}
+
+// computeHash returns the hex encoded sha256 of the event with the Hash field
+// left empty.
+func (e *Event) computeHash() (string, error) {
+	cp := *e
+	cp.Hash = ""
+	body, err := json.Marshal(&cp)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
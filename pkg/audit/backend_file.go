@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileBackend appends audit events to a file as JSON, one per line.
+type FileBackend struct {
+	f   *os.File
+	mux sync.Mutex
+}
+
+// NewFileBackend returns a new FileBackend appending to the given path. The
+// file and any missing parent directories are created.
+func NewFileBackend(path string) (Backend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileBackend{f: f}, nil
+}
+
+// Name implements Backend.
+func (f *FileBackend) Name() string { return "file" }
+
+// Write implements Backend and appends the event to the file.
+func (f *FileBackend) Write(event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	_, err = f.f.Write(append(body, '\n'))
+	return err
+}
+
+// Close implements Backend and closes the file.
+func (f *FileBackend) Close() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.f.Close()
+}
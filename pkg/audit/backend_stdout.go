@@ -0,0 +1,32 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// StdoutBackend writes audit events to stdout as JSON, one per line.
+type StdoutBackend struct {
+	out io.Writer
+	mux sync.Mutex
+}
+
+// NewStdoutBackend returns a new StdoutBackend.
+func NewStdoutBackend() Backend {
+	return &StdoutBackend{out: os.Stdout}
+}
+
+// Name implements Backend.
+func (s *StdoutBackend) Name() string { return "stdout" }
+
+// Write implements Backend and writes the event to stdout.
+func (s *StdoutBackend) Write(event *Event) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return json.NewEncoder(s.out).Encode(event)
+}
+
+// Close implements Backend. Stdout is left open.
+func (s *StdoutBackend) Close() error { return nil }
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+// webhookQueueSize is the number of events that can be waiting to be sent
+// before new ones are dropped.
+const webhookQueueSize = 1024
+
+// WebhookBackend POSTs audit events to an HTTP endpoint. Events are sent in the
+// background so that a slow endpoint does not hold up API requests.
+type WebhookBackend struct {
+	cfg    *v1alpha1.AuditWebhookConfig
+	client *http.Client
+	queue  chan *Event
+	wg     sync.WaitGroup
+}
+
+// NewWebhookBackend returns a new WebhookBackend for the given configuration.
+func NewWebhookBackend(cfg *v1alpha1.AuditWebhookConfig, timeout time.Duration) Backend {
+	w := &WebhookBackend{
+		cfg:    cfg,
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan *Event, webhookQueueSize),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// Name implements Backend.
+func (w *WebhookBackend) Name() string { return "webhook" }
+
+// Write implements Backend and queues the event to be sent.
+func (w *WebhookBackend) Write(event *Event) error {
+	select {
+	case w.queue <- event:
+		return nil
+	default:
+		return fmt.Errorf("Webhook queue is full, dropping event %d", event.Sequence)
+	}
+}
+
+// Close implements Backend and waits for queued events to be sent.
+func (w *WebhookBackend) Close() error {
+	close(w.queue)
+	w.wg.Wait()
+	return nil
+}
+
+func (w *WebhookBackend) run() {
+	defer w.wg.Done()
+	for event := range w.queue {
+		if err := w.send(event); err != nil {
+			auditLogger.Error(err, "Failed to send audit event to webhook", "Sequence", event.Sequence)
+		}
+	}
+}
+
+func (w *WebhookBackend) send(event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("Audit webhook returned status %d", res.StatusCode)
+	}
+	return nil
+}
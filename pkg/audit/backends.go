@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"fmt"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// GetBackend returns the backend configured for the given VDICluster, or nil if
+// the audit log is disabled. When sinks are configured, events are forwarded to
+// them as well. Tokens for the sinks are read from the given secrets engine.
+func GetBackend(cluster *v1alpha1.VDICluster, s *secrets.SecretEngine) (Backend, error) {
+	if !cluster.AuditLogEnabled() {
+		return nil, nil
+	}
+	backend, err := getPrimaryBackend(cluster)
+	if err != nil {
+		return nil, err
+	}
+	sinks := cluster.GetAuditSinks()
+	if len(sinks) == 0 {
+		return backend, nil
+	}
+	backends := []Backend{backend}
+	for idx := range sinks {
+		sink, err := getSinkBackend(cluster, &sinks[idx], s)
+		if err != nil {
+			// release the backends created so far
+			NewMultiBackend(backends...).Close()
+			return nil, err
+		}
+		backends = append(backends, sink)
+	}
+	return NewMultiBackend(backends...), nil
+}
+
+func getPrimaryBackend(cluster *v1alpha1.VDICluster) (Backend, error) {
+	switch cluster.GetAuditLogBackend() {
+	case v1alpha1.AuditLogFile:
+		return NewFileBackend(cluster.GetAuditLogFilePath())
+	case v1alpha1.AuditLogWebhook:
+		cfg := cluster.GetAuditWebhookConfig()
+		if cfg == nil || cfg.URL == "" {
+			return nil, fmt.Errorf("No url configured for the audit webhook")
+		}
+		return NewWebhookBackend(cfg, cluster.GetAuditWebhookTimeout()), nil
+	default:
+		return NewStdoutBackend(), nil
+	}
+}
+
+func getSinkBackend(cluster *v1alpha1.VDICluster, cfg *v1alpha1.AuditSinkConfig, s *secrets.SecretEngine) (Backend, error) {
+	var token string
+	if cfg.Splunk != nil {
+		if s == nil {
+			return nil, errors.New("The secrets backend has not been setup yet")
+		}
+		raw, err := s.ReadSecret(cfg.Splunk.GetTokenKey(), true)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the HEC token for audit sink %s: %s", cfg.Name, err.Error())
+		}
+		token = string(raw)
+	}
+	return NewSinkBackend(cfg, cluster.GetName(), token)
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Logger writes events to a Backend, chaining each one to the event before it.
+type Logger struct {
+	instance string
+	backend  Backend
+	seq      uint64
+	lastHash string
+	mux      sync.Mutex
+}
+
+// NewLogger returns a new Logger writing to the given backend. The instance
+// is recorded on every event so chains from different app replicas can be
+// told apart.
+func NewLogger(instance string, backend Backend) *Logger {
+	return &Logger{instance: instance, backend: backend}
+}
+
+// SetBackend swaps the backend events are written to and closes the previous
+// one. The chain continues uninterrupted on the new backend.
+func (l *Logger) SetBackend(backend Backend) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.backend != nil {
+		if err := l.backend.Close(); err != nil {
+			auditLogger.Error(err, "Failed to close audit backend", "Backend", l.backend.Name())
+		}
+	}
+	l.backend = backend
+}
+
+// Backend returns the backend events are currently written to.
+func (l *Logger) Backend() Backend {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.backend
+}
+
+// Log adds the event to the chain and writes it to the backend. Errors are
+// logged and not returned, since a failure to audit should not fail the request.
+func (l *Logger) Log(event *Event) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.backend == nil {
+		return
+	}
+	l.seq++
+	event.Sequence = l.seq
+	event.Instance = l.instance
+	event.PrevHash = l.lastHash
+	hash, err := event.computeHash()
+	if err != nil {
+		auditLogger.Error(err, "Failed to hash audit event")
+		return
+	}
+	event.Hash = hash
+	l.lastHash = hash
+	if err := l.backend.Write(event); err != nil {
+		auditLogger.Error(err, "Failed to write audit event", "Backend", l.backend.Name(), "Sequence", event.Sequence)
+	}
+}
+
+// Verify checks that the given events, in the order they were written by a
+// single instance, form an unbroken chain. An error is returned describing the
+// first event that was modified, removed, or reordered.
+func Verify(events []*Event) error {
+	for i, event := range events {
+		hash, err := event.computeHash()
+		if err != nil {
+			return err
+		}
+		if hash != event.Hash {
+			return fmt.Errorf("Event %d has been modified", event.Sequence)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := events[i-1]
+		if event.Sequence != prev.Sequence+1 || event.PrevHash != prev.Hash {
+			return fmt.Errorf("Chain is broken between events %d and %d", prev.Sequence, event.Sequence)
+		}
+	}
+	return nil
+}
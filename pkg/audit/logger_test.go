@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type memoryBackend struct {
+	events []*Event
+	closed bool
+}
+
+func (m *memoryBackend) Name() string { return "memory" }
+
+func (m *memoryBackend) Write(event *Event) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *memoryBackend) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestLoggerChain(t *testing.T) {
+	first := &memoryBackend{}
+	logger := NewLogger("app-0", first)
+	logger.Log(&Event{User: "admin", Method: "POST", Path: "/api/roles"})
+	logger.Log(&Event{User: "admin", Method: "PUT", Path: "/api/roles/test"})
+
+	// the chain should continue on a new backend
+	second := &memoryBackend{}
+	logger.SetBackend(second)
+	if !first.closed {
+		t.Error("Expected previous backend to be closed")
+	}
+	logger.Log(&Event{User: "admin", Method: "DELETE", Path: "/api/roles/test"})
+
+	events := append(first.events, second.events...)
+	if events[0].PrevHash != "" || events[0].Instance != "app-0" {
+		t.Error("Expected first event to start the chain, got:", events[0])
+	}
+	if err := Verify(events); err != nil {
+		t.Fatal("Expected chain to verify, got:", err)
+	}
+
+	// modifying an event should be detected
+	events[1].User = "someone-else"
+	if err := Verify(events); err == nil {
+		t.Error("Expected modified event to fail verification")
+	}
+	events[1].User = "admin"
+
+	// so should removing one
+	if err := Verify([]*Event{events[0], events[2]}); err == nil {
+		t.Error("Expected removed event to fail verification")
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit", "audit.log")
+
+	backend, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := NewLogger("app-0", backend)
+	logger.Log(&Event{Timestamp: time.Now().UTC(), User: "admin", Status: 200})
+	logger.Log(&Event{Timestamp: time.Now().UTC(), User: "admin", Status: 403})
+	logger.SetBackend(nil)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events := make([]*Event, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		event := &Event{}
+		if err := json.Unmarshal(scanner.Bytes(), event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 2 {
+		t.Fatal("Expected two events in the file, got:", len(events))
+	}
+	if err := Verify(events); err != nil {
+		t.Error("Expected events read from file to verify, got:", err)
+	}
+}
@@ -0,0 +1,202 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+// sinkInitialBackoff is how long to wait before the first retry of a failed batch.
+// The wait doubles with each retry up to sinkMaxBackoff.
+var sinkInitialBackoff = time.Second
+
+// sinkMaxBackoff is the longest to wait between retries of a failed batch.
+const sinkMaxBackoff = 30 * time.Second
+
+// sender delivers batches of events to a sink.
+type sender interface {
+	// send should deliver the given events, returning a permanentError if the
+	// batch should not be retried.
+	send([]*Event) error
+	// close should release any connections held by the sender.
+	close() error
+}
+
+// permanentError is returned by senders when retrying a batch will not help, e.g.
+// because the destination rejected it as malformed.
+type permanentError struct{ error }
+
+// SinkBackend forwards audit events to an external system in batches. Events are
+// queued and sent in the background, and failed batches are retried with an
+// exponential backoff before they are dropped.
+type SinkBackend struct {
+	name          string
+	sender        sender
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	queue         chan *Event
+	stop          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewSinkBackend returns a new SinkBackend for the given configuration. The token
+// is sent to destinations that require one, e.g. the Splunk HEC token.
+func NewSinkBackend(cfg *v1alpha1.AuditSinkConfig, cluster, token string) (Backend, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	var snd sender
+	switch {
+	case cfg.Syslog != nil:
+		snd = newSyslogSender(cfg.Syslog)
+	case cfg.Splunk != nil:
+		snd = newSplunkSender(cfg.Splunk, cluster, token)
+	default:
+		snd = newWebhookSender(cfg.Webhook)
+	}
+	return newSinkBackend(cfg, snd), nil
+}
+
+func newSinkBackend(cfg *v1alpha1.AuditSinkConfig, snd sender) *SinkBackend {
+	s := &SinkBackend{
+		name:          cfg.Name,
+		sender:        snd,
+		batchSize:     cfg.GetBatchSize(),
+		flushInterval: cfg.GetFlushInterval(),
+		maxRetries:    cfg.GetMaxRetries(),
+		queue:         make(chan *Event, cfg.GetQueueSize()),
+		stop:          make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// Name implements Backend.
+func (s *SinkBackend) Name() string { return fmt.Sprintf("sink/%s", s.name) }
+
+// Write implements Backend and queues the event to be sent.
+func (s *SinkBackend) Write(event *Event) error {
+	select {
+	case s.queue <- event:
+		return nil
+	default:
+		return fmt.Errorf("Queue for audit sink %s is full, dropping event %d", s.name, event.Sequence)
+	}
+}
+
+// Close implements Backend. Queued events are sent before the sender is closed,
+// retrying failed batches once more without waiting.
+func (s *SinkBackend) Close() error {
+	close(s.stop)
+	close(s.queue)
+	s.wg.Wait()
+	return s.sender.close()
+}
+
+func (s *SinkBackend) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Event, 0, s.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.sendWithRetry(batch)
+		batch = make([]*Event, 0, s.batchSize)
+	}
+	for {
+		select {
+		case event, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// sendWithRetry sends the batch, retrying until it succeeds, the error is
+// permanent, or the retries are exhausted. Once the sink is closed, a failed
+// batch is only retried once more without waiting.
+func (s *SinkBackend) sendWithRetry(batch []*Event) {
+	backoff := sinkInitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.sender.send(batch)
+		if err == nil {
+			return
+		}
+		_, permanent := err.(*permanentError)
+		if permanent || attempt >= s.maxRetries {
+			auditLogger.Error(err, "Dropping batch of audit events", "Sink", s.name,
+				"FirstSequence", batch[0].Sequence, "Events", len(batch))
+			return
+		}
+		auditLogger.Info("Failed to send audit events, retrying", "Sink", s.name, "Error", err.Error(), "Backoff", backoff.String())
+		select {
+		case <-s.stop:
+			// make one last attempt instead of waiting out the backoff
+			if err := s.sender.send(batch); err != nil {
+				auditLogger.Error(err, "Dropping batch of audit events on shutdown", "Sink", s.name,
+					"FirstSequence", batch[0].Sequence, "Events", len(batch))
+			}
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > sinkMaxBackoff {
+			backoff = sinkMaxBackoff
+		}
+	}
+}
+
+// MultiBackend writes audit events to several backends.
+type MultiBackend struct {
+	backends []Backend
+}
+
+// NewMultiBackend returns a new MultiBackend writing to the given backends in order.
+func NewMultiBackend(backends ...Backend) Backend {
+	return &MultiBackend{backends: backends}
+}
+
+// Name implements Backend and returns the first backend's name, which is the
+// one events are primarily written to.
+func (m *MultiBackend) Name() string { return m.backends[0].Name() }
+
+// Write implements Backend. The event is written to every backend even if some
+// of them fail, and the errors are returned together.
+func (m *MultiBackend) Write(event *Event) error {
+	errs := make([]string, 0)
+	for _, backend := range m.backends {
+		if err := backend.Write(event); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", backend.Name(), err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Close implements Backend and closes every backend.
+func (m *MultiBackend) Close() error {
+	var firstErr error
+	for _, backend := range m.backends {
+		if err := backend.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
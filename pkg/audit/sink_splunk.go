@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+// splunkEvent is the envelope events are sent to the HTTP Event Collector in.
+type splunkEvent struct {
+	Time       float64 `json:"time"`
+	Host       string  `json:"host,omitempty"`
+	Source     string  `json:"source,omitempty"`
+	SourceType string  `json:"sourcetype,omitempty"`
+	Index      string  `json:"index,omitempty"`
+	Event      *Event  `json:"event"`
+}
+
+// splunkSender sends batches of audit events to a Splunk HTTP Event Collector.
+type splunkSender struct {
+	cfg    *v1alpha1.AuditSplunkConfig
+	source string
+	token  string
+	client *http.Client
+}
+
+func newSplunkSender(cfg *v1alpha1.AuditSplunkConfig, cluster, token string) *splunkSender {
+	source := cfg.Source
+	if source == "" {
+		source = cluster
+	}
+	return &splunkSender{
+		cfg:    cfg,
+		source: source,
+		token:  token,
+		client: &http.Client{
+			Timeout: cfg.GetTimeout(),
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify},
+			},
+		},
+	}
+}
+
+func (s *splunkSender) send(events []*Event) error {
+	// the collector accepts batches as concatenated JSON objects
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, event := range events {
+		if err := enc.Encode(&splunkEvent{
+			Time:       float64(event.Timestamp.UnixNano()) / 1e9,
+			Host:       event.Instance,
+			Source:     s.source,
+			SourceType: s.cfg.GetSourceType(),
+			Index:      s.cfg.Index,
+			Event:      event,
+		}); err != nil {
+			return &permanentError{err}
+		}
+	}
+	req, err := http.NewRequest(http.MethodPost, s.cfg.GetEventURL(), &body)
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Splunk %s", s.token))
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return checkSinkResponse("Splunk HTTP Event Collector", res)
+}
+
+func (s *splunkSender) close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// checkSinkResponse returns an error if the response from an HTTP sink is not
+// successful. Client errors other than timeouts and rate limits are permanent.
+func checkSinkResponse(name string, res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		io.Copy(ioutil.Discard, res.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	err := fmt.Errorf("%s returned status %d: %s", name, res.StatusCode, string(bytes.TrimSpace(msg)))
+	if res.StatusCode >= 400 && res.StatusCode <= 499 &&
+		res.StatusCode != http.StatusRequestTimeout && res.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err}
+	}
+	return err
+}
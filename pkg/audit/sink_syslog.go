@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+// syslogTimeout is the timeout for connecting and writing to the syslog server.
+const syslogTimeout = 10 * time.Second
+
+// Syslog severities used for audit events.
+const (
+	syslogSeverityWarning = 4
+	syslogSeverityInfo    = 6
+)
+
+// syslogSender sends audit events to a syslog server in the RFC 5424 format. The
+// connection is opened on first use and reopened after a failed write.
+type syslogSender struct {
+	cfg      *v1alpha1.AuditSyslogConfig
+	hostname string
+	conn     net.Conn
+}
+
+func newSyslogSender(cfg *v1alpha1.AuditSyslogConfig) *syslogSender {
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	return &syslogSender{cfg: cfg, hostname: hostname}
+}
+
+func (s *syslogSender) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	switch s.cfg.GetProtocol() {
+	case v1alpha1.AuditSyslogUDP:
+		return dialer.Dial("udp", s.cfg.Address)
+	case v1alpha1.AuditSyslogTLS:
+		return tls.DialWithDialer(dialer, "tcp", s.cfg.Address, &tls.Config{
+			InsecureSkipVerify: s.cfg.InsecureSkipVerify,
+		})
+	default:
+		return dialer.Dial("tcp", s.cfg.Address)
+	}
+}
+
+func (s *syslogSender) send(events []*Event) error {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	// messages are written one at a time, so on failure the whole batch is
+	// resent and the server may see some of them twice
+	for _, event := range events {
+		msg, err := s.format(event)
+		if err != nil {
+			return &permanentError{err}
+		}
+		if s.cfg.GetProtocol() != v1alpha1.AuditSyslogUDP {
+			// octet counting framing from RFC 6587
+			msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+		}
+		if err := s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout)); err != nil {
+			s.close()
+			return err
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			s.close()
+			return err
+		}
+	}
+	return nil
+}
+
+// format returns the RFC 5424 message for the event, with the JSON encoded event
+// as the message body.
+func (s *syslogSender) format(event *Event) ([]byte, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	severity := syslogSeverityInfo
+	if event.Decision == DecisionDenied {
+		severity = syslogSeverityWarning
+	}
+	hostname := event.Instance
+	if hostname == "" {
+		hostname = s.hostname
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s - audit - ",
+		s.cfg.GetFacility()*8+severity,
+		event.Timestamp.UTC().Format(time.RFC3339Nano),
+		hostname,
+		s.cfg.GetTag(),
+	)
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+func (s *syslogSender) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
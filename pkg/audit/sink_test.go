@@ -0,0 +1,236 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+func init() { sinkInitialBackoff = time.Millisecond }
+
+func TestWebhookSink(t *testing.T) {
+	var mux sync.Mutex
+	var requests int
+	received := make([]*Event, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		requests++
+		// the first batch has to be retried
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Test") != "test" {
+			t.Error("Expected configured header to be sent")
+		}
+		batch := make([]*Event, 0)
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		received = append(received, batch...)
+	}))
+	defer srv.Close()
+
+	backend, err := NewSinkBackend(&v1alpha1.AuditSinkConfig{
+		Name:      "test",
+		Webhook:   &v1alpha1.AuditWebhookConfig{URL: srv.URL, Headers: map[string]string{"X-Test": "test"}},
+		BatchSize: 2,
+	}, "test-cluster", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := &memoryBackend{}
+	logger := NewLogger("app-0", NewMultiBackend(primary, backend))
+	for i := 0; i < 3; i++ {
+		logger.Log(&Event{Timestamp: time.Now().UTC(), User: "admin", Status: 200})
+	}
+	logger.SetBackend(nil)
+
+	if len(primary.events) != 3 || !primary.closed {
+		t.Error("Expected events to be written to the primary backend, got:", len(primary.events))
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if requests != 3 {
+		t.Error("Expected a retry and two batches, got requests:", requests)
+	}
+	if len(received) != 3 {
+		t.Fatal("Expected all events to be forwarded, got:", len(received))
+	}
+	if err := Verify(received); err != nil {
+		t.Error("Expected forwarded events to verify, got:", err)
+	}
+}
+
+func TestSinkPermanentError(t *testing.T) {
+	var mux sync.Mutex
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	backend, err := NewSinkBackend(&v1alpha1.AuditSinkConfig{
+		Name:    "test",
+		Webhook: &v1alpha1.AuditWebhookConfig{URL: srv.URL},
+	}, "test-cluster", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Write(&Event{Sequence: 1})
+	backend.Close()
+	mux.Lock()
+	defer mux.Unlock()
+	if requests != 1 {
+		t.Error("Expected rejected batch to not be retried, got requests:", requests)
+	}
+}
+
+func TestSplunkSink(t *testing.T) {
+	var auth string
+	envelopes := make([]map[string]interface{}, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/collector/event" {
+			t.Error("Expected events to be sent to the event endpoint, got:", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		dec := json.NewDecoder(r.Body)
+		for dec.More() {
+			envelope := make(map[string]interface{})
+			if err := dec.Decode(&envelope); err != nil {
+				t.Error(err)
+				return
+			}
+			envelopes = append(envelopes, envelope)
+		}
+		w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer srv.Close()
+
+	backend, err := NewSinkBackend(&v1alpha1.AuditSinkConfig{
+		Name:   "splunk",
+		Splunk: &v1alpha1.AuditSplunkConfig{URL: srv.URL, Index: "security"},
+	}, "test-cluster", "hec-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Write(&Event{Sequence: 1, Instance: "app-0", Timestamp: time.Unix(1600000000, 0), User: "admin"})
+	backend.Write(&Event{Sequence: 2, Instance: "app-0", Timestamp: time.Unix(1600000001, 0), User: "admin"})
+	backend.Close()
+
+	if auth != "Splunk hec-token" {
+		t.Error("Expected HEC token to be sent, got:", auth)
+	}
+	if len(envelopes) != 2 {
+		t.Fatal("Expected two events in the batch, got:", len(envelopes))
+	}
+	envelope := envelopes[0]
+	if envelope["time"] != float64(1600000000) || envelope["host"] != "app-0" || envelope["index"] != "security" ||
+		envelope["source"] != "test-cluster" || envelope["sourcetype"] != "kvdi:audit" {
+		t.Error("Unexpected event envelope:", envelope)
+	}
+	if event, ok := envelope["event"].(map[string]interface{}); !ok || event["user"] != "admin" {
+		t.Error("Expected audit event in the envelope, got:", envelope["event"])
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	messages := make(chan string, 2)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rdr := bufio.NewReader(conn)
+		for {
+			// octet counted frames
+			length, err := rdr.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, err := strconv.Atoi(strings.TrimSpace(length))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(rdr, buf); err != nil {
+				return
+			}
+			messages <- string(buf)
+		}
+	}()
+
+	backend, err := NewSinkBackend(&v1alpha1.AuditSinkConfig{
+		Name:          "syslog",
+		Syslog:        &v1alpha1.AuditSyslogConfig{Address: l.Addr().String()},
+		FlushInterval: "10ms",
+	}, "test-cluster", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	ts := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+	backend.Write(&Event{Sequence: 1, Instance: "app-0", Timestamp: ts, User: "admin", Decision: DecisionDenied})
+
+	select {
+	case msg := <-messages:
+		// facility 13 (log audit), severity 4 (warning)
+		prefix := fmt.Sprintf("<108>1 %s app-0 kvdi - audit - {", ts.Format(time.RFC3339Nano))
+		if !strings.HasPrefix(msg, prefix) {
+			t.Error("Unexpected syslog message:", msg)
+		}
+		event := &Event{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(msg, prefix[:len(prefix)-1])), event); err != nil || event.User != "admin" {
+			t.Error("Expected audit event as the message, got:", msg, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected event to be sent to the syslog server")
+	}
+}
+
+func TestSinkValidation(t *testing.T) {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Spec.App = &v1alpha1.AppConfig{
+		Audit: &v1alpha1.AuditLogConfig{
+			Sinks: []v1alpha1.AuditSinkConfig{{
+				Name:    "invalid",
+				Syslog:  &v1alpha1.AuditSyslogConfig{Address: "127.0.0.1:514"},
+				Webhook: &v1alpha1.AuditWebhookConfig{URL: "http://127.0.0.1"},
+			}},
+		},
+	}
+	if _, err := GetBackend(cluster, nil); err == nil {
+		t.Error("Expected error for sink with multiple destinations")
+	}
+	cluster.Spec.App.Audit.Sinks[0].Webhook = nil
+	backend, err := GetBackend(cluster, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	if backend.Name() != "stdout" {
+		t.Error("Expected primary backend to be stdout, got:", backend.Name())
+	}
+}
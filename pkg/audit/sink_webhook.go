@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+// webhookSender POSTs batches of audit events to an HTTP endpoint as JSON arrays.
+type webhookSender struct {
+	cfg    *v1alpha1.AuditWebhookConfig
+	client *http.Client
+}
+
+func newWebhookSender(cfg *v1alpha1.AuditWebhookConfig) *webhookSender {
+	return &webhookSender{cfg: cfg, client: &http.Client{Timeout: cfg.GetTimeout()}}
+}
+
+func (w *webhookSender) send(events []*Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return &permanentError{err}
+	}
+	req, err := http.NewRequest(http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return checkSinkResponse("Audit webhook", res)
+}
+
+func (w *webhookSender) close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
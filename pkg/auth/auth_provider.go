@@ -5,9 +5,14 @@ package auth
 import (
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/auth/mfa/duo"
+	"github.com/tinyzimmer/kvdi/pkg/auth/mfa/webhook"
+	"github.com/tinyzimmer/kvdi/pkg/auth/providers/azuread"
+	"github.com/tinyzimmer/kvdi/pkg/auth/providers/cert"
 	"github.com/tinyzimmer/kvdi/pkg/auth/providers/ldap"
 	"github.com/tinyzimmer/kvdi/pkg/auth/providers/local"
 	"github.com/tinyzimmer/kvdi/pkg/auth/providers/oidc"
+	authwebhook "github.com/tinyzimmer/kvdi/pkg/auth/providers/webhook"
 	"github.com/tinyzimmer/kvdi/pkg/secrets"
 )
 
@@ -17,8 +22,30 @@ func GetAuthProvider(cluster *v1alpha1.VDICluster, s *secrets.SecretEngine) comm
 	if cluster.IsUsingLDAPAuth() {
 		return ldap.New(s)
 	}
+	if cluster.IsUsingAzureADAuth() {
+		return azuread.New(s)
+	}
 	if cluster.IsUsingOIDCAuth() {
 		return oidc.New(s)
 	}
+	if cluster.IsUsingCertAuth() {
+		return cert.New()
+	}
+	if cluster.IsUsingWebhookAuth() {
+		return authwebhook.New(s)
+	}
 	return local.New(s)
 }
+
+// GetMFAProvider returns the push MFA provider for the given VDICluster, or nil if
+// users authorize with one-time passwords. The secret engine passed to the provider
+// is assumed to already be setup.
+func GetMFAProvider(cluster *v1alpha1.VDICluster, s *secrets.SecretEngine) common.MFAProvider {
+	switch cluster.GetMFAProvider() {
+	case v1alpha1.MFAProviderDuo:
+		return duo.New(s)
+	case v1alpha1.MFAProviderWebhook:
+		return webhook.New(s)
+	}
+	return nil
+}
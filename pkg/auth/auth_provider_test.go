@@ -6,6 +6,7 @@ import (
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	"github.com/tinyzimmer/kvdi/pkg/auth/providers/local"
+	authwebhook "github.com/tinyzimmer/kvdi/pkg/auth/providers/webhook"
 )
 
 func TestGetAuthProvider(t *testing.T) {
@@ -14,3 +15,15 @@ func TestGetAuthProvider(t *testing.T) {
 		t.Error("Should have received a local auth provider")
 	}
 }
+
+func TestGetWebhookAuthProvider(t *testing.T) {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{WebhookAuth: &v1alpha1.WebhookAuthConfig{URL: "https://idp.example.com/login"}}
+	authProvider := GetAuthProvider(cluster, nil)
+	if reflect.TypeOf(authProvider) != reflect.TypeOf(&authwebhook.AuthProvider{}) {
+		t.Error("Should have received a webhook auth provider")
+	}
+	if backend := cluster.GetAuthBackend(); backend != v1alpha1.AuthBackendWebhook {
+		t.Error("Expected webhook auth backend, got:", backend)
+	}
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MFAProvider defines an interface for authorizing users by sending a push
+// notification to a device they enrolled with an external service, in place of
+// a one-time password.
+type MFAProvider interface {
+	// Setup is called when the kVDI app launches and whenever the VDICluster
+	// changes. It should be idempotent.
+	Setup(client.Client, *v1alpha1.VDICluster) error
+	// Push should send a push notification to the given user and return an ID for
+	// the transaction. The second argument is the address of the client logging in.
+	// An empty ID may be returned if the provider allows the user without approval.
+	Push(*v1.VDIUser, string) (string, error)
+	// Status should return the status of the transaction with the given ID.
+	Status(string) (v1.MFAPushStatus, error)
+}
@@ -29,6 +29,11 @@ type AuthProvider interface {
 	// Authenticate is called for API authentication requests. It should generate
 	// a new JWTClaims object and serve an AuthResult back to the API.
 	Authenticate(*v1.LoginRequest) (*v1.AuthResult, error)
+	// Refresh is called when a refresh token is used to renew a user's session. It
+	// should serve an AuthResult with the user's current information. The second
+	// argument is the ProviderRefreshToken from the AuthResult that issued the
+	// refresh token, if any.
+	Refresh(string, string) (*v1.AuthResult, error)
 	// GetUsers should return a list of VDIUsers.
 	GetUsers() ([]*v1.VDIUser, error)
 	// GetUser should retrieve a single VDIUser.
@@ -37,6 +42,30 @@ type AuthProvider interface {
 	CreateUser(*v1.CreateUserRequest) error
 	// UpdateUser should update a VDIUser.
 	UpdateUser(string, *v1.UpdateUserRequest) error
+	// ChangePassword should change the password for a VDIUser after verifying their
+	// current one. An InvalidCredentialsError should be returned if the current
+	// password is incorrect.
+	ChangePassword(string, *v1.ChangePasswordRequest) error
 	// DeleteUser should remove a VDIUser
 	DeleteUser(string) error
 }
+
+// HealthChecker is implemented by AuthProviders that depend on a remote service.
+// The result is included in the readiness checks of the app.
+type HealthChecker interface {
+	// CheckHealth should return an error if the provider is unable to serve
+	// requests.
+	CheckHealth() error
+}
+
+// LogoutHandler is implemented by AuthProviders that keep sessions of their own
+// for users, such as OIDC providers.
+type LogoutHandler interface {
+	// GetLogoutURL should return the URL to send the user to for ending their
+	// session with the provider, or an empty string if there isn't one.
+	GetLogoutURL(*v1.VDIUser) (string, error)
+	// VerifyLogoutToken should verify a logout token sent by the provider when a
+	// user's session with it ends, and return the name of the user. An empty name
+	// is returned if the user has no session in kVDI.
+	VerifyLogoutToken(string) (string, error)
+}
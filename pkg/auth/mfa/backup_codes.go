@@ -0,0 +1,148 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// NumBackupCodes is the number of backup codes generated for a user.
+const NumBackupCodes = 10
+
+// backupCodeChars are the characters used in backup codes. Characters that are
+// easily confused with each other are left out.
+const backupCodeChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GenerateBackupCodes creates a new set of backup codes for the given user,
+// replacing any that already exist. Only hashes of the codes are stored, so
+// the plain text codes returned here cannot be retrieved again.
+func (m *Manager) GenerateBackupCodes(name string) ([]string, error) {
+	codes := make([]string, NumBackupCodes)
+	hashes := make([]string, NumBackupCodes)
+	for i := range codes {
+		code, err := newBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashBackupCode(code)
+	}
+
+	if err := m.secrets.Lock(15); err != nil {
+		return nil, err
+	}
+	defer m.secrets.Release()
+	users, err := m.readBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	users[name] = []byte(strings.Join(hashes, "\n"))
+	if err := m.secrets.WriteSecretMap(v1.MFABackupCodesSecretKey, users); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// GetBackupCodesRemaining returns the number of unused backup codes for the
+// given user.
+func (m *Manager) GetBackupCodesRemaining(name string) (int, error) {
+	users, err := m.readBackupCodes()
+	if err != nil {
+		return 0, err
+	}
+	return len(splitBackupCodes(users[name])), nil
+}
+
+// UseBackupCode checks the given code against the user's unused backup codes.
+// If it matches, the code is invalidated and true is returned.
+func (m *Manager) UseBackupCode(name, code string) (bool, error) {
+	if err := m.secrets.Lock(15); err != nil {
+		return false, err
+	}
+	defer m.secrets.Release()
+	users, err := m.readBackupCodes()
+	if err != nil {
+		return false, err
+	}
+
+	hash := hashBackupCode(code)
+	hashes := splitBackupCodes(users[name])
+	remaining := make([]string, 0, len(hashes))
+	var matched bool
+	for _, h := range hashes {
+		if !matched && subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			matched = true
+			continue
+		}
+		remaining = append(remaining, h)
+	}
+	if !matched {
+		return false, nil
+	}
+
+	users[name] = []byte(strings.Join(remaining, "\n"))
+	return true, m.secrets.WriteSecretMap(v1.MFABackupCodesSecretKey, users)
+}
+
+// deleteBackupCodes removes the backup codes for the given user. The caller
+// should hold the secrets lock.
+func (m *Manager) deleteBackupCodes(name string) error {
+	users, err := m.readBackupCodes()
+	if err != nil {
+		return err
+	}
+	if _, ok := users[name]; !ok {
+		return nil
+	}
+	delete(users, name)
+	return m.secrets.WriteSecretMap(v1.MFABackupCodesSecretKey, users)
+}
+
+// readBackupCodes returns the mapping of users to their backup code hashes.
+func (m *Manager) readBackupCodes() (map[string][]byte, error) {
+	users, err := m.secrets.ReadSecretMap(v1.MFABackupCodesSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return map[string][]byte{}, nil
+		}
+		return nil, err
+	}
+	return users, nil
+}
+
+// newBackupCode returns a random backup code in the form of XXXXX-XXXXX.
+func newBackupCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var code strings.Builder
+	for i, b := range buf {
+		if i == len(buf)/2 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(backupCodeChars[int(b)%len(backupCodeChars)])
+	}
+	return code.String(), nil
+}
+
+// hashBackupCode returns the hex encoded sha256 of a backup code. Codes are
+// normalized first so they are accepted regardless of case or separators.
+func hashBackupCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitBackupCodes splits stored backup code hashes into a slice.
+func splitBackupCodes(data []byte) []string {
+	if len(data) == 0 {
+		return []string{}
+	}
+	return strings.Split(string(data), "\n")
+}
@@ -0,0 +1,70 @@
+package mfa
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	os.Setenv("POD_NAMESPACE", "default")
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(fake.NewFakeClient(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(engine)
+}
+
+func TestBackupCodes(t *testing.T) {
+	m := newTestManager(t)
+
+	codes, err := m.GenerateBackupCodes("test-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != NumBackupCodes {
+		t.Fatalf("Expected %d backup codes, got %d", NumBackupCodes, len(codes))
+	}
+	if remaining, err := m.GetBackupCodesRemaining("test-user"); err != nil || remaining != NumBackupCodes {
+		t.Fatal("Expected all backup codes to be remaining, got:", remaining, err)
+	}
+
+	// codes are accepted regardless of case or separators, but only once
+	code := strings.ToLower(strings.Replace(codes[0], "-", "", 1))
+	if used, err := m.UseBackupCode("test-user", code); err != nil || !used {
+		t.Fatal("Expected backup code to be accepted, got:", used, err)
+	}
+	if used, err := m.UseBackupCode("test-user", codes[0]); err != nil || used {
+		t.Error("Expected used backup code to be rejected, got:", used, err)
+	}
+	if remaining, _ := m.GetBackupCodesRemaining("test-user"); remaining != NumBackupCodes-1 {
+		t.Error("Expected one backup code to be invalidated, remaining:", remaining)
+	}
+
+	// codes belong to a single user
+	if used, err := m.UseBackupCode("other-user", codes[1]); err != nil || used {
+		t.Error("Expected backup code to be rejected for another user, got:", used, err)
+	}
+	if used, _ := m.UseBackupCode("test-user", ""); used {
+		t.Error("Expected empty backup code to be rejected")
+	}
+
+	// removing the user's MFA configuration removes their codes
+	if err := m.SetUserMFAStatus("test-user", "secret", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteUserSecret("test-user"); err != nil {
+		t.Fatal(err)
+	}
+	if used, err := m.UseBackupCode("test-user", codes[1]); err != nil || used {
+		t.Error("Expected backup codes to be removed with the user's secret, got:", used, err)
+	}
+}
@@ -0,0 +1,191 @@
+// Package duo contains an MFAProvider implementation that authorizes users with
+// a push from the Duo Auth API.
+package duo
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MFAProvider implements an MFAProvider that sends pushes with the Duo Auth API.
+type MFAProvider struct {
+	// the secrets engine where the Duo secret key is stored
+	secrets *secrets.SecretEngine
+	// the http client used for requests to Duo
+	httpClient *http.Client
+	// the API hostname of the Duo application
+	host string
+	// the base URL for requests, derived from the host
+	baseURL string
+	// the integration key of the Duo application
+	integrationKey string
+	// the secret key of the Duo application
+	secretKey string
+}
+
+// Blank assignment to make sure MFAProvider satisfies the interface.
+var _ common.MFAProvider = &MFAProvider{}
+
+// New returns a new Duo MFAProvider.
+func New(s *secrets.SecretEngine) common.MFAProvider {
+	return &MFAProvider{
+		secrets:    s,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Setup implements the MFAProvider interface and reads the credentials for the
+// Duo application.
+func (m *MFAProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) error {
+	cfg := cluster.GetDuoMFAConfig()
+	if cfg.APIHostname == "" || cfg.IntegrationKey == "" {
+		return errors.New("The Duo MFA provider requires an apiHostname and integrationKey")
+	}
+	secretKey, err := m.secrets.ReadSecret(cfg.GetSecretKeyKey(), true)
+	if err != nil {
+		return err
+	}
+	m.host = strings.ToLower(cfg.APIHostname)
+	m.baseURL = fmt.Sprintf("https://%s", m.host)
+	m.integrationKey = cfg.IntegrationKey
+	m.secretKey = string(secretKey)
+	return nil
+}
+
+// Push implements the MFAProvider interface. The user is checked with a preauth
+// first, so users Duo policy allows without approval are not sent a push.
+func (m *MFAProvider) Push(user *v1.VDIUser, clientAddr string) (string, error) {
+	params := url.Values{"username": {user.GetName()}}
+	if clientAddr != "" {
+		params.Set("ipaddr", clientAddr)
+	}
+
+	preauth := &preauthResponse{}
+	if err := m.call(http.MethodPost, "/auth/v2/preauth", params, preauth); err != nil {
+		return "", err
+	}
+	switch preauth.Result {
+	case "auth":
+	case "allow":
+		return "", nil
+	case "enroll":
+		return "", fmt.Errorf("User %s is not enrolled in Duo", user.GetName())
+	default:
+		return "", fmt.Errorf("Duo denied authentication for %s: %s", user.GetName(), preauth.StatusMsg)
+	}
+
+	params.Set("factor", "push")
+	params.Set("device", "auto")
+	params.Set("async", "1")
+	auth := &authResponse{}
+	if err := m.call(http.MethodPost, "/auth/v2/auth", params, auth); err != nil {
+		return "", err
+	}
+	if auth.TxID == "" {
+		return "", errors.New("Duo did not return a transaction for the push")
+	}
+	return auth.TxID, nil
+}
+
+// Status implements the MFAProvider interface.
+func (m *MFAProvider) Status(txID string) (v1.MFAPushStatus, error) {
+	status := &authStatusResponse{}
+	if err := m.call(http.MethodGet, "/auth/v2/auth_status", url.Values{"txid": {txID}}, status); err != nil {
+		return "", err
+	}
+	switch status.Result {
+	case "allow":
+		return v1.MFAPushApproved, nil
+	case "waiting":
+		return v1.MFAPushPending, nil
+	default:
+		return v1.MFAPushDenied, nil
+	}
+}
+
+// call makes a signed request to the Duo Auth API and decodes the response into out.
+func (m *MFAProvider) call(method, path string, params url.Values, out interface{}) error {
+	body := canonicalParams(params)
+	date := time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 -0700")
+
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, fmt.Sprintf("%s%s?%s", m.baseURL, path, body), nil)
+	} else {
+		req, err = http.NewRequest(method, m.baseURL+path, strings.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Date", date)
+	req.SetBasicAuth(m.integrationKey, m.sign(date, method, path, body))
+
+	res, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	duoRes := &response{Response: out}
+	if err := json.Unmarshal(raw, duoRes); err != nil {
+		return fmt.Errorf("Failed to decode Duo response with status %d: %s", res.StatusCode, err.Error())
+	}
+	if duoRes.Stat != "OK" {
+		return fmt.Errorf("Duo request failed with code %d: %s", duoRes.Code, duoRes.Message)
+	}
+	return nil
+}
+
+// sign returns the signature for a request to the Duo Auth API.
+func (m *MFAProvider) sign(date, method, path, params string) string {
+	canon := strings.Join([]string{date, strings.ToUpper(method), m.host, path, params}, "\n")
+	mac := hmac.New(sha1.New, []byte(m.secretKey))
+	mac.Write([]byte(canon))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// canonicalParams encodes the given parameters sorted by key with spaces escaped
+// as %20, the way Duo expects them when verifying signatures.
+func canonicalParams(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range params[key] {
+			parts = append(parts, fmt.Sprintf("%s=%s", escape(key), escape(value)))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
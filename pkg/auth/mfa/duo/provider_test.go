@@ -0,0 +1,91 @@
+package duo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+)
+
+func TestCanonicalParams(t *testing.T) {
+	params := url.Values{
+		"username": {"test user"},
+		"async":    {"1"},
+		"ipaddr":   {"10.0.0.1"},
+		"factor":   {"push"},
+	}
+	expected := "async=1&factor=push&ipaddr=10.0.0.1&username=test%20user"
+	if out := canonicalParams(params); out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestPush(t *testing.T) {
+	m := &MFAProvider{httpClient: http.DefaultClient, integrationKey: "ikey", secretKey: "skey"}
+	// signs requests the way Duo does when verifying them
+	verifier := &MFAProvider{secretKey: "skey"}
+
+	results := map[string]interface{}{
+		"/auth/v2/preauth":     map[string]string{"result": "auth"},
+		"/auth/v2/auth":        map[string]string{"txid": "tx-1"},
+		"/auth/v2/auth_status": map[string]string{"result": "waiting"},
+	}
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params string
+		if r.Method == http.MethodGet {
+			params = r.URL.RawQuery
+		} else {
+			if err := r.ParseForm(); err != nil {
+				t.Error(err)
+			}
+			params = canonicalParams(r.PostForm)
+		}
+		// verify the request was signed for the parameters received
+		ikey, sig, ok := r.BasicAuth()
+		if !ok || ikey != "ikey" || sig != verifier.sign(r.Header.Get("Date"), r.Method, r.URL.Path, params) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"stat": "FAIL", "code": 40101, "message": "Missing request credentials"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"stat": "OK", "response": results[r.URL.Path]})
+	}))
+	defer srvr.Close()
+	m.host, m.baseURL = strings.TrimPrefix(srvr.URL, "http://"), srvr.URL
+	verifier.host = m.host
+
+	user := &v1.VDIUser{Name: "test-user"}
+	txID, err := m.Push(user, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txID != "tx-1" {
+		t.Error("Expected transaction tx-1, got:", txID)
+	}
+	if status, err := m.Status(txID); err != nil {
+		t.Fatal(err)
+	} else if status != v1.MFAPushPending {
+		t.Error("Expected pending status, got:", status)
+	}
+
+	// users allowed by policy are not sent a push
+	results["/auth/v2/preauth"] = map[string]string{"result": "allow"}
+	if txID, err := m.Push(user, ""); err != nil || txID != "" {
+		t.Error("Expected user to be allowed without a push, got:", txID, err)
+	}
+
+	// unenrolled users are rejected
+	results["/auth/v2/preauth"] = map[string]string{"result": "enroll"}
+	if _, err := m.Push(user, ""); err == nil {
+		t.Error("Expected error for unenrolled user, got nil")
+	}
+
+	// requests with bad signatures fail
+	m.secretKey = "wrong"
+	if _, err := m.Status("tx-1"); err == nil || !strings.Contains(err.Error(), "40101") {
+		t.Error("Expected signature error, got:", err)
+	}
+}
@@ -0,0 +1,32 @@
+package duo
+
+// response is the envelope for all responses from the Duo Auth API.
+type response struct {
+	// OK or FAIL
+	Stat string `json:"stat"`
+	// the error code when the request failed
+	Code int `json:"code"`
+	// the error message when the request failed
+	Message string `json:"message"`
+	// the result of a successful request
+	Response interface{} `json:"response"`
+}
+
+// preauthResponse is the result of a preauth request.
+type preauthResponse struct {
+	// One of auth, allow, deny, or enroll
+	Result    string `json:"result"`
+	StatusMsg string `json:"status_msg"`
+}
+
+// authResponse is the result of an asynchronous auth request.
+type authResponse struct {
+	TxID string `json:"txid"`
+}
+
+// authStatusResponse is the result of an auth_status request.
+type authStatusResponse struct {
+	// One of allow, deny, or waiting
+	Result    string `json:"result"`
+	StatusMsg string `json:"status_msg"`
+}
@@ -90,12 +90,15 @@ func (m *Manager) SetUserMFAStatus(name, secret string, verified bool) error {
 	return m.secrets.WriteSecret(v1.OTPUsersSecretKey, newData)
 }
 
-// DeleteUserSecret will remove OTP data for the given username.
+// DeleteUserSecret will remove OTP data and backup codes for the given username.
 func (m *Manager) DeleteUserSecret(name string) error {
 	if err := m.secrets.Lock(15); err != nil {
 		return err
 	}
 	defer m.secrets.Release()
+	if err := m.deleteBackupCodes(name); err != nil {
+		return err
+	}
 	users, err := m.secrets.ReadSecret(v1.OTPUsersSecretKey, false)
 	if err != nil && !errors.IsSecretNotFoundError(err) {
 		return err
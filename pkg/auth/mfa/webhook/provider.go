@@ -0,0 +1,136 @@
+// Package webhook contains an MFAProvider implementation that delegates sending
+// pushes and tracking their approval to an HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MFAProvider implements an MFAProvider that sends pushes through a webhook.
+type MFAProvider struct {
+	// the secrets engine where the bearer token is stored
+	secrets *secrets.SecretEngine
+	// the configuration for the webhook
+	cfg *v1alpha1.MFAWebhookConfig
+	// the http client used for requests to the webhook
+	httpClient *http.Client
+	// the bearer token sent with requests, if any
+	token string
+}
+
+// Blank assignment to make sure MFAProvider satisfies the interface.
+var _ common.MFAProvider = &MFAProvider{}
+
+// New returns a new webhook MFAProvider.
+func New(s *secrets.SecretEngine) common.MFAProvider {
+	return &MFAProvider{secrets: s}
+}
+
+// pushRequest is the body POSTed to the webhook to send a push.
+type pushRequest struct {
+	// The name of the user to send the push to
+	User string `json:"user"`
+	// The address of the client logging in
+	ClientAddr string `json:"clientAddr,omitempty"`
+}
+
+// pushResponse is the response from the webhook after sending a push.
+type pushResponse struct {
+	// The ID of the transaction to poll
+	ID string `json:"id"`
+}
+
+// statusResponse is the response from the webhook when polling a transaction.
+type statusResponse struct {
+	// One of pending, approved, or denied
+	Status v1.MFAPushStatus `json:"status"`
+}
+
+// Setup implements the MFAProvider interface and reads the bearer token for the
+// webhook if one is configured.
+func (m *MFAProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) error {
+	cfg := cluster.GetMFAWebhookConfig()
+	if cfg.URL == "" {
+		return errors.New("The webhook MFA provider requires a url")
+	}
+	token, err := m.secrets.ReadSecret(cfg.GetTokenKey(), true)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return err
+		}
+		token = nil
+	}
+	m.cfg = cfg
+	m.httpClient = &http.Client{Timeout: cfg.GetTimeout()}
+	m.token = string(token)
+	return nil
+}
+
+// Push implements the MFAProvider interface.
+func (m *MFAProvider) Push(user *v1.VDIUser, clientAddr string) (string, error) {
+	body, err := json.Marshal(&pushRequest{User: user.GetName(), ClientAddr: clientAddr})
+	if err != nil {
+		return "", err
+	}
+	res := &pushResponse{}
+	if err := m.do(http.MethodPost, m.cfg.URL, body, res); err != nil {
+		return "", err
+	}
+	if res.ID == "" {
+		return "", errors.New("MFA webhook did not return a transaction id")
+	}
+	return res.ID, nil
+}
+
+// Status implements the MFAProvider interface.
+func (m *MFAProvider) Status(txID string) (v1.MFAPushStatus, error) {
+	res := &statusResponse{}
+	if err := m.do(http.MethodGet, fmt.Sprintf("%s/%s", strings.TrimSuffix(m.cfg.URL, "/"), url.PathEscape(txID)), nil, res); err != nil {
+		return "", err
+	}
+	switch res.Status {
+	case v1.MFAPushApproved, v1.MFAPushPending, v1.MFAPushDenied:
+		return res.Status, nil
+	default:
+		return "", fmt.Errorf("MFA webhook returned an invalid status: %q", res.Status)
+	}
+}
+
+// do makes a request to the webhook and decodes the JSON response into out.
+func (m *MFAProvider) do(method, endpoint string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range m.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if m.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.token))
+	}
+	res, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("MFA webhook returned status %d", res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,130 @@
+package azuread
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"golang.org/x/oauth2"
+)
+
+// groupsClaim is the claim Azure AD places the object IDs of groups in.
+const groupsClaim = "groups"
+
+// tokenExpiryDelta is how long before it expires the Graph token is renewed.
+const tokenExpiryDelta = time.Minute
+
+// resolveGroups returns the object IDs of the groups the user belongs to. They are
+// read from the groups claim when Azure AD includes it in the ID token. Otherwise,
+// such as when the user is in more groups than fit in a token, they are looked up
+// in Microsoft Graph.
+func (a *AuthProvider) resolveGroups(claims map[string]interface{}) ([]string, bool, error) {
+	if groups, ok := claims[groupsClaim]; ok && !hasGroupOverage(claims) {
+		groupSlc, err := toStringSlice(groups)
+		if err != nil {
+			return nil, false, err
+		}
+		return groupSlc, len(groupSlc) > 0, nil
+	}
+	objectID, _ := claims["oid"].(string)
+	if objectID == "" {
+		return nil, false, errors.New("The ID token does not contain the object ID of the user, make sure the profile scope is requested")
+	}
+	groups, err := a.getMemberGroups(objectID)
+	if err != nil {
+		return nil, false, err
+	}
+	return groups, len(groups) > 0, nil
+}
+
+// hasGroupOverage returns true if Azure AD left the groups out of the claims because
+// the user is in too many of them. It signals this with a reference to the groups in
+// the `_claim_names` claim, or with the `hasgroups` claim in tokens from the implicit
+// flow.
+func hasGroupOverage(claims map[string]interface{}) bool {
+	if hasGroups, ok := claims["hasgroups"].(bool); ok && hasGroups {
+		return true
+	}
+	names, ok := claims["_claim_names"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = names[groupsClaim]
+	return ok
+}
+
+// getMemberGroups looks up the object IDs of all the groups the user with the given
+// object ID is a member of, including through nested groups.
+func (a *AuthProvider) getMemberGroups(objectID string) ([]string, error) {
+	token, err := a.getGraphToken()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]bool{"securityEnabledOnly": a.cluster.GetAzureADSecurityGroupsOnly()})
+	if err != nil {
+		return nil, err
+	}
+	reqURL := fmt.Sprintf("%s/v1.0/users/%s/getMemberGroups", a.cluster.GetAzureADGraphURL(), url.PathEscape(objectID))
+	req, err := http.NewRequest(http.MethodPost, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token.SetAuthHeader(req)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Microsoft Graph returned %s when looking up the groups of %s", resp.Status, objectID)
+	}
+
+	result := struct {
+		Value []string `json:"value"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// getGraphToken returns an access token for Microsoft Graph, requesting a new one
+// with the client credentials of the application when the last one is close to
+// expiring.
+func (a *AuthProvider) getGraphToken() (*oauth2.Token, error) {
+	a.tokenMux.Lock()
+	defer a.tokenMux.Unlock()
+	if a.graphToken != nil && a.graphToken.Expiry.After(time.Now().Add(tokenExpiryDelta)) {
+		return a.graphToken, nil
+	}
+	token, err := a.ClientCredentialsToken(a.cluster.GetAzureADGraphURL() + "/.default")
+	if err != nil {
+		return nil, err
+	}
+	a.graphToken = token
+	return token, nil
+}
+
+// toStringSlice converts a claim containing a list of strings to a string slice.
+func toStringSlice(ifc interface{}) ([]string, error) {
+	items, ok := ifc.([]interface{})
+	if !ok {
+		return nil, errors.New("Could not coerce groups claim to string slice")
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		str, ok := item.(string)
+		if !ok {
+			return nil, errors.New("Could not coerce slice item to string")
+		}
+		out = append(out, str)
+	}
+	return out, nil
+}
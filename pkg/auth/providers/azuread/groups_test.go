@@ -0,0 +1,112 @@
+package azuread
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResolveGroups(t *testing.T) {
+	var tokenRequests, graphRequests int
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"jwks_uri":               srv.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		id, secret, _ := r.BasicAuth()
+		if id != "kvdi" || secret != "kvdi-secret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != srv.URL+"/.default" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "graph-token", "token_type": "Bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("/v1.0/users/user-oid/getMemberGroups", func(w http.ResponseWriter, r *http.Request) {
+		graphRequests++
+		body := make(map[string]bool)
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("Authorization") != "Bearer graph-token" || !body["securityEnabledOnly"] {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": []string{"group-1", "group-2"}})
+	})
+
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{
+		OIDCAuth: &v1alpha1.OIDCConfig{
+			IssuerURL:   srv.URL,
+			RedirectURL: "https://kvdi.local/api/login",
+			AzureAD: &v1alpha1.AzureADConfig{
+				GraphURL:           srv.URL + "/",
+				SecurityGroupsOnly: true,
+			},
+		},
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	engine := secrets.GetSecretEngine(cluster)
+	c := fake.NewFakeClient()
+	if err := engine.Setup(c, cluster); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{"oidc-clientid": "kvdi", "oidc-clientsecret": "kvdi-secret"} {
+		if err := engine.WriteSecret(key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := New(engine).(*AuthProvider)
+	if err := a.Setup(c, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	// groups in the token are used as is
+	groups, ok, err := a.resolveGroups(map[string]interface{}{"oid": "user-oid", "groups": []interface{}{"group-3"}})
+	if err != nil || !ok || !reflect.DeepEqual(groups, []string{"group-3"}) {
+		t.Error("Expected the groups from the token, got:", groups, ok, err)
+	}
+	if graphRequests != 0 {
+		t.Error("Expected no requests to Microsoft Graph, got:", graphRequests)
+	}
+
+	// groups are looked up when they are left out of the token, and the graph
+	// token is reused
+	for _, claims := range []map[string]interface{}{
+		{"oid": "user-oid", "_claim_names": map[string]interface{}{"groups": "src1"}, "_claim_sources": map[string]interface{}{}},
+		{"oid": "user-oid", "hasgroups": true},
+		{"oid": "user-oid"},
+	} {
+		groups, ok, err := a.resolveGroups(claims)
+		if err != nil || !ok || !reflect.DeepEqual(groups, []string{"group-1", "group-2"}) {
+			t.Error("Expected the groups from Microsoft Graph, got:", groups, ok, err)
+		}
+	}
+	if graphRequests != 3 || tokenRequests != 1 {
+		t.Error("Expected three graph requests with one token, got:", graphRequests, tokenRequests)
+	}
+
+	// the object id is required to look up groups
+	if _, _, err := a.resolveGroups(map[string]interface{}{"hasgroups": true}); err == nil {
+		t.Error("Expected error for token without an object ID")
+	}
+	// errors from graph are returned
+	if _, _, err := a.resolveGroups(map[string]interface{}{"oid": "other-oid"}); err == nil {
+		t.Error("Expected error for unknown user")
+	}
+}
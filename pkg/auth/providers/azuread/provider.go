@@ -0,0 +1,66 @@
+// Package azuread contains an AuthProvider implementation backed by Azure AD. It
+// builds on the OIDC provider, and resolves the groups of users through Microsoft
+// Graph when Azure AD leaves them out of ID tokens.
+package azuread
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/auth/providers/oidc"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/oauth2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// graphRequestTimeout is how long to wait for responses from Microsoft Graph.
+const graphRequestTimeout = 10 * time.Second
+
+// AuthProvider implements an auth provider that uses Azure AD as the authentication
+// backend. Users log in with the OIDC flow, and the object IDs of their groups are
+// bound to VDIRoles through the same annotation as the OIDC provider.
+type AuthProvider struct {
+	*oidc.AuthProvider
+
+	// our cluster instance
+	cluster *v1alpha1.VDICluster
+	// the http client used for requests to Microsoft Graph
+	httpClient *http.Client
+	// the access token of the application for Microsoft Graph
+	graphToken *oauth2.Token
+	// locks the graphToken
+	tokenMux sync.Mutex
+}
+
+// Blank assignments to make sure AuthProvider satisfies the interfaces.
+var _ common.AuthProvider = &AuthProvider{}
+var _ common.HealthChecker = &AuthProvider{}
+var _ common.LogoutHandler = &AuthProvider{}
+
+// New returns a new Azure AD AuthProvider.
+func New(s *secrets.SecretEngine) common.AuthProvider {
+	a := &AuthProvider{httpClient: &http.Client{Timeout: graphRequestTimeout}}
+	a.AuthProvider = oidc.NewWithGroupResolver(s, a.resolveGroups)
+	return a
+}
+
+// Setup implements the AuthProvider interface and sets up the underlying OIDC
+// provider. Any cached Graph token is discarded, since the credentials may have
+// changed.
+func (a *AuthProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) error {
+	a.cluster = cluster
+	a.tokenMux.Lock()
+	a.graphToken = nil
+	a.tokenMux.Unlock()
+	return a.AuthProvider.Setup(c, cluster)
+}
+
+// Reconcile just makes sure that we have everything needed to perform an OIDC flow.
+func (a *AuthProvider) Reconcile(reqLogger logr.Logger, c client.Client, cluster *v1alpha1.VDICluster, adminPass string) error {
+	return a.Setup(c, cluster)
+}
@@ -0,0 +1,148 @@
+package cert
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Authenticate is called for API authentication requests. The username and password
+// in the request are ignored, and the user is built from the verified client certificate.
+// The certificate chain is returned as the provider refresh token so it can be verified
+// again when the session is renewed.
+func (a *AuthProvider) Authenticate(req *v1.LoginRequest) (*v1.AuthResult, error) {
+	r := req.GetRequest()
+	if r.Method != http.MethodPost {
+		return nil, errors.New("Redirect flows are not supported when using client certificate authentication")
+	}
+
+	chain, err := a.getRequestCertificates(r)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.getUserFromChain(chain, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &v1.AuthResult{
+		User:                 user,
+		ProviderRefreshToken: encodeChain(chain),
+	}, nil
+}
+
+// Refresh verifies the certificate that the session was started with again and rebuilds
+// the user's roles from it. Changes to the VDIRoles bound to the certificate are picked
+// up, and the session cannot be renewed past the expiry of the certificate.
+func (a *AuthProvider) Refresh(username, providerToken string) (*v1.AuthResult, error) {
+	if providerToken == "" {
+		return nil, errors.New("No client certificate was recorded for this session")
+	}
+	chain, err := decodeChain(providerToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.getUserFromChain(chain, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if user.GetName() != username {
+		return nil, fmt.Errorf("The client certificate is for %s and not %s", user.GetName(), username)
+	}
+	return &v1.AuthResult{
+		User:                 user,
+		ProviderRefreshToken: providerToken,
+	}, nil
+}
+
+// getRequestCertificates returns the client certificate chain for the given request,
+// with the leaf certificate first.
+func (a *AuthProvider) getRequestCertificates(r *http.Request) ([]*x509.Certificate, error) {
+	if header := a.cluster.GetCertTrustedHeader(); header != "" {
+		value := r.Header.Get(header)
+		if value == "" {
+			return nil, errors.New("No client certificate was forwarded in the request")
+		}
+		return parseHeaderCertificates(value)
+	}
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, errors.New("No client certificate was presented with the request")
+	}
+	return r.TLS.PeerCertificates, nil
+}
+
+// getUserFromChain verifies the given certificate chain against the client CAs and
+// builds a VDIUser from the leaf certificate, binding its attributes to VDIRoles.
+func (a *AuthProvider) getUserFromChain(chain []*x509.Certificate, now time.Time) (*v1.VDIUser, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("No client certificate was provided")
+	}
+	leaf := chain[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         a.roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, err
+	}
+
+	username, err := getCertUsername(leaf, a.cluster.GetCertUsernameAttribute())
+	if err != nil {
+		return nil, err
+	}
+	user := &v1.VDIUser{
+		Name:  username,
+		Roles: make([]*v1.VDIUserRole, 0),
+	}
+
+	roles, err := a.cluster.GetResolvedRoles(a.client)
+	if err != nil {
+		return nil, err
+	}
+
+	userGroups := getCertGroups(leaf)
+	boundRoles := make([]string, 0)
+	for _, role := range roles {
+		boundRoles = appendRoleIfBound(boundRoles, userGroups, role)
+	}
+
+	if len(boundRoles) == 0 {
+		// check if cluster configuration allows the user in anyway.
+		if a.cluster.AllowNonGroupedCertReadOnly() {
+			user.Roles = []*v1.VDIUserRole{a.cluster.GetLaunchTemplatesRole().ToUserRole()}
+			return user, nil
+		}
+		return nil, fmt.Errorf("The client certificate for %s is not bound to any roles", username)
+	}
+
+	user.Roles = apiutil.FilterUserRolesByNames(roles, boundRoles)
+	return user, nil
+}
+
+func appendRoleIfBound(boundRoles, userGroups []string, role v1alpha1.VDIRole) []string {
+	if annotations := role.GetAnnotations(); annotations != nil {
+		if certGroupStr, ok := annotations[v1.CertGroupRoleAnnotation]; ok {
+			for _, group := range strings.Split(certGroupStr, v1.AuthGroupSeparator) {
+				if group == "" {
+					continue
+				}
+				if common.StringSliceContains(userGroups, group) {
+					boundRoles = common.AppendStringIfMissing(boundRoles, role.GetName())
+				}
+			}
+		}
+	}
+	return boundRoles
+}
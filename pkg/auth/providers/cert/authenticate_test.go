@@ -0,0 +1,257 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis"
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test PIV CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// upnExtension returns a SAN extension containing an email address and a UPN, since
+// the x509 package cannot create otherNames.
+func upnExtension(t *testing.T, email, upn string) pkix.Extension {
+	t.Helper()
+	upnValue, err := asn1.MarshalWithParams(upn, "utf8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := asn1.MarshalWithParams(otherName{
+		TypeID: oidUPN,
+		Value:  asn1.RawValue{Class: asn1.ClassContextSpecific, IsCompound: true, Bytes: upnValue},
+	}, "tag:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emailValue, err := asn1.MarshalWithParams(email, "tag:1,ia5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	san, err := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSequence,
+		IsCompound: true,
+		Bytes:      append(emailValue, other...),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oidSubjectAltName, Value: san}
+}
+
+func (ca *testCA) issue(t *testing.T, cn string, ous []string, notAfter time.Time, usage x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(time.Now().UnixNano()),
+		Subject:         pkix.Name{CommonName: cn, OrganizationalUnit: ous},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        notAfter,
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{usage},
+		ExtraExtensions: []pkix.Extension{upnExtension(t, cn+"@example.mil", "1234567890@mil")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func newTestProvider(t *testing.T, ca *testCA, cfg *v1alpha1.CertAuthConfig) *AuthProvider {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cfg.ClientCACert = base64.StdEncoding.EncodeToString(ca.pem())
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{CertAuth: cfg}
+
+	newRole := func(name, groups string) *v1alpha1.VDIRole {
+		role := &v1alpha1.VDIRole{}
+		role.Name = name
+		role.Labels = map[string]string{v1.RoleClusterRefLabel: cluster.GetName()}
+		role.Annotations = map[string]string{v1.CertGroupRoleAnnotation: groups}
+		return role
+	}
+	a := New().(*AuthProvider)
+	if err := a.Setup(fake.NewFakeClientWithScheme(scheme,
+		newRole("engineers", "Engineering"),
+		newRole("operators", "ops@example.mil;1234567890@mil"),
+		newRole("auditors", "Audit"),
+	), cluster); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func newLoginRequest(r *http.Request) *v1.LoginRequest {
+	req := &v1.LoginRequest{Username: "anonymous"}
+	req.SetRequest(r)
+	return req
+}
+
+func TestAuthenticate(t *testing.T) {
+	ca := newTestCA(t)
+	a := newTestProvider(t, ca, &v1alpha1.CertAuthConfig{})
+	cert := ca.issue(t, "jdoe", []string{"Engineering"}, time.Now().Add(time.Hour), x509.ExtKeyUsageClientAuth)
+
+	r, _ := http.NewRequest(http.MethodPost, "/api/login", nil)
+	if _, err := a.Authenticate(newLoginRequest(r)); err == nil {
+		t.Error("Expected error when no certificate is presented")
+	}
+
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	result, err := a.Authenticate(newLoginRequest(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.User.GetName() != "jdoe" {
+		t.Error("Expected username from the common name, got:", result.User.GetName())
+	}
+	roles := make([]string, 0)
+	for _, role := range result.User.Roles {
+		roles = append(roles, role.Name)
+	}
+	if len(roles) != 2 || roles[0] != "engineers" || roles[1] != "operators" {
+		t.Error("Got wrong roles for certificate:", roles)
+	}
+
+	// The session can be renewed with the recorded certificate
+	refreshed, err := a.Refresh("jdoe", result.ProviderRefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refreshed.User.Roles) != 2 {
+		t.Error("Expected roles to be rebuilt on refresh, got:", refreshed.User.Roles)
+	}
+	if _, err := a.Refresh("someone-else", result.ProviderRefreshToken); err == nil {
+		t.Error("Expected error refreshing the session of a different user")
+	}
+
+	// Certificates from other CAs, expired certificates, and those not for client
+	// auth are rejected
+	for _, bad := range []*x509.Certificate{
+		newTestCA(t).issue(t, "jdoe", []string{"Engineering"}, time.Now().Add(time.Hour), x509.ExtKeyUsageClientAuth),
+		ca.issue(t, "jdoe", []string{"Engineering"}, time.Now().Add(-time.Minute), x509.ExtKeyUsageClientAuth),
+		ca.issue(t, "jdoe", []string{"Engineering"}, time.Now().Add(time.Hour), x509.ExtKeyUsageServerAuth),
+	} {
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{bad}}
+		if _, err := a.Authenticate(newLoginRequest(r)); err == nil {
+			t.Error("Expected error for invalid certificate")
+		}
+	}
+}
+
+func TestAuthenticateTrustedHeader(t *testing.T) {
+	ca := newTestCA(t)
+	a := newTestProvider(t, ca, &v1alpha1.CertAuthConfig{
+		TrustedHeader:     "ssl-client-cert",
+		UsernameAttribute: v1alpha1.CertUsernameUPN,
+	})
+	cert := ca.issue(t, "jdoe", []string{"Sales"}, time.Now().Add(time.Hour), x509.ExtKeyUsageClientAuth)
+
+	r, _ := http.NewRequest(http.MethodPost, "/api/login", nil)
+	// Certificates on the connection are ignored in header mode
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if _, err := a.Authenticate(newLoginRequest(r)); err == nil {
+		t.Error("Expected error when the header is missing")
+	}
+
+	r.Header.Set("ssl-client-cert", url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))))
+	result, err := a.Authenticate(newLoginRequest(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.User.GetName() != "1234567890" {
+		t.Error("Expected username from the UPN, got:", result.User.GetName())
+	}
+	if len(result.User.Roles) != 1 || result.User.Roles[0].Name != "operators" {
+		t.Error("Expected certificate to be bound by its UPN, got:", result.User.Roles)
+	}
+}
+
+func TestAuthenticateNonGrouped(t *testing.T) {
+	ca := newTestCA(t)
+	cert := ca.issue(t, "guest", []string{"Visitors"}, time.Now().Add(time.Hour), x509.ExtKeyUsageClientAuth)
+	// Drop the SAN so that only the OU can bind the certificate
+	cert.EmailAddresses = nil
+	cert.Extensions = nil
+
+	a := newTestProvider(t, ca, &v1alpha1.CertAuthConfig{})
+	if _, err := a.getUserFromChain([]*x509.Certificate{cert}, time.Now()); err == nil {
+		t.Error("Expected error for certificate not bound to any roles")
+	}
+
+	a = newTestProvider(t, ca, &v1alpha1.CertAuthConfig{AllowNonGroupedReadOnly: true})
+	user, err := a.getUserFromChain([]*x509.Certificate{cert}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Roles) != 1 || user.Roles[0].Name != "test-cluster-launch-templates" {
+		t.Error("Expected launch-templates role for non-grouped user, got:", user.Roles)
+	}
+
+	if _, err := getCertUsername(cert, v1alpha1.CertUsernameEmail); err == nil {
+		t.Error("Expected error for certificate without an email address")
+	}
+}
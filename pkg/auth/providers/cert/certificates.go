@@ -0,0 +1,164 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"net/url"
+	"strings"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+var (
+	// oidSubjectAltName is the object identifier of the subject alternative name extension
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	// oidUPN is the object identifier of the Microsoft user principal name found in the
+	// SAN of PIV/CAC certificates
+	oidUPN = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 3}
+)
+
+// otherName represents an otherName entry in a subject alternative name. The value
+// is explicitly tagged [0].
+type otherName struct {
+	TypeID asn1.ObjectIdentifier
+	Value  asn1.RawValue
+}
+
+// parseHeaderCertificates parses the certificates forwarded by an ingress in a header.
+// The value is expected to be URL encoded PEM, but raw PEM and base64 encoded DER
+// are accepted as well.
+func parseHeaderCertificates(value string) ([]*x509.Certificate, error) {
+	if unescaped, err := url.QueryUnescape(value); err == nil {
+		value = unescaped
+	}
+	data := []byte(value)
+	certs := make([]*x509.Certificate, 0)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) > 0 {
+		return certs, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, errors.New("Could not parse the client certificate from the request header")
+	}
+	return x509.ParseCertificates(der)
+}
+
+// encodeChain encodes the given certificates so they can be parsed again with
+// decodeChain.
+func encodeChain(chain []*x509.Certificate) string {
+	der := make([]byte, 0)
+	for _, cert := range chain {
+		der = append(der, cert.Raw...)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+// decodeChain parses certificates encoded with encodeChain.
+func decodeChain(encoded string) ([]*x509.Certificate, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificates(der)
+}
+
+// getUPNs returns the user principal names in the subject alternative names of the
+// given certificate.
+func getUPNs(cert *x509.Certificate) []string {
+	upns := make([]string, 0)
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var seq asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &seq); err != nil || !seq.IsCompound || seq.Tag != asn1.TagSequence {
+			return upns
+		}
+		rest := seq.Bytes
+		for len(rest) > 0 {
+			var name asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &name); err != nil {
+				return upns
+			}
+			// otherName is the implicitly tagged [0] choice of GeneralName
+			if name.Class != asn1.ClassContextSpecific || name.Tag != 0 {
+				continue
+			}
+			var other otherName
+			if _, err := asn1.UnmarshalWithParams(name.FullBytes, &other, "tag:0"); err != nil {
+				continue
+			}
+			if !other.TypeID.Equal(oidUPN) || other.Value.Class != asn1.ClassContextSpecific || other.Value.Tag != 0 {
+				continue
+			}
+			var upn string
+			if _, err := asn1.Unmarshal(other.Value.Bytes, &upn); err != nil {
+				continue
+			}
+			upns = append(upns, upn)
+		}
+	}
+	return upns
+}
+
+// getCertGroups returns the attributes of the given certificate that can be bound
+// to VDIRoles. These are the organizational units in the subject, and the email
+// addresses, UPNs, and URIs in the subject alternative names.
+func getCertGroups(cert *x509.Certificate) []string {
+	groups := make([]string, 0)
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		groups = common.AppendStringIfMissing(groups, ou)
+	}
+	for _, email := range cert.EmailAddresses {
+		groups = common.AppendStringIfMissing(groups, email)
+	}
+	for _, upn := range getUPNs(cert) {
+		groups = common.AppendStringIfMissing(groups, upn)
+	}
+	for _, uri := range cert.URIs {
+		groups = common.AppendStringIfMissing(groups, uri.String())
+	}
+	return groups
+}
+
+// getCertUsername returns the username for the given certificate using the given
+// attribute.
+func getCertUsername(cert *x509.Certificate, attr v1alpha1.CertUsernameAttribute) (string, error) {
+	var username string
+	switch attr {
+	case v1alpha1.CertUsernameEmail:
+		if len(cert.EmailAddresses) > 0 {
+			username = strings.Split(cert.EmailAddresses[0], "@")[0]
+		}
+	case v1alpha1.CertUsernameUPN:
+		if upns := getUPNs(cert); len(upns) > 0 {
+			username = strings.Split(upns[0], "@")[0]
+		}
+	default:
+		username = cert.Subject.CommonName
+	}
+	if username == "" {
+		return "", errors.New("Could not find the " + string(attr) + " of the client certificate")
+	}
+	return username, nil
+}
@@ -0,0 +1,65 @@
+// Package cert contains an AuthProvider implementation backed by client certificates,
+// such as those on PIV/CAC smart cards.
+package cert
+
+import (
+	"crypto/x509"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AuthProvider implements an auth provider that authenticates users by the client
+// certificate presented to the app, or forwarded by an ingress. Access to attributes
+// of the certificate is supplied through annotations on VDIRoles.
+type AuthProvider struct {
+	common.AuthProvider
+
+	// k8s client
+	client client.Client
+	// our cluster instance
+	cluster *v1alpha1.VDICluster
+	// the pool of CAs that issue client certificates
+	roots *x509.CertPool
+}
+
+// Blank assignment to make sure AuthProvider satisfies the interface.
+var _ common.AuthProvider = &AuthProvider{}
+
+// New returns a new client certificate AuthProvider.
+func New() common.AuthProvider {
+	return &AuthProvider{}
+}
+
+// Setup implements the AuthProvider interface and sets a local reference to the
+// k8s client and vdi cluster. It then loads the CAs for verifying client certificates.
+func (a *AuthProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) error {
+	a.client = c
+	a.cluster = cluster
+
+	caCert, err := a.cluster.GetCertClientCA()
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caCert) {
+		return errors.New("No certificates could be parsed from the client CA bundle")
+	}
+	a.roots = roots
+	return nil
+}
+
+// Reconcile just makes sure that the client CA bundle is valid. The generated admin
+// password is ignored in place of configuring admin groups.
+func (a *AuthProvider) Reconcile(reqLogger logr.Logger, c client.Client, cluster *v1alpha1.VDICluster, adminPass string) error {
+	return a.Setup(c, cluster)
+}
+
+// Close just returns nil as connections are not persistent
+func (a *AuthProvider) Close() error {
+	return nil
+}
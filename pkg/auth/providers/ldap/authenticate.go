@@ -16,18 +16,14 @@ import (
 // Authenticate is called for API authentication requests. It should generate
 // a new JWTClaims object and serve an AuthResult back to the API.
 func (a *AuthProvider) Authenticate(req *v1.LoginRequest) (*v1.AuthResult, error) {
-	conn, err := a.connect()
+	conn, err := a.pool.get()
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-
-	if err := a.bind(conn); err != nil {
-		return nil, err
-	}
+	defer a.pool.put(conn)
 
 	// fetch the role mappings
-	roles, err := a.cluster.GetRoles(a.client)
+	roles, err := a.cluster.GetResolvedRoles(a.client)
 	if err != nil {
 		return nil, err
 	}
@@ -35,40 +31,40 @@ func (a *AuthProvider) Authenticate(req *v1.LoginRequest) (*v1.AuthResult, error
 	searchRequest := ldapv3.NewSearchRequest(
 		a.getUserBase(),
 		ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, 0, 0, false,
-		fmt.Sprintf(userFilter, req.Username),
-		userAttrs,
+		a.getUserFilter(req.Username),
+		a.getUserAttributes(),
 		nil,
 	)
-	sr, err := conn.Search(searchRequest)
+	entries, err := a.search(conn, searchRequest)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(sr.Entries) != 1 {
-		return nil, errors.NewUserNotFoundError(fmt.Sprintf("Received %d matches for %s", len(sr.Entries), req.Username))
+	if len(entries) != 1 {
+		return nil, errors.NewUserNotFoundError(fmt.Sprintf("Received %d matches for %s", len(entries), req.Username))
 	}
 
-	user := sr.Entries[0]
+	user := entries[0]
 
-	if strings.ToLower(user.GetAttributeValue("accountStatus")) != "active" {
-		return nil, fmt.Errorf("User account %s is disabled", user.GetAttributeValue("uid"))
+	if a.accountDisabled(user) {
+		return nil, fmt.Errorf("User account %s is disabled", a.getUsername(user))
 	}
 
 	// perform a bind to check the credentials
-	if err := conn.Bind(user.DN, req.Password); err != nil {
+	if err := a.bindUser(conn, user, req.Password); err != nil {
 		return nil, err
 	}
 
 	// make a new user object
-	vdiUser := &v1.VDIUser{
-		Name:  req.Username,
-		Roles: make([]*v1.VDIUserRole, 0),
-	}
+	vdiUser := a.newVDIUser(req.Username, user)
 
 	// we'll have to iterate our available roles and check if any have an annotation
 	// binding it to one of this user's ldap groups
 	boundRoles := make([]string, 0)
-	userGroups := user.GetAttributeValues("memberOf")
+	userGroups, err := a.getUserGroups(conn, user)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, role := range roles {
 		boundRoles = appendRoleIfBound(boundRoles, userGroups, role)
@@ -88,7 +84,7 @@ func appendRoleIfBound(boundRoles, userGroups []string, role v1alpha1.VDIRole) [
 				if group == "" {
 					continue
 				}
-				if common.StringSliceContains(userGroups, group) {
+				if containsDN(userGroups, group) {
 					boundRoles = common.AppendStringIfMissing(boundRoles, role.GetName())
 				}
 			}
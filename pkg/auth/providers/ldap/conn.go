@@ -3,17 +3,31 @@ package ldap
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"net"
+	"strings"
 
 	ldapv3 "github.com/go-ldap/ldap/v3"
 )
 
-// connect creates a connection with the ldap server. It assumes the credentials
-// are already present in the current interface.
+// connect creates a connection with the first reachable ldap server. It assumes
+// the credentials are already present in the current interface.
 func (a *AuthProvider) connect() (*ldapv3.Conn, error) {
-	if a.cluster.IsUsingLDAPOverTLS() {
-		return ldapv3.DialURL(a.cluster.GetLDAPURL(), ldapv3.DialWithTLSConfig(a.tlsConfig))
+	return a.servers.dial(a.dialURL)
+}
+
+// dialURL creates a connection with the ldap server at the given URL, applying
+// the configured timeouts.
+func (a *AuthProvider) dialURL(addr string) (*ldapv3.Conn, error) {
+	opts := []ldapv3.DialOpt{ldapv3.DialWithDialer(&net.Dialer{Timeout: a.cluster.GetLDAPConnectTimeout()})}
+	if strings.HasPrefix(addr, "ldaps") && a.tlsConfig != nil {
+		opts = append(opts, ldapv3.DialWithTLSConfig(a.tlsConfig))
+	}
+	conn, err := ldapv3.DialURL(addr, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return ldapv3.DialURL(a.cluster.GetLDAPURL())
+	conn.SetTimeout(a.cluster.GetLDAPSearchTimeout())
+	return conn, nil
 }
 
 func (a *AuthProvider) bind(conn *ldapv3.Conn) error {
@@ -0,0 +1,201 @@
+package ldap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// matchingRuleInChain is the Active Directory extensible match rule that walks
+// the chain of ancestry for an attribute (LDAP_MATCHING_RULE_IN_CHAIN).
+const matchingRuleInChain = "1.2.840.113556.1.4.1941"
+
+// adAccountDisabled is the flag set in userAccountControl for disabled accounts.
+const adAccountDisabled = 0x2
+
+// getUserAttributes returns the attributes to read from user entries.
+func (a *AuthProvider) getUserAttributes() []string {
+	attrs := []string{
+		"cn", "dn",
+		a.cluster.GetLDAPUsernameAttribute(),
+		a.cluster.GetLDAPMemberOfAttribute(),
+		a.cluster.GetLDAPMailAttribute(),
+		a.cluster.GetLDAPDisplayNameAttribute(),
+	}
+	if status := a.cluster.GetLDAPAccountStatusAttribute(); status != "" {
+		return append(attrs, status)
+	}
+	return append(attrs, "userAccountControl")
+}
+
+// getUserFilter returns the filter to use when searching for the given user.
+func (a *AuthProvider) getUserFilter(username string) string {
+	return andFilter(
+		a.cluster.GetLDAPUserFilter(),
+		fmt.Sprintf("(%s=%s)", a.cluster.GetLDAPUsernameAttribute(), ldapv3.EscapeFilter(username)),
+	)
+}
+
+// getGroupUsersFilter returns the filter to use when searching for the members
+// of the given group. With Active Directory this includes members of nested groups.
+func (a *AuthProvider) getGroupUsersFilter(group string) string {
+	memberOf := a.cluster.GetLDAPMemberOfAttribute()
+	if a.cluster.IsUsingActiveDirectory() {
+		return andFilter(
+			a.cluster.GetLDAPUserFilter(),
+			fmt.Sprintf("(%s:%s:=%s)", memberOf, matchingRuleInChain, ldapv3.EscapeFilter(group)),
+		)
+	}
+	return andFilter(a.cluster.GetLDAPUserFilter(), fmt.Sprintf("(%s=%s)", memberOf, ldapv3.EscapeFilter(group)))
+}
+
+// andFilter returns a filter matching both the given filter and clause. Clauses
+// are added to the end of filters that are already a conjunction.
+func andFilter(filter, clause string) string {
+	if filter == "" {
+		return clause
+	}
+	if strings.HasPrefix(filter, "(&") && strings.HasSuffix(filter, ")") {
+		return strings.TrimSuffix(filter, ")") + clause + ")"
+	}
+	return "(&" + filter + clause + ")"
+}
+
+// getUsername returns the username for the given entry.
+func (a *AuthProvider) getUsername(entry *searchEntry) string {
+	return entry.GetAttributeValue(a.cluster.GetLDAPUsernameAttribute())
+}
+
+// newVDIUser returns a user with the given name and the details of the given entry.
+func (a *AuthProvider) newVDIUser(username string, entry *searchEntry) *v1.VDIUser {
+	return &v1.VDIUser{
+		Name:        username,
+		DisplayName: entry.GetAttributeValue(a.cluster.GetLDAPDisplayNameAttribute()),
+		Email:       entry.GetAttributeValue(a.cluster.GetLDAPMailAttribute()),
+		Roles:       make([]*v1.VDIUserRole, 0),
+	}
+}
+
+// accountDisabled returns true if the account for the given entry is disabled.
+func (a *AuthProvider) accountDisabled(entry *searchEntry) bool {
+	status := a.cluster.GetLDAPAccountStatusAttribute()
+	if status == "" {
+		flags, err := strconv.ParseInt(entry.GetAttributeValue("userAccountControl"), 10, 64)
+		if err != nil {
+			return true
+		}
+		return flags&adAccountDisabled != 0
+	}
+	value := entry.GetAttributeValue(status)
+	for _, active := range a.cluster.GetLDAPAccountStatusActiveValues() {
+		if strings.EqualFold(value, active) {
+			return false
+		}
+	}
+	return true
+}
+
+// getUserGroups returns the DNs of the groups the given user is a member of. When
+// nested groups are enabled, this includes all of the groups those are members of.
+func (a *AuthProvider) getUserGroups(conn *ldapv3.Conn, user *searchEntry) ([]string, error) {
+	groups := user.GetAttributeValues(a.cluster.GetLDAPMemberOfAttribute())
+	switch {
+	case a.cluster.IsUsingActiveDirectory():
+		// the server resolves the full chain for us
+		searchRequest := ldapv3.NewSearchRequest(
+			a.baseDN,
+			ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, 0, 0, false,
+			fmt.Sprintf("(member:%s:=%s)", matchingRuleInChain, ldapv3.EscapeFilter(user.DN)),
+			[]string{"dn"},
+			nil,
+		)
+		entries, err := a.search(conn, searchRequest)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			groups = appendDNIfMissing(groups, entry.DN)
+		}
+		return groups, nil
+	case a.cluster.LDAPNestedGroupsEnabled():
+		return resolveNestedGroups(groups, a.cluster.GetLDAPMaxGroupDepth(), func(group string) ([]string, error) {
+			return a.getParentGroups(conn, group)
+		})
+	default:
+		return groups, nil
+	}
+}
+
+// getParentGroups returns the groups the given group is a direct member of.
+func (a *AuthProvider) getParentGroups(conn *ldapv3.Conn, group string) ([]string, error) {
+	searchRequest := ldapv3.NewSearchRequest(
+		group,
+		ldapv3.ScopeBaseObject, ldapv3.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{a.cluster.GetLDAPMemberOfAttribute()},
+		nil,
+	)
+	entries, err := a.search(conn, searchRequest)
+	if err != nil {
+		// groups that can't be read are treated as having no parents
+		if ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	parents := make([]string, 0)
+	for _, entry := range entries {
+		parents = append(parents, entry.GetAttributeValues(a.cluster.GetLDAPMemberOfAttribute())...)
+	}
+	return parents, nil
+}
+
+// resolveNestedGroups expands the given groups with the groups they are members of,
+// up to maxDepth levels away. Cycles in the group hierarchy are only followed once.
+func resolveNestedGroups(groups []string, maxDepth int, getParents func(string) ([]string, error)) ([]string, error) {
+	resolved := make([]string, 0)
+	for _, group := range groups {
+		resolved = appendDNIfMissing(resolved, group)
+	}
+	current := resolved
+	for depth := 0; depth < maxDepth && len(current) > 0; depth++ {
+		next := make([]string, 0)
+		for _, group := range current {
+			parents, err := getParents(group)
+			if err != nil {
+				return nil, err
+			}
+			for _, parent := range parents {
+				if !containsDN(resolved, parent) {
+					resolved = append(resolved, parent)
+					next = append(next, parent)
+				}
+			}
+		}
+		current = next
+	}
+	return resolved, nil
+}
+
+// containsDN returns true if the given DN is in the list. DNs are compared
+// case-insensitively.
+func containsDN(dns []string, dn string) bool {
+	for _, d := range dns {
+		if strings.EqualFold(d, dn) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendDNIfMissing appends the given DN to the list if it is not already present.
+func appendDNIfMissing(dns []string, dn string) []string {
+	if containsDN(dns, dn) {
+		return dns
+	}
+	return append(dns, dn)
+}
@@ -0,0 +1,161 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+func TestResolveNestedGroups(t *testing.T) {
+	parents := map[string][]string{
+		"cn=team,dc=example,dc=com":       {"cn=department,dc=example,dc=com"},
+		"cn=department,dc=example,dc=com": {"CN=Division,DC=example,DC=com"},
+		"cn=division,dc=example,dc=com":   {"cn=kvdi-users,dc=example,dc=com"},
+		"cn=kvdi-users,dc=example,dc=com": {"cn=team,dc=example,dc=com"}, // cycle
+	}
+	lookup := func(group string) ([]string, error) {
+		for dn, p := range parents {
+			if containsDN([]string{dn}, group) {
+				return p, nil
+			}
+		}
+		return nil, nil
+	}
+
+	groups, err := resolveNestedGroups([]string{"cn=team,dc=example,dc=com"}, 10, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"cn=team,dc=example,dc=com",
+		"cn=department,dc=example,dc=com",
+		"CN=Division,DC=example,DC=com",
+		"cn=kvdi-users,dc=example,dc=com",
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected %v, got %v", expected, groups)
+	}
+
+	// the depth should be limited
+	groups, err = resolveNestedGroups([]string{"cn=team,dc=example,dc=com"}, 1, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 {
+		t.Error("Expected one level of nesting to be resolved, got:", groups)
+	}
+}
+
+func TestActiveDirectoryMode(t *testing.T) {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{
+		LDAPAuth: &v1alpha1.LDAPConfig{URL: "ldap://dc1.example.com"},
+	}
+	a := &AuthProvider{cluster: cluster}
+
+	entry := &searchEntry{Entry: ldapv3.NewEntry("cn=user,dc=example,dc=com", map[string][]string{
+		"uid":                {"user"},
+		"sAMAccountName":     {"aduser"},
+		"accountStatus":      {"active"},
+		"userAccountControl": {"514"},
+	})}
+
+	if got := a.getUserFilter("user*)(uid=*"); got != `(uid=user\2a\29\28uid=\2a)` {
+		t.Error("Expected escaped uid filter, got:", got)
+	}
+	if a.getUsername(entry) != "user" || a.accountDisabled(entry) {
+		t.Error("Expected regular LDAP attributes to be used")
+	}
+
+	cluster.Spec.Auth.LDAPAuth.ActiveDirectory = true
+	if got := a.getUserFilter("aduser"); got != "(&(objectCategory=person)(objectClass=user)(sAMAccountName=aduser))" {
+		t.Error("Unexpected AD user filter, got:", got)
+	}
+	if a.getUsername(entry) != "aduser" {
+		t.Error("Expected sAMAccountName to be used for the username")
+	}
+	// 514 = NORMAL_ACCOUNT | ACCOUNTDISABLE
+	if !a.accountDisabled(entry) {
+		t.Error("Expected account to be disabled")
+	}
+	entry.Attributes[3].Values = []string{"512"}
+	if a.accountDisabled(entry) {
+		t.Error("Expected account to be enabled")
+	}
+}
+
+func TestAppendRoleIfBound(t *testing.T) {
+	role := v1alpha1.VDIRole{}
+	role.Name = "developers"
+	role.Annotations = map[string]string{
+		v1.LDAPGroupRoleAnnotation: "cn=kvdi-users,ou=groups,dc=example,dc=com",
+	}
+	// DNs are matched without regard to case
+	bound := appendRoleIfBound(nil, []string{"CN=kvdi-users,OU=Groups,DC=example,DC=com"}, role)
+	if len(bound) != 1 || bound[0] != "developers" {
+		t.Error("Expected role to be bound, got:", bound)
+	}
+	if bound := appendRoleIfBound(nil, []string{"cn=other,dc=example,dc=com"}, role); len(bound) != 0 {
+		t.Error("Expected role to not be bound, got:", bound)
+	}
+}
+
+func TestAttributeMapping(t *testing.T) {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{
+		LDAPAuth: &v1alpha1.LDAPConfig{
+			URL:             "ldap://dc1.example.com",
+			ActiveDirectory: true,
+			UserFilter:      "(objectClass=person)",
+			Attributes: &v1alpha1.LDAPAttributeConfig{
+				AccountStatus:             "employeeStatus",
+				AccountStatusActiveValues: []string{"Active", "On-Leave"},
+				MemberOf:                  "groupMembership",
+				Mail:                      "proxyAddress",
+			},
+		},
+	}
+	a := &AuthProvider{cluster: cluster}
+
+	if got := a.getUserFilter("aduser"); got != "(&(objectClass=person)(sAMAccountName=aduser))" {
+		t.Error("Unexpected user filter, got:", got)
+	}
+	if got := a.getGroupUsersFilter("cn=devs,dc=example,dc=com"); got != "(&(objectClass=person)(groupMembership:1.2.840.113556.1.4.1941:=cn=devs,dc=example,dc=com))" {
+		t.Error("Unexpected group users filter, got:", got)
+	}
+	expectedAttrs := []string{"cn", "dn", "sAMAccountName", "groupMembership", "proxyAddress", "displayName", "employeeStatus"}
+	if attrs := a.getUserAttributes(); !reflect.DeepEqual(attrs, expectedAttrs) {
+		t.Errorf("Expected attributes %v, got %v", expectedAttrs, attrs)
+	}
+
+	entry := &searchEntry{Entry: ldapv3.NewEntry("cn=user,dc=example,dc=com", map[string][]string{
+		"sAMAccountName":     {"aduser"},
+		"employeeStatus":     {"on-leave"},
+		"userAccountControl": {"514"},
+		"proxyAddress":       {"aduser@example.com"},
+		"displayName":        {"AD User"},
+	})}
+	// the status attribute replaces the account flags
+	if a.accountDisabled(entry) {
+		t.Error("Expected account with an active status to be enabled")
+	}
+	entry.Attributes[1].Values = []string{"terminated"}
+	if !a.accountDisabled(entry) {
+		t.Error("Expected account with an inactive status to be disabled")
+	}
+	user := a.newVDIUser(a.getUsername(entry), entry)
+	if user.Name != "aduser" || user.Email != "aduser@example.com" || user.DisplayName != "AD User" {
+		t.Error("Unexpected user details, got:", user)
+	}
+
+	// custom filters that are already a conjunction are extended
+	cluster.Spec.Auth.LDAPAuth.UserFilter = "(&(objectClass=person)(!(ou=contractors)))"
+	cluster.Spec.Auth.LDAPAuth.Attributes.Username = "employeeID"
+	if got := a.getUserFilter("1234"); got != "(&(objectClass=person)(!(ou=contractors))(employeeID=1234))" {
+		t.Error("Unexpected user filter, got:", got)
+	}
+}
@@ -0,0 +1,149 @@
+package ldap
+
+import (
+	"sync"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// connPool keeps connections to the LDAP server open between requests. Connections
+// handed out by the pool are bound with the service account credentials.
+type connPool struct {
+	dial func() (*ldapv3.Conn, error)
+	bind func(*ldapv3.Conn) error
+
+	mux         sync.Mutex
+	size        int
+	idleTimeout time.Duration
+	idle        []*idleConn
+	active      map[*ldapv3.Conn]*activeConn
+	// incremented on reset so connections handed out before are not returned to
+	// the pool
+	generation int
+}
+
+type idleConn struct {
+	conn       *ldapv3.Conn
+	releasedAt time.Time
+	userBound  bool
+}
+
+type activeConn struct {
+	generation int
+	userBound  bool
+}
+
+func newConnPool(dial func() (*ldapv3.Conn, error), bind func(*ldapv3.Conn) error) *connPool {
+	return &connPool{
+		dial:   dial,
+		bind:   bind,
+		idle:   make([]*idleConn, 0),
+		active: make(map[*ldapv3.Conn]*activeConn),
+	}
+}
+
+// reset closes all idle connections and applies the given settings. Connections
+// currently in use are closed when they are returned.
+func (p *connPool) reset(size int, idleTimeout time.Duration) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.size = size
+	p.idleTimeout = idleTimeout
+	p.generation++
+	for _, ic := range p.idle {
+		ic.conn.Close()
+	}
+	p.idle = make([]*idleConn, 0)
+}
+
+// get returns a connection bound as the service account, reusing an idle one
+// if possible. The connection must be returned with put or discard.
+func (p *connPool) get() (*ldapv3.Conn, error) {
+	for {
+		ic := p.popIdle()
+		if ic == nil {
+			break
+		}
+		if !ic.userBound {
+			return ic.conn, nil
+		}
+		// the connection was last bound as a user, switch back to the service account
+		if err := p.bind(ic.conn); err == nil {
+			return ic.conn, nil
+		}
+		p.discard(ic.conn)
+	}
+
+	p.mux.Lock()
+	generation := p.generation
+	p.mux.Unlock()
+
+	conn, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.bind(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.active[conn] = &activeConn{generation: generation}
+	return conn, nil
+}
+
+// popIdle removes the most recently used idle connection from the pool, closing
+// any that have expired along the way. Nil is returned if there are none left.
+func (p *connPool) popIdle() *idleConn {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for len(p.idle) > 0 {
+		ic := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if ic.conn.IsClosing() || time.Since(ic.releasedAt) > p.idleTimeout {
+			ic.conn.Close()
+			continue
+		}
+		p.active[ic.conn] = &activeConn{generation: p.generation, userBound: ic.userBound}
+		return ic
+	}
+	return nil
+}
+
+// markUserBound records that the given connection was bound as a user, so that
+// it is bound as the service account again before it is reused.
+func (p *connPool) markUserBound(conn *ldapv3.Conn) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if ac, ok := p.active[conn]; ok {
+		ac.userBound = true
+	}
+}
+
+// put returns a connection to the pool. It is closed instead if the pool is full
+// or the connection is no longer usable.
+func (p *connPool) put(conn *ldapv3.Conn) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	ac, ok := p.active[conn]
+	delete(p.active, conn)
+	if !ok || ac.generation != p.generation || conn.IsClosing() || len(p.idle) >= p.size {
+		conn.Close()
+		return
+	}
+	p.idle = append(p.idle, &idleConn{
+		conn:       conn,
+		releasedAt: time.Now(),
+		userBound:  ac.userBound,
+	})
+}
+
+// discard closes a connection instead of returning it to the pool.
+func (p *connPool) discard(conn *ldapv3.Conn) {
+	p.mux.Lock()
+	delete(p.active, conn)
+	p.mux.Unlock()
+	conn.Close()
+}
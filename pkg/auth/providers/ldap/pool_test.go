@@ -0,0 +1,116 @@
+package ldap
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+type testDialer struct {
+	dials, binds int
+	bindErr      error
+}
+
+func (d *testDialer) dial() (*ldapv3.Conn, error) {
+	d.dials++
+	client, server := net.Pipe()
+	// drain anything sent to the server so closing the connection does not block
+	go func() { _, _ = io.Copy(ioutil.Discard, server) }()
+	conn := ldapv3.NewConn(client, false)
+	conn.Start()
+	return conn, nil
+}
+
+func (d *testDialer) bind(*ldapv3.Conn) error {
+	d.binds++
+	return d.bindErr
+}
+
+func TestConnPool(t *testing.T) {
+	dialer := &testDialer{}
+	pool := newConnPool(dialer.dial, dialer.bind)
+	pool.reset(1, time.Minute)
+
+	first, err := pool.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pool.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dialer.dials != 2 || dialer.binds != 2 {
+		t.Fatalf("Expected 2 dials and binds, got %d and %d", dialer.dials, dialer.binds)
+	}
+
+	// only one connection fits in the pool
+	pool.put(first)
+	pool.put(second)
+	if len(pool.idle) != 1 {
+		t.Fatal("Expected one idle connection, got", len(pool.idle))
+	}
+	if !second.IsClosing() {
+		t.Error("Expected connection that did not fit in the pool to be closed")
+	}
+
+	// idle connections are reused without binding again
+	conn, err := pool.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn != first || dialer.dials != 2 || dialer.binds != 2 {
+		t.Error("Expected the idle connection to be reused")
+	}
+
+	// connections bound as a user are bound as the service account again
+	pool.markUserBound(conn)
+	pool.put(conn)
+	if conn, err = pool.get(); err != nil {
+		t.Fatal(err)
+	}
+	if conn != first || dialer.binds != 3 {
+		t.Error("Expected the idle connection to be reused after binding again")
+	}
+
+	// connections that fail to bind again are replaced
+	pool.markUserBound(conn)
+	pool.put(conn)
+	dialer.bindErr = errors.New("invalid credentials")
+	if _, err := pool.get(); err == nil {
+		t.Error("Expected error when the bind fails")
+	}
+	if !first.IsClosing() || dialer.dials != 3 {
+		t.Error("Expected connection that failed to bind to be closed and a new one dialed")
+	}
+	dialer.bindErr = nil
+
+	// connections handed out before a reset are not returned to the pool
+	if conn, err = pool.get(); err != nil {
+		t.Fatal(err)
+	}
+	pool.reset(1, time.Minute)
+	pool.put(conn)
+	if len(pool.idle) != 0 || !conn.IsClosing() {
+		t.Error("Expected connection from before the reset to be closed")
+	}
+
+	// expired connections are not reused
+	pool.reset(1, time.Millisecond)
+	if conn, err = pool.get(); err != nil {
+		t.Fatal(err)
+	}
+	pool.put(conn)
+	time.Sleep(10 * time.Millisecond)
+	dials := dialer.dials
+	if _, err = pool.get(); err != nil {
+		t.Fatal(err)
+	}
+	if dialer.dials != dials+1 || !conn.IsClosing() {
+		t.Error("Expected expired connection to be closed and a new one dialed")
+	}
+}
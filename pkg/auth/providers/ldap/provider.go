@@ -9,16 +9,12 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	"github.com/tinyzimmer/kvdi/pkg/auth/common"
 	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 
 	"github.com/go-logr/logr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-const userFilter = "(uid=%s)"
-const groupUsersFilter = "(memberOf=%s)"
-
-var userAttrs = []string{"cn", "dn", "uid", "memberOf", "accountStatus"}
-
 // AuthProvider implements an auth provider that uses an LDAP server as the
 // authentication backend. Access to groups in LDAP is supplied through annotations
 // on VDIRoles.
@@ -39,14 +35,21 @@ type AuthProvider struct {
 	tlsConfig *tls.Config
 	// the base DN for the connected LDAP server
 	baseDN string
+	// the configured LDAP servers and their health
+	servers *serverList
+	// the pool of connections to the LDAP servers
+	pool *connPool
 }
 
-// Blank assignment to make sure AuthProvider satisfies the interface.
+// Blank assignments to make sure AuthProvider satisfies the interfaces.
 var _ common.AuthProvider = &AuthProvider{}
+var _ common.HealthChecker = &AuthProvider{}
 
 // New returns a new LDAPAuthProvider.
 func New(s *secrets.SecretEngine) common.AuthProvider {
-	return &AuthProvider{secrets: s}
+	a := &AuthProvider{secrets: s, servers: &serverList{}}
+	a.pool = newConnPool(a.connect, a.bind)
+	return a
 }
 
 // Setup implements the AuthProvider interface and sets a local reference to the
@@ -75,15 +78,17 @@ func (a *AuthProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) erro
 	}
 	a.baseDN = strings.Join(baseDnFields, ",")
 
-	// verify we can connect to the ldap server
-	conn, err := a.connect()
+	// drop any connections made with the previous configuration
+	a.servers.reset(a.cluster.GetLDAPURLs(), a.cluster.LDAPRoundRobinEnabled(), a.cluster.GetLDAPHealthCheckInterval())
+	a.pool.reset(a.cluster.GetLDAPPoolSize(), a.cluster.GetLDAPPoolIdleTimeout())
+
+	// verify we can connect to the ldap server and the credentials work
+	conn, err := a.pool.get()
 	if err != nil {
 		return err
 	}
-
-	// verify credentials work
-	defer conn.Close()
-	return a.bind(conn)
+	a.pool.put(conn)
+	return nil
 }
 
 // Reconcile just makes sure that we are able to succesfully set up a connection.
@@ -92,7 +97,34 @@ func (a *AuthProvider) Reconcile(reqLogger logr.Logger, c client.Client, cluster
 	return a.Setup(c, cluster)
 }
 
-// Close just returns nil as connections are not persistent
+// Close closes any idle connections to the LDAP server.
 func (a *AuthProvider) Close() error {
+	a.pool.reset(0, 0)
+	return nil
+}
+
+// CheckHealth implements the HealthChecker interface and verifies that an LDAP
+// server is reachable and the bind credentials are still valid. Servers that
+// could not be reached before are tried again so they are used as soon as they
+// recover.
+func (a *AuthProvider) CheckHealth() error {
+	if a.cluster == nil {
+		return errors.New("LDAP provider has not been setup yet")
+	}
+	for _, u := range a.servers.down() {
+		if conn, err := a.dialURL(u); err == nil {
+			conn.Close()
+			a.servers.markUp(u)
+		}
+	}
+	conn, err := a.pool.get()
+	if err != nil {
+		return err
+	}
+	if err := a.bind(conn); err != nil {
+		a.pool.discard(conn)
+		return err
+	}
+	a.pool.put(conn)
 	return nil
 }
@@ -0,0 +1,212 @@
+package ldap
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// searchEntry is an entry returned from a search along with the URL of the
+// server it was found on. The URL is empty for the configured server.
+type searchEntry struct {
+	*ldapv3.Entry
+	referral string
+}
+
+// search performs the given search request on the connection. If referrals are
+// enabled, any referrals returned by the server are followed and their entries
+// included in the results.
+func (a *AuthProvider) search(conn *ldapv3.Conn, req *ldapv3.SearchRequest) ([]*searchEntry, error) {
+	entries := make([]*searchEntry, 0)
+	if err := a.searchWithReferrals(conn, "", req, 0, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (a *AuthProvider) searchWithReferrals(conn *ldapv3.Conn, serverURL string, req *ldapv3.SearchRequest, hops int, entries *[]*searchEntry) error {
+	referrals := make([]string, 0)
+	sr, err := conn.Search(req)
+	if err != nil {
+		if !a.cluster.LDAPReferralsEnabled() || !ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultReferral) {
+			return err
+		}
+		referrals = append(referrals, getReferralURLs(err)...)
+	}
+	if sr != nil {
+	EntryLoop:
+		for _, entry := range sr.Entries {
+			for _, existing := range *entries {
+				if strings.EqualFold(existing.DN, entry.DN) {
+					continue EntryLoop
+				}
+			}
+			*entries = append(*entries, &searchEntry{Entry: entry, referral: serverURL})
+		}
+		referrals = append(referrals, sr.Referrals...)
+	}
+
+	if !a.cluster.LDAPReferralsEnabled() || hops >= a.cluster.GetLDAPReferralMaxHops() {
+		return nil
+	}
+
+	for _, referral := range referrals {
+		u, err := url.Parse(referral)
+		if err != nil || !a.referralAllowed(u) {
+			continue
+		}
+		// referrals that cannot be followed are skipped, the same as when
+		// referral chasing is disabled
+		_ = a.followReferral(u, req, hops+1, entries)
+	}
+
+	return nil
+}
+
+// followReferral connects to the server in the given referral and repeats the
+// search request against it.
+func (a *AuthProvider) followReferral(u *url.URL, req *ldapv3.SearchRequest, hops int, entries *[]*searchEntry) error {
+	conn, err := a.connectReferral(u)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// only send the bind credentials to trusted servers
+	if a.referralTrusted(u) {
+		if err := a.bind(conn); err != nil {
+			return err
+		}
+	}
+
+	// a referral may point to a different base in the directory
+	referredReq := *req
+	if base := strings.TrimPrefix(u.Path, "/"); base != "" {
+		referredReq.BaseDN = base
+	}
+
+	return a.searchWithReferrals(conn, u.String(), &referredReq, hops, entries)
+}
+
+// bindUser verifies the password for the given entry against the server it
+// was found on.
+func (a *AuthProvider) bindUser(conn *ldapv3.Conn, entry *searchEntry, password string) error {
+	userConn, err := a.entryConn(conn, entry)
+	if err != nil {
+		return err
+	}
+	if userConn != conn {
+		defer userConn.Close()
+	} else {
+		a.pool.markUserBound(conn)
+	}
+	return userConn.Bind(entry.DN, password)
+}
+
+// modifyUserPassword binds as the given entry with its current password on the
+// server it was found on, and then changes it with the password modify extended
+// operation.
+func (a *AuthProvider) modifyUserPassword(conn *ldapv3.Conn, entry *searchEntry, oldPassword, newPassword string) error {
+	userConn, err := a.entryConn(conn, entry)
+	if err != nil {
+		return err
+	}
+	if userConn != conn {
+		defer userConn.Close()
+	} else {
+		a.pool.markUserBound(conn)
+	}
+	if err := userConn.Bind(entry.DN, oldPassword); err != nil {
+		if ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultInvalidCredentials) {
+			return errors.NewInvalidCredentialsError(a.getUsername(entry))
+		}
+		return err
+	}
+	// an empty identity modifies the password of the bound user
+	_, err = userConn.PasswordModify(ldapv3.NewPasswordModifyRequest("", oldPassword, newPassword))
+	return err
+}
+
+// entryConn returns a connection to the server the given entry was found on.
+// If it was found on the configured server, the given connection is returned.
+// Otherwise, a new connection is made to the referral server and the caller is
+// responsible for closing it.
+func (a *AuthProvider) entryConn(conn *ldapv3.Conn, entry *searchEntry) (*ldapv3.Conn, error) {
+	if entry.referral == "" {
+		return conn, nil
+	}
+	u, err := url.Parse(entry.referral)
+	if err != nil {
+		return nil, err
+	}
+	if !a.referralTrusted(u) {
+		return nil, fmt.Errorf("User %s was found on untrusted referral server %s", entry.DN, u.Hostname())
+	}
+	return a.connectReferral(u)
+}
+
+// connectReferral creates a connection with the server in the given referral.
+func (a *AuthProvider) connectReferral(u *url.URL) (*ldapv3.Conn, error) {
+	return a.dialURL(fmt.Sprintf("%s://%s", u.Scheme, u.Host))
+}
+
+// referralAllowed returns true if the given referral may be followed.
+func (a *AuthProvider) referralAllowed(u *url.URL) bool {
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return false
+	}
+	allowed := a.cluster.GetLDAPReferralAllowedHosts()
+	if len(allowed) == 0 {
+		return true
+	}
+	return hostMatches(allowed, u.Hostname())
+}
+
+// referralTrusted returns true if the server in the given referral is trusted
+// with credentials.
+func (a *AuthProvider) referralTrusted(u *url.URL) bool {
+	return hostMatches(a.cluster.GetLDAPReferralTrustedHosts(), u.Hostname())
+}
+
+// hostMatches returns true if the host matches any of the given patterns. Patterns
+// may be an exact hostname or a wildcard in the form of `*.example.com`.
+func hostMatches(patterns []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// getReferralURLs extracts the referral URLs from an LDAP referral error.
+func getReferralURLs(err error) []string {
+	urls := make([]string, 0)
+	ldapErr, ok := err.(*ldapv3.Error)
+	if !ok || ldapErr.Packet == nil || len(ldapErr.Packet.Children) < 2 {
+		return urls
+	}
+	for _, child := range ldapErr.Packet.Children[1].Children {
+		if child.Tag != 3 {
+			continue
+		}
+		for _, ref := range child.Children {
+			if val, ok := ref.Value.(string); ok {
+				urls = append(urls, val)
+			}
+		}
+	}
+	return urls
+}
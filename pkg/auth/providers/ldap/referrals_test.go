@@ -0,0 +1,71 @@
+package ldap
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+func TestHostMatches(t *testing.T) {
+	patterns := []string{"dc1.example.com", "*.corp.example.com"}
+	tests := []struct {
+		host    string
+		matches bool
+	}{
+		{"dc1.example.com", true},
+		{"DC1.Example.com", true},
+		{"dc2.example.com", false},
+		{"dc1.corp.example.com", true},
+		{"corp.example.com", false},
+		{"dc1.corp.example.com.evil.com", false},
+	}
+	for _, tt := range tests {
+		if got := hostMatches(patterns, tt.host); got != tt.matches {
+			t.Errorf("hostMatches(%q) = %v, expected %v", tt.host, got, tt.matches)
+		}
+	}
+	if hostMatches(nil, "dc1.example.com") {
+		t.Error("Expected no match against empty patterns")
+	}
+}
+
+func TestReferralScoping(t *testing.T) {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{
+		LDAPAuth: &v1alpha1.LDAPConfig{
+			URL: "ldap://dc1.example.com",
+			Referrals: &v1alpha1.LDAPReferralConfig{
+				Follow: true,
+			},
+		},
+	}
+	a := &AuthProvider{cluster: cluster}
+
+	u, _ := url.Parse("ldap://dc1.child.example.com/DC=child,DC=example,DC=com")
+	if !a.referralAllowed(u) {
+		t.Error("Expected referral to be allowed with no allowed hosts configured")
+	}
+	if a.referralTrusted(u) {
+		t.Error("Expected referral to be untrusted with no trusted hosts configured")
+	}
+
+	other, _ := url.Parse("http://dc1.child.example.com")
+	if a.referralAllowed(other) {
+		t.Error("Expected non-ldap referral to be denied")
+	}
+
+	cluster.Spec.Auth.LDAPAuth.Referrals.AllowedHosts = []string{"*.example.com"}
+	cluster.Spec.Auth.LDAPAuth.Referrals.TrustedHosts = []string{"*.child.example.com"}
+	if !a.referralAllowed(u) || !a.referralTrusted(u) {
+		t.Error("Expected referral to be allowed and trusted")
+	}
+	external, _ := url.Parse("ldap://ldap.example.org")
+	if a.referralAllowed(external) || a.referralTrusted(external) {
+		t.Error("Expected external referral to be denied")
+	}
+
+	if hops := cluster.GetLDAPReferralMaxHops(); hops != 3 {
+		t.Error("Expected default max hops of 3, got:", hops)
+	}
+}
@@ -0,0 +1,127 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// serverList tracks the health of the configured LDAP servers and decides the
+// order they are tried in when opening a new connection.
+type serverList struct {
+	mux           sync.Mutex
+	servers       []*ldapServer
+	roundRobin    bool
+	retryInterval time.Duration
+	// the index of the server to start from for the next round-robin connection
+	next int
+}
+
+type ldapServer struct {
+	url string
+	// servers that could not be reached are skipped until this time passes
+	downUntil time.Time
+}
+
+// reset replaces the tracked servers with the given URLs, forgetting their health.
+func (s *serverList) reset(urls []string, roundRobin bool, retryInterval time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.servers = make([]*ldapServer, len(urls))
+	for idx, u := range urls {
+		s.servers[idx] = &ldapServer{url: u}
+	}
+	s.roundRobin = roundRobin
+	s.retryInterval = retryInterval
+	s.next = 0
+}
+
+// candidates returns the URLs to try in order. Servers that are up come first,
+// in the configured order or rotated for round-robin, followed by the servers
+// that are down in case they have recovered.
+func (s *serverList) candidates() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	start := 0
+	if s.roundRobin && len(s.servers) > 0 {
+		start = s.next % len(s.servers)
+		s.next++
+	}
+	now := time.Now()
+	up := make([]string, 0, len(s.servers))
+	down := make([]string, 0)
+	for i := range s.servers {
+		srv := s.servers[(start+i)%len(s.servers)]
+		if now.Before(srv.downUntil) {
+			down = append(down, srv.url)
+			continue
+		}
+		up = append(up, srv.url)
+	}
+	return append(up, down...)
+}
+
+// down returns the URLs of the servers that are currently being skipped.
+func (s *serverList) down() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := time.Now()
+	urls := make([]string, 0)
+	for _, srv := range s.servers {
+		if now.Before(srv.downUntil) {
+			urls = append(urls, srv.url)
+		}
+	}
+	return urls
+}
+
+// markDown records that the server at the given URL could not be reached.
+func (s *serverList) markDown(url string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if srv := s.lookup(url); srv != nil {
+		srv.downUntil = time.Now().Add(s.retryInterval)
+	}
+}
+
+// markUp records that the server at the given URL is reachable.
+func (s *serverList) markUp(url string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if srv := s.lookup(url); srv != nil {
+		srv.downUntil = time.Time{}
+	}
+}
+
+func (s *serverList) lookup(url string) *ldapServer {
+	for _, srv := range s.servers {
+		if srv.url == url {
+			return srv
+		}
+	}
+	return nil
+}
+
+// dial tries the servers in order with the given function until one of them can
+// be reached. If none of them can, the error contains why each one failed.
+func (s *serverList) dial(dial func(url string) (*ldapv3.Conn, error)) (*ldapv3.Conn, error) {
+	candidates := s.candidates()
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("No LDAP servers are configured")
+	}
+	errs := make([]string, 0, len(candidates))
+	for _, u := range candidates {
+		conn, err := dial(u)
+		if err != nil {
+			s.markDown(u)
+			errs = append(errs, fmt.Sprintf("%s: %s", u, err.Error()))
+			continue
+		}
+		s.markUp(u)
+		return conn, nil
+	}
+	return nil, fmt.Errorf("No LDAP servers could be reached: %s", strings.Join(errs, "; "))
+}
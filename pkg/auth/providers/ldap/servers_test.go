@@ -0,0 +1,115 @@
+package ldap
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+type testServers struct {
+	dialed []string
+	down   map[string]bool
+}
+
+func (s *testServers) dial(url string) (*ldapv3.Conn, error) {
+	s.dialed = append(s.dialed, url)
+	if s.down[url] {
+		return nil, errors.New("connection refused")
+	}
+	client, server := net.Pipe()
+	go func() { _, _ = io.Copy(ioutil.Discard, server) }()
+	conn := ldapv3.NewConn(client, false)
+	conn.Start()
+	return conn, nil
+}
+
+func TestServerListFailover(t *testing.T) {
+	urls := []string{"ldap://ldap-1:389", "ldap://ldap-2:389", "ldap://ldap-3:389"}
+	servers := &serverList{}
+	servers.reset(urls, false, time.Minute)
+	backend := &testServers{down: map[string]bool{}}
+
+	// the primary server is always used first
+	for i := 0; i < 2; i++ {
+		conn, err := servers.dial(backend.dial)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if !reflect.DeepEqual(backend.dialed, []string{urls[0], urls[0]}) {
+		t.Error("Expected only the primary server to be dialed, got:", backend.dialed)
+	}
+
+	// the next server is used when the primary is down, and the primary is
+	// skipped until the retry interval passes
+	backend.dialed = nil
+	backend.down[urls[0]] = true
+	for i := 0; i < 2; i++ {
+		conn, err := servers.dial(backend.dial)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if !reflect.DeepEqual(backend.dialed, []string{urls[0], urls[1], urls[1]}) {
+		t.Error("Expected failover to the second server, got:", backend.dialed)
+	}
+	if down := servers.down(); !reflect.DeepEqual(down, []string{urls[0]}) {
+		t.Error("Expected the primary server to be down, got:", down)
+	}
+
+	// servers that recover are used again once marked up
+	servers.markUp(urls[0])
+	backend.dialed = nil
+	delete(backend.down, urls[0])
+	conn, err := servers.dial(backend.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if !reflect.DeepEqual(backend.dialed, []string{urls[0]}) {
+		t.Error("Expected the recovered primary server to be used, got:", backend.dialed)
+	}
+
+	// every server is tried before giving up
+	for _, u := range urls {
+		backend.down[u] = true
+	}
+	_, err = servers.dial(backend.dial)
+	if err == nil {
+		t.Fatal("Expected error when no servers are reachable")
+	}
+	for _, u := range urls {
+		if !strings.Contains(err.Error(), u) {
+			t.Errorf("Expected error to contain %s, got: %s", u, err)
+		}
+	}
+}
+
+func TestServerListRoundRobin(t *testing.T) {
+	urls := []string{"ldap://ldap-1:389", "ldap://ldap-2:389", "ldap://ldap-3:389"}
+	servers := &serverList{}
+	servers.reset(urls, true, time.Minute)
+	backend := &testServers{down: map[string]bool{urls[1]: true}}
+
+	for i := 0; i < 3; i++ {
+		conn, err := servers.dial(backend.dial)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	// the second server is skipped once it is found to be down
+	expected := []string{urls[0], urls[1], urls[2], urls[2]}
+	if !reflect.DeepEqual(backend.dialed, expected) {
+		t.Errorf("Expected dials %v, got: %v", expected, backend.dialed)
+	}
+}
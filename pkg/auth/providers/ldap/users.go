@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
-	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 
 	ldapv3 "github.com/go-ldap/ldap/v3"
@@ -13,17 +12,13 @@ import (
 
 // GetUsers should return a list of VDIUsers.
 func (a *AuthProvider) GetUsers() ([]*v1.VDIUser, error) {
-	conn, err := a.connect()
+	conn, err := a.pool.get()
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-
-	if err := a.bind(conn); err != nil {
-		return nil, err
-	}
+	defer a.pool.put(conn)
 	// fetch the role mappings
-	roles, err := a.cluster.GetRoles(a.client)
+	roles, err := a.cluster.GetResolvedRoles(a.client)
 	if err != nil {
 		return nil, err
 	}
@@ -44,16 +39,16 @@ func (a *AuthProvider) GetUsers() ([]*v1.VDIUser, error) {
 					searchRequest := ldapv3.NewSearchRequest(
 						a.getUserBase(),
 						ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, 0, 0, false,
-						fmt.Sprintf(groupUsersFilter, group),
-						userAttrs,
+						a.getGroupUsersFilter(group),
+						a.getUserAttributes(),
 						nil,
 					)
-					sr, err := conn.Search(searchRequest)
+					entries, err := a.search(conn, searchRequest)
 					if err != nil {
 						return nil, err
 					}
-					for _, entry := range sr.Entries {
-						vdiUsers = appendUser(vdiUsers, entry.GetAttributeValue("uid"), userRole)
+					for _, entry := range entries {
+						vdiUsers = appendUser(vdiUsers, a.newVDIUser(a.getUsername(entry), entry), userRole)
 					}
 				}
 			}
@@ -66,18 +61,14 @@ func (a *AuthProvider) GetUsers() ([]*v1.VDIUser, error) {
 
 // GetUser should retrieve a single VDIUser.
 func (a *AuthProvider) GetUser(username string) (*v1.VDIUser, error) {
-	conn, err := a.connect()
+	conn, err := a.pool.get()
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-
-	if err := a.bind(conn); err != nil {
-		return nil, err
-	}
+	defer a.pool.put(conn)
 
 	// fetch the role mappings
-	roles, err := a.cluster.GetRoles(a.client)
+	roles, err := a.cluster.GetResolvedRoles(a.client)
 	if err != nil {
 		return nil, err
 	}
@@ -85,26 +76,28 @@ func (a *AuthProvider) GetUser(username string) (*v1.VDIUser, error) {
 	searchRequest := ldapv3.NewSearchRequest(
 		a.getUserBase(),
 		ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, 0, 0, false,
-		fmt.Sprintf(userFilter, username),
-		userAttrs,
+		a.getUserFilter(username),
+		a.getUserAttributes(),
 		nil,
 	)
-	sr, err := conn.Search(searchRequest)
+	entries, err := a.search(conn, searchRequest)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(sr.Entries) != 1 {
-		return nil, errors.NewUserNotFoundError(fmt.Sprintf("Received %d matches for %s", len(sr.Entries), username))
+	if len(entries) != 1 {
+		return nil, errors.NewUserNotFoundError(fmt.Sprintf("Received %d matches for %s", len(entries), username))
 	}
 
-	user := sr.Entries[0]
+	user := entries[0]
 
-	vdiUser := &v1.VDIUser{
-		Name:  username,
-		Roles: make([]*v1.VDIUserRole, 0),
+	userGroups, err := a.getUserGroups(conn, user)
+	if err != nil {
+		return nil, err
 	}
 
+	vdiUser := a.newVDIUser(username, user)
+
 RoleLoop:
 	for _, role := range roles {
 		if annotations := role.GetAnnotations(); annotations != nil {
@@ -114,7 +107,7 @@ RoleLoop:
 					if group == "" {
 						continue GroupLoop
 					}
-					if common.StringSliceContains(user.GetAttributeValues("memberOf"), group) {
+					if containsDN(userGroups, group) {
 						vdiUser.Roles = append(vdiUser.Roles, role.ToUserRole())
 						continue RoleLoop
 					}
@@ -126,6 +119,16 @@ RoleLoop:
 	return vdiUser, nil
 }
 
+// Refresh looks up the user's current groups in the directory for renewing
+// their session.
+func (a *AuthProvider) Refresh(username, _ string) (*v1.AuthResult, error) {
+	user, err := a.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.AuthResult{User: user}, nil
+}
+
 // CreateUser should handle any logic required to register a new user in kVDI.
 func (a *AuthProvider) CreateUser(*v1.CreateUserRequest) error {
 	return errors.New("Creating users is not supported when using LDAP authentication")
@@ -136,14 +139,49 @@ func (a *AuthProvider) UpdateUser(string, *v1.UpdateUserRequest) error {
 	return errors.New("Updating users is not supported when using LDAP authentication")
 }
 
+// ChangePassword changes the password for a user in the directory. The change is
+// made while bound as the user, so any password policies in the directory still
+// apply. The server must support the password modify extended operation.
+func (a *AuthProvider) ChangePassword(username string, req *v1.ChangePasswordRequest) error {
+	conn, err := a.pool.get()
+	if err != nil {
+		return err
+	}
+	defer a.pool.put(conn)
+
+	searchRequest := ldapv3.NewSearchRequest(
+		a.getUserBase(),
+		ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, 0, 0, false,
+		a.getUserFilter(username),
+		a.getUserAttributes(),
+		nil,
+	)
+	entries, err := a.search(conn, searchRequest)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) != 1 {
+		return errors.NewUserNotFoundError(fmt.Sprintf("Received %d matches for %s", len(entries), username))
+	}
+
+	user := entries[0]
+
+	if a.accountDisabled(user) {
+		return fmt.Errorf("User account %s is disabled", a.getUsername(user))
+	}
+
+	return a.modifyUserPassword(conn, user, req.OldPassword, req.NewPassword)
+}
+
 // DeleteUser should remove a VDIUser.
 func (a *AuthProvider) DeleteUser(string) error {
 	return errors.New("Deleting users is not supported when using LDAP authentication")
 }
 
-func appendUser(vdiUsers []*v1.VDIUser, name string, role *v1.VDIUserRole) []*v1.VDIUser {
+func appendUser(vdiUsers []*v1.VDIUser, newUser *v1.VDIUser, role *v1.VDIUserRole) []*v1.VDIUser {
 	for _, user := range vdiUsers {
-		if user.Name == name {
+		if user.Name == newUser.Name {
 			for _, userRole := range user.Roles {
 				if userRole.Name == role.Name {
 					return vdiUsers
@@ -153,8 +191,6 @@ func appendUser(vdiUsers []*v1.VDIUser, name string, role *v1.VDIUserRole) []*v1
 			return vdiUsers
 		}
 	}
-	return append(vdiUsers, &v1.VDIUser{
-		Name:  name,
-		Roles: []*v1.VDIUserRole{role},
-	})
+	newUser.Roles = append(newUser.Roles, role)
+	return append(vdiUsers, newUser)
 }
@@ -1,14 +1,17 @@
 package local
 
 import (
+	"fmt"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
-	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 )
 
 // GetUsers implements AuthProvider and serves a GET /api/users request
 func (a *AuthProvider) GetUsers() ([]*v1.VDIUser, error) {
-	roles, err := a.cluster.GetRoles(a.client)
+	roles, groups, err := a.getRolesAndGroups()
 	if err != nil {
 		return nil, err
 	}
@@ -18,10 +21,7 @@ func (a *AuthProvider) GetUsers() ([]*v1.VDIUser, error) {
 	}
 	res := make([]*v1.VDIUser, 0)
 	for _, user := range users {
-		res = append(res, &v1.VDIUser{
-			Name:  user.Username,
-			Roles: apiutil.FilterUserRolesByNames(roles, user.Groups),
-		})
+		res = append(res, toVDIUser(user, roles, groups))
 	}
 
 	return res, nil
@@ -29,6 +29,9 @@ func (a *AuthProvider) GetUsers() ([]*v1.VDIUser, error) {
 
 // CreateUser implements AuthProvider and serves a POST /api/users request
 func (a *AuthProvider) CreateUser(req *v1.CreateUserRequest) error {
+	if err := checkPasswordPolicy(a.cluster.GetPasswordPolicy(), req.Username, req.Password); err != nil {
+		return err
+	}
 	passwdHash, err := common.HashPassword(req.Password)
 	if err != nil {
 		return err
@@ -37,8 +40,13 @@ func (a *AuthProvider) CreateUser(req *v1.CreateUserRequest) error {
 		Username:     req.Username,
 		PasswordHash: passwdHash,
 		Groups:       req.Roles,
+		Expirations:  req.RoleExpirations,
 	}
-	return a.createUser(user)
+	if err := a.createUser(user); err != nil {
+		return err
+	}
+	// clear any history left behind by a previous user with the same name
+	return a.setPasswordHistory(req.Username, nil)
 }
 
 // GetUser implements AuthProvider and serves a GET /api/users/{user} request
@@ -48,15 +56,22 @@ func (a *AuthProvider) GetUser(username string) (*v1.VDIUser, error) {
 		return nil, err
 	}
 
-	roles, err := a.cluster.GetRoles(a.client)
+	roles, groups, err := a.getRolesAndGroups()
 	if err != nil {
 		return nil, err
 	}
 
-	return &v1.VDIUser{
-		Name:  user.Username,
-		Roles: apiutil.FilterUserRolesByNames(roles, user.Groups),
-	}, nil
+	return toVDIUser(user, roles, groups), nil
+}
+
+// Refresh implements AuthProvider and looks up the user's current roles for
+// renewing their session.
+func (a *AuthProvider) Refresh(username, _ string) (*v1.AuthResult, error) {
+	user, err := a.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.AuthResult{User: user}, nil
 }
 
 // UpdateUser implements AuthProvider and serves a PUT /api/users/{user} request
@@ -64,18 +79,91 @@ func (a *AuthProvider) UpdateUser(username string, req *v1.UpdateUserRequest) er
 	user := &User{Username: username}
 	if len(req.Roles) != 0 {
 		user.Groups = req.Roles
+		user.Expirations = req.RoleExpirations
 	}
-	if req.Password != "" {
-		passwdHash, err := common.HashPassword(req.Password)
-		if err != nil {
-			return err
-		}
-		user.PasswordHash = passwdHash
+	if req.Password == "" {
+		return a.updateUser(user)
+	}
+
+	existing, err := a.getUser(username)
+	if err != nil {
+		return err
+	}
+	return a.setPassword(existing, user, req.Password)
+}
+
+// ChangePassword implements AuthProvider and serves a PUT /api/users/{user}/password request
+func (a *AuthProvider) ChangePassword(username string, req *v1.ChangePasswordRequest) error {
+	existing, err := a.getUser(username)
+	if err != nil {
+		return err
+	}
+	if !existing.PasswordMatchesHash(req.OldPassword) {
+		return errors.NewInvalidCredentialsError(username)
+	}
+	return a.setPassword(existing, &User{Username: username}, req.NewPassword)
+}
+
+// setPassword checks the given password against the password policy and the
+// existing user's history, and then applies it along with any other changes in
+// the given user.
+func (a *AuthProvider) setPassword(existing, user *User, password string) error {
+	policy := a.cluster.GetPasswordPolicy()
+	if err := checkPasswordPolicy(policy, existing.Username, password); err != nil {
+		return err
+	}
+	history, err := a.checkPasswordHistory(policy, existing, password)
+	if err != nil {
+		return err
+	}
+
+	passwdHash, err := common.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = passwdHash
+	if err := a.updateUser(user); err != nil {
+		return err
 	}
-	return a.updateUser(user)
+	return a.setPasswordHistory(existing.Username, history)
 }
 
 // DeleteUser implements AuthProvider and serves a DELETE /api/users/{user} request
 func (a *AuthProvider) DeleteUser(username string) error {
-	return a.deleteUser(username)
+	if err := a.deleteUser(username); err != nil {
+		return err
+	}
+	if err := a.removeUserFromGroups(username); err != nil {
+		return err
+	}
+	return a.setPasswordHistory(username, nil)
+}
+
+// checkPasswordHistory returns an error if the given password matches one of the
+// user's recent passwords. Otherwise, the history to store once the password is
+// changed is returned.
+func (a *AuthProvider) checkPasswordHistory(policy *v1alpha1.PasswordPolicy, user *User, password string) ([]string, error) {
+	if policy.HistorySize <= 0 {
+		return nil, nil
+	}
+	history, err := a.getPasswordHistory(user.Username)
+	if err != nil {
+		return nil, err
+	}
+	// the current password is always the most recent
+	recent := append([]string{user.PasswordHash}, history...)
+	if len(recent) > policy.HistorySize {
+		recent = recent[:policy.HistorySize]
+	}
+	for _, hash := range recent {
+		if common.PasswordMatchesHash(password, hash) {
+			return nil, fmt.Errorf("Password cannot be one of the last %d passwords used", policy.HistorySize)
+		}
+	}
+	// the new password becomes the current one, so only the ones before it need
+	// to be kept
+	if len(recent) == policy.HistorySize {
+		recent = recent[:policy.HistorySize-1]
+	}
+	return recent, nil
 }
@@ -104,6 +104,7 @@ func updateUserInBuffer(file io.Reader, updated *User) (io.Reader, error) {
 		if user.Username == updated.Username {
 			if len(updated.Groups) == 0 {
 				updated.Groups = user.Groups
+				updated.Expirations = user.Expirations
 			}
 			if updated.PasswordHash == "" {
 				updated.PasswordHash = user.PasswordHash
@@ -3,8 +3,10 @@ package local
 import (
 	"bytes"
 	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestAddUserToBuffer(t *testing.T) {
@@ -35,3 +37,36 @@ func TestAddUserToBuffer(t *testing.T) {
 func TestGetUserFromBuffer(t *testing.T)  {}
 func TestUpdateUserInBuffer(t *testing.T) {}
 func TestDeleteUserInBuffer(t *testing.T) {}
+
+func TestUserRoleExpirations(t *testing.T) {
+	now := time.Now().Unix()
+	user := &User{
+		Username:     "user1",
+		Groups:       []string{"admin", "oncall", "expired"},
+		PasswordHash: "hash",
+		Expirations:  map[string]int64{"oncall": now + 3600, "expired": now - 1},
+	}
+	parsed, err := ParseUser(strings.TrimSpace(string(user.Encode())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed.Groups, user.Groups) || !reflect.DeepEqual(parsed.Expirations, user.Expirations) {
+		t.Error("Expected expirations to survive encoding, got:", parsed.Groups, parsed.Expirations)
+	}
+	if active := parsed.ActiveGroups(); !reflect.DeepEqual(active, []string{"admin", "oncall"}) {
+		t.Error("Expected expired role to be inactive, got:", active)
+	}
+	if !parsed.PruneExpiredGroups() {
+		t.Error("Expected expired role to be pruned")
+	}
+	if _, ok := parsed.Expirations["expired"]; ok || len(parsed.Groups) != 2 {
+		t.Error("Expected expired role to be removed, got:", parsed.Groups, parsed.Expirations)
+	}
+	if parsed.PruneExpiredGroups() {
+		t.Error("Expected nothing left to prune")
+	}
+
+	if _, err := ParseUser("user1:admin@never:hash"); err == nil {
+		t.Error("Expected error for invalid role expiry")
+	}
+}
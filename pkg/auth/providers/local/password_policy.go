@@ -0,0 +1,124 @@
+package local
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+// commonPasswords is a list of frequently used passwords rejected when the
+// policy has RejectCommonPasswords set.
+var commonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "password", "password1",
+	"password123", "qwerty", "qwerty123", "qwertyuiop", "abc123", "111111",
+	"123123", "000000", "iloveyou", "letmein", "welcome", "welcome1",
+	"monkey", "dragon", "football", "baseball", "sunshine", "princess",
+	"master", "shadow", "superman", "trustno1", "starwars", "passw0rd",
+	"p@ssw0rd", "p@ssword", "admin", "admin123", "administrator", "changeme",
+	"secret", "login", "zaq12wsx", "1q2w3e4r", "1qaz2wsx", "asdfghjkl",
+	"whatever", "michael", "jennifer", "hunter2", "solo", "kvdi",
+}
+
+// breachCheckClient is the client used to query the breach range API.
+var breachCheckClient = &http.Client{Timeout: 10 * time.Second}
+
+// checkPasswordPolicy returns an error if the given password does not satisfy
+// the given policy.
+func checkPasswordPolicy(policy *v1alpha1.PasswordPolicy, username, password string) error {
+	if len([]rune(password)) < policy.GetMinLength() {
+		return fmt.Errorf("Password must be at least %d characters long", policy.GetMinLength())
+	}
+
+	var hasUpper, hasLower, hasNumber, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasNumber = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	for _, req := range []struct {
+		required, present bool
+		class             string
+	}{
+		{policy.RequireUppercase, hasUpper, "an uppercase letter"},
+		{policy.RequireLowercase, hasLower, "a lowercase letter"},
+		{policy.RequireNumbers, hasNumber, "a number"},
+		{policy.RequireSymbols, hasSymbol, "a symbol"},
+	} {
+		if req.required && !req.present {
+			return fmt.Errorf("Password must contain %s", req.class)
+		}
+	}
+
+	if policy.RejectCommonPasswords {
+		lower := strings.ToLower(password)
+		for _, common := range commonPasswords {
+			if lower == common {
+				return errors.New("Password is too common")
+			}
+		}
+		if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+			return errors.New("Password cannot contain the username")
+		}
+	}
+
+	if policy.CheckBreaches {
+		breached, err := passwordIsBreached(policy.GetBreachAPIURL(), password)
+		if err != nil {
+			return fmt.Errorf("Failed to check password against known breaches: %s", err.Error())
+		}
+		if breached {
+			return errors.New("Password has appeared in a known data breach")
+		}
+	}
+
+	return nil
+}
+
+// passwordIsBreached queries the given range API for the password. Only the
+// first five characters of the SHA-1 hash are sent, and the remainder is
+// compared against the suffixes returned.
+func passwordIsBreached(apiURL, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequest(http.MethodGet, apiURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Add-Padding", "true")
+	resp, err := breachCheckClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Breach API returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)
+		if len(fields) != 2 || !strings.EqualFold(fields[0], suffix) {
+			continue
+		}
+		// padded responses include entries with a count of zero
+		return strings.TrimSpace(fields[1]) != "0", nil
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,121 @@
+package local
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+)
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	policy := &v1alpha1.PasswordPolicy{}
+	if err := checkPasswordPolicy(policy, "user", "a"); err == nil {
+		t.Error("Expected short password to be rejected by the default policy")
+	}
+	if err := checkPasswordPolicy(policy, "user", "password"); err != nil {
+		t.Error("Expected password to satisfy the default policy, got:", err)
+	}
+
+	policy = &v1alpha1.PasswordPolicy{
+		MinLength:             10,
+		RequireUppercase:      true,
+		RequireLowercase:      true,
+		RequireNumbers:        true,
+		RequireSymbols:        true,
+		RejectCommonPasswords: true,
+	}
+	for _, passw := range []string{
+		"Sh0rt!",
+		"nouppercase1!",
+		"NOLOWERCASE1!",
+		"NoNumbersHere!",
+		"NoSymbols1234",
+		"P@ssw0rd-user-1",
+	} {
+		if err := checkPasswordPolicy(policy, "user", passw); err == nil {
+			t.Errorf("Expected %q to be rejected", passw)
+		}
+	}
+	if err := checkPasswordPolicy(policy, "user", "Corr3ct-Horse"); err != nil {
+		t.Error("Expected password to satisfy the policy, got:", err)
+	}
+
+	policy = &v1alpha1.PasswordPolicy{RejectCommonPasswords: true}
+	if err := checkPasswordPolicy(policy, "user", "Password123"); err == nil {
+		t.Error("Expected common password to be rejected regardless of case")
+	}
+}
+
+func TestPasswordBreachCheck(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/range/5BAA6" {
+			fmt.Fprintln(w, "0018A45C4D1DEF81644B54AB7F969B88D65:0")
+			return
+		}
+		fmt.Fprintln(w, "003D68EB55068C33ACE09247EE4C639306B:3")
+		fmt.Fprintln(w, "1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493")
+	}))
+	defer srvr.Close()
+
+	policy := &v1alpha1.PasswordPolicy{CheckBreaches: true, BreachAPIURL: srvr.URL + "/range"}
+	if err := checkPasswordPolicy(policy, "user", "password"); err == nil {
+		t.Error("Expected breached password to be rejected")
+	}
+	if err := checkPasswordPolicy(policy, "user", "not-a-breached-password"); err != nil {
+		t.Error("Expected password not in the breach API to be accepted, got:", err)
+	}
+
+	srvr.Close()
+	if err := checkPasswordPolicy(policy, "user", "not-a-breached-password"); err == nil {
+		t.Error("Expected an error when the breach API is unavailable")
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{
+		LocalAuth: &v1alpha1.LocalAuthConfig{
+			PasswordPolicy: &v1alpha1.PasswordPolicy{HistorySize: 2},
+		},
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	c := getFakeClient(t)
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(c, cluster); err != nil {
+		t.Fatal(err)
+	}
+	provider := New(engine)
+	if err := provider.Setup(c, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Reconcile(nil, c, cluster, "admin-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	update := func(passw string) error {
+		return provider.UpdateUser("admin", &v1.UpdateUserRequest{Password: passw})
+	}
+	if err := update("admin-password"); err == nil {
+		t.Error("Expected the current password to be rejected")
+	}
+	if err := update("second-password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := update("admin-password"); err == nil {
+		t.Error("Expected the previous password to be rejected")
+	}
+	if err := update("third-password"); err != nil {
+		t.Fatal(err)
+	}
+	// the first password is now outside the history
+	if err := update("admin-password"); err != nil {
+		t.Error("Expected password outside the history to be accepted, got:", err)
+	}
+}
@@ -1,6 +1,7 @@
 package local
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
@@ -13,6 +14,10 @@ import (
 
 const passwdKey = "passwd"
 
+// passwdHistoryKey is where hashes of previous passwords are kept when the
+// password policy restricts reuse.
+const passwdHistoryKey = "passwdHistory"
+
 // Reconcile prepares the resources required to use the local authentication driver.
 func (l *AuthProvider) Reconcile(reqLogger logr.Logger, c client.Client, cluster *v1alpha1.VDICluster, adminPass string) error {
 	if _, err := l.secrets.ReadSecret(passwdKey, false); err != nil {
@@ -28,5 +33,37 @@ func (l *AuthProvider) Reconcile(reqLogger logr.Logger, c client.Client, cluster
 			return err
 		}
 	}
-	return nil
+	return l.pruneExpiredGroups()
+}
+
+// pruneExpiredGroups removes roles that were assigned temporarily and have since
+// expired from the passwd file. They are already left out when building the claims
+// for a user, this just keeps the file tidy.
+func (l *AuthProvider) pruneExpiredGroups() error {
+	if err := l.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer l.secrets.Release()
+	file, err := l.getPasswdFile()
+	if err != nil {
+		return err
+	}
+	users, err := getAllUsersFromBuffer(file)
+	if err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	var pruned bool
+	for _, user := range users {
+		if user.PruneExpiredGroups() {
+			pruned = true
+		}
+		if _, err := buf.Write(user.Encode()); err != nil {
+			return err
+		}
+	}
+	if !pruned {
+		return nil
+	}
+	return l.updatePasswdFile(buf)
 }
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"strings"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 )
 
 func (a *AuthProvider) getPasswdFile() (io.ReadWriter, error) {
@@ -21,3 +24,44 @@ func (a *AuthProvider) updatePasswdFile(rdr io.Reader) error {
 	}
 	return a.secrets.WriteSecret(passwdKey, body)
 }
+
+// getPasswordHistory returns the hashes of previous passwords for the given user,
+// most recent first.
+func (a *AuthProvider) getPasswordHistory(username string) ([]string, error) {
+	history, err := a.secrets.ReadSecretMap(passwdHistoryKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	if len(history[username]) == 0 {
+		return []string{}, nil
+	}
+	return strings.Split(string(history[username]), "\n"), nil
+}
+
+// setPasswordHistory stores the given password hashes for the user. If there are
+// none, the user is removed from the history.
+func (a *AuthProvider) setPasswordHistory(username string, hashes []string) error {
+	if err := a.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer a.secrets.Release()
+	history, err := a.secrets.ReadSecretMap(passwdHistoryKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return err
+		}
+		history = make(map[string][]byte)
+	}
+	if len(hashes) == 0 {
+		if _, ok := history[username]; !ok {
+			return nil
+		}
+		delete(history, username)
+	} else {
+		history[username] = []byte(strings.Join(hashes, "\n"))
+	}
+	return a.secrets.WriteSecretMap(passwdHistoryKey, history)
+}
@@ -3,16 +3,24 @@ package local
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 )
 
+// roleExpirySeparator separates a role in the passwd file from the unix timestamp
+// at which it expires, if it was assigned temporarily.
+const roleExpirySeparator = "@"
+
 // User is a struct implementation of a user as stored in the passwd file.
 type User struct {
 	Username     string
 	Groups       []string
 	PasswordHash string
+	// Unix timestamps at which any of the Groups expire
+	Expirations map[string]int64
 }
 
 // PasswordMatchesHash returns true if the supplied password matches the hash for this
@@ -21,9 +29,48 @@ func (u *User) PasswordMatchesHash(passw string) bool {
 	return common.PasswordMatchesHash(passw, u.PasswordHash)
 }
 
+// ActiveGroups returns the groups of this user that have not expired.
+func (u *User) ActiveGroups() []string {
+	now := time.Now().Unix()
+	groups := make([]string, 0, len(u.Groups))
+	for _, group := range u.Groups {
+		if expiresAt, ok := u.Expirations[group]; ok && expiresAt <= now {
+			continue
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// PruneExpiredGroups removes the groups that have expired from this user. It returns
+// true if any were removed.
+func (u *User) PruneExpiredGroups() bool {
+	active := u.ActiveGroups()
+	if len(active) == len(u.Groups) {
+		return false
+	}
+	expirations := make(map[string]int64)
+	for _, group := range active {
+		if expiresAt, ok := u.Expirations[group]; ok {
+			expirations[group] = expiresAt
+		}
+	}
+	u.Groups = active
+	u.Expirations = expirations
+	return true
+}
+
 // Encode will return the string representation of this user for storage in the secret.
 func (u *User) Encode() []byte {
-	return []byte(fmt.Sprintf("%s:%s:%s\n", u.Username, strings.Join(u.Groups, ","), u.PasswordHash))
+	groups := make([]string, len(u.Groups))
+	for idx, group := range u.Groups {
+		if expiresAt, ok := u.Expirations[group]; ok {
+			groups[idx] = fmt.Sprintf("%s%s%d", group, roleExpirySeparator, expiresAt)
+			continue
+		}
+		groups[idx] = group
+	}
+	return []byte(fmt.Sprintf("%s:%s:%s\n", u.Username, strings.Join(groups, ","), u.PasswordHash))
 }
 
 // ParseUser will parse a string representation of a user into a User object.
@@ -34,8 +81,20 @@ func ParseUser(text string) (*User, error) {
 	}
 	user := &User{
 		Username:     fields[0],
-		Groups:       strings.Split(fields[1], ","),
+		Groups:       make([]string, 0),
 		PasswordHash: strings.Join(fields[2:], ":"),
+		Expirations:  make(map[string]int64),
+	}
+	for _, group := range strings.Split(fields[1], ",") {
+		spl := strings.Split(group, roleExpirySeparator)
+		if len(spl) == 2 {
+			expiresAt, err := strconv.ParseInt(spl[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid expiry for role %s: %s", spl[0], err.Error())
+			}
+			user.Expirations[spl[0]] = expiresAt
+		}
+		user.Groups = append(user.Groups, spl[0])
 	}
 	return user, nil
 }
@@ -1,5 +1,16 @@
 package local
 
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/common"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
 // listUsers builds a map of users to their "groups".
 func (a *AuthProvider) listUsers() ([]*User, error) {
 	file, err := a.getPasswdFile()
@@ -71,3 +82,62 @@ func (a *AuthProvider) deleteUser(username string) error {
 	}
 	return a.updatePasswdFile(newFile)
 }
+
+// toVDIUser returns the VDIUser for the given local user. The roles granted through
+// the given groups are added to the ones assigned to the user directly. Roles that
+// were assigned temporarily and have expired are left out.
+func toVDIUser(user *User, roles []v1alpha1.VDIRole, groups []v1alpha1.VDIGroup) *v1.VDIUser {
+	vdiUser := &v1.VDIUser{
+		Name:   user.Username,
+		Roles:  apiutil.FilterUserRolesByNames(roles, v1alpha1.GetUserRoleNames(groups, user.Username, user.ActiveGroups())),
+		Groups: v1alpha1.GetUserGroupNames(groups, user.Username),
+	}
+	// roles granted through groups do not expire
+	groupRoles := v1alpha1.GetUserRoleNames(groups, user.Username, nil)
+	for _, role := range vdiUser.Roles {
+		if common.StringSliceContains(groupRoles, role.GetName()) {
+			continue
+		}
+		role.ExpiresAt = user.Expirations[role.GetName()]
+	}
+	return vdiUser
+}
+
+// getRolesAndGroups returns the resolved roles and the groups for the cluster.
+func (a *AuthProvider) getRolesAndGroups() ([]v1alpha1.VDIRole, []v1alpha1.VDIGroup, error) {
+	roles, err := a.cluster.GetResolvedRoles(a.client)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups, err := a.cluster.GetGroups(a.client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roles, groups, nil
+}
+
+// removeUserFromGroups removes the given user from every group they are a member
+// of, so that a new user with the same name does not inherit their membership.
+func (a *AuthProvider) removeUserFromGroups(username string) error {
+	groups, err := a.cluster.GetGroups(a.client)
+	if err != nil {
+		return err
+	}
+	for idx := range groups {
+		group := &groups[idx]
+		if !group.HasUser(username) {
+			continue
+		}
+		users := make([]string, 0, len(group.Users))
+		for _, user := range group.Users {
+			if user != username {
+				users = append(users, user)
+			}
+		}
+		group.Users = users
+		if err := a.client.Update(context.TODO(), group); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
-	"golang.org/x/oauth2"
 )
 
 // Authenticate is called for API authentication requests. It should generate
@@ -39,10 +38,17 @@ func (a *AuthProvider) Authenticate(req *v1.LoginRequest) (*v1.AuthResult, error
 			// If the secret is not found it means we have not generated claims yet
 			// for this user. Return the oauth redirect.
 			if errors.IsSecretNotFoundError(err) {
+				// Generate a nonce and PKCE verifier for this flow and save them
+				// for when the provider redirects back to us.
+				flow, err := newAuthFlow(a.cluster.GetOIDCPKCEEnabled())
+				if err != nil {
+					return nil, err
+				}
+				if err := a.writeAuthFlow(req.GetState(), flow); err != nil {
+					return nil, err
+				}
 				return &v1.AuthResult{
-					// Use offline access to get a refresh token that we can use to generate new
-					// internal access tokens for the user.
-					RedirectURL: a.oauthCfg.AuthCodeURL(req.GetState(), oauth2.AccessTypeOffline),
+					RedirectURL: a.oauthCfg.AuthCodeURL(req.GetState(), flow.authCodeOptions()...),
 				}, nil
 			}
 			return nil, err
@@ -63,9 +69,19 @@ func (a *AuthProvider) Authenticate(req *v1.LoginRequest) (*v1.AuthResult, error
 	// sending another post to retrieve its token.
 
 	// fetch the state key from the request
-	stateKey := getStateSecretKey(r.URL.Query().Get("state"))
+	state := r.URL.Query().Get("state")
+	stateKey := getStateSecretKey(state)
+	// retrieve the nonce and code verifier generated at the start of the flow
+	flow, err := a.popAuthFlow(state)
+	if err != nil {
+		return nil, err
+	}
+	exchangeOpts, err := a.exchangeOptions(flow)
+	if err != nil {
+		return nil, err
+	}
 	// get the oauth token from the provider
-	oauth2Token, err := a.oauthCfg.Exchange(a.ctx, r.URL.Query().Get("code"))
+	oauth2Token, err := a.oauthCfg.Exchange(a.ctx, r.URL.Query().Get("code"), exchangeOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -82,12 +98,40 @@ func (a *AuthProvider) Authenticate(req *v1.LoginRequest) (*v1.AuthResult, error
 		return nil, err
 	}
 
+	// Make sure the token was issued for this flow
+	if idToken.Nonce != flow.Nonce {
+		return nil, errors.New("The nonce in the ID token does not match the authorization request")
+	}
+
 	// parse the claims from the token
 	claims := make(map[string]interface{})
 	if err := idToken.Claims(&claims); err != nil {
 		return nil, err
 	}
 
+	user, err := a.getUserFromClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	// remember the session for when the user logs out
+	if err := a.recordSession(idToken.Subject, user.GetName(), rawIDToken); err != nil {
+		return nil, err
+	}
+	fmt.Println("Saving claims to state key", stateKey)
+
+	// save the claims to the secret backend, they will be retrieved on the next POST
+	// for this state. The session can only be renewed if the provider issued a
+	// refresh token.
+	return nil, a.marshalClaimsToSecret(stateKey, &v1.AuthResult{
+		User:                 user,
+		RefreshNotSupported:  oauth2Token.RefreshToken == "",
+		ProviderRefreshToken: oauth2Token.RefreshToken,
+	})
+}
+
+// getUserFromClaims builds a VDIUser from the claims in an ID token, binding the
+// groups in the claims to VDIRoles.
+func (a *AuthProvider) getUserFromClaims(claims map[string]interface{}) (*v1.VDIUser, error) {
 	// start building a user from the claims object
 	username, err := getUsernameFromClaims(claims)
 	if err != nil {
@@ -99,27 +143,33 @@ func (a *AuthProvider) Authenticate(req *v1.LoginRequest) (*v1.AuthResult, error
 	}
 
 	// check if we can handle group membership
-	groups, ok := claims[a.cluster.GetOIDCGroupScope()]
-	if !ok {
+	userGroupSlc := make([]string, 0)
+	var found bool
+	for _, claim := range a.cluster.GetOIDCGroupClaims() {
+		groupSlc, ok, err := a.getGroupsFromClaim(claims, claim)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		for _, group := range groupSlc {
+			userGroupSlc = common.AppendStringIfMissing(userGroupSlc, group)
+		}
+	}
+	if !found {
 		// if we can't determine group membership, check if cluster configuration
 		// allows the user in anyway.
 		if a.cluster.AllowNonGroupedReadOnly() {
 			user.Roles = []*v1.VDIUserRole{a.cluster.GetLaunchTemplatesRole().ToUserRole()}
-			return nil, a.marshalClaimsToSecret(stateKey, &v1.AuthResult{
-				User:                user,
-				RefreshNotSupported: true,
-			})
+			return user, nil
 		}
 		return nil, errors.New("No groups provided in claims and allow non-grouped users is set to false")
 	}
 
-	userGroupSlc, err := groupClaimToStringSlice(groups)
-	if err != nil {
-		return nil, err
-	}
-
 	// At this point we are ready to authorize the user
-	roles, err := a.cluster.GetRoles(a.client)
+	roles, err := a.cluster.GetResolvedRoles(a.client)
 	if err != nil {
 		return nil, err
 	}
@@ -130,14 +180,7 @@ func (a *AuthProvider) Authenticate(req *v1.LoginRequest) (*v1.AuthResult, error
 	}
 
 	user.Roles = apiutil.FilterUserRolesByNames(roles, boundRoles)
-	fmt.Println("Saving claims to state key", stateKey)
-
-	// save the claims to the secret backend, they will be retrieved on the next POST
-	// for this state.
-	return nil, a.marshalClaimsToSecret(stateKey, &v1.AuthResult{
-		User:                user,
-		RefreshNotSupported: true,
-	})
+	return user, nil
 }
 
 func (a *AuthProvider) marshalClaimsToSecret(stateKey string, result *v1.AuthResult) error {
@@ -156,7 +199,43 @@ func getStateSecretKey(state string) string {
 	return fmt.Sprintf("oidc_%s", state)
 }
 
+// getClaim returns the value of the given claim. If there is no claim with the
+// exact name, it is treated as a dot separated path to a nested claim.
+func getClaim(claims map[string]interface{}, name string) (interface{}, bool) {
+	if val, ok := claims[name]; ok {
+		return val, true
+	}
+	var val interface{} = claims
+	for _, field := range strings.Split(name, ".") {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if val, ok = obj[field]; !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// getGroupsFromClaim returns the groups in the given claim. Groups in the group
+// scope are resolved with the group resolver instead, when there is one.
+func (a *AuthProvider) getGroupsFromClaim(claims map[string]interface{}, name string) ([]string, bool, error) {
+	if a.groupResolver != nil && name == a.cluster.GetOIDCGroupScope() {
+		return a.groupResolver(claims)
+	}
+	groups, ok := getClaim(claims, name)
+	if !ok {
+		return nil, false, nil
+	}
+	groupSlc, err := groupClaimToStringSlice(groups)
+	return groupSlc, true, err
+}
+
 func groupClaimToStringSlice(ifc interface{}) ([]string, error) {
+	if group, ok := ifc.(string); ok {
+		return []string{group}, nil
+	}
 	userGroupSlc, ok := ifc.([]interface{})
 	if !ok {
 		return nil, errors.New("Could not coerce groups claims to string slice")
@@ -0,0 +1,89 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis"
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"groups":                    []interface{}{"developers"},
+		"https://example.com/roles": "admins",
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"kvdi-users"},
+		},
+	}
+	for _, name := range []string{"groups", "https://example.com/roles", "realm_access.roles"} {
+		if _, ok := getClaim(claims, name); !ok {
+			t.Errorf("Expected claim %q to be found", name)
+		}
+	}
+	for _, name := range []string{"roles", "realm_access.groups", "groups.developers"} {
+		if _, ok := getClaim(claims, name); ok {
+			t.Errorf("Expected claim %q to not be found", name)
+		}
+	}
+}
+
+func TestGetUserFromClaims(t *testing.T) {
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{
+		OIDCAuth: &v1alpha1.OIDCConfig{
+			RoleClaims: []string{"realm_access.roles"},
+		},
+	}
+
+	newRole := func(name, groups string) *v1alpha1.VDIRole {
+		role := &v1alpha1.VDIRole{}
+		role.Name = name
+		role.Labels = map[string]string{v1.RoleClusterRefLabel: cluster.GetName()}
+		role.Annotations = map[string]string{v1.OIDCGroupRoleAnnotation: groups}
+		return role
+	}
+	a := &AuthProvider{
+		cluster: cluster,
+		client: fake.NewFakeClientWithScheme(scheme,
+			newRole("developers", "developers"),
+			newRole("operators", "kvdi-operators;kvdi-admins"),
+			newRole("auditors", "auditors"),
+		),
+	}
+
+	user, err := a.getUserFromClaims(map[string]interface{}{
+		"preferred_username": "test-user",
+		"groups":             "developers",
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"kvdi-admins"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Roles) != 2 {
+		t.Fatal("Expected roles to be bound from both claims, got:", user.Roles)
+	}
+	for _, role := range user.Roles {
+		if role.Name != "developers" && role.Name != "operators" {
+			t.Error("Unexpected role bound to user:", role.Name)
+		}
+	}
+
+	// users without any of the claims are rejected unless non-grouped users are allowed
+	if _, err := a.getUserFromClaims(map[string]interface{}{"preferred_username": "test-user"}); err == nil {
+		t.Error("Expected error for user without group claims")
+	}
+	cluster.Spec.Auth.OIDCAuth.AllowNonGroupedReadOnly = true
+	if _, err := a.getUserFromClaims(map[string]interface{}{"preferred_username": "test-user"}); err != nil {
+		t.Error("Expected non-grouped user to be allowed, got:", err)
+	}
+}
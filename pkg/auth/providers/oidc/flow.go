@@ -0,0 +1,160 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	gooidc "github.com/coreos/go-oidc"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+// clientAssertionType is the assertion type used for private_key_jwt authentication.
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// authFlow represents the values generated at the start of an authorization code
+// flow that need to be verified when the provider redirects back to us.
+type authFlow struct {
+	// The PKCE code verifier sent during the token exchange
+	CodeVerifier string `json:"codeVerifier,omitempty"`
+	// The nonce expected in the returned ID token
+	Nonce string `json:"nonce"`
+}
+
+// newAuthFlow generates a new nonce, and if enabled, PKCE code verifier.
+func newAuthFlow(pkce bool) (*authFlow, error) {
+	var err error
+	flow := &authFlow{}
+	if flow.Nonce, err = randomString(32); err != nil {
+		return nil, err
+	}
+	if pkce {
+		if flow.CodeVerifier, err = randomString(32); err != nil {
+			return nil, err
+		}
+	}
+	return flow, nil
+}
+
+// authCodeOptions returns the options to pass when generating the auth code URL.
+func (f *authFlow) authCodeOptions() []oauth2.AuthCodeOption {
+	// Use offline access to get a refresh token that we can use to generate new
+	// internal access tokens for the user.
+	opts := []oauth2.AuthCodeOption{oauth2.AccessTypeOffline, gooidc.Nonce(f.Nonce)}
+	if f.CodeVerifier != "" {
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", codeChallengeS256(f.CodeVerifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+	}
+	return opts
+}
+
+// exchangeOptions returns the options to pass when exchanging an authorization code.
+func (a *AuthProvider) exchangeOptions(f *authFlow) ([]oauth2.AuthCodeOption, error) {
+	opts := make([]oauth2.AuthCodeOption, 0)
+	if f.CodeVerifier != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", f.CodeVerifier))
+	}
+	if a.authMethod == v1alpha1.OIDCAuthPrivateKeyJWT {
+		assertion, err := a.newClientAssertion()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			oauth2.SetAuthURLParam("client_assertion_type", clientAssertionType),
+			oauth2.SetAuthURLParam("client_assertion", assertion),
+		)
+	}
+	return opts, nil
+}
+
+// newClientAssertion generates a signed JWT for authenticating to the token endpoint.
+func (a *AuthProvider) newClientAssertion() (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(a.signingMethod, jwt.StandardClaims{
+		Issuer:    a.clientID,
+		Subject:   a.clientID,
+		Audience:  a.tokenURL,
+		Id:        uuid.New().String(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute * 5).Unix(),
+	})
+	if kid := a.cluster.GetOIDCPrivateKeyID(); kid != "" {
+		token.Header["kid"] = kid
+	}
+	return token.SignedString(a.signingKey)
+}
+
+// parseSigningKey parses a PEM encoded RSA or ECDSA private key and returns it
+// along with the signing method to use with it.
+func parseSigningKey(pemBytes []byte) (interface{}, jwt.SigningMethod, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+		return key, jwt.SigningMethodRS256, nil
+	}
+	if key, err := jwt.ParseECPrivateKeyFromPEM(pemBytes); err == nil {
+		return key, jwt.SigningMethodES256, nil
+	}
+	return nil, nil, errors.New("Could not parse OIDC private key, must be a PEM encoded RSA or ECDSA key")
+}
+
+// writeAuthFlow stores the given flow for the state in the secrets backend.
+func (a *AuthProvider) writeAuthFlow(state string, flow *authFlow) error {
+	out, err := json.Marshal(flow)
+	if err != nil {
+		return err
+	}
+	if err := a.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer a.secrets.Release()
+	return a.secrets.WriteSecret(getFlowSecretKey(state), out)
+}
+
+// popAuthFlow retrieves the flow for the given state and removes it from the
+// secrets backend. The flow is read and removed under the lock, so each one can
+// only be used once.
+func (a *AuthProvider) popAuthFlow(state string) (*authFlow, error) {
+	flowKey := getFlowSecretKey(state)
+	if err := a.secrets.Lock(15); err != nil {
+		return nil, err
+	}
+	defer a.secrets.Release()
+	raw, err := a.secrets.ReadSecret(flowKey, false)
+	if err != nil && !errors.IsSecretNotFoundError(err) {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("No authorization flow in progress for the provided state")
+	}
+	if err := a.secrets.WriteSecret(flowKey, nil); err != nil {
+		return nil, err
+	}
+	flow := &authFlow{}
+	return flow, json.Unmarshal(raw, flow)
+}
+
+func getFlowSecretKey(state string) string {
+	return fmt.Sprintf("oidc_flow_%s", state)
+}
+
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(length int) (string, error) {
+	buf := make([]byte, length)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
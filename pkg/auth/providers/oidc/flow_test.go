@@ -0,0 +1,67 @@
+package oidc
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPopAuthFlow(t *testing.T) {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	os.Setenv("POD_NAMESPACE", "default")
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(fake.NewFakeClient(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	a := &AuthProvider{cluster: cluster, secrets: engine}
+
+	flow, err := newAuthFlow(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.writeAuthFlow("test-state", flow); err != nil {
+		t.Fatal(err)
+	}
+
+	// only one of the callbacks racing for the same state gets the flow, even
+	// when they all arrive while the lock is held
+	if err := engine.Lock(15); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	results := make(chan *authFlow, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if popped, err := a.popAuthFlow("test-state"); err == nil {
+				results <- popped
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	engine.Release()
+	wg.Wait()
+	close(results)
+	popped := make([]*authFlow, 0)
+	for res := range results {
+		popped = append(popped, res)
+	}
+	if len(popped) != 1 {
+		t.Fatal("Expected the flow to be popped exactly once, got:", len(popped))
+	}
+	if popped[0].Nonce != flow.Nonce || popped[0].CodeVerifier != flow.CodeVerifier {
+		t.Errorf("Expected the stored flow, got: %+v", popped[0])
+	}
+
+	if _, err := a.popAuthFlow("unknown-state"); err == nil {
+		t.Error("Expected error for a state with no flow in progress")
+	}
+}
@@ -0,0 +1,175 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// backChannelLogoutEvent is the event that must be present in back-channel logout
+// tokens.
+const backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// logoutTokenMaxAge is how long after being issued a logout token is accepted.
+const logoutTokenMaxAge = 5 * time.Minute
+
+// logoutTokenClaims are the claims specific to back-channel logout tokens.
+type logoutTokenClaims struct {
+	Events map[string]json.RawMessage `json:"events"`
+	SID    string                     `json:"sid"`
+}
+
+// GetLogoutURL implements the LogoutHandler interface and returns the end_session_endpoint
+// of the provider for the given user. The last ID token issued to the user is included
+// as a hint and removed from the secrets backend.
+func (a *AuthProvider) GetLogoutURL(user *v1.VDIUser) (string, error) {
+	if a.endSessionURL == "" || !a.cluster.GetOIDCProviderLogoutEnabled() {
+		return "", nil
+	}
+	logoutURL, err := url.Parse(a.endSessionURL)
+	if err != nil {
+		return "", err
+	}
+	query := logoutURL.Query()
+	query.Set("client_id", a.clientID)
+	if redirectURL := a.cluster.GetOIDCPostLogoutRedirectURL(); redirectURL != "" {
+		query.Set("post_logout_redirect_uri", redirectURL)
+	}
+	idToken, err := a.popIDToken(user.GetName())
+	if err != nil {
+		return "", err
+	}
+	if idToken != "" {
+		query.Set("id_token_hint", idToken)
+	}
+	logoutURL.RawQuery = query.Encode()
+	return logoutURL.String(), nil
+}
+
+// VerifyLogoutToken implements the LogoutHandler interface and verifies a back-channel
+// logout token sent by the provider. The subject of the token is mapped back to the
+// user it was last seen for during a login.
+func (a *AuthProvider) VerifyLogoutToken(rawToken string) (string, error) {
+	if a.logoutVerifier == nil {
+		return "", errors.New("OIDC provider has not been setup yet")
+	}
+	token, err := a.logoutVerifier.Verify(a.ctx, rawToken)
+	if err != nil {
+		return "", err
+	}
+	claims := &logoutTokenClaims{}
+	if err := token.Claims(claims); err != nil {
+		return "", err
+	}
+	if err := validateLogoutToken(token.Subject, token.Nonce, token.IssuedAt, token.Expiry, claims); err != nil {
+		return "", err
+	}
+
+	if err := a.secrets.Lock(15); err != nil {
+		return "", err
+	}
+	defer a.secrets.Release()
+	subjects, err := a.readSecretMapIfExists(v1.OIDCSubjectsSecretKey)
+	if err != nil {
+		return "", err
+	}
+	username, ok := subjects[token.Subject]
+	if !ok {
+		return "", nil
+	}
+	idTokens, err := a.readSecretMapIfExists(v1.OIDCIDTokensSecretKey)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := idTokens[string(username)]; ok {
+		delete(idTokens, string(username))
+		if err := a.secrets.WriteSecretMap(v1.OIDCIDTokensSecretKey, idTokens); err != nil {
+			return "", err
+		}
+	}
+	return string(username), nil
+}
+
+// validateLogoutToken checks the claims of a verified token to make sure it is a
+// back-channel logout token for a subject.
+func validateLogoutToken(subject, nonce string, issuedAt, expiry time.Time, claims *logoutTokenClaims) error {
+	if _, ok := claims.Events[backChannelLogoutEvent]; !ok {
+		return errors.New("The token does not contain a back-channel logout event")
+	}
+	// ID tokens must never be accepted as logout tokens
+	if nonce != "" {
+		return errors.New("Logout tokens must not contain a nonce")
+	}
+	// Sessions are not tracked by their sid, so there is no way to tell which user
+	// a token without a subject is for.
+	if subject == "" {
+		return errors.New("Logout tokens without a subject are not supported")
+	}
+	if issuedAt.IsZero() || time.Since(issuedAt) > logoutTokenMaxAge {
+		return errors.New("The logout token is too old")
+	}
+	if !expiry.IsZero() && time.Now().After(expiry) {
+		return errors.New("The logout token has expired")
+	}
+	return nil
+}
+
+// recordSession remembers the user the given subject belongs to and the last ID
+// token issued to them, for use when they log out.
+func (a *AuthProvider) recordSession(subject, username, rawIDToken string) error {
+	if err := a.secrets.Lock(15); err != nil {
+		return err
+	}
+	defer a.secrets.Release()
+	subjects, err := a.readSecretMapIfExists(v1.OIDCSubjectsSecretKey)
+	if err != nil {
+		return err
+	}
+	if existing, ok := subjects[subject]; !ok || string(existing) != username {
+		subjects[subject] = []byte(username)
+		if err := a.secrets.WriteSecretMap(v1.OIDCSubjectsSecretKey, subjects); err != nil {
+			return err
+		}
+	}
+	idTokens, err := a.readSecretMapIfExists(v1.OIDCIDTokensSecretKey)
+	if err != nil {
+		return err
+	}
+	idTokens[username] = []byte(rawIDToken)
+	return a.secrets.WriteSecretMap(v1.OIDCIDTokensSecretKey, idTokens)
+}
+
+// popIDToken returns the last ID token issued to the given user and removes it
+// from the secrets backend.
+func (a *AuthProvider) popIDToken(username string) (string, error) {
+	if err := a.secrets.Lock(15); err != nil {
+		return "", err
+	}
+	defer a.secrets.Release()
+	idTokens, err := a.readSecretMapIfExists(v1.OIDCIDTokensSecretKey)
+	if err != nil {
+		return "", err
+	}
+	idToken, ok := idTokens[username]
+	if !ok {
+		return "", nil
+	}
+	delete(idTokens, username)
+	return string(idToken), a.secrets.WriteSecretMap(v1.OIDCIDTokensSecretKey, idTokens)
+}
+
+// readSecretMapIfExists reads the given secret map, returning an empty one if it
+// does not exist yet.
+func (a *AuthProvider) readSecretMapIfExists(key string) (map[string][]byte, error) {
+	data, err := a.secrets.ReadSecretMap(key, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return nil, err
+		}
+		return make(map[string][]byte), nil
+	}
+	return data, nil
+}
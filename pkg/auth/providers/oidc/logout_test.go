@@ -0,0 +1,145 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	gooidc "github.com/coreos/go-oidc"
+	"github.com/dgrijalva/jwt-go"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testIssuer = "https://idp.example.com"
+
+// testKeySet verifies tokens signed with a single RSA key.
+type testKeySet struct{ key *rsa.PrivateKey }
+
+func (k *testKeySet) VerifySignature(ctx context.Context, raw string) ([]byte, error) {
+	if _, err := jwt.Parse(raw, func(*jwt.Token) (interface{}, error) { return &k.key.PublicKey, nil }); err != nil {
+		return nil, err
+	}
+	payload, err := jwt.DecodeSegment(strings.Split(raw, ".")[1])
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func TestLogout(t *testing.T) {
+	key := mustGenerateKey(t)
+
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{
+		OIDCAuth: &v1alpha1.OIDCConfig{
+			IssuerURL:   testIssuer,
+			RedirectURL: "https://kvdi.local/api/login",
+		},
+	}
+	os.Setenv("POD_NAMESPACE", "default")
+	engine := secrets.GetSecretEngine(cluster)
+	if err := engine.Setup(fake.NewFakeClient(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	a := &AuthProvider{
+		cluster:        cluster,
+		secrets:        engine,
+		clientID:       "kvdi",
+		ctx:            context.Background(),
+		endSessionURL:  testIssuer + "/logout?tenant=test",
+		logoutVerifier: gooidc.NewVerifier(testIssuer, &testKeySet{key}, &gooidc.Config{ClientID: "kvdi", SkipExpiryCheck: true}),
+	}
+
+	if err := a.recordSession("subject-1", "test-user", "test-id-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the provider url includes the id token hint only once
+	logoutURL, err := a.GetLogoutURL(&v1.VDIUser{Name: "test-user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(logoutURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if u.Path != "/logout" || query.Get("tenant") != "test" || query.Get("client_id") != "kvdi" ||
+		query.Get("post_logout_redirect_uri") != "https://kvdi.local/" || query.Get("id_token_hint") != "test-id-token" {
+		t.Error("Unexpected logout URL:", logoutURL)
+	}
+	if logoutURL, _ = a.GetLogoutURL(&v1.VDIUser{Name: "test-user"}); strings.Contains(logoutURL, "id_token_hint") {
+		t.Error("Expected the id token hint to be removed after logging out, got:", logoutURL)
+	}
+
+	// there is no url when provider logout is disabled
+	cluster.Spec.Auth.OIDCAuth.DisableProviderLogout = true
+	if logoutURL, err = a.GetLogoutURL(&v1.VDIUser{Name: "test-user"}); err != nil || logoutURL != "" {
+		t.Error("Expected no logout URL when provider logout is disabled, got:", logoutURL, err)
+	}
+
+	newToken := func(claims jwt.MapClaims) string {
+		t.Helper()
+		if _, ok := claims["iss"]; !ok {
+			claims["iss"] = testIssuer
+		}
+		if _, ok := claims["aud"]; !ok {
+			claims["aud"] = "kvdi"
+		}
+		if _, ok := claims["iat"]; !ok {
+			claims["iat"] = time.Now().Unix()
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	events := map[string]interface{}{backChannelLogoutEvent: map[string]interface{}{}}
+
+	if username, err := a.VerifyLogoutToken(newToken(jwt.MapClaims{"sub": "subject-1", "events": events})); err != nil || username != "test-user" {
+		t.Error("Expected logout token to resolve to test-user, got:", username, err)
+	}
+	if username, err := a.VerifyLogoutToken(newToken(jwt.MapClaims{"sub": "subject-2", "events": events})); err != nil || username != "" {
+		t.Error("Expected no user for an unknown subject, got:", username, err)
+	}
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"no events":      {"sub": "subject-1"},
+		"nonce":          {"sub": "subject-1", "events": events, "nonce": "test-nonce"},
+		"no subject":     {"sid": "test-session", "events": events},
+		"old":            {"sub": "subject-1", "events": events, "iat": time.Now().Add(-time.Hour).Unix()},
+		"expired":        {"sub": "subject-1", "events": events, "exp": time.Now().Add(-time.Minute).Unix()},
+		"wrong audience": {"sub": "subject-1", "events": events, "aud": "other-client"},
+		"wrong issuer":   {"sub": "subject-1", "events": events, "iss": "https://other.example.com"},
+	} {
+		if _, err := a.VerifyLogoutToken(newToken(claims)); err == nil {
+			t.Errorf("Expected logout token with %s to be rejected", name)
+		}
+	}
+
+	// tokens signed with another key are rejected
+	a.logoutVerifier = gooidc.NewVerifier(testIssuer, &testKeySet{mustGenerateKey(t)}, &gooidc.Config{ClientID: "kvdi", SkipExpiryCheck: true})
+	if _, err := a.VerifyLogoutToken(newToken(jwt.MapClaims{"sub": "subject-1", "events": events})); err == nil {
+		t.Error("Expected logout token signed with another key to be rejected")
+	}
+}
+
+func mustGenerateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
@@ -7,12 +7,15 @@ import (
 	"crypto/x509"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	"github.com/tinyzimmer/kvdi/pkg/auth/common"
 	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 
 	gooidc "github.com/coreos/go-oidc"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/go-logr/logr"
 	"golang.org/x/oauth2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,24 +37,56 @@ type AuthProvider struct {
 	oauthCfg oauth2.Config
 	// verifier for verifying id tokens
 	verifier *gooidc.IDTokenVerifier
+	// verifier for verifying back-channel logout tokens
+	logoutVerifier *gooidc.IDTokenVerifier
+	// the url of the provider for ending user sessions, if it has one
+	endSessionURL string
 	// the url that can be used for exchanging refresh tokens
 	tokenURL string
 	// the context containing our http client
 	ctx context.Context
+	// the http client used for requests to the provider
+	httpClient *http.Client
 	// the client id
 	clientID string
 	// the client secret
 	clientSecret string
+	// the method used to authenticate to the token endpoint
+	authMethod v1alpha1.OIDCTokenEndpointAuthMethod
+	// the key used to sign client assertions when using private_key_jwt
+	signingKey interface{}
+	// the signing method matching the signingKey
+	signingMethod jwt.SigningMethod
+	// resolves the groups of users instead of the group scope claim, if set
+	groupResolver GroupResolver
 }
 
-// Blank assignment to make sure AuthProvider satisfies the interface.
+// GroupResolver returns the groups of the user the given ID token claims belong to.
+// It is used in place of reading the group scope claim, for providers that leave
+// groups out of ID tokens. ok should be false if the groups of the user could not
+// be determined.
+type GroupResolver func(claims map[string]interface{}) (groups []string, ok bool, err error)
+
+// Blank assignments to make sure AuthProvider satisfies the interfaces.
 var _ common.AuthProvider = &AuthProvider{}
+var _ common.HealthChecker = &AuthProvider{}
+var _ common.LogoutHandler = &AuthProvider{}
+
+// healthCheckTimeout is how long to wait for the discovery document when checking
+// the health of the provider.
+const healthCheckTimeout = 5 * time.Second
 
 // New returns a new OIDC AuthProvider.
 func New(s *secrets.SecretEngine) common.AuthProvider {
 	return &AuthProvider{secrets: s}
 }
 
+// NewWithGroupResolver returns a new OIDC AuthProvider that resolves the groups of
+// users with the given function. This is used by providers built on top of OIDC.
+func NewWithGroupResolver(s *secrets.SecretEngine, resolver GroupResolver) *AuthProvider {
+	return &AuthProvider{secrets: s, groupResolver: resolver}
+}
+
 // Setup implements the AuthProvider interface and sets a local reference to the
 // k8s client and vdi cluster. It then configures oauth2/oidc for serving authentication
 // requests.
@@ -59,9 +94,18 @@ func (a *AuthProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) erro
 	a.client = c
 	a.cluster = cluster
 
+	a.authMethod = a.cluster.GetOIDCTokenEndpointAuthMethod()
+
 	clientIDKey := a.cluster.GetOIDCClientIDKey()
 	clientSecretKey := a.cluster.GetOIDCClientSecretKey()
-	oidcSecrets, err := common.GetAuthSecrets(a.client, a.cluster, a.secrets, clientIDKey, clientSecretKey)
+	privateKeyKey := a.cluster.GetOIDCPrivateKeyKey()
+
+	// A client secret is not required when authenticating with a signed assertion
+	secretKeys := []string{clientIDKey, clientSecretKey}
+	if a.authMethod == v1alpha1.OIDCAuthPrivateKeyJWT {
+		secretKeys = []string{clientIDKey, privateKeyKey}
+	}
+	oidcSecrets, err := common.GetAuthSecrets(a.client, a.cluster, a.secrets, secretKeys...)
 	if err != nil {
 		return err
 	}
@@ -69,6 +113,12 @@ func (a *AuthProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) erro
 	a.clientID = oidcSecrets[clientIDKey]
 	a.clientSecret = oidcSecrets[clientSecretKey]
 
+	if a.authMethod == v1alpha1.OIDCAuthPrivateKeyJWT {
+		if a.signingKey, a.signingMethod, err = parseSigningKey([]byte(oidcSecrets[privateKeyKey])); err != nil {
+			return err
+		}
+	}
+
 	httpClient := &http.Client{}
 	if strings.HasPrefix(a.cluster.GetOIDCIssuerURL(), "https") {
 		caCert, err := a.cluster.GetOIDCCA()
@@ -88,6 +138,7 @@ func (a *AuthProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) erro
 		}
 	}
 
+	a.httpClient = httpClient
 	a.ctx = gooidc.ClientContext(context.Background(), httpClient)
 	provider, err := gooidc.NewProvider(a.ctx, a.cluster.GetOIDCIssuerURL())
 	if err != nil {
@@ -96,14 +147,35 @@ func (a *AuthProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) erro
 
 	a.tokenURL = provider.Endpoint().TokenURL
 
+	endpoint := provider.Endpoint()
+	switch a.authMethod {
+	case v1alpha1.OIDCAuthClientSecretBasic:
+		endpoint.AuthStyle = oauth2.AuthStyleInHeader
+	case v1alpha1.OIDCAuthClientSecretPost, v1alpha1.OIDCAuthPrivateKeyJWT:
+		// private_key_jwt still requires the client_id in the request body
+		endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+
 	a.oauthCfg = oauth2.Config{
-		ClientID:     oidcSecrets[clientIDKey],
-		ClientSecret: oidcSecrets[clientSecretKey],
+		ClientID:     a.clientID,
+		ClientSecret: a.clientSecret,
 		RedirectURL:  a.cluster.GetOIDCRedirectURL(),
-		Endpoint:     provider.Endpoint(),
+		Endpoint:     endpoint,
 		Scopes:       a.cluster.GetOIDCScopes(),
 	}
 	a.verifier = provider.Verifier(&gooidc.Config{ClientID: oidcSecrets[clientIDKey]})
+	// Logout tokens are not required to have an expiry, it is checked along with
+	// the other logout claims instead.
+	a.logoutVerifier = provider.Verifier(&gooidc.Config{ClientID: oidcSecrets[clientIDKey], SkipExpiryCheck: true})
+
+	// The end_session_endpoint is optional in the discovery document
+	var endSession struct {
+		URL string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&endSession); err != nil {
+		return err
+	}
+	a.endSessionURL = endSession.URL
 
 	return nil
 }
@@ -114,6 +186,18 @@ func (a *AuthProvider) Reconcile(reqLogger logr.Logger, c client.Client, cluster
 	return a.Setup(c, cluster)
 }
 
+// CheckHealth implements the HealthChecker interface and verifies that the OIDC
+// discovery document can still be retrieved from the issuer.
+func (a *AuthProvider) CheckHealth() error {
+	if a.httpClient == nil {
+		return errors.New("OIDC provider has not been setup yet")
+	}
+	ctx, cancel := context.WithTimeout(gooidc.ClientContext(context.Background(), a.httpClient), healthCheckTimeout)
+	defer cancel()
+	_, err := gooidc.NewProvider(ctx, a.cluster.GetOIDCIssuerURL())
+	return err
+}
+
 // Close just returns nil as connections are not persistent
 func (a *AuthProvider) Close() error {
 	return nil
@@ -0,0 +1,77 @@
+package oidc
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"golang.org/x/oauth2"
+)
+
+// Refresh exchanges the refresh token issued by the provider for a new ID token.
+// The user's roles are rebuilt from the claims in the new token, so changes to
+// their groups are picked up without a new auth flow.
+func (a *AuthProvider) Refresh(username, providerToken string) (*v1.AuthResult, error) {
+	if providerToken == "" {
+		return nil, errors.New("The OIDC provider did not issue a refresh token for this session")
+	}
+
+	token, err := a.refreshToken(providerToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Providers are not required to return an ID token when refreshing, but
+	// without one there is no way to look up the user's groups.
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("The OIDC provider did not return an ID token when refreshing the session")
+	}
+	idToken, err := a.verifier.Verify(a.ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	user, err := a.getUserFromClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	if user.GetName() != username {
+		return nil, fmt.Errorf("The refreshed ID token is for %s and not %s", user.GetName(), username)
+	}
+
+	if err := a.recordSession(idToken.Subject, username, rawIDToken); err != nil {
+		return nil, err
+	}
+
+	// Not all providers rotate refresh tokens
+	newProviderToken := token.RefreshToken
+	if newProviderToken == "" {
+		newProviderToken = providerToken
+	}
+	return &v1.AuthResult{
+		User:                 user,
+		ProviderRefreshToken: newProviderToken,
+	}, nil
+}
+
+// refreshToken requests a new token from the provider using the given refresh token.
+func (a *AuthProvider) refreshToken(refreshToken string) (*oauth2.Token, error) {
+	if a.authMethod != v1alpha1.OIDCAuthPrivateKeyJWT {
+		return a.oauthCfg.TokenSource(a.ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	}
+
+	// The oauth2 token source has no way to add a client assertion to the
+	// request, so it is built here instead.
+	return a.requestToken(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
@@ -0,0 +1,86 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenResponse represents a response from the token endpoint of the provider.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+}
+
+// ClientCredentialsToken requests an access token for the client itself with the
+// given scopes. This is used for calling APIs of the provider outside of a user's
+// session.
+func (a *AuthProvider) ClientCredentialsToken(scopes ...string) (*oauth2.Token, error) {
+	return a.requestToken(url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {strings.Join(scopes, " ")},
+	})
+}
+
+// requestToken posts the given form to the token endpoint of the provider,
+// authenticating with the configured method.
+func (a *AuthProvider) requestToken(form url.Values) (*oauth2.Token, error) {
+	if a.httpClient == nil {
+		return nil, errors.New("OIDC provider has not been setup yet")
+	}
+	switch a.authMethod {
+	case v1alpha1.OIDCAuthPrivateKeyJWT:
+		assertion, err := a.newClientAssertion()
+		if err != nil {
+			return nil, err
+		}
+		form.Set("client_id", a.clientID)
+		form.Set("client_assertion_type", clientAssertionType)
+		form.Set("client_assertion", assertion)
+	case v1alpha1.OIDCAuthClientSecretPost:
+		form.Set("client_id", a.clientID)
+		form.Set("client_secret", a.clientSecret)
+	}
+	req, err := http.NewRequest(http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if a.authMethod != v1alpha1.OIDCAuthPrivateKeyJWT && a.authMethod != v1alpha1.OIDCAuthClientSecretPost {
+		req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+	}
+
+	resp, err := a.httpClient.Do(req.WithContext(a.ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("The OIDC provider returned %s from the token endpoint", resp.Status)
+	}
+
+	tokenResp := &tokenResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(tokenResp); err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{
+		AccessToken:  tokenResp.AccessToken,
+		TokenType:    tokenResp.TokenType,
+		RefreshToken: tokenResp.RefreshToken,
+	}
+	if tokenResp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+	return token.WithExtra(map[string]interface{}{"id_token": tokenResp.IDToken}), nil
+}
@@ -25,6 +25,11 @@ func (a *AuthProvider) UpdateUser(string, *v1.UpdateUserRequest) error {
 	return errors.New("Updating users is not supported when using OIDC authentication")
 }
 
+// ChangePassword should change the password for a VDIUser.
+func (a *AuthProvider) ChangePassword(string, *v1.ChangePasswordRequest) error {
+	return errors.New("Changing passwords is not supported when using OIDC authentication")
+}
+
 // DeleteUser should remove a VDIUser.
 func (a *AuthProvider) DeleteUser(string) error {
 	return errors.New("Deleting users is not supported when using OIDC authentication")
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+const (
+	// TimestampHeader is the header containing the unix time a request was signed at.
+	TimestampHeader = "X-Kvdi-Timestamp"
+	// SignatureHeader is the header containing the signature of a request.
+	SignatureHeader = "X-Kvdi-Signature"
+	// signaturePrefix is prepended to the hex encoded signature.
+	signaturePrefix = "sha256="
+	// maxResponseSize is the largest response read from the webhook.
+	maxResponseSize = 1 << 20
+)
+
+// Authenticate is called for API authentication requests. The login request is
+// POSTed to the webhook, and the roles in the response are bound to the VDIRoles in
+// the cluster with the same names. The names of the roles are returned as the
+// provider refresh token so the user's roles can be rebuilt when the session is renewed.
+func (a *AuthProvider) Authenticate(req *v1.LoginRequest) (*v1.AuthResult, error) {
+	if r := req.GetRequest(); r != nil && r.Method != http.MethodPost {
+		return nil, errors.New("Redirect flows are not supported when using webhook authentication")
+	}
+	if req.GetUsername() == "" || req.GetPassword() == "" {
+		return nil, errors.NewInvalidCredentialsError(req.GetUsername())
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	webhookUser, err := a.do(req.GetUsername(), body)
+	if err != nil {
+		return nil, err
+	}
+	if webhookUser.GetName() == "" {
+		return nil, errors.New("Auth webhook did not return a username")
+	}
+	roleNames := make([]string, 0, len(webhookUser.Roles))
+	for _, role := range webhookUser.Roles {
+		if role != nil && role.Name != "" {
+			roleNames = append(roleNames, role.Name)
+		}
+	}
+	user, err := a.getUserWithRoles(webhookUser.GetName(), roleNames)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.AuthResult{
+		User:                 user,
+		ProviderRefreshToken: strings.Join(roleNames, v1.AuthGroupSeparator),
+	}, nil
+}
+
+// Refresh rebuilds the user's roles from the names returned by the webhook when the
+// session was started. Changes to those VDIRoles are picked up, while changes made by
+// the webhook only apply the next time the user logs in.
+func (a *AuthProvider) Refresh(username, providerToken string) (*v1.AuthResult, error) {
+	if providerToken == "" {
+		return nil, errors.New("No roles were recorded for this session")
+	}
+	user, err := a.getUserWithRoles(username, strings.Split(providerToken, v1.AuthGroupSeparator))
+	if err != nil {
+		return nil, err
+	}
+	return &v1.AuthResult{
+		User:                 user,
+		ProviderRefreshToken: providerToken,
+	}, nil
+}
+
+// getUserWithRoles returns a VDIUser bound to the VDIRoles in the cluster with the
+// given names. Names that do not match a role are ignored.
+func (a *AuthProvider) getUserWithRoles(username string, roleNames []string) (*v1.VDIUser, error) {
+	roles, err := a.cluster.GetResolvedRoles(a.client)
+	if err != nil {
+		return nil, err
+	}
+	userRoles := apiutil.FilterUserRolesByNames(roles, roleNames)
+	if len(userRoles) == 0 {
+		return nil, fmt.Errorf("The user %s is not bound to any roles", username)
+	}
+	return &v1.VDIUser{
+		Name:  username,
+		Roles: userRoles,
+	}, nil
+}
+
+// do POSTs the given body to the webhook and decodes the user in the response.
+// An InvalidCredentialsError is returned if the webhook rejects the credentials.
+func (a *AuthProvider) do(username string, body []byte) (*v1.VDIUser, error) {
+	req, err := http.NewRequest(http.MethodPost, a.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range a.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, signaturePrefix+sign(a.signingKey, timestamp, body))
+	res, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, errors.NewInvalidCredentialsError(username)
+	default:
+		return nil, fmt.Errorf("Auth webhook returned status %d", res.StatusCode)
+	}
+	user := &v1.VDIUser{}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(user); err != nil {
+		return nil, fmt.Errorf("Failed to decode the response from the auth webhook: %s", err.Error())
+	}
+	return user, nil
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the timestamp and body of a request.
+func sign(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
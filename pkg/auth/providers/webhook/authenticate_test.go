@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis"
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// newTestWebhook returns a webhook that accepts jdoe with the password "hunter2",
+// after verifying the signature of the request with the key read by the provider.
+func newTestWebhook(t *testing.T, a *AuthProvider) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mac := hmac.New(sha256.New, a.signingKey)
+		mac.Write([]byte(r.Header.Get(TimestampHeader) + "." + string(body)))
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if r.Header.Get(TimestampHeader) == "" || r.Header.Get(SignatureHeader) != expected {
+			t.Error("Request had an invalid signature:", r.Header.Get(SignatureHeader))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-Tenant") != "acme" {
+			t.Error("Expected configured headers to be sent, got:", r.Header)
+		}
+		req := &v1.LoginRequest{}
+		if err := json.Unmarshal(body, req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Password != "hunter2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.Username {
+		case "jdoe":
+			w.Write([]byte(`{"name": "jdoe", "roles": [{"name": "engineers"}, {"name": "missing", "rules": [{"verbs": ["*"], "resources": ["*"]}]}]}`))
+		case "contractor":
+			w.Write([]byte(`{"name": "contractor", "roles": [{"name": "missing"}]}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+}
+
+func newTestProvider(t *testing.T) *AuthProvider {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{WebhookAuth: &v1alpha1.WebhookAuthConfig{
+		URL:     "http://127.0.0.1:0",
+		Headers: map[string]string{"X-Tenant": "acme"},
+	}}
+
+	role := &v1alpha1.VDIRole{}
+	role.Name = "engineers"
+	role.Labels = map[string]string{v1.RoleClusterRefLabel: cluster.GetName()}
+	role.Rules = []v1.Rule{{Verbs: []v1.Verb{v1.VerbRead}, Resources: []v1.Resource{v1.ResourceTemplates}}}
+
+	c := fake.NewFakeClientWithScheme(scheme, cluster, role)
+	os.Setenv("POD_NAMESPACE", "default")
+	s := secrets.GetSecretEngine(cluster)
+	if err := s.Setup(c, cluster); err != nil {
+		t.Fatal(err)
+	}
+	a := New(s).(*AuthProvider)
+	if err := a.Setup(c, cluster); err == nil {
+		t.Error("Expected error setting up before the signing key is generated")
+	}
+	if err := a.Reconcile(logf.Log.WithName("test"), c, cluster, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.signingKey) == 0 {
+		t.Fatal("Expected a signing key to be generated")
+	}
+	return a
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := newTestProvider(t)
+	srvr := newTestWebhook(t, a)
+	defer srvr.Close()
+	a.cfg.URL = srvr.URL
+
+	result, err := a.Authenticate(&v1.LoginRequest{Username: "jdoe", Password: "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.User.GetName() != "jdoe" {
+		t.Error("Expected username from the webhook, got:", result.User.GetName())
+	}
+	// rules are always read from the cluster
+	if len(result.User.Roles) != 1 || result.User.Roles[0].Name != "engineers" || len(result.User.Roles[0].Rules) != 1 || result.User.Roles[0].Rules[0].Verbs[0] != v1.VerbRead {
+		t.Error("Got wrong roles for user:", result.User.Roles)
+	}
+
+	// The session can be renewed with the recorded roles
+	refreshed, err := a.Refresh("jdoe", result.ProviderRefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refreshed.User.Roles) != 1 {
+		t.Error("Expected roles to be rebuilt on refresh, got:", refreshed.User.Roles)
+	}
+	if _, err := a.Refresh("jdoe", ""); err == nil {
+		t.Error("Expected error refreshing without recorded roles")
+	}
+
+	if _, err := a.Authenticate(&v1.LoginRequest{Username: "jdoe", Password: "wrong"}); !errors.IsInvalidCredentialsError(err) {
+		t.Error("Expected invalid credentials error, got:", err)
+	}
+	if _, err := a.Authenticate(&v1.LoginRequest{Username: "jdoe"}); !errors.IsInvalidCredentialsError(err) {
+		t.Error("Expected invalid credentials error for an empty password, got:", err)
+	}
+	if _, err := a.Authenticate(&v1.LoginRequest{Username: "contractor", Password: "hunter2"}); err == nil {
+		t.Error("Expected error for user not bound to any roles in the cluster")
+	}
+	if _, err := a.Authenticate(&v1.LoginRequest{Username: "nobody", Password: "hunter2"}); err == nil || errors.IsInvalidCredentialsError(err) {
+		t.Error("Expected error for failed webhook, got:", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Package webhook contains an AuthProvider implementation that delegates verifying
+// credentials and assigning roles to an HTTP endpoint.
+package webhook
+
+import (
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/auth/common"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	util "github.com/tinyzimmer/kvdi/pkg/util/common"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AuthProvider implements an auth provider that POSTs login requests to a webhook.
+// The webhook responds with the user and the names of the VDIRoles they are bound to.
+type AuthProvider struct {
+	common.AuthProvider
+
+	// k8s client
+	client client.Client
+	// our cluster instance
+	cluster *v1alpha1.VDICluster
+	// the secrets engine where the signing key is stored
+	secrets *secrets.SecretEngine
+	// the configuration for the webhook
+	cfg *v1alpha1.WebhookAuthConfig
+	// the http client used for requests to the webhook
+	httpClient *http.Client
+	// the key requests are signed with
+	signingKey []byte
+}
+
+// Blank assignment to make sure AuthProvider satisfies the interface.
+var _ common.AuthProvider = &AuthProvider{}
+
+// New returns a new webhook AuthProvider.
+func New(s *secrets.SecretEngine) common.AuthProvider {
+	return &AuthProvider{secrets: s}
+}
+
+// Setup implements the AuthProvider interface and sets a local reference to the
+// k8s client and vdi cluster. It then reads the key for signing requests.
+func (a *AuthProvider) Setup(c client.Client, cluster *v1alpha1.VDICluster) error {
+	cfg := cluster.GetWebhookAuthConfig()
+	if cfg.URL == "" {
+		return errors.New("The webhook auth provider requires a url")
+	}
+	key, err := a.secrets.ReadSecret(cfg.GetSigningKeyKey(), true)
+	if err != nil {
+		return err
+	}
+	a.client = c
+	a.cluster = cluster
+	a.cfg = cfg
+	a.httpClient = &http.Client{Timeout: cfg.GetTimeout()}
+	a.signingKey = key
+	return nil
+}
+
+// Reconcile generates the key for signing requests if it does not exist yet, and
+// then makes sure the provider can be set up. The generated admin password is ignored
+// since users are managed by the webhook.
+func (a *AuthProvider) Reconcile(reqLogger logr.Logger, c client.Client, cluster *v1alpha1.VDICluster, adminPass string) error {
+	keyName := cluster.GetWebhookAuthConfig().GetSigningKeyKey()
+	if _, err := a.secrets.ReadSecret(keyName, false); err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return err
+		}
+		reqLogger.Info("Generating signing key for the auth webhook", "Key", keyName)
+		if err := a.secrets.WriteSecret(keyName, []byte(util.GeneratePassword(32))); err != nil {
+			return err
+		}
+	}
+	return a.Setup(c, cluster)
+}
+
+// Close just returns nil as connections are not persistent
+func (a *AuthProvider) Close() error {
+	return nil
+}
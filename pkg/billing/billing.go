@@ -0,0 +1,49 @@
+// Package billing contains a collector for sampling desktop usage and exporting
+// it to pluggable destinations.
+package billing
+
+import (
+	"context"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var billingLogger = logf.Log.WithName("billing")
+
+// Exporter is an interface for shipping usage reports to an external destination.
+type Exporter interface {
+	// Name should return a short name for the exporter, used in log messages.
+	Name() string
+	// Export should ship the given report to its destination.
+	Export(context.Context, *Report) error
+}
+
+// Record represents the usage of a single user's desktops from a template in
+// a namespace over the period of a report.
+type Record struct {
+	// The user that owned the desktops
+	User string `json:"user"`
+	// The namespace the desktops ran in
+	Namespace string `json:"namespace"`
+	// The template the desktops were booted from
+	Template string `json:"template"`
+	// The total hours desktops were running
+	DesktopHours float64 `json:"desktopHours"`
+	// The requested CPU cores multiplied by running hours
+	CPUHours float64 `json:"cpuHours"`
+	// The requested memory in GiB multiplied by running hours
+	MemoryGiBHours float64 `json:"memoryGiBHours"`
+}
+
+// Report represents the usage of a VDICluster over a period of time.
+type Report struct {
+	// The name of the VDICluster
+	Cluster string `json:"cluster"`
+	// The start of the reporting period
+	Start time.Time `json:"start"`
+	// The end of the reporting period
+	End time.Time `json:"end"`
+	// The usage records for the period
+	Records []*Record `json:"records"`
+}
@@ -0,0 +1,180 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// DefaultSampleInterval is how often running desktops are sampled for usage.
+const DefaultSampleInterval = time.Minute
+
+// Collector is a manager.Runnable that samples running desktops, records daily
+// usage for reporting, and periodically ships usage reports to the exporters
+// configured on each VDICluster. The usage accumulated for each export is stored
+// in a configmap after every sample, so it survives restarts and leader changes.
+type Collector struct {
+	client         client.Client
+	sampleInterval time.Duration
+	ledgers        map[string]*ledger
+	usage          map[string]*usageTracker
+}
+
+// Blank assignments to make sure Collector satisfies the interfaces.
+var _ manager.Runnable = &Collector{}
+var _ manager.LeaderElectionRunnable = &Collector{}
+
+// NewCollector returns a new Collector using the given client.
+func NewCollector(c client.Client) *Collector {
+	return &Collector{
+		client:         c,
+		sampleInterval: DefaultSampleInterval,
+		ledgers:        make(map[string]*ledger),
+		usage:          make(map[string]*usageTracker),
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the leader
+// should collect usage.
+func (c *Collector) NeedLeaderElection() bool { return true }
+
+// Start implements manager.Runnable and samples usage until the stop channel is closed.
+func (c *Collector) Start(stopCh <-chan struct{}) error {
+	ticker := time.NewTicker(c.sampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case <-ticker.C:
+			if err := c.collect(time.Now()); err != nil {
+				billingLogger.Error(err, "Failed to collect desktop usage")
+			}
+		}
+	}
+}
+
+// collect samples usage for all VDIClusters and exports any reports that are due
+// for those with billing exports enabled.
+func (c *Collector) collect(now time.Time) error {
+	clusters := &v1alpha1.VDIClusterList{}
+	if err := c.client.List(context.TODO(), clusters); err != nil {
+		return err
+	}
+	desktops := &v1alpha1.DesktopList{}
+	if err := c.client.List(context.TODO(), desktops); err != nil {
+		return err
+	}
+	templates := &v1alpha1.DesktopTemplateList{}
+	if err := c.client.List(context.TODO(), templates); err != nil {
+		return err
+	}
+	rawTemplates := make(map[string]*v1alpha1.DesktopTemplate)
+	for i := range templates.Items {
+		rawTemplates[templates.Items[i].GetName()] = &templates.Items[i]
+	}
+	lookup := func(name string) (*v1alpha1.DesktopTemplate, error) {
+		if tmpl, ok := rawTemplates[name]; ok {
+			return tmpl, nil
+		}
+		return nil, fmt.Errorf("DesktopTemplate %s not found", name)
+	}
+	// resolve any base templates so resource requests are accurate
+	templateMap := make(map[string]*v1alpha1.DesktopTemplate)
+	for name, tmpl := range rawTemplates {
+		effective, err := tmpl.Resolve(lookup)
+		if err != nil {
+			billingLogger.Error(err, "Failed to resolve template, resource usage will not be reported", "Template", name)
+			continue
+		}
+		templateMap[name] = effective
+	}
+
+	seen := make(map[string]struct{})
+	exporting := make(map[string]struct{})
+	for i := range clusters.Items {
+		cluster := &clusters.Items[i]
+		seen[cluster.GetName()] = struct{}{}
+
+		clusterDesktops := make([]v1alpha1.Desktop, 0)
+		for _, desktop := range desktops.Items {
+			if desktop.Spec.VDICluster == cluster.GetName() {
+				clusterDesktops = append(clusterDesktops, desktop)
+			}
+		}
+
+		if err := c.recordUsage(now, cluster, clusterDesktops, templateMap); err != nil {
+			billingLogger.Error(err, "Failed to record usage", "Cluster", cluster.GetName())
+		}
+
+		if !cluster.BillingExportEnabled() {
+			// drop any ledger left from before exports were disabled, so enabling
+			// them again doesn't bill the time in between
+			if err := deleteLedger(c.client, cluster); err != nil {
+				billingLogger.Error(err, "Failed to remove billing ledger", "Cluster", cluster.GetName())
+			}
+			continue
+		}
+		exporting[cluster.GetName()] = struct{}{}
+
+		l, ok := c.ledgers[cluster.GetName()]
+		if !ok {
+			// pick up where the last leader left off, or start the first period now
+			var err error
+			if l, err = readLedger(c.client, cluster, now); err != nil {
+				billingLogger.Error(err, "Failed to read billing ledger", "Cluster", cluster.GetName())
+				continue
+			}
+			c.ledgers[cluster.GetName()] = l
+		}
+		l.sample(now, cluster, clusterDesktops, templateMap)
+
+		if now.Sub(l.start) >= cluster.GetBillingExportInterval() {
+			if err := c.export(cluster, l.report(cluster.GetName())); err != nil {
+				// keep accumulating, the export will be retried on the next sample
+				billingLogger.Error(err, "Failed to export usage report", "Cluster", cluster.GetName())
+			} else {
+				l.reset()
+			}
+		}
+
+		if err := writeLedger(c.client, cluster, l); err != nil {
+			billingLogger.Error(err, "Failed to write billing ledger", "Cluster", cluster.GetName())
+		}
+	}
+
+	// drop ledgers for clusters that were deleted or had billing disabled
+	for name := range c.ledgers {
+		if _, ok := exporting[name]; !ok {
+			delete(c.ledgers, name)
+		}
+	}
+	for name := range c.usage {
+		if _, ok := seen[name]; !ok {
+			delete(c.usage, name)
+		}
+	}
+	return nil
+}
+
+// export ships the report to all exporters configured for the cluster.
+func (c *Collector) export(cluster *v1alpha1.VDICluster, report *Report) error {
+	exporters, err := GetExporters(c.client, cluster)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, exporter := range exporters {
+		billingLogger.Info("Exporting usage report", "Cluster", cluster.GetName(), "Exporter", exporter.Name(), "Records", len(report.Records))
+		if err := exporter.Export(ctx, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
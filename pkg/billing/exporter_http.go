@@ -0,0 +1,59 @@
+package billing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+// HTTPExporter POSTs usage reports to an HTTP endpoint.
+type HTTPExporter struct {
+	cfg    *v1alpha1.HTTPExportConfig
+	client *http.Client
+}
+
+// NewHTTPExporter returns a new HTTPExporter for the given configuration.
+func NewHTTPExporter(cfg *v1alpha1.HTTPExportConfig) Exporter {
+	return &HTTPExporter{cfg: cfg, client: http.DefaultClient}
+}
+
+// Name implements Exporter.
+func (h *HTTPExporter) Name() string { return "http" }
+
+// Export implements Exporter and POSTs the report to the configured URL.
+func (h *HTTPExporter) Export(ctx context.Context, report *Report) error {
+	var body []byte
+	var contentType string
+	var err error
+	switch h.cfg.Format {
+	case v1alpha1.BillingExportCSV:
+		body, err = report.CSV()
+		contentType = "text/csv"
+	default:
+		body, err = report.JSON()
+		contentType = "application/json"
+	}
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range h.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("Usage report endpoint returned status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package billing
+
+import (
+	"context"
+	"path"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/s3util"
+)
+
+// S3Exporter writes usage reports as CSV files to an S3 bucket.
+type S3Exporter struct {
+	cfg    *v1alpha1.S3ExportConfig
+	client *s3util.Client
+}
+
+// NewS3Exporter returns a new S3Exporter for the given configuration and credentials.
+func NewS3Exporter(cfg *v1alpha1.S3ExportConfig, accessKeyID, secretAccessKey string) Exporter {
+	return &S3Exporter{
+		cfg: cfg,
+		client: s3util.NewClient(cfg.Endpoint, cfg.Region, cfg.Bucket, &s3util.Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+		}),
+	}
+}
+
+// Name implements Exporter.
+func (s *S3Exporter) Name() string { return "s3" }
+
+// Export implements Exporter and PUTs the report as a CSV object in the bucket.
+func (s *S3Exporter) Export(ctx context.Context, report *Report) error {
+	body, err := report.CSV()
+	if err != nil {
+		return err
+	}
+	return s.client.PutObject(ctx, path.Join(s.cfg.Prefix, report.Filename("csv")), "text/csv", body)
+}
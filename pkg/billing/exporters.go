@@ -0,0 +1,35 @@
+package billing
+
+import (
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/s3util"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetExporters returns the exporters configured for the given VDICluster.
+func GetExporters(c client.Client, cluster *v1alpha1.VDICluster) ([]Exporter, error) {
+	exporters := make([]Exporter, 0)
+	if cfg := cluster.GetBillingS3Config(); cfg != nil {
+		creds, err := getS3Credentials(c, cfg.CredentialsSecret)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, NewS3Exporter(cfg, creds.AccessKeyID, creds.SecretAccessKey))
+	}
+	if cfg := cluster.GetBillingHTTPConfig(); cfg != nil {
+		exporters = append(exporters, NewHTTPExporter(cfg))
+	}
+	return exporters, nil
+}
+
+// getS3Credentials retrieves the AWS credentials from the given secret in the
+// manager namespace.
+func getS3Credentials(c client.Client, secretName string) (*s3util.Credentials, error) {
+	namespace, err := k8sutil.GetThisPodNamespace()
+	if err != nil {
+		return nil, err
+	}
+	return s3util.GetCredentials(c, secretName, namespace)
+}
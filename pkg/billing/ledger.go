@@ -0,0 +1,199 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// bytesPerGiB is used for converting memory requests to GiB.
+const bytesPerGiB = 1024 * 1024 * 1024
+
+// ledgerComponent is the component label applied to billing ledger configmaps.
+const ledgerComponent = "billing-ledger"
+
+// ledgerKey is the key in billing ledger configmaps where the ledger is stored.
+const ledgerKey = "ledger"
+
+// recordKey is used to aggregate usage in a ledger.
+type recordKey struct{ user, namespace, template string }
+
+// ledger accumulates usage for a single VDICluster between exports.
+type ledger struct {
+	// the start of the current reporting period
+	start time.Time
+	// the time of the last sample
+	lastSample time.Time
+	// the accumulated records
+	records map[recordKey]*Record
+}
+
+// newLedger returns a new ledger starting at the given time.
+func newLedger(start time.Time) *ledger {
+	return &ledger{start: start, lastSample: start, records: make(map[recordKey]*Record)}
+}
+
+// sample adds the time since the last sample to the records for the given running
+// desktops. Templates are used to look up the requested resources for each desktop,
+// with its parameters and any namespace defaults from the cluster applied.
+func (l *ledger) sample(now time.Time, cluster *v1alpha1.VDICluster, desktops []v1alpha1.Desktop, templates map[string]*v1alpha1.DesktopTemplate) {
+	last := l.lastSample
+	l.lastSample = now
+	if !now.After(last) {
+		return
+	}
+	for _, desktop := range desktops {
+		if !desktop.Status.Running {
+			continue
+		}
+		// desktops started since the last sample, e.g. while the collector was
+		// restarting, are only billed from the start of their session
+		since := last
+		if start := desktop.GetSessionStart(); start.After(since) {
+			since = start
+		}
+		hours := now.Sub(since).Hours()
+		if hours <= 0 {
+			continue
+		}
+		key := recordKey{user: desktop.GetUser(), namespace: desktop.GetNamespace(), template: desktop.Spec.Template}
+		rec, ok := l.records[key]
+		if !ok {
+			rec = &Record{User: key.user, Namespace: key.namespace, Template: key.template}
+			l.records[key] = rec
+		}
+		cpu, mem := desktopRequests(cluster, &desktop, templates)
+		rec.DesktopHours += hours
+		rec.CPUHours += cpu * hours
+		rec.MemoryGiBHours += mem * hours
+	}
+}
+
+// desktopRequests returns the CPU cores and GiB of memory requested for the given
+// desktop. Zero is returned for either if it can't be determined.
+func desktopRequests(cluster *v1alpha1.VDICluster, desktop *v1alpha1.Desktop, templates map[string]*v1alpha1.DesktopTemplate) (cpu, mem float64) {
+	tmpl, ok := templates[desktop.Spec.Template]
+	if !ok {
+		return 0, 0
+	}
+	// resources chosen with template parameters are billed as well
+	if applied, err := tmpl.ApplyParameters(desktop.Spec.Parameters); err == nil {
+		tmpl = applied
+	}
+	requests := cluster.GetDesktopResources(tmpl, desktop.GetNamespace()).Requests
+	if q, ok := requests[corev1.ResourceCPU]; ok {
+		cpu = float64(q.MilliValue()) / 1000
+	}
+	if q, ok := requests[corev1.ResourceMemory]; ok {
+		mem = float64(q.Value()) / bytesPerGiB
+	}
+	return cpu, mem
+}
+
+// report returns a report for the current period ending at the last sample.
+func (l *ledger) report(cluster string) *Report {
+	records := make([]*Record, 0, len(l.records))
+	for _, rec := range l.records {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].User != records[j].User {
+			return records[i].User < records[j].User
+		}
+		if records[i].Namespace != records[j].Namespace {
+			return records[i].Namespace < records[j].Namespace
+		}
+		return records[i].Template < records[j].Template
+	})
+	return &Report{Cluster: cluster, Start: l.start, End: l.lastSample, Records: records}
+}
+
+// reset starts a new reporting period at the time of the last sample.
+func (l *ledger) reset() {
+	l.start = l.lastSample
+	l.records = make(map[recordKey]*Record)
+}
+
+// persistedLedger is the representation of a ledger stored in its configmap.
+type persistedLedger struct {
+	Start      time.Time `json:"start"`
+	LastSample time.Time `json:"lastSample"`
+	Records    []*Record `json:"records"`
+}
+
+// readLedger returns the ledger stored for the cluster, so usage accumulated before
+// a restart or change of leader is still exported. A new ledger starting at the
+// given time is returned if none was stored.
+func readLedger(c client.Client, cluster *v1alpha1.VDICluster, now time.Time) (*ledger, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), cluster.GetBillingLedgerName(), cm); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		return newLedger(now), nil
+	}
+	raw, ok := cm.Data[ledgerKey]
+	if !ok {
+		return newLedger(now), nil
+	}
+	stored := &persistedLedger{}
+	if err := json.Unmarshal([]byte(raw), stored); err != nil {
+		return nil, err
+	}
+	l := newLedger(stored.Start)
+	l.lastSample = stored.LastSample
+	for _, rec := range stored.Records {
+		l.records[recordKey{user: rec.User, namespace: rec.Namespace, template: rec.Template}] = rec
+	}
+	return l, nil
+}
+
+// writeLedger stores the given ledger in the configmap for the cluster.
+func writeLedger(c client.Client, cluster *v1alpha1.VDICluster, l *ledger) error {
+	out, err := json.Marshal(&persistedLedger{
+		Start:      l.start,
+		LastSample: l.lastSample,
+		Records:    l.report(cluster.GetName()).Records,
+	})
+	if err != nil {
+		return err
+	}
+	nn := cluster.GetBillingLedgerName()
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), nn, cm); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            nn.Name,
+				Namespace:       nn.Namespace,
+				Labels:          cluster.GetComponentLabels(ledgerComponent),
+				OwnerReferences: cluster.OwnerReferences(),
+			},
+			Data: map[string]string{ledgerKey: string(out)},
+		}
+		return c.Create(context.TODO(), cm)
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[ledgerKey] = string(out)
+	return c.Update(context.TODO(), cm)
+}
+
+// deleteLedger removes the ledger stored for the cluster, if any.
+func deleteLedger(c client.Client, cluster *v1alpha1.VDICluster) error {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), cluster.GetBillingLedgerName(), cm); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	return client.IgnoreNotFound(c.Delete(context.TODO(), cm))
+}
@@ -0,0 +1,181 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis"
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestDesktop(name, user string, running bool) v1alpha1.Desktop {
+	return v1alpha1.Desktop{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1alpha1.DesktopSpec{VDICluster: "test", Template: "test-template", User: user},
+		Status:     v1alpha1.DesktopStatus{Running: running},
+	}
+}
+
+func TestLedger(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLedger(start)
+	cluster := &v1alpha1.VDICluster{}
+
+	templates := map[string]*v1alpha1.DesktopTemplate{
+		"test-template": {
+			Spec: v1alpha1.DesktopTemplateSpec{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+						corev1.ResourceMemory: resource.MustParse("2Gi"),
+					},
+				},
+			},
+		},
+	}
+	desktops := []v1alpha1.Desktop{
+		newTestDesktop("a", "alice", true),
+		newTestDesktop("b", "bob", true),
+		newTestDesktop("c", "bob", false),
+	}
+
+	l.sample(start.Add(time.Hour), cluster, desktops, templates)
+	l.sample(start.Add(2*time.Hour), cluster, desktops[:1], templates)
+
+	report := l.report("test")
+	if len(report.Records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(report.Records))
+	}
+	if report.Records[0].User != "alice" || report.Records[1].User != "bob" {
+		t.Error("Expected records to be sorted by user")
+	}
+	alice := report.Records[0]
+	if alice.DesktopHours != 2 {
+		t.Error("Expected 2 desktop hours for alice, got", alice.DesktopHours)
+	}
+	if alice.CPUHours != 1 {
+		t.Error("Expected 1 cpu hour for alice, got", alice.CPUHours)
+	}
+	if alice.MemoryGiBHours != 4 {
+		t.Error("Expected 4 memory GiB hours for alice, got", alice.MemoryGiBHours)
+	}
+	if report.Records[1].DesktopHours != 1 {
+		t.Error("Expected 1 desktop hour for bob, got", report.Records[1].DesktopHours)
+	}
+	if !report.End.Equal(start.Add(2 * time.Hour)) {
+		t.Error("Expected report to end at the last sample, got", report.End)
+	}
+
+	out, err := report.CSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d lines", len(lines))
+	}
+	if lines[1] != "test,2020-01-01T00:00:00Z,2020-01-01T02:00:00Z,alice,default,test-template,2.0000,1.0000,4.0000" {
+		t.Error("Unexpected CSV row:", lines[1])
+	}
+
+	l.reset()
+	if len(l.report("test").Records) != 0 {
+		t.Error("Expected no records after reset")
+	}
+	if !l.start.Equal(start.Add(2 * time.Hour)) {
+		t.Error("Expected new period to start at the last sample")
+	}
+}
+
+func TestLedgerPersistence(t *testing.T) {
+	reports := make([]*Report, 0)
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := &Report{}
+		if err := json.NewDecoder(r.Body).Decode(report); err != nil {
+			t.Error(err)
+		}
+		reports = append(reports, report)
+	}))
+	defer srvr.Close()
+
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test"
+	cluster.Spec.Billing = &v1alpha1.BillingConfig{
+		ExportInterval: "1h",
+		HTTP:           &v1alpha1.HTTPExportConfig{URL: srvr.URL},
+	}
+	alice := newTestDesktop("a", "alice", true)
+	c := fake.NewFakeClientWithScheme(scheme, cluster, &alice)
+
+	collector := NewCollector(c)
+	if err := collector.collect(start); err != nil {
+		t.Fatal(err)
+	}
+	if err := collector.collect(start.Add(30 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new leader picks up the usage recorded so far, and desktops started while
+	// there was no leader are billed from the start of their session
+	bob := newTestDesktop("b", "bob", true)
+	bob.CreationTimestamp = metav1.NewTime(start.Add(50 * time.Minute))
+	if err := c.Create(context.TODO(), &bob); err != nil {
+		t.Fatal(err)
+	}
+	collector = NewCollector(c)
+	if err := collector.collect(start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 exported report, got %d", len(reports))
+	}
+	report := reports[0]
+	if !report.Start.Equal(start) || !report.End.Equal(start.Add(time.Hour)) {
+		t.Error("Expected report to cover the first hour, got", report.Start, report.End)
+	}
+	if len(report.Records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(report.Records))
+	}
+	if report.Records[0].DesktopHours != 1 {
+		t.Error("Expected 1 desktop hour for alice, got", report.Records[0].DesktopHours)
+	}
+	if hours := report.Records[1].DesktopHours; hours < 0.16 || hours > 0.17 {
+		t.Error("Expected 10 desktop minutes for bob, got", hours)
+	}
+
+	// the stored ledger starts a new period after the export
+	l, err := readLedger(c, cluster, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.start.Equal(start.Add(time.Hour)) || len(l.records) != 0 {
+		t.Error("Expected an empty ledger starting after the export, got", l.start, l.records)
+	}
+
+	// ledgers are removed when exports are disabled
+	cluster.Spec.Billing = nil
+	if err := c.Update(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := collector.collect(start.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(context.TODO(), cluster.GetBillingLedgerName(), &corev1.ConfigMap{}); err == nil {
+		t.Error("Expected ledger to be removed")
+	}
+}
@@ -0,0 +1,46 @@
+package billing
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// csvHeader is the header row written to CSV reports.
+var csvHeader = []string{
+	"cluster", "periodStart", "periodEnd", "user", "namespace", "template",
+	"desktopHours", "cpuHours", "memoryGiBHours",
+}
+
+// CSV returns the report encoded as CSV with a header row.
+func (r *Report) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	start, end := r.Start.UTC().Format(time.RFC3339), r.End.UTC().Format(time.RFC3339)
+	for _, rec := range r.Records {
+		if err := w.Write([]string{
+			r.Cluster, start, end, rec.User, rec.Namespace, rec.Template,
+			formatFloat(rec.DesktopHours), formatFloat(rec.CPUHours), formatFloat(rec.MemoryGiBHours),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// JSON returns the report encoded as JSON.
+func (r *Report) JSON() ([]byte, error) { return json.Marshal(r) }
+
+// Filename returns a filename for the report based off the cluster name and period.
+func (r *Report) Filename(ext string) string {
+	return fmt.Sprintf("%s-%s-%s.%s", r.Cluster, r.Start.UTC().Format("20060102T150405Z"), r.End.UTC().Format("20060102T150405Z"), ext)
+}
+
+func formatFloat(f float64) string { return strconv.FormatFloat(f, 'f', 4, 64) }
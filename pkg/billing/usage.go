@@ -0,0 +1,254 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// usageComponent is the component label applied to daily usage configmaps.
+const usageComponent = "usage"
+
+// usageTracker accumulates the usage of a single VDICluster for the current day.
+type usageTracker struct {
+	// the time of the last sample
+	lastSample time.Time
+	// the usage for the current day
+	usage *v1.DailyUsage
+}
+
+// newUsageTracker returns a new usageTracker starting at the given time.
+func newUsageTracker(start time.Time) *usageTracker {
+	return &usageTracker{lastSample: start}
+}
+
+// sessionKey returns a key uniquely identifying a session. Desktops claimed from
+// a SessionPool keep their name, so the start of the session is included.
+func sessionKey(namespace, name string, start time.Time) string {
+	return fmt.Sprintf("%s/%s/%d", namespace, name, start.Unix())
+}
+
+// sample adds the time since the last sample to the records for the given running
+// desktops, and updates the sessions for the day. Sessions whose desktop is no
+// longer running are marked as ended.
+func (t *usageTracker) sample(now time.Time, cluster *v1alpha1.VDICluster, desktops []v1alpha1.Desktop, templates map[string]*v1alpha1.DesktopTemplate) {
+	hours := now.Sub(t.lastSample).Hours()
+	t.lastSample = now
+
+	records := make(map[recordKey]*v1.UsageRecord)
+	for _, rec := range t.usage.Records {
+		records[recordKey{user: rec.User, namespace: rec.Namespace, template: rec.Template}] = rec
+	}
+	sessions := make(map[string]*v1.UsageSession)
+	for _, sess := range t.usage.Sessions {
+		sessions[sessionKey(sess.Namespace, sess.Name, sess.Start)] = sess
+	}
+
+	running := make(map[string]struct{})
+	for i := range desktops {
+		desktop := &desktops[i]
+		if !desktop.Status.Running {
+			continue
+		}
+		cpu, mem := desktopRequests(cluster, desktop, templates)
+		roles := desktop.GetUserRoles()
+
+		if hours > 0 {
+			key := recordKey{user: desktop.GetUser(), namespace: desktop.GetNamespace(), template: desktop.Spec.Template}
+			rec, ok := records[key]
+			if !ok {
+				rec = &v1.UsageRecord{User: key.user, Namespace: key.namespace, Template: key.template}
+				records[key] = rec
+				t.usage.Records = append(t.usage.Records, rec)
+			}
+			rec.Roles = roles
+			rec.DesktopHours += hours
+			rec.CPUHours += cpu * hours
+			rec.MemoryGiBHours += mem * hours
+		}
+
+		start := desktop.GetSessionStart()
+		key := sessionKey(desktop.GetNamespace(), desktop.GetName(), start)
+		sess, ok := sessions[key]
+		if !ok {
+			sess = &v1.UsageSession{
+				Name:      desktop.GetName(),
+				Namespace: desktop.GetNamespace(),
+				User:      desktop.GetUser(),
+				Template:  desktop.Spec.Template,
+				Start:     start,
+			}
+			sessions[key] = sess
+			t.usage.Sessions = append(t.usage.Sessions, sess)
+		}
+		// the desktop may have been restarted since it was last seen
+		sess.End = nil
+		sess.Roles = roles
+		sess.CPURequest = cpu
+		sess.MemoryGiBRequest = mem
+		running[key] = struct{}{}
+	}
+
+	for key, sess := range sessions {
+		if _, ok := running[key]; !ok && sess.End == nil {
+			end := now
+			sess.End = &end
+		}
+		end := now
+		if sess.End != nil {
+			end = *sess.End
+		}
+		sess.DurationHours = end.Sub(sess.Start).Hours()
+	}
+}
+
+// recordUsage samples the given desktops into the usage for the current day and
+// persists it. When the day changes, usage older than the retention period for
+// the cluster is removed.
+func (c *Collector) recordUsage(now time.Time, cluster *v1alpha1.VDICluster, desktops []v1alpha1.Desktop, templates map[string]*v1alpha1.DesktopTemplate) error {
+	t, ok := c.usage[cluster.GetName()]
+	if !ok {
+		t = newUsageTracker(now)
+		c.usage[cluster.GetName()] = t
+	}
+	if t.usage == nil || t.usage.Date != now.UTC().Format(v1.UsageDateFormat) {
+		usage, err := ReadDailyUsage(c.client, cluster, now)
+		if err != nil {
+			return err
+		}
+		if t.usage != nil {
+			carryOverSessions(t.usage, usage)
+		}
+		t.usage = usage
+		if err := pruneUsage(c.client, cluster, now); err != nil {
+			billingLogger.Error(err, "Failed to remove expired usage", "Cluster", cluster.GetName())
+		}
+	}
+	t.sample(now, cluster, desktops, templates)
+	return writeDailyUsage(c.client, cluster, t.usage)
+}
+
+// carryOverSessions adds the sessions still running at the end of one day to the
+// next, so they are ended there if their desktop is gone.
+func carryOverSessions(from, to *v1.DailyUsage) {
+	existing := make(map[string]struct{})
+	for _, sess := range to.Sessions {
+		existing[sessionKey(sess.Namespace, sess.Name, sess.Start)] = struct{}{}
+	}
+	for _, sess := range from.Sessions {
+		if sess.End != nil {
+			continue
+		}
+		if _, ok := existing[sessionKey(sess.Namespace, sess.Name, sess.Start)]; !ok {
+			to.Sessions = append(to.Sessions, sess)
+		}
+	}
+}
+
+// ReadDailyUsage returns the usage stored for the cluster on the day of the given
+// time. Empty usage is returned if none was recorded.
+func ReadDailyUsage(c client.Client, cluster *v1alpha1.VDICluster, day time.Time) (*v1.DailyUsage, error) {
+	usage := &v1.DailyUsage{
+		Date:     day.UTC().Format(v1.UsageDateFormat),
+		Records:  make([]*v1.UsageRecord, 0),
+		Sessions: make([]*v1.UsageSession, 0),
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), cluster.GetUsageName(day), cm); err != nil {
+		return usage, client.IgnoreNotFound(err)
+	}
+	raw, ok := cm.Data[v1.UsageKey]
+	if !ok {
+		return usage, nil
+	}
+	if err := json.Unmarshal([]byte(raw), usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// ReadUsage returns the usage stored for the cluster on each day between start
+// and end, inclusive. Days without any recorded usage are skipped.
+func ReadUsage(c client.Client, cluster *v1alpha1.VDICluster, start, end time.Time) ([]*v1.DailyUsage, error) {
+	days := make([]*v1.DailyUsage, 0)
+	for day := start.UTC(); !day.After(end.UTC()); day = day.AddDate(0, 0, 1) {
+		usage, err := ReadDailyUsage(c, cluster, day)
+		if err != nil {
+			return nil, err
+		}
+		if len(usage.Records) == 0 && len(usage.Sessions) == 0 {
+			continue
+		}
+		days = append(days, usage)
+	}
+	return days, nil
+}
+
+// writeDailyUsage stores the given usage in the configmap for its day.
+func writeDailyUsage(c client.Client, cluster *v1alpha1.VDICluster, usage *v1.DailyUsage) error {
+	day, err := time.Parse(v1.UsageDateFormat, usage.Date)
+	if err != nil {
+		return err
+	}
+	out, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	nn := cluster.GetUsageName(day)
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), nn, cm); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            nn.Name,
+				Namespace:       nn.Namespace,
+				Labels:          cluster.GetComponentLabels(usageComponent),
+				OwnerReferences: cluster.OwnerReferences(),
+			},
+			Data: map[string]string{v1.UsageKey: string(out)},
+		}
+		return c.Create(context.TODO(), cm)
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[v1.UsageKey] = string(out)
+	return c.Update(context.TODO(), cm)
+}
+
+// pruneUsage removes the daily usage for the cluster that is older than its
+// retention period.
+func pruneUsage(c client.Client, cluster *v1alpha1.VDICluster, now time.Time) error {
+	cms := &corev1.ConfigMapList{}
+	if err := c.List(context.TODO(), cms, client.InNamespace(cluster.GetCoreNamespace()), client.MatchingLabels{
+		v1.VDIClusterLabel: cluster.GetName(),
+		v1.ComponentLabel:  usageComponent,
+	}); err != nil {
+		return err
+	}
+	cutoff := now.UTC().Add(-cluster.GetUsageRetention())
+	prefix := fmt.Sprintf("%s-usage-", cluster.GetName())
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		day, err := time.Parse(v1.UsageDateFormat, strings.TrimPrefix(cm.GetName(), prefix))
+		if err != nil || !day.AddDate(0, 0, 1).Before(cutoff) {
+			continue
+		}
+		billingLogger.Info("Removing expired usage", "Cluster", cluster.GetName(), "Date", day.Format(v1.UsageDateFormat))
+		if err := c.Delete(context.TODO(), cm); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
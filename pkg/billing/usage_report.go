@@ -0,0 +1,102 @@
+package billing
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+)
+
+// NewUsageReport aggregates the given daily usage into a report grouped by the
+// given field. Sessions that ran across multiple days are only counted once.
+func NewUsageReport(start, end string, groupBy v1.UsageGroupBy, days []*v1.DailyUsage) *v1.UsageReport {
+	report := &v1.UsageReport{
+		Start:    start,
+		End:      end,
+		GroupBy:  groupBy,
+		Groups:   make([]*v1.UsageReportGroup, 0),
+		Sessions: make([]*v1.UsageSession, 0),
+	}
+	groups := make(map[string]*v1.UsageReportGroup)
+	getGroup := func(key string) *v1.UsageReportGroup {
+		group, ok := groups[key]
+		if !ok {
+			group = &v1.UsageReportGroup{Key: key}
+			groups[key] = group
+			report.Groups = append(report.Groups, group)
+		}
+		return group
+	}
+
+	sessions := make(map[string]int)
+	for _, day := range days {
+		for _, rec := range day.Records {
+			for _, key := range usageGroupKeys(groupBy, rec.User, rec.Namespace, rec.Template, rec.Roles) {
+				group := getGroup(key)
+				group.DesktopHours += rec.DesktopHours
+				group.CPUHours += rec.CPUHours
+				group.MemoryGiBHours += rec.MemoryGiBHours
+			}
+		}
+		// days are in order, so later days hold the latest state of a session
+		for _, sess := range day.Sessions {
+			key := sessionKey(sess.Namespace, sess.Name, sess.Start)
+			if idx, ok := sessions[key]; ok {
+				report.Sessions[idx] = sess
+				continue
+			}
+			sessions[key] = len(report.Sessions)
+			report.Sessions = append(report.Sessions, sess)
+		}
+	}
+
+	for _, sess := range report.Sessions {
+		for _, key := range usageGroupKeys(groupBy, sess.User, sess.Namespace, sess.Template, sess.Roles) {
+			getGroup(key).Sessions++
+		}
+	}
+
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].Key < report.Groups[j].Key })
+	sort.SliceStable(report.Sessions, func(i, j int) bool { return report.Sessions[i].Start.Before(report.Sessions[j].Start) })
+	return report
+}
+
+// usageGroupKeys returns the keys usage with the given fields counts towards.
+func usageGroupKeys(groupBy v1.UsageGroupBy, user, namespace, template string, roles []string) []string {
+	switch groupBy {
+	case v1.UsageGroupByRole:
+		if len(roles) == 0 {
+			return []string{""}
+		}
+		return roles
+	case v1.UsageGroupByTemplate:
+		return []string{template}
+	case v1.UsageGroupByNamespace:
+		return []string{namespace}
+	default:
+		return []string{user}
+	}
+}
+
+// WriteUsageReportCSV writes the groups in the given report as CSV with a header row.
+func WriteUsageReportCSV(w io.Writer, report *v1.UsageReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{
+		"periodStart", "periodEnd", string(report.GroupBy), "sessions",
+		"desktopHours", "cpuHours", "memoryGiBHours",
+	}); err != nil {
+		return err
+	}
+	for _, group := range report.Groups {
+		if err := cw.Write([]string{
+			report.Start, report.End, group.Key, strconv.Itoa(group.Sessions),
+			formatFloat(group.DesktopHours), formatFloat(group.CPUHours), formatFloat(group.MemoryGiBHours),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
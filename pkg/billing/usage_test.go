@@ -0,0 +1,154 @@
+package billing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis"
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUsage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	c := &Collector{client: fake.NewFakeClientWithScheme(scheme), usage: make(map[string]*usageTracker)}
+
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test"
+	start := time.Date(2020, 1, 1, 22, 0, 0, 0, time.UTC)
+	templates := map[string]*v1alpha1.DesktopTemplate{
+		"test-template": {
+			Spec: v1alpha1.DesktopTemplateSpec{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("2"),
+						corev1.ResourceMemory: resource.MustParse("1Gi"),
+					},
+				},
+			},
+		},
+	}
+	alice := newTestDesktop("a", "alice", true)
+	alice.CreationTimestamp = metav1.NewTime(start)
+	alice.Annotations = map[string]string{v1.UserRolesAnnotation: "finance;engineering"}
+	bob := newTestDesktop("b", "bob", true)
+	bob.CreationTimestamp = metav1.NewTime(start)
+	bob.Annotations = map[string]string{v1.UserRolesAnnotation: "engineering"}
+
+	for _, sample := range []struct {
+		at       time.Time
+		desktops []v1alpha1.Desktop
+	}{
+		{start, []v1alpha1.Desktop{alice, bob}},
+		{start.Add(time.Hour), []v1alpha1.Desktop{alice, bob}},
+		{start.Add(2 * time.Hour), []v1alpha1.Desktop{alice}},
+		{start.Add(3 * time.Hour), nil},
+	} {
+		if err := c.recordUsage(sample.at, cluster, sample.desktops, templates); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	days, err := ReadUsage(c.client, cluster, start.Add(-24*time.Hour), start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("Expected usage for 2 days, got %d", len(days))
+	}
+	if days[0].Date != "2020-01-01" || days[1].Date != "2020-01-02" {
+		t.Error("Expected usage to be split by day, got", days[0].Date, days[1].Date)
+	}
+
+	report := NewUsageReport("2020-01-01", "2020-01-02", v1.UsageGroupByRole, days)
+	if len(report.Sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(report.Sessions))
+	}
+	for _, sess := range report.Sessions {
+		if sess.End == nil {
+			t.Error("Expected session to have ended:", sess.Name)
+		}
+	}
+	if report.Sessions[0].CPURequest != 2 || report.Sessions[0].MemoryGiBRequest != 1 {
+		t.Error("Expected session requests to be recorded, got", report.Sessions[0].CPURequest, report.Sessions[0].MemoryGiBRequest)
+	}
+
+	expected := map[string]struct {
+		sessions int
+		hours    float64
+	}{
+		"engineering": {2, 3},
+		"finance":     {1, 2},
+	}
+	if len(report.Groups) != len(expected) {
+		t.Fatalf("Expected %d groups, got %d", len(expected), len(report.Groups))
+	}
+	for _, group := range report.Groups {
+		exp, ok := expected[group.Key]
+		if !ok {
+			t.Error("Unexpected group in report:", group.Key)
+			continue
+		}
+		if group.Sessions != exp.sessions {
+			t.Errorf("Expected %d sessions for %s, got %d", exp.sessions, group.Key, group.Sessions)
+		}
+		if group.DesktopHours != exp.hours {
+			t.Errorf("Expected %v desktop hours for %s, got %v", exp.hours, group.Key, group.DesktopHours)
+		}
+		if group.CPUHours != exp.hours*2 {
+			t.Errorf("Expected %v cpu hours for %s, got %v", exp.hours*2, group.Key, group.CPUHours)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteUsageReportCSV(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d lines", len(lines))
+	}
+	if lines[0] != "periodStart,periodEnd,role,sessions,desktopHours,cpuHours,memoryGiBHours" {
+		t.Error("Unexpected header:", lines[0])
+	}
+}
+
+func TestPruneUsage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	c := fake.NewFakeClientWithScheme(scheme)
+
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test"
+	cluster.Spec.Billing = &v1alpha1.BillingConfig{UsageRetention: "48h"}
+	now := time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	for offset := 0; offset < 5; offset++ {
+		usage := &v1.DailyUsage{Date: now.AddDate(0, 0, -offset).Format(v1.UsageDateFormat)}
+		if err := writeDailyUsage(c, cluster, usage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pruneUsage(c, cluster, now); err != nil {
+		t.Fatal(err)
+	}
+	cms := &corev1.ConfigMapList{}
+	if err := c.List(context.TODO(), cms); err != nil {
+		t.Fatal(err)
+	}
+	if len(cms.Items) != 3 {
+		t.Errorf("Expected 3 days of usage to be kept, got %d", len(cms.Items))
+	}
+}
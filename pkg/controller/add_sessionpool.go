@@ -0,0 +1,10 @@
+package controller
+
+import (
+	"github.com/tinyzimmer/kvdi/pkg/controller/sessionpool"
+)
+
+func init() {
+	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
+	AddToManagerFuncs = append(AddToManagerFuncs, sessionpool.Add)
+}
@@ -8,13 +8,16 @@ import (
 	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 	"github.com/tinyzimmer/kvdi/pkg/resources"
 	"github.com/tinyzimmer/kvdi/pkg/resources/desktop"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -69,9 +72,87 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	err = c.Watch(&source.Kind{Type: &networkingv1.NetworkPolicy{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &v1alpha1.Desktop{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for taints on Nodes and requeue the Desktops running on them so
+	// preemption notices can be handled
+	err = c.Watch(&source.Kind{Type: &corev1.Node{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: &nodeDesktopMapper{client: mgr.GetClient()},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to DesktopTemplates and requeue the Desktops booted from
+	// them so update policies can be applied
+	err = c.Watch(&source.Kind{Type: &v1alpha1.DesktopTemplate{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: &templateDesktopMapper{client: mgr.GetClient()},
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// templateDesktopMapper maps DesktopTemplates to the Desktops booted from them.
+type templateDesktopMapper struct {
+	client client.Client
+}
+
+// Map implements handler.Mapper.
+func (m *templateDesktopMapper) Map(obj handler.MapObject) []reconcile.Request {
+	desktops := &v1alpha1.DesktopList{}
+	if err := m.client.List(context.TODO(), desktops); err != nil {
+		log.Error(err, "Failed to list desktops", "DesktopTemplate.Name", obj.Meta.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for _, desktop := range desktops.Items {
+		if desktop.Spec.Template != obj.Meta.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()},
+		})
+	}
+	return requests
+}
+
+// nodeDesktopMapper maps tainted Nodes to the Desktops running on them.
+type nodeDesktopMapper struct {
+	client client.Client
+}
+
+// Map implements handler.Mapper.
+func (m *nodeDesktopMapper) Map(obj handler.MapObject) []reconcile.Request {
+	node, ok := obj.Object.(*corev1.Node)
+	if !ok || len(node.Spec.Taints) == 0 {
+		return nil
+	}
+	pods := &corev1.PodList{}
+	if err := m.client.List(context.TODO(), pods, client.MatchingLabels{v1.ComponentLabel: "desktop"}); err != nil {
+		log.Error(err, "Failed to list desktop pods", "Node.Name", node.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != node.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: pod.GetName(), Namespace: pod.GetNamespace()},
+		})
+	}
+	return requests
+}
+
 // blank assignment to verify that ReconcileDesktop implements reconcile.Reconciler
 var _ reconcile.Reconciler = &ReconcileDesktop{}
 
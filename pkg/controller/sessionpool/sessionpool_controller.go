@@ -0,0 +1,116 @@
+// Package sessionpool contains the controller implementation for keeping desktops
+// booted and waiting to be claimed.
+package sessionpool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/resources"
+	"github.com/tinyzimmer/kvdi/pkg/resources/sessionpool"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_sessionpool")
+
+// Add creates a new SessionPool Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileSessionPool{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("sessionpool-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource SessionPool
+	err = c.Watch(&source.Kind{Type: &v1alpha1.SessionPool{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the Desktops in the pool. Claimed desktops lose their
+	// owner reference, which also requeues the pool.
+	err = c.Watch(&source.Kind{Type: &v1alpha1.Desktop{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &v1alpha1.SessionPool{},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileSessionPool implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileSessionPool{}
+
+// ReconcileSessionPool reconciles a SessionPool object
+type ReconcileSessionPool struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// Reconcile reads that state of the cluster for a SessionPool object and makes changes based on the state read
+// and what is in the SessionPool.Spec
+func (r *ReconcileSessionPool) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling SessionPool")
+
+	// Fetch the SessionPool instance
+	instance := &v1alpha1.SessionPool{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Return and don't requeue
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	reconcilers := []resources.SessionPoolReconciler{
+		sessionpool.New(r.client, r.scheme),
+	}
+
+	for _, r := range reconcilers {
+		if err := r.Reconcile(reqLogger, instance); err != nil {
+			if qerr, ok := errors.IsRequeueError(err); ok {
+				reqLogger.Info(fmt.Sprintf("Requeueing in %d seconds for: %s", qerr.Duration()/time.Second, qerr.Error()))
+				return reconcile.Result{
+					Requeue:      true,
+					RequeueAfter: qerr.Duration(),
+				}, nil
+			}
+			return reconcile.Result{}, err
+		}
+	}
+
+	reqLogger.Info("Reconcile finished")
+	return reconcile.Result{}, nil
+}
@@ -5,6 +5,7 @@ package vdicluster
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
@@ -131,9 +132,14 @@ func (r *ReconcileVDICluster) Reconcile(request reconcile.Request) (reconcile.Re
 		app.New(r.client, r.scheme),
 	}
 
-	// Run each reconciler
-	for _, r := range reconcilers {
-		if err := r.Reconcile(reqLogger, instance); err != nil {
+	// Run each reconciler, reporting the state of the components they observed
+	// in the status whether they succeed or not
+	status := instance.Status.DeepCopy()
+	for _, rec := range reconcilers {
+		if err := rec.Reconcile(reqLogger, instance); err != nil {
+			if serr := r.updateStatus(instance, status); serr != nil {
+				reqLogger.Error(serr, "Failed to update VDICluster status")
+			}
 			if qerr, ok := errors.IsRequeueError(err); ok {
 				reqLogger.Info(fmt.Sprintf("Requeueing in %d seconds for: %s", qerr.Duration()/time.Second, qerr.Error()))
 				return reconcile.Result{
@@ -145,6 +151,20 @@ func (r *ReconcileVDICluster) Reconcile(request reconcile.Request) (reconcile.Re
 		}
 	}
 
+	if err := r.updateStatus(instance, status); err != nil {
+		return reconcile.Result{}, err
+	}
+
 	reqLogger.Info("Reconcile finished")
 	return reconcile.Result{}, nil
 }
+
+// updateStatus marks the VDICluster ready if all of its conditions are true, and
+// writes the status if it changed from the given one.
+func (r *ReconcileVDICluster) updateStatus(instance *v1alpha1.VDICluster, original *v1alpha1.VDIClusterStatus) error {
+	instance.Status.Ready = instance.ConditionsReady()
+	if reflect.DeepEqual(original, &instance.Status) {
+		return nil
+	}
+	return r.client.Status().Update(context.TODO(), instance)
+}
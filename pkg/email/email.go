@@ -0,0 +1,211 @@
+// Package email contains a sender for delivering templated notifications to users
+// and administrators over SMTP.
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// Message is the name of a message that can be sent.
+type Message string
+
+// Messages that can be sent. These are also the keys for overriding templates in
+// the cluster configuration.
+const (
+	// MessageMFAEnrollment is sent to users when MFA is enabled for them, with a link
+	// for enrolling their authenticator.
+	MessageMFAEnrollment Message = "mfa-enrollment"
+	// MessagePasswordReset is sent to users that request a password reset.
+	MessagePasswordReset Message = "password-reset"
+	// MessageSessionExpiry is sent to users before their desktop is destroyed.
+	MessageSessionExpiry Message = "session-expiry"
+	// MessageAdminAlert is sent to administrators when something requires their
+	// attention.
+	MessageAdminAlert Message = "admin-alert"
+)
+
+// Data is the data rendered into message templates.
+type Data struct {
+	// The name of the VDICluster
+	Cluster string
+	// The name of the user the message is about
+	User string
+	// A link for the user to follow, if any
+	Link string
+	// The namespaced name of the desktop the message is about, if any
+	Desktop string
+	// A time relevant to the message, such as when a desktop expires
+	Time time.Time
+	// The reason for an alert
+	Reason string
+	// Additional details for an alert
+	Message string
+}
+
+// Sender sends templated messages over SMTP.
+type Sender struct {
+	cfg      *v1alpha1.EmailConfig
+	cluster  string
+	password string
+	// sendMail delivers a rendered message, it is swapped out in tests
+	sendMail func(to []string, msg []byte) error
+}
+
+// New returns a new Sender for the given cluster. The password for the SMTP server
+// is read from the secrets engine when a username is configured.
+func New(cluster *v1alpha1.VDICluster, s *secrets.SecretEngine) (*Sender, error) {
+	cfg := cluster.GetEmailConfig()
+	if cfg.Server == "" {
+		return nil, errors.New("No SMTP server is configured")
+	}
+	if cfg.From == "" {
+		return nil, errors.New("A from address is required for sending email")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Server); err != nil {
+		return nil, fmt.Errorf("Invalid SMTP server address %q: %s", cfg.Server, err.Error())
+	}
+	sender := &Sender{cfg: cfg, cluster: cluster.GetName()}
+	if cfg.Username != "" {
+		passwd, err := s.ReadSecret(cfg.GetPasswordKey(), true)
+		if err != nil {
+			return nil, err
+		}
+		sender.password = string(passwd)
+	}
+	sender.sendMail = sender.deliver
+	// make sure any template overrides render before they are needed
+	for name := range cfg.Templates {
+		if _, _, err := sender.render(Message(name), &Data{}); err != nil {
+			return nil, err
+		}
+	}
+	return sender, nil
+}
+
+// AdminAddresses returns the addresses alerts are sent to.
+func (s *Sender) AdminAddresses() []string { return s.cfg.AdminAddresses }
+
+// AppURL returns the external URL of the app for building links.
+func (s *Sender) AppURL() string { return strings.TrimSuffix(s.cfg.AppURL, "/") }
+
+// Send renders the given message with the data and sends it to the given addresses.
+func (s *Sender) Send(to []string, msg Message, data *Data) error {
+	if len(to) == 0 {
+		return nil
+	}
+	if data.Cluster == "" {
+		data.Cluster = s.cluster
+	}
+	subject, body, err := s.render(msg, data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", sanitizeHeader(subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	buf.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return s.sendMail(to, buf.Bytes())
+}
+
+// render returns the subject and body of the given message, preferring any
+// overrides in the configuration.
+func (s *Sender) render(msg Message, data *Data) (subject, body string, err error) {
+	tmpl, ok := defaultTemplates[msg]
+	if !ok {
+		return "", "", fmt.Errorf("Unknown email message: %s", msg)
+	}
+	if override, ok := s.cfg.Templates[string(msg)]; ok {
+		if override.Subject != "" {
+			tmpl.Subject = override.Subject
+		}
+		if override.Body != "" {
+			tmpl.Body = override.Body
+		}
+	}
+	if subject, err = execute(string(msg)+"-subject", tmpl.Subject, data); err != nil {
+		return
+	}
+	body, err = execute(string(msg)+"-body", tmpl.Body, data)
+	return
+}
+
+func execute(name, text string, data *Data) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("Invalid email template %s: %s", name, err.Error())
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sanitizeHeader strips line breaks from a header value so rendered data cannot
+// inject headers.
+func sanitizeHeader(s string) string {
+	return strings.NewReplacer("\r", "", "\n", " ").Replace(s)
+}
+
+// deliver sends the message over SMTP. Connections are upgraded with STARTTLS when
+// the server supports it, unless implicit TLS is configured.
+func (s *Sender) deliver(to []string, msg []byte) error {
+	host, _, _ := net.SplitHostPort(s.cfg.Server)
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.password, host)
+	}
+	if !s.cfg.ImplicitTLS {
+		return smtp.SendMail(s.cfg.Server, auth, s.cfg.From, to, msg)
+	}
+
+	conn, err := tls.Dial("tcp", s.cfg.Server, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,94 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestCluster(cfg *v1alpha1.EmailConfig) *v1alpha1.VDICluster {
+	return &v1alpha1.VDICluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+		Spec:       v1alpha1.VDIClusterSpec{Email: cfg},
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []*v1alpha1.EmailConfig{
+		nil,
+		{Server: "smtp.example.com:25"},
+		{Server: "smtp.example.com", From: "kvdi@example.com"},
+		{Server: "smtp.example.com:25", From: "kvdi@example.com", Templates: map[string]v1alpha1.EmailTemplate{
+			"admin-alert": {Subject: "{{ .Bad"},
+		}},
+		{Server: "smtp.example.com:25", From: "kvdi@example.com", Templates: map[string]v1alpha1.EmailTemplate{
+			"unknown": {Subject: "hello"},
+		}},
+	} {
+		if _, err := New(newTestCluster(cfg), nil); err == nil {
+			t.Errorf("Expected error for config %+v, got nil", cfg)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	sender, err := New(newTestCluster(&v1alpha1.EmailConfig{
+		Server: "smtp.example.com:25",
+		From:   "kvdi@example.com",
+		Templates: map[string]v1alpha1.EmailTemplate{
+			"admin-alert": {Subject: "Alert: {{ .Reason }}"},
+		},
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sentTo []string
+	var sent string
+	sender.sendMail = func(to []string, msg []byte) error {
+		sentTo, sent = to, string(msg)
+		return nil
+	}
+
+	// nothing is sent without recipients
+	if err := sender.Send(nil, MessageAdminAlert, &Data{}); err != nil || sent != "" {
+		t.Fatal("Expected nothing to be sent, got:", sent, err)
+	}
+
+	expires := time.Date(2020, 1, 1, 12, 30, 0, 0, time.UTC)
+	if err := sender.Send([]string{"user@example.com"}, MessageSessionExpiry, &Data{User: "test-user", Desktop: "default/test-desktop", Time: expires}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sentTo) != 1 || sentTo[0] != "user@example.com" {
+		t.Error("Expected message to be sent to the user, got:", sentTo)
+	}
+	for _, expected := range []string{
+		"From: kvdi@example.com\r\n",
+		"To: user@example.com\r\n",
+		"Subject: Your desktop default/test-desktop expires soon\r\n",
+		"Hello test-user,\r\n",
+		"on test-cluster will be destroyed at 12:30 UTC.",
+	} {
+		if !strings.Contains(sent, expected) {
+			t.Errorf("Expected message to contain %q, got:\n%s", expected, sent)
+		}
+	}
+
+	// overrides replace the defaults and rendered data cannot inject headers
+	if err := sender.Send([]string{"admin@example.com"}, MessageAdminAlert, &Data{Reason: "Lockout\r\nBcc: evil@example.com", Message: "details"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sent, "Subject: Alert: Lockout Bcc: evil@example.com\r\n") {
+		t.Error("Expected overridden and sanitized subject, got:", sent)
+	}
+	if !strings.HasSuffix(sent, "\r\n\r\ndetails\r\n") {
+		t.Error("Expected default body, got:", sent)
+	}
+
+	if err := sender.Send([]string{"admin@example.com"}, Message("unknown"), &Data{}); err == nil {
+		t.Error("Expected error for unknown message, got nil")
+	}
+}
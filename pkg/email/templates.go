@@ -0,0 +1,44 @@
+package email
+
+import "github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+// defaultTemplates are the messages sent when the cluster does not override them.
+var defaultTemplates = map[Message]v1alpha1.EmailTemplate{
+	MessageMFAEnrollment: {
+		Subject: "Enroll your authenticator for {{ .Cluster }}",
+		Body: `Hello {{ .User }},
+
+Multi-factor authentication has been enabled for your account on {{ .Cluster }}.
+Add the following link to your authenticator app to finish enrolling:
+
+{{ .Link }}
+
+If you did not expect this message, contact your administrator.
+`,
+	},
+	MessagePasswordReset: {
+		Subject: "Reset your password for {{ .Cluster }}",
+		Body: `Hello {{ .User }},
+
+A password reset was requested for your account on {{ .Cluster }}.
+Follow the link below before {{ .Time.Format "15:04 MST" }} to choose a new password:
+
+{{ .Link }}
+
+If you did not request this, you can ignore this message.
+`,
+	},
+	MessageSessionExpiry: {
+		Subject: "Your desktop {{ .Desktop }} expires soon",
+		Body: `Hello {{ .User }},
+
+Your desktop {{ .Desktop }} on {{ .Cluster }} will be destroyed at {{ .Time.Format "15:04 MST" }}.
+Save any work you want to keep before then.
+`,
+	},
+	MessageAdminAlert: {
+		Subject: "[{{ .Cluster }}] {{ .Reason }}",
+		Body: `{{ .Message }}
+`,
+	},
+}
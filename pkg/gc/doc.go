@@ -0,0 +1,3 @@
+// Package gc contains a sweeper for finding and cleaning up resources left
+// behind by desktops and users that no longer exist.
+package gc
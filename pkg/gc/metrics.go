@@ -0,0 +1,64 @@
+package gc
+
+import (
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// orphanKinds are the kinds of resources a scan can find, so gauges can be reset
+// for kinds that are no longer orphaned.
+var orphanKinds = []string{"Desktop", "Pod", "Service", "Secret", "PersistentVolumeClaim", "PersistentVolume"}
+
+// Prometheus gatherers, served on the metrics endpoint of the manager
+
+var (
+	// orphanedResources tracks the orphaned resources found in the last scan
+	orphanedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "gc_orphaned_resources",
+		Help:      "The number of orphaned resources found in the last scan by cluster and kind.",
+	}, []string{"cluster", "kind"})
+
+	// deletedResourcesTotal tracks the orphaned resources that were deleted
+	deletedResourcesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "gc_deleted_resources_total",
+		Help:      "Total number of orphaned resources deleted by cluster and kind.",
+	}, []string{"cluster", "kind"})
+
+	// errorsTotal tracks errors encountered while scanning or deleting resources
+	errorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "kvdi",
+		Name:      "gc_errors_total",
+		Help:      "Total number of errors encountered during garbage collection by cluster.",
+	}, []string{"cluster"})
+
+	// lastRunTimestamp tracks when each cluster was last scanned
+	lastRunTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kvdi",
+		Name:      "gc_last_run_timestamp_seconds",
+		Help:      "The time of the last scan for orphaned resources by cluster.",
+	}, []string{"cluster"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(orphanedResources, deletedResourcesTotal, errorsTotal, lastRunTimestamp)
+}
+
+// recordMetrics updates the gatherers with the results of a sweep.
+func recordMetrics(cluster string, report *v1.GCReport) {
+	found := make(map[string]float64)
+	for _, res := range report.Resources {
+		found[res.Kind]++
+		if res.Deleted {
+			deletedResourcesTotal.WithLabelValues(cluster, res.Kind).Inc()
+		}
+	}
+	for _, kind := range orphanKinds {
+		orphanedResources.WithLabelValues(cluster, kind).Set(found[kind])
+	}
+	errorsTotal.WithLabelValues(cluster).Add(float64(len(report.Errors)))
+	lastRunTimestamp.WithLabelValues(cluster).Set(float64(report.Timestamp.Unix()))
+}
@@ -0,0 +1,310 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/auth"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// object is used for handling the different kinds of orphaned resources.
+type object interface {
+	runtime.Object
+	metav1.Object
+}
+
+// orphan is an orphaned resource along with the object used to delete it.
+type orphan struct {
+	*v1.OrphanedResource
+	obj object
+	// the user that owns userdata claims and volumes
+	user string
+}
+
+// orphanGracePeriod is how old a resource must be before it is considered orphaned.
+// Desktops and the resources created for them are listed separately, so a new
+// resource may be seen before the desktop that owns it.
+const orphanGracePeriod = 5 * time.Minute
+
+// Scan looks for resources belonging to the given cluster whose owning desktop or
+// user no longer exists, and for desktops that can no longer be started.
+func Scan(c client.Client, cluster *v1alpha1.VDICluster) ([]*orphan, error) {
+	now := time.Now()
+	desktops := &v1alpha1.DesktopList{}
+	if err := c.List(context.TODO(), desktops); err != nil {
+		return nil, err
+	}
+	desktopNames := make(map[types.NamespacedName]struct{})
+	desktopUsers := make(map[types.NamespacedName]struct{})
+	activeUsers := make(map[string]struct{})
+	for _, desktop := range desktops.Items {
+		if desktop.Spec.VDICluster != cluster.GetName() {
+			continue
+		}
+		desktopNames[types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}] = struct{}{}
+		desktopUsers[types.NamespacedName{Name: desktop.GetUser(), Namespace: desktop.GetNamespace()}] = struct{}{}
+		activeUsers[desktop.GetUser()] = struct{}{}
+	}
+
+	orphans := make([]*orphan, 0)
+	desktopSelector := client.MatchingLabels{
+		v1.VDIClusterLabel: cluster.GetName(),
+		v1.ComponentLabel:  "desktop",
+	}
+
+	// pods left behind when deleting a desktop failed partway
+	pods := &corev1.PodList{}
+	if err := c.List(context.TODO(), pods, desktopSelector); err != nil {
+		return nil, err
+	}
+	desktopPods := make(map[types.NamespacedName]struct{})
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		ref := desktopRef(pod)
+		if _, ok := desktopNames[ref]; ok || isRecent(pod, now) {
+			desktopPods[ref] = struct{}{}
+			continue
+		}
+		orphans = append(orphans, newOrphan("Pod", pod, "The owning desktop no longer exists"))
+	}
+
+	// desktops without a pod that can never be started again because their
+	// template was deleted
+	templates := &v1alpha1.DesktopTemplateList{}
+	if err := c.List(context.TODO(), templates); err != nil {
+		return nil, err
+	}
+	templateNames := make(map[string]struct{})
+	for _, tmpl := range templates.Items {
+		templateNames[tmpl.GetName()] = struct{}{}
+	}
+	for i := range desktops.Items {
+		desktop := &desktops.Items[i]
+		if desktop.Spec.VDICluster != cluster.GetName() || desktop.GetDeletionTimestamp() != nil || isRecent(desktop, now) {
+			continue
+		}
+		if _, ok := desktopPods[types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}]; ok {
+			continue
+		}
+		if _, ok := templateNames[desktop.Spec.Template]; !ok {
+			orphans = append(orphans, newOrphan("Desktop", desktop, fmt.Sprintf("The desktop has no pod and its template %s no longer exists", desktop.Spec.Template)))
+		}
+	}
+
+	// services and certificates created for desktops
+	services := &corev1.ServiceList{}
+	if err := c.List(context.TODO(), services, desktopSelector); err != nil {
+		return nil, err
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		if _, ok := desktopNames[desktopRef(svc)]; !ok && !isRecent(svc, now) {
+			orphans = append(orphans, newOrphan("Service", svc, "The owning desktop no longer exists"))
+		}
+	}
+	secretList := &corev1.SecretList{}
+	if err := c.List(context.TODO(), secretList, desktopSelector); err != nil {
+		return nil, err
+	}
+	for i := range secretList.Items {
+		secret := &secretList.Items[i]
+		if _, ok := desktopNames[desktopRef(secret)]; !ok && !isRecent(secret, now) {
+			orphans = append(orphans, newOrphan("Secret", secret, "The owning desktop no longer exists"))
+		}
+	}
+
+	if cluster.GetUserdataVolumeSpec() == nil {
+		return orphans, nil
+	}
+
+	// userdata claims only live as long as a user has a desktop
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := c.List(context.TODO(), pvcs, client.MatchingLabels{v1.VDIClusterLabel: cluster.GetName()}); err != nil {
+		return nil, err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		user, ok := pvc.GetLabels()[v1.UserLabel]
+		if !ok || isRecent(pvc, now) {
+			continue
+		}
+		if _, ok := desktopUsers[types.NamespacedName{Name: user, Namespace: pvc.GetNamespace()}]; !ok {
+			o := newOrphan("PersistentVolumeClaim", pvc, "The owning desktop no longer exists")
+			o.user = user
+			orphans = append(orphans, o)
+		}
+	}
+
+	// retained userdata volumes for users that no longer exist or have not used
+	// them within the retention period
+	volOrphans, err := scanUserdataVolumes(c, cluster, activeUsers, now)
+	if err != nil {
+		return nil, err
+	}
+	return append(orphans, volOrphans...), nil
+}
+
+// scanUserdataVolumes checks the userdata volume map for volumes that have outlived
+// the retention period, and for users that no longer exist in the auth provider.
+func scanUserdataVolumes(c client.Client, cluster *v1alpha1.VDICluster, activeUsers map[string]struct{}, now time.Time) ([]*orphan, error) {
+	orphans := make([]*orphan, 0)
+	volMapCM := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), cluster.GetUserdataVolumeMapName(), volMapCM); err != nil {
+		return orphans, client.IgnoreNotFound(err)
+	}
+	if len(volMapCM.Data) == 0 {
+		return orphans, nil
+	}
+
+	// look up the volumes that still exist
+	pvs := make(map[string]*corev1.PersistentVolume)
+	for user, pvName := range volMapCM.Data {
+		pv := &corev1.PersistentVolume{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: pvName, Namespace: metav1.NamespaceAll}, pv); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+			continue
+		}
+		pvs[user] = pv
+	}
+
+	if retention := cluster.GetUserdataRetentionPeriod(); retention > 0 {
+		for user, pv := range pvs {
+			if _, ok := activeUsers[user]; ok {
+				continue
+			}
+			// volumes released before retention was supported are never expired
+			lastUsed, err := time.Parse(time.RFC3339, pv.GetAnnotations()[v1.UserdataLastUsedAnnotation])
+			if err != nil || now.Sub(lastUsed) < retention {
+				continue
+			}
+			o := newOrphan("PersistentVolume", pv, fmt.Sprintf("The volume for user %s has not been used since %s", user, lastUsed.Format(time.RFC3339)))
+			o.user = user
+			orphans = append(orphans, o)
+			delete(pvs, user)
+		}
+	}
+
+	if len(pvs) == 0 || cluster.GetUserdataReclaimPolicy() == v1alpha1.UserDataReclaimRetain {
+		return orphans, nil
+	}
+
+	secretsEngine := secrets.GetSecretEngine(cluster)
+	if err := secretsEngine.Setup(c, cluster); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := secretsEngine.Close(); err != nil {
+			gcLogger.Error(err, "Error cleaning up secrets engine")
+		}
+	}()
+	authProvider := auth.GetAuthProvider(cluster, secretsEngine)
+	if err := authProvider.Setup(c, cluster); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := authProvider.Close(); err != nil {
+			gcLogger.Error(err, "Error cleaning up auth provider")
+		}
+	}()
+
+	for user, pv := range pvs {
+		// Only users the provider positively reports as missing are considered.
+		// Providers that can't look up users will return other errors.
+		if _, err := authProvider.GetUser(user); err == nil || !errors.IsUserNotFoundError(err) {
+			continue
+		}
+		o := newOrphan("PersistentVolume", pv, fmt.Sprintf("The user %s no longer exists", user))
+		o.user = user
+		orphans = append(orphans, o)
+	}
+	return orphans, nil
+}
+
+func newOrphan(kind string, obj object, reason string) *orphan {
+	return &orphan{
+		OrphanedResource: &v1.OrphanedResource{
+			Kind:      kind,
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Reason:    reason,
+		},
+		obj: obj,
+	}
+}
+
+// stillOrphaned checks the owner of the given orphan again before it is deleted.
+// The reader should not be served from a cache, so that resources for desktops
+// created since the scan listed them are left alone.
+func stillOrphaned(r client.Reader, cluster *v1alpha1.VDICluster, o *orphan) (bool, error) {
+	switch o.Kind {
+	case "Pod", "Service", "Secret":
+		err := r.Get(context.TODO(), desktopRef(o.obj), &v1alpha1.Desktop{})
+		if err == nil {
+			return false, nil
+		}
+		return true, client.IgnoreNotFound(err)
+	case "PersistentVolumeClaim":
+		hasDesktop, err := userHasDesktop(r, cluster, o.user, o.Namespace)
+		return !hasDesktop, err
+	case "PersistentVolume":
+		hasDesktop, err := userHasDesktop(r, cluster, o.user, metav1.NamespaceAll)
+		return !hasDesktop, err
+	case "Desktop":
+		desktop := &v1alpha1.Desktop{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: o.Name, Namespace: o.Namespace}, desktop); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		if desktop.GetDeletionTimestamp() != nil {
+			return false, nil
+		}
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: desktop.Spec.Template}, &v1alpha1.DesktopTemplate{}); client.IgnoreNotFound(err) != nil || err == nil {
+			return false, err
+		}
+		pods := &corev1.PodList{}
+		if err := r.List(context.TODO(), pods, client.InNamespace(desktop.GetNamespace()), client.MatchingLabels{
+			v1.VDIClusterLabel:  cluster.GetName(),
+			v1.DesktopNameLabel: desktop.GetName(),
+		}); err != nil {
+			return false, err
+		}
+		return len(pods.Items) == 0, nil
+	}
+	return true, nil
+}
+
+// userHasDesktop returns true if the given user has a desktop in the cluster. An
+// empty namespace checks all of them.
+func userHasDesktop(r client.Reader, cluster *v1alpha1.VDICluster, user, namespace string) (bool, error) {
+	desktops := &v1alpha1.DesktopList{}
+	if err := r.List(context.TODO(), desktops, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+	for _, desktop := range desktops.Items {
+		if desktop.Spec.VDICluster == cluster.GetName() && desktop.GetUser() == user {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isRecent returns true if the object was created within the grace period.
+func isRecent(obj metav1.Object, now time.Time) bool {
+	return now.Sub(obj.GetCreationTimestamp().Time) < orphanGracePeriod
+}
+
+func desktopRef(obj metav1.Object) types.NamespacedName {
+	return types.NamespacedName{Name: obj.GetLabels()[v1.DesktopNameLabel], Namespace: obj.GetNamespace()}
+}
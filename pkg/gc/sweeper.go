@@ -0,0 +1,176 @@
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var gcLogger = logf.Log.WithName("gc")
+
+// checkInterval is how often the sweeper checks if any clusters are due for a scan.
+const checkInterval = time.Minute
+
+// Sweeper is a manager.Runnable that periodically scans VDIClusters with garbage
+// collection enabled for orphaned resources.
+type Sweeper struct {
+	client  client.Client
+	reader  client.Reader
+	lastRun map[string]time.Time
+}
+
+// Blank assignments to make sure Sweeper satisfies the interfaces.
+var _ manager.Runnable = &Sweeper{}
+var _ manager.LeaderElectionRunnable = &Sweeper{}
+
+// NewSweeper returns a new Sweeper using the given client. The reader is used to
+// check owners again before deleting anything, and should read from the API server
+// directly.
+func NewSweeper(c client.Client, r client.Reader) *Sweeper {
+	return &Sweeper{client: c, reader: r, lastRun: make(map[string]time.Time)}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the leader
+// should clean up resources.
+func (s *Sweeper) NeedLeaderElection() bool { return true }
+
+// Start implements manager.Runnable and runs scans until the stop channel is closed.
+func (s *Sweeper) Start(stopCh <-chan struct{}) error {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case <-ticker.C:
+			if err := s.sweepDue(time.Now()); err != nil {
+				gcLogger.Error(err, "Failed to check clusters for garbage collection")
+			}
+		}
+	}
+}
+
+// sweepDue runs a sweep on every cluster whose interval has elapsed.
+func (s *Sweeper) sweepDue(now time.Time) error {
+	clusters := &v1alpha1.VDIClusterList{}
+	if err := s.client.List(context.TODO(), clusters); err != nil {
+		return err
+	}
+	for i := range clusters.Items {
+		cluster := &clusters.Items[i]
+		if !cluster.GCEnabled() {
+			continue
+		}
+		if last, ok := s.lastRun[cluster.GetName()]; ok && now.Sub(last) < cluster.GetGCInterval() {
+			continue
+		}
+		s.lastRun[cluster.GetName()] = now
+		report := Sweep(s.client, s.reader, cluster, cluster.GCDryRun())
+		if err := WriteReport(s.client, cluster, report); err != nil {
+			gcLogger.Error(err, "Failed to write garbage collection report", "Cluster", cluster.GetName())
+		}
+	}
+	return nil
+}
+
+// Sweep scans the given cluster for orphaned resources and deletes them unless
+// dryRun is true. Each orphan is checked again with the reader before it is reported,
+// so it should not be served from a cache. Errors are recorded in the returned report.
+func Sweep(c client.Client, r client.Reader, cluster *v1alpha1.VDICluster, dryRun bool) *v1.GCReport {
+	report := &v1.GCReport{
+		Timestamp: time.Now(),
+		DryRun:    dryRun,
+		Resources: make([]*v1.OrphanedResource, 0),
+	}
+	orphans, err := Scan(c, cluster)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		recordMetrics(cluster.GetName(), report)
+		return report
+	}
+	for _, o := range orphans {
+		reqLogger := gcLogger.WithValues("Cluster", cluster.GetName(), "Kind", o.Kind, "Name", o.Name, "Namespace", o.Namespace)
+		orphaned, err := stillOrphaned(r, cluster, o)
+		if err != nil {
+			reqLogger.Error(err, "Failed to check the owner of orphaned resource")
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		if !orphaned {
+			reqLogger.Info("Resource is no longer orphaned, skipping")
+			continue
+		}
+		reqLogger.Info("Found orphaned resource", "Reason", o.Reason)
+		if !dryRun {
+			if err := deleteOrphan(c, cluster, o); err != nil {
+				reqLogger.Error(err, "Failed to delete orphaned resource")
+				report.Errors = append(report.Errors, err.Error())
+			} else {
+				o.Deleted = true
+			}
+		}
+		report.Resources = append(report.Resources, o.OrphanedResource)
+	}
+	recordMetrics(cluster.GetName(), report)
+	return report
+}
+
+// deleteOrphan deletes the given orphan. For userdata volumes the entry in the
+// volume map is also removed.
+func deleteOrphan(c client.Client, cluster *v1alpha1.VDICluster, o *orphan) error {
+	if err := c.Delete(context.TODO(), o.obj); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if _, ok := o.obj.(*corev1.PersistentVolume); !ok {
+		return nil
+	}
+	volMapCM := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), cluster.GetUserdataVolumeMapName(), volMapCM); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	for user, pvName := range volMapCM.Data {
+		if pvName == o.Name {
+			delete(volMapCM.Data, user)
+		}
+	}
+	return c.Update(context.TODO(), volMapCM)
+}
+
+// WriteReport stores the given report in the report configmap for the cluster.
+func WriteReport(c client.Client, cluster *v1alpha1.VDICluster, report *v1.GCReport) error {
+	out, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	nn := cluster.GetGCReportName()
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), nn, cm); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            nn.Name,
+				Namespace:       nn.Namespace,
+				Labels:          cluster.GetComponentLabels("gc-report"),
+				OwnerReferences: cluster.OwnerReferences(),
+			},
+			Data: map[string]string{v1.GCReportKey: string(out)},
+		}
+		return c.Create(context.TODO(), cm)
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[v1.GCReportKey] = string(out)
+	return c.Update(context.TODO(), cm)
+}
@@ -0,0 +1,337 @@
+package gc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis"
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestClient(t *testing.T, objs ...runtime.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	return fake.NewFakeClientWithScheme(scheme, objs...)
+}
+
+func newTestCluster() *v1alpha1.VDICluster {
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	return cluster
+}
+
+func newTestTemplate() *v1alpha1.DesktopTemplate {
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "test"
+	return tmpl
+}
+
+func newDesktopMeta(cluster *v1alpha1.VDICluster, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		Labels: map[string]string{
+			v1.VDIClusterLabel:  cluster.GetName(),
+			v1.ComponentLabel:   "desktop",
+			v1.DesktopNameLabel: name,
+		},
+	}
+}
+
+func TestSweep(t *testing.T) {
+	cluster := newTestCluster()
+	desktop := &v1alpha1.Desktop{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default"},
+		Spec:       v1alpha1.DesktopSpec{VDICluster: cluster.GetName(), Template: "test"},
+	}
+	c := newTestClient(t,
+		cluster,
+		newTestTemplate(),
+		desktop,
+		&corev1.Service{ObjectMeta: newDesktopMeta(cluster, "running")},
+		&corev1.Secret{ObjectMeta: newDesktopMeta(cluster, "running")},
+		&corev1.Service{ObjectMeta: newDesktopMeta(cluster, "deleted")},
+		&corev1.Secret{ObjectMeta: newDesktopMeta(cluster, "deleted")},
+	)
+
+	report := Sweep(c, c, cluster, true)
+	if len(report.Errors) != 0 {
+		t.Fatal("Expected no errors, got:", report.Errors)
+	}
+	if len(report.Resources) != 2 {
+		t.Fatalf("Expected 2 orphaned resources, got %d", len(report.Resources))
+	}
+	for _, res := range report.Resources {
+		if res.Name != "deleted" {
+			t.Error("Expected only resources for the deleted desktop, got", res.Name)
+		}
+		if res.Deleted {
+			t.Error("Resources should not be deleted during a dry run")
+		}
+	}
+	nn := types.NamespacedName{Name: "deleted", Namespace: "default"}
+	if err := c.Get(context.TODO(), nn, &corev1.Service{}); err != nil {
+		t.Error("Expected service to still exist after dry run, got:", err)
+	}
+
+	report = Sweep(c, c, cluster, false)
+	if len(report.Resources) != 2 {
+		t.Fatalf("Expected 2 orphaned resources, got %d", len(report.Resources))
+	}
+	for _, res := range report.Resources {
+		if !res.Deleted {
+			t.Error("Expected resource to be deleted:", res.Kind, res.Name)
+		}
+	}
+	if err := c.Get(context.TODO(), nn, &corev1.Service{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected service to be deleted, got:", err)
+	}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "running", Namespace: "default"}, &corev1.Service{}); err != nil {
+		t.Error("Expected service for running desktop to still exist, got:", err)
+	}
+
+	if err := WriteReport(c, cluster, report); err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), cluster.GetGCReportName(), cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Data[v1.GCReportKey]; !ok {
+		t.Error("Expected report to be written to configmap")
+	}
+}
+
+func TestSweepUserdataRetention(t *testing.T) {
+	cluster := newTestCluster()
+	cluster.Spec.UserDataSpec = &corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+	}
+	cluster.Spec.UserDataPolicy = &v1alpha1.UserDataPolicy{
+		// keep the test from needing an auth provider
+		ReclaimPolicy:   v1alpha1.UserDataReclaimRetain,
+		RetentionPeriod: "24h",
+	}
+	now := time.Now()
+	newPV := func(name string, lastUsed *time.Time) *corev1.PersistentVolume {
+		pv := &corev1.PersistentVolume{}
+		pv.Name = name
+		if lastUsed != nil {
+			pv.Annotations = map[string]string{v1.UserdataLastUsedAnnotation: lastUsed.Format(time.RFC3339)}
+		}
+		return pv
+	}
+	stale := now.Add(-48 * time.Hour)
+	recent := now.Add(-time.Hour)
+	volMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cluster.GetUserdataVolumeMapName().Name,
+			Namespace: cluster.GetUserdataVolumeMapName().Namespace,
+		},
+		Data: map[string]string{
+			"stale-user":   "pv-stale",
+			"recent-user":  "pv-recent",
+			"active-user":  "pv-active",
+			"unknown-user": "pv-unknown",
+			"missing-user": "pv-missing",
+		},
+	}
+	desktop := &v1alpha1.Desktop{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default"},
+		Spec:       v1alpha1.DesktopSpec{VDICluster: cluster.GetName(), Template: "test", User: "active-user"},
+	}
+	c := newTestClient(t,
+		cluster,
+		newTestTemplate(),
+		desktop,
+		volMap,
+		newPV("pv-stale", &stale),
+		newPV("pv-recent", &recent),
+		newPV("pv-active", &stale),
+		newPV("pv-unknown", nil),
+	)
+
+	report := Sweep(c, c, cluster, false)
+	if len(report.Errors) != 0 {
+		t.Fatal("Expected no errors, got:", report.Errors)
+	}
+	if len(report.Resources) != 1 || report.Resources[0].Name != "pv-stale" || !report.Resources[0].Deleted {
+		t.Fatalf("Expected only the stale volume to be deleted, got: %+v", report.Resources)
+	}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "pv-active"}, &corev1.PersistentVolume{}); err != nil {
+		t.Error("Expected volume for user with a desktop to still exist, got:", err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), cluster.GetUserdataVolumeMapName(), cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Data["stale-user"]; ok {
+		t.Error("Expected the stale user to be removed from the volume map")
+	}
+	if _, ok := cm.Data["recent-user"]; !ok {
+		t.Error("Expected the recent user to remain in the volume map")
+	}
+}
+
+func TestSweepPodsAndDesktops(t *testing.T) {
+	cluster := newTestCluster()
+	newDesktop := func(name, template string) *v1alpha1.Desktop {
+		return &v1alpha1.Desktop{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1alpha1.DesktopSpec{VDICluster: cluster.GetName(), Template: template},
+		}
+	}
+	c := newTestClient(t,
+		cluster,
+		newTestTemplate(),
+		newDesktop("running", "test"),
+		newDesktop("starting", "test"),
+		newDesktop("stranded", "deleted-template"),
+		newDesktop("still-running", "deleted-template"),
+		&corev1.Pod{ObjectMeta: newDesktopMeta(cluster, "running")},
+		&corev1.Pod{ObjectMeta: newDesktopMeta(cluster, "still-running")},
+		&corev1.Pod{ObjectMeta: newDesktopMeta(cluster, "deleted")},
+	)
+
+	report := Sweep(c, c, cluster, false)
+	if len(report.Errors) != 0 {
+		t.Fatal("Expected no errors, got:", report.Errors)
+	}
+	found := make(map[string]string)
+	for _, res := range report.Resources {
+		if !res.Deleted {
+			t.Error("Expected resource to be deleted:", res.Kind, res.Name)
+		}
+		found[res.Kind] = res.Name
+	}
+	if len(found) != 2 || found["Pod"] != "deleted" || found["Desktop"] != "stranded" {
+		t.Fatalf("Expected the orphaned pod and stranded desktop, got: %v", found)
+	}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "stranded", Namespace: "default"}, &v1alpha1.Desktop{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected stranded desktop to be deleted, got:", err)
+	}
+	for _, name := range []string{"running", "starting", "still-running"} {
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "default"}, &v1alpha1.Desktop{}); err != nil {
+			t.Errorf("Expected desktop %s to still exist, got: %s", name, err)
+		}
+	}
+
+	if val := testutil.ToFloat64(orphanedResources.WithLabelValues(cluster.GetName(), "Pod")); val != 1 {
+		t.Error("Expected orphaned pod gauge to be 1, got:", val)
+	}
+	if val := testutil.ToFloat64(deletedResourcesTotal.WithLabelValues(cluster.GetName(), "Desktop")); val != 1 {
+		t.Error("Expected deleted desktop counter to be 1, got:", val)
+	}
+
+	// gauges are reset once the orphans are gone
+	Sweep(c, c, cluster, false)
+	if val := testutil.ToFloat64(orphanedResources.WithLabelValues(cluster.GetName(), "Pod")); val != 0 {
+		t.Error("Expected orphaned pod gauge to be reset, got:", val)
+	}
+}
+
+// createAfterListClient creates an object right after the first time objects of
+// the same kind as list are listed, like one created while a scan is running.
+type createAfterListClient struct {
+	client.Client
+	list runtime.Object
+	obj  runtime.Object
+}
+
+func (c *createAfterListClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	if c.obj != nil && reflect.TypeOf(list) == reflect.TypeOf(c.list) {
+		obj := c.obj
+		c.obj = nil
+		return c.Client.Create(ctx, obj)
+	}
+	return nil
+}
+
+func TestSweepInFlightDesktops(t *testing.T) {
+	cluster := newTestCluster()
+	late := &v1alpha1.Desktop{
+		ObjectMeta: metav1.ObjectMeta{Name: "late", Namespace: "default"},
+		Spec:       v1alpha1.DesktopSpec{VDICluster: cluster.GetName(), Template: "test"},
+	}
+	recent := newDesktopMeta(cluster, "recent")
+	recent.CreationTimestamp = metav1.NewTime(time.Now())
+	base := newTestClient(t,
+		cluster,
+		newTestTemplate(),
+		&corev1.Pod{ObjectMeta: newDesktopMeta(cluster, "late")},
+		&corev1.Service{ObjectMeta: newDesktopMeta(cluster, "late")},
+		&corev1.Secret{ObjectMeta: newDesktopMeta(cluster, "late")},
+		&corev1.Service{ObjectMeta: recent},
+	)
+	c := &createAfterListClient{Client: base, list: &v1alpha1.DesktopList{}, obj: late}
+
+	report := Sweep(c, base, cluster, false)
+	if len(report.Errors) != 0 {
+		t.Fatal("Expected no errors, got:", report.Errors)
+	}
+	if len(report.Resources) != 0 {
+		t.Fatalf("Expected no orphaned resources, got: %+v", report.Resources)
+	}
+	nn := types.NamespacedName{Name: "late", Namespace: "default"}
+	for _, obj := range []runtime.Object{&corev1.Pod{}, &corev1.Service{}, &corev1.Secret{}} {
+		if err := base.Get(context.TODO(), nn, obj); err != nil {
+			t.Errorf("Expected %T for desktop created during the scan to still exist, got: %s", obj, err)
+		}
+	}
+	if err := base.Get(context.TODO(), types.NamespacedName{Name: "recent", Namespace: "default"}, &corev1.Service{}); err != nil {
+		t.Error("Expected service created within the grace period to still exist, got:", err)
+	}
+
+	// once the desktop is gone and the grace period has passed they are collected
+	if err := base.Delete(context.TODO(), late); err != nil {
+		t.Fatal(err)
+	}
+	report = Sweep(base, base, cluster, false)
+	if len(report.Resources) != 3 {
+		t.Fatalf("Expected 3 orphaned resources, got: %+v", report.Resources)
+	}
+}
+
+func TestSweepInFlightTemplates(t *testing.T) {
+	cluster := newTestCluster()
+	newDesktop := func(name string) *v1alpha1.Desktop {
+		return &v1alpha1.Desktop{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1alpha1.DesktopSpec{VDICluster: cluster.GetName(), Template: "test"},
+		}
+	}
+	starting := newDesktop("starting")
+	starting.CreationTimestamp = metav1.NewTime(time.Now())
+	base := newTestClient(t, cluster, newDesktop("waiting"), starting)
+	// the template is created again while the scan is running
+	c := &createAfterListClient{Client: base, list: &v1alpha1.DesktopTemplateList{}, obj: newTestTemplate()}
+
+	report := Sweep(c, base, cluster, false)
+	if len(report.Errors) != 0 {
+		t.Fatal("Expected no errors, got:", report.Errors)
+	}
+	if len(report.Resources) != 0 {
+		t.Fatalf("Expected no stranded desktops, got: %+v", report.Resources)
+	}
+	for _, name := range []string{"waiting", "starting"} {
+		if err := base.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "default"}, &v1alpha1.Desktop{}); err != nil {
+			t.Errorf("Expected desktop %s to still exist, got: %s", name, err)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 
@@ -53,3 +55,17 @@ func (r *Reconciler) reconcileAdminSecret(reqLogger logr.Logger, cluster *v1alph
 	}
 	return string(existingPassw), nil
 }
+
+// reconcileAdminPassword ensures a generated admin password in the secrets backend.
+// This is used instead of a kubernetes secret when the backend is external.
+func (r *Reconciler) reconcileAdminPassword(secretsEngine *secrets.SecretEngine) (password string, err error) {
+	existingPassw, err := secretsEngine.ReadSecret(v1.AdminPasswordSecretKey, false)
+	if err == nil {
+		return string(existingPassw), nil
+	}
+	if !errors.IsSecretNotFoundError(err) {
+		return "", err
+	}
+	passw := common.GeneratePassword(16)
+	return passw, secretsEngine.WriteSecret(v1.AdminPasswordSecretKey, []byte(passw))
+}
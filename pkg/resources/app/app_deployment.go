@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
@@ -13,6 +14,15 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// appShutdownGracePeriod is the extra time given to app pods to shut down after
+// draining connections.
+const appShutdownGracePeriod = 15 * time.Second
+
+var (
+	zeroIntStr = intstr.FromInt(0)
+	oneIntStr  = intstr.FromInt(1)
+)
+
 func newAppDeploymentForCR(instance *v1alpha1.VDICluster) *appsv1.Deployment {
 	containers := []corev1.Container{newAppContainerForCR(instance)}
 	volumes := newAppVolumesForCR(instance)
@@ -39,19 +49,32 @@ func newAppDeploymentForCR(instance *v1alpha1.VDICluster) *appsv1.Deployment {
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: instance.GetAppReplicas(),
+			// Bring up new replicas before old ones start draining connections
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: &zeroIntStr,
+					MaxSurge:       &oneIntStr,
+				},
+			},
 			Selector: &metav1.LabelSelector{
 				MatchLabels: instance.GetComponentLabels("app"),
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: instance.GetComponentLabels("app"),
+					// All other configurations are picked up by the app at runtime
+					Annotations: map[string]string{
+						v1.AppBackendsAnnotation: fmt.Sprintf("auth=%s,secrets=%s", instance.GetAuthBackend(), instance.GetSecretsBackend()),
+					},
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: instance.GetAppName(),
-					SecurityContext:    instance.GetAppSecurityContext(),
-					Volumes:            volumes,
-					ImagePullSecrets:   instance.GetPullSecrets(),
-					Containers:         containers,
+					ServiceAccountName:            instance.GetAppName(),
+					TerminationGracePeriodSeconds: common.Int64Ptr(int64((instance.GetAppDrainTimeout() + appShutdownGracePeriod).Seconds())),
+					SecurityContext:               instance.GetAppSecurityContext(),
+					Volumes:                       volumes,
+					ImagePullSecrets:              instance.GetPullSecrets(),
+					Containers:                    containers,
 				},
 			},
 		},
@@ -80,16 +103,12 @@ func newAppVolumesForCR(instance *v1alpha1.VDICluster) []corev1.Volume {
 }
 
 func newAppContainerForCR(instance *v1alpha1.VDICluster) corev1.Container {
-	args := []string{"--vdi-cluster", instance.GetName()}
-	if instance.EnableCORS() {
-		args = append(args, "--enable-cors")
-	}
 	return corev1.Container{
 		Name:            "app",
 		Image:           instance.GetAppImage(),
 		ImagePullPolicy: instance.GetAppPullPolicy(),
 		Resources:       instance.GetAppResources(),
-		Args:            args,
+		Args:            []string{"--vdi-cluster", instance.GetName(), "--drain-timeout", instance.GetAppDrainTimeout().String()},
 		Env: []corev1.EnvVar{
 			{
 				Name: "POD_NAME",
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+)
+
+func TestNewAppDeploymentForCR(t *testing.T) {
+	cluster := newCluster(t)
+	deployment := newAppDeploymentForCR(cluster)
+
+	expected := "auth=local,secrets=k8s"
+	if got := deployment.Spec.Template.Annotations[v1.AppBackendsAnnotation]; got != expected {
+		t.Errorf("Expected backends annotation %q, got %q", expected, got)
+	}
+
+	// runtime settings should not change the deployment spec
+	cluster.Spec.App = &v1alpha1.AppConfig{CORSEnabled: true, AuditLog: true}
+	if !reflect.DeepEqual(deployment.Spec, newAppDeploymentForCR(cluster).Spec) {
+		t.Error("Expected runtime configuration changes to leave the deployment untouched")
+	}
+
+	// backend swaps should
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{
+		LDAPAuth: &v1alpha1.LDAPConfig{URL: "ldap://ldap.example.com:389"},
+	}
+	expected = "auth=ldap,secrets=k8s"
+	if got := newAppDeploymentForCR(cluster).Spec.Template.Annotations[v1.AppBackendsAnnotation]; got != expected {
+		t.Errorf("Expected backends annotation %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/apiutil"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+)
+
+// reconcileJWTSigningKeys generates and rotates the keys for signing access tokens.
+// When a key is replaced it is retired, and it keeps verifying tokens until the
+// retention period has passed. The unix timestamp of when the keys next need to be
+// rotated or pruned is returned, or zero if there is nothing to do.
+func reconcileJWTSigningKeys(reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, instance *v1alpha1.VDICluster) (int64, error) {
+	keys := make([]*v1.JWTSigningKey, 0)
+	var changed bool
+	data, err := secretsEngine.ReadSecret(v1.JWTSigningKeysSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return 0, err
+		}
+		changed = true
+	} else if err := json.Unmarshal(data, &keys); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	alg := instance.GetTokenSigningAlgorithm()
+	interval := instance.GetTokenKeyRotationInterval()
+	retention := instance.GetTokenKeyRetention()
+
+	var active *v1.JWTSigningKey
+	for _, key := range keys {
+		if !key.IsRetired() && (active == nil || key.CreatedAt > active.CreatedAt) {
+			active = key
+		}
+	}
+
+	useKeys := instance.IsUsingTokenSigningKeys()
+	rotate := useKeys && (active == nil || active.Algorithm != string(alg) || (interval > 0 && !now.Before(time.Unix(active.CreatedAt, 0).Add(interval))))
+	if active != nil && (!useKeys || rotate) {
+		reqLogger.Info("Retiring token signing key", "KeyID", active.ID)
+		active.RetiredAt = now.Unix()
+		active = nil
+		changed = true
+	}
+	if rotate {
+		active, err = apiutil.NewJWTSigningKey(alg)
+		if err != nil {
+			return 0, err
+		}
+		reqLogger.Info("Generated new token signing key", "KeyID", active.ID, "Algorithm", active.Algorithm)
+		keys = append(keys, active)
+		changed = true
+	}
+
+	var nextEvent int64
+	setNextEvent := func(t time.Time) {
+		if nextEvent == 0 || t.Unix() < nextEvent {
+			nextEvent = t.Unix()
+		}
+	}
+	if active != nil && interval > 0 {
+		setNextEvent(time.Unix(active.CreatedAt, 0).Add(interval))
+	}
+	retained := make([]*v1.JWTSigningKey, 0)
+	for _, key := range keys {
+		if key.IsRetired() {
+			expiry := time.Unix(key.RetiredAt, 0).Add(retention)
+			if !now.Before(expiry) {
+				reqLogger.Info("Removing retired token signing key", "KeyID", key.ID)
+				changed = true
+				continue
+			}
+			setNextEvent(expiry)
+		}
+		retained = append(retained, key)
+	}
+
+	if !changed {
+		return nextEvent, nil
+	}
+	out, err := json.Marshal(retained)
+	if err != nil {
+		return 0, err
+	}
+	return nextEvent, secretsEngine.WriteSecret(v1.JWTSigningKeysSecretKey, out)
+}
@@ -18,7 +18,7 @@ var appRules = []rbacv1.PolicyRule{
 	},
 	{
 		APIGroups: []string{""},
-		Resources: []string{"pods", "pods/log", "services", "namespaces", "endpoints"},
+		Resources: []string{"pods", "pods/log", "services", "namespaces", "endpoints", "persistentvolumeclaims"},
 		Verbs:     verbsReadOnly,
 	},
 	{
@@ -26,6 +26,26 @@ var appRules = []rbacv1.PolicyRule{
 		Resources: []string{"configmaps", "secrets"},
 		Verbs:     []string{rbacv1.VerbAll},
 	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"nodes"},
+		Verbs:     []string{"get", "list", "update", "patch"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"nodes/proxy"},
+		Verbs:     []string{"get"},
+	},
+	{
+		APIGroups: []string{"snapshot.storage.k8s.io"},
+		Resources: []string{"volumesnapshots"},
+		Verbs:     []string{"get", "list", "create", "delete"},
+	},
+	{
+		APIGroups: []string{"apps"},
+		Resources: []string{"controllerrevisions"},
+		Verbs:     []string{"get", "list", "watch", "create", "delete"},
+	},
 }
 
 func newAppClusterRoleForCR(instance *v1alpha1.VDICluster) *rbacv1.ClusterRole {
@@ -47,7 +67,7 @@ func newAppServiceAccountForCR(instance *v1alpha1.VDICluster) *corev1.ServiceAcc
 			Name:            instance.GetAppName(),
 			Namespace:       instance.GetCoreNamespace(),
 			Labels:          instance.GetComponentLabels("app"),
-			Annotations:     instance.GetAnnotations(),
+			Annotations:     instance.GetServiceAccountAnnotations(),
 			OwnerReferences: instance.OwnerReferences(),
 		},
 	}
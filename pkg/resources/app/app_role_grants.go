@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	"github.com/go-logr/logr"
+)
+
+// reconcileRoleGrants removes expired grants from the VDIRoles for this cluster.
+// The unix timestamp of when the next remaining grant expires is returned, or zero
+// if there are none.
+func (f *Reconciler) reconcileRoleGrants(reqLogger logr.Logger, instance *v1alpha1.VDICluster) (int64, error) {
+	roles, err := instance.GetRoles(f.client)
+	if err != nil {
+		return 0, err
+	}
+	var nextExpiry int64
+	for idx := range roles {
+		role := &roles[idx]
+		pruned, roleExpiry, err := role.PruneExpiredGrants()
+		if err != nil {
+			reqLogger.Error(err, "Could not parse the grants for role, skipping", "Role", role.GetName())
+			continue
+		}
+		if roleExpiry > 0 && (nextExpiry == 0 || roleExpiry < nextExpiry) {
+			nextExpiry = roleExpiry
+		}
+		if !pruned {
+			continue
+		}
+		reqLogger.Info("Removing expired grants from role", "Role", role.GetName())
+		if err := f.client.Update(context.TODO(), role); err != nil {
+			return 0, err
+		}
+	}
+	return nextExpiry, nil
+}
@@ -1,7 +1,9 @@
 package app
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
@@ -15,6 +17,7 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -36,17 +39,11 @@ func New(c client.Client, s *runtime.Scheme) *Reconciler {
 
 // Reconcile reconciles all the core-components of a kVDI cluster.
 func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.VDICluster) error {
-	// Generate the admin secret
-	reqLogger.Info("Reconciling admin password secret")
-	adminPass, err := f.reconcileAdminSecret(reqLogger, instance)
-	if err != nil {
-		return err
-	}
-
 	// Set up a temporary connection to the secrets engine
 	reqLogger.Info("Setting up a temporary connection to the cluster secrets backend")
 	secretsEngine := secrets.GetSecretEngine(instance)
 	if err := secretsEngine.Setup(f.client, instance); err != nil {
+		instance.SetCondition(v1alpha1.VDIClusterSecretsBackendReady, corev1.ConditionFalse, "SetupFailed", err.Error())
 		return err
 	}
 	defer func() {
@@ -54,6 +51,25 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.VDIClus
 			reqLogger.Error(err, "Error cleaning up secrets engine")
 		}
 	}()
+	if err := secretsEngine.CheckHealth(); err != nil {
+		instance.SetCondition(v1alpha1.VDIClusterSecretsBackendReady, corev1.ConditionFalse, "Unreachable", err.Error())
+		return err
+	}
+	instance.SetCondition(v1alpha1.VDIClusterSecretsBackendReady, corev1.ConditionTrue, "Connected", "The secrets backend is reachable")
+
+	// Generate the admin password. When using an external secrets backend it is
+	// kept there instead of in a kubernetes secret.
+	reqLogger.Info("Reconciling admin password secret")
+	var adminPass string
+	var err error
+	if instance.GetSecretsBackend() != v1alpha1.SecretsBackendK8s {
+		adminPass, err = f.reconcileAdminPassword(secretsEngine)
+	} else {
+		adminPass, err = f.reconcileAdminSecret(reqLogger, instance)
+	}
+	if err != nil {
+		return err
+	}
 
 	// Reconcile a secret for generating JWT tokens
 	reqLogger.Info("Reconciling JWT secrets")
@@ -67,6 +83,24 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.VDIClus
 		}
 	}
 
+	reqLogger.Info("Reconciling JWT signing keys")
+	nextKeyRotation, err := reconcileJWTSigningKeys(reqLogger, secretsEngine, instance)
+	if err != nil {
+		return err
+	}
+
+	// Reconcile a secret for signing template bundles
+	reqLogger.Info("Reconciling template bundle signing key")
+	if _, err := secretsEngine.ReadSecret(v1.TemplateBundleSecretKey, false); err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return err
+		}
+		bundleKey := common.GeneratePassword(32)
+		if err := secretsEngine.WriteSecret(v1.TemplateBundleSecretKey, []byte(bundleKey)); err != nil {
+			return err
+		}
+	}
+
 	reqLogger.Info("Reconciling built-in VDIRoles")
 	// Reconcile the built-in roles.
 	if err := reconcile.VDIRole(reqLogger, f.client, instance.GetAdminRole()); err != nil {
@@ -77,12 +111,20 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.VDIClus
 		return err
 	}
 
+	reqLogger.Info("Reconciling temporary role grants")
+	nextGrantExpiry, err := f.reconcileRoleGrants(reqLogger, instance)
+	if err != nil {
+		return err
+	}
+
 	// reconcile any resources needed for the auth provider
 	reqLogger.Info("Reconciling required resources for the configured authentication provider")
 	authProvider := auth.GetAuthProvider(instance, secretsEngine)
 	if err := authProvider.Reconcile(reqLogger, f.client, instance, adminPass); err != nil {
+		instance.SetCondition(v1alpha1.VDIClusterAuthProviderReady, corev1.ConditionFalse, "ReconcileFailed", err.Error())
 		return err
 	}
+	instance.SetCondition(v1alpha1.VDIClusterAuthProviderReady, corev1.ConditionTrue, "Reconciled", fmt.Sprintf("The resources for %s authentication are ready", instance.GetAuthBackend()))
 	if err := authProvider.Close(); err != nil {
 		reqLogger.Error(err, "Failed to close auth provider cleanly")
 	}
@@ -103,8 +145,10 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.VDIClus
 	// for the app deployment.
 	reqLogger.Info("Reconciling PKI resources for mTLS")
 	if err := pki.New(f.client, instance, secretsEngine).Reconcile(reqLogger); err != nil {
+		instance.SetCondition(v1alpha1.VDIClusterPKIReady, corev1.ConditionFalse, "ReconcileFailed", err.Error())
 		return err
 	}
+	instance.SetCondition(v1alpha1.VDIClusterPKIReady, corev1.ConditionTrue, "Reconciled", "The CA and mTLS certificates are ready")
 
 	if instance.RunAppGrafanaSidecar() {
 		// we need a configmap for grafana first
@@ -117,8 +161,14 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.VDIClus
 	// App deployment and service
 	reqLogger.Info("Reconciling app deployment and services")
 	if err := reconcile.Deployment(reqLogger, f.client, newAppDeploymentForCR(instance), true); err != nil {
+		if _, ok := errors.IsRequeueError(err); ok {
+			instance.SetCondition(v1alpha1.VDIClusterAppReady, corev1.ConditionFalse, "DeploymentNotReady", err.Error())
+		} else {
+			instance.SetCondition(v1alpha1.VDIClusterAppReady, corev1.ConditionFalse, "ReconcileFailed", err.Error())
+		}
 		return err
 	}
+	instance.SetCondition(v1alpha1.VDIClusterAppReady, corev1.ConditionTrue, "DeploymentReady", "All replicas of the app are ready")
 	if err := reconcile.Service(reqLogger, f.client, newAppServiceForCR(instance)); err != nil {
 		return err
 	}
@@ -142,7 +192,18 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.VDIClus
 	if instance.CreateAppServiceMonitor() {
 		reqLogger.Info("Reconciling ServiceMonitor for app metrics")
 		err = reconcile.ServiceMonitor(reqLogger, f.client, newAppServiceMonitorForCR(instance))
-		return ignoreNoPromOperator(reqLogger, err)
+		if err := ignoreNoPromOperator(reqLogger, err); err != nil {
+			return err
+		}
+	}
+
+	// Come back when the next role grant expires to clean it up, or when the JWT
+	// signing keys need to be rotated
+	if nextKeyRotation > 0 && (nextGrantExpiry == 0 || nextKeyRotation < nextGrantExpiry) {
+		return errors.NewRequeueError("Waiting for the JWT signing keys to be rotated", int(time.Until(time.Unix(nextKeyRotation, 0)).Seconds())+1)
+	}
+	if nextGrantExpiry > 0 {
+		return errors.NewRequeueError("Waiting for the next role grant to expire", int(time.Until(time.Unix(nextGrantExpiry, 0)).Seconds())+1)
 	}
 
 	return nil
@@ -2,11 +2,15 @@ package app
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis"
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 
 	promv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
@@ -61,6 +65,15 @@ func TestReconcile(t *testing.T) {
 	} else if !strings.Contains(qerr.Error(), "deployment with wait") {
 		t.Error("Expected error from waiting for deployment, got:", err)
 	}
+	for _, condType := range v1alpha1.VDIClusterConditionTypes {
+		cond := cluster.GetCondition(condType)
+		if cond == nil {
+			t.Fatal("Expected condition to be set:", condType)
+		}
+		if expected := condType != v1alpha1.VDIClusterAppReady; (cond.Status == corev1.ConditionTrue) != expected {
+			t.Error("Unexpected condition status, got:", cond)
+		}
+	}
 
 	// should keep happening until the deployment is ready
 	if err := r.Reconcile(testLogger, cluster); err == nil {
@@ -87,4 +100,133 @@ func TestReconcile(t *testing.T) {
 	if err := r.Reconcile(testLogger, cluster); err != nil {
 		t.Error("Expected reconcile to complete successfully")
 	}
+	if !cluster.ConditionsReady() {
+		t.Error("Expected all conditions to be true, got:", cluster.Status.Conditions)
+	}
+}
+
+// TestReconcileRoleGrants tests removing expired grants from roles.
+func TestReconcileRoleGrants(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	now := time.Now().Unix()
+	role := &v1alpha1.VDIRole{}
+	role.Name = "oncall"
+	role.Labels = map[string]string{v1.RoleClusterRefLabel: cluster.GetName()}
+	if err := role.SetGrants([]*v1.RoleGrant{
+		{User: "active", ExpiresAt: now + 60},
+		{User: "expired", ExpiresAt: now - 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Create(context.TODO(), role); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := r.reconcileRoleGrants(testLogger, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != now+60 {
+		t.Error("Expected the next expiry to be the remaining grant, got:", next)
+	}
+	updated := &v1alpha1.VDIRole{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: role.Name}, updated); err != nil {
+		t.Fatal(err)
+	}
+	grants, err := updated.GetGrants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 1 || grants[0].User != "active" {
+		t.Error("Expected only the active grant to remain, got:", grants)
+	}
+}
+
+func TestReconcileJWTSigningKeys(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	secretsEngine := secrets.GetSecretEngine(cluster)
+	if err := secretsEngine.Setup(r.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+	readKeys := func() []*v1.JWTSigningKey {
+		t.Helper()
+		data, err := secretsEngine.ReadSecret(v1.JWTSigningKeysSecretKey, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := make([]*v1.JWTSigningKey, 0)
+		if err := json.Unmarshal(data, &keys); err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+
+	// no keys are generated when signing with the secret
+	next, err := reconcileJWTSigningKeys(testLogger, secretsEngine, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := readKeys(); len(keys) != 0 || next != 0 {
+		t.Error("Expected no keys and nothing to do, got:", keys, next)
+	}
+
+	// a key is generated when switching algorithms
+	cluster.Spec.Auth = &v1alpha1.AuthConfig{TokenSigning: &v1alpha1.TokenSigningConfig{Algorithm: v1alpha1.TokenSigningES256, RotationInterval: "1h"}}
+	now := time.Now()
+	if next, err = reconcileJWTSigningKeys(testLogger, secretsEngine, cluster); err != nil {
+		t.Fatal(err)
+	}
+	keys := readKeys()
+	if len(keys) != 1 || keys[0].Algorithm != "ES256" || keys[0].IsRetired() {
+		t.Fatal("Expected a single active ES256 key, got:", keys)
+	}
+	if next < now.Add(time.Hour).Unix() || next > time.Now().Add(time.Hour).Unix() {
+		t.Error("Expected the next event to be the rotation, got:", next)
+	}
+
+	// nothing changes until the key is due for rotation
+	if _, err = reconcileJWTSigningKeys(testLogger, secretsEngine, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if updated := readKeys(); len(updated) != 1 || updated[0].ID != keys[0].ID {
+		t.Error("Expected the key to be kept, got:", updated)
+	}
+
+	// keys past the rotation interval are retired and replaced, and retired keys
+	// past the retention period are removed
+	keys[0].CreatedAt = now.Add(-2 * time.Hour).Unix()
+	expired := &v1.JWTSigningKey{ID: "expired", Algorithm: "ES256", CreatedAt: keys[0].CreatedAt, RetiredAt: now.Add(-time.Hour).Unix()}
+	out, err := json.Marshal(append(keys, expired))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := secretsEngine.WriteSecret(v1.JWTSigningKeysSecretKey, out); err != nil {
+		t.Fatal(err)
+	}
+	if next, err = reconcileJWTSigningKeys(testLogger, secretsEngine, cluster); err != nil {
+		t.Fatal(err)
+	}
+	rotated := readKeys()
+	if len(rotated) != 2 || rotated[0].ID != keys[0].ID || !rotated[0].IsRetired() || rotated[1].IsRetired() {
+		t.Fatal("Expected the old key to be retired and a new one added, got:", rotated)
+	}
+	if next < now.Add(time.Hour).Unix() || next > time.Now().Add(time.Hour).Unix() {
+		t.Error("Expected the next event to be an hour from now, got:", next)
+	}
+
+	// all keys are retired when switching back to the secret
+	cluster.Spec.Auth = nil
+	if next, err = reconcileJWTSigningKeys(testLogger, secretsEngine, cluster); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range readKeys() {
+		if !key.IsRetired() {
+			t.Error("Expected all keys to be retired, got:", key)
+		}
+	}
+	if next == 0 {
+		t.Error("Expected the retired keys to be pruned later, got 0")
+	}
 }
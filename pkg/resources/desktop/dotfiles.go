@@ -0,0 +1,66 @@
+package desktop
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reconcileDotfiles ensures the secret holding the user's dotfiles repository
+// configuration for the dotfiles init container. The secret is created even if the
+// user has since removed their configuration, so the pod can still start.
+func (f *Reconciler) reconcileDotfiles(reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop) error {
+	dotfiles, err := getDotfiles(secretsEngine, instance.GetUser())
+	if err != nil {
+		return err
+	}
+	return reconcile.Secret(reqLogger, f.client, newDotfilesSecretForCR(cluster, instance, dotfiles))
+}
+
+// getDotfiles returns the dotfiles configuration for the given user. An empty
+// configuration is returned if the user has none.
+func getDotfiles(secretsEngine *secrets.SecretEngine, user string) (*v1.DotfilesConfig, error) {
+	dotfiles := &v1.DotfilesConfig{}
+	users, err := secretsEngine.ReadSecretMap(v1.DotfilesSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return dotfiles, nil
+		}
+		return nil, err
+	}
+	if data, ok := users[user]; ok {
+		if err := json.Unmarshal(data, dotfiles); err != nil {
+			return nil, err
+		}
+	}
+	return dotfiles, nil
+}
+
+func newDotfilesSecretForCR(cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop, dotfiles *v1.DotfilesConfig) *corev1.Secret {
+	data := map[string][]byte{
+		"DOTFILES_REPO":   []byte(dotfiles.Repository),
+		"DOTFILES_BRANCH": []byte(dotfiles.Branch),
+		"DOTFILES_DEPTH":  []byte(""),
+	}
+	if dotfiles.Depth > 0 {
+		data["DOTFILES_DEPTH"] = []byte(fmt.Sprintf("%d", dotfiles.Depth))
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetDotfilesSecretName(),
+			Namespace:       instance.GetNamespace(),
+			Labels:          cluster.GetDesktopLabels(instance),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Data: data,
+	}
+}
@@ -0,0 +1,82 @@
+package desktop
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileDotfiles(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	tmpl := newTemplate(t)
+
+	// desktops without the annotation should not have an init container
+	if containers := tmpl.GetDesktopInitContainers(cluster, desktop); len(containers) != 0 {
+		t.Error("Expected no init containers, got:", containers)
+	}
+
+	desktop.SetAnnotations(map[string]string{v1.DotfilesAnnotation: "true"})
+	containers := tmpl.GetDesktopInitContainers(cluster, desktop)
+	if len(containers) != 1 {
+		t.Fatal("Expected a dotfiles init container, got:", containers)
+	}
+	if ref := containers[0].EnvFrom[0].SecretRef.Name; ref != desktop.GetDotfilesSecretName() {
+		t.Error("Expected init container to use the dotfiles secret, got:", ref)
+	}
+
+	secretsEngine := secrets.GetSecretEngine(cluster)
+	if err := secretsEngine.Setup(r.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	nn := types.NamespacedName{Name: desktop.GetDotfilesSecretName(), Namespace: desktop.GetNamespace()}
+	getDotfilesSecret := func() *corev1.Secret {
+		t.Helper()
+		secret := &corev1.Secret{}
+		if err := r.client.Get(context.TODO(), nn, secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	// the secret should exist even if the user has no configuration
+	if err := r.reconcileDotfiles(testLogger, secretsEngine, cluster, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if repo := string(getDotfilesSecret().Data["DOTFILES_REPO"]); repo != "" {
+		t.Error("Expected empty repository, got:", repo)
+	}
+
+	data, err := json.Marshal(&v1.DotfilesConfig{
+		Repository: "https://github.com/example/dotfiles",
+		Branch:     "main",
+		Depth:      1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := secretsEngine.WriteSecretMap(v1.DotfilesSecretKey, map[string][]byte{desktop.GetUser(): data}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileDotfiles(testLogger, secretsEngine, cluster, desktop); err != nil {
+		t.Fatal(err)
+	}
+	secret := getDotfilesSecret()
+	if repo := string(secret.Data["DOTFILES_REPO"]); repo != "https://github.com/example/dotfiles" {
+		t.Error("Expected repository to be set, got:", repo)
+	}
+	if branch := string(secret.Data["DOTFILES_BRANCH"]); branch != "main" {
+		t.Error("Expected branch to be set, got:", branch)
+	}
+	if depth := string(secret.Data["DOTFILES_DEPTH"]); depth != "1" {
+		t.Error("Expected depth to be set, got:", depth)
+	}
+}
@@ -0,0 +1,48 @@
+package desktop
+
+import (
+	"context"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileDrain checks if the node the desktop pod is running on is being drained.
+// Before the deadline in the drain notice, the deadline is returned so the desktop
+// can be requeued for it. Once it passes the desktop is either destroyed, or marked
+// as drained, which causes the pod to be recreated on another node.
+func (f *Reconciler) reconcileDrain(reqLogger logr.Logger, instance *v1alpha1.Desktop, pod *corev1.Pod) (time.Time, error) {
+	notice := instance.GetDrainNotice()
+	if notice == nil || pod.Spec.NodeName != notice.Node || instance.Status.DrainedFrom == notice.Node {
+		return time.Time{}, nil
+	}
+
+	if time.Now().Before(notice.Deadline) {
+		return notice.Deadline, nil
+	}
+
+	if notice.Action == v1.DrainActionTerminate {
+		reqLogger.Info("Desktop node is being drained, destroying instance", "Node.Name", notice.Node)
+		if err := f.client.Delete(context.TODO(), instance); err != nil {
+			return time.Time{}, client.IgnoreNotFound(err)
+		}
+		return time.Time{}, errors.NewRequeueError("Desktop has been destroyed for node drain", 1)
+	}
+
+	reqLogger.Info("Desktop node is being drained, relaunching on another node", "Node.Name", notice.Node)
+
+	instance.Status.DrainedFrom = notice.Node
+	instance.Status.Running = false
+	instance.Status.PodPhase = pod.Status.Phase
+	instance.Status.Phase = v1alpha1.DesktopPhaseScheduling
+	if err := f.client.Status().Update(context.TODO(), instance); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, errors.NewRequeueError("Desktop has been drained", 1)
+}
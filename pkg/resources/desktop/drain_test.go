@@ -0,0 +1,90 @@
+package desktop
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func setDrainNotice(t *testing.T, desktop *v1alpha1.Desktop, notice *v1.DrainNotice) {
+	t.Helper()
+	raw, err := json.Marshal(notice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desktop.SetAnnotations(map[string]string{v1.DrainAnnotation: string(raw)})
+}
+
+func TestReconcileDrain(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+
+	pod := newDesktopPodForCR(cluster, newTemplate(t), desktop)
+	pod.Spec.NodeName = "test-node"
+
+	// desktop without a notice should be a no-op
+	if drainAt, err := r.reconcileDrain(testLogger, desktop, pod); err != nil {
+		t.Fatal(err)
+	} else if !drainAt.IsZero() {
+		t.Error("Expected no drain deadline, got:", drainAt)
+	}
+
+	// a pending notice should return the deadline without touching the pod
+	deadline := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+	setDrainNotice(t, desktop, &v1.DrainNotice{Node: "test-node", Action: v1.DrainActionMigrate, Deadline: deadline})
+	if drainAt, err := r.reconcileDrain(testLogger, desktop, pod); err != nil {
+		t.Fatal(err)
+	} else if !drainAt.Equal(deadline) {
+		t.Error("Expected drain deadline to be returned, got:", drainAt)
+	}
+	if newDesktopPodForCR(cluster, newTemplate(t), desktop).Annotations[v1.DrainAnnotation] != "" {
+		t.Error("Expected drain notice to not be applied to the pod")
+	}
+
+	// once the deadline passes the desktop should be drained
+	setDrainNotice(t, desktop, &v1.DrainNotice{Node: "test-node", Action: v1.DrainActionMigrate, Deadline: time.Now().Add(-time.Minute)})
+	if _, err := r.reconcileDrain(testLogger, desktop, pod); err == nil {
+		t.Fatal("Expected requeue error, got nil")
+	} else if _, ok := errors.IsRequeueError(err); !ok {
+		t.Fatal("Expected requeue error, got:", err)
+	}
+
+	found := &v1alpha1.Desktop{}
+	nn := types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}
+	if err := r.client.Get(context.TODO(), nn, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Status.DrainedFrom != "test-node" {
+		t.Error("Expected desktop to be marked as drained, got:", found.Status.DrainedFrom)
+	}
+	pod = newDesktopPodForCR(cluster, newTemplate(t), found)
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil {
+		t.Fatal("Expected drained desktop to have a node affinity")
+	}
+	terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchFields) != 1 || terms[0].MatchFields[0].Values[0] != "test-node" {
+		t.Error("Expected drained desktop to avoid the drained node, got:", terms)
+	}
+
+	// terminating desktops should be deleted at the deadline
+	setDrainNotice(t, found, &v1.DrainNotice{Node: "test-node", Action: v1.DrainActionTerminate, Deadline: time.Now().Add(-time.Minute)})
+	found.Status.DrainedFrom = ""
+	pod.Spec.NodeName = "test-node"
+	if _, err := r.reconcileDrain(testLogger, found, pod); err == nil {
+		t.Fatal("Expected requeue error, got nil")
+	}
+	if err := r.client.Get(context.TODO(), nn, &v1alpha1.Desktop{}); err == nil {
+		t.Error("Expected desktop to be deleted")
+	}
+}
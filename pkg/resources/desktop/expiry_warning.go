@@ -0,0 +1,74 @@
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/email"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+)
+
+// reconcileExpiryWarning emails the user of the desktop when it is about to be
+// destroyed. Before it is time to warn them, the time it will be is returned so the
+// desktop can be requeued for it. The desktop is annotated with the termination
+// time it was warned for, so users are warned again if it changes.
+func (f *Reconciler) reconcileExpiryWarning(reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop) (time.Time, error) {
+	if !cluster.EmailEnabled() || instance.Status.TerminatesAt == nil {
+		return time.Time{}, nil
+	}
+	terminatesAt := instance.Status.TerminatesAt.Time
+	if instance.GetAnnotations()[v1.ExpiryWarningAnnotation] == terminatesAt.UTC().Format(time.RFC3339) {
+		return time.Time{}, nil
+	}
+	warnAt := terminatesAt.Add(-cluster.GetEmailConfig().GetExpiryWarning())
+	if time.Now().Before(warnAt) {
+		return warnAt, nil
+	}
+
+	users, err := secretsEngine.ReadSecretMap(v1.UserEmailsSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	addr, ok := users[instance.GetUser()]
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	reqLogger.Info("Warning user that their desktop will be destroyed soon", "TerminatesAt", terminatesAt)
+	if err := f.sendEmail(cluster, secretsEngine, []string{string(addr)}, email.MessageSessionExpiry, &email.Data{
+		User:    instance.GetUser(),
+		Desktop: fmt.Sprintf("%s/%s", instance.GetNamespace(), instance.GetName()),
+		Time:    terminatesAt,
+		Reason:  string(instance.Status.TerminationReason),
+	}); err != nil {
+		// don't hold up the rest of the reconcile for a failed notification
+		reqLogger.Error(err, "Failed to send desktop expiry warning")
+		return time.Time{}, nil
+	}
+
+	annotations := instance.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[v1.ExpiryWarningAnnotation] = terminatesAt.UTC().Format(time.RFC3339)
+	instance.SetAnnotations(annotations)
+	return time.Time{}, f.client.Update(context.TODO(), instance)
+}
+
+// sendEmail sends a message using the email configuration on the cluster.
+func sendEmail(cluster *v1alpha1.VDICluster, secretsEngine *secrets.SecretEngine, to []string, msg email.Message, data *email.Data) error {
+	sender, err := email.New(cluster, secretsEngine)
+	if err != nil {
+		return err
+	}
+	return sender.Send(to, msg, data)
+}
@@ -0,0 +1,89 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/email"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileExpiryWarning(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	desktop.Spec.User = "test-user"
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+	secretsEngine := secrets.GetSecretEngine(cluster)
+	if err := secretsEngine.Setup(r.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []*email.Data
+	r.sendEmail = func(_ *v1alpha1.VDICluster, _ *secrets.SecretEngine, to []string, msg email.Message, data *email.Data) error {
+		if len(to) != 1 || to[0] != "user@example.com" || msg != email.MessageSessionExpiry {
+			t.Error("Unexpected message sent:", to, msg)
+		}
+		sent = append(sent, data)
+		return nil
+	}
+	reconcileWarning := func() time.Time {
+		t.Helper()
+		warnAt, err := r.reconcileExpiryWarning(testLogger, secretsEngine, cluster, desktop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return warnAt
+	}
+	setTerminatesAt := func(at time.Time) {
+		ts := metav1.NewTime(at.Truncate(time.Second))
+		desktop.Status.TerminatesAt = &ts
+	}
+
+	// nothing happens without email configured
+	setTerminatesAt(time.Now().Add(time.Minute))
+	if warnAt := reconcileWarning(); !warnAt.IsZero() || len(sent) != 0 {
+		t.Fatal("Expected no warning without email configured")
+	}
+
+	cluster.Spec.Email = &v1alpha1.EmailConfig{Server: "smtp.example.com:25", From: "kvdi@example.com", ExpiryWarning: "10m"}
+
+	// warnings are scheduled ahead of termination
+	terminatesAt := time.Now().Add(time.Hour)
+	setTerminatesAt(terminatesAt)
+	if warnAt := reconcileWarning(); warnAt.Sub(terminatesAt.Add(-10*time.Minute)) > time.Second || len(sent) != 0 {
+		t.Error("Expected warning to be scheduled 10 minutes before termination, got:", warnAt)
+	}
+
+	// users without an address are not warned
+	setTerminatesAt(time.Now().Add(5 * time.Minute))
+	if warnAt := reconcileWarning(); !warnAt.IsZero() || len(sent) != 0 {
+		t.Error("Expected no warning for user without an address")
+	}
+
+	if err := secretsEngine.WriteSecretMap(v1.UserEmailsSecretKey, map[string][]byte{"test-user": []byte("user@example.com")}); err != nil {
+		t.Fatal(err)
+	}
+	reconcileWarning()
+	if len(sent) != 1 || sent[0].User != "test-user" || sent[0].Desktop != "test-namespace/test-desktop" {
+		t.Fatal("Expected the user to be warned, got:", sent)
+	}
+
+	// users are only warned once for the same termination time
+	reconcileWarning()
+	if len(sent) != 1 {
+		t.Error("Expected the user to be warned once, got:", len(sent))
+	}
+	setTerminatesAt(time.Now().Add(2 * time.Minute))
+	reconcileWarning()
+	if len(sent) != 2 {
+		t.Error("Expected the user to be warned again for a new termination time, got:", len(sent))
+	}
+}
@@ -0,0 +1,68 @@
+package desktop
+
+import (
+	"context"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileIdleTimeout checks how long the desktop has gone without a display or audio
+// connection. Before the idle timeout passes, the time it will is returned so the desktop
+// can be requeued for it. Once it passes the desktop is destroyed. Desktops that have
+// never been connected to are idle from the time they were created. Desktops waiting
+// in a pool are never idle.
+func (f *Reconciler) reconcileIdleTimeout(reqLogger logr.Logger, cluster *v1alpha1.VDICluster, template *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop) (time.Time, error) {
+	timeout := template.GetIdleTimeout(cluster)
+	if timeout == 0 || instance.IsWarm() {
+		return time.Time{}, nil
+	}
+
+	connected, err := f.desktopConnected(cluster, instance)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if connected {
+		// check again later in case the connection is lost without the app
+		// getting a chance to record it
+		return time.Now().Add(timeout), nil
+	}
+
+	lastActivity := instance.GetCreationTimestamp().Time
+	if instance.Status.LastActivity != nil && instance.Status.LastActivity.Time.After(lastActivity) {
+		lastActivity = instance.Status.LastActivity.Time
+	}
+
+	idleAt := lastActivity.Add(timeout)
+	if time.Now().Before(idleAt) {
+		return idleAt, nil
+	}
+
+	reqLogger.Info("Desktop has exceeded its idle timeout, destroying instance", "IdleTimeout", timeout.String())
+	if err := f.client.Delete(context.TODO(), instance); err != nil {
+		return time.Time{}, client.IgnoreNotFound(err)
+	}
+	return time.Time{}, errors.NewRequeueError("Desktop has been destroyed for being idle", 1)
+}
+
+// desktopConnected returns true if the app is holding a display or audio lock for
+// the desktop.
+func (f *Reconciler) desktopConnected(cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop) (bool, error) {
+	for _, name := range []string{instance.GetDisplayLockName(), instance.GetAudioLockName()} {
+		nn := types.NamespacedName{Name: name, Namespace: cluster.GetCoreNamespace()}
+		if err := f.client.Get(context.TODO(), nn, &corev1.ConfigMap{}); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
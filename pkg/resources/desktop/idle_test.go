@@ -0,0 +1,77 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileIdleTimeout(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	template := newTemplate(t)
+	desktop := newDesktop(t)
+	desktop.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+
+	// no timeout configured should be a no-op
+	if idleAt, err := r.reconcileIdleTimeout(testLogger, cluster, template, desktop); err != nil {
+		t.Fatal(err)
+	} else if !idleAt.IsZero() {
+		t.Error("Expected no idle deadline, got:", idleAt)
+	}
+
+	// the template should take precedence over the cluster
+	cluster.Spec.Desktops = &v1alpha1.DesktopsConfig{IdleTimeout: "30m"}
+	template.Spec.IdleTimeout = "2h"
+	if timeout := template.GetIdleTimeout(cluster); timeout != 2*time.Hour {
+		t.Error("Expected template idle timeout, got:", timeout)
+	}
+
+	// recent activity should return the time the desktop goes idle
+	lastActivity := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	desktop.Status.LastActivity = &lastActivity
+	if idleAt, err := r.reconcileIdleTimeout(testLogger, cluster, template, desktop); err != nil {
+		t.Fatal(err)
+	} else if !idleAt.Equal(lastActivity.Add(2 * time.Hour)) {
+		t.Error("Expected idle deadline from last activity, got:", idleAt)
+	}
+
+	// connected desktops should not be destroyed
+	template.Spec.IdleTimeout = ""
+	desktop.Status.LastActivity = nil
+	lock := &corev1.ConfigMap{}
+	lock.Name = desktop.GetDisplayLockName()
+	lock.Namespace = cluster.GetCoreNamespace()
+	if err := r.client.Create(context.TODO(), lock); err != nil {
+		t.Fatal(err)
+	}
+	if idleAt, err := r.reconcileIdleTimeout(testLogger, cluster, template, desktop); err != nil {
+		t.Fatal(err)
+	} else if idleAt.Before(time.Now()) {
+		t.Error("Expected connected desktop to be rechecked later, got:", idleAt)
+	}
+
+	// once disconnected the desktop should be destroyed
+	if err := r.client.Delete(context.TODO(), lock); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.reconcileIdleTimeout(testLogger, cluster, template, desktop); err == nil {
+		t.Fatal("Expected requeue error, got nil")
+	} else if _, ok := errors.IsRequeueError(err); !ok {
+		t.Fatal("Expected requeue error, got:", err)
+	}
+	nn := types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}
+	if err := r.client.Get(context.TODO(), nn, &v1alpha1.Desktop{}); err == nil {
+		t.Error("Expected desktop to be deleted")
+	}
+}
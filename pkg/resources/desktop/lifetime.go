@@ -0,0 +1,65 @@
+package desktop
+
+import (
+	"context"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileMaxLifetime checks how long the session in the desktop has been running.
+// Before the maximum lifetime passes, the time it will is returned so the desktop can
+// be requeued for it. Once it passes the desktop is destroyed. Desktops waiting in a
+// pool have not started a session yet.
+func (f *Reconciler) reconcileMaxLifetime(reqLogger logr.Logger, cluster *v1alpha1.VDICluster, template *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop) (time.Time, error) {
+	lifetime := template.GetMaxLifetime(cluster)
+	if lifetime == 0 || instance.IsWarm() {
+		return time.Time{}, nil
+	}
+
+	expiresAt := instance.GetSessionStart().Add(lifetime)
+	if time.Now().Before(expiresAt) {
+		return expiresAt, nil
+	}
+
+	reqLogger.Info("Desktop has reached its maximum lifetime, destroying instance", "MaxLifetime", lifetime.String())
+	if err := f.client.Delete(context.TODO(), instance); err != nil {
+		return time.Time{}, client.IgnoreNotFound(err)
+	}
+	return time.Time{}, errors.NewRequeueError("Desktop has been destroyed for reaching its maximum lifetime", 1)
+}
+
+// reconcileTerminationStatus records in the status of the desktop when it will be
+// destroyed for reaching its maximum lifetime or the close of its template's
+// availability window, whichever comes first. This lets the API warn the user ahead
+// of time.
+func (f *Reconciler) reconcileTerminationStatus(instance *v1alpha1.Desktop, expiresAt, windowCloses time.Time) error {
+	var terminatesAt time.Time
+	var reason v1alpha1.TerminationReason
+	if !expiresAt.IsZero() {
+		terminatesAt, reason = expiresAt, v1alpha1.TerminationReasonMaxLifetime
+	}
+	if !windowCloses.IsZero() && (terminatesAt.IsZero() || windowCloses.Before(terminatesAt)) {
+		terminatesAt, reason = windowCloses, v1alpha1.TerminationReasonAvailability
+	}
+
+	current := instance.Status.TerminatesAt
+	if reason == instance.Status.TerminationReason &&
+		((current == nil && terminatesAt.IsZero()) || (current != nil && current.Time.Truncate(time.Second).Equal(terminatesAt.Truncate(time.Second)))) {
+		return nil
+	}
+
+	if terminatesAt.IsZero() {
+		instance.Status.TerminatesAt = nil
+	} else {
+		t := metav1.NewTime(terminatesAt.Truncate(time.Second))
+		instance.Status.TerminatesAt = &t
+	}
+	instance.Status.TerminationReason = reason
+	return f.client.Status().Update(context.TODO(), instance)
+}
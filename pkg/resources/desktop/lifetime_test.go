@@ -0,0 +1,78 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileMaxLifetime(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	template := newTemplate(t)
+	desktop := newDesktop(t)
+	desktop.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour).Truncate(time.Second))
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+
+	// no lifetime configured should be a no-op
+	if expiresAt, err := r.reconcileMaxLifetime(testLogger, cluster, template, desktop); err != nil {
+		t.Fatal(err)
+	} else if !expiresAt.IsZero() {
+		t.Error("Expected no lifetime deadline, got:", expiresAt)
+	}
+
+	// the template should take precedence over the cluster
+	cluster.Spec.Desktops = &v1alpha1.DesktopsConfig{MaxSessionLength: "1h"}
+	template.Spec.MaxLifetime = "8h"
+	if expiresAt, err := r.reconcileMaxLifetime(testLogger, cluster, template, desktop); err != nil {
+		t.Fatal(err)
+	} else if !expiresAt.Equal(desktop.CreationTimestamp.Add(8 * time.Hour)) {
+		t.Error("Expected lifetime deadline from the template, got:", expiresAt)
+	}
+
+	// the deadline should be recorded in the status
+	if err := r.reconcileTerminationStatus(desktop, desktop.CreationTimestamp.Add(8*time.Hour), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if desktop.Status.TerminatesAt == nil || desktop.Status.TerminationReason != v1alpha1.TerminationReasonMaxLifetime {
+		t.Error("Expected lifetime deadline in status, got:", desktop.Status)
+	}
+	// an earlier availability window closing should take its place
+	windowCloses := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := r.reconcileTerminationStatus(desktop, desktop.CreationTimestamp.Add(8*time.Hour), windowCloses); err != nil {
+		t.Fatal(err)
+	}
+	if !desktop.Status.TerminatesAt.Time.Equal(windowCloses) || desktop.Status.TerminationReason != v1alpha1.TerminationReasonAvailability {
+		t.Error("Expected availability deadline in status, got:", desktop.Status)
+	}
+
+	// claimed desktops count their lifetime from when they were claimed
+	template.Spec.MaxLifetime = ""
+	desktop.Annotations = map[string]string{v1.ClaimedAtAnnotation: time.Now().UTC().Format(time.RFC3339)}
+	if expiresAt, err := r.reconcileMaxLifetime(testLogger, cluster, template, desktop); err != nil {
+		t.Fatal(err)
+	} else if expiresAt.Before(time.Now()) {
+		t.Error("Expected claimed desktop to have time remaining, got:", expiresAt)
+	}
+
+	// once the lifetime passes the desktop should be destroyed
+	desktop.Annotations = nil
+	if _, err := r.reconcileMaxLifetime(testLogger, cluster, template, desktop); err == nil {
+		t.Fatal("Expected requeue error, got nil")
+	} else if _, ok := errors.IsRequeueError(err); !ok {
+		t.Fatal("Expected requeue error, got:", err)
+	}
+	nn := types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}
+	if err := r.client.Get(context.TODO(), nn, &v1alpha1.Desktop{}); err == nil {
+		t.Error("Expected desktop to be deleted")
+	}
+}
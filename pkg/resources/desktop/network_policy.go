@@ -0,0 +1,42 @@
+package desktop
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileNetworkPolicy ensures the NetworkPolicy restricting the network access of
+// the desktop. If the template does not restrict it, any existing policy is removed.
+func (f *Reconciler) reconcileNetworkPolicy(reqLogger logr.Logger, cluster *v1alpha1.VDICluster, tmpl *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop) error {
+	policy, err := newNetworkPolicyForCR(cluster, tmpl, instance)
+	if err != nil {
+		return err
+	}
+	if !tmpl.RestrictsNetwork() {
+		return client.IgnoreNotFound(f.client.Delete(context.TODO(), policy))
+	}
+	return reconcile.NetworkPolicy(reqLogger, f.client, policy)
+}
+
+func newNetworkPolicyForCR(cluster *v1alpha1.VDICluster, tmpl *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop) (*networkingv1.NetworkPolicy, error) {
+	spec, err := tmpl.GetNetworkPolicySpec(cluster, instance)
+	if err != nil {
+		return nil, err
+	}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetName(),
+			Namespace:       instance.GetNamespace(),
+			Labels:          cluster.GetDesktopLabels(instance),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Spec: spec,
+	}, nil
+}
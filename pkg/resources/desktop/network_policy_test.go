@@ -0,0 +1,88 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileNetworkPolicy(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	tmpl := newTemplate(t)
+
+	nn := types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}
+
+	// unrestricted templates should not create a policy
+	if err := r.reconcileNetworkPolicy(testLogger, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, &networkingv1.NetworkPolicy{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected network policy to not exist, got:", err)
+	}
+
+	// dns-only egress
+	tmpl.Spec.Network = &v1alpha1.DesktopNetworkConfig{
+		Egress: &v1alpha1.NetworkRulesConfig{AllowDNS: true},
+	}
+	if err := r.reconcileNetworkPolicy(testLogger, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	policy := &networkingv1.NetworkPolicy{}
+	if err := r.client.Get(context.TODO(), nn, policy); err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Spec.PolicyTypes) != 1 || policy.Spec.PolicyTypes[0] != networkingv1.PolicyTypeEgress {
+		t.Error("Expected only egress to be restricted, got:", policy.Spec.PolicyTypes)
+	}
+	if len(policy.Spec.Egress) != 1 || len(policy.Spec.Egress[0].Ports) != 2 || len(policy.Spec.Egress[0].To) != 0 {
+		t.Error("Expected a single DNS egress rule, got:", policy.Spec.Egress)
+	}
+	if len(policy.GetOwnerReferences()) != 1 {
+		t.Error("Expected network policy to be owned by the desktop, got:", policy.GetOwnerReferences())
+	}
+
+	// deny-all ingress and egress only to the allowed cidrs
+	tmpl.Spec.Network = &v1alpha1.DesktopNetworkConfig{
+		Ingress: &v1alpha1.NetworkRulesConfig{},
+		Egress:  &v1alpha1.NetworkRulesConfig{AllowedCIDRs: []string{"10.0.0.0/8", "192.168.1.0/24"}},
+	}
+	if err := r.reconcileNetworkPolicy(testLogger, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	policy = &networkingv1.NetworkPolicy{}
+	if err := r.client.Get(context.TODO(), nn, policy); err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Spec.PolicyTypes) != 2 {
+		t.Error("Expected ingress and egress to be restricted, got:", policy.Spec.PolicyTypes)
+	}
+	// the app should always be able to reach the desktop
+	if len(policy.Spec.Ingress) != 1 || len(policy.Spec.Ingress[0].From) != 1 || policy.Spec.Ingress[0].From[0].PodSelector == nil {
+		t.Error("Expected only the app to be allowed ingress, got:", policy.Spec.Ingress)
+	}
+	if len(policy.Spec.Egress) != 1 || len(policy.Spec.Egress[0].To) != 2 {
+		t.Error("Expected egress to the allowed CIDRs, got:", policy.Spec.Egress)
+	}
+
+	// invalid cidrs should be rejected
+	tmpl.Spec.Network.Egress.AllowedCIDRs = []string{"not-a-cidr"}
+	if err := r.reconcileNetworkPolicy(testLogger, cluster, tmpl, desktop); err == nil {
+		t.Error("Expected error for invalid CIDR, got nil")
+	}
+
+	// removing the restrictions should remove the policy
+	tmpl.Spec.Network = nil
+	if err := r.reconcileNetworkPolicy(testLogger, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, &networkingv1.NetworkPolicy{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected network policy to be removed, got:", err)
+	}
+}
@@ -0,0 +1,52 @@
+package desktop
+
+import (
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// containerCreatingReasons are the reasons a container is left waiting while the
+// kubelet pulls its image and creates it.
+var containerCreatingReasons = map[string]struct{}{
+	"ContainerCreating": {},
+	"PodInitializing":   {},
+	"ErrImagePull":      {},
+	"ImagePullBackOff":  {},
+}
+
+// getDesktopPhase returns how far the given desktop pod has progressed towards
+// serving display connections, derived from its conditions and container states.
+func getDesktopPhase(pod *corev1.Pod) v1alpha1.DesktopPhase {
+	if !podConditionTrue(pod, corev1.PodScheduled) {
+		return v1alpha1.DesktopPhaseScheduling
+	}
+	if podConditionTrue(pod, corev1.PodReady) {
+		return v1alpha1.DesktopPhaseReady
+	}
+	if !podConditionTrue(pod, corev1.PodInitialized) {
+		return v1alpha1.DesktopPhasePullingImage
+	}
+	if len(pod.Status.ContainerStatuses) == 0 {
+		return v1alpha1.DesktopPhasePullingImage
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting == nil {
+			continue
+		}
+		if _, ok := containerCreatingReasons[status.State.Waiting.Reason]; ok {
+			return v1alpha1.DesktopPhasePullingImage
+		}
+	}
+	return v1alpha1.DesktopPhaseBootingDisplay
+}
+
+// podConditionTrue returns true if the given condition is true on the pod.
+func podConditionTrue(pod *corev1.Pod, conditionType corev1.PodConditionType) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == conditionType {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package desktop
+
+import (
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetDesktopPhase(t *testing.T) {
+	pod := &corev1.Pod{}
+	pod.Status.Phase = corev1.PodPending
+	if phase := getDesktopPhase(pod); phase != v1alpha1.DesktopPhaseScheduling {
+		t.Error("Expected unscheduled pod to be scheduling, got:", phase)
+	}
+
+	pod.Status.Conditions = []corev1.PodCondition{
+		{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+		{Type: corev1.PodInitialized, Status: corev1.ConditionTrue},
+		{Type: corev1.PodReady, Status: corev1.ConditionFalse},
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "kvdi-proxy", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		{Name: "desktop", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}},
+	}
+	if phase := getDesktopPhase(pod); phase != v1alpha1.DesktopPhasePullingImage {
+		t.Error("Expected creating container to be pulling image, got:", phase)
+	}
+
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.ContainerStatuses[1].State = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	if phase := getDesktopPhase(pod); phase != v1alpha1.DesktopPhaseBootingDisplay {
+		t.Error("Expected running containers that are not ready to be booting display, got:", phase)
+	}
+
+	pod.Status.Conditions[2].Status = corev1.ConditionTrue
+	if phase := getDesktopPhase(pod); phase != v1alpha1.DesktopPhaseReady {
+		t.Error("Expected ready pod to be ready, got:", phase)
+	}
+}
@@ -0,0 +1,104 @@
+package desktop
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcilePlacement checks the desktop against the placement policies of the roles
+// its user held at launch before its pod is created. The API checks these as well,
+// but desktops can be created without it, and concurrent requests can race past
+// the resource quotas. Desktops in namespaces or without node labels their roles
+// require, or that would put the user over their CPU or memory quotas counting only
+// desktops launched before them, are destroyed. Pooled desktops are booted before
+// they are claimed and are not checked.
+func (f *Reconciler) reconcilePlacement(reqLogger logr.Logger, cluster *v1alpha1.VDICluster, template *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop) error {
+	if instance.Spec.SessionPool != "" {
+		return nil
+	}
+
+	// desktops are only checked once, before they are first scheduled
+	nn := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
+	if err := f.client.Get(context.TODO(), nn, &corev1.Pod{}); client.IgnoreNotFound(err) != nil {
+		return err
+	} else if err == nil {
+		return nil
+	}
+
+	user, err := cluster.GetDesktopUser(f.client, instance)
+	if err != nil {
+		return err
+	}
+	if !user.HasSessionPlacement() {
+		return nil
+	}
+
+	reason, err := f.getPlacementViolation(cluster, template, instance, user)
+	if err != nil || reason == "" {
+		return err
+	}
+
+	reqLogger.Info("Desktop violates the placement policies of its user's roles, destroying instance", "Reason", reason)
+	if err := f.client.Delete(context.TODO(), instance); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	return errors.NewRequeueError("Desktop has been destroyed for violating its placement policies", 1)
+}
+
+// getPlacementViolation returns why the desktop violates the placement policies of
+// the given user's roles, or an empty string if it does not.
+func (f *Reconciler) getPlacementViolation(cluster *v1alpha1.VDICluster, template *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop, user *v1.VDIUser) (string, error) {
+	if !user.AllowsSessionNamespace(instance.GetNamespace()) {
+		return fmt.Sprintf("namespace %s is not allowed", instance.GetNamespace()), nil
+	}
+
+	required, err := user.GetSessionNodeSelector()
+	if err != nil {
+		return err.Error(), nil
+	}
+	selector := instance.GetNodeSelector(template)
+	for key, val := range required {
+		if selector[key] != val {
+			return fmt.Sprintf("node label %s=%s is required", key, val), nil
+		}
+	}
+
+	if len(user.GetSessionResourceLimits()) == 0 {
+		return "", nil
+	}
+	usage, err := cluster.GetUserSessionResourceUsage(f.client, instance.Spec.User, func(desktop *v1alpha1.Desktop) bool {
+		return !launchedBefore(desktop, instance)
+	})
+	if err != nil {
+		return "", err
+	}
+	for name, quantity := range cluster.GetSessionResourceUsage(template, instance.GetNamespace()) {
+		sum := usage[name]
+		sum.Add(quantity)
+		usage[name] = sum
+	}
+	if name := user.GetExceededSessionResource(usage); name != "" {
+		used, limit := usage[name], user.GetSessionResourceLimits()[name]
+		return fmt.Sprintf("%s of %s %s would be in use", used.String(), limit.String(), name), nil
+	}
+	return "", nil
+}
+
+// launchedBefore returns true if desktop a was created before desktop b. Desktops
+// created in the same second are ordered by name.
+func launchedBefore(a, b *v1alpha1.Desktop) bool {
+	at, bt := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !at.Equal(&bt) {
+		return at.Before(&bt)
+	}
+	return a.GetNamespace()+"/"+a.GetName() < b.GetNamespace()+"/"+b.GetName()
+}
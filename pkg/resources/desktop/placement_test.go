@@ -0,0 +1,100 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcilePlacement(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	template := newTemplate(t)
+	template.Spec.Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}
+	if err := r.client.Create(context.TODO(), template); err != nil {
+		t.Fatal(err)
+	}
+	role := &v1alpha1.VDIRole{}
+	role.Name = "data-science"
+	role.Labels = map[string]string{v1.RoleClusterRefLabel: cluster.GetName()}
+	role.Placement = &v1.SessionPlacement{
+		Namespaces:   []string{"test-namespace"},
+		NodeSelector: map[string]string{"pool": "data-science"},
+		MaxCPU:       "3",
+	}
+	if err := r.client.Create(context.TODO(), role); err != nil {
+		t.Fatal(err)
+	}
+
+	newUserDesktop := func(name string, created time.Time) *v1alpha1.Desktop {
+		desktop := newDesktop(t)
+		desktop.Name = name
+		desktop.CreationTimestamp = metav1.NewTime(created)
+		desktop.Labels = cluster.GetUserDesktopLabels("analyst")
+		desktop.Annotations = map[string]string{v1.UserRolesAnnotation: "data-science"}
+		desktop.Spec.User = "analyst"
+		desktop.Spec.NodeSelector = map[string]string{"pool": "data-science"}
+		if err := r.client.Create(context.TODO(), desktop); err != nil {
+			t.Fatal(err)
+		}
+		return desktop
+	}
+	exists := func(desktop *v1alpha1.Desktop) bool {
+		nn := types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}
+		return r.client.Get(context.TODO(), nn, &v1alpha1.Desktop{}) == nil
+	}
+
+	// the first desktop fits in the policy
+	first := newUserDesktop("first", time.Now().Add(-time.Minute).Truncate(time.Second))
+	if err := r.reconcilePlacement(testLogger, cluster, template, first); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(first) {
+		t.Error("Expected desktop within the policy to be kept")
+	}
+
+	// the second would go over the cpu quota, and only it is destroyed
+	second := newUserDesktop("second", time.Now().Truncate(time.Second))
+	if err := r.reconcilePlacement(testLogger, cluster, template, second); err == nil {
+		t.Fatal("Expected requeue error, got nil")
+	} else if _, ok := errors.IsRequeueError(err); !ok {
+		t.Fatal("Expected requeue error, got:", err)
+	}
+	if exists(second) {
+		t.Error("Expected desktop over the cpu quota to be destroyed")
+	}
+	if err := r.reconcilePlacement(testLogger, cluster, template, first); err != nil || !exists(first) {
+		t.Error("Expected earlier desktop to be kept, got:", err)
+	}
+
+	// desktops without the required node labels are destroyed
+	role.Placement.MaxCPU = ""
+	if err := r.client.Update(context.TODO(), role); err != nil {
+		t.Fatal(err)
+	}
+	unlabeled := newUserDesktop("unlabeled", time.Now().Truncate(time.Second))
+	unlabeled.Spec.NodeSelector = nil
+	if err := r.reconcilePlacement(testLogger, cluster, template, unlabeled); err == nil {
+		t.Error("Expected requeue error for missing node labels")
+	}
+
+	// desktops that are already scheduled are left alone
+	pod := &corev1.Pod{}
+	pod.Name, pod.Namespace = first.GetName(), first.GetNamespace()
+	if err := r.client.Create(context.TODO(), pod); err != nil {
+		t.Fatal(err)
+	}
+	first.Spec.NodeSelector = nil
+	if err := r.reconcilePlacement(testLogger, cluster, template, first); err != nil || !exists(first) {
+		t.Error("Expected scheduled desktop to be left alone, got:", err)
+	}
+}
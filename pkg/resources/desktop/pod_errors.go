@@ -0,0 +1,56 @@
+package desktop
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// containerStartErrors are the reasons a container is left waiting when the kubelet
+// fails to create or start it, e.g. because its seccomp profile is missing on the node.
+var containerStartErrors = map[string]struct{}{
+	"CreateContainerError":       {},
+	"CreateContainerConfigError": {},
+	"RunContainerError":          {},
+}
+
+// isPodRejection returns true if the given error means the API server, or an
+// admission controller, refused to create the desktop pod.
+func isPodRejection(err error) bool {
+	return kerrors.IsForbidden(err) || kerrors.IsInvalid(err)
+}
+
+// recordPodRejection records the reason the desktop pod was refused on the desktop
+// status so it can be surfaced by the API, and returns the original error.
+func (f *Reconciler) recordPodRejection(instance *v1alpha1.Desktop, err error) error {
+	msg := fmt.Sprintf("Desktop pod was rejected: %s", err.Error())
+	if instance.Status.PodError != msg {
+		instance.Status.PodError = msg
+		if uerr := f.client.Status().Update(context.TODO(), instance); uerr != nil {
+			return uerr
+		}
+	}
+	return err
+}
+
+// getPodStartError returns why the given desktop pod failed to start, or an empty
+// string if it has not. This covers the kubelet rejecting the pod, such as when its
+// AppArmor profile is not loaded on the node, and containers that could not be created.
+func getPodStartError(pod *corev1.Pod) string {
+	if pod.Status.Phase == corev1.PodFailed && pod.Status.Reason != "" {
+		return fmt.Sprintf("%s: %s", pod.Status.Reason, pod.Status.Message)
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting == nil {
+			continue
+		}
+		if _, ok := containerStartErrors[status.State.Waiting.Reason]; ok {
+			return fmt.Sprintf("Container %s failed to start: %s", status.Name, status.State.Waiting.Message)
+		}
+	}
+	return ""
+}
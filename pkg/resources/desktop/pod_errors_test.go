@@ -0,0 +1,78 @@
+package desktop
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestGetPodStartError(t *testing.T) {
+	pod := &corev1.Pod{}
+	pod.Status.Phase = corev1.PodPending
+	if msg := getPodStartError(pod); msg != "" {
+		t.Error("Expected no error for pending pod, got:", msg)
+	}
+
+	// kubelet rejecting the pod for a missing apparmor profile
+	pod.Status = corev1.PodStatus{
+		Phase:   corev1.PodFailed,
+		Reason:  "AppArmor",
+		Message: `Cannot enforce AppArmor: profile "kvdi-desktop" is not loaded`,
+	}
+	if msg := getPodStartError(pod); !strings.Contains(msg, "is not loaded") {
+		t.Error("Expected AppArmor error, got:", msg)
+	}
+
+	// container runtime failing to load a seccomp profile
+	pod.Status = corev1.PodStatus{
+		Phase: corev1.PodPending,
+		ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "kvdi-proxy", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			{Name: "desktop", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason:  "CreateContainerError",
+				Message: "cannot load seccomp profile",
+			}}},
+		},
+	}
+	if msg := getPodStartError(pod); !strings.Contains(msg, "desktop") || !strings.Contains(msg, "seccomp") {
+		t.Error("Expected seccomp error for desktop container, got:", msg)
+	}
+
+	// pulling images is not a start error
+	pod.Status.ContainerStatuses[1].State.Waiting.Reason = "ContainerCreating"
+	if msg := getPodStartError(pod); msg != "" {
+		t.Error("Expected no error for creating container, got:", msg)
+	}
+}
+
+func TestRecordPodRejection(t *testing.T) {
+	r := newReconciler(t)
+	desktop := newDesktop(t)
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+
+	if isPodRejection(kerrors.NewNotFound(schema.GroupResource{Resource: "pods"}, desktop.GetName())) {
+		t.Error("Expected not found to not be a rejection")
+	}
+	rejection := kerrors.NewForbidden(schema.GroupResource{Resource: "pods"}, desktop.GetName(), errors.New("seccomp profile not allowed"))
+	if !isPodRejection(rejection) {
+		t.Fatal("Expected forbidden to be a rejection")
+	}
+	if err := r.recordPodRejection(desktop, rejection); err != rejection {
+		t.Error("Expected the original error to be returned, got:", err)
+	}
+	found := newDesktop(t)
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}, found); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(found.Status.PodError, "seccomp profile not allowed") {
+		t.Error("Expected rejection on desktop status, got:", found.Status.PodError)
+	}
+}
@@ -10,12 +10,36 @@ import (
 )
 
 func newDesktopPodForCR(cluster *v1alpha1.VDICluster, tmpl *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop) *corev1.Pod {
+	podConfig := tmpl.GetPodConfig()
+	containers := []corev1.Container{
+		tmpl.GetDesktopProxyContainer(),
+		{
+			Name:            "desktop",
+			Image:           tmpl.GetDesktopImage(),
+			ImagePullPolicy: tmpl.GetDesktopPullPolicy(),
+			VolumeMounts:    podConfig.AppendVolumeMounts(tmpl.GetDesktopVolumeMounts(cluster, instance)),
+			SecurityContext: tmpl.GetDesktopContainerSecurityContext(),
+			Env:             tmpl.GetDesktopEnvVars(instance),
+			Lifecycle:       tmpl.GetLifecycle(),
+			ReadinessProbe:  tmpl.GetDesktopReadinessProbe(),
+			Resources:       cluster.GetDesktopResources(tmpl, instance.GetNamespace()),
+		},
+	}
+	// Only desktops launched by a traced request export spans from the proxy, so
+	// that enabling tracing does not recreate the pods of running desktops.
+	if _, ok := instance.GetAnnotations()[v1.TraceParentAnnotation]; ok {
+		containers[0].Env = append(containers[0].Env, cluster.GetTracingEnvVars()...)
+	}
+	if tmpl.RDPEnabled() {
+		containers = append(containers, tmpl.GetDesktopGuacdContainer())
+	}
+	containers = podConfig.AppendSidecars(containers)
 	return &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            instance.GetName(),
 			Namespace:       instance.GetNamespace(),
-			Labels:          cluster.GetDesktopLabels(instance),
-			Annotations:     instance.GetAnnotations(),
+			Labels:          podConfig.MergeLabels(cluster.GetDesktopLabels(instance)),
+			Annotations:     podConfig.MergeAnnotations(newPodAnnotationsForCR(tmpl, instance)),
 			OwnerReferences: instance.OwnerReferences(),
 		},
 		Spec: corev1.PodSpec{
@@ -23,32 +47,91 @@ func newDesktopPodForCR(cluster *v1alpha1.VDICluster, tmpl *v1alpha1.DesktopTemp
 			Subdomain:          instance.GetName(),
 			ServiceAccountName: tmpl.GetDesktopServiceAccount(),
 			SecurityContext:    tmpl.GetDesktopPodSecurityContext(),
-			Volumes:            tmpl.GetDesktopVolumes(cluster, instance),
+			Volumes:            podConfig.AppendVolumes(tmpl.GetDesktopVolumes(cluster, instance)),
 			ImagePullSecrets:   tmpl.GetDesktopPullSecrets(),
-			Containers: []corev1.Container{
-				tmpl.GetDesktopProxyContainer(),
-				{
-					Name:            "desktop",
-					Image:           tmpl.GetDesktopImage(),
-					ImagePullPolicy: tmpl.GetDesktopPullPolicy(),
-					VolumeMounts:    tmpl.GetDesktopVolumeMounts(cluster, instance),
-					SecurityContext: tmpl.GetDesktopContainerSecurityContext(),
-					Env:             tmpl.GetDesktopEnvVars(instance),
-					Lifecycle:       tmpl.GetLifecycle(),
-					Resources:       tmpl.GetDesktopResources(),
-				},
-			},
+			Affinity:           newAffinityForCR(cluster, instance),
+			NodeSelector:       instance.GetNodeSelector(tmpl),
+			Tolerations:        tmpl.GetDesktopTolerations(),
+			InitContainers:     podConfig.AppendInitContainers(tmpl.GetDesktopInitContainers(cluster, instance)),
+			Containers:         containers,
 		},
 	}
 }
 
+// newPodInstanceForCR returns the desktop to build the pod and its dependent resources
+// from. Pooled desktops are built as if they were never claimed, so that claiming one
+// does not cause its pod to be recreated.
+func newPodInstanceForCR(instance *v1alpha1.Desktop) *v1alpha1.Desktop {
+	if instance.Spec.SessionPool == "" {
+		return instance
+	}
+	podInstance := instance.DeepCopy()
+	podInstance.Spec.User = ""
+	podInstance.SetLabels(map[string]string{v1.SessionPoolLabel: instance.Spec.SessionPool})
+	return podInstance
+}
+
+// newAnnotationsForCR returns the annotations to apply to the resources for a desktop.
+// Drain notices are left out so that they do not cause the resources to be recreated.
+func newAnnotationsForCR(instance *v1alpha1.Desktop) map[string]string {
+	annotations := make(map[string]string)
+	for key, val := range instance.GetAnnotations() {
+		if key == v1.DrainAnnotation {
+			continue
+		}
+		annotations[key] = val
+	}
+	return annotations
+}
+
+// newPodAnnotationsForCR returns the annotations to apply to the desktop pod, including
+// the ones applying the template's security profiles to the desktop container.
+func newPodAnnotationsForCR(tmpl *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop) map[string]string {
+	annotations := newAnnotationsForCR(instance)
+	for key, val := range tmpl.GetSecurityProfileAnnotations("desktop") {
+		annotations[key] = val
+	}
+	return annotations
+}
+
+// newAffinityForCR returns the affinity for the desktop pod. Desktops that have been
+// preempted are kept off of spot and preemptible capacity, and desktops that have
+// been drained are kept off of the drained node.
+func newAffinityForCR(cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop) *corev1.Affinity {
+	if !instance.Status.Preempted && instance.Status.DrainedFrom == "" {
+		return nil
+	}
+	affinity := &corev1.NodeAffinity{}
+	if instance.Status.Preempted {
+		affinity = cluster.GetStableNodeAffinity().DeepCopy()
+	}
+	if instance.Status.DrainedFrom != "" {
+		avoidNode := corev1.NodeSelectorRequirement{
+			Key:      "metadata.name",
+			Operator: corev1.NodeSelectorOpNotIn,
+			Values:   []string{instance.Status.DrainedFrom},
+		}
+		if affinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+			affinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{}},
+			}
+		}
+		// terms are ORed, so the drained node needs to be excluded from each of them
+		terms := affinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		for i := range terms {
+			terms[i].MatchFields = append(terms[i].MatchFields, avoidNode)
+		}
+	}
+	return &corev1.Affinity{NodeAffinity: affinity}
+}
+
 func newServiceForCR(cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop) *corev1.Service {
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            instance.GetName(),
 			Namespace:       instance.GetNamespace(),
 			Labels:          cluster.GetDesktopLabels(instance),
-			Annotations:     instance.GetAnnotations(),
+			Annotations:     newAnnotationsForCR(instance),
 			OwnerReferences: instance.OwnerReferences(),
 		},
 		Spec: corev1.ServiceSpec{
@@ -0,0 +1,469 @@
+package desktop
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestNewDesktopPodResources(t *testing.T) {
+	cluster := newCluster(t)
+	cluster.Spec.Desktops = &v1alpha1.DesktopsConfig{
+		NamespaceResources: map[string]v1alpha1.NamespaceResourceConfig{
+			"*": {
+				Default: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				},
+				Max: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+			},
+			"unbounded": {},
+		},
+	}
+	tmpl := newTemplate(t)
+	tmpl.Spec.Resources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")},
+	}
+	desktop := newDesktop(t)
+
+	resources := newDesktopPodForCR(cluster, tmpl, desktop).Spec.Containers[1].Resources
+	for name, expected := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    "250m",
+		corev1.ResourceMemory: "4Gi",
+	} {
+		if q := resources.Requests[name]; q.String() != expected {
+			t.Errorf("Expected %s request of %s, got %s", name, expected, q.String())
+		}
+	}
+	for name, expected := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    "1",
+		corev1.ResourceMemory: "4Gi",
+	} {
+		if q := resources.Limits[name]; q.String() != expected {
+			t.Errorf("Expected %s limit of %s, got %s", name, expected, q.String())
+		}
+	}
+
+	// the template itself should not be modified
+	if q := tmpl.Spec.Resources.Requests[corev1.ResourceMemory]; q.String() != "8Gi" {
+		t.Error("Expected template resources to be unchanged, got memory request", q.String())
+	}
+
+	// namespaces with their own entry don't use the wildcard
+	desktop.Namespace = "unbounded"
+	resources = newDesktopPodForCR(cluster, tmpl, desktop).Spec.Containers[1].Resources
+	if len(resources.Limits) != 0 {
+		t.Error("Expected no limits for unbounded namespace, got:", resources.Limits)
+	}
+}
+
+func TestNewDesktopPodForPooledCR(t *testing.T) {
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	desktop := newDesktop(t)
+	desktop.Spec.SessionPool = "test-pool"
+	desktop.SetLabels(map[string]string{v1.SessionPoolLabel: "test-pool"})
+	warmPod := newDesktopPodForCR(cluster, tmpl, newPodInstanceForCR(desktop))
+
+	// claiming the desktop should not change the pod
+	desktop.Spec.User = "test-user"
+	desktop.SetLabels(cluster.GetUserDesktopLabels("test-user"))
+	claimedPod := newDesktopPodForCR(cluster, tmpl, newPodInstanceForCR(desktop))
+	if !reflect.DeepEqual(warmPod, claimedPod) {
+		t.Error("Expected claiming a pooled desktop to leave the pod unchanged")
+	}
+	if desktop.IsWarm() {
+		t.Error("Expected claimed desktop to no longer be warm")
+	}
+}
+
+func TestNewDesktopPodParameters(t *testing.T) {
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	tmpl.Spec.Parameters = []v1alpha1.DesktopTemplateParameter{
+		{Name: "resolution", Default: "1920x1080", Options: []string{"1920x1080", "2560x1440"}, Env: "DISPLAY_RESOLUTION"},
+		{Name: "memory", Type: v1alpha1.ParameterQuantity, Default: "2Gi", Maximum: "8Gi", Resource: corev1.ResourceMemory},
+		{Name: "data", Options: []string{"team-data"}, ClaimMountPath: "/mnt/data"},
+	}
+	desktop := newDesktop(t)
+	desktop.Spec.Parameters = map[string]string{"resolution": "2560x1440", "data": "team-data"}
+
+	applied, err := tmpl.ApplyParameters(desktop.Spec.Parameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := newDesktopPodForCR(cluster, applied, desktop).Spec.Containers[1]
+
+	var found bool
+	for _, env := range container.Env {
+		if env.Name == "DISPLAY_RESOLUTION" {
+			found = true
+			if env.Value != "2560x1440" {
+				t.Error("Expected chosen resolution, got:", env.Value)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected resolution environment variable to be set")
+	}
+
+	// defaults are applied for parameters that are not set
+	if q := container.Resources.Limits[corev1.ResourceMemory]; q.String() != "2Gi" {
+		t.Error("Expected default memory limit of 2Gi, got:", q.String())
+	}
+
+	found = false
+	for _, mount := range container.VolumeMounts {
+		if mount.MountPath == "/mnt/data" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected chosen claim to be mounted")
+	}
+
+	// invalid values are rejected
+	if _, err := tmpl.ApplyParameters(map[string]string{"memory": "16Gi"}); err == nil {
+		t.Error("Expected error applying memory over the maximum, got nil")
+	}
+	if _, err := tmpl.ApplyParameters(map[string]string{"data": "other-claim"}); err == nil {
+		t.Error("Expected error applying claim not in options, got nil")
+	}
+}
+
+func TestNewDesktopPodGPU(t *testing.T) {
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	tmpl.Spec.GPU = &v1alpha1.GPUConfig{
+		Vendor:       v1alpha1.GPUVendorAMD,
+		NodeSelector: map[string]string{"gpu-model": "mi100"},
+	}
+	desktop := newDesktop(t)
+
+	pod := newDesktopPodForCR(cluster, tmpl, desktop)
+	if q := pod.Spec.Containers[1].Resources.Limits["amd.com/gpu"]; q.Value() != 1 {
+		t.Error("Expected default GPU limit of 1, got:", q.String())
+	}
+	if !reflect.DeepEqual(pod.Spec.NodeSelector, tmpl.Spec.GPU.NodeSelector) {
+		t.Error("Expected GPU node selector on pod, got:", pod.Spec.NodeSelector)
+	}
+	if len(pod.Spec.Tolerations) != 1 || pod.Spec.Tolerations[0].Key != "amd.com/gpu" {
+		t.Error("Expected default GPU toleration on pod, got:", pod.Spec.Tolerations)
+	}
+	if _, ok := tmpl.Spec.Resources.Limits["amd.com/gpu"]; ok {
+		t.Error("Expected template resources to be unchanged")
+	}
+
+	// explicit resource names and tolerations override the defaults
+	tmpl.Spec.GPU = &v1alpha1.GPUConfig{
+		Count:        2,
+		ResourceName: "nvidia.com/mig-1g.5gb",
+		Tolerations:  []corev1.Toleration{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}},
+	}
+	pod = newDesktopPodForCR(cluster, tmpl, desktop)
+	if q := pod.Spec.Containers[1].Resources.Limits["nvidia.com/mig-1g.5gb"]; q.Value() != 2 {
+		t.Error("Expected GPU limit of 2, got:", q.String())
+	}
+	if !reflect.DeepEqual(pod.Spec.Tolerations, tmpl.Spec.GPU.Tolerations) {
+		t.Error("Expected configured tolerations on pod, got:", pod.Spec.Tolerations)
+	}
+
+	// desktops without gpus are unaffected
+	tmpl.Spec.GPU = nil
+	pod = newDesktopPodForCR(cluster, tmpl, desktop)
+	if pod.Spec.NodeSelector != nil || pod.Spec.Tolerations != nil {
+		t.Error("Expected no node selector or tolerations without GPUs")
+	}
+}
+
+func TestNewDesktopPodRDP(t *testing.T) {
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	desktop := newDesktop(t)
+
+	// desktops served over VNC don't get a guacd sidecar
+	if pod := newDesktopPodForCR(cluster, tmpl, desktop); len(pod.Spec.Containers) != 2 {
+		t.Error("Expected no guacd sidecar without RDP, got containers:", len(pod.Spec.Containers))
+	}
+
+	tmpl.Spec.Config = &v1alpha1.DesktopConfig{
+		SocketType: v1alpha1.SocketRDP,
+		RDP: &v1alpha1.RDPConfig{
+			Address:           "windows.example.com:3389",
+			Security:          v1alpha1.RDPSecurityNLA,
+			CredentialsSecret: "rdp-creds",
+		},
+	}
+	pod := newDesktopPodForCR(cluster, tmpl, desktop)
+	if len(pod.Spec.Containers) != 3 || pod.Spec.Containers[2].Name != "guacd" {
+		t.Fatal("Expected guacd sidecar for RDP template")
+	}
+	if image := pod.Spec.Containers[2].Image; image != v1.DefaultGuacdImage {
+		t.Error("Expected default guacd image, got:", image)
+	}
+
+	proxy := pod.Spec.Containers[0]
+	args := strings.Join(proxy.Args, " ")
+	if !strings.Contains(args, "--rdp-addr windows.example.com:3389") || !strings.Contains(args, "--rdp-security nla") {
+		t.Error("Expected RDP configuration in kvdi-proxy args, got:", args)
+	}
+
+	// the credentials are only mounted in the proxy
+	var mounted bool
+	for _, mount := range proxy.VolumeMounts {
+		if mount.MountPath == v1.RDPCredentialsMountPath {
+			mounted = true
+		}
+	}
+	if !mounted {
+		t.Error("Expected RDP credentials to be mounted in kvdi-proxy")
+	}
+	for _, mount := range pod.Spec.Containers[1].VolumeMounts {
+		if mount.MountPath == v1.RDPCredentialsMountPath {
+			t.Error("Expected RDP credentials to not be mounted in the desktop container")
+		}
+	}
+	var found bool
+	for _, vol := range pod.Spec.Volumes {
+		if vol.Secret != nil && vol.Secret.SecretName == "rdp-creds" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected RDP credentials secret volume on pod")
+	}
+}
+
+func TestNewDesktopPodPrinting(t *testing.T) {
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	desktop := newDesktop(t)
+
+	hasSpoolMount := func(container corev1.Container) bool {
+		for _, mount := range container.VolumeMounts {
+			if mount.MountPath == v1.DesktopPrintSpoolPath {
+				return true
+			}
+		}
+		return false
+	}
+
+	pod := newDesktopPodForCR(cluster, tmpl, desktop)
+	if hasSpoolMount(pod.Spec.Containers[0]) || hasSpoolMount(pod.Spec.Containers[1]) {
+		t.Error("Expected no print spool without printing enabled")
+	}
+
+	tmpl.Spec.Config = &v1alpha1.DesktopConfig{AllowPrinting: true}
+	pod = newDesktopPodForCR(cluster, tmpl, desktop)
+	proxy, desk := pod.Spec.Containers[0], pod.Spec.Containers[1]
+	if !hasSpoolMount(proxy) || !hasSpoolMount(desk) {
+		t.Error("Expected the print spool to be shared between the desktop and kvdi-proxy")
+	}
+	if args := strings.Join(proxy.Args, " "); !strings.Contains(args, "--print-dir "+v1.DesktopPrintSpoolPath) {
+		t.Error("Expected the print spool in kvdi-proxy args, got:", args)
+	}
+	var found bool
+	for _, env := range desk.Env {
+		if env.Name == v1.PrintSpoolEnvVar && env.Value == v1.DesktopPrintSpoolPath {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected the print spool in the desktop environment, got:", desk.Env)
+	}
+}
+
+func TestTemplateMicrophonePolicy(t *testing.T) {
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	desktop := newDesktop(t)
+
+	// microphone input is off unless the template allows it
+	for _, config := range []*v1alpha1.DesktopConfig{nil, {AllowFileTransfer: true}} {
+		tmpl.Spec.Config = config
+		proxy := newDesktopPodForCR(cluster, tmpl, desktop).Spec.Containers[0]
+		if args := strings.Join(proxy.Args, " "); tmpl.MicrophoneEnabled() || strings.Contains(args, "--microphone") {
+			t.Error("Expected microphone input to be disabled by default, got:", args)
+		}
+	}
+
+	tmpl.Spec.Config = &v1alpha1.DesktopConfig{AllowMicrophone: true}
+	proxy := newDesktopPodForCR(cluster, tmpl, desktop).Spec.Containers[0]
+	if args := strings.Join(proxy.Args, " "); !tmpl.MicrophoneEnabled() || !strings.Contains(args, "--microphone") {
+		t.Error("Expected microphone flag in kvdi-proxy args, got:", args)
+	}
+}
+
+func TestNewDesktopPodCustomization(t *testing.T) {
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	desktop := newDesktop(t)
+	generated := newDesktopPodForCR(cluster, tmpl, desktop)
+
+	tmpl.Spec.Pod = &v1alpha1.DesktopPodConfig{
+		Annotations: map[string]string{"example.com/annotation": "true"},
+		Labels: map[string]string{
+			"team":              "finance",
+			v1.UserLabel:        "someone-else",
+			v1.DesktopNameLabel: "override",
+		},
+		InitContainers: []corev1.Container{{Name: "setup", Image: "busybox"}},
+		Sidecars: []corev1.Container{
+			{Name: "agent", Image: "agent"},
+			{Name: "desktop", Image: "override"},
+		},
+		Volumes: []corev1.Volume{
+			{Name: "shared", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: "shared", MountPath: "/mnt/shared"}},
+	}
+	pod := newDesktopPodForCR(cluster, tmpl, desktop)
+
+	if pod.Annotations["example.com/annotation"] != "true" {
+		t.Error("Expected extra annotation on pod, got:", pod.Annotations)
+	}
+	if pod.Labels["team"] != "finance" {
+		t.Error("Expected extra label on pod, got:", pod.Labels)
+	}
+	for key, val := range generated.Labels {
+		if pod.Labels[key] != val {
+			t.Errorf("Expected generated label %s=%s to be kept, got %s", key, val, pod.Labels[key])
+		}
+	}
+
+	if len(pod.Spec.InitContainers) != len(generated.Spec.InitContainers)+1 {
+		t.Fatal("Expected extra init container to be appended, got", len(pod.Spec.InitContainers))
+	}
+	if pod.Spec.InitContainers[len(pod.Spec.InitContainers)-1].Name != "setup" {
+		t.Error("Expected extra init container to run last")
+	}
+
+	if len(pod.Spec.Containers) != len(generated.Spec.Containers)+1 {
+		t.Fatal("Expected one sidecar to be appended, got", len(pod.Spec.Containers))
+	}
+	if pod.Spec.Containers[1].Image == "override" {
+		t.Error("Expected sidecars to not replace the desktop container")
+	}
+	if pod.Spec.Containers[len(pod.Spec.Containers)-1].Name != "agent" {
+		t.Error("Expected agent sidecar to be appended")
+	}
+
+	if len(pod.Spec.Volumes) != len(generated.Spec.Volumes)+1 {
+		t.Error("Expected extra volume on pod")
+	}
+	mounts := pod.Spec.Containers[1].VolumeMounts
+	if len(mounts) != len(generated.Spec.Containers[1].VolumeMounts)+1 || mounts[len(mounts)-1].MountPath != "/mnt/shared" {
+		t.Error("Expected extra mount on desktop container, got:", mounts)
+	}
+}
+
+func TestNewDesktopPodTracing(t *testing.T) {
+	cluster := newCluster(t)
+	cluster.Spec.App = &v1alpha1.AppConfig{
+		Tracing: &v1alpha1.TracingConfig{
+			Endpoint: "http://collector:4318",
+			Headers:  map[string]string{"b": "2", "a": "1 b"},
+		},
+	}
+	tmpl := newTemplate(t)
+	desktop := newDesktop(t)
+
+	pod := newDesktopPodForCR(cluster, tmpl, desktop)
+	for _, env := range pod.Spec.Containers[0].Env {
+		if env.Name == "OTEL_EXPORTER_OTLP_ENDPOINT" {
+			t.Error("Expected desktops not launched by a traced request to not export spans")
+		}
+	}
+
+	desktop.Annotations = map[string]string{v1.TraceParentAnnotation: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	pod = newDesktopPodForCR(cluster, tmpl, desktop)
+	env := make(map[string]string)
+	for _, e := range pod.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env["OTEL_EXPORTER_OTLP_ENDPOINT"] != "http://collector:4318" {
+		t.Error("Expected the collector endpoint on the proxy, got:", env)
+	}
+	if env["OTEL_EXPORTER_OTLP_HEADERS"] != "a=1%20b,b=2" {
+		t.Error("Expected sorted and escaped headers on the proxy, got:", env["OTEL_EXPORTER_OTLP_HEADERS"])
+	}
+}
+
+func TestNewDesktopPodSecurityProfiles(t *testing.T) {
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	desktop := newDesktop(t)
+
+	pod := newDesktopPodForCR(cluster, tmpl, desktop)
+	if desk := pod.Spec.Containers[1]; !*desk.SecurityContext.Privileged || desk.SecurityContext.SELinuxOptions != nil {
+		t.Error("Expected privileged desktop without security profiles, got:", desk.SecurityContext)
+	}
+	for key := range pod.GetAnnotations() {
+		if strings.Contains(key, "seccomp") || strings.Contains(key, "apparmor") {
+			t.Error("Expected no profile annotations without security profiles, got:", key)
+		}
+	}
+
+	tmpl.Spec.SecurityProfiles = &v1alpha1.SecurityProfilesConfig{
+		Seccomp:  "runtime/default",
+		AppArmor: "localhost/kvdi-desktop",
+		SELinux:  &corev1.SELinuxOptions{Type: "container_t"},
+	}
+	// profiles conflicting with user supplied annotations take precedence
+	tmpl.Spec.Pod = &v1alpha1.DesktopPodConfig{
+		Annotations: map[string]string{"container.apparmor.security.beta.kubernetes.io/desktop": "unconfined"},
+	}
+	pod = newDesktopPodForCR(cluster, tmpl, desktop)
+	annotations := pod.GetAnnotations()
+	if val := annotations["container.seccomp.security.alpha.kubernetes.io/desktop"]; val != "runtime/default" {
+		t.Error("Expected seccomp profile annotation, got:", val)
+	}
+	if val := annotations["container.apparmor.security.beta.kubernetes.io/desktop"]; val != "localhost/kvdi-desktop" {
+		t.Error("Expected AppArmor profile annotation, got:", val)
+	}
+	desk := pod.Spec.Containers[1]
+	if *desk.SecurityContext.Privileged {
+		t.Error("Expected unprivileged desktop with security profiles")
+	}
+	if desk.SecurityContext.SELinuxOptions == nil || desk.SecurityContext.SELinuxOptions.Type != "container_t" {
+		t.Error("Expected SELinux options on the desktop container, got:", desk.SecurityContext.SELinuxOptions)
+	}
+	if proxy := pod.Spec.Containers[0]; proxy.SecurityContext != nil && proxy.SecurityContext.SELinuxOptions != nil {
+		t.Error("Expected no SELinux options on the kvdi-proxy, got:", proxy.SecurityContext.SELinuxOptions)
+	}
+}
+
+func TestNewDesktopPodReadinessProbe(t *testing.T) {
+	cluster := newCluster(t)
+	tmpl := newTemplate(t)
+	desktop := newDesktop(t)
+
+	// the display socket is checked for inside the desktop image
+	probe := newDesktopPodForCR(cluster, tmpl, desktop).Spec.Containers[1].ReadinessProbe
+	if probe == nil || probe.Exec == nil {
+		t.Fatal("Expected exec readiness probe on the desktop container, got:", probe)
+	}
+	if cmd := strings.Join(probe.Exec.Command, " "); cmd != "test -S /var/run/kvdi/display.sock" {
+		t.Error("Expected probe to check the display socket, got:", cmd)
+	}
+
+	tmpl.Spec.Config = &v1alpha1.DesktopConfig{SocketAddr: "tcp://127.0.0.1:5900"}
+	probe = newDesktopPodForCR(cluster, tmpl, desktop).Spec.Containers[1].ReadinessProbe
+	if probe == nil || probe.TCPSocket == nil || probe.TCPSocket.Port.IntValue() != 5900 {
+		t.Error("Expected TCP readiness probe on the display port, got:", probe)
+	}
+
+	// RDP servers are not probed
+	tmpl.Spec.Config = &v1alpha1.DesktopConfig{SocketType: v1alpha1.SocketRDP}
+	if probe = newDesktopPodForCR(cluster, tmpl, desktop).Spec.Containers[1].ReadinessProbe; probe != nil {
+		t.Error("Expected no readiness probe for RDP desktops, got:", probe)
+	}
+}
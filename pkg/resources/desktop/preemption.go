@@ -0,0 +1,69 @@
+package desktop
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcilePreemption checks if the node the desktop pod is running on has received
+// a preemption notice. If it has, the userdata volume is snapshotted (when configured)
+// and the desktop is marked as preempted, which causes the pod to be recreated
+// on stable capacity.
+func (f *Reconciler) reconcilePreemption(reqLogger logr.Logger, cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop, pod *corev1.Pod) error {
+	if instance.Status.Preempted || pod.Spec.NodeName == "" {
+		return nil
+	}
+
+	node := &corev1.Node{}
+	if err := f.client.Get(context.TODO(), types.NamespacedName{Name: pod.Spec.NodeName, Namespace: metav1.NamespaceAll}, node); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !cluster.NodeHasPreemptionNotice(node) {
+		return nil
+	}
+
+	reqLogger.Info("Desktop node has received a preemption notice, relaunching on stable capacity", "Node.Name", node.GetName())
+
+	if cluster.GetUserdataSnapshotClass() != "" {
+		if err := f.snapshotUserdata(reqLogger, cluster, instance); err != nil {
+			return err
+		}
+	}
+
+	instance.Status.Preempted = true
+	instance.Status.Running = false
+	instance.Status.PodPhase = pod.Status.Phase
+	instance.Status.Phase = v1alpha1.DesktopPhaseScheduling
+	if err := f.client.Status().Update(context.TODO(), instance); err != nil {
+		return err
+	}
+	return errors.NewRequeueError("Desktop has been preempted", 1)
+}
+
+// snapshotUserdata creates a VolumeSnapshot of the userdata volume for the desktop's user.
+func (f *Reconciler) snapshotUserdata(reqLogger logr.Logger, cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop) error {
+	pvc, err := f.getPVCForInstance(cluster, instance)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			// nothing to snapshot
+			return nil
+		}
+		return err
+	}
+
+	snapshot := k8sutil.NewVolumeSnapshot(pvc.GetName(), pvc.GetNamespace(), cluster.GetUserdataSnapshotClass(), cluster.GetDesktopLabels(instance))
+	snapshot.SetAnnotations(map[string]string{v1.SnapshotTemplateAnnotation: instance.Spec.Template})
+
+	reqLogger.Info("Creating snapshot of userdata volume", "VolumeSnapshot.Name", snapshot.GetName(), "VolumeSnapshot.Namespace", snapshot.GetNamespace())
+	return f.client.Create(context.TODO(), snapshot)
+}
@@ -0,0 +1,68 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcilePreemption(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	cluster.Spec.Desktops = &v1alpha1.DesktopsConfig{Preemption: &v1alpha1.PreemptionConfig{}}
+	desktop := newDesktop(t)
+	if err := r.client.Create(context.TODO(), desktop); err != nil {
+		t.Fatal(err)
+	}
+
+	node := &corev1.Node{}
+	node.Name = "test-node"
+	if err := r.client.Create(context.TODO(), node); err != nil {
+		t.Fatal(err)
+	}
+
+	pod := newDesktopPodForCR(cluster, newTemplate(t), desktop)
+	if pod.Spec.Affinity != nil {
+		t.Error("Expected no affinity for desktop that hasn't been preempted")
+	}
+	pod.Spec.NodeName = node.Name
+
+	// node without a notice should be a no-op
+	if err := r.reconcilePreemption(testLogger, cluster, desktop, pod); err != nil {
+		t.Fatal(err)
+	}
+	if desktop.Status.Preempted {
+		t.Fatal("Expected desktop to not be preempted")
+	}
+
+	// taint the node
+	node.Spec.Taints = []corev1.Taint{
+		{Key: "cloud.google.com/impending-node-termination", Effect: corev1.TaintEffectNoSchedule},
+	}
+	if err := r.client.Update(context.TODO(), node); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcilePreemption(testLogger, cluster, desktop, pod); err == nil {
+		t.Fatal("Expected requeue error, got nil")
+	} else if _, ok := errors.IsRequeueError(err); !ok {
+		t.Fatal("Expected requeue error, got:", err)
+	}
+
+	found := &v1alpha1.Desktop{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}, found); err != nil {
+		t.Fatal(err)
+	}
+	if !found.Status.Preempted {
+		t.Error("Expected desktop to be marked as preempted")
+	}
+
+	pod = newDesktopPodForCR(cluster, newTemplate(t), found)
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil {
+		t.Error("Expected preempted desktop to have a node affinity")
+	}
+}
@@ -2,10 +2,10 @@ package desktop
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/email"
 	"github.com/tinyzimmer/kvdi/pkg/pki"
 	"github.com/tinyzimmer/kvdi/pkg/resources"
 	"github.com/tinyzimmer/kvdi/pkg/secrets"
@@ -26,36 +26,62 @@ type Reconciler struct {
 
 	client client.Client
 	scheme *runtime.Scheme
+	// sends email notifications, swapped out in tests
+	sendEmail func(*v1alpha1.VDICluster, *secrets.SecretEngine, []string, email.Message, *email.Data) error
 }
 
 var _ resources.DesktopReconciler = &Reconciler{}
 
 var userdataReclaimFinalizer = "kvdi.io/userdata-reclaim"
 
-// Global map of ticker routines. The UID of the desktop is placed as a key to
-// avoid duplicate goroutines spawning.
-var tickerRoutines = make(map[types.UID]struct{})
-
 // New returns a new Desktop reconciler
 func New(c client.Client, s *runtime.Scheme) *Reconciler {
-	return &Reconciler{client: c, scheme: s}
+	return &Reconciler{client: c, scheme: s, sendEmail: sendEmail}
 }
 
 // Reconcile ensures the required resources for a desktop session.
-func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.Desktop) error {
+func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.Desktop) (err error) {
 	if instance.GetDeletionTimestamp() != nil {
 		return f.runFinalizers(reqLogger, instance)
 	}
 
-	template, err := instance.GetTemplate(f.client)
+	template, revision, err := instance.GetTemplateAndRevision(f.client)
 	if err != nil {
 		return err
 	}
+
+	// destroy the desktop if it is outside its template's availability windows
+	var windowCloses time.Time
+	if template.TerminateSessionsOutsideWindows() {
+		var available bool
+		available, windowCloses, err = template.GetAvailability(time.Now())
+		if err != nil {
+			return err
+		}
+		if !available {
+			reqLogger.Info("Desktop template is outside of its availability windows, destroying instance")
+			return client.IgnoreNotFound(f.client.Delete(context.TODO(), instance))
+		}
+	}
 	cluster, err := instance.GetVDICluster(f.client)
 	if err != nil {
 		return err
 	}
 
+	// keep track of the revision of the template the desktop is running
+	if err := f.reconcileTemplateRevision(reqLogger, cluster, instance, revision); err != nil {
+		return err
+	}
+
+	// destroy the desktop if it is placed where its user's roles do not allow
+	if err := f.reconcilePlacement(reqLogger, cluster, template, instance); err != nil {
+		return err
+	}
+
+	// continue the trace of the request that created the desktop until it is running
+	traceCtx, span := startReconcileSpan(cluster, instance)
+	defer func() { endReconcileSpan(span, err) }()
+
 	resourceNamespacedName := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
 
 	// create a PV for the user if we need to
@@ -65,8 +91,16 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.Desktop
 		}
 	}
 
+	// pooled desktops keep the resources they were booted with after being claimed
+	podInstance := newPodInstanceForCR(instance)
+
 	// create a service in front of the desktop (so we can pre-allocate an IP that resolves to the pod)
-	if err := reconcile.Service(reqLogger, f.client, newServiceForCR(cluster, instance)); err != nil {
+	if err := reconcile.Service(reqLogger, f.client, newServiceForCR(cluster, podInstance)); err != nil {
+		return err
+	}
+
+	// restrict the network access of the desktop before it boots
+	if err := f.reconcileNetworkPolicy(reqLogger, cluster, template, podInstance); err != nil {
 		return err
 	}
 
@@ -96,8 +130,28 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.Desktop
 		return err
 	}
 
+	// ensure the first-boot script for the session
+	if err := f.reconcileUserData(reqLogger, secretsEngine, cluster, template, podInstance); err != nil {
+		return err
+	}
+
+	// ensure the environment variables set from the user's secrets
+	if err := f.reconcileSessionEnv(reqLogger, secretsEngine, cluster, template, podInstance); err != nil {
+		return err
+	}
+
+	// ensure the dotfiles configuration for the session
+	if instance.DotfilesEnabled() {
+		if err := f.reconcileDotfiles(reqLogger, secretsEngine, cluster, instance); err != nil {
+			return err
+		}
+	}
+
 	// ensure the pod
-	if _, err := reconcile.Pod(reqLogger, f.client, newDesktopPodForCR(cluster, template, instance)); err != nil {
+	if _, err := reconcile.Pod(reqLogger, f.client, newDesktopPodForCR(cluster, template, podInstance)); err != nil {
+		if isPodRejection(err) {
+			return f.recordPodRejection(instance, err)
+		}
 		return err
 	}
 
@@ -108,6 +162,19 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.Desktop
 		return err
 	}
 
+	// relaunch the desktop if its node is being reclaimed
+	if cluster.PreemptionEnabled() {
+		if err := f.reconcilePreemption(reqLogger, cluster, instance, desktopPod); err != nil {
+			return err
+		}
+	}
+
+	// relaunch or destroy the desktop if its node is being drained
+	drainAt, err := f.reconcileDrain(reqLogger, instance, desktopPod)
+	if err != nil {
+		return err
+	}
+
 	if desktopPod.Status.Phase != corev1.PodRunning {
 		return f.updateNonRunningStatusAndRequeue(instance, desktopPod, "Desktop pod is not in running phase")
 	}
@@ -116,6 +183,10 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.Desktop
 			return f.updateNonRunningStatusAndRequeue(instance, desktopPod, "Desktop instance is not yet running")
 		}
 	}
+	// wait for the display to accept connections
+	if !podConditionTrue(desktopPod, corev1.PodReady) {
+		return f.updateNonRunningStatusAndRequeue(instance, desktopPod, "Desktop display is not yet ready")
+	}
 
 	if cluster.GetUserdataVolumeSpec() != nil {
 		if err := f.reconcileUserdataMapping(reqLogger, cluster, instance); err != nil {
@@ -126,60 +197,62 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.Desktop
 		}
 	}
 
-	if !instance.Status.Running {
+	if !instance.Status.Running || instance.Status.Phase != v1alpha1.DesktopPhaseReady {
+		started := !instance.Status.Running
 		instance.Status.PodPhase = desktopPod.Status.Phase
+		instance.Status.Phase = v1alpha1.DesktopPhaseReady
+		instance.Status.PodError = ""
 		instance.Status.Running = true
 		if err := f.client.Status().Update(context.TODO(), instance); err != nil {
 			return err
 		}
-	}
-
-	// start a timer to kill the desktop if max session length is set
-	if dur := cluster.GetMaxSessionLength(); dur != 0 {
-		if _, ok := tickerRoutines[instance.GetUID()]; ok {
-			// we already have a goroutine running, we are done here
-			return nil
+		if started {
+			recordStartup(traceCtx, cluster, instance)
 		}
-		tickerRoutines[instance.GetUID()] = struct{}{}
-		go func() {
-			reqLogger.Info("Starting session timer for desktop instance.")
-
-			// make sure to clean the global map on return
-			defer func() { delete(tickerRoutines, instance.GetUID()) }()
+	}
 
-			// define the namespaced name and setup tickers
-			nn := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
-			sessTicker := time.NewTicker(dur)
-			pollTicker := time.NewTicker(time.Duration(10) * time.Second)
+	// destroy the desktop if it has gone idle
+	idleAt, err := f.reconcileIdleTimeout(reqLogger, cluster, template, instance)
+	if err != nil {
+		return err
+	}
 
-			// listen on the ticker channels
-			for {
-				select {
+	// destroy the desktop if it has reached its maximum lifetime
+	expiresAt, err := f.reconcileMaxLifetime(reqLogger, cluster, template, instance)
+	if err != nil {
+		return err
+	}
 
-				case <-sessTicker.C:
-					// the desktop session has expired
-					reqLogger.Info("Desktop session has expired, destroying instance")
-					if err := f.client.Delete(context.TODO(), instance); err != nil {
-						if client.IgnoreNotFound(err) != nil {
-							reqLogger.Error(err, fmt.Sprintf("Error destroying desktop instance: %s", err.Error()))
-						}
-					}
-					return
+	// let the user know ahead of time when the desktop will be destroyed
+	if err := f.reconcileTerminationStatus(instance, expiresAt, windowCloses); err != nil {
+		return err
+	}
 
-				case <-pollTicker.C:
-					// return if desktop has been deleted
-					if err := f.client.Get(context.TODO(), nn, &v1alpha1.Desktop{}); err != nil {
-						if client.IgnoreNotFound(err) == nil {
-							reqLogger.Info("Desktop instance has been deleted, stopping session poll")
-							return
-						}
-						reqLogger.Error(err, fmt.Sprintf("Error polling desktop instance: %s", err.Error()))
-						// retry on next loop
-					}
+	// and email them shortly before it happens
+	warnAt, err := f.reconcileExpiryWarning(reqLogger, secretsEngine, cluster, instance)
+	if err != nil {
+		return err
+	}
 
-				}
-			}
-		}()
+	// requeue for whichever of the pending deadlines comes first
+	var requeueAt time.Time
+	var requeueMsg string
+	for _, deadline := range []struct {
+		at  time.Time
+		msg string
+	}{
+		{idleAt, "Desktop idle timeout is pending"},
+		{drainAt, "Desktop node is being drained"},
+		{windowCloses, "Desktop template availability window is open"},
+		{expiresAt, "Desktop maximum lifetime is pending"},
+		{warnAt, "Desktop expiry warning is pending"},
+	} {
+		if !deadline.at.IsZero() && (requeueAt.IsZero() || deadline.at.Before(requeueAt)) {
+			requeueAt, requeueMsg = deadline.at, deadline.msg
+		}
+	}
+	if !requeueAt.IsZero() {
+		return errors.NewRequeueError(requeueMsg, int(time.Until(requeueAt).Seconds())+1)
 	}
 
 	return nil
@@ -188,6 +261,8 @@ func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.Desktop
 func (f *Reconciler) updateNonRunningStatusAndRequeue(instance *v1alpha1.Desktop, pod *corev1.Pod, msg string) error {
 	instance.Status.Running = false
 	instance.Status.PodPhase = pod.Status.Phase
+	instance.Status.Phase = getDesktopPhase(pod)
+	instance.Status.PodError = getPodStartError(pod)
 	if err := f.client.Status().Update(context.TODO(), instance); err != nil {
 		return err
 	}
@@ -11,6 +11,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,6 +31,7 @@ func newReconciler(t *testing.T) *Reconciler {
 	corev1.AddToScheme(scheme)
 	appsv1.AddToScheme(scheme)
 	rbacv1.AddToScheme(scheme)
+	networkingv1.AddToScheme(scheme)
 	return New(fake.NewFakeClientWithScheme(scheme), scheme)
 }
 
@@ -179,6 +181,36 @@ func TestReconcile(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// error should be waiting for the display to be ready
+	if err := r.Reconcile(testLogger, desktop); err != nil {
+		if qerr, ok := errors.IsRequeueError(err); !ok {
+			t.Error("Expected requeue error, got:", err)
+		} else if !strings.Contains(qerr.Error(), "display is not yet ready") {
+			t.Error("Expected waiting for display ready, got:", qerr)
+		}
+	} else if err == nil {
+		t.Error("Expected error got nil")
+	}
+	found := &v1alpha1.Desktop{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Status.Phase != v1alpha1.DesktopPhaseScheduling {
+		t.Error("Expected pod without conditions to be scheduling, got:", found.Status.Phase)
+	}
+
+	// mark the pod ready
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: desktop.GetName(), Namespace: desktop.GetNamespace()}, pod); err != nil {
+		t.Fatal(err)
+	}
+	pod.Status.Conditions = []corev1.PodCondition{
+		{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+		{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+	}
+	if err := r.client.Status().Update(context.TODO(), pod); err != nil {
+		t.Fatal(err)
+	}
+
 	// reconciler should be waiting for a volume
 	if err := r.Reconcile(testLogger, desktop); err != nil {
 		if qerr, ok := errors.IsRequeueError(err); !ok {
@@ -211,6 +243,9 @@ func TestReconcile(t *testing.T) {
 	if err := r.Reconcile(testLogger, desktop); err != nil {
 		t.Error("Expected reconcile to finish completely, got:", err)
 	}
+	if !desktop.Status.Running || desktop.Status.Phase != v1alpha1.DesktopPhaseReady {
+		t.Error("Expected desktop to be running and ready, got:", desktop.Status)
+	}
 
 	// mock a deletion
 	now := metav1.Now()
@@ -0,0 +1,30 @@
+package desktop
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// reconcileTemplateRevision records the current revision of the desktop's template,
+// in case it was changed outside of the API, and the revision the desktop is running
+// in its status.
+func (f *Reconciler) reconcileTemplateRevision(reqLogger logr.Logger, cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop, revision int64) error {
+	tmpl := &v1alpha1.DesktopTemplate{}
+	if err := f.client.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.Template, Namespace: metav1.NamespaceAll}, tmpl); err != nil {
+		return err
+	}
+	if err := tmpl.RecordRevision(f.client, cluster.GetCoreNamespace()); err != nil {
+		return err
+	}
+	if instance.Status.TemplateRevision == revision {
+		return nil
+	}
+	reqLogger.Info("Desktop is using a new revision of its template", "Template", tmpl.GetName(), "Revision", revision)
+	instance.Status.TemplateRevision = revision
+	return f.client.Status().Update(context.TODO(), instance)
+}
@@ -0,0 +1,96 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileTemplateRevision(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	pinned := newDesktop(t)
+	pinned.Name = "pinned-desktop"
+	tmpl := newTemplate(t)
+	tmpl.Generation = 1
+	tmpl.Spec.Image = "image:1"
+	for _, obj := range []runtime.Object{cluster, desktop, tmpl} {
+		if err := r.client.Create(context.TODO(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	updateTemplate := func(update func(*v1alpha1.DesktopTemplate)) {
+		t.Helper()
+		update(tmpl)
+		tmpl.Generation++
+		if err := r.client.Update(context.TODO(), tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkRevision := func(desktop *v1alpha1.Desktop, revision int64, image string) {
+		t.Helper()
+		found, rev, err := desktop.GetTemplateAndRevision(r.client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != revision || found.Spec.Image != image {
+			t.Errorf("Expected revision %d with image %s, got %d with %s", revision, image, rev, found.Spec.Image)
+		}
+		if err := r.reconcileTemplateRevision(testLogger, cluster, desktop, rev); err != nil {
+			t.Fatal(err)
+		}
+		if desktop.Status.TemplateRevision != revision {
+			t.Errorf("Expected status revision %d, got %d", revision, desktop.Status.TemplateRevision)
+		}
+	}
+
+	// new desktops use the current revision, which is recorded
+	checkRevision(desktop, 1, "image:1")
+	revs, err := tmpl.GetRevisions(r.client, cluster.GetCoreNamespace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 1 || revs[0].Revision != 1 || !revs[0].Current {
+		t.Fatal("Expected the first revision to be recorded, got:", revs)
+	}
+
+	// running desktops keep their revision by default
+	updateTemplate(func(tmpl *v1alpha1.DesktopTemplate) { tmpl.Spec.Image = "image:2" })
+	checkRevision(desktop, 1, "image:1")
+
+	// desktops follow the template with the recreate policy, unless they are pinned
+	updateTemplate(func(tmpl *v1alpha1.DesktopTemplate) {
+		tmpl.Spec.SessionUpdatePolicy = v1alpha1.SessionUpdateRecreate
+	})
+	checkRevision(desktop, 3, "image:2")
+	pinned.Spec.TemplateRevision = 1
+	if err := r.client.Create(context.TODO(), pinned); err != nil {
+		t.Fatal(err)
+	}
+	checkRevision(pinned, 1, "image:1")
+
+	// old revisions are pruned unless a desktop uses them
+	limit := int32(0)
+	updateTemplate(func(tmpl *v1alpha1.DesktopTemplate) { tmpl.Spec.RevisionHistoryLimit = &limit })
+	if err := tmpl.RecordRevision(r.client, cluster.GetCoreNamespace()); err != nil {
+		t.Fatal(err)
+	}
+	for rev, expected := range map[int64]bool{1: true, 2: false, 3: true, 4: true} {
+		nn := types.NamespacedName{Name: tmpl.GetRevisionName(rev), Namespace: cluster.GetCoreNamespace()}
+		err := r.client.Get(context.TODO(), nn, &appsv1.ControllerRevision{})
+		if client.IgnoreNotFound(err) != nil {
+			t.Fatal(err)
+		}
+		if exists := err == nil; exists != expected {
+			t.Errorf("Expected revision %d to exist: %v", rev, expected)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package desktop
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileSessionEnv ensures the secret holding the values of environment variables
+// set from the user's secrets for the desktop. Variables for secrets the user does
+// not have are left out. If there are none, any existing secret is removed.
+func (f *Reconciler) reconcileSessionEnv(reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, cluster *v1alpha1.VDICluster, tmpl *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop) error {
+	userSecrets, err := getUserSecrets(secretsEngine, instance.GetUser())
+	if err != nil {
+		return err
+	}
+	data := make(map[string][]byte)
+	for name, secret := range tmpl.GetSessionSecretEnv(instance) {
+		if value, ok := userSecrets[secret]; ok {
+			data[name] = []byte(value)
+		}
+	}
+	secret := newSessionEnvSecretForCR(cluster, instance, data)
+	if len(data) == 0 {
+		return client.IgnoreNotFound(f.client.Delete(context.TODO(), secret))
+	}
+	return reconcile.Secret(reqLogger, f.client, secret)
+}
+
+// getUserSecrets returns the secrets of the given user. An empty map is returned if
+// the user has none.
+func getUserSecrets(secretsEngine *secrets.SecretEngine, user string) (map[string]string, error) {
+	userSecrets := make(map[string]string)
+	users, err := secretsEngine.ReadSecretMap(v1.UserSecretsSecretKey, false)
+	if err != nil {
+		if errors.IsSecretNotFoundError(err) {
+			return userSecrets, nil
+		}
+		return nil, err
+	}
+	if data, ok := users[user]; ok {
+		if err := json.Unmarshal(data, &userSecrets); err != nil {
+			return nil, err
+		}
+	}
+	return userSecrets, nil
+}
+
+func newSessionEnvSecretForCR(cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetSessionEnvSecretName(),
+			Namespace:       instance.GetNamespace(),
+			Labels:          cluster.GetDesktopLabels(instance),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Data: data,
+	}
+}
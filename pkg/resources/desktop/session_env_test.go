@@ -0,0 +1,74 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileSessionEnv(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	tmpl := newTemplate(t)
+
+	secretsEngine := secrets.GetSecretEngine(cluster)
+	if err := secretsEngine.Setup(r.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	nn := types.NamespacedName{Name: desktop.GetSessionEnvSecretName(), Namespace: desktop.GetNamespace()}
+
+	// no secret env anywhere should not create a secret
+	if err := r.reconcileSessionEnv(testLogger, secretsEngine, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, &corev1.Secret{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected session env secret to not exist, got:", err)
+	}
+
+	// only secrets the user has should be included
+	tmpl.Spec.SessionEnv = &v1alpha1.SessionEnvConfig{
+		UserSecrets: map[string]string{"GIT_TOKEN": "git"},
+	}
+	desktop.Spec.SecretEnv = map[string]string{"API_KEY": "api", "MISSING": "missing"}
+	if err := secretsEngine.WriteSecretMap(v1.UserSecretsSecretKey, map[string][]byte{
+		desktop.GetUser(): []byte(`{"git": "git-value", "api": "api-value"}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileSessionEnv(testLogger, secretsEngine, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), nn, secret); err != nil {
+		t.Fatal(err)
+	}
+	if len(secret.Data) != 2 {
+		t.Error("Expected two values in the session env secret, got:", secret.Data)
+	}
+	if val := string(secret.Data["GIT_TOKEN"]); val != "git-value" {
+		t.Error("Expected template secret value, got:", val)
+	}
+	if val := string(secret.Data["API_KEY"]); val != "api-value" {
+		t.Error("Expected requested secret value, got:", val)
+	}
+
+	// removing the user's secrets should remove the secret
+	if err := secretsEngine.WriteSecretMap(v1.UserSecretsSecretKey, map[string][]byte{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileSessionEnv(testLogger, secretsEngine, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, &corev1.Secret{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected session env secret to be removed, got:", err)
+	}
+}
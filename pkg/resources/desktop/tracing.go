@@ -0,0 +1,57 @@
+package desktop
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/tracing"
+)
+
+// tracingServiceName is the service spans from the manager are reported under.
+const tracingServiceName = "kvdi-manager"
+
+// startReconcileSpan starts a span for a reconcile of the given desktop if it was
+// created by a traced request and is not running yet. Once the desktop is running
+// its reconciles are no longer traced.
+func startReconcileSpan(cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop) (context.Context, *tracing.Span) {
+	ctx := context.TODO()
+	if instance.Status.Running {
+		return ctx, nil
+	}
+	parent, ok := tracing.ParseTraceParent(instance.GetAnnotations()[v1.TraceParentAnnotation])
+	if !ok {
+		return ctx, nil
+	}
+	ctx, span := tracing.ForConfig(tracingServiceName, cluster.GetTracing()).Start(
+		tracing.ContextWithRemoteParent(ctx, parent),
+		"Reconcile Desktop",
+	)
+	span.SetAttribute("kvdi.desktop.name", instance.GetName())
+	span.SetAttribute("kvdi.desktop.namespace", instance.GetNamespace())
+	span.SetAttribute("kvdi.desktop.template", instance.Spec.Template)
+	return ctx, span
+}
+
+// endReconcileSpan ends the given reconcile span. Requeues are expected while
+// waiting for the desktop to start and are not recorded as errors.
+func endReconcileSpan(span *tracing.Span, err error) {
+	if _, ok := errors.IsRequeueError(err); !ok {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+// recordStartup records a span covering the time from the creation of the given
+// desktop until it was first seen running.
+func recordStartup(ctx context.Context, cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop) {
+	_, span := tracing.ForConfig(tracingServiceName, cluster.GetTracing()).Start(
+		ctx,
+		"Desktop Startup",
+		tracing.WithStartTime(instance.GetCreationTimestamp().Time),
+	)
+	span.SetAttribute("kvdi.desktop.name", instance.GetName())
+	span.SetAttribute("kvdi.desktop.namespace", instance.GetNamespace())
+	span.End()
+}
@@ -0,0 +1,67 @@
+package desktop
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileUserData ensures the secret holding the first-boot script for the desktop.
+// A script configured by the user takes precedence over the one in the template. If
+// neither is set, any existing secret is removed.
+func (f *Reconciler) reconcileUserData(reqLogger logr.Logger, secretsEngine *secrets.SecretEngine, cluster *v1alpha1.VDICluster, tmpl *v1alpha1.DesktopTemplate, instance *v1alpha1.Desktop) error {
+	userData, fromUser, err := getUserData(secretsEngine, tmpl, instance.GetUser())
+	if err != nil {
+		return err
+	}
+	// Scripts supplied by users only run as root if the template allows it
+	runAs := "root"
+	if fromUser && !tmpl.RootEnabled() {
+		runAs = instance.GetUser()
+	}
+	secret := newUserDataSecretForCR(cluster, instance, userData, runAs)
+	if userData == "" {
+		return client.IgnoreNotFound(f.client.Delete(context.TODO(), secret))
+	}
+	return reconcile.Secret(reqLogger, f.client, secret)
+}
+
+// getUserData returns the first-boot script to use for the given user, and whether
+// it was supplied by the user.
+func getUserData(secretsEngine *secrets.SecretEngine, tmpl *v1alpha1.DesktopTemplate, user string) (userData string, fromUser bool, err error) {
+	users, err := secretsEngine.ReadSecretMap(v1.UserDataSecretKey, false)
+	if err != nil {
+		if !errors.IsSecretNotFoundError(err) {
+			return "", false, err
+		}
+		return tmpl.GetUserData(), false, nil
+	}
+	if userData, ok := users[user]; ok && len(userData) > 0 {
+		return string(userData), true, nil
+	}
+	return tmpl.GetUserData(), false, nil
+}
+
+func newUserDataSecretForCR(cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop, userData, runAs string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            instance.GetUserDataSecretName(),
+			Namespace:       instance.GetNamespace(),
+			Labels:          cluster.GetDesktopLabels(instance),
+			OwnerReferences: instance.OwnerReferences(),
+		},
+		Data: map[string][]byte{
+			v1.UserDataFileName:      []byte(userData),
+			v1.UserDataRunAsFileName: []byte(runAs),
+		},
+	}
+}
@@ -0,0 +1,85 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileUserData(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	tmpl := newTemplate(t)
+
+	secretsEngine := secrets.GetSecretEngine(cluster)
+	if err := secretsEngine.Setup(r.client, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	nn := types.NamespacedName{Name: desktop.GetUserDataSecretName(), Namespace: desktop.GetNamespace()}
+	getUserDataSecret := func() *corev1.Secret {
+		t.Helper()
+		secret := &corev1.Secret{}
+		if err := r.client.Get(context.TODO(), nn, secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	// no script anywhere should not create a secret
+	if err := r.reconcileUserData(testLogger, secretsEngine, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, &corev1.Secret{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected userdata secret to not exist, got:", err)
+	}
+
+	// the template script should be used when the user has none
+	tmpl.Spec.UserData = "echo template"
+	if err := r.reconcileUserData(testLogger, secretsEngine, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	secret := getUserDataSecret()
+	if data := string(secret.Data[v1.UserDataFileName]); data != "echo template" {
+		t.Error("Expected template user data, got:", data)
+	}
+	if runAs := string(secret.Data[v1.UserDataRunAsFileName]); runAs != "root" {
+		t.Error("Expected template user data to run as root, got:", runAs)
+	}
+
+	// the user's script should take precedence
+	if err := secretsEngine.WriteSecretMap(v1.UserDataSecretKey, map[string][]byte{
+		desktop.GetUser(): []byte("echo user"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileUserData(testLogger, secretsEngine, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	secret = getUserDataSecret()
+	if data := string(secret.Data[v1.UserDataFileName]); data != "echo user" {
+		t.Error("Expected user's user data, got:", data)
+	}
+	if runAs := string(secret.Data[v1.UserDataRunAsFileName]); runAs != desktop.GetUser() {
+		t.Error("Expected user's user data to run as the user, got:", runAs)
+	}
+
+	// removing both should remove the secret
+	tmpl.Spec.UserData = ""
+	if err := secretsEngine.WriteSecretMap(v1.UserDataSecretKey, map[string][]byte{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileUserData(testLogger, secretsEngine, cluster, tmpl, desktop); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.TODO(), nn, &corev1.Secret{}); client.IgnoreNotFound(err) != nil || err == nil {
+		t.Error("Expected userdata secret to be removed, got:", err)
+	}
+}
@@ -64,6 +64,10 @@ func (f *Reconciler) reclaimVolumes(reqLogger logr.Logger, instance *v1alpha1.De
 			return err
 		}
 
+		if err := f.markPVLastUsed(pv); err != nil {
+			return err
+		}
+
 		reqLogger.Info("Freeing pv from old pvc claim")
 		if changed, err := f.freePV(pv); err != nil {
 			return err
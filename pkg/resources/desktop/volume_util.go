@@ -2,8 +2,10 @@ package desktop
 
 import (
 	"context"
+	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,6 +29,18 @@ func (f *Reconciler) freePV(pv *corev1.PersistentVolume) (bool, error) {
 	return changed, nil
 }
 
+// markPVLastUsed records the current time on the given PV so the garbage collector
+// can enforce the userdata retention period.
+func (f *Reconciler) markPVLastUsed(pv *corev1.PersistentVolume) error {
+	annotations := pv.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[v1.UserdataLastUsedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	pv.SetAnnotations(annotations)
+	return f.client.Update(context.TODO(), pv)
+}
+
 func (f *Reconciler) getPVCForInstance(cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop) (*corev1.PersistentVolumeClaim, error) {
 	pvcNN := types.NamespacedName{
 		Name:      cluster.GetUserdataVolumeName(instance.GetUser()),
@@ -5,6 +5,7 @@ import (
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
 	"github.com/tinyzimmer/kvdi/pkg/util/reconcile"
 
 	"github.com/go-logr/logr"
@@ -30,6 +31,11 @@ func (f *Reconciler) reconcileVolumes(reqLogger logr.Logger, cluster *v1alpha1.V
 			existingVol = ""
 		}
 	}
+	// Volumes restored from a snapshot are always provisioned fresh. The user's
+	// previous volume is retained, but is unmapped once the new claim is bound.
+	if instance.Spec.Snapshot != "" {
+		existingVol = ""
+	}
 	pvc := newPVCForUser(cluster, instance, existingVol)
 	return reconcile.PersistentVolumeClaim(reqLogger, f.client, pvc)
 }
@@ -91,10 +97,18 @@ func newConfigMapForCluster(cluster *v1alpha1.VDICluster) *corev1.ConfigMap {
 }
 
 func newPVCForUser(cluster *v1alpha1.VDICluster, instance *v1alpha1.Desktop, existingPVName string) *corev1.PersistentVolumeClaim {
-	spec := cluster.GetUserdataVolumeSpec()
+	spec := cluster.GetUserdataVolumeSpec().DeepCopy()
 	if existingPVName != "" {
 		spec.VolumeName = existingPVName
 	}
+	if instance.Spec.Snapshot != "" {
+		apiGroup := k8sutil.VolumeSnapshotAPIGroup
+		spec.DataSource = &corev1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     "VolumeSnapshot",
+			Name:     instance.Spec.Snapshot,
+		}
+	}
 	return &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            cluster.GetUserdataVolumeName(instance.GetUser()),
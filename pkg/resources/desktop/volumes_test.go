@@ -0,0 +1,48 @@
+package desktop
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileVolumesFromSnapshot(t *testing.T) {
+	r := newReconciler(t)
+	cluster := newCluster(t)
+	desktop := newDesktop(t)
+	desktop.Spec.User = "test-user"
+	desktop.Spec.Snapshot = "test-snapshot"
+
+	// the user already has a volume
+	pv := &corev1.PersistentVolume{}
+	pv.Name = "previous-volume"
+	if err := r.client.Create(context.TODO(), pv); err != nil {
+		t.Fatal(err)
+	}
+	volMap, err := r.getVolMapForCluster(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	volMap.Data = map[string]string{"test-user": pv.Name}
+	if err := r.client.Update(context.TODO(), volMap); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.reconcileVolumes(testLogger, cluster, desktop); err != nil {
+		t.Fatal(err)
+	}
+	pvc, err := r.getPVCForInstance(cluster, desktop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pvc.Spec.VolumeName != "" {
+		t.Error("Expected a new volume to be provisioned for the restore, got:", pvc.Spec.VolumeName)
+	}
+	if pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Kind != "VolumeSnapshot" || pvc.Spec.DataSource.Name != "test-snapshot" {
+		t.Error("Expected claim to be restored from the snapshot, got:", pvc.Spec.DataSource)
+	}
+	if cluster.Spec.UserDataSpec.DataSource != nil {
+		t.Error("Expected the cluster's userdata spec to be left alone")
+	}
+}
@@ -23,3 +23,9 @@ type DesktopReconciler interface {
 
 // DesktopClusterReconcileFunc is a function for reconciling desktop resources.
 type DesktopClusterReconcileFunc func(logr.Logger, *v1alpha1.Desktop) error
+
+// SessionPoolReconciler represents an interface for ensuring the desktops in
+// a session pool.
+type SessionPoolReconciler interface {
+	Reconcile(logr.Logger, *v1alpha1.SessionPool) error
+}
@@ -0,0 +1,2 @@
+// Package sessionpool contains reconciliation logic for the desktops in a SessionPool.
+package sessionpool
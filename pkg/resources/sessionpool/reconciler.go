@@ -0,0 +1,140 @@
+package sessionpool
+
+import (
+	"context"
+	"sort"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/resources"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reconciler implements a reconciler for the desktops in a SessionPool.
+type Reconciler struct {
+	resources.SessionPoolReconciler
+
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// blank assignment to make sure Reconciler satisfies the SessionPoolReconciler interface
+var _ resources.SessionPoolReconciler = &Reconciler{}
+
+// New returns a new SessionPool reconciler
+func New(c client.Client, s *runtime.Scheme) *Reconciler {
+	return &Reconciler{client: c, scheme: s}
+}
+
+// Reconcile boots or destroys desktops until the number waiting to be claimed
+// matches the size of the pool.
+func (f *Reconciler) Reconcile(reqLogger logr.Logger, instance *v1alpha1.SessionPool) error {
+	cluster, err := instance.GetVDICluster(f.client)
+	if err != nil {
+		return err
+	}
+	if cluster.GetUserdataVolumeSpec() != nil {
+		reqLogger.Info("VDICluster has userdata volumes configured, session pools are not supported")
+		return nil
+	}
+
+	tmpl := &v1alpha1.DesktopTemplate{}
+	if err := f.client.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.Template, Namespace: metav1.NamespaceAll}, tmpl); err != nil {
+		return err
+	}
+	tmpl, err = tmpl.GetEffectiveTemplate(f.client)
+	if err != nil {
+		return err
+	}
+
+	warm, err := f.getWarmDesktops(instance)
+	if err != nil {
+		return err
+	}
+
+	size := instance.GetSize()
+
+	// destroy any desktops beyond the size of the pool, starting with the ones
+	// that are not running yet
+	if len(warm) > size {
+		sort.SliceStable(warm, func(i, j int) bool { return !warm[i].Status.Running && warm[j].Status.Running })
+		for _, desktop := range warm[:len(warm)-size] {
+			reqLogger.Info("Destroying desktop beyond the size of the pool", "Desktop.Name", desktop.GetName())
+			if err := f.client.Delete(context.TODO(), &desktop); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+		warm = warm[len(warm)-size:]
+	}
+
+	// boot new desktops to fill the pool, without exceeding the capacity of the template
+	if len(warm) < size {
+		missing := size - len(warm)
+		if max := int(tmpl.GetMaxSessions()); max > 0 {
+			running, err := f.countTemplateSessions(cluster, tmpl)
+			if err != nil {
+				return err
+			}
+			if available := max - running; available < missing {
+				missing = available
+			}
+		}
+		for i := 0; i < missing; i++ {
+			desktop := instance.NewDesktop()
+			reqLogger.Info("Booting new desktop for the pool", "Desktop.Name", desktop.GetName())
+			if err := f.client.Create(context.TODO(), desktop); err != nil {
+				return err
+			}
+			warm = append(warm, *desktop)
+		}
+	}
+
+	var ready int32
+	for _, desktop := range warm {
+		if desktop.Status.Running {
+			ready++
+		}
+	}
+	if instance.Status.Warm != int32(len(warm)) || instance.Status.Ready != ready {
+		instance.Status.Warm = int32(len(warm))
+		instance.Status.Ready = ready
+		return f.client.Status().Update(context.TODO(), instance)
+	}
+
+	return nil
+}
+
+// getWarmDesktops returns the desktops in the pool that have not been claimed yet.
+func (f *Reconciler) getWarmDesktops(instance *v1alpha1.SessionPool) ([]v1alpha1.Desktop, error) {
+	desktops := &v1alpha1.DesktopList{}
+	if err := f.client.List(context.TODO(), desktops, client.InNamespace(instance.GetNamespace()), instance.GetDesktopsSelector()); err != nil {
+		return nil, err
+	}
+	warm := make([]v1alpha1.Desktop, 0)
+	for _, desktop := range desktops.Items {
+		if desktop.GetDeletionTimestamp() == nil && desktop.IsWarm() {
+			warm = append(warm, desktop)
+		}
+	}
+	return warm, nil
+}
+
+// countTemplateSessions returns the number of active desktops in the cluster booted
+// from the given template.
+func (f *Reconciler) countTemplateSessions(cluster *v1alpha1.VDICluster, tmpl *v1alpha1.DesktopTemplate) (int, error) {
+	desktops := &v1alpha1.DesktopList{}
+	if err := f.client.List(context.TODO(), desktops); err != nil {
+		return 0, err
+	}
+	var count int
+	for _, desktop := range desktops.Items {
+		if desktop.Spec.VDICluster == cluster.GetName() && desktop.Spec.Template == tmpl.GetName() && desktop.IsActive() {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,144 @@
+package sessionpool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis"
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var testLogger = logf.Log.WithName("test")
+
+func newReconciler(t *testing.T) *Reconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	apis.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	cluster := &v1alpha1.VDICluster{}
+	cluster.Name = "test-cluster"
+	tmpl := &v1alpha1.DesktopTemplate{}
+	tmpl.Name = "test-template"
+	return New(fake.NewFakeClientWithScheme(scheme, cluster, tmpl), scheme)
+}
+
+func newPool(t *testing.T, size int32) *v1alpha1.SessionPool {
+	t.Helper()
+	pool := &v1alpha1.SessionPool{}
+	pool.Name = "test-pool"
+	pool.Namespace = "test-namespace"
+	pool.Spec = v1alpha1.SessionPoolSpec{
+		VDICluster: "test-cluster",
+		Template:   "test-template",
+		Size:       size,
+	}
+	return pool
+}
+
+func mustGetWarm(t *testing.T, r *Reconciler, pool *v1alpha1.SessionPool) []v1alpha1.Desktop {
+	t.Helper()
+	warm, err := r.getWarmDesktops(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return warm
+}
+
+func TestReconcile(t *testing.T) {
+	r := newReconciler(t)
+	pool := newPool(t, 2)
+	if err := r.client.Create(context.TODO(), pool); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pool should be filled
+	if err := r.Reconcile(testLogger, pool); err != nil {
+		t.Fatal(err)
+	}
+	warm := mustGetWarm(t, r, pool)
+	if len(warm) != 2 {
+		t.Fatal("Expected two warm desktops, got:", len(warm))
+	}
+	if pool.Status.Warm != 2 || pool.Status.Ready != 0 {
+		t.Error("Expected two warm and no ready desktops, got:", pool.Status)
+	}
+
+	// running desktops should be reported as ready
+	warm[0].Status.Running = true
+	if err := r.client.Status().Update(context.TODO(), &warm[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reconcile(testLogger, pool); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Status.Ready != 1 {
+		t.Error("Expected one ready desktop, got:", pool.Status.Ready)
+	}
+
+	// claimed desktops should be replaced
+	claimed := warm[0].DeepCopy()
+	claimed.Spec.User = "test-user"
+	claimed.SetLabels(map[string]string{})
+	claimed.SetOwnerReferences(nil)
+	if err := r.client.Update(context.TODO(), claimed); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reconcile(testLogger, pool); err != nil {
+		t.Fatal(err)
+	}
+	if warm = mustGetWarm(t, r, pool); len(warm) != 2 {
+		t.Error("Expected claimed desktop to be replaced, got:", len(warm))
+	}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: claimed.GetName(), Namespace: claimed.GetNamespace()}, &v1alpha1.Desktop{}); err != nil {
+		t.Error("Expected claimed desktop to be left alone, got:", err)
+	}
+
+	// shrinking the pool should destroy the extra desktops
+	warm[1].Status.Running = true
+	if err := r.client.Status().Update(context.TODO(), &warm[1]); err != nil {
+		t.Fatal(err)
+	}
+	pool.Spec.Size = 1
+	if err := r.Reconcile(testLogger, pool); err != nil {
+		t.Fatal(err)
+	}
+	if warm = mustGetWarm(t, r, pool); len(warm) != 1 {
+		t.Fatal("Expected one warm desktop, got:", len(warm))
+	} else if !warm[0].Status.Running {
+		t.Error("Expected the running desktop to be kept")
+	}
+}
+
+func TestReconcileTemplateCapacity(t *testing.T) {
+	r := newReconciler(t)
+	tmpl := &v1alpha1.DesktopTemplate{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "test-template"}, tmpl); err != nil {
+		t.Fatal(err)
+	}
+	tmpl.Spec.MaxSessions = 3
+	if err := r.client.Update(context.TODO(), tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := newPool(t, 5)
+	if err := r.client.Create(context.TODO(), pool); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reconcile(testLogger, pool); err != nil {
+		t.Fatal(err)
+	}
+	desktops := &v1alpha1.DesktopList{}
+	if err := r.client.List(context.TODO(), desktops, client.InNamespace("test-namespace")); err != nil {
+		t.Fatal(err)
+	}
+	if len(desktops.Items) != 3 {
+		t.Error("Expected pool to be limited by template capacity, got:", len(desktops.Items))
+	}
+}
@@ -1,6 +1,6 @@
 // Package secrets contains an engine for reading and writing secrets from
-// configurable backends. Currently only a K8s secret backend provider is
-// available, but eventually other interfaces can be added such as for vault.
+// configurable backends. Kubernetes secrets, vault, AWS Secrets Manager, and GCP
+// Secret Manager are currently supported.
 //
 // The purpose of this package is to provide "filesystem" like access to
 // sensitive values, e.g. JWT signing secrets, user credential hashes, OTP secrets,
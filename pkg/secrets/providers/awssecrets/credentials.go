@@ -0,0 +1,201 @@
+package awssecrets
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/sigv4"
+)
+
+// credentialsExpiryWindow is how long before they expire that credentials are
+// refreshed.
+const credentialsExpiryWindow = 5 * time.Minute
+
+// Default endpoints used when loading credentials.
+const (
+	defaultIMDSEndpoint = "http://169.254.169.254"
+	stsEndpointFormat   = "https://sts.%s.amazonaws.com"
+)
+
+// credentials are the AWS credentials used for signing requests.
+type credentials struct {
+	sigv4.Credentials
+	// the time the credentials expire, zero if they do not
+	Expiration time.Time
+}
+
+// expired returns true if the credentials are expired or about to.
+func (c *credentials) expired(now time.Time) bool {
+	return !c.Expiration.IsZero() && now.Add(credentialsExpiryWindow).After(c.Expiration)
+}
+
+// getCredentials returns the cached credentials, loading new ones if they are
+// missing or expired.
+func (p *Provider) getCredentials() (*credentials, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.creds != nil && !p.creds.expired(time.Now()) {
+		return p.creds, nil
+	}
+	creds, err := p.loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	p.creds = creds
+	return creds, nil
+}
+
+// loadDefaultCredentials loads credentials from the environment, a web identity
+// token, or the instance metadata service, in that order.
+func (p *Provider) loadDefaultCredentials() (*credentials, error) {
+	if keyID := os.Getenv("AWS_ACCESS_KEY_ID"); keyID != "" {
+		return &credentials{Credentials: sigv4.Credentials{
+			AccessKeyID:     keyID,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}}, nil
+	}
+	if roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); roleARN != "" && tokenFile != "" {
+		return p.loadWebIdentityCredentials(roleARN, tokenFile)
+	}
+	return p.loadIMDSCredentials()
+}
+
+// assumeRoleWithWebIdentityResponse is the response from an AssumeRoleWithWebIdentity
+// request to STS.
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// loadWebIdentityCredentials exchanges the web identity token in the given file
+// for credentials for the given role. This is how IAM roles for service accounts
+// are assumed.
+func (p *Provider) loadWebIdentityCredentials(roleARN, tokenFile string) (*credentials, error) {
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("kvdi-%d", time.Now().Unix())
+	}
+	query := url.Values{
+		"Action":           []string{"AssumeRoleWithWebIdentity"},
+		"Version":          []string{"2011-06-15"},
+		"RoleArn":          []string{roleARN},
+		"RoleSessionName":  []string{sessionName},
+		"WebIdentityToken": []string{strings.TrimSpace(string(token))},
+	}
+	res, err := p.httpClient.PostForm(p.stsEndpoint+"/", query)
+	if err != nil {
+		return nil, err
+	}
+	body, err := readResponse(res)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to assume role %s with web identity: %s", roleARN, err.Error())
+	}
+	out := &assumeRoleWithWebIdentityResponse{}
+	if err := xml.Unmarshal(body, out); err != nil {
+		return nil, err
+	}
+	return &credentials{
+		Credentials: sigv4.Credentials{
+			AccessKeyID:     out.Credentials.AccessKeyID,
+			SecretAccessKey: out.Credentials.SecretAccessKey,
+			SessionToken:    out.Credentials.SessionToken,
+		},
+		Expiration: out.Credentials.Expiration,
+	}, nil
+}
+
+// imdsCredentialsResponse is the response from the instance metadata service for
+// the credentials of an instance role.
+type imdsCredentialsResponse struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// loadIMDSCredentials loads the credentials for the role attached to the instance
+// from the instance metadata service, using IMDSv2.
+func (p *Provider) loadIMDSCredentials() (*credentials, error) {
+	req, err := http.NewRequest(http.MethodPut, p.imdsEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	token, err := p.doIMDS(req)
+	if err != nil {
+		return nil, err
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequest(http.MethodGet, p.imdsEndpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return p.doIMDS(req)
+	}
+	role, err := get("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return nil, err
+	}
+	roleName := strings.TrimSpace(strings.Split(string(role), "\n")[0])
+	if roleName == "" {
+		return nil, errors.New("No instance role found in the instance metadata service")
+	}
+	body, err := get("/latest/meta-data/iam/security-credentials/" + roleName)
+	if err != nil {
+		return nil, err
+	}
+	out := &imdsCredentialsResponse{}
+	if err := json.Unmarshal(body, out); err != nil {
+		return nil, err
+	}
+	return &credentials{
+		Credentials: sigv4.Credentials{
+			AccessKeyID:     out.AccessKeyID,
+			SecretAccessKey: out.SecretAccessKey,
+			SessionToken:    out.Token,
+		},
+		Expiration: out.Expiration,
+	}, nil
+}
+
+// doIMDS performs the given request against the instance metadata service.
+func (p *Provider) doIMDS(req *http.Request) ([]byte, error) {
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to find AWS credentials, the instance metadata service is unavailable: %s", err.Error())
+	}
+	return readResponse(res)
+}
+
+// readResponse reads the body of the given response, returning an error if the
+// request was not successful.
+func readResponse(res *http.Response) ([]byte, error) {
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
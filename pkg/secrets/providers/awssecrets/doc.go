@@ -0,0 +1,3 @@
+// Package awssecrets implements a SecretsProvider backend that uses AWS Secrets Manager
+// for storing sensitive information.
+package awssecrets
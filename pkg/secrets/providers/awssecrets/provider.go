@@ -0,0 +1,67 @@
+package awssecrets
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets/common"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var awsLogger = logf.Log.WithName("aws_secrets")
+
+// Provider implements a SecretsProvider that matches secret names to secrets
+// in AWS Secrets Manager.
+type Provider struct {
+	common.SecretsProvider
+
+	// the configuration for secrets manager
+	crConfig *v1alpha1.AWSSecretsManagerConfig
+	// the client used for all requests
+	httpClient *http.Client
+	// endpoints used for loading credentials
+	stsEndpoint, imdsEndpoint string
+	// the currently cached credentials
+	creds *credentials
+	// loads new credentials when the cached ones expire
+	loadCredentials func() (*credentials, error)
+	// protects the cached credentials
+	mux sync.Mutex
+}
+
+// Blank assignmnt to make sure Provider satisfies the SecretsProvider
+// interface.
+var _ common.SecretsProvider = &Provider{}
+
+// New returns a new Provider.
+func New() *Provider {
+	p := &Provider{
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		imdsEndpoint: defaultIMDSEndpoint,
+	}
+	p.loadCredentials = p.loadDefaultCredentials
+	return p
+}
+
+// Setup will set configurations and then make sure credentials can be found for
+// accessing secrets manager.
+func (p *Provider) Setup(client client.Client, cluster *v1alpha1.VDICluster) error {
+	p.crConfig = cluster.Spec.Secrets.AWSSecretsManager
+	if p.stsEndpoint == "" {
+		p.stsEndpoint = fmt.Sprintf(stsEndpointFormat, p.crConfig.Region)
+	}
+	creds, err := p.getCredentials()
+	if err != nil {
+		return err
+	}
+	awsLogger.Info("Using AWS Secrets Manager for secrets", "Region", p.crConfig.Region, "AccessKeyID", creds.AccessKeyID)
+	return nil
+}
+
+// Close is a no-op since requests are not made outside of secret operations.
+func (p *Provider) Close() error { return nil }
@@ -0,0 +1,191 @@
+package awssecrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/sigv4"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestSecretsManager returns a server that mimics the secrets manager API.
+func newTestSecretsManager(t *testing.T) *httptest.Server {
+	t.Helper()
+	var mux sync.Mutex
+	secrets := make(map[string]string)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-key/") {
+			t.Error("Expected signed request, got:", r.Header.Get("Authorization"))
+		}
+		req := make(map[string]interface{})
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		notFound := func() {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "ResourceNotFoundException", "message": "not found"}`))
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			val, ok := secrets[req["SecretId"].(string)]
+			if !ok {
+				notFound()
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": val})
+		case "secretsmanager.PutSecretValue":
+			if _, ok := secrets[req["SecretId"].(string)]; !ok {
+				notFound()
+				return
+			}
+			secrets[req["SecretId"].(string)] = req["SecretString"].(string)
+			w.Write([]byte("{}"))
+		case "secretsmanager.CreateSecret":
+			if req["KmsKeyId"] != "test-kms-key" {
+				t.Error("Expected KMS key to be used for new secret, got:", req["KmsKeyId"])
+			}
+			secrets[req["Name"].(string)] = req["SecretString"].(string)
+			w.Write([]byte("{}"))
+		case "secretsmanager.DeleteSecret":
+			if _, ok := secrets[req["SecretId"].(string)]; !ok {
+				notFound()
+				return
+			}
+			delete(secrets, req["SecretId"].(string))
+			w.Write([]byte("{}"))
+		default:
+			t.Error("Unexpected action:", r.Header.Get("X-Amz-Target"))
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func mustSetupProvider(t *testing.T, srvr *httptest.Server) *Provider {
+	t.Helper()
+	provider := New()
+	provider.loadCredentials = func() (*credentials, error) {
+		return &credentials{Credentials: sigv4.Credentials{AccessKeyID: "test-key", SecretAccessKey: "test-secret"}}, nil
+	}
+	cluster := &v1alpha1.VDICluster{
+		Spec: v1alpha1.VDIClusterSpec{
+			Secrets: &v1alpha1.SecretsConfig{
+				AWSSecretsManager: &v1alpha1.AWSSecretsManagerConfig{
+					Region:   "us-east-1",
+					KMSKeyID: "test-kms-key",
+					Endpoint: srvr.URL,
+				},
+			},
+		},
+	}
+	if err := provider.Setup(fake.NewFakeClientWithScheme(runtime.NewScheme()), cluster); err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestReadAndWriteSecret(t *testing.T) {
+	srvr := newTestSecretsManager(t)
+	defer srvr.Close()
+	provider := mustSetupProvider(t, srvr)
+
+	if _, err := provider.ReadSecret("test-secret"); !errors.IsSecretNotFoundError(err) {
+		t.Error("Expected secret not found error, got:", err)
+	}
+
+	// write the secret twice to both create and update it
+	for _, val := range []string{"test-value", "new-value"} {
+		if err := provider.WriteSecret("test-secret", []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := provider.ReadSecret("test-secret"); err != nil {
+			t.Fatal(err)
+		} else if string(value) != val {
+			t.Errorf("Expected %s on retrieval, got: %s", val, string(value))
+		}
+	}
+
+	if err := provider.WriteSecretMap("test-map", map[string][]byte{"key": []byte("value")}); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := provider.ReadSecretMap("test-map"); err != nil {
+		t.Fatal(err)
+	} else if string(value["key"]) != "value" {
+		t.Error("Secret map malformed on retrieval, got:", value)
+	}
+
+	// delete the secret, twice to make sure missing secrets are ignored
+	for i := 0; i < 2; i++ {
+		if err := provider.WriteSecret("test-secret", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := provider.ReadSecret("test-secret"); !errors.IsSecretNotFoundError(err) {
+		t.Error("Expected secret not found error, got:", err)
+	}
+}
+
+func TestWebIdentityCredentials(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tokenFile := filepath.Join(tmpDir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("test-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.FormValue("Action") != "AssumeRoleWithWebIdentity" || r.FormValue("WebIdentityToken") != "test-token" || r.FormValue("RoleArn") != "test-role" {
+			t.Error("Unexpected AssumeRoleWithWebIdentity request:", r.Form)
+		}
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse>
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>test-key</AccessKeyId>
+      <SecretAccessKey>test-secret</SecretAccessKey>
+      <SessionToken>test-session</SessionToken>
+      <Expiration>` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer sts.Close()
+
+	os.Unsetenv("AWS_ACCESS_KEY_ID")
+	os.Setenv("AWS_ROLE_ARN", "test-role")
+	os.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	defer os.Unsetenv("AWS_ROLE_ARN")
+	defer os.Unsetenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+
+	provider := New()
+	provider.stsEndpoint = sts.URL
+	for i := 0; i < 2; i++ {
+		creds, err := provider.getCredentials()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds.AccessKeyID != "test-key" || creds.SecretAccessKey != "test-secret" || creds.SessionToken != "test-session" {
+			t.Error("Unexpected credentials:", creds)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected credentials to be cached until they expire, got %d calls", calls)
+	}
+}
@@ -0,0 +1,160 @@
+package awssecrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+	"github.com/tinyzimmer/kvdi/pkg/util/sigv4"
+)
+
+// invalidNameChars matches characters that are not allowed in secret names.
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9/_+=.@-]`)
+
+// apiError is an error returned by the secrets manager API.
+type apiError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+// isNotFound returns true if the given error is from a secret not existing.
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && strings.HasSuffix(apiErr.Type, "ResourceNotFoundException")
+}
+
+// ReadSecret implements SecretsProvider and will retrieve the requsted secret
+// from secrets manager.
+func (p *Provider) ReadSecret(name string) ([]byte, error) {
+	secretMap, err := p.ReadSecretMap(name)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secretMap["data"]
+	if !ok {
+		return nil, errors.NewSecretNotFoundError(name)
+	}
+	return data, nil
+}
+
+// WriteSecret implements SecretsProvider and will write the secret to secrets
+// manager.
+func (p *Provider) WriteSecret(name string, content []byte) error {
+	if len(content) == 0 {
+		return p.WriteSecretMap(name, nil)
+	}
+	return p.WriteSecretMap(name, map[string][]byte{
+		"data": content,
+	})
+}
+
+// ReadSecretMap returns a map from secrets manager. The map is stored as a JSON
+// object of base64 encoded values.
+func (p *Provider) ReadSecretMap(name string) (map[string][]byte, error) {
+	out := struct {
+		SecretString string `json:"SecretString"`
+	}{}
+	if err := p.call("GetSecretValue", map[string]interface{}{
+		"SecretId": p.getSecretID(name),
+	}, &out); err != nil {
+		if isNotFound(err) {
+			return nil, errors.NewSecretNotFoundError(name)
+		}
+		return nil, err
+	}
+	data := make(map[string][]byte)
+	if err := json.Unmarshal([]byte(out.SecretString), &data); err != nil {
+		awsLogger.Info("Could not decode secret data", "Name", name)
+		return nil, errors.NewSecretNotFoundError(name)
+	}
+	return data, nil
+}
+
+// WriteSecretMap implements SecretsProvider and will write the key-value pair
+// to secrets manager, creating the secret if it does not exist. An empty map
+// deletes the secret.
+func (p *Provider) WriteSecretMap(name string, content map[string][]byte) error {
+	id := p.getSecretID(name)
+	if len(content) == 0 {
+		err := p.call("DeleteSecret", map[string]interface{}{
+			"SecretId":                   id,
+			"ForceDeleteWithoutRecovery": true,
+		}, nil)
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	out, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	err = p.call("PutSecretValue", map[string]interface{}{
+		"SecretId":     id,
+		"SecretString": string(out),
+	}, nil)
+	if !isNotFound(err) {
+		return err
+	}
+	req := map[string]interface{}{
+		"Name":         id,
+		"SecretString": string(out),
+		"Tags":         []map[string]string{{"Key": "app", "Value": "kvdi"}},
+	}
+	if p.crConfig.KMSKeyID != "" {
+		req["KmsKeyId"] = p.crConfig.KMSKeyID
+	}
+	return p.call("CreateSecret", req, nil)
+}
+
+// getSecretID returns the name of the given secret in secrets manager.
+func (p *Provider) getSecretID(name string) string {
+	return p.crConfig.GetSecretsPrefix() + invalidNameChars.ReplaceAllString(name, "_")
+}
+
+// call signs and sends a request for the given action to the secrets manager API,
+// and decodes the response into out if it is not nil.
+func (p *Provider) call(action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.crConfig.GetEndpoint()+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager."+action)
+	creds, err := p.getCredentials()
+	if err != nil {
+		return err
+	}
+	sigv4.SignRequest(req, sigv4.SHA256Hex(body), &creds.Credentials, p.crConfig.Region, "secretsmanager", time.Now())
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		apiErr := &apiError{}
+		if err := json.NewDecoder(res.Body).Decode(apiErr); err != nil || apiErr.Type == "" {
+			return fmt.Errorf("%s failed: %s", action, res.Status)
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
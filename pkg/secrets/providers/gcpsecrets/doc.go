@@ -0,0 +1,3 @@
+// Package gcpsecrets implements a SecretsProvider backend that uses GCP Secret Manager
+// for storing sensitive information.
+package gcpsecrets
@@ -0,0 +1,68 @@
+package gcpsecrets
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets/common"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var gcpLogger = logf.Log.WithName("gcp_secrets")
+
+// cloudPlatformScope is the OAuth scope required for accessing secret manager.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Provider implements a SecretsProvider that matches secret names to secrets
+// in GCP Secret Manager.
+type Provider struct {
+	common.SecretsProvider
+
+	// the configuration for secret manager
+	crConfig *v1alpha1.GCPSecretManagerConfig
+	// the client used for all requests, which adds the access token
+	httpClient *http.Client
+	// returns the default token source when one is not set
+	getTokenSource func(context.Context) (oauth2.TokenSource, error)
+}
+
+// Blank assignmnt to make sure Provider satisfies the SecretsProvider
+// interface.
+var _ common.SecretsProvider = &Provider{}
+
+// New returns a new Provider.
+func New() *Provider {
+	return &Provider{
+		getTokenSource: func(ctx context.Context) (oauth2.TokenSource, error) {
+			return google.DefaultTokenSource(ctx, cloudPlatformScope)
+		},
+	}
+}
+
+// Setup will set configurations and then make sure an access token can be retrieved
+// using the application default credentials. Tokens are cached and refreshed by the
+// client when they expire.
+func (p *Provider) Setup(client client.Client, cluster *v1alpha1.VDICluster) error {
+	p.crConfig = cluster.Spec.Secrets.GCPSecretManager
+	ts, err := p.getTokenSource(context.Background())
+	if err != nil {
+		return err
+	}
+	ts = oauth2.ReuseTokenSource(nil, ts)
+	if _, err := ts.Token(); err != nil {
+		return err
+	}
+	p.httpClient = oauth2.NewClient(context.Background(), ts)
+	p.httpClient.Timeout = 10 * time.Second
+	gcpLogger.Info("Using GCP Secret Manager for secrets", "Project", p.crConfig.Project)
+	return nil
+}
+
+// Close is a no-op since requests are not made outside of secret operations.
+func (p *Provider) Close() error { return nil }
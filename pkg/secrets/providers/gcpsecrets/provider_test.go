@@ -0,0 +1,149 @@
+package gcpsecrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"golang.org/x/oauth2"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testSecretManager mimics the secret manager API for a single project.
+type testSecretManager struct {
+	t   *testing.T
+	mux sync.Mutex
+	// the data of each version of each secret, nil if destroyed
+	secrets map[string][][]byte
+}
+
+func (s *testSecretManager) notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(`{"error": {"code": 404, "message": "not found", "status": "NOT_FOUND"}}`))
+}
+
+func (s *testSecretManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		s.t.Error("Expected access token on request, got:", r.Header.Get("Authorization"))
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1/projects/test-project/secrets")
+	switch {
+	case r.Method == http.MethodPost && path == "":
+		s.secrets[r.URL.Query().Get("secretId")] = make([][]byte, 0)
+		w.Write([]byte("{}"))
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/versions/latest:access"):
+		versions, ok := s.secrets[strings.Split(path, "/")[1]]
+		if !ok || len(versions) == 0 {
+			s.notFound(w)
+			return
+		}
+		out := &secretPayload{}
+		out.Payload.Data = versions[len(versions)-1]
+		json.NewEncoder(w).Encode(out)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, ":addVersion"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), ":addVersion")
+		versions, ok := s.secrets[id]
+		if !ok {
+			s.notFound(w)
+			return
+		}
+		in := &secretPayload{}
+		json.NewDecoder(r.Body).Decode(in)
+		s.secrets[id] = append(versions, in.Payload.Data)
+		json.NewEncoder(w).Encode(map[string]string{
+			"name": fmt.Sprintf("projects/1234/secrets/%s/versions/%d", id, len(s.secrets[id])),
+		})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, ":destroy"):
+		var id string
+		var version int
+		fmt.Sscanf(strings.Replace(strings.TrimSuffix(path, ":destroy"), "/", " ", -1), "%s versions %d", &id, &version)
+		s.secrets[id][version-1] = nil
+		w.Write([]byte("{}"))
+	case r.Method == http.MethodDelete:
+		id := strings.TrimPrefix(path, "/")
+		if _, ok := s.secrets[id]; !ok {
+			s.notFound(w)
+			return
+		}
+		delete(s.secrets, id)
+		w.Write([]byte("{}"))
+	default:
+		s.t.Error("Unexpected request:", r.Method, r.URL.String())
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func mustSetupProvider(t *testing.T, srvr *httptest.Server) *Provider {
+	t.Helper()
+	provider := New()
+	provider.getTokenSource = func(context.Context) (oauth2.TokenSource, error) {
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"}), nil
+	}
+	cluster := &v1alpha1.VDICluster{
+		Spec: v1alpha1.VDIClusterSpec{
+			Secrets: &v1alpha1.SecretsConfig{
+				GCPSecretManager: &v1alpha1.GCPSecretManagerConfig{
+					Project:  "test-project",
+					Endpoint: srvr.URL,
+				},
+			},
+		},
+	}
+	if err := provider.Setup(fake.NewFakeClientWithScheme(runtime.NewScheme()), cluster); err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestReadAndWriteSecret(t *testing.T) {
+	sm := &testSecretManager{t: t, secrets: make(map[string][][]byte)}
+	srvr := httptest.NewServer(sm)
+	defer srvr.Close()
+	provider := mustSetupProvider(t, srvr)
+
+	if _, err := provider.ReadSecret("test.secret"); !errors.IsSecretNotFoundError(err) {
+		t.Error("Expected secret not found error, got:", err)
+	}
+
+	// write the secret twice to both create and update it
+	for _, val := range []string{"test-value", "new-value"} {
+		if err := provider.WriteSecret("test.secret", []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+		if value, err := provider.ReadSecret("test.secret"); err != nil {
+			t.Fatal(err)
+		} else if string(value) != val {
+			t.Errorf("Expected %s on retrieval, got: %s", val, string(value))
+		}
+	}
+
+	// names are sanitized and the previous version destroyed
+	versions, ok := sm.secrets["kvdi-test_secret"]
+	if !ok {
+		t.Fatal("Expected secret to be stored as kvdi-test_secret, got:", sm.secrets)
+	}
+	if len(versions) != 2 || versions[0] != nil {
+		t.Error("Expected previous version of the secret to be destroyed")
+	}
+
+	// delete the secret, twice to make sure missing secrets are ignored
+	for i := 0; i < 2; i++ {
+		if err := provider.WriteSecret("test.secret", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := provider.ReadSecret("test.secret"); !errors.IsSecretNotFoundError(err) {
+		t.Error("Expected secret not found error, got:", err)
+	}
+}
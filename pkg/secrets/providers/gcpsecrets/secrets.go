@@ -0,0 +1,184 @@
+package gcpsecrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+)
+
+// invalidIDChars matches characters that are not allowed in secret IDs.
+var invalidIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// apiError is an error returned by the secret manager API.
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// Error implements the error interface.
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+// isNotFound returns true if the given error is from a secret not existing.
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.Code == http.StatusNotFound
+}
+
+// secretPayload is the payload of a secret version.
+type secretPayload struct {
+	Payload struct {
+		Data []byte `json:"data"`
+	} `json:"payload"`
+}
+
+// ReadSecret implements SecretsProvider and will retrieve the requsted secret
+// from secret manager.
+func (p *Provider) ReadSecret(name string) ([]byte, error) {
+	secretMap, err := p.ReadSecretMap(name)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secretMap["data"]
+	if !ok {
+		return nil, errors.NewSecretNotFoundError(name)
+	}
+	return data, nil
+}
+
+// WriteSecret implements SecretsProvider and will write the secret to secret
+// manager.
+func (p *Provider) WriteSecret(name string, content []byte) error {
+	if len(content) == 0 {
+		return p.WriteSecretMap(name, nil)
+	}
+	return p.WriteSecretMap(name, map[string][]byte{
+		"data": content,
+	})
+}
+
+// ReadSecretMap returns a map from the latest version of a secret in secret manager.
+// The map is stored as a JSON object of base64 encoded values.
+func (p *Provider) ReadSecretMap(name string) (map[string][]byte, error) {
+	out := &secretPayload{}
+	if err := p.call(http.MethodGet, p.getSecretPath(name)+"/versions/latest:access", nil, out); err != nil {
+		if isNotFound(err) {
+			return nil, errors.NewSecretNotFoundError(name)
+		}
+		return nil, err
+	}
+	data := make(map[string][]byte)
+	if err := json.Unmarshal(out.Payload.Data, &data); err != nil {
+		gcpLogger.Info("Could not decode secret data", "Name", name)
+		return nil, errors.NewSecretNotFoundError(name)
+	}
+	return data, nil
+}
+
+// WriteSecretMap implements SecretsProvider and will add a new version of the
+// secret to secret manager, creating the secret if it does not exist. The previous
+// version is destroyed, so that old values are not kept around. An empty map
+// deletes the secret.
+func (p *Provider) WriteSecretMap(name string, content map[string][]byte) error {
+	path := p.getSecretPath(name)
+	if len(content) == 0 {
+		if err := p.call(http.MethodDelete, path, nil, nil); err != nil && !isNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	payload := &secretPayload{}
+	var err error
+	if payload.Payload.Data, err = json.Marshal(content); err != nil {
+		return err
+	}
+
+	version := struct {
+		Name string `json:"name"`
+	}{}
+	err = p.call(http.MethodPost, path+":addVersion", payload, &version)
+	if isNotFound(err) {
+		if err := p.createSecret(name); err != nil {
+			return err
+		}
+		err = p.call(http.MethodPost, path+":addVersion", payload, &version)
+	}
+	if err != nil {
+		return err
+	}
+
+	// versions are numbered sequentially, so the previous one is one less
+	idx := strings.LastIndex(version.Name, "/")
+	num, err := strconv.Atoi(version.Name[idx+1:])
+	if err != nil || num <= 1 {
+		return nil
+	}
+	previous := fmt.Sprintf("%s/versions/%d:destroy", path, num-1)
+	if err := p.call(http.MethodPost, previous, struct{}{}, nil); err != nil {
+		gcpLogger.Info("Failed to destroy previous secret version", "Name", name, "Error", err.Error())
+	}
+	return nil
+}
+
+// createSecret creates the secret with the given name with automatic replication.
+func (p *Provider) createSecret(name string) error {
+	path := fmt.Sprintf("/v1/projects/%s/secrets?secretId=%s", p.crConfig.Project, url.QueryEscape(p.getSecretID(name)))
+	return p.call(http.MethodPost, path, map[string]interface{}{
+		"replication": map[string]interface{}{"automatic": map[string]interface{}{}},
+		"labels":      map[string]string{"app": "kvdi"},
+	}, nil)
+}
+
+// getSecretID returns the ID of the given secret in secret manager.
+func (p *Provider) getSecretID(name string) string {
+	return p.crConfig.GetSecretsPrefix() + invalidIDChars.ReplaceAllString(name, "_")
+}
+
+// getSecretPath returns the API path to the given secret.
+func (p *Provider) getSecretPath(name string) string {
+	return fmt.Sprintf("/v1/projects/%s/secrets/%s", p.crConfig.Project, p.getSecretID(name))
+}
+
+// call sends a request to the secret manager API, and decodes the response into
+// out if it is not nil.
+func (p *Provider) call(method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, p.crConfig.GetEndpoint()+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		out := struct {
+			Error *apiError `json:"error"`
+		}{}
+		if err := json.NewDecoder(res.Body).Decode(&out); err != nil || out.Error == nil {
+			return &apiError{Code: res.StatusCode, Status: res.Status}
+		}
+		return out.Error
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -3,6 +3,7 @@ package vault
 import (
 	"encoding/base64"
 	"fmt"
+	"strings"
 
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 )
@@ -44,8 +45,19 @@ func (p *Provider) ReadSecretMap(name string) (map[string][]byte, error) {
 		vaultLogger.Info("Secret data is nil, assuming doesn't exist", "Path", path)
 		return nil, errors.NewSecretNotFoundError(name)
 	}
+	data := res.Data
+	if p.crConfig.IsKVv2() {
+		// KV v2 nests the secret under data, which is nil if the latest version
+		// was deleted.
+		var ok bool
+		data, ok = res.Data["data"].(map[string]interface{})
+		if !ok || data == nil {
+			vaultLogger.Info("Secret version data is nil, assuming doesn't exist", "Path", path)
+			return nil, errors.NewSecretNotFoundError(name)
+		}
+	}
 	out := make(map[string][]byte)
-	for k, v := range res.Data {
+	for k, v := range data {
 		data, ok := v.(string)
 		if !ok {
 			vaultLogger.Info("Could not assert secret data to string, probably empty", "Path", path)
@@ -66,18 +78,44 @@ func (p *Provider) ReadSecretMap(name string) (map[string][]byte, error) {
 // This will be the preferred function going forward.
 func (p *Provider) WriteSecretMap(name string, content map[string][]byte) error {
 	if len(content) == 0 {
-		_, err := p.client.Logical().Delete(p.getSecretPath(name))
+		_, err := p.client.Logical().Delete(p.getDeletePath(name))
 		return err
 	}
 	out := make(map[string]interface{})
 	for k, v := range content {
 		out[k] = v
 	}
+	if p.crConfig.IsKVv2() {
+		out = map[string]interface{}{"data": out}
+	}
 	_, err := p.client.Logical().Write(p.getSecretPath(name), out)
 	return err
 }
 
 // getSecretPath returns the path to a given secret name in vault.
 func (p *Provider) getSecretPath(name string) string {
+	if p.crConfig.IsKVv2() {
+		return p.getKVv2Path("data", name)
+	}
 	return fmt.Sprintf("%s/%s", p.crConfig.GetSecretsPath(), name)
 }
+
+// getDeletePath returns the path to delete a given secret name in vault. For KV
+// v2 this is the metadata path, so that all versions of the secret are removed.
+func (p *Provider) getDeletePath(name string) string {
+	if p.crConfig.IsKVv2() {
+		return p.getKVv2Path("metadata", name)
+	}
+	return p.getSecretPath(name)
+}
+
+// getKVv2Path returns the path to the given secret name under the data or metadata
+// prefix of a KV v2 secrets engine.
+func (p *Provider) getKVv2Path(prefix, name string) string {
+	mount := p.crConfig.GetKVMountPath()
+	secretsPath := strings.Trim(strings.TrimPrefix(strings.TrimPrefix(p.crConfig.GetSecretsPath(), "/"), mount), "/")
+	if secretsPath == "" {
+		return fmt.Sprintf("%s/%s/%s", mount, prefix, name)
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", mount, prefix, secretsPath, name)
+}
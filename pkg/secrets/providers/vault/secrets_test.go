@@ -1,9 +1,17 @@
 package vault
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	"github.com/hashicorp/vault/api"
 )
 
 func TestReadAndWriteSecret(t *testing.T) {
@@ -45,3 +53,113 @@ func TestReadAndWriteSecret(t *testing.T) {
 		t.Error("Expected secret not found error, got:", err)
 	}
 }
+
+// newTestKVv2Server returns a server that mimics the data and metadata endpoints
+// of a KV v2 secrets engine mounted at secret/.
+func newTestKVv2Server(t *testing.T) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	secrets := make(map[string]interface{})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/") {
+			if r.Method != http.MethodDelete {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			delete(secrets, strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/"))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/v1/secret/data/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := secrets[path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"data": data, "metadata": map[string]interface{}{"version": 1}},
+			})
+		case http.MethodPut, http.MethodPost:
+			body := make(map[string]interface{})
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, ok := body["data"]
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			secrets[path] = data
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"version": 1}})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestReadAndWriteSecretKVv2(t *testing.T) {
+	srvr := newTestKVv2Server(t)
+	defer srvr.Close()
+
+	client, err := api.NewClient(&api.Config{Address: srvr.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("test-token")
+	provider := &Provider{
+		crConfig: &v1alpha1.VaultConfig{SecretsPath: "secret/kvdi", KVVersion: v1alpha1.VaultKVv2},
+		client:   client,
+	}
+
+	if _, err := provider.ReadSecretMap("test-secret"); !errors.IsSecretNotFoundError(err) {
+		t.Fatal("Expected secret not found error, got:", err)
+	}
+
+	if err := provider.WriteSecretMap("test-secret", map[string][]byte{"key": []byte("value")}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := provider.ReadSecretMap("test-secret"); err != nil {
+		t.Fatal(err)
+	} else if string(data["key"]) != "value" {
+		t.Error("Secret value malformed on retrieval, got:", data)
+	}
+
+	// deleting should remove all versions of the secret
+	if err := provider.WriteSecretMap("test-secret", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.ReadSecretMap("test-secret"); !errors.IsSecretNotFoundError(err) {
+		t.Error("Expected secret not found error, got:", err)
+	}
+}
+
+func TestKVv2Paths(t *testing.T) {
+	tests := []struct {
+		config   *v1alpha1.VaultConfig
+		data     string
+		metadata string
+	}{
+		{&v1alpha1.VaultConfig{SecretsPath: "secret/kvdi/"}, "secret/data/kvdi/jwt", "secret/metadata/kvdi/jwt"},
+		{&v1alpha1.VaultConfig{SecretsPath: "secret"}, "secret/data/jwt", "secret/metadata/jwt"},
+		{&v1alpha1.VaultConfig{SecretsPath: "team/kv/kvdi", KVMountPath: "team/kv/"}, "team/kv/data/kvdi/jwt", "team/kv/metadata/kvdi/jwt"},
+	}
+	for _, tt := range tests {
+		tt.config.KVVersion = v1alpha1.VaultKVv2
+		provider := &Provider{crConfig: tt.config}
+		if path := provider.getSecretPath("jwt"); path != tt.data {
+			t.Errorf("Expected data path %q, got %q", tt.data, path)
+		}
+		if path := provider.getDeletePath("jwt"); path != tt.metadata {
+			t.Errorf("Expected metadata path %q, got %q", tt.metadata, path)
+		}
+	}
+}
@@ -7,11 +7,14 @@ import (
 	"time"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 	"github.com/tinyzimmer/kvdi/pkg/util/lock"
 
 	"github.com/tinyzimmer/kvdi/pkg/secrets/common"
+	"github.com/tinyzimmer/kvdi/pkg/secrets/providers/awssecrets"
+	"github.com/tinyzimmer/kvdi/pkg/secrets/providers/gcpsecrets"
 	"github.com/tinyzimmer/kvdi/pkg/secrets/providers/k8secret"
 	"github.com/tinyzimmer/kvdi/pkg/secrets/providers/vault"
 
@@ -22,10 +25,6 @@ import (
 // secretsLog is the logr interface for the secrets engine
 var secretsLog = logf.Log.WithName("secrets")
 
-// cacheTTL is how long cache items remain valid.
-// TODO: make this configurable
-var cacheTTL = time.Duration(1) * time.Hour
-
 // SecretEngine is an object wrapper for interacting with backend secret
 // "providers". It wraps a cache and a locking mechanism around the simple
 // Read/Write methods that the backends provide.
@@ -38,6 +37,8 @@ type SecretEngine struct {
 	client client.Client
 	// the local value cache
 	cache map[string]*cacheItem
+	// mux for concurrent access to the cache
+	cacheMux sync.RWMutex
 	// mux for local-process locking
 	mux sync.Mutex
 	// a pointer used for remote locks
@@ -62,6 +63,10 @@ func GetSecretEngine(cluster *v1alpha1.VDICluster) *SecretEngine {
 	switch cluster.GetSecretsBackend() {
 	case v1alpha1.SecretsBackendVault:
 		backend = vault.New()
+	case v1alpha1.SecretsBackendAWS:
+		backend = awssecrets.New()
+	case v1alpha1.SecretsBackendGCP:
+		backend = gcpsecrets.New()
 	default:
 		backend = k8secret.New()
 	}
@@ -69,7 +74,7 @@ func GetSecretEngine(cluster *v1alpha1.VDICluster) *SecretEngine {
 		backend:  backend,
 		cluster:  cluster,
 		cache:    make(map[string]*cacheItem),
-		cacheTTL: cacheTTL,
+		cacheTTL: cluster.GetSecretsCacheTTL(),
 	}
 	return engine
 }
@@ -90,12 +95,15 @@ func (s *SecretEngine) Setup(c client.Client, cluster *v1alpha1.VDICluster) erro
 	// rewrite cluster since this is a method that can be used to refresh
 	// configuration also.
 	s.cluster = cluster
+	s.cacheTTL = cluster.GetSecretsCacheTTL()
 	return s.backend.Setup(c, cluster)
 }
 
 // readCache will return the contents of a secret from the cache if still valid.
 // Otherwise it returns nil.
 func (s *SecretEngine) readCache(name string) []byte {
+	s.cacheMux.RLock()
+	defer s.cacheMux.RUnlock()
 	if cached, ok := s.cache[name]; ok {
 		if cached.expiresAt > time.Now().Unix() {
 			return cached.contents
@@ -104,12 +112,14 @@ func (s *SecretEngine) readCache(name string) []byte {
 	return nil
 }
 
-// readCacheMap will return the contents of a secret from the cache if still valid.
-// Otherwise it returns nil.
+// readCacheMap will return a copy of the contents of a secret from the cache if still
+// valid. Otherwise it returns nil.
 func (s *SecretEngine) readCacheMap(name string) map[string][]byte {
+	s.cacheMux.RLock()
+	defer s.cacheMux.RUnlock()
 	if cached, ok := s.cache[name]; ok {
 		if cached.expiresAt > time.Now().Unix() {
-			return cached.contentsMap
+			return copyMap(cached.contentsMap)
 		}
 	}
 	return nil
@@ -118,21 +128,38 @@ func (s *SecretEngine) readCacheMap(name string) map[string][]byte {
 // writeCache writes a new bytes value to the cache, replacing an existing one of the
 // same name.
 func (s *SecretEngine) writeCache(name string, contents []byte) {
+	s.cacheMux.Lock()
+	defer s.cacheMux.Unlock()
 	s.cache[name] = &cacheItem{
 		contents:  contents,
 		expiresAt: time.Now().Add(s.cacheTTL).Unix(),
 	}
 }
 
-// writeCacheMap writes a new map value to the cache, replacing an existing one of the
-// same name.
+// writeCacheMap writes a copy of a new map value to the cache, replacing an existing
+// one of the same name.
 func (s *SecretEngine) writeCacheMap(name string, contents map[string][]byte) {
+	s.cacheMux.Lock()
+	defer s.cacheMux.Unlock()
 	s.cache[name] = &cacheItem{
-		contentsMap: contents,
+		contentsMap: copyMap(contents),
 		expiresAt:   time.Now().Add(s.cacheTTL).Unix(),
 	}
 }
 
+// copyMap returns a copy of the given secret map. Maps are copied in and out of the
+// cache so callers can modify the ones they are given without holding the cacheMux.
+func copyMap(contents map[string][]byte) map[string][]byte {
+	if contents == nil {
+		return nil
+	}
+	out := make(map[string][]byte, len(contents))
+	for k, v := range contents {
+		out[k] = v
+	}
+	return out
+}
+
 // ReadSecret will fetch the requested secret from the backend. If cache is true,
 // the cache will be checked first, and if not found then the backend will be queried.
 // The secret is unconditionally written to the cache after retrieval.
@@ -152,7 +179,7 @@ func (s *SecretEngine) ReadSecret(name string, cache bool) ([]byte, error) {
 
 // ReadSecretMap will fetch the requested secret from the backend. If cache is true,
 // the cache will be checked first, and if not found the backend will be queried. The result
-// is then unconditionally written to the cache.
+// is then unconditionally written to the cache. The returned map is owned by the caller.
 func (s *SecretEngine) ReadSecretMap(name string, cache bool) (map[string][]byte, error) {
 	if cache {
 		if val := s.readCacheMap(name); val != nil {
@@ -231,5 +258,18 @@ func (s *SecretEngine) Release() {
 	s.lock = nil
 }
 
+// CheckHealth verifies that the backend is reachable by reading the JWT secret
+// from it, bypassing the cache. A secret that has not been written yet is not
+// considered a failure.
+func (s *SecretEngine) CheckHealth() error {
+	if s.client == nil {
+		return errors.New("Secrets engine has not been setup yet")
+	}
+	if _, err := s.backend.ReadSecret(v1.JWTSecretKey); err != nil && !errors.IsSecretNotFoundError(err) {
+		return err
+	}
+	return nil
+}
+
 // Close calls close on the backend
 func (s *SecretEngine) Close() error { return s.backend.Close() }
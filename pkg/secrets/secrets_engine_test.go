@@ -9,6 +9,8 @@ import (
 
 	"github.com/tinyzimmer/kvdi/pkg/apis"
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	"github.com/tinyzimmer/kvdi/pkg/secrets/providers/awssecrets"
+	"github.com/tinyzimmer/kvdi/pkg/secrets/providers/gcpsecrets"
 	"github.com/tinyzimmer/kvdi/pkg/secrets/providers/k8secret"
 	"github.com/tinyzimmer/kvdi/pkg/secrets/providers/vault"
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
@@ -69,6 +71,26 @@ func TestGetSecretEngine(t *testing.T) {
 	if reflect.TypeOf(se.backend) != reflect.TypeOf(vault.New()) {
 		t.Error("Expected secret engine with vault backend, got:", reflect.TypeOf(se.backend))
 	}
+
+	cluster.Spec.Secrets = &v1alpha1.SecretsConfig{
+		AWSSecretsManager: &v1alpha1.AWSSecretsManagerConfig{Region: "us-east-1"},
+		CacheTTL:          "5m",
+	}
+	se = GetSecretEngine(cluster)
+	if reflect.TypeOf(se.backend) != reflect.TypeOf(awssecrets.New()) {
+		t.Error("Expected secret engine with aws backend, got:", reflect.TypeOf(se.backend))
+	}
+	if se.cacheTTL != 5*time.Minute {
+		t.Error("Expected configured cache TTL, got:", se.cacheTTL)
+	}
+
+	cluster.Spec.Secrets = &v1alpha1.SecretsConfig{
+		GCPSecretManager: &v1alpha1.GCPSecretManagerConfig{Project: "test-project"},
+	}
+	se = GetSecretEngine(cluster)
+	if reflect.TypeOf(se.backend) != reflect.TypeOf(gcpsecrets.New()) {
+		t.Error("Expected secret engine with gcp backend, got:", reflect.TypeOf(se.backend))
+	}
 }
 
 func TestReadAndWriteSecret(t *testing.T) {
@@ -147,6 +169,31 @@ func TestReadAndWriteMap(t *testing.T) {
 		t.Error("Secret value malformed on retrieval, got:", string(val))
 	}
 
+	// Modifying a returned map doesn't change the cache
+	delete(valMap, "test-key")
+	valMap["other-key"] = []byte("other-value")
+	valMap, err = se.ReadSecretMap("test-secret", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(valMap) != 1 || string(valMap["test-key"]) != "test-value" {
+		t.Error("Expected cached secret to be unchanged, got:", valMap)
+	}
+
+	// Neither does modifying a map after writing it
+	written := map[string][]byte{"test-key": []byte("new-value")}
+	if err := se.WriteSecretMap("test-secret", written); err != nil {
+		t.Fatal(err)
+	}
+	written["other-key"] = []byte("other-value")
+	valMap, err = se.ReadSecretMap("test-secret", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(valMap) != 1 || string(valMap["test-key"]) != "new-value" {
+		t.Error("Expected cached secret to be unchanged, got:", valMap)
+	}
+
 	if _, err := se.ReadSecretMap("non-exist", true); err == nil {
 		t.Fatal("Expected error reading non-existent secret, got nil")
 	} else if !errors.IsSecretNotFoundError(err) {
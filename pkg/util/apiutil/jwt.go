@@ -1,6 +1,8 @@
 package apiutil
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"fmt"
 	"time"
 
@@ -8,26 +10,111 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/util/errors"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	"github.com/mitchellh/mapstructure"
 )
 
 // GenerateJWT will create a new JWT with the given user object's fields
 // embedded in the claims.
-func GenerateJWT(secret []byte, authResult *v1.AuthResult, authorized bool, sessionLength time.Duration) (v1.JWTClaims, string, error) {
+func GenerateJWT(keys *JWTKeySet, authResult *v1.AuthResult, authorized bool, sessionLength time.Duration) (v1.JWTClaims, string, error) {
+	now := time.Now()
 	claims := v1.JWTClaims{
-		User:       authResult.User,
-		Authorized: authorized,
-		Renewable:  !authResult.RefreshNotSupported,
+		User:           authResult.User,
+		Authorized:     authorized,
+		Renewable:      !authResult.RefreshNotSupported,
+		IssuedAtMillis: unixMillis(now),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(sessionLength).Unix(),
-			IssuedAt:  time.Now().Unix(),
+			Id:        uuid.New().String(),
+			ExpiresAt: now.Add(sessionLength).Unix(),
+			IssuedAt:  now.Unix(),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(secret)
+	tokenString, err := keys.sign(claims)
 	return claims, tokenString, err
 }
 
+// GenerateViewTokenJWT will create a new JWT for the given view token. The claims
+// carry no roles and are only accepted for watching the display the view token
+// was created for.
+func GenerateViewTokenJWT(keys *JWTKeySet, viewToken *v1.ViewToken) (string, error) {
+	now := time.Now()
+	claims := v1.JWTClaims{
+		User:           &v1.VDIUser{Name: viewToken.CreatedBy, Roles: []*v1.VDIUserRole{}},
+		Authorized:     true,
+		ViewToken:      viewToken.ID,
+		IssuedAtMillis: unixMillis(now),
+		StandardClaims: jwt.StandardClaims{
+			Id:        viewToken.ID,
+			ExpiresAt: viewToken.ExpiresAt,
+			IssuedAt:  now.Unix(),
+		},
+	}
+	return keys.sign(claims)
+}
+
+// GenerateImpersonationJWT will create a new JWT for the given user on behalf of the
+// impersonator. The claims are authorized but cannot be renewed.
+func GenerateImpersonationJWT(keys *JWTKeySet, user *v1.VDIUser, impersonator string, duration time.Duration) (v1.JWTClaims, string, error) {
+	now := time.Now()
+	claims := v1.JWTClaims{
+		User:           user,
+		Authorized:     true,
+		Impersonator:   impersonator,
+		IssuedAtMillis: unixMillis(now),
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			ExpiresAt: now.Add(duration).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+	}
+	token, err := keys.sign(claims)
+	return claims, token, err
+}
+
+// passwordResetAudience is the audience of password reset tokens.
+const passwordResetAudience = "password-reset"
+
+// passwordResetKey derives the key for signing password reset tokens from the JWT
+// secret, so they can never be verified as session tokens.
+func passwordResetKey(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(passwordResetAudience))
+	return mac.Sum(nil)
+}
+
+// GeneratePasswordResetJWT will create a new token authorizing a password reset for
+// the given user until it expires. The ID of the token is returned along with it.
+func GeneratePasswordResetJWT(secret []byte, username string, ttl time.Duration) (*jwt.StandardClaims, string, error) {
+	claims := &jwt.StandardClaims{
+		Id:        uuid.New().String(),
+		Subject:   username,
+		Audience:  passwordResetAudience,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+		IssuedAt:  time.Now().Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(passwordResetKey(secret))
+	return claims, token, err
+}
+
+// DecodePasswordResetJWT will decode the provided password reset token and verify
+// its signature and expiry. The claims are returned if it is valid.
+func DecodePasswordResetJWT(secret []byte, resetToken string) (*jwt.StandardClaims, error) {
+	claims := &jwt.StandardClaims{}
+	token, err := jwt.ParseWithClaims(resetToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("Incorrect signing algorithm on token")
+		}
+		return passwordResetKey(secret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("The password reset token is invalid or has expired")
+	}
+	if !claims.VerifyAudience(passwordResetAudience, true) || claims.Subject == "" || claims.Id == "" {
+		return nil, errors.New("The password reset token is invalid or has expired")
+	}
+	return claims, nil
+}
+
 // Token verification errors
 var errTokenMalformedError = errors.New("Malformed token provided in the request")
 var errTokenNotValidYetError = errors.New("Provided token is not valid yet")
@@ -37,16 +124,10 @@ var errTokenSigInvalidError = errors.New("Token provided in the request has an i
 // DecodeAndVerifyJWT will decode the provided JWT and verify the validity of its claims.
 // If the claims are valid, they are returned, otherwise an error with the reason why
 // they are invalid.
-func DecodeAndVerifyJWT(secret []byte, authToken string) (*v1.JWTClaims, error) {
+func DecodeAndVerifyJWT(keys *JWTKeySet, authToken string) (*v1.JWTClaims, error) {
 	// parse the token
 	parser := &jwt.Parser{UseJSONNumber: true}
-	token, err := parser.Parse(authToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("Incorrect signing algorithm on token")
-		}
-		// use cache for the JWT secret, since we use it for every request
-		return secret, nil
-	})
+	token, err := parser.Parse(authToken, keys.keyFunc)
 	// Check if token is nil and return error. The error will also be populated
 	// if the token was parsed successfully but is invalid.
 	if token == nil {
@@ -58,7 +139,9 @@ func DecodeAndVerifyJWT(secret []byte, authToken string) (*v1.JWTClaims, error)
 
 		// Just the error conditions we have specific messages for
 		if ve, ok := err.(*jwt.ValidationError); ok {
-			if ve.Errors&jwt.ValidationErrorMalformed != 0 {
+			if ve.Inner == ErrUnknownTokenKey {
+				return nil, ErrUnknownTokenKey
+			} else if ve.Errors&jwt.ValidationErrorMalformed != 0 {
 				return nil, errTokenMalformedError
 			} else if ve.Errors&(jwt.ValidationErrorExpired) != 0 {
 				return nil, errTokenExpiredError
@@ -83,12 +166,27 @@ func DecodeAndVerifyJWT(secret []byte, authToken string) (*v1.JWTClaims, error)
 
 	// decode the claims into a session object
 	session := &v1.JWTClaims{}
+	if err := decodeClaims(claims, session); err != nil {
+		return nil, err
+	}
+	// the standard claims are embedded without a tag, so they need to be decoded
+	// separately
+	return session, decodeClaims(claims, &session.StandardClaims)
+}
+
+// unixMillis returns the given time in milliseconds since the epoch.
+func unixMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// decodeClaims decodes the given claims into the given object using its json tags.
+func decodeClaims(claims jwt.MapClaims, out interface{}) error {
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		TagName: "json",
-		Result:  session,
+		Result:  out,
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return session, decoder.Decode(claims)
+	return decoder.Decode(claims)
 }
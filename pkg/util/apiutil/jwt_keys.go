@@ -0,0 +1,193 @@
+package apiutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+	"github.com/tinyzimmer/kvdi/pkg/util/errors"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// ErrUnknownTokenKey is returned when verifying a token signed with a key that is
+// not in the key set. The keys may have been rotated since they were last read.
+var ErrUnknownTokenKey = errors.New("Token provided in the request was signed with an unknown key")
+
+// NewJWTSigningKey generates a new key for signing JWTs with the given algorithm.
+func NewJWTSigningKey(alg v1alpha1.TokenSigningAlgorithm) (*v1.JWTSigningKey, error) {
+	var key []byte
+	switch alg {
+	case v1alpha1.TokenSigningHS256:
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	case v1alpha1.TokenSigningRS256:
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		key = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	case v1alpha1.TokenSigningES256:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		key = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	default:
+		return nil, fmt.Errorf("Unsupported token signing algorithm: %s", alg)
+	}
+	return &v1.JWTSigningKey{
+		ID:        uuid.New().String(),
+		Algorithm: string(alg),
+		Key:       key,
+		CreatedAt: time.Now().Unix(),
+	}, nil
+}
+
+// jwtKey is a parsed JWT signing key.
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func parseJWTSigningKey(key *v1.JWTSigningKey) (*jwtKey, error) {
+	parsed := &jwtKey{id: key.ID}
+	switch v1alpha1.TokenSigningAlgorithm(key.Algorithm) {
+	case v1alpha1.TokenSigningHS256:
+		parsed.method = jwt.SigningMethodHS256
+		parsed.signKey, parsed.verifyKey = key.Key, key.Key
+	case v1alpha1.TokenSigningRS256:
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		parsed.method = jwt.SigningMethodRS256
+		parsed.signKey, parsed.verifyKey = priv, &priv.PublicKey
+	case v1alpha1.TokenSigningES256:
+		priv, err := jwt.ParseECPrivateKeyFromPEM(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		parsed.method = jwt.SigningMethodES256
+		parsed.signKey, parsed.verifyKey = priv, &priv.PublicKey
+	default:
+		return nil, fmt.Errorf("Unsupported token signing algorithm: %s", key.Algorithm)
+	}
+	return parsed, nil
+}
+
+// JWTKeySet holds the keys for signing and verifying JWTs. Tokens signed with the
+// static JWT secret carry no key ID, and are still accepted after switching to
+// signing keys so that existing sessions are not logged out.
+type JWTKeySet struct {
+	secret  []byte
+	keys    map[string]*jwtKey
+	signing *jwtKey
+	public  []v1.JSONWebKey
+}
+
+// NewJWTKeySet returns a key set for the given JWT secret and signing keys. When
+// useKeys is true and there are keys that are not retired, new tokens are signed
+// with the most recently created one. Otherwise they are signed with the secret.
+func NewJWTKeySet(secret []byte, keys []*v1.JWTSigningKey, useKeys bool) (*JWTKeySet, error) {
+	set := &JWTKeySet{secret: secret, keys: make(map[string]*jwtKey), public: make([]v1.JSONWebKey, 0)}
+	sorted := make([]*v1.JWTSigningKey, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CreatedAt < sorted[j].CreatedAt })
+	for _, key := range sorted {
+		parsed, err := parseJWTSigningKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse token signing key %s: %s", key.ID, err.Error())
+		}
+		set.keys[key.ID] = parsed
+		if jwk := parsed.publicJWK(); jwk != nil {
+			set.public = append(set.public, *jwk)
+		}
+		if useKeys && !key.IsRetired() {
+			set.signing = parsed
+		}
+	}
+	return set, nil
+}
+
+// sign returns the given claims as a token signed with the current key.
+func (k *JWTKeySet) sign(claims jwt.Claims) (string, error) {
+	if k.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+	token := jwt.NewWithClaims(k.signing.method, claims)
+	token.Header["kid"] = k.signing.id
+	return token.SignedString(k.signing.signKey)
+}
+
+// keyFunc returns the key for verifying the given token.
+func (k *JWTKeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("Incorrect signing algorithm on token")
+		}
+		return k.secret, nil
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownTokenKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("Incorrect signing algorithm on token")
+	}
+	return key.verifyKey, nil
+}
+
+// PublicKeys returns the public keys in the set in JWK format. HMAC keys are never
+// included.
+func (k *JWTKeySet) PublicKeys() *v1.JSONWebKeySet {
+	return &v1.JSONWebKeySet{Keys: k.public}
+}
+
+// publicJWK returns the public part of the key in JWK format, or nil for HMAC keys.
+func (k *jwtKey) publicJWK() *v1.JSONWebKey {
+	jwk := &v1.JSONWebKey{KeyID: k.id, Algorithm: k.method.Alg(), Use: "sig"}
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(padBytes(pub.X.Bytes(), size))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(padBytes(pub.Y.Bytes(), size))
+	default:
+		return nil
+	}
+	return jwk
+}
+
+// padBytes left-pads b with zeroes to the given size.
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
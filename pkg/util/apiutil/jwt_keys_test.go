@@ -0,0 +1,120 @@
+package apiutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
+)
+
+func mustNewJWTSigningKey(t *testing.T, alg v1alpha1.TokenSigningAlgorithm, createdAt int64) *v1.JWTSigningKey {
+	t.Helper()
+	key, err := NewJWTSigningKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.CreatedAt = createdAt
+	return key
+}
+
+func TestJWTKeySet(t *testing.T) {
+	now := time.Now().Unix()
+	user := &v1.AuthResult{User: &v1.VDIUser{Name: "test-user"}}
+
+	for _, alg := range []v1alpha1.TokenSigningAlgorithm{v1alpha1.TokenSigningHS256, v1alpha1.TokenSigningRS256, v1alpha1.TokenSigningES256} {
+		old := mustNewJWTSigningKey(t, alg, now-60)
+		current := mustNewJWTSigningKey(t, alg, now)
+		keys, err := NewJWTKeySet(secret, []*v1.JWTSigningKey{current, old}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys.signing.id != current.ID {
+			t.Errorf("%s: Expected newest key to be used for signing, got: %s", alg, keys.signing.id)
+		}
+		_, token, err := GenerateJWT(keys, user, true, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := DecodeAndVerifyJWT(keys, token)
+		if err != nil {
+			t.Fatalf("%s: Expected token to verify, got: %s", alg, err)
+		}
+		if claims.User.Name != "test-user" {
+			t.Errorf("%s: Expected claims to match, got: %v", alg, claims)
+		}
+
+		// tokens signed with the secret or the previous key remain valid
+		if _, err := DecodeAndVerifyJWT(keys, mustGenerateJWT(t, true, time.Minute)); err != nil {
+			t.Errorf("%s: Expected token signed with secret to verify, got: %s", alg, err)
+		}
+		oldKeys, err := NewJWTKeySet(secret, []*v1.JWTSigningKey{old}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, token, err = GenerateJWT(oldKeys, user, true, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecodeAndVerifyJWT(keys, token); err != nil {
+			t.Errorf("%s: Expected token signed with previous key to verify, got: %s", alg, err)
+		}
+
+		// tokens signed with keys not in the set are rejected
+		_, token, err = GenerateJWT(keys, user, true, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecodeAndVerifyJWT(oldKeys, token); err != ErrUnknownTokenKey {
+			t.Errorf("%s: Expected unknown key error, got: %v", alg, err)
+		}
+	}
+}
+
+func TestJWTKeySetAlgorithmConfusion(t *testing.T) {
+	key := mustNewJWTSigningKey(t, v1alpha1.TokenSigningRS256, time.Now().Unix())
+	keys, err := NewJWTKeySet(secret, []*v1.JWTSigningKey{key}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a HMAC token claiming the RSA key ID must not be verified with the key
+	hmacKey := &v1.JWTSigningKey{ID: key.ID, Algorithm: string(v1alpha1.TokenSigningHS256), Key: key.Key}
+	forged, err := NewJWTKeySet(secret, []*v1.JWTSigningKey{hmacKey}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, token, err := GenerateJWT(forged, &v1.AuthResult{User: &v1.VDIUser{Name: "test-user"}}, true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeAndVerifyJWT(keys, token); err == nil {
+		t.Error("Expected token with mismatched algorithm to be rejected, got nil")
+	}
+}
+
+func TestJWTKeySetPublicKeys(t *testing.T) {
+	now := time.Now().Unix()
+	keys, err := NewJWTKeySet(secret, []*v1.JWTSigningKey{
+		mustNewJWTSigningKey(t, v1alpha1.TokenSigningHS256, now-2),
+		mustNewJWTSigningKey(t, v1alpha1.TokenSigningRS256, now-1),
+		mustNewJWTSigningKey(t, v1alpha1.TokenSigningES256, now),
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := keys.PublicKeys()
+	if len(jwks.Keys) != 2 {
+		t.Fatal("Expected only the asymmetric keys to be published, got:", jwks.Keys)
+	}
+	if rsa := jwks.Keys[0]; rsa.KeyType != "RSA" || rsa.Algorithm != "RS256" || rsa.N == "" || rsa.E != "AQAB" {
+		t.Error("Unexpected RSA key:", rsa)
+	}
+	if ec := jwks.Keys[1]; ec.KeyType != "EC" || ec.Curve != "P-256" || len(ec.X) != 43 || len(ec.Y) != 43 {
+		t.Error("Unexpected EC key:", ec)
+	}
+
+	legacy, _ := NewJWTKeySet(secret, nil, false)
+	if jwks := legacy.PublicKeys(); jwks.Keys == nil || len(jwks.Keys) != 0 {
+		t.Error("Expected an empty key set, got:", jwks.Keys)
+	}
+}
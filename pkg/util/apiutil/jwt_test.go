@@ -9,13 +9,15 @@ import (
 
 var secret = []byte("test-secret")
 
+var secretKeys, _ = NewJWTKeySet(secret, nil, false)
+
 func TestGenerateJWT(t *testing.T) {
 	authResult := &v1.AuthResult{
 		User: &v1.VDIUser{
 			Name: "test-user",
 		},
 	}
-	claims, token, err := GenerateJWT(secret, authResult, true, time.Duration(30)*time.Second)
+	claims, token, err := GenerateJWT(secretKeys, authResult, true, time.Duration(30)*time.Second)
 	if err != nil {
 		t.Fatal("Expected no error generating JWT")
 	}
@@ -31,7 +33,7 @@ func TestGenerateJWT(t *testing.T) {
 
 func mustGenerateJWT(t *testing.T, authorized bool, duration time.Duration) string {
 	t.Helper()
-	_, token, err := GenerateJWT(secret, &v1.AuthResult{
+	_, token, err := GenerateJWT(secretKeys, &v1.AuthResult{
 		User: &v1.VDIUser{
 			Name: "test-user",
 		},
@@ -44,7 +46,7 @@ func mustGenerateJWT(t *testing.T, authorized bool, duration time.Duration) stri
 
 func mustDecodeAndVerifyJWT(t *testing.T, token string) *v1.JWTClaims {
 	t.Helper()
-	claims, err := DecodeAndVerifyJWT(secret, token)
+	claims, err := DecodeAndVerifyJWT(secretKeys, token)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -68,6 +70,20 @@ func TestDecodeAndVerifyJWT(t *testing.T) {
 		t.Error("Expected username to be 'test-user', got:", claims.User.Name)
 	}
 
+	// standard claims
+	if claims.Id == "" {
+		t.Error("Expected token to have an ID")
+	}
+	if claims.IssuedAt == 0 || claims.ExpiresAt <= claims.IssuedAt {
+		t.Errorf("Expected token to expire after it was issued, got iat: %d exp: %d", claims.IssuedAt, claims.ExpiresAt)
+	}
+	if claims.IssuedAtMillis/1000 != claims.IssuedAt {
+		t.Errorf("Expected millisecond issue time within the issued-at second, got iatMillis: %d iat: %d", claims.IssuedAtMillis, claims.IssuedAt)
+	}
+	if other := mustDecodeAndVerifyJWT(t, mustGenerateJWT(t, true, time.Duration(10)*time.Second)); other.Id == claims.Id {
+		t.Error("Expected tokens to have unique IDs")
+	}
+
 	// non-authorized token
 	token = mustGenerateJWT(t, false, time.Duration(10)*time.Second)
 	claims = mustDecodeAndVerifyJWT(t, token)
@@ -78,14 +94,14 @@ func TestDecodeAndVerifyJWT(t *testing.T) {
 	// invalid token test cases
 
 	// something not even readable
-	_, err = DecodeAndVerifyJWT(secret, "fuckeduptoken")
+	_, err = DecodeAndVerifyJWT(secretKeys, "fuckeduptoken")
 	if err == nil {
 		t.Error("Expected error trying to parse a bad token, got nil")
 	}
 
 	// mess up the signature
 	token = mustGenerateJWT(t, true, time.Duration(10)*time.Second)
-	_, err = DecodeAndVerifyJWT(secret, token[:len(token)-5])
+	_, err = DecodeAndVerifyJWT(secretKeys, token[:len(token)-5])
 	if err == nil {
 		t.Error("Expected error from bad signature, got nil")
 	} else if err != errTokenSigInvalidError {
@@ -95,7 +111,7 @@ func TestDecodeAndVerifyJWT(t *testing.T) {
 	// expired token
 	token = mustGenerateJWT(t, true, time.Duration(1)*time.Second)
 	time.Sleep(2 * time.Second)
-	_, err = DecodeAndVerifyJWT(secret, token)
+	_, err = DecodeAndVerifyJWT(secretKeys, token)
 	if err == nil {
 		t.Error("Expected error from expired token, got nil")
 	} else if err != errTokenExpiredError {
@@ -104,10 +120,58 @@ func TestDecodeAndVerifyJWT(t *testing.T) {
 
 	// mess up the data
 	token = mustGenerateJWT(t, true, time.Duration(10)*time.Second)
-	_, err = DecodeAndVerifyJWT(secret, token[3:])
+	_, err = DecodeAndVerifyJWT(secretKeys, token[3:])
 	if err == nil {
 		t.Error("Expected error from malformed data, got nil")
 	} else if err != errTokenMalformedError {
 		t.Error("Expected malformed token error, got:", err)
 	}
 }
+
+func TestPasswordResetJWT(t *testing.T) {
+	claims, token, err := GeneratePasswordResetJWT(secret, "test-user", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodePasswordResetJWT(secret, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Subject != "test-user" || decoded.Id != claims.Id {
+		t.Error("Expected decoded claims to match, got:", decoded)
+	}
+
+	// reset tokens are not valid sessions, and sessions are not valid reset tokens
+	if _, err := DecodeAndVerifyJWT(secretKeys, token); err == nil {
+		t.Error("Expected reset token to be rejected as a session, got nil")
+	}
+	if _, err := DecodePasswordResetJWT(secret, mustGenerateJWT(t, true, time.Minute)); err == nil {
+		t.Error("Expected session token to be rejected as a reset token, got nil")
+	}
+	if _, err := DecodePasswordResetJWT([]byte("other"), token); err == nil {
+		t.Error("Expected token signed with another secret to be rejected, got nil")
+	}
+
+	_, expired, err := GeneratePasswordResetJWT(secret, "test-user", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodePasswordResetJWT(secret, expired); err == nil {
+		t.Error("Expected expired token to be rejected, got nil")
+	}
+}
+
+func TestGenerateImpersonationJWT(t *testing.T) {
+	user := &v1.VDIUser{Name: "test-user", Roles: []*v1.VDIUserRole{{Name: "test-role"}}}
+	claims, token, err := GenerateImpersonationJWT(secretKeys, user, "admin", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := mustDecodeAndVerifyJWT(t, token)
+	if decoded.User.Name != "test-user" || decoded.Impersonator != "admin" || decoded.Id != claims.Id {
+		t.Error("Expected decoded claims to match, got:", decoded)
+	}
+	if !decoded.Authorized || decoded.Renewable {
+		t.Error("Expected impersonation claims to be authorized and not renewable, got:", decoded)
+	}
+}
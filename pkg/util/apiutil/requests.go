@@ -80,12 +80,60 @@ func GetRoleFromRequest(r *http.Request) string {
 	return vars["role"]
 }
 
+// GetGroupFromRequest will retrieve the group variable from a request path.
+func GetGroupFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["group"]
+}
+
+// GetAnnouncementFromRequest will retrieve the announcement variable from a request path.
+func GetAnnouncementFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["announcement"]
+}
+
 // GetTemplateFromRequest will retrieve the template variable from a request path.
 func GetTemplateFromRequest(r *http.Request) string {
 	vars := mux.Vars(r)
 	return vars["template"]
 }
 
+// GetNodeFromRequest will retrieve the node variable from a request path.
+func GetNodeFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["node"]
+}
+
+// GetAPIKeyFromRequest will retrieve the apikey variable from a request path.
+func GetAPIKeyFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["apikey"]
+}
+
+// GetRecordingFromRequest will retrieve the recording variable from a request path.
+func GetRecordingFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["recording"]
+}
+
+// GetInviteFromRequest will retrieve the invite variable from a request path.
+func GetInviteFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["invite"]
+}
+
+// GetViewTokenFromRequest will retrieve the view token variable from a request path.
+func GetViewTokenFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["viewtoken"]
+}
+
+// GetJobFromRequest will retrieve the job variable from a request path.
+func GetJobFromRequest(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["job"]
+}
+
 // GetGorillaPath will retrieve the URL path as it was configured in mux.
 func GetGorillaPath(r *http.Request) string {
 	rt := mux.CurrentRoute(r)
@@ -59,6 +59,12 @@ func ReturnAPIForbidden(err error, msg string, w http.ResponseWriter) {
 	WriteOrLogError(errors.ToAPIError(fmt.Errorf("Forbidden: %s", msg)).JSON(), w, http.StatusForbidden)
 }
 
+// ReturnAPITooManyRequests returns a TooManyRequests status code with a json encoded
+// error message.
+func ReturnAPITooManyRequests(err error, w http.ResponseWriter) {
+	WriteOrLogError(errors.ToAPIError(err).JSON(), w, http.StatusTooManyRequests)
+}
+
 // WriteJSON encodes the provided interface to JSON and writes it to the response
 // stream.
 func WriteJSON(i interface{}, w http.ResponseWriter) {
@@ -70,6 +76,14 @@ func WriteJSON(i interface{}, w http.ResponseWriter) {
 	WriteOrLogError(out, w, http.StatusOK)
 }
 
+// Flush sends any buffered data in the response to the client, if the writer
+// supports it. It is used by middlewares wrapping the http.ResponseWriter.
+func Flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // UnmarshalRequest will read the body of the given request and decode it into
 // the given interface.
 func UnmarshalRequest(r *http.Request, in interface{}) error {
@@ -0,0 +1,160 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field describes the bounds and names allowed in a single cron field.
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{
+		name: "month", min: 1, max: 12,
+		names: map[string]int{
+			"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+			"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+		},
+	}
+	// Sunday can be represented as either 0 or 7
+	dowField = field{
+		name: "day of week", min: 0, max: 7,
+		names: map[string]int{
+			"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+		},
+	}
+)
+
+// Schedule represents a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Whether the day fields were unrestricted, which changes how they are
+	// combined when matching.
+	domStar, dowStar bool
+}
+
+// Parse parses a standard five-field cron expression (minute, hour, day of month,
+// month, and day of week). Each field may be a `*`, a value, a range (`1-5`), or
+// a comma-separated list of them, and values and ranges may have a step
+// (`*/15`). Months and days of the week may also be given by their three-letter
+// English names.
+func Parse(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Expected 5 fields in cron expression %q, got %d", expr, len(fields))
+	}
+	s := &Schedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		spec field
+	}{
+		{&s.minute, minuteField},
+		{&s.hour, hourField},
+		{&s.dom, domField},
+		{&s.month, monthField},
+		{&s.dow, dowField},
+	} {
+		if *f.bits, err = parseField(fields[i], f.spec); err != nil {
+			return nil, err
+		}
+	}
+	// normalize sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// Matches returns true if the minute containing the given time matches the
+// schedule. If both the day of month and day of week are restricted, a time
+// matches if either of them match.
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 ||
+		s.hour&(1<<uint(t.Hour())) == 0 ||
+		s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// parseField parses a single field of a cron expression into a bitset of the
+// values it matches.
+func parseField(expr string, spec field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, step := part, 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			var err error
+			rangeExpr = part[:idx]
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("Invalid step in %s field: %q", spec.name, part)
+			}
+		}
+
+		var start, end int
+		switch {
+		case rangeExpr == "*":
+			start, end = spec.min, spec.max
+		case strings.Contains(rangeExpr, "-"):
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			if start, err = parseValue(bounds[0], spec); err != nil {
+				return 0, err
+			}
+			if end, err = parseValue(bounds[1], spec); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if start, err = parseValue(rangeExpr, spec); err != nil {
+				return 0, err
+			}
+			end = start
+			// a single value with a step runs to the end of the range
+			if step != 1 {
+				end = spec.max
+			}
+		}
+		if start > end {
+			return 0, fmt.Errorf("Invalid range in %s field: %q", spec.name, part)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a single value or name in a cron field.
+func parseValue(val string, spec field) (int, error) {
+	if n, ok := spec.names[strings.ToLower(val)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid value in %s field: %q", spec.name, val)
+	}
+	if n < spec.min || n > spec.max {
+		return 0, fmt.Errorf("Value %d out of range for %s field (%d-%d)", n, spec.name, spec.min, spec.max)
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		"0 8 * * MON-FRI",
+		"*/15 9-17 1,15 jan-jun 0",
+		"30 22 * * 7",
+	} {
+		if _, err := Parse(expr); err != nil {
+			t.Errorf("Expected %q to parse, got: %s", expr, err)
+		}
+	}
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * foo *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Expected error parsing %q", expr)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	// Wednesday, January 15th 2020
+	wed := time.Date(2020, time.January, 15, 8, 0, 30, 0, time.UTC)
+
+	tc := []struct {
+		expr    string
+		t       time.Time
+		matches bool
+	}{
+		{"* * * * *", wed, true},
+		{"0 8 * * MON-FRI", wed, true},
+		{"0 8 * * SAT,SUN", wed, false},
+		{"1 8 * * *", wed, false},
+		{"*/20 8 * * *", wed.Add(40 * time.Minute), true},
+		{"*/20 8 * * *", wed.Add(30 * time.Minute), false},
+		{"0 8 15 feb *", wed, false},
+		// day of month or day of week when both are restricted
+		{"0 8 1 * 3", wed, true},
+		{"0 8 15 * 1", wed, true},
+		{"0 8 1 * 1", wed, false},
+		// sunday as 7
+		{"0 8 * * 7", time.Date(2020, time.January, 19, 8, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, c := range tc {
+		s, err := Parse(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Matches(c.t) != c.matches {
+			t.Errorf("Expected %q matching %s to be %v", c.expr, c.t, c.matches)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+// Package cron contains a parser for standard five-field cron expressions.
+package cron
@@ -8,6 +8,7 @@ import (
 const (
 	userNotFoundFormat = "User '%s' not found in the cluster"
 	roleNotFoundFormat = "Role '%s' not found in the cluster"
+	invalidCredsFormat = "Invalid credentials for user '%s'"
 )
 
 // UserNotFoundError is an error signaling that the requested user was not found.
@@ -59,3 +60,29 @@ func IsRoleNotFoundError(err error) bool {
 	}
 	return false
 }
+
+// InvalidCredentialsError is an error signaling that the credentials provided for
+// a user were incorrect.
+type InvalidCredentialsError struct {
+	errMsg string
+}
+
+// Error implements the error interface.
+func (r *InvalidCredentialsError) Error() string {
+	return r.errMsg
+}
+
+// NewInvalidCredentialsError returns a new InvalidCredentialsError for the provided username.
+func NewInvalidCredentialsError(user string) error {
+	return &InvalidCredentialsError{
+		errMsg: fmt.Sprintf(invalidCredsFormat, user),
+	}
+}
+
+// IsInvalidCredentialsError returns true if the given error interface is an InvalidCredentialsError.
+func IsInvalidCredentialsError(err error) bool {
+	if _, ok := err.(*InvalidCredentialsError); ok {
+		return true
+	}
+	return false
+}
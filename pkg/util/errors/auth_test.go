@@ -35,4 +35,17 @@ func TestRethinkDBErrors(t *testing.T) {
 		t.Error("Generic error should not evaluate to RoleNotFoundError")
 	}
 
+	// InvalidCredentialsError
+
+	invalidCreds := NewInvalidCredentialsError("fakeUser")
+	if invalidCreds.Error() != fmt.Sprintf(invalidCredsFormat, "fakeUser") {
+		t.Error("Error message for invalid credentials is malformed")
+	}
+	if !IsInvalidCredentialsError(invalidCreds) {
+		t.Error("Error should be valid InvalidCredentialsError")
+	}
+	if IsInvalidCredentialsError(errors.New("fake error")) {
+		t.Error("Generic error should not evaluate to InvalidCredentialsError")
+	}
+
 }
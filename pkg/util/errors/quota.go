@@ -0,0 +1,56 @@
+package errors
+
+import "fmt"
+
+// The error message format for a QuotaExceededError
+const quotaExceededFormat = "Session quota exceeded, %d of %d %s are in use"
+
+// The error message format for a QuotaExceededError on a resource quantity
+const resourceQuotaExceededFormat = "Session quota exceeded, %s of %s %s would be in use"
+
+// The error message format for a QuotaExceededError on the capacity of a template
+const capacityReachedFormat = "Capacity reached for template %s, %d of %d sessions are in use and %d ahead of you"
+
+// QuotaExceededError is used to signal that a request would exceed one of the
+// session quotas applied to a user.
+type QuotaExceededError struct {
+	errMsg string
+}
+
+// Error implements the error interface
+func (r *QuotaExceededError) Error() string {
+	return r.errMsg
+}
+
+// NewQuotaExceededError returns a new QuotaExceededError for the given usage
+// and limit. The description is what is being limited, e.g. `sessions`.
+func NewQuotaExceededError(used, limit int, description string) error {
+	return &QuotaExceededError{
+		errMsg: fmt.Sprintf(quotaExceededFormat, used, limit, description),
+	}
+}
+
+// NewResourceQuotaExceededError returns a new QuotaExceededError for a resource
+// quantity, e.g. `cpu` or `memory`, that would be over its limit.
+func NewResourceQuotaExceededError(used, limit, resource string) error {
+	return &QuotaExceededError{
+		errMsg: fmt.Sprintf(resourceQuotaExceededFormat, used, limit, resource),
+	}
+}
+
+// NewCapacityReachedError returns a new QuotaExceededError for a template that has
+// reached its maximum number of sessions, and the number of users queued ahead of
+// the one making the request.
+func NewCapacityReachedError(template string, used, limit, ahead int) error {
+	return &QuotaExceededError{
+		errMsg: fmt.Sprintf(capacityReachedFormat, template, used, limit, ahead),
+	}
+}
+
+// IsQuotaExceededError returns true if the given error is a QuotaExceededError.
+func IsQuotaExceededError(err error) bool {
+	if _, ok := err.(*QuotaExceededError); ok {
+		return true
+	}
+	return false
+}
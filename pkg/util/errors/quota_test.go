@@ -0,0 +1,31 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestQuotaExceededError(t *testing.T) {
+	qerr := NewQuotaExceededError(2, 2, "sessions")
+
+	if qerr.Error() != fmt.Sprintf(quotaExceededFormat, 2, 2, "sessions") {
+		t.Error("Error body is malformed")
+	}
+
+	if ok := IsQuotaExceededError(qerr); !ok {
+		t.Error("Should be a valid quota exceeded error")
+	}
+
+	if ok := IsQuotaExceededError(NewResourceQuotaExceededError("3", "2", "cpu")); !ok {
+		t.Error("Should be a valid quota exceeded error")
+	}
+
+	if ok := IsQuotaExceededError(NewCapacityReachedError("ubuntu", 2, 2, 1)); !ok {
+		t.Error("Should be a valid quota exceeded error")
+	}
+
+	if ok := IsQuotaExceededError(errors.New("fake error")); ok {
+		t.Error("IsQuotaExceededError returned valid for invalid error")
+	}
+}
@@ -0,0 +1,3 @@
+// Package guac contains utilities for speaking the Guacamole protocol to guacd,
+// which is used to serve RDP displays to the web client.
+package guac
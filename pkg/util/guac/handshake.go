@@ -0,0 +1,100 @@
+package guac
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// versionPrefix is the prefix of the protocol version guacd sends as the first
+// connection parameter.
+const versionPrefix = "VERSION_"
+
+// Config is the configuration for a connection made through guacd.
+type Config struct {
+	// The protocol for guacd to connect with, e.g. `rdp`.
+	Protocol string
+	// The values of the connection parameters requested by guacd. Parameters
+	// that are not set are sent empty, which leaves them at their defaults.
+	Parameters map[string]string
+	// The size and resolution of the client display.
+	Width, Height, DPI int
+	// The mimetypes supported by the client.
+	AudioMimetypes, VideoMimetypes, ImageMimetypes []string
+	// The timezone of the client, if known.
+	Timezone string
+}
+
+// Handshake selects the configured protocol on a new connection to guacd and
+// provides the client information and connection parameters. The ID of the
+// connection is returned once guacd reports it is ready.
+func Handshake(server io.Writer, rdr *bufio.Reader, cfg *Config) (string, error) {
+	if err := writeInstruction(server, NewInstruction("select", cfg.Protocol)); err != nil {
+		return "", err
+	}
+	args, err := expectInstruction(rdr, "args")
+	if err != nil {
+		return "", err
+	}
+
+	clientInfo := []*Instruction{
+		NewInstruction("size", strconv.Itoa(cfg.Width), strconv.Itoa(cfg.Height), strconv.Itoa(cfg.DPI)),
+		NewInstruction("audio", cfg.AudioMimetypes...),
+		NewInstruction("video", cfg.VideoMimetypes...),
+		NewInstruction("image", cfg.ImageMimetypes...),
+	}
+	if cfg.Timezone != "" {
+		clientInfo = append(clientInfo, NewInstruction("timezone", cfg.Timezone))
+	}
+	for _, inst := range clientInfo {
+		if err := writeInstruction(server, inst); err != nil {
+			return "", err
+		}
+	}
+
+	values := make([]string, len(args.Args))
+	for idx, name := range args.Args {
+		if strings.HasPrefix(name, versionPrefix) {
+			// accept the version offered by guacd
+			values[idx] = name
+			continue
+		}
+		values[idx] = cfg.Parameters[name]
+	}
+	if err := writeInstruction(server, NewInstruction("connect", values...)); err != nil {
+		return "", err
+	}
+
+	ready, err := expectInstruction(rdr, "ready")
+	if err != nil {
+		return "", err
+	}
+	if len(ready.Args) == 0 {
+		return "", fmt.Errorf("guacd did not return a connection ID")
+	}
+	return ready.Args[0], nil
+}
+
+// expectInstruction reads the next instruction and returns an error if it does
+// not have the given opcode.
+func expectInstruction(rdr *bufio.Reader, opcode string) (*Instruction, error) {
+	inst, err := ReadInstruction(rdr)
+	if err != nil {
+		return nil, err
+	}
+	if inst.Opcode == "error" && len(inst.Args) > 0 {
+		return nil, fmt.Errorf("guacd returned an error: %s", inst.Args[0])
+	}
+	if inst.Opcode != opcode {
+		return nil, fmt.Errorf("Expected '%s' instruction from guacd, got '%s'", opcode, inst.Opcode)
+	}
+	return inst, nil
+}
+
+// writeInstruction writes the given instruction in a single call.
+func writeInstruction(w io.Writer, inst *Instruction) error {
+	_, err := io.WriteString(w, inst.String())
+	return err
+}
@@ -0,0 +1,89 @@
+package guac
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// fakeGuacd plays the server side of a handshake, sending the given connection
+// parameters and returning the instructions it receives.
+func fakeGuacd(t *testing.T, conn net.Conn, params []string) <-chan []*Instruction {
+	received := make(chan []*Instruction, 1)
+	go func() {
+		rdr := bufio.NewReader(conn)
+		insts := make([]*Instruction, 0)
+		for {
+			inst, err := ReadInstruction(rdr)
+			if err != nil {
+				t.Error(err)
+				close(received)
+				return
+			}
+			insts = append(insts, inst)
+			switch inst.Opcode {
+			case "select":
+				conn.Write([]byte(NewInstruction("args", params...).String()))
+			case "connect":
+				conn.Write([]byte(NewInstruction("ready", "$abc").String()))
+				received <- insts
+				return
+			}
+		}
+	}()
+	return received
+}
+
+func TestHandshake(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	received := fakeGuacd(t, server, []string{"VERSION_1_3_0", "hostname", "password", "domain"})
+
+	id, err := Handshake(client, bufio.NewReader(client), &Config{
+		Protocol:       "rdp",
+		Parameters:     map[string]string{"hostname": "localhost", "password": "secret", "ignored": "true"},
+		Width:          1024,
+		Height:         768,
+		DPI:            96,
+		ImageMimetypes: []string{"image/png"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "$abc" {
+		t.Error("Got unexpected connection ID:", id)
+	}
+
+	insts := <-received
+	opcodes := make([]string, len(insts))
+	for idx, inst := range insts {
+		opcodes[idx] = inst.Opcode
+	}
+	if !reflect.DeepEqual(opcodes, []string{"select", "size", "audio", "video", "image", "connect"}) {
+		t.Error("Got unexpected handshake:", opcodes)
+	}
+	if insts[0].Args[0] != "rdp" {
+		t.Error("Expected rdp to be selected, got:", insts[0].Args)
+	}
+	if !reflect.DeepEqual(insts[5].Args, []string{"VERSION_1_3_0", "localhost", "secret", ""}) {
+		t.Error("Got unexpected connection parameters:", insts[5].Args)
+	}
+}
+
+func TestHandshakeError(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		ReadInstruction(bufio.NewReader(server))
+		server.Write([]byte(NewInstruction("error", "Unsupported protocol", "768").String()))
+	}()
+
+	if _, err := Handshake(client, bufio.NewReader(client), &Config{Protocol: "fake"}); err == nil {
+		t.Error("Expected error from failed handshake")
+	}
+}
@@ -0,0 +1,81 @@
+package guac
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// InternalOpcode is the opcode of instructions exchanged between a tunnel and its
+// client that are never forwarded to guacd.
+const InternalOpcode = ""
+
+// maxElementLength is the longest element that will be read from a stream. This
+// is well above anything guacd or the web client send, and only guards against
+// a misbehaving peer.
+const maxElementLength = 64 * 1024
+
+// Instruction is a single Guacamole protocol instruction.
+type Instruction struct {
+	Opcode string
+	Args   []string
+}
+
+// NewInstruction returns a new instruction with the given opcode and arguments.
+func NewInstruction(opcode string, args ...string) *Instruction {
+	return &Instruction{Opcode: opcode, Args: args}
+}
+
+// String returns the wire encoding of the instruction. The length prefix of each
+// element is the number of Unicode characters it contains.
+func (i *Instruction) String() string {
+	var b strings.Builder
+	for idx, elem := range append([]string{i.Opcode}, i.Args...) {
+		if idx > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(utf8.RuneCountInString(elem)))
+		b.WriteByte('.')
+		b.WriteString(elem)
+	}
+	b.WriteByte(';')
+	return b.String()
+}
+
+// ReadInstruction reads the next complete instruction from the given reader.
+func ReadInstruction(rdr *bufio.Reader) (*Instruction, error) {
+	elems := make([]string, 0)
+	for {
+		prefix, err := rdr.ReadString('.')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(prefix[:len(prefix)-1])
+		if err != nil || length < 0 || length > maxElementLength {
+			return nil, fmt.Errorf("Invalid element length: %q", prefix)
+		}
+		var b strings.Builder
+		for n := 0; n < length; n++ {
+			r, _, err := rdr.ReadRune()
+			if err != nil {
+				return nil, err
+			}
+			b.WriteRune(r)
+		}
+		elems = append(elems, b.String())
+		term, err := rdr.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch term {
+		case ',':
+			continue
+		case ';':
+			return &Instruction{Opcode: elems[0], Args: elems[1:]}, nil
+		default:
+			return nil, fmt.Errorf("Invalid element terminator: %q", term)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package guac
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInstructionString(t *testing.T) {
+	inst := NewInstruction("size", "1024", "768", "96")
+	if got := inst.String(); got != "4.size,4.1024,3.768,2.96;" {
+		t.Error("Got unexpected encoding:", got)
+	}
+	// lengths are in characters, not bytes
+	if got := NewInstruction("name", "héllo").String(); got != "4.name,5.héllo;" {
+		t.Error("Got unexpected encoding:", got)
+	}
+	if got := NewInstruction(InternalOpcode, "ping").String(); got != "0.,4.ping;" {
+		t.Error("Got unexpected encoding:", got)
+	}
+}
+
+func TestReadInstruction(t *testing.T) {
+	rdr := bufio.NewReader(strings.NewReader("4.args,13.VERSION_1_3_0,8.hostname,4.port;5.héllo;0.,4.ping;"))
+
+	inst, err := ReadInstruction(rdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Opcode != "args" || !reflect.DeepEqual(inst.Args, []string{"VERSION_1_3_0", "hostname", "port"}) {
+		t.Error("Got unexpected instruction:", inst)
+	}
+
+	inst, err = ReadInstruction(rdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Opcode != "héllo" || len(inst.Args) != 0 {
+		t.Error("Got unexpected instruction:", inst)
+	}
+
+	inst, err = ReadInstruction(rdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Opcode != InternalOpcode || !reflect.DeepEqual(inst.Args, []string{"ping"}) {
+		t.Error("Got unexpected instruction:", inst)
+	}
+
+	for _, invalid := range []string{"4.size|", "x.size;", "-1.size;", "10.size;"} {
+		if _, err := ReadInstruction(bufio.NewReader(strings.NewReader(invalid))); err == nil {
+			t.Errorf("Expected error reading %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package guac
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// Proxy relays instructions between a client of the Guacamole websocket tunnel and
+// a connection to guacd that has completed the handshake. The client is first sent
+// the ID of the connection, and internal instructions it sends, such as pings, are
+// answered here rather than forwarded. Every instruction is written to the client
+// in a single call so that it arrives as a single websocket message. Proxy returns
+// when either side of the connection ends, and the caller is responsible for
+// closing both connections.
+func Proxy(client io.ReadWriter, server io.Writer, serverRdr *bufio.Reader, id string) error {
+	var mux sync.Mutex
+	writeClient := func(inst *Instruction) error {
+		mux.Lock()
+		defer mux.Unlock()
+		return writeInstruction(client, inst)
+	}
+
+	if err := writeClient(NewInstruction(InternalOpcode, id)); err != nil {
+		return err
+	}
+
+	errs := make(chan error, 2)
+
+	go func() {
+		clientRdr := bufio.NewReader(client)
+		for {
+			inst, err := ReadInstruction(clientRdr)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if inst.Opcode == InternalOpcode {
+				if len(inst.Args) > 0 && inst.Args[0] == "ping" {
+					if err := writeClient(inst); err != nil {
+						errs <- err
+						return
+					}
+				}
+				continue
+			}
+			if err := writeInstruction(server, inst); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			inst, err := ReadInstruction(serverRdr)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := writeClient(inst); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	if err := <-errs; err != io.EOF {
+		return err
+	}
+	return nil
+}
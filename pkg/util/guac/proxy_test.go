@@ -0,0 +1,61 @@
+package guac
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	clientConn, proxyClient := net.Pipe()
+	proxyServer, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	go func() {
+		Proxy(proxyClient, proxyServer, bufio.NewReader(proxyServer), "$abc")
+		proxyClient.Close()
+		proxyServer.Close()
+	}()
+
+	clientRdr := bufio.NewReader(clientConn)
+	serverRdr := bufio.NewReader(serverConn)
+
+	// the client should first be sent the ID of the connection
+	inst, err := ReadInstruction(clientRdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.String() != "0.,4.$abc;" {
+		t.Error("Expected connection ID, got:", inst)
+	}
+
+	// pings should be answered without reaching guacd
+	clientConn.Write([]byte("0.,4.ping,3.123;"))
+	inst, err = ReadInstruction(clientRdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.String() != "0.,4.ping,3.123;" {
+		t.Error("Expected ping to be returned, got:", inst)
+	}
+
+	// other instructions are relayed in both directions
+	clientConn.Write([]byte("5.mouse,2.10,2.20,1.1;"))
+	inst, err = ReadInstruction(serverRdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.String() != "5.mouse,2.10,2.20,1.1;" {
+		t.Error("Expected mouse instruction at server, got:", inst)
+	}
+
+	serverConn.Write([]byte("4.sync,4.1234;"))
+	inst, err = ReadInstruction(clientRdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.String() != "4.sync,4.1234;" {
+		t.Error("Expected sync instruction at client, got:", inst)
+	}
+}
@@ -0,0 +1,53 @@
+package k8sutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// nodeStatsSummary is the subset of the kubelet stats summary used for reading
+// pod ephemeral storage usage.
+type nodeStatsSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		EphemeralStorage *struct {
+			UsedBytes *uint64 `json:"usedBytes"`
+		} `json:"ephemeral-storage"`
+	} `json:"pods"`
+}
+
+// GetPodEphemeralStorageUsage queries the kubelet stats summary for the given node,
+// via the API server, and returns the ephemeral storage used by each pod on it in bytes.
+func GetPodEphemeralStorageUsage(nodeName string) (map[types.NamespacedName]int64, error) {
+	if DefaultClient == nil {
+		return nil, errors.New("No in-cluster client is available")
+	}
+	raw, err := DefaultClient.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix("stats/summary").
+		DoRaw(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	summary := &nodeStatsSummary{}
+	if err := json.Unmarshal(raw, summary); err != nil {
+		return nil, err
+	}
+	usage := make(map[types.NamespacedName]int64)
+	for _, pod := range summary.Pods {
+		if pod.EphemeralStorage == nil || pod.EphemeralStorage.UsedBytes == nil {
+			continue
+		}
+		nn := types.NamespacedName{Name: pod.PodRef.Name, Namespace: pod.PodRef.Namespace}
+		usage[nn] = int64(*pod.EphemeralStorage.UsedBytes)
+	}
+	return usage, nil
+}
@@ -0,0 +1,73 @@
+package k8sutil
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// VolumeSnapshotAPIGroup is the API group of VolumeSnapshots.
+const VolumeSnapshotAPIGroup = "snapshot.storage.k8s.io"
+
+// volumeSnapshotAPIVersion is the version of the VolumeSnapshot API used by kVDI.
+// The snapshot CRDs are not part of the core API, so they are handled as
+// unstructured objects.
+const volumeSnapshotAPIVersion = VolumeSnapshotAPIGroup + "/v1beta1"
+
+// NewVolumeSnapshot returns a new VolumeSnapshot of the given PVC. The name of the
+// snapshot is the name of the PVC suffixed with the current time.
+func NewVolumeSnapshot(pvcName, namespace, class string, labels map[string]string) *unstructured.Unstructured {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetAPIVersion(volumeSnapshotAPIVersion)
+	snapshot.SetKind("VolumeSnapshot")
+	snapshot.SetName(fmt.Sprintf("%s-%d", pvcName, time.Now().Unix()))
+	snapshot.SetNamespace(namespace)
+	snapshot.SetLabels(labels)
+	snapshot.Object["spec"] = map[string]interface{}{
+		"volumeSnapshotClassName": class,
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvcName,
+		},
+	}
+	return snapshot
+}
+
+// NewVolumeSnapshotObject returns an empty VolumeSnapshot for retrieving one from
+// the API.
+func NewVolumeSnapshotObject() *unstructured.Unstructured {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetAPIVersion(volumeSnapshotAPIVersion)
+	snapshot.SetKind("VolumeSnapshot")
+	return snapshot
+}
+
+// NewVolumeSnapshotList returns an empty list for retrieving VolumeSnapshots from
+// the API.
+func NewVolumeSnapshotList() *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion(volumeSnapshotAPIVersion)
+	list.SetKind("VolumeSnapshotList")
+	return list
+}
+
+// VolumeSnapshotReady returns true if the given VolumeSnapshot can be used to
+// provision new volumes.
+func VolumeSnapshotReady(snapshot *unstructured.Unstructured) bool {
+	ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	return ready
+}
+
+// VolumeSnapshotError returns the error reported while taking the given
+// VolumeSnapshot, or an empty string if there is none.
+func VolumeSnapshotError(snapshot *unstructured.Unstructured) string {
+	msg, _, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message")
+	return msg
+}
+
+// VolumeSnapshotRestoreSize returns the minimum size of a volume restored from the
+// given VolumeSnapshot, or an empty string if it is not known yet.
+func VolumeSnapshotRestoreSize(snapshot *unstructured.Unstructured) string {
+	size, _, _ := unstructured.NestedString(snapshot.Object, "status", "restoreSize")
+	return size
+}
@@ -113,6 +113,15 @@ func GetThisPod(c client.Client) (*corev1.Pod, error) {
 	return pod, c.Get(context.TODO(), nn, pod)
 }
 
+// StreamPodLogs returns a stream of the logs for the pod with the given options. The
+// stream is closed when the context is cancelled.
+func StreamPodLogs(ctx context.Context, pod *corev1.Pod, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	if DefaultClient == nil {
+		return nil, errors.New("There is no raw client configured for scraping logs")
+	}
+	return DefaultClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
+}
+
 // LogFollower implements a ReadCloser for reading logs from a container in a pod.
 type LogFollower struct {
 	ctx           context.Context
@@ -11,6 +11,7 @@ import (
 	"github.com/tinyzimmer/kvdi/pkg/util/common"
 	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
 
+	"github.com/google/uuid"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,6 +28,15 @@ func init() {
 // expireKey is the key in the configmap where we store the expiry data
 const expireKey = "expiresAt"
 
+// holderAnnotation is the annotation on the configmap identifying the instance
+// of the lock that holds it. A single pod may hold the same lock more than once
+// over time, so the owner reference alone cannot tell them apart.
+const holderAnnotation = "kvdi.io/lock-holder"
+
+// takeoverAnnotation is the annotation on the configmap containing the takeover
+// key of the holder, if it has one.
+const takeoverAnnotation = "kvdi.io/lock-takeover-key"
+
 // lockLogger is a logger interface for lock events
 var lockLogger = logf.Log.WithName("lock")
 
@@ -45,6 +55,12 @@ type Lock struct {
 	labels map[string]string
 	// the pod that owns this lock
 	pod *corev1.Pod
+	// a unique identifier for this instance of the lock
+	id string
+	// a key that allows another instance of the lock to take it over
+	takeoverKey string
+	// whether to take the lock from any existing holder
+	force bool
 }
 
 // New returns a new lock. If timeout is a value less than zero, then no expiration
@@ -56,6 +72,7 @@ func New(c client.Client, name string, timeout time.Duration) *Lock {
 		name:    name,
 		timeout: timeout,
 		labels:  map[string]string{},
+		id:      uuid.New().String(),
 	}
 }
 
@@ -65,6 +82,24 @@ func (l *Lock) WithLabels(labels map[string]string) *Lock {
 	return l
 }
 
+// WithTakeoverKey configures a key that allows the lock to be taken over. When the
+// lock is held by another holder with the same key, it is taken from them instead
+// of waiting for it to be released. This is useful when the holder may have gone
+// away without knowing it, e.g. a client reconnecting after its connection dropped.
+// The previous holder can find out with IsHeld.
+func (l *Lock) WithTakeoverKey(key string) *Lock {
+	l.takeoverKey = key
+	return l
+}
+
+// WithForcedTakeover configures the lock to be taken from any existing holder
+// instead of waiting for it to be released, e.g. when a user explicitly moves a
+// connection to another device. The previous holder can find out with IsHeld.
+func (l *Lock) WithForcedTakeover() *Lock {
+	l.force = true
+	return l
+}
+
 // GetName returns the name of this lock.
 func (l *Lock) GetName() string { return l.name }
 
@@ -122,6 +157,14 @@ func (l *Lock) Acquire() error {
 			return err
 		}
 
+		if l.canTakeOver(existingLock) || l.ownerIsGone(ctx, existingLock) {
+			lockLogger.Info("Taking over existing lock", "Lock.Name", l.GetName(), "Owner", existingLock.OwnerReferences[0].Name)
+			if err := l.releaseLock(ctx, existingLock); err != nil {
+				return err
+			}
+			return l.client.Create(ctx, cm)
+		}
+
 		if err := l.checkExistingLockExpiry(ctx, existingLock); err != nil {
 			return err
 		}
@@ -159,8 +202,51 @@ func (l *Lock) checkExistingLockExpiry(ctx context.Context, existingLock *corev1
 	return nil
 }
 
+// canTakeOver returns true if this lock is forcing a takeover, or if the given
+// lock was acquired with the same takeover key as this one.
+func (l *Lock) canTakeOver(existingLock *corev1.ConfigMap) bool {
+	if l.force {
+		return true
+	}
+	return l.takeoverKey != "" && existingLock.GetAnnotations()[takeoverAnnotation] == l.takeoverKey
+}
+
+// ownerIsGone returns true if the pod that acquired the given lock no longer
+// exists or is shutting down, e.g. after scaling down the deployment it belonged to.
+// The lock would be garbage collected eventually, but there is no reason to wait.
+func (l *Lock) ownerIsGone(ctx context.Context, existingLock *corev1.ConfigMap) bool {
+	ref := existingLock.GetOwnerReferences()
+	if len(ref) != 1 {
+		return false
+	}
+	pod := &corev1.Pod{}
+	nn := types.NamespacedName{Name: ref[0].Name, Namespace: existingLock.GetNamespace()}
+	if err := l.client.Get(ctx, nn, pod); err != nil {
+		return kerrors.IsNotFound(err)
+	}
+	return pod.GetUID() != ref[0].UID || pod.GetDeletionTimestamp() != nil
+}
+
+// IsHeld returns true if the lock is still held by this instance. It returns false
+// once the lock has been released or taken over.
+func (l *Lock) IsHeld() (bool, error) {
+	if l.pod == nil {
+		return false, nil
+	}
+	cm := &corev1.ConfigMap{}
+	nn := types.NamespacedName{Name: l.GetName(), Namespace: l.pod.GetNamespace()}
+	if err := l.client.Get(context.TODO(), nn, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return cm.GetAnnotations()[holderAnnotation] == l.id, nil
+}
+
 // Release will delete the configmap, releasing the lock. If the found lock does not
-// belong to the running pod, an error is returned.
+// belong to the running pod, an error is returned. If it was taken over by another
+// instance of the lock, it is left alone.
 func (l *Lock) Release() error {
 	lockLogger.Info("Releasing lock", "Lock.Name", l.name)
 	cm := &corev1.ConfigMap{}
@@ -177,6 +263,10 @@ func (l *Lock) Release() error {
 	if len(ref) != 1 {
 		return fmt.Errorf("Owner references on found lock is malformed: %+v", ref)
 	}
+	if holder, ok := cm.GetAnnotations()[holderAnnotation]; ok && holder != l.id {
+		lockLogger.Info("Lock has been taken over", "Owner", ref[0].Name)
+		return nil
+	}
 	if ref[0].UID != l.pod.GetUID() {
 		return fmt.Errorf("Present lock is not owned by this pod, owned by: %s", ref[0].Name)
 	}
@@ -186,7 +276,9 @@ func (l *Lock) Release() error {
 // releaseLock removes a lock from kubernetes
 func (l *Lock) releaseLock(ctx context.Context, cm *corev1.ConfigMap) error {
 	lockLogger.Info("Releasing lock", "Owner", cm.OwnerReferences[0])
-	if err := l.client.Delete(ctx, cm); err != nil {
+	// make sure a lock acquired by someone else in the meantime is not removed
+	uid := cm.GetUID()
+	if err := l.client.Delete(ctx, cm, client.Preconditions{UID: &uid}); err != nil {
 		if !kerrors.IsNotFound(err) {
 			lockLogger.Error(err, fmt.Sprintf("Error releasing lock: %s", err.Error()))
 			return err
@@ -199,9 +291,10 @@ func (l *Lock) releaseLock(ctx context.Context, cm *corev1.ConfigMap) error {
 func newConfigMapForLock(l *Lock) *corev1.ConfigMap {
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      l.GetName(),
-			Namespace: l.pod.GetNamespace(),
-			Labels:    l.labels,
+			Name:        l.GetName(),
+			Namespace:   l.pod.GetNamespace(),
+			Labels:      l.labels,
+			Annotations: l.getAnnotations(),
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion:         "v1",
@@ -216,3 +309,12 @@ func newConfigMapForLock(l *Lock) *corev1.ConfigMap {
 		Data: l.GetCMData(),
 	}
 }
+
+// getAnnotations returns the annotations to apply to the configmap for this lock.
+func (l *Lock) getAnnotations() map[string]string {
+	annotations := map[string]string{holderAnnotation: l.id}
+	if l.takeoverKey != "" {
+		annotations[takeoverAnnotation] = l.takeoverKey
+	}
+	return annotations
+}
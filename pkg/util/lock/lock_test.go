@@ -184,3 +184,101 @@ func TestLockWithLabels(t *testing.T) {
 		t.Error("Expected value of 'test-key' to be 'test-value', got:", val)
 	}
 }
+
+func TestLockTakeover(t *testing.T) {
+	l, c := setupLock(t, -1)
+	l = l.WithTakeoverKey("test-key")
+	if err := l.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := l.IsHeld(); err != nil || !held {
+		t.Fatal("Expected lock to be held, got:", held, err)
+	}
+
+	// a lock with the same key takes it over without waiting
+	nl := New(c, "test-lock", -1).WithTakeoverKey("test-key")
+	if err := nl.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := l.IsHeld(); err != nil || held {
+		t.Error("Expected original lock to no longer be held, got:", held, err)
+	}
+	if held, err := nl.IsHeld(); err != nil || !held {
+		t.Error("Expected new lock to be held, got:", held, err)
+	}
+
+	// releasing the original lock leaves the new one in place
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := nl.IsHeld(); err != nil || !held {
+		t.Error("Expected new lock to still be held, got:", held, err)
+	}
+	if err := nl.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := nl.IsHeld(); err != nil || held {
+		t.Error("Expected new lock to be released, got:", held, err)
+	}
+}
+
+func TestLockForcedTakeover(t *testing.T) {
+	l, c := setupLock(t, -1)
+	l = l.WithTakeoverKey("test-key")
+	if err := l.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a forced lock takes it over regardless of its key
+	nl := New(c, "test-lock", -1).WithTakeoverKey("other-key").WithForcedTakeover()
+	if err := nl.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := l.IsHeld(); err != nil || held {
+		t.Error("Expected original lock to no longer be held, got:", held, err)
+	}
+	if held, err := nl.IsHeld(); err != nil || !held {
+		t.Error("Expected new lock to be held, got:", held, err)
+	}
+
+	// the original key no longer takes it back
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "test-lock", Namespace: "test-namespace"}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if New(c, "test-lock", -1).WithTakeoverKey("test-key").canTakeOver(cm) {
+		t.Error("Expected the original key to no longer take over the lock")
+	}
+}
+
+func TestLockOwnerGone(t *testing.T) {
+	l, c := setupLock(t, -1)
+
+	// a lock left behind by a pod that no longer exists
+	stale := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-lock",
+			Namespace: "test-namespace",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "v1", Kind: "Pod", Name: "deleted-pod", UID: "deleted-pod-uid"},
+			},
+		},
+	}
+	if err := c.Create(context.TODO(), stale); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- l.Acquire() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected lock owned by a deleted pod to be acquired")
+	}
+	if held, err := l.IsHeld(); err != nil || !held {
+		t.Error("Expected lock to be held, got:", held, err)
+	}
+}
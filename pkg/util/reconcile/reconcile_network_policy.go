@@ -0,0 +1,41 @@
+package reconcile
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NetworkPolicy reconciles a provided network policy with the cluster.
+func NetworkPolicy(reqLogger logr.Logger, c client.Client, policy *networkingv1.NetworkPolicy) error {
+	if err := k8sutil.SetCreationSpecAnnotation(&policy.ObjectMeta, policy); err != nil {
+		return err
+	}
+	found := &networkingv1.NetworkPolicy{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace}, found); err != nil {
+		// Return API error
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		// Create the network policy
+		reqLogger.Info("Creating new NetworkPolicy", "NetworkPolicy.Name", policy.Name, "NetworkPolicy.Namespace", policy.Namespace)
+		return c.Create(context.TODO(), policy)
+	}
+
+	// Check the found network policy spec
+	if !k8sutil.CreationSpecsEqual(policy.ObjectMeta, found.ObjectMeta) {
+		// We need to update the network policy
+		reqLogger.Info("NetworkPolicy annotation spec has changed, updating", "NetworkPolicy.Name", policy.Name, "NetworkPolicy.Namespace", policy.Namespace)
+		found.Spec = policy.Spec
+		found.SetAnnotations(policy.GetAnnotations())
+		found.SetLabels(policy.GetLabels())
+		return c.Update(context.TODO(), found)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newFakeNetworkPolicy() *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-policy",
+			Namespace: "fake-namespace",
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		},
+	}
+}
+
+func TestReconcileNetworkPolicy(t *testing.T) {
+	c := getFakeClient(t)
+	if err := NetworkPolicy(testLogger, c, newFakeNetworkPolicy()); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+	// should be idempotent
+	if err := NetworkPolicy(testLogger, c, newFakeNetworkPolicy()); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+
+	// a changed spec should be updated in place
+	policy := newFakeNetworkPolicy()
+	policy.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	if err := NetworkPolicy(testLogger, c, policy); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+	found := &networkingv1.NetworkPolicy{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Spec.PolicyTypes) != 1 || found.Spec.PolicyTypes[0] != networkingv1.PolicyTypeIngress {
+		t.Error("Expected network policy to be updated, got:", found.Spec.PolicyTypes)
+	}
+}
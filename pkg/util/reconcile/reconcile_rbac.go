@@ -24,11 +24,25 @@ func ServiceAccount(reqLogger logr.Logger, c client.Client, acct *corev1.Service
 		}
 		// Create the service account
 		reqLogger.Info("Creating new service account", "ServiceAccount.Name", acct.Name, "ServiceAccount.Namespace", acct.Namespace)
-		if err := c.Create(context.TODO(), acct); err != nil {
-			return err
+		return c.Create(context.TODO(), acct)
+	}
+	// Add any missing annotations, e.g. ones binding a cloud IAM role. Others are
+	// left alone since they may be managed by the cluster.
+	var changed bool
+	for key, val := range acct.GetAnnotations() {
+		if found.Annotations[key] != val {
+			if found.Annotations == nil {
+				found.Annotations = make(map[string]string)
+			}
+			found.Annotations[key] = val
+			changed = true
 		}
 	}
-	return nil
+	if !changed {
+		return nil
+	}
+	reqLogger.Info("Updating service account annotations", "ServiceAccount.Name", acct.Name, "ServiceAccount.Namespace", acct.Namespace)
+	return c.Update(context.TODO(), found)
 }
 
 // ClusterRole will ensure a ClusterRole with the cluster.
@@ -1,12 +1,14 @@
 package reconcile
 
 import (
+	"context"
 	"testing"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func newFakeSvcAccount() *corev1.ServiceAccount {
@@ -26,6 +28,20 @@ func TestReconcileSvcAccount(t *testing.T) {
 	if err := ServiceAccount(testLogger, c, newFakeSvcAccount()); err != nil {
 		t.Error("Expected no error, got:", err)
 	}
+
+	// annotations should be added to existing accounts
+	acct := newFakeSvcAccount()
+	acct.Annotations = map[string]string{"eks.amazonaws.com/role-arn": "test-role"}
+	if err := ServiceAccount(testLogger, c, acct); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+	found := &corev1.ServiceAccount{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: acct.Name, Namespace: acct.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Annotations["eks.amazonaws.com/role-arn"] != "test-role" {
+		t.Error("Expected annotation to be added to service account, got:", found.Annotations)
+	}
 }
 
 func newFakeClusterRole() *rbacv1.ClusterRole {
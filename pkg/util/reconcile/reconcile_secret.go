@@ -0,0 +1,45 @@
+package reconcile
+
+import (
+	"context"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/k8sutil"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Secret reconciles a provided secret with the cluster.
+func Secret(reqLogger logr.Logger, c client.Client, secret *corev1.Secret) error {
+	if err := k8sutil.SetCreationSpecAnnotation(&secret.ObjectMeta, secret); err != nil {
+		return err
+	}
+	found := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found); err != nil {
+		// Return API error
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		// Create the secret
+		reqLogger.Info("Creating new Secret", "Secret.Name", secret.Name, "Secret.Namespace", secret.Namespace)
+		if err := c.Create(context.TODO(), secret); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// Check the found secret spec
+	if !k8sutil.CreationSpecsEqual(secret.ObjectMeta, found.ObjectMeta) {
+		// We need to update the secret
+		reqLogger.Info("Secret annotation spec has changed, updating", "Secret.Name", secret.Name, "Secret.Namespace", secret.Namespace)
+		found.Data = secret.Data
+		found.SetAnnotations(secret.GetAnnotations())
+		if err := c.Update(context.TODO(), found); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
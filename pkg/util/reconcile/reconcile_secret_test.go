@@ -0,0 +1,43 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newFakeSecret(data string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake-secret",
+			Namespace: "fake-namespace",
+		},
+		Data: map[string][]byte{"data": []byte(data)},
+	}
+}
+
+func TestReconcileSecret(t *testing.T) {
+	c := getFakeClient(t)
+	if err := Secret(testLogger, c, newFakeSecret("test")); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+	// should be idempotent
+	if err := Secret(testLogger, c, newFakeSecret("test")); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+
+	// changing the data should trigger an update
+	if err := Secret(testLogger, c, newFakeSecret("updated")); err != nil {
+		t.Error("Expected no error, got:", err)
+	}
+	found := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "fake-secret", Namespace: "fake-namespace"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if string(found.Data["data"]) != "updated" {
+		t.Error("Expected secret data to be updated, got:", string(found.Data["data"]))
+	}
+}
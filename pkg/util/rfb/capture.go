@@ -0,0 +1,167 @@
+package rfb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+)
+
+// Server-to-client message types
+const (
+	msgFramebufferUpdate   byte = 0
+	msgSetColourMapEntries byte = 1
+	msgBell                byte = 2
+	msgServerCutText       byte = 3
+)
+
+// The number of bytes per pixel in the format requested during capture
+const bytesPerPixel = 4
+
+// captureClient is a minimal RFB client used for retrieving a single frame from
+// a server.
+type captureClient struct {
+	conn          io.Writer
+	rdr           *bufio.Reader
+	width, height int
+}
+
+// Capture performs a handshake as a shared client with the RFB server on the
+// given connection, requests a full framebuffer update, and returns the contents
+// of the framebuffer as an image. Only the None security type is supported. The
+// caller is responsible for closing the connection.
+func Capture(conn io.ReadWriter) (*image.RGBA, error) {
+	c := &captureClient{conn: conn, rdr: bufio.NewReader(conn)}
+	if err := c.handshake(); err != nil {
+		return nil, err
+	}
+	if err := c.requestFrame(); err != nil {
+		return nil, err
+	}
+	return c.readFrame()
+}
+
+func (c *captureClient) handshake() error {
+	serverInit, err := clientHandshake(c.conn, c.rdr, true)
+	if err != nil {
+		return err
+	}
+	c.width = int(binary.BigEndian.Uint16(serverInit[0:2]))
+	c.height = int(binary.BigEndian.Uint16(serverInit[2:4]))
+	return nil
+}
+
+func (c *captureClient) requestFrame() error {
+	msg := make([]byte, 0)
+
+	// SetPixelFormat - 32bpp little-endian true color with 8 bits per channel
+	msg = append(msg, msgSetPixelFormat, 0, 0, 0)
+	msg = append(msg,
+		32, 24, 0, 1, // bpp, depth, big-endian, true-color
+		0, 255, 0, 255, 0, 255, // red, green, blue max
+		16, 8, 0, // red, green, blue shift
+		0, 0, 0, // padding
+	)
+
+	// SetEncodings - raw only
+	msg = append(msg, msgSetEncodings, 0, 0, 1, 0, 0, 0, 0)
+
+	// FramebufferUpdateRequest - non-incremental for the entire screen
+	msg = append(msg, msgFramebufferUpdateRequest, 0, 0, 0, 0, 0)
+	msg = append(msg, byte(c.width>>8), byte(c.width), byte(c.height>>8), byte(c.height))
+
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+func (c *captureClient) readFrame() (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, c.width, c.height))
+	for {
+		msgType, err := c.rdr.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch msgType {
+
+		case msgFramebufferUpdate:
+			hdr := make([]byte, 3)
+			if _, err := io.ReadFull(c.rdr, hdr); err != nil {
+				return nil, err
+			}
+			for i := 0; i < int(binary.BigEndian.Uint16(hdr[1:3])); i++ {
+				if err := c.readRect(img); err != nil {
+					return nil, err
+				}
+			}
+			return img, nil
+
+		case msgSetColourMapEntries:
+			hdr := make([]byte, 5)
+			if _, err := io.ReadFull(c.rdr, hdr); err != nil {
+				return nil, err
+			}
+			if err := c.discard(6 * int64(binary.BigEndian.Uint16(hdr[3:5]))); err != nil {
+				return nil, err
+			}
+
+		case msgBell:
+
+		case msgServerCutText:
+			if err := c.discard(3); err != nil {
+				return nil, err
+			}
+			length, err := c.readUint32()
+			if err != nil {
+				return nil, err
+			}
+			if err := c.discard(int64(length)); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, fmt.Errorf("Unsupported server message type: %d", msgType)
+		}
+	}
+}
+
+func (c *captureClient) readRect(img *image.RGBA) error {
+	hdr := make([]byte, 12)
+	if _, err := io.ReadFull(c.rdr, hdr); err != nil {
+		return err
+	}
+	x := int(binary.BigEndian.Uint16(hdr[0:2]))
+	y := int(binary.BigEndian.Uint16(hdr[2:4]))
+	w := int(binary.BigEndian.Uint16(hdr[4:6]))
+	h := int(binary.BigEndian.Uint16(hdr[6:8]))
+	if encoding := int32(binary.BigEndian.Uint32(hdr[8:12])); encoding != encodingRaw {
+		return fmt.Errorf("Unsupported rectangle encoding: %d", encoding)
+	}
+	row := make([]byte, w*bytesPerPixel)
+	for j := 0; j < h; j++ {
+		if _, err := io.ReadFull(c.rdr, row); err != nil {
+			return err
+		}
+		if y+j >= c.height {
+			continue
+		}
+		for i := 0; i < w && x+i < c.width; i++ {
+			px := row[i*bytesPerPixel:]
+			offset := img.PixOffset(x+i, y+j)
+			// pixels are little-endian with red at shift 16, so they arrive as BGRX
+			img.Pix[offset] = px[2]
+			img.Pix[offset+1] = px[1]
+			img.Pix[offset+2] = px[0]
+			img.Pix[offset+3] = 255
+		}
+	}
+	return nil
+}
+
+func (c *captureClient) readUint32() (uint32, error) { return readUint32(c.rdr) }
+
+func (c *captureClient) discard(n int64) error {
+	_, err := io.CopyN(ioutil.Discard, c.rdr, n)
+	return err
+}
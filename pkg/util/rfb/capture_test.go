@@ -0,0 +1,77 @@
+package rfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// fakeServer plays the server side of an RFB 3.8 session with a 2x1 framebuffer
+// and sends a single raw framebuffer update.
+func fakeServer(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	if _, err := conn.Write([]byte("RFB 003.008\n")); err != nil {
+		t.Error(err)
+		return
+	}
+	// read the client version
+	if _, err := io.ReadFull(conn, make([]byte, 12)); err != nil {
+		t.Error(err)
+		return
+	}
+	// offer the None security type and read the selection
+	conn.Write([]byte{1, securityNone})
+	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+		t.Error(err)
+		return
+	}
+	// security result and ClientInit
+	conn.Write([]byte{0, 0, 0, 0})
+	clientInit := make([]byte, 1)
+	if _, err := io.ReadFull(conn, clientInit); err != nil {
+		t.Error(err)
+		return
+	}
+	if clientInit[0] != 1 {
+		t.Error("Expected client to request a shared session")
+	}
+	// ServerInit
+	serverInit := make([]byte, 24)
+	binary.BigEndian.PutUint16(serverInit[0:2], 2)
+	binary.BigEndian.PutUint16(serverInit[2:4], 1)
+	binary.BigEndian.PutUint32(serverInit[20:24], 4)
+	conn.Write(append(serverInit, []byte("test")...))
+	// SetPixelFormat, SetEncodings, and FramebufferUpdateRequest
+	if _, err := io.ReadFull(conn, make([]byte, 20+8+10)); err != nil {
+		t.Error(err)
+		return
+	}
+	// A bell followed by the update
+	var update bytes.Buffer
+	update.Write([]byte{msgBell, msgFramebufferUpdate, 0, 0, 1})
+	update.Write([]byte{0, 0, 0, 0, 0, 2, 0, 1, 0, 0, 0, 0})
+	update.Write([]byte{0x10, 0x20, 0x30, 0, 0x40, 0x50, 0x60, 0})
+	conn.Write(update.Bytes())
+}
+
+func TestCapture(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go fakeServer(t, server)
+
+	img, err := Capture(client)
+	if err != nil {
+		t.Fatal("Expected no error, got:", err)
+	}
+	if img.Bounds().Dx() != 2 || img.Bounds().Dy() != 1 {
+		t.Fatal("Unexpected image bounds:", img.Bounds())
+	}
+	if r, g, b, _ := img.At(0, 0).RGBA(); r>>8 != 0x30 || g>>8 != 0x20 || b>>8 != 0x10 {
+		t.Errorf("Unexpected first pixel: %d %d %d", r>>8, g>>8, b>>8)
+	}
+	if r, g, b, _ := img.At(1, 0).RGBA(); r>>8 != 0x60 || g>>8 != 0x50 || b>>8 != 0x40 {
+		t.Errorf("Unexpected second pixel: %d %d %d", r>>8, g>>8, b>>8)
+	}
+}
@@ -0,0 +1,436 @@
+package rfb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Client-to-server message types
+const (
+	msgSetPixelFormat           byte = 0
+	msgSetEncodings             byte = 2
+	msgFramebufferUpdateRequest byte = 3
+	msgKeyEvent                 byte = 4
+	msgPointerEvent             byte = 5
+	msgClientCutText            byte = 6
+	msgEnableContinuousUpdates  byte = 150
+	msgClientFence              byte = 248
+	msgXvp                      byte = 250
+	msgSetDesktopSize           byte = 251
+	msgQEMU                     byte = 255
+)
+
+// kvdi client message types. These are handled by the proxy and never reach the
+// server.
+const (
+	// msgTextInput carries UTF-8 text composed on the client, e.g. with an input
+	// method, to be typed into the server.
+	msgTextInput byte = 200
+)
+
+// QEMU client message sub-types
+const (
+	qemuExtendedKeyEvent byte = 0
+)
+
+// Security types
+const (
+	securityNone    byte = 1
+	securityVNCAuth byte = 2
+)
+
+// clientStream forwards RFB client messages from a source to a destination,
+// optionally dropping input events or unsupported encodings along the way.
+type clientStream struct {
+	dst     io.Writer
+	src     *bufio.Reader
+	written int64
+
+	// dropInput signals that key, pointer, clipboard, and resize events should be
+	// dropped.
+	dropInput bool
+	// dropClipboard signals that clipboard updates should be dropped.
+	dropClipboard bool
+	// textInput signals that the client may send text input messages, which are
+	// typed into the server as key events.
+	textInput bool
+	// encodings, when not nil, restricts the encodings the client may request to
+	// those in the set.
+	encodings map[int32]struct{}
+	// onSetPixelFormat, when not nil, is called with the pixel format whenever the
+	// client changes it.
+	onSetPixelFormat func(pixelFormat)
+
+	// throttle, when not nil, limits the quality and frame rate requested from
+	// the server.
+	throttle *Throttle
+	// sendDeferred sends messages to the server outside of the normal flow of
+	// client messages. It is called with mux held.
+	sendDeferred func([]byte)
+	// mux is held while handling each client message and while sending deferred
+	// messages, so they are never interleaved.
+	mux sync.Mutex
+	// requestedEncodings are the encodings last requested by the client, after
+	// any restrictions.
+	requestedEncodings []int32
+	// lastRequest is when the last framebuffer update request was sent.
+	lastRequest time.Time
+	// pendingRequest is an update request held back by the throttle.
+	pendingRequest []byte
+	requestTimer   *time.Timer
+}
+
+// copyMessages forwards client messages until EOF is reached on the source.
+func (c *clientStream) copyMessages() error {
+	for {
+		if err := c.nextMessage(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// handshake forwards the client side of the handshake to the destination. When
+// input is being dropped, the ClientInit message is rewritten to request a shared
+// session so that viewers do not disconnect other clients.
+//
+// Only the None and VNC Authentication security types are supported. RFB 3.3
+// clients do not announce the security type, so they are assumed to be using None.
+func (c *clientStream) handshake() error {
+	version, err := c.read(12)
+	if err != nil {
+		return err
+	}
+	if err := c.write(version); err != nil {
+		return err
+	}
+	// Versions 3.7 and later have the client select a security type
+	if string(version[8:11]) >= "007" {
+		secType, err := c.read(1)
+		if err != nil {
+			return err
+		}
+		if err := c.write(secType); err != nil {
+			return err
+		}
+		switch secType[0] {
+		case securityNone:
+		case securityVNCAuth:
+			// challenge response
+			if err := c.forward(16); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unsupported security type for filtered connection: %d", secType[0])
+		}
+	}
+	// ClientInit
+	clientInit, err := c.read(1)
+	if err != nil {
+		return err
+	}
+	if c.dropInput {
+		clientInit[0] = 1
+	}
+	return c.write(clientInit)
+}
+
+func (c *clientStream) nextMessage() error {
+	msgType, err := c.src.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	switch msgType {
+
+	case msgSetPixelFormat:
+		body, err := c.read(19)
+		if err != nil {
+			return err
+		}
+		if c.onSetPixelFormat != nil {
+			c.onSetPixelFormat(parsePixelFormat(body[3:19]))
+		}
+		return c.write(append([]byte{msgType}, body...))
+
+	case msgFramebufferUpdateRequest:
+		if c.throttle != nil {
+			return c.requestUpdate()
+		}
+		return c.forwardMessage(msgType, 9)
+
+	case msgEnableContinuousUpdates:
+		// throttled clients are never offered continuous updates
+		if c.throttle != nil {
+			return c.discard(9)
+		}
+		return c.forwardMessage(msgType, 9)
+
+	case msgSetEncodings:
+		hdr, err := c.read(3)
+		if err != nil {
+			return err
+		}
+		if c.encodings == nil && c.throttle == nil {
+			if err := c.write(append([]byte{msgType}, hdr...)); err != nil {
+				return err
+			}
+			return c.forward(4 * int64(binary.BigEndian.Uint16(hdr[1:3])))
+		}
+		return c.filterEncodings(binary.BigEndian.Uint16(hdr[1:3]))
+
+	case msgClientFence:
+		hdr, err := c.read(8)
+		if err != nil {
+			return err
+		}
+		if err := c.write(append([]byte{msgType}, hdr...)); err != nil {
+			return err
+		}
+		return c.forward(int64(hdr[7]))
+
+	case msgKeyEvent:
+		return c.dropOrForward(msgType, 7)
+
+	case msgPointerEvent:
+		return c.dropOrForward(msgType, 5)
+
+	case msgXvp:
+		return c.dropOrForward(msgType, 3)
+
+	case msgClientCutText:
+		hdr, err := c.read(7)
+		if err != nil {
+			return err
+		}
+		// A negative length signals an extended clipboard message
+		length := int64(int32(binary.BigEndian.Uint32(hdr[3:7])))
+		if length < 0 {
+			length = -length
+		}
+		if c.dropClipboard {
+			return c.discard(length)
+		}
+		return c.dropOrForwardWithHeader(append([]byte{msgType}, hdr...), length)
+
+	case msgTextInput:
+		if !c.textInput {
+			return fmt.Errorf("Unsupported client message type: %d", msgType)
+		}
+		hdr, err := c.read(7)
+		if err != nil {
+			return err
+		}
+		length := int64(binary.BigEndian.Uint32(hdr[3:7]))
+		if length > maxTextInputLength {
+			return fmt.Errorf("Text input of %d bytes exceeds the maximum of %d", length, maxTextInputLength)
+		}
+		if c.dropInput {
+			return c.discard(length)
+		}
+		text, err := c.read(int(length))
+		if err != nil {
+			return err
+		}
+		return c.write(textInputKeyEvents(string(text)))
+
+	case msgSetDesktopSize:
+		hdr, err := c.read(7)
+		if err != nil {
+			return err
+		}
+		return c.dropOrForwardWithHeader(append([]byte{msgType}, hdr...), 16*int64(hdr[5]))
+
+	case msgQEMU:
+		subType, err := c.src.ReadByte()
+		if err != nil {
+			return err
+		}
+		if subType == qemuExtendedKeyEvent {
+			return c.dropOrForwardWithHeader([]byte{msgType, subType}, 10)
+		}
+		return fmt.Errorf("Unsupported QEMU client message type: %d", subType)
+
+	default:
+		return fmt.Errorf("Unsupported client message type: %d", msgType)
+	}
+}
+
+// filterEncodings reads the given number of encodings from the source and
+// forwards a SetEncodings message containing only the allowed ones.
+func (c *clientStream) filterEncodings(count uint16) error {
+	encodings, err := c.read(4 * int(count))
+	if err != nil {
+		return err
+	}
+	allowed := make([]int32, 0, count)
+	for i := 0; i < len(encodings); i += 4 {
+		enc := int32(binary.BigEndian.Uint32(encodings[i : i+4]))
+		if c.encodings == nil {
+			allowed = append(allowed, enc)
+		} else if _, ok := c.encodings[enc]; ok {
+			allowed = append(allowed, enc)
+		}
+	}
+	c.requestedEncodings = allowed
+	return c.write(c.encodingsMessage())
+}
+
+// encodingsMessage returns a SetEncodings message for the encodings last
+// requested by the client, adjusted by the throttle if there is one.
+func (c *clientStream) encodingsMessage() []byte {
+	encodings := c.requestedEncodings
+	if c.throttle != nil {
+		encodings = c.throttle.adjustEncodings(encodings)
+	}
+	msg := make([]byte, 4, 4+4*len(encodings))
+	msg[0] = msgSetEncodings
+	binary.BigEndian.PutUint16(msg[2:4], uint16(len(encodings)))
+	for _, enc := range encodings {
+		msg = append(msg, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(msg[len(msg)-4:], uint32(enc))
+	}
+	return msg
+}
+
+// watchThrottle sends the encodings last requested by the client to the server
+// again whenever the quality limit of the throttle changes. The returned function
+// stops watching the throttle and sending any held back update request.
+func (c *clientStream) watchThrottle() func() {
+	if c.throttle == nil {
+		return func() {}
+	}
+	unsubscribe := c.throttle.onQualityChange(func() {
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		if c.requestedEncodings != nil {
+			c.sendDeferred(c.encodingsMessage())
+		}
+	})
+	return func() {
+		unsubscribe()
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		if c.requestTimer != nil {
+			c.requestTimer.Stop()
+			c.requestTimer = nil
+		}
+		c.pendingRequest = nil
+	}
+}
+
+// requestUpdate reads a framebuffer update request and forwards it, unless it is
+// incremental and the last request was sent too recently for the frame rate
+// allowed by the throttle. The latest of those is held back and sent once enough
+// time has passed.
+func (c *clientStream) requestUpdate() error {
+	body, err := c.read(9)
+	if err != nil {
+		return err
+	}
+	msg := append([]byte{msgFramebufferUpdateRequest}, body...)
+	interval := c.throttle.frameInterval()
+	wait := time.Until(c.lastRequest.Add(interval))
+	incremental := body[0] != 0
+	if interval == 0 || !incremental || wait <= 0 {
+		c.pendingRequest = nil
+		c.lastRequest = time.Now()
+		return c.write(msg)
+	}
+	c.pendingRequest = msg
+	if c.requestTimer == nil {
+		c.requestTimer = time.AfterFunc(wait, c.sendPendingRequest)
+	}
+	return nil
+}
+
+// sendPendingRequest sends the update request held back by the throttle, if it
+// was not replaced by a newer request in the meantime.
+func (c *clientStream) sendPendingRequest() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.requestTimer = nil
+	if c.pendingRequest == nil {
+		return
+	}
+	c.lastRequest = time.Now()
+	c.sendDeferred(c.pendingRequest)
+	c.pendingRequest = nil
+}
+
+// dropOrForward discards the remaining n bytes of an input message if input is
+// being dropped, otherwise the message is forwarded.
+func (c *clientStream) dropOrForward(msgType byte, n int64) error {
+	if c.dropInput {
+		return c.discard(n)
+	}
+	return c.forwardMessage(msgType, n)
+}
+
+// dropOrForwardWithHeader is like dropOrForward, except for messages where a
+// header has already been read from the source.
+func (c *clientStream) dropOrForwardWithHeader(hdr []byte, n int64) error {
+	if c.dropInput {
+		return c.discard(n)
+	}
+	if err := c.write(hdr); err != nil {
+		return err
+	}
+	return c.forward(n)
+}
+
+// read reads exactly n bytes from the source.
+func (c *clientStream) read(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c.src, buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return buf, nil
+}
+
+// write writes the given bytes to the destination.
+func (c *clientStream) write(buf []byte) error {
+	n, err := c.dst.Write(buf)
+	c.written += int64(n)
+	return err
+}
+
+// forward copies exactly n bytes from the source to the destination.
+func (c *clientStream) forward(n int64) error {
+	written, err := io.CopyN(c.dst, c.src, n)
+	c.written += written
+	return unexpectedEOF(err)
+}
+
+// forwardMessage writes the message type to the destination followed by the
+// remaining n bytes of the message.
+func (c *clientStream) forwardMessage(msgType byte, n int64) error {
+	if err := c.write([]byte{msgType}); err != nil {
+		return err
+	}
+	return c.forward(n)
+}
+
+// discard reads and drops exactly n bytes from the source.
+func (c *clientStream) discard(n int64) error {
+	_, err := io.CopyN(ioutil.Discard, c.src, n)
+	return unexpectedEOF(err)
+}
+
+// unexpectedEOF converts an EOF in the middle of a message to an ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package rfb
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func copyClientStream(c *clientStream) (int64, error) {
+	if err := c.handshake(); err != nil {
+		return c.written, err
+	}
+	return c.written, c.copyMessages()
+}
+
+func copyViewOnly(dst io.Writer, src io.Reader) (int64, error) {
+	return copyClientStream(&clientStream{dst: dst, src: bufio.NewReader(src), dropInput: true})
+}
+
+func TestClientStreamViewOnly(t *testing.T) {
+	var client bytes.Buffer
+	// Handshake with VNC authentication and an exclusive ClientInit
+	client.WriteString("RFB 003.008\n")
+	client.WriteByte(securityVNCAuth)
+	client.Write(bytes.Repeat([]byte{0xAA}, 16))
+	client.WriteByte(0)
+
+	// SetEncodings with two encodings
+	setEncodings := []byte{msgSetEncodings, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 7}
+	client.Write(setEncodings)
+	// KeyEvent
+	client.Write([]byte{msgKeyEvent, 1, 0, 0, 0, 0, 0, 0x61})
+	// PointerEvent
+	client.Write([]byte{msgPointerEvent, 1, 0, 10, 0, 10})
+	// ClientCutText
+	client.Write([]byte{msgClientCutText, 0, 0, 0, 0, 0, 0, 5})
+	client.WriteString("hello")
+	// FramebufferUpdateRequest
+	updateRequest := []byte{msgFramebufferUpdateRequest, 1, 0, 0, 0, 0, 4, 0, 3, 0}
+	client.Write(updateRequest)
+
+	var expected bytes.Buffer
+	expected.WriteString("RFB 003.008\n")
+	expected.WriteByte(securityVNCAuth)
+	expected.Write(bytes.Repeat([]byte{0xAA}, 16))
+	expected.WriteByte(1)
+	expected.Write(setEncodings)
+	expected.Write(updateRequest)
+
+	var server bytes.Buffer
+	written, err := copyViewOnly(&server, &client)
+	if err != nil {
+		t.Fatal("Expected no error, got:", err)
+	}
+	if written != int64(expected.Len()) {
+		t.Errorf("Expected %d bytes written, got %d", expected.Len(), written)
+	}
+	if !bytes.Equal(server.Bytes(), expected.Bytes()) {
+		t.Errorf("Unexpected server stream, got %v", server.Bytes())
+	}
+}
+
+func TestClientStreamErrors(t *testing.T) {
+	// Truncated message
+	client := bytes.NewBuffer([]byte("RFB 003.008\n"))
+	client.Write([]byte{securityNone, 1, msgKeyEvent, 1})
+	if _, err := copyViewOnly(&bytes.Buffer{}, client); err != io.ErrUnexpectedEOF {
+		t.Error("Expected unexpected EOF, got:", err)
+	}
+
+	// Unknown message type
+	client = bytes.NewBuffer([]byte("RFB 003.008\n"))
+	client.Write([]byte{securityNone, 1, 100})
+	if _, err := copyViewOnly(&bytes.Buffer{}, client); err == nil {
+		t.Error("Expected error for unknown message type")
+	}
+
+	// Unsupported security type
+	client = bytes.NewBuffer([]byte("RFB 003.008\n"))
+	client.Write([]byte{19})
+	if _, err := copyViewOnly(&bytes.Buffer{}, client); err == nil {
+		t.Error("Expected error for unsupported security type")
+	}
+}
+
+func TestClientStreamDropClipboard(t *testing.T) {
+	var client bytes.Buffer
+	client.WriteString("RFB 003.008\n")
+	client.WriteByte(securityNone)
+	client.WriteByte(0)
+	keyEvent := []byte{msgKeyEvent, 1, 0, 0, 0, 0, 0, 0x61}
+	client.Write(keyEvent)
+	client.Write([]byte{msgClientCutText, 0, 0, 0, 0, 0, 0, 5})
+	client.WriteString("hello")
+
+	var expected bytes.Buffer
+	expected.WriteString("RFB 003.008\n")
+	expected.WriteByte(securityNone)
+	expected.WriteByte(0)
+	expected.Write(keyEvent)
+
+	var server bytes.Buffer
+	if _, err := copyClientStream(&clientStream{dst: &server, src: bufio.NewReader(&client), dropClipboard: true}); err != nil {
+		t.Fatal("Expected no error, got:", err)
+	}
+	if !bytes.Equal(server.Bytes(), expected.Bytes()) {
+		t.Errorf("Unexpected server stream, got %v", server.Bytes())
+	}
+}
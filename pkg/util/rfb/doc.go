@@ -0,0 +1,2 @@
+// Package rfb contains utilities for working with RFB (VNC) protocol streams.
+package rfb
@@ -0,0 +1,69 @@
+package rfb
+
+import "unicode"
+
+// glyphWidth and glyphHeight are the dimensions of each glyph in the watermark
+// font.
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+// font is a minimal 5x7 bitmap font used for rendering watermarks. Each glyph
+// is a list of rows, with the most significant of the five low bits being the
+// leftmost pixel. Lowercase characters are rendered as uppercase, and any
+// character not in the font is rendered as a question mark.
+var font = map[rune][glyphHeight]byte{
+	' ': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'_': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F},
+	'@': {0x0E, 0x11, 0x01, 0x0D, 0x15, 0x15, 0x0E},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'?': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'A': {0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+}
+
+// glyph returns the bitmap for the given character.
+func glyph(r rune) [glyphHeight]byte {
+	if g, ok := font[unicode.ToUpper(r)]; ok {
+		return g
+	}
+	return font['?']
+}
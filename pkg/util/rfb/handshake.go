@@ -0,0 +1,162 @@
+package rfb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The highest minor protocol version supported when negotiating with clients
+// and servers
+const maxSupportedMinorVersion = "008"
+
+// clientHandshake performs the client side of the RFB handshake with a server
+// using the None security type. The raw ServerInit message, including the
+// desktop name, is returned.
+func clientHandshake(w io.Writer, rdr *bufio.Reader, shared bool) ([]byte, error) {
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(rdr, version); err != nil {
+		return nil, err
+	}
+	minor := string(version[8:11])
+	if minor > maxSupportedMinorVersion {
+		minor = maxSupportedMinorVersion
+	}
+	if _, err := w.Write([]byte(fmt.Sprintf("RFB 003.%s\n", minor))); err != nil {
+		return nil, err
+	}
+
+	if minor >= "007" {
+		count, err := rdr.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, readFailureReason(rdr)
+		}
+		secTypes := make([]byte, count)
+		if _, err := io.ReadFull(rdr, secTypes); err != nil {
+			return nil, err
+		}
+		var supported bool
+		for _, secType := range secTypes {
+			if secType == securityNone {
+				supported = true
+			}
+		}
+		if !supported {
+			return nil, fmt.Errorf("RFB server does not support the None security type, offered: %v", secTypes)
+		}
+		if _, err := w.Write([]byte{securityNone}); err != nil {
+			return nil, err
+		}
+		// Version 3.7 does not send a security result for the None type
+		if minor >= "008" {
+			result, err := readUint32(rdr)
+			if err != nil {
+				return nil, err
+			}
+			if result != 0 {
+				return nil, readFailureReason(rdr)
+			}
+		}
+	} else {
+		secType, err := readUint32(rdr)
+		if err != nil {
+			return nil, err
+		}
+		switch secType {
+		case 0:
+			return nil, readFailureReason(rdr)
+		case uint32(securityNone):
+		default:
+			return nil, fmt.Errorf("RFB server requires unsupported security type: %d", secType)
+		}
+	}
+
+	// ClientInit
+	var sharedFlag byte
+	if shared {
+		sharedFlag = 1
+	}
+	if _, err := w.Write([]byte{sharedFlag}); err != nil {
+		return nil, err
+	}
+
+	// ServerInit
+	serverInit := make([]byte, 24)
+	if _, err := io.ReadFull(rdr, serverInit); err != nil {
+		return nil, err
+	}
+	name := make([]byte, binary.BigEndian.Uint32(serverInit[20:24]))
+	if _, err := io.ReadFull(rdr, name); err != nil {
+		return nil, err
+	}
+	return append(serverInit, name...), nil
+}
+
+// serverHandshake performs the server side of the RFB handshake with a client,
+// offering only the None security type. The value of the client's shared flag
+// is returned. The caller is responsible for sending the ServerInit message.
+func serverHandshake(w io.Writer, rdr *bufio.Reader) (shared bool, err error) {
+	if _, err := w.Write([]byte(fmt.Sprintf("RFB 003.%s\n", maxSupportedMinorVersion))); err != nil {
+		return false, err
+	}
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(rdr, version); err != nil {
+		return false, err
+	}
+	minor := string(version[8:11])
+
+	if minor >= "007" {
+		if _, err := w.Write([]byte{1, securityNone}); err != nil {
+			return false, err
+		}
+		secType, err := rdr.ReadByte()
+		if err != nil {
+			return false, err
+		}
+		if secType != securityNone {
+			return false, fmt.Errorf("Client selected unsupported security type: %d", secType)
+		}
+		if minor >= "008" {
+			if _, err := w.Write([]byte{0, 0, 0, 0}); err != nil {
+				return false, err
+			}
+		}
+	} else {
+		if _, err := w.Write([]byte{0, 0, 0, securityNone}); err != nil {
+			return false, err
+		}
+	}
+
+	// ClientInit
+	sharedFlag, err := rdr.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	return sharedFlag != 0, nil
+}
+
+// readFailureReason reads a failure reason string from the server and returns
+// it as an error.
+func readFailureReason(rdr *bufio.Reader) error {
+	length, err := readUint32(rdr)
+	if err != nil {
+		return err
+	}
+	reason := make([]byte, length)
+	if _, err := io.ReadFull(rdr, reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("RFB server refused connection: %s", string(reason))
+}
+
+func readUint32(rdr *bufio.Reader) (uint32, error) {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(rdr, buf); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf), nil
+}
@@ -0,0 +1,80 @@
+package rfb
+
+import "encoding/binary"
+
+// pixelFormat represents the PIXEL_FORMAT structure used to describe how pixel
+// values are encoded in framebuffer updates.
+type pixelFormat struct {
+	bitsPerPixel, depth             uint8
+	bigEndian, trueColour           bool
+	redMax, greenMax, blueMax       uint16
+	redShift, greenShift, blueShift uint8
+}
+
+// parsePixelFormat parses a 16-byte PIXEL_FORMAT structure.
+func parsePixelFormat(b []byte) pixelFormat {
+	return pixelFormat{
+		bitsPerPixel: b[0],
+		depth:        b[1],
+		bigEndian:    b[2] != 0,
+		trueColour:   b[3] != 0,
+		redMax:       binary.BigEndian.Uint16(b[4:6]),
+		greenMax:     binary.BigEndian.Uint16(b[6:8]),
+		blueMax:      binary.BigEndian.Uint16(b[8:10]),
+		redShift:     b[10],
+		greenShift:   b[11],
+		blueShift:    b[12],
+	}
+}
+
+// bytes encodes the pixel format as a 16-byte PIXEL_FORMAT structure.
+func (p pixelFormat) bytes() []byte {
+	b := make([]byte, 16)
+	b[0], b[1] = p.bitsPerPixel, p.depth
+	if p.bigEndian {
+		b[2] = 1
+	}
+	if p.trueColour {
+		b[3] = 1
+	}
+	binary.BigEndian.PutUint16(b[4:6], p.redMax)
+	binary.BigEndian.PutUint16(b[6:8], p.greenMax)
+	binary.BigEndian.PutUint16(b[8:10], p.blueMax)
+	b[10], b[11], b[12] = p.redShift, p.greenShift, p.blueShift
+	return b
+}
+
+// size returns the number of bytes used by each pixel.
+func (p pixelFormat) size() int { return int(p.bitsPerPixel) / 8 }
+
+// byteOrder returns the byte order pixel values are encoded in.
+func (p pixelFormat) byteOrder() binary.ByteOrder {
+	if p.bigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// readPixel reads a single pixel value from the start of b.
+func (p pixelFormat) readPixel(b []byte) uint32 {
+	switch p.size() {
+	case 1:
+		return uint32(b[0])
+	case 2:
+		return uint32(p.byteOrder().Uint16(b))
+	default:
+		return p.byteOrder().Uint32(b)
+	}
+}
+
+// writePixel writes a single pixel value to the start of b.
+func (p pixelFormat) writePixel(b []byte, v uint32) {
+	switch p.size() {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		p.byteOrder().PutUint16(b, uint16(v))
+	default:
+		p.byteOrder().PutUint32(b, v)
+	}
+}
@@ -0,0 +1,150 @@
+package rfb
+
+import (
+	"bufio"
+	"io"
+)
+
+// Encodings and pseudo-encodings understood when inspecting server messages
+const (
+	encodingRaw                 int32 = 0
+	encodingCopyRect            int32 = 1
+	encodingZRLE                int32 = 16
+	encodingCursor              int32 = -239
+	encodingDesktopSize         int32 = -223
+	encodingLastRect            int32 = -224
+	encodingQEMUExtendedKey     int32 = -258
+	encodingExtendedDesktopSize int32 = -308
+)
+
+// watermarkEncodings are the encodings clients may request on a watermarked
+// connection. Raw is the only encoding allowed for pixel data since the watermark
+// is blended directly into each rectangle. CopyRect and the compressed encodings
+// would either move the watermark around the screen or hide it from us.
+var watermarkEncodings = map[int32]struct{}{
+	encodingRaw:                 {},
+	encodingCursor:              {},
+	encodingDesktopSize:         {},
+	encodingLastRect:            {},
+	encodingQEMUExtendedKey:     {},
+	encodingExtendedDesktopSize: {},
+}
+
+// inspectedEncodings are the encodings clients may request when server messages
+// need to be inspected, but not modified. These are limited to encodings where
+// the length of each rectangle can be determined without decoding it.
+var inspectedEncodings = map[int32]struct{}{
+	encodingRaw:                 {},
+	encodingCopyRect:            {},
+	encodingZRLE:                {},
+	encodingCursor:              {},
+	encodingDesktopSize:         {},
+	encodingLastRect:            {},
+	encodingQEMUExtendedKey:     {},
+	encodingExtendedDesktopSize: {},
+}
+
+// ProxyOpts are options for filtering an RFB session.
+type ProxyOpts struct {
+	// Drop all input events sent by the client.
+	ViewOnly bool
+	// A watermark to overlay on the display.
+	Watermark *Watermark
+	// Drop clipboard updates sent from the client to the server.
+	DisableClipboardIn bool
+	// Drop clipboard updates sent from the server to the client.
+	DisableClipboardOut bool
+	// Accept text input messages from the client and type them into the server as
+	// key events. This lets text composed with an input method on the client reach
+	// the server, and clients must not send these messages unless it is set.
+	TextInput bool
+	// Record the data sent to the client, after any other options are applied.
+	// If writing to the recorder fails, the session is ended.
+	Recorder io.Writer
+	// Limit the quality, frame rate, and bandwidth of the display stream sent
+	// to the client.
+	Throttle *Throttle
+}
+
+// Proxy runs an RFB session between the client and server connections, applying
+// the given options. Proxy returns when either side of the session ends, and the
+// caller is responsible for closing both connections.
+//
+// When no options are set, the streams are copied as-is. When only client
+// messages need to be filtered, the handshake is passed through and server
+// messages are copied as-is. Otherwise, the handshake is completed separately
+// with each side so that server messages can be inspected from the start. This
+// means only the None security type is supported, and the encodings available to
+// the client are restricted. Throttled sessions always filter client messages.
+func Proxy(client, server io.ReadWriter, opts *ProxyOpts) error {
+	if opts == nil {
+		opts = &ProxyOpts{}
+	}
+	if opts.Recorder != nil {
+		client = newRecordedConn(client, opts.Recorder)
+	}
+
+	errs := make(chan error, 2)
+	copyStream := func(dst io.Writer, src io.Reader) {
+		_, err := io.Copy(dst, src)
+		errs <- err
+	}
+
+	if !opts.ViewOnly && !opts.TextInput && opts.Watermark == nil && !opts.DisableClipboardIn && !opts.DisableClipboardOut && opts.Throttle == nil {
+		go copyStream(server, client)
+		go copyStream(client, server)
+		return <-errs
+	}
+
+	clientRdr := bufio.NewReader(client)
+	cs := &clientStream{
+		dst:           server,
+		src:           clientRdr,
+		dropInput:     opts.ViewOnly,
+		dropClipboard: opts.DisableClipboardIn,
+		textInput:     opts.TextInput,
+		throttle:      opts.Throttle,
+	}
+	cs.sendDeferred = func(msg []byte) { _ = cs.write(msg) }
+	defer cs.watchThrottle()()
+	throttled := newRateLimitedWriter(client, opts.Throttle)
+
+	if opts.Watermark == nil && !opts.DisableClipboardOut {
+		if err := cs.handshake(); err != nil {
+			return err
+		}
+		go func() { errs <- cs.copyMessages() }()
+		go copyStream(throttled, server)
+		return <-errs
+	}
+
+	serverRdr := bufio.NewReader(server)
+	shared, err := serverHandshake(client, clientRdr)
+	if err != nil {
+		return err
+	}
+	serverInit, err := clientHandshake(server, serverRdr, shared || opts.ViewOnly)
+	if err != nil {
+		return err
+	}
+	if _, err := client.Write(serverInit); err != nil {
+		return err
+	}
+
+	ss := &serverStream{
+		dst:           throttled,
+		src:           serverRdr,
+		watermark:     opts.Watermark,
+		dropClipboard: opts.DisableClipboardOut,
+		format:        parsePixelFormat(serverInit[4:20]),
+	}
+	cs.onSetPixelFormat = ss.setPixelFormat
+	cs.encodings = inspectedEncodings
+	if opts.Watermark != nil {
+		cs.encodings = watermarkEncodings
+	}
+
+	go func() { errs <- cs.copyMessages() }()
+	go func() { errs <- ss.copyMessages() }()
+	return <-errs
+}
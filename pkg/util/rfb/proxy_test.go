@@ -0,0 +1,96 @@
+package rfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	clientConn, proxyClient := net.Pipe()
+	proxyServer, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	go func() {
+		Proxy(proxyClient, proxyServer, &ProxyOpts{
+			ViewOnly:            true,
+			Watermark:           NewWatermark("a"),
+			DisableClipboardOut: true,
+		})
+		proxyClient.Close()
+		proxyServer.Close()
+	}()
+
+	// The fake server performs its side of the handshake and then waits for the
+	// client's encodings before sending a 4x1 raw update.
+	serverEncodings := make(chan []byte, 1)
+	go func() {
+		serverConn.Write([]byte("RFB 003.008\n"))
+		io.ReadFull(serverConn, make([]byte, 12))
+		serverConn.Write([]byte{1, securityNone})
+		io.ReadFull(serverConn, make([]byte, 1))
+		serverConn.Write([]byte{0, 0, 0, 0})
+		io.ReadFull(serverConn, make([]byte, 1))
+		serverInit := make([]byte, 24)
+		binary.BigEndian.PutUint16(serverInit[0:2], 4)
+		binary.BigEndian.PutUint16(serverInit[2:4], 1)
+		serverInit[4], serverInit[5], serverInit[7] = 32, 24, 1
+		binary.BigEndian.PutUint16(serverInit[8:10], 255)
+		binary.BigEndian.PutUint16(serverInit[10:12], 255)
+		binary.BigEndian.PutUint16(serverInit[12:14], 255)
+		serverInit[14], serverInit[15], serverInit[16] = 16, 8, 0
+		serverConn.Write(serverInit)
+
+		hdr := make([]byte, 4)
+		io.ReadFull(serverConn, hdr)
+		encodings := make([]byte, 4*binary.BigEndian.Uint16(hdr[2:4]))
+		io.ReadFull(serverConn, encodings)
+		serverEncodings <- encodings
+
+		// clipboard updates should be dropped before reaching the client
+		serverConn.Write([]byte{msgServerCutText, 0, 0, 0, 0, 0, 0, 5})
+		serverConn.Write([]byte("hello"))
+
+		update := []byte{msgFramebufferUpdate, 0, 0, 1, 0, 0, 0, 0, 0, 4, 0, 1, 0, 0, 0, 0}
+		update = append(update, make([]byte, 16)...)
+		serverConn.Write(update)
+	}()
+
+	// client handshake
+	if _, err := io.ReadFull(clientConn, make([]byte, 12)); err != nil {
+		t.Fatal(err)
+	}
+	clientConn.Write([]byte("RFB 003.008\n"))
+	secTypes := make([]byte, 2)
+	io.ReadFull(clientConn, secTypes)
+	if !bytes.Equal(secTypes, []byte{1, securityNone}) {
+		t.Fatal("Expected only the None security type, got", secTypes)
+	}
+	clientConn.Write([]byte{securityNone})
+	io.ReadFull(clientConn, make([]byte, 4))
+	clientConn.Write([]byte{0})
+	if _, err := io.ReadFull(clientConn, make([]byte, 24)); err != nil {
+		t.Fatal(err)
+	}
+
+	// request raw, copyrect, and desktop size
+	clientConn.Write([]byte{msgSetEncodings, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 1, 0xFF, 0xFF, 0xFF, 0x21})
+	if encodings := <-serverEncodings; !bytes.Equal(encodings, []byte{0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0x21}) {
+		t.Error("Expected CopyRect to be filtered from encodings, got", encodings)
+	}
+
+	update := make([]byte, 16+16)
+	if _, err := io.ReadFull(clientConn, update); err != nil {
+		t.Fatal(err)
+	}
+	pixels := update[16:]
+	if !bytes.Equal(pixels[0:4], []byte{0, 0, 0, 0}) {
+		t.Error("Expected first pixel to be untouched, got", pixels[0:4])
+	}
+	if bytes.Equal(pixels[12:16], []byte{0, 0, 0, 0}) {
+		t.Error("Expected pixel under the watermark to be blended")
+	}
+}
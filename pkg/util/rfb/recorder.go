@@ -0,0 +1,61 @@
+package rfb
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// fbsHeader is the header written at the start of every recording.
+const fbsHeader = "FBS 001.000\n"
+
+// Recorder writes the data sent to an RFB client in the FBS 001.000 format used
+// by rfbproxy and vncrec, so that sessions can be replayed by compatible players.
+// Each write is stored as a block containing the length of the data, the data
+// padded to a multiple of four bytes, and the milliseconds since the recording
+// started.
+type Recorder struct {
+	w     io.Writer
+	start time.Time
+	mux   sync.Mutex
+}
+
+// NewRecorder writes the FBS header to the given writer and returns a Recorder
+// for the rest of the stream.
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	if _, err := io.WriteString(w, fbsHeader); err != nil {
+		return nil, err
+	}
+	return &Recorder{w: w, start: time.Now()}, nil
+}
+
+// Write implements io.Writer and writes the given data as a single block.
+func (r *Recorder) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	padding := (4 - len(p)%4) % 4
+	block := make([]byte, 4+len(p)+padding+4)
+	binary.BigEndian.PutUint32(block[:4], uint32(len(p)))
+	copy(block[4:], p)
+	binary.BigEndian.PutUint32(block[len(block)-4:], uint32(time.Since(r.start)/time.Millisecond))
+	if _, err := r.w.Write(block); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// recordedConn wraps a client connection so that everything written to it is
+// also written to a Recorder.
+type recordedConn struct {
+	io.Reader
+	io.Writer
+}
+
+func newRecordedConn(conn io.ReadWriter, rec io.Writer) io.ReadWriter {
+	return &recordedConn{Reader: conn, Writer: io.MultiWriter(conn, rec)}
+}
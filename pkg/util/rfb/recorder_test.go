@@ -0,0 +1,75 @@
+package rfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := NewRecorder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := rec.Write([]byte("RFB 003.008\n")); err != nil || n != 12 {
+		t.Fatal("Expected full write, got:", n, err)
+	}
+	if _, err := rec.Write([]byte{1, 2, 3, 4, 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.Bytes()
+	if string(out[:len(fbsHeader)]) != fbsHeader {
+		t.Fatalf("Expected FBS header, got %q", out[:len(fbsHeader)])
+	}
+	out = out[len(fbsHeader):]
+
+	for _, expected := range [][]byte{[]byte("RFB 003.008\n"), {1, 2, 3, 4, 5}} {
+		length := int(binary.BigEndian.Uint32(out[:4]))
+		if length != len(expected) {
+			t.Fatalf("Expected block length %d, got %d", len(expected), length)
+		}
+		if !bytes.Equal(out[4:4+length], expected) {
+			t.Errorf("Expected block data %v, got %v", expected, out[4:4+length])
+		}
+		padded := length + (4-length%4)%4
+		out = out[4+padded+4:]
+	}
+	if len(out) != 0 {
+		t.Error("Expected no trailing data, got:", out)
+	}
+}
+
+func TestProxyRecorder(t *testing.T) {
+	clientConn, proxyClient := net.Pipe()
+	proxyServer, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	var buf bytes.Buffer
+	rec, err := NewRecorder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		Proxy(proxyClient, proxyServer, &ProxyOpts{Recorder: rec})
+		proxyClient.Close()
+		proxyServer.Close()
+		close(done)
+	}()
+
+	go serverConn.Write([]byte("RFB 003.008\n"))
+	if _, err := io.ReadFull(clientConn, make([]byte, 12)); err != nil {
+		t.Fatal(err)
+	}
+	serverConn.Close()
+	<-done
+
+	if !bytes.Contains(buf.Bytes(), []byte("RFB 003.008\n")) {
+		t.Error("Expected server data to be recorded")
+	}
+}
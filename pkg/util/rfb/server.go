@@ -0,0 +1,230 @@
+package rfb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// serverStream forwards RFB server messages to a client, optionally blending a
+// watermark into raw framebuffer rectangles or dropping clipboard updates.
+type serverStream struct {
+	dst io.Writer
+	src *bufio.Reader
+
+	// watermark, when not nil, is blended into all raw rectangles.
+	watermark *Watermark
+	// dropClipboard signals that clipboard updates should be dropped.
+	dropClipboard bool
+	// onResize, when not nil, is called with the new dimensions of the framebuffer
+	// whenever the server resizes it.
+	onResize func(w, h int)
+
+	format pixelFormat
+	mux    sync.Mutex
+}
+
+// setPixelFormat sets the pixel format the client has requested for updates.
+func (s *serverStream) setPixelFormat(pf pixelFormat) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.format = pf
+}
+
+// pixelFormat returns the current pixel format for updates.
+func (s *serverStream) pixelFormat() pixelFormat {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.format
+}
+
+// copyMessages forwards server messages until EOF is reached on the source.
+func (s *serverStream) copyMessages() error {
+	for {
+		if err := s.nextMessage(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (s *serverStream) nextMessage() error {
+	msgType, err := s.src.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch msgType {
+
+	case msgFramebufferUpdate:
+		hdr, err := s.readAndForward(msgType, 3)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < int(binary.BigEndian.Uint16(hdr[1:3])); i++ {
+			last, err := s.copyRect()
+			if err != nil {
+				return err
+			}
+			if last {
+				break
+			}
+		}
+		return nil
+
+	case msgSetColourMapEntries:
+		hdr, err := s.readAndForward(msgType, 5)
+		if err != nil {
+			return err
+		}
+		return s.forward(6 * int64(binary.BigEndian.Uint16(hdr[3:5])))
+
+	case msgBell:
+		_, err := s.dst.Write([]byte{msgType})
+		return err
+
+	case msgServerCutText:
+		hdr, err := s.read(7)
+		if err != nil {
+			return err
+		}
+		// A negative length signals an extended clipboard message
+		length := int64(int32(binary.BigEndian.Uint32(hdr[3:7])))
+		if length < 0 {
+			length = -length
+		}
+		if s.dropClipboard {
+			_, err := io.CopyN(ioutil.Discard, s.src, length)
+			return unexpectedEOF(err)
+		}
+		if _, err := s.dst.Write(append([]byte{msgType}, hdr...)); err != nil {
+			return err
+		}
+		return s.forward(length)
+
+	default:
+		return fmt.Errorf("Unsupported server message type: %d", msgType)
+	}
+}
+
+// copyRect forwards a single framebuffer rectangle, returning true if it was
+// the last in the update.
+func (s *serverStream) copyRect() (last bool, err error) {
+	hdr, err := s.read(12)
+	if err != nil {
+		return false, err
+	}
+	if _, err := s.dst.Write(hdr); err != nil {
+		return false, err
+	}
+	x := int(binary.BigEndian.Uint16(hdr[0:2]))
+	y := int(binary.BigEndian.Uint16(hdr[2:4]))
+	w := int(binary.BigEndian.Uint16(hdr[4:6]))
+	h := int(binary.BigEndian.Uint16(hdr[6:8]))
+	pf := s.pixelFormat()
+
+	switch encoding := int32(binary.BigEndian.Uint32(hdr[8:12])); encoding {
+	case encodingRaw:
+		return false, s.copyRawRect(pf, x, y, w, h)
+	case encodingCopyRect:
+		return false, s.forward(4)
+	case encodingZRLE:
+		length, err := s.read(4)
+		if err != nil {
+			return false, err
+		}
+		if _, err := s.dst.Write(length); err != nil {
+			return false, err
+		}
+		return false, s.forward(int64(binary.BigEndian.Uint32(length)))
+	case encodingDesktopSize:
+		s.resized(w, h)
+		return false, nil
+	case encodingLastRect:
+		return true, nil
+	case encodingCursor:
+		return false, s.forward(int64(w*h*pf.size() + ((w+7)/8)*h))
+	case encodingExtendedDesktopSize:
+		screens, err := s.read(4)
+		if err != nil {
+			return false, err
+		}
+		if _, err := s.dst.Write(screens); err != nil {
+			return false, err
+		}
+		// a non-zero status signals a failed resize request
+		if y == 0 {
+			s.resized(w, h)
+		}
+		return false, s.forward(16 * int64(screens[0]))
+	default:
+		return false, fmt.Errorf("Unsupported rectangle encoding: %d", encoding)
+	}
+}
+
+// copyRawRect forwards the pixel data for a raw rectangle one row at a time,
+// blending the watermark into each row if configured.
+func (s *serverStream) copyRawRect(pf pixelFormat, x, y, w, h int) error {
+	if s.watermark == nil {
+		return s.forward(int64(w * h * pf.size()))
+	}
+	size := pf.size()
+	row := make([]byte, w*size)
+	for j := 0; j < h; j++ {
+		if _, err := io.ReadFull(s.src, row); err != nil {
+			return unexpectedEOF(err)
+		}
+		if pf.trueColour {
+			for i := 0; i < w; i++ {
+				if !s.watermark.covers(x+i, y+j) {
+					continue
+				}
+				px := row[i*size : (i+1)*size]
+				pf.writePixel(px, blend(pf, pf.readPixel(px)))
+			}
+		}
+		if _, err := s.dst.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resized calls the resize callback, if configured, with the given dimensions.
+func (s *serverStream) resized(w, h int) {
+	if s.onResize != nil {
+		s.onResize(w, h)
+	}
+}
+
+// read reads exactly n bytes from the source.
+func (s *serverStream) read(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(s.src, buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return buf, nil
+}
+
+// readAndForward reads the n bytes following the given message type from the
+// source, forwards the message type and bytes to the destination, and returns
+// the bytes read.
+func (s *serverStream) readAndForward(msgType byte, n int) ([]byte, error) {
+	buf, err := s.read(n)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.dst.Write(append([]byte{msgType}, buf...))
+	return buf, err
+}
+
+// forward copies exactly n bytes from the source to the destination.
+func (s *serverStream) forward(n int64) error {
+	_, err := io.CopyN(s.dst, s.src, n)
+	return unexpectedEOF(err)
+}
@@ -0,0 +1,341 @@
+package rfb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// maxSessionClipboard is the largest clipboard update a session will hold on to
+// for replaying to a resumed client.
+const maxSessionClipboard = 1 << 20
+
+// Session is a resumable RFB session with a server. The handshake with the
+// server is completed once, and clients may then attach to and detach from the
+// session without the server seeing a new connection. Only one client is attached
+// at a time, and attaching a new client detaches the previous one.
+//
+// While no client is attached, framebuffer updates and bells from the server are
+// dropped. The latest clipboard update from the server is kept and replayed to
+// the next client, along with the current dimensions and pixel format of the
+// framebuffer. If no client attaches within the resume timeout, the session is
+// closed.
+type Session struct {
+	server        io.ReadWriteCloser
+	opts          *ProxyOpts
+	resumeTimeout time.Duration
+	ss            *serverStream
+
+	// serverInit is the ServerInit message sent to each attaching client, kept
+	// up to date with the framebuffer size and pixel format.
+	serverInit []byte
+	initMux    sync.Mutex
+
+	// mux is held while forwarding each server message, so that clients are
+	// only switched at message boundaries.
+	mux       sync.Mutex
+	client    *sessionClient
+	clipboard []byte
+	timer     *time.Timer
+
+	// writeMux serializes client messages to the server, and protects the
+	// client allowed to send them.
+	writeMux sync.Mutex
+	active   *sessionClient
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// sessionClient is a client attached to a session. Write errors are recorded
+// rather than returned, so that the server stream is never left in the middle
+// of a message when a client goes away.
+type sessionClient struct {
+	conn io.Writer
+	err  error
+	done chan struct{}
+}
+
+// Write writes to the client connection until the first error.
+func (c *sessionClient) Write(p []byte) (int, error) {
+	if c.err == nil {
+		_, c.err = c.conn.Write(p)
+	}
+	return len(p), nil
+}
+
+// NewSession performs the handshake with the server and returns a session that
+// clients can be attached to with Serve. The session is always requested as
+// shared. Watermarks and recordings are not supported, since they apply to a
+// single client connection. If a client does not attach within the resume
+// timeout, the session is closed.
+func NewSession(server io.ReadWriteCloser, opts *ProxyOpts, resumeTimeout time.Duration) (*Session, error) {
+	if opts == nil {
+		opts = &ProxyOpts{}
+	}
+	if opts.Watermark != nil || opts.Recorder != nil {
+		return nil, errors.New("Resumable sessions do not support watermarks or recordings")
+	}
+	serverRdr := bufio.NewReader(server)
+	serverInit, err := clientHandshake(server, serverRdr, true)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		server:        server,
+		opts:          opts,
+		resumeTimeout: resumeTimeout,
+		serverInit:    serverInit,
+		done:          make(chan struct{}),
+	}
+	s.ss = &serverStream{
+		src:           serverRdr,
+		dropClipboard: opts.DisableClipboardOut,
+		onResize:      s.setSize,
+		format:        parsePixelFormat(serverInit[4:20]),
+	}
+	s.mux.Lock()
+	s.startTimer()
+	s.mux.Unlock()
+	go s.copyServerMessages()
+	return s, nil
+}
+
+// Done returns a channel that is closed when the session has ended.
+func (s *Session) Done() <-chan struct{} { return s.done }
+
+// Close ends the session, detaching any client and closing the server connection.
+func (s *Session) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.server.Close()
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		if s.client != nil {
+			s.detach(s.client)
+		}
+	})
+	return err
+}
+
+// Serve performs the handshake with the client and attaches it to the session,
+// detaching any previous client. Serve returns when the client disconnects, is
+// replaced by another client, or the session ends. The caller is responsible for
+// closing the client connection.
+func (s *Session) Serve(client io.ReadWriter) error {
+	clientRdr := bufio.NewReader(client)
+	if _, err := serverHandshake(client, clientRdr); err != nil {
+		return err
+	}
+	c := &sessionClient{conn: newRateLimitedWriter(client, s.opts.Throttle), done: make(chan struct{})}
+	if err := s.attach(c); err != nil {
+		return err
+	}
+
+	cs := &clientStream{
+		src:              clientRdr,
+		dropInput:        s.opts.ViewOnly,
+		dropClipboard:    s.opts.DisableClipboardIn,
+		textInput:        s.opts.TextInput,
+		encodings:        inspectedEncodings,
+		onSetPixelFormat: s.setPixelFormat,
+		throttle:         s.opts.Throttle,
+	}
+	cs.sendDeferred = func(msg []byte) {
+		s.writeMux.Lock()
+		defer s.writeMux.Unlock()
+		if s.active == c {
+			s.server.Write(msg)
+		}
+	}
+	defer cs.watchThrottle()()
+	errs := make(chan error, 1)
+	go func() { errs <- s.copyClientMessages(c, cs) }()
+
+	select {
+	case err := <-errs:
+		s.mux.Lock()
+		s.detach(c)
+		s.mux.Unlock()
+		return err
+	case <-c.done:
+		return nil
+	}
+}
+
+// attach sends the current ServerInit and clipboard to the client and makes it
+// the attached client of the session.
+func (s *Session) attach(c *sessionClient) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	select {
+	case <-s.done:
+		return errors.New("The session has ended")
+	default:
+	}
+	if s.client != nil {
+		s.detach(s.client)
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	c.Write(s.getServerInit())
+	if s.clipboard != nil {
+		c.Write(s.clipboard)
+	}
+	if c.err != nil {
+		s.startTimer()
+		return c.err
+	}
+	s.client = c
+	s.writeMux.Lock()
+	s.active = c
+	s.writeMux.Unlock()
+	return nil
+}
+
+// detach removes the client from the session if it is still attached and starts
+// the resume timer. It must be called with the session mutex held.
+func (s *Session) detach(c *sessionClient) {
+	if s.client != c {
+		return
+	}
+	s.client = nil
+	s.writeMux.Lock()
+	s.active = nil
+	s.writeMux.Unlock()
+	close(c.done)
+	s.startTimer()
+}
+
+// startTimer starts the timer for closing the session if no client attaches.
+// It must be called with the session mutex held.
+func (s *Session) startTimer() {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	s.timer = time.AfterFunc(s.resumeTimeout, func() {
+		s.mux.Lock()
+		expired := s.client == nil
+		s.mux.Unlock()
+		if expired {
+			s.Close()
+		}
+	})
+}
+
+// copyServerMessages forwards server messages to the attached client until the
+// server connection fails, at which point the session is closed.
+func (s *Session) copyServerMessages() {
+	defer s.Close()
+	for {
+		// wait for the next message before taking the lock, so clients can
+		// attach while the server is idle
+		msgType, err := s.ss.src.Peek(1)
+		if err != nil {
+			return
+		}
+		if err := s.nextServerMessage(msgType[0]); err != nil {
+			return
+		}
+	}
+}
+
+// nextServerMessage forwards a single server message to the attached client, or
+// holds on to it if it needs to be replayed to the next client.
+func (s *Session) nextServerMessage(msgType byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var dst io.Writer = ioutil.Discard
+	if s.client != nil {
+		dst = s.client
+	}
+	var clipboard *bytes.Buffer
+	if msgType == msgServerCutText && !s.opts.DisableClipboardOut {
+		clipboard = &bytes.Buffer{}
+		dst = io.MultiWriter(dst, clipboard)
+	}
+	s.ss.dst = dst
+	if err := s.ss.nextMessage(); err != nil {
+		return err
+	}
+
+	if clipboard != nil {
+		s.clipboard = nil
+		if clipboard.Len() <= maxSessionClipboard {
+			s.clipboard = clipboard.Bytes()
+		}
+	}
+	if s.client != nil && s.client.err != nil {
+		s.detach(s.client)
+	}
+	return nil
+}
+
+// copyClientMessages forwards messages from the client to the server for as long
+// as the client is attached. Each message is buffered in full so that messages
+// from different clients are never interleaved.
+func (s *Session) copyClientMessages(c *sessionClient, cs *clientStream) error {
+	var buf bytes.Buffer
+	cs.dst = &buf
+	for {
+		buf.Reset()
+		if err := cs.nextMessage(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if buf.Len() == 0 {
+			continue
+		}
+		s.writeMux.Lock()
+		if s.active != c {
+			s.writeMux.Unlock()
+			return nil
+		}
+		_, err := s.server.Write(buf.Bytes())
+		s.writeMux.Unlock()
+		if err != nil {
+			s.Close()
+			return err
+		}
+	}
+}
+
+// getServerInit returns a copy of the current ServerInit message.
+func (s *Session) getServerInit() []byte {
+	s.initMux.Lock()
+	defer s.initMux.Unlock()
+	return append([]byte{}, s.serverInit...)
+}
+
+// setSize updates the framebuffer dimensions in the ServerInit message.
+func (s *Session) setSize(w, h int) {
+	s.initMux.Lock()
+	defer s.initMux.Unlock()
+	binary.BigEndian.PutUint16(s.serverInit[0:2], uint16(w))
+	binary.BigEndian.PutUint16(s.serverInit[2:4], uint16(h))
+}
+
+// setPixelFormat updates the pixel format used for inspecting updates and the
+// one advertised to the next client in the ServerInit message.
+func (s *Session) setPixelFormat(pf pixelFormat) {
+	s.ss.setPixelFormat(pf)
+	s.initMux.Lock()
+	defer s.initMux.Unlock()
+	copy(s.serverInit[4:20], pf.bytes())
+}
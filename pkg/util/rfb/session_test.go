@@ -0,0 +1,142 @@
+package rfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeServerHandshake performs the server side of the handshake for a 4x1
+// framebuffer and returns the shared flag sent by the client.
+func fakeServerHandshake(conn net.Conn) byte {
+	conn.Write([]byte("RFB 003.008\n"))
+	io.ReadFull(conn, make([]byte, 12))
+	conn.Write([]byte{1, securityNone})
+	io.ReadFull(conn, make([]byte, 1))
+	conn.Write([]byte{0, 0, 0, 0})
+	shared := make([]byte, 1)
+	io.ReadFull(conn, shared)
+	serverInit := make([]byte, 24)
+	binary.BigEndian.PutUint16(serverInit[0:2], 4)
+	binary.BigEndian.PutUint16(serverInit[2:4], 1)
+	serverInit[4], serverInit[5], serverInit[7] = 32, 24, 1
+	conn.Write(serverInit)
+	return shared[0]
+}
+
+// fakeClientHandshake performs the client side of the handshake and returns the
+// ServerInit message.
+func fakeClientHandshake(t *testing.T, conn net.Conn) []byte {
+	if _, err := io.ReadFull(conn, make([]byte, 12)); err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("RFB 003.008\n"))
+	io.ReadFull(conn, make([]byte, 2))
+	conn.Write([]byte{securityNone})
+	io.ReadFull(conn, make([]byte, 4))
+	conn.Write([]byte{0})
+	serverInit := make([]byte, 24)
+	if _, err := io.ReadFull(conn, serverInit); err != nil {
+		t.Fatal(err)
+	}
+	return serverInit
+}
+
+func TestSessionResume(t *testing.T) {
+	proxyServer, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	sharedFlag := make(chan byte, 1)
+	go func() { sharedFlag <- fakeServerHandshake(serverConn) }()
+
+	sess, err := NewSession(proxyServer, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	if shared := <-sharedFlag; shared != 1 {
+		t.Error("Expected the session to be requested as shared")
+	}
+
+	// attach the first client and send it a clipboard update
+	clientConn, proxyClient := net.Pipe()
+	served := make(chan error, 1)
+	go func() { served <- sess.Serve(proxyClient) }()
+	fakeClientHandshake(t, clientConn)
+
+	cutText := []byte{msgServerCutText, 0, 0, 0, 0, 0, 0, 5, 'h', 'e', 'l', 'l', 'o'}
+	go serverConn.Write(cutText)
+	buf := make([]byte, len(cutText))
+	if _, err := io.ReadFull(clientConn, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// drop the first client, the server then resizes the display while nobody
+	// is attached
+	clientConn.Close()
+	if err := <-served; err != nil {
+		t.Error("Expected no error from closed client, got", err)
+	}
+	proxyClient.Close()
+	update := []byte{msgFramebufferUpdate, 0, 0, 1, 0, 0, 0, 0, 0, 8, 0, 2, 0xFF, 0xFF, 0xFF, 0x21}
+	if _, err := serverConn.Write(update); err != nil {
+		t.Fatal(err)
+	}
+
+	// resume with a second client
+	clientConn, proxyClient = net.Pipe()
+	defer clientConn.Close()
+	go func() { served <- sess.Serve(proxyClient) }()
+	serverInit := fakeClientHandshake(t, clientConn)
+	if w, h := binary.BigEndian.Uint16(serverInit[0:2]), binary.BigEndian.Uint16(serverInit[2:4]); w != 8 || h != 2 {
+		t.Errorf("Expected resized framebuffer 8x2, got %dx%d", w, h)
+	}
+	if _, err := io.ReadFull(clientConn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, cutText) {
+		t.Error("Expected clipboard to be replayed to resumed client, got", buf)
+	}
+
+	// input from the resumed client reaches the same server connection
+	keyEvent := []byte{msgKeyEvent, 1, 0, 0, 0, 0, 0, 0x61}
+	go clientConn.Write(keyEvent)
+	buf = make([]byte, len(keyEvent))
+	if _, err := io.ReadFull(serverConn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, keyEvent) {
+		t.Error("Expected key event to be forwarded, got", buf)
+	}
+}
+
+func TestSessionResumeTimeout(t *testing.T) {
+	proxyServer, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go fakeServerHandshake(serverConn)
+
+	sess, err := NewSession(proxyServer, nil, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sess.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected session to close when no client attached")
+	}
+	if err := sess.Serve(proxyServer); err == nil {
+		t.Error("Expected error attaching to a closed session")
+	}
+}
+
+func TestSessionUnsupportedOpts(t *testing.T) {
+	proxyServer, serverConn := net.Pipe()
+	defer proxyServer.Close()
+	defer serverConn.Close()
+	if _, err := NewSession(proxyServer, &ProxyOpts{Watermark: NewWatermark("a")}, time.Minute); err == nil {
+		t.Error("Expected error creating a watermarked session")
+	}
+}
@@ -0,0 +1,52 @@
+package rfb
+
+import "encoding/binary"
+
+// maxTextInputLength is the largest text input message accepted from a client.
+// Composed text is typically a handful of characters, so anything larger is
+// treated as a protocol error.
+const maxTextInputLength = 4096
+
+// X11 keysyms for control characters that can appear in composed text
+const (
+	keysymTab    uint32 = 0xff09
+	keysymReturn uint32 = 0xff0d
+)
+
+// runeToKeysym returns the X11 keysym for the given character. Latin-1 characters
+// map directly to their keysyms, and all other characters use the Unicode keysym
+// range. Control characters other than tabs and newlines have no keysym.
+func runeToKeysym(r rune) (uint32, bool) {
+	switch {
+	case r == '\t':
+		return keysymTab, true
+	case r == '\n' || r == '\r':
+		return keysymReturn, true
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return 0, false
+	case r <= 0xff:
+		return uint32(r), true
+	default:
+		return 0x01000000 | uint32(r), true
+	}
+}
+
+// textInputKeyEvents returns a press and release KeyEvent for each character in
+// the given text. Servers that do not have a keycode for a keysym, such as Xvnc,
+// map it to a free keycode on demand, so characters missing from the current
+// keyboard layout can still be typed.
+func textInputKeyEvents(text string) []byte {
+	events := make([]byte, 0, 16*len(text))
+	for _, r := range text {
+		keysym, ok := runeToKeysym(r)
+		if !ok {
+			continue
+		}
+		for _, down := range []byte{1, 0} {
+			event := []byte{msgKeyEvent, down, 0, 0, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(event[4:8], keysym)
+			events = append(events, event...)
+		}
+	}
+	return events
+}
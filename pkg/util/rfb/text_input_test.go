@@ -0,0 +1,68 @@
+package rfb
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestTextInputKeyEvents(t *testing.T) {
+	expected := []byte{
+		msgKeyEvent, 1, 0, 0, 0, 0, 0, 0x61,
+		msgKeyEvent, 0, 0, 0, 0, 0, 0, 0x61,
+		msgKeyEvent, 1, 0, 0, 0, 0, 0, 0xe9,
+		msgKeyEvent, 0, 0, 0, 0, 0, 0, 0xe9,
+		msgKeyEvent, 1, 0, 0, 0x01, 0, 0x65, 0xe5,
+		msgKeyEvent, 0, 0, 0, 0x01, 0, 0x65, 0xe5,
+		msgKeyEvent, 1, 0, 0, 0, 0, 0xff, 0x0d,
+		msgKeyEvent, 0, 0, 0, 0, 0, 0xff, 0x0d,
+	}
+	// the escape has no keysym and is skipped
+	if events := textInputKeyEvents("aé日\x1b\n"); !bytes.Equal(events, expected) {
+		t.Errorf("Unexpected key events, got %v", events)
+	}
+}
+
+func TestClientStreamTextInput(t *testing.T) {
+	var client bytes.Buffer
+	client.WriteString("RFB 003.008\n")
+	client.WriteByte(securityNone)
+	client.WriteByte(0)
+	client.Write([]byte{msgTextInput, 0, 0, 0, 0, 0, 0, 3})
+	client.WriteString("日")
+
+	var expected bytes.Buffer
+	expected.WriteString("RFB 003.008\n")
+	expected.WriteByte(securityNone)
+	expected.WriteByte(0)
+	expected.Write(textInputKeyEvents("日"))
+
+	var server bytes.Buffer
+	if _, err := copyClientStream(&clientStream{dst: &server, src: bufio.NewReader(bytes.NewReader(client.Bytes())), textInput: true}); err != nil {
+		t.Fatal("Expected no error, got:", err)
+	}
+	if !bytes.Equal(server.Bytes(), expected.Bytes()) {
+		t.Errorf("Unexpected server stream, got %v", server.Bytes())
+	}
+
+	// clients may only send text input when it is enabled
+	if _, err := copyViewOnly(&bytes.Buffer{}, bytes.NewReader(client.Bytes())); err == nil {
+		t.Error("Expected error for text input when it is not enabled")
+	}
+
+	// and it is dropped with other input events
+	server.Reset()
+	if _, err := copyClientStream(&clientStream{dst: &server, src: bufio.NewReader(bytes.NewReader(client.Bytes())), textInput: true, dropInput: true}); err != nil {
+		t.Fatal("Expected no error, got:", err)
+	}
+	if bytes.Contains(server.Bytes(), []byte{msgKeyEvent}) {
+		t.Errorf("Expected text input to be dropped, got %v", server.Bytes())
+	}
+
+	// oversized messages end the session
+	oversized := bytes.NewBuffer([]byte("RFB 003.008\n"))
+	oversized.Write([]byte{securityNone, 0, msgTextInput, 0, 0, 0, 0, 0x01, 0, 0})
+	if _, err := copyClientStream(&clientStream{dst: &bytes.Buffer{}, src: bufio.NewReader(oversized), textInput: true}); err == nil {
+		t.Error("Expected error for oversized text input")
+	}
+}
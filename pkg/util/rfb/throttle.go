@@ -0,0 +1,189 @@
+package rfb
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Pseudo-encodings adjusted by a throttle
+const (
+	encodingQualityLevel0     int32 = -32
+	encodingQualityLevel9     int32 = -23
+	encodingContinuousUpdates int32 = -313
+)
+
+const (
+	// maxQualityLevel is the highest quality level clients can request
+	maxQualityLevel = 9
+	// bandwidthChunksPerSecond is how many chunks the bandwidth allowed each second
+	// is split into
+	bandwidthChunksPerSecond = 10
+	// minBandwidthChunk is the smallest chunk written at once
+	minBandwidthChunk = 1024
+)
+
+// ThrottleLimits are the limits a Throttle applies to the display stream sent
+// to a client.
+type ThrottleLimits struct {
+	// The highest JPEG quality level, from 0 to 9, the server is asked to encode
+	// with. Quality requested by the client above it is lowered, and it is used
+	// when the client does not request any. Negative for no limit.
+	Quality int
+	// The most framebuffer updates per second the server is asked for. Zero for
+	// no limit.
+	FrameRate int
+	// The most bytes per second sent to the client. Zero for no limit.
+	Bandwidth int64
+}
+
+// Throttle limits the display stream sent to clients. The limits can be changed
+// while sessions using the throttle are running, and take effect right away.
+//
+// Throttled clients may not enable continuous updates, so that the server only
+// sends updates when asked for them.
+type Throttle struct {
+	mux         sync.Mutex
+	limits      ThrottleLimits
+	subscribers map[int]func()
+	nextID      int
+}
+
+// NewThrottle returns a throttle applying the given limits.
+func NewThrottle(limits ThrottleLimits) *Throttle {
+	return &Throttle{limits: limits, subscribers: make(map[int]func())}
+}
+
+// Limits returns the limits currently applied by the throttle.
+func (t *Throttle) Limits() ThrottleLimits {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.limits
+}
+
+// SetLimits changes the limits applied by the throttle. Sessions using the
+// throttle ask the server for the new quality level right away.
+func (t *Throttle) SetLimits(limits ThrottleLimits) {
+	t.mux.Lock()
+	changed := limits.Quality != t.limits.Quality
+	t.limits = limits
+	subscribers := make([]func(), 0, len(t.subscribers))
+	for _, fn := range t.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	t.mux.Unlock()
+	if changed {
+		for _, fn := range subscribers {
+			fn()
+		}
+	}
+}
+
+// onQualityChange registers a function to be called whenever the quality limit
+// changes. The returned function removes it.
+func (t *Throttle) onQualityChange(fn func()) func() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	id := t.nextID
+	t.nextID++
+	t.subscribers[id] = fn
+	return func() {
+		t.mux.Lock()
+		defer t.mux.Unlock()
+		delete(t.subscribers, id)
+	}
+}
+
+// frameInterval returns the minimum time between framebuffer update requests.
+func (t *Throttle) frameInterval() time.Duration {
+	if limits := t.Limits(); limits.FrameRate > 0 {
+		return time.Second / time.Duration(limits.FrameRate)
+	}
+	return 0
+}
+
+// adjustEncodings returns the given SetEncodings payload with continuous updates
+// removed and the quality level limited.
+func (t *Throttle) adjustEncodings(encodings []int32) []int32 {
+	limit := t.Limits().Quality
+	if limit > maxQualityLevel {
+		limit = maxQualityLevel
+	}
+	out := make([]int32, 0, len(encodings)+1)
+	requested := -1
+	for _, enc := range encodings {
+		switch {
+		case enc == encodingContinuousUpdates:
+			continue
+		case enc >= encodingQualityLevel0 && enc <= encodingQualityLevel9:
+			if requested < 0 {
+				requested = int(enc - encodingQualityLevel0)
+			}
+			continue
+		}
+		out = append(out, enc)
+	}
+	quality := requested
+	if limit >= 0 && (quality < 0 || quality > limit) {
+		quality = limit
+	}
+	if quality >= 0 {
+		out = append(out, encodingQualityLevel0+int32(quality))
+	}
+	return out
+}
+
+// rateLimitedWriter limits the rate data is written to the underlying writer to
+// the bandwidth allowed by a throttle. Writes are split into chunks so that the
+// stream stays smooth when the limit is low.
+type rateLimitedWriter struct {
+	w        io.Writer
+	throttle *Throttle
+	// when the next chunk may be written
+	next time.Time
+}
+
+// newRateLimitedWriter returns a writer limited by the given throttle, or the
+// writer itself if there is no throttle.
+func newRateLimitedWriter(w io.Writer, throttle *Throttle) io.Writer {
+	if throttle == nil {
+		return w
+	}
+	return &rateLimitedWriter{w: w, throttle: throttle}
+}
+
+// Write writes p to the underlying writer, waiting as needed to stay within the
+// bandwidth limit.
+func (r *rateLimitedWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		rate := r.throttle.Limits().Bandwidth
+		if rate <= 0 {
+			n, err := r.w.Write(p)
+			return written + n, err
+		}
+		chunkSize := int(rate / bandwidthChunksPerSecond)
+		if chunkSize < minBandwidthChunk {
+			chunkSize = minBandwidthChunk
+		}
+		chunk := p
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+		if wait := time.Until(r.next); wait > 0 {
+			time.Sleep(wait)
+		}
+		n, err := r.w.Write(chunk)
+		written += n
+		now := time.Now()
+		if r.next.Before(now) {
+			r.next = now
+		}
+		r.next = r.next.Add(time.Duration(int64(n) * int64(time.Second) / rate))
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,115 @@
+package rfb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func setEncodingsMessage(encodings ...int32) []byte {
+	msg := []byte{msgSetEncodings, 0, 0, 0}
+	binary.BigEndian.PutUint16(msg[2:4], uint16(len(encodings)))
+	for _, enc := range encodings {
+		msg = append(msg, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(msg[len(msg)-4:], uint32(enc))
+	}
+	return msg
+}
+
+func TestThrottleAdjustEncodings(t *testing.T) {
+	tests := []struct {
+		limit    int
+		in, out  []int32
+		scenario string
+	}{
+		{-1, []int32{7, encodingContinuousUpdates, -30}, []int32{7, -30}, "no quality limit"},
+		{5, []int32{7, encodingQualityLevel9}, []int32{7, -27}, "quality lowered to the limit"},
+		{5, []int32{7, -30}, []int32{7, -30}, "quality below the limit"},
+		{3, []int32{7}, []int32{7, -29}, "quality added when not requested"},
+	}
+	for _, tc := range tests {
+		throttle := NewThrottle(ThrottleLimits{Quality: tc.limit})
+		if out := throttle.adjustEncodings(tc.in); !reflect.DeepEqual(out, tc.out) {
+			t.Errorf("%s: expected %v, got %v", tc.scenario, tc.out, out)
+		}
+	}
+}
+
+func TestClientStreamThrottle(t *testing.T) {
+	var client bytes.Buffer
+	client.Write(setEncodingsMessage(7, encodingContinuousUpdates, -30))
+	client.Write([]byte{msgEnableContinuousUpdates, 1, 0, 0, 0, 0, 0, 4, 0, 1})
+	request := []byte{msgFramebufferUpdateRequest, 1, 0, 0, 0, 0, 0, 4, 0, 1}
+	for i := 0; i < 3; i++ {
+		client.Write(request)
+	}
+
+	throttle := NewThrottle(ThrottleLimits{Quality: -1, FrameRate: 10})
+	var server bytes.Buffer
+	deferred := make(chan []byte, 2)
+	cs := &clientStream{dst: &server, src: bufio.NewReader(&client), throttle: throttle}
+	cs.sendDeferred = func(msg []byte) { deferred <- msg }
+	defer cs.watchThrottle()()
+	if err := cs.copyMessages(); err != nil {
+		t.Fatal(err)
+	}
+
+	// continuous updates are removed and only the first request is sent right away
+	expected := append(setEncodingsMessage(7, -30), request...)
+	if !bytes.Equal(server.Bytes(), expected) {
+		t.Errorf("Unexpected server stream, got %v", server.Bytes())
+	}
+
+	// the latest held back request is sent once the frame interval passes
+	select {
+	case msg := <-deferred:
+		if !bytes.Equal(msg, request) {
+			t.Error("Expected the held back update request, got:", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the held back update request to be sent")
+	}
+
+	// changing the quality limit sends the encodings again
+	throttle.SetLimits(ThrottleLimits{Quality: 1, FrameRate: 10})
+	select {
+	case msg := <-deferred:
+		if !bytes.Equal(msg, setEncodingsMessage(7, -31)) {
+			t.Error("Expected the encodings with the new quality level, got:", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the encodings to be sent again")
+	}
+}
+
+func TestRateLimitedWriter(t *testing.T) {
+	throttle := NewThrottle(ThrottleLimits{Bandwidth: 10 * 1024})
+	var out bytes.Buffer
+	w := newRateLimitedWriter(&out, throttle)
+
+	// the first chunk is written right away and the next two wait for their share
+	// of the bandwidth
+	start := time.Now()
+	if n, err := w.Write(make([]byte, 3*1024)); err != nil || n != 3*1024 {
+		t.Fatal("Expected all bytes to be written, got:", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Error("Expected the write to be limited, took:", elapsed)
+	}
+
+	// removing the limit takes effect right away
+	throttle.SetLimits(ThrottleLimits{})
+	start = time.Now()
+	if _, err := w.Write(make([]byte, 64*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Error("Expected the write to not be limited, took:", elapsed)
+	}
+	if out.Len() != 67*1024 {
+		t.Error("Expected all bytes to reach the writer, got:", out.Len())
+	}
+}
@@ -0,0 +1,88 @@
+package rfb
+
+// Watermark rendering parameters
+const (
+	// The factor to scale each glyph in the font by
+	watermarkScale = 3
+	// The opacity of the watermark out of 255
+	watermarkAlpha = 64
+	// The horizontal and vertical space between repetitions of the text
+	watermarkSpacingX = 160
+	watermarkSpacingY = 120
+)
+
+// Watermark overlays text onto the framebuffer updates sent to an RFB client.
+// The text is tiled across the entire display, with each row of tiles offset
+// from the one above it.
+type Watermark struct {
+	mask          []bool
+	width, height int
+}
+
+// NewWatermark returns a new Watermark rendering the given text.
+func NewWatermark(text string) *Watermark {
+	chars := []rune(text)
+	w := &Watermark{
+		width:  len(chars)*(glyphWidth+1)*watermarkScale + watermarkSpacingX,
+		height: glyphHeight*watermarkScale + watermarkSpacingY,
+	}
+	w.mask = make([]bool, w.width*w.height)
+	for i, char := range chars {
+		g := glyph(char)
+		for row := 0; row < glyphHeight; row++ {
+			for col := 0; col < glyphWidth; col++ {
+				if g[row]&(1<<uint(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				for sy := 0; sy < watermarkScale; sy++ {
+					for sx := 0; sx < watermarkScale; sx++ {
+						x := (i*(glyphWidth+1)+col)*watermarkScale + sx
+						y := row*watermarkScale + sy
+						w.mask[y*w.width+x] = true
+					}
+				}
+			}
+		}
+	}
+	return w
+}
+
+// covers returns true if the watermark covers the pixel at the given position
+// in the framebuffer.
+func (w *Watermark) covers(x, y int) bool {
+	tileRow := y / w.height
+	tx := (x + tileRow*w.width/2) % w.width
+	return w.mask[(y%w.height)*w.width+tx]
+}
+
+// blend blends the watermark into the given true-colour pixel value. Light
+// pixels are darkened and dark pixels are lightened so the watermark remains
+// visible on any background.
+func blend(pf pixelFormat, v uint32) uint32 {
+	r := (v >> pf.redShift) & uint32(pf.redMax)
+	g := (v >> pf.greenShift) & uint32(pf.greenMax)
+	b := (v >> pf.blueShift) & uint32(pf.blueMax)
+	darken := scaleChannel(r, pf.redMax)+scaleChannel(g, pf.greenMax)+scaleChannel(b, pf.blueMax) > 3*127
+	v &^= uint32(pf.redMax)<<pf.redShift | uint32(pf.greenMax)<<pf.greenShift | uint32(pf.blueMax)<<pf.blueShift
+	return v |
+		blendChannel(r, pf.redMax, darken)<<pf.redShift |
+		blendChannel(g, pf.greenMax, darken)<<pf.greenShift |
+		blendChannel(b, pf.blueMax, darken)<<pf.blueShift
+}
+
+// scaleChannel scales a colour channel value to the range 0-255.
+func scaleChannel(c uint32, max uint16) uint32 {
+	if max == 0 {
+		return 0
+	}
+	return c * 255 / uint32(max)
+}
+
+// blendChannel moves a colour channel value towards zero or its maximum by the
+// watermark alpha.
+func blendChannel(c uint32, max uint16, darken bool) uint32 {
+	if darken {
+		return c - c*watermarkAlpha/255
+	}
+	return c + (uint32(max)-c)*watermarkAlpha/255
+}
@@ -0,0 +1,41 @@
+package rfb
+
+import "testing"
+
+var testPixelFormat = pixelFormat{
+	bitsPerPixel: 32,
+	trueColour:   true,
+	redMax:       255,
+	greenMax:     255,
+	blueMax:      255,
+	redShift:     16,
+	greenShift:   8,
+	blueShift:    0,
+}
+
+func TestWatermarkCovers(t *testing.T) {
+	w := NewWatermark("a")
+	// The top row of 'A' is 0x0E, so the first column is empty and the second is set
+	if w.covers(0, 0) {
+		t.Error("Expected first column of glyph to be uncovered")
+	}
+	if !w.covers(watermarkScale, 0) {
+		t.Error("Expected second column of glyph to be covered")
+	}
+	// The next row of tiles should be offset by half a tile
+	if !w.covers(watermarkScale-w.width/2+w.width, w.height) {
+		t.Error("Expected second row of tiles to be offset")
+	}
+}
+
+func TestBlend(t *testing.T) {
+	if v := blend(testPixelFormat, 0x000000); v == 0x000000 {
+		t.Error("Expected black pixel to be lightened")
+	}
+	if v := blend(testPixelFormat, 0xFFFFFF); v >= 0xFFFFFF {
+		t.Error("Expected white pixel to be darkened")
+	}
+	if v := blend(testPixelFormat, 0xFF000000); v&0xFF000000 != 0xFF000000 {
+		t.Error("Expected unused bits to be preserved")
+	}
+}
@@ -0,0 +1,219 @@
+package s3util
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/util/sigv4"
+)
+
+// DefaultRegion is the region used when one is not configured.
+const DefaultRegion = "us-east-1"
+
+// Client is a client for a single bucket in S3-compatible storage. Objects are
+// addressed using path-style URLs so that custom endpoints work without
+// wildcard DNS.
+type Client struct {
+	endpoint, region, bucket string
+	creds                    *Credentials
+	client                   *http.Client
+}
+
+// Object contains information about an object in the bucket.
+type Object struct {
+	// The key of the object.
+	Key string `xml:"Key"`
+	// The size of the object in bytes.
+	Size int64 `xml:"Size"`
+	// When the object was last modified.
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// StatusError is returned when S3 responds with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface.
+func (s *StatusError) Error() string {
+	return fmt.Sprintf("S3 returned status %d: %s", s.StatusCode, s.Message)
+}
+
+// IsNotFound returns true if the given error was caused by a request for an
+// object that does not exist.
+func IsNotFound(err error) bool {
+	if statusErr, ok := err.(*StatusError); ok {
+		return statusErr.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// NewClient returns a new client for the given bucket. If the region is empty,
+// DefaultRegion is used. If the endpoint is empty, the AWS endpoint for the region
+// is used.
+func NewClient(endpoint, region, bucket string, creds *Credentials) *Client {
+	if region == "" {
+		region = DefaultRegion
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		region:   region,
+		bucket:   bucket,
+		creds:    creds,
+		client:   http.DefaultClient,
+	}
+}
+
+// objectURL returns the path-style URL for the given key. The raw path is set to
+// the same encoding used when signing, so the two always match.
+func (c *Client) objectURL(key string, query url.Values) (*url.URL, error) {
+	u, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = sigv4.CanonicalURI(u)
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return u, nil
+}
+
+// do signs and executes the given request. A StatusError is returned for any
+// non-2xx response, otherwise the caller is responsible for closing the body.
+func (c *Client) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	creds := &sigv4.Credentials{AccessKeyID: c.creds.AccessKeyID, SecretAccessKey: c.creds.SecretAccessKey}
+	sigv4.SignRequest(req, payloadHash, creds, c.region, "s3", time.Now())
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+		return nil, &StatusError{StatusCode: res.StatusCode, Message: string(msg)}
+	}
+	return res, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u, err := c.objectURL(key, query)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+// PutObject writes the given body to the key in the bucket.
+func (c *Client) PutObject(ctx context.Context, key, contentType string, body []byte) error {
+	req, err := c.newRequest(ctx, http.MethodPut, key, nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := c.do(req, sigv4.SHA256Hex(body))
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// PutObjectStream writes the contents of the given reader to the key in the bucket.
+// The size must be known ahead of time. Since the body is not hashed, the endpoint
+// should use TLS.
+func (c *Client) PutObjectStream(ctx context.Context, key, contentType string, body io.Reader, size int64) error {
+	req, err := c.newRequest(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	res, err := c.do(req, sigv4.UnsignedPayload)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// GetObject returns the contents and size of the given key in the bucket. The
+// caller is responsible for closing the reader.
+func (c *Client) GetObject(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := c.do(req, sigv4.SHA256Hex(nil))
+	if err != nil {
+		return nil, 0, err
+	}
+	return res.Body, res.ContentLength, nil
+}
+
+// DeleteObject removes the given key from the bucket.
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.do(req, sigv4.SHA256Hex(nil))
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// listBucketResult is the response to a ListObjectsV2 request.
+type listBucketResult struct {
+	Contents              []*Object `xml:"Contents"`
+	IsTruncated           bool      `xml:"IsTruncated"`
+	NextContinuationToken string    `xml:"NextContinuationToken"`
+}
+
+// ListObjects returns all objects in the bucket with the given prefix.
+func (c *Client) ListObjects(ctx context.Context, prefix string) ([]*Object, error) {
+	objects := make([]*Object, 0)
+	var token string
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := c.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := c.do(req, sigv4.SHA256Hex(nil))
+		if err != nil {
+			return nil, err
+		}
+		result := &listBucketResult{}
+		err = xml.NewDecoder(res.Body).Decode(result)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
@@ -0,0 +1,20 @@
+package s3util
+
+import (
+	"testing"
+)
+
+func TestObjectURL(t *testing.T) {
+	c := NewClient("http://minio:9000/", "", "bucket", &Credentials{})
+	u, err := c.objectURL("prefix/user@example.com.fbs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "http://minio:9000/bucket/prefix/user%40example.com.fbs"
+	if u.String() != expected {
+		t.Errorf("Expected URL %q, got %q", expected, u.String())
+	}
+	if c.region != DefaultRegion {
+		t.Error("Expected default region, got:", c.region)
+	}
+}
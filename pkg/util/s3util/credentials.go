@@ -0,0 +1,35 @@
+package s3util
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Credentials contains the keys used for signing requests to S3.
+type Credentials struct {
+	AccessKeyID, SecretAccessKey string
+}
+
+// GetCredentials retrieves the `AWS_ACCESS_KEY_ID` and `AWS_SECRET_ACCESS_KEY`
+// from the given secret.
+func GetCredentials(c client.Client, secretName, namespace string) (*Credentials, error) {
+	if secretName == "" {
+		return nil, fmt.Errorf("No credentialsSecret configured for S3")
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: namespace}, secret); err != nil {
+		return nil, err
+	}
+	creds := &Credentials{
+		AccessKeyID:     string(secret.Data["AWS_ACCESS_KEY_ID"]),
+		SecretAccessKey: string(secret.Data["AWS_SECRET_ACCESS_KEY"]),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("Secret %s must contain AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", secretName)
+	}
+	return creds, nil
+}
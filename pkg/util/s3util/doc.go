@@ -0,0 +1,5 @@
+// Package s3util contains a minimal client for S3-compatible object storage.
+// It only implements the handful of operations needed for exporting reports
+// and storing session recordings, and signs requests with the sigv4 package
+// so no SDK is required.
+package s3util
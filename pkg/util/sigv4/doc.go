@@ -0,0 +1,3 @@
+// Package sigv4 signs requests to AWS and S3-compatible APIs with AWS Signature
+// Version 4, so no SDK is required.
+package sigv4
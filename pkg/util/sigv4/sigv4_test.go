@@ -0,0 +1,59 @@
+package sigv4
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	u, _ := url.Parse("https://s3.amazonaws.com/bucket?prefix=a+b/c&list-type=2&continuation-token=x%3D%3D")
+	expected := "continuation-token=x%3D%3D&list-type=2&prefix=a%20b%2Fc"
+	if got := canonicalQuery(u); got != expected {
+		t.Errorf("Expected canonical query %q, got %q", expected, got)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	// the get-vanilla case from the AWS Signature Version 4 test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := &Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	SignRequest(req, SHA256Hex(nil), creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := req.Header.Get("Authorization"); auth != expected {
+		t.Errorf("Unexpected signature\nExpected: %s\nGot:      %s", expected, auth)
+	}
+
+	// session tokens are sent and signed
+	creds.SessionToken = "session-token"
+	SignRequest(req, SHA256Hex(nil), creds, "us-east-1", "service", time.Now())
+	if req.Header.Get("X-Amz-Security-Token") != "session-token" {
+		t.Error("Expected session token header to be set")
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Error("Expected session token to be signed, got:", req.Header.Get("Authorization"))
+	}
+
+	// the content type and any other X-Amz headers are signed when they are sent
+	creds.SessionToken = ""
+	req, _ = http.NewRequest(http.MethodPut, "https://s3.amazonaws.com/bucket/key", nil)
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("X-Amz-Content-Sha256", UnsignedPayload)
+	SignRequest(req, UnsignedPayload, creds, "us-east-1", "s3", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20200101/us-east-1/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, ") {
+		t.Error("Unexpected authorization header:", auth)
+	}
+	if req.Header.Get("X-Amz-Date") != "20200101T000000Z" {
+		t.Error("Unexpected date header:", req.Header.Get("X-Amz-Date"))
+	}
+}
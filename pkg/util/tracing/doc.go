@@ -0,0 +1,3 @@
+// Package tracing contains a lightweight tracer that propagates W3C trace context
+// and exports spans to an OpenTelemetry collector using OTLP over HTTP.
+package tracing
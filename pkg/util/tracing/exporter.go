@@ -0,0 +1,250 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var tracingLogger = logf.Log.WithName("tracing")
+
+const (
+	// exportInterval is the longest spans are queued before being exported.
+	exportInterval = 5 * time.Second
+	// exportBatchSize is the number of queued spans that triggers an export.
+	exportBatchSize = 256
+	// maxQueueSize is the number of queued spans after which new ones are dropped,
+	// e.g. while the collector is unavailable.
+	maxQueueSize = 2048
+	// instrumentationScope is the name of the instrumentation reported with spans.
+	instrumentationScope = "github.com/tinyzimmer/kvdi"
+)
+
+// exporter batches ended spans and sends them to an OTLP/HTTP collector using the
+// JSON encoding.
+type exporter struct {
+	service  string
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	mux      sync.Mutex
+	queue    []*Span
+	inflight int
+	timer    *time.Timer
+}
+
+// newExporter returns an exporter sending spans for the given service to the
+// collector at the given endpoint. The traces path is added to the endpoint if
+// it is not already present.
+func newExporter(service, endpoint string, headers map[string]string) *exporter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	return &exporter{
+		service:  service,
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// export queues the given span. Spans are sent once enough have been queued, or
+// when the export interval passes.
+func (e *exporter) export(span *Span) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if len(e.queue)+e.inflight >= maxQueueSize {
+		return
+	}
+	e.queue = append(e.queue, span)
+	if len(e.queue) >= exportBatchSize {
+		go e.flush()
+		return
+	}
+	if e.timer == nil {
+		e.timer = time.AfterFunc(exportInterval, e.flush)
+	}
+}
+
+// flush sends all of the queued spans.
+func (e *exporter) flush() {
+	e.mux.Lock()
+	batch := e.queue
+	e.queue = nil
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.inflight += len(batch)
+	e.mux.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	if err := e.send(batch); err != nil {
+		tracingLogger.Error(err, "Failed to export spans", "Endpoint", e.endpoint, "Spans", len(batch))
+	}
+
+	e.mux.Lock()
+	e.inflight -= len(batch)
+	e.mux.Unlock()
+}
+
+// send posts the given spans to the collector.
+func (e *exporter) send(batch []*Span) error {
+	body, err := json.Marshal(e.buildRequest(batch))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, val := range e.headers {
+		req.Header.Set(key, val)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// The types below are the JSON encoding of an OTLP ExportTraceServiceRequest.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+// otlpStatusError is the status code of failed spans.
+const otlpStatusError = 2
+
+// buildRequest returns the export request for the given spans.
+func (e *exporter) buildRequest(batch []*Span) *otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		span.mux.Lock()
+		out := otlpSpan{
+			TraceID:           hex.EncodeToString(span.ctx.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.ctx.SpanID[:]),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        toAttributes(span.attributes),
+		}
+		if span.parentID != [8]byte{} {
+			out.ParentSpanID = hex.EncodeToString(span.parentID[:])
+		}
+		if span.errMsg != "" {
+			out.Status = otlpStatus{Code: otlpStatusError, Message: span.errMsg}
+		}
+		span.mux.Unlock()
+		spans = append(spans, out)
+	}
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: toAttributes(map[string]interface{}{"service.name": e.service}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: instrumentationScope},
+				Spans: spans,
+			}},
+		}},
+	}
+}
+
+// toAttributes converts the given attributes to their OTLP encoding, sorted by key.
+func toAttributes(attrs map[string]interface{}) []otlpAttribute {
+	out := make([]otlpAttribute, 0, len(attrs))
+	for key, val := range attrs {
+		var value otlpValue
+		switch v := val.(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		case int32:
+			s := strconv.FormatInt(int64(v), 10)
+			value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		out = append(out, otlpAttribute{Key: key, Value: value})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
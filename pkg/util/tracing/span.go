@@ -0,0 +1,191 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceParentHeader is the W3C header used for propagating trace context.
+const TraceParentHeader = "traceparent"
+
+// SpanKind describes the relationship of a span to its callers and callees.
+type SpanKind int
+
+// Span kinds as defined by OTLP.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// SpanContext identifies a span and whether its trace is being recorded.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid returns true if the span context identifies a span.
+func (s SpanContext) IsValid() bool {
+	return s.TraceID != [16]byte{} && s.SpanID != [8]byte{}
+}
+
+// TraceParent returns the span context formatted as a W3C traceparent header.
+func (s SpanContext) TraceParent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(s.TraceID[:]), hex.EncodeToString(s.SpanID[:]), flags)
+}
+
+// ParseTraceParent parses a W3C traceparent header. False is returned if it is
+// invalid.
+func ParseTraceParent(header string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// contextKey is the key the current span context is stored under in a context.
+type contextKey struct{}
+
+// spanKey is the key the current local span is stored under in a context.
+type spanKey struct{}
+
+// ContextWithRemoteParent returns a copy of the given context with the given span
+// context as the parent of spans started from it.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// SpanContextFromContext returns the context of the current span in the given
+// context. It is invalid if there is none.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(contextKey{}).(SpanContext)
+	return sc
+}
+
+// SpanFromContext returns the current span in the given context, or nil if it
+// was not started locally.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Extract returns a copy of the given context with the trace context in the
+// given headers as the parent of spans started from it.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceParent(header.Get(TraceParentHeader))
+	if !ok {
+		return ctx
+	}
+	return ContextWithRemoteParent(ctx, sc)
+}
+
+// Inject adds the current span in the given context to the given headers.
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceParentHeader, sc.TraceParent())
+	}
+}
+
+// Span represents a single operation in a trace. All methods are safe to call
+// on a nil Span.
+type Span struct {
+	tracer   *Tracer
+	name     string
+	kind     SpanKind
+	ctx      SpanContext
+	parentID [8]byte
+	start    time.Time
+
+	mux        sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	errMsg     string
+	ended      bool
+}
+
+// SpanContext returns the context identifying the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.ctx
+}
+
+// SetAttribute sets an attribute on the span. Values that are not strings, integers,
+// or booleans are formatted as strings.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil || !s.ctx.Sampled {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed with the given error. Nil errors are ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil || !s.ctx.Sampled {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.errMsg = err.Error()
+}
+
+// End completes the span and queues it for export. Only the first call has any
+// effect.
+func (s *Span) End() {
+	if s == nil || !s.ctx.Sampled {
+		return
+	}
+	s.mux.Lock()
+	if s.ended {
+		s.mux.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mux.Unlock()
+	s.tracer.exporter.export(s)
+}
+
+// newID fills the given buffer with a random, non-zero ID.
+func newID(buf []byte) {
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			panic(err)
+		}
+		for _, b := range buf {
+			if b != 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
+)
+
+// Tracer starts spans for a service and exports the sampled ones. All methods are
+// safe to call on a nil Tracer, which does not record anything.
+type Tracer struct {
+	// the number of new traces per sampled one, zero to only continue traces
+	sampleRate int32
+	exporter   *exporter
+}
+
+// New returns a tracer exporting spans for the given service to the OTLP/HTTP
+// collector at the given endpoint. One out of every sampleRate new traces are
+// sampled, traces continued from a remote parent follow its sampling decision.
+func New(service, endpoint string, headers map[string]string, sampleRate int32) *Tracer {
+	return &Tracer{
+		sampleRate: sampleRate,
+		exporter:   newExporter(service, endpoint, headers),
+	}
+}
+
+var (
+	tracers    = make(map[string]*Tracer)
+	tracersMux sync.Mutex
+)
+
+// ForConfig returns the tracer for the given service and configuration, creating
+// it the first time it is requested. Nil is returned if tracing is disabled.
+func ForConfig(service string, cfg *v1alpha1.TracingConfig) *Tracer {
+	if cfg == nil || !cfg.IsEnabled() {
+		return nil
+	}
+	headers := make([]string, 0, len(cfg.Headers))
+	for key, val := range cfg.Headers {
+		headers = append(headers, key+"="+val)
+	}
+	sort.Strings(headers)
+	key := fmt.Sprintf("%s|%s|%d|%s", service, cfg.Endpoint, cfg.GetSampleRate(), strings.Join(headers, ","))
+
+	tracersMux.Lock()
+	defer tracersMux.Unlock()
+	if tracer, ok := tracers[key]; ok {
+		return tracer
+	}
+	tracer := New(service, cfg.Endpoint, cfg.Headers, cfg.GetSampleRate())
+	tracers[key] = tracer
+	return tracer
+}
+
+// FromEnv returns a tracer for the given service using the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_EXPORTER_OTLP_HEADERS environment variables.
+// The tracer only continues traces from remote parents. Nil is returned if no
+// endpoint is set.
+func FromEnv(service string) *Tracer {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		return nil
+	}
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, _ := url.QueryUnescape(strings.TrimSpace(parts[0]))
+		val, _ := url.QueryUnescape(strings.TrimSpace(parts[1]))
+		headers[key] = val
+	}
+	return New(service, endpoint, headers, 0)
+}
+
+// StartOption configures a span when it is started.
+type StartOption func(*Span)
+
+// WithKind sets the kind of the span. Spans are internal by default.
+func WithKind(kind SpanKind) StartOption {
+	return func(s *Span) { s.kind = kind }
+}
+
+// WithStartTime sets the time the span started, instead of now.
+func WithStartTime(start time.Time) StartOption {
+	return func(s *Span) { s.start = start }
+}
+
+// Start starts a span with the given name as a child of the current span in the
+// given context, and returns a copy of the context with the new span as current.
+// The span must be ended by the caller.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       SpanKindInternal,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		span.ctx.TraceID = parent.TraceID
+		span.ctx.Sampled = parent.Sampled
+		span.parentID = parent.SpanID
+	} else {
+		newID(span.ctx.TraceID[:])
+		span.ctx.Sampled = t.sampleRate > 0 && rand.Int31n(t.sampleRate) == 0
+	}
+	newID(span.ctx.SpanID[:])
+	for _, opt := range opts {
+		opt(span)
+	}
+	ctx = context.WithValue(ctx, contextKey{}, span.ctx)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
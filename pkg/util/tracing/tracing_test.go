@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceParent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceParent(header)
+	if !ok {
+		t.Fatal("Expected traceparent to be valid")
+	}
+	if !sc.Sampled {
+		t.Error("Expected span context to be sampled")
+	}
+	if sc.TraceParent() != header {
+		t.Error("Expected traceparent to round trip, got", sc.TraceParent())
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceParent(invalid); ok {
+			t.Error("Expected traceparent to be invalid:", invalid)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	var nilTracer *Tracer
+	ctx, span := nilTracer.Start(context.TODO(), "test")
+	if span != nil || SpanContextFromContext(ctx).IsValid() {
+		t.Error("Expected a nil tracer to not start spans")
+	}
+	// methods on nil spans are no-ops
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("error"))
+	span.End()
+
+	tracer := &Tracer{sampleRate: 1}
+	ctx, parent := tracer.Start(context.TODO(), "parent")
+	if !parent.SpanContext().Sampled {
+		t.Error("Expected new trace to be sampled")
+	}
+	_, child := tracer.Start(ctx, "child")
+	if child.SpanContext().TraceID != parent.SpanContext().TraceID {
+		t.Error("Expected child to be in the same trace as its parent")
+	}
+	if child.parentID != parent.SpanContext().SpanID {
+		t.Error("Expected child to reference its parent")
+	}
+
+	// remote parents decide whether traces are sampled
+	header := http.Header{}
+	header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span = tracer.Start(Extract(context.TODO(), header), "remote")
+	if span.SpanContext().Sampled {
+		t.Error("Expected span to follow the remote sampling decision")
+	}
+
+	// tracers with no sample rate only continue remote traces
+	tracer = &Tracer{}
+	if _, span := tracer.Start(context.TODO(), "test"); span.SpanContext().Sampled {
+		t.Error("Expected new trace to not be sampled")
+	}
+
+	out := http.Header{}
+	Inject(ctx, out)
+	if out.Get(TraceParentHeader) != parent.SpanContext().TraceParent() {
+		t.Error("Expected current span to be injected, got", out.Get(TraceParentHeader))
+	}
+}
+
+func TestExporter(t *testing.T) {
+	requests := make(chan *otlpRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Error("Unexpected export path:", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Error("Expected configured headers to be sent")
+		}
+		req := &otlpRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			t.Error(err)
+		}
+		requests <- req
+	}))
+	defer srv.Close()
+
+	tracer := New("test-service", srv.URL, map[string]string{"Authorization": "Bearer token"}, 1)
+	_, span := tracer.Start(context.TODO(), "GET /api/test", WithKind(SpanKindServer))
+	span.SetAttribute("http.status_code", 500)
+	span.SetError(errors.New("500 Internal Server Error"))
+	span.End()
+	// only the first end is exported
+	span.End()
+	tracer.exporter.flush()
+
+	req := <-requests
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatal("Expected a single resource and scope")
+	}
+	if attrs := req.ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || *attrs[0].Value.StringValue != "test-service" {
+		t.Error("Expected the service name to be reported")
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name != "GET /api/test" || spans[0].Kind != SpanKindServer {
+		t.Error("Unexpected span:", spans[0].Name, spans[0].Kind)
+	}
+	if spans[0].Status.Code != otlpStatusError {
+		t.Error("Expected span to be marked as failed")
+	}
+	if len(spans[0].Attributes) != 1 || *spans[0].Attributes[0].Value.IntValue != "500" {
+		t.Error("Expected status code attribute to be encoded as an int")
+	}
+}
@@ -0,0 +1,72 @@
+package usbip
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Known USB class codes, by the names used in DesktopTemplates.
+var classCodes = map[string]uint8{
+	"audio":           0x01,
+	"communications":  0x02,
+	"hid":             0x03,
+	"image":           0x06,
+	"printer":         0x07,
+	"mass-storage":    0x08,
+	"smart-card":      0x0b,
+	"video":           0x0e,
+	"vendor-specific": 0xff,
+}
+
+// classPerInterface is the device class of devices whose class is defined by
+// each of their interfaces instead.
+const classPerInterface = 0x00
+
+// ClassName returns the name of the given class code, or its hex value if it is
+// not a known class.
+func ClassName(code uint8) string {
+	for name, c := range classCodes {
+		if c == code {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%02x", code)
+}
+
+// ParseClass returns the class code for the given name. Hex values, e.g. `0x0b`,
+// are also accepted.
+func ParseClass(name string) (uint8, error) {
+	if code, ok := classCodes[name]; ok {
+		return code, nil
+	}
+	if strings.HasPrefix(name, "0x") {
+		code, err := strconv.ParseUint(strings.TrimPrefix(name, "0x"), 16, 8)
+		if err == nil {
+			return uint8(code), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown USB device class '%s'", name)
+}
+
+// Allowlist is a set of USB classes that may be redirected.
+type Allowlist map[uint8]struct{}
+
+// NewAllowlist returns an Allowlist for the given class names.
+func NewAllowlist(names []string) (Allowlist, error) {
+	allowed := make(Allowlist, len(names))
+	for _, name := range names {
+		code, err := ParseClass(name)
+		if err != nil {
+			return nil, err
+		}
+		allowed[code] = struct{}{}
+	}
+	return allowed, nil
+}
+
+// Allows returns true if the given class is in the allowlist.
+func (a Allowlist) Allows(code uint8) bool {
+	_, ok := a[code]
+	return ok
+}
@@ -0,0 +1,5 @@
+// Package usbip contains utilities for redirecting USB devices from a client into
+// a desktop with the USB/IP protocol. The client exports devices with a USB/IP
+// server, and the kvdi-proxy imports them over a websocket and attaches them to
+// the vhci-hcd driver, checking the classes of the device against an allowlist.
+package usbip
@@ -0,0 +1,55 @@
+package usbip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Device classes that describe the device as a whole rather than its function.
+// Devices with these classes are checked by the classes of their interfaces only.
+var compositeClasses = map[uint8]struct{}{
+	classPerInterface: {},
+	0xef:              {}, // Miscellaneous, used with interface association descriptors
+}
+
+// Import requests the device with the given bus ID from the USB/IP server on
+// the other end of conn. An error is returned if the server refuses the import
+// or if the class of the device is not in the allowlist. On success the
+// connection is ready to carry commands for the device.
+func Import(conn io.ReadWriter, busID string, allowed Allowlist) (*Device, error) {
+	if len(busID) == 0 || len(busID) >= busIDSize {
+		return nil, fmt.Errorf("Invalid USB bus ID '%s'", busID)
+	}
+
+	req := make([]byte, 8+busIDSize)
+	binary.BigEndian.PutUint16(req[0:2], protocolVersion)
+	binary.BigEndian.PutUint16(req[2:4], opReqImport)
+	copy(req[8:], busID)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	var hdr opHeader
+	if err := binary.Read(conn, binary.BigEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if hdr.Code != opRepImport {
+		return nil, fmt.Errorf("Unexpected USB/IP reply code 0x%04x", hdr.Code)
+	}
+	if hdr.Status != 0 {
+		return nil, fmt.Errorf("USB/IP server refused to export %s, status %d", busID, hdr.Status)
+	}
+
+	dev := &Device{}
+	if err := binary.Read(conn, binary.BigEndian, dev); err != nil {
+		return nil, err
+	}
+	if dev.GetBusID() != busID {
+		return nil, fmt.Errorf("USB/IP server exported %s instead of %s", dev.GetBusID(), busID)
+	}
+	if _, ok := compositeClasses[dev.BDeviceClass]; !ok && !allowed.Allows(dev.BDeviceClass) {
+		return nil, fmt.Errorf("Device %s is not an allowed class", dev.String())
+	}
+	return dev, nil
+}
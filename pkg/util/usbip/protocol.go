@@ -0,0 +1,147 @@
+package usbip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// protocolVersion is the version of the USB/IP protocol implemented by this package.
+const protocolVersion = 0x0111
+
+// Operation codes used while importing a device.
+const (
+	opReqImport = 0x8003
+	opRepImport = 0x0003
+)
+
+// Commands used once a device is imported.
+const (
+	cmdSubmit = 0x00000001
+	cmdUnlink = 0x00000002
+	retSubmit = 0x00000003
+	retUnlink = 0x00000004
+)
+
+// The direction of a CMD_SUBMIT.
+const (
+	dirOut = 0
+	dirIn  = 1
+)
+
+// busIDSize is the size of the bus ID field in USB/IP messages.
+const busIDSize = 32
+
+// urbHeaderSize is the size of the header of every command sent once a device
+// is imported.
+const urbHeaderSize = 48
+
+// isoPacketDescriptorSize is the size of each isochronous packet descriptor
+// following the transfer buffer of a submit.
+const isoPacketDescriptorSize = 16
+
+// opHeader is the header of the messages sent while importing a device.
+type opHeader struct {
+	Version uint16
+	Code    uint16
+	Status  uint32
+}
+
+// Device is the description of an exported device sent by the USB/IP server.
+type Device struct {
+	Path                [256]byte
+	BusID               [busIDSize]byte
+	BusNum              uint32
+	DevNum              uint32
+	Speed               uint32
+	IDVendor            uint16
+	IDProduct           uint16
+	BCDDevice           uint16
+	BDeviceClass        uint8
+	BDeviceSubClass     uint8
+	BDeviceProtocol     uint8
+	BConfigurationValue uint8
+	BNumConfigurations  uint8
+	BNumInterfaces      uint8
+}
+
+// GetBusID returns the bus ID of the device.
+func (d *Device) GetBusID() string { return cString(d.BusID[:]) }
+
+// GetDevID returns the ID used to refer to the device when attaching it.
+func (d *Device) GetDevID() uint32 { return d.BusNum<<16 | d.DevNum }
+
+// String returns a description of the device for logging.
+func (d *Device) String() string {
+	return fmt.Sprintf("%s (%04x:%04x class %s)", d.GetBusID(), d.IDVendor, d.IDProduct, ClassName(d.BDeviceClass))
+}
+
+// urbHeader is the header of the commands sent once a device is imported. Only
+// the fields needed to follow the stream are decoded, the rest are kept as-is.
+type urbHeader struct {
+	raw [urbHeaderSize]byte
+}
+
+func (h *urbHeader) command() uint32   { return binary.BigEndian.Uint32(h.raw[0:4]) }
+func (h *urbHeader) seqnum() uint32    { return binary.BigEndian.Uint32(h.raw[4:8]) }
+func (h *urbHeader) direction() uint32 { return binary.BigEndian.Uint32(h.raw[12:16]) }
+
+// transferLength returns the transfer_buffer_length of a CMD_SUBMIT, or the
+// actual_length of a RET_SUBMIT.
+func (h *urbHeader) transferLength() int32 {
+	return int32(binary.BigEndian.Uint32(h.raw[24:28]))
+}
+
+// numberOfPackets returns the number of isochronous packets of a submit.
+func (h *urbHeader) numberOfPackets() int32 {
+	return int32(binary.BigEndian.Uint32(h.raw[32:36]))
+}
+
+// setup returns the setup packet of a CMD_SUBMIT.
+func (h *urbHeader) setup() []byte { return h.raw[40:48] }
+
+// readURB reads a command and its payload from the given reader. Whether the
+// command carries a transfer buffer is decided by the given function, since
+// replies do not include their direction.
+func readURB(r io.Reader, hasData func(*urbHeader) bool) (*urbHeader, []byte, error) {
+	hdr := &urbHeader{}
+	if _, err := io.ReadFull(r, hdr.raw[:]); err != nil {
+		return nil, nil, err
+	}
+	switch hdr.command() {
+	case cmdSubmit, retSubmit:
+	case cmdUnlink, retUnlink:
+		return hdr, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("Unknown USB/IP command 0x%08x", hdr.command())
+	}
+	var size int64
+	if hasData(hdr) {
+		if length := hdr.transferLength(); length > 0 {
+			size += int64(length)
+		}
+	}
+	if packets := hdr.numberOfPackets(); packets > 0 {
+		size += int64(packets) * isoPacketDescriptorSize
+	}
+	if size > maxTransferSize {
+		return nil, nil, fmt.Errorf("USB/IP transfer of %d bytes exceeds the maximum size", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+	return hdr, payload, nil
+}
+
+// maxTransferSize is the largest payload accepted for a single command.
+const maxTransferSize = 16 * 1024 * 1024
+
+// cString returns the string in the given NUL padded buffer.
+func cString(b []byte) string {
+	if idx := bytes.IndexByte(b, 0); idx >= 0 {
+		return string(b[:idx])
+	}
+	return string(b)
+}
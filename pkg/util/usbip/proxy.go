@@ -0,0 +1,148 @@
+package usbip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// USB descriptor constants used when inspecting configuration descriptors.
+const (
+	usbReqGetDescriptor    = 0x06
+	usbDirDeviceToHost     = 0x80
+	usbDescTypeConfig      = 0x02
+	usbDescTypeInterface   = 0x04
+	usbInterfaceClassIndex = 5
+)
+
+// pendingSubmit tracks a CMD_SUBMIT sent to the device until its reply arrives.
+type pendingSubmit struct {
+	direction    uint32
+	isConfigDesc bool
+}
+
+// Proxy relays commands between the vhci-hcd driver on the host and an imported
+// device. Configuration descriptors returned by the device are inspected, and the
+// connection is ended if any interface of the device is not an allowed class.
+// This keeps a composite device from exposing functions, e.g. a keyboard, that
+// its device class does not announce. Proxy returns when either side of the
+// connection ends, and the caller is responsible for closing both connections.
+func Proxy(device io.ReadWriter, host io.ReadWriter, allowed Allowlist) error {
+	var mux sync.Mutex
+	pending := make(map[uint32]*pendingSubmit)
+	unlinks := make(map[uint32]uint32)
+
+	errs := make(chan error, 2)
+
+	// Commands from the host
+	go func() {
+		for {
+			hdr, payload, err := readURB(host, func(h *urbHeader) bool {
+				return h.direction() == dirOut
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			mux.Lock()
+			switch hdr.command() {
+			case cmdSubmit:
+				pending[hdr.seqnum()] = &pendingSubmit{
+					direction:    hdr.direction(),
+					isConfigDesc: isGetConfigDescriptor(hdr.setup()),
+				}
+			case cmdUnlink:
+				unlinks[hdr.seqnum()] = binary.BigEndian.Uint32(hdr.raw[20:24])
+			}
+			mux.Unlock()
+			if err := writeURB(device, hdr, payload); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	// Replies from the device
+	go func() {
+		for {
+			var submit *pendingSubmit
+			hdr, payload, err := readURB(device, func(h *urbHeader) bool {
+				mux.Lock()
+				defer mux.Unlock()
+				submit = pending[h.seqnum()]
+				return submit != nil && submit.direction == dirIn
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			mux.Lock()
+			switch hdr.command() {
+			case retSubmit:
+				delete(pending, hdr.seqnum())
+			case retUnlink:
+				// A successful unlink means there will be no reply to the submit
+				if int32(binary.BigEndian.Uint32(hdr.raw[20:24])) != 0 {
+					delete(pending, unlinks[hdr.seqnum()])
+				}
+				delete(unlinks, hdr.seqnum())
+			}
+			mux.Unlock()
+			if submit != nil && submit.isConfigDesc {
+				if err := checkInterfaces(payload, hdr.transferLength(), allowed); err != nil {
+					errs <- err
+					return
+				}
+			}
+			if err := writeURB(host, hdr, payload); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	if err := <-errs; err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// writeURB writes the given command and its payload in a single call.
+func writeURB(w io.Writer, hdr *urbHeader, payload []byte) error {
+	_, err := w.Write(append(hdr.raw[:], payload...))
+	return err
+}
+
+// isGetConfigDescriptor returns true if the given setup packet requests a
+// configuration descriptor.
+func isGetConfigDescriptor(setup []byte) bool {
+	return setup[0] == usbDirDeviceToHost &&
+		setup[1] == usbReqGetDescriptor &&
+		setup[3] == usbDescTypeConfig
+}
+
+// checkInterfaces walks the descriptors in a configuration descriptor and
+// returns an error if any interface is not an allowed class.
+func checkInterfaces(payload []byte, length int32, allowed Allowlist) error {
+	if length <= 0 {
+		return nil
+	}
+	if int(length) < len(payload) {
+		payload = payload[:length]
+	}
+	for len(payload) >= 2 {
+		size := int(payload[0])
+		if size < 2 || size > len(payload) {
+			// Truncated, e.g. the host only asked for the header
+			return nil
+		}
+		if payload[1] == usbDescTypeInterface && size > usbInterfaceClassIndex {
+			if class := payload[usbInterfaceClassIndex]; !allowed.Allows(class) {
+				return fmt.Errorf("Device has an interface of class %s, which is not allowed", ClassName(class))
+			}
+		}
+		payload = payload[size:]
+	}
+	return nil
+}
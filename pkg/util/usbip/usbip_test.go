@@ -0,0 +1,183 @@
+package usbip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func newTestDevice(busID string, class uint8) *Device {
+	dev := &Device{BusNum: 1, DevNum: 4, Speed: 2, IDVendor: 0x072f, IDProduct: 0x90cc, BDeviceClass: class}
+	copy(dev.BusID[:], busID)
+	return dev
+}
+
+func serveImport(t *testing.T, conn net.Conn, dev *Device, status uint32) {
+	t.Helper()
+	req := make([]byte, 8+busIDSize)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		t.Error(err)
+		return
+	}
+	if code := binary.BigEndian.Uint16(req[2:4]); code != opReqImport {
+		t.Errorf("Expected import request, got 0x%04x", code)
+	}
+	if err := binary.Write(conn, binary.BigEndian, opHeader{Version: protocolVersion, Code: opRepImport, Status: status}); err != nil {
+		t.Error(err)
+		return
+	}
+	if status == 0 {
+		if err := binary.Write(conn, binary.BigEndian, dev); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	allowed, err := NewAllowlist([]string{"smart-card", "0xff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAllowlist([]string{"keyboard"}); err == nil {
+		t.Error("Expected error for unknown class")
+	}
+
+	tests := []struct {
+		name    string
+		dev     *Device
+		status  uint32
+		wantErr bool
+	}{
+		{"allowed class", newTestDevice("1-1", 0x0b), 0, false},
+		{"per-interface class", newTestDevice("1-1", 0x00), 0, false},
+		{"denied class", newTestDevice("1-1", 0x03), 0, true},
+		{"refused", newTestDevice("1-1", 0x0b), 1, true},
+		{"wrong device", newTestDevice("1-2", 0x0b), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			go serveImport(t, server, tt.dev, tt.status)
+			dev, err := Import(client, "1-1", allowed)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error importing device")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dev.GetDevID() != 1<<16|4 {
+				t.Error("Got wrong device ID:", dev.GetDevID())
+			}
+		})
+	}
+
+	if _, err := Import(&bytes.Buffer{}, "", allowed); err == nil {
+		t.Error("Expected error for empty bus ID")
+	}
+}
+
+func newSubmit(seqnum, direction uint32, length int32, setup []byte) *urbHeader {
+	hdr := &urbHeader{}
+	binary.BigEndian.PutUint32(hdr.raw[0:4], cmdSubmit)
+	binary.BigEndian.PutUint32(hdr.raw[4:8], seqnum)
+	binary.BigEndian.PutUint32(hdr.raw[12:16], direction)
+	binary.BigEndian.PutUint32(hdr.raw[24:28], uint32(length))
+	binary.BigEndian.PutUint32(hdr.raw[32:36], 0xffffffff)
+	copy(hdr.raw[40:48], setup)
+	return hdr
+}
+
+func newReply(seqnum uint32, length int32) *urbHeader {
+	hdr := &urbHeader{}
+	binary.BigEndian.PutUint32(hdr.raw[0:4], retSubmit)
+	binary.BigEndian.PutUint32(hdr.raw[4:8], seqnum)
+	binary.BigEndian.PutUint32(hdr.raw[24:28], uint32(length))
+	binary.BigEndian.PutUint32(hdr.raw[32:36], 0xffffffff)
+	return hdr
+}
+
+func configDescriptor(classes ...uint8) []byte {
+	desc := []byte{9, usbDescTypeConfig, 0, 0, byte(len(classes)), 1, 0, 0x80, 50}
+	for i, class := range classes {
+		desc = append(desc, 9, usbDescTypeInterface, byte(i), 0, 1, class, 0, 0, 0)
+	}
+	binary.LittleEndian.PutUint16(desc[2:4], uint16(len(desc)))
+	return desc
+}
+
+func TestProxy(t *testing.T) {
+	allowed, _ := NewAllowlist([]string{"smart-card"})
+	getConfig := []byte{usbDirDeviceToHost, usbReqGetDescriptor, 0, usbDescTypeConfig, 0, 0, 0xff, 0}
+
+	tests := []struct {
+		name    string
+		classes []uint8
+		wantErr bool
+	}{
+		{"allowed interfaces", []uint8{0x0b}, false},
+		{"denied interface", []uint8{0x0b, 0x03}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, hostProxy := net.Pipe()
+			device, deviceProxy := net.Pipe()
+			errs := make(chan error, 1)
+			go func() { errs <- Proxy(deviceProxy, hostProxy, allowed) }()
+
+			// An OUT transfer is forwarded with its data
+			go func() {
+				writeURB(host, newSubmit(1, dirOut, 4, nil), []byte{1, 2, 3, 4})
+				writeURB(host, newSubmit(2, dirIn, 255, getConfig), nil)
+			}()
+			hdr, payload, err := readURB(device, func(h *urbHeader) bool { return h.direction() == dirOut })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hdr.seqnum() != 1 || !bytes.Equal(payload, []byte{1, 2, 3, 4}) {
+				t.Fatal("Got wrong OUT transfer:", hdr.seqnum(), payload)
+			}
+			if hdr, _, err = readURB(device, func(h *urbHeader) bool { return h.direction() == dirOut }); err != nil {
+				t.Fatal(err)
+			} else if hdr.seqnum() != 2 {
+				t.Fatal("Got wrong seqnum for descriptor request:", hdr.seqnum())
+			}
+
+			// Reply to the OUT transfer carries no data, the descriptor reply does
+			desc := configDescriptor(tt.classes...)
+			go func() {
+				writeURB(device, newReply(1, 4), nil)
+				writeURB(device, newReply(2, int32(len(desc))), desc)
+			}()
+			if hdr, _, err = readURB(host, func(*urbHeader) bool { return false }); err != nil {
+				t.Fatal(err)
+			} else if hdr.seqnum() != 1 {
+				t.Fatal("Got wrong seqnum for OUT reply:", hdr.seqnum())
+			}
+
+			if tt.wantErr {
+				if err := <-errs; err == nil {
+					t.Error("Expected proxy to end with an error")
+				}
+			} else {
+				hdr, payload, err := readURB(host, func(*urbHeader) bool { return true })
+				if err != nil {
+					t.Fatal(err)
+				}
+				if hdr.seqnum() != 2 || !bytes.Equal(payload, desc) {
+					t.Error("Got wrong descriptor reply:", hdr.seqnum(), payload)
+				}
+			}
+			host.Close()
+			device.Close()
+			hostProxy.Close()
+			deviceProxy.Close()
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package usbip
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// vhciPath is the sysfs directory of the vhci-hcd driver. The vhci-hcd kernel
+// module must be loaded on the host.
+var vhciPath = "/sys/devices/platform/vhci_hcd.0"
+
+// Device speeds as reported by the USB/IP server.
+const (
+	speedSuper     = 5
+	speedSuperPlus = 6
+)
+
+// vdevStatusNull is the status of a free port on the vhci-hcd driver.
+const vdevStatusNull = 4
+
+// Attach attaches the given imported device to a free port on the vhci-hcd
+// driver. Commands from the driver can be read from, and replies written to, the
+// returned connection. Detach should be called with the returned port when the
+// device is no longer needed.
+func Attach(dev *Device) (int, net.Conn, error) {
+	port, err := freePort(dev.Speed)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	// The driver holds its own reference to the socket once attached.
+	defer syscall.Close(fds[0])
+
+	attach := fmt.Sprintf("%d %d %d %d", port, fds[0], dev.GetDevID(), dev.Speed)
+	if err := ioutil.WriteFile(filepath.Join(vhciPath, "attach"), []byte(attach), 0200); err != nil {
+		syscall.Close(fds[1])
+		return 0, nil, err
+	}
+
+	f := os.NewFile(uintptr(fds[1]), "usbip")
+	defer f.Close()
+	conn, err := net.FileConn(f)
+	if err != nil {
+		Detach(port)
+		return 0, nil, err
+	}
+	return port, conn, nil
+}
+
+// Detach removes the device on the given port from the vhci-hcd driver.
+func Detach(port int) error {
+	return ioutil.WriteFile(filepath.Join(vhciPath, "detach"), []byte(strconv.Itoa(port)), 0200)
+}
+
+// freePort returns a free port on the vhci-hcd driver for a device of the given
+// speed. SuperSpeed devices must use a port on the SuperSpeed hub.
+func freePort(speed uint32) (int, error) {
+	hub := "hs"
+	if speed == speedSuper || speed == speedSuperPlus {
+		hub = "ss"
+	}
+	f, err := os.Open(filepath.Join(vhciPath, "status"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hub port sta spd dev sockfd local_busid
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != hub {
+			continue
+		}
+		status, err := strconv.Atoi(fields[2])
+		if err != nil || status != vdevStatusNull {
+			continue
+		}
+		port, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		return port, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("No free %s ports on the vhci-hcd driver", hub)
+}
@@ -1,24 +1,65 @@
-// Package user is a new packager where user related utilities will be migrated to.
 package user
 
 import (
+	"fmt"
+
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
 	v1 "github.com/tinyzimmer/kvdi/pkg/apis/meta/v1"
 )
 
 // FilterTemplates will take a list of DesktopTemplates and filter them based
-// off which ones the user is allowed to use.
-func FilterTemplates(u *v1.VDIUser, tmpls []v1alpha1.DesktopTemplate) []v1alpha1.DesktopTemplate {
+// off which ones the user is allowed to launch. When a namespace is given, only
+// templates the user can launch in that namespace are returned. Otherwise,
+// templates restricted to specific namespaces are returned if the user can
+// launch them in any of those namespaces.
+func FilterTemplates(u *v1.VDIUser, tmpls []v1alpha1.DesktopTemplate, namespace string) []v1alpha1.DesktopTemplate {
+	rawTemplates := make(map[string]*v1alpha1.DesktopTemplate, len(tmpls))
+	for i := range tmpls {
+		rawTemplates[tmpls[i].GetName()] = &tmpls[i]
+	}
+	lookup := func(name string) (*v1alpha1.DesktopTemplate, error) {
+		if tmpl, ok := rawTemplates[name]; ok {
+			return tmpl, nil
+		}
+		return nil, fmt.Errorf("DesktopTemplate %s not found", name)
+	}
+
 	filtered := make([]v1alpha1.DesktopTemplate, 0)
-	for _, tmpl := range tmpls {
-		action := &v1.APIAction{
-			Verb:         v1.VerbLaunch,
-			ResourceType: v1.ResourceTemplates,
-			ResourceName: tmpl.GetName(),
+	for i := range tmpls {
+		// the namespaces a template allows may be inherited from its base
+		effective, err := tmpls[i].Resolve(lookup)
+		if err != nil {
+			effective = &tmpls[i]
+		}
+		namespaces := effective.Spec.Namespaces
+		if namespace != "" {
+			if !effective.AllowsNamespace(namespace) {
+				continue
+			}
+			namespaces = []string{namespace}
 		}
-		if u.Evaluate(action) {
-			filtered = append(filtered, tmpl)
+		if canLaunch(u, tmpls[i].GetName(), namespaces) {
+			filtered = append(filtered, tmpls[i])
 		}
 	}
 	return filtered
 }
+
+// canLaunch returns true if the user can launch the given template in any of the
+// given namespaces. If there are none, the namespace is not considered.
+func canLaunch(u *v1.VDIUser, template string, namespaces []string) bool {
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	for _, ns := range namespaces {
+		if u.Evaluate(&v1.APIAction{
+			Verb:              v1.VerbLaunch,
+			ResourceType:      v1.ResourceTemplates,
+			ResourceName:      template,
+			ResourceNamespace: ns,
+		}) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/tinyzimmer/kvdi/pkg/apis/kvdi/v1alpha1"
@@ -39,7 +40,7 @@ var testUser = &v1.VDIUser{
 }
 
 func TestFilterTemplates(t *testing.T) {
-	allowedTemplates := FilterTemplates(testUser, testTemplates)
+	allowedTemplates := FilterTemplates(testUser, testTemplates, "")
 	if len(allowedTemplates) != 1 {
 		t.Fatalf("Expected only one allowed template, got: %d", len(allowedTemplates))
 	}
@@ -47,3 +48,55 @@ func TestFilterTemplates(t *testing.T) {
 		t.Errorf("Expected 'test-template' allowed, got: %s", allowedTemplates[0].GetName())
 	}
 }
+
+func TestFilterTemplatesByNamespace(t *testing.T) {
+	tmpls := []v1alpha1.DesktopTemplate{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "any-namespace"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a-only"},
+			Spec:       v1alpha1.DesktopTemplateSpec{Namespaces: []string{"team-a"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-b-only"},
+			Spec:       v1alpha1.DesktopTemplateSpec{Namespaces: []string{"team-b"}},
+		},
+		{
+			// namespaces are inherited from the base template
+			ObjectMeta: metav1.ObjectMeta{Name: "team-b-child"},
+			Spec:       v1alpha1.DesktopTemplateSpec{BaseTemplate: "team-b-only"},
+		},
+	}
+	user := &v1.VDIUser{
+		Roles: []*v1.VDIUserRole{
+			{
+				Rules: []v1.Rule{
+					{
+						Verbs:            []v1.Verb{v1.VerbLaunch},
+						Resources:        []v1.Resource{v1.ResourceTemplates},
+						ResourcePatterns: []string{".*"},
+						Namespaces:       []string{"team-a"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		namespace string
+		expected  []string
+	}{
+		{"", []string{"any-namespace", "team-a-only"}},
+		{"team-a", []string{"any-namespace", "team-a-only"}},
+		{"team-b", []string{}},
+	} {
+		names := make([]string, 0)
+		for _, tmpl := range FilterTemplates(user, tmpls, tc.namespace) {
+			names = append(names, tmpl.GetName())
+		}
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Errorf("Expected %v for namespace %q, got: %v", tc.expected, tc.namespace, names)
+		}
+	}
+}
@@ -1,4 +1,5 @@
 import RFB from '@novnc/novnc/core/rfb.js'
+import Guacamole from 'guacamole-common-js'
 import AudioManager from './audioManager.js'
 
 // DisplayManager handles display and audio connections to remote desktop sessions.
@@ -6,12 +7,13 @@ export default class DisplayManager {
     // Builds the DisplayManager instance. The userStore and sessionStore are Vuex
     // Store instances that reflect the currently logged in user and the current desktop
     // sessions respectively.
-    constructor ({ userStore, sessionStore, onError, onStatusUpdate, onDisconnect, onConnect }) {
+    constructor ({ userStore, sessionStore, onError, onWarning, onStatusUpdate, onDisconnect, onConnect }) {
         // Vuex stores
         this._userStore = userStore
         this._sessionStore = sessionStore
         // Event listeners - I am sure there is a more correct way to do this
         this._errCb = onError
+        this._warnCb = onWarning
         this._disconnectCb = onDisconnect
         this._connectCb = onConnect
         this._statusCb = onStatusUpdate
@@ -21,12 +23,25 @@ export default class DisplayManager {
         this._currentSession = this._getActiveSession()
         // A socket being used to query a desktop's boot status
         this._statusSocket = null
+        // A socket following the status of a connected desktop for disk usage warnings
+        this._followSocket = null
+        // A socket receiving documents printed in a connected desktop
+        this._printerSocket = null
         // Status text to display to a user when a connection is pending
         this._statusText = ''
         // The RFB client for noVNC connections
         this._rfbClient = null
+        // Removes the hidden input used for input method composition, if attached
+        this._detachIMEInput = null
+        // The Guacamole client for RDP connections
+        this._guacClient = null
+        // Set when the Guacamole client reports an error before disconnecting
+        this._guacError = false
         // The audio player for streaming playback
         this._audioManager = null
+        // A token identifying this viewer's display sessions, so that a dropped
+        // connection can resume the existing session if the template allows it
+        this._resumeToken = newResumeToken()
         // Subscribe to changes to desktop sessions
         this._unsubscribeSessions = this._sessionStore.subscribe((mutation) => {
             this._handleSessionChange(mutation)
@@ -45,6 +60,9 @@ export default class DisplayManager {
     // _callError will call the onError callback if configured.
     _callError (err) { if (this._errCb) { this._errCb(err) } }
 
+    // _callWarning will call the onWarning callback if configured.
+    _callWarning (msg) { if (this._warnCb) { this._warnCb(msg) } }
+
     // _getActiveSession returns the session currently marked as active in the session store.
     _getActiveSession () {
         return this._sessionStore.getters.activeSession
@@ -232,7 +250,23 @@ export default class DisplayManager {
 
             // Update the status text for the user
             let statusText = `Waiting for ${activeSession.namespace}/${activeSession.name}`
-            if (msgCount > 6) {
+            if (st.podError) {
+                statusText = `${activeSession.namespace}/${activeSession.name} failed to start:`
+                statusText += `\n${st.podError}`
+            } else if (st.preempted) {
+                statusText = `The node running ${activeSession.namespace}/${activeSession.name} is being reclaimed.`
+                statusText += '\nRelaunching the desktop on stable capacity...'
+            } else if (st.drained) {
+                statusText = `The node running ${activeSession.namespace}/${activeSession.name} is under maintenance.`
+                statusText += '\nRelaunching the desktop on another node...'
+            } else if (st.phase === 'Scheduling') {
+                statusText = `Scheduling ${activeSession.namespace}/${activeSession.name}`
+            } else if (st.phase === 'PullingImage') {
+                statusText = `Pulling the image for ${activeSession.namespace}/${activeSession.name}`
+                statusText += '\nThis can take a while the first time a template is launched on a node.'
+            } else if (st.phase === 'BootingDisplay') {
+                statusText = `Booting the display for ${activeSession.namespace}/${activeSession.name}`
+            } else if (msgCount > 6) {
                 statusText += '\n\nThis is taking a while. The server might be pulling the'
                 statusText += '\nimage for the first time, or the control-plane is having'
                 statusText += '\ntrouble scheduling the desktop instance.'
@@ -257,24 +291,29 @@ export default class DisplayManager {
     }
 
     // _createConnection will create a new RFB connection if the socketType
-    // is xvnc. xpra sockets use the official client embedded in an iframe.
+    // is xvnc, or a Guacamole connection if it is rdp. xpra sockets use the
+    // official client embedded in an iframe.
     async _createConnection () {
-        if (this._currentSession.socketType !== 'xvnc') {
+        const socketType = this._currentSession.socketType
+        if (socketType !== 'xvnc' && socketType !== 'rdp') {
             // xpra sockets are handled via an iframe currently
             this._callConnect()
             return
         }
-        // get the websocket display address
         const urls = this._getSessionURLs()
-        const displayURL = urls.displayURL()
         // get the view port for the display
         const view = document.getElementById('view')
         if (view === null || view === undefined) {
             return
         }
         try {
-            // create a vnc connection
-            await this._createRFBConnection(view, displayURL)
+            if (socketType === 'rdp') {
+                // create a guacamole connection
+                await this._createGuacConnection(view, urls)
+            } else {
+                // create a vnc connection
+                await this._createRFBConnection(view, urls.displayURL(this._resumeToken, detectKeyboardLayout()))
+            }
         } catch (err) {
             this._callDisconnect()
             this._callError(err)
@@ -293,6 +332,110 @@ export default class DisplayManager {
         this._rfbClient.addEventListener('clipboard', (ev) => { this._handleRecvClipboard(ev) })
         this._rfbClient.resizeSession = true
         this._rfbClient.scaleViewport = true
+        this._attachIMEInput(view)
+    }
+
+    // _attachIMEInput passes text composed with an input method through to the
+    // desktop. The noVNC canvas cannot receive composition events, so when a key
+    // is pressed while an input method is active, focus moves to a hidden textarea
+    // until the composition ends.
+    _attachIMEInput (view) {
+        this._removeIMEInput()
+        const input = document.createElement('textarea')
+        input.setAttribute('autocomplete', 'off')
+        input.style.cssText = 'position: absolute; opacity: 0; width: 1px; height: 1px; pointer-events: none'
+        view.appendChild(input)
+        const onKeyDown = (ev) => {
+            // browsers report a keyCode of 229 for keys handled by an input method
+            if (ev.isComposing || ev.keyCode === 229) {
+                input.focus()
+            }
+        }
+        view.addEventListener('keydown', onKeyDown, true)
+        input.addEventListener('compositionend', (ev) => {
+            if (ev.data) {
+                this._sendTextInput(ev.data)
+            }
+            input.value = ''
+            if (this._rfbClient) {
+                this._rfbClient.focus()
+            }
+        })
+        this._detachIMEInput = () => {
+            view.removeEventListener('keydown', onKeyDown, true)
+            if (input.parentNode) {
+                input.parentNode.removeChild(input)
+            }
+        }
+    }
+
+    // _removeIMEInput removes the hidden textarea used for input method composition.
+    _removeIMEInput () {
+        if (this._detachIMEInput) {
+            this._detachIMEInput()
+            this._detachIMEInput = null
+        }
+    }
+
+    // _sendTextInput sends text to the desktop in a text input message, which the
+    // kvdi-proxy types into the display as key events.
+    _sendTextInput (text) {
+        if (!this._rfbClient) { return }
+        const data = new TextEncoder().encode(text)
+        const msg = new Uint8Array(8 + data.length)
+        msg[0] = textInputMessageType
+        new DataView(msg.buffer).setUint32(4, data.length)
+        msg.set(data, 8)
+        this._rfbClient._sock.send(msg)
+    }
+
+    // _createGuacConnection creates a new Guacamole connection to an RDP desktop.
+    async _createGuacConnection (view, urls) {
+        if (this._guacClient) { return }
+        const client = new Guacamole.Client(new Guacamole.WebSocketTunnel(urls.rdpTunnelURL()))
+        const display = client.getDisplay().getElement()
+        view.appendChild(display)
+
+        client.onstatechange = (state) => {
+            if (state === Guacamole.Client.State.CONNECTED) {
+                this._connectedToRFBServer()
+            } else if (state === Guacamole.Client.State.DISCONNECTED) {
+                this._disconnectedFromGuacServer(display)
+            }
+        }
+        client.onerror = (status) => {
+            this._guacError = true
+            this._callError(new Error(`RDP connection error: ${status.message}`))
+        }
+        client.onclipboard = (stream, mimetype) => {
+            if (!mimetype.startsWith('text/')) { return }
+            const reader = new Guacamole.StringReader(stream)
+            let text = ''
+            reader.ontext = (data) => { text += data }
+            reader.onend = () => { this._handleRecvClipboard({ detail: { text: text } }) }
+        }
+
+        const mouse = new Guacamole.Mouse(display)
+        mouse.onmousedown = mouse.onmouseup = mouse.onmousemove = (state) => {
+            client.sendMouseState(state)
+        }
+        const keyboard = new Guacamole.Keyboard(view)
+        keyboard.onkeydown = (keysym) => { client.sendKeyEvent(1, keysym) }
+        keyboard.onkeyup = (keysym) => { client.sendKeyEvent(0, keysym) }
+
+        this._guacError = false
+        this._guacClient = client
+        const dpi = Math.round(96 * (window.devicePixelRatio || 1))
+        client.connect(urls.rdpConnectArgs(view.clientWidth, view.clientHeight, dpi))
+    }
+
+    // _disconnectedFromGuacServer is called when a Guacamole connection is closed.
+    _disconnectedFromGuacServer (display) {
+        this._guacClient = null
+        if (display.parentNode) {
+            display.parentNode.removeChild(display)
+        }
+        this._disconnectedFromRFBServer({ detail: { clean: !this._guacError } })
     }
 
     // _handleRecvClipboard is called when the RFB connection sends clipboard data
@@ -315,6 +458,140 @@ export default class DisplayManager {
     _connectedToRFBServer () {
         console.log('Connected to display server!')
         this._currentSession = this._getActiveSession()
+        this._doFollowWebsocket()
+        if (this._currentSession.printing) {
+            this._doPrinterWebsocket()
+        }
+    }
+
+    // _doFollowWebsocket opens a websocket connection that follows the status of the
+    // connected desktop session and warns the user when it is running low on disk
+    // or is about to be stopped.
+    _doFollowWebsocket () {
+        this._closeFollowWebsocket()
+
+        const urls = this._getSessionURLs()
+        const socket = new WebSocket(`${urls.statusURL()}&follow=true`)
+
+        let warned = false
+        let drainWarned = false
+        let terminationWarned = false
+        socket.onmessage = (event) => {
+            const st = JSON.parse(event.data)
+            if (st.error) { return }
+            if (st.drain && !drainWarned) {
+                drainWarned = true
+                const deadline = new Date(st.drain.deadline).toLocaleTimeString()
+                let msg = 'The node running your desktop is going under maintenance.'
+                if (st.drain.action === 'terminate') {
+                    msg += ` Your desktop will be stopped at ${deadline}, please save your work.`
+                } else {
+                    msg += ` Your desktop will be restarted on another node at ${deadline}, please save your work.`
+                }
+                if (st.drain.message) {
+                    msg += ` ${st.drain.message}`
+                }
+                this._callWarning(msg)
+            }
+            if (st.terminationPending && !terminationWarned) {
+                terminationWarned = true
+                const deadline = new Date(st.terminatesAt).toLocaleTimeString()
+                let msg = `Your desktop will be stopped at ${deadline}, please save your work.`
+                if (st.terminationReason === 'MaxLifetime') {
+                    msg = `Your desktop has almost reached its maximum lifetime. ${msg}`
+                } else if (st.terminationReason === 'AvailabilityWindow') {
+                    msg = `Your desktop's template is about to become unavailable. ${msg}`
+                }
+                this._callWarning(msg)
+            }
+            if (st.diskPressure && !warned) {
+                warned = true
+                let msg = 'Your desktop is running low on disk space and may be stopped.'
+                if (st.diskLimitBytes) {
+                    const usedMiB = Math.round(st.diskUsedBytes / 1048576)
+                    const limitMiB = Math.round(st.diskLimitBytes / 1048576)
+                    msg += ` ${usedMiB}MiB of ${limitMiB}MiB is in use.`
+                }
+                this._callWarning(msg)
+            } else if (!st.diskPressure) {
+                warned = false
+            }
+        }
+
+        this._followSocket = socket
+    }
+
+    // _doPrinterWebsocket opens a websocket connection that receives documents printed
+    // in the connected desktop session and prints them locally. Each job is announced
+    // in a text frame and followed by the PDF in a binary frame.
+    _doPrinterWebsocket () {
+        this._closePrinterWebsocket()
+
+        const urls = this._getSessionURLs()
+        const socket = new WebSocket(urls.printerURL())
+        socket.binaryType = 'blob'
+
+        let job = null
+        socket.onmessage = (event) => {
+            if (typeof event.data === 'string') {
+                job = JSON.parse(event.data)
+                return
+            }
+            const name = job ? job.name : 'document.pdf'
+            job = null
+            this._printDocument(name, new Blob([event.data], { type: 'application/pdf' }))
+        }
+
+        this._printerSocket = socket
+    }
+
+    // _printDocument opens the print dialog of the browser for the given PDF.
+    _printDocument (name, blob) {
+        console.log(`Printing ${name}`)
+        const url = URL.createObjectURL(blob)
+        const frame = document.createElement('iframe')
+        frame.style.display = 'none'
+        frame.src = url
+        frame.onload = () => {
+            try {
+                frame.contentWindow.focus()
+                frame.contentWindow.print()
+            } catch (err) {
+                // fall back to letting the browser's PDF viewer handle it
+                window.open(url, '_blank')
+            }
+            setTimeout(() => {
+                document.body.removeChild(frame)
+                URL.revokeObjectURL(url)
+            }, 60000)
+        }
+        document.body.appendChild(frame)
+    }
+
+    // _closePrinterWebsocket closes the printer socket if it is open.
+    _closePrinterWebsocket () {
+        if (this._printerSocket) {
+            try {
+                this._printerSocket.close()
+            } catch (err) {
+                console.log(err)
+            } finally {
+                this._printerSocket = null
+            }
+        }
+    }
+
+    // _closeFollowWebsocket closes the status follow socket if it is open.
+    _closeFollowWebsocket () {
+        if (this._followSocket) {
+            try {
+                this._followSocket.close()
+            } catch (err) {
+                console.log(err)
+            } finally {
+                this._followSocket = null
+            }
+        }
     }
 
     // _disconnectedFromRFBServer is called when the connection is dropped to a
@@ -323,6 +600,9 @@ export default class DisplayManager {
         if (this._rfbClient) {
             this._rfbClient = null
         }
+        this._removeIMEInput()
+        this._closeFollowWebsocket()
+        this._closePrinterWebsocket()
         this._callDisconnect()
 
         if (event.detail.clean) {
@@ -332,7 +612,11 @@ export default class DisplayManager {
                 try {
                     // check if the desktop still exists, if we get an error back
                     // it was deleted.
-                    await this._sessionStore.getters.sessionStatus(this._currentSession)
+                    const st = await this._sessionStore.getters.sessionStatus(this._currentSession)
+                    // if the desktop was preempted or drained wait for it to be relaunched
+                    if ((st.preempted || st.drained) && !this._statusIsReady(st)) {
+                        this._doStatusWebsocket()
+                    }
                 } catch {
                     this._sessionStore.dispatch('deleteSession', this._currentSession)
                     this._currentSession = null
@@ -364,6 +648,17 @@ export default class DisplayManager {
 
     // _disconnect will close any connections currently open
     _disconnect () {
+        if (this._guacClient) {
+            try {
+                // _disconnectedFromGuacServer will call the disconnect callback
+                this._guacClient.disconnect()
+            } catch (err) {
+                console.log(err)
+            } finally {
+                this._guacClient = null
+            }
+            return
+        }
         if (this._rfbClient) {
             try {
                 // _disconnectedFromRFBServer will call the disconnect callback
@@ -421,6 +716,12 @@ export default class DisplayManager {
     // syncClipboardData syncs the provied data to the clipboard inside the currently
     // active RFB connection.
     syncClipboardData (data) {
+        if (this._guacClient) {
+            const writer = new Guacamole.StringWriter(this._guacClient.createClipboardStream('text/plain'))
+            writer.sendText(data)
+            writer.sendEnd()
+            return
+        }
         if (!this._rfbClient) {
             return
         }
@@ -438,6 +739,60 @@ export default class DisplayManager {
     }
 }
 
+// textInputMessageType is the RFB client message type the kvdi-proxy accepts text
+// composed with an input method in.
+const textInputMessageType = 200
+
+// keyboardLayouts maps browser languages to XKB keyboard layouts. Languages with a
+// region are checked before the language alone.
+const keyboardLayouts = {
+    'en-gb': 'gb',
+    'en-ie': 'ie',
+    'fr-ca': 'ca',
+    'fr-be': 'be',
+    'nl-be': 'be',
+    'fr-ch': 'ch',
+    'de-ch': 'ch',
+    'pt-br': 'br',
+    en: 'us',
+    de: 'de',
+    fr: 'fr',
+    es: 'es',
+    it: 'it',
+    pt: 'pt',
+    nl: 'nl',
+    sv: 'se',
+    da: 'dk',
+    nb: 'no',
+    no: 'no',
+    fi: 'fi',
+    pl: 'pl',
+    cs: 'cz',
+    hu: 'hu',
+    tr: 'tr',
+    el: 'gr',
+    ru: 'ru',
+    uk: 'ua',
+    he: 'il',
+    ja: 'jp',
+    ko: 'kr',
+    zh: 'cn'
+}
+
+// detectKeyboardLayout returns the XKB layout for the browser's language, or an
+// empty string if it is not known.
+function detectKeyboardLayout () {
+    const lang = (navigator.language || '').toLowerCase()
+    return keyboardLayouts[lang] || keyboardLayouts[lang.split('-')[0]] || ''
+}
+
+// newResumeToken returns a random token for resuming display sessions.
+function newResumeToken () {
+    const buf = new Uint8Array(16)
+    window.crypto.getRandomValues(buf)
+    return Array.from(buf, (b) => b.toString(16).padStart(2, '0')).join('')
+}
+
 // DesktopAddressGetter is a convenience wrapper around retrieving connection
 // URLs for a given desktop instance.
 export class DesktopAddressGetter {
@@ -459,15 +814,43 @@ export class DesktopAddressGetter {
       return `${window.location.origin.replace('http', 'ws')}/api/desktops/ws/${this.namespace}/${this.name}/${endpoint}?token=${this._getToken()}`
     }
   
-    // displayURL returns the websocket address for display connections.
-    displayURL () {
-      return this._buildAddress('display')
+    // displayURL returns the websocket address for display connections. If a
+    // resume token is given, it is passed along so dropped connections can resume.
+    // If a keyboard layout is given, it is set on the display unless the user has
+    // configured their own.
+    displayURL (resumeToken, keyboardLayout) {
+      let addr = this._buildAddress('display')
+      if (resumeToken) {
+        addr = `${addr}&resume=${resumeToken}`
+      }
+      if (keyboardLayout) {
+        addr = `${addr}&keyboardLayout=${keyboardLayout}`
+      }
+      return addr
     }
   
+    // rdpTunnelURL returns the websocket address for Guacamole tunnels to RDP
+    // desktops. The tunnel appends its own query string when connecting, so the
+    // token is passed with rdpConnectArgs instead.
+    rdpTunnelURL () {
+      return `${window.location.origin.replace('http', 'ws')}/api/desktops/ws/${this.namespace}/${this.name}/display`
+    }
+
+    // rdpConnectArgs returns the query string for connecting a Guacamole tunnel
+    // with the given display size.
+    rdpConnectArgs (width, height, dpi) {
+      return `token=${this._getToken()}&width=${width}&height=${height}&dpi=${dpi}`
+    }
+
     // audioURL returns the websocket address for audio connections.
     audioURL () {
       return this._buildAddress('audio')
     }
+
+    // printerURL returns the websocket address for receiving print jobs.
+    printerURL () {
+      return this._buildAddress('printer')
+    }
   
     // statusURL returns the websocket address for querying desktop status.
     statusURL () {
@@ -55,6 +55,12 @@ export const ConfigStore = new Vuex.Store({
         if (state.serverConfig.auth.oidcAuth !== undefined && state.serverConfig.auth.oidcAuth.IssuerURL) {
           return 'oidc'
         }
+        if (state.serverConfig.auth.certAuth !== undefined && state.serverConfig.auth.certAuth.clientCACert) {
+          return 'cert'
+        }
+        if (state.serverConfig.auth.webhookAuth !== undefined && state.serverConfig.auth.webhookAuth.url) {
+          return 'webhook'
+        }
       }
       return 'local'
     }
@@ -77,6 +77,8 @@ export const DesktopSessions = new Vuex.Store({
         } else {
           session.data.socketType = 'xvnc'
         }
+        // deliver documents printed in the desktop when the template allows it
+        session.data.printing = template.spec.config.allowPrinting === true
         commit('new_session', session.data)
         commit('set_active_session', session.data)
       } catch (err) {
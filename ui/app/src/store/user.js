@@ -21,6 +21,8 @@ export const UserStore = new Vuex.Store({
     token: localStorage.getItem('token') || '',
     renewable: localStorage.getItem('renewable') === 'true' || false,
     requiresMFA: false,
+    mfaMethod: 'totp',
+    mfaEnrollmentRequired: false,
     user: {},
     stateToken: '',
     timeout: null
@@ -48,14 +50,17 @@ export const UserStore = new Vuex.Store({
       state.token = token
       state.stateToken = ''
       state.requiresMFA = false
+      state.mfaEnrollmentRequired = false
       state.renewable = renewable
       localStorage.setItem('token', token)
       localStorage.setItem('renewable', String(renewable))
       localStorage.removeItem('state')
     },
 
-    auth_need_mfa (state) {
+    auth_need_mfa (state, { method, enrollmentRequired }) {
       state.requiresMFA = true
+      state.mfaMethod = method || 'totp'
+      state.mfaEnrollmentRequired = enrollmentRequired || false
     },
 
     auth_error (state) {
@@ -148,7 +153,7 @@ export const UserStore = new Vuex.Store({
           }
           return
         }
-        commit('auth_need_mfa')
+        commit('auth_need_mfa', { method: res.data.mfaMethod, enrollmentRequired: res.data.mfaEnrollmentRequired })
       } catch (err) {
         commit('auth_error')
         throw err
@@ -188,6 +193,24 @@ export const UserStore = new Vuex.Store({
 
     async authorize ({ commit, state }, otp) {
       const res = await axios({ url: '/api/authorize', data: { otp: otp, state: state.stateToken }, method: 'POST' })
+      await this.dispatch('handleAuthorized', res)
+    },
+
+    async authorizePush ({ commit, state }) {
+      const push = await axios({ url: '/api/authorize/push', method: 'POST' })
+      const transaction = push.data.transaction
+      // poll until the push is approved, a denial is returned as an error
+      while (true) {
+        const res = await axios({ url: '/api/authorize', data: { transaction: transaction, state: state.stateToken }, method: 'POST' })
+        if (res.status !== 202) {
+          await this.dispatch('handleAuthorized', res)
+          return
+        }
+        await new Promise((resolve, reject) => setTimeout(resolve, 2000))
+      }
+    },
+
+    async handleAuthorized ({ commit, state }, res) {
       const resState = res.data.state
       if (state.stateToken !== resState) {
         console.log('State token was malformed during request flow!')
@@ -210,8 +233,13 @@ export const UserStore = new Vuex.Store({
     async logout ({ commit }) {
       commit('logout')
       try {
-        await Vue.prototype.$axios.post('/api/logout')
+        const res = await Vue.prototype.$axios.post('/api/logout')
         delete Vue.prototype.$axios.defaults.headers.common['X-Session-Token']
+        // End the session with the auth provider also, if it has one
+        if (res.headers['x-redirect']) {
+          window.location = res.headers['x-redirect']
+          return
+        }
         this.dispatch('initStore')
       } catch (err) {
         console.error(err)
@@ -224,6 +252,8 @@ export const UserStore = new Vuex.Store({
   getters: {
     isLoggedIn: state => !!state.token,
     requiresMFA: state => state.requiresMFA,
+    mfaMethod: state => state.mfaMethod,
+    mfaEnrollmentRequired: state => state.mfaEnrollmentRequired,
     authStatus: state => state.status,
     user: state => state.user,
     token: state => state.token,